@@ -0,0 +1,50 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type (
+	// AddressRequest is an address to save to the address book.
+	AddressRequest struct {
+		Name       string `json:"name"`
+		Line1      string `json:"line1"`
+		Line2      string `json:"line2"`
+		City       string `json:"city"`
+		PostalCode string `json:"postal_code"`
+		Country    string `json:"country"`
+		Phone      string `json:"phone"`
+	}
+
+	// Address is a saved address.
+	Address struct {
+		ID uint `json:"id"`
+		AddressRequest
+		CreatedAt time.Time `json:"created_at"`
+	}
+)
+
+// ListAddresses returns the address book of the session.
+func (c *Client) ListAddresses(ctx context.Context) ([]Address, error) {
+	var addresses []Address
+	err := c.do(ctx, http.MethodGet, "/addresses", nil, &addresses)
+	return addresses, err
+}
+
+// CreateAddress saves an address to the address book. Invalid addresses fail with an *Error listing
+// the problem of each field.
+func (c *Client) CreateAddress(ctx context.Context, address AddressRequest) (*Address, error) {
+	var saved Address
+	if err := c.do(ctx, http.MethodPost, "/addresses", address, &saved); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// DeleteAddress removes an address from the address book.
+func (c *Client) DeleteAddress(ctx context.Context, id uint) error {
+	return c.do(ctx, http.MethodDelete, "/addresses/"+strconv.FormatUint(uint64(id), 10), nil, nil)
+}
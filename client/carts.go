@@ -0,0 +1,230 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type (
+	// Cart is a cart of the session. Total is the grand total to pay. ReservedUntil is when the stock
+	// held for its limited items during flash sales is released, nil while none is held.
+	Cart struct {
+		ID            uint           `json:"id"`
+		Name          string         `json:"name"`
+		Status        string         `json:"status"`
+		Subtotal      float64        `json:"subtotal"`
+		DiscountTotal float64        `json:"discount_total"`
+		Tax           float64        `json:"tax"`
+		Shipping      float64        `json:"shipping"`
+		Credit        float64        `json:"credit"`
+		Total         float64        `json:"total"`
+		Version       int            `json:"version"`
+		Items         []CartItem     `json:"items"`
+		Discounts     []CartDiscount `json:"discounts"`
+		Metadata      map[string]any `json:"metadata,omitempty"`
+		ReservedUntil *time.Time     `json:"reserved_until,omitempty"`
+	}
+
+	// CartItem is an item of a cart. TierQuantity is the quantity from which the volume discount Price
+	// comes from applies, 0 at the regular price. Items added with a bundle share their BundleGroup, 0 for
+	// items added on their own, and are removed together.
+	CartItem struct {
+		ID           uint           `json:"id"`
+		Product      string         `json:"product"`
+		Quantity     int            `json:"quantity"`
+		Price        float64        `json:"price"`
+		TierQuantity int            `json:"tier_quantity,omitempty"`
+		BundleGroup  uint           `json:"bundle_group,omitempty"`
+		Bundle       string         `json:"bundle,omitempty"`
+		Metadata     map[string]any `json:"metadata,omitempty"`
+	}
+
+	// CartDiscount is a promotion applied to a cart.
+	CartDiscount struct {
+		Promotion string  `json:"promotion"`
+		Amount    float64 `json:"amount"`
+	}
+
+	// CartItemPage is a page of cart items. NextCursor is passed to ListItems to fetch the following
+	// page and is empty on the last page.
+	CartItemPage struct {
+		Items      []CartItem `json:"items"`
+		NextCursor string     `json:"next_cursor,omitempty"`
+	}
+
+	// CartChange is an entry of the history of a cart. Action is one of added, removed, restored,
+	// merged, repriced, gift_card, referral, checked_out and expired; Amount is the new price of
+	// repriced items and the credit of redeemed gift cards. UndoneAt is set once the change was undone with Undo.
+	CartChange struct {
+		Action   string     `json:"action"`
+		Product  string     `json:"product,omitempty"`
+		Quantity int        `json:"quantity,omitempty"`
+		Amount   float64    `json:"amount,omitempty"`
+		Time     time.Time  `json:"time"`
+		UndoneAt *time.Time `json:"undone_at,omitempty"`
+	}
+
+	// CartChangePage is a page of the history of a cart, newest first. NextCursor is passed to History
+	// to fetch older changes and is empty on the last page.
+	CartChangePage struct {
+		Changes    []CartChange `json:"changes"`
+		NextCursor string       `json:"next_cursor,omitempty"`
+	}
+
+	// CartRef works on one cart of the session, see Client.Cart.
+	CartRef struct {
+		client *Client
+		name   string
+	}
+)
+
+// ListCarts returns the open carts of the session.
+func (c *Client) ListCarts(ctx context.Context) ([]Cart, error) {
+	var carts []Cart
+	err := c.do(ctx, http.MethodGet, "/carts", nil, &carts)
+	return carts, err
+}
+
+// CreateCart creates a named cart.
+func (c *Client) CreateCart(ctx context.Context, name string) (*Cart, error) {
+	return c.cart(ctx, http.MethodPost, "/carts", map[string]string{"name": name})
+}
+
+// RenameCart renames a cart.
+func (c *Client) RenameCart(ctx context.Context, name, newName string) (*Cart, error) {
+	return c.cart(ctx, http.MethodPatch, "/carts/"+url.PathEscape(name), map[string]string{"name": newName})
+}
+
+// DeleteCart deletes a cart.
+func (c *Client) DeleteCart(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/carts/"+url.PathEscape(name), nil, nil)
+}
+
+// Cart returns the cart of the session with the name, the default cart when it is empty.
+func (c *Client) Cart(name string) CartRef {
+	return CartRef{client: c, name: name}
+}
+
+// Get returns the cart.
+func (r CartRef) Get(ctx context.Context) (*Cart, error) {
+	return r.client.cart(ctx, http.MethodGet, r.path("/cart", nil), nil)
+}
+
+// SetMetadata sets the keys of metadata on the metadata of the cart, keys set to nil are removed.
+func (r CartRef) SetMetadata(ctx context.Context, metadata map[string]any) (*Cart, error) {
+	return r.client.cart(ctx, http.MethodPatch, r.path("/cart", nil), map[string]any{"metadata": metadata})
+}
+
+// ListItems returns the page of items following the cursor, the first page when it is empty. A limit
+// of 0 uses the default page size.
+func (r CartRef) ListItems(ctx context.Context, cursor string, limit int) (*CartItemPage, error) {
+	query := url.Values{}
+	if cursor != "" {
+		query.Set("after", cursor)
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var page CartItemPage
+	if err := r.client.do(ctx, http.MethodGet, r.path("/cart/items", query), nil, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// AddItem adds a quantity of the product to the cart.
+func (r CartRef) AddItem(ctx context.Context, product string, quantity int) (*Cart, error) {
+	body := map[string]any{"product": product, "quantity": quantity}
+	return r.client.cart(ctx, http.MethodPost, r.path("/cart/items", nil), body)
+}
+
+// AddBundle adds a quantity of the bundle with the product ID to the cart, as one item per component.
+func (r CartRef) AddBundle(ctx context.Context, bundleID uint, quantity int) (*Cart, error) {
+	body := map[string]any{"bundle_id": bundleID, "quantity": quantity}
+	return r.client.cart(ctx, http.MethodPost, r.path("/cart/bundles", nil), body)
+}
+
+// SetItemMetadata sets the keys of metadata on the metadata of an item, keys set to nil are removed.
+func (r CartRef) SetItemMetadata(ctx context.Context, itemID uint, metadata map[string]any) (*Cart, error) {
+	return r.client.cart(ctx, http.MethodPatch, r.path(itemPath(itemID), nil), map[string]any{"metadata": metadata})
+}
+
+// RemoveItem removes an item from the cart, with the other items of its bundle.
+func (r CartRef) RemoveItem(ctx context.Context, itemID uint) (*Cart, error) {
+	return r.client.cart(ctx, http.MethodDelete, r.path(itemPath(itemID), nil), nil)
+}
+
+// ListDeletedItems returns the items removed from the cart, most recently removed first.
+func (r CartRef) ListDeletedItems(ctx context.Context) ([]CartItem, error) {
+	var items []CartItem
+	err := r.client.do(ctx, http.MethodGet, r.path("/cart/deleted-items", nil), nil, &items)
+	return items, err
+}
+
+// History returns the page of changes of the cart following the cursor, the most recent ones when it is
+// empty. A limit of 0 uses the default page size.
+func (r CartRef) History(ctx context.Context, cursor string, limit int) (*CartChangePage, error) {
+	query := url.Values{}
+	if cursor != "" {
+		query.Set("after", cursor)
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var page CartChangePage
+	if err := r.client.do(ctx, http.MethodGet, r.path("/cart/history", query), nil, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// RestoreItem puts a removed item back in the cart.
+func (r CartRef) RestoreItem(ctx context.Context, itemID uint) (*Cart, error) {
+	return r.client.cart(ctx, http.MethodPost, r.path(itemPath(itemID)+"/restore", nil), nil)
+}
+
+// Undo undoes the latest change to the cart that wasn't undone yet: added units are taken out again and
+// removed items put back. Changes older than the undo window of the server can't be undone.
+func (r CartRef) Undo(ctx context.Context) (*Cart, error) {
+	return r.client.cart(ctx, http.MethodPost, r.path("/cart/undo", nil), nil)
+}
+
+// RedeemGiftCard applies the balance of a gift card to the cart.
+func (r CartRef) RedeemGiftCard(ctx context.Context, code string) (*Cart, error) {
+	return r.client.cart(ctx, http.MethodPost, r.path("/cart/gift-card", nil), map[string]string{"code": code})
+}
+
+// ApplyReferralCode records a referral code on the cart.
+func (r CartRef) ApplyReferralCode(ctx context.Context, code string) (*Cart, error) {
+	return r.client.cart(ctx, http.MethodPost, r.path("/cart/referral-code", nil), map[string]string{"code": code})
+}
+
+// path returns the path of an endpoint of the cart, with the query.
+func (r CartRef) path(path string, query url.Values) string {
+	if r.name != "" {
+		if query == nil {
+			query = url.Values{}
+		}
+		query.Set("cart", r.name)
+	}
+	if len(query) == 0 {
+		return path
+	}
+	return path + "?" + query.Encode()
+}
+
+// cart sends a request answered with a cart.
+func (c *Client) cart(ctx context.Context, method, path string, body any) (*Cart, error) {
+	var cart Cart
+	if err := c.do(ctx, method, path, body, &cart); err != nil {
+		return nil, err
+	}
+	return &cart, nil
+}
+
+func itemPath(id uint) string {
+	return "/cart/items/" + strconv.FormatUint(uint64(id), 10)
+}
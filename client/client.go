@@ -0,0 +1,187 @@
+// Package client is a thin client of version 1 of the JSON cart API of the shop, for other services
+// to call it without writing HTTP requests. Its types mirror the schemas of the OpenAPI description
+// served at /api/v1/openapi.json, which can be used to generate a fuller client instead.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultTimeout bounds the requests of clients created with New.
+const defaultTimeout = 10 * time.Second
+
+type (
+	// Client calls the API as one guest cart session. Its first request to a cart endpoint starts the
+	// session unless SetTokens restored one; expired access tokens are refreshed transparently.
+	Client struct {
+		// BaseURL is the URL the API is served under, e.g. "https://shop.example/api/v1"
+		BaseURL string
+		// HTTPClient sends the requests
+		HTTPClient *http.Client
+
+		mu     sync.Mutex
+		tokens *TokenPair
+	}
+
+	// TokenPair holds the tokens of a session. Services keep it to resume the session later.
+	TokenPair struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+
+	// Error is an error answered by the API, decoded from its RFC 7807 problem details.
+	Error struct {
+		Type     string       `json:"type"`
+		Title    string       `json:"title"`
+		Status   int          `json:"status"`
+		Detail   string       `json:"detail,omitempty"`
+		Instance string       `json:"instance,omitempty"`
+		Errors   []FieldError `json:"errors,omitempty"`
+	}
+
+	// FieldError is the problem with one field of an invalid request.
+	FieldError struct {
+		Field  string `json:"field"`
+		Detail string `json:"detail"`
+	}
+)
+
+// New returns a client of the API served under baseURL.
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+func (e *Error) Error() string {
+	if e.Detail == "" {
+		return fmt.Sprintf("api: %d %s", e.Status, e.Title)
+	}
+	return fmt.Sprintf("api: %d %s: %s", e.Status, e.Title, e.Detail)
+}
+
+// IsStatus reports whether err is an Error answered with the status.
+func IsStatus(err error, status int) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Status == status
+}
+
+// Tokens returns the tokens of the session, nil before it is started.
+func (c *Client) Tokens() *TokenPair {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tokens
+}
+
+// SetTokens resumes a session whose tokens were returned by Tokens.
+func (c *Client) SetTokens(tokens *TokenPair) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tokens = tokens
+}
+
+// StartSession starts a new guest cart session, replacing the session of the client.
+func (c *Client) StartSession(ctx context.Context) (*TokenPair, error) {
+	var tokens TokenPair
+	if err := c.send(ctx, http.MethodPost, "/auth/token", "", nil, &tokens); err != nil {
+		return nil, err
+	}
+	c.SetTokens(&tokens)
+	return &tokens, nil
+}
+
+// Refresh exchanges the refresh token of the session for new tokens.
+func (c *Client) Refresh(ctx context.Context) (*TokenPair, error) {
+	current := c.Tokens()
+	if current == nil {
+		return nil, errors.New("api: no session to refresh")
+	}
+	var tokens TokenPair
+	body := map[string]string{"refresh_token": current.RefreshToken}
+	if err := c.send(ctx, http.MethodPost, "/auth/refresh", "", body, &tokens); err != nil {
+		return nil, err
+	}
+	c.SetTokens(&tokens)
+	return &tokens, nil
+}
+
+// do sends an authenticated request, starting the session first when there is none and refreshing
+// the tokens once when the access token is rejected.
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	tokens := c.Tokens()
+	if tokens == nil {
+		var err error
+		if tokens, err = c.StartSession(ctx); err != nil {
+			return err
+		}
+	}
+
+	err := c.send(ctx, method, path, tokens.AccessToken, body, out)
+	if !IsStatus(err, http.StatusUnauthorized) {
+		return err
+	}
+	if tokens, err = c.Refresh(ctx); err != nil {
+		return err
+	}
+	return c.send(ctx, method, path, tokens.AccessToken, body, out)
+}
+
+// send sends a request with the JSON body, if any, and decodes the JSON response into out, if any.
+// Responses other than 2xx are returned as *Error.
+func (c *Client) send(ctx context.Context, method, path, token string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return decodeError(resp)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// decodeError returns the error of a failed response, from its problem details when it has them.
+func decodeError(resp *http.Response) error {
+	apiErr := &Error{Status: resp.StatusCode, Title: http.StatusText(resp.StatusCode)}
+	if err := json.NewDecoder(resp.Body).Decode(apiErr); err != nil || apiErr.Status == 0 {
+		apiErr.Status = resp.StatusCode
+	}
+	return apiErr
+}
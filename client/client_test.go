@@ -0,0 +1,150 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"interview/client"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAPI serves the endpoints the tests call, accepting only the latest access token it issued
+type fakeAPI struct {
+	issued atomic.Int32
+	token  atomic.Value
+}
+
+func (f *fakeAPI) issue(w http.ResponseWriter) {
+	n := f.issued.Add(1)
+	access := "access-" + strconv.Itoa(int(n))
+	f.token.Store(access)
+	writeJSON(w, http.StatusOK, client.TokenPair{AccessToken: access, RefreshToken: "refresh", TokenType: "Bearer", ExpiresIn: 900})
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method + " " + r.URL.Path {
+	case "POST /api/v1/auth/token":
+		f.issue(w)
+		return
+	case "POST /api/v1/auth/refresh":
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["refresh_token"] != "refresh" {
+			writeJSON(w, http.StatusUnauthorized, client.Error{Status: http.StatusUnauthorized})
+			return
+		}
+		f.issue(w)
+		return
+	}
+
+	if r.Header.Get("Authorization") != "Bearer "+f.token.Load().(string) {
+		writeJSON(w, http.StatusUnauthorized, client.Error{Type: "about:blank", Title: "Unauthorized", Status: http.StatusUnauthorized})
+		return
+	}
+	switch r.Method + " " + r.URL.Path {
+	case "POST /api/v1/cart/items":
+		var body struct {
+			Product  string `json:"product"`
+			Quantity int    `json:"quantity"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		name := r.URL.Query().Get("cart")
+		writeJSON(w, http.StatusCreated, client.Cart{Name: name, Items: []client.CartItem{{ID: 1, Product: body.Product, Quantity: body.Quantity}}})
+	case "GET /api/v1/cart/items":
+		writeJSON(w, http.StatusOK, client.CartItemPage{NextCursor: r.URL.RawQuery})
+	case "DELETE /api/v1/cart/items/7":
+		writeJSON(w, http.StatusNotFound, client.Error{Type: "about:blank", Title: "Not Found", Status: http.StatusNotFound, Detail: "item not found"})
+	case "DELETE /api/v1/carts/weekly shop":
+		w.WriteHeader(http.StatusNoContent)
+	case "POST /api/v1/addresses":
+		writeJSON(w, http.StatusUnprocessableEntity, client.Error{
+			Title: "Unprocessable Entity", Status: http.StatusUnprocessableEntity, Detail: "invalid address",
+			Errors: []client.FieldError{{Field: "city", Detail: "is required"}},
+		})
+	default:
+		http.Error(w, "404 page not found", http.StatusNotFound)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func TestClient(t *testing.T) {
+	api := &fakeAPI{}
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+	ctx := context.Background()
+
+	t.Run("Starts A Session", func(t *testing.T) {
+		c := client.New(server.URL + "/api/v1/")
+		cart, err := c.Cart("").AddItem(ctx, "shoe", 2)
+		require.NoError(t, err)
+		assert.Equal(t, []client.CartItem{{ID: 1, Product: "shoe", Quantity: 2}}, cart.Items)
+		require.NotNil(t, c.Tokens())
+		assert.Equal(t, "refresh", c.Tokens().RefreshToken)
+	})
+
+	t.Run("Named Cart", func(t *testing.T) {
+		c := client.New(server.URL + "/api/v1")
+		cart, err := c.Cart("gifts").AddItem(ctx, "shoe", 1)
+		require.NoError(t, err)
+		assert.Equal(t, "gifts", cart.Name)
+	})
+
+	t.Run("Page Parameters", func(t *testing.T) {
+		c := client.New(server.URL + "/api/v1")
+		page, err := c.Cart("gifts").ListItems(ctx, "12", 5)
+		require.NoError(t, err)
+		assert.Equal(t, "after=12&cart=gifts&limit=5", page.NextCursor)
+	})
+
+	t.Run("Refreshes Expired Tokens", func(t *testing.T) {
+		c := client.New(server.URL + "/api/v1")
+		c.SetTokens(&client.TokenPair{AccessToken: "expired", RefreshToken: "refresh"})
+		_, err := c.Cart("").AddItem(ctx, "shoe", 1)
+		require.NoError(t, err)
+		assert.NotEqual(t, "expired", c.Tokens().AccessToken)
+	})
+
+	t.Run("Session Ended", func(t *testing.T) {
+		c := client.New(server.URL + "/api/v1")
+		c.SetTokens(&client.TokenPair{AccessToken: "expired", RefreshToken: "revoked"})
+		_, err := c.Cart("").AddItem(ctx, "shoe", 1)
+		assert.True(t, client.IsStatus(err, http.StatusUnauthorized))
+	})
+
+	t.Run("Escapes Cart Names", func(t *testing.T) {
+		c := client.New(server.URL + "/api/v1")
+		assert.NoError(t, c.DeleteCart(ctx, "weekly shop"))
+	})
+
+	t.Run("Problem Details", func(t *testing.T) {
+		c := client.New(server.URL + "/api/v1")
+		_, err := c.Cart("").RemoveItem(ctx, 7)
+		assert.EqualError(t, err, "api: 404 Not Found: item not found")
+		assert.True(t, client.IsStatus(err, http.StatusNotFound))
+	})
+
+	t.Run("Invalid Fields", func(t *testing.T) {
+		c := client.New(server.URL + "/api/v1")
+		_, err := c.CreateAddress(ctx, client.AddressRequest{Name: "Ada"})
+		var apiErr *client.Error
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, []client.FieldError{{Field: "city", Detail: "is required"}}, apiErr.Errors)
+	})
+
+	t.Run("Error Without Problem Details", func(t *testing.T) {
+		c := client.New(server.URL + "/api/v1")
+		_, err := c.ListCarts(ctx)
+		assert.EqualError(t, err, "api: 404 Not Found")
+	})
+}
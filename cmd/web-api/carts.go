@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"interview/internal/analytics"
+	"interview/internal/api"
+	"interview/internal/cart"
+	"interview/internal/config"
+	"interview/internal/repo"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+const cartsUsage = "usage: carts list [-status open|closed] | carts show <session-id> [name] | carts close <cart-id>"
+
+// runCarts lets support engineers inspect and close carts without direct database access.
+func runCarts(args []string) error {
+	if len(args) == 0 {
+		return errors.New(cartsUsage)
+	}
+
+	switch args[0] {
+	case "list":
+		fs := flag.NewFlagSet("carts list", flag.ExitOnError)
+		status := fs.String("status", "", "only list carts with this status")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		r, _, err := openRepository()
+		if err != nil {
+			return err
+		}
+		return listCarts(r, *status)
+	case "show":
+		if len(args) < 2 || len(args) > 3 {
+			return errors.New(cartsUsage)
+		}
+		name := cart.DefaultName
+		if len(args) == 3 {
+			name = args[2]
+		}
+		r, _, err := openRepository()
+		if err != nil {
+			return err
+		}
+		return showCart(r, args[1], name)
+	case "close":
+		if len(args) != 2 {
+			return errors.New(cartsUsage)
+		}
+		id, err := strconv.ParseUint(args[1], 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid cart ID %q", args[1])
+		}
+		r, cfg, err := openRepository()
+		if err != nil {
+			return err
+		}
+		// Closing a cart checks it out, which rewards the referrer of the cart and grants the downloads
+		// of its digital products
+		r.SetReferralReward(cfg.ReferralReward)
+		r.SetDownloadLimits(cfg.DownloadLimit, cfg.DownloadTTL)
+		return closeCart(cfg, r, uint(id))
+	default:
+		return errors.New(cartsUsage)
+	}
+}
+
+// closeCart checks out the cart, recording the checkout for analytics.
+func closeCart(cfg config.Config, r *repo.Repository, id uint) error {
+	tracker, err := api.NewTracker(cfg, r)
+	if err != nil {
+		return err
+	}
+	if tracker != nil {
+		tracker.Start()
+		defer tracker.Close()
+	}
+
+	if err := r.CloseCart(id); err != nil {
+		return err
+	}
+	if closed, err := r.GetCart(id); err == nil {
+		tracker.Track(analytics.Event{
+			Type: analytics.TypeCheckout, SessionID: closed.SessionID, UserID: closed.UserID, Total: closed.Total,
+		})
+	}
+	fmt.Printf("Cart %d closed\n", id)
+	return nil
+}
+
+func listCarts(r *repo.Repository, status string) error {
+	carts, err := r.GetAllCarts()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSESSION\tNAME\tSTATUS\tITEMS\tTOTAL\tUPDATED")
+	for _, c := range carts {
+		if status != "" && c.Status != status {
+			continue
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%.2f\t%s\n",
+			c.ID, c.SessionID, c.Name, c.Status, len(c.CartItems), c.Total, c.UpdatedAt.Format("2006-01-02 15:04"))
+	}
+	return w.Flush()
+}
+
+func showCart(r *repo.Repository, sessionID, name string) error {
+	c, err := r.GetExistingCart(sessionID, name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Cart %d %q (%s)\nSession: %s\nTotal:   %.2f\n\n", c.ID, c.Name, c.Status, c.SessionID, c.Total)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ITEM\tPRODUCT\tQUANTITY\tPRICE")
+	for _, item := range c.CartItems {
+		fmt.Fprintf(w, "%d\t%s\t%d\t%.2f\n", item.ID, item.ProductName, item.Quantity, item.Price)
+	}
+	return w.Flush()
+}
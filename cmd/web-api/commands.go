@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"interview/internal/api"
+	"interview/internal/repo"
+	"interview/internal/search"
+	"interview/internal/seed"
+	"log"
+)
+
+// runServe migrates the database and starts the HTTP server.
+func runServe(args []string) error {
+	if err := flag.NewFlagSet("serve", flag.ExitOnError).Parse(args); err != nil {
+		return err
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	db, err := repo.InitDatabase(cfg)
+	if err != nil {
+		return err
+	}
+
+	api.InitAPI(db, templateFS, cfg)
+	return nil
+}
+
+// runMigrate applies ("up") or reverts ("down") the database schema.
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || (fs.Arg(0) != "up" && fs.Arg(0) != "down") {
+		return errors.New("usage: migrate up|down")
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	db, err := repo.Connect(cfg)
+	if err != nil {
+		return err
+	}
+
+	if fs.Arg(0) == "up" {
+		err = repo.Migrate(db)
+	} else {
+		err = repo.MigrateDown(db)
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Migration %s completed", fs.Arg(0))
+	return nil
+}
+
+// runSeed populates the database with sample products and demo carts.
+func runSeed(args []string) error {
+	if err := flag.NewFlagSet("seed", flag.ExitOnError).Parse(args); err != nil {
+		return err
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	db, err := repo.InitDatabase(cfg)
+	if err != nil {
+		return err
+	}
+
+	r := repo.NewRepository(db)
+	if cfg.SearchURL != "" {
+		r.SetProductIndex(search.NewElasticsearch(cfg.SearchURL, cfg.SearchIndex))
+	}
+	if err := seed.Run(r); err != nil {
+		return err
+	}
+
+	log.Printf("Seed data created")
+	return nil
+}
+
+// runSearch maintains the product search index.
+func runSearch(args []string) error {
+	if len(args) != 1 || args[0] != "reindex" {
+		return errors.New("usage: search reindex")
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if cfg.SearchURL == "" {
+		return errors.New("SEARCH_URL is not configured")
+	}
+
+	db, err := repo.Connect(cfg)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	index := search.NewElasticsearch(cfg.SearchURL, cfg.SearchIndex)
+	if err := index.EnsureIndex(ctx); err != nil {
+		return err
+	}
+	r := repo.NewRepository(db)
+	r.SetProductIndex(index)
+
+	count, err := r.ReindexProducts(ctx)
+	if err != nil {
+		return err
+	}
+	log.Printf("Indexed %d products", count)
+	return nil
+}
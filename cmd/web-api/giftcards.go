@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"interview/internal/giftcard"
+	"interview/internal/repo"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+const giftCardsUsage = "usage: giftcards issue <amount> [code] | giftcards show <code>"
+
+// runGiftCards issues gift cards and shows their balance and redemption history.
+func runGiftCards(args []string) error {
+	if len(args) == 0 {
+		return errors.New(giftCardsUsage)
+	}
+
+	switch args[0] {
+	case "issue":
+		if len(args) < 2 || len(args) > 3 {
+			return errors.New(giftCardsUsage)
+		}
+		amount, err := strconv.ParseFloat(args[1], 64)
+		if err != nil {
+			return fmt.Errorf("invalid amount %q", args[1])
+		}
+		r, _, err := openRepository()
+		if err != nil {
+			return err
+		}
+		code := ""
+		if len(args) == 3 {
+			code = args[2]
+		} else if code, err = giftcard.NewCode(); err != nil {
+			return err
+		}
+		card, err := r.CreateGiftCard(code, amount)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Gift card %s issued with a balance of %.2f\n", card.Code, card.Balance)
+		return nil
+	case "show":
+		if len(args) != 2 {
+			return errors.New(giftCardsUsage)
+		}
+		r, _, err := openRepository()
+		if err != nil {
+			return err
+		}
+		return showGiftCard(r, args[1])
+	default:
+		return errors.New(giftCardsUsage)
+	}
+}
+
+func showGiftCard(r *repo.Repository, code string) error {
+	card, err := r.GetGiftCard(code)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Gift card %s\nBalance: %.2f\n\n", card.Code, card.Balance)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "REDEEMED\tCART\tAMOUNT")
+	for _, redemption := range card.Redemptions {
+		fmt.Fprintf(w, "%s\t%d\t%.2f\n",
+			redemption.CreatedAt.Format("2006-01-02 15:04"), redemption.CartID, redemption.Amount)
+	}
+	return w.Flush()
+}
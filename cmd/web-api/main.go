@@ -2,10 +2,11 @@ package main
 
 import (
 	"embed"
-	"interview/internal/api"
+	"fmt"
 	"interview/internal/config"
 	"interview/internal/repo"
 	"log"
+	"os"
 
 	"github.com/joho/godotenv"
 )
@@ -13,22 +14,89 @@ import (
 //go:embed templates
 var templateFS embed.FS
 
+const usage = `Usage: web-api <command> [arguments]
+
+Commands:
+  serve          start the HTTP server (default)
+  migrate up     create or update the database schema
+  migrate down   drop all application tables
+  seed           populate sample products and demo carts
+  carts list [-status open|closed]
+                 list carts
+  carts show <session-id>
+                 show a cart and its items
+  carts close <cart-id>
+                 close an open cart
+  giftcards issue <amount> [code]
+                 issue a gift card, generating a code unless given
+  giftcards show <code>
+                 show the balance and redemptions of a gift card
+  users list     list users, their roles and customer groups
+  users role <user-id> customer|support|admin
+                 change the role of a user
+  users group <user-id> retail|wholesale|vip
+                 change the customer group, and so the price list, of a user
+  search reindex rebuild the product search index
+`
+
 func main() {
-	// Load environment variables from .env file
-	if err := godotenv.Load(); err != nil {
-		log.Printf("Warning: Error loading .env file: %v", err)
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	// Commands load the configuration once their arguments are checked, so asking for help or getting
+	// them wrong doesn't need a configured environment
+	var err error
+	switch command {
+	case "serve":
+		err = runServe(args)
+	case "migrate":
+		err = runMigrate(args)
+	case "seed":
+		err = runSeed(args)
+	case "carts":
+		err = runCarts(args)
+	case "giftcards":
+		err = runGiftCards(args)
+	case "users":
+		err = runUsers(args)
+	case "search":
+		err = runSearch(args)
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
 	}
 
-	// Load configuration
-	cfg, err := config.Load()
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		log.Fatalf("%s failed: %v", command, err)
+	}
+}
+
+// loadConfig loads the configuration from the environment and the .env file.
+func loadConfig() (config.Config, error) {
+	if err := godotenv.Load(config.EnvFile); err != nil {
+		log.Printf("Warning: Error loading .env file: %v", err)
 	}
-	// Initialize database
-	db, err := repo.InitDatabase(*cfg)
+	cfg, err := config.Load()
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		return config.Config{}, fmt.Errorf("failed to load configuration: %w", err)
 	}
+	return *cfg, nil
+}
 
-	api.InitAPI(db, templateFS, *cfg)
+// openRepository loads the configuration and connects to the database without migrating it.
+func openRepository() (*repo.Repository, config.Config, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, cfg, err
+	}
+	db, err := repo.Connect(cfg)
+	if err != nil {
+		return nil, cfg, err
+	}
+	return repo.NewRepository(db), cfg, nil
 }
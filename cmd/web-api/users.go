@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"interview/internal/auth"
+	"interview/internal/pricelist"
+	"interview/internal/repo"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+const usersUsage = "usage: users list | users role <user-id> customer|support|admin | users group <user-id> retail|wholesale|vip"
+
+// runUsers lists users, grants them staff roles and assigns them to customer groups.
+func runUsers(args []string) error {
+	if len(args) == 0 {
+		return errors.New(usersUsage)
+	}
+
+	switch args[0] {
+	case "list":
+		if len(args) != 1 {
+			return errors.New(usersUsage)
+		}
+		r, _, err := openRepository()
+		if err != nil {
+			return err
+		}
+		return listUsers(r)
+	case "role":
+		if len(args) != 3 {
+			return errors.New(usersUsage)
+		}
+		id, err := strconv.ParseUint(args[1], 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid user ID %q", args[1])
+		}
+		if !auth.IsValidRole(args[2]) {
+			return fmt.Errorf("unknown role %q", args[2])
+		}
+		r, _, err := openRepository()
+		if err != nil {
+			return err
+		}
+		if err := r.SetUserRole(uint(id), args[2]); err != nil {
+			return err
+		}
+		fmt.Printf("User %d is now %s\n", id, args[2])
+		return nil
+	case "group":
+		if len(args) != 3 {
+			return errors.New(usersUsage)
+		}
+		id, err := strconv.ParseUint(args[1], 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid user ID %q", args[1])
+		}
+		if !pricelist.ValidGroup(args[2]) {
+			return fmt.Errorf("unknown customer group %q", args[2])
+		}
+		r, _, err := openRepository()
+		if err != nil {
+			return err
+		}
+		if err := r.SetUserGroup(uint(id), args[2]); err != nil {
+			return err
+		}
+		fmt.Printf("User %d now buys at %s prices\n", id, args[2])
+		return nil
+	default:
+		return errors.New(usersUsage)
+	}
+}
+
+func listUsers(r *repo.Repository) error {
+	users, err := r.ListUsers()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tEMAIL\tNAME\tPROVIDER\tROLE\tGROUP")
+	for _, u := range users {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", u.ID, u.Email, u.Name, u.Provider, u.Role, u.CustomerGroup)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,58 @@
+// Package abandonment scores open carts by the risk of being abandoned, so campaigns can target the
+// segments worth a nudge.
+package abandonment
+
+import (
+	"context"
+	"interview/internal/cart"
+	"interview/internal/repo"
+	"time"
+)
+
+// batchSize is how many carts are scored per query
+const batchSize = 500
+
+// Scorer stores the abandonment score and segment of every open cart with items, see
+// cart.AbandonmentScore
+type Scorer struct {
+	repo *repo.Repository
+	now  func() time.Time
+}
+
+// NewScorer creates a Scorer
+func NewScorer(r *repo.Repository) *Scorer {
+	return &Scorer{repo: r, now: time.Now}
+}
+
+// SetClock replaces the clock of the scorer, for tests.
+func (s *Scorer) SetClock(now func() time.Time) {
+	s.now = now
+}
+
+// Run scores the open carts with items and returns how many were scored. Carts emptied since they were
+// last scored leave their segment.
+func (s *Scorer) Run(ctx context.Context) (int, error) {
+	// Whole seconds survive the database, which ClearAbandonmentScores compares with
+	now := s.now().Truncate(time.Second)
+	var scored int
+	var after uint
+	for {
+		carts, err := s.repo.ListCartSignals(after, batchSize, now)
+		if err != nil {
+			return scored, err
+		}
+		for _, c := range carts {
+			if err := ctx.Err(); err != nil {
+				return scored, err
+			}
+			if err := s.repo.SetAbandonmentScore(c.CartID, cart.AbandonmentScore(c.Signals), now); err != nil {
+				return scored, err
+			}
+			scored++
+			after = c.CartID
+		}
+		if len(carts) < batchSize {
+			return scored, s.repo.ClearAbandonmentScores(now)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package abandonment_test
+
+import (
+	"context"
+	"interview/internal/abandonment"
+	cartpkg "interview/internal/cart"
+	"interview/internal/repo"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		name    string
+		signals cartpkg.AbandonmentSignals
+		score   int
+		segment string
+	}{
+		{"Fresh Cart Of A Regular", cartpkg.AbandonmentSignals{IdleFor: time.Minute, Total: 20, PriorOrders: 2}, 0, cartpkg.SegmentLow},
+		{"Idle For A Day", cartpkg.AbandonmentSignals{IdleFor: 24 * time.Hour, Total: 100}, 35, cartpkg.SegmentMedium},
+		{"Anonymous And Reminded", cartpkg.AbandonmentSignals{IdleFor: 72 * time.Hour, Total: 500, Anonymous: true, Reminders: 5}, 100, cartpkg.SegmentHigh},
+		{"Prior Orders Lower The Risk", cartpkg.AbandonmentSignals{IdleFor: 72 * time.Hour, Total: 500, PriorOrders: 1}, 60, cartpkg.SegmentHigh},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score := cartpkg.AbandonmentScore(tt.signals)
+			assert.Equal(t, tt.score, score)
+			assert.Equal(t, tt.segment, cartpkg.AbandonmentSegment(score))
+		})
+	}
+}
+
+func TestScorer(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, repo.Migrate(db))
+	cartRepo := repo.NewRepository(db)
+	now := time.Now()
+
+	// A returning customer with a fresh cart, and an anonymous cart idle for days
+	user, err := cartRepo.FindOrCreateUser("github", "1", "jane@example.com", "Jane")
+	require.NoError(t, err)
+	for _, session := range []string{"jane-old", "jane"} {
+		c, err := cartRepo.GetOrCreateCart(session, cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 1, 10))
+		require.NoError(t, cartRepo.AssignCartToUser(session, user.ID))
+		if session == "jane-old" {
+			require.NoError(t, cartRepo.CloseCart(c.ID))
+		}
+	}
+	idle, err := cartRepo.GetOrCreateCart("anonymous", cartpkg.DefaultName)
+	require.NoError(t, err)
+	require.NoError(t, cartRepo.AddCartItem(idle.ID, "bag", 4, 50))
+	require.NoError(t, db.Model(&cartpkg.Cart{}).Where("id = ?", idle.ID).
+		UpdateColumn("updated_at", now.Add(-72*time.Hour)).Error)
+	empty, err := cartRepo.GetOrCreateCart("empty", cartpkg.DefaultName)
+	require.NoError(t, err)
+
+	scorer := abandonment.NewScorer(cartRepo)
+	scorer.SetClock(func() time.Time { return now })
+
+	t.Run("Scores Open Carts With Items", func(t *testing.T) {
+		scored, err := scorer.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 2, scored)
+
+		jane, err := cartRepo.GetExistingCart("jane", cartpkg.DefaultName)
+		require.NoError(t, err)
+		assert.Equal(t, cartpkg.SegmentLow, jane.AbandonmentSegment)
+		require.NotNil(t, jane.ScoredAt)
+
+		idle, err := cartRepo.GetCart(idle.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 80, idle.AbandonmentScore)
+		assert.Equal(t, cartpkg.SegmentHigh, idle.AbandonmentSegment)
+		assert.WithinDuration(t, now.Add(-72*time.Hour), idle.UpdatedAt, time.Second, "scoring isn't a change of the cart")
+
+		empty, err := cartRepo.GetCart(empty.ID)
+		require.NoError(t, err)
+		assert.Empty(t, empty.AbandonmentSegment)
+	})
+
+	t.Run("Emptied Carts Leave Their Segment", func(t *testing.T) {
+		idle, err := cartRepo.GetCart(idle.ID)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.RemoveCartItem(idle.ID, idle.CartItems[0].ID))
+		scorer.SetClock(func() time.Time { return now.Add(time.Hour) })
+		scored, err := scorer.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, scored)
+
+		idle, err = cartRepo.GetCart(idle.ID)
+		require.NoError(t, err)
+		assert.Empty(t, idle.AbandonmentSegment)
+		stats, err := cartRepo.ListSegmentStats()
+		require.NoError(t, err)
+		assert.Equal(t, []repo.SegmentStats{{Segment: cartpkg.SegmentLow, Carts: 1, Value: 10}}, stats)
+	})
+}
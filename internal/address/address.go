@@ -0,0 +1,110 @@
+// Package address defines the postal addresses of the address book and how they are validated.
+package address
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+type (
+	// Address is a postal address saved to the address book of a user or, before they log in, of a
+	// session. Orders will copy the chosen shipping and billing addresses.
+	Address struct {
+		gorm.Model
+		// UserID links the address to a logged-in user, nil for addresses of anonymous sessions
+		UserID *uint `gorm:"index"`
+		// SessionID is the session the address was saved in
+		SessionID string `gorm:"size:255;index;not null"`
+		// Name is the name of the recipient
+		Name string `gorm:"size:255;not null"`
+		// Line1 and Line2 are the street address
+		Line1 string `gorm:"size:255;not null"`
+		Line2 string `gorm:"size:255"`
+		// City is the city or town
+		City string `gorm:"size:255;not null"`
+		// PostalCode is the postal code in the format of the country
+		PostalCode string `gorm:"size:32;not null"`
+		// Country is the ISO 3166-1 alpha-2 code of the country
+		Country string `gorm:"size:2;not null"`
+		// Phone is an optional phone number for the carrier
+		Phone string `gorm:"size:64"`
+	}
+
+	// ValidationErrors maps the names of invalid fields to what is wrong with them
+	ValidationErrors map[string]string
+)
+
+// postalCodes lists the countries addresses can be saved for, with the format of their postal codes
+var postalCodes = map[string]*regexp.Regexp{
+	"AT": regexp.MustCompile(`^\d{4}$`),
+	"BE": regexp.MustCompile(`^\d{4}$`),
+	"CA": regexp.MustCompile(`^[A-Z]\d[A-Z] ?\d[A-Z]\d$`),
+	"CH": regexp.MustCompile(`^\d{4}$`),
+	"DE": regexp.MustCompile(`^\d{5}$`),
+	"DK": regexp.MustCompile(`^\d{4}$`),
+	"ES": regexp.MustCompile(`^\d{5}$`),
+	"FR": regexp.MustCompile(`^\d{5}$`),
+	"GB": regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2}$`),
+	"IE": regexp.MustCompile(`^[A-Z]\d[\dW] ?[A-Z\d]{4}$`),
+	"IT": regexp.MustCompile(`^\d{5}$`),
+	"NL": regexp.MustCompile(`^\d{4} ?[A-Z]{2}$`),
+	"PL": regexp.MustCompile(`^\d{2}-\d{3}$`),
+	"SE": regexp.MustCompile(`^\d{3} ?\d{2}$`),
+	"US": regexp.MustCompile(`^\d{5}(-\d{4})?$`),
+}
+
+// SupportedCountry reports whether addresses can be saved for the country code.
+func SupportedCountry(country string) bool {
+	_, ok := postalCodes[country]
+	return ok
+}
+
+// Normalize trims all fields and upper-cases the country and postal code.
+func (a *Address) Normalize() {
+	a.Name = strings.TrimSpace(a.Name)
+	a.Line1 = strings.TrimSpace(a.Line1)
+	a.Line2 = strings.TrimSpace(a.Line2)
+	a.City = strings.TrimSpace(a.City)
+	a.PostalCode = strings.ToUpper(strings.TrimSpace(a.PostalCode))
+	a.Country = strings.ToUpper(strings.TrimSpace(a.Country))
+	a.Phone = strings.TrimSpace(a.Phone)
+}
+
+// Validate checks the required fields and that the postal code has the format of the country. It
+// returns ValidationErrors listing every invalid field.
+func (a Address) Validate() error {
+	errs := ValidationErrors{}
+	required := map[string]string{"name": a.Name, "line1": a.Line1, "city": a.City}
+	for field, value := range required {
+		if value == "" {
+			errs[field] = "is required"
+		}
+	}
+
+	if pattern, ok := postalCodes[a.Country]; !ok {
+		errs["country"] = "is not supported"
+	} else if !pattern.MatchString(a.PostalCode) {
+		errs["postal_code"] = "is not valid for " + a.Country
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (e ValidationErrors) Error() string {
+	fields := make([]string, 0, len(e))
+	for field := range e {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for i, field := range fields {
+		fields[i] = fmt.Sprintf("%s %s", field, e[field])
+	}
+	return "invalid address: " + strings.Join(fields, ", ")
+}
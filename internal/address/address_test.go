@@ -0,0 +1,52 @@
+package address_test
+
+import (
+	"interview/internal/address"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	valid := address.Address{Name: "Jane Doe", Line1: "Hauptstr. 1", City: "Berlin", PostalCode: "10115", Country: "DE"}
+
+	tests := []struct {
+		name       string
+		postalCode string
+		country    string
+		invalid    string
+	}{
+		{"German Postal Code", "10115", "de", ""},
+		{"Short German Postal Code", "1011", "DE", "postal_code"},
+		{"US ZIP+4", "94105-1234", "US", ""},
+		{"UK Postcode", "sw1a 1aa", "GB", ""},
+		{"Dutch Postcode", "1012AB", "NL", ""},
+		{"Dutch Postcode For Germany", "1012 AB", "DE", "postal_code"},
+		{"Unsupported Country", "12345", "XX", "country"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := valid
+			a.PostalCode = tt.postalCode
+			a.Country = tt.country
+			a.Normalize()
+
+			err := a.Validate()
+			if tt.invalid == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var invalid address.ValidationErrors
+			require.ErrorAs(t, err, &invalid)
+			assert.Contains(t, invalid, tt.invalid)
+		})
+	}
+
+	t.Run("Missing Fields", func(t *testing.T) {
+		a := address.Address{Name: " ", PostalCode: "10115", Country: "DE"}
+		a.Normalize()
+		err := a.Validate()
+		assert.EqualError(t, err, "invalid address: city is required, line1 is required, name is required")
+	})
+}
@@ -0,0 +1,182 @@
+// Package analytics records shop activity (page views, add-to-cart and checkout) for analysis. Events
+// are buffered in memory and written to the configured sinks in batches by a background goroutine,
+// so tracking never slows down requests.
+package analytics
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// Event types
+const (
+	TypePageView  = "page_view"
+	TypeAddToCart = "add_to_cart"
+	TypeCheckout  = "checkout"
+)
+
+const (
+	// bufferSize is how many events may wait to be written before new ones are dropped
+	bufferSize = 4096
+	// batchSize is the largest number of events written to the sinks at once
+	batchSize = 100
+	// writeTimeout bounds writing one batch to the sinks
+	writeTimeout = 10 * time.Second
+)
+
+type (
+	// Event is something a visitor did. Events are only ever appended, so they are stored without
+	// the timestamps and soft deletion of gorm.Model.
+	Event struct {
+		ID uint `gorm:"primaryKey" json:"-"`
+		// Type is one of the Type constants
+		Type string `gorm:"size:32;index;not null" json:"type"`
+		// SessionID is the cart session of the visitor
+		SessionID string `gorm:"size:255;index" json:"session_id,omitempty"`
+		// UserID is the logged-in user, nil for anonymous visitors
+		UserID *uint `json:"user_id,omitempty"`
+		// Path is the page viewed
+		Path string `gorm:"size:2048" json:"path,omitempty"`
+		// Product and Quantity are what was added to the cart
+		Product  string `gorm:"size:255" json:"product,omitempty"`
+		Quantity int    `json:"quantity,omitempty"`
+		// Total is the value of the cart checked out
+		Total float64 `json:"total,omitempty"`
+		// Time is when the event happened
+		Time time.Time `gorm:"index;not null" json:"time"`
+	}
+
+	// Sink stores or forwards batches of events
+	Sink interface {
+		Write(ctx context.Context, events []Event) error
+	}
+
+	// Tracker buffers events and writes them to a sink in the background. A nil Tracker tracks nothing.
+	Tracker struct {
+		sink          Sink
+		flushInterval time.Duration
+		events        chan Event
+		stop          chan struct{}
+		stopped       chan struct{}
+		stopOnce      sync.Once
+	}
+
+	// multiSink writes every batch to several sinks
+	multiSink []Sink
+)
+
+// TableName keeps the events table name explicit about what kind of events they are.
+func (Event) TableName() string {
+	return "analytics_events"
+}
+
+// MultiSink returns a sink writing to all of the given sinks. A failing sink doesn't keep the
+// events from the others.
+func MultiSink(sinks ...Sink) Sink {
+	if len(sinks) == 1 {
+		return sinks[0]
+	}
+	return multiSink(sinks)
+}
+
+func (m multiSink) Write(ctx context.Context, events []Event) error {
+	var errs []error
+	for _, sink := range m {
+		if err := sink.Write(ctx, events); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// NewTracker creates a Tracker writing to sink at least every flushInterval. Call Start to begin
+// writing and Close to write what is left.
+func NewTracker(sink Sink, flushInterval time.Duration) *Tracker {
+	return &Tracker{
+		sink:          sink,
+		flushInterval: flushInterval,
+		events:        make(chan Event, bufferSize),
+		stop:          make(chan struct{}),
+		stopped:       make(chan struct{}),
+	}
+}
+
+// Track queues the event without blocking; it is dropped when the buffer is full. A zero Time is set
+// to now.
+func (t *Tracker) Track(e Event) {
+	if t == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	select {
+	case t.events <- e:
+	default:
+		log.Printf("Analytics buffer full, dropping %s event", e.Type)
+	}
+}
+
+// Start writes the tracked events in the background until Close is called.
+func (t *Tracker) Start() {
+	go t.run()
+}
+
+// Close stops the tracker after writing the events tracked so far.
+func (t *Tracker) Close() {
+	if t == nil {
+		return
+	}
+	t.stopOnce.Do(func() { close(t.stop) })
+	<-t.stopped
+}
+
+func (t *Tracker) run() {
+	defer close(t.stopped)
+	ticker := time.NewTicker(t.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Event, 0, batchSize)
+	for {
+		select {
+		case e := <-t.events:
+			batch = append(batch, e)
+			if len(batch) == batchSize {
+				batch = t.flush(batch)
+			}
+		case <-ticker.C:
+			batch = t.flush(batch)
+		case <-t.stop:
+			// Events tracked before Close are still buffered
+			for {
+				select {
+				case e := <-t.events:
+					batch = append(batch, e)
+					if len(batch) == batchSize {
+						batch = t.flush(batch)
+					}
+				default:
+					t.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush writes the batch to the sink and returns it emptied. Batches that fail are dropped rather
+// than retried, so a sink that is down can't make events pile up.
+func (t *Tracker) flush(batch []Event) []Event {
+	if len(batch) == 0 {
+		return batch
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+	if err := t.sink.Write(ctx, batch); err != nil {
+		log.Printf("Failed to write %d analytics events: %v", len(batch), err)
+	}
+	return batch[:0]
+}
@@ -0,0 +1,147 @@
+package analytics_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"interview/internal/analytics"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingSink keeps the batches written to it
+type recordingSink struct {
+	mu      sync.Mutex
+	batches [][]analytics.Event
+	err     error
+}
+
+func (s *recordingSink) Write(_ context.Context, events []analytics.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, append([]analytics.Event(nil), events...))
+	return s.err
+}
+
+func (s *recordingSink) events() []analytics.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var all []analytics.Event
+	for _, batch := range s.batches {
+		all = append(all, batch...)
+	}
+	return all
+}
+
+func TestTracker(t *testing.T) {
+	t.Run("Flushes On Close", func(t *testing.T) {
+		sink := &recordingSink{}
+		tracker := analytics.NewTracker(sink, time.Hour)
+		tracker.Start()
+		for i := 0; i < 250; i++ {
+			tracker.Track(analytics.Event{Type: analytics.TypePageView, Path: "/"})
+		}
+		tracker.Close()
+
+		events := sink.events()
+		require.Len(t, events, 250)
+		assert.False(t, events[0].Time.IsZero())
+		for _, batch := range sink.batches {
+			assert.LessOrEqual(t, len(batch), 100)
+		}
+	})
+
+	t.Run("Flushes Periodically", func(t *testing.T) {
+		sink := &recordingSink{}
+		tracker := analytics.NewTracker(sink, 10*time.Millisecond)
+		tracker.Start()
+		defer tracker.Close()
+
+		tracker.Track(analytics.Event{Type: analytics.TypeAddToCart, Product: "shoe", Quantity: 1})
+		assert.Eventually(t, func() bool { return len(sink.events()) == 1 }, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("Nil Tracker", func(t *testing.T) {
+		var tracker *analytics.Tracker
+		tracker.Track(analytics.Event{Type: analytics.TypePageView})
+		tracker.Close()
+	})
+
+	t.Run("Multi Sink", func(t *testing.T) {
+		failing := &recordingSink{err: errors.New("down")}
+		working := &recordingSink{}
+		err := analytics.MultiSink(failing, working).Write(context.Background(), []analytics.Event{{Type: analytics.TypeCheckout}})
+		assert.Error(t, err)
+		assert.Len(t, working.events(), 1)
+	})
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "analytics.jsonl")
+	sink, err := analytics.NewFileSink(path)
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	require.NoError(t, sink.Write(context.Background(), []analytics.Event{
+		{Type: analytics.TypePageView, SessionID: "s1", Path: "/products", Time: now},
+		{Type: analytics.TypeAddToCart, SessionID: "s1", Product: "shoe", Quantity: 2, Time: now},
+	}))
+	require.NoError(t, sink.Close())
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var lines []analytics.Event
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e analytics.Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		lines = append(lines, e)
+	}
+	require.Len(t, lines, 2)
+	assert.Equal(t, "/products", lines[0].Path)
+	assert.Equal(t, 2, lines[1].Quantity)
+	assert.True(t, now.Equal(lines[1].Time))
+}
+
+func TestSegmentSink(t *testing.T) {
+	var body struct {
+		Batch []map[string]interface{} `json:"batch"`
+	}
+	var writeKey string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeKey, _, _ = r.BasicAuth()
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+	}))
+	defer server.Close()
+
+	sink := analytics.NewSegmentSink("write-key")
+	sink.Endpoint = server.URL
+	userID := uint(7)
+	require.NoError(t, sink.Write(context.Background(), []analytics.Event{
+		{Type: analytics.TypePageView, SessionID: "s1", Path: "/"},
+		{Type: analytics.TypeAddToCart, SessionID: "s1", UserID: &userID, Product: "shoe", Quantity: 2},
+	}))
+
+	assert.Equal(t, "write-key", writeKey)
+	require.Len(t, body.Batch, 2)
+	assert.Equal(t, "page", body.Batch[0]["type"])
+	assert.Equal(t, "s1", body.Batch[0]["anonymousId"])
+	assert.Equal(t, "track", body.Batch[1]["type"])
+	assert.Equal(t, "Product Added", body.Batch[1]["event"])
+	assert.Equal(t, "7", body.Batch[1]["userId"])
+
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	})
+	assert.Error(t, sink.Write(context.Background(), []analytics.Event{{Type: analytics.TypeCheckout}}))
+}
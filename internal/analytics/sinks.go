@@ -0,0 +1,158 @@
+package analytics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SegmentEndpoint is the batch endpoint of the Segment HTTP tracking API
+const SegmentEndpoint = "https://api.segment.io/v1/batch"
+
+type (
+	// Store persists events in the database
+	Store interface {
+		// SaveAnalyticsEvents inserts the events
+		SaveAnalyticsEvents(events []Event) error
+	}
+
+	// DBSink writes events to the analytics_events table
+	DBSink struct {
+		store Store
+	}
+
+	// FileSink appends events to a file as JSON lines
+	FileSink struct {
+		mu   sync.Mutex
+		file *os.File
+	}
+
+	// SegmentSink sends events to Segment, page views as page calls and everything else as track calls
+	SegmentSink struct {
+		WriteKey string
+		Endpoint string
+		Client   *http.Client
+	}
+
+	// segmentMessage is a call in a Segment batch
+	segmentMessage struct {
+		Type        string                 `json:"type"`
+		Event       string                 `json:"event,omitempty"`
+		AnonymousID string                 `json:"anonymousId,omitempty"`
+		UserID      string                 `json:"userId,omitempty"`
+		Properties  map[string]interface{} `json:"properties"`
+		Timestamp   time.Time              `json:"timestamp"`
+	}
+)
+
+// segmentEvents maps event types to the names of Segment's e-commerce spec
+var segmentEvents = map[string]string{
+	TypeAddToCart: "Product Added",
+	TypeCheckout:  "Order Completed",
+}
+
+// NewDBSink creates a sink saving events to store.
+func NewDBSink(store Store) *DBSink {
+	return &DBSink{store: store}
+}
+
+func (s *DBSink) Write(_ context.Context, events []Event) error {
+	return s.store.SaveAnalyticsEvents(events)
+}
+
+// NewFileSink opens the file at path for appending, creating it when needed.
+func NewFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open analytics file: %w", err)
+	}
+	return &FileSink{file: f}, nil
+}
+
+func (s *FileSink) Write(_ context.Context, events []Event) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			return fmt.Errorf("failed to encode analytics event: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write analytics file: %w", err)
+	}
+	return nil
+}
+
+// Close closes the file.
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// NewSegmentSink creates a sink sending events to Segment with the write key of a source.
+func NewSegmentSink(writeKey string) *SegmentSink {
+	return &SegmentSink{
+		WriteKey: writeKey,
+		Endpoint: SegmentEndpoint,
+		Client:   &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (s *SegmentSink) Write(ctx context.Context, events []Event) error {
+	batch := make([]segmentMessage, len(events))
+	for i, e := range events {
+		msg := segmentMessage{AnonymousID: e.SessionID, Timestamp: e.Time, Properties: map[string]interface{}{}}
+		if e.UserID != nil {
+			msg.UserID = strconv.FormatUint(uint64(*e.UserID), 10)
+		}
+		if e.Type == TypePageView {
+			msg.Type = "page"
+			msg.Properties["path"] = e.Path
+		} else {
+			msg.Type = "track"
+			msg.Event = e.Type
+			if name, ok := segmentEvents[e.Type]; ok {
+				msg.Event = name
+			}
+			if e.Product != "" {
+				msg.Properties["name"] = e.Product
+				msg.Properties["quantity"] = e.Quantity
+			}
+			if e.Total != 0 {
+				msg.Properties["total"] = e.Total
+			}
+		}
+		batch[i] = msg
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"batch": batch})
+	if err != nil {
+		return fmt.Errorf("failed to encode segment batch: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create segment request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(s.WriteKey, "")
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send events to segment: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("segment responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
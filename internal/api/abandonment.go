@@ -0,0 +1,105 @@
+package api
+
+import (
+	"interview/internal/cart"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type (
+	// SegmentResponse counts the open carts of an abandonment segment and sums their totals.
+	SegmentResponse struct {
+		Segment string  `json:"segment"`
+		Carts   int64   `json:"carts"`
+		Value   float64 `json:"value"`
+	}
+
+	// SegmentCartResponse is an open cart of an abandonment segment. Email is the address of its user,
+	// omitted for anonymous carts.
+	SegmentCartResponse struct {
+		CartID    uint       `json:"cart_id"`
+		SessionID string     `json:"session_id"`
+		CartName  string     `json:"cart_name"`
+		UserID    *uint      `json:"user_id,omitempty"`
+		Email     string     `json:"email,omitempty"`
+		Score     int        `json:"score"`
+		Total     float64    `json:"total"`
+		UpdatedAt time.Time  `json:"updated_at"`
+		ScoredAt  *time.Time `json:"scored_at,omitempty"`
+	}
+
+	// SegmentCartPage is a page of the carts of an abandonment segment. NextCursor is passed as the after
+	// parameter to fetch the following page and is omitted on the last page.
+	SegmentCartPage struct {
+		Carts      []SegmentCartResponse `json:"carts"`
+		NextCursor string                `json:"next_cursor,omitempty"`
+	}
+)
+
+// ListSegments returns the number and value of the open carts of each abandonment segment, from the
+// lowest risk to the highest.
+func (h *AdminHandler) ListSegments(c *gin.Context) {
+	stats, err := h.repoFor(c).ListSegmentStats()
+	if err != nil {
+		log.Printf("Failed to count segment carts: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to count segment carts")
+		return
+	}
+
+	responses := make([]SegmentResponse, len(cart.Segments))
+	for i, segment := range cart.Segments {
+		responses[i] = SegmentResponse{Segment: segment}
+		for _, s := range stats {
+			if s.Segment == segment {
+				responses[i].Carts, responses[i].Value = s.Carts, s.Value
+			}
+		}
+	}
+	c.JSON(http.StatusOK, responses)
+}
+
+// ListSegmentCarts returns a page of the open carts of an abandonment segment, with the email addresses
+// of their users for campaigns, paginated with ?after=<cursor>&limit=<n>.
+func (h *AdminHandler) ListSegmentCarts(c *gin.Context) {
+	segment := c.Param("segment")
+	if !cart.ValidSegment(segment) {
+		respondWithProblem(c, http.StatusNotFound, "unknown segment")
+		return
+	}
+	after, limit, ok := parsePageParams(c)
+	if !ok {
+		return
+	}
+
+	// Fetch one extra row to know whether another page follows
+	carts, err := h.repoFor(c).ListSegmentCarts(segment, after, limit+1)
+	if err != nil {
+		log.Printf("Failed to list segment carts: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to list segment carts")
+		return
+	}
+
+	page := SegmentCartPage{Carts: []SegmentCartResponse{}}
+	if len(carts) > limit {
+		carts = carts[:limit]
+		page.NextCursor = strconv.FormatUint(uint64(carts[limit-1].Cart.ID), 10)
+	}
+	for _, sc := range carts {
+		page.Carts = append(page.Carts, SegmentCartResponse{
+			CartID:    sc.Cart.ID,
+			SessionID: sc.Cart.SessionID,
+			CartName:  sc.Cart.Name,
+			UserID:    sc.Cart.UserID,
+			Email:     sc.Email,
+			Score:     sc.Cart.AbandonmentScore,
+			Total:     sc.Cart.Total,
+			UpdatedAt: sc.Cart.UpdatedAt,
+			ScoredAt:  sc.Cart.ScoredAt,
+		})
+	}
+	c.JSON(http.StatusOK, page)
+}
@@ -0,0 +1,86 @@
+package api_test
+
+import (
+	"encoding/json"
+	"interview/internal/api"
+	"interview/internal/cart"
+	"interview/internal/repo"
+	"interview/internal/storage"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAbandonmentSegments(t *testing.T) {
+	ts := setupTest(t)
+	ts.clearDatabase(t)
+
+	cartRepo := repo.NewRepository(ts.db)
+	user, err := cartRepo.FindOrCreateUser("github", "1", "jane@example.com", "Jane")
+	require.NoError(t, err)
+	now := time.Now()
+	scored := map[string]int{"jane": 80, "anonymous": 65, "fresh": 10}
+	ids := map[string]uint{}
+	for _, session := range []string{"jane", "anonymous", "fresh"} {
+		c, err := cartRepo.GetOrCreateCart(session, cart.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 1, 25.0))
+		require.NoError(t, cartRepo.SetAbandonmentScore(c.ID, scored[session], now))
+		ids[session] = c.ID
+	}
+	require.NoError(t, cartRepo.AssignCartToUser("jane", user.ID))
+
+	router := gin.New()
+	media := storage.NewLocal(t.TempDir(), "/media", []byte("test_secret"))
+	api.NewAdminHandler(ts.db, media, time.Hour).RegisterRoutes(router, gin.Accounts{"admin": "secret"})
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Counts Every Segment", func(t *testing.T) {
+		w := get("/admin/carts/segments")
+		require.Equal(t, http.StatusOK, w.Code)
+		var segments []api.SegmentResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &segments))
+		assert.Equal(t, []api.SegmentResponse{
+			{Segment: cart.SegmentLow, Carts: 1, Value: 25},
+			{Segment: cart.SegmentMedium},
+			{Segment: cart.SegmentHigh, Carts: 2, Value: 50},
+		}, segments)
+	})
+
+	t.Run("Lists The Carts Of A Segment", func(t *testing.T) {
+		w := get("/admin/carts/segments/high?limit=1")
+		require.Equal(t, http.StatusOK, w.Code)
+		var page api.SegmentCartPage
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		require.Len(t, page.Carts, 1)
+		assert.Equal(t, ids["jane"], page.Carts[0].CartID)
+		assert.Equal(t, "jane@example.com", page.Carts[0].Email)
+		assert.Equal(t, 80, page.Carts[0].Score)
+		require.NotEmpty(t, page.NextCursor)
+
+		w = get("/admin/carts/segments/high?limit=1&after=" + page.NextCursor)
+		require.Equal(t, http.StatusOK, w.Code)
+		page = api.SegmentCartPage{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		require.Len(t, page.Carts, 1)
+		assert.Equal(t, ids["anonymous"], page.Carts[0].CartID)
+		assert.Empty(t, page.Carts[0].Email)
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("Rejects Unknown Segments", func(t *testing.T) {
+		w := get("/admin/carts/segments/urgent")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,298 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"interview/internal/auth"
+	"interview/internal/i18n"
+	"interview/internal/mail"
+	"interview/internal/pricing"
+	"interview/internal/ratelimit"
+	"interview/internal/repo"
+	"interview/internal/user"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/csrf"
+)
+
+// currencySessionKey is the session key of the currency prices are shown in
+const currencySessionKey = "currency"
+
+var emailChangeTemplate = texttemplate.Must(texttemplate.New("email-change").Parse(`Hello,
+
+please confirm that you want to use this email address for your account:
+
+{{ .Link }}
+
+The link expires in {{ .TTL }}. If you didn't ask for it, you can ignore this email.
+`))
+
+// AccountData contains data to be rendered in the account template.
+type AccountData struct {
+	Error         string
+	Notice        string
+	Locale        string
+	CSRFFieldName template.HTML
+	Email         string
+	// EmailChangeable is set for accounts identified by their email address, whose address can be
+	// changed once the new one is confirmed. Other accounts use the address of their login provider.
+	EmailChangeable bool
+	// EmailUnverified offers to send another link verifying the email address
+	EmailUnverified bool
+	// HasPassword asks for the current password before setting a new one
+	HasPassword bool
+	// PreferredLocale and PreferredCurrency are the preferences of the user, empty for the defaults
+	PreferredLocale   string
+	PreferredCurrency string
+	Locales           []string
+	Currencies        []string
+	// Sessions lists the sessions the user is logged in to, which they can log out of
+	Sessions []SessionView
+}
+
+// SetCurrencies sets the currencies customers can choose to see prices in.
+func (h *CartHandler) SetCurrencies(currencies *pricing.Currencies) {
+	h.currencies = currencies
+}
+
+// EnableEmailChanges lets users change their email address with a link signed by links and emailed
+// through mailer to the new address.
+func (h *CartHandler) EnableEmailChanges(links *auth.EmailChangeLinks, mailer mail.Mailer) {
+	h.emailChanges = links
+	h.mailer = mailer
+	h.emailChangeLimiter = ratelimit.NewLimiter(emailRequestsPerEmail, time.Hour)
+}
+
+// SetEmailChangeLimit sets how many email address changes each user may request per hour once they are
+// enabled. The limit may change while the server runs.
+func (h *CartHandler) SetEmailChangeLimit(perUser int) {
+	if h.emailChangeLimiter != nil {
+		h.emailChangeLimiter.SetLimit(perUser)
+	}
+}
+
+// ShowAccount renders the account page of the logged-in user.
+func (h *CartHandler) ShowAccount(c *gin.Context) {
+	session := sessions.Default(c)
+	u := h.accountUser(c, session)
+	if u == nil {
+		return
+	}
+
+	data := h.accountData(c, session, u)
+	if notices := session.Flashes(noticeFlash); len(notices) > 0 {
+		data.Notice = notices[0].(string)
+		if err := session.Save(); err != nil {
+			log.Printf("Failed to save session: %v", err)
+		}
+	}
+	h.renderAccount(c, data)
+}
+
+// UpdateAccount saves the preferred "locale" and "currency" of the logged-in user, sets the
+// "password" when given and emails a confirmation link when the "email" changed. The new password
+// needs to be repeated in "password_confirmation" and, if the user has one, the "current_password".
+func (h *CartHandler) UpdateAccount(c *gin.Context) {
+	session := sessions.Default(c)
+	u := h.accountUser(c, session)
+	if u == nil {
+		return
+	}
+	data := h.accountData(c, session, u)
+	fail := func(message string) {
+		data.Error = message
+		h.renderAccount(c, data)
+	}
+
+	locale := c.PostForm("locale")
+	if locale != "" && !i18n.IsSupported(locale) {
+		fail("Unsupported language")
+		return
+	}
+	currency := c.PostForm("currency")
+	if currency != "" && !h.currencies.IsSupported(currency) {
+		fail("Unsupported currency")
+		return
+	}
+
+	var passwordHash string
+	if password := c.PostForm("password"); password != "" {
+		if data.HasPassword && !auth.CheckPassword(u.PasswordHash, c.PostForm("current_password")) {
+			fail("The current password is wrong")
+			return
+		}
+		if err := auth.ValidatePassword(password); err != nil {
+			fail(errorMessage(err, "Invalid password"))
+			return
+		}
+		if password != c.PostForm("password_confirmation") {
+			fail("The passwords don't match")
+			return
+		}
+		hash, err := auth.HashPassword(password)
+		if err != nil {
+			log.Printf("Failed to hash password: %v", err)
+			fail("Failed to update account")
+			return
+		}
+		passwordHash = hash
+	}
+
+	email := strings.ToLower(strings.TrimSpace(c.PostForm("email")))
+	changeEmail := email != "" && email != strings.ToLower(u.Email)
+	if changeEmail {
+		if message := h.checkEmailChange(u, email); message != "" {
+			fail(message)
+			return
+		}
+	}
+
+	if err := h.repoFor(c).UpdatePreferences(u.ID, locale, currency); err != nil {
+		log.Printf("Failed to update preferences: %v", err)
+		fail("Failed to update account")
+		return
+	}
+	applyPreferences(session, locale, currency)
+	if passwordHash != "" {
+		if err := h.repoFor(c).SetPassword(u.ID, passwordHash); err != nil {
+			log.Printf("Failed to set password: %v", err)
+			fail("Failed to update account")
+			return
+		}
+	}
+
+	notice := "Your account was updated"
+	if changeEmail {
+		if err := h.sendEmailChange(u.ID, email); err != nil {
+			log.Printf("Failed to send email change link: %v", err)
+			fail("Failed to send the confirmation link")
+			return
+		}
+		notice = "Your account was updated, please confirm your new email address with the link we sent to it"
+	}
+	session.AddFlash(notice, noticeFlash)
+	if err := session.Save(); err != nil {
+		log.Printf("Failed to save session: %v", err)
+	}
+	c.Redirect(http.StatusFound, "/account")
+}
+
+// ConfirmEmailChange changes the email address of the user to the address the link was sent to.
+func (h *CartHandler) ConfirmEmailChange(c *gin.Context) {
+	session := sessions.Default(c)
+	userID, email, err := h.emailChanges.Verify(c.Request.URL.Query())
+	if err != nil {
+		h.redirectWithFlash(c, session, "This link is invalid or has expired")
+		return
+	}
+	if err := h.repoFor(c).ChangeEmail(userID, auth.ProviderEmail, email); err != nil {
+		h.redirectWithFlash(c, session, errorMessage(err, "Failed to change email address"))
+		return
+	}
+	redirectWithNotice(c, session, "Your email address was changed")
+}
+
+// checkEmailChange returns why the user can't change their email address to email, if they can't.
+func (h *CartHandler) checkEmailChange(u *user.User, email string) string {
+	if h.emailChanges == nil || u.Provider != auth.ProviderEmail {
+		return "The email address of this account is managed by its login provider"
+	}
+	if !validEmail(email) {
+		return "Please enter a valid email address"
+	}
+	other, err := h.repo.FindUserByEmail(email)
+	if err == nil && other.ID != u.ID {
+		return errorMessage(repo.ErrEmailTaken, "")
+	} else if err != nil && !errors.Is(err, repo.ErrUserNotFound) {
+		return errorMessage(err, "Failed to update account")
+	}
+	if !h.emailChangeLimiter.Allow(strconv.FormatUint(uint64(u.ID), 10)) {
+		return "Too many requests, please try again later"
+	}
+	return ""
+}
+
+// sendEmailChange emails the link confirming the new email address of the user to that address.
+func (h *CartHandler) sendEmailChange(userID uint, email string) error {
+	var body bytes.Buffer
+	err := emailChangeTemplate.Execute(&body, map[string]string{
+		"Link": h.emailChanges.URL(userID, email),
+		"TTL":  h.emailChanges.TTL().String(),
+	})
+	if err != nil {
+		return err
+	}
+	sendEmail(h.mailer, mail.Message{To: email, Subject: "Confirm your new email address", Body: body.String()})
+	return nil
+}
+
+// accountUser returns the logged-in user, or sends users who aren't logged in to the cart page.
+func (h *CartHandler) accountUser(c *gin.Context, session sessions.Session) *user.User {
+	if userID, ok := session.Get("user_id").(uint); ok {
+		if u, err := h.repoFor(c).GetUser(userID); err == nil {
+			return u
+		}
+	}
+	h.redirectWithFlash(c, session, "Please log in to manage your account")
+	return nil
+}
+
+func (h *CartHandler) accountData(c *gin.Context, session sessions.Session, u *user.User) AccountData {
+	data := AccountData{
+		Locale:            detectLocale(c, session).String(),
+		Email:             u.Email,
+		EmailChangeable:   h.emailChanges != nil && u.Provider == auth.ProviderEmail,
+		EmailUnverified:   h.verification != nil && u.Email != "" && u.EmailVerifiedAt == nil,
+		HasPassword:       u.PasswordHash != "",
+		PreferredLocale:   u.Locale,
+		PreferredCurrency: u.Currency,
+		Currencies:        h.currencies.Codes(),
+		Sessions:          h.sessionViews(c, session, u.ID),
+	}
+	for _, tag := range i18n.Supported {
+		data.Locales = append(data.Locales, tag.String())
+	}
+	return data
+}
+
+func (h *CartHandler) renderAccount(c *gin.Context, data AccountData) {
+	data.Error = i18n.T(data.Locale, data.Error)
+	data.Notice = i18n.T(data.Locale, data.Notice)
+	data.CSRFFieldName = csrf.TemplateField(c.Request)
+	if data.Error != "" {
+		c.Status(http.StatusUnprocessableEntity)
+	}
+	if err := h.templateFor(c).ExecuteTemplate(c.Writer, "account.html", data); err != nil {
+		log.Printf("Failed to render template: %v", err)
+		http.Error(c.Writer, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// applyPreferences shows the pages of the session in the locale and currency the user prefers. Empty
+// preferences go back to the defaults.
+func applyPreferences(session sessions.Session, locale, currency string) {
+	if locale != "" {
+		session.Set("locale", locale)
+	} else {
+		session.Delete("locale")
+	}
+	if currency != "" {
+		session.Set(currencySessionKey, currency)
+	} else {
+		session.Delete(currencySessionKey)
+	}
+}
+
+// sessionCurrency returns the currency the session shows prices in, empty for the currency of the shop.
+func sessionCurrency(session sessions.Session) string {
+	currency, _ := session.Get(currencySessionKey).(string)
+	return currency
+}
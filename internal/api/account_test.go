@@ -0,0 +1,223 @@
+package api_test
+
+import (
+	"fmt"
+	"interview/internal/auth"
+	"interview/internal/cart"
+	"interview/internal/pricing"
+	"interview/internal/user"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var emailChangeLinkPattern = regexp.MustCompile(`http://shop\.example\.com(/account/email/confirm\?\S+)`)
+
+// setupAccount returns a test environment with the account page and a session logged in to the
+// account of jane@example.com
+func setupAccount(t *testing.T) (*loginLinkSetup, *http.Cookie) {
+	t.Helper()
+	ts := setupLoginLinks(t, time.Minute)
+	ts.handler.SetCurrencies(pricing.NewCurrencies("EUR", map[string]float64{"USD": 1.08}))
+	ts.handler.EnableEmailChanges(auth.NewEmailChangeLinks("http://shop.example.com", []byte("secret"), time.Minute), ts.mailer)
+	ts.router.GET("/account", ts.handler.ShowAccount)
+	ts.router.POST("/account", ts.handler.UpdateAccount)
+	ts.router.POST("/account/sessions/:id/revoke", ts.handler.RevokeSession)
+	ts.router.GET(auth.EmailChangePath, ts.handler.ConfirmEmailChange)
+	return ts, ts.login(t)
+}
+
+// login logs a new session in to the account of jane@example.com
+func (ts *loginLinkSetup) login(t *testing.T) *http.Cookie {
+	t.Helper()
+	cookie := ts.createSession(t)
+	ts.makeRequest(t, http.MethodGet, ts.requestLink(t, "jane@example.com", cookie), nil, cookie)
+	return cookie
+}
+
+func (ts *loginLinkSetup) jane(t *testing.T) user.User {
+	t.Helper()
+	var u user.User
+	require.NoError(t, ts.db.Where("email = ? OR provider_user_id = ?", "jane@example.com", "jane@example.com").First(&u).Error)
+	return u
+}
+
+func TestAccount(t *testing.T) {
+	t.Run("Requires Login", func(t *testing.T) {
+		ts, _ := setupAccount(t)
+		cookie := ts.createSession(t)
+		w := ts.makeRequest(t, http.MethodGet, "/account", nil, cookie)
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Contains(t, ts.makeRequest(t, http.MethodGet, "/", nil, cookie).Body.String(), "Please log in to manage your account")
+	})
+
+	t.Run("Preferences", func(t *testing.T) {
+		ts, cookie := setupAccount(t)
+		w := ts.makeRequest(t, http.MethodGet, "/account", nil, cookie)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `<option value="USD" >USD</option>`)
+
+		w = ts.makeRequest(t, http.MethodPost, "/account", url.Values{"locale": {"de"}, "currency": {"USD"}}, cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "/account", w.Header().Get("Location"))
+		assert.Contains(t, ts.makeRequest(t, http.MethodGet, "/account", nil, cookie).Body.String(), "Ihr Konto wurde aktualisiert")
+
+		ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"shoe"}, "quantity": {"1"}}, cookie)
+		body := ts.makeRequest(t, http.MethodGet, "/", nil, cookie).Body.String()
+		assert.Contains(t, body, "Angemeldet als")
+		assert.Contains(t, body, "10.80 USD")
+
+		// The preferences follow the user to other browsers
+		body = ts.makeRequest(t, http.MethodGet, "/", nil, ts.login(t)).Body.String()
+		assert.Contains(t, body, "Angemeldet als")
+
+		w = ts.makeRequest(t, http.MethodPost, "/account", url.Values{"locale": {""}, "currency": {""}}, cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+		body = ts.makeRequest(t, http.MethodGet, "/", nil, cookie).Body.String()
+		assert.Contains(t, body, "Logged in as")
+		assert.Contains(t, body, "10.00")
+	})
+
+	t.Run("Unsupported Preferences", func(t *testing.T) {
+		ts, cookie := setupAccount(t)
+		w := ts.makeRequest(t, http.MethodPost, "/account", url.Values{"currency": {"JPY"}}, cookie)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Contains(t, w.Body.String(), "Unsupported currency")
+		w = ts.makeRequest(t, http.MethodPost, "/account", url.Values{"locale": {"fr"}}, cookie)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Equal(t, "", ts.jane(t).Currency)
+	})
+
+	t.Run("Password", func(t *testing.T) {
+		ts, cookie := setupAccount(t)
+		form := url.Values{"password": {"correct horse"}, "password_confirmation": {"correct horse"}}
+		require.Equal(t, http.StatusFound, ts.makeRequest(t, http.MethodPost, "/account", form, cookie).Code)
+		assert.True(t, auth.CheckPassword(ts.jane(t).PasswordHash, "correct horse"))
+
+		form = url.Values{"current_password": {"wrong horse"}, "password": {"battery staple"}, "password_confirmation": {"battery staple"}}
+		w := ts.makeRequest(t, http.MethodPost, "/account", form, cookie)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Contains(t, w.Body.String(), "The current password is wrong")
+
+		form.Set("current_password", "correct horse")
+		form.Set("password_confirmation", "battery")
+		w = ts.makeRequest(t, http.MethodPost, "/account", form, cookie)
+		assert.Contains(t, w.Body.String(), "The passwords don&#39;t match")
+
+		form.Set("password_confirmation", "battery staple")
+		require.Equal(t, http.StatusFound, ts.makeRequest(t, http.MethodPost, "/account", form, cookie).Code)
+		assert.True(t, auth.CheckPassword(ts.jane(t).PasswordHash, "battery staple"))
+	})
+
+	t.Run("Email Change", func(t *testing.T) {
+		ts, cookie := setupAccount(t)
+		w := ts.makeRequest(t, http.MethodPost, "/account", url.Values{"email": {"Jane.Doe@example.com"}}, cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "jane@example.com", ts.jane(t).Email, "the address only changes once confirmed")
+
+		var link string
+		select {
+		case msg := <-ts.mailer:
+			assert.Equal(t, "jane.doe@example.com", msg.To)
+			match := emailChangeLinkPattern.FindStringSubmatch(msg.Body)
+			require.NotNil(t, match, msg.Body)
+			link = match[1]
+		case <-time.After(5 * time.Second):
+			t.Fatal("no confirmation email sent")
+		}
+
+		w = ts.makeRequest(t, http.MethodGet, link, nil, ts.createSession(t))
+		require.Equal(t, http.StatusFound, w.Code)
+		var u user.User
+		require.NoError(t, ts.db.Where("email = ?", "jane.doe@example.com").First(&u).Error)
+		assert.Equal(t, "jane.doe@example.com", u.ProviderUserID)
+	})
+
+	t.Run("Email Taken", func(t *testing.T) {
+		ts, cookie := setupAccount(t)
+		require.NoError(t, ts.db.Create(&user.User{Provider: "google", ProviderUserID: "1", Email: "john@example.com"}).Error)
+		w := ts.makeRequest(t, http.MethodPost, "/account", url.Values{"email": {"john@example.com"}}, cookie)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Contains(t, w.Body.String(), "This email address is already used by another account")
+		assert.Empty(t, ts.mailer)
+	})
+
+	t.Run("Sessions", func(t *testing.T) {
+		ts, cookie := setupAccount(t)
+		other := ts.login(t)
+		var devices []user.Device
+		require.NoError(t, ts.db.Where("user_id = ?", ts.jane(t).ID).Order("id").Find(&devices).Error)
+		require.Len(t, devices, 2)
+		assert.NotEmpty(t, devices[0].IPHash)
+		assert.NotEqual(t, "192.0.2.1", devices[0].IPHash)
+
+		body := ts.makeRequest(t, http.MethodGet, "/account", nil, cookie).Body.String()
+		assert.Equal(t, 2, strings.Count(body, "/revoke"))
+		assert.Contains(t, body, "This session")
+
+		w := ts.makeRequest(t, http.MethodPost, fmt.Sprintf("/account/sessions/%d/revoke", devices[1].ID), url.Values{}, cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+		assert.Contains(t, ts.makeRequest(t, http.MethodGet, "/account", nil, cookie).Body.String(), "The session was logged out")
+		assert.NotContains(t, ts.makeRequest(t, http.MethodGet, "/", nil, other).Body.String(), "Logged in as")
+		assert.Contains(t, ts.makeRequest(t, http.MethodGet, "/", nil, cookie).Body.String(), "Logged in as")
+
+		// Logging in again isn't revoked
+		ts.makeRequest(t, http.MethodGet, ts.requestLink(t, "jane@example.com", other), nil, other)
+		assert.Contains(t, ts.makeRequest(t, http.MethodGet, "/", nil, other).Body.String(), "Logged in as")
+	})
+
+	t.Run("Session Limit", func(t *testing.T) {
+		for _, tt := range []struct {
+			name         string
+			evictedCarts string
+			laptopItems  int
+		}{
+			{"Carts Stay", user.EvictedCartsKeep, 1},
+			{"Carts Move", user.EvictedCartsMove, 0},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				ts, laptop := setupAccount(t)
+				ts.auth.SetSessionLimit(2, tt.evictedCarts, time.Hour)
+				ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"shoe"}, "quantity": {"1"}}, laptop)
+				phone := ts.login(t)
+				assert.NotContains(t, ts.makeRequest(t, http.MethodGet, "/", nil, phone).Body.String(), "You were logged out of your oldest session")
+
+				// The link is opened in another browser than it was requested from, which leaves no notice behind
+				tablet := ts.createSession(t)
+				ts.makeRequest(t, http.MethodGet, ts.requestLink(t, "jane@example.com", ts.createSession(t)), nil, tablet)
+				assert.Contains(t, ts.makeRequest(t, http.MethodGet, "/", nil, tablet).Body.String(), "You were logged out of your oldest session")
+				assert.NotContains(t, ts.makeRequest(t, http.MethodGet, "/", nil, laptop).Body.String(), "Logged in as")
+				assert.Contains(t, ts.makeRequest(t, http.MethodGet, "/", nil, phone).Body.String(), "Logged in as")
+
+				var devices []user.Device
+				require.NoError(t, ts.db.Order("id").Find(&devices).Error)
+				require.Len(t, devices, 4)
+				items := func(sessionID string) (count int64) {
+					require.NoError(t, ts.db.Table("cart_items").Joins("JOIN carts ON carts.id = cart_items.cart_id").
+						Where("carts.session_id = ? AND carts.status = ?", sessionID, cart.StatusOpen).Count(&count).Error)
+					return count
+				}
+				laptopItems, tabletItems := items(devices[0].SessionID), items(devices[2].SessionID)
+				assert.EqualValues(t, tt.laptopItems, laptopItems)
+				assert.EqualValues(t, 1-tt.laptopItems, tabletItems)
+			})
+		}
+	})
+
+	t.Run("Sessions Of Other Users", func(t *testing.T) {
+		ts, cookie := setupAccount(t)
+		require.NoError(t, ts.db.Create(&user.Device{SessionID: "john", LastSeenAt: time.Now(), FirstSeenAt: time.Now()}).Error)
+		var john user.Device
+		require.NoError(t, ts.db.Where("session_id = ?", "john").First(&john).Error)
+		w := ts.makeRequest(t, http.MethodPost, fmt.Sprintf("/account/sessions/%d/revoke", john.ID), url.Values{}, cookie)
+		assert.Equal(t, http.StatusFound, w.Code)
+		require.NoError(t, ts.db.First(&john, john.ID).Error)
+		assert.Nil(t, john.RevokedAt)
+	})
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"errors"
+	"interview/internal/address"
+	"interview/internal/repo"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type (
+	// AddressRequest is the JSON body accepted by POST /api/v1/addresses.
+	AddressRequest struct {
+		Name       string `json:"name"`
+		Line1      string `json:"line1"`
+		Line2      string `json:"line2"`
+		City       string `json:"city"`
+		PostalCode string `json:"postal_code"`
+		Country    string `json:"country"`
+		Phone      string `json:"phone"`
+	}
+
+	// AddressResponse is the JSON representation of a saved address.
+	AddressResponse struct {
+		ID uint `json:"id"`
+		AddressRequest
+		CreatedAt time.Time `json:"created_at"`
+	}
+)
+
+// APIListAddresses returns the address book of the authenticated session.
+func (h *CartHandler) APIListAddresses(c *gin.Context) {
+	addresses, err := h.repoFor(c).ListAddresses(0, c.GetString(apiSessionKey))
+	if err != nil {
+		log.Printf("Failed to list addresses: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to list addresses")
+		return
+	}
+	responses := make([]AddressResponse, len(addresses))
+	for i, a := range addresses {
+		responses[i] = newAddressResponse(a)
+	}
+	c.JSON(http.StatusOK, responses)
+}
+
+// APICreateAddress saves an address to the address book of the authenticated session. Invalid
+// addresses are answered with 422 and the problems of each field.
+func (h *CartHandler) APICreateAddress(c *gin.Context) {
+	var req AddressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	a := address.Address{
+		SessionID:  c.GetString(apiSessionKey),
+		Name:       req.Name,
+		Line1:      req.Line1,
+		Line2:      req.Line2,
+		City:       req.City,
+		PostalCode: req.PostalCode,
+		Country:    req.Country,
+		Phone:      req.Phone,
+	}
+	var invalid address.ValidationErrors
+	if err := h.repoFor(c).CreateAddress(&a); errors.As(err, &invalid) {
+		respondWithInvalidFields(c, http.StatusUnprocessableEntity, "invalid address", invalid)
+		return
+	} else if err != nil {
+		log.Printf("Failed to create address: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to create address")
+		return
+	}
+	c.JSON(http.StatusCreated, newAddressResponse(a))
+}
+
+// APIDeleteAddress removes an address from the address book of the authenticated session.
+func (h *CartHandler) APIDeleteAddress(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid address ID")
+		return
+	}
+	if err := h.repoFor(c).DeleteAddress(0, c.GetString(apiSessionKey), uint(id)); errors.Is(err, repo.ErrAddressNotFound) {
+		respondWithProblem(c, http.StatusNotFound, "address not found")
+		return
+	} else if err != nil {
+		log.Printf("Failed to delete address: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to delete address")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func newAddressResponse(a address.Address) AddressResponse {
+	return AddressResponse{
+		ID: a.ID,
+		AddressRequest: AddressRequest{
+			Name:       a.Name,
+			Line1:      a.Line1,
+			Line2:      a.Line2,
+			City:       a.City,
+			PostalCode: a.PostalCode,
+			Country:    a.Country,
+			Phone:      a.Phone,
+		},
+		CreatedAt: a.CreatedAt,
+	}
+}
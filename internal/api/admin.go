@@ -0,0 +1,309 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"expvar"
+	"fmt"
+	"interview/internal/auth"
+	"interview/internal/events"
+	"interview/internal/imaging"
+	"interview/internal/payment"
+	"interview/internal/pricedrop"
+	"interview/internal/repo"
+	"interview/internal/storage"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxImageSize is the largest product image accepted for upload
+	maxImageSize = 5 << 20
+	// thumbnailSize is the width and height thumbnails are scaled to fit in
+	thumbnailSize = 128
+	// eventsHeartbeat is how often an idle event stream sends a comment to keep proxies from closing it
+	eventsHeartbeat = 15 * time.Second
+)
+
+type (
+	// AdminHandler serves the back-office endpoints under /admin.
+	AdminHandler struct {
+		repo     *repo.Repository
+		storage  storage.Storage
+		mediaTTL time.Duration
+		events   *events.Bus
+		sso      *auth.OIDCProvider
+		// requireStaff2FA keeps staff users without two-factor authentication out
+		requireStaff2FA atomic.Bool
+		// config is the configuration shown by the config endpoint, nil to hide it
+		config *LiveConfig
+		// maintenance is switched by the maintenance endpoints, nil to disable them
+		maintenance *Maintenance
+		// payments captures the held payments staff approve and refunds orders, nil when customers can't
+		// check out
+		payments payment.Provider
+		// refundRestock puts refunded items back into stock
+		refundRestock bool
+		// priceDrops alerts the owners of open carts when a product gets cheaper, nil to disable the alerts
+		priceDrops *pricedrop.Alerter
+	}
+
+	// DashboardSnapshot is the first event of the admin event stream.
+	DashboardSnapshot struct {
+		OpenCarts int64   `json:"open_carts"`
+		OpenValue float64 `json:"open_value"`
+	}
+)
+
+// NewAdminHandler creates a new AdminHandler storing uploads in store. Signed URLs returned for
+// uploaded files are valid for mediaTTL.
+func NewAdminHandler(db *gorm.DB, store storage.Storage, mediaTTL time.Duration) *AdminHandler {
+	return &AdminHandler{
+		repo:     repo.NewRepository(db),
+		storage:  store,
+		mediaTTL: mediaTTL,
+	}
+}
+
+// RegisterRoutes mounts the admin endpoints under /admin. Staff members log in with the identity
+// provider when single sign-on is set up, with HTTP basic authentication as one of accounts otherwise,
+// or to the shop with a user having a staff role. Each endpoint requires a permission of their role.
+func (h *AdminHandler) RegisterRoutes(router gin.IRouter, accounts gin.Accounts) {
+	authenticate := basicAuth(accounts)
+	if h.sso != nil {
+		router.GET("/admin/login", h.SSOLogin)
+		router.GET("/admin/callback", h.SSOCallback)
+		router.GET("/admin/logout", h.SSOLogout)
+		authenticate = requireStaffSession
+	}
+
+	admin := router.Group("/admin", h.authenticateStaff(authenticate))
+	admin.GET("/products/export", requirePermission(auth.PermManageProducts), h.ExportProducts)
+	admin.POST("/products/import", requirePermission(auth.PermManageProducts), h.ImportProducts)
+	admin.POST("/products/:id/image", requirePermission(auth.PermManageProducts), h.UploadProductImage)
+	admin.POST("/products/:id/price", requirePermission(auth.PermManageProducts), h.UpdateProductPrice)
+	admin.GET("/products/:id/price-tiers", requirePermission(auth.PermManageProducts), h.ListPriceTiers)
+	admin.PUT("/products/:id/price-tiers", requirePermission(auth.PermManageProducts), h.UpdatePriceTiers)
+	admin.GET("/products/:id/components", requirePermission(auth.PermManageProducts), h.ListBundleComponents)
+	admin.PUT("/products/:id/components", requirePermission(auth.PermManageProducts), h.UpdateBundleComponents)
+	admin.POST("/products/:id/stock", requirePermission(auth.PermManageProducts), h.UpdateProductStock)
+	admin.POST("/products/:id/low-stock", requirePermission(auth.PermManageProducts), h.UpdateLowStockThreshold)
+	admin.POST("/products/:id/low-stock/snooze", requirePermission(auth.PermManageProducts), h.SnoozeLowStock)
+	admin.POST("/products/:id/type", requirePermission(auth.PermManageProducts), h.UpdateProductType)
+	admin.POST("/products/:id/category", requirePermission(auth.PermManageProducts), h.UpdateProductCategory)
+	admin.POST("/products/:id/file", requirePermission(auth.PermManageProducts), h.UploadProductFile)
+	admin.GET("/carts/export", requirePermission(auth.PermViewCarts), h.ExportCarts)
+	admin.POST("/carts/reprice", requirePermission(auth.PermManageCarts), h.RepriceCarts)
+	admin.GET("/carts/segments", requirePermission(auth.PermViewCarts), h.ListSegments)
+	admin.GET("/carts/segments/:segment", requirePermission(auth.PermViewCarts), h.ListSegmentCarts)
+	admin.GET("/carts/:id", requirePermission(auth.PermViewCarts), h.ShowCart)
+	admin.GET("/reports/stale-prices", requirePermission(auth.PermViewCarts), h.StalePriceReport)
+	admin.GET("/reports/sales", requirePermission(auth.PermViewReports), h.SalesReport)
+	admin.GET("/reports/experiments", requirePermission(auth.PermViewReports), h.ExperimentReport)
+	admin.GET("/metrics", requirePermission(auth.PermViewReports), gin.WrapH(expvar.Handler()))
+	admin.GET("/warehouses", requirePermission(auth.PermManageProducts), h.ListWarehouses)
+	admin.POST("/warehouses", requirePermission(auth.PermManageProducts), h.CreateWarehouse)
+	admin.POST("/warehouses/:id/stock", requirePermission(auth.PermManageProducts), h.UpdateWarehouseStock)
+	admin.GET("/price-lists", requirePermission(auth.PermManageProducts), h.ListPriceLists)
+	admin.POST("/price-lists", requirePermission(auth.PermManageProducts), h.CreatePriceList)
+	admin.GET("/price-lists/:id", requirePermission(auth.PermManageProducts), h.ShowPriceList)
+	admin.PATCH("/price-lists/:id", requirePermission(auth.PermManageProducts), h.UpdatePriceList)
+	admin.DELETE("/price-lists/:id", requirePermission(auth.PermManageProducts), h.DeletePriceList)
+	admin.POST("/price-lists/:id/prices", requirePermission(auth.PermManageProducts), h.SetListPrice)
+	admin.DELETE("/price-lists/:id/prices/:product", requirePermission(auth.PermManageProducts), h.RemoveListPrice)
+	admin.GET("/price-overrides", requirePermission(auth.PermManageProducts), h.ListPriceOverrides)
+	admin.POST("/price-overrides", requirePermission(auth.PermManageProducts), h.CreatePriceOverride)
+	admin.DELETE("/price-overrides/:id", requirePermission(auth.PermManageProducts), h.DeletePriceOverride)
+	admin.GET("/orders", requirePermission(auth.PermViewCarts), h.ListOrders)
+	admin.GET("/orders/:id", requirePermission(auth.PermViewCarts), h.ShowOrder)
+	admin.POST("/orders/:id/transition", requirePermission(auth.PermManageCarts), h.TransitionOrder)
+	admin.GET("/orders/:id/refunds", requirePermission(auth.PermViewCarts), h.ListRefunds)
+	admin.POST("/orders/:id/refunds", requirePermission(auth.PermIssueRefunds), h.CreateRefund)
+	admin.POST("/orders/:id/refunds/:refund/retry", requirePermission(auth.PermIssueRefunds), h.RetryRefund)
+	admin.GET("/impersonate", requirePermission(auth.PermViewCarts), h.Impersonate)
+	admin.GET("/audit-log", requirePermission(auth.PermViewAuditLog), h.ListAuditLog)
+
+	webhooks := admin.Group("", requirePermission(auth.PermManageWebhooks))
+	webhooks.GET("/webhooks", h.ListWebhooks)
+	webhooks.POST("/webhooks", h.CreateWebhook)
+	webhooks.DELETE("/webhooks/:id", h.DeleteWebhook)
+	webhooks.GET("/webhooks/:id/deliveries", h.ListWebhookDeliveries)
+	webhooks.POST("/webhook-deliveries/:id/retry", h.RetryWebhookDelivery)
+	if h.events != nil {
+		admin.GET("/events", requirePermission(auth.PermViewCarts), h.Events)
+	}
+	if h.config != nil {
+		admin.GET("/config", requirePermission(auth.PermViewSettings), h.ShowConfig)
+	}
+	if h.maintenance != nil {
+		admin.GET("/maintenance", requirePermission(auth.PermViewSettings), h.ShowMaintenance)
+		admin.POST("/maintenance", requirePermission(auth.PermManageSettings), h.UpdateMaintenance)
+	}
+	if h.payments != nil {
+		admin.GET("/payments/held", requirePermission(auth.PermViewCarts), h.ListHeldPayments)
+		admin.POST("/payments/:id/approve", requirePermission(auth.PermManageCarts), h.ApprovePayment)
+		admin.POST("/payments/:id/reject", requirePermission(auth.PermManageCarts), h.RejectPayment)
+	}
+}
+
+// SetRepository has the admin endpoints share the repository of the storefront, so carts staff check
+// out or reprice get the downloads, referral rewards, charges and prices they get in the shop.
+func (h *AdminHandler) SetRepository(r *repo.Repository) {
+	h.repo = r
+}
+
+// SetConfig sets the configuration shown by the config endpoint.
+func (h *AdminHandler) SetConfig(config *LiveConfig) {
+	h.config = config
+}
+
+// SetEventBus sets the bus streamed by the events endpoint.
+func (h *AdminHandler) SetEventBus(bus *events.Bus) {
+	h.events = bus
+}
+
+// Events streams cart activity as Server-Sent Events. The stream starts with a "snapshot" event
+// holding the number and value of open carts, which dashboards keep current by applying the
+// "cart" events that follow.
+func (h *AdminHandler) Events(c *gin.Context) {
+	// Subscribe before taking the snapshot so no change falls in between
+	ch, unsubscribe := h.events.Subscribe()
+	defer unsubscribe()
+
+	count, value, err := h.repoFor(c).OpenCartStats()
+	if err != nil {
+		log.Printf("Failed to load dashboard snapshot: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to load dashboard")
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.SSEvent("snapshot", DashboardSnapshot{OpenCarts: count, OpenValue: value})
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				return false
+			}
+			c.SSEvent("cart", e)
+			return true
+		case <-heartbeat.C:
+			_, err := io.WriteString(w, ": heartbeat\n\n")
+			return err == nil
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// UploadProductImage stores the multipart "image" file as the product's image and renders its thumbnail.
+// Every upload gets new storage keys so signed URLs and caches never serve a replaced image.
+func (h *AdminHandler) UploadProductImage(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid product ID")
+		return
+	}
+	product, err := h.repoFor(c).GetProduct(uint(id))
+	if err != nil {
+		respondWithProblem(c, http.StatusNotFound, "product not found")
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImageSize+1<<20)
+	file, err := c.FormFile("image")
+	if err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "image file is required")
+		return
+	}
+	if file.Size > maxImageSize {
+		respondWithProblem(c, http.StatusRequestEntityTooLarge, "image must not exceed 5 MB")
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "failed to read image")
+		return
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "failed to read image")
+		return
+	}
+
+	img, err := imaging.Decode(bytes.NewReader(data))
+	if errors.Is(err, imaging.ErrUnsupportedFormat) {
+		respondWithProblem(c, http.StatusUnsupportedMediaType, err.Error())
+		return
+	} else if err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid image")
+		return
+	}
+	thumbnail, thumbnailType, err := imaging.Thumbnail(img, thumbnailSize)
+	if err != nil {
+		log.Printf("Failed to render thumbnail: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to render thumbnail")
+		return
+	}
+
+	version, err := generateSessionID()
+	if err != nil {
+		respondWithProblem(c, http.StatusInternalServerError, "failed to store image")
+		return
+	}
+	prefix := fmt.Sprintf("products/%d/%s", product.ID, version[:16])
+	imageKey := prefix + "/image." + extension(img.Format)
+	thumbnailKey := prefix + "/thumbnail." + extension(thumbnailType[len("image/"):])
+
+	ctx := c.Request.Context()
+	if err := h.storage.Put(ctx, imageKey, bytes.NewReader(data), int64(len(data)), "image/"+img.Format); err != nil {
+		log.Printf("Failed to store image: %v", err)
+		respondWithProblem(c, http.StatusBadGateway, "failed to store image")
+		return
+	}
+	if err := h.storage.Put(ctx, thumbnailKey, bytes.NewReader(thumbnail), int64(len(thumbnail)), thumbnailType); err != nil {
+		log.Printf("Failed to store thumbnail: %v", err)
+		respondWithProblem(c, http.StatusBadGateway, "failed to store image")
+		return
+	}
+	if err := h.repoFor(c).SetProductImage(product.ID, imageKey, thumbnailKey); err != nil {
+		log.Printf("Failed to update product: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to update product")
+		return
+	}
+
+	for _, old := range []string{product.ImageKey, product.ThumbnailKey} {
+		if old == "" {
+			continue
+		}
+		if err := h.storage.Delete(ctx, old); err != nil {
+			log.Printf("Failed to delete replaced image %s: %v", old, err)
+		}
+	}
+
+	imageURL, _ := h.storage.SignedURL(imageKey, h.mediaTTL)
+	thumbnailURL, _ := h.storage.SignedURL(thumbnailKey, h.mediaTTL)
+	c.JSON(http.StatusOK, gin.H{"image_url": imageURL, "thumbnail_url": thumbnailURL})
+}
+
+// extension maps an image format name to a file extension.
+func extension(format string) string {
+	if format == "jpeg" {
+		return "jpg"
+	}
+	return format
+}
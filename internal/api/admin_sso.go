@@ -0,0 +1,173 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"interview/internal/auth"
+	"interview/internal/config"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// staffRoleKey is the gin context key holding the role of the authenticated staff member
+	staffRoleKey = "staff_role"
+	// adminHome is where staff members land after logging in without a page to return to
+	adminHome = "/admin/reports/sales"
+)
+
+// SetSSO makes staff members log in with the OpenID Connect identity provider instead of basic auth.
+func (h *AdminHandler) SetSSO(provider *auth.OIDCProvider) {
+	h.sso = provider
+}
+
+// newAdminSSO sets up the identity provider of the configuration, exiting when it can't be reached.
+func newAdminSSO(config config.Config) *auth.OIDCProvider {
+	roleGroups, err := auth.ParseRoleGroups(config.OIDCRoleGroups)
+	if err != nil {
+		log.Fatalf("Invalid OIDC_ROLE_GROUPS: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	redirectURL := strings.TrimSuffix(config.OAuthRedirectBaseURL, "/") + "/admin/callback"
+	provider, err := auth.DiscoverOIDCProvider(ctx, config.OIDCIssuerURL, config.OIDCClientID,
+		config.OIDCClientSecret, redirectURL, roleGroups)
+	if err != nil {
+		log.Fatalf("Failed to set up admin single sign-on: %v", err)
+	}
+	provider.GroupsClaim = config.OIDCGroupsClaim
+	return provider
+}
+
+// basicAuth authenticates staff members with the local admin accounts, who have the admin role.
+func basicAuth(accounts gin.Accounts) gin.HandlerFunc {
+	authenticate := gin.BasicAuth(accounts)
+	return func(c *gin.Context) {
+		authenticate(c)
+		if !c.IsAborted() {
+			c.Set(staffRoleKey, auth.RoleAdmin)
+		}
+	}
+}
+
+// requireStaffSession lets staff members logged in with single sign-on through. Browsers are sent to
+// the login page and brought back afterwards, other clients get 401 Unauthorized.
+func requireStaffSession(c *gin.Context) {
+	session := sessions.Default(c)
+	if role, _ := session.Get(staffRoleKey).(string); role != "" {
+		c.Set(staffRoleKey, role)
+		return
+	}
+
+	if c.Request.Method == http.MethodGet && strings.Contains(c.GetHeader("Accept"), "text/html") {
+		session.Set("admin_next", c.Request.URL.RequestURI())
+		if err := session.Save(); err != nil {
+			log.Printf("Failed to save session: %v", err)
+		}
+		c.Redirect(http.StatusFound, "/admin/login")
+		c.Abort()
+		return
+	}
+	respondWithProblem(c, http.StatusUnauthorized, "login required")
+}
+
+// SSOLogin redirects staff members to the identity provider.
+func (h *AdminHandler) SSOLogin(c *gin.Context) {
+	state, err := generateSessionID()
+	if err != nil {
+		log.Printf("Failed to generate OIDC state: %v", err)
+		c.String(http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	nonce, err := generateSessionID()
+	if err != nil {
+		log.Printf("Failed to generate OIDC nonce: %v", err)
+		c.String(http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	session := sessions.Default(c)
+	session.Set("admin_oidc_state", state)
+	session.Set("admin_oidc_nonce", nonce)
+	if err := session.Save(); err != nil {
+		log.Printf("Failed to save session: %v", err)
+		c.String(http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	c.Redirect(http.StatusFound, h.sso.AuthCodeURL(state, nonce))
+}
+
+// SSOCallback completes the login: it verifies the state and the ID token and gives a new session, the
+// old one's carts moved along, the role granted by the groups of the staff member.
+func (h *AdminHandler) SSOCallback(c *gin.Context) {
+	session := sessions.Default(c)
+	expectedState, _ := session.Get("admin_oidc_state").(string)
+	nonce, _ := session.Get("admin_oidc_nonce").(string)
+	session.Delete("admin_oidc_state")
+	session.Delete("admin_oidc_nonce")
+	if err := session.Save(); err != nil {
+		log.Printf("Failed to save session: %v", err)
+	}
+
+	state := c.Query("state")
+	if expectedState == "" || subtle.ConstantTimeCompare([]byte(state), []byte(expectedState)) != 1 {
+		c.String(http.StatusBadRequest, "Login failed, please try again")
+		return
+	}
+
+	identity, err := h.sso.Exchange(c.Request.Context(), c.Query("code"), nonce)
+	if errors.Is(err, auth.ErrNoRole) {
+		log.Printf("Admin login of %s denied: no role", identity.Email)
+		c.String(http.StatusForbidden, "Your account has no access to the admin area")
+		return
+	} else if err != nil {
+		log.Printf("Admin login failed: %v", err)
+		c.String(http.StatusUnauthorized, "Login failed, please try again")
+		return
+	}
+
+	next, _ := session.Get("admin_next").(string)
+	session.Delete("admin_next")
+	// Only return to admin pages, the value could have been planted to redirect elsewhere
+	if !strings.HasPrefix(next, "/admin/") {
+		next = adminHome
+	}
+	anonymousID, _ := session.Get("session_id").(string)
+	sessionID, err := rotateSession(session, "cart_name", "locale", currencySessionKey)
+	if err != nil {
+		log.Printf("Failed to rotate session: %v", err)
+		c.String(http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	if anonymousID != "" {
+		if err := h.repoFor(c).MoveSessionCarts(anonymousID, sessionID); err != nil {
+			log.Printf("Failed to move carts to the new session: %v", err)
+		}
+	}
+	session.Set(staffRoleKey, identity.Role)
+	session.Set("staff_email", identity.Email)
+	if err := session.Save(); err != nil {
+		log.Printf("Failed to save session: %v", err)
+		c.String(http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	log.Printf("Admin login of %s (%s) as %s", identity.Email, identity.Subject, identity.Role)
+	c.Redirect(http.StatusFound, next)
+}
+
+// SSOLogout ends the admin session of the staff member.
+func (h *AdminHandler) SSOLogout(c *gin.Context) {
+	session := sessions.Default(c)
+	session.Delete(staffRoleKey)
+	session.Delete("staff_email")
+	if err := session.Save(); err != nil {
+		log.Printf("Failed to save session: %v", err)
+	}
+	c.Redirect(http.StatusFound, "/")
+}
@@ -0,0 +1,175 @@
+package api_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"interview/internal/api"
+	"interview/internal/auth"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFakeIdP starts a server emulating an OpenID Connect identity provider. The ID token issued for
+// a code carries the groups registered for it in codes.
+func newFakeIdP(t *testing.T, codes map[string][]string) *auth.OIDCProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	var srv *httptest.Server
+	nonces := map[string]string{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 srv.URL,
+			"authorization_endpoint": srv.URL + "/authorize",
+			"token_endpoint":         srv.URL + "/token",
+			"jwks_uri":               srv.URL + "/jwks",
+		})
+	})
+	// The authorize endpoint logs in the user of the code in the login_hint right away
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		code := r.URL.Query().Get("login_hint")
+		nonces[code] = r.URL.Query().Get("nonce")
+		callback := r.URL.Query().Get("redirect_uri") + "?code=" + code + "&state=" + url.QueryEscape(r.URL.Query().Get("state"))
+		http.Redirect(w, r, callback, http.StatusFound)
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kid": "key-1", "kty": "RSA",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		code := r.PostFormValue("code")
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss": srv.URL, "aud": "client", "sub": code, "email": code + "@example.com",
+			"nonce": nonces[code], "groups": codes[code], "exp": time.Now().Add(time.Hour).Unix(),
+		})
+		token.Header["kid"] = "key-1"
+		idToken, err := token.SignedString(key)
+		require.NoError(t, err)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "at", "token_type": "Bearer", "id_token": idToken})
+	})
+	srv = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	p, err := auth.DiscoverOIDCProvider(context.Background(), srv.URL, "client", "secret",
+		"http://localhost/admin/callback", map[string]string{"shop-admins": auth.RoleAdmin})
+	require.NoError(t, err)
+	return p
+}
+
+func TestAdminSSO(t *testing.T) {
+	ts := setupTest(t)
+	admin := api.NewAdminHandler(ts.db, nil, time.Hour)
+	admin.SetSSO(newFakeIdP(t, map[string][]string{"alice": {"shop-admins"}, "bob": {"everyone"}}))
+	admin.RegisterRoutes(ts.router, gin.Accounts{"admin": "secret"})
+
+	// login follows the login flow of the user of code through the identity provider
+	login := func(t *testing.T, code string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		t.Helper()
+		w := ts.makeRequest(t, http.MethodGet, "/admin/login", nil, cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+
+		resp, err := (&http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}}).Get(w.Header().Get("Location") + "&login_hint=" + code)
+		require.NoError(t, err)
+		resp.Body.Close()
+		callback, err := url.Parse(resp.Header.Get("Location"))
+		require.NoError(t, err)
+		return ts.makeRequest(t, http.MethodGet, callback.RequestURI(), nil, cookie)
+	}
+
+	t.Run("Requires Login", func(t *testing.T) {
+		ts.clearDatabase(t)
+		w := ts.makeRequest(t, http.MethodGet, "/admin/reports/sales", nil, ts.createSession(t))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		req := httptest.NewRequest(http.MethodGet, "/admin/reports/sales", nil)
+		req.SetBasicAuth("admin", "secret")
+		w = httptest.NewRecorder()
+		ts.router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "local accounts are replaced by single sign-on")
+	})
+
+	t.Run("Browser Returns To Page After Login", func(t *testing.T) {
+		ts.clearDatabase(t)
+		cookie := ts.createSession(t)
+		req := httptest.NewRequest(http.MethodGet, "/admin/reports/experiments", nil)
+		req.Header.Set("Accept", "text/html")
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		ts.router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "/admin/login", w.Header().Get("Location"))
+
+		w = login(t, "alice", cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "/admin/reports/experiments", w.Header().Get("Location"))
+
+		w = ts.makeRequest(t, http.MethodGet, "/admin/reports/experiments", nil, cookie)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Login Replaces The Session", func(t *testing.T) {
+		ts.clearDatabase(t)
+		cookie := ts.createSession(t)
+		planted := *cookie
+		w := login(t, "alice", cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+		assert.NotEqual(t, planted.Value, cookie.Value)
+
+		assert.Equal(t, http.StatusOK, ts.makeRequest(t, http.MethodGet, "/admin/reports/sales", nil, cookie).Code)
+		w = ts.makeRequest(t, http.MethodGet, "/admin/reports/sales", nil, &planted)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "the session before the login isn't logged in")
+	})
+
+	t.Run("Logout", func(t *testing.T) {
+		ts.clearDatabase(t)
+		cookie := ts.createSession(t)
+		w := login(t, "alice", cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "/admin/reports/sales", w.Header().Get("Location"))
+
+		ts.makeRequest(t, http.MethodGet, "/admin/logout", nil, cookie)
+		w = ts.makeRequest(t, http.MethodGet, "/admin/reports/sales", nil, cookie)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("User Without Role", func(t *testing.T) {
+		ts.clearDatabase(t)
+		cookie := ts.createSession(t)
+		w := login(t, "bob", cookie)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = ts.makeRequest(t, http.MethodGet, "/admin/reports/sales", nil, cookie)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Forged State", func(t *testing.T) {
+		ts.clearDatabase(t)
+		cookie := ts.createSession(t)
+		ts.makeRequest(t, http.MethodGet, "/admin/login", nil, cookie)
+		w := ts.makeRequest(t, http.MethodGet, "/admin/callback?code=alice&state=forged", nil, cookie)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = ts.makeRequest(t, http.MethodGet, "/admin/reports/sales", nil, cookie)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
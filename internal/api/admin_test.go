@@ -0,0 +1,176 @@
+package api_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"interview/internal/api"
+	"interview/internal/events"
+	"interview/internal/repo"
+	"interview/internal/storage"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// uploadRequest builds a multipart request uploading content as the "image" file
+func uploadRequest(t *testing.T, path string, content []byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("image", "image.png")
+	require.NoError(t, err)
+	_, err = part.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, mw.Close())
+
+	req := httptest.NewRequest(http.MethodPost, path, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.SetBasicAuth("admin", "secret")
+	return req
+}
+
+func TestUploadProductImage(t *testing.T) {
+	ts := setupTest(t)
+	ts.clearDatabase(t)
+
+	dir := t.TempDir()
+	media := storage.NewLocal(dir, "/media", []byte("test_secret"))
+	router := gin.New()
+	api.NewAdminHandler(ts.db, media, time.Hour).RegisterRoutes(router, gin.Accounts{"admin": "secret"})
+
+	product, err := repo.NewRepository(ts.db).UpsertProduct("shoe", 10.0)
+	require.NoError(t, err)
+	path := fmt.Sprintf("/admin/products/%d/image", product.ID)
+
+	var img bytes.Buffer
+	require.NoError(t, png.Encode(&img, image.NewNRGBA(image.Rect(0, 0, 300, 150))))
+
+	t.Run("Requires Authentication", func(t *testing.T) {
+		req := uploadRequest(t, path, img.Bytes())
+		req.SetBasicAuth("admin", "wrong")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Rejects Non-Images", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, uploadRequest(t, path, []byte("not an image")))
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	})
+
+	t.Run("Unknown Product", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, uploadRequest(t, "/admin/products/999/image", img.Bytes()))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Stores Image And Thumbnail", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, uploadRequest(t, path, img.Bytes()))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp map[string]string
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Contains(t, resp["thumbnail_url"], "/media/products/")
+
+		updated, err := repo.NewRepository(ts.db).GetProduct(product.ID)
+		require.NoError(t, err)
+		require.NotEmpty(t, updated.ThumbnailKey)
+		assert.FileExists(t, filepath.Join(dir, filepath.FromSlash(updated.ImageKey)))
+
+		thumbURL, err := url.Parse(resp["thumbnail_url"])
+		require.NoError(t, err)
+		w = httptest.NewRecorder()
+		http.StripPrefix("/media", media).ServeHTTP(w, httptest.NewRequest(http.MethodGet, thumbURL.String(), nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		thumb, err := png.Decode(w.Body)
+		require.NoError(t, err)
+		assert.Equal(t, image.Rect(0, 0, 128, 64), thumb.Bounds())
+	})
+
+	t.Run("Thumbnail Shown In Cart", func(t *testing.T) {
+		ts.handler.SetStorage(media, time.Hour)
+		defer ts.handler.SetStorage(nil, 0)
+
+		cookie := ts.createSession(t)
+		form := url.Values{"product": {"shoe"}, "quantity": {"1"}}
+		ts.makeRequest(t, http.MethodPost, "/add-item", form, cookie)
+		w := ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.Contains(t, w.Body.String(), `<img src="/media/products/`)
+	})
+}
+
+func TestAdminEvents(t *testing.T) {
+	ts := setupTest(t)
+	ts.clearDatabase(t)
+
+	bus := events.NewBus()
+	ts.handler.SetEventBus(bus)
+	defer ts.handler.SetEventBus(nil)
+
+	admin := api.NewAdminHandler(ts.db, nil, time.Hour)
+	admin.SetEventBus(bus)
+	router := gin.New()
+	admin.RegisterRoutes(router, gin.Accounts{"admin": "secret"})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/admin/events", nil)
+	require.NoError(t, err)
+	req.SetBasicAuth("admin", "secret")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	lines := bufio.NewScanner(resp.Body)
+	nextEvent := func() (string, string) {
+		var name, data string
+		for lines.Scan() {
+			line := lines.Text()
+			switch {
+			case strings.HasPrefix(line, "event:"):
+				name = strings.TrimPrefix(line, "event:")
+			case strings.HasPrefix(line, "data:"):
+				data = strings.TrimPrefix(line, "data:")
+			case line == "" && name != "":
+				return name, data
+			}
+		}
+		return name, data
+	}
+
+	name, data := nextEvent()
+	assert.Equal(t, "snapshot", name)
+	assert.JSONEq(t, `{"open_carts":0,"open_value":0}`, data)
+
+	cookie := ts.createSession(t)
+	ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"shoe"}, "quantity": {"2"}}, cookie)
+
+	name, data = nextEvent()
+	assert.Equal(t, "cart", name)
+	var e events.Event
+	require.NoError(t, json.Unmarshal([]byte(data), &e))
+	assert.Equal(t, events.TypeItemAdded, e.Type)
+	assert.Equal(t, "shoe", e.Product)
+	assert.Equal(t, 2, e.Quantity)
+	assert.Equal(t, 20.0, e.Total)
+}
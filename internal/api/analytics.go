@@ -0,0 +1,73 @@
+package api
+
+import (
+	"fmt"
+	"interview/internal/analytics"
+	"interview/internal/config"
+	"interview/internal/repo"
+	"net/http"
+	"strings"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// NewTracker creates the analytics tracker writing to the sinks of the configuration, nil when
+// analytics are disabled. The tracker still has to be started.
+func NewTracker(config config.Config, r *repo.Repository) (*analytics.Tracker, error) {
+	var sinks []analytics.Sink
+	for _, name := range splitList(config.AnalyticsSinks) {
+		switch name {
+		case "db":
+			sinks = append(sinks, analytics.NewDBSink(r))
+		case "file":
+			file, err := analytics.NewFileSink(config.AnalyticsFile)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, file)
+		case "segment":
+			sinks = append(sinks, analytics.NewSegmentSink(config.SegmentWriteKey))
+		default:
+			return nil, fmt.Errorf("unknown analytics sink %q", name)
+		}
+	}
+	if len(sinks) == 0 {
+		return nil, nil
+	}
+
+	if config.AnalyticsFlushInterval <= 0 {
+		return nil, fmt.Errorf("invalid ANALYTICS_FLUSH_INTERVAL %s", config.AnalyticsFlushInterval)
+	}
+	return analytics.NewTracker(analytics.MultiSink(sinks...), config.AnalyticsFlushInterval), nil
+}
+
+// SetTracker sets the tracker recording page views and cart activity.
+func (h *CartHandler) SetTracker(tracker *analytics.Tracker) {
+	h.tracker = tracker
+}
+
+// TrackPageViews is middleware recording a page view for every page successfully shown.
+func (h *CartHandler) TrackPageViews(c *gin.Context) {
+	c.Next()
+
+	if h.tracker == nil || c.Request.Method != http.MethodGet || !isPagePath(c.Request.URL.Path) ||
+		strings.HasPrefix(c.Request.URL.Path, "/auth/") || c.Writer.Status() >= http.StatusBadRequest {
+		return
+	}
+	// Pages create the session ID, so it is read once they ran
+	h.track(c, analytics.Event{Type: analytics.TypePageView, Path: c.Request.URL.Path})
+}
+
+// track records the event for the visitor of the page, filling in their session and user.
+func (h *CartHandler) track(c *gin.Context, e analytics.Event) {
+	if h.tracker == nil {
+		return
+	}
+	session := sessions.Default(c)
+	e.SessionID, _ = session.Get("session_id").(string)
+	if userID, ok := session.Get("user_id").(uint); ok {
+		e.UserID = &userID
+	}
+	h.tracker.Track(e)
+}
@@ -0,0 +1,50 @@
+package api_test
+
+import (
+	"interview/internal/analytics"
+	"interview/internal/api"
+	"interview/internal/config"
+	"interview/internal/repo"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalytics(t *testing.T) {
+	ts := setupTest(t)
+	ts.clearDatabase(t)
+
+	tracker, err := api.NewTracker(config.Config{AnalyticsSinks: "db", AnalyticsFlushInterval: time.Hour}, repo.NewRepository(ts.db))
+	require.NoError(t, err)
+	tracker.Start()
+	ts.handler.SetTracker(tracker)
+
+	cookie := ts.createSession(t)
+	w := ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"shoe"}, "quantity": {"2"}}, cookie)
+	require.Equal(t, http.StatusFound, w.Code)
+	w = ts.makeRequest(t, http.MethodGet, "/products", nil, cookie)
+	require.Equal(t, http.StatusOK, w.Code)
+	tracker.Close()
+
+	var events []analytics.Event
+	require.NoError(t, ts.db.Order("id").Find(&events).Error)
+	require.Len(t, events, 3)
+	assert.Equal(t, analytics.TypePageView, events[0].Type)
+	assert.Equal(t, "/", events[0].Path)
+	assert.NotEmpty(t, events[0].SessionID)
+	assert.Equal(t, analytics.TypeAddToCart, events[1].Type)
+	assert.Equal(t, "shoe", events[1].Product)
+	assert.Equal(t, 2, events[1].Quantity)
+	assert.Equal(t, events[0].SessionID, events[1].SessionID)
+	assert.Equal(t, "/products", events[2].Path)
+
+	t.Run("Disabled", func(t *testing.T) {
+		tracker, err := api.NewTracker(config.Config{}, repo.NewRepository(ts.db))
+		require.NoError(t, err)
+		assert.Nil(t, tracker)
+	})
+}
@@ -2,78 +2,708 @@
 package api
 
 import (
+	"compress/gzip"
+	"context"
 	"crypto/rand"
 	"embed"
+	"errors"
 	"fmt"
 	"html/template"
+	"interview/internal/abandonment"
+	"interview/internal/analytics"
+	"interview/internal/auth"
+	"interview/internal/botcheck"
+	"interview/internal/cache"
 	"interview/internal/cart"
+	"interview/internal/checkout"
 	"interview/internal/config"
+	"interview/internal/download"
+	"interview/internal/events"
+	"interview/internal/experiment"
+	"interview/internal/i18n"
+	"interview/internal/ipfilter"
+	"interview/internal/jobs"
+	"interview/internal/lowstock"
+	"interview/internal/mail"
+	"interview/internal/notification"
+	"interview/internal/payment"
+	"interview/internal/pricedrop"
+	"interview/internal/pricing"
+	productpkg "interview/internal/product"
+	"interview/internal/ratelimit"
+	"interview/internal/recommend"
+	"interview/internal/reminder"
 	"interview/internal/repo"
+	"interview/internal/restock"
+	"interview/internal/risk"
+	"interview/internal/search"
+	"interview/internal/service"
+	"interview/internal/static"
+	"interview/internal/storage"
+	"interview/internal/subscription"
+	"interview/internal/tenant"
+	"interview/internal/theme"
+	"interview/internal/vat"
+	"interview/internal/warehouse"
+	"interview/internal/webhook"
 	"log"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/gin-contrib/sessions"
 	gormSessions "github.com/gin-contrib/sessions/gorm"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/csrf"
+	gsessions "github.com/gorilla/sessions"
+	"golang.org/x/text/language"
 	"gorm.io/gorm"
 )
 
 type (
 	// CartHandler is used with HTTP handlers so we can inject the repository, template, and product prices.
 	CartHandler struct {
-		repo          *repo.Repository
-		Template      *template.Template
-		productPrices map[string]float64
-		config        config.Config
+		repo           *repo.Repository
+		Template       *template.Template
+		config         config.Config
+		loginProviders []string
+		storage        storage.Storage
+		mediaTTL       time.Duration
+		events         *events.Bus
+		carts          *service.CartService
+		assets         *static.Assets
+		// themes restyle the pages for the shop of the request, nil for the built-in look
+		themes      *theme.Themes
+		cartLinks   *reminder.CartLinks
+		experiments atomic.Pointer[[]experiment.Experiment]
+		tracker     *analytics.Tracker
+		// priceRefreshAfter is how old item prices may get before ShowCart refreshes them, 0 never does
+		priceRefreshAfter time.Duration
+		// passwordReset offers the forgotten password form on the cart page
+		passwordReset bool
+		// loginLinks offers the form emailing a login link on the cart page
+		loginLinks bool
+		// currencies converts prices to the currency the customer prefers
+		currencies *pricing.Currencies
+		// emailChanges signs the links confirming new email addresses sent by mailer, nil when users
+		// can't change their address; emailChangeLimiter limits the changes per user
+		emailChanges       *auth.EmailChangeLinks
+		mailer             mail.Mailer
+		emailChangeLimiter *ratelimit.Limiter
+		// verification emails the links verifying email addresses, nil when they are disabled;
+		// requireVerifiedEmail keeps the saved addresses of unverified users out of checkout
+		verification         *emailVerification
+		requireVerifiedEmail bool
+		// maintenance makes the shop read-only while it is enabled
+		maintenance *Maintenance
+		// breaker tells whether the database is down, nil when requests always try it
+		breaker *repo.Breaker
+		// recommender suggests products for the cart page, nil to suggest none
+		recommender recommend.Provider
+		// stockNotifications offers to email customers when out-of-stock products are back
+		stockNotifications bool
+		// handoffLinks sign the links of cart QR codes, nil when the cart page shows none
+		handoffLinks *reminder.CartLinks
+		// downloadLinks sign the links digital products are downloaded from, nil to offer no downloads
+		downloadLinks *download.Links
+		// payments takes the payments of carts at checkout, nil when customers can't check out;
+		// paymentBaseURL is where the provider sends customers back to
+		payments       payment.Provider
+		paymentBaseURL string
+		// risk screens checkouts before their payment is captured, nil to capture every payment;
+		// riskCountryHeader is the request header reporting the customer's country
+		risk              risk.Checker
+		riskCountryHeader string
+		// vat validates the VAT IDs of businesses at checkout, nil when they can't enter any; vatPrefix
+		// is the country prefix of the shop's VAT ID, whose businesses are charged tax
+		vat       vat.Validator
+		vatPrefix string
+		// botCheck rejects forms adding items that bots submitted, nil to accept every submission
+		botCheck *botcheck.Checker
+		// inventorySecret verifies the inventory webhooks of the ERP
+		inventorySecret string
 	}
 
 	// TemplateData contains data to be rendered in HTML templates.
 	TemplateData struct {
-		Error         string
-		CartItems     []CartItemView
-		CSRFToken     string
-		CSRFFieldName template.HTML
+		Error     string
+		Notice    string
+		CartItems []CartItemView
+		// ItemGroups are the CartItems as shown, grouped by category when GroupByCategory is set
+		ItemGroups      []ItemGroupView
+		GroupByCategory bool
+		Subtotal        string
+		Discounts       []DiscountView
+		Credit          string
+		Total           string
+		CSRFToken       string
+		CSRFFieldName   template.HTML
+		UserName        string
+		LoginProviders  []string
+		Locale          string
+		// TwoFactorPending asks the user logging in for a code of their authenticator app
+		TwoFactorPending bool
+		// PasswordReset offers to email a link for resetting a forgotten password
+		PasswordReset bool
+		// LoginLinks offers to email a link logging the user in without a password
+		LoginLinks bool
+		// DiscountTotal, Tax and Shipping break the total down, empty when they are 0
+		DiscountTotal string
+		Tax           string
+		Shipping      string
+		// Currency is the currency prices are shown in, empty for the currency of the shop
+		Currency string
+		// Experiments maps the A/B experiments of the session to its variants, e.g.
+		// {{ if eq (index .Experiments "checkout_button") "green" }}
+		Experiments map[string]string
+		// RemovedItem is the item just removed, which the page offers to restore
+		RemovedItem *CartItemView
+		// CartName is the name of the cart shown, Carts the names of all open carts of the session
+		CartName string
+		Carts    []string
+		// ReferralCode and ReferralRewards are shown to logged-in users who generated a referral code
+		ReferralCode    string
+		ReferralRewards []ReferralRewardView
+		// RecentlyViewed are the products viewed last in the session, Recommendations those often bought
+		// together with the products of the cart
+		RecentlyViewed  []ProductView
+		Recommendations []ProductView
+		// CartHistory lists the latest changes of the cart, newest first
+		CartHistory []CartChangeView
+		// Notifications lists the latest notifications of the customer, newest first, of which
+		// UnreadNotifications are unread
+		Notifications       []NotificationView
+		UnreadNotifications int64
+		// ReservedUntil is when the stock held for the limited items of the cart is released, in RFC 3339,
+		// and ReservedFor the time left until then, e.g. "9:41". Both are empty while none is held.
+		ReservedUntil string
+		ReservedFor   string
+		// CartHandoff shows the QR code opening the cart on a store terminal
+		CartHandoff bool
+		// Impersonation is the banner shown while a staff member views the cart of a customer, nil otherwise
+		Impersonation *ImpersonationView
+		// SubscriptionIntervals are the intervals in days items can be subscribed to with subscribe & save
+		SubscriptionIntervals []int
+		// Checkout offers to pay for the cart and check it out
+		Checkout bool
+		// CheckingOut is whether the cart is being checked out, which locks it against changes
+		CheckingOut bool
+		// InReview is whether the order of the cart is held for review, which locks it too
+		InReview bool
+		// Shop is the name of the shop the page belongs to, empty when the deployment serves one shop
+		Shop string
+		// Form holds the values submitted with the add-item form when it is shown again because of
+		// FieldErrors, which map the names of its invalid fields to their error messages
+		Form        AddItemForm
+		FieldErrors map[string]string
+		// BotFields are the inputs of the bot check added to the forms adding items, BotScript the
+		// script they need; both empty without a bot check
+		BotFields template.HTML
+		BotScript template.HTML
+	}
+
+	// AddItemForm holds the values of the fields of the add-item form, as submitted.
+	AddItemForm struct {
+		Product  string
+		Quantity string
 	}
 
 	// CartItemView represents a cart item for the view layer.
 	CartItemView struct {
-		ID       uint
-		Product  string
-		Quantity int
+		ID           uint
+		Product      string
+		Quantity     int
+		ThumbnailURL string
+		// SubscriptionDays is how often the item is reordered, 0 for a one-time purchase
+		SubscriptionDays int
+		// TierQuantity is the quantity from which the volume discount the item is priced at applies, 0
+		// without one, and TierPrice the unit price it sells at
+		TierQuantity int
+		TierPrice    string
+		// Bundle is the bundle the item was added with, empty for items added on their own
+		Bundle string
+	}
+
+	// DiscountView represents a promotion applied to the cart for the view layer.
+	DiscountView struct {
+		Promotion string
+		Amount    string
 	}
 )
 
+// removedItemFlash is the flash key holding the ID of the item just removed from the cart
+const removedItemFlash = "removed_item"
+
 // InitAPI initializes and starts the HTTP server.
 func InitAPI(db *gorm.DB, templateFS embed.FS, config config.Config) {
 	handler := NewCartHandler(db, templateFS, config, "templates/*.html")
-	router := gin.Default()
+	live := NewLiveConfig(config)
+	router := gin.New()
+	// TRUSTED_PROXIES was validated when the configuration was loaded
+	if err := TrustProxies(router, config.TrustedProxies); err != nil {
+		log.Fatalf("Failed to trust proxies: %v", err)
+	}
+	router.Use(requestLogger(live), gin.Recovery())
+	// TENANTS was validated when the configuration was loaded
+	if hosts, _ := tenant.Parse(config.Tenants); hosts != nil {
+		router.Use(ResolveTenant(hosts))
+	}
+	if config.ThemesDir != "" {
+		themes, err := theme.Load(config.ThemesDir, handler.Template, handler.assets)
+		if err != nil {
+			log.Fatalf("Failed to load themes: %v", err)
+		}
+		if err := themes.Select(config.Theme); err != nil {
+			log.Fatalf("Invalid THEME: %v", err)
+		}
+		handler.SetThemes(themes)
+	}
+
+	if config.BotCheck {
+		botCheck := botcheck.New([]byte(config.SessionSecret), config.BotMinFillTime)
+		if config.HCaptchaSiteKey != "" {
+			botCheck.SetCaptcha(config.HCaptchaSiteKey, botcheck.NewHCaptcha(config.HCaptchaSecret))
+		}
+		handler.SetBotCheck(botCheck)
+	}
+
+	media, mediaOrigin := newStorage(config)
+	handler.SetStorage(media, config.MediaURLTTL)
+
+	csp := config.ContentSecurityPolicy
+	if csp == "" {
+		csp = DefaultContentSecurityPolicy
+		if mediaOrigin != "" {
+			csp = strings.Replace(csp, "img-src 'self' data:", "img-src 'self' data: "+mediaOrigin, 1)
+		}
+		// The hCaptcha widget loads its script, styles and challenge frame from hCaptcha
+		if config.HCaptchaSiteKey != "" {
+			csp = strings.NewReplacer(
+				"script-src 'self'", "script-src 'self' "+botcheck.HCaptchaCSPSources,
+				"style-src 'self'", "style-src 'self' "+botcheck.HCaptchaCSPSources,
+			).Replace(csp) + "; frame-src " + botcheck.HCaptchaCSPSources + "; connect-src 'self' " + botcheck.HCaptchaCSPSources
+		}
+	}
+	router.Use(SecurityHeaders(SecurityHeadersOptions{
+		ContentSecurityPolicy: csp,
+		CSPReportOnly:         config.CSPReportOnly,
+		HSTSMaxAge:            config.HSTSMaxAge,
+	}))
 
 	// Add session middleware with proper duration enforcement
-	store := gormSessions.NewStore(db, true, []byte(config.SessionSecret))
-	store.Options(sessions.Options{
-		Path:     "/",
-		MaxAge:   3600, // 1 hour session duration
-		HttpOnly: true,
-		Secure:   false, // Set to true in production
-		SameSite: http.SameSiteLaxMode,
+	sessionOptions := func(maxAge time.Duration) sessions.Options {
+		return sessions.Options{
+			Path:     "/",
+			MaxAge:   int(maxAge.Seconds()),
+			HttpOnly: true,
+			Secure:   tlsEnabled(config),
+			SameSite: http.SameSiteLaxMode,
+		}
+	}
+	// Expired sessions are swept by a job rather than by every instance's store
+	store := newReloadableStore(gormSessions.NewStore(db, false, []byte(config.SessionSecret)), sessionOptions(config.SessionMaxAge),
+		func(options sessions.Options) sessions.Store {
+			s := gormSessions.NewStore(db, false, []byte(config.SessionSecret))
+			s.Options(options)
+			return s
+		})
+	live.OnReload(func() {
+		store.Options(sessionOptions(live.Current().SessionMaxAge))
 	})
 
+	router.Use(Compress(gzip.DefaultCompression))
 	router.Use(sessions.Sessions(config.SessionName, store))
 
+	rates, err := pricing.ParseRates(config.CurrencyRates)
+	if err != nil {
+		log.Fatalf("Invalid CURRENCY_RATES: %v", err)
+	}
+	handler.SetCurrencies(pricing.NewCurrencies(config.Currency, rates))
+
+	setExperiments := func() {
+		// EXPERIMENTS was validated when the configuration was loaded
+		experiments, _ := experiment.Parse(live.Current().Experiments)
+		handler.SetExperiments(experiments)
+	}
+	setExperiments()
+	live.OnReload(setExperiments)
+	router.Use(handler.AssignExperiments)
+
+	maintenance := &Maintenance{}
+	maintenance.Set(config.MaintenanceMode)
+	handler.SetMaintenance(maintenance)
+	router.Use(handler.BlockDuringMaintenance)
+	maintenanceMode := config.MaintenanceMode
+	live.OnReload(func() {
+		// Switching maintenance mode in the admin area holds until MAINTENANCE_MODE changes
+		if enabled := live.Current().MaintenanceMode; enabled != maintenanceMode {
+			maintenanceMode = enabled
+			maintenance.Set(enabled)
+		}
+	})
+
+	// Requests fail fast while the database doesn't answer instead of each waiting for it
+	breaker := repo.NewBreaker(config.DBBreakerThreshold, config.DBBreakerCooldown)
+	if err := db.Use(breaker); err != nil {
+		log.Fatalf("Failed to set up the database circuit breaker: %v", err)
+	}
+	handler.SetBreaker(breaker)
+	handler.carts.SetQueryTimeout(config.DBQueryTimeout)
+	router.Use(handler.BlockWhileDatabaseDown)
+	if config.DBReplicaDSNs != "" {
+		// Customers see their changes on the page they are redirected to after making them
+		router.Use(ReadYourWrites(config.DBReplicaPinAfterWrite))
+	}
+
+	tracker, err := NewTracker(config, handler.repo)
+	if err != nil {
+		log.Fatalf("Failed to set up analytics: %v", err)
+	}
+	if tracker != nil {
+		tracker.Start()
+		handler.SetTracker(tracker)
+	}
+	router.Use(handler.TrackPageViews)
+	router.Use(handler.TrackDevices)
+	// Staff members viewing a customer's cart are kept to the cart and the catalog
+	router.Use(handler.GuardImpersonation)
+
+	if config.CORSAllowedOrigins != "" {
+		router.Use(CORS(CORSOptions{
+			AllowedOrigins:   splitList(config.CORSAllowedOrigins),
+			AllowedMethods:   splitList(config.CORSAllowedMethods),
+			AllowedHeaders:   splitList(config.CORSAllowedHeaders),
+			AllowCredentials: config.CORSAllowCredentials,
+			MaxAge:           config.CORSMaxAge,
+		}))
+	}
+
 	// CSRF Protection
 	csrfMiddleware := csrf.Protect(
 		[]byte(config.SessionSecret),
-		csrf.Secure(false), // Set to true in production
+		csrf.Secure(tlsEnabled(config)),
 		csrf.Path("/"),
 		csrf.MaxAge(3600), // 1 hour CSRF token duration
 	)
 
 	// Add routes
+	router.GET("/static/*file", gin.WrapH(http.StripPrefix("/static", handler.assets)))
 	router.GET("/", handler.ShowCart)
+	router.GET("/products", handler.ShowProducts)
+	router.GET("/products/:id", handler.ShowProduct)
+	router.GET("/orders/:id", handler.ShowOrder)
 	router.POST("/add-item", handler.AddItem)
+	router.POST("/add-bundle", handler.AddBundle)
 	router.POST("/remove-item", handler.RemoveItem)
+	router.POST("/cart/items/reorder", handler.ReorderItems)
+	router.POST("/cart/items/group", handler.GroupItems)
+	router.POST("/restore-item", handler.RestoreItem)
+	router.POST("/undo-change", handler.UndoChange)
+	router.POST("/notifications/read", handler.MarkNotificationsRead)
+	router.POST("/subscribe-item", handler.SubscribeItem)
+	router.GET("/subscriptions", handler.ShowSubscriptions)
+	router.POST("/subscriptions/:id/:action", handler.UpdateSubscription)
+	router.POST("/redeem-gift-card", handler.RedeemGiftCard)
+	router.POST("/carts/new", handler.CreateCart)
+	router.POST("/carts/switch", handler.SwitchCart)
+	router.POST("/carts/rename", handler.RenameCart)
+	router.POST("/carts/delete", handler.DeleteCart)
+	router.POST("/create-referral-code", handler.CreateReferralCode)
+	router.POST("/apply-referral-code", handler.ApplyReferralCode)
+	router.POST(stopImpersonationPath, handler.StopImpersonating)
+
+	if local, ok := media.(*storage.Local); ok {
+		router.GET("/media/*key", gin.WrapH(http.StripPrefix("/media", local)))
+	}
+	var replicas *repo.ReplicaSet
+	if config.DBReplicaDSNs != "" {
+		var err error
+		replicas, err = repo.ConnectReplicaSet(config, db)
+		if err != nil {
+			log.Fatalf("Failed to connect to the read replicas: %v", err)
+		}
+		go replicas.Monitor(context.Background(), config.DBReplicaCheckInterval)
+		handler.repo.SetReplicas(replicas)
+	}
+
+	bus := events.NewBus()
+	handler.SetEventBus(bus)
+	// Customers are notified of the price drops and shipped orders of their carts
+	bus.Attach(notification.NewNotifier(handler.repo))
+	scheduler := jobs.NewScheduler()
+	// Scheduled jobs take a lock in the database, so each runs on one instance when several are deployed
+	scheduler.SetLocker(handler.repo)
+	// Emails are queued in the database and sent by the job workers, which retry them while the mail
+	// server is unavailable
+	queue := jobs.NewQueue(handler.repo)
+	worker := jobs.NewWorker(handler.repo, config.JobWorkers, config.JobPollInterval)
+	worker.Register(mail.SendJob, 0, mail.HandleSend(newMailer(config)))
+	mailer := mail.NewQueued(queue)
+	var payments payment.Provider
+	if config.PaymentProvider != "" {
+		payments = newPaymentProvider(config)
+	}
+	allocation, err := warehouse.NewStrategy(config.WarehouseStrategy)
+	if err != nil {
+		log.Fatalf("Failed to set up warehouse allocation: %v", err)
+	}
+	handler.repo.SetAllocationStrategy(allocation)
+	// Products and prices are cached in Redis, shared by every instance, when CACHE_URL is set
+	var cacheStore cache.Store
+	if config.CacheURL != "" {
+		store, err := cache.NewRedis(config.CacheURL)
+		if err != nil {
+			log.Fatalf("Failed to set up the cache: %v", err)
+		}
+		cacheStore = store
+		handler.repo.SetProductCache(store, config.CacheTTL)
+	}
+	// Products sell at the prices of the pricing service, where it has them, when PRICE_SERVICE_URL is set
+	var prices pricing.Provider
+	if config.PriceServiceURL != "" {
+		prices = pricing.NewHTTPProvider(config.PriceServiceURL)
+		if cacheStore != nil {
+			prices = pricing.NewSharedCachedProvider(prices, cacheStore, config.CacheTTL)
+		}
+		prices = pricing.NewCachedProvider(prices, config.PriceCacheTTL)
+		handler.SetPriceProvider(prices)
+	}
+	// The admin handler shares the repository, so carts staff check out or reprice are charged alike
+	handler.repo.SetReferralReward(config.ReferralReward)
+	handler.repo.SetDownloadLimits(config.DownloadLimit, config.DownloadTTL)
+	handler.repo.SetSubscriptionDiscount(config.SubscriptionDiscount)
+	handler.repo.SetCheckoutTTL(config.CheckoutTTL)
+	handler.repo.SetCharges(cart.Charges{
+		TaxRate:          config.TaxRate,
+		Shipping:         config.ShippingCost,
+		FreeShippingFrom: config.FreeShippingFrom,
+	})
+	if config.AdminUser != "" || config.OIDCIssuerURL != "" {
+		admin := NewAdminHandler(db, media, config.MediaURLTTL)
+		if config.OIDCIssuerURL != "" {
+			admin.SetSSO(newAdminSSO(config))
+		}
+		// Approving held payments checks carts out and repricing recomputes their totals, like in the shop
+		admin.SetRepository(handler.repo)
+		admin.SetEventBus(bus)
+		admin.SetRequireStaff2FA(config.RequireStaff2FA)
+		admin.SetConfig(live)
+		admin.SetMaintenance(maintenance)
+		admin.SetPayments(payments)
+		admin.SetRefundRestock(config.RefundRestock)
+		admin.SetPriceDrops(pricedrop.NewAlerter(admin.repo, mailer, bus, config.PublicBaseURL, config.PriceDropRepriceCarts))
+		live.OnReload(func() {
+			admin.SetRequireStaff2FA(live.Current().RequireStaff2FA)
+		})
+		var adminRouter gin.IRouter = router
+		if config.AdminAllowedIPs != "" || config.AdminDeniedIPs != "" {
+			// The lists were validated when the configuration was loaded
+			filter := &ipfilter.Filter{}
+			filter.Allow, _ = ipfilter.ParseList(config.AdminAllowedIPs)
+			filter.Deny, _ = ipfilter.ParseList(config.AdminDeniedIPs)
+			filter.TrustedProxies, _ = ipfilter.ParseList(config.TrustedProxies)
+			adminRouter = router.Group("", RestrictIPs(filter))
+		}
+		admin.RegisterRoutes(adminRouter, gin.Accounts{config.AdminUser: config.AdminPassword})
+
+		// Webhooks are registered through the admin endpoints
+		dispatcher := webhook.NewDispatcher(admin.repo)
+		bus.Attach(dispatcher)
+		scheduler.Every("webhook deliveries", config.WebhookPollInterval, dispatcher.DeliverDue)
+	}
+
+	handler.SetRecommender(recommend.NewBoughtTogether(handler.repo))
+	handler.SetPriceRefreshAfter(config.PriceRefreshAfter)
+	handler.carts.SetUndoWindow(config.CartUndoWindow)
+	handler.carts.SetReservationTTL(config.ReservationTTL)
+
+	if config.SearchURL != "" {
+		index := search.NewElasticsearch(config.SearchURL, config.SearchIndex)
+		if err := index.EnsureIndex(context.Background()); err != nil {
+			log.Printf("Failed to create the product search index: %v", err)
+		}
+		handler.repo.SetProductIndex(index)
+	}
+
+	// Cart links point to PUBLIC_BASE_URL, reminder emails and QR codes open the cart on other devices
+	if config.PublicBaseURL != "" {
+		secret := []byte(config.SessionSecret)
+		handler.SetCartLinks(reminder.NewCartLinks(config.PublicBaseURL, secret, config.ReminderLinkTTL))
+		handler.SetCartHandoff(reminder.NewCartLinks(config.PublicBaseURL, secret, config.CartHandoffTTL))
+		router.GET(reminder.ResumePath, handler.ResumeCart)
+		router.GET("/cart/qr.png", handler.CartQRCode)
+	}
+	// Download links point to PUBLIC_BASE_URL and are emailed once carts with digital products are checked out
+	if config.PublicBaseURL != "" {
+		links := download.NewLinks(config.PublicBaseURL, []byte(config.SessionSecret))
+		handler.SetDownloadLinks(links)
+		router.GET(download.PathPrefix+":id", handler.Download)
+		sender := download.NewSender(handler.repo, mailer, links)
+		scheduler.Every("download emails", config.DownloadEmailInterval, func(ctx context.Context) error {
+			sent, err := sender.Run(ctx)
+			if sent > 0 {
+				log.Printf("Sent %d download emails", sent)
+			}
+			return err
+		})
+	}
+	if config.ReminderAfter > 0 {
+		sender := newReminderSender(config, handler.repo, mailer)
+		scheduler.Every("abandoned-cart reminders", config.ReminderInterval, func(ctx context.Context) error {
+			sent, err := sender.Run(ctx)
+			if sent > 0 {
+				log.Printf("Sent %d abandoned-cart reminders", sent)
+			}
+			return err
+		})
+	}
+	// Notifications link to the product pages under PUBLIC_BASE_URL
+	if config.PublicBaseURL != "" {
+		notifier := restock.NewNotifier(handler.repo, mailer, config.PublicBaseURL)
+		handler.SetStockNotifications(true)
+		router.POST("/products/:id/notify", handler.SubscribeToStock)
+		scheduler.Every("restock notifications", config.RestockInterval, func(ctx context.Context) error {
+			sent, err := notifier.Run(ctx)
+			if sent > 0 {
+				log.Printf("Sent %d restock notifications", sent)
+			}
+			return err
+		})
+	}
+	alerter := lowstock.NewAlerter(handler.repo, mailer, splitList(config.LowStockEmails), bus, config.LowStockSnooze)
+	scheduler.Every("low-stock alerts", config.LowStockInterval, func(ctx context.Context) error {
+		alerted, err := alerter.Run(ctx)
+		if alerted > 0 {
+			log.Printf("Alerted staff about %d products running low", alerted)
+		}
+		return err
+	})
+	// Payment providers send customers back to PUBLIC_BASE_URL, which is required with PAYMENT_PROVIDER
+	if payments != nil {
+		handler.SetPayments(payments, config.PublicBaseURL)
+		if checker := newRiskChecker(config); checker != nil {
+			handler.SetRiskChecker(checker, config.RiskCountryHeader)
+		}
+		if config.VATID != "" {
+			viesClient := vat.NewVIES(config.VIESURL, config.VATID)
+			handler.SetVATValidator(vat.NewCached(viesClient, config.VATCacheTTL), config.VATID)
+		}
+		router.POST("/checkout", handler.Checkout)
+		router.GET("/checkout", handler.ShowCheckout)
+		router.POST("/checkout/address", handler.SetCheckoutAddress)
+		router.POST("/checkout/shipping", handler.SetCheckoutShipping)
+		router.POST("/checkout/payment", handler.StartPayment)
+		router.POST("/checkout/confirm", handler.ConfirmCheckout)
+		router.GET("/checkout/return", handler.PaymentReturn)
+		router.GET("/checkout/cancel", handler.PaymentCancel)
+		router.POST("/checkout/cancel", handler.CancelCheckout)
+		router.POST(paymentWebhookPath, handler.PaymentWebhook)
+	}
+	if config.InventoryWebhookSecret != "" {
+		handler.SetInventorySecret(config.InventoryWebhookSecret)
+		router.POST(inventoryWebhookPath, handler.InventoryWebhook)
+	}
+	scorer := abandonment.NewScorer(handler.repo)
+	scheduler.Every("abandonment scoring", config.AbandonmentInterval, func(ctx context.Context) error {
+		scored, err := scorer.Run(ctx)
+		if scored > 0 {
+			log.Printf("Scored %d open carts by abandonment risk", scored)
+		}
+		return err
+	})
+	scheduler.Every("cross-sell associations", config.CrossSellInterval, func(ctx context.Context) error {
+		stored, err := handler.repo.ComputeAssociations(ctx, time.Now())
+		if err == nil {
+			log.Printf("Stored %d associations of products bought together", stored)
+		}
+		return err
+	})
+	orderer := subscription.NewOrderer(handler.repo, handler.carts)
+	scheduler.Every("subscription orders", config.SubscriptionInterval, func(ctx context.Context) error {
+		placed, err := orderer.Run(ctx)
+		if placed > 0 {
+			log.Printf("Placed %d subscription orders", placed)
+		}
+		return err
+	})
+	if config.ReservationTTL > 0 {
+		scheduler.Every("reservation release", config.ReservationInterval, func(ctx context.Context) error {
+			released, err := handler.repo.ReleaseExpiredReservations(ctx, time.Now())
+			if released > 0 {
+				log.Printf("Released the expired reservations of %d carts", released)
+			}
+			return err
+		})
+	}
+	scheduler.Every("session cleanup", config.SessionCleanupInterval, func(ctx context.Context) error {
+		deleted, err := handler.repo.SweepExpiredSessions(ctx, time.Now(), config.SessionCleanupBatch)
+		if deleted > 0 {
+			log.Printf("Deleted %d expired sessions", deleted)
+		}
+		return err
+	})
+	scheduler.Start(context.Background())
+	worker.Start(context.Background())
+
+	if config.JWTSigningKeys != "" {
+		keys, err := auth.ParseKeySet(config.JWTSigningKeys)
+		if err != nil {
+			log.Fatalf("Invalid JWT_SIGNING_KEYS: %v", err)
+		}
+		handler.RegisterAPIRoutes(router, auth.NewIssuer(keys))
+	}
+
+	authHandler := NewAuthHandler(db, loginProviders(config)...)
+	authHandler.SetEmailLimits(config.EmailLimitPerIP, config.EmailLimitPerAddress)
+	authHandler.SetSessionLimit(config.MaxSessionsPerUser, config.SessionLimitCarts, config.SessionMaxAge)
+	authHandler.SetLoginLockout(config.LoginFailuresPerEmail, config.LoginFailuresPerIP, config.LoginLockout, config.LoginMaxLockout)
+	handler.SetLoginProviders(authHandler.Providers())
+	router.GET("/auth/:provider/login", authHandler.Login)
+	router.GET("/auth/:provider/callback", authHandler.Callback)
+	router.POST("/auth/2fa", authHandler.VerifyTwoFactor)
+	router.POST("/login", authHandler.PasswordLogin)
+	router.POST("/logout", authHandler.Logout)
+	// Reset and login links point to PUBLIC_BASE_URL, never to the host of the request, which could be forged
+	if config.PublicBaseURL != "" {
+		authHandler.EnablePasswordReset(handler.Template, mailer, config.PublicBaseURL, config.PasswordResetTTL)
+		authHandler.SetThemes(handler.themes)
+		handler.SetPasswordReset(true)
+		router.POST("/forgot-password", authHandler.ForgotPassword)
+		router.GET("/reset-password/:token", authHandler.ShowResetPassword)
+		router.POST("/reset-password/:token", authHandler.ResetPassword)
+
+		authHandler.EnableLoginLinks(auth.NewLoginLinks(config.PublicBaseURL, []byte(config.SessionSecret), config.LoginLinkTTL), mailer)
+		handler.SetLoginLinks(true)
+		router.POST(auth.LoginLinkPath, authHandler.RequestLoginLink)
+		router.GET(auth.LoginLinkPath, authHandler.LoginWithLink)
+
+		handler.EnableEmailChanges(auth.NewEmailChangeLinks(config.PublicBaseURL, []byte(config.SessionSecret), config.LoginLinkTTL), mailer)
+		handler.SetEmailChangeLimit(config.EmailLimitPerAddress)
+		router.GET(auth.EmailChangePath, handler.ConfirmEmailChange)
+
+		verificationLinks := auth.NewVerificationLinks(config.PublicBaseURL, []byte(config.SessionSecret), config.VerificationLinkTTL)
+		authHandler.EnableEmailVerification(verificationLinks, mailer, config.VerificationCooldown)
+		handler.EnableEmailVerification(verificationLinks, mailer, config.VerificationCooldown, config.RequireVerifiedEmail)
+		router.GET(auth.VerifyEmailPath, handler.VerifyEmail)
+		router.POST("/account/email/verification", handler.ResendVerification)
+	}
+	live.OnReload(func() {
+		cfg := live.Current()
+		authHandler.SetEmailLimits(cfg.EmailLimitPerIP, cfg.EmailLimitPerAddress)
+		authHandler.SetSessionLimit(cfg.MaxSessionsPerUser, cfg.SessionLimitCarts, cfg.SessionMaxAge)
+		handler.SetEmailChangeLimit(cfg.EmailLimitPerAddress)
+	})
+	go watchConfig(context.Background(), live)
+	authHandler.SetTOTPIssuer(config.TOTPIssuer)
+	router.GET("/account", handler.ShowAccount)
+	router.POST("/account", handler.UpdateAccount)
+	router.POST("/account/sessions/:id/revoke", handler.RevokeSession)
+	router.POST("/account/2fa/enroll", authHandler.EnrollTwoFactor)
+	router.POST("/account/2fa/confirm", authHandler.ConfirmTwoFactor)
 
 	// Add CSRF token to response headers
 	router.Use(func(c *gin.Context) {
@@ -81,45 +711,191 @@ func InitAPI(db *gorm.DB, templateFS embed.FS, config config.Config) {
 		c.Next()
 	})
 
-	address := fmt.Sprintf(":%s", config.APIPort)
-	if err := http.ListenAndServe(address, csrfMiddleware(router)); err != nil {
+	ln, err := Listen(config)
+	if err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+	if err := serve(config, ln, NegotiateAPIVersion(skipCSRFForAPI(csrfMiddleware(router)))); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
 
-// NewCartHandler creates a new CartHandler with the given dependencies.
-func NewCartHandler(db *gorm.DB, templateFS embed.FS, config config.Config, pattern string) *CartHandler {
-	tpl := template.Must(template.ParseFS(templateFS, pattern))
+// loginProviders returns the social login providers enabled in the configuration.
+func loginProviders(config config.Config) []*auth.OAuthProvider {
+	callbackURL := func(name string) string {
+		return strings.TrimSuffix(config.OAuthRedirectBaseURL, "/") + "/auth/" + name + "/callback"
+	}
+
+	var providers []*auth.OAuthProvider
+	if config.GoogleClientID != "" && config.GoogleClientSecret != "" {
+		providers = append(providers, auth.NewGoogleProvider(
+			config.GoogleClientID, config.GoogleClientSecret, callbackURL(auth.ProviderGoogle)))
+	}
+	if config.GitHubClientID != "" && config.GitHubClientSecret != "" {
+		providers = append(providers, auth.NewGitHubProvider(
+			config.GitHubClientID, config.GitHubClientSecret, callbackURL(auth.ProviderGitHub)))
+	}
+	return providers
+}
+
+// newReminderSender creates the abandoned-cart reminder sender.
+func newReminderSender(config config.Config, r *repo.Repository, mailer mail.Mailer) *reminder.Sender {
+	links := reminder.NewCartLinks(config.PublicBaseURL, []byte(config.SessionSecret), config.ReminderLinkTTL)
+	return reminder.NewSender(r, mailer, links, config.ReminderAfter)
+}
+
+// newMailer returns the SMTP mailer of the configuration, or one writing emails to the log when no SMTP
+// server is configured.
+func newMailer(config config.Config) mail.Mailer {
+	if config.SMTPHost != "" {
+		return mail.NewSMTP(config.SMTPHost, strconv.Itoa(config.SMTPPort), config.SMTPUsername, config.SMTPPassword, config.MailFrom)
+	}
+	return mail.LogMailer{}
+}
+
+// newPaymentProvider creates the payment provider selected by PAYMENT_PROVIDER.
+func newPaymentProvider(config config.Config) payment.Provider {
+	if config.PaymentProvider == "fake" {
+		log.Println("Payments are taken with the fake payment provider, which approves every payment")
+		return payment.NewFake()
+	}
+	baseURL := payment.PayPalSandboxURL
+	if config.PayPalEnvironment == "live" {
+		baseURL = payment.PayPalLiveURL
+	}
+	return payment.NewPayPal(baseURL, config.PayPalClientID, config.PayPalClientSecret, config.PayPalWebhookID)
+}
+
+// newRiskChecker creates the risk checks of the configuration, nil when none is enabled.
+func newRiskChecker(config config.Config) risk.Checker {
+	var checkers risk.Checkers
+	if config.RiskVelocityLimit > 0 {
+		checkers = append(checkers, risk.NewVelocity(config.RiskVelocityLimit, config.RiskVelocityWindow, config.RiskVelocityAction))
+	}
+	if config.RiskCountryHeader != "" {
+		checkers = append(checkers, risk.CountryMismatch{Action: config.RiskCountryAction})
+	}
+	if config.RiskServiceURL != "" {
+		checkers = append(checkers, risk.NewHTTPChecker(config.RiskServiceURL))
+	}
+	if len(checkers) == 0 {
+		return nil
+	}
+	return checkers
+}
 
-	// Default prices for development and testing
-	defaultPrices := map[string]float64{
-		"shoe":  10.0,
-		"purse": 20.0,
-		"bag":   30.0,
-		"watch": 40.0,
+// newStorage creates the storage backend for uploads and returns the origin its signed URLs point
+// to when that isn't this server.
+func newStorage(config config.Config) (storage.Storage, string) {
+	if config.StorageBackend == "s3" {
+		s3 := storage.NewS3(config.S3Endpoint, config.S3Region, config.S3Bucket,
+			config.S3AccessKeyID, config.S3SecretAccessKey)
+		return s3, s3.Origin()
 	}
+	return storage.NewLocal(config.StorageLocalDir, "/media", []byte(config.SessionSecret)), ""
+}
+
+// skipCSRFForAPI disables CSRF checks for the JSON API. API requests authenticate with bearer tokens
+// rather than cookies, so they can't be forged cross-site. The payment webhooks, which are verified with
+// the payment provider, and the signed inventory webhooks are exempt too. Admin requests are only exempt
+// when they come from the shop itself, see sameOrigin; browsers send cached basic auth credentials with
+// forged requests as well.
+func skipCSRFForAPI(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") || r.URL.Path == paymentWebhookPath ||
+			r.URL.Path == inventoryWebhookPath ||
+			(strings.HasPrefix(r.URL.Path, "/admin/") && sameOrigin(r)) {
+			r = csrf.UnsafeSkipCheck(r)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sameOrigin reports whether the Origin of the request, or its Referer without one, is the host the
+// request was sent to. Pages of other sites can't set either, so scripts calling the admin endpoints send
+// the Origin of the shop.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		origin = r.Header.Get("Referer")
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host != "" && u.Host == r.Host
+}
+
+// NewCartHandler creates a new CartHandler with the given dependencies.
+func NewCartHandler(db *gorm.DB, templateFS embed.FS, config config.Config, pattern string) *CartHandler {
+	assets := static.Default()
+	tpl := template.Must(template.New("").Funcs(template.FuncMap{"t": i18n.T, "asset": assets.URL}).ParseFS(templateFS, pattern))
+	// Pages referring to missing assets would fail while they are rendered
+	tpl = template.Must(tpl, static.CheckTemplates(tpl, assets.Has))
 
+	cartRepo := repo.NewRepository(db)
 	return &CartHandler{
-		repo:          repo.NewRepository(db),
-		Template:      tpl,
-		config:        config,
-		productPrices: defaultPrices,
+		repo:       cartRepo,
+		Template:   tpl,
+		assets:     assets,
+		config:     config,
+		carts:      service.NewCartService(cartRepo),
+		currencies: pricing.NewCurrencies(config.Currency, nil),
 	}
 }
 
 // ShowCart displays the shopping cart page.
 func (h *CartHandler) ShowCart(c *gin.Context) {
+	h.showCart(c, AddItemForm{}, nil)
+}
+
+// showCart renders the cart page. With fieldErrors, the page is answered with 422 Unprocessable Entity
+// and shows the add-item form again with the submitted values and the errors of its fields.
+func (h *CartHandler) showCart(c *gin.Context, form AddItemForm, fieldErrors map[string]string) {
 	session := sessions.Default(c)
-	data := TemplateData{}
+	data := TemplateData{
+		Locale:      detectLocale(c, session).String(),
+		Currency:    sessionCurrency(session),
+		Experiments: experimentVariants(c),
+		Shop:        shopName(c),
+		Form:        form,
+		FieldErrors: fieldErrors,
+	}
+	if len(fieldErrors) > 0 {
+		c.Status(http.StatusUnprocessableEntity)
+	}
 
 	flashes := session.Flashes()
+	removed := session.Flashes(removedItemFlash)
+	notices := session.Flashes(noticeFlash)
 	if len(flashes) > 0 {
 		data.Error = flashes[0].(string)
+	}
+	if len(notices) > 0 {
+		data.Notice = notices[0].(string)
+	}
+	if len(flashes) > 0 || len(removed) > 0 || len(notices) > 0 {
 		if err := session.Save(); err != nil {
 			log.Printf("Failed to save session: %v", err)
 		}
 	}
 
+	data.LoginProviders = h.loginProviders
+	data.PasswordReset = h.passwordReset
+	data.LoginLinks = h.loginLinks
+	data.CartHandoff = h.handoffLinks != nil
+	data.SubscriptionIntervals = cart.SubscriptionIntervals
+	data.Checkout = h.payments != nil
+	data.GroupByCategory = groupedByCategory(session)
+	_, data.TwoFactorPending = session.Get(pendingUserKey).(uint)
+	if userID, ok := session.Get("user_id").(uint); ok {
+		if u, err := h.repoFor(c).GetUser(userID); err == nil {
+			data.UserName = u.Name
+			if data.UserName == "" {
+				data.UserName = u.Email
+			}
+			h.addReferral(&data, userID)
+		}
+	}
+	data.Impersonation = impersonationView(session, data.UserName)
+
 	// Get or create a unique session ID
 	sessionID := session.Get("session_id")
 	if sessionID == nil {
@@ -142,16 +918,149 @@ func (h *CartHandler) ShowCart(c *gin.Context) {
 		sessionID = newSessionID
 	}
 
-	cart, err := h.repo.GetOrCreateCart(sessionID.(string))
+	data.CartName = currentCartName(session)
+	cart, err := h.pageCart(c, session, sessionID.(string), data.CartName)
+	if err == nil && !readOnlyView(session) && h.refreshPrices(c.Request.Context(), cart, sessionUserID(session)) {
+		data.Notice = "Prices in your cart were updated"
+		before := cart
+		cart, err = h.repoFor(c).GetOrCreateCart(sessionID.(string), data.CartName)
+		if err == nil {
+			publishPriceDrops(h.events, before, cart)
+		}
+	}
+	if err == nil {
+		data.Carts, err = h.cartNames(c, sessionID.(string))
+	}
 	if err != nil {
 		data.Error = "Failed to load cart"
 	} else {
+		if len(removed) > 0 {
+			data.RemovedItem = h.removedItemView(c, cart.ID, removed[0])
+		}
+		if h.payments != nil {
+			if s, err := h.repoFor(c).GetCheckout(cart.ID); err == nil {
+				data.CheckingOut = s.Active(time.Now())
+				data.InReview = s.Status == checkout.StatusReview
+			}
+		}
+		h.addProductStrips(c, session, &data, cart)
+		h.addCartHistory(c, &data, cart)
+		h.addNotifications(c, session, &data, sessionID.(string))
+		if cart.ReservedUntil != nil {
+			if left := time.Until(*cart.ReservedUntil); left > 0 {
+				data.ReservedUntil = cart.ReservedUntil.UTC().Format(time.RFC3339)
+				data.ReservedFor = fmt.Sprintf("%d:%02d", int(left.Minutes()), int(left.Seconds())%60)
+			}
+		}
+		// Pages showing a message are only shown once, everything else follows from the cart
+		if data.Error == "" && data.Notice == "" && data.RemovedItem == nil && len(data.FieldErrors) == 0 &&
+			notModified(c, h.cartPageETag(cart, data)) {
+			return
+		}
 		data.CartItems = h.CreateCartItemViews(cart.CartItems)
+		for i, item := range cart.CartItems {
+			if item.TierQuantity > 0 {
+				data.CartItems[i].TierPrice = h.currencies.Format(item.Price, data.Currency)
+			}
+		}
+		h.addThumbnails(c, data.CartItems)
+		data.ItemGroups = h.itemGroups(c, data.CartItems, data.GroupByCategory)
+		data.Subtotal = h.currencies.Format(cart.Subtotal, data.Currency)
+		data.Discounts = h.CreateDiscountViews(cart.Discounts, data.Currency)
+		if cart.DiscountTotal > 0 {
+			data.DiscountTotal = "-" + h.currencies.Format(cart.DiscountTotal, data.Currency)
+		}
+		if cart.Tax > 0 {
+			data.Tax = h.currencies.Format(cart.Tax, data.Currency)
+		}
+		if cart.Shipping > 0 {
+			data.Shipping = h.currencies.Format(cart.Shipping, data.Currency)
+		}
+		if cart.Credit > 0 {
+			data.Credit = "-" + h.currencies.Format(cart.Credit, data.Currency)
+		}
+		data.Total = h.currencies.Format(cart.Total, data.Currency)
 	}
 
 	h.RenderTemplate(c, data)
 }
 
+// pageCart returns the named cart of the session shown on the cart page, creating it if needed. Staff
+// viewing the customer's cart read-only get it as it is, and an empty cart where there is no open one.
+func (h *CartHandler) pageCart(c *gin.Context, session sessions.Session, sessionID, name string) (*cart.Cart, error) {
+	if !readOnlyView(session) {
+		return h.carts.GetCart(c.Request.Context(), sessionID, name)
+	}
+	existing, err := h.repoFor(c).GetExistingCart(sessionID, name)
+	if errors.Is(err, cart.ErrCartNotFound) || (err == nil && existing.Status != cart.StatusOpen) {
+		return &cart.Cart{SessionID: sessionID, Name: name, Status: cart.StatusOpen}, nil
+	}
+	return existing, err
+}
+
+// cartPageETag returns the ETag of the cart page. Besides the cart, the page depends on:
+//   - the shop, language and currency
+//   - the logged-in user, a pending two-factor login, their referral rewards and staff viewing the cart
+//   - the other carts of the session and how items are grouped
+//   - the checkout and the time left on the stock held for the cart
+//   - the recently viewed and recommended products
+//   - the recent activity, which includes changes not bumping the cart version, and the notifications
+//   - the signed thumbnail URLs and the token of the bot check, which are renewed every half of their
+//     lifetime
+func (h *CartHandler) cartPageETag(userCart *cart.Cart, data TemplateData) string {
+	variant := []string{data.Shop, data.Locale, data.Currency, data.UserName, strconv.FormatBool(data.TwoFactorPending),
+		strings.Join(data.LoginProviders, ","), data.ReferralCode, strings.Join(data.Carts, ","),
+		strconv.FormatBool(data.CheckingOut), strconv.FormatBool(data.InReview), data.ReservedFor,
+		strconv.FormatBool(data.GroupByCategory)}
+	if data.Impersonation != nil {
+		variant = append(variant, "impersonated", strconv.FormatBool(data.Impersonation.Write))
+	}
+	experiments := make([]string, 0, len(data.Experiments))
+	for name, v := range data.Experiments {
+		experiments = append(experiments, name+"="+v)
+	}
+	sort.Strings(experiments)
+	variant = append(variant, experiments...)
+	for _, reward := range data.ReferralRewards {
+		variant = append(variant, reward.Code+"="+reward.Balance)
+	}
+	for _, p := range data.RecentlyViewed {
+		variant = append(variant, p.Name+"="+p.Price)
+	}
+	for _, p := range data.Recommendations {
+		variant = append(variant, p.Name+"="+p.Price)
+	}
+	for _, change := range data.CartHistory {
+		variant = append(variant, change.Time+" "+change.Description+" "+strconv.FormatBool(change.Undone))
+	}
+	for _, n := range data.Notifications {
+		variant = append(variant, n.Time+" "+n.Message+" "+strconv.FormatBool(n.Unread))
+	}
+	variant = append(variant, strconv.FormatInt(data.UnreadNotifications, 10))
+	if h.storage != nil && h.mediaTTL > 0 {
+		window := time.Now().UnixNano() / int64(max(h.mediaTTL/2, time.Second))
+		variant = append(variant, strconv.FormatInt(window, 10))
+	}
+	if h.botCheck != nil {
+		variant = append(variant, "bot:"+strconv.FormatInt(h.botCheck.TokenWindow(), 10))
+	}
+	return cartETag(userCart, variant...)
+}
+
+// refreshPrices re-fetches the prices of the cart items once they are older than the refresh window
+// and reports whether any of them changed.
+func (h *CartHandler) refreshPrices(ctx context.Context, userCart *cart.Cart, userID *uint) bool {
+	changed, err := h.carts.RefreshPrices(ctx, userCart, userID, h.priceRefreshAfter)
+	if errors.Is(err, cart.ErrCartLocked) {
+		// The prices of a cart being checked out stay what the customer is paying
+		return false
+	} else if err != nil {
+		log.Printf("Failed to refresh cart prices: %v", err)
+		return false
+	}
+	return changed
+}
+
 func generateSessionID() (string, error) {
 	// Generate a random session ID using crypto/rand
 	b := make([]byte, 32)
@@ -161,146 +1070,248 @@ func generateSessionID() (string, error) {
 	return fmt.Sprintf("%x", b), nil
 }
 
-// AddItem adds a product to the user's cart.
+// rotateSession replaces the session with a new one under a new session ID, deleting the old one from
+// the store, so a session ID planted in the browser before logging in doesn't get logged in with it
+// (session fixation). Only the values of the keys in keep are carried over; it returns the new ID.
+func rotateSession(session sessions.Session, keep ...string) (string, error) {
+	store, ok := session.(interface{ Session() *gsessions.Session })
+	if !ok {
+		return "", errors.New("session can't be rotated")
+	}
+	sessionID, err := generateSessionID()
+	if err != nil {
+		return "", err
+	}
+	kept := make(map[string]interface{}, len(keep))
+	for _, key := range keep {
+		if value := session.Get(key); value != nil {
+			kept[key] = value
+		}
+	}
+
+	// Saving the session expired deletes it from the store; saved again, the store creates a new one
+	current := store.Session()
+	options := *current.Options
+	session.Clear()
+	session.Options(sessions.Options{
+		Path: options.Path, Domain: options.Domain, MaxAge: -1,
+		Secure: options.Secure, HttpOnly: options.HttpOnly, SameSite: options.SameSite,
+	})
+	if err := session.Save(); err != nil {
+		return "", fmt.Errorf("failed to delete session: %w", err)
+	}
+	current.Options = &options
+	current.ID, current.IsNew = "", true
+
+	for key, value := range kept {
+		session.Set(key, value)
+	}
+	session.Set("session_id", sessionID)
+	return sessionID, nil
+}
+
+// AddItem adds a product to the user's cart. Invalid products and quantities show the form again with
+// an error next to each invalid field.
 func (h *CartHandler) AddItem(c *gin.Context) {
 	session := sessions.Default(c)
+	form := AddItemForm{Product: c.PostForm("product"), Quantity: c.PostForm("quantity")}
+	if h.isBot(c) {
+		h.redirectWithFlash(c, session, "We couldn't tell you apart from a bot, please try again")
+		return
+	}
 
-	product := c.PostForm("product")
-	if !isValidProduct(product) {
-		session.AddFlash("Invalid product selected")
-		if err := session.Save(); err != nil {
-			log.Printf("Failed to save session: %v", err)
-		}
-		c.Redirect(http.StatusFound, "/")
+	fieldErrors := map[string]string{}
+	sold, err := h.repoFor(c).HasProduct(form.Product)
+	if err != nil {
+		log.Printf("Failed to look up product: %v", err)
+		h.redirectWithFlash(c, session, "Failed to add item to cart")
+		return
+	}
+	if !sold {
+		fieldErrors["product"] = "Invalid product selected"
+	}
+	quantity, err := strconv.Atoi(form.Quantity)
+	if form.Quantity == "" {
+		fieldErrors["quantity"] = "Please enter a quantity"
+	} else if err != nil || quantity < 1 {
+		fieldErrors["quantity"] = "Quantity must be a valid number greater than 0"
+	} else if quantity > cart.MaxQuantity {
+		fieldErrors["quantity"] = "You can add at most 999 of a product"
+	}
+	if len(fieldErrors) > 0 {
+		h.showCart(c, form, fieldErrors)
 		return
 	}
 
-	quantityStr := c.PostForm("quantity")
-	if quantityStr == "" {
-		session.AddFlash("Please enter a quantity")
-		if err := session.Save(); err != nil {
-			log.Printf("Failed to save session: %v", err)
-		}
-		c.Redirect(http.StatusFound, "/")
+	sessionID := session.Get("session_id")
+	if sessionID == nil {
+		h.redirectWithFlash(c, session, "Invalid session")
 		return
 	}
 
-	quantity, err := strconv.Atoi(quantityStr)
-	if err != nil || quantity < 1 {
-		session.AddFlash("Quantity must be a valid number greater than 0")
-		if err := session.Save(); err != nil {
-			log.Printf("Failed to save session: %v", err)
+	cartName := currentCartName(session)
+	if err := h.carts.AddItem(c.Request.Context(), sessionID.(string), sessionUserID(session), cartName, form.Product, quantity); err != nil {
+		if field := addItemField(err); field != "" {
+			h.showCart(c, form, map[string]string{field: errorMessage(err, "Failed to add item to cart")})
+			return
 		}
-		c.Redirect(http.StatusFound, "/")
+		h.redirectWithFlash(c, session, errorMessage(err, "Failed to add item to cart"))
 		return
 	}
 
-	price, err := h.GetProductPrice(product)
+	h.publishCartEvent(c, events.TypeItemAdded, sessionID.(string), cartName, form.Product, quantity)
+	h.recordConversion(c, sessionID.(string), experiment.EventAddToCart)
+	h.track(c, analytics.Event{Type: analytics.TypeAddToCart, Product: form.Product, Quantity: quantity})
+	c.Redirect(http.StatusFound, "/")
+}
+
+// addItemField returns the field of the add-item form err is about, empty for errors about no field
+func addItemField(err error) string {
+	switch {
+	case errors.Is(err, service.ErrInvalidProduct), errors.Is(err, pricing.ErrProductNotFound),
+		errors.Is(err, productpkg.ErrOutOfStock):
+		return "product"
+	case errors.Is(err, cart.ErrInvalidQuantity), errors.Is(err, cart.ErrQuantityTooLarge):
+		return "quantity"
+	}
+	return ""
+}
+
+// RemoveItem removes an item from the user's cart.
+func (h *CartHandler) RemoveItem(c *gin.Context) {
+	session := sessions.Default(c)
+
+	itemID, err := strconv.ParseUint(c.PostForm("cart_item_id"), 10, 32)
 	if err != nil {
-		session.AddFlash(err.Error())
-		if err := session.Save(); err != nil {
-			log.Printf("Failed to save session: %v", err)
-		}
-		c.Redirect(http.StatusFound, "/")
+		h.redirectWithFlash(c, session, "Invalid item ID")
 		return
 	}
 
 	sessionID := session.Get("session_id")
 	if sessionID == nil {
-		session.AddFlash("Invalid session")
-		if err := session.Save(); err != nil {
-			log.Printf("Failed to save session: %v", err)
-		}
-		c.Redirect(http.StatusFound, "/")
+		h.redirectWithFlash(c, session, "Invalid session")
 		return
 	}
 
-	userCart, err := h.repo.GetOrCreateCart(sessionID.(string))
+	cartName := currentCartName(session)
+	item, err := h.carts.RemoveItem(c.Request.Context(), sessionID.(string), cartName, uint(itemID))
 	if err != nil {
-		session.AddFlash("Failed to load cart")
-		if err := session.Save(); err != nil {
-			log.Printf("Failed to save session: %v", err)
-		}
-		c.Redirect(http.StatusFound, "/")
+		h.redirectWithFlash(c, session, errorMessage(err, "Failed to remove item"))
 		return
 	}
 
-	if err := h.repo.AddCartItem(userCart.ID, product, quantity, price); err != nil {
-		session.AddFlash("Failed to add item to cart")
-		if err := session.Save(); err != nil {
-			log.Printf("Failed to save session: %v", err)
-		}
-		c.Redirect(http.StatusFound, "/")
-		return
-	}
+	h.publishCartEvent(c, events.TypeItemRemoved, sessionID.(string), cartName, item.ProductName, item.Quantity)
 
+	// Offer to undo the removal on the next page
+	session.AddFlash(item.ID, removedItemFlash)
+	if err := session.Save(); err != nil {
+		log.Printf("Failed to save session: %v", err)
+	}
 	c.Redirect(http.StatusFound, "/")
 }
 
-// RemoveItem removes an item from the user's cart.
-func (h *CartHandler) RemoveItem(c *gin.Context) {
+// RestoreItem puts an item removed from the user's cart back.
+func (h *CartHandler) RestoreItem(c *gin.Context) {
 	session := sessions.Default(c)
 
 	itemID, err := strconv.ParseUint(c.PostForm("cart_item_id"), 10, 32)
 	if err != nil {
-		session.AddFlash("Invalid item ID")
-		if err := session.Save(); err != nil {
-			log.Printf("Failed to save session: %v", err)
-		}
-		c.Redirect(http.StatusFound, "/")
+		h.redirectWithFlash(c, session, "Invalid item ID")
 		return
 	}
 
 	sessionID := session.Get("session_id")
 	if sessionID == nil {
-		session.AddFlash("Invalid session")
-		if err := session.Save(); err != nil {
-			log.Printf("Failed to save session: %v", err)
-		}
-		c.Redirect(http.StatusFound, "/")
+		h.redirectWithFlash(c, session, "Invalid session")
 		return
 	}
 
-	userCart, err := h.repo.GetExistingCart(sessionID.(string))
+	cartName := currentCartName(session)
+	item, err := h.carts.RestoreItem(c.Request.Context(), sessionID.(string), cartName, uint(itemID))
 	if err != nil {
-		session.AddFlash("Cart not found")
-		if err := session.Save(); err != nil {
-			log.Printf("Failed to save session: %v", err)
-		}
-		c.Redirect(http.StatusFound, "/")
+		h.redirectWithFlash(c, session, errorMessage(err, "Failed to restore item"))
 		return
 	}
 
-	// Validate item belongs to cart
-	item, err := h.repo.GetCartItem(userCart.ID, uint(itemID))
-	if err != nil || item == nil {
-		session.AddFlash("Item not found")
-		if err := session.Save(); err != nil {
-			log.Printf("Failed to save session: %v", err)
+	h.publishCartEvent(c, events.TypeItemAdded, sessionID.(string), cartName, item.ProductName, item.Quantity)
+	c.Redirect(http.StatusFound, "/")
+}
+
+// removedItemView returns the item just removed from the cart for the undo notice, nil when it was
+// restored or purged since.
+func (h *CartHandler) removedItemView(c *gin.Context, cartID uint, flash interface{}) *CartItemView {
+	itemID, ok := flash.(uint)
+	if !ok {
+		return nil
+	}
+	deleted, err := h.repoFor(c).ListDeletedItems(cartID)
+	if err != nil {
+		log.Printf("Failed to list deleted items: %v", err)
+		return nil
+	}
+	for _, item := range deleted {
+		if item.ID == itemID && item.BundleGroup != 0 {
+			// The whole bundle was removed with the item
+			return &CartItemView{ID: item.ID, Product: item.BundleName, Quantity: item.BundleQuantity}
+		} else if item.ID == itemID {
+			return &CartItemView{ID: item.ID, Product: item.ProductName, Quantity: item.Quantity}
 		}
-		c.Redirect(http.StatusFound, "/")
+	}
+	return nil
+}
+
+// RedeemGiftCard applies the balance of a gift card to the user's cart.
+func (h *CartHandler) RedeemGiftCard(c *gin.Context) {
+	session := sessions.Default(c)
+
+	code := c.PostForm("code")
+	if strings.TrimSpace(code) == "" {
+		h.redirectWithFlash(c, session, "Please enter a gift card code")
 		return
 	}
 
-	if err := h.repo.RemoveCartItem(userCart.ID, uint(itemID)); err != nil {
-		session.AddFlash(err.Error())
-		if err := session.Save(); err != nil {
-			log.Printf("Failed to save session: %v", err)
-		}
-		c.Redirect(http.StatusFound, "/")
+	sessionID := session.Get("session_id")
+	if sessionID == nil {
+		h.redirectWithFlash(c, session, "Invalid session")
 		return
 	}
 
+	cartName := currentCartName(session)
+	if _, err := h.carts.RedeemGiftCard(c.Request.Context(), sessionID.(string), cartName, code); err != nil {
+		h.redirectWithFlash(c, session, errorMessage(err, "Failed to redeem gift card"))
+		return
+	}
+
+	h.publishCartEvent(c, events.TypeGiftCardRedeemed, sessionID.(string), cartName, "", 0)
+	c.Redirect(http.StatusFound, "/")
+}
+
+// redirectWithFlash shows the message on the cart page.
+func (h *CartHandler) redirectWithFlash(c *gin.Context, session sessions.Session, message string) {
+	session.AddFlash(message)
+	if err := session.Save(); err != nil {
+		log.Printf("Failed to save session: %v", err)
+	}
 	c.Redirect(http.StatusFound, "/")
 }
 
 // GetProductPrice returns the price of a product by name.
-func (h *CartHandler) GetProductPrice(name string) (float64, error) {
-	price, ok := h.productPrices[name]
-	if !ok {
-		return 0, fmt.Errorf("product not found: %s", name)
+func (h *CartHandler) GetProductPrice(ctx context.Context, name string) (float64, error) {
+	return h.carts.Price(ctx, name)
+}
+
+// detectLocale returns the locale to render the page in. A supported ?lang= parameter is remembered in
+// the session; otherwise the locale stored in the session wins over the Accept-Language header.
+func detectLocale(c *gin.Context, session sessions.Session) language.Tag {
+	if lang := c.Query("lang"); i18n.IsSupported(lang) {
+		session.Set("locale", lang)
+		if err := session.Save(); err != nil {
+			log.Printf("Failed to save session: %v", err)
+		}
 	}
-	return price, nil
+
+	locale, _ := session.Get("locale").(string)
+	return i18n.Match(locale, c.GetHeader("Accept-Language"))
 }
 
 // CreateCartItemViews converts cart items to view models
@@ -308,9 +1319,51 @@ func (h *CartHandler) CreateCartItemViews(items []cart.CartItem) []CartItemView
 	views := make([]CartItemView, len(items))
 	for i, item := range items {
 		views[i] = CartItemView{
-			ID:       item.ID,
-			Product:  item.ProductName,
-			Quantity: item.Quantity,
+			ID:               item.ID,
+			Product:          item.ProductName,
+			Quantity:         item.Quantity,
+			SubscriptionDays: item.SubscriptionDays,
+			TierQuantity:     item.TierQuantity,
+			Bundle:           item.BundleName,
+		}
+	}
+	return views
+}
+
+// addThumbnails sets signed thumbnail URLs on the views of products with an uploaded image.
+func (h *CartHandler) addThumbnails(c *gin.Context, views []CartItemView) {
+	if h.storage == nil || len(views) == 0 {
+		return
+	}
+
+	names := make([]string, len(views))
+	for i, view := range views {
+		names[i] = view.Product
+	}
+	products, err := h.repoFor(c).GetProductsByName(names)
+	if err != nil {
+		log.Printf("Failed to load product images: %v", err)
+		return
+	}
+
+	for i, view := range views {
+		key := products[view.Product].ThumbnailKey
+		if key == "" {
+			continue
+		}
+		if url, err := h.storage.SignedURL(key, h.mediaTTL); err == nil {
+			views[i].ThumbnailURL = url
+		}
+	}
+}
+
+// CreateDiscountViews converts cart discounts to view models
+func (h *CartHandler) CreateDiscountViews(discounts []cart.CartDiscount, currency string) []DiscountView {
+	views := make([]DiscountView, len(discounts))
+	for i, discount := range discounts {
+		views[i] = DiscountView{
+			Promotion: discount.Promotion,
+			Amount:    "-" + h.currencies.Format(discount.Amount, currency),
 		}
 	}
 	return views
@@ -318,17 +1371,78 @@ func (h *CartHandler) CreateCartItemViews(items []cart.CartItem) []CartItemView
 
 // RenderTemplate renders the cart template with the given data
 func (h *CartHandler) RenderTemplate(c *gin.Context, data TemplateData) {
+	data.Error = i18n.T(data.Locale, data.Error)
+	data.Notice = i18n.T(data.Locale, data.Notice)
+	for field, message := range data.FieldErrors {
+		data.FieldErrors[field] = i18n.T(data.Locale, message)
+	}
 	data.CSRFToken = csrf.Token(c.Request)
 	data.CSRFFieldName = csrf.TemplateField(c.Request)
-	if err := h.Template.ExecuteTemplate(c.Writer, "cart.html", data); err != nil {
+	data.BotFields, data.BotScript = h.botFields()
+	if err := h.templateFor(c).ExecuteTemplate(c.Writer, "cart.html", data); err != nil {
 		log.Printf("Failed to render template: %v", err)
 		http.Error(c.Writer, "Internal Server Error", http.StatusInternalServerError)
 	}
 }
 
-// SetProductPrices sets the product prices map for testing.
+// SetProductPrices overrides the catalog prices of the products in the map for testing.
 func (h *CartHandler) SetProductPrices(prices map[string]float64) {
-	h.productPrices = prices
+	h.repo.SetPriceProvider(pricing.StaticProvider(prices))
+}
+
+// SetPriceProvider sets the provider overriding the catalog prices of the products it knows.
+func (h *CartHandler) SetPriceProvider(provider pricing.Provider) {
+	h.repo.SetPriceProvider(provider)
+}
+
+// SetStorage sets the storage product images are served from. Signed URLs are valid for mediaTTL.
+func (h *CartHandler) SetStorage(store storage.Storage, mediaTTL time.Duration) {
+	h.storage = store
+	h.mediaTTL = mediaTTL
+}
+
+// SetPriceRefreshAfter makes ShowCart refresh item prices older than after; 0 disables refreshing.
+func (h *CartHandler) SetPriceRefreshAfter(after time.Duration) {
+	h.priceRefreshAfter = after
+}
+
+// SetEventBus sets the bus cart changes are published to.
+func (h *CartHandler) SetEventBus(bus *events.Bus) {
+	h.events = bus
+}
+
+// publishCartEvent notifies subscribers of the event bus of a cart change, with the cart's new total.
+func (h *CartHandler) publishCartEvent(c *gin.Context, eventType, sessionID, cartName, product string, quantity int) {
+	if h.events == nil || !h.events.Wants(eventType) {
+		return
+	}
+	userCart, err := h.repoFor(c).GetExistingCart(sessionID, cartName)
+	if err != nil {
+		log.Printf("Failed to load cart for event: %v", err)
+		return
+	}
+	h.events.Publish(events.Event{
+		Type:     eventType,
+		CartID:   userCart.ID,
+		Product:  product,
+		Quantity: quantity,
+		Total:    userCart.Total,
+	})
+}
+
+// SetLoginProviders sets the login providers offered on the cart page.
+func (h *CartHandler) SetLoginProviders(providers []string) {
+	h.loginProviders = providers
+}
+
+// SetPasswordReset offers to reset forgotten passwords on the cart page.
+func (h *CartHandler) SetPasswordReset(enabled bool) {
+	h.passwordReset = enabled
+}
+
+// SetLoginLinks offers to log in with an emailed link on the cart page.
+func (h *CartHandler) SetLoginLinks(enabled bool) {
+	h.loginLinks = enabled
 }
 
 // Helper functions for input validation and sanitization
@@ -346,10 +1460,6 @@ func sanitizeProductName(name string) string {
 	return sanitized
 }
 
-func isValidProduct(name string) bool {
-	return name == "shoe" || name == "purse" || name == "bag" || name == "watch"
-}
-
 func (h *CartHandler) GetRepo() *repo.Repository {
 	return h.repo
 }
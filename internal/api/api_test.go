@@ -6,11 +6,16 @@ import (
 	"interview/internal/api"
 	"interview/internal/cart"
 	"interview/internal/config"
+	"interview/internal/repo"
+	"interview/internal/seed"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/gin-contrib/sessions"
 	gormsessions "github.com/gin-contrib/sessions/gorm"
@@ -30,7 +35,7 @@ func setupTestDB(t *testing.T) *gorm.DB {
 	require.NoError(t, err)
 
 	// Run migrations
-	err = db.AutoMigrate(&cart.Cart{}, &cart.CartItem{})
+	err = repo.Migrate(db)
 	require.NoError(t, err)
 
 	return db
@@ -53,11 +58,35 @@ func setupTestRouter(_ *testing.T, handler *api.CartHandler, db *gorm.DB) *gin.E
 
 	// Initialize the session middleware
 	router.Use(sessions.Sessions("test_session", store))
+	router.Use(handler.AssignExperiments)
+	router.Use(handler.BlockDuringMaintenance)
+	router.Use(handler.BlockWhileDatabaseDown)
+	router.Use(handler.TrackPageViews)
+	router.Use(handler.TrackDevices)
+	router.Use(handler.GuardImpersonation)
 
 	// Add routes
 	router.GET("/", handler.ShowCart)
+	router.GET("/products", handler.ShowProducts)
+	router.GET("/products/:id", handler.ShowProduct)
+	router.GET("/orders/:id", handler.ShowOrder)
+	router.POST("/products/:id/notify", handler.SubscribeToStock)
 	router.POST("/add-item", handler.AddItem)
+	router.POST("/add-bundle", handler.AddBundle)
 	router.POST("/remove-item", handler.RemoveItem)
+	router.POST("/cart/items/reorder", handler.ReorderItems)
+	router.POST("/cart/items/group", handler.GroupItems)
+	router.POST("/restore-item", handler.RestoreItem)
+	router.POST("/undo-change", handler.UndoChange)
+	router.POST("/notifications/read", handler.MarkNotificationsRead)
+	router.POST("/subscribe-item", handler.SubscribeItem)
+	router.GET("/subscriptions", handler.ShowSubscriptions)
+	router.POST("/subscriptions/:id/:action", handler.UpdateSubscription)
+	router.POST("/carts/new", handler.CreateCart)
+	router.POST("/carts/switch", handler.SwitchCart)
+	router.POST("/carts/rename", handler.RenameCart)
+	router.POST("/carts/delete", handler.DeleteCart)
+	router.POST("/stop-impersonating", handler.StopImpersonating)
 
 	return router
 }
@@ -67,6 +96,7 @@ func setupTestHandler(_ *testing.T, db *gorm.DB) *api.CartHandler {
 	testConfig := config.Config{
 		SessionSecret: "test_secret",
 		SessionName:   "test_session",
+		SessionMaxAge: time.Hour,
 	}
 
 	handler := api.NewCartHandler(db, templateFS, testConfig, "testdata/templates/*.html")
@@ -85,6 +115,7 @@ type testSetup struct {
 func setupTest(t *testing.T) *testSetup {
 	t.Helper()
 	db := setupTestDB(t)
+	require.NoError(t, seed.Products(repo.NewRepository(db)))
 	handler := setupTestHandler(t, db)
 	router := setupTestRouter(t, handler, db)
 	return &testSetup{
@@ -94,14 +125,15 @@ func setupTest(t *testing.T) *testSetup {
 	}
 }
 
-// clearDatabase cleans up the test database
+// clearDatabase cleans up the test database, leaving only the sample products
 func (ts *testSetup) clearDatabase(t *testing.T) {
 	t.Helper()
-	tables := []string{"cart_items", "carts", "sessions"}
+	tables := []string{"notifications", "audit_log", "job_locks", "jobs", "inventory_events", "analytics_events", "experiment_conversions", "referral_rewards", "webhook_deliveries", "webhook_endpoints", "cart_reminders", "stock_subscriptions", "item_subscriptions", "downloads", "item_allocations", "warehouse_stock", "warehouses", "refund_lines", "refunds", "order_history", "orders", "vat_evidence", "checkout_sessions", "payments", "cart_changes", "cart_discounts", "cart_items", "carts", "addresses", "referrals", "recovery_codes", "password_reset_tokens", "session_devices", "users", "price_overrides", "price_list_prices", "price_lists", "gift_card_redemptions", "gift_cards", "price_tiers", "bundle_components", "product_associations", "products", "sessions"}
 	for _, table := range tables {
 		err := ts.db.Exec("DELETE FROM " + table).Error
 		require.NoError(t, err)
 	}
+	require.NoError(t, seed.Products(repo.NewRepository(ts.db)))
 }
 
 // createSession creates a new session and returns the session cookie
@@ -122,7 +154,8 @@ func (ts *testSetup) createSession(t *testing.T) *http.Cookie {
 	return sessionCookie
 }
 
-// makeRequest performs an HTTP request with session cookie
+// makeRequest performs an HTTP request with session cookie, which follows the session like a browser
+// when the response replaces it
 func (ts *testSetup) makeRequest(t *testing.T, method, path string, formData url.Values, cookie *http.Cookie) *httptest.ResponseRecorder {
 	t.Helper()
 	w := httptest.NewRecorder()
@@ -134,6 +167,13 @@ func (ts *testSetup) makeRequest(t *testing.T, method, path string, formData url
 		req.AddCookie(cookie)
 	}
 	ts.router.ServeHTTP(w, req)
+	if cookie != nil {
+		for _, set := range w.Result().Cookies() {
+			if set.Name == cookie.Name && set.MaxAge >= 0 {
+				cookie.Value = set.Value
+			}
+		}
+	}
 	return w
 }
 
@@ -155,7 +195,7 @@ func TestShowCart(t *testing.T) {
 		{
 			name: "Existing Session With Items",
 			setupData: func(t *testing.T, h *api.CartHandler) {
-				cart, err := h.GetRepo().GetOrCreateCart("test-session-id")
+				cart, err := h.GetRepo().GetOrCreateCart("test-session-id", cart.DefaultName)
 				require.NoError(t, err)
 				err = h.GetRepo().AddCartItem(cart.ID, "shoe", 1, 10.0)
 				require.NoError(t, err)
@@ -222,7 +262,7 @@ func TestAddItem(t *testing.T) {
 				"product":  []string{"invalid_product"},
 				"quantity": []string{"1"},
 			},
-			expectedStatus: http.StatusFound,
+			expectedStatus: http.StatusUnprocessableEntity,
 			checkResult: func(t *testing.T, h *api.CartHandler) {
 				assertNoItemsInCarts(t, h)
 			},
@@ -233,7 +273,7 @@ func TestAddItem(t *testing.T) {
 				"product":  []string{"shoe"},
 				"quantity": []string{"-1"},
 			},
-			expectedStatus: http.StatusFound,
+			expectedStatus: http.StatusUnprocessableEntity,
 			checkResult: func(t *testing.T, h *api.CartHandler) {
 				assertNoItemsInCarts(t, h)
 			},
@@ -255,6 +295,60 @@ func TestAddItem(t *testing.T) {
 	}
 }
 
+func TestAddItemFieldErrors(t *testing.T) {
+	ts := setupTest(t)
+
+	tests := []struct {
+		name      string
+		formData  url.Values
+		contains  []string
+		notExpect []string
+	}{
+		{
+			name:     "Invalid Quantity Keeps The Product",
+			formData: url.Values{"product": {"bag"}, "quantity": {"zero"}},
+			contains: []string{
+				`<option value="bag" selected>`,
+				`value="zero"`,
+				`aria-invalid="true" aria-describedby="quantity-error"`,
+				`<p id="quantity-error" class="field-error">Quantity must be a valid number greater than 0</p>`,
+			},
+			notExpect: []string{`<option value="shoe" selected>`, `id="product-error"`},
+		},
+		{
+			name:     "Every Invalid Field",
+			formData: url.Values{"product": {"hat"}},
+			contains: []string{
+				`<p id="product-error" class="field-error">Invalid product selected</p>`,
+				`<p id="quantity-error" class="field-error">Please enter a quantity</p>`,
+				"Please correct the highlighted fields",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts.clearDatabase(t)
+			cookie := ts.createSession(t)
+
+			w := ts.makeRequest(t, http.MethodPost, "/add-item", tt.formData, cookie)
+			require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+			for _, s := range tt.contains {
+				assert.Contains(t, w.Body.String(), s)
+			}
+			for _, s := range tt.notExpect {
+				assert.NotContains(t, w.Body.String(), s)
+			}
+			assertNoItemsInCarts(t, ts.handler)
+
+			// The errors are only shown in the response to the submitted form
+			w = ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.NotContains(t, w.Body.String(), "field-error")
+		})
+	}
+}
+
 func TestRemoveItem(t *testing.T) {
 	ts := setupTest(t)
 
@@ -281,7 +375,7 @@ func TestRemoveItem(t *testing.T) {
 				require.NoError(t, err)
 
 				// Refresh cart to get the item ID
-				cart, err = h.GetRepo().GetExistingCart(cart.SessionID)
+				cart, err = h.GetRepo().GetExistingCart(cart.SessionID, cart.Name)
 				require.NoError(t, err)
 				require.Len(t, cart.CartItems, 1)
 				return cart.CartItems[0].ID
@@ -318,6 +412,52 @@ func TestRemoveItem(t *testing.T) {
 	}
 }
 
+func TestUndoRemoveItem(t *testing.T) {
+	ts := setupTest(t)
+	ts.clearDatabase(t)
+	cookie := ts.createSession(t)
+
+	carts, err := ts.handler.GetRepo().GetAllCarts()
+	require.NoError(t, err)
+	require.Len(t, carts, 1)
+	require.NoError(t, ts.handler.GetRepo().AddCartItem(carts[0].ID, "shoe", 2, 10.0))
+	cart, err := ts.handler.GetRepo().GetExistingCart(carts[0].SessionID, carts[0].Name)
+	require.NoError(t, err)
+	itemID := fmt.Sprint(cart.CartItems[0].ID)
+
+	w := ts.makeRequest(t, http.MethodPost, "/remove-item", url.Values{"cart_item_id": {itemID}}, cookie)
+	require.Equal(t, http.StatusFound, w.Code)
+
+	t.Run("Offers Undo Once", func(t *testing.T) {
+		w := ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Removed 2 × shoe")
+		assert.Contains(t, w.Body.String(), `action="/restore-item"`)
+
+		w = ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.NotContains(t, w.Body.String(), "Removed 2 × shoe")
+	})
+
+	t.Run("Restores Item", func(t *testing.T) {
+		w := ts.makeRequest(t, http.MethodPost, "/restore-item", url.Values{"cart_item_id": {itemID}}, cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+
+		cart, err := ts.handler.GetRepo().GetExistingCart(carts[0].SessionID, carts[0].Name)
+		require.NoError(t, err)
+		require.Len(t, cart.CartItems, 1)
+		assert.Equal(t, 2, cart.CartItems[0].Quantity)
+		assert.Equal(t, 20.0, cart.Total)
+	})
+
+	t.Run("Already Restored", func(t *testing.T) {
+		w := ts.makeRequest(t, http.MethodPost, "/restore-item", url.Values{"cart_item_id": {itemID}}, cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+
+		w = ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.Contains(t, w.Body.String(), "Item not found")
+	})
+}
+
 // assertNoItemsInCarts verifies that no carts have any items
 func assertNoItemsInCarts(t *testing.T, h *api.CartHandler) {
 	t.Helper()
@@ -327,3 +467,204 @@ func assertNoItemsInCarts(t *testing.T, h *api.CartHandler) {
 		assert.Len(t, cart.CartItems, 0, "Cart should not have any items")
 	}
 }
+
+func TestLocale(t *testing.T) {
+	ts := setupTest(t)
+	ts.clearDatabase(t)
+
+	t.Run("Accept-Language", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Language", "de-DE,de;q=0.9")
+		ts.router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `<html lang="de">`)
+		assert.Contains(t, w.Body.String(), "In den Warenkorb")
+	})
+
+	t.Run("Translated Field Error", func(t *testing.T) {
+		cookie := ts.createSession(t)
+		w := ts.makeRequest(t, http.MethodGet, "/?lang=de", nil, cookie)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		w = ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"invalid"}, "quantity": {"1"}}, cookie)
+		assert.Contains(t, w.Body.String(), "Ungültiges Produkt ausgewählt")
+		assert.Contains(t, w.Body.String(), "Bitte korrigieren Sie die markierten Felder")
+	})
+
+	t.Run("Default English", func(t *testing.T) {
+		w := ts.makeRequest(t, http.MethodGet, "/", nil, nil)
+		assert.Contains(t, w.Body.String(), "Add Item to Cart")
+	})
+}
+
+func TestPriceRefresh(t *testing.T) {
+	ts := setupTest(t)
+	ts.clearDatabase(t)
+	ts.handler.SetPriceRefreshAfter(time.Hour)
+
+	cookie := ts.createSession(t)
+	ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"shoe"}, "quantity": {"2"}}, cookie)
+	ts.handler.SetProductPrices(map[string]float64{"shoe": 12, "purse": 20, "bag": 30, "watch": 40})
+
+	t.Run("Recent Prices Are Kept", func(t *testing.T) {
+		w := ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.NotContains(t, w.Body.String(), "Prices in your cart were updated")
+		assert.Contains(t, w.Body.String(), "20.00")
+	})
+
+	t.Run("Old Prices Are Refreshed", func(t *testing.T) {
+		require.NoError(t, ts.db.Model(&cart.Cart{}).Where("1 = 1").
+			UpdateColumn("created_at", time.Now().Add(-2*time.Hour)).Error)
+
+		w := ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.Contains(t, w.Body.String(), "Prices in your cart were updated")
+		assert.Contains(t, w.Body.String(), "24.00")
+
+		// The refresh is recorded, so the notice isn't shown again
+		w = ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.NotContains(t, w.Body.String(), "Prices in your cart were updated")
+	})
+}
+
+func TestShowCartETag(t *testing.T) {
+	ts := setupTest(t)
+	ts.clearDatabase(t)
+	cookie := ts.createSession(t)
+
+	getPath := func(path, ifNoneMatch string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.AddCookie(cookie)
+		req.Header.Set("If-None-Match", ifNoneMatch)
+		ts.router.ServeHTTP(w, req)
+		return w
+	}
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		return getPath("/", ifNoneMatch)
+	}
+
+	w := get("")
+	require.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	require.True(t, strings.HasPrefix(etag, `W/"`), etag)
+
+	t.Run("Unchanged Cart", func(t *testing.T) {
+		w := get(etag)
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Body.String())
+	})
+
+	t.Run("Changed Cart", func(t *testing.T) {
+		ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"shoe"}, "quantity": {"1"}}, cookie)
+		w := get(etag)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotEqual(t, etag, w.Header().Get("ETag"))
+	})
+
+	t.Run("Other Language", func(t *testing.T) {
+		current := get("").Header().Get("ETag")
+		require.Equal(t, http.StatusNotModified, get(etag+", "+current).Code)
+		assert.Equal(t, http.StatusOK, getPath("/?lang=de", current).Code)
+	})
+
+	t.Run("Stock Held For The Cart", func(t *testing.T) {
+		current := get("").Header().Get("ETag")
+		// Holding stock doesn't change the cart, but the page counts down the time left
+		require.NoError(t, ts.db.Model(&cart.Cart{}).Where("name = ?", cart.DefaultName).
+			UpdateColumn("reserved_until", time.Now().Add(10*time.Minute)).Error)
+		assert.Equal(t, http.StatusOK, get(current).Code)
+	})
+}
+
+func TestNamedCarts(t *testing.T) {
+	ts := setupTest(t)
+	ts.clearDatabase(t)
+	cookie := ts.createSession(t)
+	cartRepo := ts.handler.GetRepo()
+
+	itemsByCart := func(t *testing.T) map[string]int {
+		t.Helper()
+		carts, err := cartRepo.GetAllCarts()
+		require.NoError(t, err)
+		items := make(map[string]int)
+		for _, c := range carts {
+			items[c.Name] = len(c.CartItems)
+		}
+		return items
+	}
+
+	w := ts.makeRequest(t, http.MethodPost, "/carts/new", url.Values{"name": {" work "}}, cookie)
+	require.Equal(t, http.StatusFound, w.Code)
+	ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"shoe"}, "quantity": {"1"}}, cookie)
+	assert.Equal(t, map[string]int{cart.DefaultName: 0, "work": 1}, itemsByCart(t))
+
+	w = ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+	assert.Contains(t, w.Body.String(), "<strong>work</strong>")
+
+	t.Run("Duplicate Name", func(t *testing.T) {
+		ts.makeRequest(t, http.MethodPost, "/carts/new", url.Values{"name": {"work"}}, cookie)
+		w := ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.Contains(t, w.Body.String(), "You already have a cart with this name")
+	})
+
+	t.Run("Switch And Rename", func(t *testing.T) {
+		ts.makeRequest(t, http.MethodPost, "/carts/switch", url.Values{"name": {cart.DefaultName}}, cookie)
+		ts.makeRequest(t, http.MethodPost, "/carts/rename", url.Values{"name": {"personal"}}, cookie)
+		ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"bag"}, "quantity": {"2"}}, cookie)
+		assert.Equal(t, map[string]int{"personal": 1, "work": 1}, itemsByCart(t))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		ts.makeRequest(t, http.MethodPost, "/carts/delete", nil, cookie)
+		w := ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.Contains(t, w.Body.String(), "<strong>default</strong>")
+		assert.Equal(t, map[string]int{cart.DefaultName: 0, "work": 1}, itemsByCart(t))
+	})
+}
+
+func TestConcurrentAddItem(t *testing.T) {
+	ts := setupTest(t)
+	ts.clearDatabase(t)
+	cookie := ts.createSession(t)
+
+	// Track how many requests read the cart at the same time, slowing reads down to widen the race
+	var inflight, maxInflight int32
+	require.NoError(t, ts.db.Callback().Query().Before("gorm:query").Register("test:track_cart_reads", func(tx *gorm.DB) {
+		if tx.Statement.Table != "carts" {
+			return
+		}
+		n := atomic.AddInt32(&inflight, 1)
+		for {
+			current := atomic.LoadInt32(&maxInflight)
+			if n <= current || atomic.CompareAndSwapInt32(&maxInflight, current, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&inflight, -1)
+	}))
+	defer func() {
+		require.NoError(t, ts.db.Callback().Query().Remove("test:track_cart_reads"))
+	}()
+
+	const clicks = 10
+	var wg sync.WaitGroup
+	for i := 0; i < clicks; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"shoe"}, "quantity": {"1"}}, cookie)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), maxInflight, "cart mutations of one session must not overlap")
+
+	var userCart cart.Cart
+	require.NoError(t, ts.db.Preload("CartItems").First(&userCart).Error)
+	require.Len(t, userCart.CartItems, 1)
+	assert.Equal(t, clicks, userCart.CartItems[0].Quantity)
+	assert.Equal(t, float64(clicks)*10.0, userCart.Total)
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"html/template"
+	"interview/internal/botcheck"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetBotCheck adds the honeypot and timing fields of checker to the forms adding items to the cart and
+// rejects the submissions it takes for a bot's, nil to accept every submission.
+func (h *CartHandler) SetBotCheck(checker *botcheck.Checker) {
+	h.botCheck = checker
+}
+
+// botFields returns the inputs of the bot check for the forms adding items and the script they need,
+// empty without a bot check
+func (h *CartHandler) botFields() (fields, script template.HTML) {
+	if h.botCheck == nil {
+		return "", ""
+	}
+	return h.botCheck.Fields(), h.botCheck.Script()
+}
+
+// isBot tells whether the bot check takes the submitted form for a bot's
+func (h *CartHandler) isBot(c *gin.Context) bool {
+	if h.botCheck == nil {
+		return false
+	}
+	return h.botCheck.Check(c.Request.Context(), botcheck.Submission{
+		Honeypot: c.PostForm(botcheck.HoneypotField),
+		Token:    c.PostForm(botcheck.TokenField),
+		Captcha:  c.PostForm(botcheck.CaptchaField),
+		RemoteIP: c.ClientIP(),
+	}) != nil
+}
@@ -0,0 +1,64 @@
+package api_test
+
+import (
+	"interview/internal/botcheck"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBotCheck(t *testing.T) {
+	ts := setupTest(t)
+	now := time.Now()
+	checker := botcheck.New([]byte("secret"), 2*time.Second)
+	checker.SetClock(func() time.Time { return now })
+	ts.handler.SetBotCheck(checker)
+	defer ts.handler.SetBotCheck(nil)
+
+	t.Run("Forms Carry The Fields", func(t *testing.T) {
+		ts.clearDatabase(t)
+		w := ts.makeRequest(t, http.MethodGet, "/", nil, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `name="website"`)
+		assert.Contains(t, w.Body.String(), `name="form_token" value="`+checker.Token()+`"`)
+	})
+
+	tests := []struct {
+		name     string
+		form     url.Values
+		elapsed  time.Duration
+		wantItem bool
+	}{
+		{"Person", url.Values{"form_token": {checker.Token()}}, 5 * time.Second, true},
+		{"Filled Honeypot", url.Values{"form_token": {checker.Token()}, "website": {"https://spam.example"}}, 5 * time.Second, false},
+		{"Too Fast", url.Values{"form_token": {checker.Token()}}, 500 * time.Millisecond, false},
+		{"Without Loading The Form", url.Values{}, 5 * time.Second, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts.clearDatabase(t)
+			cookie := ts.createSession(t)
+			checker.SetClock(func() time.Time { return now.Add(tt.elapsed) })
+			defer checker.SetClock(func() time.Time { return now })
+
+			tt.form.Set("product", "shoe")
+			tt.form.Set("quantity", "1")
+			w := ts.makeRequest(t, http.MethodPost, "/add-item", tt.form, cookie)
+			require.Equal(t, http.StatusFound, w.Code)
+			if tt.wantItem {
+				carts, err := ts.handler.GetRepo().GetAllCarts()
+				require.NoError(t, err)
+				require.Len(t, carts, 1)
+				assert.Len(t, carts[0].CartItems, 1)
+				return
+			}
+			assertNoItemsInCarts(t, ts.handler)
+			w = ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+			assert.Contains(t, w.Body.String(), "We couldn&#39;t tell you apart from a bot, please try again")
+		})
+	}
+}
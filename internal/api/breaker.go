@@ -0,0 +1,35 @@
+package api
+
+import (
+	"interview/internal/repo"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// unavailableMessage answers requests while the database is down
+const unavailableMessage = "The shop is temporarily unavailable, please try again in a moment"
+
+// SetBreaker sets the circuit breaker of the database, enabling BlockWhileDatabaseDown.
+func (h *CartHandler) SetBreaker(breaker *repo.Breaker) {
+	h.breaker = breaker
+}
+
+// BlockWhileDatabaseDown is middleware answering requests with 503 Service Unavailable while the circuit
+// breaker of the database is open, asking clients to retry once it lets queries through again. Static
+// files, media and the metrics don't need the database and are still served.
+func (h *CartHandler) BlockWhileDatabaseDown(c *gin.Context) {
+	path := c.Request.URL.Path
+	if h.breaker == nil || path == "/admin/metrics" || strings.HasPrefix(path, "/static/") || strings.HasPrefix(path, "/media/") {
+		c.Next()
+		return
+	}
+	wait := h.breaker.RetryAfter()
+	if wait <= 0 {
+		c.Next()
+		return
+	}
+	h.abortUnavailable(c, strconv.Itoa(int(math.Ceil(wait.Seconds()))), unavailableMessage)
+}
@@ -0,0 +1,57 @@
+package api_test
+
+import (
+	"context"
+	"interview/internal/auth"
+	"interview/internal/repo"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatabaseDown(t *testing.T) {
+	ts := setupTest(t)
+	now := time.Now()
+	breaker := repo.NewBreaker(1, time.Minute)
+	breaker.SetClock(func() time.Time { return now })
+	require.NoError(t, ts.db.Use(breaker))
+	ts.handler.SetBreaker(breaker)
+
+	keys, err := auth.ParseKeySet("test:test_jwt_secret")
+	require.NoError(t, err)
+	apiRouter := gin.New()
+	apiRouter.Use(ts.handler.BlockWhileDatabaseDown)
+	ts.handler.RegisterAPIRoutes(apiRouter, auth.NewIssuer(keys))
+
+	// A query the database doesn't answer in time opens the breaker
+	expired, cancel := context.WithDeadline(context.Background(), now.Add(-time.Second))
+	defer cancel()
+	var count int64
+	require.ErrorIs(t, ts.db.WithContext(expired).Table("carts").Count(&count).Error, context.DeadlineExceeded)
+
+	t.Run("Answers Pages With 503", func(t *testing.T) {
+		w := ts.makeRequest(t, http.MethodGet, "/", nil, nil)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, "60", w.Header().Get("Retry-After"))
+		assert.Contains(t, w.Body.String(), "The shop is temporarily unavailable")
+	})
+
+	t.Run("Answers API With JSON Error", func(t *testing.T) {
+		w := doJSON(t, apiRouter, http.MethodPost, "/api/v1/auth/token", "", nil)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, "60", w.Header().Get("Retry-After"))
+		assert.JSONEq(t, `{"type":"about:blank","title":"Service Unavailable","status":503,
+			"detail":"The shop is temporarily unavailable, please try again in a moment","instance":"/api/v1/auth/token"}`, w.Body.String())
+	})
+
+	t.Run("Serves Requests Again After Cooldown", func(t *testing.T) {
+		now = now.Add(time.Minute)
+		w := ts.makeRequest(t, http.MethodGet, "/", nil, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Retry-After"))
+	})
+}
@@ -0,0 +1,204 @@
+package api
+
+import (
+	"errors"
+	"interview/internal/analytics"
+	"interview/internal/cart"
+	"interview/internal/events"
+	"interview/internal/experiment"
+	productpkg "interview/internal/product"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type (
+	// BundleRequest is the JSON body accepted by PUT /admin/products/:id/components. It makes the
+	// product a bundle of the components, an empty list makes it a regular product again.
+	BundleRequest struct {
+		Components []BundleComponentRequest `json:"components"`
+	}
+
+	// BundleComponentRequest puts Quantity units of the product ProductID into each unit of the bundle.
+	BundleComponentRequest struct {
+		ProductID uint `json:"product_id"`
+		Quantity  int  `json:"quantity"`
+	}
+
+	// BundleResponse lists the components of a bundle ordered by product name, none for regular
+	// products. Price is the price of the bundle, which its components share when it is added to a cart.
+	BundleResponse struct {
+		ProductID  uint                      `json:"product_id"`
+		Price      float64                   `json:"price"`
+		Components []BundleComponentResponse `json:"components"`
+	}
+
+	// BundleComponentResponse is the JSON representation of a component of a bundle.
+	BundleComponentResponse struct {
+		ProductID uint   `json:"product_id"`
+		Product   string `json:"product"`
+		Quantity  int    `json:"quantity"`
+	}
+
+	// AddBundleRequest is the JSON body accepted by POST /api/v1/cart/bundles.
+	AddBundleRequest struct {
+		BundleID uint `json:"bundle_id"`
+		Quantity int  `json:"quantity"`
+	}
+
+	// BundleComponentView represents a component of the bundle shown on the product page.
+	BundleComponentView struct {
+		Product  string
+		Quantity int
+	}
+)
+
+// ListBundleComponents returns the components of a product, none when it isn't a bundle.
+func (h *AdminHandler) ListBundleComponents(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid product ID")
+		return
+	}
+	h.respondWithBundle(c, uint(id))
+}
+
+// UpdateBundleComponents makes a product a bundle of other products, e.g. a shoe and two watches. Bundles
+// already in carts keep the items they were added with.
+func (h *AdminHandler) UpdateBundleComponents(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid product ID")
+		return
+	}
+	var req BundleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	components := make([]productpkg.BundleComponent, len(req.Components))
+	for i, component := range req.Components {
+		components[i] = productpkg.BundleComponent{ProductID: component.ProductID, Quantity: component.Quantity}
+	}
+
+	if err := h.repoFor(c).SetBundleComponents(uint(id), components); errors.Is(err, productpkg.ErrInvalidBundle) {
+		respondWithInvalidField(c, "components", "need distinct existing products that aren't bundles and quantities of at least 1")
+		return
+	} else if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondWithProblem(c, http.StatusNotFound, "product not found")
+		return
+	} else if err != nil {
+		log.Printf("Failed to set bundle components: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to set bundle components")
+		return
+	}
+	h.respondWithBundle(c, uint(id))
+}
+
+// respondWithBundle responds with the components of the product, see BundleResponse.
+func (h *AdminHandler) respondWithBundle(c *gin.Context, id uint) {
+	r := h.repoFor(c)
+	parts, err := r.ListBundleComponents(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondWithProblem(c, http.StatusNotFound, "product not found")
+		return
+	} else if err != nil {
+		log.Printf("Failed to list bundle components: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to list bundle components")
+		return
+	}
+	p, err := r.GetProduct(id)
+	if err != nil {
+		log.Printf("Failed to get bundle: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to list bundle components")
+		return
+	}
+
+	resp := BundleResponse{ProductID: id, Price: p.Price, Components: make([]BundleComponentResponse, len(parts))}
+	for i, part := range parts {
+		resp.Components[i] = BundleComponentResponse{ProductID: part.Product.ID, Product: part.Product.Name, Quantity: part.Quantity}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// AddBundle adds a bundle to the user's cart, expanded to its components.
+func (h *CartHandler) AddBundle(c *gin.Context) {
+	session := sessions.Default(c)
+	if h.isBot(c) {
+		h.redirectWithFlash(c, session, "We couldn't tell you apart from a bot, please try again")
+		return
+	}
+	bundleID, err := strconv.ParseUint(c.PostForm("bundle"), 10, 32)
+	if err != nil {
+		h.redirectWithFlash(c, session, "Invalid product selected")
+		return
+	}
+	quantity, err := strconv.Atoi(c.PostForm("quantity"))
+	if err != nil || quantity < 1 {
+		h.redirectWithFlash(c, session, "Quantity must be a valid number greater than 0")
+		return
+	}
+	if quantity > cart.MaxQuantity {
+		h.redirectWithFlash(c, session, "You can add at most 999 of a product")
+		return
+	}
+
+	sessionID, ok := session.Get("session_id").(string)
+	if !ok {
+		h.redirectWithFlash(c, session, "Invalid session")
+		return
+	}
+
+	cartName := currentCartName(session)
+	bundle, err := h.carts.AddBundle(c.Request.Context(), sessionID, sessionUserID(session), cartName, uint(bundleID), quantity)
+	if err != nil {
+		h.redirectWithFlash(c, session, errorMessage(err, "Failed to add item to cart"))
+		return
+	}
+
+	h.publishCartEvent(c, events.TypeItemAdded, sessionID, cartName, bundle.Name, quantity)
+	h.recordConversion(c, sessionID, experiment.EventAddToCart)
+	h.track(c, analytics.Event{Type: analytics.TypeAddToCart, Product: bundle.Name, Quantity: quantity})
+	c.Redirect(http.StatusFound, "/")
+}
+
+// APIAddBundle adds a bundle to the cart of the authenticated session, expanded to its components.
+func (h *CartHandler) APIAddBundle(c *gin.Context) {
+	var req AddBundleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	sessionID, cartName := c.GetString(apiSessionKey), apiCartName(c)
+	bundle, err := h.carts.AddBundle(c.Request.Context(), sessionID, nil, cartName, req.BundleID, req.Quantity)
+	if err != nil {
+		respondWithError(c, err, "Failed to add item to cart")
+		return
+	}
+
+	h.publishCartEvent(c, events.TypeItemAdded, sessionID, cartName, bundle.Name, req.Quantity)
+	h.tracker.Track(analytics.Event{
+		Type: analytics.TypeAddToCart, SessionID: sessionID, Product: bundle.Name, Quantity: req.Quantity,
+	})
+	h.respondWithCart(c, sessionID, cartName, http.StatusCreated)
+}
+
+// bundleComponentViews returns the components of the product for the product page, none when it isn't
+// a bundle.
+func (h *CartHandler) bundleComponentViews(c *gin.Context, id uint) []BundleComponentView {
+	parts, err := h.repoFor(c).ListBundleComponents(id)
+	if err != nil {
+		log.Printf("Failed to list bundle components: %v", err)
+		return nil
+	}
+	views := make([]BundleComponentView, len(parts))
+	for i, part := range parts {
+		views[i] = BundleComponentView{Product: part.Product.Name, Quantity: part.Quantity}
+	}
+	return views
+}
@@ -0,0 +1,105 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"interview/internal/api"
+	"interview/internal/repo"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBundles(t *testing.T) {
+	ts := setupTest(t)
+	ts.clearDatabase(t)
+	cartRepo := repo.NewRepository(ts.db)
+	shoe, err := cartRepo.UpsertProduct("shoe", 10.0)
+	require.NoError(t, err)
+	watch, err := cartRepo.UpsertProduct("watch", 30.0)
+	require.NoError(t, err)
+	kit, err := cartRepo.UpsertProduct("kit", 36.0)
+	require.NoError(t, err)
+
+	admin := gin.New()
+	api.NewAdminHandler(ts.db, nil, time.Hour).RegisterRoutes(admin, gin.Accounts{"admin": "secret"})
+	request := func(t *testing.T, method, path string, body interface{}) *httptest.ResponseRecorder {
+		t.Helper()
+		var buf bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&buf).Encode(body))
+		}
+		req := httptest.NewRequest(method, path, &buf)
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		admin.ServeHTTP(w, req)
+		return w
+	}
+	path := fmt.Sprintf("/admin/products/%d/components", kit.ID)
+
+	t.Run("Invalid Components Are Rejected", func(t *testing.T) {
+		w := request(t, http.MethodPut, path, api.BundleRequest{Components: []api.BundleComponentRequest{{ProductID: kit.ID, Quantity: 1}}})
+		assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), `"field":"components"`)
+		w = request(t, http.MethodPut, "/admin/products/9999/components", api.BundleRequest{})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Components Are Replaced", func(t *testing.T) {
+		w := request(t, http.MethodPut, path, api.BundleRequest{Components: []api.BundleComponentRequest{
+			{ProductID: watch.ID, Quantity: 1}, {ProductID: shoe.ID, Quantity: 2},
+		}})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		w = request(t, http.MethodGet, path, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp api.BundleResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, 36.0, resp.Price)
+		assert.Equal(t, []api.BundleComponentResponse{
+			{ProductID: shoe.ID, Product: "shoe", Quantity: 2}, {ProductID: watch.ID, Product: "watch", Quantity: 1},
+		}, resp.Components)
+	})
+
+	t.Run("API Adds Bundles As Their Components", func(t *testing.T) {
+		router := setupAPIRouter(t, ts)
+		pair := issueToken(t, router)
+		w := doJSON(t, router, http.MethodPost, "/api/v1/cart/bundles", pair.AccessToken, api.AddBundleRequest{BundleID: kit.ID, Quantity: 1})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var cart api.CartResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &cart))
+		require.Len(t, cart.Items, 2)
+		assert.Equal(t, "kit", cart.Items[0].Bundle)
+		assert.Equal(t, cart.Items[0].BundleGroup, cart.Items[1].BundleGroup)
+		assert.InDelta(t, 36.0, cart.Subtotal, 1e-9)
+
+		w = doJSON(t, router, http.MethodDelete, fmt.Sprintf("/api/v1/cart/items/%d", cart.Items[1].ID), pair.AccessToken, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &cart))
+		assert.Empty(t, cart.Items, "the whole bundle is removed")
+
+		w = doJSON(t, router, http.MethodPost, "/api/v1/cart/bundles", pair.AccessToken, api.AddBundleRequest{BundleID: shoe.ID, Quantity: 1})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Product Page Adds The Bundle", func(t *testing.T) {
+		cookie := ts.createSession(t)
+		body := ts.makeRequest(t, http.MethodGet, fmt.Sprintf("/products/%d", kit.ID), nil, cookie).Body.String()
+		assert.Contains(t, body, "Bundle of:")
+		assert.Contains(t, body, `action="/add-bundle"`)
+
+		w := ts.makeRequest(t, http.MethodPost, "/add-bundle", url.Values{"bundle": {fmt.Sprint(kit.ID)}, "quantity": {"1"}}, cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+		body = ts.makeRequest(t, http.MethodGet, "/", nil, cookie).Body.String()
+		assert.Contains(t, body, "Part of bundle kit")
+		assert.Contains(t, body, "Remove bundle kit")
+	})
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"interview/internal/cart"
+	"interview/internal/repo"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportBatchSize is how many carts the export loads from the database at a time
+const exportBatchSize = 500
+
+// CartExport is the JSON representation of a cart in the export.
+type CartExport struct {
+	CartResponse
+	SessionID string    `json:"session_id"`
+	UserID    *uint     `json:"user_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// cartExportColumns is the header of the CSV export, which has a row per item and a row without item
+// columns for empty carts
+var cartExportColumns = []string{
+	"cart_id", "session_id", "user_id", "status", "total", "credit", "created_at", "updated_at",
+	"item_id", "product", "quantity", "price",
+}
+
+// ExportCarts streams all carts matching the status, from and to query parameters with their items
+// as CSV or JSON, selected by the format parameter. from and to are RFC 3339 times or dates and bound
+// the creation time of the carts.
+func (h *AdminHandler) ExportCarts(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "json" {
+		respondWithInvalidField(c, "format", "must be csv or json")
+		return
+	}
+	var filter repo.CartFilter
+	switch filter.Status = c.Query("status"); filter.Status {
+	case "", cart.StatusOpen, cart.StatusClosed:
+	default:
+		respondWithInvalidField(c, "status", "must be open or closed")
+		return
+	}
+	var err error
+	if filter.From, err = parseExportTime(c.Query("from")); err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid from time")
+		return
+	}
+	if filter.To, err = parseExportTime(c.Query("to")); err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid to time")
+		return
+	}
+
+	filename := fmt.Sprintf("carts-%s.%s", time.Now().UTC().Format("20060102-150405"), format)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	if format == "csv" {
+		err = h.exportCSV(c, filter)
+	} else {
+		err = h.exportJSON(c, filter)
+	}
+	// The status has been sent with the first batch, so a failure can only cut the export short
+	if err != nil {
+		log.Printf("Cart export failed: %v", err)
+	}
+}
+
+func (h *AdminHandler) exportCSV(c *gin.Context, filter repo.CartFilter) error {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(http.StatusOK)
+	w := csv.NewWriter(c.Writer)
+	if err := w.Write(cartExportColumns); err != nil {
+		return err
+	}
+
+	err := h.repoFor(c).EachCartBatch(filter, exportBatchSize, func(carts []cart.Cart) error {
+		for _, userCart := range carts {
+			userID := ""
+			if userCart.UserID != nil {
+				userID = strconv.FormatUint(uint64(*userCart.UserID), 10)
+			}
+			row := []string{
+				strconv.FormatUint(uint64(userCart.ID), 10),
+				userCart.SessionID,
+				userID,
+				userCart.Status,
+				strconv.FormatFloat(userCart.Total, 'f', 2, 64),
+				strconv.FormatFloat(userCart.Credit, 'f', 2, 64),
+				userCart.CreatedAt.UTC().Format(time.RFC3339),
+				userCart.UpdatedAt.UTC().Format(time.RFC3339),
+			}
+			if len(userCart.CartItems) == 0 {
+				if err := w.Write(append(row, "", "", "", "")); err != nil {
+					return err
+				}
+			}
+			for _, item := range userCart.CartItems {
+				err := w.Write(append(row[:8:8],
+					strconv.FormatUint(uint64(item.ID), 10),
+					item.ProductName,
+					strconv.Itoa(item.Quantity),
+					strconv.FormatFloat(item.Price, 'f', 2, 64),
+				))
+				if err != nil {
+					return err
+				}
+			}
+		}
+		w.Flush()
+		c.Writer.Flush()
+		return w.Error()
+	})
+	w.Flush()
+	if err != nil {
+		return err
+	}
+	return w.Error()
+}
+
+func (h *AdminHandler) exportJSON(c *gin.Context, filter repo.CartFilter) error {
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Status(http.StatusOK)
+	if _, err := c.Writer.WriteString("["); err != nil {
+		return err
+	}
+
+	first := true
+	err := h.repoFor(c).EachCartBatch(filter, exportBatchSize, func(carts []cart.Cart) error {
+		for i := range carts {
+			record, err := json.Marshal(CartExport{
+				CartResponse: newCartResponse(&carts[i]),
+				SessionID:    carts[i].SessionID,
+				UserID:       carts[i].UserID,
+				CreatedAt:    carts[i].CreatedAt,
+				UpdatedAt:    carts[i].UpdatedAt,
+			})
+			if err != nil {
+				return err
+			}
+			if !first {
+				record = append([]byte(","), record...)
+			}
+			first = false
+			if _, err := c.Writer.Write(record); err != nil {
+				return err
+			}
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	_, err = c.Writer.WriteString("]")
+	return err
+}
+
+// parseExportTime parses an RFC 3339 time or a date, returning the zero time for an empty value.
+func parseExportTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
@@ -0,0 +1,84 @@
+package api_test
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"interview/internal/api"
+	"interview/internal/cart"
+	"interview/internal/repo"
+	"interview/internal/storage"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportCarts(t *testing.T) {
+	ts := setupTest(t)
+	ts.clearDatabase(t)
+
+	cartRepo := repo.NewRepository(ts.db)
+	full, err := cartRepo.GetOrCreateCart("export-full", cart.DefaultName)
+	require.NoError(t, err)
+	require.NoError(t, cartRepo.AddCartItem(full.ID, "shoe", 2, 10.0))
+	require.NoError(t, cartRepo.AddCartItem(full.ID, "bag", 1, 30.0))
+	_, err = cartRepo.GetOrCreateCart("export-empty", cart.DefaultName)
+	require.NoError(t, err)
+
+	router := gin.New()
+	media := storage.NewLocal(t.TempDir(), "/media", []byte("test_secret"))
+	api.NewAdminHandler(ts.db, media, time.Hour).RegisterRoutes(router, gin.Accounts{"admin": "secret"})
+	export := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/admin/carts/export?"+query, nil)
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("CSV", func(t *testing.T) {
+		w := export("format=csv&status=open")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Disposition"), ".csv")
+
+		rows, err := csv.NewReader(w.Body).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, rows, 4)
+		assert.Equal(t, "cart_id", rows[0][0])
+		assert.Equal(t, []string{"export-full", "shoe", "2", "10.00"}, []string{rows[1][1], rows[1][9], rows[1][10], rows[1][11]})
+		assert.Equal(t, "bag", rows[2][9])
+		assert.Equal(t, "export-empty", rows[3][1])
+		assert.Empty(t, rows[3][9])
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		w := export("format=json&from=" + url.QueryEscape(time.Now().Add(-time.Hour).Format(time.RFC3339)))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var carts []api.CartExport
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &carts))
+		require.Len(t, carts, 2)
+		assert.Equal(t, "export-full", carts[0].SessionID)
+		assert.Len(t, carts[0].Items, 2)
+		assert.Equal(t, 50.0, carts[0].Total)
+		assert.Empty(t, carts[1].Items)
+	})
+
+	t.Run("No Matches", func(t *testing.T) {
+		w := export("format=json&status=closed")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "[]", strings.TrimSpace(w.Body.String()))
+	})
+
+	t.Run("Invalid Parameters", func(t *testing.T) {
+		for _, query := range []string{"format=xml", "status=pending", "from=yesterday", "to=2024-13-01"} {
+			assert.Equal(t, http.StatusBadRequest, export(query).Code, query)
+		}
+	})
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"interview/internal/cart"
+	"interview/internal/events"
+	"interview/internal/i18n"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// recentActivityShown is how many changes of the cart its page lists as recent activity
+const recentActivityShown = 10
+
+// CartChangeView represents an entry of the recent activity of the cart for the view layer.
+type CartChangeView struct {
+	Time        string
+	Description string
+	Undone      bool
+}
+
+// APIListCartHistory returns a page of the changes made to the cart of the authenticated session, newest
+// first, to explain how it came to be what it is.
+func (h *CartHandler) APIListCartHistory(c *gin.Context) {
+	after, limit, ok := parsePageParams(c)
+	if !ok {
+		return
+	}
+
+	userCart, err := h.repoFor(c).GetOrCreateCart(c.GetString(apiSessionKey), apiCartName(c))
+	if err != nil {
+		respondWithProblem(c, http.StatusInternalServerError, "failed to load cart")
+		return
+	}
+
+	// Fetch one extra row to know whether another page follows
+	changes, err := h.repoFor(c).ListCartChanges(userCart.ID, after, limit+1)
+	if err != nil {
+		respondWithProblem(c, http.StatusInternalServerError, "failed to load cart history")
+		return
+	}
+
+	page := CartChangePage{Changes: []CartChangeResponse{}}
+	if len(changes) > limit {
+		changes = changes[:limit]
+		page.NextCursor = strconv.FormatUint(uint64(changes[limit-1].ID), 10)
+	}
+	for _, change := range changes {
+		page.Changes = append(page.Changes, CartChangeResponse{
+			Action:   change.Action,
+			Product:  change.Product,
+			Quantity: change.Quantity,
+			Amount:   change.Amount,
+			Time:     change.CreatedAt,
+			UndoneAt: change.UndoneAt,
+		})
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// UndoChange undoes the latest change to the user's cart.
+func (h *CartHandler) UndoChange(c *gin.Context) {
+	session := sessions.Default(c)
+	sessionID, ok := session.Get("session_id").(string)
+	if !ok {
+		h.redirectWithFlash(c, session, "Invalid session")
+		return
+	}
+
+	cartName := currentCartName(session)
+	change, err := h.carts.Undo(c.Request.Context(), sessionID, cartName)
+	if err != nil {
+		h.redirectWithFlash(c, session, errorMessage(err, "Failed to undo the change"))
+		return
+	}
+
+	h.publishUndoEvent(c, sessionID, cartName, change)
+	locale := detectLocale(c, session).String()
+	session.AddFlash(i18n.T(locale, "Undone: %s", h.describeChange(*change, locale, sessionCurrency(session))), noticeFlash)
+	if err := session.Save(); err != nil {
+		log.Printf("Failed to save session: %v", err)
+	}
+	c.Redirect(http.StatusFound, "/")
+}
+
+// APIUndoChange undoes the latest change to the cart of the authenticated session.
+func (h *CartHandler) APIUndoChange(c *gin.Context) {
+	sessionID, cartName := c.GetString(apiSessionKey), apiCartName(c)
+	change, err := h.carts.Undo(c.Request.Context(), sessionID, cartName)
+	if err != nil {
+		respondWithError(c, err, "Failed to undo the change")
+		return
+	}
+
+	h.publishUndoEvent(c, sessionID, cartName, change)
+	h.respondWithCart(c, sessionID, cartName, http.StatusOK)
+}
+
+// publishUndoEvent tells subscribers about the units undoing the change put back or took out
+func (h *CartHandler) publishUndoEvent(c *gin.Context, sessionID, cartName string, change *cart.Change) {
+	eventType := events.TypeItemRemoved
+	if change.Action == cart.ChangeRemoved {
+		eventType = events.TypeItemAdded
+	}
+	h.publishCartEvent(c, eventType, sessionID, cartName, change.Product, change.Quantity)
+}
+
+// addCartHistory shows the latest changes of the cart as its recent activity, left out when they fail
+// to load
+func (h *CartHandler) addCartHistory(c *gin.Context, data *TemplateData, userCart *cart.Cart) {
+	changes, err := h.repoFor(c).ListCartChanges(userCart.ID, 0, recentActivityShown)
+	if err != nil {
+		log.Printf("Failed to load cart history: %v", err)
+		return
+	}
+	for _, change := range changes {
+		data.CartHistory = append(data.CartHistory, CartChangeView{
+			Time:        change.CreatedAt.UTC().Format("2006-01-02 15:04 MST"),
+			Description: h.describeChange(change, data.Locale, data.Currency),
+			Undone:      change.UndoneAt != nil,
+		})
+	}
+}
+
+// describeChange returns the sentence telling customers about the change in their language
+func (h *CartHandler) describeChange(change cart.Change, locale, currency string) string {
+	switch change.Action {
+	case cart.ChangeAdded:
+		return i18n.T(locale, "Added %d × %s", change.Quantity, change.Product)
+	case cart.ChangeRemoved:
+		return i18n.T(locale, "Took out %d × %s", change.Quantity, change.Product)
+	case cart.ChangeRestored:
+		return i18n.T(locale, "Put back %d × %s", change.Quantity, change.Product)
+	case cart.ChangeMerged:
+		return i18n.T(locale, "Brought over %d × %s from before logging in", change.Quantity, change.Product)
+	case cart.ChangeRepriced:
+		return i18n.T(locale, "Price of %s changed to %s", change.Product, h.currencies.Format(change.Amount, currency))
+	case cart.ChangeGiftCard:
+		return i18n.T(locale, "Redeemed %s of gift card credit", h.currencies.Format(change.Amount, currency))
+	case cart.ChangeReferral:
+		return i18n.T(locale, "Entered a referral code")
+	case cart.ChangeCheckedOut:
+		return i18n.T(locale, "Checked out")
+	case cart.ChangeExpired:
+		return i18n.T(locale, "The reservation of %d × %s ran out", change.Quantity, change.Product)
+	}
+	return change.Action
+}
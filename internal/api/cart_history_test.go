@@ -0,0 +1,129 @@
+package api_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"interview/internal/api"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCartHistory(t *testing.T) {
+	ts := setupTest(t)
+	router := setupAPIRouter(t, ts)
+
+	t.Run("API Lists Changes Newest First", func(t *testing.T) {
+		ts.clearDatabase(t)
+		pair := issueToken(t, router)
+		w := doJSON(t, router, http.MethodPost, "/api/v1/cart/items", pair.AccessToken,
+			api.AddItemRequest{Product: "shoe", Quantity: 2})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var cart api.CartResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &cart))
+		w = doJSON(t, router, http.MethodPost, "/api/v1/cart/items", pair.AccessToken,
+			api.AddItemRequest{Product: "watch", Quantity: 1})
+		require.Equal(t, http.StatusCreated, w.Code)
+		w = doJSON(t, router, http.MethodDelete, fmt.Sprintf("/api/v1/cart/items/%d", cart.Items[0].ID), pair.AccessToken, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		w = doJSON(t, router, http.MethodGet, "/api/v1/cart/history?limit=2", pair.AccessToken, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var page api.CartChangePage
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		require.Len(t, page.Changes, 2)
+		assert.Equal(t, "removed", page.Changes[0].Action)
+		assert.Equal(t, "shoe", page.Changes[0].Product)
+		assert.Equal(t, 2, page.Changes[0].Quantity)
+		assert.Equal(t, "added", page.Changes[1].Action)
+		assert.Equal(t, "watch", page.Changes[1].Product)
+		require.NotEmpty(t, page.NextCursor)
+
+		w = doJSON(t, router, http.MethodGet, "/api/v1/cart/history?after="+page.NextCursor, pair.AccessToken, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		page = api.CartChangePage{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		require.Len(t, page.Changes, 1)
+		assert.Equal(t, "added", page.Changes[0].Action)
+		assert.Equal(t, "shoe", page.Changes[0].Product)
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("API Empty History", func(t *testing.T) {
+		ts.clearDatabase(t)
+		pair := issueToken(t, router)
+		w := doJSON(t, router, http.MethodGet, "/api/v1/cart/history", pair.AccessToken, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"changes": []}`, w.Body.String())
+	})
+
+	t.Run("Cart Page Shows Recent Activity", func(t *testing.T) {
+		ts.clearDatabase(t)
+		cookie := ts.createSession(t)
+		w := ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "Recent activity")
+
+		w = ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"shoe"}, "quantity": {"2"}}, cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+
+		w = ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Recent activity")
+		assert.Contains(t, w.Body.String(), "Added 2 × shoe")
+	})
+
+	t.Run("API Undoes The Latest Change", func(t *testing.T) {
+		ts.clearDatabase(t)
+		pair := issueToken(t, router)
+		w := doJSON(t, router, http.MethodPost, "/api/v1/cart/undo", pair.AccessToken, nil)
+		require.Equal(t, http.StatusConflict, w.Code)
+		var problem api.Problem
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+		assert.Equal(t, "There is no recent change to undo", problem.Detail)
+
+		w = doJSON(t, router, http.MethodPost, "/api/v1/cart/items", pair.AccessToken,
+			api.AddItemRequest{Product: "shoe", Quantity: 2})
+		require.Equal(t, http.StatusCreated, w.Code)
+		var cart api.CartResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &cart))
+		w = doJSON(t, router, http.MethodDelete, fmt.Sprintf("/api/v1/cart/items/%d", cart.Items[0].ID), pair.AccessToken, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		w = doJSON(t, router, http.MethodPost, "/api/v1/cart/undo", pair.AccessToken, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		cart = api.CartResponse{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &cart))
+		require.Len(t, cart.Items, 1)
+		assert.Equal(t, 2, cart.Items[0].Quantity)
+
+		w = doJSON(t, router, http.MethodGet, "/api/v1/cart/history", pair.AccessToken, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var page api.CartChangePage
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		require.Len(t, page.Changes, 2)
+		assert.NotNil(t, page.Changes[0].UndoneAt)
+		assert.Nil(t, page.Changes[1].UndoneAt)
+	})
+
+	t.Run("Cart Page Undoes The Latest Change", func(t *testing.T) {
+		ts.clearDatabase(t)
+		cookie := ts.createSession(t)
+		w := ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"shoe"}, "quantity": {"2"}}, cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+
+		w = ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Undo last change")
+
+		w = ts.makeRequest(t, http.MethodPost, "/undo-change", nil, cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+		w = ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Undone: Added 2 × shoe")
+		assert.Contains(t, w.Body.String(), "(undone)")
+	})
+}
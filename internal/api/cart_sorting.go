@@ -0,0 +1,160 @@
+package api
+
+import (
+	"errors"
+	productpkg "interview/internal/product"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// itemGroupingKey is the session key of how the cart page groups items, empty to show them as arranged
+	itemGroupingKey = "item_grouping"
+	// groupByCategory groups the items of the cart page by the category of their products
+	groupByCategory = "category"
+)
+
+type (
+	// ProductCategoryRequest is the JSON body accepted by POST /admin/products/:id/category. An empty
+	// category takes the product out of its category.
+	ProductCategoryRequest struct {
+		Category string `json:"category"`
+	}
+
+	// ItemGroupView is a group of the items shown on the cart page: the items of a category when they are
+	// grouped by category, all of them otherwise. Category is empty for products without one.
+	ItemGroupView struct {
+		Category string
+		Items    []CartItemView
+	}
+)
+
+// UpdateProductCategory puts a product in a category, by which customers can group the items of their
+// cart.
+func (h *AdminHandler) UpdateProductCategory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid product ID")
+		return
+	}
+	var req ProductCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	category, err := productpkg.NormalizeCategory(req.Category)
+	if err != nil {
+		respondWithInvalidField(c, "category", "must be at most 64 characters")
+		return
+	}
+
+	switch err := h.repoFor(c).SetProductCategory(uint(id), category); {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		respondWithProblem(c, http.StatusNotFound, "product not found")
+	case err != nil:
+		log.Printf("Failed to update product category: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to update product category")
+	default:
+		c.JSON(http.StatusOK, gin.H{"id": id, "category": category})
+	}
+}
+
+// ReorderItems arranges the items of the user's cart in the order of the "items" posted, the IDs of
+// every item of the cart, e.g. once an item was dragged to another position.
+func (h *CartHandler) ReorderItems(c *gin.Context) {
+	session := sessions.Default(c)
+
+	values := c.PostFormArray("items")
+	itemIDs := make([]uint, len(values))
+	for i, value := range values {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			h.redirectWithFlash(c, session, "Invalid item ID")
+			return
+		}
+		itemIDs[i] = uint(id)
+	}
+
+	sessionID := session.Get("session_id")
+	if sessionID == nil {
+		h.redirectWithFlash(c, session, "Invalid session")
+		return
+	}
+
+	err := h.carts.ReorderItems(c.Request.Context(), sessionID.(string), currentCartName(session), itemIDs)
+	if err != nil {
+		h.redirectWithFlash(c, session, errorMessage(err, "Failed to reorder items"))
+		return
+	}
+	c.Redirect(http.StatusFound, "/")
+}
+
+// GroupItems sets how the cart page of the session groups items: by the category of their products
+// when "by" is "category", not at all otherwise.
+func (h *CartHandler) GroupItems(c *gin.Context) {
+	session := sessions.Default(c)
+	if c.PostForm("by") == groupByCategory {
+		session.Set(itemGroupingKey, groupByCategory)
+	} else {
+		session.Delete(itemGroupingKey)
+	}
+	if err := session.Save(); err != nil {
+		log.Printf("Failed to save session: %v", err)
+	}
+	c.Redirect(http.StatusFound, "/")
+}
+
+// groupedByCategory reports whether the session groups the items of the cart page by category.
+func groupedByCategory(session sessions.Session) bool {
+	grouping, _ := session.Get(itemGroupingKey).(string)
+	return grouping == groupByCategory
+}
+
+// itemGroups returns the groups of items shown on the cart page. Grouped by category, categories come
+// in the order of their first item and products without one last; items keep the order they were
+// arranged in. Without categories, or when they fail to load, all items form one group.
+func (h *CartHandler) itemGroups(c *gin.Context, views []CartItemView, byCategory bool) []ItemGroupView {
+	if len(views) == 0 {
+		return nil
+	}
+	if !byCategory {
+		return []ItemGroupView{{Items: views}}
+	}
+
+	names := make([]string, len(views))
+	for i, view := range views {
+		names[i] = view.Product
+	}
+	products, err := h.repoFor(c).GetProductsByName(names)
+	if err != nil {
+		log.Printf("Failed to load product categories: %v", err)
+		return []ItemGroupView{{Items: views}}
+	}
+
+	var groups []ItemGroupView
+	index := map[string]int{}
+	var uncategorized []CartItemView
+	for _, view := range views {
+		category := products[view.Product].Category
+		if category == "" {
+			uncategorized = append(uncategorized, view)
+			continue
+		}
+		i, ok := index[category]
+		if !ok {
+			i = len(groups)
+			index[category] = i
+			groups = append(groups, ItemGroupView{Category: category})
+		}
+		groups[i].Items = append(groups[i].Items, view)
+	}
+	if len(uncategorized) > 0 {
+		groups = append(groups, ItemGroupView{Items: uncategorized})
+	}
+	return groups
+}
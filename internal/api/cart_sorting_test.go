@@ -0,0 +1,136 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"interview/internal/api"
+	"interview/internal/repo"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCartItemSorting(t *testing.T) {
+	ts := setupTest(t)
+	cartRepo := repo.NewRepository(ts.db)
+	admin := gin.New()
+	api.NewAdminHandler(ts.db, nil, time.Hour).RegisterRoutes(admin, gin.Accounts{"admin": "secret"})
+
+	// setup fills the cart of a new session with a shoe, a bag and a watch, added in this order
+	setup := func(t *testing.T) (*http.Cookie, map[string]uint) {
+		t.Helper()
+		ts.clearDatabase(t)
+		ids := map[string]uint{}
+		for _, name := range []string{"shoe", "bag", "watch"} {
+			p, err := cartRepo.UpsertProduct(name, 10)
+			require.NoError(t, err)
+			ids[name] = p.ID
+		}
+		cookie := ts.createSession(t)
+		for _, name := range []string{"shoe", "bag", "watch"} {
+			w := ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {name}, "quantity": {"1"}}, cookie)
+			require.Equal(t, http.StatusFound, w.Code)
+		}
+		return cookie, ids
+	}
+	// setCategory puts the product with the ID in the category through the admin API
+	setCategory := func(t *testing.T, id uint, category string) *httptest.ResponseRecorder {
+		t.Helper()
+		var buf bytes.Buffer
+		require.NoError(t, json.NewEncoder(&buf).Encode(api.ProductCategoryRequest{Category: category}))
+		req := httptest.NewRequest(http.MethodPost, "/admin/products/"+strconv.FormatUint(uint64(id), 10)+"/category", &buf)
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		admin.ServeHTTP(w, req)
+		return w
+	}
+	// cartPage returns the cart page and the IDs of its items in the order shown
+	cartPage := func(t *testing.T, cookie *http.Cookie) (string, []string) {
+		t.Helper()
+		w := ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		require.Equal(t, http.StatusOK, w.Code)
+		var ids []string
+		for _, match := range regexp.MustCompile(`data-item-id="(\d+)"`).FindAllStringSubmatch(w.Body.String(), -1) {
+			ids = append(ids, match[1])
+		}
+		return w.Body.String(), ids
+	}
+	// assertOrder checks the products appear on the page in the order given
+	assertOrder := func(t *testing.T, body string, products ...string) {
+		t.Helper()
+		for i := 1; i < len(products); i++ {
+			assert.Less(t, strings.Index(body, "Product: "+products[i-1]), strings.Index(body, "Product: "+products[i]))
+		}
+	}
+
+	t.Run("Reorders Items", func(t *testing.T) {
+		cookie, _ := setup(t)
+		body, ids := cartPage(t, cookie)
+		require.Len(t, ids, 3)
+		assert.Contains(t, body, `id="reorder-form"`)
+
+		w := ts.makeRequest(t, http.MethodPost, "/cart/items/reorder", url.Values{"items": {ids[2], ids[0], ids[1]}}, cookie)
+		assert.Equal(t, http.StatusFound, w.Code)
+		body, _ = cartPage(t, cookie)
+		assertOrder(t, body, "watch", "shoe", "bag")
+	})
+
+	t.Run("Rejects Stale Orders", func(t *testing.T) {
+		cookie, _ := setup(t)
+		_, ids := cartPage(t, cookie)
+
+		w := ts.makeRequest(t, http.MethodPost, "/cart/items/reorder", url.Values{"items": {ids[2], ids[0]}}, cookie)
+		assert.Equal(t, http.StatusFound, w.Code)
+		body, _ := cartPage(t, cookie)
+		assert.Contains(t, body, "Your cart changed, please arrange its items again")
+		assertOrder(t, body, "shoe", "bag", "watch")
+
+		w = ts.makeRequest(t, http.MethodPost, "/cart/items/reorder", url.Values{"items": {"x"}}, cookie)
+		assert.Equal(t, http.StatusFound, w.Code)
+		body, _ = cartPage(t, cookie)
+		assert.Contains(t, body, "Invalid item ID")
+	})
+
+	t.Run("Groups Items By Category", func(t *testing.T) {
+		cookie, ids := setup(t)
+		for name, category := range map[string]string{"watch": "accessories", "shoe": "footwear"} {
+			w := setCategory(t, ids[name], category)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		}
+		body, _ := cartPage(t, cookie)
+		assert.NotContains(t, body, "footwear")
+		assert.Contains(t, body, "Group items by category")
+
+		w := ts.makeRequest(t, http.MethodPost, "/cart/items/group", url.Values{"by": {"category"}}, cookie)
+		assert.Equal(t, http.StatusFound, w.Code)
+		body, _ = cartPage(t, cookie)
+		assert.Contains(t, body, "Show items as arranged")
+		assert.Less(t, strings.Index(body, "footwear"), strings.Index(body, "accessories"))
+		assert.Less(t, strings.Index(body, "accessories"), strings.Index(body, "Other products"))
+		assertOrder(t, body, "shoe", "watch", "bag")
+
+		ts.makeRequest(t, http.MethodPost, "/cart/items/group", nil, cookie)
+		body, _ = cartPage(t, cookie)
+		assert.NotContains(t, body, "footwear")
+		assertOrder(t, body, "shoe", "bag", "watch")
+	})
+
+	t.Run("Invalid Categories Are Rejected", func(t *testing.T) {
+		_, ids := setup(t)
+		w := setCategory(t, ids["shoe"], strings.Repeat("x", 65))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"field":"category"`)
+		w = setCategory(t, 9999, "footwear")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
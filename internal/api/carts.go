@@ -0,0 +1,183 @@
+package api
+
+import (
+	"interview/internal/cart"
+	"log"
+	"net/http"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+type (
+	// CartNameRequest is the JSON body accepted by POST /api/v1/carts and PATCH /api/v1/carts/:name.
+	CartNameRequest struct {
+		Name string `json:"name"`
+	}
+)
+
+// currentCartName returns the name of the cart the session is working on.
+func currentCartName(session sessions.Session) string {
+	if name, ok := session.Get("cart_name").(string); ok && name != "" {
+		return name
+	}
+	return cart.DefaultName
+}
+
+// apiCartName returns the name of the cart an API request works on, chosen with ?cart=<name>.
+func apiCartName(c *gin.Context) string {
+	if name := c.Query("cart"); name != "" {
+		return name
+	}
+	return cart.DefaultName
+}
+
+// cartNames returns the names of the open carts of the session, for the cart switcher.
+func (h *CartHandler) cartNames(c *gin.Context, sessionID string) ([]string, error) {
+	carts, err := h.repoFor(c).ListCarts(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(carts))
+	for i, c := range carts {
+		names[i] = c.Name
+	}
+	return names, nil
+}
+
+// CreateCart creates a named cart for the session and switches to it.
+func (h *CartHandler) CreateCart(c *gin.Context) {
+	session := sessions.Default(c)
+
+	sessionID, ok := session.Get("session_id").(string)
+	if !ok {
+		h.redirectWithFlash(c, session, "Invalid session")
+		return
+	}
+
+	created, err := h.carts.CreateCart(c.Request.Context(), sessionID, c.PostForm("name"))
+	if err != nil {
+		h.redirectWithFlash(c, session, errorMessage(err, "Failed to create cart"))
+		return
+	}
+	h.switchCart(c, session, created.Name)
+}
+
+// SwitchCart makes another open cart of the session the current one.
+func (h *CartHandler) SwitchCart(c *gin.Context) {
+	session := sessions.Default(c)
+
+	sessionID, ok := session.Get("session_id").(string)
+	if !ok {
+		h.redirectWithFlash(c, session, "Invalid session")
+		return
+	}
+
+	name := c.PostForm("name")
+	if _, err := h.repoFor(c).GetExistingCart(sessionID, name); err != nil {
+		h.redirectWithFlash(c, session, errorMessage(err, "Failed to switch cart"))
+		return
+	}
+	h.switchCart(c, session, name)
+}
+
+// RenameCart renames the current cart of the session.
+func (h *CartHandler) RenameCart(c *gin.Context) {
+	session := sessions.Default(c)
+
+	sessionID, ok := session.Get("session_id").(string)
+	if !ok {
+		h.redirectWithFlash(c, session, "Invalid session")
+		return
+	}
+
+	name, err := h.carts.RenameCart(c.Request.Context(), sessionID, currentCartName(session), c.PostForm("name"))
+	if err != nil {
+		h.redirectWithFlash(c, session, errorMessage(err, "Failed to rename cart"))
+		return
+	}
+	h.switchCart(c, session, name)
+}
+
+// DeleteCart deletes the current cart of the session and switches back to the default cart.
+func (h *CartHandler) DeleteCart(c *gin.Context) {
+	session := sessions.Default(c)
+
+	sessionID, ok := session.Get("session_id").(string)
+	if !ok {
+		h.redirectWithFlash(c, session, "Invalid session")
+		return
+	}
+
+	if err := h.carts.DeleteCart(c.Request.Context(), sessionID, currentCartName(session)); err != nil {
+		h.redirectWithFlash(c, session, errorMessage(err, "Failed to delete cart"))
+		return
+	}
+	h.switchCart(c, session, cart.DefaultName)
+}
+
+// switchCart remembers the current cart in the session and shows it.
+func (h *CartHandler) switchCart(c *gin.Context, session sessions.Session, name string) {
+	session.Set("cart_name", name)
+	if err := session.Save(); err != nil {
+		log.Printf("Failed to save session: %v", err)
+	}
+	c.Redirect(http.StatusFound, "/")
+}
+
+// APIListCarts returns the open carts of the authenticated session.
+func (h *CartHandler) APIListCarts(c *gin.Context) {
+	carts, err := h.repoFor(c).ListCarts(c.GetString(apiSessionKey))
+	if err != nil {
+		log.Printf("Failed to list carts: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to list carts")
+		return
+	}
+	responses := make([]CartResponse, len(carts))
+	for i := range carts {
+		responses[i] = newCartResponse(&carts[i])
+	}
+	c.JSON(http.StatusOK, responses)
+}
+
+// APICreateCart creates a named cart for the authenticated session.
+func (h *CartHandler) APICreateCart(c *gin.Context) {
+	var req CartNameRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	created, err := h.carts.CreateCart(c.Request.Context(), c.GetString(apiSessionKey), req.Name)
+	if err != nil {
+		respondWithError(c, err, "Failed to create cart")
+		return
+	}
+	c.JSON(http.StatusCreated, newCartResponse(created))
+}
+
+// APIRenameCart renames a cart of the authenticated session.
+func (h *CartHandler) APIRenameCart(c *gin.Context) {
+	var req CartNameRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	sessionID := c.GetString(apiSessionKey)
+	name, err := h.carts.RenameCart(c.Request.Context(), sessionID, c.Param("name"), req.Name)
+	if err != nil {
+		respondWithError(c, err, "Failed to rename cart")
+		return
+	}
+	h.respondWithCart(c, sessionID, name, http.StatusOK)
+}
+
+// APIDeleteCart deletes a cart of the authenticated session.
+func (h *CartHandler) APIDeleteCart(c *gin.Context) {
+	if err := h.carts.DeleteCart(c.Request.Context(), c.GetString(apiSessionKey), c.Param("name")); err != nil {
+		respondWithError(c, err, "Failed to delete cart")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
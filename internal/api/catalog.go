@@ -0,0 +1,162 @@
+package api
+
+import (
+	"html/template"
+	"interview/internal/i18n"
+	productpkg "interview/internal/product"
+	"interview/internal/repo"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/csrf"
+)
+
+// catalogPageSize is the number of products shown per catalog page
+const catalogPageSize = 12
+
+type (
+	// CatalogData contains data to be rendered in the product catalog template.
+	CatalogData struct {
+		Error         string
+		Locale        string
+		CSRFFieldName template.HTML
+		Products      []ProductView
+		Query         string
+		MinPrice      string
+		MaxPrice      string
+		Sort          string
+		Page          int
+		TotalPages    int
+		PrevURL       string
+		NextURL       string
+		// Experiments maps the A/B experiments of the session to its variants
+		Experiments map[string]string
+		// Shop is the name of the shop the page belongs to, empty when the deployment serves one shop
+		Shop string
+		// BotFields and BotScript add the bot check to the forms adding items, empty without one
+		BotFields template.HTML
+		BotScript template.HTML
+	}
+
+	// ProductView represents a catalog product for the view layer.
+	ProductView struct {
+		ID    uint
+		Name  string
+		Price string
+		// OriginalPrice is the base price struck through while the product is on sale, empty otherwise
+		OriginalPrice string
+		ThumbnailURL  string
+		// OutOfStock is set when no units of the product are left
+		OutOfStock bool
+	}
+)
+
+// ShowProducts lists the product catalog with search by name (?q=), price range filtering
+// (?min_price=&max_price=), sorting (?sort=relevance|name|price_asc|price_desc|newest) and
+// pagination (?page=). Searches are sorted by relevance unless another order is chosen.
+func (h *CartHandler) ShowProducts(c *gin.Context) {
+	session := sessions.Default(c)
+	data := CatalogData{
+		Locale:   detectLocale(c, session).String(),
+		Query:    c.Query("q"),
+		MinPrice: c.Query("min_price"),
+		MaxPrice: c.Query("max_price"),
+		Sort:     c.Query("sort"),
+		Page:     1,
+		Shop:     shopName(c),
+	}
+	data.Experiments = experimentVariants(c)
+	if data.Sort == "" {
+		data.Sort = repo.SortByName
+		if data.Query != "" {
+			data.Sort = repo.SortByRelevance
+		}
+	}
+
+	query := repo.ProductQuery{Search: data.Query, Sort: data.Sort, Page: 1, PerPage: catalogPageSize}
+	if page, err := strconv.Atoi(c.Query("page")); err == nil && page > 1 {
+		query.Page, data.Page = page, page
+	}
+	var ok bool
+	if query.MinPrice, ok = parsePriceParam(data.MinPrice); !ok {
+		data.Error = "Invalid minimum price"
+	}
+	if query.MaxPrice, ok = parsePriceParam(data.MaxPrice); !ok {
+		data.Error = "Invalid maximum price"
+	}
+
+	if data.Error == "" {
+		products, total, err := h.repoFor(c).SearchProducts(query)
+		if err != nil {
+			log.Printf("Failed to search products: %v", err)
+			data.Error = "Failed to load products"
+		} else {
+			data.Products = h.createProductViews(c, products, sessionCurrency(session))
+			data.TotalPages = int((total + catalogPageSize - 1) / catalogPageSize)
+			if data.Page > 1 {
+				data.PrevURL = catalogPageURL(c.Request.URL.Query(), data.Page-1)
+			}
+			if data.Page < data.TotalPages {
+				data.NextURL = catalogPageURL(c.Request.URL.Query(), data.Page+1)
+			}
+		}
+	}
+
+	data.Error = i18n.T(data.Locale, data.Error)
+	data.CSRFFieldName = csrf.TemplateField(c.Request)
+	data.BotFields, data.BotScript = h.botFields()
+	if err := h.templateFor(c).ExecuteTemplate(c.Writer, "products.html", data); err != nil {
+		log.Printf("Failed to render template: %v", err)
+		http.Error(c.Writer, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// parsePriceParam parses an optional non-negative price; it returns false for invalid values.
+func parsePriceParam(value string) (*float64, bool) {
+	if value == "" {
+		return nil, true
+	}
+	price, err := strconv.ParseFloat(value, 64)
+	if err != nil || price < 0 {
+		return nil, false
+	}
+	return &price, true
+}
+
+// catalogPageURL keeps the current filters and replaces the page number.
+func catalogPageURL(query url.Values, page int) string {
+	query.Set("page", strconv.Itoa(page))
+	return "/products?" + query.Encode()
+}
+
+// createProductViews shows the products at their sale prices where they have an active price override.
+func (h *CartHandler) createProductViews(c *gin.Context, products []productpkg.Product, currency string) []ProductView {
+	names := make([]string, len(products))
+	for i, p := range products {
+		names[i] = p.Name
+	}
+	overrides, err := h.repoFor(c).ActivePriceOverrides(names, time.Now())
+	if err != nil {
+		log.Printf("Failed to load sale prices: %v", err)
+	}
+
+	views := make([]ProductView, len(products))
+	for i, p := range products {
+		views[i] = ProductView{ID: p.ID, Name: p.Name, Price: h.currencies.Format(p.Price, currency), OutOfStock: p.OutOfStock()}
+		if o, onSale := overrides[p.Name]; onSale && o.Price != p.Price {
+			views[i].OriginalPrice = views[i].Price
+			views[i].Price = h.currencies.Format(o.Price, currency)
+		}
+		if h.storage != nil && p.ThumbnailKey != "" {
+			if url, err := h.storage.SignedURL(p.ThumbnailKey, h.mediaTTL); err == nil {
+				views[i].ThumbnailURL = url
+			}
+		}
+	}
+	return views
+}
@@ -0,0 +1,87 @@
+package api_test
+
+import (
+	"fmt"
+	"interview/internal/pricelist"
+	"interview/internal/repo"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShowProducts(t *testing.T) {
+	ts := setupTest(t)
+	ts.clearDatabase(t)
+
+	cartRepo := repo.NewRepository(ts.db)
+	for i := 0; i < 15; i++ {
+		_, err := cartRepo.UpsertProduct(fmt.Sprintf("product-%02d", i), float64(i))
+		require.NoError(t, err)
+	}
+	_, err := cartRepo.UpsertProduct("shoe", 10.0)
+	require.NoError(t, err)
+
+	t.Run("First Page", func(t *testing.T) {
+		w := ts.makeRequest(t, http.MethodGet, "/products", nil, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		body := w.Body.String()
+		assert.Contains(t, body, "product-00")
+		assert.NotContains(t, body, "shoe")
+		assert.Contains(t, body, "Page 1 of 2")
+		assert.Contains(t, body, `href="/products?page=2"`)
+		assert.Contains(t, body, `action="/add-item"`)
+	})
+
+	t.Run("Search And Filter", func(t *testing.T) {
+		w := ts.makeRequest(t, http.MethodGet, "/products?q=sho&max_price=20", nil, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		body := w.Body.String()
+		assert.Contains(t, body, `name="product" value="shoe"`)
+		assert.NotContains(t, body, "product-00")
+	})
+
+	t.Run("Invalid Price", func(t *testing.T) {
+		w := ts.makeRequest(t, http.MethodGet, "/products?min_price=abc", nil, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Invalid minimum price")
+	})
+
+	t.Run("No Results", func(t *testing.T) {
+		w := ts.makeRequest(t, http.MethodGet, "/products?q=nothing", nil, nil)
+		assert.Contains(t, w.Body.String(), "No products found")
+	})
+
+	t.Run("Sale Price", func(t *testing.T) {
+		now := time.Now()
+		require.NoError(t, cartRepo.CreatePriceOverride(&pricelist.PriceOverride{
+			Product: "shoe", Price: 7.5, StartsAt: now.Add(-time.Hour), EndsAt: now.Add(time.Hour)}))
+		require.NoError(t, cartRepo.CreatePriceOverride(&pricelist.PriceOverride{
+			Product: "product-01", Price: 0.5, StartsAt: now.Add(-2 * time.Hour), EndsAt: now.Add(-time.Hour)}))
+
+		w := ts.makeRequest(t, http.MethodGet, "/products?q=sho", nil, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Regexp(t, `<s>[^<]*10\.00[^<]*</s> [^<]*7\.50`, w.Body.String())
+
+		w = ts.makeRequest(t, http.MethodGet, "/products?q=product-01", nil, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "<s>", "expired overrides are ignored")
+	})
+
+	t.Run("Adds Catalog Products At Their Price", func(t *testing.T) {
+		_, err := cartRepo.UpsertProduct("product-03", 3.25)
+		require.NoError(t, err)
+		cookie := ts.createSession(t)
+		w := ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"product-03"}, "quantity": {"2"}}, cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+
+		carts, err := cartRepo.GetAllCarts()
+		require.NoError(t, err)
+		require.Len(t, carts, 1)
+		require.Len(t, carts[0].CartItems, 1)
+		assert.Equal(t, 3.25, carts[0].CartItems[0].Price)
+	})
+}
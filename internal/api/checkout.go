@@ -0,0 +1,411 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"interview/internal/address"
+	"interview/internal/cart"
+	"interview/internal/checkout"
+	"interview/internal/i18n"
+	"interview/internal/payment"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/csrf"
+)
+
+type (
+	// CheckoutData contains data to be rendered in the checkout template.
+	CheckoutData struct {
+		Error         string
+		Notice        string
+		Locale        string
+		CSRFFieldName template.HTML
+		// Step is the step shown, which is the step the customer is at unless they went back
+		Step  string
+		Steps []CheckoutStepView
+		// Addresses is the address book to ship to, AddressID the chosen address and Address its label.
+		// BillingAddressID is the address chosen to bill to, 0 to bill the shipping address.
+		Addresses        []CheckoutAddressView
+		AddressID        uint
+		Address          string
+		BillingAddressID uint
+		// VerifyEmail asks the user to verify their email address to use the addresses they saved
+		VerifyEmail bool
+		// VAT is whether businesses can enter their VAT ID with the address, VATID is the one entered and
+		// TaxExempt whether it zero-rates the order
+		VAT       bool
+		VATID     string
+		TaxExempt bool
+		// ShippingMethods can be chosen at the shipping step, ShippingMethod is the chosen one
+		ShippingMethods []CheckoutShippingView
+		ShippingMethod  string
+		Shipping        string
+		Total           string
+		// Payment is whether the cart costs anything, carts without a total skip the payment
+		Payment bool
+	}
+
+	// CheckoutStepView represents a step of the checkout for the view layer.
+	CheckoutStepView struct {
+		Name  string
+		Title string
+		// Reached is whether the customer got to the step and can go back to it
+		Reached bool
+	}
+
+	// CheckoutAddressView represents an address of the address book for the view layer.
+	CheckoutAddressView struct {
+		ID    uint
+		Label string
+	}
+
+	// CheckoutShippingView represents a shipping method for the view layer.
+	CheckoutShippingView struct {
+		Name  string
+		Title string
+	}
+)
+
+var (
+	// checkoutStepTitles are the titles of the checkout steps shown to customers
+	checkoutStepTitles = map[string]string{
+		checkout.StepAddress:  "Address",
+		checkout.StepShipping: "Shipping",
+		checkout.StepPayment:  "Payment",
+		checkout.StepConfirm:  "Confirm",
+	}
+	// shippingMethodTitles are the titles of the shipping methods shown to customers
+	shippingMethodTitles = map[string]string{
+		checkout.ShippingStandard: "Standard shipping",
+	}
+)
+
+// Checkout starts the checkout of the current cart of the session, or resumes it when the cart is already
+// being checked out.
+func (h *CartHandler) Checkout(c *gin.Context) {
+	session := sessions.Default(c)
+	sessionID, ok := session.Get("session_id").(string)
+	if !ok {
+		h.redirectWithFlash(c, session, "Invalid session")
+		return
+	}
+
+	userCart, err := h.repoFor(c).GetExistingCart(sessionID, currentCartName(session))
+	if err == nil && userCart.Status != cart.StatusOpen {
+		err = cart.ErrCartClosed
+	}
+	if err != nil {
+		h.redirectWithFlash(c, session, errorMessage(err, "Failed to load cart"))
+		return
+	}
+	if len(userCart.CartItems) == 0 {
+		h.redirectWithFlash(c, session, "Your cart is empty")
+		return
+	}
+	if _, err := h.repoFor(c).StartCheckout(sessionID, userCart.ID); err != nil {
+		log.Printf("Failed to start checkout: %v", err)
+		h.redirectWithFlash(c, session, "Failed to check out")
+		return
+	}
+	c.Redirect(http.StatusFound, "/checkout")
+}
+
+// ShowCheckout shows the step the checkout of the current cart is at, or an earlier step given by ?step=.
+func (h *CartHandler) ShowCheckout(c *gin.Context) {
+	session := sessions.Default(c)
+	userCart, s, ok := h.loadCheckout(c, session, "")
+	if !ok {
+		return
+	}
+
+	data := CheckoutData{Locale: detectLocale(c, session).String(), Step: s.Step}
+	data.CSRFFieldName = csrf.TemplateField(c.Request)
+	flashes := session.Flashes()
+	notices := session.Flashes(noticeFlash)
+	if len(flashes) > 0 {
+		data.Error = flashes[0].(string)
+	}
+	if len(notices) > 0 {
+		data.Notice = notices[0].(string)
+	}
+	if len(flashes) > 0 || len(notices) > 0 {
+		if err := session.Save(); err != nil {
+			log.Printf("Failed to save session: %v", err)
+		}
+	}
+
+	if step := c.Query("step"); s.Reached(step) {
+		data.Step = step
+	}
+	for _, step := range checkout.Steps {
+		data.Steps = append(data.Steps, CheckoutStepView{Name: step, Title: checkoutStepTitles[step], Reached: s.Reached(step)})
+	}
+
+	addressBookUser := h.addressBookUser(c, session)
+	data.VerifyEmail = addressBookUser != sessionUserIDValue(session)
+	addresses, err := h.repoFor(c).ListAddresses(addressBookUser, s.SessionID)
+	if err != nil {
+		log.Printf("Failed to list addresses: %v", err)
+		data.Error = "Failed to load checkout"
+	}
+	for _, a := range addresses {
+		view := CheckoutAddressView{ID: a.ID, Label: addressLabel(a)}
+		data.Addresses = append(data.Addresses, view)
+		if s.AddressID != nil && *s.AddressID == a.ID {
+			data.AddressID, data.Address = a.ID, view.Label
+		}
+		if s.BillingAddressID != nil && *s.BillingAddressID == a.ID {
+			data.BillingAddressID = a.ID
+		}
+	}
+	for _, method := range checkout.ShippingMethods {
+		data.ShippingMethods = append(data.ShippingMethods, CheckoutShippingView{Name: method, Title: shippingMethodTitles[method]})
+	}
+	data.ShippingMethod = s.ShippingMethod
+	data.VAT, data.VATID, data.TaxExempt = h.vat != nil, s.VATID, userCart.TaxExempt
+	currency := sessionCurrency(session)
+	data.Shipping = h.currencies.Format(userCart.Shipping, currency)
+	data.Total = h.currencies.Format(userCart.Total, currency)
+	data.Payment = userCart.Total > 0
+
+	data.Error = i18n.T(data.Locale, data.Error)
+	data.Notice = i18n.T(data.Locale, data.Notice)
+	if err := h.templateFor(c).ExecuteTemplate(c.Writer, "checkout.html", data); err != nil {
+		log.Printf("Failed to render template: %v", err)
+	}
+}
+
+// SetCheckoutAddress ships the order to the "address_id" of the address book, or to a new address saved
+// from the form, and bills it to the "billing_address_id" of the address book, or the shipping address
+// without one. It checks the "vat_id" of businesses if VAT IDs are accepted, and moves on to the shipping
+// step.
+func (h *CartHandler) SetCheckoutAddress(c *gin.Context) {
+	session := sessions.Default(c)
+	_, s, ok := h.loadCheckout(c, session, checkout.StepAddress)
+	if !ok {
+		return
+	}
+
+	userID := h.addressBookUser(c, session)
+	var billingID *uint
+	if id := c.PostForm("billing_address_id"); id != "" {
+		addressID, err := strconv.ParseUint(id, 10, 32)
+		if err != nil {
+			h.checkoutFlash(c, session, "Invalid address ID")
+			return
+		}
+		billing, err := h.repoFor(c).GetAddress(userID, s.SessionID, uint(addressID))
+		if err != nil {
+			h.checkoutFlash(c, session, errorMessage(err, "Failed to save the address"))
+			return
+		}
+		billingID = &billing.ID
+	}
+
+	var a *address.Address
+	var err error
+	if id := c.PostForm("address_id"); id != "" {
+		addressID, parseErr := strconv.ParseUint(id, 10, 32)
+		if parseErr != nil {
+			h.checkoutFlash(c, session, "Invalid address ID")
+			return
+		}
+		a, err = h.repoFor(c).GetAddress(userID, s.SessionID, uint(addressID))
+	} else {
+		a = &address.Address{
+			SessionID:  s.SessionID,
+			Name:       c.PostForm("name"),
+			Line1:      c.PostForm("line1"),
+			Line2:      c.PostForm("line2"),
+			City:       c.PostForm("city"),
+			PostalCode: c.PostForm("postal_code"),
+			Country:    c.PostForm("country"),
+			Phone:      c.PostForm("phone"),
+		}
+		// The addresses of unverified users stay with the session rather than their address book
+		if userID != 0 {
+			a.UserID = &userID
+		}
+		err = h.repoFor(c).CreateAddress(a)
+	}
+	var invalid address.ValidationErrors
+	if errors.As(err, &invalid) {
+		h.checkoutFlash(c, session, "Please enter a complete address")
+		return
+	} else if err != nil {
+		h.checkoutFlash(c, session, errorMessage(err, "Failed to save the address"))
+		return
+	}
+
+	s.AddressID, s.BillingAddressID = &a.ID, billingID
+	if h.vat != nil && !h.checkVATID(c, session, s, a.Country) {
+		return
+	}
+	s.Step = checkout.StepShipping
+	h.advanceCheckout(c, session, s)
+}
+
+// SetCheckoutShipping ships the order with the "method" and moves on to the payment step.
+func (h *CartHandler) SetCheckoutShipping(c *gin.Context) {
+	session := sessions.Default(c)
+	_, s, ok := h.loadCheckout(c, session, checkout.StepShipping)
+	if !ok {
+		return
+	}
+	method := c.PostForm("method")
+	if !checkout.ValidShippingMethod(method) {
+		h.checkoutFlash(c, session, errorMessage(checkout.ErrInvalidShipping, ""))
+		return
+	}
+
+	s.ShippingMethod = method
+	s.Step = checkout.StepPayment
+	h.advanceCheckout(c, session, s)
+}
+
+// ConfirmCheckout captures the approved payment and checks out the cart, unless the risk checks hold or
+// block the payment. Confirming twice, e.g. by submitting the form again, shows the order without
+// checking the cart out twice.
+func (h *CartHandler) ConfirmCheckout(c *gin.Context) {
+	session := sessions.Default(c)
+	userCart, s, ok := h.loadCheckout(c, session, checkout.StepConfirm)
+	if !ok {
+		return
+	}
+
+	if s.PaymentID != nil && h.risk != nil && !h.screenCheckout(c, session, userCart, s) {
+		return
+	}
+	var err error
+	switch {
+	case s.PaymentID != nil:
+		var p *payment.Payment
+		if p, err = h.repoFor(c).GetPaymentByID(*s.PaymentID); err == nil {
+			_, err = h.capturePayment(c.Request.Context(), p.ExternalID)
+		}
+	case userCart.Total > 0:
+		err = payment.ErrNotApproved
+	default:
+		var checkedOut bool
+		if checkedOut, err = h.repoFor(c).CompleteCheckout(s.ID); checkedOut {
+			h.checkedOut(userCart.ID)
+		}
+	}
+	if errors.Is(err, payment.ErrCartChanged) || errors.Is(err, payment.ErrNotApproved) {
+		// The payment has to be started again
+		s.Step = checkout.StepPayment
+		resetCheckoutPayment(s)
+		if saveErr := h.repoFor(c).SaveCheckout(s); saveErr != nil {
+			log.Printf("Failed to save checkout: %v", saveErr)
+		}
+	}
+	if err != nil {
+		h.checkoutFlash(c, session, errorMessage(err, "Failed to complete the payment"))
+		return
+	}
+	c.Redirect(http.StatusFound, fmt.Sprintf("/orders/%d", userCart.ID))
+}
+
+// CancelCheckout cancels the checkout of the current cart, which unlocks the cart for changes.
+func (h *CartHandler) CancelCheckout(c *gin.Context) {
+	session := sessions.Default(c)
+	userCart, _, ok := h.loadCheckout(c, session, "")
+	if !ok {
+		return
+	}
+	if err := h.repoFor(c).CancelCheckout(userCart.ID); err != nil {
+		log.Printf("Failed to cancel checkout: %v", err)
+		h.checkoutFlash(c, session, "Failed to cancel the checkout")
+		return
+	}
+	redirectWithNotice(c, session, "The checkout was cancelled")
+}
+
+// loadCheckout loads the open current cart of the session and its active checkout session, which must
+// have reached the step unless it is empty. Completed checkouts redirect to their order; expired,
+// cancelled and reviewed ones to the cart. When ok is false, the response was sent already.
+func (h *CartHandler) loadCheckout(c *gin.Context, session sessions.Session, step string) (*cart.Cart, *checkout.Session, bool) {
+	sessionID, ok := session.Get("session_id").(string)
+	if !ok {
+		h.redirectWithFlash(c, session, "Invalid session")
+		return nil, nil, false
+	}
+	userCart, err := h.repoFor(c).GetExistingCart(sessionID, currentCartName(session))
+	if err != nil {
+		h.redirectWithFlash(c, session, errorMessage(err, "Failed to load cart"))
+		return nil, nil, false
+	}
+	s, err := h.repoFor(c).GetCheckout(userCart.ID)
+	if err != nil {
+		h.redirectWithFlash(c, session, errorMessage(err, "Failed to load checkout"))
+		return nil, nil, false
+	}
+
+	switch {
+	case s.Status == checkout.StatusCompleted:
+		c.Redirect(http.StatusFound, fmt.Sprintf("/orders/%d", userCart.ID))
+		return nil, nil, false
+	case userCart.Status != cart.StatusOpen:
+		h.redirectWithFlash(c, session, errorMessage(cart.ErrCartClosed, ""))
+		return nil, nil, false
+	case s.Status == checkout.StatusReview:
+		redirectWithNotice(c, session, "Your order is being reviewed before the payment is taken")
+		return nil, nil, false
+	case !s.Active(time.Now()):
+		h.redirectWithFlash(c, session, errorMessage(checkout.ErrSessionExpired, ""))
+		return nil, nil, false
+	case step != "" && !s.Reached(step):
+		h.checkoutFlash(c, session, errorMessage(checkout.ErrStepNotReached, ""))
+		return nil, nil, false
+	}
+	return userCart, s, true
+}
+
+// advanceCheckout saves the checkout session after the customer completed a step before the payment,
+// which has to be started again as what is paid for may have changed, and shows the next step.
+func (h *CartHandler) advanceCheckout(c *gin.Context, session sessions.Session, s *checkout.Session) {
+	resetCheckoutPayment(s)
+	if err := h.repoFor(c).SaveCheckout(s); err != nil {
+		log.Printf("Failed to save checkout: %v", err)
+		h.checkoutFlash(c, session, "Failed to save the checkout")
+		return
+	}
+	c.Redirect(http.StatusFound, "/checkout")
+}
+
+// checkoutFlash shows the message on the checkout page.
+func (h *CartHandler) checkoutFlash(c *gin.Context, session sessions.Session, message string) {
+	session.AddFlash(message)
+	if err := session.Save(); err != nil {
+		log.Printf("Failed to save session: %v", err)
+	}
+	c.Redirect(http.StatusFound, "/checkout")
+}
+
+// resetCheckoutPayment forgets the payment started at the payment step.
+func resetCheckoutPayment(s *checkout.Session) {
+	s.PaymentID = nil
+	s.ApproveURL = ""
+}
+
+// addressLabel returns the address on one line, e.g. "Jane Doe, Main St 1, 10115 Berlin, DE".
+func addressLabel(a address.Address) string {
+	parts := []string{a.Name, a.Line1}
+	if a.Line2 != "" {
+		parts = append(parts, a.Line2)
+	}
+	return strings.Join(append(parts, a.PostalCode+" "+a.City, a.Country), ", ")
+}
+
+// sessionUserIDValue returns the ID of the logged-in user, 0 for anonymous sessions.
+func sessionUserIDValue(session sessions.Session) uint {
+	userID, _ := session.Get("user_id").(uint)
+	return userID
+}
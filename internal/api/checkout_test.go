@@ -0,0 +1,586 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"interview/internal/address"
+	"interview/internal/api"
+	cartpkg "interview/internal/cart"
+	"interview/internal/checkout"
+	"interview/internal/order"
+	"interview/internal/payment"
+	productpkg "interview/internal/product"
+	"interview/internal/risk"
+	"interview/internal/vat"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckout(t *testing.T) {
+	ts := setupTest(t)
+	ts.clearDatabase(t)
+	provider := payment.NewFake()
+	ts.handler.SetPayments(provider, "https://shop.test/")
+	ts.router.POST("/checkout", ts.handler.Checkout)
+	ts.router.GET("/checkout", ts.handler.ShowCheckout)
+	ts.router.POST("/checkout/address", ts.handler.SetCheckoutAddress)
+	ts.router.POST("/checkout/shipping", ts.handler.SetCheckoutShipping)
+	ts.router.POST("/checkout/payment", ts.handler.StartPayment)
+	ts.router.POST("/checkout/confirm", ts.handler.ConfirmCheckout)
+	ts.router.GET("/checkout/return", ts.handler.PaymentReturn)
+	ts.router.GET("/checkout/cancel", ts.handler.PaymentCancel)
+	ts.router.POST("/checkout/cancel", ts.handler.CancelCheckout)
+	ts.router.POST("/payments/webhook", ts.handler.PaymentWebhook)
+
+	berlin := url.Values{"name": {"Jane Doe"}, "line1": {"Main St 1"}, "postal_code": {"10115"}, "city": {"Berlin"}, "country": {"de"}}
+
+	// startCheckout fills the cart of a new session and starts its checkout
+	startCheckout := func(t *testing.T) *http.Cookie {
+		t.Helper()
+		cookie := ts.createSession(t)
+		w := ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"watch"}, "quantity": {"1"}}, cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+		w = ts.makeRequest(t, http.MethodPost, "/checkout", nil, cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+		require.Equal(t, "/checkout", w.Header().Get("Location"))
+		return cookie
+	}
+	// withVATID returns the Berlin address with a VAT ID
+	withVATID := func(vatID string) url.Values {
+		values := url.Values{"vat_id": {vatID}}
+		for key, value := range berlin {
+			values[key] = value
+		}
+		return values
+	}
+	// page returns the checkout page
+	page := func(t *testing.T, cookie *http.Cookie) string {
+		t.Helper()
+		w := ts.makeRequest(t, http.MethodGet, "/checkout", nil, cookie)
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+	// startPayment goes through the address and shipping steps and starts the payment, returning its ID
+	startPayment := func(t *testing.T, cookie *http.Cookie) string {
+		t.Helper()
+		require.Equal(t, http.StatusFound, ts.makeRequest(t, http.MethodPost, "/checkout/address", berlin, cookie).Code)
+		require.Equal(t, http.StatusFound, ts.makeRequest(t, http.MethodPost, "/checkout/shipping", url.Values{"method": {"standard"}}, cookie).Code)
+		w := ts.makeRequest(t, http.MethodPost, "/checkout/payment", nil, cookie)
+		require.Equal(t, http.StatusSeeOther, w.Code)
+		require.Equal(t, "https://shop.test/checkout/return", w.Header().Get("Location"))
+
+		var p payment.Payment
+		require.NoError(t, ts.db.Last(&p).Error)
+		return p.ExternalID
+	}
+	// checkoutOf returns the checkout session and cart the payment was started for
+	checkoutOf := func(t *testing.T, paymentID string) (checkout.Session, cartpkg.Cart) {
+		t.Helper()
+		var p payment.Payment
+		require.NoError(t, ts.db.Where("external_id = ?", paymentID).First(&p).Error)
+		var s checkout.Session
+		require.NoError(t, ts.db.Where("cart_id = ?", p.CartID).First(&s).Error)
+		var c cartpkg.Cart
+		require.NoError(t, ts.db.First(&c, p.CartID).Error)
+		return s, c
+	}
+	// webhook sends a notification about the payment and returns the response status
+	webhook := func(t *testing.T, eventType, paymentID string) int {
+		t.Helper()
+		body := fmt.Sprintf(`{"type": %q, "payment_id": %q}`, eventType, paymentID)
+		req := httptest.NewRequest(http.MethodPost, "/payments/webhook", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		ts.router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("Cart Page Offers Checkout", func(t *testing.T) {
+		cookie := ts.createSession(t)
+		ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"watch"}, "quantity": {"1"}}, cookie)
+		w := ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.Contains(t, w.Body.String(), `action="/checkout"`)
+	})
+
+	t.Run("Empty Cart Is Not Checked Out", func(t *testing.T) {
+		cookie := ts.createSession(t)
+		w := ts.makeRequest(t, http.MethodPost, "/checkout", nil, cookie)
+		assert.Equal(t, "/", w.Header().Get("Location"))
+		w = ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.Contains(t, w.Body.String(), "Your cart is empty")
+	})
+
+	t.Run("Steps Can't Be Skipped", func(t *testing.T) {
+		cookie := startCheckout(t)
+		assert.Contains(t, page(t, cookie), `action="/checkout/address"`)
+		ts.makeRequest(t, http.MethodPost, "/checkout/payment", nil, cookie)
+		assert.Contains(t, page(t, cookie), "Please complete the previous checkout steps first")
+	})
+
+	t.Run("Invalid Address Is Rejected", func(t *testing.T) {
+		cookie := startCheckout(t)
+		incomplete := url.Values{"name": {"Jane Doe"}, "line1": {"Main St 1"}, "postal_code": {"1"}, "country": {"DE"}}
+		ts.makeRequest(t, http.MethodPost, "/checkout/address", incomplete, cookie)
+		body := page(t, cookie)
+		assert.Contains(t, body, "Please enter a complete address")
+		assert.Contains(t, body, `action="/checkout/address"`)
+
+		ts.makeRequest(t, http.MethodPost, "/checkout/address", url.Values{"address_id": {"9999"}}, cookie)
+		assert.Contains(t, page(t, cookie), "Address not found")
+	})
+
+	t.Run("Resumes Where The Customer Left Off", func(t *testing.T) {
+		cookie := startCheckout(t)
+		ts.makeRequest(t, http.MethodPost, "/checkout/address", berlin, cookie)
+		body := page(t, cookie)
+		assert.Contains(t, body, `action="/checkout/shipping"`)
+		assert.Contains(t, body, `href="/checkout?step=address"`)
+
+		// Starting the checkout again keeps its progress
+		var before, after int64
+		require.NoError(t, ts.db.Model(&checkout.Session{}).Count(&before).Error)
+		ts.makeRequest(t, http.MethodPost, "/checkout", nil, cookie)
+		assert.Contains(t, page(t, cookie), `action="/checkout/shipping"`)
+		require.NoError(t, ts.db.Model(&checkout.Session{}).Count(&after).Error)
+		assert.Equal(t, before, after)
+
+		// Going back shows the address book
+		w := ts.makeRequest(t, http.MethodGet, "/checkout?step=address", nil, cookie)
+		assert.Contains(t, w.Body.String(), "Jane Doe, Main St 1, 10115 Berlin, DE")
+		w = ts.makeRequest(t, http.MethodGet, "/checkout?step=confirm", nil, cookie)
+		assert.Contains(t, w.Body.String(), `action="/checkout/shipping"`)
+	})
+
+	t.Run("Pays And Confirms Once", func(t *testing.T) {
+		cookie := startCheckout(t)
+		id := startPayment(t, cookie)
+
+		// Submitting the payment step again resumes the payment
+		w := ts.makeRequest(t, http.MethodPost, "/checkout/payment", nil, cookie)
+		require.Equal(t, http.StatusSeeOther, w.Code)
+		var last payment.Payment
+		require.NoError(t, ts.db.Last(&last).Error)
+		assert.Equal(t, id, last.ExternalID)
+
+		w = ts.makeRequest(t, http.MethodGet, "/checkout/return", nil, cookie)
+		assert.Equal(t, "/checkout", w.Header().Get("Location"))
+		body := page(t, cookie)
+		assert.Contains(t, body, `action="/checkout/confirm"`)
+		assert.Contains(t, body, "Jane Doe, Main St 1, 10115 Berlin, DE")
+
+		for i := 0; i < 2; i++ {
+			w = ts.makeRequest(t, http.MethodPost, "/checkout/confirm", nil, cookie)
+			require.Equal(t, http.StatusFound, w.Code)
+			s, c := checkoutOf(t, id)
+			assert.Equal(t, fmt.Sprintf("/orders/%d", c.ID), w.Header().Get("Location"))
+			assert.Equal(t, cartpkg.StatusClosed, c.Status)
+			assert.Equal(t, checkout.StatusCompleted, s.Status)
+		}
+		require.NoError(t, provider.Refund(context.Background(), id, "refund-test"), "the payment was captured")
+	})
+
+	t.Run("Orders Keep The Chosen Addresses", func(t *testing.T) {
+		cookie := startCheckout(t)
+		require.Equal(t, http.StatusFound, ts.makeRequest(t, http.MethodPost, "/checkout/address", berlin, cookie).Code)
+		var billing address.Address
+		require.NoError(t, ts.db.Last(&billing).Error)
+		munich := url.Values{"name": {"Jane Doe"}, "line1": {"Office St 2"}, "postal_code": {"80331"}, "city": {"Munich"},
+			"country": {"DE"}, "billing_address_id": {strconv.Itoa(int(billing.ID))}}
+		require.Equal(t, http.StatusFound, ts.makeRequest(t, http.MethodPost, "/checkout/address", munich, cookie).Code)
+		require.Equal(t, http.StatusFound, ts.makeRequest(t, http.MethodPost, "/checkout/shipping", url.Values{"method": {"standard"}}, cookie).Code)
+		require.Equal(t, http.StatusSeeOther, ts.makeRequest(t, http.MethodPost, "/checkout/payment", nil, cookie).Code)
+		var p payment.Payment
+		require.NoError(t, ts.db.Last(&p).Error)
+		ts.makeRequest(t, http.MethodGet, "/checkout/return", nil, cookie)
+		require.Equal(t, http.StatusFound, ts.makeRequest(t, http.MethodPost, "/checkout/confirm", nil, cookie).Code)
+
+		// Changing the address book afterwards doesn't change where the order went
+		require.NoError(t, ts.db.Model(&address.Address{}).Where("id = ?", billing.ID).Update("city", "Hamburg").Error)
+		require.NoError(t, ts.db.Where("city = ?", "Munich").Delete(&address.Address{}).Error)
+		var o order.Order
+		require.NoError(t, ts.db.Where("cart_id = ?", p.CartID).First(&o).Error)
+		assert.Equal(t, order.Address{Name: "Jane Doe", Line1: "Office St 2", City: "Munich", PostalCode: "80331", Country: "DE"}, o.ShippingAddress)
+		assert.Equal(t, "Berlin", o.BillingAddress.City)
+		require.NoError(t, provider.Refund(context.Background(), p.ExternalID, "refund-test"), "the payment was captured")
+	})
+
+	t.Run("Changed Cart Is Paid Again", func(t *testing.T) {
+		cookie := startCheckout(t)
+		id := startPayment(t, cookie)
+		ts.makeRequest(t, http.MethodGet, "/checkout/return", nil, cookie)
+		// The cart is locked during the checkout, so change it behind the checkout's back
+		_, c := checkoutOf(t, id)
+		require.NoError(t, ts.db.Model(&c).Update("version", c.Version+1).Error)
+
+		ts.makeRequest(t, http.MethodPost, "/checkout/confirm", nil, cookie)
+		body := page(t, cookie)
+		assert.Contains(t, body, "Your cart changed during the payment, please pay again")
+		assert.Contains(t, body, `action="/checkout/payment"`)
+		assert.ErrorIs(t, provider.Refund(context.Background(), id, "refund-test"), payment.ErrNotCaptured)
+		_, c = checkoutOf(t, id)
+		assert.Equal(t, cartpkg.StatusOpen, c.Status)
+	})
+
+	t.Run("Cart Is Locked Until The Checkout Is Cancelled", func(t *testing.T) {
+		cookie := startCheckout(t)
+		w := ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.Contains(t, w.Body.String(), "Your cart is being checked out")
+		assert.Contains(t, w.Body.String(), `action="/checkout/cancel"`)
+
+		ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"shoe"}, "quantity": {"1"}}, cookie)
+		w = ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.Contains(t, w.Body.String(), "This cart is being checked out")
+		assert.NotContains(t, w.Body.String(), "Product: shoe")
+
+		var s checkout.Session
+		require.NoError(t, ts.db.Last(&s).Error)
+		var item cartpkg.CartItem
+		require.NoError(t, ts.db.Where("cart_id = ?", s.CartID).First(&item).Error)
+		ts.makeRequest(t, http.MethodPost, "/remove-item", url.Values{"cart_item_id": {fmt.Sprint(item.ID)}}, cookie)
+		w = ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.Contains(t, w.Body.String(), "This cart is being checked out")
+
+		w = ts.makeRequest(t, http.MethodPost, "/checkout/cancel", nil, cookie)
+		assert.Equal(t, "/", w.Header().Get("Location"))
+		w = ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.Contains(t, w.Body.String(), "The checkout was cancelled")
+		assert.Contains(t, w.Body.String(), `action="/checkout"`)
+
+		w = ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"shoe"}, "quantity": {"1"}}, cookie)
+		assert.Equal(t, "/", w.Header().Get("Location"))
+		w = ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.NotContains(t, w.Body.String(), "This cart is being checked out")
+		assert.Contains(t, w.Body.String(), "Product: shoe")
+
+		w = ts.makeRequest(t, http.MethodGet, "/checkout", nil, cookie)
+		assert.Equal(t, "/", w.Header().Get("Location"))
+	})
+
+	t.Run("Expired Checkout Unlocks The Cart", func(t *testing.T) {
+		cookie := startCheckout(t)
+		id := startPayment(t, cookie)
+		s, _ := checkoutOf(t, id)
+		require.NoError(t, ts.db.Model(&s).Update("expires_at", time.Now().Add(-time.Minute)).Error)
+
+		ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"shoe"}, "quantity": {"1"}}, cookie)
+		w := ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.NotContains(t, w.Body.String(), "This cart is being checked out")
+		assert.Contains(t, w.Body.String(), "Product: shoe")
+
+		ts.makeRequest(t, http.MethodGet, "/checkout", nil, cookie)
+		w = ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.Contains(t, w.Body.String(), "Your checkout expired, please check out again")
+
+		// Checking out again keeps the choices but starts the payment again
+		ts.makeRequest(t, http.MethodPost, "/checkout", nil, cookie)
+		assert.Contains(t, page(t, cookie), `action="/checkout/payment"`)
+		s, _ = checkoutOf(t, id)
+		assert.Nil(t, s.PaymentID)
+		assert.True(t, s.Active(time.Now()))
+	})
+
+	t.Run("Declined Payment Is Not Captured", func(t *testing.T) {
+		cookie := startCheckout(t)
+		id := startPayment(t, cookie)
+		provider.Decline(id)
+		ts.makeRequest(t, http.MethodGet, "/checkout/return", nil, cookie)
+		ts.makeRequest(t, http.MethodPost, "/checkout/confirm", nil, cookie)
+		body := page(t, cookie)
+		assert.Contains(t, body, "The payment was not approved")
+		assert.Contains(t, body, `action="/checkout/payment"`)
+	})
+
+	t.Run("Cancelled Payment Returns To The Payment Step", func(t *testing.T) {
+		cookie := startCheckout(t)
+		id := startPayment(t, cookie)
+		ts.makeRequest(t, http.MethodGet, "/checkout/cancel", nil, cookie)
+		body := page(t, cookie)
+		assert.Contains(t, body, "The payment was cancelled")
+		assert.Contains(t, body, `action="/checkout/payment"`)
+		s, _ := checkoutOf(t, id)
+		assert.Nil(t, s.PaymentID)
+	})
+
+	t.Run("Webhook Resumes And Completes Checkouts", func(t *testing.T) {
+		cookie := startCheckout(t)
+		id := startPayment(t, cookie)
+		assert.Equal(t, http.StatusBadRequest, webhook(t, payment.EventCaptured, id), "the payment wasn't captured yet")
+
+		assert.Equal(t, http.StatusOK, webhook(t, payment.EventApproved, id))
+		assert.Contains(t, page(t, cookie), `action="/checkout/confirm"`)
+
+		require.NoError(t, provider.Capture(context.Background(), id))
+		assert.Equal(t, http.StatusOK, webhook(t, payment.EventCaptured, id))
+		s, c := checkoutOf(t, id)
+		assert.Equal(t, cartpkg.StatusClosed, c.Status)
+		assert.Equal(t, checkout.StatusCompleted, s.Status)
+	})
+
+	t.Run("Webhook Rejects Unknown Payments", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, webhook(t, payment.EventCaptured, "fake_99"))
+		assert.Equal(t, http.StatusOK, webhook(t, "refunded", "fake_1"))
+	})
+
+	t.Run("Risk Checks Hold Payments For Review", func(t *testing.T) {
+		checker := &stubRisk{decision: risk.Decision{Action: risk.ActionReview, Reason: "new customer"}}
+		ts.handler.SetRiskChecker(checker, "X-Country")
+		defer ts.handler.SetRiskChecker(nil, "")
+		admin := newRiskAdmin(ts, provider)
+
+		cookie := startCheckout(t)
+		id := startPayment(t, cookie)
+		ts.makeRequest(t, http.MethodGet, "/checkout/return", nil, cookie)
+		w := ts.makeRequest(t, http.MethodPost, "/checkout/confirm", nil, cookie)
+		assert.Equal(t, "/", w.Header().Get("Location"))
+		assert.Equal(t, "DE", checker.last.ShippingCountry)
+		assert.Equal(t, 40.0, checker.last.Amount)
+
+		w = ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.Contains(t, w.Body.String(), "Thank you! Your order is being reviewed")
+		assert.Contains(t, w.Body.String(), "Your order is being reviewed before the payment is taken")
+		ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"shoe"}, "quantity": {"1"}}, cookie)
+		w = ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.Contains(t, w.Body.String(), "This cart is being checked out")
+		assert.ErrorIs(t, provider.Refund(context.Background(), id, "refund-test"), payment.ErrNotCaptured)
+
+		var held []api.HeldPaymentResponse
+		w = admin(http.MethodGet, "/admin/payments/held")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &held))
+		require.Len(t, held, 1)
+		assert.Equal(t, "new customer", held[0].Reason)
+
+		w = admin(http.MethodPost, fmt.Sprintf("/admin/payments/%d/approve", held[0].ID))
+		require.Equal(t, http.StatusOK, w.Code)
+		s, c := checkoutOf(t, id)
+		assert.Equal(t, cartpkg.StatusClosed, c.Status)
+		assert.Equal(t, checkout.StatusCompleted, s.Status)
+		require.NoError(t, provider.Refund(context.Background(), id, "refund-test"), "the payment was captured")
+		assert.Equal(t, http.StatusConflict, admin(http.MethodPost, fmt.Sprintf("/admin/payments/%d/approve", held[0].ID)).Code)
+	})
+
+	t.Run("Staff Reject Held Payments", func(t *testing.T) {
+		ts.handler.SetRiskChecker(&stubRisk{decision: risk.Decision{Action: risk.ActionReview}}, "")
+		defer ts.handler.SetRiskChecker(nil, "")
+		admin := newRiskAdmin(ts, provider)
+
+		cookie := startCheckout(t)
+		id := startPayment(t, cookie)
+		ts.makeRequest(t, http.MethodGet, "/checkout/return", nil, cookie)
+		ts.makeRequest(t, http.MethodPost, "/checkout/confirm", nil, cookie)
+
+		var p payment.Payment
+		require.NoError(t, ts.db.Where("external_id = ?", id).First(&p).Error)
+		assert.Equal(t, payment.StatusHeld, p.Status)
+		w := admin(http.MethodPost, fmt.Sprintf("/admin/payments/%d/reject", p.ID))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, ts.db.First(&p, p.ID).Error)
+		assert.Equal(t, payment.StatusBlocked, p.Status)
+
+		s, c := checkoutOf(t, id)
+		assert.Equal(t, checkout.StatusCancelled, s.Status)
+		assert.Equal(t, cartpkg.StatusOpen, c.Status)
+		w = ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"shoe"}, "quantity": {"1"}}, cookie)
+		assert.Equal(t, "/", w.Header().Get("Location"))
+		w = ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.Contains(t, w.Body.String(), "Product: shoe")
+	})
+
+	t.Run("Approved Payments Grant Limited Downloads", func(t *testing.T) {
+		ts.handler.SetRiskChecker(&stubRisk{decision: risk.Decision{Action: risk.ActionReview}}, "")
+		defer ts.handler.SetRiskChecker(nil, "")
+		admin := newRiskAdmin(ts, provider)
+		r := ts.handler.GetRepo()
+		r.SetDownloadLimits(3, time.Hour)
+		defer r.SetDownloadLimits(0, 0)
+		watch, err := r.UpsertProduct("watch", 40)
+		require.NoError(t, err)
+		require.NoError(t, r.SetProductFile(watch.ID, "products/watch/manual.pdf"))
+		require.NoError(t, r.SetProductType(watch.ID, productpkg.TypeDigital))
+		defer func() { require.NoError(t, r.SetProductType(watch.ID, productpkg.TypePhysical)) }()
+
+		cookie := startCheckout(t)
+		id := startPayment(t, cookie)
+		ts.makeRequest(t, http.MethodGet, "/checkout/return", nil, cookie)
+		ts.makeRequest(t, http.MethodPost, "/checkout/confirm", nil, cookie)
+		var p payment.Payment
+		require.NoError(t, ts.db.Where("external_id = ?", id).First(&p).Error)
+		require.Equal(t, http.StatusOK, admin(http.MethodPost, fmt.Sprintf("/admin/payments/%d/approve", p.ID)).Code)
+
+		downloads, err := r.ListDownloads(p.CartID)
+		require.NoError(t, err)
+		require.Len(t, downloads, 1)
+		assert.Equal(t, 3, downloads[0].MaxDownloads)
+		assert.NotNil(t, downloads[0].ExpiresAt)
+	})
+
+	t.Run("Risk Checks Block Payments", func(t *testing.T) {
+		ts.handler.SetRiskChecker(&stubRisk{decision: risk.Decision{Action: risk.ActionBlock, Reason: "stolen card"}}, "")
+		defer ts.handler.SetRiskChecker(nil, "")
+
+		cookie := startCheckout(t)
+		id := startPayment(t, cookie)
+		ts.makeRequest(t, http.MethodGet, "/checkout/return", nil, cookie)
+		ts.makeRequest(t, http.MethodPost, "/checkout/confirm", nil, cookie)
+		body := page(t, cookie)
+		assert.Contains(t, body, "We can&#39;t accept this payment, please contact us")
+		assert.Contains(t, body, `action="/checkout/payment"`)
+
+		var p payment.Payment
+		require.NoError(t, ts.db.Where("external_id = ?", id).First(&p).Error)
+		assert.Equal(t, payment.StatusBlocked, p.Status)
+		assert.Equal(t, "stolen card", p.RiskReason)
+		assert.ErrorIs(t, provider.Refund(context.Background(), id, "refund-test"), payment.ErrNotCaptured)
+	})
+
+	t.Run("Valid VAT IDs Zero-Rate Cross-Border Orders", func(t *testing.T) {
+		validator := &stubVAT{results: map[string]vat.Result{
+			"DE123456789": {Valid: true, Name: "Doe GmbH", ConsultationNumber: "WAPIAAAAZ1", CheckedAt: time.Now()},
+		}}
+		ts.handler.SetVATValidator(validator, "FR40303265045")
+		defer ts.handler.SetVATValidator(nil, "")
+
+		cookie := startCheckout(t)
+		assert.Contains(t, page(t, cookie), `name="vat_id"`)
+		business := withVATID("de 123.456.789")
+		w := ts.makeRequest(t, http.MethodPost, "/checkout/address", business, cookie)
+		require.Equal(t, "/checkout", w.Header().Get("Location"))
+		assert.Contains(t, page(t, cookie), `action="/checkout/shipping"`)
+		assert.Equal(t, "DE123456789", validator.last)
+
+		ts.makeRequest(t, http.MethodPost, "/checkout/shipping", url.Values{"method": {"standard"}}, cookie)
+		ts.makeRequest(t, http.MethodPost, "/checkout/payment", nil, cookie)
+		var p payment.Payment
+		require.NoError(t, ts.db.Last(&p).Error)
+		ts.makeRequest(t, http.MethodGet, "/checkout/return", nil, cookie)
+		assert.Contains(t, page(t, cookie), "DE123456789 (Reverse charge)")
+
+		s, c := checkoutOf(t, p.ExternalID)
+		assert.Equal(t, "DE123456789", s.VATID)
+		assert.True(t, c.TaxExempt)
+		ts.makeRequest(t, http.MethodPost, "/checkout/confirm", nil, cookie)
+
+		var o order.Order
+		require.NoError(t, ts.db.Preload("VATEvidence").Where("cart_id = ?", c.ID).First(&o).Error)
+		require.NotNil(t, o.VATEvidence)
+		assert.Equal(t, "DE123456789", o.VATEvidence.VATID)
+		assert.Equal(t, "Doe GmbH", o.VATEvidence.Name)
+		assert.Equal(t, "WAPIAAAAZ1", o.VATEvidence.ConsultationNumber)
+		assert.True(t, o.VATEvidence.Exempt)
+	})
+
+	t.Run("VAT IDs Of The Shop's Country Are Charged Tax", func(t *testing.T) {
+		ts.handler.SetVATValidator(&stubVAT{results: map[string]vat.Result{"DE123456789": {Valid: true}}}, "DE811569869")
+		defer ts.handler.SetVATValidator(nil, "")
+
+		cookie := startCheckout(t)
+		business := withVATID("DE123456789")
+		ts.makeRequest(t, http.MethodPost, "/checkout/address", business, cookie)
+		assert.Contains(t, page(t, cookie), `action="/checkout/shipping"`)
+
+		var evidence vat.Evidence
+		require.NoError(t, ts.db.Last(&evidence).Error)
+		assert.True(t, evidence.Valid)
+		assert.False(t, evidence.Exempt)
+		var c cartpkg.Cart
+		require.NoError(t, ts.db.First(&c, evidence.CartID).Error)
+		assert.False(t, c.TaxExempt)
+	})
+
+	t.Run("VAT IDs Are Validated", func(t *testing.T) {
+		validator := &stubVAT{results: map[string]vat.Result{"DE999999999": {Valid: false}}}
+		ts.handler.SetVATValidator(validator, "FR40303265045")
+		defer ts.handler.SetVATValidator(nil, "")
+
+		tests := []struct {
+			name  string
+			vatID string
+			want  string
+		}{
+			{name: "Malformed", vatID: "US123", want: "Please enter a valid VAT ID"},
+			{name: "Other Country", vatID: "FR40303265045", want: "The VAT ID must be from the country the order ships to"},
+			{name: "Invalid", vatID: "DE999999999", want: "This VAT ID isn&#39;t valid"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				cookie := startCheckout(t)
+				business := withVATID(tt.vatID)
+				ts.makeRequest(t, http.MethodPost, "/checkout/address", business, cookie)
+				body := page(t, cookie)
+				assert.Contains(t, body, tt.want)
+				assert.Contains(t, body, `action="/checkout/address"`)
+			})
+		}
+	})
+
+	t.Run("Unavailable VAT Service Charges Tax", func(t *testing.T) {
+		ts.handler.SetVATValidator(&stubVAT{err: vat.ErrUnavailable}, "FR40303265045")
+		defer ts.handler.SetVATValidator(nil, "")
+
+		cookie := startCheckout(t)
+		business := withVATID("DE123456789")
+		ts.makeRequest(t, http.MethodPost, "/checkout/address", business, cookie)
+		body := page(t, cookie)
+		assert.Contains(t, body, "Your VAT ID couldn&#39;t be checked right now, so tax is charged")
+		assert.Contains(t, body, `action="/checkout/shipping"`)
+
+		var s checkout.Session
+		require.NoError(t, ts.db.Last(&s).Error)
+		assert.Equal(t, "DE123456789", s.VATID)
+		var c cartpkg.Cart
+		require.NoError(t, ts.db.First(&c, s.CartID).Error)
+		assert.False(t, c.TaxExempt)
+	})
+}
+
+// stubVAT validates VAT IDs with fixed results, or fails with err, and remembers the last ID
+type stubVAT struct {
+	results map[string]vat.Result
+	err     error
+	last    string
+}
+
+func (s *stubVAT) Validate(_ context.Context, country, number string) (vat.Result, error) {
+	s.last = country + number
+	if s.err != nil {
+		return vat.Result{}, s.err
+	}
+	return s.results[country+number], nil
+}
+
+// stubRisk takes the same decision on every checkout and remembers the last one
+type stubRisk struct {
+	decision risk.Decision
+	last     risk.Checkout
+}
+
+func (s *stubRisk) Check(_ context.Context, checkout risk.Checkout) (risk.Decision, error) {
+	s.last = checkout
+	return s.decision, nil
+}
+
+// newRiskAdmin serves the admin endpoints reviewing held payments and returns a function making
+// authenticated requests to them
+func newRiskAdmin(ts *testSetup, provider payment.Provider) func(method, path string) *httptest.ResponseRecorder {
+	admin := api.NewAdminHandler(ts.db, nil, time.Hour)
+	admin.SetRepository(ts.handler.GetRepo())
+	admin.SetPayments(provider)
+	router := gin.New()
+	admin.RegisterRoutes(router, gin.Accounts{"admin": "secret"})
+	return func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+}
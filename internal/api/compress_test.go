@@ -0,0 +1,69 @@
+package api_test
+
+import (
+	"compress/gzip"
+	"interview/internal/api"
+	"interview/internal/static"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(api.Compress(gzip.DefaultCompression))
+	body := strings.Repeat("cart ", 100)
+	router.GET("/json", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"body": body})
+	})
+	router.GET("/image", func(c *gin.Context) {
+		c.Data(http.StatusOK, "image/png", []byte(body))
+	})
+	router.GET("/empty", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	assets := static.Default()
+	router.GET("/static/*file", gin.WrapH(http.StripPrefix("/static", assets)))
+
+	tests := []struct {
+		name           string
+		path           string
+		acceptEncoding string
+		compressed     bool
+	}{
+		{"JSON", "/json", "gzip, deflate, br", true},
+		{"Static CSS", assets.URL("css/app.css"), "gzip", true},
+		{"Gzip Not Accepted", "/json", "br", false},
+		{"Gzip Refused", "/json", "gzip;q=0, br", false},
+		{"Image", "/image", "gzip", false},
+		{"No Content", "/empty", "gzip", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Contains(t, w.Header().Values("Vary"), "Accept-Encoding")
+			if !tt.compressed {
+				assert.Empty(t, w.Header().Get("Content-Encoding"))
+				return
+			}
+			assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+			assert.Empty(t, w.Header().Get("Content-Length"))
+			gz, err := gzip.NewReader(w.Body)
+			require.NoError(t, err)
+			decoded, err := io.ReadAll(gz)
+			require.NoError(t, err)
+			assert.NotEmpty(t, decoded)
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package api_test
+
+import (
+	"interview/internal/api"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupCORSRouter creates a router with the CORS middleware and a single JSON route
+func setupCORSRouter(opts api.CORSOptions) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(api.CORS(opts))
+	router.GET("/api/v1/cart", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{})
+	})
+	return router
+}
+
+func TestCORS(t *testing.T) {
+	opts := api.CORSOptions{
+		AllowedOrigins:   []string{"https://shop.example.com"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+
+	tests := []struct {
+		name            string
+		opts            api.CORSOptions
+		method          string
+		origin          string
+		requestMethod   string
+		expectedStatus  int
+		expectedOrigin  string
+		expectedMethods string
+	}{
+		{
+			name:           "Same Origin Request",
+			opts:           opts,
+			method:         http.MethodGet,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Allowed Origin",
+			opts:           opts,
+			method:         http.MethodGet,
+			origin:         "https://shop.example.com",
+			expectedStatus: http.StatusOK,
+			expectedOrigin: "https://shop.example.com",
+		},
+		{
+			name:           "Disallowed Origin",
+			opts:           opts,
+			method:         http.MethodGet,
+			origin:         "https://evil.example.com",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:            "Preflight From Allowed Origin",
+			opts:            opts,
+			method:          http.MethodOptions,
+			origin:          "https://shop.example.com",
+			requestMethod:   http.MethodPost,
+			expectedStatus:  http.StatusNoContent,
+			expectedOrigin:  "https://shop.example.com",
+			expectedMethods: "GET, POST",
+		},
+		{
+			name:           "Preflight From Disallowed Origin",
+			opts:           opts,
+			method:         http.MethodOptions,
+			origin:         "https://evil.example.com",
+			requestMethod:  http.MethodPost,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "Wildcard Without Credentials",
+			opts:           api.CORSOptions{AllowedOrigins: []string{"*"}},
+			method:         http.MethodGet,
+			origin:         "https://any.example.com",
+			expectedStatus: http.StatusOK,
+			expectedOrigin: "*",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupCORSRouter(tt.opts)
+
+			req := httptest.NewRequest(tt.method, "/api/v1/cart", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.requestMethod != "" {
+				req.Header.Set("Access-Control-Request-Method", tt.requestMethod)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Equal(t, tt.expectedOrigin, w.Header().Get("Access-Control-Allow-Origin"))
+			assert.Equal(t, tt.expectedMethods, w.Header().Get("Access-Control-Allow-Methods"))
+			if tt.expectedOrigin != "" && tt.opts.AllowCredentials {
+				assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"interview/internal/cart"
+	"interview/internal/repo"
+	"interview/internal/user"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// maxUserAgentLength is the longest user agent recorded, longer ones are cut
+const maxUserAgentLength = 512
+
+type (
+	// SessionView represents a session the user is logged in to on the account page.
+	SessionView struct {
+		ID        uint
+		UserAgent string
+		FirstSeen string
+		LastSeen  string
+		// Current is the session showing the page
+		Current bool
+	}
+
+	// DeviceResponse is the JSON representation of the browser of a session.
+	DeviceResponse struct {
+		UserAgent   string     `json:"user_agent"`
+		IPHash      string     `json:"ip_hash"`
+		FirstSeenAt time.Time  `json:"first_seen_at"`
+		LastSeenAt  time.Time  `json:"last_seen_at"`
+		RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	}
+
+	// AdminCartResponse is the JSON representation of a cart in the admin area, with the session it
+	// belongs to and the browser of that session, omitted when none was recorded.
+	AdminCartResponse struct {
+		CartResponse
+		SessionID string          `json:"session_id"`
+		UserID    *uint           `json:"user_id,omitempty"`
+		CreatedAt time.Time       `json:"created_at"`
+		UpdatedAt time.Time       `json:"updated_at"`
+		Device    *DeviceResponse `json:"device,omitempty"`
+	}
+)
+
+// TrackDevices is middleware recording the user agent and the hashed IP address of every session, and
+// when it was first and last used. Sessions their user logged out from another session are logged out
+// before the request is handled.
+func (h *CartHandler) TrackDevices(c *gin.Context) {
+	path := c.Request.URL.Path
+	if strings.HasPrefix(path, "/static/") || strings.HasPrefix(path, "/media/") || strings.HasPrefix(path, "/api/") {
+		c.Next()
+		return
+	}
+	session := sessions.Default(c)
+	// Staff members viewing a customer's cart aren't one of the customer's devices
+	if _, impersonating := session.Get(impersonatorKey).(string); impersonating {
+		c.Next()
+		return
+	}
+	h.logOutRevoked(c, session)
+
+	c.Next()
+
+	// Pages create the session ID, so it is read once they ran
+	sessionID, _ := session.Get("session_id").(string)
+	if sessionID == "" {
+		return
+	}
+	userAgent := c.Request.UserAgent()
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	err := h.repoFor(c).TouchDevice(user.Device{
+		SessionID:  sessionID,
+		UserID:     sessionUserID(session),
+		UserAgent:  userAgent,
+		IPHash:     h.hashIP(c.ClientIP()),
+		LastSeenAt: time.Now(),
+	})
+	if err != nil {
+		log.Printf("Failed to record session device: %v", err)
+	}
+}
+
+// logOutRevoked logs the session out when its user revoked it from another session.
+func (h *CartHandler) logOutRevoked(c *gin.Context, session sessions.Session) {
+	userID, loggedIn := session.Get("user_id").(uint)
+	sessionID, _ := session.Get("session_id").(string)
+	if !loggedIn || sessionID == "" {
+		return
+	}
+	r := h.repoFor(c)
+	device, err := r.GetDevice(sessionID)
+	if err != nil {
+		if !errors.Is(err, repo.ErrDeviceNotFound) {
+			log.Printf("Failed to get session device: %v", err)
+		}
+		return
+	}
+	if device.RevokedAt == nil || device.UserID == nil || *device.UserID != userID {
+		return
+	}
+
+	session.Delete("user_id")
+	if err := session.Save(); err != nil {
+		log.Printf("Failed to save session: %v", err)
+		return
+	}
+	if err := r.ForgetDeviceUser(sessionID); err != nil {
+		log.Printf("Failed to log out revoked session: %v", err)
+	}
+}
+
+// SetSessionLimit limits how many sessions a user may be logged in to at once, 0 for no limit. Logging
+// in to one more logs out the session the user started first, whose open carts stay with its browser or
+// move to the session logging in as evictedCarts says. Sessions count while used within maxAge.
+func (h *AuthHandler) SetSessionLimit(max int, evictedCarts string, maxAge time.Duration) {
+	h.maxSessions = max
+	h.evictedCarts = evictedCarts
+	h.sessionMaxAge = maxAge
+}
+
+// limitSessions logs out the oldest sessions of the user logging in to the session sessionID while they
+// are logged in to more than the limit.
+func (h *AuthHandler) limitSessions(c *gin.Context, session sessions.Session, sessionID string, userID uint) {
+	if h.maxSessions == 0 {
+		return
+	}
+	r := h.repoFor(c)
+	now := time.Now()
+	revoked, err := r.RevokeOldestDevices(userID, sessionID, h.maxSessions-1, now.Add(-h.sessionMaxAge), now)
+	if err != nil {
+		log.Printf("Failed to limit sessions of user %d: %v", userID, err)
+		return
+	}
+	for _, d := range revoked {
+		if h.evictedCarts == user.EvictedCartsMove {
+			if err := r.MoveSessionCarts(d.SessionID, sessionID); err != nil {
+				log.Printf("Failed to move carts of logged out session %d: %v", d.ID, err)
+			}
+		}
+	}
+	if len(revoked) > 0 {
+		session.AddFlash("You were logged out of your oldest session", noticeFlash)
+	}
+}
+
+// hashIP returns the hash of the IP address keyed with the session secret, so addresses can't be
+// recovered by hashing every possible one.
+func (h *CartHandler) hashIP(ip string) string {
+	mac := hmac.New(sha256.New, []byte(h.config.SessionSecret))
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// sessionViews returns the sessions the user is logged in to that were used within the session lifetime,
+// for the account page.
+func (h *CartHandler) sessionViews(c *gin.Context, session sessions.Session, userID uint) []SessionView {
+	devices, err := h.repoFor(c).ListUserDevices(userID, time.Now().Add(-h.config.SessionMaxAge))
+	if err != nil {
+		log.Printf("Failed to list sessions of user %d: %v", userID, err)
+		return nil
+	}
+	current, _ := session.Get("session_id").(string)
+	views := make([]SessionView, len(devices))
+	for i, d := range devices {
+		views[i] = SessionView{
+			ID:        d.ID,
+			UserAgent: d.UserAgent,
+			FirstSeen: d.FirstSeenAt.UTC().Format("2006-01-02 15:04 MST"),
+			LastSeen:  d.LastSeenAt.UTC().Format("2006-01-02 15:04 MST"),
+			Current:   d.SessionID == current,
+		}
+	}
+	return views
+}
+
+// RevokeSession logs the logged-in user out of one of their sessions, identified by the "id" parameter,
+// with the next request of that session. Revoking the current session logs out right away.
+func (h *CartHandler) RevokeSession(c *gin.Context) {
+	session := sessions.Default(c)
+	u := h.accountUser(c, session)
+	if u == nil {
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.redirectToAccount(c, session, "Session not found")
+		return
+	}
+
+	r := h.repoFor(c)
+	err = r.RevokeDevice(u.ID, uint(id), time.Now())
+	if errors.Is(err, repo.ErrDeviceNotFound) {
+		h.redirectToAccount(c, session, "Session not found")
+		return
+	} else if err != nil {
+		log.Printf("Failed to revoke session %d: %v", id, err)
+		h.redirectToAccount(c, session, "Failed to log out the session")
+		return
+	}
+
+	current, _ := session.Get("session_id").(string)
+	if device, err := r.GetDevice(current); err == nil && device.ID == uint(id) {
+		h.logOutRevoked(c, session)
+		c.Redirect(http.StatusFound, "/")
+		return
+	}
+	h.redirectToAccount(c, session, "The session was logged out")
+}
+
+// redirectToAccount shows the account page again with the notice.
+func (h *CartHandler) redirectToAccount(c *gin.Context, session sessions.Session, notice string) {
+	session.AddFlash(notice, noticeFlash)
+	if err := session.Save(); err != nil {
+		log.Printf("Failed to save session: %v", err)
+	}
+	c.Redirect(http.StatusFound, "/account")
+}
+
+// ShowCart returns a cart with its items, the session it belongs to and the browser of that session.
+func (h *AdminHandler) ShowCart(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid cart ID")
+		return
+	}
+	r := h.repoFor(c)
+	found, err := r.GetCart(uint(id))
+	if errors.Is(err, cart.ErrCartNotFound) {
+		respondWithProblem(c, http.StatusNotFound, "cart not found")
+		return
+	} else if err != nil {
+		log.Printf("Failed to get cart %d: %v", id, err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to get cart")
+		return
+	}
+
+	resp := AdminCartResponse{
+		CartResponse: newCartResponse(found),
+		SessionID:    found.SessionID,
+		UserID:       found.UserID,
+		CreatedAt:    found.CreatedAt,
+		UpdatedAt:    found.UpdatedAt,
+	}
+	device, err := r.GetDevice(found.SessionID)
+	if err == nil {
+		resp.Device = &DeviceResponse{
+			UserAgent:   device.UserAgent,
+			IPHash:      device.IPHash,
+			FirstSeenAt: device.FirstSeenAt,
+			LastSeenAt:  device.LastSeenAt,
+			RevokedAt:   device.RevokedAt,
+		}
+	} else if !errors.Is(err, repo.ErrDeviceNotFound) {
+		log.Printf("Failed to get session device of cart %d: %v", id, err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to get cart")
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,62 @@
+package api_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"interview/internal/api"
+	"interview/internal/cart"
+	"interview/internal/repo"
+	"interview/internal/storage"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminCartView(t *testing.T) {
+	ts := setupTest(t)
+	ts.clearDatabase(t)
+	media := storage.NewLocal(t.TempDir(), "/media", []byte("test_secret"))
+	api.NewAdminHandler(ts.db, media, time.Hour).RegisterRoutes(ts.router, gin.Accounts{"admin": "secret"})
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		ts.router.ServeHTTP(w, req)
+		return w
+	}
+
+	cookie := ts.createSession(t)
+	req := httptest.NewRequest(http.MethodPost, "/add-item", strings.NewReader(url.Values{"product": {"shoe"}, "quantity": {"2"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) Firefox/130.0")
+	req.AddCookie(cookie)
+	ts.router.ServeHTTP(httptest.NewRecorder(), req)
+	carts, err := repo.NewRepository(ts.db).GetAllCarts()
+	require.NoError(t, err)
+	require.Len(t, carts, 1)
+
+	t.Run("Shows The Session Device", func(t *testing.T) {
+		w := get(fmt.Sprintf("/admin/carts/%d", carts[0].ID))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp api.AdminCartResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, carts[0].SessionID, resp.SessionID)
+		assert.Equal(t, cart.DefaultName, resp.Name)
+		require.Len(t, resp.Items, 1)
+		require.NotNil(t, resp.Device)
+		assert.Equal(t, "Mozilla/5.0 (X11; Linux x86_64) Firefox/130.0", resp.Device.UserAgent)
+		assert.Len(t, resp.Device.IPHash, 64)
+		assert.False(t, resp.Device.LastSeenAt.Before(resp.Device.FirstSeenAt))
+	})
+
+	t.Run("Unknown Cart", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, get("/admin/carts/999999").Code)
+	})
+}
@@ -0,0 +1,257 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"interview/internal/cart"
+	"interview/internal/download"
+	"interview/internal/i18n"
+	productpkg "interview/internal/product"
+	"log"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/csrf"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxFileSize is the largest file of a digital product accepted for upload
+	maxFileSize = 200 << 20
+	// downloadURLTTL is how long the storage URL a download redirects to stays valid
+	downloadURLTTL = 5 * time.Minute
+)
+
+// fileExtension matches the extensions kept in the storage keys of uploaded files
+var fileExtension = regexp.MustCompile(`^\.[a-z0-9]{1,10}$`)
+
+type (
+	// OrderData contains data to be rendered in the order confirmation template.
+	OrderData struct {
+		Error         string
+		Locale        string
+		CSRFFieldName template.HTML
+		// CartID is the ID of the checked out cart, 0 when it couldn't be shown
+		CartID    uint
+		CartItems []CartItemView
+		Total     string
+		// Downloads are the digital products of the order
+		Downloads []DownloadView
+	}
+
+	// DownloadView represents a digital product of an order for the view layer.
+	DownloadView struct {
+		Product string
+		URL     string
+		// Remaining is how many more downloads are allowed, -1 for any number
+		Remaining int
+		// ExpiresAt is when the link stops working, empty when it doesn't
+		ExpiresAt string
+	}
+
+	// ProductTypeRequest is the JSON body accepted by POST /admin/products/:id/type.
+	ProductTypeRequest struct {
+		Type string `json:"type" binding:"required"`
+	}
+)
+
+// SetDownloadLinks enables the download endpoint serving the digital products of checked out carts.
+func (h *CartHandler) SetDownloadLinks(links *download.Links) {
+	h.downloadLinks = links
+}
+
+// ShowOrder shows the checked out cart of the path with the download links of its digital products.
+// Only the session or user the cart belongs to can see it.
+func (h *CartHandler) ShowOrder(c *gin.Context) {
+	session := sessions.Default(c)
+	data := OrderData{Locale: detectLocale(c, session).String()}
+	data.CSRFFieldName = csrf.TemplateField(c.Request)
+
+	status := http.StatusOK
+	if order := h.sessionOrder(c, session); order == nil {
+		status, data.Error = http.StatusNotFound, "Order not found"
+	} else {
+		data.CartID = order.ID
+		data.CartItems = h.CreateCartItemViews(order.CartItems)
+		data.Total = h.currencies.Format(order.Total, sessionCurrency(session))
+		downloads, err := h.repoFor(c).ListDownloads(order.ID)
+		if err != nil {
+			log.Printf("Failed to list downloads: %v", err)
+			data.Error = "Failed to load downloads"
+		}
+		for _, d := range downloads {
+			view := DownloadView{Product: d.ProductName, Remaining: d.Remaining()}
+			if h.downloadLinks != nil {
+				view.URL = h.downloadLinks.URL(d.ID)
+			}
+			if d.ExpiresAt != nil {
+				view.ExpiresAt = d.ExpiresAt.UTC().Format("2006-01-02 15:04 MST")
+			}
+			data.Downloads = append(data.Downloads, view)
+		}
+	}
+
+	data.Error = i18n.T(data.Locale, data.Error)
+	c.Status(status)
+	if err := h.templateFor(c).ExecuteTemplate(c.Writer, "order.html", data); err != nil {
+		log.Printf("Failed to render template: %v", err)
+	}
+}
+
+// sessionOrder returns the checked out cart of the path when it belongs to the session or its user.
+func (h *CartHandler) sessionOrder(c *gin.Context, session sessions.Session) *cart.Cart {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil
+	}
+	order, err := h.repoFor(c).GetCart(uint(id))
+	if err != nil || order.Status != cart.StatusClosed {
+		return nil
+	}
+	sessionID, _ := session.Get("session_id").(string)
+	userID, loggedIn := session.Get("user_id").(uint)
+	if (sessionID != "" && order.SessionID == sessionID) || (loggedIn && order.UserID != nil && *order.UserID == userID) {
+		return order
+	}
+	return nil
+}
+
+// Download counts a download of the signed link of the path and redirects to the file of its product.
+func (h *CartHandler) Download(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || !h.downloadLinks.Verify(uint(id), c.Query("signature")) {
+		c.String(http.StatusNotFound, "Download not found")
+		return
+	}
+
+	d, err := h.repoFor(c).UseDownload(uint(id))
+	switch {
+	case errors.Is(err, productpkg.ErrDownloadNotFound):
+		c.String(http.StatusNotFound, "Download not found")
+		return
+	case errors.Is(err, productpkg.ErrDownloadExpired):
+		c.String(http.StatusGone, "This download link has expired")
+		return
+	case errors.Is(err, productpkg.ErrDownloadLimit):
+		c.String(http.StatusGone, "This file was downloaded as often as allowed")
+		return
+	case err != nil:
+		log.Printf("Failed to use download: %v", err)
+		c.String(http.StatusInternalServerError, "Failed to download the file")
+		return
+	}
+
+	p, err := h.repoFor(c).GetProduct(d.ProductID)
+	if err != nil || p.FileKey == "" || h.storage == nil {
+		c.String(http.StatusNotFound, "The file is not available")
+		return
+	}
+	url, err := h.storage.SignedURL(p.FileKey, downloadURLTTL)
+	if err != nil {
+		log.Printf("Failed to sign download URL: %v", err)
+		c.String(http.StatusInternalServerError, "Failed to download the file")
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Redirect(http.StatusFound, url)
+}
+
+// UploadProductFile stores the multipart "file" as the file customers of the digital product download.
+// Every upload gets a new storage key so links handed out before never serve a replaced file.
+func (h *AdminHandler) UploadProductFile(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid product ID")
+		return
+	}
+	product, err := h.repoFor(c).GetProduct(uint(id))
+	if err != nil {
+		respondWithProblem(c, http.StatusNotFound, "product not found")
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxFileSize+1<<20)
+	file, err := c.FormFile("file")
+	if err != nil {
+		respondWithInvalidField(c, "file", "is required")
+		return
+	}
+	if file.Size > maxFileSize {
+		respondWithProblem(c, http.StatusRequestEntityTooLarge, "file must not exceed 200 MB")
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "failed to read file")
+		return
+	}
+	defer f.Close()
+
+	version, err := generateSessionID()
+	if err != nil {
+		respondWithProblem(c, http.StatusInternalServerError, "failed to store file")
+		return
+	}
+	key := fmt.Sprintf("products/%d/%s/file", product.ID, version[:16])
+	if ext := strings.ToLower(filepath.Ext(file.Filename)); fileExtension.MatchString(ext) {
+		key += ext
+	}
+	contentType := file.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	ctx := c.Request.Context()
+	if err := h.storage.Put(ctx, key, f, file.Size, contentType); err != nil {
+		log.Printf("Failed to store file: %v", err)
+		respondWithProblem(c, http.StatusBadGateway, "failed to store file")
+		return
+	}
+	if err := h.repoFor(c).SetProductFile(product.ID, key); err != nil {
+		log.Printf("Failed to update product: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to update product")
+		return
+	}
+	if product.FileKey != "" {
+		if err := h.storage.Delete(ctx, product.FileKey); err != nil {
+			log.Printf("Failed to delete replaced file %s: %v", product.FileKey, err)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"id": product.ID, "file_key": key})
+}
+
+// UpdateProductType makes a product physical or digital. Digital products are downloaded after checkout
+// and need their file uploaded first.
+func (h *AdminHandler) UpdateProductType(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid product ID")
+		return
+	}
+	var req ProductTypeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondWithInvalidField(c, "type", "is required")
+		return
+	}
+
+	switch err := h.repoFor(c).SetProductType(uint(id), req.Type); {
+	case errors.Is(err, productpkg.ErrInvalidType):
+		respondWithProblem(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, productpkg.ErrNoFile):
+		respondWithProblem(c, http.StatusConflict, "upload the file of the product first")
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		respondWithProblem(c, http.StatusNotFound, "product not found")
+	case err != nil:
+		log.Printf("Failed to update product type: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to update product type")
+	default:
+		c.JSON(http.StatusOK, gin.H{"id": id, "type": req.Type})
+	}
+}
@@ -0,0 +1,133 @@
+package api_test
+
+import (
+	"bytes"
+	"fmt"
+	"interview/internal/api"
+	cartpkg "interview/internal/cart"
+	"interview/internal/download"
+	"interview/internal/repo"
+	"interview/internal/storage"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDigitalProducts(t *testing.T) {
+	ts := setupTest(t)
+	ts.clearDatabase(t)
+	media := storage.NewLocal(t.TempDir(), "/media", []byte("test_secret"))
+	api.NewAdminHandler(ts.db, media, time.Hour).RegisterRoutes(ts.router, gin.Accounts{"admin": "secret"})
+	ts.router.GET("/media/*key", gin.WrapH(http.StripPrefix("/media", media)))
+	links := download.NewLinks("http://localhost", []byte("test_secret"))
+	ts.handler.SetDownloadLinks(links)
+	ts.handler.SetStorage(media, time.Hour)
+	ts.handler.SetProductPrices(map[string]float64{"watch": 5, "shoe": 10})
+	ts.router.GET(download.PathPrefix+":id", ts.handler.Download)
+
+	cartRepo := repo.NewRepository(ts.db)
+	cartRepo.SetDownloadLimits(1, time.Hour)
+	watch, err := cartRepo.UpsertProduct("watch", 5)
+	require.NoError(t, err)
+	shoe, err := cartRepo.UpsertProduct("shoe", 10)
+	require.NoError(t, err)
+
+	// admin sends an authenticated admin request
+	admin := func(t *testing.T, path, contentType string, body io.Reader) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, path, body)
+		req.Header.Set("Content-Type", contentType)
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		ts.router.ServeHTTP(w, req)
+		return w
+	}
+	setType := func(t *testing.T, id uint, productType string) int {
+		t.Helper()
+		body := fmt.Sprintf(`{"type": %q}`, productType)
+		return admin(t, fmt.Sprintf("/admin/products/%d/type", id), "application/json", strings.NewReader(body)).Code
+	}
+	// linkPath returns the path of a link to the server
+	linkPath := func(t *testing.T, link string) string {
+		t.Helper()
+		u, err := url.Parse(link)
+		require.NoError(t, err)
+		return u.RequestURI()
+	}
+
+	t.Run("Digital Products Need A File", func(t *testing.T) {
+		assert.Equal(t, http.StatusConflict, setType(t, shoe.ID, "digital"))
+		assert.Equal(t, http.StatusBadRequest, setType(t, shoe.ID, "virtual"))
+		assert.Equal(t, http.StatusNotFound, setType(t, 9999, "physical"))
+	})
+
+	t.Run("Uploads The File", func(t *testing.T) {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		part, err := mw.CreateFormFile("file", "Manual.PDF")
+		require.NoError(t, err)
+		_, err = part.Write([]byte("%PDF-1.7 the manual"))
+		require.NoError(t, err)
+		require.NoError(t, mw.Close())
+
+		w := admin(t, fmt.Sprintf("/admin/products/%d/file", watch.ID), mw.FormDataContentType(), &body)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, http.StatusOK, setType(t, watch.ID, "digital"))
+
+		p, err := cartRepo.GetProduct(watch.ID)
+		require.NoError(t, err)
+		assert.True(t, p.Digital())
+		assert.True(t, strings.HasSuffix(p.FileKey, "/file.pdf"), p.FileKey)
+	})
+
+	cookie := ts.createSession(t)
+	w := ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"watch"}, "quantity": {"1"}}, cookie)
+	require.Equal(t, http.StatusFound, w.Code)
+	var order cartpkg.Cart
+	require.NoError(t, ts.db.First(&order).Error)
+	require.NoError(t, cartRepo.CloseCart(order.ID))
+	downloads, err := cartRepo.ListDownloads(order.ID)
+	require.NoError(t, err)
+	require.Len(t, downloads, 1)
+	orderPath := fmt.Sprintf("/orders/%d", order.ID)
+
+	t.Run("Order Page Shows The Download", func(t *testing.T) {
+		w := ts.makeRequest(t, http.MethodGet, orderPath, nil, cookie)
+		require.Equal(t, http.StatusOK, w.Code)
+		body := w.Body.String()
+		assert.Contains(t, body, "Download watch")
+		assert.Contains(t, body, strings.ReplaceAll(links.URL(downloads[0].ID), "&", "&amp;"))
+		assert.Contains(t, body, "1 downloads left")
+	})
+
+	t.Run("Order Page Is Private", func(t *testing.T) {
+		w := ts.makeRequest(t, http.MethodGet, orderPath, nil, ts.createSession(t))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.NotContains(t, w.Body.String(), "Download watch")
+	})
+
+	t.Run("Tampered Link Is Rejected", func(t *testing.T) {
+		w := ts.makeRequest(t, http.MethodGet, linkPath(t, links.URL(downloads[0].ID))+"0", nil, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Downloads The File Once", func(t *testing.T) {
+		w := ts.makeRequest(t, http.MethodGet, linkPath(t, links.URL(downloads[0].ID)), nil, nil)
+		require.Equal(t, http.StatusFound, w.Code)
+		w = ts.makeRequest(t, http.MethodGet, w.Header().Get("Location"), nil, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "%PDF-1.7 the manual", w.Body.String())
+
+		w = ts.makeRequest(t, http.MethodGet, linkPath(t, links.URL(downloads[0].ID)), nil, nil)
+		assert.Equal(t, http.StatusGone, w.Code)
+	})
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"interview/internal/auth"
+	"interview/internal/cart"
+	"interview/internal/checkout"
+	"interview/internal/payment"
+	"interview/internal/pricing"
+	productpkg "interview/internal/product"
+	"interview/internal/repo"
+	"interview/internal/service"
+	"log"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errorMappings lists the domain errors shown to users, with the status of the JSON response and the
+// message used by both the JSON responses and the flashes of the HTML pages. The first match wins.
+var errorMappings = []struct {
+	err     error
+	status  int
+	message string
+}{
+	{cart.ErrCartNotFound, http.StatusNotFound, "Cart not found"},
+	{cart.ErrCartClosed, http.StatusConflict, "This cart is no longer available"},
+	{cart.ErrCartLocked, http.StatusConflict, "This cart is being checked out"},
+	{cart.ErrInvalidName, http.StatusBadRequest, "Cart names must be between 1 and 64 characters"},
+	{cart.ErrNameTaken, http.StatusConflict, "You already have a cart with this name"},
+	{cart.ErrCartHasCredit, http.StatusConflict, "Carts holding gift card credit can't be deleted"},
+	{cart.ErrItemNotFound, http.StatusNotFound, "Item not found"},
+	{cart.ErrInvalidItemOrder, http.StatusConflict, "Your cart changed, please arrange its items again"},
+	{cart.ErrInvalidQuantity, http.StatusBadRequest, "Quantity must be a valid number greater than 0"},
+	{cart.ErrQuantityTooLarge, http.StatusBadRequest, "You can add at most 999 of a product"},
+	{cart.ErrInvalidInterval, http.StatusBadRequest, "Please choose an offered subscription interval"},
+	{cart.ErrInvalidMetadata, http.StatusBadRequest, "Metadata must have at most 50 keys of up to 40 characters with string, number or boolean values"},
+	{cart.ErrNothingToUndo, http.StatusConflict, "There is no recent change to undo"},
+	{cart.ErrCannotUndo, http.StatusConflict, "The latest change can't be undone"},
+	{cart.ErrSubscriptionNotFound, http.StatusNotFound, "Subscription not found"},
+	{cart.ErrSubscriptionCancelled, http.StatusConflict, "This subscription was cancelled"},
+	{service.ErrInvalidProduct, http.StatusBadRequest, "Invalid product selected"},
+	{service.ErrMissingCode, http.StatusBadRequest, "Please enter a gift card code"},
+	{pricing.ErrProductNotFound, http.StatusBadRequest, "Product not found"},
+	{productpkg.ErrOutOfStock, http.StatusConflict, "This product is out of stock"},
+	{service.ErrPricesUnavailable, http.StatusServiceUnavailable, "Prices are temporarily unavailable, please try again"},
+	{repo.ErrCircuitOpen, http.StatusServiceUnavailable, unavailableMessage},
+	{context.DeadlineExceeded, http.StatusServiceUnavailable, unavailableMessage},
+	{repo.ErrGiftCardNotFound, http.StatusNotFound, "Unknown gift card code"},
+	{repo.ErrGiftCardEmpty, http.StatusUnprocessableEntity, "This gift card has no balance left"},
+	{repo.ErrNothingToPay, http.StatusUnprocessableEntity, "Your cart has nothing left to pay"},
+	{service.ErrMissingReferralCode, http.StatusBadRequest, "Please enter a referral code"},
+	{repo.ErrReferralNotFound, http.StatusNotFound, "Unknown referral code"},
+	{repo.ErrSelfReferral, http.StatusUnprocessableEntity, "You can't use your own referral code"},
+	{repo.ErrNotNewCustomer, http.StatusUnprocessableEntity, "Referral codes are only for new customers"},
+	{repo.ErrReferralLoginRequired, http.StatusForbidden, "Please log in to use a referral code"},
+	{repo.ErrTOTPEnabled, http.StatusConflict, "Two-factor authentication is already enabled"},
+	{repo.ErrTOTPNotEnrolled, http.StatusConflict, "Set up two-factor authentication first"},
+	{repo.ErrResetTokenInvalid, http.StatusNotFound, "This password reset link is invalid or has expired"},
+	{auth.ErrPasswordTooShort, http.StatusBadRequest, "Passwords must have at least 8 characters"},
+	{auth.ErrPasswordTooLong, http.StatusBadRequest, "This password is too long"},
+	{repo.ErrEmailTaken, http.StatusConflict, "This email address is already used by another account"},
+	{checkout.ErrSessionNotFound, http.StatusNotFound, "Please check out from your cart"},
+	{checkout.ErrSessionExpired, http.StatusConflict, "Your checkout expired, please check out again"},
+	{checkout.ErrStepNotReached, http.StatusConflict, "Please complete the previous checkout steps first"},
+	{checkout.ErrInvalidShipping, http.StatusBadRequest, "Please choose an offered shipping method"},
+	{repo.ErrAddressNotFound, http.StatusNotFound, "Address not found"},
+	{payment.ErrPaymentNotFound, http.StatusNotFound, "Payment not found"},
+	{payment.ErrNotApproved, http.StatusConflict, "The payment was not approved"},
+	{payment.ErrCartChanged, http.StatusConflict, "Your cart changed during the payment, please pay again"},
+	{repo.ErrConflict, http.StatusConflict, "Your cart changed while we were updating it, please try again"},
+}
+
+// errorResponse returns the HTTP status and user-facing message for err. Unexpected errors are logged
+// and reported as 500 with the fallback message, so internal details never reach the user.
+func errorResponse(err error, fallback string) (int, string) {
+	for _, m := range errorMappings {
+		if errors.Is(err, m.err) {
+			return m.status, m.message
+		}
+	}
+	log.Printf("%s: %v", fallback, err)
+	return http.StatusInternalServerError, fallback
+}
+
+// errorMessage returns the user-facing message for err, see errorResponse.
+func errorMessage(err error, fallback string) string {
+	_, message := errorResponse(err, fallback)
+	return message
+}
+
+// problemContentType is the media type of the problem details of RFC 7807
+const problemContentType = "application/problem+json"
+
+type (
+	// Problem is the body of JSON error responses: the problem details of RFC 7807. Type is always
+	// "about:blank", so Title is the reason phrase of Status, and Detail tells what went wrong. Instance
+	// is the path of the request.
+	Problem struct {
+		Type     string `json:"type"`
+		Title    string `json:"title"`
+		Status   int    `json:"status"`
+		Detail   string `json:"detail,omitempty"`
+		Instance string `json:"instance,omitempty"`
+		// Errors lists the invalid fields of requests failing validation, sorted by field
+		Errors []FieldError `json:"errors,omitempty"`
+	}
+
+	// FieldError tells what is wrong with a field of a request.
+	FieldError struct {
+		Field  string `json:"field"`
+		Detail string `json:"detail"`
+	}
+)
+
+// newProblem returns the problem details of the request answered with status
+func newProblem(c *gin.Context, status int, detail string) Problem {
+	return Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: c.Request.URL.Path,
+	}
+}
+
+// respondWithProblem aborts the request with a JSON error response, see Problem.
+func respondWithProblem(c *gin.Context, status int, detail string) {
+	writeProblem(c, status, newProblem(c, status, detail))
+}
+
+// respondWithInvalidField aborts the request with 400 Bad Request for the field, with a detail such as
+// "name is required" for the message "is required".
+func respondWithInvalidField(c *gin.Context, field, message string) {
+	respondWithInvalidFields(c, http.StatusBadRequest, field+" "+message, map[string]string{field: message})
+}
+
+// respondWithInvalidFields aborts the request with a JSON error response listing what is wrong with
+// each of the fields, keyed by their names.
+func respondWithInvalidFields(c *gin.Context, status int, detail string, fields map[string]string) {
+	problem := newProblem(c, status, detail)
+	for field, message := range fields {
+		problem.Errors = append(problem.Errors, FieldError{Field: field, Detail: message})
+	}
+	sort.Slice(problem.Errors, func(i, j int) bool { return problem.Errors[i].Field < problem.Errors[j].Field })
+	writeProblem(c, status, problem)
+}
+
+// writeProblem aborts the request with body, the Problem or a struct embedding it to add members
+func writeProblem(c *gin.Context, status int, body any) {
+	c.Header("Content-Type", problemContentType)
+	c.AbortWithStatusJSON(status, body)
+}
+
+// respondWithError writes the JSON error response for err, see errorResponse.
+func respondWithError(c *gin.Context, err error, fallback string) {
+	status, message := errorResponse(err, fallback)
+	respondWithProblem(c, status, message)
+}
@@ -0,0 +1,62 @@
+package api_test
+
+import (
+	"encoding/json"
+	"interview/internal/api"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProblemDetails(t *testing.T) {
+	ts := setupTest(t)
+	ts.clearDatabase(t)
+
+	router := gin.New()
+	api.NewAdminHandler(ts.db, nil, time.Hour).RegisterRoutes(router, gin.Accounts{"admin": "secret"})
+	request := func(method, path, body string) (*httptest.ResponseRecorder, api.Problem) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var problem api.Problem
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+		return w, problem
+	}
+
+	t.Run("Error", func(t *testing.T) {
+		w, problem := request(http.MethodGet, "/admin/orders/999", "")
+		require.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+		assert.Equal(t, api.Problem{
+			Type:     "about:blank",
+			Title:    "Not Found",
+			Status:   http.StatusNotFound,
+			Detail:   "order not found",
+			Instance: "/admin/orders/999",
+		}, problem)
+	})
+
+	t.Run("Invalid Field", func(t *testing.T) {
+		w, problem := request(http.MethodPost, "/admin/warehouses", `{"country": "DE"}`)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "name is required", problem.Detail)
+		assert.Equal(t, []api.FieldError{{Field: "name", Detail: "is required"}}, problem.Errors)
+	})
+
+	t.Run("Several Invalid Fields", func(t *testing.T) {
+		w, problem := request(http.MethodPost, "/admin/price-overrides", `{"product": "shoe", "price": 5}`)
+		require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+		assert.Equal(t, []api.FieldError{
+			{Field: "ends_at", Detail: "is required"},
+			{Field: "starts_at", Detail: "is required"},
+		}, problem.Errors)
+	})
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"interview/internal/cart"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cartETag returns a weak ETag for a representation of the cart. The version changes with every change
+// to the cart; variant distinguishes representations of the same cart, such as the page in another
+// language.
+func cartETag(c *cart.Cart, variant ...string) string {
+	h := sha256.New()
+	h.Write([]byte(strconv.FormatUint(uint64(c.ID), 10) + ":" + strconv.Itoa(c.Version) + ":" +
+		strconv.FormatInt(c.UpdatedAt.UnixNano(), 10)))
+	for _, v := range variant {
+		h.Write([]byte{0})
+		h.Write([]byte(v))
+	}
+	return `W/"` + hex.EncodeToString(h.Sum(nil))[:16] + `"`
+}
+
+// notModified sets the ETag of the response and answers 304 Not Modified when the request's
+// If-None-Match already matches it. Clients have to revalidate before reusing a response.
+func notModified(c *gin.Context, etag string) bool {
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "private, no-cache")
+	if !etagMatches(c.GetHeader("If-None-Match"), etag) {
+		return false
+	}
+	c.Status(http.StatusNotModified)
+	return true
+}
+
+// etagMatches reports whether an If-None-Match header matches the ETag, using the weak comparison
+// required for If-None-Match.
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	opaque := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == opaque {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"interview/internal/experiment"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// experimentsKey is the gin context key holding the experiment variants of the session
+const experimentsKey = "experiments"
+
+// ExperimentResultResponse is the JSON representation of the results of an experiment variant. The
+// rates are shares of the sessions assigned to the variant.
+type ExperimentResultResponse struct {
+	Experiment        string  `json:"experiment"`
+	Variant           string  `json:"variant"`
+	Sessions          int64   `json:"sessions"`
+	AddToCartSessions int64   `json:"add_to_cart_sessions"`
+	AddToCartRate     float64 `json:"add_to_cart_rate"`
+	Checkouts         int64   `json:"checkouts"`
+	CheckoutRate      float64 `json:"checkout_rate"`
+}
+
+// SetExperiments sets the A/B experiments sessions take part in. They may change while the server runs.
+func (h *CartHandler) SetExperiments(experiments []experiment.Experiment) {
+	h.experiments.Store(&experiments)
+}
+
+// activeExperiments returns the A/B experiments sessions take part in.
+func (h *CartHandler) activeExperiments() []experiment.Experiment {
+	if experiments := h.experiments.Load(); experiments != nil {
+		return *experiments
+	}
+	return nil
+}
+
+// AssignExperiments is middleware assigning the session to a variant of every experiment. Variants
+// are derived from the session ID and kept in the session, so they stay the same for the session
+// even when experiments are added. Handlers read them with experimentVariants.
+func (h *CartHandler) AssignExperiments(c *gin.Context) {
+	experiments := h.activeExperiments()
+	if len(experiments) == 0 || !isPagePath(c.Request.URL.Path) {
+		c.Next()
+		return
+	}
+
+	session := sessions.Default(c)
+	sessionID, _ := session.Get("session_id").(string)
+	if sessionID == "" {
+		// Pages create the session ID anyway, the variants need it first
+		newSessionID, err := generateSessionID()
+		if err != nil {
+			log.Printf("Failed to generate session ID: %v", err)
+			c.Next()
+			return
+		}
+		sessionID = newSessionID
+		session.Set("session_id", sessionID)
+	}
+
+	variants := make(map[string]string, len(experiments))
+	assigned := map[string]string{}
+	for _, exp := range experiments {
+		key := "experiment:" + exp.Name
+		variant, _ := session.Get(key).(string)
+		// Sessions of variants dropped from the experiment are assigned again
+		if !exp.HasVariant(variant) {
+			variant = exp.Assign(sessionID)
+			session.Set(key, variant)
+			assigned[exp.Name] = variant
+		}
+		variants[exp.Name] = variant
+	}
+
+	if len(assigned) > 0 {
+		if err := session.Save(); err != nil {
+			log.Printf("Failed to save session: %v", err)
+		} else if err := h.repoFor(c).RecordConversions(sessionID, experiment.EventAssigned, assigned); err != nil {
+			log.Printf("Failed to record experiment assignment: %v", err)
+		}
+	}
+
+	c.Set(experimentsKey, variants)
+	c.Next()
+}
+
+// isPagePath reports whether the path serves pages of the shop, which the JSON API, admin endpoints
+// and static files are not.
+func isPagePath(path string) bool {
+	for _, prefix := range []string{"/api/", "/admin/", "/static/", "/media/"} {
+		if strings.HasPrefix(path, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// experimentVariants returns the variants of the session by experiment name, nil when it takes
+// part in no experiments.
+func experimentVariants(c *gin.Context) map[string]string {
+	variants, _ := c.Value(experimentsKey).(map[string]string)
+	return variants
+}
+
+// recordConversion records an event of the session for the experiments it takes part in.
+func (h *CartHandler) recordConversion(c *gin.Context, sessionID, event string) {
+	if err := h.repoFor(c).RecordConversions(sessionID, event, experimentVariants(c)); err != nil {
+		log.Printf("Failed to record experiment conversion: %v", err)
+	}
+}
+
+// ExperimentReport returns how many sessions of each experiment variant added products to their
+// cart and checked out.
+func (h *AdminHandler) ExperimentReport(c *gin.Context) {
+	results, err := h.repoFor(c).ListExperimentResults()
+	if err != nil {
+		log.Printf("Failed to build experiment report: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to build experiment report")
+		return
+	}
+
+	responses := make([]ExperimentResultResponse, len(results))
+	for i, r := range results {
+		responses[i] = ExperimentResultResponse{
+			Experiment:        r.Experiment,
+			Variant:           r.Variant,
+			Sessions:          r.Sessions,
+			AddToCartSessions: r.AddToCartSessions,
+			Checkouts:         r.Checkouts,
+		}
+		if r.Sessions > 0 {
+			responses[i].AddToCartRate = float64(r.AddToCartSessions) / float64(r.Sessions)
+			responses[i].CheckoutRate = float64(r.Checkouts) / float64(r.Sessions)
+		}
+	}
+	c.JSON(http.StatusOK, responses)
+}
@@ -0,0 +1,85 @@
+package api_test
+
+import (
+	"encoding/json"
+	"interview/internal/api"
+	"interview/internal/experiment"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExperiments(t *testing.T) {
+	ts := setupTest(t)
+	ts.clearDatabase(t)
+	exp := experiment.Experiment{Name: "checkout_button", Variants: []string{"control", "green"}}
+	ts.handler.SetExperiments([]experiment.Experiment{exp})
+
+	countConversions := func(t *testing.T, event string) int64 {
+		t.Helper()
+		var count int64
+		require.NoError(t, ts.db.Model(&experiment.Conversion{}).Where("event = ?", event).Count(&count).Error)
+		return count
+	}
+
+	cookie := ts.createSession(t)
+	carts, err := ts.handler.GetRepo().GetAllCarts()
+	require.NoError(t, err)
+	require.Len(t, carts, 1)
+	sessionID := carts[0].SessionID
+
+	t.Run("Assigns Variant Once", func(t *testing.T) {
+		var conversion experiment.Conversion
+		require.NoError(t, ts.db.Where("event = ?", experiment.EventAssigned).First(&conversion).Error)
+		assert.Equal(t, sessionID, conversion.SessionID)
+		assert.Equal(t, exp.Assign(sessionID), conversion.Variant)
+
+		w := ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, int64(1), countConversions(t, experiment.EventAssigned))
+	})
+
+	t.Run("Records Conversions", func(t *testing.T) {
+		w := ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"shoe"}, "quantity": {"1"}}, cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, int64(1), countConversions(t, experiment.EventAddToCart))
+
+		require.NoError(t, ts.handler.GetRepo().CloseCart(carts[0].ID))
+		assert.Equal(t, int64(1), countConversions(t, experiment.EventCheckout))
+	})
+
+	t.Run("Reports Results", func(t *testing.T) {
+		// A second session that only looks around
+		ts.createSession(t)
+
+		router := gin.New()
+		api.NewAdminHandler(ts.db, nil, time.Hour).RegisterRoutes(router, gin.Accounts{"admin": "secret"})
+		req := httptest.NewRequest(http.MethodGet, "/admin/reports/experiments", nil)
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var results []api.ExperimentResultResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+		var sessions, checkouts int64
+		for _, r := range results {
+			assert.Equal(t, "checkout_button", r.Experiment)
+			sessions += r.Sessions
+			checkouts += r.Checkouts
+			if r.Variant == exp.Assign(sessionID) {
+				assert.Equal(t, int64(1), r.AddToCartSessions)
+				assert.Equal(t, int64(1), r.Checkouts)
+				assert.Greater(t, r.CheckoutRate, 0.0)
+			}
+		}
+		assert.Equal(t, int64(2), sessions)
+		assert.Equal(t, int64(1), checkouts)
+	})
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"bytes"
+	"image/png"
+	"interview/internal/cart"
+	"interview/internal/qrcode"
+	"interview/internal/reminder"
+	"log"
+	"net/http"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// qrScale is the number of pixels per module of cart QR codes
+const qrScale = 6
+
+// SetCartHandoff enables the QR code of the cart page, which opens the cart on a store terminal
+// through a signed link.
+func (h *CartHandler) SetCartHandoff(links *reminder.CartLinks) {
+	h.handoffLinks = links
+}
+
+// CartQRCode renders a PNG QR code of a signed link to the current cart, so customers can bring their
+// cart to a physical store by scanning it at a terminal.
+func (h *CartHandler) CartQRCode(c *gin.Context) {
+	session := sessions.Default(c)
+	sessionID, ok := session.Get("session_id").(string)
+	if !ok {
+		c.String(http.StatusNotFound, "Cart not found")
+		return
+	}
+	userCart, err := h.repoFor(c).GetExistingCart(sessionID, currentCartName(session))
+	if err != nil || userCart.Status != cart.StatusOpen {
+		c.String(http.StatusNotFound, "Cart not found")
+		return
+	}
+
+	code, err := qrcode.Encode(h.handoffLinks.URL(userCart.ID))
+	if err != nil {
+		log.Printf("Failed to encode cart QR code: %v", err)
+		c.String(http.StatusInternalServerError, "Failed to create QR code")
+		return
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, code.Image(qrScale)); err != nil {
+		log.Printf("Failed to encode cart QR code: %v", err)
+		c.String(http.StatusInternalServerError, "Failed to create QR code")
+		return
+	}
+	// Every code carries a link that expires, so it is never reused
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "image/png", buf.Bytes())
+}
@@ -0,0 +1,52 @@
+package api_test
+
+import (
+	"bytes"
+	"image/png"
+	"interview/internal/reminder"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCartQRCode(t *testing.T) {
+	ts := setupTest(t)
+	ts.clearDatabase(t)
+	ts.handler.SetCartHandoff(reminder.NewCartLinks("http://localhost", []byte("test_secret"), 15*time.Minute))
+	ts.router.GET("/cart/qr.png", ts.handler.CartQRCode)
+
+	t.Run("Session Without Cart Gets No Code", func(t *testing.T) {
+		w := ts.makeRequest(t, http.MethodGet, "/cart/qr.png", nil, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Cart Page Shows The Code Once The Cart Has Items", func(t *testing.T) {
+		cookie := ts.createSession(t)
+		w := ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.NotContains(t, w.Body.String(), "/cart/qr.png")
+
+		w = ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"watch"}, "quantity": {"1"}}, cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+		w = ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.Contains(t, w.Body.String(), `<img src="/cart/qr.png"`)
+	})
+
+	t.Run("Code Is A PNG That Is Never Cached", func(t *testing.T) {
+		cookie := ts.createSession(t)
+		w := ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"shoe"}, "quantity": {"1"}}, cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+
+		w = ts.makeRequest(t, http.MethodGet, "/cart/qr.png", nil, cookie)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+		img, err := png.Decode(bytes.NewReader(w.Body.Bytes()))
+		require.NoError(t, err)
+		assert.Equal(t, img.Bounds().Dx(), img.Bounds().Dy())
+	})
+}
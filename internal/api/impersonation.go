@@ -0,0 +1,294 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"interview/internal/audit"
+	"interview/internal/auth"
+	"interview/internal/cart"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// impersonatorKey is the session key of the staff member viewing a customer's cart in the session,
+	// impersonationWriteKey whether they may change it
+	impersonatorKey       = "impersonator"
+	impersonationWriteKey = "impersonation_write"
+	// The session's own values of these keys are kept while it views a customer's cart, to go back to
+	impersonatorSessionKey  = "impersonator_session_id"
+	impersonatorUserKey     = "impersonator_user_id"
+	impersonatorCartNameKey = "impersonator_cart_name"
+	// stopImpersonationPath leaves the view of a customer's cart
+	stopImpersonationPath = "/stop-impersonating"
+	// auditLogSize is how many entries the audit log endpoint returns
+	auditLogSize = 100
+)
+
+// impersonationEdits are the requests changing a customer's cart, only allowed in write-enabled views.
+// Everything else but looking at the cart and the catalog is off limits while impersonating.
+var impersonationEdits = map[string]bool{
+	"/add-item":           true,
+	"/add-bundle":         true,
+	"/remove-item":        true,
+	"/cart/items/reorder": true,
+	"/restore-item":       true,
+	"/undo-change":        true,
+	"/subscribe-item":     true,
+	"/carts/new":          true,
+	"/carts/rename":       true,
+	"/carts/delete":       true,
+}
+
+// impersonatorKeys maps the session keys replaced while viewing a customer's cart to the keys their
+// values are kept under meanwhile
+var impersonatorKeys = map[string]string{
+	"session_id": impersonatorSessionKey,
+	"user_id":    impersonatorUserKey,
+	"cart_name":  impersonatorCartNameKey,
+}
+
+type (
+	// ImpersonationView is the banner shown while a staff member views a customer's cart.
+	ImpersonationView struct {
+		// Customer is the name or email address of the customer, their session ID when anonymous
+		Customer string
+		// Write is whether the staff member may change the cart
+		Write bool
+	}
+
+	// AuditEntryResponse is the JSON representation of an entry of the audit log.
+	AuditEntryResponse struct {
+		ID        uint      `json:"id"`
+		Actor     string    `json:"actor"`
+		Action    string    `json:"action"`
+		SessionID string    `json:"session_id,omitempty"`
+		UserID    *uint     `json:"user_id,omitempty"`
+		Detail    string    `json:"detail,omitempty"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+)
+
+// Impersonate opens the view of a customer's cart in the staff member's browser session: the shop shows
+// the open cart changed last of the session_id or, without one, of the user_id query parameter, with a
+// banner. The view is read-only unless write=true, which requires the permission to manage carts.
+// Starting the view and every request made in it are recorded in the audit log.
+func (h *AdminHandler) Impersonate(c *gin.Context) {
+	sessionID := c.Query("session_id")
+	var userID uint
+	if sessionID == "" {
+		id, err := strconv.ParseUint(c.Query("user_id"), 10, 32)
+		if err != nil || id == 0 {
+			respondWithProblem(c, http.StatusBadRequest, "session_id or user_id required")
+			return
+		}
+		userID = uint(id)
+	}
+	write := c.Query("write") == "true"
+	if write && !auth.Can(c.GetString(staffRoleKey), auth.PermManageCarts) {
+		respondWithProblem(c, http.StatusForbidden, "permission denied")
+		return
+	}
+	// The view lives in the browser session, which admin routes mounted without sessions don't have
+	if _, ok := c.Get(sessions.DefaultKey); !ok {
+		respondWithProblem(c, http.StatusNotImplemented, "impersonation requires sessions")
+		return
+	}
+
+	r := h.repoFor(c)
+	customerCart, err := r.FindCustomerCart(sessionID, userID)
+	if errors.Is(err, cart.ErrCartNotFound) {
+		respondWithProblem(c, http.StatusNotFound, "no open cart found")
+		return
+	} else if err != nil {
+		log.Printf("Failed to find cart to impersonate: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to open cart")
+		return
+	}
+
+	actor := staffActor(c)
+	mode := "read-only"
+	if write {
+		mode = "write-enabled"
+	}
+	err = r.RecordAudit(&audit.Entry{
+		Actor:     actor,
+		Action:    audit.ActionImpersonationStarted,
+		SessionID: customerCart.SessionID,
+		UserID:    customerCart.UserID,
+		Detail:    fmt.Sprintf("%s view of cart %q", mode, customerCart.Name),
+	})
+	if err != nil {
+		log.Printf("Failed to record impersonation: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to open cart")
+		return
+	}
+
+	session := sessions.Default(c)
+	// Switching to another customer keeps the values of the staff member's own session
+	if _, impersonating := session.Get(impersonatorKey).(string); !impersonating {
+		for key, saved := range impersonatorKeys {
+			if value := session.Get(key); value != nil {
+				session.Set(saved, value)
+			}
+		}
+	}
+	session.Set(impersonatorKey, actor)
+	session.Set(impersonationWriteKey, write)
+	session.Set("session_id", customerCart.SessionID)
+	session.Set("cart_name", customerCart.Name)
+	if customerCart.UserID != nil {
+		session.Set("user_id", *customerCart.UserID)
+	} else {
+		session.Delete("user_id")
+	}
+	if err := session.Save(); err != nil {
+		log.Printf("Failed to save session: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to open cart")
+		return
+	}
+	c.Redirect(http.StatusSeeOther, "/")
+}
+
+// ListAuditLog returns the latest entries of the audit log, only those about the session of the
+// "session_id" query parameter if given.
+func (h *AdminHandler) ListAuditLog(c *gin.Context) {
+	entries, err := h.repoFor(c).ListAuditLog(c.Query("session_id"), auditLogSize)
+	if err != nil {
+		log.Printf("Failed to list audit log: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to list audit log")
+		return
+	}
+	responses := make([]AuditEntryResponse, len(entries))
+	for i, e := range entries {
+		responses[i] = AuditEntryResponse{
+			ID:        e.ID,
+			Actor:     e.Actor,
+			Action:    e.Action,
+			SessionID: e.SessionID,
+			UserID:    e.UserID,
+			Detail:    e.Detail,
+			CreatedAt: e.CreatedAt,
+		}
+	}
+	c.JSON(http.StatusOK, responses)
+}
+
+// GuardImpersonation is middleware keeping sessions viewing a customer's cart to the cart and the
+// catalog: changes to the cart need a write-enabled view, everything else, e.g. the account or checkout,
+// is denied. Every request but those for assets is recorded in the audit log with its outcome.
+func (h *CartHandler) GuardImpersonation(c *gin.Context) {
+	session := sessions.Default(c)
+	actor, impersonating := session.Get(impersonatorKey).(string)
+	path := c.Request.URL.Path
+	// Leaving the view is recorded by StopImpersonating itself
+	if !impersonating || path == stopImpersonationPath || strings.HasPrefix(path, "/static/") ||
+		strings.HasPrefix(path, "/media/") || strings.HasPrefix(path, "/admin/") || strings.HasPrefix(path, "/api/") {
+		c.Next()
+		return
+	}
+	// Read before the request, which may change the session
+	sessionID, _ := session.Get("session_id").(string)
+	userID := sessionUserID(session)
+
+	write, _ := session.Get(impersonationWriteKey).(bool)
+	switch {
+	case impersonationAllowed(c.Request.Method, path, write):
+		c.Next()
+	case impersonationEdits[path]:
+		h.redirectWithFlash(c, session, "This view of the customer's cart is read-only")
+		c.Abort()
+	default:
+		h.redirectWithFlash(c, session, "Not available while viewing a customer's cart")
+		c.Abort()
+	}
+
+	err := h.repoFor(c).RecordAudit(&audit.Entry{
+		Actor:     actor,
+		Action:    audit.ActionImpersonatedRequest,
+		SessionID: sessionID,
+		UserID:    userID,
+		Detail:    fmt.Sprintf("%s %s %d", c.Request.Method, path, c.Writer.Status()),
+	})
+	if err != nil {
+		log.Printf("Failed to record impersonated request: %v", err)
+	}
+}
+
+// impersonationAllowed reports whether a session viewing a customer's cart may make the request.
+func impersonationAllowed(method, path string, write bool) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		return path == "/" || path == "/products" || strings.HasPrefix(path, "/products/") ||
+			strings.HasPrefix(path, "/orders/") || path == "/subscriptions"
+	case http.MethodPost:
+		return path == "/carts/switch" || (write && impersonationEdits[path])
+	}
+	return false
+}
+
+// StopImpersonating leaves the view of a customer's cart, giving the staff member their own session back.
+func (h *CartHandler) StopImpersonating(c *gin.Context) {
+	session := sessions.Default(c)
+	actor, impersonating := session.Get(impersonatorKey).(string)
+	if !impersonating {
+		c.Redirect(http.StatusFound, "/")
+		return
+	}
+
+	sessionID, _ := session.Get("session_id").(string)
+	err := h.repoFor(c).RecordAudit(&audit.Entry{
+		Actor:     actor,
+		Action:    audit.ActionImpersonationStopped,
+		SessionID: sessionID,
+		UserID:    sessionUserID(session),
+	})
+	if err != nil {
+		log.Printf("Failed to record end of impersonation: %v", err)
+	}
+
+	for key, saved := range impersonatorKeys {
+		if value := session.Get(saved); value != nil {
+			session.Set(key, value)
+		} else {
+			session.Delete(key)
+		}
+		session.Delete(saved)
+	}
+	session.Delete(impersonatorKey)
+	session.Delete(impersonationWriteKey)
+	if err := session.Save(); err != nil {
+		log.Printf("Failed to save session: %v", err)
+	}
+	c.Redirect(http.StatusFound, "/")
+}
+
+// readOnlyView reports whether the session views a customer's cart without write access, in which
+// case pages leave the cart as it is, e.g. don't update its prices or create it
+func readOnlyView(session sessions.Session) bool {
+	if _, impersonating := session.Get(impersonatorKey).(string); !impersonating {
+		return false
+	}
+	write, _ := session.Get(impersonationWriteKey).(bool)
+	return !write
+}
+
+// impersonationView returns the banner of a session viewing a customer's cart, nil for other sessions.
+// userName is the name of the customer's user, empty for anonymous customers.
+func impersonationView(session sessions.Session, userName string) *ImpersonationView {
+	if _, impersonating := session.Get(impersonatorKey).(string); !impersonating {
+		return nil
+	}
+	view := &ImpersonationView{Customer: userName}
+	view.Write, _ = session.Get(impersonationWriteKey).(bool)
+	if view.Customer == "" {
+		view.Customer, _ = session.Get("session_id").(string)
+	}
+	return view
+}
@@ -0,0 +1,164 @@
+package api_test
+
+import (
+	"encoding/json"
+	"interview/internal/api"
+	"interview/internal/audit"
+	"interview/internal/cart"
+	"interview/internal/repo"
+	"interview/internal/storage"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImpersonation(t *testing.T) {
+	ts := setupTest(t)
+	media := storage.NewLocal(t.TempDir(), "/media", []byte("test_secret"))
+	api.NewAdminHandler(ts.db, media, time.Hour).RegisterRoutes(ts.router, gin.Accounts{"admin": "secret"})
+	cartRepo := repo.NewRepository(ts.db)
+
+	// setupCustomer gives a customer a cart holding a shoe
+	setupCustomer := func(t *testing.T) (*cart.Cart, uint) {
+		ts.clearDatabase(t)
+		user, err := cartRepo.FindOrCreateUser("github", "1", "jane@example.com", "Jane")
+		require.NoError(t, err)
+		c, err := cartRepo.GetOrCreateCart("customer", cart.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 1, 10))
+		require.NoError(t, cartRepo.AssignCartToUser("customer", user.ID))
+		return c, user.ID
+	}
+	impersonate := func(t *testing.T, cookie *http.Cookie, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/admin/impersonate?"+query, nil)
+		req.SetBasicAuth("admin", "secret")
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		ts.router.ServeHTTP(w, req)
+		return w
+	}
+	auditLog := func(t *testing.T) []api.AuditEntryResponse {
+		req := httptest.NewRequest(http.MethodGet, "/admin/audit-log?session_id=customer", nil)
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		ts.router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var entries []api.AuditEntryResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+		return entries
+	}
+
+	t.Run("Read-Only View Shows The Customer's Cart", func(t *testing.T) {
+		customerCart, _ := setupCustomer(t)
+		staff := ts.createSession(t)
+
+		w := impersonate(t, staff, "session_id=customer")
+		require.Equal(t, http.StatusSeeOther, w.Code, w.Body.String())
+		assert.Equal(t, "/", w.Header().Get("Location"))
+
+		w = ts.makeRequest(t, http.MethodGet, "/", nil, staff)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "You are viewing the cart of Jane, read-only")
+		assert.Contains(t, w.Body.String(), "shoe")
+
+		w = ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"bag"}, "quantity": {"1"}}, staff)
+		assert.Equal(t, http.StatusFound, w.Code)
+		w = ts.makeRequest(t, http.MethodGet, "/", nil, staff)
+		assert.Contains(t, w.Body.String(), "This view of the customer&#39;s cart is read-only")
+		customerCart, err := cartRepo.GetCart(customerCart.ID)
+		require.NoError(t, err)
+		assert.Len(t, customerCart.CartItems, 1)
+
+		entries := auditLog(t)
+		require.Len(t, entries, 4)
+		assert.Equal(t, audit.ActionImpersonatedRequest, entries[0].Action)
+		assert.Equal(t, "GET / 200", entries[0].Detail)
+		assert.Equal(t, "POST /add-item 302", entries[1].Detail)
+		assert.Equal(t, "GET / 200", entries[2].Detail)
+		assert.Equal(t, audit.ActionImpersonationStarted, entries[3].Action)
+		assert.Equal(t, "admin", entries[3].Actor)
+		assert.Equal(t, `read-only view of cart "default"`, entries[3].Detail)
+	})
+
+	t.Run("Read-Only View Leaves The Cart As It Is", func(t *testing.T) {
+		ts.handler.SetPriceRefreshAfter(time.Nanosecond)
+		defer ts.handler.SetPriceRefreshAfter(0)
+		customerCart, _ := setupCustomer(t)
+		// The shoe costs 10 in the catalog now
+		require.NoError(t, ts.db.Model(&cart.CartItem{}).Where("cart_id = ?", customerCart.ID).UpdateColumn("price", 7).Error)
+		staff := ts.createSession(t)
+		require.Equal(t, http.StatusSeeOther, impersonate(t, staff, "session_id=customer").Code)
+
+		w := ts.makeRequest(t, http.MethodGet, "/", nil, staff)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "Prices in your cart were updated")
+		unchanged, err := cartRepo.GetCart(customerCart.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 7.0, unchanged.CartItems[0].Price)
+
+		require.NoError(t, cartRepo.CloseCart(customerCart.ID))
+		require.Equal(t, http.StatusOK, ts.makeRequest(t, http.MethodGet, "/", nil, staff).Code)
+		var carts int64
+		require.NoError(t, ts.db.Model(&cart.Cart{}).Where("session_id = ?", "customer").Count(&carts).Error)
+		assert.Equal(t, int64(1), carts, "the customer doesn't get a new cart")
+	})
+
+	t.Run("Write-Enabled View Changes The Customer's Cart", func(t *testing.T) {
+		customerCart, userID := setupCustomer(t)
+		staff := ts.createSession(t)
+
+		w := impersonate(t, staff, "user_id="+strconv.FormatUint(uint64(userID), 10)+"&write=true")
+		require.Equal(t, http.StatusSeeOther, w.Code, w.Body.String())
+		w = ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"bag"}, "quantity": {"1"}}, staff)
+		assert.Equal(t, http.StatusFound, w.Code)
+		customerCart, err := cartRepo.GetCart(customerCart.ID)
+		require.NoError(t, err)
+		assert.Len(t, customerCart.CartItems, 2)
+
+		entries := auditLog(t)
+		require.Len(t, entries, 2)
+		assert.Equal(t, "POST /add-item 302", entries[0].Detail)
+		assert.Equal(t, &userID, entries[0].UserID)
+	})
+
+	t.Run("Account Pages Are Off Limits", func(t *testing.T) {
+		setupCustomer(t)
+		staff := ts.createSession(t)
+		require.Equal(t, http.StatusSeeOther, impersonate(t, staff, "session_id=customer&write=true").Code)
+
+		w := ts.makeRequest(t, http.MethodPost, "/account", url.Values{"name": {"Eve"}}, staff)
+		assert.Equal(t, http.StatusFound, w.Code)
+		w = ts.makeRequest(t, http.MethodGet, "/", nil, staff)
+		assert.Contains(t, w.Body.String(), "Not available while viewing a customer&#39;s cart")
+	})
+
+	t.Run("Stopping Gives The Session Back", func(t *testing.T) {
+		setupCustomer(t)
+		staff := ts.createSession(t)
+		require.Equal(t, http.StatusSeeOther, impersonate(t, staff, "session_id=customer").Code)
+
+		w := ts.makeRequest(t, http.MethodPost, "/stop-impersonating", url.Values{}, staff)
+		assert.Equal(t, http.StatusFound, w.Code)
+		w = ts.makeRequest(t, http.MethodGet, "/", nil, staff)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "impersonation-banner")
+		assert.NotContains(t, w.Body.String(), "Jane")
+
+		entries := auditLog(t)
+		require.Len(t, entries, 2)
+		assert.Equal(t, audit.ActionImpersonationStopped, entries[0].Action)
+	})
+
+	t.Run("Unknown Customer", func(t *testing.T) {
+		setupCustomer(t)
+		w := impersonate(t, ts.createSession(t), "session_id=nobody")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"encoding/json"
+	"interview/internal/inventory"
+	"interview/internal/webhook"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// inventoryWebhookPath receives the stock and price updates of the ERP
+	inventoryWebhookPath = "/webhooks/inventory"
+	// inventorySignatureTolerance is how far the signature time of an inventory webhook may be from
+	// now, so captured requests can't be replayed later
+	inventorySignatureTolerance = 5 * time.Minute
+)
+
+// SetInventorySecret accepts the inventory webhooks of the ERP signed with secret, see InventoryWebhook.
+func (h *CartHandler) SetInventorySecret(secret string) {
+	h.inventorySecret = secret
+}
+
+// InventoryWebhook upserts the products of a batch of stock and price updates sent by the ERP, signed
+// like the webhooks the shop sends. It responds with a report of the updates that failed; batches
+// whose event ID was seen before are reported as duplicates without being applied again.
+func (h *CartHandler) InventoryWebhook(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookSize))
+	if err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "failed to read request body")
+		return
+	}
+	signature := c.GetHeader(webhook.SignatureHeader)
+	if err := webhook.Verify(h.inventorySecret, signature, body, time.Now(), inventorySignatureTolerance); err != nil {
+		respondWithProblem(c, http.StatusUnauthorized, "invalid signature")
+		return
+	}
+
+	var batch inventory.Batch
+	if err := json.Unmarshal(body, &batch); err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if batch.EventID == "" {
+		respondWithInvalidField(c, "event_id", "is required")
+		return
+	}
+
+	report, err := h.repoFor(c).ApplyInventory(batch)
+	if err != nil {
+		log.Printf("Failed to apply inventory event %s: %v", batch.EventID, err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to apply inventory updates")
+		return
+	}
+	if !report.Duplicate {
+		log.Printf("Applied inventory event %s: %d created, %d updated, %d failed",
+			batch.EventID, report.Created, report.Updated, len(report.Failed))
+	}
+	c.JSON(http.StatusOK, report)
+}
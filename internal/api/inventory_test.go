@@ -0,0 +1,84 @@
+package api_test
+
+import (
+	"encoding/json"
+	"interview/internal/inventory"
+	"interview/internal/repo"
+	"interview/internal/webhook"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInventoryWebhook(t *testing.T) {
+	ts := setupTest(t)
+	ts.clearDatabase(t)
+	// Updates create the products missing from the catalog
+	require.NoError(t, ts.db.Exec("DELETE FROM products").Error)
+	ts.handler.SetInventorySecret("erp_secret")
+	router := gin.New()
+	router.POST("/webhooks/inventory", ts.handler.InventoryWebhook)
+
+	send := func(body, signature string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks/inventory", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(webhook.SignatureHeader, signature)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	signed := func(body string) *httptest.ResponseRecorder {
+		return send(body, webhook.Sign("erp_secret", time.Now(), []byte(body)))
+	}
+
+	t.Run("Applies Updates", func(t *testing.T) {
+		w := signed(`{"event_id": "evt_1", "updates": [
+			{"product": "shoe", "price": 10, "stock": 5},
+			{"product": "hat", "stock": 2}
+		]}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var report inventory.Report
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+		assert.Equal(t, inventory.Report{EventID: "evt_1", Created: 1, Failed: []inventory.Failure{
+			{Index: 1, Product: "hat", Error: "price is required for new products"},
+		}}, report)
+
+		products, err := repo.NewRepository(ts.db).GetProductsByName([]string{"shoe"})
+		require.NoError(t, err)
+		require.NotNil(t, products["shoe"].Stock)
+		assert.Equal(t, 5, *products["shoe"].Stock)
+	})
+
+	t.Run("Replayed Event", func(t *testing.T) {
+		w := signed(`{"event_id": "evt_1", "updates": [{"product": "shoe", "price": 99}]}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		var report inventory.Report
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+		assert.True(t, report.Duplicate)
+	})
+
+	t.Run("Invalid Signature", func(t *testing.T) {
+		body := `{"event_id": "evt_2", "updates": []}`
+		w := send(body, webhook.Sign("other_secret", time.Now(), []byte(body)))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Old Signature", func(t *testing.T) {
+		body := `{"event_id": "evt_2", "updates": []}`
+		w := send(body, webhook.Sign("erp_secret", time.Now().Add(-time.Hour), []byte(body)))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Missing Event ID", func(t *testing.T) {
+		w := signed(`{"updates": [{"product": "shoe", "price": 1}]}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "event_id is required")
+	})
+}
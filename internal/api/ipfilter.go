@@ -0,0 +1,38 @@
+package api
+
+import (
+	"interview/internal/ipfilter"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RestrictIPs returns a middleware answering requests of clients the filter doesn't allow with
+// 403 Forbidden.
+func RestrictIPs(filter *ipfilter.Filter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		client, ok := filter.ClientIP(c.Request)
+		if !ok || !filter.Allowed(client) {
+			log.Printf("Denied %s %s to client %s (peer %s)", c.Request.Method, c.Request.URL.Path, client, c.Request.RemoteAddr)
+			respondWithProblem(c, http.StatusForbidden, "access denied")
+			return
+		}
+		c.Next()
+	}
+}
+
+// TrustProxies has the router honor the X-Forwarded-For header of the reverse proxies in spec, a list
+// like TRUSTED_PROXIES, and of no other peer. The client address of gin.Context.ClientIP, which the
+// login throttle, risk checks, device records and rate limits go by, can't be forged by clients then.
+func TrustProxies(router *gin.Engine, spec string) error {
+	prefixes, err := ipfilter.ParseList(spec)
+	if err != nil {
+		return err
+	}
+	var proxies []string
+	for _, prefix := range prefixes {
+		proxies = append(proxies, prefix.String())
+	}
+	return router.SetTrustedProxies(proxies)
+}
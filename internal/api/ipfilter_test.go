@@ -0,0 +1,104 @@
+package api_test
+
+import (
+	"interview/internal/api"
+	"interview/internal/ipfilter"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestrictIPs(t *testing.T) {
+	ts := setupTest(t)
+	filter := &ipfilter.Filter{
+		Allow:          []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")},
+		Deny:           []netip.Prefix{netip.MustParsePrefix("192.0.2.66/32")},
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.1/32")},
+	}
+	router := gin.New()
+	api.NewAdminHandler(ts.db, nil, time.Hour).RegisterRoutes(router.Group("", api.RestrictIPs(filter)), gin.Accounts{"admin": "secret"})
+
+	tests := []struct {
+		name       string
+		peer       string
+		forwarded  string
+		wantStatus int
+	}{
+		{"Allowed Client", "192.0.2.10:5000", "", http.StatusOK},
+		{"Other Client", "198.51.100.7:5000", "", http.StatusForbidden},
+		{"Denied Client", "192.0.2.66:5000", "", http.StatusForbidden},
+		{"Allowed Client Behind Trusted Proxy", "10.0.0.1:5000", "192.0.2.10", http.StatusOK},
+		{"Other Client Behind Trusted Proxy", "10.0.0.1:5000", "198.51.100.7", http.StatusForbidden},
+		{"Forged Header Of Untrusted Peer", "198.51.100.7:5000", "192.0.2.10", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin/metrics", nil)
+			req.RemoteAddr = tt.peer
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			req.SetBasicAuth("admin", "secret")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+
+	t.Run("Checked Before Authentication", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/admin/metrics", nil)
+		req.RemoteAddr = "198.51.100.7:5000"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Empty(t, w.Header().Get("WWW-Authenticate"))
+	})
+}
+
+func TestTrustProxies(t *testing.T) {
+	router := gin.New()
+	require.NoError(t, api.TrustProxies(router, "10.0.0.0/8, ::1"))
+	router.GET("/ip", func(c *gin.Context) { c.String(http.StatusOK, c.ClientIP()) })
+
+	tests := []struct {
+		name      string
+		peer      string
+		forwarded string
+		want      string
+	}{
+		{"Client Behind Trusted Proxy", "10.1.2.3:5000", "192.0.2.10", "192.0.2.10"},
+		{"Forged Header Of Untrusted Peer", "198.51.100.7:5000", "192.0.2.10", "198.51.100.7"},
+		{"No Header", "198.51.100.7:5000", "", "198.51.100.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+			req.RemoteAddr = tt.peer
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.want, w.Body.String())
+		})
+	}
+
+	t.Run("Nothing Is Trusted Without Proxies", func(t *testing.T) {
+		router := gin.New()
+		require.NoError(t, api.TrustProxies(router, ""))
+		router.GET("/ip", func(c *gin.Context) { c.String(http.StatusOK, c.ClientIP()) })
+		req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+		req.RemoteAddr = "127.0.0.1:5000"
+		req.Header.Set("X-Forwarded-For", "192.0.2.10")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, "127.0.0.1", w.Body.String())
+	})
+	assert.Error(t, api.TrustProxies(gin.New(), "not-an-ip"))
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"fmt"
+	"interview/internal/config"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// systemdListenFD is the first file descriptor systemd passes to socket-activated services
+const systemdListenFD = 3
+
+// Listen opens the listener of the HTTP server: the socket systemd passed to the process when it is
+// socket activated, else the unix socket or TCP address of API_LISTEN, else the TCP port API_PORT.
+func Listen(config config.Config) (net.Listener, error) {
+	switch {
+	case config.SocketActivated:
+		ln, err := net.FileListener(os.NewFile(systemdListenFD, "systemd socket"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to use the socket passed by systemd: %w", err)
+		}
+		return ln, nil
+	case strings.HasPrefix(config.APIListen, "unix://"):
+		return listenUnix(strings.TrimPrefix(config.APIListen, "unix://"))
+	case strings.HasPrefix(config.APIListen, "tcp://"):
+		return net.Listen("tcp", strings.TrimPrefix(config.APIListen, "tcp://"))
+	default:
+		return net.Listen("tcp", fmt.Sprintf(":%d", config.APIPort))
+	}
+}
+
+// listenUnix listens on the unix socket at path, replacing a socket left behind by a previous run.
+func listenUnix(path string) (net.Listener, error) {
+	if info, err := os.Stat(path); err == nil && info.Mode()&fs.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+	return net.Listen("unix", path)
+}
+
+// unixPeer gives requests received on a unix socket, which have no remote address, the loopback
+// address of the reverse proxy sending them, so client IPs are taken from its forwarding headers.
+func unixPeer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.RemoteAddr == "" || r.RemoteAddr == "@" {
+			r.RemoteAddr = "127.0.0.1:0"
+		}
+		next.ServeHTTP(w, r)
+	})
+}
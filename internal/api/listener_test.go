@@ -0,0 +1,47 @@
+package api_test
+
+import (
+	"interview/internal/api"
+	"interview/internal/config"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListen(t *testing.T) {
+	t.Run("Unix Socket", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cart.sock")
+		// A socket left behind by a previous run is replaced
+		stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+		require.NoError(t, err)
+		stale.SetUnlinkOnClose(false)
+		require.NoError(t, stale.Close())
+
+		ln, err := api.Listen(config.Config{APIListen: "unix://" + path})
+		require.NoError(t, err)
+		defer ln.Close()
+		go http.Serve(ln, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}))
+
+		client := &http.Client{Transport: &http.Transport{
+			Dial: func(_, _ string) (net.Conn, error) { return net.Dial("unix", path) },
+		}}
+		resp, err := client.Get("http://cart/")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusTeapot, resp.StatusCode)
+	})
+
+	t.Run("TCP Address", func(t *testing.T) {
+		ln, err := api.Listen(config.Config{APIListen: "tcp://127.0.0.1:0", APIPort: 8088})
+		require.NoError(t, err)
+		defer ln.Close()
+		assert.Equal(t, "tcp", ln.Addr().Network())
+		assert.Contains(t, ln.Addr().String(), "127.0.0.1:")
+	})
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"interview/internal/auth"
+	"interview/internal/cart"
+	"interview/internal/mail"
+	"log"
+	netmail "net/mail"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+var loginLinkEmailTemplate = texttemplate.Must(texttemplate.New("login").Parse(`Hello,
+
+click the link below to log in to your account:
+
+{{ .Link }}
+
+The link expires in {{ .TTL }}. If you didn't ask for it, you can ignore this email.
+`))
+
+// EnableLoginLinks lets users log in with links signed by links and emailed through mailer.
+func (h *AuthHandler) EnableLoginLinks(links *auth.LoginLinks, mailer mail.Mailer) {
+	h.loginLinks = links
+	h.mailer = mailer
+}
+
+// RequestLoginLink emails a link logging the user in to the "email" form field. The link also carries
+// the anonymous cart of the session, so it isn't lost when the link is opened in another browser.
+func (h *AuthHandler) RequestLoginLink(c *gin.Context) {
+	session := sessions.Default(c)
+	email := strings.ToLower(strings.TrimSpace(c.PostForm("email")))
+	if !validEmail(email) {
+		h.failLogin(c, session, "Please enter a valid email address")
+		return
+	}
+	if !h.allowEmail(c, email) {
+		h.failLogin(c, session, "Too many requests, please try again later")
+		return
+	}
+
+	link := auth.LoginLink{Email: email}
+	if sessionID, ok := session.Get("session_id").(string); ok {
+		userCart, err := h.repoFor(c).GetExistingCart(sessionID, currentCartName(session))
+		if err == nil && userCart.UserID == nil && len(userCart.CartItems) > 0 {
+			link.CartID = userCart.ID
+		}
+	}
+
+	var body bytes.Buffer
+	err := loginLinkEmailTemplate.Execute(&body, map[string]string{
+		"Link": h.loginLinks.URL(link),
+		"TTL":  h.loginLinks.TTL().String(),
+	})
+	if err != nil {
+		log.Printf("Failed to render login link email: %v", err)
+		h.failLogin(c, session, "Failed to send login link")
+		return
+	}
+	sendEmail(h.mailer, mail.Message{To: email, Subject: "Your login link", Body: body.String()})
+	redirectWithNotice(c, session, "We sent you a link to log in, please check your email")
+}
+
+// validEmail reports whether email is a plain email address, without a display name.
+func validEmail(email string) bool {
+	address, err := netmail.ParseAddress(email)
+	return err == nil && address.Address == email
+}
+
+// LoginWithLink logs the user of a login link in, creating their account on first login, and merges
+// the cart the link was requested with into the cart of the session.
+func (h *AuthHandler) LoginWithLink(c *gin.Context) {
+	session := sessions.Default(c)
+	link, err := h.loginLinks.Verify(c.Request.URL.Query())
+	if err != nil {
+		h.failLogin(c, session, "This link is invalid or has expired")
+		return
+	}
+
+	u, err := h.repoFor(c).FindOrCreateUserByEmail(auth.ProviderEmail, link.Email)
+	if err != nil {
+		log.Printf("Failed to load user: %v", err)
+		h.failLogin(c, session, "Login failed, please try again")
+		return
+	}
+	// The link was sent to the address, which verifies it
+	if u.EmailVerifiedAt == nil {
+		if err := h.repoFor(c).VerifyEmail(u.ID, link.Email, time.Now()); err != nil {
+			log.Printf("Failed to verify email address of user %d: %v", u.ID, err)
+		}
+	}
+	if link.CartID != 0 {
+		h.mergeLinkedCart(c, session, link.CartID)
+	}
+
+	if u.TOTPEnabled {
+		startPendingLogin(c, session, u.ID)
+		return
+	}
+	h.completeLogin(c, session, u.ID)
+}
+
+// mergeLinkedCart moves the anonymous cart of a login link into the cart of the session, unless the
+// link was opened in the browser it was requested from.
+func (h *AuthHandler) mergeLinkedCart(c *gin.Context, session sessions.Session, cartID uint) {
+	sessionID, _ := session.Get("session_id").(string)
+	if sessionID == "" {
+		newSessionID, err := generateSessionID()
+		if err != nil {
+			log.Printf("Failed to generate session ID: %v", err)
+			return
+		}
+		sessionID = newSessionID
+		session.Set("session_id", sessionID)
+	}
+
+	r := h.repoFor(c)
+	linked, err := r.GetCart(cartID)
+	if err != nil || linked.SessionID == sessionID {
+		return
+	}
+	userCart, err := r.GetOrCreateCart(sessionID, currentCartName(session))
+	if err != nil {
+		log.Printf("Failed to load cart: %v", err)
+		return
+	}
+	err = r.MergeAnonymousCart(cartID, userCart.ID)
+	if err != nil && !errors.Is(err, cart.ErrCartNotFound) && !errors.Is(err, cart.ErrCartLocked) {
+		log.Printf("Failed to merge cart: %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package api_test
+
+import (
+	"interview/internal/api"
+	"interview/internal/auth"
+	"interview/internal/cart"
+	"interview/internal/user"
+	"net/http"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var loginLinkPattern = regexp.MustCompile(`http://shop\.example\.com(/login/link\?\S+)`)
+
+// loginLinkSetup is a test environment with login links enabled
+type loginLinkSetup struct {
+	*testSetup
+	auth   *api.AuthHandler
+	mailer fakeMailer
+}
+
+func setupLoginLinks(t *testing.T, ttl time.Duration) *loginLinkSetup {
+	t.Helper()
+	ts := &loginLinkSetup{testSetup: setupTest(t), mailer: make(fakeMailer, 10)}
+	ts.auth = api.NewAuthHandler(ts.db)
+	ts.auth.EnableLoginLinks(auth.NewLoginLinks("http://shop.example.com", []byte("secret"), ttl), ts.mailer)
+	ts.handler.SetLoginLinks(true)
+	ts.router.POST(auth.LoginLinkPath, ts.auth.RequestLoginLink)
+	ts.router.GET(auth.LoginLinkPath, ts.auth.LoginWithLink)
+	ts.clearDatabase(t)
+	return ts
+}
+
+// requestLink asks for a login link for the session and returns its path
+func (ts *loginLinkSetup) requestLink(t *testing.T, email string, cookie *http.Cookie) string {
+	t.Helper()
+	w := ts.makeRequest(t, http.MethodPost, auth.LoginLinkPath, url.Values{"email": {email}}, cookie)
+	require.Equal(t, http.StatusFound, w.Code)
+	select {
+	case msg := <-ts.mailer:
+		match := loginLinkPattern.FindStringSubmatch(msg.Body)
+		require.NotNil(t, match, msg.Body)
+		return match[1]
+	case <-time.After(5 * time.Second):
+		t.Fatal("no login link email sent")
+		return ""
+	}
+}
+
+// cartQuantities returns the quantities of the products in the cart of the session
+func (ts *loginLinkSetup) cartQuantities(t *testing.T, cookie *http.Cookie) map[string]int {
+	t.Helper()
+	ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+	var carts []cart.Cart
+	require.NoError(t, ts.db.Preload("CartItems").Where("status = ?", cart.StatusOpen).Find(&carts).Error)
+	quantities := map[string]int{}
+	for _, c := range carts {
+		for _, item := range c.CartItems {
+			quantities[item.ProductName] += item.Quantity
+		}
+	}
+	return quantities
+}
+
+func TestLoginLinks(t *testing.T) {
+	t.Run("Creates The User", func(t *testing.T) {
+		ts := setupLoginLinks(t, time.Minute)
+		cookie := ts.createSession(t)
+		assert.Contains(t, ts.makeRequest(t, http.MethodGet, "/", nil, cookie).Body.String(), `action="/login/link"`)
+
+		link := ts.requestLink(t, " Jane@Example.com ", cookie)
+		assert.Contains(t, ts.makeRequest(t, http.MethodGet, "/", nil, cookie).Body.String(), "We sent you a link to log in")
+
+		w := ts.makeRequest(t, http.MethodGet, link, nil, cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+		assert.Contains(t, ts.makeRequest(t, http.MethodGet, "/", nil, cookie).Body.String(), "Logged in as jane@example.com")
+
+		var users []user.User
+		require.NoError(t, ts.db.Find(&users).Error)
+		require.Len(t, users, 1)
+		assert.Equal(t, auth.ProviderEmail, users[0].Provider)
+	})
+
+	t.Run("Merges The Cart Of The Other Browser", func(t *testing.T) {
+		ts := setupLoginLinks(t, time.Minute)
+		phone := ts.createSession(t)
+		ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"shoe"}, "quantity": {"2"}}, phone)
+		laptop := ts.createSession(t)
+		ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"shoe"}, "quantity": {"1"}}, laptop)
+		ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"bag"}, "quantity": {"1"}}, laptop)
+
+		link := ts.requestLink(t, "jane@example.com", phone)
+		require.Equal(t, http.StatusFound, ts.makeRequest(t, http.MethodGet, link, nil, laptop).Code)
+		assert.Equal(t, map[string]int{"shoe": 3, "bag": 1}, ts.cartQuantities(t, laptop))
+
+		var carts []cart.Cart
+		require.NoError(t, ts.db.Where("status = ?", cart.StatusOpen).Find(&carts).Error)
+		assert.Len(t, carts, 1, "the cart of the phone is merged")
+
+		// Opening the link again doesn't add the items twice
+		ts.makeRequest(t, http.MethodGet, link, nil, laptop)
+		assert.Equal(t, map[string]int{"shoe": 3, "bag": 1}, ts.cartQuantities(t, laptop))
+	})
+
+	t.Run("Login Replaces The Session", func(t *testing.T) {
+		ts := setupLoginLinks(t, time.Minute)
+		cookie := ts.createSession(t)
+		ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"shoe"}, "quantity": {"1"}}, cookie)
+		var anonymous cart.Cart
+		require.NoError(t, ts.db.Where("status = ?", cart.StatusOpen).First(&anonymous).Error)
+		// A session ID planted in the browser before the login, e.g. by an attacker
+		planted := *cookie
+
+		require.Equal(t, http.StatusFound, ts.makeRequest(t, http.MethodGet, ts.requestLink(t, "jane@example.com", cookie), nil, cookie).Code)
+		assert.NotEqual(t, planted.Value, cookie.Value)
+		assert.Contains(t, ts.makeRequest(t, http.MethodGet, "/", nil, cookie).Body.String(), "Logged in as jane@example.com")
+		assert.NotContains(t, ts.makeRequest(t, http.MethodGet, "/", nil, &planted).Body.String(), "Logged in as")
+
+		var moved cart.Cart
+		require.NoError(t, ts.db.First(&moved, anonymous.ID).Error)
+		assert.NotEqual(t, anonymous.SessionID, moved.SessionID, "the cart moves to the new session")
+		assert.Equal(t, map[string]int{"shoe": 1}, ts.cartQuantities(t, cookie))
+	})
+
+	t.Run("Same Browser Keeps Its Cart", func(t *testing.T) {
+		ts := setupLoginLinks(t, time.Minute)
+		cookie := ts.createSession(t)
+		ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"watch"}, "quantity": {"1"}}, cookie)
+
+		link := ts.requestLink(t, "jane@example.com", cookie)
+		ts.makeRequest(t, http.MethodGet, link, nil, cookie)
+		assert.Equal(t, map[string]int{"watch": 1}, ts.cartQuantities(t, cookie))
+	})
+
+	t.Run("Invalid Links", func(t *testing.T) {
+		ts := setupLoginLinks(t, -time.Minute)
+		cookie := ts.createSession(t)
+		expired := ts.requestLink(t, "jane@example.com", cookie)
+		for _, link := range []string{expired, auth.LoginLinkPath + "?email=jane%40example.com&expires=9999999999&signature=abc"} {
+			ts.makeRequest(t, http.MethodGet, link, nil, cookie)
+			body := ts.makeRequest(t, http.MethodGet, "/", nil, cookie).Body.String()
+			assert.Contains(t, body, "This link is invalid or has expired")
+			assert.NotContains(t, body, "Logged in as")
+		}
+	})
+
+	t.Run("Invalid Email", func(t *testing.T) {
+		ts := setupLoginLinks(t, time.Minute)
+		cookie := ts.createSession(t)
+		ts.makeRequest(t, http.MethodPost, auth.LoginLinkPath, url.Values{"email": {"Jane <jane@example.com>"}}, cookie)
+		assert.Contains(t, ts.makeRequest(t, http.MethodGet, "/", nil, cookie).Body.String(), "Please enter a valid email address")
+		assert.Empty(t, ts.mailer)
+	})
+
+	t.Run("Two-Factor Authentication Still Applies", func(t *testing.T) {
+		ts := setupLoginLinks(t, time.Minute)
+		require.NoError(t, ts.db.Create(&user.User{
+			Provider: auth.ProviderEmail, ProviderUserID: "jane@example.com", Email: "jane@example.com",
+			TOTPSecret: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", TOTPEnabled: true,
+		}).Error)
+		cookie := ts.createSession(t)
+
+		ts.makeRequest(t, http.MethodGet, ts.requestLink(t, "jane@example.com", cookie), nil, cookie)
+		body := ts.makeRequest(t, http.MethodGet, "/", nil, cookie).Body.String()
+		assert.NotContains(t, body, "Logged in as")
+		assert.Contains(t, body, `action="/auth/2fa"`)
+	})
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"fmt"
+	"interview/internal/audit"
+	"interview/internal/ratelimit"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// loginFailuresPerEmail and loginFailuresPerIP are how many logins to an email address and from an
+	// IP address may fail in a row before they are locked out for loginLockout, doubling with every
+	// further failure up to loginMaxLockout, unless configured otherwise
+	loginFailuresPerEmail = 5
+	loginFailuresPerIP    = 20
+	loginLockout          = time.Minute
+	loginMaxLockout       = time.Hour
+	// loginAttemptsWarning is how few attempts have to be left before failed logins tell how many
+	loginAttemptsWarning = 2
+	// maxEmailLength is the longest valid email address, longer input is cut before it is tracked
+	maxEmailLength = 254
+	// loginLockedMessage is shown for locked out logins, whether or not the password is right
+	loginLockedMessage = "Too many failed logins, please try again later"
+)
+
+// SetLoginLockout locks logins to an email address out after perEmail failed in a row, and logins from an
+// IP address after perIP, for base at first and twice as long with every further failure up to max.
+func (h *AuthHandler) SetLoginLockout(perEmail, perIP int, base, max time.Duration) {
+	h.emailLockout = ratelimit.NewLockout(perEmail, base, max)
+	h.ipLockout = ratelimit.NewLockout(perIP, base, max)
+}
+
+// loginLocked reports whether logins to the email address or from the client are locked out.
+func (h *AuthHandler) loginLocked(c *gin.Context, email string) bool {
+	return h.emailLockout.LockedFor(loginKey(email)) > 0 || h.ipLockout.LockedFor(c.ClientIP()) > 0
+}
+
+// loginFailed records a login to the email address that failed for a wrong password or second factor,
+// as method says, in the audit log, locking the address or the client out after too many in a row.
+// userID is nil for addresses without an account. It returns how many attempts are left before the
+// lockout, counted for unknown addresses too so they can't be told apart, and whether it started.
+func (h *AuthHandler) loginFailed(c *gin.Context, email string, userID *uint, method string) (left int, locked bool) {
+	email = loginKey(email)
+	ip := c.ClientIP()
+	emailLeft, emailLockedFor := h.emailLockout.Fail(email)
+	ipLeft, ipLockedFor := h.ipLockout.Fail(ip)
+
+	h.recordSecurityEvent(c, audit.ActionLoginFailed, userID, fmt.Sprintf("wrong %s for %q", method, email))
+	if emailLockedFor > 0 {
+		h.recordSecurityEvent(c, audit.ActionLoginLockedOut, userID, fmt.Sprintf("email address %q locked out for %s", email, emailLockedFor))
+	}
+	if ipLockedFor > 0 {
+		h.recordSecurityEvent(c, audit.ActionLoginLockedOut, nil, fmt.Sprintf("IP address locked out for %s", ipLockedFor))
+	}
+	return min(emailLeft, ipLeft), emailLockedFor > 0 || ipLockedFor > 0
+}
+
+// loginSucceeded forgets the failed logins to the email address. Those from the client still count, so
+// logging in to an account of one's own doesn't allow guessing the passwords of others.
+func (h *AuthHandler) loginSucceeded(email string) {
+	h.emailLockout.Reset(loginKey(email))
+}
+
+// recordSecurityEvent records the event in the audit log with the client as the actor.
+func (h *AuthHandler) recordSecurityEvent(c *gin.Context, action string, userID *uint, detail string) {
+	err := h.repoFor(c).RecordAudit(&audit.Entry{Actor: c.ClientIP(), Action: action, UserID: userID, Detail: detail})
+	if err != nil {
+		log.Printf("Failed to record security event %s: %v", action, err)
+	}
+}
+
+// loginKey returns the email address logins are tracked by.
+func loginKey(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if len(email) > maxEmailLength {
+		email = email[:maxEmailLength]
+	}
+	return email
+}
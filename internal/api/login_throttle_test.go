@@ -0,0 +1,79 @@
+package api_test
+
+import (
+	"interview/internal/audit"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginThrottling(t *testing.T) {
+	// setPassword gives the account of the fake Google user a password
+	setPassword := func(t *testing.T, ts *passwordResetSetup) {
+		t.Helper()
+		form := url.Values{"password": {"correct horse"}, "password_confirmation": {"correct horse"}}
+		require.Equal(t, http.StatusFound, ts.makeRequest(t, http.MethodPost, ts.requestReset(t), form, ts.cookie).Code)
+	}
+	auditActions := func(t *testing.T, ts *passwordResetSetup) map[string]int {
+		t.Helper()
+		var entries []audit.Entry
+		require.NoError(t, ts.db.Find(&entries).Error)
+		actions := map[string]int{}
+		for _, e := range entries {
+			actions[e.Action]++
+		}
+		return actions
+	}
+
+	t.Run("Locks The Account Out", func(t *testing.T) {
+		ts := setupPasswordReset(t)
+		setPassword(t, ts)
+
+		assert.Contains(t, ts.login(t, "wrong horse"), "Invalid email or password")
+		assert.NotContains(t, ts.login(t, "wrong horse"), "attempts left")
+		assert.Contains(t, ts.login(t, "wrong horse"), "Invalid email or password, attempts left: 2")
+		assert.Contains(t, ts.login(t, "wrong horse"), "Invalid email or password, attempts left: 1")
+		assert.Contains(t, ts.login(t, "wrong horse"), "Too many failed logins, please try again later")
+
+		body := ts.login(t, "correct horse")
+		assert.NotContains(t, body, "Logged in as", "the right password doesn't help while locked out")
+		assert.Contains(t, body, "Too many failed logins, please try again later")
+		assert.Equal(t, map[string]int{audit.ActionLoginFailed: 5, audit.ActionLoginLockedOut: 1}, auditActions(t, ts))
+
+		var locked audit.Entry
+		require.NoError(t, ts.db.Where("action = ?", audit.ActionLoginLockedOut).First(&locked).Error)
+		assert.NotNil(t, locked.UserID)
+		assert.Contains(t, locked.Detail, `"jane@example.com"`)
+		assert.NotEmpty(t, locked.Actor)
+	})
+
+	t.Run("Unknown Accounts Look The Same", func(t *testing.T) {
+		ts := setupPasswordReset(t)
+		login := func(email string) string {
+			cookie := ts.createSession(t)
+			ts.makeRequest(t, http.MethodPost, "/login", url.Values{"email": {email}, "password": {"guess"}}, cookie)
+			return ts.makeRequest(t, http.MethodGet, "/", nil, cookie).Body.String()
+		}
+		for i := 0; i < 2; i++ {
+			login("nobody@example.com")
+		}
+		assert.Contains(t, login(" Nobody@Example.com"), "Invalid email or password, attempts left: 2")
+
+		var failed audit.Entry
+		require.NoError(t, ts.db.Where("action = ?", audit.ActionLoginFailed).First(&failed).Error)
+		assert.Nil(t, failed.UserID)
+	})
+
+	t.Run("Successful Logins Start Over", func(t *testing.T) {
+		ts := setupPasswordReset(t)
+		setPassword(t, ts)
+		for i := 0; i < 4; i++ {
+			ts.login(t, "wrong horse")
+		}
+		assert.Contains(t, ts.login(t, "correct horse"), "Logged in as Jane")
+		assert.NotContains(t, ts.login(t, "wrong horse"), "attempts left")
+	})
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maintenanceMessage answers requests changing something during maintenance
+	maintenanceMessage = "The shop is under maintenance, please try again in a few minutes"
+	// maintenanceRetryAfter is how many seconds clients are asked to wait during maintenance
+	maintenanceRetryAfter = "300"
+	// maintenancePath is the admin endpoint switching maintenance mode, which has to work during it
+	maintenancePath = "/admin/maintenance"
+)
+
+type (
+	// Maintenance switches the shop to read-only mode, e.g. while the schema is migrated: pages are
+	// still shown, but requests changing anything are answered with 503 Service Unavailable.
+	Maintenance struct {
+		enabled atomic.Bool
+	}
+
+	// MaintenanceData contains data to be rendered in the maintenance template.
+	MaintenanceData struct {
+		Locale  string
+		Message string
+	}
+
+	// MaintenanceRequest switches maintenance mode on or off.
+	MaintenanceRequest struct {
+		Enabled *bool `json:"enabled" binding:"required"`
+	}
+
+	// MaintenanceResponse is the JSON representation of the maintenance mode.
+	MaintenanceResponse struct {
+		Enabled bool `json:"enabled"`
+	}
+)
+
+// Enabled reports whether the shop is in maintenance mode.
+func (m *Maintenance) Enabled() bool {
+	return m.enabled.Load()
+}
+
+// Set switches maintenance mode on or off.
+func (m *Maintenance) Set(enabled bool) {
+	m.enabled.Store(enabled)
+}
+
+// SetMaintenance sets the switch of maintenance mode.
+func (h *CartHandler) SetMaintenance(maintenance *Maintenance) {
+	h.maintenance = maintenance
+}
+
+// inMaintenance reports whether the shop is in maintenance mode.
+func (h *CartHandler) inMaintenance() bool {
+	return h.maintenance != nil && h.maintenance.Enabled()
+}
+
+// BlockDuringMaintenance is middleware answering requests that could change something with 503 Service
+// Unavailable during maintenance: a JSON error for the API and a page for browsers.
+func (h *CartHandler) BlockDuringMaintenance(c *gin.Context) {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		c.Next()
+		return
+	}
+	if !h.inMaintenance() || c.Request.URL.Path == maintenancePath {
+		c.Next()
+		return
+	}
+
+	h.abortUnavailable(c, maintenanceRetryAfter, maintenanceMessage)
+}
+
+// abortUnavailable answers the request with 503 Service Unavailable asking to retry after the given
+// seconds: a JSON error for the API and the maintenance page showing message for browsers.
+func (h *CartHandler) abortUnavailable(c *gin.Context, retryAfter, message string) {
+	c.Header("Retry-After", retryAfter)
+	if !isPagePath(c.Request.URL.Path) {
+		respondWithProblem(c, http.StatusServiceUnavailable, message)
+		return
+	}
+
+	locale := detectLocale(c, sessions.Default(c)).String()
+	c.Status(http.StatusServiceUnavailable)
+	data := MaintenanceData{Locale: locale, Message: message}
+	if err := h.templateFor(c).ExecuteTemplate(c.Writer, "maintenance.html", data); err != nil {
+		log.Printf("Failed to render template: %v", err)
+	}
+	c.Abort()
+}
+
+// SetMaintenance sets the switch of maintenance mode, enabling the maintenance endpoints.
+func (h *AdminHandler) SetMaintenance(maintenance *Maintenance) {
+	h.maintenance = maintenance
+}
+
+// ShowMaintenance returns whether the shop is in maintenance mode.
+func (h *AdminHandler) ShowMaintenance(c *gin.Context) {
+	c.JSON(http.StatusOK, MaintenanceResponse{Enabled: h.maintenance.Enabled()})
+}
+
+// UpdateMaintenance switches maintenance mode on or off.
+func (h *AdminHandler) UpdateMaintenance(c *gin.Context) {
+	var req MaintenanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondWithInvalidField(c, "enabled", "is required")
+		return
+	}
+	h.maintenance.Set(*req.Enabled)
+	log.Printf("Maintenance mode enabled: %t", *req.Enabled)
+	c.JSON(http.StatusOK, MaintenanceResponse{Enabled: *req.Enabled})
+}
@@ -0,0 +1,105 @@
+package api_test
+
+import (
+	"encoding/json"
+	"interview/internal/api"
+	"interview/internal/auth"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	ts := setupTest(t)
+	maintenance := &api.Maintenance{}
+	ts.handler.SetMaintenance(maintenance)
+
+	keys, err := auth.ParseKeySet("test:test_jwt_secret")
+	require.NoError(t, err)
+	apiRouter := gin.New()
+	apiRouter.Use(ts.handler.BlockDuringMaintenance)
+	ts.handler.RegisterAPIRoutes(apiRouter, auth.NewIssuer(keys))
+
+	admin := api.NewAdminHandler(ts.db, nil, time.Hour)
+	admin.SetMaintenance(maintenance)
+	admin.RegisterRoutes(ts.router, gin.Accounts{"admin": "secret"})
+
+	t.Run("Changes Work Without Maintenance", func(t *testing.T) {
+		ts.clearDatabase(t)
+		cookie := ts.createSession(t)
+		w := ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"shoe"}, "quantity": {"1"}}, cookie)
+		assert.Equal(t, http.StatusFound, w.Code)
+	})
+
+	t.Run("Shows Cart During Maintenance", func(t *testing.T) {
+		maintenance.Set(true)
+		t.Cleanup(func() { maintenance.Set(false) })
+
+		w := ts.makeRequest(t, http.MethodGet, "/", nil, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Answers Changes With Maintenance Page", func(t *testing.T) {
+		maintenance.Set(true)
+		t.Cleanup(func() { maintenance.Set(false) })
+		ts.clearDatabase(t)
+		cookie := ts.createSession(t)
+
+		w := ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"shoe"}, "quantity": {"1"}}, cookie)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, "300", w.Header().Get("Retry-After"))
+		assert.Contains(t, w.Body.String(), "The shop is under maintenance")
+
+		var items int64
+		require.NoError(t, ts.db.Table("cart_items").Count(&items).Error)
+		assert.Zero(t, items)
+	})
+
+	t.Run("Answers API Changes With JSON Error", func(t *testing.T) {
+		maintenance.Set(true)
+		t.Cleanup(func() { maintenance.Set(false) })
+
+		w := doJSON(t, apiRouter, http.MethodPost, "/api/v1/auth/token", "", nil)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.JSONEq(t, `{"type":"about:blank","title":"Service Unavailable","status":503,
+			"detail":"The shop is under maintenance, please try again in a few minutes","instance":"/api/v1/auth/token"}`, w.Body.String())
+	})
+
+	t.Run("Admin Switches Maintenance Mode", func(t *testing.T) {
+		t.Cleanup(func() { maintenance.Set(false) })
+
+		req := httptest.NewRequest(http.MethodPost, "/admin/maintenance", strings.NewReader(`{"enabled":true}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		ts.router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.True(t, maintenance.Enabled())
+
+		req = httptest.NewRequest(http.MethodGet, "/admin/maintenance", nil)
+		req.SetBasicAuth("admin", "secret")
+		w = httptest.NewRecorder()
+		ts.router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp api.MaintenanceResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.True(t, resp.Enabled)
+	})
+
+	t.Run("Rejects Missing Flag", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/admin/maintenance", strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		ts.router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.False(t, maintenance.Enabled())
+	})
+}
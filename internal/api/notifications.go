@@ -0,0 +1,172 @@
+package api
+
+import (
+	"errors"
+	"interview/internal/cart"
+	"interview/internal/events"
+	"interview/internal/i18n"
+	"interview/internal/notification"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// notificationsShown is how many notifications the cart page lists
+	notificationsShown = 10
+	// notificationPageSize is how many notifications GET /api/v1/notifications returns
+	notificationPageSize = 50
+)
+
+type (
+	// NotificationView represents a notification on the cart page.
+	NotificationView struct {
+		Time    string
+		Message string
+		Unread  bool
+	}
+
+	// NotificationResponse is the JSON representation of a notification. Product and Price are set for
+	// price drops, OrderID for shipped orders.
+	NotificationResponse struct {
+		ID        uint       `json:"id"`
+		Kind      string     `json:"kind"`
+		Product   string     `json:"product,omitempty"`
+		Price     float64    `json:"price,omitempty"`
+		OrderID   uint       `json:"order_id,omitempty"`
+		ReadAt    *time.Time `json:"read_at,omitempty"`
+		CreatedAt time.Time  `json:"created_at"`
+	}
+
+	// NotificationList is the response of GET /api/v1/notifications: the latest notifications, newest
+	// first, and how many notifications are unread.
+	NotificationList struct {
+		Unread        int64                  `json:"unread"`
+		Notifications []NotificationResponse `json:"notifications"`
+	}
+)
+
+// APIListNotifications returns the latest notifications of the authenticated session.
+func (h *CartHandler) APIListNotifications(c *gin.Context) {
+	notifications, unread, err := h.repoFor(c).ListNotifications(0, c.GetString(apiSessionKey), notificationPageSize)
+	if err != nil {
+		log.Printf("Failed to list notifications: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to list notifications")
+		return
+	}
+	resp := NotificationList{Unread: unread, Notifications: make([]NotificationResponse, len(notifications))}
+	for i, n := range notifications {
+		resp.Notifications[i] = NotificationResponse{
+			ID:        n.ID,
+			Kind:      n.Kind,
+			Product:   n.Product,
+			Price:     n.Price,
+			OrderID:   n.OrderID,
+			ReadAt:    n.ReadAt,
+			CreatedAt: n.CreatedAt,
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// APIMarkNotificationRead marks a notification of the authenticated session read.
+func (h *CartHandler) APIMarkNotificationRead(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid notification ID")
+		return
+	}
+	err = h.repoFor(c).MarkNotificationRead(0, c.GetString(apiSessionKey), uint(id), time.Now())
+	if errors.Is(err, notification.ErrNotificationNotFound) {
+		respondWithProblem(c, http.StatusNotFound, "notification not found")
+		return
+	} else if err != nil {
+		log.Printf("Failed to mark notification %d read: %v", id, err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to mark notification read")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// APIMarkAllNotificationsRead marks every notification of the authenticated session read.
+func (h *CartHandler) APIMarkAllNotificationsRead(c *gin.Context) {
+	if err := h.repoFor(c).MarkAllNotificationsRead(0, c.GetString(apiSessionKey), time.Now()); err != nil {
+		log.Printf("Failed to mark notifications read: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to mark notifications read")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// MarkNotificationsRead marks the notifications shown on the cart page read.
+func (h *CartHandler) MarkNotificationsRead(c *gin.Context) {
+	session := sessions.Default(c)
+	sessionID, _ := session.Get("session_id").(string)
+	if sessionID == "" {
+		c.Redirect(http.StatusFound, "/")
+		return
+	}
+	if err := h.repoFor(c).MarkAllNotificationsRead(sessionUserIDValue(session), sessionID, time.Now()); err != nil {
+		log.Printf("Failed to mark notifications read: %v", err)
+		h.redirectWithFlash(c, session, "Failed to update notifications")
+		return
+	}
+	c.Redirect(http.StatusFound, "/")
+}
+
+// addNotifications lists the latest notifications of the logged-in user, or of the session of anonymous
+// customers, on the cart page.
+func (h *CartHandler) addNotifications(c *gin.Context, session sessions.Session, data *TemplateData, sessionID string) {
+	notifications, unread, err := h.repoFor(c).ListNotifications(sessionUserIDValue(session), sessionID, notificationsShown)
+	if err != nil {
+		log.Printf("Failed to load notifications: %v", err)
+		return
+	}
+	data.UnreadNotifications = unread
+	for _, n := range notifications {
+		data.Notifications = append(data.Notifications, NotificationView{
+			Time:    n.CreatedAt.UTC().Format("2006-01-02 15:04 MST"),
+			Message: h.describeNotification(n, data.Locale, data.Currency),
+			Unread:  n.ReadAt == nil,
+		})
+	}
+}
+
+// describeNotification returns the sentence telling customers about the notification in their language
+func (h *CartHandler) describeNotification(n notification.Notification, locale, currency string) string {
+	switch n.Kind {
+	case notification.KindPriceDropped:
+		return i18n.T(locale, "The price of %s in your cart dropped to %s", n.Product, h.currencies.Format(n.Price, currency))
+	case notification.KindOrderShipped:
+		return i18n.T(locale, "Your order #%d has shipped", n.OrderID)
+	}
+	return n.Kind
+}
+
+// publishPriceDrops publishes an events.TypePriceDropped event for each item of the cart whose price
+// is lower after it was repriced than before.
+func publishPriceDrops(bus *events.Bus, before, after *cart.Cart) {
+	if bus == nil || !bus.Wants(events.TypePriceDropped) {
+		return
+	}
+	prices := make(map[uint]float64, len(before.CartItems))
+	for _, item := range before.CartItems {
+		prices[item.ID] = item.Price
+	}
+	for _, item := range after.CartItems {
+		if price, ok := prices[item.ID]; ok && item.Price < price {
+			bus.Publish(events.Event{
+				Type:     events.TypePriceDropped,
+				CartID:   after.ID,
+				Product:  item.ProductName,
+				Quantity: item.Quantity,
+				Price:    item.Price,
+				Total:    after.Total,
+			})
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"interview/internal/api"
+	"interview/internal/cart"
+	"interview/internal/events"
+	"interview/internal/notification"
+	"interview/internal/repo"
+	"interview/internal/storage"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotifications(t *testing.T) {
+	t.Run("Price Drops Show On The Cart Page", func(t *testing.T) {
+		ts := setupTest(t)
+		ts.clearDatabase(t)
+		cartRepo := repo.NewRepository(ts.db)
+		_, err := cartRepo.UpsertProduct("shoe", 10.0)
+		require.NoError(t, err)
+
+		bus := events.NewBus()
+		bus.Attach(notification.NewNotifier(cartRepo))
+
+		router := gin.New()
+		admin := api.NewAdminHandler(ts.db, storage.NewLocal(t.TempDir(), "/media", []byte("test_secret")), time.Hour)
+		admin.SetEventBus(bus)
+		admin.RegisterRoutes(router, gin.Accounts{"admin": "secret"})
+
+		cookie := ts.createSession(t)
+		ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"shoe"}, "quantity": {"2"}}, cookie)
+		var shoeCart cart.Cart
+		require.NoError(t, ts.db.Where("status = ?", cart.StatusOpen).First(&shoeCart).Error)
+		assert.NotContains(t, ts.makeRequest(t, http.MethodGet, "/", nil, cookie).Body.String(), "Mark all as read")
+
+		_, err = cartRepo.UpsertProduct("shoe", 1.0)
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		require.NoError(t, json.NewEncoder(&buf).Encode(api.RepriceCartsRequest{CartIDs: []uint{shoeCart.ID}}))
+		req := httptest.NewRequest(http.MethodPost, "/admin/carts/reprice", &buf)
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var body string
+		require.Eventually(t, func() bool {
+			body = ts.makeRequest(t, http.MethodGet, "/", nil, cookie).Body.String()
+			return strings.Contains(body, "The price of shoe in your cart dropped to")
+		}, time.Second, 10*time.Millisecond)
+		assert.Contains(t, body, "Mark all as read")
+
+		w = ts.makeRequest(t, http.MethodPost, "/notifications/read", nil, cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+		body = ts.makeRequest(t, http.MethodGet, "/", nil, cookie).Body.String()
+		assert.Contains(t, body, "The price of shoe in your cart dropped to", "read notifications stay listed")
+		assert.NotContains(t, body, "Mark all as read")
+	})
+
+	t.Run("API", func(t *testing.T) {
+		ts := setupTest(t)
+		ts.clearDatabase(t)
+		router := setupAPIRouter(t, ts)
+		cartRepo := repo.NewRepository(ts.db)
+		token := issueToken(t, router).AccessToken
+
+		var apiCart cart.Cart
+		require.NoError(t, ts.db.First(&apiCart).Error)
+		for _, orderID := range []uint{1, 2} {
+			require.NoError(t, cartRepo.NotifyCartOwner(apiCart.ID, &notification.Notification{Kind: notification.KindOrderShipped, OrderID: orderID}))
+		}
+		other, err := cartRepo.GetOrCreateCart("other", cart.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.NotifyCartOwner(other.ID, &notification.Notification{Kind: notification.KindPriceDropped, Product: "shoe", Price: 8}))
+
+		list := func(t *testing.T) api.NotificationList {
+			t.Helper()
+			w := doJSON(t, router, http.MethodGet, "/api/v1/notifications", token, nil)
+			require.Equal(t, http.StatusOK, w.Code)
+			var resp api.NotificationList
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			return resp
+		}
+		resp := list(t)
+		assert.EqualValues(t, 2, resp.Unread)
+		require.Len(t, resp.Notifications, 2, "only the notifications of the session")
+		assert.Equal(t, notification.KindOrderShipped, resp.Notifications[0].Kind)
+		assert.Equal(t, uint(2), resp.Notifications[0].OrderID)
+		assert.Nil(t, resp.Notifications[0].ReadAt)
+
+		first := resp.Notifications[1].ID
+		w := doJSON(t, router, http.MethodPost, "/api/v1/notifications/"+strconv.FormatUint(uint64(first), 10)+"/read", token, nil)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		resp = list(t)
+		assert.EqualValues(t, 1, resp.Unread)
+		assert.NotNil(t, resp.Notifications[1].ReadAt)
+
+		var others notification.Notification
+		require.NoError(t, ts.db.Where("session_id = ?", "other").First(&others).Error)
+		w = doJSON(t, router, http.MethodPost, "/api/v1/notifications/"+strconv.FormatUint(uint64(others.ID), 10)+"/read", token, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w = doJSON(t, router, http.MethodPost, "/api/v1/notifications/abc/read", token, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = doJSON(t, router, http.MethodPost, "/api/v1/notifications/read", token, nil)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Zero(t, list(t).Unread)
+	})
+}
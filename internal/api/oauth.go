@@ -0,0 +1,213 @@
+package api
+
+import (
+	"crypto/subtle"
+	"html/template"
+	"interview/internal/auth"
+	"interview/internal/cart"
+	"interview/internal/mail"
+	"interview/internal/ratelimit"
+	"interview/internal/repo"
+	"interview/internal/theme"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// AuthHandler serves the OAuth2 social login flow.
+type AuthHandler struct {
+	repo      *repo.Repository
+	providers map[string]*auth.OAuthProvider
+	// totpIssuer names the shop in authenticator apps
+	totpIssuer string
+	// template renders the password reset page, mailer sends reset links pointing to baseURL that
+	// are valid for resetTTL
+	template *template.Template
+	mailer   mail.Mailer
+	baseURL  string
+	resetTTL time.Duration
+	// themes restyle the reset page for the shop of the request, nil for the built-in look
+	themes *theme.Themes
+	// loginLinks signs the login links sent by mailer, nil when they are disabled
+	loginLinks *auth.LoginLinks
+	// emailIPLimiter and emailLimiter limit the password reset and login emails requested
+	emailIPLimiter *ratelimit.Limiter
+	emailLimiter   *ratelimit.Limiter
+	// emailLockout and ipLockout lock logins to an email address and from an IP address out after too
+	// many failed in a row
+	emailLockout *ratelimit.Lockout
+	ipLockout    *ratelimit.Lockout
+	// maxSessions is how many sessions a user may be logged in to at once, 0 for no limit. The open carts
+	// of sessions logged out for it are handled as evictedCarts says, see user.EvictedCartsKeep.
+	// Sessions count while used within sessionMaxAge.
+	maxSessions   int
+	evictedCarts  string
+	sessionMaxAge time.Duration
+	// verification emails new accounts a link verifying their email address, nil when it is disabled
+	verification *emailVerification
+}
+
+// NewAuthHandler creates a new AuthHandler for the given login providers.
+func NewAuthHandler(db *gorm.DB, providers ...*auth.OAuthProvider) *AuthHandler {
+	byName := make(map[string]*auth.OAuthProvider, len(providers))
+	for _, p := range providers {
+		byName[p.Name] = p
+	}
+	return &AuthHandler{
+		repo:       repo.NewRepository(db),
+		providers:  byName,
+		totpIssuer: "Shopping Cart",
+
+		emailIPLimiter: ratelimit.NewLimiter(emailRequestsPerIP, time.Hour),
+		emailLimiter:   ratelimit.NewLimiter(emailRequestsPerEmail, time.Hour),
+		emailLockout:   ratelimit.NewLockout(loginFailuresPerEmail, loginLockout, loginMaxLockout),
+		ipLockout:      ratelimit.NewLockout(loginFailuresPerIP, loginLockout, loginMaxLockout),
+	}
+}
+
+// Providers returns the names of the configured login providers.
+func (h *AuthHandler) Providers() []string {
+	names := make([]string, 0, len(h.providers))
+	for name := range h.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Login redirects the user to the provider's consent page.
+func (h *AuthHandler) Login(c *gin.Context) {
+	provider, ok := h.providers[c.Param("provider")]
+	if !ok {
+		c.String(http.StatusNotFound, "Unknown login provider")
+		return
+	}
+
+	state, err := generateSessionID()
+	if err != nil {
+		log.Printf("Failed to generate OAuth state: %v", err)
+		c.String(http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	session := sessions.Default(c)
+	session.Set("oauth_state", state)
+	if err := session.Save(); err != nil {
+		log.Printf("Failed to save session: %v", err)
+		c.String(http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	c.Redirect(http.StatusFound, provider.AuthCodeURL(state))
+}
+
+// Callback completes the login: it verifies the state, creates the user on first login and
+// links the session cart to the user. Users with two-factor authentication are asked for a code first.
+func (h *AuthHandler) Callback(c *gin.Context) {
+	session := sessions.Default(c)
+
+	provider, ok := h.providers[c.Param("provider")]
+	if !ok {
+		c.String(http.StatusNotFound, "Unknown login provider")
+		return
+	}
+
+	expectedState, _ := session.Get("oauth_state").(string)
+	session.Delete("oauth_state")
+	state := c.Query("state")
+	if expectedState == "" || subtle.ConstantTimeCompare([]byte(state), []byte(expectedState)) != 1 {
+		h.failLogin(c, session, "Login failed, please try again")
+		return
+	}
+
+	profile, err := provider.Exchange(c.Request.Context(), c.Query("code"))
+	if err != nil {
+		log.Printf("OAuth login with %s failed: %v", provider.Name, err)
+		h.failLogin(c, session, "Login failed, please try again")
+		return
+	}
+
+	u, err := h.repoFor(c).FindOrCreateUser(provider.Name, profile.ID, profile.Email, profile.Name)
+	if err != nil {
+		log.Printf("Failed to load user: %v", err)
+		h.failLogin(c, session, "Login failed, please try again")
+		return
+	}
+
+	if u.TOTPEnabled {
+		startPendingLogin(c, session, u.ID)
+		return
+	}
+	h.completeLogin(c, session, u.ID)
+}
+
+// completeLogin logs the user in to a new session, taking the carts, device, addresses and preferences
+// of the anonymous one along, and links the session cart and addresses to them.
+func (h *AuthHandler) completeLogin(c *gin.Context, session sessions.Session, userID uint) {
+	anonymousID, _ := session.Get("session_id").(string)
+	sessionID, err := rotateSession(session, "cart_name", "locale", currencySessionKey)
+	if err != nil {
+		log.Printf("Failed to rotate session: %v", err)
+		h.failLogin(c, session, "Failed to create session")
+		return
+	}
+
+	if anonymousID != "" {
+		if err := h.repoFor(c).MoveSessionCarts(anonymousID, sessionID); err != nil {
+			log.Printf("Failed to move carts to the new session: %v", err)
+		}
+		if err := h.repoFor(c).MoveDevice(anonymousID, sessionID); err != nil {
+			log.Printf("Failed to move device to the new session: %v", err)
+		}
+		if err := h.repoFor(c).AssignAddressesToUser(anonymousID, userID); err != nil {
+			log.Printf("Failed to link addresses to user: %v", err)
+		}
+	}
+	if _, err := h.repoFor(c).GetOrCreateCart(sessionID, cart.DefaultName); err != nil {
+		log.Printf("Failed to load cart: %v", err)
+	} else if err := h.repoFor(c).AssignCartToUser(sessionID, userID); err != nil {
+		log.Printf("Failed to link cart to user: %v", err)
+	}
+	h.limitSessions(c, session, sessionID, userID)
+
+	// Pages follow the language and currency chosen on the account page from now on
+	if u, err := h.repoFor(c).GetUser(userID); err == nil {
+		h.verifyNewAccount(c, u)
+		if u.Locale != "" {
+			session.Set("locale", u.Locale)
+		}
+		if u.Currency != "" {
+			session.Set(currencySessionKey, u.Currency)
+		}
+	}
+
+	session.Set("user_id", userID)
+	if err := session.Save(); err != nil {
+		log.Printf("Failed to save session: %v", err)
+	}
+	c.Redirect(http.StatusFound, "/")
+}
+
+// Logout forgets the logged-in user. The session cart stays with the browser.
+func (h *AuthHandler) Logout(c *gin.Context) {
+	session := sessions.Default(c)
+	session.Delete("user_id")
+	clearPendingLogin(session)
+	if err := session.Save(); err != nil {
+		log.Printf("Failed to save session: %v", err)
+	}
+	c.Redirect(http.StatusFound, "/")
+}
+
+func (h *AuthHandler) failLogin(c *gin.Context, session sessions.Session, message string) {
+	session.AddFlash(message)
+	if err := session.Save(); err != nil {
+		log.Printf("Failed to save session: %v", err)
+	}
+	c.Redirect(http.StatusFound, "/")
+}
@@ -0,0 +1,110 @@
+package api_test
+
+import (
+	"encoding/json"
+	"interview/internal/api"
+	"interview/internal/auth"
+	"interview/internal/user"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+// newFakeGoogle starts a server emulating Google's token and userinfo endpoints
+func newFakeGoogle(t *testing.T) *auth.OAuthProvider {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "google-token", "token_type": "Bearer"})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{"sub": "g-123", "email": "jane@example.com", "name": "Jane"})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	p := auth.NewGoogleProvider("client", "secret", "http://localhost/auth/google/callback")
+	p.Config.Endpoint = oauth2.Endpoint{AuthURL: srv.URL + "/auth", TokenURL: srv.URL + "/token"}
+	p.ProfileURL = srv.URL + "/userinfo"
+	return p
+}
+
+// setupAuthRoutes adds the social login routes to the test router
+func setupAuthRoutes(t *testing.T, ts *testSetup) *gin.Engine {
+	t.Helper()
+	authHandler := api.NewAuthHandler(ts.db, newFakeGoogle(t))
+	ts.handler.SetLoginProviders(authHandler.Providers())
+	ts.router.GET("/auth/:provider/login", authHandler.Login)
+	ts.router.GET("/auth/:provider/callback", authHandler.Callback)
+	return ts.router
+}
+
+func TestOAuthLogin(t *testing.T) {
+	ts := setupTest(t)
+	setupAuthRoutes(t, ts)
+
+	login := func(t *testing.T, cookie *http.Cookie) string {
+		t.Helper()
+		w := ts.makeRequest(t, http.MethodGet, "/auth/google/login", nil, cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+		location, err := url.Parse(w.Header().Get("Location"))
+		require.NoError(t, err)
+		state := location.Query().Get("state")
+		require.NotEmpty(t, state)
+		return state
+	}
+
+	t.Run("Cart Page Offers Login", func(t *testing.T) {
+		ts.clearDatabase(t)
+		w := ts.makeRequest(t, http.MethodGet, "/", nil, nil)
+		assert.Contains(t, w.Body.String(), "/auth/google/login")
+	})
+
+	t.Run("Unknown Provider", func(t *testing.T) {
+		w := ts.makeRequest(t, http.MethodGet, "/auth/myspace/login", nil, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Callback Creates User And Links Cart", func(t *testing.T) {
+		ts.clearDatabase(t)
+		cookie := ts.createSession(t)
+		state := login(t, cookie)
+
+		w := ts.makeRequest(t, http.MethodGet, "/auth/google/callback?code=abc&state="+state, nil, cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+
+		var users []user.User
+		require.NoError(t, ts.db.Find(&users).Error)
+		require.Len(t, users, 1)
+		assert.Equal(t, "jane@example.com", users[0].Email)
+
+		carts, err := ts.handler.GetRepo().GetAllCarts()
+		require.NoError(t, err)
+		require.Len(t, carts, 1)
+		require.NotNil(t, carts[0].UserID)
+		assert.Equal(t, users[0].ID, *carts[0].UserID)
+
+		w = ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.Contains(t, w.Body.String(), "Logged in as Jane")
+	})
+
+	t.Run("Callback Rejects Wrong State", func(t *testing.T) {
+		ts.clearDatabase(t)
+		cookie := ts.createSession(t)
+		login(t, cookie)
+
+		w := ts.makeRequest(t, http.MethodGet, "/auth/google/callback?code=abc&state=forged", nil, cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+
+		var count int64
+		require.NoError(t, ts.db.Model(&user.User{}).Count(&count).Error)
+		assert.Zero(t, count)
+	})
+}
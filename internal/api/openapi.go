@@ -0,0 +1,21 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// openAPIv1 is the OpenAPI 3 description of version 1 of the JSON API, for generating typed clients.
+// Endpoints added to registerAPIv1 are documented in it too; TestOpenAPISpec fails otherwise.
+//
+//go:embed openapi.json
+var openAPIv1 []byte
+
+// serveOpenAPISpec returns a handler serving the OpenAPI description of an API version.
+func serveOpenAPISpec(spec []byte) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", spec)
+	}
+}
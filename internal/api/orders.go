@@ -0,0 +1,309 @@
+package api
+
+import (
+	"errors"
+	"interview/internal/auth"
+	"interview/internal/events"
+	"interview/internal/order"
+	"interview/internal/repo"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type (
+	// TransitionOrderRequest is the JSON body accepted by POST /admin/orders/:id/transition.
+	TransitionOrderRequest struct {
+		Status string `json:"status"`
+		Note   string `json:"note"`
+	}
+
+	// OrderResponse is the JSON representation of an order. Next lists the statuses it can change to,
+	// one button each in the back office, and Allocations the warehouses its items ship from.
+	OrderResponse struct {
+		ID          uint                   `json:"id"`
+		CartID      uint                   `json:"cart_id"`
+		Status      string                 `json:"status"`
+		Next        []string               `json:"next"`
+		History     []OrderHistoryResponse `json:"history,omitempty"`
+		Allocations []AllocationResponse   `json:"allocations,omitempty"`
+		VATEvidence *VATEvidenceResponse   `json:"vat_evidence,omitempty"`
+		// ShippingAddress and BillingAddress are the addresses chosen at checkout, nil when none was
+		ShippingAddress *OrderAddressResponse `json:"shipping_address,omitempty"`
+		BillingAddress  *OrderAddressResponse `json:"billing_address,omitempty"`
+		CreatedAt       time.Time             `json:"created_at"`
+		UpdatedAt       time.Time             `json:"updated_at"`
+	}
+
+	// OrderPage is a page of orders, newest first. NextCursor is passed as the after parameter to fetch
+	// older orders and is omitted on the last page.
+	OrderPage struct {
+		Orders     []OrderResponse `json:"orders"`
+		NextCursor string          `json:"next_cursor,omitempty"`
+	}
+
+	// OrderAddressResponse is the JSON representation of an address copied onto an order.
+	OrderAddressResponse struct {
+		Name       string `json:"name"`
+		Line1      string `json:"line1"`
+		Line2      string `json:"line2,omitempty"`
+		City       string `json:"city"`
+		PostalCode string `json:"postal_code"`
+		Country    string `json:"country"`
+		Phone      string `json:"phone,omitempty"`
+	}
+
+	// VATEvidenceResponse is the JSON representation of the validation of the VAT ID an order was placed
+	// with.
+	VATEvidenceResponse struct {
+		VATID              string    `json:"vat_id"`
+		Valid              bool      `json:"valid"`
+		Exempt             bool      `json:"exempt"`
+		Name               string    `json:"name,omitempty"`
+		Address            string    `json:"address,omitempty"`
+		ConsultationNumber string    `json:"consultation_number,omitempty"`
+		Offline            bool      `json:"offline"`
+		CheckedAt          time.Time `json:"checked_at"`
+	}
+
+	// OrderHistoryResponse is the JSON representation of a status change of an order.
+	OrderHistoryResponse struct {
+		From      string    `json:"from,omitempty"`
+		To        string    `json:"to"`
+		Actor     string    `json:"actor"`
+		Note      string    `json:"note,omitempty"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+)
+
+// ListOrders returns a page of the orders, newest first, only those with the status of the "status"
+// query parameter if given, paginated with ?after=<cursor>&limit=<n>.
+func (h *AdminHandler) ListOrders(c *gin.Context) {
+	status := c.Query("status")
+	if status != "" && !order.ValidStatus(status) {
+		respondWithProblem(c, http.StatusBadRequest, "invalid order status")
+		return
+	}
+	after, limit, ok := parsePageParams(c)
+	if !ok {
+		return
+	}
+
+	// Fetch one extra row to know whether another page follows
+	orders, err := h.repoFor(c).ListOrders(status, after, limit+1)
+	if err != nil {
+		log.Printf("Failed to list orders: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to list orders")
+		return
+	}
+
+	page := OrderPage{Orders: []OrderResponse{}}
+	if len(orders) > limit {
+		orders = orders[:limit]
+		page.NextCursor = strconv.FormatUint(uint64(orders[limit-1].ID), 10)
+	}
+	for _, o := range orders {
+		page.Orders = append(page.Orders, newOrderResponse(o))
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// ShowOrder returns an order with its history and the warehouses its items ship from.
+func (h *AdminHandler) ShowOrder(c *gin.Context) {
+	id, ok := orderID(c)
+	if !ok {
+		return
+	}
+	o, err := h.repoFor(c).GetOrder(id)
+	if errors.Is(err, order.ErrOrderNotFound) {
+		respondWithProblem(c, http.StatusNotFound, "order not found")
+		return
+	} else if err != nil {
+		log.Printf("Failed to load order: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to load order")
+		return
+	}
+	allocations, err := h.repoFor(c).ListAllocations(o.CartID)
+	if err != nil {
+		log.Printf("Failed to load allocations: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to load order")
+		return
+	}
+	response := newOrderResponse(*o)
+	for _, a := range allocations {
+		response.Allocations = append(response.Allocations, AllocationResponse{
+			CartItemID: a.CartItemID, Product: a.ProductName, Warehouse: a.WarehouseName, Quantity: a.Quantity,
+		})
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// TransitionOrder changes the status of an order along the allowed transitions. Refunding an order
+// requires the permission to issue refunds and refunds all that is left of it, see refundOrder.
+func (h *AdminHandler) TransitionOrder(c *gin.Context) {
+	id, ok := orderID(c)
+	if !ok {
+		return
+	}
+	var req TransitionOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if !order.ValidStatus(req.Status) {
+		respondWithProblem(c, http.StatusBadRequest, "invalid order status")
+		return
+	}
+	o, err := h.repoFor(c).GetOrder(id)
+	if errors.Is(err, order.ErrOrderNotFound) {
+		respondWithProblem(c, http.StatusNotFound, "order not found")
+		return
+	} else if err != nil {
+		log.Printf("Failed to load order: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to load order")
+		return
+	}
+	if !order.CanTransition(o.Status, req.Status) {
+		respondWithProblem(c, http.StatusConflict, "order can't change from "+o.Status+" to "+req.Status)
+		return
+	}
+	if req.Status == order.StatusRefunded {
+		h.refundOrder(c, o, req.Note)
+		return
+	}
+
+	o, err = h.repoFor(c).TransitionOrder(id, req.Status, staffActor(c), req.Note)
+	if errors.Is(err, order.ErrInvalidTransition) || errors.Is(err, repo.ErrConflict) {
+		respondWithProblem(c, http.StatusConflict, "order changed meanwhile, please reload it")
+		return
+	} else if err != nil {
+		log.Printf("Failed to change status of order %d: %v", id, err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to change order status")
+		return
+	}
+	h.publishOrderStatus(c, o)
+	c.JSON(http.StatusOK, newOrderResponse(*o))
+}
+
+// refundOrder refunds all that is left of the order like CreateRefund does, paying back a pending
+// refund of it first, so the items refunded are recorded and put back into stock like those of any refund.
+func (h *AdminHandler) refundOrder(c *gin.Context, o *order.Order, note string) {
+	if !auth.Can(c.GetString(staffRoleKey), auth.PermIssueRefunds) {
+		respondWithProblem(c, http.StatusForbidden, "permission denied")
+		return
+	}
+	r := h.repoFor(c)
+	refunds, err := r.ListRefunds(o.ID)
+	if err != nil {
+		log.Printf("Failed to list refunds of order %d: %v", o.ID, err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to refund order")
+		return
+	}
+	for i := range refunds {
+		if refunds[i].Status != order.RefundPending {
+			continue
+		}
+		if _, ok := h.payRefund(c, &refunds[i]); !ok {
+			return
+		}
+	}
+
+	refund, err := r.StartRefund(o.ID, nil, 0, staffActor(c), note)
+	switch {
+	case errors.Is(err, order.ErrNotRefundable):
+		// Paying back the pending refund refunded the order
+	case errors.Is(err, order.ErrInvalidRefund):
+		respondWithProblem(c, http.StatusConflict, "the order has nothing left to refund")
+		return
+	case err != nil:
+		log.Printf("Failed to start refund of order %d: %v", o.ID, err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to refund order")
+		return
+	default:
+		if _, ok := h.payRefund(c, refund); !ok {
+			return
+		}
+	}
+
+	refunded, err := r.GetOrder(o.ID)
+	if err != nil {
+		log.Printf("Failed to load order %d: %v", o.ID, err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to load order")
+		return
+	}
+	c.JSON(http.StatusOK, newOrderResponse(*refunded))
+}
+
+// publishOrderStatus publishes the status of an order that just changed.
+func (h *AdminHandler) publishOrderStatus(c *gin.Context, o *order.Order) {
+	if h.events == nil {
+		return
+	}
+	e := events.Event{Type: events.TypeOrderStatusChanged, CartID: o.CartID, OrderID: o.ID, Status: o.Status}
+	if orderCart, err := h.repoFor(c).GetCart(o.CartID); err == nil {
+		e.Total = orderCart.Total
+	}
+	h.events.Publish(e)
+}
+
+// staffActor names the staff member of the request in order histories: the basic auth user or, for
+// staff logged in otherwise, their role.
+func staffActor(c *gin.Context) string {
+	if name := c.GetString(gin.AuthUserKey); name != "" {
+		return name
+	}
+	return c.GetString(staffRoleKey)
+}
+
+// orderID parses the "id" parameter. When ok is false, the response was sent already.
+func orderID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid order ID")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func newOrderResponse(o order.Order) OrderResponse {
+	history := make([]OrderHistoryResponse, len(o.History))
+	for i, h := range o.History {
+		history[i] = OrderHistoryResponse{From: h.FromStatus, To: h.ToStatus, Actor: h.Actor, Note: h.Note, CreatedAt: h.CreatedAt}
+	}
+	next := order.Next(o.Status)
+	if next == nil {
+		next = []string{}
+	}
+	response := OrderResponse{
+		ID:        o.ID,
+		CartID:    o.CartID,
+		Status:    o.Status,
+		Next:      next,
+		History:   history,
+		CreatedAt: o.CreatedAt,
+		UpdatedAt: o.UpdatedAt,
+	}
+	response.ShippingAddress = newOrderAddressResponse(o.ShippingAddress)
+	response.BillingAddress = newOrderAddressResponse(o.BillingAddress)
+	if e := o.VATEvidence; e != nil {
+		response.VATEvidence = &VATEvidenceResponse{
+			VATID: e.VATID, Valid: e.Valid, Exempt: e.Exempt, Name: e.Name, Address: e.Address,
+			ConsultationNumber: e.ConsultationNumber, Offline: e.Offline, CheckedAt: e.CheckedAt,
+		}
+	}
+	return response
+}
+
+// newOrderAddressResponse returns the JSON representation of an address of an order, nil when it is empty
+func newOrderAddressResponse(a order.Address) *OrderAddressResponse {
+	if a == (order.Address{}) {
+		return nil
+	}
+	return &OrderAddressResponse{
+		Name: a.Name, Line1: a.Line1, Line2: a.Line2, City: a.City, PostalCode: a.PostalCode, Country: a.Country,
+		Phone: a.Phone,
+	}
+}
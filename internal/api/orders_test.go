@@ -0,0 +1,314 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"interview/internal/api"
+	cartpkg "interview/internal/cart"
+	"interview/internal/events"
+	"interview/internal/order"
+	"interview/internal/payment"
+	"interview/internal/repo"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminOrders(t *testing.T) {
+	ts := setupTest(t)
+	ts.clearDatabase(t)
+	r := repo.NewRepository(ts.db)
+	provider := payment.NewFake()
+	bus := events.NewBus()
+	received, unsubscribe := bus.Subscribe()
+	defer unsubscribe()
+
+	admin := api.NewAdminHandler(ts.db, nil, time.Hour)
+	admin.SetPayments(provider)
+	admin.SetEventBus(bus)
+	router := gin.New()
+	admin.RegisterRoutes(router, gin.Accounts{"admin": "secret"})
+	request := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		var payload bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&payload).Encode(body))
+		}
+		req := httptest.NewRequest(method, path, &payload)
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	transition := func(id uint, status, note string) *httptest.ResponseRecorder {
+		path := fmt.Sprintf("/admin/orders/%d/transition", id)
+		return request(http.MethodPost, path, api.TransitionOrderRequest{Status: status, Note: note})
+	}
+
+	// paidOrder checks out a cart paid through the provider and returns its order
+	paidOrder := func(t *testing.T, sessionID string) *order.Order {
+		t.Helper()
+		c, err := r.GetOrCreateCart(sessionID, cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, r.AddCartItem(c.ID, "shoe", 1, 10))
+		authorization, err := provider.Authorize(context.Background(), payment.Order{Amount: 10, Currency: "EUR"})
+		require.NoError(t, err)
+		require.NoError(t, provider.Capture(context.Background(), authorization.PaymentID))
+		require.NoError(t, r.CreatePayment(&payment.Payment{
+			CartID: c.ID, Provider: provider.Name(), ExternalID: authorization.PaymentID, Amount: 10, Currency: "EUR",
+			Status: payment.StatusPending,
+		}))
+		_, _, err = r.CompletePayment(provider.Name(), authorization.PaymentID)
+		require.NoError(t, err)
+		o, err := r.GetOrderByCart(c.ID)
+		require.NoError(t, err)
+		return o
+	}
+
+	t.Run("Paid Orders Advance To Shipped", func(t *testing.T) {
+		o := paidOrder(t, "order-session-1")
+		assert.Equal(t, order.StatusPaid, o.Status)
+
+		w := transition(o.ID, order.StatusFulfilled, "packed")
+		require.Equal(t, http.StatusOK, w.Code)
+		e := <-received
+		assert.Equal(t, events.TypeOrderStatusChanged, e.Type)
+		assert.Equal(t, o.ID, e.OrderID)
+		assert.Equal(t, order.StatusFulfilled, e.Status)
+
+		require.Equal(t, http.StatusOK, transition(o.ID, order.StatusShipped, "").Code)
+		<-received
+
+		w = request(http.MethodGet, fmt.Sprintf("/admin/orders/%d", o.ID), nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var shown api.OrderResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &shown))
+		assert.Equal(t, order.StatusShipped, shown.Status)
+		assert.Equal(t, []string{order.StatusRefunded}, shown.Next)
+		require.Len(t, shown.History, 4)
+		assert.Equal(t, api.OrderHistoryResponse{To: order.StatusPending, Actor: "checkout", CreatedAt: shown.History[0].CreatedAt}, shown.History[0])
+		assert.Equal(t, order.StatusPaid, shown.History[1].To)
+		assert.Equal(t, "admin", shown.History[2].Actor)
+		assert.Equal(t, "packed", shown.History[2].Note)
+		assert.Equal(t, order.StatusFulfilled, shown.History[3].From)
+	})
+
+	t.Run("Invalid Transitions Are Rejected", func(t *testing.T) {
+		o := paidOrder(t, "order-session-2")
+		tests := []struct {
+			name         string
+			status       string
+			expectedCode int
+		}{
+			{"Unknown Status", "lost", http.StatusBadRequest},
+			{"Skipping Fulfilment", order.StatusShipped, http.StatusConflict},
+			{"Cancelling Paid Order", order.StatusCancelled, http.StatusConflict},
+			{"Same Status", order.StatusPaid, http.StatusConflict},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert.Equal(t, tt.expectedCode, transition(o.ID, tt.status, "").Code)
+			})
+		}
+		assert.Equal(t, http.StatusNotFound, transition(o.ID+100, order.StatusFulfilled, "").Code)
+
+		unchanged, err := r.GetOrder(o.ID)
+		require.NoError(t, err)
+		assert.Equal(t, order.StatusPaid, unchanged.Status)
+		assert.Len(t, unchanged.History, 2)
+	})
+
+	t.Run("Refunds Pay Back The Payment", func(t *testing.T) {
+		o := paidOrder(t, "order-session-3")
+		w := transition(o.ID, order.StatusRefunded, "damaged")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, order.StatusRefunded, (<-received).Status)
+
+		var p payment.Payment
+		require.NoError(t, ts.db.Where("cart_id = ?", o.CartID).First(&p).Error)
+		assert.Equal(t, payment.StatusRefunded, p.Status)
+		assert.Equal(t, 10.0, provider.Refunded(p.ExternalID))
+		assert.Equal(t, http.StatusConflict, transition(o.ID, order.StatusShipped, "").Code)
+
+		refunds, err := r.ListRefunds(o.ID)
+		require.NoError(t, err)
+		require.Len(t, refunds, 1, "the refund is recorded like those of the refund endpoint")
+		assert.Equal(t, "damaged", refunds[0].Note)
+		require.Len(t, refunds[0].Lines, 1)
+		assert.Equal(t, 1, refunds[0].Lines[0].Quantity)
+	})
+
+	t.Run("Failed Refunds Are Retried Without Paying Twice", func(t *testing.T) {
+		o := paidOrder(t, "order-session-4")
+		authorization, err := provider.Authorize(context.Background(), payment.Order{Amount: 5, Currency: "EUR"})
+		require.NoError(t, err)
+		require.NoError(t, provider.Capture(context.Background(), authorization.PaymentID))
+		require.NoError(t, r.CreatePayment(&payment.Payment{
+			CartID: o.CartID, Provider: provider.Name(), ExternalID: authorization.PaymentID, Amount: 5, Currency: "EUR",
+			Status: payment.StatusCaptured,
+		}))
+		recording := &recordingRefunds{Provider: provider, failing: authorization.PaymentID, keys: map[string][]string{}}
+		admin.SetPayments(recording)
+		defer admin.SetPayments(provider)
+
+		assert.Equal(t, http.StatusBadGateway, transition(o.ID, order.StatusRefunded, "").Code)
+		var payments []payment.Payment
+		require.NoError(t, ts.db.Where("cart_id = ?", o.CartID).Order("id").Find(&payments).Error)
+		require.Len(t, payments, 2)
+		assert.Equal(t, payment.StatusRefunded, payments[0].Status)
+		assert.Equal(t, payment.StatusCaptured, payments[1].Status)
+		unchanged, err := r.GetOrder(o.ID)
+		require.NoError(t, err)
+		assert.Equal(t, order.StatusPaid, unchanged.Status)
+
+		recording.failing = ""
+		require.Equal(t, http.StatusOK, transition(o.ID, order.StatusRefunded, "").Code)
+		assert.Equal(t, order.StatusRefunded, (<-received).Status)
+		refunds, err := r.ListRefunds(o.ID)
+		require.NoError(t, err)
+		require.Len(t, refunds, 1, "the pending refund is paid back instead of starting another")
+		first, second := payments[0], payments[1]
+		firstKey, secondKey := fmt.Sprintf("refund-%d-%d", refunds[0].ID, first.ID), fmt.Sprintf("refund-%d-%d", refunds[0].ID, second.ID)
+		assert.Equal(t, []string{firstKey}, recording.keys[first.ExternalID], "the refunded payment isn't paid back again")
+		assert.Equal(t, []string{secondKey, secondKey}, recording.keys[second.ExternalID])
+		require.NoError(t, ts.db.Where("cart_id = ?", o.CartID).Order("id").Find(&payments).Error)
+		assert.Equal(t, payment.StatusRefunded, payments[1].Status)
+	})
+
+	t.Run("Orders Are Refunded In Parts", func(t *testing.T) {
+		o := paidOrder(t, "order-session-5")
+		var item cartpkg.CartItem
+		require.NoError(t, ts.db.Where("cart_id = ?", o.CartID).First(&item).Error)
+		var p payment.Payment
+		require.NoError(t, ts.db.Where("cart_id = ?", o.CartID).First(&p).Error)
+		path := fmt.Sprintf("/admin/orders/%d/refunds", o.ID)
+
+		w := request(http.MethodPost, path, api.RefundRequest{Amount: 4, Note: "late delivery"})
+		require.Equal(t, http.StatusCreated, w.Code)
+		var refund api.RefundResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &refund))
+		assert.Equal(t, order.RefundCompleted, refund.Status)
+		assert.Equal(t, 4.0, refund.Paid)
+		assert.Equal(t, "admin", refund.Actor)
+		assert.Empty(t, refund.Lines)
+		assert.Equal(t, 4.0, provider.Refunded(p.ExternalID))
+
+		items := []api.RefundItemRequest{{CartItemID: item.ID, Quantity: 2}}
+		assert.Equal(t, http.StatusBadRequest, request(http.MethodPost, path, api.RefundRequest{Items: items}).Code)
+		items[0].Quantity = 1
+		w = request(http.MethodPost, path, api.RefundRequest{Items: items})
+		require.Equal(t, http.StatusCreated, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &refund))
+		assert.Equal(t, 6.0, refund.Amount, "items are refunded up to what is left of the payment")
+		assert.Equal(t, []api.RefundLineResponse{{CartItemID: item.ID, Product: "shoe", Quantity: 1, Amount: 10}}, refund.Lines)
+		assert.Equal(t, 10.0, provider.Refunded(p.ExternalID))
+		assert.Equal(t, order.StatusRefunded, (<-received).Status)
+
+		w = request(http.MethodGet, path, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var refunds []api.RefundResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &refunds))
+		assert.Len(t, refunds, 2)
+		assert.Equal(t, http.StatusConflict, request(http.MethodPost, path, api.RefundRequest{}).Code)
+	})
+
+	t.Run("Failed Refunds Are Retried With The Same Key", func(t *testing.T) {
+		o := paidOrder(t, "order-session-6")
+		var p payment.Payment
+		require.NoError(t, ts.db.Where("cart_id = ?", o.CartID).First(&p).Error)
+		recording := &recordingRefunds{Provider: provider, failing: p.ExternalID, keys: map[string][]string{}}
+		admin.SetPayments(recording)
+		defer admin.SetPayments(provider)
+		path := fmt.Sprintf("/admin/orders/%d/refunds", o.ID)
+
+		assert.Equal(t, http.StatusBadGateway, request(http.MethodPost, path, api.RefundRequest{Amount: 3}).Code)
+		assert.Equal(t, http.StatusConflict, request(http.MethodPost, path, api.RefundRequest{Amount: 3}).Code)
+		refunds, err := r.ListRefunds(o.ID)
+		require.NoError(t, err)
+		require.Len(t, refunds, 1)
+		assert.Equal(t, order.RefundPending, refunds[0].Status)
+
+		recording.failing = ""
+		w := request(http.MethodPost, fmt.Sprintf("%s/%d/retry", path, refunds[0].ID), nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var refund api.RefundResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &refund))
+		assert.Equal(t, order.RefundCompleted, refund.Status)
+		key := fmt.Sprintf("refund-%d-%d", refund.ID, p.ID)
+		assert.Equal(t, []string{key, key}, recording.keys[p.ExternalID])
+		assert.Equal(t, 3.0, provider.Refunded(p.ExternalID))
+		assert.Equal(t, http.StatusNotFound, request(http.MethodPost, fmt.Sprintf("/admin/orders/%d/refunds/%d/retry", o.ID+100, refund.ID), nil).Code)
+	})
+
+	t.Run("List Filters By Status", func(t *testing.T) {
+		w := request(http.MethodGet, "/admin/orders?status=shipped", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var page api.OrderPage
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		require.Len(t, page.Orders, 1)
+		assert.Equal(t, order.StatusShipped, page.Orders[0].Status)
+		assert.Empty(t, page.NextCursor)
+
+		assert.Equal(t, http.StatusBadRequest, request(http.MethodGet, "/admin/orders?status=lost", nil).Code)
+	})
+
+	t.Run("List Pages Through Orders", func(t *testing.T) {
+		list := func(t *testing.T, path string) api.OrderPage {
+			t.Helper()
+			w := request(http.MethodGet, path, nil)
+			require.Equal(t, http.StatusOK, w.Code)
+			var page api.OrderPage
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+			return page
+		}
+		paidOrder(t, "order-session-page-1")
+		paidOrder(t, "order-session-page-2")
+		paidOrder(t, "order-session-page-3")
+		all := list(t, "/admin/orders?status=paid&limit=100")
+		require.GreaterOrEqual(t, len(all.Orders), 3)
+		last := len(all.Orders) - 1
+
+		first := list(t, fmt.Sprintf("/admin/orders?status=paid&limit=%d", last))
+		assert.Equal(t, all.Orders[:last], first.Orders)
+		require.Equal(t, fmt.Sprint(all.Orders[last-1].ID), first.NextCursor)
+
+		second := list(t, fmt.Sprintf("/admin/orders?status=paid&limit=%d&after=%s", last, first.NextCursor))
+		assert.Equal(t, all.Orders[last:], second.Orders)
+		assert.Empty(t, second.NextCursor)
+
+		assert.Equal(t, http.StatusBadRequest, request(http.MethodGet, "/admin/orders?limit=101", nil).Code)
+		assert.Equal(t, http.StatusBadRequest, request(http.MethodGet, "/admin/orders?after=x", nil).Code)
+	})
+}
+
+// recordingRefunds records the idempotency keys of the refunds of each payment, failing the refunds of
+// the payment failing
+type recordingRefunds struct {
+	payment.Provider
+	failing string
+	keys    map[string][]string
+}
+
+func (p *recordingRefunds) Refund(ctx context.Context, paymentID, key string) error {
+	p.keys[paymentID] = append(p.keys[paymentID], key)
+	if paymentID == p.failing {
+		return errors.New("provider unavailable")
+	}
+	return p.Provider.Refund(ctx, paymentID, key)
+}
+
+func (p *recordingRefunds) RefundPart(ctx context.Context, paymentID, key string, amount float64, currency string) error {
+	p.keys[paymentID] = append(p.keys[paymentID], key)
+	if paymentID == p.failing {
+		return errors.New("provider unavailable")
+	}
+	return p.Provider.RefundPart(ctx, paymentID, key, amount, currency)
+}
@@ -0,0 +1,254 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"html/template"
+	"interview/internal/auth"
+	"interview/internal/i18n"
+	"interview/internal/mail"
+	"interview/internal/repo"
+	"log"
+	"net/http"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/csrf"
+)
+
+const (
+	// noticeFlash is the flash key of messages confirming that something worked
+	noticeFlash = "notice"
+	// emailRequestsPerIP and emailRequestsPerEmail limit the password reset and login emails requested
+	// per hour, the latter also the email address changes of a user, unless configured otherwise
+	emailRequestsPerIP    = 10
+	emailRequestsPerEmail = 3
+	// emailTimeout bounds how long sending an email requested by a user may take
+	emailTimeout = 30 * time.Second
+	// resetRequestedNotice is shown whether or not an account exists, so the form can't be used to
+	// find out which addresses have one
+	resetRequestedNotice = "If an account exists for this email address, we sent you a link to reset your password"
+)
+
+var resetEmailTemplate = texttemplate.Must(texttemplate.New("reset").Parse(`Hello {{ .Name }},
+
+we received a request to reset the password of your account. Choose a new password here:
+
+{{ .Link }}
+
+The link can be used once and expires in {{ .TTL }}. If you didn't ask for it, you can ignore this email.
+`))
+
+// ResetPasswordData contains data to be rendered in the password reset template.
+type ResetPasswordData struct {
+	Error         string
+	Locale        string
+	CSRFFieldName template.HTML
+	Token         string
+	// Valid is false when the token can't be used, in which case no form is shown
+	Valid bool
+}
+
+// EnablePasswordReset lets users reset their password with a link emailed through mailer, pointing to
+// baseURL and valid for ttl. The reset page is rendered with tpl.
+func (h *AuthHandler) EnablePasswordReset(tpl *template.Template, mailer mail.Mailer, baseURL string, ttl time.Duration) {
+	h.template = tpl
+	h.mailer = mailer
+	h.baseURL = strings.TrimSuffix(baseURL, "/")
+	h.resetTTL = ttl
+}
+
+// PasswordLogin logs the user in with the "email" and "password" form fields.
+func (h *AuthHandler) PasswordLogin(c *gin.Context) {
+	session := sessions.Default(c)
+	email := c.PostForm("email")
+	if h.loginLocked(c, email) {
+		h.failLogin(c, session, loginLockedMessage)
+		return
+	}
+	u, err := h.repoFor(c).FindUserByEmail(email)
+	if err != nil && !errors.Is(err, repo.ErrUserNotFound) {
+		log.Printf("Failed to find user: %v", err)
+	}
+	hash := ""
+	var userID *uint
+	if u != nil {
+		hash = u.PasswordHash
+		userID = &u.ID
+	}
+	if !auth.CheckPassword(hash, c.PostForm("password")) {
+		left, locked := h.loginFailed(c, email, userID, "password")
+		switch {
+		case locked:
+			h.failLogin(c, session, loginLockedMessage)
+		case left <= loginAttemptsWarning:
+			h.failLogin(c, session, i18n.T(detectLocale(c, session).String(), "Invalid email or password, attempts left: %d", left))
+		default:
+			h.failLogin(c, session, "Invalid email or password")
+		}
+		return
+	}
+
+	if u.TOTPEnabled {
+		startPendingLogin(c, session, u.ID)
+		return
+	}
+	h.loginSucceeded(email)
+	h.completeLogin(c, session, u.ID)
+}
+
+// ForgotPassword emails a password reset link to the "email" form field if an account has that
+// address. The response is the same either way.
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	session := sessions.Default(c)
+	email := strings.ToLower(strings.TrimSpace(c.PostForm("email")))
+	if email == "" {
+		h.failLogin(c, session, "Please enter your email address")
+		return
+	}
+	if !h.allowEmail(c, email) {
+		h.failLogin(c, session, "Too many requests, please try again later")
+		return
+	}
+
+	u, err := h.repoFor(c).FindUserByEmail(email)
+	if err == nil {
+		h.sendPasswordReset(u.ID, u.Email, u.Name)
+	} else if !errors.Is(err, repo.ErrUserNotFound) {
+		log.Printf("Failed to find user: %v", err)
+	}
+	redirectWithNotice(c, session, resetRequestedNotice)
+}
+
+// sendPasswordReset creates a reset token and emails its link in the background, so the response
+// takes as long for unknown addresses.
+func (h *AuthHandler) sendPasswordReset(userID uint, email, name string) {
+	token, err := generateSessionID()
+	if err != nil {
+		log.Printf("Failed to generate password reset token: %v", err)
+		return
+	}
+	if err := h.repo.CreatePasswordResetToken(userID, hashToken(token), time.Now().Add(h.resetTTL)); err != nil {
+		log.Printf("Failed to create password reset token: %v", err)
+		return
+	}
+
+	if name == "" {
+		name = email
+	}
+	var body bytes.Buffer
+	err = resetEmailTemplate.Execute(&body, map[string]string{
+		"Name": name,
+		"Link": h.baseURL + "/reset-password/" + token,
+		"TTL":  h.resetTTL.String(),
+	})
+	if err != nil {
+		log.Printf("Failed to render password reset email: %v", err)
+		return
+	}
+	sendEmail(h.mailer, mail.Message{To: email, Subject: "Reset your password", Body: body.String()})
+}
+
+// SetEmailLimits sets how many password reset and login emails each IP address and email address may
+// request per hour. The limits may change while the server runs.
+func (h *AuthHandler) SetEmailLimits(perIP, perAddress int) {
+	h.emailIPLimiter.SetLimit(perIP)
+	h.emailLimiter.SetLimit(perAddress)
+}
+
+// allowEmail reports whether the client may request another email to the address.
+func (h *AuthHandler) allowEmail(c *gin.Context, email string) bool {
+	return h.emailIPLimiter.Allow(c.ClientIP()) && h.emailLimiter.Allow(email)
+}
+
+// sendEmail sends the message in the background, so responses don't wait for the mail server.
+func sendEmail(mailer mail.Mailer, msg mail.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), emailTimeout)
+		defer cancel()
+		if err := mailer.Send(ctx, msg); err != nil {
+			log.Printf("Failed to send %q email: %v", msg.Subject, err)
+		}
+	}()
+}
+
+// ShowResetPassword renders the form choosing a new password with the token of the link.
+func (h *AuthHandler) ShowResetPassword(c *gin.Context) {
+	data := ResetPasswordData{Token: c.Param("token")}
+	if _, err := h.repoFor(c).GetPasswordResetToken(hashToken(data.Token)); err != nil {
+		data.Error = errorMessage(err, "Failed to load password reset")
+	} else {
+		data.Valid = true
+	}
+	h.renderResetPassword(c, data)
+}
+
+// ResetPassword sets the "password" form field as the new password of the user of the token, if it
+// matches "password_confirmation".
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	data := ResetPasswordData{Token: c.Param("token"), Valid: true}
+	password := c.PostForm("password")
+	if err := auth.ValidatePassword(password); err != nil {
+		data.Error = errorMessage(err, "Invalid password")
+		h.renderResetPassword(c, data)
+		return
+	}
+	if password != c.PostForm("password_confirmation") {
+		data.Error = "The passwords don't match"
+		h.renderResetPassword(c, data)
+		return
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		log.Printf("Failed to hash password: %v", err)
+		data.Error = "Failed to reset password"
+		h.renderResetPassword(c, data)
+		return
+	}
+	if err := h.repoFor(c).ResetPassword(hashToken(data.Token), hash); err != nil {
+		data.Error = errorMessage(err, "Failed to reset password")
+		data.Valid = false
+		h.renderResetPassword(c, data)
+		return
+	}
+	redirectWithNotice(c, sessions.Default(c), "Your password was changed, you can log in with it now")
+}
+
+func (h *AuthHandler) renderResetPassword(c *gin.Context, data ResetPasswordData) {
+	data.Locale = detectLocale(c, sessions.Default(c)).String()
+	data.Error = i18n.T(data.Locale, data.Error)
+	data.CSRFFieldName = csrf.TemplateField(c.Request)
+	status := http.StatusOK
+	if !data.Valid {
+		status = http.StatusNotFound
+	} else if data.Error != "" {
+		status = http.StatusUnprocessableEntity
+	}
+	c.Status(status)
+	if err := h.templateFor(c).ExecuteTemplate(c.Writer, "reset_password.html", data); err != nil {
+		log.Printf("Failed to render template: %v", err)
+		http.Error(c.Writer, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// redirectWithNotice shows the message on the cart page.
+func redirectWithNotice(c *gin.Context, session sessions.Session, message string) {
+	session.AddFlash(message, noticeFlash)
+	if err := session.Save(); err != nil {
+		log.Printf("Failed to save session: %v", err)
+	}
+	c.Redirect(http.StatusFound, "/")
+}
+
+// hashToken returns the hash tokens sent by email are stored as.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,176 @@
+package api_test
+
+import (
+	"context"
+	"interview/internal/api"
+	"interview/internal/mail"
+	"interview/internal/user"
+	"net/http"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMailer hands the emails sent to the test
+type fakeMailer chan mail.Message
+
+func (m fakeMailer) Send(_ context.Context, msg mail.Message) error {
+	m <- msg
+	return nil
+}
+
+var resetLinkPattern = regexp.MustCompile(`http://shop\.example\.com(/reset-password/[0-9a-f]+)`)
+
+// passwordResetSetup is a test environment with password reset enabled and the fake Google user
+type passwordResetSetup struct {
+	*testSetup
+	mailer fakeMailer
+	cookie *http.Cookie
+}
+
+func setupPasswordReset(t *testing.T) *passwordResetSetup {
+	t.Helper()
+	ts := &passwordResetSetup{testSetup: setupTest(t), mailer: make(fakeMailer, 10)}
+	authHandler := api.NewAuthHandler(ts.db, newFakeGoogle(t))
+	authHandler.EnablePasswordReset(ts.handler.Template, ts.mailer, "http://shop.example.com/", time.Hour)
+	ts.handler.SetPasswordReset(true)
+	ts.router.GET("/auth/:provider/login", authHandler.Login)
+	ts.router.GET("/auth/:provider/callback", authHandler.Callback)
+	ts.router.POST("/login", authHandler.PasswordLogin)
+	ts.router.POST("/forgot-password", authHandler.ForgotPassword)
+	ts.router.GET("/reset-password/:token", authHandler.ShowResetPassword)
+	ts.router.POST("/reset-password/:token", authHandler.ResetPassword)
+
+	// Create the account of the fake Google user, then continue in a fresh session
+	ts.clearDatabase(t)
+	cookie := ts.createSession(t)
+	w := ts.makeRequest(t, http.MethodGet, "/auth/google/login", nil, cookie)
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	ts.makeRequest(t, http.MethodGet, "/auth/google/callback?code=abc&state="+location.Query().Get("state"), nil, cookie)
+	ts.cookie = ts.createSession(t)
+	return ts
+}
+
+// requestReset asks for a reset email and returns the path of its link
+func (ts *passwordResetSetup) requestReset(t *testing.T) string {
+	t.Helper()
+	w := ts.makeRequest(t, http.MethodPost, "/forgot-password", url.Values{"email": {"JANE@example.com"}}, ts.cookie)
+	require.Equal(t, http.StatusFound, w.Code)
+	select {
+	case msg := <-ts.mailer:
+		assert.Equal(t, "jane@example.com", msg.To)
+		match := resetLinkPattern.FindStringSubmatch(msg.Body)
+		require.NotNil(t, match, msg.Body)
+		return match[1]
+	case <-time.After(5 * time.Second):
+		t.Fatal("no password reset email sent")
+		return ""
+	}
+}
+
+// login logs a new session in with the password and returns the cart page
+func (ts *passwordResetSetup) login(t *testing.T, password string) string {
+	t.Helper()
+	cookie := ts.createSession(t)
+	ts.makeRequest(t, http.MethodPost, "/login", url.Values{"email": {"jane@example.com"}, "password": {password}}, cookie)
+	return ts.makeRequest(t, http.MethodGet, "/", nil, cookie).Body.String()
+}
+
+func TestPasswordReset(t *testing.T) {
+	t.Run("Reset And Log In", func(t *testing.T) {
+		ts := setupPasswordReset(t)
+		cookie := ts.cookie
+		assert.NotContains(t, ts.login(t, ""), "Logged in as", "accounts without a password can't log in with one")
+
+		link := ts.requestReset(t)
+		w := ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.Contains(t, w.Body.String(), "If an account exists for this email address")
+
+		w = ts.makeRequest(t, http.MethodGet, link, nil, cookie)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `name="password_confirmation"`)
+
+		w = ts.makeRequest(t, http.MethodPost, link, url.Values{"password": {"short"}, "password_confirmation": {"short"}}, cookie)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Contains(t, w.Body.String(), "Passwords must have at least 8 characters")
+		w = ts.makeRequest(t, http.MethodPost, link, url.Values{"password": {"correct horse"}, "password_confirmation": {"wrong horse"}}, cookie)
+		assert.Contains(t, w.Body.String(), "The passwords don&#39;t match")
+
+		w = ts.makeRequest(t, http.MethodPost, link, url.Values{"password": {"correct horse"}, "password_confirmation": {"correct horse"}}, cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+		w = ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.Contains(t, w.Body.String(), "Your password was changed")
+
+		assert.Contains(t, ts.login(t, "correct horse"), "Logged in as Jane")
+		assert.NotContains(t, ts.login(t, "wrong horse"), "Logged in as")
+	})
+
+	t.Run("Links Work Once", func(t *testing.T) {
+		ts := setupPasswordReset(t)
+		cookie := ts.cookie
+		link := ts.requestReset(t)
+		form := url.Values{"password": {"correct horse"}, "password_confirmation": {"correct horse"}}
+		require.Equal(t, http.StatusFound, ts.makeRequest(t, http.MethodPost, link, form, cookie).Code)
+
+		w := ts.makeRequest(t, http.MethodGet, link, nil, cookie)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.NotContains(t, w.Body.String(), `name="password"`)
+		w = ts.makeRequest(t, http.MethodPost, link, form, cookie)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Tokens Are Stored Hashed", func(t *testing.T) {
+		ts := setupPasswordReset(t)
+		link := ts.requestReset(t)
+		var tokens []user.PasswordResetToken
+		require.NoError(t, ts.db.Find(&tokens).Error)
+		require.Len(t, tokens, 1)
+		assert.NotContains(t, link, tokens[0].TokenHash)
+	})
+
+	t.Run("Unknown Email", func(t *testing.T) {
+		ts := setupPasswordReset(t)
+		cookie := ts.cookie
+		w := ts.makeRequest(t, http.MethodPost, "/forgot-password", url.Values{"email": {"nobody@example.com"}}, cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+		w = ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.Contains(t, w.Body.String(), "If an account exists for this email address")
+		select {
+		case msg := <-ts.mailer:
+			t.Fatalf("unexpected email to %s", msg.To)
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+
+	t.Run("Rate Limited", func(t *testing.T) {
+		ts := setupPasswordReset(t)
+		cookie := ts.cookie
+		for i := 0; i < 3; i++ {
+			ts.requestReset(t)
+		}
+		w := ts.makeRequest(t, http.MethodPost, "/forgot-password", url.Values{"email": {"jane@example.com"}}, cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+		w = ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.Contains(t, w.Body.String(), "Too many requests")
+	})
+
+	t.Run("Two-Factor Authentication Still Applies", func(t *testing.T) {
+		ts := setupPasswordReset(t)
+		cookie := ts.cookie
+		link := ts.requestReset(t)
+		form := url.Values{"password": {"correct horse"}, "password_confirmation": {"correct horse"}}
+		require.Equal(t, http.StatusFound, ts.makeRequest(t, http.MethodPost, link, form, cookie).Code)
+		require.NoError(t, ts.db.Model(&user.User{}).Where("1 = 1").Updates(map[string]interface{}{
+			"totp_secret": "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", "totp_enabled": true,
+		}).Error)
+
+		body := ts.login(t, "correct horse")
+		assert.NotContains(t, body, "Logged in as")
+		assert.Contains(t, body, `action="/auth/2fa"`)
+	})
+}
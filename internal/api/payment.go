@@ -0,0 +1,243 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"interview/internal/analytics"
+	"interview/internal/cart"
+	"interview/internal/checkout"
+	"interview/internal/events"
+	"interview/internal/payment"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// paymentWebhookPath receives the webhook notifications of the payment provider
+	paymentWebhookPath = "/payments/webhook"
+	// maxWebhookSize is the largest webhook notification accepted from the payment provider
+	maxWebhookSize = 1 << 20
+)
+
+// SetPayments lets customers pay for their carts with the provider at checkout. The provider sends
+// them back to the checkout pages under baseURL.
+func (h *CartHandler) SetPayments(provider payment.Provider, baseURL string) {
+	h.payments = provider
+	h.paymentBaseURL = strings.TrimSuffix(baseURL, "/")
+}
+
+// StartPayment starts the payment of the cart being checked out and sends the customer to the payment
+// provider to approve it. A payment started before is resumed as long as the cart didn't change, and carts
+// with nothing to pay go straight to the confirm step.
+func (h *CartHandler) StartPayment(c *gin.Context) {
+	session := sessions.Default(c)
+	userCart, s, ok := h.loadCheckout(c, session, checkout.StepPayment)
+	if !ok {
+		return
+	}
+	if userCart.Total <= 0 {
+		s.Step = checkout.StepConfirm
+		h.advanceCheckout(c, session, s)
+		return
+	}
+	if s.PaymentID != nil && s.ApproveURL != "" {
+		p, err := h.repoFor(c).GetPaymentByID(*s.PaymentID)
+		if err == nil && p.Status == payment.StatusPending && p.CartVersion == userCart.Version {
+			c.Redirect(http.StatusSeeOther, s.ApproveURL)
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	auth, err := h.payments.Authorize(ctx, payment.Order{
+		Reference: strconv.FormatUint(uint64(userCart.ID), 10),
+		Amount:    userCart.Total,
+		Currency:  h.currencies.Base(),
+		ReturnURL: h.paymentBaseURL + "/checkout/return",
+		CancelURL: h.paymentBaseURL + "/checkout/cancel",
+	})
+	if err != nil {
+		log.Printf("Failed to start payment: %v", err)
+		h.checkoutFlash(c, session, "Payments are temporarily unavailable, please try again")
+		return
+	}
+	p := &payment.Payment{
+		TenantID:    userCart.TenantID,
+		CartID:      userCart.ID,
+		CartVersion: userCart.Version,
+		Provider:    h.payments.Name(),
+		ExternalID:  auth.PaymentID,
+		Amount:      userCart.Total,
+		Currency:    h.currencies.Base(),
+		Status:      payment.StatusPending,
+	}
+	if err := h.repoFor(c).CreatePayment(p); err != nil {
+		log.Printf("Failed to store payment: %v", err)
+		h.checkoutFlash(c, session, "Failed to check out")
+		return
+	}
+	s.PaymentID, s.ApproveURL = &p.ID, auth.ApproveURL
+	if err := h.repoFor(c).SaveCheckout(s); err != nil {
+		log.Printf("Failed to save checkout: %v", err)
+		h.checkoutFlash(c, session, "Failed to check out")
+		return
+	}
+	c.Redirect(http.StatusSeeOther, auth.ApproveURL)
+}
+
+// PaymentReturn resumes the checkout at the confirm step once the customer approved the payment at the
+// payment provider.
+func (h *CartHandler) PaymentReturn(c *gin.Context) {
+	session := sessions.Default(c)
+	_, s, ok := h.loadCheckout(c, session, checkout.StepPayment)
+	if !ok {
+		return
+	}
+	if s.PaymentID == nil {
+		h.checkoutFlash(c, session, errorMessage(payment.ErrPaymentNotFound, ""))
+		return
+	}
+	if s.Step == checkout.StepPayment {
+		s.Step = checkout.StepConfirm
+		if err := h.repoFor(c).SaveCheckout(s); err != nil {
+			log.Printf("Failed to save checkout: %v", err)
+		}
+	}
+	c.Redirect(http.StatusFound, "/checkout")
+}
+
+// PaymentCancel returns to the payment step when the customer cancelled the payment at the payment
+// provider.
+func (h *CartHandler) PaymentCancel(c *gin.Context) {
+	session := sessions.Default(c)
+	_, s, ok := h.loadCheckout(c, session, checkout.StepPayment)
+	if !ok {
+		return
+	}
+	s.Step = checkout.StepPayment
+	resetCheckoutPayment(s)
+	if err := h.repoFor(c).SaveCheckout(s); err != nil {
+		log.Printf("Failed to save checkout: %v", err)
+	}
+	h.checkoutFlash(c, session, "The payment was cancelled")
+}
+
+// PaymentWebhook handles the notifications of the payment provider: approved payments resume their
+// checkout at the confirm step even when the customer didn't return to the shop, and captured payments
+// check out their cart. Failures respond with 500 for the provider to send the notification again.
+func (h *CartHandler) PaymentWebhook(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookSize))
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	ctx := c.Request.Context()
+	event, err := h.payments.VerifyWebhook(ctx, c.Request.Header, body)
+	if errors.Is(err, payment.ErrInvalidWebhook) {
+		c.Status(http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Printf("Failed to verify payment webhook: %v", err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	switch event.Type {
+	case payment.EventApproved:
+		err = h.approvePayment(event.PaymentID)
+	case payment.EventCaptured:
+		_, err = h.completePayment(event.PaymentID)
+	}
+	switch {
+	case errors.Is(err, payment.ErrPaymentNotFound):
+		// Payments started elsewhere are none of the shop's business
+	case errors.Is(err, checkout.ErrSessionNotFound):
+		log.Printf("Payment %s has no checkout", event.PaymentID)
+	case err != nil:
+		log.Printf("Failed to handle payment webhook: %v", err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// approvePayment moves the checkout of an approved payment on to the confirm step.
+func (h *CartHandler) approvePayment(paymentID string) error {
+	p, err := h.repo.GetPayment(h.payments.Name(), paymentID)
+	if err != nil {
+		return err
+	}
+	s, err := h.repo.GetCheckout(p.CartID)
+	if err != nil {
+		return err
+	}
+	if s.Step != checkout.StepPayment || s.PaymentID == nil || *s.PaymentID != p.ID {
+		return nil
+	}
+	s.Step = checkout.StepConfirm
+	return h.repo.SaveCheckout(s)
+}
+
+// capturePayment captures an approved payment and checks out its cart. The payment is only captured
+// while the cart is unchanged since the payment was started, failing with ErrCartChanged otherwise.
+func (h *CartHandler) capturePayment(ctx context.Context, paymentID string) (*payment.Payment, error) {
+	p, err := h.repo.GetPayment(h.payments.Name(), paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if p.Status == payment.StatusCaptured {
+		return p, nil
+	}
+	userCart, err := h.repo.GetCart(p.CartID)
+	if err != nil {
+		return nil, err
+	}
+	if userCart.Status != cart.StatusOpen || userCart.Version != p.CartVersion {
+		return nil, payment.ErrCartChanged
+	}
+
+	if err := h.payments.Capture(ctx, paymentID); err != nil {
+		return nil, err
+	}
+	return h.completePayment(paymentID)
+}
+
+// completePayment records a captured payment and checks out its cart, unless that happened before.
+func (h *CartHandler) completePayment(paymentID string) (*payment.Payment, error) {
+	p, checkedOut, err := h.repo.CompletePayment(h.payments.Name(), paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if checkedOut {
+		h.checkedOut(p.CartID)
+	}
+	return p, nil
+}
+
+// checkedOut publishes the checkout of the cart and its paid order and records it for analytics.
+func (h *CartHandler) checkedOut(cartID uint) {
+	closed, err := h.repo.GetCart(cartID)
+	if err != nil {
+		log.Printf("Failed to load checked out cart: %v", err)
+		return
+	}
+	if h.events != nil {
+		h.events.Publish(events.Event{Type: events.TypeCartClosed, CartID: closed.ID, Total: closed.Total})
+		if o, err := h.repo.GetOrderByCart(closed.ID); err == nil {
+			h.events.Publish(events.Event{
+				Type: events.TypeOrderStatusChanged, CartID: closed.ID, OrderID: o.ID, Status: o.Status, Total: closed.Total,
+			})
+		}
+	}
+	if h.tracker != nil {
+		h.tracker.Track(analytics.Event{
+			Type: analytics.TypeCheckout, SessionID: closed.SessionID, UserID: closed.UserID, Total: closed.Total,
+		})
+	}
+}
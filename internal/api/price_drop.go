@@ -0,0 +1,70 @@
+package api
+
+import (
+	"errors"
+	"interview/internal/pricedrop"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type (
+	// ProductPriceRequest is the JSON body accepted by POST /admin/products/:id/price.
+	ProductPriceRequest struct {
+		Price *float64 `json:"price"`
+	}
+
+	// ProductPriceResponse tells the new and previous price of a product, and how many open carts holding
+	// it were alerted about a lower price.
+	ProductPriceResponse struct {
+		ID            uint    `json:"id"`
+		Price         float64 `json:"price"`
+		PreviousPrice float64 `json:"previous_price"`
+		AlertedCarts  int     `json:"alerted_carts"`
+	}
+)
+
+// SetPriceDrops alerts the owners of open carts through alerter when staff lower the price of a product,
+// nil disables the alerts.
+func (h *AdminHandler) SetPriceDrops(alerter *pricedrop.Alerter) {
+	h.priceDrops = alerter
+}
+
+// UpdateProductPrice sets the base price of a product. Lowering it alerts the owners of the open carts
+// holding the product.
+func (h *AdminHandler) UpdateProductPrice(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid product ID")
+		return
+	}
+	var req ProductPriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Price == nil || *req.Price < 0 {
+		respondWithInvalidField(c, "price", "must be a number of at least 0")
+		return
+	}
+
+	p, previous, err := h.repoFor(c).SetProductPrice(uint(id), *req.Price)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondWithProblem(c, http.StatusNotFound, "product not found")
+		return
+	} else if err != nil {
+		log.Printf("Failed to update price: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to update price")
+		return
+	}
+
+	resp := ProductPriceResponse{ID: p.ID, Price: p.Price, PreviousPrice: previous}
+	if h.priceDrops != nil && p.Price < previous {
+		// The price is updated either way, failed alerts are only logged
+		resp.AlertedCarts, err = h.priceDrops.Alert(c.Request.Context(), *p, time.Now())
+		if err != nil {
+			log.Printf("Failed to alert carts about the price drop of %s: %v", p.Name, err)
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,107 @@
+package api_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"interview/internal/api"
+	"interview/internal/cart"
+	"interview/internal/pricedrop"
+	"interview/internal/repo"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductPrice(t *testing.T) {
+	ts := setupTest(t)
+	ts.clearDatabase(t)
+	cartRepo := repo.NewRepository(ts.db)
+	mailer := make(fakeMailer, 10)
+	router := gin.New()
+	admin := api.NewAdminHandler(ts.db, nil, time.Hour)
+	admin.SetPriceDrops(pricedrop.NewAlerter(cartRepo, mailer, nil, "http://shop.example.com", true))
+	admin.RegisterRoutes(router, gin.Accounts{"admin": "secret"})
+
+	// setPrice posts the JSON body to the price endpoint of the product
+	setPrice := func(t *testing.T, id uint, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/admin/products/%d/price", id), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	shoe, err := cartRepo.UpsertProduct("shoe", 10)
+	require.NoError(t, err)
+	c, err := cartRepo.GetOrCreateCart("jane", cart.DefaultName)
+	require.NoError(t, err)
+	require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 1, 10))
+	jane, err := cartRepo.FindOrCreateUser("github", "1", "jane@example.com", "Jane")
+	require.NoError(t, err)
+	require.NoError(t, cartRepo.AssignCartToUser("jane", jane.ID))
+
+	t.Run("Rejects Invalid Prices", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, setPrice(t, shoe.ID, `{"price": -1}`).Code)
+		assert.Equal(t, http.StatusBadRequest, setPrice(t, shoe.ID, `{}`).Code)
+		assert.Equal(t, http.StatusNotFound, setPrice(t, 9999, `{"price": 1}`).Code)
+	})
+
+	t.Run("Raising The Price Alerts No One", func(t *testing.T) {
+		w := setPrice(t, shoe.ID, `{"price": 12}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp api.ProductPriceResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, api.ProductPriceResponse{ID: shoe.ID, Price: 12, PreviousPrice: 10}, resp)
+		assert.Empty(t, mailer)
+	})
+
+	t.Run("Lowering The Price Alerts Open Carts", func(t *testing.T) {
+		w := setPrice(t, shoe.ID, `{"price": 8}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp api.ProductPriceResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, 1, resp.AlertedCarts)
+
+		require.Len(t, mailer, 1)
+		assert.Equal(t, "jane@example.com", (<-mailer).To)
+		repriced, err := cartRepo.GetCart(c.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 8.0, repriced.CartItems[0].Price)
+	})
+
+	t.Run("The Cart Charges The Lower Price", func(t *testing.T) {
+		ts.handler.SetPriceRefreshAfter(time.Nanosecond)
+		defer ts.handler.SetPriceRefreshAfter(0)
+		require.Equal(t, http.StatusOK, setPrice(t, shoe.ID, `{"price": 12}`).Code)
+		cookie := ts.createSession(t)
+		w := ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"shoe"}, "quantity": {"1"}}, cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+
+		w = setPrice(t, shoe.ID, `{"price": 9}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp api.ProductPriceResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, 1, resp.AlertedCarts)
+
+		// Viewing the cart refreshes its prices, which keeps the price of the alert
+		w = ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "9.00")
+		assert.NotContains(t, w.Body.String(), "12.00")
+		assert.NotContains(t, w.Body.String(), "Prices in your cart were updated")
+
+		w = ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"shoe"}, "quantity": {"1"}}, cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+		w = ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.Contains(t, w.Body.String(), "18.00")
+	})
+}
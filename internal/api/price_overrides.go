@@ -0,0 +1,125 @@
+package api
+
+import (
+	"errors"
+	"interview/internal/pricelist"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type (
+	// PriceOverrideRequest is the JSON body accepted by POST /admin/price-overrides. Times are RFC 3339.
+	PriceOverrideRequest struct {
+		Product  string     `json:"product"`
+		Price    *float64   `json:"price"`
+		StartsAt *time.Time `json:"starts_at"`
+		EndsAt   *time.Time `json:"ends_at"`
+	}
+
+	// PriceOverrideResponse is the JSON representation of a price override.
+	PriceOverrideResponse struct {
+		ID       uint      `json:"id"`
+		Product  string    `json:"product"`
+		Price    float64   `json:"price"`
+		StartsAt time.Time `json:"starts_at"`
+		EndsAt   time.Time `json:"ends_at"`
+		// Active is set while the override is the price of the product
+		Active bool `json:"active"`
+	}
+)
+
+// ListPriceOverrides returns the price overrides that haven't ended yet, of one product with ?product=.
+func (h *AdminHandler) ListPriceOverrides(c *gin.Context) {
+	now := time.Now()
+	overrides, err := h.repoFor(c).ListPriceOverrides(c.Query("product"), now)
+	if err != nil {
+		log.Printf("Failed to list price overrides: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to list price overrides")
+		return
+	}
+	responses := make([]PriceOverrideResponse, len(overrides))
+	for i, o := range overrides {
+		responses[i] = newPriceOverrideResponse(o, now)
+	}
+	c.JSON(http.StatusOK, responses)
+}
+
+// CreatePriceOverride schedules a product to sell at another price for a period, e.g. for a sale.
+func (h *AdminHandler) CreatePriceOverride(c *gin.Context) {
+	var req PriceOverrideRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if sold, err := h.repoFor(c).HasProduct(req.Product); err != nil {
+		log.Printf("Failed to look up product: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to look up product")
+		return
+	} else if !sold {
+		respondWithProblem(c, http.StatusBadRequest, "unknown product")
+		return
+	}
+	if req.Price == nil || *req.Price < 0 {
+		respondWithInvalidField(c, "price", "must be a number of at least 0")
+		return
+	}
+	if req.StartsAt == nil || req.EndsAt == nil {
+		missing := map[string]string{}
+		if req.StartsAt == nil {
+			missing["starts_at"] = "is required"
+		}
+		if req.EndsAt == nil {
+			missing["ends_at"] = "is required"
+		}
+		respondWithInvalidFields(c, http.StatusBadRequest, "starts_at and ends_at are required", missing)
+		return
+	}
+
+	override := pricelist.PriceOverride{Product: req.Product, Price: *req.Price, StartsAt: req.StartsAt.UTC(), EndsAt: req.EndsAt.UTC()}
+	err := h.repoFor(c).CreatePriceOverride(&override)
+	if errors.Is(err, pricelist.ErrInvalidPeriod) {
+		respondWithInvalidField(c, "ends_at", "must be after starts_at")
+		return
+	} else if errors.Is(err, pricelist.ErrOverrideOverlaps) {
+		respondWithProblem(c, http.StatusConflict, "product has another price override during this period")
+		return
+	} else if err != nil {
+		log.Printf("Failed to create price override: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to create price override")
+		return
+	}
+	c.JSON(http.StatusCreated, newPriceOverrideResponse(override, time.Now()))
+}
+
+// DeletePriceOverride deletes a price override; an active one ends right away.
+func (h *AdminHandler) DeletePriceOverride(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid price override ID")
+		return
+	}
+	if err := h.repoFor(c).DeletePriceOverride(uint(id)); errors.Is(err, pricelist.ErrOverrideNotFound) {
+		respondWithProblem(c, http.StatusNotFound, "price override not found")
+		return
+	} else if err != nil {
+		log.Printf("Failed to delete price override: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to delete price override")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func newPriceOverrideResponse(o pricelist.PriceOverride, now time.Time) PriceOverrideResponse {
+	return PriceOverrideResponse{
+		ID:       o.ID,
+		Product:  o.Product,
+		Price:    o.Price,
+		StartsAt: o.StartsAt,
+		EndsAt:   o.EndsAt,
+		Active:   o.Active(now),
+	}
+}
@@ -0,0 +1,91 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"interview/internal/api"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminPriceOverrides(t *testing.T) {
+	ts := setupTest(t)
+	ts.clearDatabase(t)
+
+	router := gin.New()
+	api.NewAdminHandler(ts.db, nil, time.Hour).RegisterRoutes(router, gin.Accounts{"admin": "secret"})
+	request := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		var payload bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&payload).Encode(body))
+		}
+		req := httptest.NewRequest(method, path, &payload)
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	now := time.Now().Truncate(time.Second)
+	start, end, later := now.Add(-time.Hour), now.Add(time.Hour), now.Add(2*time.Hour)
+	price, negative := 7.5, -1.0
+	var created api.PriceOverrideResponse
+
+	t.Run("Create", func(t *testing.T) {
+		tests := []struct {
+			name         string
+			body         api.PriceOverrideRequest
+			expectedCode int
+		}{
+			{"Unknown Product", api.PriceOverrideRequest{Product: "hat", Price: &price, StartsAt: &start, EndsAt: &end}, http.StatusBadRequest},
+			{"Negative Price", api.PriceOverrideRequest{Product: "shoe", Price: &negative, StartsAt: &start, EndsAt: &end}, http.StatusBadRequest},
+			{"Missing End", api.PriceOverrideRequest{Product: "shoe", Price: &price, StartsAt: &start}, http.StatusBadRequest},
+			{"End Before Start", api.PriceOverrideRequest{Product: "shoe", Price: &price, StartsAt: &end, EndsAt: &start}, http.StatusBadRequest},
+			{"Valid Override", api.PriceOverrideRequest{Product: "shoe", Price: &price, StartsAt: &start, EndsAt: &end}, http.StatusCreated},
+			{"Overlapping Override", api.PriceOverrideRequest{Product: "shoe", Price: &price, StartsAt: &now, EndsAt: &later}, http.StatusConflict},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				w := request(http.MethodPost, "/admin/price-overrides", tt.body)
+				assert.Equal(t, tt.expectedCode, w.Code)
+				if w.Code == http.StatusCreated {
+					require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+				}
+			})
+		}
+		assert.True(t, created.Active)
+		assert.True(t, created.StartsAt.Equal(start))
+	})
+
+	t.Run("List", func(t *testing.T) {
+		upcoming := api.PriceOverrideRequest{Product: "bag", Price: &price, StartsAt: &end, EndsAt: &later}
+		require.Equal(t, http.StatusCreated, request(http.MethodPost, "/admin/price-overrides", upcoming).Code)
+
+		w := request(http.MethodGet, "/admin/price-overrides?product=shoe", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var overrides []api.PriceOverrideResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &overrides))
+		require.Len(t, overrides, 1)
+		assert.Equal(t, created.ID, overrides[0].ID)
+
+		w = request(http.MethodGet, "/admin/price-overrides", nil)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &overrides))
+		require.Len(t, overrides, 2)
+		assert.False(t, overrides[1].Active)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		path := fmt.Sprintf("/admin/price-overrides/%d", created.ID)
+		assert.Equal(t, http.StatusNoContent, request(http.MethodDelete, path, nil).Code)
+		assert.Equal(t, http.StatusNotFound, request(http.MethodDelete, path, nil).Code)
+		assert.Equal(t, http.StatusBadRequest, request(http.MethodDelete, "/admin/price-overrides/abc", nil).Code)
+	})
+}
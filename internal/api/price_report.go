@@ -0,0 +1,114 @@
+package api
+
+import (
+	"errors"
+	"interview/internal/cart"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// stalePriceReportSize is how many stale items the price report lists
+	stalePriceReportSize = 500
+	// maxRepriceCarts is how many carts one reprice request may select
+	maxRepriceCarts = 100
+)
+
+type (
+	// StalePriceResponse is an entry of the stale price report: a cart item whose price differs from
+	// the catalog. Difference is what repricing would add to the cart, negative when it gets cheaper.
+	StalePriceResponse struct {
+		CartID       uint    `json:"cart_id"`
+		SessionID    string  `json:"session_id"`
+		CartName     string  `json:"cart_name"`
+		ItemID       uint    `json:"item_id"`
+		Product      string  `json:"product"`
+		Quantity     int     `json:"quantity"`
+		Price        float64 `json:"price"`
+		CatalogPrice float64 `json:"catalog_price"`
+		Difference   float64 `json:"difference"`
+	}
+
+	// RepriceCartsRequest is the JSON body accepted by POST /admin/carts/reprice.
+	RepriceCartsRequest struct {
+		CartIDs []uint `json:"cart_ids"`
+	}
+
+	// RepriceCartsResponse lists which of the selected carts got new prices, which already had current
+	// prices and which were skipped because they were closed, deleted or being checked out.
+	RepriceCartsResponse struct {
+		Repriced  []uint `json:"repriced"`
+		Unchanged []uint `json:"unchanged"`
+		Skipped   []uint `json:"skipped"`
+	}
+)
+
+// StalePriceReport lists the items of open carts whose stored price differs from the current catalog
+// price, so they can be repriced before they reach checkout.
+func (h *AdminHandler) StalePriceReport(c *gin.Context) {
+	stale, err := h.repoFor(c).ListStalePrices(stalePriceReportSize, time.Now())
+	if err != nil {
+		log.Printf("Failed to build price report: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to build price report")
+		return
+	}
+
+	responses := make([]StalePriceResponse, len(stale))
+	for i, s := range stale {
+		responses[i] = StalePriceResponse{
+			CartID:       s.CartID,
+			SessionID:    s.SessionID,
+			CartName:     s.CartName,
+			ItemID:       s.ItemID,
+			Product:      s.ProductName,
+			Quantity:     s.Quantity,
+			Price:        s.Price,
+			CatalogPrice: s.CatalogPrice,
+			Difference:   (s.CatalogPrice - s.Price) * float64(s.Quantity),
+		}
+	}
+	c.JSON(http.StatusOK, responses)
+}
+
+// RepriceCarts updates the selected carts to the current catalog prices.
+func (h *AdminHandler) RepriceCarts(c *gin.Context) {
+	var req RepriceCartsRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.CartIDs) == 0 {
+		respondWithInvalidField(c, "cart_ids", "is required")
+		return
+	}
+	if len(req.CartIDs) > maxRepriceCarts {
+		respondWithProblem(c, http.StatusBadRequest, "at most 100 carts can be repriced at once")
+		return
+	}
+
+	resp := RepriceCartsResponse{Repriced: []uint{}, Unchanged: []uint{}, Skipped: []uint{}}
+	now := time.Now()
+	for _, id := range req.CartIDs {
+		// The prices before are compared to the new ones to tell customers about price drops
+		before, _ := h.repoFor(c).GetCart(id)
+		changed, err := h.repoFor(c).RepriceCart(id, now)
+		switch {
+		case errors.Is(err, cart.ErrCartNotFound) || errors.Is(err, cart.ErrCartClosed) || errors.Is(err, cart.ErrCartLocked):
+			resp.Skipped = append(resp.Skipped, id)
+		case err != nil:
+			log.Printf("Failed to reprice cart %d: %v", id, err)
+			writeProblem(c, http.StatusInternalServerError, struct {
+				Problem
+				Result RepriceCartsResponse `json:"result"`
+			}{newProblem(c, http.StatusInternalServerError, "failed to reprice carts"), resp})
+			return
+		case changed:
+			resp.Repriced = append(resp.Repriced, id)
+			if after, err := h.repoFor(c).GetCart(id); err == nil && before != nil {
+				publishPriceDrops(h.events, before, after)
+			}
+		default:
+			resp.Unchanged = append(resp.Unchanged, id)
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,119 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"interview/internal/api"
+	"interview/internal/cart"
+	"interview/internal/repo"
+	"interview/internal/storage"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStalePrices(t *testing.T) {
+	ts := setupTest(t)
+	ts.clearDatabase(t)
+
+	cartRepo := repo.NewRepository(ts.db)
+	_, err := cartRepo.UpsertProduct("shoe", 12.0)
+	require.NoError(t, err)
+	_, err = cartRepo.UpsertProduct("bag", 30.0)
+	require.NoError(t, err)
+
+	stale, err := cartRepo.GetOrCreateCart("stale", cart.DefaultName)
+	require.NoError(t, err)
+	require.NoError(t, cartRepo.AddCartItem(stale.ID, "shoe", 2, 10.0))
+	require.NoError(t, cartRepo.AddCartItem(stale.ID, "bag", 1, 30.0))
+	current, err := cartRepo.GetOrCreateCart("current", cart.DefaultName)
+	require.NoError(t, err)
+	require.NoError(t, cartRepo.AddCartItem(current.ID, "bag", 1, 30.0))
+	closed, err := cartRepo.GetOrCreateCart("closed", cart.DefaultName)
+	require.NoError(t, err)
+	require.NoError(t, cartRepo.AddCartItem(closed.ID, "shoe", 1, 10.0))
+	require.NoError(t, cartRepo.CloseCart(closed.ID))
+
+	router := gin.New()
+	media := storage.NewLocal(t.TempDir(), "/media", []byte("test_secret"))
+	api.NewAdminHandler(ts.db, media, time.Hour).RegisterRoutes(router, gin.Accounts{"admin": "secret"})
+	do := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&buf).Encode(body))
+		}
+		req := httptest.NewRequest(method, path, &buf)
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Report", func(t *testing.T) {
+		w := do(http.MethodGet, "/admin/reports/stale-prices", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var report []api.StalePriceResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+		require.Len(t, report, 1)
+		assert.Equal(t, stale.ID, report[0].CartID)
+		assert.Equal(t, "shoe", report[0].Product)
+		assert.Equal(t, 10.0, report[0].Price)
+		assert.Equal(t, 12.0, report[0].CatalogPrice)
+		assert.Equal(t, 4.0, report[0].Difference)
+	})
+
+	t.Run("Reprice", func(t *testing.T) {
+		w := do(http.MethodPost, "/admin/carts/reprice", api.RepriceCartsRequest{CartIDs: []uint{stale.ID, current.ID, closed.ID}})
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp api.RepriceCartsResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, []uint{stale.ID}, resp.Repriced)
+		assert.Equal(t, []uint{current.ID}, resp.Unchanged)
+		assert.Equal(t, []uint{closed.ID}, resp.Skipped)
+
+		repriced, err := cartRepo.GetCart(stale.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 54.0, repriced.Total)
+
+		w = do(http.MethodGet, "/admin/reports/stale-prices", nil)
+		assert.JSONEq(t, `[]`, w.Body.String())
+	})
+
+	t.Run("Reprice Keeps The Charges Of The Shop", func(t *testing.T) {
+		charges := cart.Charges{TaxRate: 0.19, Shipping: 4.9, FreeShippingFrom: 100}
+		shop := repo.NewRepository(ts.db)
+		shop.SetCharges(charges)
+		admin := api.NewAdminHandler(ts.db, media, time.Hour)
+		admin.SetRepository(shop)
+		router := gin.New()
+		admin.RegisterRoutes(router, gin.Accounts{"admin": "secret"})
+
+		charged, err := cartRepo.GetOrCreateCart("charged", cart.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AddCartItem(charged.ID, "shoe", 2, 10.0))
+		var buf bytes.Buffer
+		require.NoError(t, json.NewEncoder(&buf).Encode(api.RepriceCartsRequest{CartIDs: []uint{charged.ID}}))
+		req := httptest.NewRequest(http.MethodPost, "/admin/carts/reprice", &buf)
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		repriced, err := cartRepo.GetCart(charged.ID)
+		require.NoError(t, err)
+		assert.Equal(t, charges.Totals(24, 0, 0).Total, repriced.Total)
+		assert.Greater(t, repriced.Total, 24.0)
+	})
+
+	t.Run("Missing Selection", func(t *testing.T) {
+		w := do(http.MethodPost, "/admin/carts/reprice", api.RepriceCartsRequest{})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
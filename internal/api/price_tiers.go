@@ -0,0 +1,102 @@
+package api
+
+import (
+	"errors"
+	"interview/internal/product"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type (
+	// PriceTiersRequest is the JSON body accepted by PUT /admin/products/:id/price-tiers. It replaces the
+	// tiers of the product, an empty list removes them.
+	PriceTiersRequest struct {
+		Tiers []PriceTierRequest `json:"tiers"`
+	}
+
+	// PriceTierRequest is a price tier of a PriceTiersRequest.
+	PriceTierRequest struct {
+		MinQuantity int      `json:"min_quantity"`
+		Price       *float64 `json:"price"`
+	}
+
+	// PriceTiersResponse lists the price tiers of a product ordered by quantity.
+	PriceTiersResponse struct {
+		ProductID uint                `json:"product_id"`
+		Tiers     []PriceTierResponse `json:"tiers"`
+	}
+
+	// PriceTierResponse is the JSON representation of a price tier: MinQuantity or more units of the
+	// product in one cart sell at Price each.
+	PriceTierResponse struct {
+		MinQuantity int     `json:"min_quantity"`
+		Price       float64 `json:"price"`
+	}
+)
+
+// ListPriceTiers returns the volume discounts of a product.
+func (h *AdminHandler) ListPriceTiers(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid product ID")
+		return
+	}
+	tiers, err := h.repoFor(c).ListPriceTiers(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondWithProblem(c, http.StatusNotFound, "product not found")
+		return
+	} else if err != nil {
+		log.Printf("Failed to list price tiers: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to list price tiers")
+		return
+	}
+	c.JSON(http.StatusOK, newPriceTiersResponse(uint(id), tiers))
+}
+
+// UpdatePriceTiers replaces the volume discounts of a product, e.g. 10 or more units at 9 and 50 or
+// more at 8. Carts holding the product get the tiers on their next change or price refresh.
+func (h *AdminHandler) UpdatePriceTiers(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid product ID")
+		return
+	}
+	var req PriceTiersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	tiers := make([]product.PriceTier, len(req.Tiers))
+	for i, tier := range req.Tiers {
+		if tier.Price == nil {
+			respondWithInvalidField(c, "tiers", "every tier needs a price")
+			return
+		}
+		tiers[i] = product.PriceTier{MinQuantity: tier.MinQuantity, Price: *tier.Price}
+	}
+
+	if err := h.repoFor(c).SetPriceTiers(uint(id), tiers); errors.Is(err, product.ErrInvalidTiers) {
+		respondWithInvalidField(c, "tiers", "need distinct quantities of at least 2 and prices of at least 0 that drop as the quantity grows")
+		return
+	} else if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondWithProblem(c, http.StatusNotFound, "product not found")
+		return
+	} else if err != nil {
+		log.Printf("Failed to set price tiers: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to set price tiers")
+		return
+	}
+	c.JSON(http.StatusOK, newPriceTiersResponse(uint(id), tiers))
+}
+
+func newPriceTiersResponse(productID uint, tiers []product.PriceTier) PriceTiersResponse {
+	resp := PriceTiersResponse{ProductID: productID, Tiers: make([]PriceTierResponse, len(tiers))}
+	for i, tier := range tiers {
+		resp.Tiers[i] = PriceTierResponse{MinQuantity: tier.MinQuantity, Price: tier.Price}
+	}
+	return resp
+}
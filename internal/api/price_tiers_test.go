@@ -0,0 +1,79 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"interview/internal/api"
+	"interview/internal/repo"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriceTiers(t *testing.T) {
+	ts := setupTest(t)
+	ts.clearDatabase(t)
+	shoe, err := repo.NewRepository(ts.db).UpsertProduct("shoe", 10.0)
+	require.NoError(t, err)
+
+	router := gin.New()
+	api.NewAdminHandler(ts.db, nil, time.Hour).RegisterRoutes(router, gin.Accounts{"admin": "secret"})
+	request := func(t *testing.T, method, path string, body interface{}) *httptest.ResponseRecorder {
+		t.Helper()
+		var buf bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&buf).Encode(body))
+		}
+		req := httptest.NewRequest(method, path, &buf)
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	path := "/admin/products/" + strconv.FormatUint(uint64(shoe.ID), 10) + "/price-tiers"
+	price := func(p float64) *float64 { return &p }
+
+	t.Run("Invalid Tiers Are Rejected", func(t *testing.T) {
+		for _, tiers := range [][]api.PriceTierRequest{
+			{{MinQuantity: 10}},
+			{{MinQuantity: 1, Price: price(9)}},
+			{{MinQuantity: 10, Price: price(8)}, {MinQuantity: 50, Price: price(9)}},
+		} {
+			w := request(t, http.MethodPut, path, api.PriceTiersRequest{Tiers: tiers})
+			assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+			assert.Contains(t, w.Body.String(), `"field":"tiers"`)
+		}
+		w := request(t, http.MethodPut, "/admin/products/9999/price-tiers", api.PriceTiersRequest{})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Tiers Are Replaced", func(t *testing.T) {
+		w := request(t, http.MethodPut, path, api.PriceTiersRequest{Tiers: []api.PriceTierRequest{
+			{MinQuantity: 50, Price: price(8)},
+			{MinQuantity: 10, Price: price(9)},
+		}})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		w = request(t, http.MethodGet, path, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp api.PriceTiersResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, shoe.ID, resp.ProductID)
+		assert.Equal(t, []api.PriceTierResponse{{MinQuantity: 10, Price: 9}, {MinQuantity: 50, Price: 8}}, resp.Tiers)
+	})
+
+	t.Run("Cart Shows The Volume Price", func(t *testing.T) {
+		cookie := ts.createSession(t)
+		ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"shoe"}, "quantity": {"10"}}, cookie)
+		body := ts.makeRequest(t, http.MethodGet, "/", nil, cookie).Body.String()
+		assert.Contains(t, body, "Volume price from 10")
+	})
+}
@@ -0,0 +1,231 @@
+package api
+
+import (
+	"errors"
+	"interview/internal/pricelist"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type (
+	// PriceListRequest is the JSON body accepted by POST and PATCH /admin/price-lists.
+	PriceListRequest struct {
+		Name          string `json:"name"`
+		CustomerGroup string `json:"customer_group"`
+	}
+
+	// ListPriceRequest is the JSON body accepted by POST /admin/price-lists/:id/prices.
+	ListPriceRequest struct {
+		Product string   `json:"product"`
+		Price   *float64 `json:"price"`
+	}
+
+	// PriceListResponse is the JSON representation of a price list. Prices are only listed when a
+	// single price list is returned.
+	PriceListResponse struct {
+		ID            uint                `json:"id"`
+		Name          string              `json:"name"`
+		CustomerGroup string              `json:"customer_group"`
+		Prices        []ListPriceResponse `json:"prices,omitempty"`
+		CreatedAt     time.Time           `json:"created_at"`
+		UpdatedAt     time.Time           `json:"updated_at"`
+	}
+
+	// ListPriceResponse is the JSON representation of the price of a product on a price list.
+	ListPriceResponse struct {
+		Product string  `json:"product"`
+		Price   float64 `json:"price"`
+	}
+)
+
+// ListPriceLists returns the price lists of the customer groups.
+func (h *AdminHandler) ListPriceLists(c *gin.Context) {
+	lists, err := h.repoFor(c).ListPriceLists()
+	if err != nil {
+		log.Printf("Failed to list price lists: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to list price lists")
+		return
+	}
+	responses := make([]PriceListResponse, len(lists))
+	for i, list := range lists {
+		responses[i] = newPriceListResponse(list)
+	}
+	c.JSON(http.StatusOK, responses)
+}
+
+// CreatePriceList adds a price list for a customer group without one.
+func (h *AdminHandler) CreatePriceList(c *gin.Context) {
+	req, ok := bindPriceList(c)
+	if !ok {
+		return
+	}
+	list := pricelist.PriceList{Name: req.Name, CustomerGroup: req.CustomerGroup}
+	if err := h.repoFor(c).CreatePriceList(&list); errors.Is(err, pricelist.ErrGroupTaken) {
+		respondWithProblem(c, http.StatusConflict, "customer group already has a price list")
+		return
+	} else if err != nil {
+		log.Printf("Failed to create price list: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to create price list")
+		return
+	}
+	c.JSON(http.StatusCreated, newPriceListResponse(list))
+}
+
+// ShowPriceList returns a price list with its prices.
+func (h *AdminHandler) ShowPriceList(c *gin.Context) {
+	id, ok := priceListID(c)
+	if !ok {
+		return
+	}
+	list, err := h.repoFor(c).GetPriceList(id)
+	if errors.Is(err, pricelist.ErrPriceListNotFound) {
+		respondWithProblem(c, http.StatusNotFound, "price list not found")
+		return
+	} else if err != nil {
+		log.Printf("Failed to load price list: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to load price list")
+		return
+	}
+	c.JSON(http.StatusOK, newPriceListResponse(*list))
+}
+
+// UpdatePriceList renames a price list or moves it to another customer group.
+func (h *AdminHandler) UpdatePriceList(c *gin.Context) {
+	id, ok := priceListID(c)
+	if !ok {
+		return
+	}
+	req, ok := bindPriceList(c)
+	if !ok {
+		return
+	}
+	err := h.repoFor(c).UpdatePriceList(id, req.Name, req.CustomerGroup)
+	if errors.Is(err, pricelist.ErrPriceListNotFound) {
+		respondWithProblem(c, http.StatusNotFound, "price list not found")
+		return
+	} else if errors.Is(err, pricelist.ErrGroupTaken) {
+		respondWithProblem(c, http.StatusConflict, "customer group already has a price list")
+		return
+	} else if err != nil {
+		log.Printf("Failed to update price list: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to update price list")
+		return
+	}
+	h.ShowPriceList(c)
+}
+
+// DeletePriceList deletes a price list, so its customer group buys at base prices again.
+func (h *AdminHandler) DeletePriceList(c *gin.Context) {
+	id, ok := priceListID(c)
+	if !ok {
+		return
+	}
+	if err := h.repoFor(c).DeletePriceList(id); errors.Is(err, pricelist.ErrPriceListNotFound) {
+		respondWithProblem(c, http.StatusNotFound, "price list not found")
+		return
+	} else if err != nil {
+		log.Printf("Failed to delete price list: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to delete price list")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// SetListPrice puts a product on a price list or changes its price there. Carts already holding the
+// product get the new price when their prices are refreshed.
+func (h *AdminHandler) SetListPrice(c *gin.Context) {
+	id, ok := priceListID(c)
+	if !ok {
+		return
+	}
+	var req ListPriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if sold, err := h.repoFor(c).HasProduct(req.Product); err != nil {
+		log.Printf("Failed to look up product: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to look up product")
+		return
+	} else if !sold {
+		respondWithProblem(c, http.StatusBadRequest, "unknown product")
+		return
+	}
+	if req.Price == nil || *req.Price < 0 {
+		respondWithInvalidField(c, "price", "must be a number of at least 0")
+		return
+	}
+	if err := h.repoFor(c).SetListPrice(id, req.Product, *req.Price); errors.Is(err, pricelist.ErrPriceListNotFound) {
+		respondWithProblem(c, http.StatusNotFound, "price list not found")
+		return
+	} else if err != nil {
+		log.Printf("Failed to set list price: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to set price")
+		return
+	}
+	c.JSON(http.StatusOK, ListPriceResponse{Product: req.Product, Price: *req.Price})
+}
+
+// RemoveListPrice takes a product off a price list, selling it at its base price again.
+func (h *AdminHandler) RemoveListPrice(c *gin.Context) {
+	id, ok := priceListID(c)
+	if !ok {
+		return
+	}
+	if err := h.repoFor(c).RemoveListPrice(id, c.Param("product")); err != nil {
+		log.Printf("Failed to remove list price: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to remove price")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// bindPriceList parses and checks the body of a price list request. When ok is false, the response was
+// sent already.
+func bindPriceList(c *gin.Context) (PriceListRequest, bool) {
+	var req PriceListRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid request body")
+		return req, false
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	req.CustomerGroup = strings.ToLower(strings.TrimSpace(req.CustomerGroup))
+	if req.Name == "" {
+		respondWithInvalidField(c, "name", "is required")
+		return req, false
+	}
+	if !pricelist.ValidGroup(req.CustomerGroup) {
+		respondWithInvalidField(c, "customer_group", "must be "+strings.Join(pricelist.Groups, ", "))
+		return req, false
+	}
+	return req, true
+}
+
+// priceListID parses the "id" parameter. When ok is false, the response was sent already.
+func priceListID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid price list ID")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func newPriceListResponse(list pricelist.PriceList) PriceListResponse {
+	response := PriceListResponse{
+		ID:            list.ID,
+		Name:          list.Name,
+		CustomerGroup: list.CustomerGroup,
+		CreatedAt:     list.CreatedAt,
+		UpdatedAt:     list.UpdatedAt,
+	}
+	for _, p := range list.Prices {
+		response.Prices = append(response.Prices, ListPriceResponse{Product: p.Product, Price: p.Price})
+	}
+	return response
+}
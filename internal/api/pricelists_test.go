@@ -0,0 +1,117 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"interview/internal/api"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminPriceLists(t *testing.T) {
+	ts := setupTest(t)
+	ts.clearDatabase(t)
+
+	router := gin.New()
+	api.NewAdminHandler(ts.db, nil, time.Hour).RegisterRoutes(router, gin.Accounts{"admin": "secret"})
+	request := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		var payload bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&payload).Encode(body))
+		}
+		req := httptest.NewRequest(method, path, &payload)
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	var created api.PriceListResponse
+
+	t.Run("Create", func(t *testing.T) {
+		tests := []struct {
+			name         string
+			body         api.PriceListRequest
+			expectedCode int
+		}{
+			{"Missing Name", api.PriceListRequest{CustomerGroup: "wholesale"}, http.StatusBadRequest},
+			{"Unknown Group", api.PriceListRequest{Name: "Staff", CustomerGroup: "staff"}, http.StatusBadRequest},
+			{"Valid Price List", api.PriceListRequest{Name: "Wholesale", CustomerGroup: "Wholesale"}, http.StatusCreated},
+			{"Group Taken", api.PriceListRequest{Name: "Resellers", CustomerGroup: "wholesale"}, http.StatusConflict},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				w := request(http.MethodPost, "/admin/price-lists", tt.body)
+				assert.Equal(t, tt.expectedCode, w.Code)
+				if w.Code == http.StatusCreated {
+					require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+				}
+			})
+		}
+		assert.Equal(t, "wholesale", created.CustomerGroup)
+
+		w := request(http.MethodGet, "/admin/price-lists", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var lists []api.PriceListResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &lists))
+		require.Len(t, lists, 1)
+		assert.Equal(t, "Wholesale", lists[0].Name)
+	})
+
+	t.Run("Prices", func(t *testing.T) {
+		path := fmt.Sprintf("/admin/price-lists/%d/prices", created.ID)
+		price, negative := 7.5, -1.0
+		tests := []struct {
+			name         string
+			path         string
+			body         api.ListPriceRequest
+			expectedCode int
+		}{
+			{"Unknown Product", path, api.ListPriceRequest{Product: "hat", Price: &price}, http.StatusBadRequest},
+			{"Missing Price", path, api.ListPriceRequest{Product: "shoe"}, http.StatusBadRequest},
+			{"Negative Price", path, api.ListPriceRequest{Product: "shoe", Price: &negative}, http.StatusBadRequest},
+			{"Unknown Price List", fmt.Sprintf("/admin/price-lists/%d/prices", created.ID+100), api.ListPriceRequest{Product: "shoe", Price: &price}, http.StatusNotFound},
+			{"Valid Price", path, api.ListPriceRequest{Product: "shoe", Price: &price}, http.StatusOK},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert.Equal(t, tt.expectedCode, request(http.MethodPost, tt.path, tt.body).Code)
+			})
+		}
+
+		w := request(http.MethodGet, fmt.Sprintf("/admin/price-lists/%d", created.ID), nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var list api.PriceListResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+		assert.Equal(t, []api.ListPriceResponse{{Product: "shoe", Price: 7.5}}, list.Prices)
+
+		assert.Equal(t, http.StatusNoContent, request(http.MethodDelete, path+"/shoe", nil).Code)
+		w = request(http.MethodGet, fmt.Sprintf("/admin/price-lists/%d", created.ID), nil)
+		var emptied api.PriceListResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &emptied))
+		assert.Empty(t, emptied.Prices)
+	})
+
+	t.Run("Update And Delete", func(t *testing.T) {
+		path := fmt.Sprintf("/admin/price-lists/%d", created.ID)
+		w := request(http.MethodPatch, path, api.PriceListRequest{Name: "Friends", CustomerGroup: "vip"})
+		require.Equal(t, http.StatusOK, w.Code)
+		var list api.PriceListResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+		assert.Equal(t, "Friends", list.Name)
+		assert.Equal(t, "vip", list.CustomerGroup)
+
+		assert.Equal(t, http.StatusNoContent, request(http.MethodDelete, path, nil).Code)
+		assert.Equal(t, http.StatusNotFound, request(http.MethodGet, path, nil).Code)
+		assert.Equal(t, http.StatusNotFound, request(http.MethodDelete, path, nil).Code)
+		assert.Equal(t, http.StatusBadRequest, request(http.MethodGet, "/admin/price-lists/abc", nil).Code)
+	})
+}
@@ -0,0 +1,231 @@
+package api
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"interview/internal/product"
+	"interview/internal/repo"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxProductImportSize is the largest CSV accepted by the product import
+const maxProductImportSize = 32 << 20
+
+// productCSVColumns are the columns of the product export, and those the import accepts in any order.
+// Only name is required; cells left empty, like the stock of products whose stock isn't tracked, leave
+// the product unchanged.
+var productCSVColumns = []string{"name", "price", "stock", "low_stock_threshold", "type"}
+
+// errInvalidRows rolls back imports with invalid rows
+var errInvalidRows = errors.New("invalid rows")
+
+type (
+	// ProductImportReport tells how an import went. Created and Updated count the rows that were, or
+	// in a dry run would be, applied; Applied is false when nothing was imported.
+	ProductImportReport struct {
+		DryRun  bool                 `json:"dry_run"`
+		Applied bool                 `json:"applied"`
+		Rows    int                  `json:"rows"`
+		Created int                  `json:"created"`
+		Updated int                  `json:"updated"`
+		Errors  []ProductImportError `json:"errors"`
+	}
+
+	// ProductImportError is a row of the import that can't be applied.
+	ProductImportError struct {
+		// Line is the line of the row in the CSV, the header being line 1
+		Line    int    `json:"line"`
+		Product string `json:"product,omitempty"`
+		Error   string `json:"error"`
+	}
+)
+
+// ImportProducts creates and updates products from the CSV in the request body, in the format of
+// ExportProducts. The rows are parsed as they are read and imported in one transaction: when a row
+// is invalid, nothing is imported and the response lists every invalid row with 422. With
+// ?dry_run=true the rows are checked without importing them.
+func (h *AdminHandler) ImportProducts(c *gin.Context) {
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		respondWithInvalidField(c, "dry_run", "must be true or false")
+		return
+	}
+
+	r := csv.NewReader(http.MaxBytesReader(c.Writer, c.Request.Body, maxProductImportSize))
+	r.ReuseRecord = true
+	header, err := r.Read()
+	if err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "the CSV needs a header row")
+		return
+	}
+	columns, err := parseProductColumns(header)
+	if err != nil {
+		respondWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report := ProductImportReport{DryRun: dryRun, Errors: []ProductImportError{}}
+	err = h.repoFor(c).ImportProducts(dryRun, func(importer *repo.ProductImporter) error {
+		for {
+			record, err := r.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) && errors.Is(err, csv.ErrFieldCount) {
+				report.Rows++
+				report.Errors = append(report.Errors, ProductImportError{Line: parseErr.StartLine, Error: "wrong number of fields"})
+				continue
+			} else if err != nil {
+				return err
+			}
+
+			report.Rows++
+			line, _ := r.FieldPos(0)
+			row, err := parseProductRow(columns, record)
+			if err == nil {
+				err = importer.Apply(row)
+				if err != nil && !repo.IsProductImportFailure(err) {
+					return err
+				}
+			}
+			if err != nil {
+				report.Errors = append(report.Errors, ProductImportError{Line: line, Product: row.Name, Error: err.Error()})
+			}
+		}
+		report.Created, report.Updated = importer.Created, importer.Updated
+		if len(report.Errors) > 0 {
+			return errInvalidRows
+		}
+		return nil
+	})
+
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.Is(err, errInvalidRows):
+		writeProblem(c, http.StatusUnprocessableEntity, struct {
+			Problem
+			Result ProductImportReport `json:"result"`
+		}{newProblem(c, http.StatusUnprocessableEntity, "the CSV has invalid rows, nothing was imported"), report})
+	case errors.As(err, &tooLarge):
+		respondWithProblem(c, http.StatusRequestEntityTooLarge, "the CSV is too large")
+	case errors.Is(err, csv.ErrQuote) || errors.Is(err, csv.ErrBareQuote):
+		respondWithProblem(c, http.StatusBadRequest, err.Error())
+	case err != nil:
+		log.Printf("Failed to import products: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to import products")
+	default:
+		report.Applied = !dryRun
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// ExportProducts streams the catalog as CSV, in the format ImportProducts reads.
+func (h *AdminHandler) ExportProducts(c *gin.Context) {
+	filename := fmt.Sprintf("products-%s.csv", time.Now().UTC().Format("20060102-150405"))
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	err := w.Write(productCSVColumns)
+	if err == nil {
+		err = h.repoFor(c).EachProductBatch(exportBatchSize, func(products []product.Product) error {
+			for _, p := range products {
+				if err := w.Write([]string{
+					p.Name, strconv.FormatFloat(p.Price, 'f', -1, 64), formatOptionalInt(p.Stock),
+					formatOptionalInt(p.LowStockThreshold), p.Type,
+				}); err != nil {
+					return err
+				}
+			}
+			w.Flush()
+			c.Writer.Flush()
+			return w.Error()
+		})
+	}
+	w.Flush()
+	if err == nil {
+		err = w.Error()
+	}
+	// The status has been sent, so a failure can only cut the export short
+	if err != nil {
+		log.Printf("Product export failed: %v", err)
+	}
+}
+
+// parseProductColumns returns the index of each column of the header, failing for unknown columns and
+// headers without a name column.
+func parseProductColumns(header []string) (map[string]int, error) {
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		known := false
+		for _, column := range productCSVColumns {
+			known = known || name == column
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown column %q, the columns are %s", name, strings.Join(productCSVColumns, ", "))
+		}
+		if _, ok := columns[name]; ok {
+			return nil, fmt.Errorf("column %q appears twice", name)
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, errors.New("the CSV needs a name column")
+	}
+	return columns, nil
+}
+
+// parseProductRow returns the import of a CSV row, failing for cells that aren't valid for their column
+func parseProductRow(columns map[string]int, record []string) (repo.ProductImport, error) {
+	cell := func(column string) string {
+		if i, ok := columns[column]; ok {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	row := repo.ProductImport{Name: cell("name"), Type: cell("type")}
+	if raw := cell("price"); raw != "" {
+		price, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return row, errors.New("price must be a number")
+		}
+		row.Price = &price
+	}
+	var err error
+	if row.Stock, err = parseOptionalInt(cell("stock"), "stock"); err != nil {
+		return row, err
+	}
+	if row.LowStockThreshold, err = parseOptionalInt(cell("low_stock_threshold"), "low_stock_threshold"); err != nil {
+		return row, err
+	}
+	return row, nil
+}
+
+func parseOptionalInt(raw, column string) (*int, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a whole number", column)
+	}
+	return &n, nil
+}
+
+func formatOptionalInt(n *int) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.Itoa(*n)
+}
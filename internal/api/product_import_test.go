@@ -0,0 +1,107 @@
+package api_test
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"interview/internal/api"
+	"interview/internal/repo"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductImport(t *testing.T) {
+	ts := setupTest(t)
+	ts.clearDatabase(t)
+	// The export lists the whole catalog, so it starts out without the sample products
+	require.NoError(t, ts.db.Exec("DELETE FROM products").Error)
+	cartRepo := repo.NewRepository(ts.db)
+	_, err := cartRepo.UpsertProduct("shoe", 10)
+	require.NoError(t, err)
+
+	router := gin.New()
+	api.NewAdminHandler(ts.db, nil, time.Hour).RegisterRoutes(router, gin.Accounts{"admin": "secret"})
+	request := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "text/csv")
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	price := func(name string) float64 {
+		products, err := cartRepo.GetProductsByName([]string{name})
+		require.NoError(t, err)
+		return products[name].Price
+	}
+
+	t.Run("Dry Run", func(t *testing.T) {
+		w := request(http.MethodPost, "/admin/products/import?dry_run=true", "name,price\nshoe,12\nhat,5\n")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var report api.ProductImportReport
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+		assert.Equal(t, api.ProductImportReport{DryRun: true, Rows: 2, Created: 1, Updated: 1, Errors: []api.ProductImportError{}}, report)
+		assert.Equal(t, 10.0, price("shoe"), "dry runs change nothing")
+	})
+
+	t.Run("Invalid Rows", func(t *testing.T) {
+		w := request(http.MethodPost, "/admin/products/import",
+			"name,price,stock\nshoe,12,\nscarf,,3\nhat,cheap,\nsock,2\nbelt,-1,\n")
+		require.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+		var body struct {
+			api.Problem
+			Result api.ProductImportReport `json:"result"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.False(t, body.Result.Applied)
+		assert.Equal(t, []api.ProductImportError{
+			{Line: 3, Product: "scarf", Error: "price is required for new products"},
+			{Line: 4, Product: "hat", Error: "price must be a number"},
+			{Line: 5, Error: "wrong number of fields"},
+			{Line: 6, Product: "belt", Error: "price must be at least 0"},
+		}, body.Result.Errors)
+		assert.Equal(t, 10.0, price("shoe"), "nothing is imported when a row is invalid")
+	})
+
+	t.Run("Unknown Column", func(t *testing.T) {
+		w := request(http.MethodPost, "/admin/products/import", "name,colour\nshoe,red\n")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Import", func(t *testing.T) {
+		w := request(http.MethodPost, "/admin/products/import",
+			"stock,name,price,low_stock_threshold,type\n4,shoe,12.5,2,\n,hat,5,,physical\n")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var report api.ProductImportReport
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+		assert.True(t, report.Applied)
+		assert.Equal(t, 1, report.Created)
+		assert.Equal(t, 12.5, price("shoe"))
+		assert.Equal(t, 5.0, price("hat"))
+	})
+
+	t.Run("Export", func(t *testing.T) {
+		w := request(http.MethodGet, "/admin/products/export", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+		rows, err := csv.NewReader(w.Body).ReadAll()
+		require.NoError(t, err)
+		assert.Equal(t, [][]string{
+			{"name", "price", "stock", "low_stock_threshold", "type"},
+			{"shoe", "12.5", "4", "2", "physical"},
+			{"hat", "5", "", "", "physical"},
+		}, rows)
+
+		// The export imports back without changes
+		var export strings.Builder
+		require.NoError(t, csv.NewWriter(&export).WriteAll(rows))
+		w = request(http.MethodPost, "/admin/products/import", export.String())
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+}
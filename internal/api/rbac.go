@@ -0,0 +1,64 @@
+package api
+
+import (
+	"interview/internal/auth"
+	"interview/internal/user"
+	"net/http"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// SetRequireStaff2FA keeps users with a staff role out of the admin area until they enabled two-factor
+// authentication. It may change while the server runs.
+func (h *AdminHandler) SetRequireStaff2FA(require bool) {
+	h.requireStaff2FA.Store(require)
+}
+
+// authenticateStaff lets users logged in to the shop with a staff role into the admin area and hands
+// everyone else to fallback, which authenticates staff members with basic auth or single sign-on.
+func (h *AdminHandler) authenticateStaff(fallback gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if u := h.sessionUser(c); u != nil && auth.IsStaffRole(u.Role) {
+			if h.requireStaff2FA.Load() && !u.TOTPEnabled {
+				respondWithProblem(c, http.StatusForbidden, "two-factor authentication required")
+				return
+			}
+			c.Set(staffRoleKey, u.Role)
+			return
+		}
+		fallback(c)
+	}
+}
+
+// sessionUser returns the user logged in to the session, if any.
+func (h *AdminHandler) sessionUser(c *gin.Context) *user.User {
+	// Admin routes may be mounted without sessions, e.g. when only used with basic auth
+	if _, ok := c.Get(sessions.DefaultKey); !ok {
+		return nil
+	}
+	// Staff members viewing a customer's cart are logged in as the customer in the shop only
+	session := sessions.Default(c)
+	key := "user_id"
+	if _, impersonating := session.Get(impersonatorKey).(string); impersonating {
+		key = impersonatorUserKey
+	}
+	userID, ok := session.Get(key).(uint)
+	if !ok {
+		return nil
+	}
+	u, err := h.repoFor(c).GetUser(userID)
+	if err != nil {
+		return nil
+	}
+	return u
+}
+
+// requirePermission only lets staff members whose role has the permission through.
+func requirePermission(perm auth.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !auth.Can(c.GetString(staffRoleKey), perm) {
+			respondWithProblem(c, http.StatusForbidden, "permission denied")
+		}
+	}
+}
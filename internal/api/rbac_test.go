@@ -0,0 +1,98 @@
+package api_test
+
+import (
+	"interview/internal/api"
+	"interview/internal/auth"
+	"interview/internal/config"
+	"interview/internal/user"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminPermissions(t *testing.T) {
+	ts := setupTest(t)
+	setupAuthRoutes(t, ts)
+	admin := api.NewAdminHandler(ts.db, nil, time.Hour)
+	admin.SetConfig(api.NewLiveConfig(config.Config{}))
+	admin.SetMaintenance(&api.Maintenance{})
+	admin.RegisterRoutes(ts.router, gin.Accounts{"admin": "secret"})
+
+	// loginAs logs a new session in with the fake Google account and gives the user the role
+	loginAs := func(t *testing.T, role string) *http.Cookie {
+		t.Helper()
+		ts.clearDatabase(t)
+		cookie := ts.createSession(t)
+		w := ts.makeRequest(t, http.MethodGet, "/auth/google/login", nil, cookie)
+		location, err := url.Parse(w.Header().Get("Location"))
+		require.NoError(t, err)
+		w = ts.makeRequest(t, http.MethodGet, "/auth/google/callback?code=abc&state="+location.Query().Get("state"), nil, cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+		require.NoError(t, ts.db.Model(&user.User{}).Where("1 = 1").Update("role", role).Error)
+		return cookie
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status map[string]int
+	}{
+		{
+			name: "View Carts", method: http.MethodGet, path: "/admin/carts/export?format=json",
+			status: map[string]int{auth.RoleCustomer: http.StatusUnauthorized, auth.RoleSupport: http.StatusOK, auth.RoleAdmin: http.StatusOK},
+		},
+		{
+			name: "View Stale Prices", method: http.MethodGet, path: "/admin/reports/stale-prices",
+			status: map[string]int{auth.RoleCustomer: http.StatusUnauthorized, auth.RoleSupport: http.StatusOK, auth.RoleAdmin: http.StatusOK},
+		},
+		{
+			name: "Modify Products", method: http.MethodPost, path: "/admin/products/1/image",
+			status: map[string]int{auth.RoleCustomer: http.StatusUnauthorized, auth.RoleSupport: http.StatusForbidden, auth.RoleAdmin: http.StatusNotFound},
+		},
+		{
+			name: "Reprice Carts", method: http.MethodPost, path: "/admin/carts/reprice",
+			status: map[string]int{auth.RoleCustomer: http.StatusUnauthorized, auth.RoleSupport: http.StatusForbidden, auth.RoleAdmin: http.StatusBadRequest},
+		},
+		{
+			name: "Sales Report", method: http.MethodGet, path: "/admin/reports/sales",
+			status: map[string]int{auth.RoleCustomer: http.StatusUnauthorized, auth.RoleSupport: http.StatusForbidden, auth.RoleAdmin: http.StatusOK},
+		},
+		{
+			name: "View Settings", method: http.MethodGet, path: "/admin/config",
+			status: map[string]int{auth.RoleCustomer: http.StatusUnauthorized, auth.RoleSupport: http.StatusForbidden, auth.RoleAdmin: http.StatusOK},
+		},
+		{
+			name: "Manage Settings", method: http.MethodPost, path: "/admin/maintenance",
+			status: map[string]int{auth.RoleCustomer: http.StatusUnauthorized, auth.RoleSupport: http.StatusForbidden, auth.RoleAdmin: http.StatusBadRequest},
+		},
+		{
+			name: "Manage Webhooks", method: http.MethodGet, path: "/admin/webhooks",
+			status: map[string]int{auth.RoleCustomer: http.StatusUnauthorized, auth.RoleSupport: http.StatusForbidden, auth.RoleAdmin: http.StatusOK},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, role := range []string{auth.RoleCustomer, auth.RoleSupport, auth.RoleAdmin} {
+				cookie := loginAs(t, role)
+				w := ts.makeRequest(t, tt.method, tt.path, nil, cookie)
+				assert.Equal(t, tt.status[role], w.Code, role)
+			}
+		})
+	}
+
+	t.Run("Basic Auth Account Is Admin", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/admin/webhooks", nil)
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		ts.router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"html/template"
+	"interview/internal/cart"
+	"interview/internal/i18n"
+	productpkg "interview/internal/product"
+	"interview/internal/recommend"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/csrf"
+)
+
+const (
+	// recentlyViewedKey is the session key of the IDs of the products viewed last, newest first and
+	// separated by commas
+	recentlyViewedKey = "recently_viewed"
+	// maxRecentlyViewed is how many viewed products the session remembers
+	maxRecentlyViewed = 6
+	// maxRecommendations is how many recommended products the cart and product pages show
+	maxRecommendations = 4
+)
+
+// ProductData contains data to be rendered in the product template.
+type ProductData struct {
+	Error         string
+	Locale        string
+	CSRFFieldName template.HTML
+	Product       *ProductView
+	// ImageURL is the signed URL of the product image, empty when it has none
+	ImageURL string
+	// Components are the products the product bundles, none for products that aren't bundles
+	Components []BundleComponentView
+	// Recommendations are the products often bought together with the product
+	Recommendations []ProductView
+	// StockNotifications offers to subscribe to an email when the product is out of stock, prefilled
+	// with the Email of the logged-in user
+	StockNotifications bool
+	Email              string
+	// Shop is the name of the shop the page belongs to, empty when the deployment serves one shop
+	Shop string
+	// BotFields and BotScript add the bot check to the form adding the product, empty without one
+	BotFields template.HTML
+	BotScript template.HTML
+}
+
+// SetRecommender sets the provider of the products recommended on the cart and product pages, nil to
+// show none.
+func (h *CartHandler) SetRecommender(recommender recommend.Provider) {
+	h.recommender = recommender
+}
+
+// ShowProduct shows the product with the ID of the path and remembers it as recently viewed.
+func (h *CartHandler) ShowProduct(c *gin.Context) {
+	session := sessions.Default(c)
+	data := ProductData{Locale: detectLocale(c, session).String(), Shop: shopName(c)}
+	data.CSRFFieldName = csrf.TemplateField(c.Request)
+	data.BotFields, data.BotScript = h.botFields()
+
+	status := http.StatusOK
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		status, data.Error = http.StatusNotFound, "Product not found"
+	} else if p, err := h.carts.GetProduct(c.Request.Context(), uint(id)); err != nil {
+		status, data.Error = http.StatusNotFound, "Product not found"
+	} else {
+		views := h.createProductViews(c, []productpkg.Product{*p}, sessionCurrency(session))
+		data.Product = &views[0]
+		if h.storage != nil && p.ImageKey != "" {
+			if url, err := h.storage.SignedURL(p.ImageKey, h.mediaTTL); err == nil {
+				data.ImageURL = url
+			}
+		}
+		data.Components = h.bundleComponentViews(c, p.ID)
+		data.Recommendations = h.recommendations(c, []string{p.Name}, sessionCurrency(session))
+		rememberViewed(session, p.ID)
+	}
+	data.StockNotifications = h.stockNotifications
+	if userID, ok := session.Get("user_id").(uint); ok && data.StockNotifications {
+		if u, err := h.repoFor(c).GetUser(userID); err == nil {
+			data.Email = u.Email
+		}
+	}
+
+	data.Error = i18n.T(data.Locale, data.Error)
+	c.Status(status)
+	if err := h.templateFor(c).ExecuteTemplate(c.Writer, "product.html", data); err != nil {
+		log.Printf("Failed to render template: %v", err)
+	}
+}
+
+// rememberViewed puts the product first in the recently viewed products of the session.
+func rememberViewed(session sessions.Session, id uint) {
+	ids := []uint{id}
+	for _, viewed := range recentlyViewed(session) {
+		if viewed != id && len(ids) < maxRecentlyViewed {
+			ids = append(ids, viewed)
+		}
+	}
+	values := make([]string, len(ids))
+	for i, id := range ids {
+		values[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	session.Set(recentlyViewedKey, strings.Join(values, ","))
+	if err := session.Save(); err != nil {
+		log.Printf("Failed to save session: %v", err)
+	}
+}
+
+// recentlyViewed returns the IDs of the products viewed last in the session, newest first.
+func recentlyViewed(session sessions.Session) []uint {
+	value, _ := session.Get(recentlyViewedKey).(string)
+	var ids []uint
+	for _, s := range strings.Split(value, ",") {
+		if id, err := strconv.ParseUint(s, 10, 64); err == nil {
+			ids = append(ids, uint(id))
+		}
+	}
+	return ids
+}
+
+// addProductStrips shows the products recently viewed in the session and those recommended for the
+// cart. Both are left out when they fail to load.
+func (h *CartHandler) addProductStrips(c *gin.Context, session sessions.Session, data *TemplateData, userCart *cart.Cart) {
+	if ids := recentlyViewed(session); len(ids) > 0 {
+		products, err := h.repoFor(c).GetProductsByID(ids)
+		if err != nil {
+			log.Printf("Failed to load recently viewed products: %v", err)
+		} else {
+			data.RecentlyViewed = h.createProductViews(c, products, data.Currency)
+		}
+	}
+
+	names := make([]string, len(userCart.CartItems))
+	for i, item := range userCart.CartItems {
+		names[i] = item.ProductName
+	}
+	data.Recommendations = h.recommendations(c, names, data.Currency)
+}
+
+// recommendations returns the products recommended to customers interested in the named products, none
+// without a recommender or when they fail to load.
+func (h *CartHandler) recommendations(c *gin.Context, names []string, currency string) []ProductView {
+	if h.recommender == nil || len(names) == 0 {
+		return nil
+	}
+	products, err := h.recommender.Recommend(c.Request.Context(), names, maxRecommendations)
+	if err != nil {
+		log.Printf("Failed to recommend products: %v", err)
+		return nil
+	}
+	return h.createProductViews(c, products, currency)
+}
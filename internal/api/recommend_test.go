@@ -0,0 +1,99 @@
+package api_test
+
+import (
+	"context"
+	"fmt"
+	"interview/internal/cart"
+	"interview/internal/recommend"
+	"interview/internal/repo"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductRecommendations(t *testing.T) {
+	ts := setupTest(t)
+	cartRepo := repo.NewRepository(ts.db)
+	ts.handler.SetRecommender(recommend.NewBoughtTogether(cartRepo))
+	t.Cleanup(func() { ts.handler.SetRecommender(nil) })
+
+	// setup adds the products and a checked out cart holding the shoe and the watch
+	setup := func(t *testing.T) (shoeID, bagID uint) {
+		t.Helper()
+		ts.clearDatabase(t)
+		ids := map[string]uint{}
+		for _, name := range []string{"shoe", "bag", "watch"} {
+			p, err := cartRepo.UpsertProduct(name, 10)
+			require.NoError(t, err)
+			ids[name] = p.ID
+		}
+		closed, err := cartRepo.GetOrCreateCart("other-session", cart.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AddCartItem(closed.ID, "shoe", 1, 10))
+		require.NoError(t, cartRepo.AddCartItem(closed.ID, "watch", 1, 40))
+		require.NoError(t, cartRepo.CloseCart(closed.ID))
+		_, err = cartRepo.ComputeAssociations(context.Background(), time.Now())
+		require.NoError(t, err)
+		return ids["shoe"], ids["bag"]
+	}
+
+	t.Run("Shows Product", func(t *testing.T) {
+		shoeID, _ := setup(t)
+		w := ts.makeRequest(t, http.MethodGet, fmt.Sprintf("/products/%d", shoeID), nil, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `name="product" value="shoe"`)
+	})
+
+	t.Run("Product Shows Products Bought Together", func(t *testing.T) {
+		shoeID, bagID := setup(t)
+		w := ts.makeRequest(t, http.MethodGet, fmt.Sprintf("/products/%d", shoeID), nil, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		body := w.Body.String()
+		assert.Contains(t, body, "Frequently bought together")
+		assert.Contains(t, body, `name="product" value="watch"`)
+
+		w = ts.makeRequest(t, http.MethodGet, fmt.Sprintf("/products/%d", bagID), nil, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "Frequently bought together")
+	})
+
+	t.Run("Unknown Product", func(t *testing.T) {
+		setup(t)
+		w := ts.makeRequest(t, http.MethodGet, "/products/9999", nil, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), "Product not found")
+	})
+
+	t.Run("Cart Shows Recently Viewed Newest First", func(t *testing.T) {
+		shoeID, bagID := setup(t)
+		cookie := ts.createSession(t)
+		ts.makeRequest(t, http.MethodGet, fmt.Sprintf("/products/%d", shoeID), nil, cookie)
+		ts.makeRequest(t, http.MethodGet, fmt.Sprintf("/products/%d", bagID), nil, cookie)
+
+		w := ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		require.Equal(t, http.StatusOK, w.Code)
+		body := w.Body.String()
+		assert.Contains(t, body, "Recently viewed")
+		assert.Less(t, strings.Index(body, fmt.Sprintf(`href="/products/%d"`, bagID)), strings.Index(body, fmt.Sprintf(`href="/products/%d"`, shoeID)))
+	})
+
+	t.Run("Cart Shows Products Bought Together", func(t *testing.T) {
+		setup(t)
+		cookie := ts.createSession(t)
+		w := ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.NotContains(t, w.Body.String(), "Frequently bought together")
+
+		ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"shoe"}, "quantity": {"1"}}, cookie)
+		w = ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		require.Equal(t, http.StatusOK, w.Code)
+		body := w.Body.String()
+		assert.Contains(t, body, "Frequently bought together")
+		assert.Contains(t, body, `name="product" value="watch"`)
+		assert.NotContains(t, body, `name="product" value="bag"`)
+	})
+}
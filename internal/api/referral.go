@@ -0,0 +1,100 @@
+package api
+
+import (
+	"interview/internal/events"
+	"log"
+	"net/http"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+type (
+	// ReferralRewardView represents a gift card earned by referring a customer for the view layer.
+	ReferralRewardView struct {
+		Code    string
+		Balance string
+	}
+
+	// ApplyReferralCodeRequest is the JSON body accepted by POST /api/v1/cart/referral-code.
+	ApplyReferralCodeRequest struct {
+		Code string `json:"code"`
+	}
+)
+
+// addReferral shows logged-in users their referral code and the gift cards it earned them.
+func (h *CartHandler) addReferral(data *TemplateData, userID uint) {
+	ref, err := h.repo.GetReferral(userID)
+	if err != nil {
+		// Users without a code are offered to generate one
+		return
+	}
+	data.ReferralCode = ref.Code
+
+	rewards, err := h.repo.ListReferralRewards(userID)
+	if err != nil {
+		log.Printf("Failed to list referral rewards: %v", err)
+		return
+	}
+	for _, reward := range rewards {
+		data.ReferralRewards = append(data.ReferralRewards, ReferralRewardView{
+			Code:    reward.GiftCard.Code,
+			Balance: h.currencies.Format(reward.GiftCard.Balance, data.Currency),
+		})
+	}
+}
+
+// CreateReferralCode generates the referral code of the logged-in user.
+func (h *CartHandler) CreateReferralCode(c *gin.Context) {
+	session := sessions.Default(c)
+
+	userID, ok := session.Get("user_id").(uint)
+	if !ok {
+		h.redirectWithFlash(c, session, "Please log in to get a referral code")
+		return
+	}
+
+	if _, err := h.repoFor(c).CreateReferral(userID); err != nil {
+		h.redirectWithFlash(c, session, errorMessage(err, "Failed to create referral code"))
+		return
+	}
+	c.Redirect(http.StatusFound, "/")
+}
+
+// ApplyReferralCode records a referral code on the user's cart.
+func (h *CartHandler) ApplyReferralCode(c *gin.Context) {
+	session := sessions.Default(c)
+
+	sessionID := session.Get("session_id")
+	if sessionID == nil {
+		h.redirectWithFlash(c, session, "Invalid session")
+		return
+	}
+
+	cartName := currentCartName(session)
+	if err := h.carts.ApplyReferralCode(c.Request.Context(), sessionID.(string), cartName, c.PostForm("code")); err != nil {
+		h.redirectWithFlash(c, session, errorMessage(err, "Failed to apply referral code"))
+		return
+	}
+
+	h.publishCartEvent(c, events.TypeReferralApplied, sessionID.(string), cartName, "", 0)
+	c.Redirect(http.StatusFound, "/")
+}
+
+// APIApplyReferralCode records a referral code on the cart of the authenticated session.
+func (h *CartHandler) APIApplyReferralCode(c *gin.Context) {
+	var req ApplyReferralCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "referral code is required")
+		return
+	}
+
+	sessionID, cartName := c.GetString(apiSessionKey), apiCartName(c)
+	if err := h.carts.ApplyReferralCode(c.Request.Context(), sessionID, cartName, req.Code); err != nil {
+		respondWithError(c, err, "Failed to apply referral code")
+		return
+	}
+
+	h.publishCartEvent(c, events.TypeReferralApplied, sessionID, cartName, "", 0)
+	h.respondWithCart(c, sessionID, cartName, http.StatusOK)
+}
@@ -0,0 +1,220 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"interview/internal/order"
+	"interview/internal/payment"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type (
+	// RefundRequest is the JSON body accepted by POST /admin/orders/:id/refunds. Items are refunded at
+	// the price they were bought for unless Amount says how much to pay back; without items only Amount
+	// is paid back, and without either all that is left of the order is refunded.
+	RefundRequest struct {
+		Items  []RefundItemRequest `json:"items"`
+		Amount float64             `json:"amount"`
+		Note   string              `json:"note"`
+	}
+
+	// RefundItemRequest is an item of an order to refund. CartItemID is the ID of the item in the cart
+	// the order was checked out from.
+	RefundItemRequest struct {
+		CartItemID uint `json:"cart_item_id"`
+		Quantity   int  `json:"quantity"`
+	}
+
+	// RefundResponse is the JSON representation of a refund of an order.
+	RefundResponse struct {
+		ID        uint                 `json:"id"`
+		OrderID   uint                 `json:"order_id"`
+		Amount    float64              `json:"amount"`
+		Paid      float64              `json:"paid"`
+		Status    string               `json:"status"`
+		Actor     string               `json:"actor"`
+		Note      string               `json:"note,omitempty"`
+		Restocked bool                 `json:"restocked"`
+		Lines     []RefundLineResponse `json:"lines"`
+		CreatedAt time.Time            `json:"created_at"`
+	}
+
+	// RefundLineResponse is the JSON representation of an item refunded.
+	RefundLineResponse struct {
+		CartItemID uint    `json:"cart_item_id"`
+		Product    string  `json:"product"`
+		Quantity   int     `json:"quantity"`
+		Amount     float64 `json:"amount"`
+	}
+)
+
+// SetRefundRestock puts the items of refunds back into stock once they are paid back.
+func (h *AdminHandler) SetRefundRestock(restock bool) {
+	h.refundRestock = restock
+}
+
+// ListRefunds returns the refunds of an order, oldest first.
+func (h *AdminHandler) ListRefunds(c *gin.Context) {
+	id, ok := orderID(c)
+	if !ok {
+		return
+	}
+	refunds, err := h.repoFor(c).ListRefunds(id)
+	if err != nil {
+		log.Printf("Failed to list refunds of order %d: %v", id, err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to list refunds")
+		return
+	}
+	responses := make([]RefundResponse, len(refunds))
+	for i, refund := range refunds {
+		responses[i] = newRefundResponse(refund)
+	}
+	c.JSON(http.StatusOK, responses)
+}
+
+// CreateRefund refunds an order or some of its items, paying the refund back through the payment
+// provider. Refunding all that is left of the order refunds the order.
+func (h *AdminHandler) CreateRefund(c *gin.Context) {
+	id, ok := orderID(c)
+	if !ok {
+		return
+	}
+	var req RefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	items := make(map[uint]int, len(req.Items))
+	for _, item := range req.Items {
+		if _, ok := items[item.CartItemID]; ok {
+			respondWithProblem(c, http.StatusBadRequest, "items must be listed once")
+			return
+		}
+		items[item.CartItemID] = item.Quantity
+	}
+
+	refund, err := h.repoFor(c).StartRefund(id, items, req.Amount, staffActor(c), req.Note)
+	switch {
+	case errors.Is(err, order.ErrOrderNotFound):
+		respondWithProblem(c, http.StatusNotFound, "order not found")
+		return
+	case errors.Is(err, order.ErrNotRefundable):
+		respondWithProblem(c, http.StatusConflict, "only paid, fulfilled or shipped orders can be refunded")
+		return
+	case errors.Is(err, order.ErrRefundPending):
+		respondWithProblem(c, http.StatusConflict, "a refund of the order is still pending, retry it first")
+		return
+	case errors.Is(err, order.ErrInvalidRefund):
+		respondWithProblem(c, http.StatusBadRequest, "the order has no such items or amount left to refund")
+		return
+	case err != nil:
+		log.Printf("Failed to start refund of order %d: %v", id, err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to refund order")
+		return
+	}
+	if refund, ok = h.payRefund(c, refund); ok {
+		c.JSON(http.StatusCreated, newRefundResponse(*refund))
+	}
+}
+
+// RetryRefund pays back a refund whose payment failed before.
+func (h *AdminHandler) RetryRefund(c *gin.Context) {
+	id, ok := orderID(c)
+	if !ok {
+		return
+	}
+	refundID, err := strconv.ParseUint(c.Param("refund"), 10, 32)
+	if err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid refund ID")
+		return
+	}
+	refund, err := h.repoFor(c).GetRefund(uint(refundID))
+	if errors.Is(err, order.ErrRefundNotFound) || (err == nil && refund.OrderID != id) {
+		respondWithProblem(c, http.StatusNotFound, "refund not found")
+		return
+	} else if err != nil {
+		log.Printf("Failed to load refund %d: %v", refundID, err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to load refund")
+		return
+	}
+	if refund, ok = h.payRefund(c, refund); ok {
+		c.JSON(http.StatusOK, newRefundResponse(*refund))
+	}
+}
+
+// payRefund pays what is left of a pending refund back from the captured payments of its order and
+// finishes it. Each payment is sent the same key when the refund is retried, so refunds failing halfway
+// never pay anything back twice. When ok is false, the response was sent already.
+func (h *AdminHandler) payRefund(c *gin.Context, refund *order.Refund) (*order.Refund, bool) {
+	if refund.Status != order.RefundPending {
+		return refund, true
+	}
+	r := h.repoFor(c)
+	o, err := r.GetOrder(refund.OrderID)
+	if err != nil {
+		log.Printf("Failed to load order %d: %v", refund.OrderID, err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to refund order")
+		return nil, false
+	}
+	payments, err := r.ListRefundablePayments(o.CartID)
+	if err != nil {
+		log.Printf("Failed to list payments of order %d: %v", o.ID, err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to refund order")
+		return nil, false
+	}
+	left := roundCents(refund.Amount - refund.Paid)
+	for _, p := range payments {
+		part := roundCents(math.Min(left, p.Amount-p.Refunded))
+		if p.Status != payment.StatusCaptured || part <= 0 {
+			continue
+		}
+		if h.payments == nil {
+			respondWithProblem(c, http.StatusConflict, "payments can't be refunded without a payment provider")
+			return nil, false
+		}
+		key := fmt.Sprintf("refund-%d-%d", refund.ID, p.ID)
+		if err := h.payments.RefundPart(c.Request.Context(), p.ExternalID, key, part, p.Currency); err != nil {
+			log.Printf("Failed to refund %.2f of payment %d: %v", part, p.ID, err)
+			respondWithProblem(c, http.StatusBadGateway, "failed to refund payment")
+			return nil, false
+		}
+		if err := r.PayRefund(refund.ID, p.ID, part); err != nil {
+			log.Printf("Failed to record refund %d of payment %d: %v", refund.ID, p.ID, err)
+			respondWithProblem(c, http.StatusInternalServerError, "failed to refund order")
+			return nil, false
+		}
+		if left = roundCents(left - part); left <= 0 {
+			break
+		}
+	}
+
+	refund, err = r.FinishRefund(refund.ID, h.refundRestock, staffActor(c))
+	if err != nil {
+		log.Printf("Failed to finish refund of order %d: %v", o.ID, err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to refund order")
+		return nil, false
+	}
+	if refunded, err := r.GetOrder(o.ID); err == nil && refunded.Status != o.Status {
+		h.publishOrderStatus(c, refunded)
+	}
+	return refund, true
+}
+
+func newRefundResponse(refund order.Refund) RefundResponse {
+	lines := make([]RefundLineResponse, len(refund.Lines))
+	for i, line := range refund.Lines {
+		lines[i] = RefundLineResponse{
+			CartItemID: line.CartItemID, Product: line.ProductName, Quantity: line.Quantity, Amount: line.Amount,
+		}
+	}
+	return RefundResponse{
+		ID: refund.ID, OrderID: refund.OrderID, Amount: refund.Amount, Paid: refund.Paid, Status: refund.Status,
+		Actor: refund.Actor, Note: refund.Note, Restocked: refund.Restocked, Lines: lines, CreatedAt: refund.CreatedAt,
+	}
+}
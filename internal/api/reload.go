@@ -0,0 +1,145 @@
+package api
+
+import (
+	"context"
+	"interview/internal/config"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	gsessions "github.com/gorilla/sessions"
+)
+
+// configCheckInterval is how often the env file is checked for changes
+const configCheckInterval = 5 * time.Second
+
+type (
+	// LiveConfig holds the configuration the server runs with. Reloading replaces the settings that may
+	// change at runtime and calls the functions registered with OnReload to apply them.
+	LiveConfig struct {
+		current atomic.Pointer[config.Config]
+
+		mu       sync.Mutex
+		onReload []func()
+	}
+
+	// reloadableStore is a session store whose options can change while the server runs. Stores
+	// can't be reconfigured safely while they are in use, so a new store replaces the current one.
+	reloadableStore struct {
+		create  func(sessions.Options) sessions.Store
+		current atomic.Value
+
+		mu      sync.Mutex
+		options sessions.Options
+	}
+)
+
+// NewLiveConfig creates a LiveConfig starting with cfg.
+func NewLiveConfig(cfg config.Config) *LiveConfig {
+	l := &LiveConfig{}
+	l.current.Store(&cfg)
+	return l
+}
+
+// Current returns the active configuration.
+func (l *LiveConfig) Current() config.Config {
+	return *l.current.Load()
+}
+
+// OnReload registers apply to be called after every reload, which reads the new settings from Current.
+func (l *LiveConfig) OnReload(apply func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.onReload = append(l.onReload, apply)
+}
+
+// Reload takes the settings that may change at runtime from next and applies them.
+func (l *LiveConfig) Reload(next config.Config) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cfg := l.Current().Reloaded(next)
+	l.current.Store(&cfg)
+	for _, apply := range l.onReload {
+		apply()
+	}
+}
+
+// watchConfig reloads live from the environment and the env file on SIGHUP and when the file changes.
+// An invalid configuration is logged and the current one kept.
+func watchConfig(ctx context.Context, live *LiveConfig) {
+	config.Watch(ctx, config.EnvFile, configCheckInterval, func() {
+		next, err := config.Reload(config.EnvFile)
+		if err != nil {
+			log.Printf("Failed to reload configuration, keeping the current one: %v", err)
+			return
+		}
+		live.Reload(*next)
+		log.Printf("Configuration reloaded")
+	})
+}
+
+// requestLogger logs requests at the LOG_LEVEL of the active configuration: "info" logs every request,
+// "warn" those answered with an error and "error" those failing with a server error.
+func requestLogger(live *LiveConfig) gin.HandlerFunc {
+	return gin.LoggerWithConfig(gin.LoggerConfig{
+		Skip: func(c *gin.Context) bool {
+			switch live.Current().LogLevel {
+			case "warn":
+				return c.Writer.Status() < http.StatusBadRequest
+			case "error":
+				return c.Writer.Status() < http.StatusInternalServerError
+			default:
+				return false
+			}
+		},
+	})
+}
+
+// ShowConfig returns the active configuration with secrets redacted.
+func (h *AdminHandler) ShowConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, h.config.Current().Redacted())
+}
+
+// newReloadableStore creates a session store using initial until its options change, when create
+// makes the replacement.
+func newReloadableStore(initial sessions.Store, options sessions.Options, create func(sessions.Options) sessions.Store) *reloadableStore {
+	initial.Options(options)
+	s := &reloadableStore{create: create, options: options}
+	s.current.Store(initial)
+	return s
+}
+
+func (s *reloadableStore) store() sessions.Store {
+	return s.current.Load().(sessions.Store)
+}
+
+// Get returns the session of the request with the given name.
+func (s *reloadableStore) Get(r *http.Request, name string) (*gsessions.Session, error) {
+	return s.store().Get(r, name)
+}
+
+// New creates a session with the given name.
+func (s *reloadableStore) New(r *http.Request, name string) (*gsessions.Session, error) {
+	return s.store().New(r, name)
+}
+
+// Save writes the session to the response.
+func (s *reloadableStore) Save(r *http.Request, w http.ResponseWriter, session *gsessions.Session) error {
+	return s.store().Save(r, w, session)
+}
+
+// Options replaces the store with one using options, unless they didn't change.
+func (s *reloadableStore) Options(options sessions.Options) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if options == s.options {
+		return
+	}
+	s.options = options
+	s.current.Store(s.create(options))
+}
@@ -0,0 +1,57 @@
+package api_test
+
+import (
+	"encoding/json"
+	"interview/internal/api"
+	"interview/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLiveConfig(t *testing.T) {
+	live := api.NewLiveConfig(config.Config{
+		APIPort:       8088,
+		SessionSecret: "test_secret",
+		LogLevel:      "info",
+		LoginLinkTTL:  15 * time.Minute,
+	})
+
+	t.Run("Reloads Runtime Settings", func(t *testing.T) {
+		var applied []string
+		live.OnReload(func() {
+			applied = append(applied, live.Current().LogLevel)
+		})
+
+		live.Reload(config.Config{APIPort: 9000, LogLevel: "warn", EmailLimitPerIP: 20})
+		assert.Equal(t, []string{"warn"}, applied)
+		assert.Equal(t, 8088, live.Current().APIPort, "the port only changes on restart")
+		assert.Equal(t, 20, live.Current().EmailLimitPerIP)
+	})
+
+	t.Run("Shows Config Without Secrets", func(t *testing.T) {
+		ts := setupTest(t)
+		router := gin.New()
+		admin := api.NewAdminHandler(ts.db, nil, time.Hour)
+		admin.SetConfig(live)
+		admin.RegisterRoutes(router, gin.Accounts{"admin": "secret"})
+
+		req := httptest.NewRequest(http.MethodGet, "/admin/config", nil)
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var settings map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &settings))
+		assert.Equal(t, "REDACTED", settings["SessionSecret"])
+		assert.Equal(t, "warn", settings["LogLevel"])
+		assert.Equal(t, "15m0s", settings["LoginLinkTTL"])
+		assert.NotContains(t, w.Body.String(), "test_secret")
+	})
+}
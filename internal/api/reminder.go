@@ -0,0 +1,41 @@
+package api
+
+import (
+	"interview/internal/cart"
+	"interview/internal/reminder"
+	"log"
+	"net/http"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// SetCartLinks enables the endpoint opening carts from the signed links of reminder emails.
+func (h *CartHandler) SetCartLinks(links *reminder.CartLinks) {
+	h.cartLinks = links
+}
+
+// ResumeCart opens the cart of a signed reminder link in the current browser, so the user can
+// pick up where they left off on any device.
+func (h *CartHandler) ResumeCart(c *gin.Context) {
+	session := sessions.Default(c)
+
+	cartID, err := h.cartLinks.Verify(c.Request.URL.Query())
+	if err != nil {
+		h.redirectWithFlash(c, session, "This link is invalid or has expired")
+		return
+	}
+
+	userCart, err := h.repoFor(c).GetCart(cartID)
+	if err != nil || userCart.Status != cart.StatusOpen {
+		h.redirectWithFlash(c, session, "This cart is no longer available")
+		return
+	}
+
+	session.Set("session_id", userCart.SessionID)
+	session.Set("cart_name", userCart.Name)
+	if err := session.Save(); err != nil {
+		log.Printf("Failed to save session: %v", err)
+	}
+	c.Redirect(http.StatusFound, "/")
+}
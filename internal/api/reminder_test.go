@@ -0,0 +1,66 @@
+package api_test
+
+import (
+	"interview/internal/cart"
+	"interview/internal/reminder"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResumeCart(t *testing.T) {
+	ts := setupTest(t)
+	ts.clearDatabase(t)
+	links := reminder.NewCartLinks("http://localhost", []byte("test_secret"), time.Hour)
+	ts.handler.SetCartLinks(links)
+	ts.router.GET(reminder.ResumePath, ts.handler.ResumeCart)
+
+	// The cart is filled in one browser and opened from the reminder email in another
+	firstBrowser := ts.createSession(t)
+	w := ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"watch"}, "quantity": {"3"}}, firstBrowser)
+	require.Equal(t, http.StatusFound, w.Code)
+
+	var userCart cart.Cart
+	require.NoError(t, ts.db.First(&userCart).Error)
+
+	resumePath := func(link string) string {
+		u, err := url.Parse(link)
+		require.NoError(t, err)
+		return u.RequestURI()
+	}
+
+	t.Run("Valid Link Opens The Cart", func(t *testing.T) {
+		secondBrowser := ts.createSession(t)
+		w := ts.makeRequest(t, http.MethodGet, resumePath(links.URL(userCart.ID)), nil, secondBrowser)
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "/", w.Header().Get("Location"))
+
+		w = ts.makeRequest(t, http.MethodGet, "/", nil, secondBrowser)
+		assert.Contains(t, w.Body.String(), "Quantity: 3")
+	})
+
+	t.Run("Tampered Link Is Rejected", func(t *testing.T) {
+		browser := ts.createSession(t)
+		link := resumePath(links.URL(userCart.ID)) + "0"
+		w := ts.makeRequest(t, http.MethodGet, link, nil, browser)
+		assert.Equal(t, http.StatusFound, w.Code)
+
+		w = ts.makeRequest(t, http.MethodGet, "/", nil, browser)
+		assert.Contains(t, w.Body.String(), "This link is invalid or has expired")
+		assert.NotContains(t, w.Body.String(), "Quantity: 3")
+	})
+
+	t.Run("Closed Cart Is Not Reopened", func(t *testing.T) {
+		require.NoError(t, ts.handler.GetRepo().CloseCart(userCart.ID))
+		browser := ts.createSession(t)
+		w := ts.makeRequest(t, http.MethodGet, resumePath(links.URL(userCart.ID)), nil, browser)
+		assert.Equal(t, http.StatusFound, w.Code)
+
+		w = ts.makeRequest(t, http.MethodGet, "/", nil, browser)
+		assert.Contains(t, w.Body.String(), "This cart is no longer available")
+	})
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"interview/internal/repo"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// primaryCookie marks clients that changed something recently, whose reads skip the read replicas
+const primaryCookie = "read_primary"
+
+// ReadYourWrites returns a middleware keeping the reads of a client on the primary for pin after it
+// sent a request that may change something, e.g. the cart page shown after adding an item, so clients
+// see their own changes before replication catches up. The client is marked with a cookie, so this
+// works whichever instance serves the next request.
+func ReadYourWrites(pin time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			if _, err := c.Request.Cookie(primaryCookie); err == nil {
+				c.Request = c.Request.WithContext(repo.ReadPrimary(c.Request.Context()))
+			}
+		default:
+			http.SetCookie(c.Writer, &http.Cookie{
+				Name:     primaryCookie,
+				Value:    "1",
+				Path:     "/",
+				MaxAge:   max(int(pin.Seconds()), 1),
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+		c.Next()
+	}
+}
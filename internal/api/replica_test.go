@@ -0,0 +1,68 @@
+package api_test
+
+import (
+	"interview/internal/api"
+	"interview/internal/cart"
+	"interview/internal/repo"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestReadYourWrites(t *testing.T) {
+	open := func() *gorm.DB {
+		db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+		require.NoError(t, err)
+		require.NoError(t, repo.Migrate(db))
+		return db
+	}
+	primary, replica := open(), open()
+	carts := repo.NewRepository(primary)
+	carts.SetReplicas(repo.NewReplicaSet(primary, replica))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(api.ReadYourWrites(5 * time.Second))
+	router.POST("/carts", func(c *gin.Context) {
+		userCart := cart.Cart{SessionID: "pinned", Status: cart.StatusOpen}
+		require.NoError(t, primary.Create(&userCart).Error)
+		c.Status(http.StatusCreated)
+	})
+	router.GET("/carts", func(c *gin.Context) {
+		list, err := carts.WithContext(c.Request.Context()).GetAllCarts()
+		require.NoError(t, err)
+		c.String(http.StatusOK, strconv.Itoa(len(list)))
+	})
+
+	request := func(method string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, "/carts", nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request(http.MethodPost)
+	require.Equal(t, http.StatusCreated, w.Code)
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, 5, cookies[0].MaxAge)
+
+	t.Run("Reads After Writes Use The Primary", func(t *testing.T) {
+		assert.Equal(t, "1", request(http.MethodGet, cookies...).Body.String())
+	})
+
+	t.Run("Other Reads Use The Replicas", func(t *testing.T) {
+		assert.Equal(t, "0", request(http.MethodGet).Body.String())
+	})
+}
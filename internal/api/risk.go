@@ -0,0 +1,194 @@
+package api
+
+import (
+	"errors"
+	"interview/internal/cart"
+	"interview/internal/checkout"
+	"interview/internal/events"
+	"interview/internal/payment"
+	"interview/internal/risk"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// HeldPaymentResponse is the JSON representation of a payment held for review.
+type HeldPaymentResponse struct {
+	ID        uint      `json:"id"`
+	CartID    uint      `json:"cart_id"`
+	Amount    float64   `json:"amount"`
+	Currency  string    `json:"currency"`
+	Status    string    `json:"status"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SetRiskChecker screens checkouts with the checker before their payment is captured. countryHeader is
+// the request header the proxy in front of the shop reports the customer's country in, e.g.
+// "CF-IPCountry", empty when it reports none.
+func (h *CartHandler) SetRiskChecker(checker risk.Checker, countryHeader string) {
+	h.risk = checker
+	h.riskCountryHeader = countryHeader
+}
+
+// screenCheckout runs the risk checks on the checkout before its payment is captured. Held payments
+// wait for staff to review them and blocked payments go back to the payment step; ok is false for
+// both, as the response was sent already. Checks that fail hold the payment rather than capturing it
+// unchecked.
+func (h *CartHandler) screenCheckout(c *gin.Context, session sessions.Session, userCart *cart.Cart, s *checkout.Session) bool {
+	p, err := h.repoFor(c).GetPaymentByID(*s.PaymentID)
+	if err != nil || p.Status != payment.StatusPending {
+		// Capturing reports missing payments and shows the order of captured ones
+		return true
+	}
+
+	screened := risk.Checkout{
+		CartID:    userCart.ID,
+		SessionID: s.SessionID,
+		UserID:    userCart.UserID,
+		IP:        c.ClientIP(),
+		Amount:    p.Amount,
+		Currency:  p.Currency,
+	}
+	if h.riskCountryHeader != "" {
+		screened.IPCountry = c.GetHeader(h.riskCountryHeader)
+	}
+	if s.AddressID != nil {
+		if a, err := h.repoFor(c).GetAddress(h.addressBookUser(c, session), s.SessionID, *s.AddressID); err == nil {
+			screened.ShippingCountry = a.Country
+		}
+	}
+	decision, err := h.risk.Check(c.Request.Context(), screened)
+	if err != nil {
+		log.Printf("Failed to check risk of cart %d: %v", userCart.ID, err)
+		decision = risk.Decision{Action: risk.ActionReview, Reason: "risk check failed"}
+	}
+
+	switch decision.Action {
+	case risk.ActionReview:
+		if err := h.repoFor(c).HoldPayment(p.ID, decision.Reason); err != nil {
+			log.Printf("Failed to hold payment: %v", err)
+			h.checkoutFlash(c, session, "Failed to complete the payment")
+			return false
+		}
+		redirectWithNotice(c, session, "Thank you! Your order is being reviewed")
+		return false
+	case risk.ActionBlock:
+		if err := h.repoFor(c).BlockPayment(p.ID, decision.Reason); err != nil {
+			log.Printf("Failed to block payment: %v", err)
+			h.checkoutFlash(c, session, "Failed to complete the payment")
+			return false
+		}
+		h.checkoutFlash(c, session, "We can't accept this payment, please contact us")
+		return false
+	}
+	return true
+}
+
+// SetPayments lets staff review the payments held by the risk checks, capturing them through the
+// provider once approved, and refund orders through the provider.
+func (h *AdminHandler) SetPayments(provider payment.Provider) {
+	h.payments = provider
+}
+
+// ListHeldPayments returns the payments held for review, oldest first.
+func (h *AdminHandler) ListHeldPayments(c *gin.Context) {
+	payments, err := h.repoFor(c).ListHeldPayments()
+	if err != nil {
+		log.Printf("Failed to list held payments: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to list held payments")
+		return
+	}
+	responses := make([]HeldPaymentResponse, len(payments))
+	for i, p := range payments {
+		responses[i] = newHeldPaymentResponse(p)
+	}
+	c.JSON(http.StatusOK, responses)
+}
+
+// ApprovePayment captures a held payment and checks out its cart.
+func (h *AdminHandler) ApprovePayment(c *gin.Context) {
+	p, ok := h.heldPayment(c)
+	if !ok {
+		return
+	}
+	if err := h.payments.Capture(c.Request.Context(), p.ExternalID); errors.Is(err, payment.ErrNotApproved) {
+		respondWithProblem(c, http.StatusConflict, "the payment is no longer approved, reject it instead")
+		return
+	} else if err != nil {
+		log.Printf("Failed to capture payment %d: %v", p.ID, err)
+		respondWithProblem(c, http.StatusBadGateway, "failed to capture payment")
+		return
+	}
+
+	captured, checkedOut, err := h.repoFor(c).CompletePayment(p.Provider, p.ExternalID)
+	if err != nil {
+		log.Printf("Failed to complete payment %d: %v", p.ID, err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to complete payment")
+		return
+	}
+	if checkedOut && h.events != nil {
+		if closed, err := h.repoFor(c).GetCart(captured.CartID); err == nil {
+			h.events.Publish(events.Event{Type: events.TypeCartClosed, CartID: closed.ID, Total: closed.Total})
+		}
+		if o, err := h.repoFor(c).GetOrderByCart(captured.CartID); err == nil {
+			h.publishOrderStatus(c, o)
+		}
+	}
+	c.JSON(http.StatusOK, newHeldPaymentResponse(*captured))
+}
+
+// RejectPayment declines a held payment, which is never captured, and cancels its checkout.
+func (h *AdminHandler) RejectPayment(c *gin.Context) {
+	p, ok := h.heldPayment(c)
+	if !ok {
+		return
+	}
+	if err := h.repoFor(c).BlockPayment(p.ID, p.RiskReason); err != nil {
+		log.Printf("Failed to reject payment %d: %v", p.ID, err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to reject payment")
+		return
+	}
+	p.Status = payment.StatusBlocked
+	c.JSON(http.StatusOK, newHeldPaymentResponse(*p))
+}
+
+// heldPayment loads the payment of the "id" parameter, which must be held for review. When ok is false,
+// the response was sent already.
+func (h *AdminHandler) heldPayment(c *gin.Context) (*payment.Payment, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid payment ID")
+		return nil, false
+	}
+	p, err := h.repoFor(c).GetPaymentByID(uint(id))
+	if errors.Is(err, payment.ErrPaymentNotFound) {
+		respondWithProblem(c, http.StatusNotFound, "payment not found")
+		return nil, false
+	} else if err != nil {
+		log.Printf("Failed to load payment: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to load payment")
+		return nil, false
+	}
+	if p.Status != payment.StatusHeld {
+		respondWithProblem(c, http.StatusConflict, "payment is not held for review")
+		return nil, false
+	}
+	return p, true
+}
+
+func newHeldPaymentResponse(p payment.Payment) HeldPaymentResponse {
+	return HeldPaymentResponse{
+		ID:        p.ID,
+		CartID:    p.CartID,
+		Amount:    p.Amount,
+		Currency:  p.Currency,
+		Status:    p.Status,
+		Reason:    p.RiskReason,
+		CreatedAt: p.CreatedAt,
+	}
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"interview/internal/repo"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultReportPeriod is the period covered by the sales report when no from time is given
+	defaultReportPeriod = 30 * 24 * time.Hour
+	// defaultTopProducts and maxTopProducts bound the top parameter of the sales report
+	defaultTopProducts = 10
+	maxTopProducts     = 100
+	// abandonedAfter is how long an open cart has to be idle to count as abandoned
+	abandonedAfter = 24 * time.Hour
+)
+
+type (
+	// SalesReportResponse sums up the carts checked out in the period of the report. The abandonment
+	// rate is the share of carts created in the period that were abandoned rather than checked out.
+	SalesReportResponse struct {
+		From             time.Time              `json:"from"`
+		To               *time.Time             `json:"to,omitempty"`
+		Revenue          float64                `json:"revenue"`
+		Checkouts        int64                  `json:"checkouts"`
+		AverageCartValue float64                `json:"average_cart_value"`
+		AbandonedCarts   int64                  `json:"abandoned_carts"`
+		AbandonmentRate  float64                `json:"abandonment_rate"`
+		Daily            []DailyRevenueResponse `json:"daily"`
+		TopProducts      []ProductSalesResponse `json:"top_products"`
+	}
+
+	// DailyRevenueResponse is the revenue of a day of the sales report.
+	DailyRevenueResponse struct {
+		Day     string  `json:"day"`
+		Carts   int64   `json:"carts"`
+		Revenue float64 `json:"revenue"`
+	}
+
+	// ProductSalesResponse is a product of the top products of the sales report.
+	ProductSalesResponse struct {
+		Product  string  `json:"product"`
+		Quantity int64   `json:"quantity"`
+		Revenue  float64 `json:"revenue"`
+	}
+)
+
+// SalesReport returns the revenue by day, top products by quantity, average cart value and
+// abandonment rate of the carts checked out between the from and to query parameters (RFC 3339
+// times or dates, the last 30 days by default). top sets how many products are listed.
+func (h *AdminHandler) SalesReport(c *gin.Context) {
+	var period repo.ReportPeriod
+	var err error
+	if period.From, err = parseExportTime(c.Query("from")); err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid from time")
+		return
+	}
+	if period.To, err = parseExportTime(c.Query("to")); err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid to time")
+		return
+	}
+	if period.From.IsZero() {
+		period.From = time.Now().Add(-defaultReportPeriod).Truncate(24 * time.Hour)
+	}
+	top := defaultTopProducts
+	if raw := c.Query("top"); raw != "" {
+		if top, err = strconv.Atoi(raw); err != nil || top < 1 || top > maxTopProducts {
+			respondWithInvalidField(c, "top", "must be between 1 and "+strconv.Itoa(maxTopProducts))
+			return
+		}
+	}
+
+	days, err := h.repoFor(c).ListDailyRevenue(period)
+	if err != nil {
+		h.reportFailed(c, err)
+		return
+	}
+	products, err := h.repoFor(c).ListTopProducts(period, top)
+	if err != nil {
+		h.reportFailed(c, err)
+		return
+	}
+	stats, err := h.repoFor(c).GetCheckoutStats(period, time.Now().Add(-abandonedAfter))
+	if err != nil {
+		h.reportFailed(c, err)
+		return
+	}
+
+	report := SalesReportResponse{
+		From:             period.From,
+		Checkouts:        stats.CheckedOut,
+		AverageCartValue: roundCents(stats.AverageValue),
+		AbandonedCarts:   stats.Abandoned,
+		Daily:            make([]DailyRevenueResponse, len(days)),
+		TopProducts:      make([]ProductSalesResponse, len(products)),
+	}
+	if !period.To.IsZero() {
+		report.To = &period.To
+	}
+	if ended := stats.Converted + stats.Abandoned; ended > 0 {
+		report.AbandonmentRate = float64(stats.Abandoned) / float64(ended)
+	}
+	for i, d := range days {
+		report.Daily[i] = DailyRevenueResponse{Day: d.Day, Carts: d.Carts, Revenue: roundCents(d.Revenue)}
+		report.Revenue += d.Revenue
+	}
+	report.Revenue = roundCents(report.Revenue)
+	for i, p := range products {
+		report.TopProducts[i] = ProductSalesResponse{Product: p.ProductName, Quantity: p.Quantity, Revenue: roundCents(p.Revenue)}
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+func (h *AdminHandler) reportFailed(c *gin.Context, err error) {
+	log.Printf("Failed to build sales report: %v", err)
+	respondWithProblem(c, http.StatusInternalServerError, "failed to build sales report")
+}
+
+// roundCents rounds sums of float prices to cents.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
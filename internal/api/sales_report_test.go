@@ -0,0 +1,85 @@
+package api_test
+
+import (
+	"encoding/json"
+	"interview/internal/api"
+	"interview/internal/cart"
+	"interview/internal/repo"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSalesReport(t *testing.T) {
+	ts := setupTest(t)
+	ts.clearDatabase(t)
+
+	cartRepo := repo.NewRepository(ts.db)
+	addCart := func(sessionID string, items map[string]int) *cart.Cart {
+		c, err := cartRepo.GetOrCreateCart(sessionID, cart.DefaultName)
+		require.NoError(t, err)
+		for product, quantity := range items {
+			require.NoError(t, cartRepo.AddCartItem(c.ID, product, quantity, 10.0))
+		}
+		return c
+	}
+	checkedOut := addCart("checked-out", map[string]int{"shoe": 3, "bag": 1})
+	require.NoError(t, cartRepo.CloseCart(checkedOut.ID))
+	other := addCart("other", map[string]int{"bag": 1})
+	require.NoError(t, cartRepo.CloseCart(other.ID))
+	abandoned := addCart("abandoned", map[string]int{"shoe": 1})
+	require.NoError(t, ts.db.Exec("UPDATE carts SET updated_at = ? WHERE id = ?", time.Now().Add(-48*time.Hour), abandoned.ID).Error)
+	addCart("active", map[string]int{"shoe": 1})
+	addCart("empty", nil)
+
+	router := gin.New()
+	api.NewAdminHandler(ts.db, nil, time.Hour).RegisterRoutes(router, gin.Accounts{"admin": "secret"})
+	report := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/admin/reports/sales?"+query, nil)
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Report", func(t *testing.T) {
+		w := report("top=1")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp api.SalesReportResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, 50.0, resp.Revenue)
+		assert.Equal(t, int64(2), resp.Checkouts)
+		assert.Equal(t, 25.0, resp.AverageCartValue)
+		assert.Equal(t, int64(1), resp.AbandonedCarts)
+		assert.InDelta(t, 1.0/3, resp.AbandonmentRate, 0.001)
+		require.Len(t, resp.Daily, 1)
+		assert.Equal(t, time.Now().UTC().Format(time.DateOnly), resp.Daily[0].Day)
+		assert.Equal(t, int64(2), resp.Daily[0].Carts)
+		require.Len(t, resp.TopProducts, 1)
+		assert.Equal(t, api.ProductSalesResponse{Product: "shoe", Quantity: 3, Revenue: 30}, resp.TopProducts[0])
+	})
+
+	t.Run("Empty Period", func(t *testing.T) {
+		w := report("from=2020-01-01&to=2020-02-01")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp api.SalesReportResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Zero(t, resp.Checkouts)
+		assert.Zero(t, resp.AbandonmentRate)
+		assert.Empty(t, resp.Daily)
+		assert.Empty(t, resp.TopProducts)
+	})
+
+	t.Run("Invalid Parameters", func(t *testing.T) {
+		for _, query := range []string{"from=yesterday", "to=2024-13-01", "top=0", "top=many"} {
+			assert.Equal(t, http.StatusBadRequest, report(query).Code, query)
+		}
+	})
+}
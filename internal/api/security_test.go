@@ -0,0 +1,49 @@
+package api_test
+
+import (
+	"interview/internal/api"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	serve := func(opts api.SecurityHeadersOptions) http.Header {
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.Use(api.SecurityHeaders(opts))
+		router.GET("/", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		return w.Header()
+	}
+
+	t.Run("Enforced Policy", func(t *testing.T) {
+		h := serve(api.SecurityHeadersOptions{ContentSecurityPolicy: api.DefaultContentSecurityPolicy})
+
+		assert.Equal(t, api.DefaultContentSecurityPolicy, h.Get("Content-Security-Policy"))
+		assert.Empty(t, h.Get("Content-Security-Policy-Report-Only"))
+		assert.Empty(t, h.Get("Strict-Transport-Security"))
+		assert.Equal(t, "DENY", h.Get("X-Frame-Options"))
+		assert.Equal(t, "nosniff", h.Get("X-Content-Type-Options"))
+		assert.Equal(t, "strict-origin-when-cross-origin", h.Get("Referrer-Policy"))
+	})
+
+	t.Run("Report Only Policy", func(t *testing.T) {
+		h := serve(api.SecurityHeadersOptions{ContentSecurityPolicy: "default-src 'self'", CSPReportOnly: true})
+
+		assert.Empty(t, h.Get("Content-Security-Policy"))
+		assert.Equal(t, "default-src 'self'", h.Get("Content-Security-Policy-Report-Only"))
+	})
+
+	t.Run("HSTS Enabled", func(t *testing.T) {
+		h := serve(api.SecurityHeadersOptions{HSTSMaxAge: 24 * time.Hour})
+
+		assert.Equal(t, "max-age=86400; includeSubDomains", h.Get("Strict-Transport-Security"))
+	})
+}
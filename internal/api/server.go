@@ -0,0 +1,60 @@
+package api
+
+import (
+	"crypto/tls"
+	"fmt"
+	"interview/internal/config"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// tlsEnabled reports whether the server terminates TLS itself.
+func tlsEnabled(config config.Config) bool {
+	return config.TLSCertFile != "" || config.AutoTLSDomain != ""
+}
+
+// serve runs the HTTP server on ln. It serves HTTPS when a certificate is configured or obtains
+// certificates from Let's Encrypt when AUTO_TLS_DOMAIN is set, and plain HTTP otherwise.
+func serve(config config.Config, ln net.Listener, handler http.Handler) error {
+	if ln.Addr().Network() == "unix" {
+		handler = unixPeer(handler)
+	}
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	switch {
+	case config.AutoTLSDomain != "":
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(splitList(config.AutoTLSDomain)...),
+			Cache:      autocert.DirCache(config.AutoTLSCacheDir),
+		}
+		server.TLSConfig = manager.TLSConfig()
+
+		// Answer ACME HTTP-01 challenges and redirect everything else to HTTPS
+		go func() {
+			challengeServer := &http.Server{
+				Addr:              fmt.Sprintf(":%d", config.AutoTLSHTTPPort),
+				Handler:           manager.HTTPHandler(nil),
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+			if err := challengeServer.ListenAndServe(); err != nil {
+				log.Printf("ACME challenge server stopped: %v", err)
+			}
+		}()
+		return server.ServeTLS(ln, "", "")
+
+	case config.TLSCertFile != "":
+		server.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		return server.ServeTLS(ln, config.TLSCertFile, config.TLSKeyFile)
+
+	default:
+		return server.Serve(ln)
+	}
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"errors"
+	"interview/internal/warehouse"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type (
+	// ProductStockRequest is the JSON body accepted by POST /admin/products/:id/stock. A null stock stops
+	// tracking the stock of the product.
+	ProductStockRequest struct {
+		Stock *int `json:"stock"`
+	}
+
+	// LowStockRequest is the JSON body accepted by POST /admin/products/:id/low-stock. A null threshold
+	// stops the alerts about the product.
+	LowStockRequest struct {
+		Threshold *int `json:"threshold"`
+	}
+
+	// SnoozeLowStockRequest is the JSON body accepted by POST /admin/products/:id/low-stock/snooze, with a
+	// duration such as "48h".
+	SnoozeLowStockRequest struct {
+		Duration string `json:"duration"`
+	}
+)
+
+// SetStockNotifications offers to subscribe to an email on the pages of out-of-stock products.
+func (h *CartHandler) SetStockNotifications(enabled bool) {
+	h.stockNotifications = enabled
+}
+
+// SubscribeToStock subscribes the email address of the form to the out-of-stock product of the path,
+// to be notified once it is back in stock.
+func (h *CartHandler) SubscribeToStock(c *gin.Context) {
+	session := sessions.Default(c)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		h.redirectWithFlash(c, session, "Product not found")
+		return
+	}
+	p, err := h.repoFor(c).GetProduct(uint(id))
+	if err != nil {
+		h.redirectWithFlash(c, session, "Product not found")
+		return
+	}
+	if !p.OutOfStock() {
+		c.Redirect(http.StatusFound, "/products/"+c.Param("id"))
+		return
+	}
+	email := strings.TrimSpace(c.PostForm("email"))
+	if !validEmail(email) {
+		h.redirectWithFlash(c, session, "Please enter a valid email address")
+		return
+	}
+
+	if err := h.repoFor(c).SubscribeToStock(p.ID, email); err != nil {
+		log.Printf("Failed to subscribe to stock: %v", err)
+		h.redirectWithFlash(c, session, "Failed to subscribe")
+		return
+	}
+	redirectWithNotice(c, session, "We will email you when the product is back in stock")
+}
+
+// UpdateProductStock sets the units left of a product not kept in warehouses. Subscribers are notified
+// by the restock job once a product is back in stock.
+func (h *AdminHandler) UpdateProductStock(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid product ID")
+		return
+	}
+	var req ProductStockRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Stock != nil && *req.Stock < 0) {
+		respondWithInvalidField(c, "stock", "must be a number of at least 0 or null")
+		return
+	}
+
+	if err := h.repoFor(c).SetProductStock(uint(id), req.Stock); errors.Is(err, gorm.ErrRecordNotFound) {
+		respondWithProblem(c, http.StatusNotFound, "product not found")
+		return
+	} else if errors.Is(err, warehouse.ErrStockInWarehouses) {
+		respondWithProblem(c, http.StatusConflict, "the stock of the product is set per warehouse")
+		return
+	} else if err != nil {
+		log.Printf("Failed to update stock: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to update stock")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id, "stock": req.Stock})
+}
+
+// UpdateLowStockThreshold sets the stock below which staff are alerted about a product.
+func (h *AdminHandler) UpdateLowStockThreshold(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid product ID")
+		return
+	}
+	var req LowStockRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Threshold != nil && *req.Threshold < 1) {
+		respondWithInvalidField(c, "threshold", "must be a number of at least 1 or null")
+		return
+	}
+
+	if err := h.repoFor(c).SetLowStockThreshold(uint(id), req.Threshold); errors.Is(err, gorm.ErrRecordNotFound) {
+		respondWithProblem(c, http.StatusNotFound, "product not found")
+		return
+	} else if err != nil {
+		log.Printf("Failed to update low-stock threshold: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to update threshold")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id, "threshold": req.Threshold})
+}
+
+// SnoozeLowStock silences the low-stock alerts of a product for a while, or until it is restocked.
+func (h *AdminHandler) SnoozeLowStock(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid product ID")
+		return
+	}
+	var req SnoozeLowStockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	duration, err := time.ParseDuration(req.Duration)
+	if err != nil || duration <= 0 {
+		respondWithInvalidField(c, "duration", "must be positive, e.g. 48h")
+		return
+	}
+
+	until := time.Now().Add(duration)
+	if err := h.repoFor(c).SnoozeLowStock(uint(id), until); errors.Is(err, gorm.ErrRecordNotFound) {
+		respondWithProblem(c, http.StatusNotFound, "product not found")
+		return
+	} else if err != nil {
+		log.Printf("Failed to snooze low-stock alerts: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to snooze alerts")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id, "snoozed_until": until})
+}
@@ -0,0 +1,121 @@
+package api_test
+
+import (
+	"fmt"
+	"interview/internal/api"
+	"interview/internal/repo"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductStock(t *testing.T) {
+	ts := setupTest(t)
+	cartRepo := repo.NewRepository(ts.db)
+	admin := api.NewAdminHandler(ts.db, nil, time.Hour)
+	admin.RegisterRoutes(ts.router, gin.Accounts{"admin": "secret"})
+	ts.handler.SetStockNotifications(true)
+	t.Cleanup(func() { ts.handler.SetStockNotifications(false) })
+
+	// adminPost posts the JSON body to the admin endpoint
+	adminPost := func(t *testing.T, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		ts.router.ServeHTTP(w, req)
+		return w
+	}
+	// setStock sets the stock of the product through the admin endpoint
+	setStock := func(t *testing.T, id uint, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		return adminPost(t, fmt.Sprintf("/admin/products/%d/stock", id), body)
+	}
+
+	// setup adds the shoe to the catalog without stock left
+	setup := func(t *testing.T) uint {
+		t.Helper()
+		ts.clearDatabase(t)
+		shoe, err := cartRepo.UpsertProduct("shoe", 10)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, setStock(t, shoe.ID, `{"stock": 0}`).Code)
+		return shoe.ID
+	}
+
+	t.Run("Rejects Invalid Stock", func(t *testing.T) {
+		id := setup(t)
+		assert.Equal(t, http.StatusBadRequest, setStock(t, id, `{"stock": -1}`).Code)
+		assert.Equal(t, http.StatusNotFound, setStock(t, 9999, `{"stock": 1}`).Code)
+	})
+
+	t.Run("Out Of Stock Product Can't Be Added", func(t *testing.T) {
+		setup(t)
+		cookie := ts.createSession(t)
+		w := ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"shoe"}, "quantity": {"1"}}, cookie)
+		require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Contains(t, w.Body.String(), `<p id="product-error" class="field-error">This product is out of stock</p>`)
+		var items int64
+		require.NoError(t, ts.db.Table("cart_items").Count(&items).Error)
+		assert.Zero(t, items)
+	})
+
+	t.Run("Product Page Offers Notification", func(t *testing.T) {
+		id := setup(t)
+		w := ts.makeRequest(t, http.MethodGet, fmt.Sprintf("/products/%d", id), nil, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		body := w.Body.String()
+		assert.Contains(t, body, "Out of stock")
+		assert.Contains(t, body, fmt.Sprintf(`action="/products/%d/notify"`, id))
+		assert.NotContains(t, body, `action="/add-item"`)
+	})
+
+	t.Run("Subscribes To Stock", func(t *testing.T) {
+		id := setup(t)
+		cookie := ts.createSession(t)
+		w := ts.makeRequest(t, http.MethodPost, fmt.Sprintf("/products/%d/notify", id), url.Values{"email": {"jane@example.com"}}, cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+		w = ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.Contains(t, w.Body.String(), "We will email you when the product is back in stock")
+
+		require.Equal(t, http.StatusOK, setStock(t, id, `{"stock": 3}`).Code)
+		restocked, err := cartRepo.ListRestockedSubscriptions(10)
+		require.NoError(t, err)
+		require.Len(t, restocked, 1)
+		assert.Equal(t, "jane@example.com", restocked[0].Subscription.Email)
+	})
+
+	t.Run("Rejects Invalid Email", func(t *testing.T) {
+		id := setup(t)
+		cookie := ts.createSession(t)
+		ts.makeRequest(t, http.MethodPost, fmt.Sprintf("/products/%d/notify", id), url.Values{"email": {"jane"}}, cookie)
+		w := ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.Contains(t, w.Body.String(), "Please enter a valid email address")
+	})
+
+	t.Run("Low Stock Alerts Are Configured", func(t *testing.T) {
+		id := setup(t)
+		path := fmt.Sprintf("/admin/products/%d/low-stock", id)
+		assert.Equal(t, http.StatusBadRequest, adminPost(t, path, `{"threshold": 0}`).Code)
+		assert.Equal(t, http.StatusNotFound, adminPost(t, "/admin/products/9999/low-stock", `{"threshold": 2}`).Code)
+		require.Equal(t, http.StatusOK, adminPost(t, path, `{"threshold": 2}`).Code)
+
+		low, err := cartRepo.ListLowStockProducts()
+		require.NoError(t, err)
+		require.Len(t, low, 1)
+		assert.Equal(t, id, low[0].ID)
+
+		assert.Equal(t, http.StatusBadRequest, adminPost(t, path+"/snooze", `{"duration": "soon"}`).Code)
+		require.Equal(t, http.StatusOK, adminPost(t, path+"/snooze", `{"duration": "48h"}`).Code)
+		claimed, err := cartRepo.ClaimLowStockAlert(id, time.Now().Add(47*time.Hour), time.Now().Add(72*time.Hour))
+		require.NoError(t, err)
+		assert.False(t, claimed)
+	})
+}
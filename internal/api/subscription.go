@@ -0,0 +1,166 @@
+package api
+
+import (
+	"html/template"
+	"interview/internal/cart"
+	"interview/internal/i18n"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/csrf"
+)
+
+type (
+	// SubscriptionsData contains data to be rendered in the subscriptions template.
+	SubscriptionsData struct {
+		Error         string
+		Notice        string
+		Locale        string
+		CSRFFieldName template.HTML
+		Subscriptions []SubscriptionView
+	}
+
+	// SubscriptionView represents a subscription for the view layer.
+	SubscriptionView struct {
+		ID           uint
+		Product      string
+		Quantity     int
+		IntervalDays int
+		Paused       bool
+		// NextOrder is when the next order is placed while the subscription is active
+		NextOrder string
+	}
+)
+
+// subscriptionActions maps the actions of POST /subscriptions/:id/:action to the status they set and
+// the notice confirming it
+var subscriptionActions = map[string]struct{ status, notice string }{
+	"pause":  {cart.SubscriptionPaused, "Subscription paused"},
+	"resume": {cart.SubscriptionActive, "Subscription resumed"},
+	"cancel": {cart.SubscriptionCancelled, "Subscription cancelled"},
+}
+
+// SubscribeItem reorders the "cart_item_id" of the user's cart every "interval" days once the cart is
+// checked out, or makes it a one-time purchase again for an interval of 0.
+func (h *CartHandler) SubscribeItem(c *gin.Context) {
+	session := sessions.Default(c)
+
+	itemID, err := strconv.ParseUint(c.PostForm("cart_item_id"), 10, 32)
+	if err != nil {
+		h.redirectWithFlash(c, session, "Invalid item ID")
+		return
+	}
+	days, err := strconv.Atoi(c.PostForm("interval"))
+	if err != nil {
+		h.redirectWithFlash(c, session, errorMessage(cart.ErrInvalidInterval, ""))
+		return
+	}
+
+	sessionID := session.Get("session_id")
+	if sessionID == nil {
+		h.redirectWithFlash(c, session, "Invalid session")
+		return
+	}
+
+	err = h.carts.SetItemSubscription(c.Request.Context(), sessionID.(string), currentCartName(session), uint(itemID), days)
+	if err != nil {
+		h.redirectWithFlash(c, session, errorMessage(err, "Failed to update item"))
+		return
+	}
+	c.Redirect(http.StatusFound, "/")
+}
+
+// ShowSubscriptions lists the subscriptions of the session and of the logged-in user, which can be
+// paused, resumed and cancelled there.
+func (h *CartHandler) ShowSubscriptions(c *gin.Context) {
+	session := sessions.Default(c)
+	data := SubscriptionsData{Locale: detectLocale(c, session).String()}
+	data.CSRFFieldName = csrf.TemplateField(c.Request)
+
+	flashes := session.Flashes()
+	notices := session.Flashes(noticeFlash)
+	if len(flashes) > 0 {
+		data.Error = flashes[0].(string)
+	}
+	if len(notices) > 0 {
+		data.Notice = notices[0].(string)
+	}
+	if len(flashes) > 0 || len(notices) > 0 {
+		if err := session.Save(); err != nil {
+			log.Printf("Failed to save session: %v", err)
+		}
+	}
+
+	if sessionID, ok := session.Get("session_id").(string); ok {
+		subs, err := h.repoFor(c).ListSubscriptions(sessionID, sessionUserID(session))
+		if err != nil {
+			log.Printf("Failed to list subscriptions: %v", err)
+			data.Error = "Failed to load subscriptions"
+		}
+		for _, sub := range subs {
+			data.Subscriptions = append(data.Subscriptions, SubscriptionView{
+				ID:           sub.ID,
+				Product:      sub.ProductName,
+				Quantity:     sub.Quantity,
+				IntervalDays: sub.IntervalDays,
+				Paused:       sub.Status == cart.SubscriptionPaused,
+				NextOrder:    sub.NextOrderAt.UTC().Format("2006-01-02"),
+			})
+		}
+	}
+
+	data.Error = i18n.T(data.Locale, data.Error)
+	data.Notice = i18n.T(data.Locale, data.Notice)
+	if err := h.templateFor(c).ExecuteTemplate(c.Writer, "subscriptions.html", data); err != nil {
+		log.Printf("Failed to render template: %v", err)
+	}
+}
+
+// UpdateSubscription pauses, resumes or cancels a subscription of the session or of the logged-in user.
+func (h *CartHandler) UpdateSubscription(c *gin.Context) {
+	session := sessions.Default(c)
+	action, ok := subscriptionActions[c.Param("action")]
+	if !ok {
+		c.String(http.StatusNotFound, "Not found")
+		return
+	}
+	fail := func(message string) {
+		session.AddFlash(message)
+		if err := session.Save(); err != nil {
+			log.Printf("Failed to save session: %v", err)
+		}
+		c.Redirect(http.StatusFound, "/subscriptions")
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		fail(errorMessage(cart.ErrSubscriptionNotFound, ""))
+		return
+	}
+	sessionID, ok := session.Get("session_id").(string)
+	if !ok {
+		fail("Invalid session")
+		return
+	}
+
+	if err := h.repoFor(c).SetSubscriptionStatus(uint(id), sessionID, sessionUserID(session), action.status); err != nil {
+		fail(errorMessage(err, "Failed to update subscription"))
+		return
+	}
+	session.AddFlash(action.notice, noticeFlash)
+	if err := session.Save(); err != nil {
+		log.Printf("Failed to save session: %v", err)
+	}
+	c.Redirect(http.StatusFound, "/subscriptions")
+}
+
+// sessionUserID returns the ID of the user logged in to the session, nil for anonymous sessions
+func sessionUserID(session sessions.Session) *uint {
+	if userID, ok := session.Get("user_id").(uint); ok {
+		return &userID
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package api_test
+
+import (
+	"fmt"
+	cartpkg "interview/internal/cart"
+	"interview/internal/repo"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscriptions(t *testing.T) {
+	ts := setupTest(t)
+	ts.clearDatabase(t)
+	cartRepo := repo.NewRepository(ts.db)
+
+	cookie := ts.createSession(t)
+	w := ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"shoe"}, "quantity": {"2"}}, cookie)
+	require.Equal(t, http.StatusFound, w.Code)
+	var item cartpkg.CartItem
+	require.NoError(t, ts.db.First(&item).Error)
+
+	// subscribe subscribes to the item every days and returns the error shown on the cart page
+	subscribe := func(t *testing.T, days string) string {
+		t.Helper()
+		w := ts.makeRequest(t, http.MethodPost, "/subscribe-item",
+			url.Values{"cart_item_id": {fmt.Sprint(item.ID)}, "interval": {days}}, cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+		w = ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+
+	t.Run("Rejects Intervals Not Offered", func(t *testing.T) {
+		assert.Contains(t, subscribe(t, "3"), "Please choose an offered subscription interval")
+		assert.Contains(t, subscribe(t, "weekly"), "Please choose an offered subscription interval")
+	})
+
+	t.Run("Subscribes To The Item", func(t *testing.T) {
+		body := subscribe(t, "14")
+		assert.NotContains(t, body, "Please choose an offered subscription interval")
+		assert.Contains(t, body, `<option value="14" selected>`)
+	})
+
+	var order cartpkg.Cart
+	require.NoError(t, ts.db.First(&order).Error)
+	require.NoError(t, cartRepo.CloseCart(order.ID))
+	subs, err := cartRepo.ListSubscriptions(order.SessionID, nil)
+	require.NoError(t, err)
+	require.Len(t, subs, 1)
+	path := fmt.Sprintf("/subscriptions/%d", subs[0].ID)
+
+	t.Run("Lists The Subscriptions", func(t *testing.T) {
+		w := ts.makeRequest(t, http.MethodGet, "/subscriptions", nil, cookie)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "2 × shoe every 14 days")
+		assert.Contains(t, w.Body.String(), path+"/pause")
+	})
+
+	t.Run("Subscriptions Are Private", func(t *testing.T) {
+		other := ts.createSession(t)
+		w := ts.makeRequest(t, http.MethodGet, "/subscriptions", nil, other)
+		assert.NotContains(t, w.Body.String(), "shoe")
+
+		w = ts.makeRequest(t, http.MethodPost, path+"/cancel", nil, other)
+		require.Equal(t, http.StatusFound, w.Code)
+		w = ts.makeRequest(t, http.MethodGet, "/subscriptions", nil, other)
+		assert.Contains(t, w.Body.String(), "Subscription not found")
+	})
+
+	t.Run("Pauses And Cancels", func(t *testing.T) {
+		w := ts.makeRequest(t, http.MethodPost, path+"/pause", nil, cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "/subscriptions", w.Header().Get("Location"))
+		w = ts.makeRequest(t, http.MethodGet, "/subscriptions", nil, cookie)
+		assert.Contains(t, w.Body.String(), "Subscription paused")
+		assert.Contains(t, w.Body.String(), path+"/resume")
+
+		ts.makeRequest(t, http.MethodPost, path+"/cancel", nil, cookie)
+		w = ts.makeRequest(t, http.MethodGet, "/subscriptions", nil, cookie)
+		assert.Contains(t, w.Body.String(), "Subscription cancelled")
+		assert.NotContains(t, w.Body.String(), "every 14 days")
+
+		ts.makeRequest(t, http.MethodPost, path+"/resume", nil, cookie)
+		w = ts.makeRequest(t, http.MethodGet, "/subscriptions", nil, cookie)
+		assert.Contains(t, w.Body.String(), "This subscription was cancelled")
+	})
+
+	t.Run("Unknown Action", func(t *testing.T) {
+		w := ts.makeRequest(t, http.MethodPost, path+"/renew", nil, cookie)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
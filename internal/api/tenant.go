@@ -0,0 +1,49 @@
+package api
+
+import (
+	"interview/internal/repo"
+	"interview/internal/tenant"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ResolveTenant assigns requests to the shop serving their host, so their database queries only see
+// the products and carts of that shop. Requests for hosts that no shop serves are not found. Payment
+// webhooks are sent to the host of PUBLIC_BASE_URL and look payments up by their ID, so they aren't
+// assigned a shop.
+func ResolveTenant(hosts tenant.Hosts) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.URL.Path == paymentWebhookPath {
+			c.Next()
+			return
+		}
+		id, ok := hosts.Resolve(c.Request.Host)
+		if !ok {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		c.Request = c.Request.WithContext(tenant.NewContext(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// shopName returns the name of the shop the request was sent to, empty for single-shop deployments
+func shopName(c *gin.Context) string {
+	id, _ := tenant.FromContext(c.Request.Context())
+	return id
+}
+
+// repoFor returns the repository for the queries of the request, limited to the shop it was sent to. The
+// admin and auth handlers have one too.
+func (h *CartHandler) repoFor(c *gin.Context) *repo.Repository {
+	return h.repo.WithContext(c.Request.Context())
+}
+
+func (h *AdminHandler) repoFor(c *gin.Context) *repo.Repository {
+	return h.repo.WithContext(c.Request.Context())
+}
+
+func (h *AuthHandler) repoFor(c *gin.Context) *repo.Repository {
+	return h.repo.WithContext(c.Request.Context())
+}
@@ -0,0 +1,104 @@
+package api_test
+
+import (
+	"context"
+	"fmt"
+	"interview/internal/api"
+	"interview/internal/cart"
+	"interview/internal/repo"
+	"interview/internal/tenant"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-contrib/sessions"
+	gormsessions "github.com/gin-contrib/sessions/gorm"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenants(t *testing.T) {
+	ts := setupTest(t)
+	ts.clearDatabase(t)
+	require.NoError(t, ts.db.Use(repo.TenantScope{}))
+
+	hosts, err := tenant.Parse("acme=shop.acme.test;globex=globex.test")
+	require.NoError(t, err)
+	router := gin.New()
+	router.Use(api.ResolveTenant(hosts))
+	router.Use(sessions.Sessions("test_session", gormsessions.NewStore(ts.db, true, []byte("test_secret"))))
+	router.GET("/", ts.handler.ShowCart)
+	router.GET("/products", ts.handler.ShowProducts)
+	router.GET("/products/:id", ts.handler.ShowProduct)
+	router.POST("/add-item", ts.handler.AddItem)
+
+	shop := func(id string) *repo.Repository {
+		return ts.handler.GetRepo().WithContext(tenant.NewContext(context.Background(), id))
+	}
+	shoe, err := shop("acme").UpsertProduct("shoe", 10)
+	require.NoError(t, err)
+	hat, err := shop("globex").UpsertProduct("hat", 20)
+	require.NoError(t, err)
+
+	request := func(method, host, path string, form url.Values, cookie *http.Cookie) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		req.Host = host
+		if form != nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Unknown Host", func(t *testing.T) {
+		w := request(http.MethodGet, "other.test", "/products", nil, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Catalog Of The Shop", func(t *testing.T) {
+		w := request(http.MethodGet, "shop.acme.test:8080", "/products", nil, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "· acme</title>")
+		assert.Contains(t, w.Body.String(), fmt.Sprintf(`href="/products/%d"`, shoe.ID))
+		assert.NotContains(t, w.Body.String(), fmt.Sprintf(`href="/products/%d"`, hat.ID))
+	})
+
+	t.Run("Product Of Another Shop", func(t *testing.T) {
+		w := request(http.MethodGet, "shop.acme.test", fmt.Sprintf("/products/%d", hat.ID), nil, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = request(http.MethodGet, "globex.test", fmt.Sprintf("/products/%d", hat.ID), nil, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Carts Of The Shop", func(t *testing.T) {
+		w := request(http.MethodGet, "shop.acme.test", "/", nil, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var cookie *http.Cookie
+		for _, c := range w.Result().Cookies() {
+			if c.Name == "test_session" {
+				cookie = c
+			}
+		}
+		require.NotNil(t, cookie)
+
+		w = request(http.MethodPost, "shop.acme.test", "/add-item", url.Values{"product": {"shoe"}, "quantity": {"1"}}, cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+
+		carts, err := ts.handler.GetRepo().GetAllCarts()
+		require.NoError(t, err)
+		require.Len(t, carts, 1)
+		assert.Equal(t, "acme", carts[0].TenantID)
+
+		// The session cookie taken to the other shop doesn't bring the cart along
+		_, err = shop("globex").GetExistingCart(carts[0].SessionID, cart.DefaultName)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"html/template"
+	"interview/internal/theme"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetThemes renders the pages with the theme of the shop each request was sent to, nil to render the
+// built-in pages.
+func (h *CartHandler) SetThemes(themes *theme.Themes) {
+	h.themes = themes
+}
+
+// templateFor returns the page templates of the theme of the shop the request was sent to
+func (h *CartHandler) templateFor(c *gin.Context) *template.Template {
+	if h.themes == nil {
+		return h.Template
+	}
+	return h.themes.For(shopName(c))
+}
+
+// SetThemes renders the password reset page with the theme of the shop each request was sent to, nil
+// to render the template passed to EnablePasswordReset.
+func (h *AuthHandler) SetThemes(themes *theme.Themes) {
+	h.themes = themes
+}
+
+// templateFor returns the page templates of the theme of the shop the request was sent to
+func (h *AuthHandler) templateFor(c *gin.Context) *template.Template {
+	if h.themes == nil {
+		return h.template
+	}
+	return h.themes.For(shopName(c))
+}
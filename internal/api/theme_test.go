@@ -0,0 +1,46 @@
+package api_test
+
+import (
+	"interview/internal/static"
+	"interview/internal/theme"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThemes(t *testing.T) {
+	ts := setupTest(t)
+	ts.clearDatabase(t)
+
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "acme", "templates"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "acme", "templates", "cart.html"),
+		[]byte(`<h1>Acme cart</h1>{{ range .CartItems }}{{ .Product }}{{ end }}`), 0o644))
+	themes, err := theme.Load(dir, ts.handler.Template, static.Default())
+	require.NoError(t, err)
+
+	t.Run("Built-In Look", func(t *testing.T) {
+		ts.handler.SetThemes(themes)
+		w := ts.makeRequest(t, http.MethodGet, "/", nil, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Add Item to Cart")
+	})
+
+	t.Run("Selected Theme", func(t *testing.T) {
+		require.NoError(t, themes.Select("acme"))
+		ts.handler.SetThemes(themes)
+		defer ts.handler.SetThemes(nil)
+
+		w := ts.makeRequest(t, http.MethodGet, "/", nil, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "<h1>Acme cart</h1>")
+
+		w = ts.makeRequest(t, http.MethodGet, "/products", nil, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "Acme cart", "pages the theme leaves out are built in")
+	})
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"errors"
+	"interview/internal/auth"
+	"interview/internal/i18n"
+	"interview/internal/repo"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// pendingUserKey is the session key of the user who logged in with the provider but still has to
+	// enter their second factor, pendingSinceKey when they did
+	pendingUserKey  = "2fa_user_id"
+	pendingSinceKey = "2fa_since"
+	// pendingAttemptsKey counts the wrong codes entered for the pending login
+	pendingAttemptsKey = "2fa_attempts"
+	// twoFactorTimeout is how long the second factor can be entered after logging in with the provider
+	twoFactorTimeout = 5 * time.Minute
+	// maxTwoFactorAttempts is how many wrong codes end the pending login
+	maxTwoFactorAttempts = 5
+)
+
+type (
+	// TwoFactorEnrollResponse holds the secret to add to an authenticator app, either typed in or
+	// scanned from a QR code of the provisioning URI.
+	TwoFactorEnrollResponse struct {
+		Secret          string `json:"secret"`
+		ProvisioningURI string `json:"provisioning_uri"`
+	}
+
+	// TwoFactorCodeRequest carries a code of the authenticator app.
+	TwoFactorCodeRequest struct {
+		Code string `json:"code" binding:"required"`
+	}
+
+	// TwoFactorConfirmResponse lists the recovery codes, which are only shown once.
+	TwoFactorConfirmResponse struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+)
+
+// SetTOTPIssuer sets the name of the shop shown in authenticator apps.
+func (h *AuthHandler) SetTOTPIssuer(issuer string) {
+	h.totpIssuer = issuer
+}
+
+// EnrollTwoFactor starts enabling two-factor authentication for the logged-in user: it returns a new
+// secret for their authenticator app, which they confirm with ConfirmTwoFactor.
+func (h *AuthHandler) EnrollTwoFactor(c *gin.Context) {
+	userID, ok := sessions.Default(c).Get("user_id").(uint)
+	if !ok {
+		respondWithProblem(c, http.StatusUnauthorized, "login required")
+		return
+	}
+	u, err := h.repoFor(c).GetUser(userID)
+	if err != nil {
+		respondWithProblem(c, http.StatusUnauthorized, "login required")
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		respondWithError(c, err, "Failed to set up two-factor authentication")
+		return
+	}
+	if err := h.repoFor(c).StartTOTPEnrollment(u.ID, secret); err != nil {
+		respondWithError(c, err, "Failed to set up two-factor authentication")
+		return
+	}
+
+	account := u.Email
+	if account == "" {
+		account = u.Name
+	}
+	c.JSON(http.StatusOK, TwoFactorEnrollResponse{
+		Secret:          secret,
+		ProvisioningURI: auth.TOTPProvisioningURI(h.totpIssuer, account, secret),
+	})
+}
+
+// ConfirmTwoFactor enables two-factor authentication once the user entered a code of their
+// authenticator app, and returns their recovery codes.
+func (h *AuthHandler) ConfirmTwoFactor(c *gin.Context) {
+	userID, ok := sessions.Default(c).Get("user_id").(uint)
+	if !ok {
+		respondWithProblem(c, http.StatusUnauthorized, "login required")
+		return
+	}
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondWithInvalidField(c, "code", "is required")
+		return
+	}
+	u, err := h.repoFor(c).GetUser(userID)
+	if err != nil {
+		respondWithProblem(c, http.StatusUnauthorized, "login required")
+		return
+	}
+	if u.TOTPEnabled {
+		respondWithError(c, repo.ErrTOTPEnabled, "Failed to enable two-factor authentication")
+		return
+	}
+	if u.TOTPSecret == "" {
+		respondWithError(c, repo.ErrTOTPNotEnrolled, "Failed to enable two-factor authentication")
+		return
+	}
+	if !auth.VerifyTOTP(u.TOTPSecret, req.Code, time.Now()) {
+		respondWithProblem(c, http.StatusUnprocessableEntity, "invalid code")
+		return
+	}
+
+	codes, err := auth.GenerateRecoveryCodes(auth.RecoveryCodeCount)
+	if err != nil {
+		respondWithError(c, err, "Failed to enable two-factor authentication")
+		return
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = auth.HashRecoveryCode(code)
+	}
+	if err := h.repoFor(c).EnableTOTP(u.ID, hashes); err != nil {
+		respondWithError(c, err, "Failed to enable two-factor authentication")
+		return
+	}
+	c.JSON(http.StatusOK, TwoFactorConfirmResponse{RecoveryCodes: codes})
+}
+
+// VerifyTwoFactor completes the login of a user with two-factor authentication with the "code" form
+// field, a code of their authenticator app or one of their recovery codes.
+func (h *AuthHandler) VerifyTwoFactor(c *gin.Context) {
+	session := sessions.Default(c)
+	userID, ok := session.Get(pendingUserKey).(uint)
+	since, _ := session.Get(pendingSinceKey).(int64)
+	if !ok || time.Since(time.Unix(since, 0)) > twoFactorTimeout {
+		clearPendingLogin(session)
+		h.failLogin(c, session, "Login expired, please log in again")
+		return
+	}
+	u, err := h.repoFor(c).GetUser(userID)
+	if err != nil {
+		clearPendingLogin(session)
+		h.failLogin(c, session, "Login failed, please try again")
+		return
+	}
+	if h.loginLocked(c, u.Email) {
+		h.failLogin(c, session, loginLockedMessage)
+		return
+	}
+
+	code := c.PostForm("code")
+	valid := auth.VerifyTOTP(u.TOTPSecret, code, time.Now())
+	if !valid && len(code) > 6 {
+		err := h.repoFor(c).UseRecoveryCode(u.ID, auth.HashRecoveryCode(code))
+		if err != nil && !errors.Is(err, repo.ErrRecoveryCodeInvalid) {
+			log.Printf("Failed to check recovery code: %v", err)
+		}
+		valid = err == nil
+	}
+	if !valid {
+		left, locked := h.loginFailed(c, u.Email, &u.ID, "second factor")
+		attempts, _ := session.Get(pendingAttemptsKey).(int)
+		switch {
+		case locked:
+			clearPendingLogin(session)
+			h.failLogin(c, session, loginLockedMessage)
+		case attempts+1 >= maxTwoFactorAttempts:
+			clearPendingLogin(session)
+			h.failLogin(c, session, "Too many invalid codes, please log in again")
+		case left <= loginAttemptsWarning:
+			session.Set(pendingAttemptsKey, attempts+1)
+			h.failLogin(c, session, i18n.T(detectLocale(c, session).String(), "Invalid code, attempts left: %d", left))
+		default:
+			session.Set(pendingAttemptsKey, attempts+1)
+			h.failLogin(c, session, "Invalid code, please try again")
+		}
+		return
+	}
+
+	clearPendingLogin(session)
+	h.loginSucceeded(u.Email)
+	h.completeLogin(c, session, u.ID)
+}
+
+// startPendingLogin asks the user for their second factor before completing the login.
+func startPendingLogin(c *gin.Context, session sessions.Session, userID uint) {
+	session.Set(pendingUserKey, userID)
+	session.Set(pendingSinceKey, time.Now().Unix())
+	session.Delete(pendingAttemptsKey)
+	if err := session.Save(); err != nil {
+		log.Printf("Failed to save session: %v", err)
+	}
+	c.Redirect(http.StatusFound, "/")
+}
+
+func clearPendingLogin(session sessions.Session) {
+	session.Delete(pendingUserKey)
+	session.Delete(pendingSinceKey)
+	session.Delete(pendingAttemptsKey)
+}
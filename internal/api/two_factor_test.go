@@ -0,0 +1,171 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"interview/internal/api"
+	"interview/internal/auth"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTwoFactorAuthentication(t *testing.T) {
+	ts := setupTest(t)
+	authHandler := api.NewAuthHandler(ts.db, newFakeGoogle(t))
+	// The invalid codes entered by the tests below mustn't lock the account out
+	authHandler.SetLoginLockout(100, 100, time.Minute, time.Hour)
+	ts.handler.SetLoginProviders(authHandler.Providers())
+	ts.router.GET("/auth/:provider/login", authHandler.Login)
+	ts.router.GET("/auth/:provider/callback", authHandler.Callback)
+	ts.router.POST("/auth/2fa", authHandler.VerifyTwoFactor)
+	ts.router.POST("/logout", authHandler.Logout)
+	ts.router.POST("/account/2fa/enroll", authHandler.EnrollTwoFactor)
+	ts.router.POST("/account/2fa/confirm", authHandler.ConfirmTwoFactor)
+
+	login := func(t *testing.T, cookie *http.Cookie) {
+		t.Helper()
+		w := ts.makeRequest(t, http.MethodGet, "/auth/google/login", nil, cookie)
+		location, err := url.Parse(w.Header().Get("Location"))
+		require.NoError(t, err)
+		w = ts.makeRequest(t, http.MethodGet, "/auth/google/callback?code=abc&state="+location.Query().Get("state"), nil, cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+	}
+	postJSON := func(t *testing.T, path string, body interface{}, cookie *http.Cookie) *httptest.ResponseRecorder {
+		t.Helper()
+		data, err := json.Marshal(body)
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		ts.router.ServeHTTP(w, req)
+		return w
+	}
+	// enable logs a new user in and enables two-factor authentication, returning the secret and
+	// recovery codes
+	enable := func(t *testing.T, cookie *http.Cookie) (string, []string) {
+		t.Helper()
+		login(t, cookie)
+		w := postJSON(t, "/account/2fa/enroll", nil, cookie)
+		require.Equal(t, http.StatusOK, w.Code)
+		var enrolled api.TwoFactorEnrollResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &enrolled))
+		assert.Contains(t, enrolled.ProvisioningURI, "otpauth://totp/")
+		assert.Contains(t, enrolled.ProvisioningURI, "secret="+enrolled.Secret)
+
+		code, err := auth.TOTPCode(enrolled.Secret, time.Now())
+		require.NoError(t, err)
+		w = postJSON(t, "/account/2fa/confirm", api.TwoFactorCodeRequest{Code: code}, cookie)
+		require.Equal(t, http.StatusOK, w.Code)
+		var confirmed api.TwoFactorConfirmResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &confirmed))
+		require.Len(t, confirmed.RecoveryCodes, auth.RecoveryCodeCount)
+
+		ts.makeRequest(t, http.MethodPost, "/logout", url.Values{}, cookie)
+		return enrolled.Secret, confirmed.RecoveryCodes
+	}
+
+	t.Run("Enrollment Requires Login", func(t *testing.T) {
+		ts.clearDatabase(t)
+		w := postJSON(t, "/account/2fa/enroll", nil, ts.createSession(t))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Confirmation Requires Valid Code", func(t *testing.T) {
+		ts.clearDatabase(t)
+		cookie := ts.createSession(t)
+		login(t, cookie)
+		w := postJSON(t, "/account/2fa/confirm", api.TwoFactorCodeRequest{Code: "123456"}, cookie)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		require.Equal(t, http.StatusOK, postJSON(t, "/account/2fa/enroll", nil, cookie).Code)
+		w = postJSON(t, "/account/2fa/confirm", api.TwoFactorCodeRequest{Code: "000000x"}, cookie)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("Login Asks For Code", func(t *testing.T) {
+		ts.clearDatabase(t)
+		cookie := ts.createSession(t)
+		secret, _ := enable(t, cookie)
+
+		login(t, cookie)
+		w := ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.Contains(t, w.Body.String(), `action="/auth/2fa"`)
+		assert.NotContains(t, w.Body.String(), "Logged in as")
+
+		ts.makeRequest(t, http.MethodPost, "/auth/2fa", url.Values{"code": {"000000"}}, cookie)
+		w = ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.Contains(t, w.Body.String(), "Invalid code, please try again")
+		assert.NotContains(t, w.Body.String(), "Logged in as")
+
+		code, err := auth.TOTPCode(secret, time.Now())
+		require.NoError(t, err)
+		w = ts.makeRequest(t, http.MethodPost, "/auth/2fa", url.Values{"code": {code}}, cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+		w = ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.Contains(t, w.Body.String(), "Logged in as Jane")
+	})
+
+	t.Run("Recovery Code", func(t *testing.T) {
+		ts.clearDatabase(t)
+		cookie := ts.createSession(t)
+		_, recoveryCodes := enable(t, cookie)
+
+		login(t, cookie)
+		ts.makeRequest(t, http.MethodPost, "/auth/2fa", url.Values{"code": {recoveryCodes[0]}}, cookie)
+		w := ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.Contains(t, w.Body.String(), "Logged in as Jane")
+
+		ts.makeRequest(t, http.MethodPost, "/logout", url.Values{}, cookie)
+		login(t, cookie)
+		ts.makeRequest(t, http.MethodPost, "/auth/2fa", url.Values{"code": {recoveryCodes[0]}}, cookie)
+		w = ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.NotContains(t, w.Body.String(), "Logged in as", "recovery codes only work once")
+	})
+
+	t.Run("Too Many Invalid Codes", func(t *testing.T) {
+		ts.clearDatabase(t)
+		cookie := ts.createSession(t)
+		secret, _ := enable(t, cookie)
+
+		login(t, cookie)
+		for i := 0; i < 5; i++ {
+			ts.makeRequest(t, http.MethodPost, "/auth/2fa", url.Values{"code": {"000000"}}, cookie)
+		}
+		code, err := auth.TOTPCode(secret, time.Now())
+		require.NoError(t, err)
+		ts.makeRequest(t, http.MethodPost, "/auth/2fa", url.Values{"code": {code}}, cookie)
+		w := ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		assert.NotContains(t, w.Body.String(), "Logged in as")
+		assert.NotContains(t, w.Body.String(), `action="/auth/2fa"`)
+	})
+
+	t.Run("Staff Need Two-Factor Authentication", func(t *testing.T) {
+		ts.clearDatabase(t)
+		admin := api.NewAdminHandler(ts.db, nil, time.Hour)
+		admin.SetRequireStaff2FA(true)
+		admin.RegisterRoutes(ts.router, gin.Accounts{"admin": "secret"})
+
+		cookie := ts.createSession(t)
+		login(t, cookie)
+		require.NoError(t, ts.db.Exec("UPDATE users SET role = ?", auth.RoleSupport).Error)
+		w := ts.makeRequest(t, http.MethodGet, "/admin/carts/export", nil, cookie)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		ts.makeRequest(t, http.MethodPost, "/logout", url.Values{}, cookie)
+		secret, _ := enable(t, cookie)
+		login(t, cookie)
+		code, err := auth.TOTPCode(secret, time.Now())
+		require.NoError(t, err)
+		ts.makeRequest(t, http.MethodPost, "/auth/2fa", url.Values{"code": {code}}, cookie)
+		w = ts.makeRequest(t, http.MethodGet, "/admin/carts/export", nil, cookie)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
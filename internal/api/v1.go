@@ -0,0 +1,480 @@
+package api
+
+import (
+	"interview/internal/analytics"
+	"interview/internal/auth"
+	"interview/internal/cart"
+	"interview/internal/events"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// apiSessionKey is the gin context key holding the cart session ID of an authenticated API request.
+	apiSessionKey = "api_session_id"
+
+	// defaultPageSize and maxPageSize bound the limit parameter of list endpoints
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+type (
+	// AddItemRequest is the JSON body accepted by POST /api/v1/cart/items.
+	AddItemRequest struct {
+		Product  string `json:"product"`
+		Quantity int    `json:"quantity"`
+	}
+
+	// RefreshRequest is the JSON body accepted by POST /api/v1/auth/refresh.
+	RefreshRequest struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	// MetadataRequest is the JSON body accepted by PATCH /api/v1/cart and PATCH /api/v1/cart/items/:id.
+	// Its keys are set on the metadata of the cart or item, keys set to null are removed.
+	MetadataRequest struct {
+		Metadata cart.Metadata `json:"metadata"`
+	}
+
+	// RedeemGiftCardRequest is the JSON body accepted by POST /api/v1/cart/gift-card.
+	RedeemGiftCardRequest struct {
+		Code string `json:"code"`
+	}
+
+	// CartResponse is the JSON representation of a cart. Total is the grand total to pay: the subtotal
+	// less the discount total, plus tax and shipping, less the redeemed gift card credit.
+	CartResponse struct {
+		ID            uint                   `json:"id"`
+		Name          string                 `json:"name"`
+		Status        string                 `json:"status"`
+		Subtotal      float64                `json:"subtotal"`
+		DiscountTotal float64                `json:"discount_total"`
+		Tax           float64                `json:"tax"`
+		Shipping      float64                `json:"shipping"`
+		Credit        float64                `json:"credit"`
+		Total         float64                `json:"total"`
+		Version       int                    `json:"version"`
+		Items         []CartItemResponse     `json:"items"`
+		Discounts     []CartDiscountResponse `json:"discounts"`
+		Metadata      cart.Metadata          `json:"metadata,omitempty"`
+		// ReservedUntil is when the stock held for the limited items of the cart is released
+		ReservedUntil *time.Time `json:"reserved_until,omitempty"`
+	}
+
+	// CartDiscountResponse is the JSON representation of a promotion applied to a cart.
+	CartDiscountResponse struct {
+		Promotion string  `json:"promotion"`
+		Amount    float64 `json:"amount"`
+	}
+
+	// CartItemPage is a page of cart items. NextCursor is passed as the after parameter to fetch the
+	// following page and is omitted on the last page.
+	CartItemPage struct {
+		Items      []CartItemResponse `json:"items"`
+		NextCursor string             `json:"next_cursor,omitempty"`
+	}
+
+	// CartChangePage is a page of the history of a cart, newest first. NextCursor is passed as the after
+	// parameter to fetch older changes and is omitted on the last page.
+	CartChangePage struct {
+		Changes    []CartChangeResponse `json:"changes"`
+		NextCursor string               `json:"next_cursor,omitempty"`
+	}
+
+	// CartChangeResponse is the JSON representation of a change to a cart. Action is one of added,
+	// removed, restored, merged, repriced, gift_card, referral, checked_out and expired; Amount is the
+	// new price of repriced items and the credit of redeemed gift cards.
+	CartChangeResponse struct {
+		Action   string    `json:"action"`
+		Product  string    `json:"product,omitempty"`
+		Quantity int       `json:"quantity,omitempty"`
+		Amount   float64   `json:"amount,omitempty"`
+		Time     time.Time `json:"time"`
+		// UndoneAt is when the change was undone with POST /cart/undo
+		UndoneAt *time.Time `json:"undone_at,omitempty"`
+	}
+
+	// CartItemResponse is the JSON representation of a cart item. TierQuantity is the quantity from which
+	// the volume discount Price comes from applies, omitted at the regular price. Items of a bundle share
+	// their BundleGroup and are priced at their share of the bundle price; both bundle fields are omitted
+	// for items added on their own.
+	CartItemResponse struct {
+		ID           uint          `json:"id"`
+		Product      string        `json:"product"`
+		Quantity     int           `json:"quantity"`
+		Price        float64       `json:"price"`
+		TierQuantity int           `json:"tier_quantity,omitempty"`
+		BundleGroup  uint          `json:"bundle_group,omitempty"`
+		Bundle       string        `json:"bundle,omitempty"`
+		Metadata     cart.Metadata `json:"metadata,omitempty"`
+	}
+)
+
+// RegisterAPIRoutes mounts the versions of the JSON API under /api/<version>, see apiVersions.
+func (h *CartHandler) RegisterAPIRoutes(router gin.IRouter, issuer *auth.Issuer) {
+	h.registerAPIv1(apiVersionGroup(router, "v1"), issuer)
+}
+
+// registerAPIv1 mounts version 1 of the JSON API. Cart endpoints require a bearer access token issued
+// by the token endpoint, so mobile clients don't need cookies, and work on the cart named with
+// ?cart=<name>, the default cart of the session otherwise.
+func (h *CartHandler) registerAPIv1(v1 *gin.RouterGroup, issuer *auth.Issuer) {
+	v1.GET("/openapi.json", serveOpenAPISpec(openAPIv1))
+	v1.POST("/auth/token", h.apiIssueToken(issuer))
+	v1.POST("/auth/refresh", h.apiRefreshToken(issuer))
+
+	authorized := v1.Group("", requireAccessToken(issuer))
+	authorized.GET("/carts", h.APIListCarts)
+	authorized.POST("/carts", h.APICreateCart)
+	authorized.PATCH("/carts/:name", h.APIRenameCart)
+	authorized.DELETE("/carts/:name", h.APIDeleteCart)
+	authorized.GET("/cart", h.APIGetCart)
+	authorized.PATCH("/cart", h.APISetCartMetadata)
+	authorized.GET("/cart/items", h.APIListCartItems)
+	authorized.POST("/cart/items", h.APIAddItem)
+	authorized.POST("/cart/bundles", h.APIAddBundle)
+	authorized.PATCH("/cart/items/:id", h.APISetItemMetadata)
+	authorized.DELETE("/cart/items/:id", h.APIRemoveItem)
+	authorized.GET("/cart/deleted-items", h.APIListDeletedItems)
+	authorized.GET("/cart/history", h.APIListCartHistory)
+	authorized.POST("/cart/undo", h.APIUndoChange)
+	authorized.POST("/cart/items/:id/restore", h.APIRestoreItem)
+	authorized.POST("/cart/gift-card", h.APIRedeemGiftCard)
+	authorized.POST("/cart/referral-code", h.APIApplyReferralCode)
+	authorized.GET("/addresses", h.APIListAddresses)
+	authorized.POST("/addresses", h.APICreateAddress)
+	authorized.DELETE("/addresses/:id", h.APIDeleteAddress)
+	authorized.GET("/notifications", h.APIListNotifications)
+	authorized.POST("/notifications/read", h.APIMarkAllNotificationsRead)
+	authorized.POST("/notifications/:id/read", h.APIMarkNotificationRead)
+}
+
+// requireAccessToken rejects requests without a valid bearer access token.
+func requireAccessToken(issuer *auth.Issuer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || token == "" {
+			respondWithProblem(c, http.StatusUnauthorized, "missing bearer token")
+			return
+		}
+
+		claims, err := issuer.Verify(token, auth.TokenTypeAccess)
+		if err != nil {
+			respondWithProblem(c, http.StatusUnauthorized, "invalid or expired token")
+			return
+		}
+
+		c.Set(apiSessionKey, claims.Subject)
+		c.Next()
+	}
+}
+
+// apiIssueToken starts a new guest cart session and returns a token pair bound to it.
+func (h *CartHandler) apiIssueToken(issuer *auth.Issuer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID, err := generateSessionID()
+		if err != nil {
+			log.Printf("Failed to generate session ID: %v", err)
+			respondWithProblem(c, http.StatusInternalServerError, "failed to create session")
+			return
+		}
+
+		if _, err := h.repoFor(c).GetOrCreateCart(sessionID, cart.DefaultName); err != nil {
+			log.Printf("Failed to create cart: %v", err)
+			respondWithProblem(c, http.StatusInternalServerError, "failed to create cart")
+			return
+		}
+
+		pair, err := issuer.Issue(sessionID)
+		if err != nil {
+			log.Printf("Failed to issue token: %v", err)
+			respondWithProblem(c, http.StatusInternalServerError, "failed to issue token")
+			return
+		}
+		c.JSON(http.StatusOK, pair)
+	}
+}
+
+// apiRefreshToken exchanges a valid refresh token for a new token pair for the same session.
+func (h *CartHandler) apiRefreshToken(issuer *auth.Issuer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req RefreshRequest
+		if err := c.ShouldBindJSON(&req); err != nil || req.RefreshToken == "" {
+			respondWithInvalidField(c, "refresh_token", "is required")
+			return
+		}
+
+		claims, err := issuer.Verify(req.RefreshToken, auth.TokenTypeRefresh)
+		if err != nil {
+			respondWithProblem(c, http.StatusUnauthorized, "invalid or expired refresh token")
+			return
+		}
+
+		pair, err := issuer.Issue(claims.Subject)
+		if err != nil {
+			log.Printf("Failed to issue token: %v", err)
+			respondWithProblem(c, http.StatusInternalServerError, "failed to issue token")
+			return
+		}
+		c.JSON(http.StatusOK, pair)
+	}
+}
+
+// APIGetCart returns the cart of the authenticated session, or 304 Not Modified when it is unchanged
+// since the response whose ETag is sent in If-None-Match.
+func (h *CartHandler) APIGetCart(c *gin.Context) {
+	userCart, err := h.carts.GetCart(c.Request.Context(), c.GetString(apiSessionKey), apiCartName(c))
+	if err != nil {
+		respondWithProblem(c, http.StatusInternalServerError, "failed to load cart")
+		return
+	}
+	if notModified(c, cartETag(userCart)) {
+		return
+	}
+	c.JSON(http.StatusOK, newCartResponse(userCart))
+}
+
+// APIListCartItems returns the items of the cart of the authenticated session, paginated with
+// ?after=<cursor>&limit=<n>.
+func (h *CartHandler) APIListCartItems(c *gin.Context) {
+	after, limit, ok := parsePageParams(c)
+	if !ok {
+		return
+	}
+
+	userCart, err := h.repoFor(c).GetOrCreateCart(c.GetString(apiSessionKey), apiCartName(c))
+	if err != nil {
+		respondWithProblem(c, http.StatusInternalServerError, "failed to load cart")
+		return
+	}
+
+	// Fetch one extra row to know whether another page follows
+	items, err := h.repoFor(c).ListCartItems(userCart.ID, after, limit+1)
+	if err != nil {
+		respondWithProblem(c, http.StatusInternalServerError, "failed to load cart items")
+		return
+	}
+
+	page := CartItemPage{Items: []CartItemResponse{}}
+	if len(items) > limit {
+		items = items[:limit]
+		page.NextCursor = strconv.FormatUint(uint64(items[limit-1].ID), 10)
+	}
+	for _, item := range items {
+		page.Items = append(page.Items, newCartItemResponse(item))
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// parsePageParams reads the after and limit query parameters, responding with 400 when they're invalid.
+func parsePageParams(c *gin.Context) (after uint, limit int, ok bool) {
+	limit = defaultPageSize
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxPageSize {
+			respondWithInvalidField(c, "limit", "must be between 1 and "+strconv.Itoa(maxPageSize))
+			return 0, 0, false
+		}
+		limit = n
+	}
+	if raw := c.Query("after"); raw != "" {
+		n, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			respondWithProblem(c, http.StatusBadRequest, "invalid cursor")
+			return 0, 0, false
+		}
+		after = uint(n)
+	}
+	return after, limit, true
+}
+
+// APIAddItem adds a product to the cart of the authenticated session.
+func (h *CartHandler) APIAddItem(c *gin.Context) {
+	var req AddItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	sessionID, cartName := c.GetString(apiSessionKey), apiCartName(c)
+	if err := h.carts.AddItem(c.Request.Context(), sessionID, nil, cartName, req.Product, req.Quantity); err != nil {
+		respondWithError(c, err, "Failed to add item to cart")
+		return
+	}
+
+	h.publishCartEvent(c, events.TypeItemAdded, sessionID, cartName, req.Product, req.Quantity)
+	h.tracker.Track(analytics.Event{
+		Type: analytics.TypeAddToCart, SessionID: sessionID, Product: req.Product, Quantity: req.Quantity,
+	})
+	h.respondWithCart(c, sessionID, cartName, http.StatusCreated)
+}
+
+// APIRemoveItem removes an item from the cart of the authenticated session.
+func (h *CartHandler) APIRemoveItem(c *gin.Context) {
+	itemID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid item ID")
+		return
+	}
+
+	sessionID, cartName := c.GetString(apiSessionKey), apiCartName(c)
+	item, err := h.carts.RemoveItem(c.Request.Context(), sessionID, cartName, uint(itemID))
+	if err != nil {
+		respondWithError(c, err, "Failed to remove item")
+		return
+	}
+
+	h.publishCartEvent(c, events.TypeItemRemoved, sessionID, cartName, item.ProductName, item.Quantity)
+	h.respondWithCart(c, sessionID, cartName, http.StatusOK)
+}
+
+// APISetCartMetadata changes the metadata of the cart of the authenticated session.
+func (h *CartHandler) APISetCartMetadata(c *gin.Context) {
+	var req MetadataRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Metadata == nil {
+		respondWithInvalidField(c, "metadata", "is required")
+		return
+	}
+
+	sessionID, cartName := c.GetString(apiSessionKey), apiCartName(c)
+	if err := h.carts.SetCartMetadata(c.Request.Context(), sessionID, cartName, req.Metadata); err != nil {
+		respondWithError(c, err, "Failed to update cart")
+		return
+	}
+	h.respondWithCart(c, sessionID, cartName, http.StatusOK)
+}
+
+// APISetItemMetadata changes the metadata of an item of the cart of the authenticated session.
+func (h *CartHandler) APISetItemMetadata(c *gin.Context) {
+	itemID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid item ID")
+		return
+	}
+	var req MetadataRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Metadata == nil {
+		respondWithInvalidField(c, "metadata", "is required")
+		return
+	}
+
+	sessionID, cartName := c.GetString(apiSessionKey), apiCartName(c)
+	if err := h.carts.SetItemMetadata(c.Request.Context(), sessionID, cartName, uint(itemID), req.Metadata); err != nil {
+		respondWithError(c, err, "Failed to update item")
+		return
+	}
+	h.respondWithCart(c, sessionID, cartName, http.StatusOK)
+}
+
+// APIListDeletedItems returns the items removed from the cart of the authenticated session, most
+// recently removed first.
+func (h *CartHandler) APIListDeletedItems(c *gin.Context) {
+	userCart, err := h.repoFor(c).GetOrCreateCart(c.GetString(apiSessionKey), apiCartName(c))
+	if err != nil {
+		respondWithProblem(c, http.StatusInternalServerError, "failed to load cart")
+		return
+	}
+
+	items, err := h.repoFor(c).ListDeletedItems(userCart.ID)
+	if err != nil {
+		respondWithProblem(c, http.StatusInternalServerError, "failed to load cart items")
+		return
+	}
+
+	response := []CartItemResponse{}
+	for _, item := range items {
+		response = append(response, newCartItemResponse(item))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// APIRestoreItem puts an item removed from the cart of the authenticated session back.
+func (h *CartHandler) APIRestoreItem(c *gin.Context) {
+	itemID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid item ID")
+		return
+	}
+
+	sessionID, cartName := c.GetString(apiSessionKey), apiCartName(c)
+	item, err := h.carts.RestoreItem(c.Request.Context(), sessionID, cartName, uint(itemID))
+	if err != nil {
+		respondWithError(c, err, "Failed to restore item")
+		return
+	}
+
+	h.publishCartEvent(c, events.TypeItemAdded, sessionID, cartName, item.ProductName, item.Quantity)
+	h.respondWithCart(c, sessionID, cartName, http.StatusOK)
+}
+
+// APIRedeemGiftCard applies the balance of a gift card to the cart of the authenticated session.
+func (h *CartHandler) APIRedeemGiftCard(c *gin.Context) {
+	var req RedeemGiftCardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "gift card code is required")
+		return
+	}
+
+	sessionID, cartName := c.GetString(apiSessionKey), apiCartName(c)
+	if _, err := h.carts.RedeemGiftCard(c.Request.Context(), sessionID, cartName, req.Code); err != nil {
+		respondWithError(c, err, "Failed to redeem gift card")
+		return
+	}
+
+	h.publishCartEvent(c, events.TypeGiftCardRedeemed, sessionID, cartName, "", 0)
+	h.respondWithCart(c, sessionID, cartName, http.StatusOK)
+}
+
+func (h *CartHandler) respondWithCart(c *gin.Context, sessionID, cartName string, status int) {
+	userCart, err := h.repoFor(c).GetExistingCart(sessionID, cartName)
+	if err != nil {
+		respondWithProblem(c, http.StatusInternalServerError, "failed to load cart")
+		return
+	}
+	c.JSON(status, newCartResponse(userCart))
+}
+
+func newCartResponse(c *cart.Cart) CartResponse {
+	items := make([]CartItemResponse, len(c.CartItems))
+	for i, item := range c.CartItems {
+		items[i] = newCartItemResponse(item)
+	}
+	discounts := make([]CartDiscountResponse, len(c.Discounts))
+	for i, d := range c.Discounts {
+		discounts[i] = CartDiscountResponse{Promotion: d.Promotion, Amount: d.Amount}
+	}
+	return CartResponse{
+		ID:            c.ID,
+		Name:          c.Name,
+		Status:        c.Status,
+		Subtotal:      c.Subtotal,
+		DiscountTotal: c.DiscountTotal,
+		Tax:           c.Tax,
+		Shipping:      c.Shipping,
+		Credit:        c.Credit,
+		Total:         c.Total,
+		Version:       c.Version,
+		Items:         items,
+		Discounts:     discounts,
+		Metadata:      c.Metadata,
+		ReservedUntil: c.ReservedUntil,
+	}
+}
+
+func newCartItemResponse(item cart.CartItem) CartItemResponse {
+	return CartItemResponse{
+		ID:           item.ID,
+		Product:      item.ProductName,
+		Quantity:     item.Quantity,
+		Price:        item.Price,
+		TierQuantity: item.TierQuantity,
+		BundleGroup:  item.BundleGroup,
+		Bundle:       item.BundleName,
+		Metadata:     item.Metadata,
+	}
+}
@@ -0,0 +1,500 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"interview/internal/api"
+	"interview/internal/auth"
+	cartpkg "interview/internal/cart"
+	"interview/internal/repo"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupAPIRouter creates a test router serving only the JSON API
+func setupAPIRouter(t *testing.T, ts *testSetup) *gin.Engine {
+	t.Helper()
+	keys, err := auth.ParseKeySet("test:test_jwt_secret")
+	require.NoError(t, err)
+
+	router := gin.New()
+	ts.handler.RegisterAPIRoutes(router, auth.NewIssuer(keys))
+	return router
+}
+
+// doJSON performs a JSON request against the router, optionally with a bearer token
+func doJSON(t *testing.T, router *gin.Engine, method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&buf).Encode(body))
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// issueToken obtains a fresh token pair from the API
+func issueToken(t *testing.T, router *gin.Engine) auth.TokenPair {
+	t.Helper()
+	w := doJSON(t, router, http.MethodPost, "/api/v1/auth/token", "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var pair auth.TokenPair
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &pair))
+	require.NotEmpty(t, pair.AccessToken)
+	require.NotEmpty(t, pair.RefreshToken)
+	return pair
+}
+
+func TestAPIAuthentication(t *testing.T) {
+	ts := setupTest(t)
+	router := setupAPIRouter(t, ts)
+
+	t.Run("Missing Token", func(t *testing.T) {
+		w := doJSON(t, router, http.MethodGet, "/api/v1/cart", "", nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Invalid Token", func(t *testing.T) {
+		w := doJSON(t, router, http.MethodGet, "/api/v1/cart", "not-a-jwt", nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Refresh Token Is Not An Access Token", func(t *testing.T) {
+		pair := issueToken(t, router)
+		w := doJSON(t, router, http.MethodGet, "/api/v1/cart", pair.RefreshToken, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Refresh Keeps The Same Cart", func(t *testing.T) {
+		ts.clearDatabase(t)
+		pair := issueToken(t, router)
+
+		w := doJSON(t, router, http.MethodPost, "/api/v1/cart/items", pair.AccessToken,
+			api.AddItemRequest{Product: "shoe", Quantity: 1})
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		w = doJSON(t, router, http.MethodPost, "/api/v1/auth/refresh", "",
+			api.RefreshRequest{RefreshToken: pair.RefreshToken})
+		require.Equal(t, http.StatusOK, w.Code)
+		var refreshed auth.TokenPair
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &refreshed))
+
+		w = doJSON(t, router, http.MethodGet, "/api/v1/cart", refreshed.AccessToken, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var cart api.CartResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &cart))
+		assert.Len(t, cart.Items, 1)
+	})
+
+	t.Run("Refresh Rejects Access Token", func(t *testing.T) {
+		pair := issueToken(t, router)
+		w := doJSON(t, router, http.MethodPost, "/api/v1/auth/refresh", "",
+			api.RefreshRequest{RefreshToken: pair.AccessToken})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+func TestAPICartItems(t *testing.T) {
+	ts := setupTest(t)
+	router := setupAPIRouter(t, ts)
+
+	t.Run("Add And Remove Item", func(t *testing.T) {
+		ts.clearDatabase(t)
+		pair := issueToken(t, router)
+
+		w := doJSON(t, router, http.MethodPost, "/api/v1/cart/items", pair.AccessToken,
+			api.AddItemRequest{Product: "bag", Quantity: 2})
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		var cart api.CartResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &cart))
+		require.Len(t, cart.Items, 1)
+		assert.Equal(t, "bag", cart.Items[0].Product)
+		assert.Equal(t, 2, cart.Items[0].Quantity)
+		assert.Equal(t, 60.0, cart.Subtotal)
+		assert.Zero(t, cart.Tax)
+		assert.Zero(t, cart.Shipping)
+		assert.Equal(t, 60.0, cart.Total)
+
+		w = doJSON(t, router, http.MethodDelete, fmt.Sprintf("/api/v1/cart/items/%d", cart.Items[0].ID), pair.AccessToken, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &cart))
+		assert.Empty(t, cart.Items)
+	})
+
+	t.Run("Restore Removed Item", func(t *testing.T) {
+		ts.clearDatabase(t)
+		pair := issueToken(t, router)
+		w := doJSON(t, router, http.MethodPost, "/api/v1/cart/items", pair.AccessToken,
+			api.AddItemRequest{Product: "shoe", Quantity: 3})
+		require.Equal(t, http.StatusCreated, w.Code)
+		var cart api.CartResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &cart))
+		itemPath := fmt.Sprintf("/api/v1/cart/items/%d", cart.Items[0].ID)
+
+		w = doJSON(t, router, http.MethodDelete, itemPath, pair.AccessToken, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		w = doJSON(t, router, http.MethodGet, "/api/v1/cart/deleted-items", pair.AccessToken, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var deleted []api.CartItemResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &deleted))
+		require.Len(t, deleted, 1)
+		assert.Equal(t, cart.Items[0].ID, deleted[0].ID)
+
+		w = doJSON(t, router, http.MethodPost, itemPath+"/restore", pair.AccessToken, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &cart))
+		require.Len(t, cart.Items, 1)
+		assert.Equal(t, 3, cart.Items[0].Quantity)
+
+		w = doJSON(t, router, http.MethodPost, itemPath+"/restore", pair.AccessToken, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Invalid Product", func(t *testing.T) {
+		pair := issueToken(t, router)
+		w := doJSON(t, router, http.MethodPost, "/api/v1/cart/items", pair.AccessToken,
+			api.AddItemRequest{Product: "invalid_product", Quantity: 1})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Invalid Quantity", func(t *testing.T) {
+		pair := issueToken(t, router)
+		w := doJSON(t, router, http.MethodPost, "/api/v1/cart/items", pair.AccessToken,
+			api.AddItemRequest{Product: "shoe", Quantity: 0})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Cannot Remove Another Session's Item", func(t *testing.T) {
+		ts.clearDatabase(t)
+		owner := issueToken(t, router)
+		w := doJSON(t, router, http.MethodPost, "/api/v1/cart/items", owner.AccessToken,
+			api.AddItemRequest{Product: "watch", Quantity: 1})
+		require.Equal(t, http.StatusCreated, w.Code)
+		var cart api.CartResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &cart))
+
+		other := issueToken(t, router)
+		w = doJSON(t, router, http.MethodDelete, fmt.Sprintf("/api/v1/cart/items/%d", cart.Items[0].ID), other.AccessToken, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Closed Cart", func(t *testing.T) {
+		ts.clearDatabase(t)
+		pair := issueToken(t, router)
+		w := doJSON(t, router, http.MethodPost, "/api/v1/cart/items", pair.AccessToken,
+			api.AddItemRequest{Product: "shoe", Quantity: 1})
+		require.Equal(t, http.StatusCreated, w.Code)
+		var cart api.CartResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &cart))
+		require.NoError(t, repo.NewRepository(ts.db).CloseCart(cart.ID))
+
+		w = doJSON(t, router, http.MethodDelete, fmt.Sprintf("/api/v1/cart/items/%d", cart.Items[0].ID), pair.AccessToken, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.JSONEq(t, fmt.Sprintf(`{"type":"about:blank","title":"Conflict","status":409,
+			"detail":"This cart is no longer available","instance":"/api/v1/cart/items/%d"}`, cart.Items[0].ID), w.Body.String())
+
+		// Shopping on after checkout opens a new cart of the same name
+		w = doJSON(t, router, http.MethodPost, "/api/v1/cart/items", pair.AccessToken,
+			api.AddItemRequest{Product: "shoe", Quantity: 2})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var reopened api.CartResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &reopened))
+		assert.NotEqual(t, cart.ID, reopened.ID)
+		require.Len(t, reopened.Items, 1)
+		assert.Equal(t, 2, reopened.Items[0].Quantity)
+	})
+}
+
+func TestAPIMetadata(t *testing.T) {
+	ts := setupTest(t)
+	router := setupAPIRouter(t, ts)
+	ts.clearDatabase(t)
+	pair := issueToken(t, router)
+
+	w := doJSON(t, router, http.MethodPost, "/api/v1/cart/items", pair.AccessToken,
+		api.AddItemRequest{Product: "watch", Quantity: 1})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var cart api.CartResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &cart))
+	itemPath := fmt.Sprintf("/api/v1/cart/items/%d", cart.Items[0].ID)
+
+	t.Run("Cart Metadata", func(t *testing.T) {
+		w := doJSON(t, router, http.MethodPatch, "/api/v1/cart", pair.AccessToken,
+			map[string]interface{}{"metadata": map[string]interface{}{"campaign": "spring", "source": "app"}})
+		require.Equal(t, http.StatusOK, w.Code)
+		w = doJSON(t, router, http.MethodPatch, "/api/v1/cart", pair.AccessToken,
+			map[string]interface{}{"metadata": map[string]interface{}{"source": nil, "visits": 3}})
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var updated api.CartResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+		assert.Equal(t, map[string]interface{}{"campaign": "spring", "visits": 3.0}, map[string]interface{}(updated.Metadata))
+	})
+
+	t.Run("Item Metadata", func(t *testing.T) {
+		w := doJSON(t, router, http.MethodPatch, itemPath, pair.AccessToken,
+			map[string]interface{}{"metadata": map[string]interface{}{"engraving": "For Sam"}})
+		require.Equal(t, http.StatusOK, w.Code)
+		var updated api.CartResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+		engraving, ok := updated.Items[0].Metadata.String("engraving")
+		assert.True(t, ok)
+		assert.Equal(t, "For Sam", engraving)
+
+		w = doJSON(t, router, http.MethodGet, "/api/v1/cart/items", pair.AccessToken, nil)
+		assert.Contains(t, w.Body.String(), `"metadata":{"engraving":"For Sam"}`)
+	})
+
+	t.Run("Invalid Requests", func(t *testing.T) {
+		tests := []struct {
+			name         string
+			path         string
+			body         interface{}
+			expectedCode int
+		}{
+			{"Missing Metadata", "/api/v1/cart", map[string]interface{}{}, http.StatusBadRequest},
+			{"Nested Value", "/api/v1/cart", map[string]interface{}{"metadata": map[string]interface{}{"a": map[string]string{"b": "c"}}}, http.StatusBadRequest},
+			{"Invalid Item ID", "/api/v1/cart/items/abc", map[string]interface{}{"metadata": map[string]string{"a": "b"}}, http.StatusBadRequest},
+			{"Unknown Item", itemPath + "0", map[string]interface{}{"metadata": map[string]string{"a": "b"}}, http.StatusNotFound},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert.Equal(t, tt.expectedCode, doJSON(t, router, http.MethodPatch, tt.path, pair.AccessToken, tt.body).Code)
+			})
+		}
+	})
+}
+
+func TestAPIGetCartETag(t *testing.T) {
+	ts := setupTest(t)
+	router := setupAPIRouter(t, ts)
+	ts.clearDatabase(t)
+	pair := issueToken(t, router)
+
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/cart", nil)
+		req.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+		req.Header.Set("If-None-Match", ifNoneMatch)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("")
+	require.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	assert.Equal(t, "private, no-cache", w.Header().Get("Cache-Control"))
+
+	assert.Equal(t, http.StatusNotModified, get(etag).Code)
+	assert.Equal(t, http.StatusNotModified, get("*").Code)
+
+	w = doJSON(t, router, http.MethodPost, "/api/v1/cart/items", pair.AccessToken,
+		api.AddItemRequest{Product: "bag", Quantity: 1})
+	require.Equal(t, http.StatusCreated, w.Code)
+	w = get(etag)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+}
+
+func TestAPIListCartItems(t *testing.T) {
+	ts := setupTest(t)
+	router := setupAPIRouter(t, ts)
+	ts.clearDatabase(t)
+
+	pair := issueToken(t, router)
+	for _, product := range []string{"shoe", "purse", "bag"} {
+		w := doJSON(t, router, http.MethodPost, "/api/v1/cart/items", pair.AccessToken,
+			api.AddItemRequest{Product: product, Quantity: 1})
+		require.Equal(t, http.StatusCreated, w.Code)
+	}
+
+	w := doJSON(t, router, http.MethodGet, "/api/v1/cart/items?limit=2", pair.AccessToken, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var page api.CartItemPage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	require.Len(t, page.Items, 2)
+	assert.Equal(t, "shoe", page.Items[0].Product)
+	require.NotEmpty(t, page.NextCursor)
+
+	w = doJSON(t, router, http.MethodGet, "/api/v1/cart/items?limit=2&after="+page.NextCursor, pair.AccessToken, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	page = api.CartItemPage{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "bag", page.Items[0].Product)
+	assert.Empty(t, page.NextCursor)
+
+	w = doJSON(t, router, http.MethodGet, "/api/v1/cart/items?limit=1000", pair.AccessToken, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doJSON(t, router, http.MethodGet, "/api/v1/cart/items?after=abc", pair.AccessToken, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAPICarts(t *testing.T) {
+	ts := setupTest(t)
+	router := setupAPIRouter(t, ts)
+	ts.clearDatabase(t)
+	pair := issueToken(t, router)
+
+	w := doJSON(t, router, http.MethodPost, "/api/v1/carts", pair.AccessToken, api.CartNameRequest{Name: "work"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	w = doJSON(t, router, http.MethodPost, "/api/v1/carts", pair.AccessToken, api.CartNameRequest{Name: "work"})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = doJSON(t, router, http.MethodPost, "/api/v1/carts", pair.AccessToken, api.CartNameRequest{Name: " "})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doJSON(t, router, http.MethodPost, "/api/v1/cart/items?cart=work", pair.AccessToken,
+		api.AddItemRequest{Product: "shoe", Quantity: 1})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var work api.CartResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &work))
+	assert.Equal(t, "work", work.Name)
+	assert.Len(t, work.Items, 1)
+
+	w = doJSON(t, router, http.MethodPatch, "/api/v1/carts/work", pair.AccessToken, api.CartNameRequest{Name: "office"})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = doJSON(t, router, http.MethodGet, "/api/v1/carts", pair.AccessToken, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var carts []api.CartResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &carts))
+	require.Len(t, carts, 2)
+	assert.Equal(t, "default", carts[0].Name)
+	assert.Empty(t, carts[0].Items)
+	assert.Equal(t, "office", carts[1].Name)
+	assert.Len(t, carts[1].Items, 1)
+
+	w = doJSON(t, router, http.MethodDelete, "/api/v1/carts/office", pair.AccessToken, nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = doJSON(t, router, http.MethodDelete, "/api/v1/carts/office", pair.AccessToken, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAPIRedeemGiftCard(t *testing.T) {
+	ts := setupTest(t)
+	router := setupAPIRouter(t, ts)
+	ts.clearDatabase(t)
+
+	_, err := repo.NewRepository(ts.db).CreateGiftCard("API-GIFT", 100.0)
+	require.NoError(t, err)
+
+	pair := issueToken(t, router)
+	w := doJSON(t, router, http.MethodPost, "/api/v1/cart/items", pair.AccessToken,
+		api.AddItemRequest{Product: "watch", Quantity: 1})
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	w = doJSON(t, router, http.MethodPost, "/api/v1/cart/gift-card", pair.AccessToken,
+		api.RedeemGiftCardRequest{Code: "api-gift"})
+	require.Equal(t, http.StatusOK, w.Code)
+	var cart api.CartResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &cart))
+	assert.Equal(t, 40.0, cart.Credit)
+	assert.Equal(t, 0.0, cart.Total)
+
+	w = doJSON(t, router, http.MethodPost, "/api/v1/cart/gift-card", pair.AccessToken,
+		api.RedeemGiftCardRequest{Code: "api-gift"})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	w = doJSON(t, router, http.MethodPost, "/api/v1/cart/gift-card", pair.AccessToken,
+		api.RedeemGiftCardRequest{Code: "unknown"})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAPIApplyReferralCode(t *testing.T) {
+	ts := setupTest(t)
+	router := setupAPIRouter(t, ts)
+	ts.clearDatabase(t)
+
+	cartRepo := repo.NewRepository(ts.db)
+	referrer, err := cartRepo.FindOrCreateUser("github", "1", "jane@example.com", "Jane")
+	require.NoError(t, err)
+	ref, err := cartRepo.CreateReferral(referrer.ID)
+	require.NoError(t, err)
+
+	pair := issueToken(t, router)
+	w := doJSON(t, router, http.MethodPost, "/api/v1/cart/items", pair.AccessToken,
+		api.AddItemRequest{Product: "watch", Quantity: 1})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var cart api.CartResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &cart))
+
+	w = doJSON(t, router, http.MethodPost, "/api/v1/cart/referral-code", pair.AccessToken,
+		api.ApplyReferralCodeRequest{Code: ref.Code})
+	assert.Equal(t, http.StatusForbidden, w.Code, "anonymous customers can't enter codes")
+
+	customer, err := cartRepo.FindOrCreateUser("github", "2", "joe@example.com", "Joe")
+	require.NoError(t, err)
+	require.NoError(t, ts.db.Model(&cartpkg.Cart{}).Where("id = ?", cart.ID).Update("user_id", customer.ID).Error)
+
+	w = doJSON(t, router, http.MethodPost, "/api/v1/cart/referral-code", pair.AccessToken,
+		api.ApplyReferralCodeRequest{Code: "REF-00000000"})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"type":"about:blank","title":"Not Found","status":404,
+		"detail":"Unknown referral code","instance":"/api/v1/cart/referral-code"}`, w.Body.String())
+
+	w = doJSON(t, router, http.MethodPost, "/api/v1/cart/referral-code", pair.AccessToken,
+		api.ApplyReferralCodeRequest{Code: " "})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doJSON(t, router, http.MethodPost, "/api/v1/cart/referral-code", pair.AccessToken,
+		api.ApplyReferralCodeRequest{Code: strings.ToLower(ref.Code)})
+	require.Equal(t, http.StatusOK, w.Code)
+	stored, err := cartRepo.GetCart(cart.ID)
+	require.NoError(t, err)
+	require.NotNil(t, stored.ReferralID)
+	assert.Equal(t, ref.ID, *stored.ReferralID)
+}
+
+func TestAPIAddresses(t *testing.T) {
+	ts := setupTest(t)
+	router := setupAPIRouter(t, ts)
+	ts.clearDatabase(t)
+	pair := issueToken(t, router)
+
+	valid := api.AddressRequest{Name: "Jane Doe", Line1: "Hauptstr. 1", City: "Berlin", PostalCode: "10115", Country: "DE"}
+	w := doJSON(t, router, http.MethodPost, "/api/v1/addresses", pair.AccessToken, valid)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created api.AddressResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "10115", created.PostalCode)
+
+	invalid := valid
+	invalid.PostalCode = "1011"
+	w = doJSON(t, router, http.MethodPost, "/api/v1/addresses", pair.AccessToken, invalid)
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"type":"about:blank","title":"Unprocessable Entity","status":422,"detail":"invalid address",
+		"instance":"/api/v1/addresses","errors":[{"field":"postal_code","detail":"is not valid for DE"}]}`, w.Body.String())
+
+	other := issueToken(t, router)
+	w = doJSON(t, router, http.MethodGet, "/api/v1/addresses", other.AccessToken, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[]`, w.Body.String())
+	w = doJSON(t, router, http.MethodDelete, fmt.Sprintf("/api/v1/addresses/%d", created.ID), other.AccessToken, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = doJSON(t, router, http.MethodGet, "/api/v1/addresses", pair.AccessToken, nil)
+	var list []api.AddressResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list, 1)
+	w = doJSON(t, router, http.MethodDelete, fmt.Sprintf("/api/v1/addresses/%d", created.ID), pair.AccessToken, nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
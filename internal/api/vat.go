@@ -0,0 +1,61 @@
+package api
+
+import (
+	"interview/internal/checkout"
+	"interview/internal/vat"
+	"log"
+	"strings"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// SetVATValidator lets businesses enter their VAT ID at the address step of the checkout, validated with
+// the validator. Orders with a valid VAT ID of another member state than shopVATID, the VAT ID of the
+// shop, are zero-rated.
+func (h *CartHandler) SetVATValidator(validator vat.Validator, shopVATID string) {
+	h.vat = validator
+	h.vatPrefix, _, _ = vat.Parse(shopVATID)
+}
+
+// checkVATID validates the "vat_id" entered with the address, which must be from the country the order
+// ships to, and records the evidence, zero-rating the cart when it is exempt. While the validation
+// service is unavailable, IDs not validated before are kept but tax is charged. When ok is false, the
+// response was sent already.
+func (h *CartHandler) checkVATID(c *gin.Context, session sessions.Session, s *checkout.Session, country string) bool {
+	var evidence *vat.Evidence
+	s.VATID = ""
+	if id := c.PostForm("vat_id"); strings.TrimSpace(id) != "" {
+		prefix, number, err := vat.Parse(id)
+		if err != nil {
+			h.checkoutFlash(c, session, "Please enter a valid VAT ID")
+			return false
+		}
+		if !vat.Matches(prefix, country) {
+			h.checkoutFlash(c, session, "The VAT ID must be from the country the order ships to")
+			return false
+		}
+		result, err := h.vat.Validate(c.Request.Context(), prefix, number)
+		switch {
+		case err != nil:
+			log.Printf("Failed to validate VAT ID %s%s: %v", prefix, number, err)
+			session.AddFlash("Your VAT ID couldn't be checked right now, so tax is charged", noticeFlash)
+			if err := session.Save(); err != nil {
+				log.Printf("Failed to save session: %v", err)
+			}
+		case !result.Valid:
+			h.checkoutFlash(c, session, "This VAT ID isn't valid")
+			return false
+		default:
+			evidence = vat.NewEvidence(s.CartID, prefix, number, result, h.vatPrefix)
+		}
+		s.VATID = prefix + number
+	}
+
+	if err := h.repoFor(c).SetVATEvidence(s.CartID, evidence); err != nil {
+		log.Printf("Failed to save VAT evidence: %v", err)
+		h.checkoutFlash(c, session, errorMessage(err, "Failed to check the VAT ID"))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"interview/internal/auth"
+	"interview/internal/mail"
+	"interview/internal/repo"
+	"interview/internal/user"
+	"log"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+var verificationEmailTemplate = texttemplate.Must(texttemplate.New("verification").Parse(`Hello{{ if .Name }} {{ .Name }}{{ end }},
+
+please confirm the email address of your account:
+
+{{ .Link }}
+
+The link expires in {{ .TTL }}. If you didn't create an account, you can ignore this email.
+`))
+
+// emailVerification emails the links verifying the email addresses of accounts, at most one per user
+// every cooldown.
+type emailVerification struct {
+	links    *auth.VerificationLinks
+	mailer   mail.Mailer
+	cooldown time.Duration
+}
+
+// send emails a link verifying the address of the user, failing with repo.ErrVerificationCooldown
+// when one was sent too recently.
+func (v *emailVerification) send(r *repo.Repository, u *user.User) error {
+	if err := r.StartEmailVerification(u.ID, time.Now(), v.cooldown); err != nil {
+		return err
+	}
+	var body bytes.Buffer
+	err := verificationEmailTemplate.Execute(&body, map[string]string{
+		"Name": u.Name,
+		"Link": v.links.URL(u.ID, u.Email),
+		"TTL":  v.links.TTL().String(),
+	})
+	if err != nil {
+		return err
+	}
+	sendEmail(v.mailer, mail.Message{To: u.Email, Subject: "Confirm your email address", Body: body.String()})
+	return nil
+}
+
+// EnableEmailVerification emails a link signed by links through mailer to new accounts, verifying their
+// email address.
+func (h *AuthHandler) EnableEmailVerification(links *auth.VerificationLinks, mailer mail.Mailer, cooldown time.Duration) {
+	h.verification = &emailVerification{links: links, mailer: mailer, cooldown: cooldown}
+}
+
+// verifyNewAccount emails the link verifying the address of the user logging in, unless it is verified
+// or a link was sent before. Users who lost it ask for another on the account page.
+func (h *AuthHandler) verifyNewAccount(c *gin.Context, u *user.User) {
+	if h.verification == nil || u.Email == "" || u.EmailVerifiedAt != nil || u.VerificationSentAt != nil {
+		return
+	}
+	err := h.verification.send(h.repoFor(c), u)
+	if err != nil && !errors.Is(err, repo.ErrVerificationCooldown) {
+		log.Printf("Failed to send verification email to user %d: %v", u.ID, err)
+	}
+}
+
+// EnableEmailVerification lets users verify their email address with links signed by links and ask for
+// another through mailer once cooldown passed. With required set, checkout offers the saved addresses of
+// users only once they verified their address.
+func (h *CartHandler) EnableEmailVerification(links *auth.VerificationLinks, mailer mail.Mailer, cooldown time.Duration, required bool) {
+	h.verification = &emailVerification{links: links, mailer: mailer, cooldown: cooldown}
+	h.requireVerifiedEmail = required
+}
+
+// VerifyEmail marks the email address a verification link was sent to as verified, if it still is the
+// address of the user.
+func (h *CartHandler) VerifyEmail(c *gin.Context) {
+	session := sessions.Default(c)
+	userID, email, err := h.verification.links.Verify(c.Request.URL.Query())
+	if errors.Is(err, auth.ErrExpiredLink) {
+		h.redirectWithFlash(c, session, "This verification link has expired, please request a new one on your account page")
+		return
+	} else if err != nil {
+		h.redirectWithFlash(c, session, "This link is invalid or has expired")
+		return
+	}
+	if err := h.repoFor(c).VerifyEmail(userID, email, time.Now()); errors.Is(err, repo.ErrUserNotFound) {
+		h.redirectWithFlash(c, session, "This link is invalid or has expired")
+		return
+	} else if err != nil {
+		log.Printf("Failed to verify email address of user %d: %v", userID, err)
+		h.redirectWithFlash(c, session, "Failed to verify email address")
+		return
+	}
+	redirectWithNotice(c, session, "Your email address was verified")
+}
+
+// ResendVerification emails the logged-in user another link verifying their email address.
+func (h *CartHandler) ResendVerification(c *gin.Context) {
+	session := sessions.Default(c)
+	u := h.accountUser(c, session)
+	if u == nil {
+		return
+	}
+	if u.Email == "" {
+		h.redirectToAccount(c, session, "Your account has no email address")
+		return
+	}
+	if u.EmailVerifiedAt != nil {
+		h.redirectToAccount(c, session, "Your email address is already verified")
+		return
+	}
+	err := h.verification.send(h.repoFor(c), u)
+	if errors.Is(err, repo.ErrVerificationCooldown) {
+		h.redirectToAccount(c, session, "A verification link was sent recently, please try again later")
+		return
+	} else if err != nil {
+		log.Printf("Failed to send verification email to user %d: %v", u.ID, err)
+		h.redirectToAccount(c, session, "Failed to send verification link")
+		return
+	}
+	h.redirectToAccount(c, session, "We sent you a link to verify your email address")
+}
+
+// addressBookUser returns the user whose saved addresses checkout offers, 0 when the session is anonymous
+// or verified email addresses are required and the user's isn't. Such sessions use the addresses they
+// entered themselves.
+func (h *CartHandler) addressBookUser(c *gin.Context, session sessions.Session) uint {
+	userID := sessionUserIDValue(session)
+	if userID == 0 || !h.requireVerifiedEmail {
+		return userID
+	}
+	u, err := h.repoFor(c).GetUser(userID)
+	if err != nil || u.EmailVerifiedAt == nil {
+		return 0
+	}
+	return userID
+}
@@ -0,0 +1,174 @@
+package api_test
+
+import (
+	"interview/internal/address"
+	"interview/internal/api"
+	"interview/internal/auth"
+	"net/http"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var verificationLinkPattern = regexp.MustCompile(`http://shop\.example\.com(/account/email/verify\?\S+)`)
+
+// verificationSetup is a test environment with email verification enabled and the fake Google login
+type verificationSetup struct {
+	*testSetup
+	auth   *api.AuthHandler
+	mailer fakeMailer
+}
+
+func setupEmailVerification(t *testing.T, ttl time.Duration, required bool) *verificationSetup {
+	t.Helper()
+	ts := &verificationSetup{testSetup: setupTest(t), mailer: make(fakeMailer, 10)}
+	links := auth.NewVerificationLinks("http://shop.example.com", []byte("secret"), ttl)
+	ts.auth = api.NewAuthHandler(ts.db, newFakeGoogle(t))
+	ts.auth.EnableEmailVerification(links, ts.mailer, time.Minute)
+	ts.handler.EnableEmailVerification(links, ts.mailer, time.Minute, required)
+	ts.router.GET("/auth/:provider/login", ts.auth.Login)
+	ts.router.GET("/auth/:provider/callback", ts.auth.Callback)
+	ts.router.GET("/account", ts.handler.ShowAccount)
+	ts.router.GET(auth.VerifyEmailPath, ts.handler.VerifyEmail)
+	ts.router.POST("/account/email/verification", ts.handler.ResendVerification)
+	ts.router.POST("/checkout", ts.handler.Checkout)
+	ts.router.GET("/checkout", ts.handler.ShowCheckout)
+	ts.clearDatabase(t)
+	return ts
+}
+
+// login logs a new session in as the fake Google user
+func (ts *verificationSetup) login(t *testing.T) *http.Cookie {
+	t.Helper()
+	cookie := ts.createSession(t)
+	w := ts.makeRequest(t, http.MethodGet, "/auth/google/login", nil, cookie)
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	w = ts.makeRequest(t, http.MethodGet, "/auth/google/callback?code=abc&state="+location.Query().Get("state"), nil, cookie)
+	require.Equal(t, http.StatusFound, w.Code)
+	return cookie
+}
+
+// verificationLink returns the path of the link of the next verification email
+func (ts *verificationSetup) verificationLink(t *testing.T) string {
+	t.Helper()
+	select {
+	case msg := <-ts.mailer:
+		assert.Equal(t, "jane@example.com", msg.To)
+		match := verificationLinkPattern.FindStringSubmatch(msg.Body)
+		require.NotNil(t, match, msg.Body)
+		return match[1]
+	case <-time.After(5 * time.Second):
+		t.Fatal("no verification email sent")
+		return ""
+	}
+}
+
+// noEmail checks that no further email is sent
+func (ts *verificationSetup) noEmail(t *testing.T) {
+	t.Helper()
+	select {
+	case msg := <-ts.mailer:
+		t.Errorf("unexpected email %q", msg.Subject)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestEmailVerification(t *testing.T) {
+	t.Run("Verifies New Accounts", func(t *testing.T) {
+		ts := setupEmailVerification(t, time.Hour, false)
+		cookie := ts.login(t)
+		link := ts.verificationLink(t)
+		assert.Contains(t, ts.makeRequest(t, http.MethodGet, "/account", nil, cookie).Body.String(), "Your email address is not verified yet")
+
+		ts.login(t)
+		ts.noEmail(t)
+
+		w := ts.makeRequest(t, http.MethodGet, link, nil, cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+		assert.Contains(t, ts.makeRequest(t, http.MethodGet, "/", nil, cookie).Body.String(), "Your email address was verified")
+		assert.NotContains(t, ts.makeRequest(t, http.MethodGet, "/account", nil, cookie).Body.String(), "Your email address is not verified yet")
+
+		ts.makeRequest(t, http.MethodPost, "/account/email/verification", nil, cookie)
+		assert.Contains(t, ts.makeRequest(t, http.MethodGet, "/account", nil, cookie).Body.String(), "Your email address is already verified")
+	})
+
+	t.Run("Resends After The Cooldown", func(t *testing.T) {
+		ts := setupEmailVerification(t, time.Hour, false)
+		cookie := ts.login(t)
+		ts.verificationLink(t)
+
+		ts.makeRequest(t, http.MethodPost, "/account/email/verification", nil, cookie)
+		assert.Contains(t, ts.makeRequest(t, http.MethodGet, "/account", nil, cookie).Body.String(), "A verification link was sent recently")
+		ts.noEmail(t)
+
+		require.NoError(t, ts.db.Exec("UPDATE users SET verification_sent_at = ?", time.Now().Add(-2*time.Minute)).Error)
+		ts.makeRequest(t, http.MethodPost, "/account/email/verification", nil, cookie)
+		assert.Contains(t, ts.makeRequest(t, http.MethodGet, "/account", nil, cookie).Body.String(), "We sent you a link to verify your email address")
+		ts.verificationLink(t)
+	})
+
+	t.Run("Rejects Expired And Forged Links", func(t *testing.T) {
+		ts := setupEmailVerification(t, -time.Minute, false)
+		cookie := ts.login(t)
+		link := ts.verificationLink(t)
+
+		ts.makeRequest(t, http.MethodGet, link, nil, cookie)
+		assert.Contains(t, ts.makeRequest(t, http.MethodGet, "/", nil, cookie).Body.String(), "This verification link has expired")
+
+		forged, err := url.Parse(link)
+		require.NoError(t, err)
+		query := forged.Query()
+		query.Set("email", "mallory@example.com")
+		ts.makeRequest(t, http.MethodGet, auth.VerifyEmailPath+"?"+query.Encode(), nil, cookie)
+		assert.Contains(t, ts.makeRequest(t, http.MethodGet, "/", nil, cookie).Body.String(), "This link is invalid or has expired")
+		assert.Contains(t, ts.makeRequest(t, http.MethodGet, "/account", nil, cookie).Body.String(), "Your email address is not verified yet")
+	})
+
+	t.Run("Checkout Offers Saved Addresses Once Verified", func(t *testing.T) {
+		ts := setupEmailVerification(t, time.Hour, true)
+		cookie := ts.login(t)
+		link := ts.verificationLink(t)
+		jane := ts.jane(t)
+		require.NoError(t, ts.db.Create(&address.Address{
+			UserID: &jane, Name: "Jane Doe", Line1: "Main St 1", City: "Berlin", PostalCode: "10115", Country: "DE",
+		}).Error)
+
+		ts.makeRequest(t, http.MethodPost, "/add-item", url.Values{"product": {"watch"}, "quantity": {"1"}}, cookie)
+		require.Equal(t, "/checkout", ts.makeRequest(t, http.MethodPost, "/checkout", nil, cookie).Header().Get("Location"))
+		body := ts.makeRequest(t, http.MethodGet, "/checkout", nil, cookie).Body.String()
+		assert.Contains(t, body, "Verify your email address to use your saved addresses")
+		assert.NotContains(t, body, "Main St 1")
+
+		ts.makeRequest(t, http.MethodGet, link, nil, cookie)
+		ts.makeRequest(t, http.MethodGet, "/", nil, cookie)
+		body = ts.makeRequest(t, http.MethodGet, "/checkout", nil, cookie).Body.String()
+		assert.NotContains(t, body, "Verify your email address to use your saved addresses")
+		assert.Contains(t, body, "Main St 1")
+	})
+
+	t.Run("Login Links Verify The Address", func(t *testing.T) {
+		ts := setupLoginLinks(t, time.Minute)
+		ts.auth.EnableEmailVerification(auth.NewVerificationLinks("http://shop.example.com", []byte("secret"), time.Hour), ts.mailer, time.Minute)
+		ts.login(t)
+		assert.NotNil(t, ts.jane(t).EmailVerifiedAt)
+		select {
+		case msg := <-ts.mailer:
+			t.Errorf("unexpected email %q", msg.Subject)
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+}
+
+// jane returns the ID of the fake Google user
+func (ts *verificationSetup) jane(t *testing.T) uint {
+	t.Helper()
+	var id uint
+	require.NoError(t, ts.db.Table("users").Select("id").Where("email = ?", "jane@example.com").Scan(&id).Error)
+	require.NotZero(t, id)
+	return id
+}
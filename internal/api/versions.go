@@ -0,0 +1,136 @@
+package api
+
+import (
+	"encoding/json"
+	"expvar"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// currentAPIVersion is the version of the JSON API requests name neither in the path nor in Accept
+	currentAPIVersion = "v1"
+	// apiMediaTypePrefix and apiMediaTypeSuffix enclose the version in the media type negotiating it,
+	// e.g. application/vnd.shop.v1+json
+	apiMediaTypePrefix = "application/vnd.shop."
+	apiMediaTypeSuffix = "+json"
+)
+
+// apiVersions are the versions of the JSON API served. Adding a version means listing it here and
+// registering its routes on apiVersionGroup(router, "v2") in RegisterAPIRoutes; endpoints being retired
+// get Deprecate.
+var apiVersions = map[string]bool{"v1": true}
+
+// deprecatedRequests counts the requests to deprecated endpoints by route, to tell when they can go
+var deprecatedRequests = expvar.NewMap("deprecated_api_requests")
+
+// Deprecation announces that endpoints of the API are being retired.
+type Deprecation struct {
+	// Date is when the endpoints were deprecated
+	Date time.Time
+	// Sunset is when they stop being served, zero while that isn't decided
+	Sunset time.Time
+	// Successor is the path of the endpoint replacing them, e.g. "/api/v2/cart", empty for none
+	Successor string
+	// Info is the URL of the documentation of the deprecation, empty for none
+	Info string
+}
+
+// apiVersionGroup returns the group of the routes of the version, under /api/<version>. Its responses
+// tell the version in the API-Version header.
+func apiVersionGroup(router gin.IRouter, version string) *gin.RouterGroup {
+	return router.Group("/api/"+version, func(c *gin.Context) {
+		c.Header("API-Version", version)
+	})
+}
+
+// Deprecate returns a middleware announcing the deprecation of the endpoints it is added to, with the
+// Deprecation (RFC 9745) and Sunset (RFC 8594) headers and Link headers to the successor and the
+// documentation. The endpoints keep working; requests to them are counted in deprecated_api_requests.
+func Deprecate(d Deprecation) gin.HandlerFunc {
+	deprecation := "@" + strconv.FormatInt(d.Date.Unix(), 10)
+	var sunset string
+	if !d.Sunset.IsZero() {
+		sunset = d.Sunset.UTC().Format(http.TimeFormat)
+	}
+	var links []string
+	if d.Successor != "" {
+		links = append(links, "<"+d.Successor+`>; rel="successor-version"`)
+	}
+	if d.Info != "" {
+		links = append(links, "<"+d.Info+`>; rel="deprecation"; type="text/html"`)
+	}
+
+	return func(c *gin.Context) {
+		c.Header("Deprecation", deprecation)
+		if sunset != "" {
+			c.Header("Sunset", sunset)
+		}
+		for _, link := range links {
+			c.Writer.Header().Add("Link", link)
+		}
+		deprecatedRequests.Add(c.Request.Method+" "+c.FullPath(), 1)
+		c.Next()
+	}
+}
+
+// NegotiateAPIVersion serves API requests without a version in their path, like /api/cart, from the
+// version named by their Accept header, e.g. application/vnd.shop.v1+json, or from the current version
+// when it names none. Versions that aren't served are answered with 406 Not Acceptable.
+func NegotiateAPIVersion(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rest, ok := strings.CutPrefix(r.URL.Path, "/api/")
+		if !ok || apiVersions[strings.SplitN(rest, "/", 2)[0]] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Accept")
+		version, ok := acceptedAPIVersion(r.Header.Get("Accept"))
+		if !ok {
+			writeNotAcceptable(w, r, version)
+			return
+		}
+		r.URL.Path = "/api/" + version + "/" + rest
+		if r.URL.RawPath != "" {
+			r.URL.RawPath = "/api/" + version + strings.TrimPrefix(r.URL.RawPath, "/api")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// acceptedAPIVersion returns the version named by the first API media type of the Accept header, the
+// current version when there is none, and false when the named version isn't served.
+func acceptedAPIVersion(accept string) (string, bool) {
+	for _, mediaType := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(mediaType))
+		if err != nil {
+			continue
+		}
+		version, ok := strings.CutPrefix(mediaType, apiMediaTypePrefix)
+		if !ok {
+			continue
+		}
+		version = strings.TrimSuffix(version, apiMediaTypeSuffix)
+		return version, apiVersions[version]
+	}
+	return currentAPIVersion, true
+}
+
+// writeNotAcceptable answers a request for an API version that isn't served, outside of gin
+func writeNotAcceptable(w http.ResponseWriter, r *http.Request, version string) {
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(http.StatusNotAcceptable)
+	_ = json.NewEncoder(w).Encode(Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(http.StatusNotAcceptable),
+		Status:   http.StatusNotAcceptable,
+		Detail:   "API version " + version + " is not available",
+		Instance: r.URL.Path,
+	})
+}
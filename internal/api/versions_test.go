@@ -0,0 +1,87 @@
+package api_test
+
+import (
+	"encoding/json"
+	"expvar"
+	"interview/internal/api"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIVersions(t *testing.T) {
+	ts := setupTest(t)
+	ts.clearDatabase(t)
+	router := setupAPIRouter(t, ts)
+	pair := issueToken(t, router)
+	server := api.NegotiateAPIVersion(router)
+
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Version In The Path", func(t *testing.T) {
+		w := get("/api/v1/cart", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "v1", w.Header().Get("API-Version"))
+		assert.Empty(t, w.Header().Get("Vary"))
+	})
+
+	t.Run("Version In Accept", func(t *testing.T) {
+		w := get("/api/cart", "application/vnd.shop.v1+json")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "v1", w.Header().Get("API-Version"))
+		assert.Equal(t, "Accept", w.Header().Get("Vary"))
+	})
+
+	t.Run("Current Version Without One", func(t *testing.T) {
+		w := get("/api/cart", "application/json")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "v1", w.Header().Get("API-Version"))
+	})
+
+	t.Run("Unknown Version", func(t *testing.T) {
+		w := get("/api/cart", "application/json;q=0.5, application/vnd.shop.v9+json")
+		require.Equal(t, http.StatusNotAcceptable, w.Code)
+		var problem api.Problem
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+		assert.Equal(t, "API version v9 is not available", problem.Detail)
+	})
+}
+
+func TestDeprecate(t *testing.T) {
+	router := gin.New()
+	router.GET("/api/v1/legacy", api.Deprecate(api.Deprecation{
+		Date:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Sunset:    time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC),
+		Successor: "/api/v2/current",
+		Info:      "https://shop.example/docs/deprecations",
+	}), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"legacy": true})
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/legacy", nil))
+	require.Equal(t, http.StatusOK, w.Code, "deprecated endpoints keep working")
+	assert.Equal(t, "@1704067200", w.Header().Get("Deprecation"))
+	assert.Equal(t, "Mon, 01 Jul 2024 00:00:00 GMT", w.Header().Get("Sunset"))
+	assert.Equal(t, []string{
+		`</api/v2/current>; rel="successor-version"`,
+		`<https://shop.example/docs/deprecations>; rel="deprecation"; type="text/html"`,
+	}, w.Header().Values("Link"))
+
+	requests := expvar.Get("deprecated_api_requests").(*expvar.Map)
+	assert.Equal(t, "1", requests.Get("GET /api/v1/legacy").String())
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"errors"
+	"interview/internal/address"
+	"interview/internal/warehouse"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type (
+	// CreateWarehouseRequest is the JSON body accepted by POST /admin/warehouses.
+	CreateWarehouseRequest struct {
+		Name    string `json:"name"`
+		Country string `json:"country"`
+	}
+
+	// WarehouseStockRequest is the JSON body accepted by POST /admin/warehouses/:id/stock.
+	WarehouseStockRequest struct {
+		ProductID uint `json:"product_id"`
+		Quantity  *int `json:"quantity"`
+	}
+
+	// WarehouseResponse is the JSON representation of a warehouse.
+	WarehouseResponse struct {
+		ID        uint      `json:"id"`
+		Name      string    `json:"name"`
+		Country   string    `json:"country"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+
+	// AllocationResponse is the JSON representation of the units of an order item shipped from a
+	// warehouse.
+	AllocationResponse struct {
+		CartItemID uint   `json:"cart_item_id"`
+		Product    string `json:"product"`
+		Warehouse  string `json:"warehouse"`
+		Quantity   int    `json:"quantity"`
+	}
+)
+
+// ListWarehouses returns the warehouses products are kept in.
+func (h *AdminHandler) ListWarehouses(c *gin.Context) {
+	warehouses, err := h.repoFor(c).ListWarehouses()
+	if err != nil {
+		log.Printf("Failed to list warehouses: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to list warehouses")
+		return
+	}
+	responses := make([]WarehouseResponse, len(warehouses))
+	for i, w := range warehouses {
+		responses[i] = newWarehouseResponse(w)
+	}
+	c.JSON(http.StatusOK, responses)
+}
+
+// CreateWarehouse adds a warehouse.
+func (h *AdminHandler) CreateWarehouse(c *gin.Context) {
+	var req CreateWarehouseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	w := warehouse.Warehouse{Name: strings.TrimSpace(req.Name), Country: strings.ToUpper(strings.TrimSpace(req.Country))}
+	if w.Name == "" {
+		respondWithInvalidField(c, "name", "is required")
+		return
+	}
+	if !address.SupportedCountry(w.Country) {
+		respondWithInvalidField(c, "country", "must be a supported country code")
+		return
+	}
+	if err := h.repoFor(c).CreateWarehouse(&w); err != nil {
+		log.Printf("Failed to create warehouse: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to create warehouse")
+		return
+	}
+	c.JSON(http.StatusCreated, newWarehouseResponse(w))
+}
+
+// UpdateWarehouseStock sets the units of a product in a warehouse. The stock of the product becomes its
+// units in all warehouses and can't be set directly anymore.
+func (h *AdminHandler) UpdateWarehouseStock(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid warehouse ID")
+		return
+	}
+	var req WarehouseStockRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Quantity == nil || *req.Quantity < 0 {
+		respondWithInvalidField(c, "quantity", "must be a number of at least 0")
+		return
+	}
+
+	total, err := h.repoFor(c).SetWarehouseStock(uint(id), req.ProductID, *req.Quantity)
+	if errors.Is(err, warehouse.ErrWarehouseNotFound) {
+		respondWithProblem(c, http.StatusNotFound, "warehouse not found")
+		return
+	} else if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondWithProblem(c, http.StatusNotFound, "product not found")
+		return
+	} else if err != nil {
+		log.Printf("Failed to update warehouse stock: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to update stock")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"warehouse_id": id, "product_id": req.ProductID, "quantity": *req.Quantity, "stock": total})
+}
+
+func newWarehouseResponse(w warehouse.Warehouse) WarehouseResponse {
+	return WarehouseResponse{ID: w.ID, Name: w.Name, Country: w.Country, CreatedAt: w.CreatedAt}
+}
@@ -0,0 +1,86 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"interview/internal/api"
+	"interview/internal/repo"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminWarehouses(t *testing.T) {
+	ts := setupTest(t)
+	ts.clearDatabase(t)
+	shoe, err := repo.NewRepository(ts.db).UpsertProduct("shoe", 10)
+	require.NoError(t, err)
+
+	router := gin.New()
+	api.NewAdminHandler(ts.db, nil, time.Hour).RegisterRoutes(router, gin.Accounts{"admin": "secret"})
+	request := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		var payload bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&payload).Encode(body))
+		}
+		req := httptest.NewRequest(method, path, &payload)
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	var created api.WarehouseResponse
+
+	t.Run("Create", func(t *testing.T) {
+		tests := []struct {
+			name         string
+			body         api.CreateWarehouseRequest
+			expectedCode int
+		}{
+			{"Missing Name", api.CreateWarehouseRequest{Country: "DE"}, http.StatusBadRequest},
+			{"Unknown Country", api.CreateWarehouseRequest{Name: "Berlin", Country: "XX"}, http.StatusBadRequest},
+			{"Valid Warehouse", api.CreateWarehouseRequest{Name: "Berlin", Country: "de"}, http.StatusCreated},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				w := request(http.MethodPost, "/admin/warehouses", tt.body)
+				assert.Equal(t, tt.expectedCode, w.Code)
+				if w.Code == http.StatusCreated {
+					require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+				}
+			})
+		}
+		assert.Equal(t, "DE", created.Country)
+
+		w := request(http.MethodGet, "/admin/warehouses", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var list []api.WarehouseResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+		require.Len(t, list, 1)
+		assert.Equal(t, "Berlin", list[0].Name)
+	})
+
+	t.Run("Stock Is Set Per Warehouse", func(t *testing.T) {
+		path := fmt.Sprintf("/admin/warehouses/%d/stock", created.ID)
+		quantity := 4
+		w := request(http.MethodPost, path, api.WarehouseStockRequest{ProductID: shoe.ID, Quantity: &quantity})
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, fmt.Sprintf(`{"warehouse_id": %d, "product_id": %d, "quantity": 4, "stock": 4}`, created.ID, shoe.ID), w.Body.String())
+
+		assert.Equal(t, http.StatusBadRequest, request(http.MethodPost, path, api.WarehouseStockRequest{ProductID: shoe.ID}).Code)
+		assert.Equal(t, http.StatusNotFound, request(http.MethodPost, path, api.WarehouseStockRequest{ProductID: shoe.ID + 100, Quantity: &quantity}).Code)
+		w = request(http.MethodPost, fmt.Sprintf("/admin/warehouses/%d/stock", created.ID+100), api.WarehouseStockRequest{ProductID: shoe.ID, Quantity: &quantity})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = request(http.MethodPost, fmt.Sprintf("/admin/products/%d/stock", shoe.ID), api.ProductStockRequest{Stock: &quantity})
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"errors"
+	"interview/internal/repo"
+	"interview/internal/webhook"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// deliveryLogSize is how many deliveries the delivery log returns
+const deliveryLogSize = 50
+
+type (
+	// CreateWebhookRequest is the JSON body accepted by POST /admin/webhooks.
+	CreateWebhookRequest struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+
+	// WebhookResponse is the JSON representation of a webhook endpoint. The secret is only returned
+	// when the endpoint is created.
+	WebhookResponse struct {
+		ID        uint      `json:"id"`
+		URL       string    `json:"url"`
+		Events    []string  `json:"events"`
+		Secret    string    `json:"secret,omitempty"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+
+	// WebhookDeliveryResponse is the JSON representation of an entry of the delivery log.
+	WebhookDeliveryResponse struct {
+		ID             uint       `json:"id"`
+		Event          string     `json:"event"`
+		Status         string     `json:"status"`
+		Attempts       int        `json:"attempts"`
+		NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+		ResponseStatus int        `json:"response_status,omitempty"`
+		LastError      string     `json:"last_error,omitempty"`
+		DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+		CreatedAt      time.Time  `json:"created_at"`
+		Payload        string     `json:"payload"`
+	}
+)
+
+// ListWebhooks returns the registered webhook endpoints.
+func (h *AdminHandler) ListWebhooks(c *gin.Context) {
+	endpoints, err := h.repoFor(c).ListWebhooks()
+	if err != nil {
+		log.Printf("Failed to list webhooks: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to list webhooks")
+		return
+	}
+	responses := make([]WebhookResponse, len(endpoints))
+	for i, endpoint := range endpoints {
+		responses[i] = newWebhookResponse(endpoint)
+	}
+	c.JSON(http.StatusOK, responses)
+}
+
+// CreateWebhook registers an endpoint and returns it with the secret its deliveries are signed with.
+func (h *AdminHandler) CreateWebhook(c *gin.Context) {
+	var req CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	target, err := url.Parse(req.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		respondWithInvalidField(c, "url", "must be an absolute http or https URL")
+		return
+	}
+	events := make([]string, 0, len(req.Events))
+	for _, event := range req.Events {
+		event = strings.TrimSpace(event)
+		if event == "" || strings.Contains(event, ",") {
+			respondWithProblem(c, http.StatusBadRequest, "invalid event name")
+			return
+		}
+		events = append(events, event)
+	}
+	if len(events) == 0 {
+		events = []string{webhook.AllEvents}
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		respondWithProblem(c, http.StatusInternalServerError, "failed to create webhook")
+		return
+	}
+	endpoint, err := h.repoFor(c).CreateWebhook(target.String(), secret, events)
+	if err != nil {
+		log.Printf("Failed to create webhook: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to create webhook")
+		return
+	}
+
+	response := newWebhookResponse(*endpoint)
+	response.Secret = endpoint.Secret
+	c.JSON(http.StatusCreated, response)
+}
+
+// DeleteWebhook unregisters an endpoint.
+func (h *AdminHandler) DeleteWebhook(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid webhook ID")
+		return
+	}
+	if err := h.repoFor(c).DeleteWebhook(uint(id)); errors.Is(err, repo.ErrWebhookNotFound) {
+		respondWithProblem(c, http.StatusNotFound, "webhook not found")
+		return
+	} else if err != nil {
+		log.Printf("Failed to delete webhook: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to delete webhook")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListWebhookDeliveries returns the delivery log of an endpoint, newest first. The status query
+// parameter restricts it to pending, delivered or dead deliveries.
+func (h *AdminHandler) ListWebhookDeliveries(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid webhook ID")
+		return
+	}
+	status := c.Query("status")
+	switch status {
+	case "", webhook.StatusPending, webhook.StatusDelivered, webhook.StatusDead:
+	default:
+		respondWithInvalidField(c, "status", "must be pending, delivered or dead")
+		return
+	}
+	if _, err := h.repoFor(c).GetWebhook(uint(id)); errors.Is(err, repo.ErrWebhookNotFound) {
+		respondWithProblem(c, http.StatusNotFound, "webhook not found")
+		return
+	} else if err != nil {
+		respondWithProblem(c, http.StatusInternalServerError, "failed to load webhook")
+		return
+	}
+
+	deliveries, err := h.repoFor(c).ListWebhookDeliveries(uint(id), status, deliveryLogSize)
+	if err != nil {
+		log.Printf("Failed to list deliveries: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to list deliveries")
+		return
+	}
+	responses := make([]WebhookDeliveryResponse, len(deliveries))
+	for i, d := range deliveries {
+		responses[i] = WebhookDeliveryResponse{
+			ID:             d.ID,
+			Event:          d.Event,
+			Status:         d.Status,
+			Attempts:       d.Attempts,
+			ResponseStatus: d.ResponseStatus,
+			LastError:      d.LastError,
+			DeliveredAt:    d.DeliveredAt,
+			CreatedAt:      d.CreatedAt,
+			Payload:        d.Payload,
+		}
+		if d.Status == webhook.StatusPending {
+			responses[i].NextAttemptAt = &d.NextAttemptAt
+		}
+	}
+	c.JSON(http.StatusOK, responses)
+}
+
+// RetryWebhookDelivery moves a dead delivery back to the queue with a fresh set of attempts.
+func (h *AdminHandler) RetryWebhookDelivery(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithProblem(c, http.StatusBadRequest, "invalid delivery ID")
+		return
+	}
+	if err := h.repoFor(c).RetryWebhookDelivery(uint(id), time.Now()); errors.Is(err, repo.ErrDeliveryNotRetryable) {
+		respondWithProblem(c, http.StatusConflict, err.Error())
+		return
+	} else if err != nil {
+		log.Printf("Failed to retry delivery: %v", err)
+		respondWithProblem(c, http.StatusInternalServerError, "failed to retry delivery")
+		return
+	}
+	c.Status(http.StatusAccepted)
+}
+
+func newWebhookResponse(endpoint webhook.Endpoint) WebhookResponse {
+	return WebhookResponse{
+		ID:        endpoint.ID,
+		URL:       endpoint.URL,
+		Events:    strings.Split(endpoint.Events, ","),
+		CreatedAt: endpoint.CreatedAt,
+	}
+}
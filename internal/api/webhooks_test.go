@@ -0,0 +1,123 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"interview/internal/api"
+	"interview/internal/repo"
+	"interview/internal/storage"
+	"interview/internal/webhook"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminWebhooks(t *testing.T) {
+	ts := setupTest(t)
+	ts.clearDatabase(t)
+
+	router := gin.New()
+	media := storage.NewLocal(t.TempDir(), "/media", []byte("test_secret"))
+	api.NewAdminHandler(ts.db, media, time.Hour).RegisterRoutes(router, gin.Accounts{"admin": "secret"})
+
+	request := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		var payload bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&payload).Encode(body))
+		}
+		req := httptest.NewRequest(method, path, &payload)
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	var created api.WebhookResponse
+
+	t.Run("Create", func(t *testing.T) {
+		tests := []struct {
+			name         string
+			body         api.CreateWebhookRequest
+			expectedCode int
+		}{
+			{"Relative URL", api.CreateWebhookRequest{URL: "/hooks"}, http.StatusBadRequest},
+			{"Unsupported Scheme", api.CreateWebhookRequest{URL: "ftp://example.com/hooks"}, http.StatusBadRequest},
+			{"Invalid Event", api.CreateWebhookRequest{URL: "https://example.com/hooks", Events: []string{"a,b"}}, http.StatusBadRequest},
+			{"Valid Webhook", api.CreateWebhookRequest{URL: "https://example.com/hooks", Events: []string{"cart.item_added"}}, http.StatusCreated},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				w := request(http.MethodPost, "/admin/webhooks", tt.body)
+				assert.Equal(t, tt.expectedCode, w.Code)
+				if w.Code == http.StatusCreated {
+					require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+				}
+			})
+		}
+
+		assert.NotEmpty(t, created.Secret)
+		assert.Equal(t, []string{"cart.item_added"}, created.Events)
+	})
+
+	t.Run("List Hides Secrets", func(t *testing.T) {
+		w := request(http.MethodGet, "/admin/webhooks", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var list []api.WebhookResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+		require.Len(t, list, 1)
+		assert.Equal(t, created.ID, list[0].ID)
+		assert.Empty(t, list[0].Secret)
+	})
+
+	t.Run("Delivery Log And Retry", func(t *testing.T) {
+		r := repo.NewRepository(ts.db)
+		_, err := r.EnqueueWebhookDeliveries("cart.item_added", []byte(`{}`), time.Now())
+		require.NoError(t, err)
+		deliveries, err := r.ListWebhookDeliveries(created.ID, "", 10)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		dead := deliveries[0]
+		dead.Status = webhook.StatusDead
+		dead.Attempts = webhook.MaxAttempts
+		dead.LastError = "endpoint responded with 500 Internal Server Error"
+		require.NoError(t, r.SaveWebhookDelivery(&dead))
+
+		w := request(http.MethodGet, fmt.Sprintf("/admin/webhooks/%d/deliveries?status=dead", created.ID), nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var log []api.WebhookDeliveryResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &log))
+		require.Len(t, log, 1)
+		assert.Equal(t, dead.LastError, log[0].LastError)
+		assert.Equal(t, webhook.MaxAttempts, log[0].Attempts)
+
+		w = request(http.MethodPost, fmt.Sprintf("/admin/webhook-deliveries/%d/retry", dead.ID), nil)
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		w = request(http.MethodPost, fmt.Sprintf("/admin/webhook-deliveries/%d/retry", dead.ID), nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = request(http.MethodGet, fmt.Sprintf("/admin/webhooks/%d/deliveries?status=pending", created.ID), nil)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &log))
+		require.Len(t, log, 1)
+		assert.Zero(t, log[0].Attempts)
+		assert.NotNil(t, log[0].NextAttemptAt)
+
+		w = request(http.MethodGet, fmt.Sprintf("/admin/webhooks/%d/deliveries?status=unknown", created.ID), nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		w := request(http.MethodDelete, fmt.Sprintf("/admin/webhooks/%d", created.ID), nil)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		w = request(http.MethodDelete, fmt.Sprintf("/admin/webhooks/%d", created.ID), nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w = request(http.MethodGet, fmt.Sprintf("/admin/webhooks/%d/deliveries", created.ID), nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,40 @@
+// Package audit defines the audit log recording what staff members did on behalf of customers, and
+// security events such as failed logins.
+package audit
+
+import "time"
+
+// Actions recorded in the audit log
+const (
+	// ActionImpersonationStarted is recorded when a staff member opens the view of a customer's cart
+	ActionImpersonationStarted = "impersonation.started"
+	// ActionImpersonationStopped is recorded when a staff member leaves the view of a customer's cart
+	ActionImpersonationStopped = "impersonation.stopped"
+	// ActionImpersonatedRequest is recorded for every request made while viewing a customer's cart,
+	// including those denied
+	ActionImpersonatedRequest = "impersonation.request"
+	// ActionLoginFailed is recorded for every login failing for a wrong password or second factor
+	ActionLoginFailed = "login.failed"
+	// ActionLoginLockedOut is recorded when failed logins lock an email address or IP address out
+	ActionLoginLockedOut = "login.locked_out"
+)
+
+// Entry records an action of a staff member or a security event
+type Entry struct {
+	ID uint `gorm:"primarykey"`
+	// Actor is the staff member, e.g. the basic auth user or their role, or the IP address of the client
+	// for security events
+	Actor  string `gorm:"size:64;not null"`
+	Action string `gorm:"size:32;index;not null"`
+	// SessionID and UserID are the customer acted for, UserID is nil for anonymous customers
+	SessionID string `gorm:"size:255;index"`
+	UserID    *uint  `gorm:"index"`
+	// Detail describes the action, e.g. the method, path and response status of a request
+	Detail    string `gorm:"size:1024"`
+	CreatedAt time.Time
+}
+
+// TableName names the table after the log rather than its entries.
+func (Entry) TableName() string {
+	return "audit_log"
+}
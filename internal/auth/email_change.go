@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EmailChangePath is the path of the endpoint confirming a new email address
+const EmailChangePath = "/account/email/confirm"
+
+// EmailChangeLinks builds and verifies signed, short-lived links confirming the new email address of
+// a user. The link is sent to the new address, so opening it proves the user owns it.
+type EmailChangeLinks struct {
+	baseURL string
+	secret  []byte
+	ttl     time.Duration
+	now     func() time.Time
+}
+
+// NewEmailChangeLinks creates links to baseURL signed with secret and valid for ttl.
+func NewEmailChangeLinks(baseURL string, secret []byte, ttl time.Duration) *EmailChangeLinks {
+	return &EmailChangeLinks{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		secret:  secret,
+		ttl:     ttl,
+		now:     time.Now,
+	}
+}
+
+// TTL returns how long links stay valid.
+func (l *EmailChangeLinks) TTL() time.Duration {
+	return l.ttl
+}
+
+// URL returns a link changing the email address of the user with the given ID to email.
+func (l *EmailChangeLinks) URL(userID uint, email string) string {
+	expires := l.now().Add(l.ttl).Unix()
+	query := url.Values{}
+	query.Set("user", strconv.FormatUint(uint64(userID), 10))
+	query.Set("email", email)
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", signLink(l.secret, "email-change", userID, email, expires))
+	return l.baseURL + EmailChangePath + "?" + query.Encode()
+}
+
+// Verify checks the query of a link and returns the user and their new email address. Malformed, forged
+// and expired links fail with ErrInvalidToken.
+func (l *EmailChangeLinks) Verify(query url.Values) (uint, string, error) {
+	userID, err := strconv.ParseUint(query.Get("user"), 10, 64)
+	if err != nil {
+		return 0, "", ErrInvalidToken
+	}
+	email := query.Get("email")
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || email == "" {
+		return 0, "", ErrInvalidToken
+	}
+	signature := signLink(l.secret, "email-change", uint(userID), email, expires)
+	if !hmac.Equal([]byte(query.Get("signature")), []byte(signature)) || l.now().Unix() > expires {
+		return 0, "", ErrInvalidToken
+	}
+	return uint(userID), email, nil
+}
@@ -0,0 +1,54 @@
+package auth_test
+
+import (
+	"interview/internal/auth"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmailChangeLinks(t *testing.T) {
+	links := auth.NewEmailChangeLinks("https://shop.example.com", []byte("secret"), time.Hour)
+
+	parse := func(t *testing.T, link string) url.Values {
+		u, err := url.Parse(link)
+		require.NoError(t, err)
+		assert.Equal(t, auth.EmailChangePath, u.Path)
+		return u.Query()
+	}
+
+	t.Run("valid link", func(t *testing.T) {
+		userID, email, err := links.Verify(parse(t, links.URL(7, "new@example.com")))
+		require.NoError(t, err)
+		assert.Equal(t, uint(7), userID)
+		assert.Equal(t, "new@example.com", email)
+	})
+
+	t.Run("tampered user or email", func(t *testing.T) {
+		query := parse(t, links.URL(7, "new@example.com"))
+		query.Set("user", "8")
+		_, _, err := links.Verify(query)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+
+		query = parse(t, links.URL(7, "new@example.com"))
+		query.Set("email", "evil@example.com")
+		_, _, err = links.Verify(query)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	})
+
+	t.Run("login links are no email change links", func(t *testing.T) {
+		login := auth.NewLoginLinks("https://shop.example.com", []byte("secret"), time.Hour)
+		query := parse(t, links.URL(7, "new@example.com"))
+		_, err := login.Verify(query)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	})
+
+	t.Run("expired link", func(t *testing.T) {
+		expired := auth.NewEmailChangeLinks("https://shop.example.com", []byte("secret"), -time.Minute)
+		_, _, err := expired.Verify(parse(t, expired.URL(7, "new@example.com")))
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	})
+}
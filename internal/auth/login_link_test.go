@@ -0,0 +1,60 @@
+package auth_test
+
+import (
+	"interview/internal/auth"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginLinks(t *testing.T) {
+	links := auth.NewLoginLinks("https://shop.example.com/", []byte("secret"), time.Hour)
+
+	parse := func(t *testing.T, link string) url.Values {
+		u, err := url.Parse(link)
+		require.NoError(t, err)
+		assert.Equal(t, "https://shop.example.com"+auth.LoginLinkPath, u.Scheme+"://"+u.Host+u.Path)
+		return u.Query()
+	}
+
+	t.Run("valid link", func(t *testing.T) {
+		link, err := links.Verify(parse(t, links.URL(auth.LoginLink{Email: "jane@example.com", CartID: 42})))
+		require.NoError(t, err)
+		assert.Equal(t, auth.LoginLink{Email: "jane@example.com", CartID: 42}, *link)
+	})
+
+	t.Run("link without cart", func(t *testing.T) {
+		query := parse(t, links.URL(auth.LoginLink{Email: "jane@example.com"}))
+		assert.False(t, query.Has("cart"))
+		link, err := links.Verify(query)
+		require.NoError(t, err)
+		assert.Equal(t, uint(0), link.CartID)
+	})
+
+	t.Run("tampered email or cart", func(t *testing.T) {
+		query := parse(t, links.URL(auth.LoginLink{Email: "jane@example.com", CartID: 42}))
+		query.Set("email", "john@example.com")
+		_, err := links.Verify(query)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+
+		query = parse(t, links.URL(auth.LoginLink{Email: "jane@example.com", CartID: 42}))
+		query.Set("cart", "43")
+		_, err = links.Verify(query)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	})
+
+	t.Run("other secret", func(t *testing.T) {
+		other := auth.NewLoginLinks("https://shop.example.com", []byte("other"), time.Hour)
+		_, err := other.Verify(parse(t, links.URL(auth.LoginLink{Email: "jane@example.com"})))
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	})
+
+	t.Run("expired link", func(t *testing.T) {
+		expired := auth.NewLoginLinks("https://shop.example.com", []byte("secret"), -time.Minute)
+		_, err := expired.Verify(parse(t, expired.URL(auth.LoginLink{Email: "jane@example.com"})))
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	})
+}
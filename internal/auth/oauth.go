@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/endpoints"
+)
+
+const (
+	// ProviderGoogle is the name of the Google login provider
+	ProviderGoogle = "google"
+	// ProviderGitHub is the name of the GitHub login provider
+	ProviderGitHub = "github"
+)
+
+type (
+	// Profile is the identity returned by a login provider after a successful OAuth2 flow.
+	Profile struct {
+		ID    string
+		Email string
+		Name  string
+	}
+
+	// OAuthProvider wraps the OAuth2 configuration of a social login provider together with
+	// the endpoints used to look up the logged-in user's profile.
+	OAuthProvider struct {
+		Name   string
+		Config *oauth2.Config
+		// ProfileURL returns the authenticated user's profile
+		ProfileURL string
+		// EmailsURL lists the user's email addresses; only used when the profile has no public email
+		EmailsURL string
+		decode    func(p *OAuthProvider, ctx context.Context, client *http.Client) (*Profile, error)
+	}
+)
+
+// NewGoogleProvider creates the Google login provider.
+func NewGoogleProvider(clientID, clientSecret, redirectURL string) *OAuthProvider {
+	return &OAuthProvider{
+		Name: ProviderGoogle,
+		Config: &oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  redirectURL,
+			Endpoint:     endpoints.Google,
+			Scopes:       []string{"openid", "email", "profile"},
+		},
+		ProfileURL: "https://openidconnect.googleapis.com/v1/userinfo",
+		decode:     decodeGoogleProfile,
+	}
+}
+
+// NewGitHubProvider creates the GitHub login provider.
+func NewGitHubProvider(clientID, clientSecret, redirectURL string) *OAuthProvider {
+	return &OAuthProvider{
+		Name: ProviderGitHub,
+		Config: &oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  redirectURL,
+			Endpoint:     endpoints.GitHub,
+			Scopes:       []string{"read:user", "user:email"},
+		},
+		ProfileURL: "https://api.github.com/user",
+		EmailsURL:  "https://api.github.com/user/emails",
+		decode:     decodeGitHubProfile,
+	}
+}
+
+// AuthCodeURL returns the provider URL the user is redirected to in order to log in.
+func (p *OAuthProvider) AuthCodeURL(state string) string {
+	return p.Config.AuthCodeURL(state)
+}
+
+// Exchange trades the authorization code from the callback for a token and fetches the user's profile.
+func (p *OAuthProvider) Exchange(ctx context.Context, code string) (*Profile, error) {
+	token, err := p.Config.Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+
+	profile, err := p.decode(p, ctx, p.Config.Client(ctx, token))
+	if err != nil {
+		return nil, err
+	}
+	if profile.ID == "" {
+		return nil, errors.New("provider returned a profile without an ID")
+	}
+	return profile, nil
+}
+
+func decodeGoogleProfile(p *OAuthProvider, ctx context.Context, client *http.Client) (*Profile, error) {
+	var info struct {
+		Sub   string `json:"sub"`
+		Email string `json:"email"`
+		Name  string `json:"name"`
+	}
+	if err := getJSON(ctx, client, p.ProfileURL, &info); err != nil {
+		return nil, err
+	}
+	return &Profile{ID: info.Sub, Email: info.Email, Name: info.Name}, nil
+}
+
+func decodeGitHubProfile(p *OAuthProvider, ctx context.Context, client *http.Client) (*Profile, error) {
+	var info struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	}
+	if err := getJSON(ctx, client, p.ProfileURL, &info); err != nil {
+		return nil, err
+	}
+
+	profile := &Profile{ID: strconv.FormatInt(info.ID, 10), Email: info.Email, Name: info.Name}
+	if profile.Name == "" {
+		profile.Name = info.Login
+	}
+
+	// GitHub omits the email from the profile when the user keeps it private
+	if profile.Email == "" && p.EmailsURL != "" {
+		var emails []struct {
+			Email    string `json:"email"`
+			Primary  bool   `json:"primary"`
+			Verified bool   `json:"verified"`
+		}
+		if err := getJSON(ctx, client, p.EmailsURL, &emails); err != nil {
+			return nil, err
+		}
+		for _, e := range emails {
+			if e.Primary && e.Verified {
+				profile.Email = e.Email
+				break
+			}
+		}
+	}
+
+	return profile, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch profile: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch profile: unexpected status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode profile: %w", err)
+	}
+	return nil
+}
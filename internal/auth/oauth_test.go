@@ -0,0 +1,83 @@
+package auth_test
+
+import (
+	"context"
+	"encoding/json"
+	"interview/internal/auth"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+// newFakeGitHub starts a server emulating GitHub's token, profile and emails endpoints
+func newFakeGitHub(t *testing.T, profile map[string]interface{}) *auth.OAuthProvider {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		if r.Form.Get("code") != "good-code" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "gh-token", "token_type": "bearer"})
+	})
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer gh-token", r.Header.Get("Authorization"))
+		_ = json.NewEncoder(w).Encode(profile)
+	})
+	mux.HandleFunc("/user/emails", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode([]map[string]interface{}{
+			{"email": "secondary@example.com", "primary": false, "verified": true},
+			{"email": "primary@example.com", "primary": true, "verified": true},
+		})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	p := auth.NewGitHubProvider("client", "secret", "http://localhost/auth/github/callback")
+	p.Config.Endpoint = oauth2.Endpoint{
+		AuthURL:  srv.URL + "/login/oauth/authorize",
+		TokenURL: srv.URL + "/login/oauth/access_token",
+	}
+	p.ProfileURL = srv.URL + "/user"
+	p.EmailsURL = srv.URL + "/user/emails"
+	return p
+}
+
+func TestOAuthProvider(t *testing.T) {
+	t.Run("auth code URL carries the state", func(t *testing.T) {
+		p := auth.NewGoogleProvider("client", "secret", "http://localhost/auth/google/callback")
+		assert.Contains(t, p.AuthCodeURL("xyz"), "state=xyz")
+	})
+
+	t.Run("exchange returns the GitHub profile", func(t *testing.T) {
+		p := newFakeGitHub(t, map[string]interface{}{"id": 42, "login": "octo", "name": "Octo Cat", "email": "octo@example.com"})
+
+		profile, err := p.Exchange(context.Background(), "good-code")
+		require.NoError(t, err)
+		assert.Equal(t, "42", profile.ID)
+		assert.Equal(t, "Octo Cat", profile.Name)
+		assert.Equal(t, "octo@example.com", profile.Email)
+	})
+
+	t.Run("private GitHub email falls back to the primary verified address", func(t *testing.T) {
+		p := newFakeGitHub(t, map[string]interface{}{"id": 7, "login": "octo"})
+
+		profile, err := p.Exchange(context.Background(), "good-code")
+		require.NoError(t, err)
+		assert.Equal(t, "octo", profile.Name)
+		assert.Equal(t, "primary@example.com", profile.Email)
+	})
+
+	t.Run("bad code fails", func(t *testing.T) {
+		p := newFakeGitHub(t, map[string]interface{}{"id": 7})
+
+		_, err := p.Exchange(context.Background(), "bad-code")
+		assert.Error(t, err)
+	})
+}
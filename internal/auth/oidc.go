@@ -0,0 +1,233 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+// DefaultGroupsClaim is the ID token claim listing the groups of the user at most identity providers
+const DefaultGroupsClaim = "groups"
+
+// ErrNoRole is returned when none of the groups of a user is mapped to a role
+var ErrNoRole = errors.New("user has no admin role")
+
+type (
+	// OIDCProvider logs staff members in with an OpenID Connect identity provider and maps the groups
+	// they belong to there to admin roles.
+	OIDCProvider struct {
+		Issuer string
+		Config *oauth2.Config
+		// JWKSURL is where the keys signing ID tokens are published
+		JWKSURL string
+		// GroupsClaim is the ID token claim listing the groups of the user
+		GroupsClaim string
+		// RoleGroups maps group names to the role they grant
+		RoleGroups map[string]string
+		Client     *http.Client
+
+		mu   sync.Mutex
+		keys map[string]*rsa.PublicKey
+	}
+
+	// StaffIdentity is the staff member logged in with the identity provider
+	StaffIdentity struct {
+		Subject string
+		Email   string
+		Name    string
+		Role    string
+	}
+
+	// discoveryDocument holds the parts of the provider metadata used for logging in
+	discoveryDocument struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+
+	// jsonWebKey is an RSA key of a JWK set
+	jsonWebKey struct {
+		Kid string `json:"kid"`
+		Kty string `json:"kty"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	}
+)
+
+// ParseRoleGroups parses a comma-separated list of "group=role" pairs, e.g.
+// "shop-admins=admin,shop-support=support".
+func ParseRoleGroups(spec string) (map[string]string, error) {
+	roles := map[string]string{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		group, role, ok := strings.Cut(entry, "=")
+		group, role = strings.TrimSpace(group), strings.TrimSpace(role)
+		if !ok || group == "" {
+			return nil, fmt.Errorf("role group %q must have the form group=role", entry)
+		}
+		if !IsStaffRole(role) {
+			return nil, fmt.Errorf("unknown role %q, must be %s or %s", role, RoleAdmin, RoleSupport)
+		}
+		roles[group] = role
+	}
+	if len(roles) == 0 {
+		return nil, errors.New("at least one group must be mapped to a role")
+	}
+	return roles, nil
+}
+
+// DiscoverOIDCProvider reads the metadata of the identity provider at issuer to create an
+// OIDCProvider.
+func DiscoverOIDCProvider(ctx context.Context, issuer, clientID, clientSecret, redirectURL string, roleGroups map[string]string) (*OIDCProvider, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	var doc discoveryDocument
+	wellKnown := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	if err := getJSON(ctx, client, wellKnown, &doc); err != nil {
+		return nil, fmt.Errorf("failed to discover identity provider: %w", err)
+	}
+	if doc.Issuer != issuer {
+		return nil, fmt.Errorf("identity provider reports issuer %q instead of %q", doc.Issuer, issuer)
+	}
+
+	return &OIDCProvider{
+		Issuer: doc.Issuer,
+		Config: &oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  redirectURL,
+			Endpoint:     oauth2.Endpoint{AuthURL: doc.AuthorizationEndpoint, TokenURL: doc.TokenEndpoint},
+			Scopes:       []string{"openid", "email", "profile", "groups"},
+		},
+		JWKSURL:     doc.JWKSURI,
+		GroupsClaim: DefaultGroupsClaim,
+		RoleGroups:  roleGroups,
+		Client:      client,
+	}, nil
+}
+
+// AuthCodeURL returns the identity provider URL staff members are redirected to in order to log in.
+// The nonce comes back in the ID token, binding it to this login.
+func (p *OIDCProvider) AuthCodeURL(state, nonce string) string {
+	return p.Config.AuthCodeURL(state, oauth2.SetAuthURLParam("nonce", nonce))
+}
+
+// Exchange trades the authorization code from the callback for an ID token, verifies it and returns
+// the staff member it identifies. It fails with ErrNoRole when none of their groups grants a role.
+func (p *OIDCProvider) Exchange(ctx context.Context, code, nonce string) (*StaffIdentity, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.Client)
+	token, err := p.Config.Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, errors.New("identity provider returned no ID token")
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(rawIDToken, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer(p.Issuer),
+		jwt.WithAudience(p.Config.ClientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if claimNonce, _ := claims["nonce"].(string); claimNonce == "" || claimNonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidToken)
+	}
+
+	identity := &StaffIdentity{}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+	if identity.Subject == "" {
+		return nil, fmt.Errorf("%w: no subject", ErrInvalidToken)
+	}
+	identity.Role = p.role(claims[p.GroupsClaim])
+	if identity.Role == "" {
+		return identity, ErrNoRole
+	}
+	return identity, nil
+}
+
+// role returns the role granted by the groups claim, the admin role winning over the others.
+func (p *OIDCProvider) role(groups interface{}) string {
+	list, _ := groups.([]interface{})
+	role := ""
+	for _, g := range list {
+		name, _ := g.(string)
+		switch p.RoleGroups[name] {
+		case RoleAdmin:
+			return RoleAdmin
+		case RoleSupport:
+			role = RoleSupport
+		}
+	}
+	return role
+}
+
+// key returns the public key with the given ID, fetching the key set again when it is unknown so
+// keys rotated by the identity provider are picked up.
+func (p *OIDCProvider) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := getJSON(ctx, p.Client, p.JWKSURL, &set); err != nil {
+		return nil, fmt.Errorf("failed to fetch signing keys: %w", err)
+	}
+	p.keys = make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		key, err := k.rsaPublicKey()
+		if err != nil {
+			return nil, err
+		}
+		p.keys[k.Kid] = key
+	}
+
+	key, ok := p.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return key, nil
+}
+
+func (k jsonWebKey) rsaPublicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("invalid modulus of key %q: %w", k.Kid, err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exponent of key %q: %w", k.Kid, err)
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+}
@@ -0,0 +1,143 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"interview/internal/auth"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeIdP emulates an OpenID Connect identity provider issuing ID tokens with the claims registered
+// for each authorization code
+type fakeIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	codes  map[string]jwt.MapClaims
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	idp := &fakeIdP{key: key, codes: map[string]jwt.MapClaims{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kid": "key-1", "kty": "RSA", "use": "sig",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		claims, ok := idp.codes[r.Form.Get("code")]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "key-1"
+		idToken, err := token.SignedString(key)
+		require.NoError(t, err)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "at", "token_type": "Bearer", "id_token": idToken})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+// issue registers a code for an ID token of the client with the given claims on top of the defaults
+func (idp *fakeIdP) issue(code, nonce string, extra jwt.MapClaims) {
+	claims := jwt.MapClaims{
+		"iss": idp.server.URL, "aud": "client", "sub": "staff-1", "email": "staff@example.com",
+		"nonce": nonce, "exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range extra {
+		claims[k] = v
+	}
+	idp.codes[code] = claims
+}
+
+func TestParseRoleGroups(t *testing.T) {
+	roles, err := auth.ParseRoleGroups(" shop-admins=admin, shop-support = support ")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"shop-admins": auth.RoleAdmin, "shop-support": auth.RoleSupport}, roles)
+
+	for _, spec := range []string{"", "shop-admins", "=admin", "shop-admins=owner"} {
+		_, err := auth.ParseRoleGroups(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestOIDCProvider(t *testing.T) {
+	idp := newFakeIdP(t)
+	roles := map[string]string{"shop-admins": auth.RoleAdmin, "shop-support": auth.RoleSupport}
+	p, err := auth.DiscoverOIDCProvider(context.Background(), idp.server.URL, "client", "secret",
+		"http://localhost/admin/callback", roles)
+	require.NoError(t, err)
+
+	t.Run("auth code URL carries the state and nonce", func(t *testing.T) {
+		u, err := url.Parse(p.AuthCodeURL("state-1", "nonce-1"))
+		require.NoError(t, err)
+		assert.Equal(t, idp.server.URL+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+		assert.Equal(t, "state-1", u.Query().Get("state"))
+		assert.Equal(t, "nonce-1", u.Query().Get("nonce"))
+	})
+
+	t.Run("maps groups to the strongest role", func(t *testing.T) {
+		idp.issue("both", "n", jwt.MapClaims{"groups": []string{"shop-support", "shop-admins"}})
+		identity, err := p.Exchange(context.Background(), "both", "n")
+		require.NoError(t, err)
+		assert.Equal(t, auth.StaffIdentity{Subject: "staff-1", Email: "staff@example.com", Role: auth.RoleAdmin}, *identity)
+
+		idp.issue("support", "n", jwt.MapClaims{"groups": []string{"everyone", "shop-support"}})
+		identity, err = p.Exchange(context.Background(), "support", "n")
+		require.NoError(t, err)
+		assert.Equal(t, auth.RoleSupport, identity.Role)
+	})
+
+	t.Run("rejects users without a role", func(t *testing.T) {
+		idp.issue("none", "n", jwt.MapClaims{"groups": []string{"everyone"}})
+		_, err := p.Exchange(context.Background(), "none", "n")
+		assert.ErrorIs(t, err, auth.ErrNoRole)
+	})
+
+	t.Run("rejects invalid tokens", func(t *testing.T) {
+		admins := []string{"shop-admins"}
+		idp.issue("other-nonce", "n", jwt.MapClaims{"groups": admins})
+		idp.issue("other-client", "n", jwt.MapClaims{"groups": admins, "aud": "someone-else"})
+		idp.issue("expired", "n", jwt.MapClaims{"groups": admins, "exp": time.Now().Add(-time.Minute).Unix()})
+		idp.issue("other-issuer", "n", jwt.MapClaims{"groups": admins, "iss": "https://evil.example.com"})
+
+		_, err := p.Exchange(context.Background(), "other-nonce", "different")
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+		for _, code := range []string{"other-client", "expired", "other-issuer"} {
+			_, err := p.Exchange(context.Background(), code, "n")
+			assert.ErrorIs(t, err, auth.ErrInvalidToken, code)
+		}
+		_, err = p.Exchange(context.Background(), "unknown-code", "n")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// MinPasswordLength is the smallest number of characters accepted for a password
+	MinPasswordLength = 8
+	// maxPasswordBytes is the longest password bcrypt can hash
+	maxPasswordBytes = 72
+)
+
+var (
+	// ErrPasswordTooShort is returned for passwords shorter than MinPasswordLength
+	ErrPasswordTooShort = fmt.Errorf("password must have at least %d characters", MinPasswordLength)
+	// ErrPasswordTooLong is returned for passwords bcrypt can't hash
+	ErrPasswordTooLong = errors.New("password is too long")
+)
+
+// dummyHash is compared against when there is no account, so logins take as long either way
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("no account has this password"), bcrypt.DefaultCost)
+
+// ValidatePassword checks that the password is long enough to be set and short enough to be hashed.
+func ValidatePassword(password string) error {
+	if utf8.RuneCountInString(password) < MinPasswordLength {
+		return ErrPasswordTooShort
+	}
+	if len(password) > maxPasswordBytes {
+		return ErrPasswordTooLong
+	}
+	return nil
+}
+
+// HashPassword returns the bcrypt hash of the password.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// CheckPassword reports whether the password matches the hash. An empty hash never matches, but
+// takes as long to check as a real one.
+func CheckPassword(hash, password string) bool {
+	if hash == "" {
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
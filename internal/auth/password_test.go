@@ -0,0 +1,22 @@
+package auth_test
+
+import (
+	"interview/internal/auth"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPassword(t *testing.T) {
+	assert.ErrorIs(t, auth.ValidatePassword("short"), auth.ErrPasswordTooShort)
+	assert.ErrorIs(t, auth.ValidatePassword(strings.Repeat("x", 73)), auth.ErrPasswordTooLong)
+	assert.NoError(t, auth.ValidatePassword("correct horse"))
+
+	hash, err := auth.HashPassword("correct horse")
+	require.NoError(t, err)
+	assert.True(t, auth.CheckPassword(hash, "correct horse"))
+	assert.False(t, auth.CheckPassword(hash, "wrong horse"))
+	assert.False(t, auth.CheckPassword("", ""))
+}
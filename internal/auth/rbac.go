@@ -0,0 +1,63 @@
+package auth
+
+// Roles of users. Customers only use the shop, staff members also work in the admin area.
+const (
+	// RoleCustomer is the role of every new user
+	RoleCustomer = "customer"
+	// RoleSupport helps customers with their carts
+	RoleSupport = "support"
+	// RoleAdmin may do everything in the admin area
+	RoleAdmin = "admin"
+)
+
+// Permission is an action in the admin area granted to some roles.
+type Permission string
+
+const (
+	// PermViewCarts allows looking at carts, their items and live cart activity
+	PermViewCarts Permission = "carts:view"
+	// PermManageCarts allows changing carts of customers, e.g. repricing them
+	PermManageCarts Permission = "carts:manage"
+	// PermManageProducts allows changing the catalog
+	PermManageProducts Permission = "products:manage"
+	// PermIssueRefunds allows giving money back to customers
+	PermIssueRefunds Permission = "refunds:issue"
+	// PermViewReports allows reading the sales and experiment reports
+	PermViewReports Permission = "reports:view"
+	// PermManageWebhooks allows registering webhook endpoints and retrying deliveries
+	PermManageWebhooks Permission = "webhooks:manage"
+	// PermViewSettings allows looking at the configuration of the shop, with secrets redacted
+	PermViewSettings Permission = "settings:view"
+	// PermManageSettings allows changing settings while the shop runs, e.g. switching maintenance mode
+	PermManageSettings Permission = "settings:manage"
+	// PermViewAuditLog allows reading what staff members did on behalf of customers
+	PermViewAuditLog Permission = "audit:view"
+)
+
+// rolePermissions lists what each staff role may do. Admins may do everything.
+var rolePermissions = map[string][]Permission{
+	RoleSupport: {PermViewCarts},
+}
+
+// IsStaffRole reports whether role gives access to the admin area.
+func IsStaffRole(role string) bool {
+	return role == RoleAdmin || role == RoleSupport
+}
+
+// IsValidRole reports whether role is one of the roles a user can have.
+func IsValidRole(role string) bool {
+	return role == RoleCustomer || IsStaffRole(role)
+}
+
+// Can reports whether role has the permission.
+func Can(role string, perm Permission) bool {
+	if role == RoleAdmin {
+		return true
+	}
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
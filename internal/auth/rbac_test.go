@@ -0,0 +1,35 @@
+package auth_test
+
+import (
+	"interview/internal/auth"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCan(t *testing.T) {
+	tests := []struct {
+		role string
+		perm auth.Permission
+		want bool
+	}{
+		{auth.RoleAdmin, auth.PermManageProducts, true},
+		{auth.RoleAdmin, auth.PermIssueRefunds, true},
+		{auth.RoleSupport, auth.PermViewCarts, true},
+		{auth.RoleSupport, auth.PermManageCarts, false},
+		{auth.RoleSupport, auth.PermManageProducts, false},
+		{auth.RoleSupport, auth.PermIssueRefunds, false},
+		{auth.RoleCustomer, auth.PermViewCarts, false},
+		{"", auth.PermViewCarts, false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, auth.Can(tt.role, tt.perm), "%s %s", tt.role, tt.perm)
+	}
+}
+
+func TestRoles(t *testing.T) {
+	assert.True(t, auth.IsStaffRole(auth.RoleSupport))
+	assert.False(t, auth.IsStaffRole(auth.RoleCustomer))
+	assert.True(t, auth.IsValidRole(auth.RoleCustomer))
+	assert.False(t, auth.IsValidRole("owner"))
+}
@@ -0,0 +1,179 @@
+// Package auth issues and validates the JSON Web Tokens used by the JSON API and implements
+// the OAuth2 social login providers, emailed login links and the roles and permissions of staff members.
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// DefaultAccessTTL is how long an access token stays valid
+	DefaultAccessTTL = 15 * time.Minute
+	// DefaultRefreshTTL is how long a refresh token can be exchanged for a new token pair
+	DefaultRefreshTTL = 7 * 24 * time.Hour
+)
+
+// TokenType distinguishes access tokens from refresh tokens.
+type TokenType string
+
+const (
+	// TokenTypeAccess is presented on every API request
+	TokenTypeAccess TokenType = "access"
+	// TokenTypeRefresh is only accepted by the refresh endpoint
+	TokenTypeRefresh TokenType = "refresh"
+)
+
+// ErrInvalidToken is returned when a token is malformed, expired, signed with an unknown key or of the wrong type.
+var ErrInvalidToken = errors.New("invalid token")
+
+type (
+	// Key is a named HMAC secret used to sign tokens.
+	Key struct {
+		ID     string
+		Secret []byte
+	}
+
+	// KeySet holds the signing keys. The first key signs new tokens; the remaining keys are only
+	// used for verification, which allows rotating keys without invalidating issued tokens.
+	KeySet struct {
+		keys []Key
+	}
+
+	// Claims are the JWT claims carried by API tokens. The subject is the cart session ID.
+	Claims struct {
+		jwt.RegisteredClaims
+		Type TokenType `json:"typ"`
+	}
+
+	// TokenPair is returned to API clients after login or refresh.
+	TokenPair struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+
+	// Issuer signs and verifies tokens with a KeySet.
+	Issuer struct {
+		keys       *KeySet
+		AccessTTL  time.Duration
+		RefreshTTL time.Duration
+		now        func() time.Time
+	}
+)
+
+// ParseKeySet parses a comma-separated list of "kid:secret" pairs, e.g. "2024-06:s3cret,2024-01:old".
+func ParseKeySet(spec string) (*KeySet, error) {
+	ks := &KeySet{}
+	seen := map[string]bool{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, secret, ok := strings.Cut(entry, ":")
+		if !ok || id == "" || secret == "" {
+			return nil, fmt.Errorf("key %q must have the form kid:secret", entry)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("duplicate key id %q", id)
+		}
+		seen[id] = true
+		ks.keys = append(ks.keys, Key{ID: id, Secret: []byte(secret)})
+	}
+	if len(ks.keys) == 0 {
+		return nil, errors.New("at least one signing key is required")
+	}
+	return ks, nil
+}
+
+// Active returns the key used to sign new tokens.
+func (ks *KeySet) Active() Key {
+	return ks.keys[0]
+}
+
+// Lookup returns the key with the given ID.
+func (ks *KeySet) Lookup(id string) (Key, bool) {
+	for _, k := range ks.keys {
+		if k.ID == id {
+			return k, true
+		}
+	}
+	return Key{}, false
+}
+
+// NewIssuer creates an Issuer with the default token lifetimes.
+func NewIssuer(keys *KeySet) *Issuer {
+	return &Issuer{
+		keys:       keys,
+		AccessTTL:  DefaultAccessTTL,
+		RefreshTTL: DefaultRefreshTTL,
+		now:        time.Now,
+	}
+}
+
+// Issue creates a new access/refresh token pair for the given subject.
+func (i *Issuer) Issue(subject string) (*TokenPair, error) {
+	access, err := i.sign(subject, TokenTypeAccess, i.AccessTTL)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := i.sign(subject, TokenTypeRefresh, i.RefreshTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(i.AccessTTL.Seconds()),
+	}, nil
+}
+
+func (i *Issuer) sign(subject string, typ TokenType, ttl time.Duration) (string, error) {
+	now := i.now()
+	key := i.keys.Active()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+		Type: typ,
+	})
+	token.Header["kid"] = key.ID
+
+	signed, err := token.SignedString(key.Secret)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	return signed, nil
+}
+
+// Verify parses the token, checks its signature, expiry and type, and returns its claims.
+func (i *Issuer) Verify(tokenString string, typ TokenType) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, ok := i.keys.Lookup(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		return key.Secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithTimeFunc(i.now),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if claims.Type != typ || claims.Subject == "" {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
@@ -0,0 +1,96 @@
+package auth_test
+
+import (
+	"interview/internal/auth"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKeySet(t *testing.T) {
+	t.Run("parses multiple keys with the first one active", func(t *testing.T) {
+		ks, err := auth.ParseKeySet("new:secret-1, old:secret-2")
+		require.NoError(t, err)
+		assert.Equal(t, "new", ks.Active().ID)
+
+		key, ok := ks.Lookup("old")
+		require.True(t, ok)
+		assert.Equal(t, []byte("secret-2"), key.Secret)
+	})
+
+	t.Run("rejects malformed entries", func(t *testing.T) {
+		_, err := auth.ParseKeySet("missing-secret")
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects duplicate key ids", func(t *testing.T) {
+		_, err := auth.ParseKeySet("a:one,a:two")
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects empty spec", func(t *testing.T) {
+		_, err := auth.ParseKeySet("")
+		assert.Error(t, err)
+	})
+}
+
+func TestIssuer(t *testing.T) {
+	keys, err := auth.ParseKeySet("k1:first-secret")
+	require.NoError(t, err)
+	issuer := auth.NewIssuer(keys)
+
+	t.Run("issued access token verifies", func(t *testing.T) {
+		pair, err := issuer.Issue("session-1")
+		require.NoError(t, err)
+		assert.Equal(t, "Bearer", pair.TokenType)
+		assert.Equal(t, int(auth.DefaultAccessTTL.Seconds()), pair.ExpiresIn)
+
+		claims, err := issuer.Verify(pair.AccessToken, auth.TokenTypeAccess)
+		require.NoError(t, err)
+		assert.Equal(t, "session-1", claims.Subject)
+	})
+
+	t.Run("token types are not interchangeable", func(t *testing.T) {
+		pair, err := issuer.Issue("session-1")
+		require.NoError(t, err)
+
+		_, err = issuer.Verify(pair.RefreshToken, auth.TokenTypeAccess)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+		_, err = issuer.Verify(pair.AccessToken, auth.TokenTypeRefresh)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	})
+
+	t.Run("expired token is rejected", func(t *testing.T) {
+		shortLived := auth.NewIssuer(keys)
+		shortLived.AccessTTL = -time.Minute
+
+		pair, err := shortLived.Issue("session-1")
+		require.NoError(t, err)
+
+		_, err = issuer.Verify(pair.AccessToken, auth.TokenTypeAccess)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	})
+
+	t.Run("tokens signed with a rotated-out key still verify", func(t *testing.T) {
+		pair, err := issuer.Issue("session-1")
+		require.NoError(t, err)
+
+		rotated, err := auth.ParseKeySet("k2:second-secret,k1:first-secret")
+		require.NoError(t, err)
+		claims, err := auth.NewIssuer(rotated).Verify(pair.AccessToken, auth.TokenTypeAccess)
+		require.NoError(t, err)
+		assert.Equal(t, "session-1", claims.Subject)
+	})
+
+	t.Run("tokens signed with an unknown key are rejected", func(t *testing.T) {
+		pair, err := issuer.Issue("session-1")
+		require.NoError(t, err)
+
+		other, err := auth.ParseKeySet("k1:different-secret")
+		require.NoError(t, err)
+		_, err = auth.NewIssuer(other).Verify(pair.AccessToken, auth.TokenTypeAccess)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	})
+}
@@ -0,0 +1,72 @@
+package auth_test
+
+import (
+	"interview/internal/auth"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the base32 encoding of the "12345678901234567890" secret of the RFC 6238 test vectors
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		code, err := auth.TOTPCode(rfcSecret, time.Unix(tt.unix, 0))
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, code, tt.unix)
+	}
+
+	_, err := auth.TOTPCode("not base32!", time.Now())
+	assert.Error(t, err)
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	secret, err := auth.GenerateTOTPSecret()
+	require.NoError(t, err)
+	now := time.Now()
+	code, err := auth.TOTPCode(secret, now)
+	require.NoError(t, err)
+
+	assert.True(t, auth.VerifyTOTP(secret, code, now))
+	assert.True(t, auth.VerifyTOTP(secret, code[:3]+" "+code[3:], now), "spaces are ignored")
+	assert.True(t, auth.VerifyTOTP(secret, code, now.Add(30*time.Second)), "clock drift of one period is allowed")
+	assert.False(t, auth.VerifyTOTP(secret, code, now.Add(2*time.Minute)))
+	assert.False(t, auth.VerifyTOTP(secret, "", now))
+	assert.False(t, auth.VerifyTOTP(rfcSecret, code, now))
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	u, err := url.Parse(auth.TOTPProvisioningURI("Shopping Cart", "jane@example.com", rfcSecret))
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, "totp", u.Host)
+	assert.Equal(t, "/Shopping Cart:jane@example.com", u.Path)
+	assert.Equal(t, rfcSecret, u.Query().Get("secret"))
+	assert.Equal(t, "Shopping Cart", u.Query().Get("issuer"))
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := auth.GenerateRecoveryCodes(auth.RecoveryCodeCount)
+	require.NoError(t, err)
+	require.Len(t, codes, auth.RecoveryCodeCount)
+	assert.NotEqual(t, codes[0], codes[1])
+	assert.Len(t, codes[0], 11)
+
+	hash := auth.HashRecoveryCode(codes[0])
+	assert.Equal(t, hash, auth.HashRecoveryCode(" "+strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))))
+	assert.NotEqual(t, hash, auth.HashRecoveryCode(codes[1]))
+}
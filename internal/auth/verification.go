@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VerifyEmailPath is the path of the endpoint verifying the email address of an account
+const VerifyEmailPath = "/account/email/verify"
+
+// ErrExpiredLink is returned for verification links that were genuine but expired, so a new one can be offered
+var ErrExpiredLink = errors.New("link expired")
+
+// VerificationLinks builds and verifies signed links proving that a user receives email at the address
+// of their account.
+type VerificationLinks struct {
+	baseURL string
+	secret  []byte
+	ttl     time.Duration
+	now     func() time.Time
+}
+
+// NewVerificationLinks creates links to baseURL signed with secret and valid for ttl.
+func NewVerificationLinks(baseURL string, secret []byte, ttl time.Duration) *VerificationLinks {
+	return &VerificationLinks{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		secret:  secret,
+		ttl:     ttl,
+		now:     time.Now,
+	}
+}
+
+// TTL returns how long links stay valid.
+func (l *VerificationLinks) TTL() time.Duration {
+	return l.ttl
+}
+
+// URL returns a link verifying that the user with the given ID receives email at email.
+func (l *VerificationLinks) URL(userID uint, email string) string {
+	expires := l.now().Add(l.ttl).Unix()
+	query := url.Values{}
+	query.Set("user", strconv.FormatUint(uint64(userID), 10))
+	query.Set("email", email)
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", signLink(l.secret, "email-verification", userID, email, expires))
+	return l.baseURL + VerifyEmailPath + "?" + query.Encode()
+}
+
+// Verify checks the query of a link and returns the user and the email address it was sent to. Malformed
+// and forged links fail with ErrInvalidToken, expired ones with ErrExpiredLink.
+func (l *VerificationLinks) Verify(query url.Values) (uint, string, error) {
+	userID, err := strconv.ParseUint(query.Get("user"), 10, 64)
+	if err != nil {
+		return 0, "", ErrInvalidToken
+	}
+	email := query.Get("email")
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || email == "" {
+		return 0, "", ErrInvalidToken
+	}
+	signature := signLink(l.secret, "email-verification", uint(userID), email, expires)
+	if !hmac.Equal([]byte(query.Get("signature")), []byte(signature)) {
+		return 0, "", ErrInvalidToken
+	}
+	if l.now().Unix() > expires {
+		return uint(userID), email, ErrExpiredLink
+	}
+	return uint(userID), email, nil
+}
@@ -0,0 +1,55 @@
+package auth_test
+
+import (
+	"interview/internal/auth"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerificationLinks(t *testing.T) {
+	links := auth.NewVerificationLinks("https://shop.example.com/", []byte("secret"), time.Hour)
+
+	parse := func(t *testing.T, link string) url.Values {
+		u, err := url.Parse(link)
+		require.NoError(t, err)
+		assert.Equal(t, auth.VerifyEmailPath, u.Path)
+		return u.Query()
+	}
+
+	t.Run("valid link", func(t *testing.T) {
+		userID, email, err := links.Verify(parse(t, links.URL(7, "jane@example.com")))
+		require.NoError(t, err)
+		assert.Equal(t, uint(7), userID)
+		assert.Equal(t, "jane@example.com", email)
+	})
+
+	t.Run("tampered email", func(t *testing.T) {
+		query := parse(t, links.URL(7, "jane@example.com"))
+		query.Set("email", "evil@example.com")
+		_, _, err := links.Verify(query)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	})
+
+	t.Run("email change links are no verification links", func(t *testing.T) {
+		changes := auth.NewEmailChangeLinks("https://shop.example.com", []byte("secret"), time.Hour)
+		u, err := url.Parse(changes.URL(7, "jane@example.com"))
+		require.NoError(t, err)
+		_, _, err = links.Verify(u.Query())
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	})
+
+	t.Run("expired link", func(t *testing.T) {
+		expired := auth.NewVerificationLinks("https://shop.example.com", []byte("secret"), -time.Minute)
+		userID, _, err := expired.Verify(parse(t, expired.URL(7, "jane@example.com")))
+		assert.ErrorIs(t, err, auth.ErrExpiredLink)
+		assert.Equal(t, uint(7), userID, "expired links still tell whose they were")
+
+		expired = auth.NewVerificationLinks("https://shop.example.com", []byte("other"), -time.Minute)
+		_, _, err = links.Verify(parse(t, expired.URL(7, "jane@example.com")))
+		assert.ErrorIs(t, err, auth.ErrInvalidToken, "forged links aren't reported as expired")
+	})
+}
@@ -0,0 +1,177 @@
+// Package botcheck keeps simple bots from submitting forms. Protected forms carry the Fields of a
+// Checker: a honeypot input hidden from people, which bots filling in every input give away, and a
+// signed token of when the form was shown, as people take a moment to submit a form while bots post
+// it right away or without loading it at all. An hCaptcha challenge can be added on top.
+package botcheck
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"expvar"
+	"fmt"
+	"html/template"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// HoneypotField is the name of the input people don't see and leave empty
+	HoneypotField = "website"
+	// TokenField is the name of the input holding when the form was shown
+	TokenField = "form_token"
+	// CaptchaField is the name of the input the hCaptcha widget puts the response of the challenge in
+	CaptchaField = "h-captcha-response"
+
+	// tokenTTL is how long a shown form can be submitted, so bots can't reuse one token forever
+	tokenTTL = 24 * time.Hour
+)
+
+var (
+	// ErrHoneypot is returned when the honeypot input was filled in
+	ErrHoneypot = errors.New("honeypot field was filled in")
+	// ErrTooFast is returned when the form was submitted sooner after it was shown than people manage
+	ErrTooFast = errors.New("form was submitted too fast")
+	// ErrInvalidToken is returned when the token is missing, forged or expired
+	ErrInvalidToken = errors.New("form token is invalid")
+	// ErrCaptcha is returned when the hCaptcha challenge wasn't solved
+	ErrCaptcha = errors.New("captcha was not solved")
+)
+
+// blocked counts the blocked submissions by the check they failed
+var blocked = expvar.NewMap("bot_submissions_blocked")
+
+type (
+	// Submission is what a submitted form tells about its sender.
+	Submission struct {
+		Honeypot string
+		Token    string
+		// Captcha is the response of the hCaptcha challenge, empty without one
+		Captcha  string
+		RemoteIP string
+	}
+
+	// Verifier verifies the responses of captcha challenges.
+	Verifier interface {
+		// Verify returns ErrCaptcha when the response doesn't solve a challenge, and other errors when it
+		// can't tell.
+		Verify(ctx context.Context, response, remoteIP string) error
+	}
+
+	// Checker adds its fields to forms and checks the submissions of the forms.
+	Checker struct {
+		secret      []byte
+		minFillTime time.Duration
+		now         func() time.Time
+		// siteKey and captcha add an hCaptcha challenge to the forms, empty and nil for none
+		siteKey string
+		captcha Verifier
+	}
+)
+
+// New creates a Checker signing its tokens with secret and blocking forms submitted sooner than
+// minFillTime after they were shown. A minFillTime of 0 only blocks forged and expired tokens.
+func New(secret []byte, minFillTime time.Duration) *Checker {
+	return &Checker{secret: secret, minFillTime: minFillTime, now: time.Now}
+}
+
+// SetClock replaces the clock of the checker, for tests.
+func (c *Checker) SetClock(now func() time.Time) {
+	c.now = now
+}
+
+// SetCaptcha adds the hCaptcha challenge of the site key to the forms, verifying the responses with
+// verifier.
+func (c *Checker) SetCaptcha(siteKey string, verifier Verifier) {
+	c.siteKey = siteKey
+	c.captcha = verifier
+}
+
+// Token returns the token of a form shown now.
+func (c *Checker) Token() string {
+	shown := c.now().UnixMilli()
+	return strconv.FormatInt(shown, 10) + "." + c.sign(shown)
+}
+
+// TokenWindow numbers the halves of the token lifetime, so pages caching the fields of a form can be
+// renewed whenever it changes, long before their token expires.
+func (c *Checker) TokenWindow() int64 {
+	return c.now().UnixNano() / int64(tokenTTL/2)
+}
+
+// Fields returns the inputs to add to a protected form.
+func (c *Checker) Fields() template.HTML {
+	fields := fmt.Sprintf(`<div aria-hidden="true" style="position: absolute; left: -10000px;">`+
+		`<label>Leave this field empty <input type="text" name="%s" tabindex="-1" autocomplete="off"></label>`+
+		`</div><input type="hidden" name="%s" value="%s">`,
+		HoneypotField, TokenField, c.Token())
+	if c.siteKey != "" {
+		fields += fmt.Sprintf(`<div class="h-captcha" data-sitekey="%s"></div>`, template.HTMLEscapeString(c.siteKey))
+	}
+	return template.HTML(fields)
+}
+
+// Script returns the script tag of the hCaptcha widget for pages with protected forms, empty without
+// a challenge.
+func (c *Checker) Script() template.HTML {
+	if c.siteKey == "" {
+		return ""
+	}
+	return `<script src="https://js.hcaptcha.com/1/api.js" async defer></script>`
+}
+
+// Check returns an error when the submission looks like a bot's, counting it by the check it failed.
+// When the captcha can't be verified the submission is let through, so an outage of hCaptcha doesn't
+// stop people from shopping.
+func (c *Checker) Check(ctx context.Context, form Submission) error {
+	err := c.check(ctx, form)
+	switch {
+	case errors.Is(err, ErrHoneypot):
+		blocked.Add("honeypot", 1)
+	case errors.Is(err, ErrInvalidToken):
+		blocked.Add("invalid_token", 1)
+	case errors.Is(err, ErrTooFast):
+		blocked.Add("too_fast", 1)
+	case errors.Is(err, ErrCaptcha):
+		blocked.Add("captcha", 1)
+	case err != nil:
+		log.Printf("Failed to verify captcha: %v", err)
+		return nil
+	}
+	return err
+}
+
+func (c *Checker) check(ctx context.Context, form Submission) error {
+	if form.Honeypot != "" {
+		return ErrHoneypot
+	}
+	value, signature, _ := strings.Cut(form.Token, ".")
+	shown, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || !hmac.Equal([]byte(signature), []byte(c.sign(shown))) {
+		return ErrInvalidToken
+	}
+	elapsed := c.now().Sub(time.UnixMilli(shown))
+	if elapsed > tokenTTL {
+		return ErrInvalidToken
+	}
+	if elapsed < c.minFillTime {
+		return ErrTooFast
+	}
+	if c.captcha != nil {
+		if form.Captcha == "" {
+			return ErrCaptcha
+		}
+		return c.captcha.Verify(ctx, form.Captcha, form.RemoteIP)
+	}
+	return nil
+}
+
+func (c *Checker) sign(shown int64) string {
+	mac := hmac.New(sha256.New, c.secret)
+	fmt.Fprintf(mac, "botcheck:%d", shown)
+	return hex.EncodeToString(mac.Sum(nil))
+}
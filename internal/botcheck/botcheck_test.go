@@ -0,0 +1,146 @@
+package botcheck_test
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"interview/internal/botcheck"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// verifierFunc adapts a function to botcheck.Verifier
+type verifierFunc func(response string) error
+
+func (f verifierFunc) Verify(_ context.Context, response, _ string) error {
+	return f(response)
+}
+
+func TestChecker(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	checker := botcheck.New([]byte("secret"), 2*time.Second)
+	checker.SetClock(func() time.Time { return now })
+	shown := checker.Token()
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		form    botcheck.Submission
+		wantErr error
+	}{
+		{"Person", 5 * time.Second, botcheck.Submission{Token: shown}, nil},
+		{"Filled Honeypot", 5 * time.Second, botcheck.Submission{Token: shown, Honeypot: "https://spam.example"}, botcheck.ErrHoneypot},
+		{"Too Fast", time.Second, botcheck.Submission{Token: shown}, botcheck.ErrTooFast},
+		{"Missing Token", 5 * time.Second, botcheck.Submission{}, botcheck.ErrInvalidToken},
+		{"Forged Token", 5 * time.Second, botcheck.Submission{Token: shown[:len(shown)-1] + "0"}, botcheck.ErrInvalidToken},
+		{"Expired Token", 25 * time.Hour, botcheck.Submission{Token: shown}, botcheck.ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker.SetClock(func() time.Time { return now.Add(tt.elapsed) })
+			err := checker.Check(ctx, tt.form)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("Counts Blocked Submissions", func(t *testing.T) {
+		counts := expvar.Get("bot_submissions_blocked").(*expvar.Map)
+		assert.Equal(t, "1", counts.Get("honeypot").String())
+		assert.Equal(t, "1", counts.Get("too_fast").String())
+		assert.Equal(t, "3", counts.Get("invalid_token").String())
+	})
+
+	t.Run("Fields", func(t *testing.T) {
+		checker.SetClock(func() time.Time { return now })
+		fields := string(checker.Fields())
+		assert.Contains(t, fields, `name="website"`)
+		assert.Contains(t, fields, `name="form_token" value="`+shown+`"`)
+		assert.NotContains(t, fields, "h-captcha")
+		assert.Empty(t, checker.Script())
+	})
+
+	t.Run("Token Window", func(t *testing.T) {
+		checker.SetClock(func() time.Time { return now })
+		window := checker.TokenWindow()
+		checker.SetClock(func() time.Time { return now.Add(12 * time.Hour) })
+		assert.Equal(t, window+1, checker.TokenWindow())
+	})
+}
+
+func TestCaptcha(t *testing.T) {
+	now := time.Now()
+	checker := botcheck.New([]byte("secret"), 0)
+	checker.SetClock(func() time.Time { return now })
+	checker.SetCaptcha("site-key", verifierFunc(func(response string) error {
+		switch response {
+		case "solved":
+			return nil
+		case "outage":
+			return errors.New("hcaptcha returned status 503")
+		}
+		return botcheck.ErrCaptcha
+	}))
+	token := checker.Token()
+	ctx := context.Background()
+
+	t.Run("Widget", func(t *testing.T) {
+		assert.Contains(t, checker.Fields(), `<div class="h-captcha" data-sitekey="site-key"></div>`)
+		assert.Contains(t, checker.Script(), "https://js.hcaptcha.com/1/api.js")
+	})
+
+	t.Run("Solved", func(t *testing.T) {
+		assert.NoError(t, checker.Check(ctx, botcheck.Submission{Token: token, Captcha: "solved"}))
+	})
+
+	t.Run("Unsolved", func(t *testing.T) {
+		assert.ErrorIs(t, checker.Check(ctx, botcheck.Submission{Token: token}), botcheck.ErrCaptcha)
+		assert.ErrorIs(t, checker.Check(ctx, botcheck.Submission{Token: token, Captcha: "wrong"}), botcheck.ErrCaptcha)
+	})
+
+	t.Run("Outage Lets People Through", func(t *testing.T) {
+		assert.NoError(t, checker.Check(ctx, botcheck.Submission{Token: token, Captcha: "outage"}))
+	})
+}
+
+func TestHCaptcha(t *testing.T) {
+	response := `{"success": true}`
+	status := http.StatusOK
+	var received map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		received = map[string]string{"secret": r.PostForm.Get("secret"), "response": r.PostForm.Get("response"), "remoteip": r.PostForm.Get("remoteip")}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(response))
+	}))
+	defer server.Close()
+	verifier := botcheck.NewHCaptcha("captcha-secret")
+	verifier.URL = server.URL
+	ctx := context.Background()
+
+	t.Run("Solved Challenge", func(t *testing.T) {
+		require.NoError(t, verifier.Verify(ctx, "token", "192.0.2.1"))
+		assert.Equal(t, map[string]string{"secret": "captcha-secret", "response": "token", "remoteip": "192.0.2.1"}, received)
+	})
+
+	t.Run("Unsolved Challenge", func(t *testing.T) {
+		response = `{"success": false, "error-codes": ["invalid-input-response"]}`
+		assert.ErrorIs(t, verifier.Verify(ctx, "token", ""), botcheck.ErrCaptcha)
+	})
+
+	t.Run("Fails On Errors", func(t *testing.T) {
+		status = http.StatusServiceUnavailable
+		err := verifier.Verify(ctx, "token", "")
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, botcheck.ErrCaptcha)
+	})
+}
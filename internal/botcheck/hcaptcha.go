@@ -0,0 +1,69 @@
+package botcheck
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// HCaptchaVerifyURL is where hCaptcha verifies the responses of its challenges
+const HCaptchaVerifyURL = "https://api.hcaptcha.com/siteverify"
+
+// HCaptchaCSPSources are the sources the Content-Security-Policy has to allow scripts, frames, styles
+// and connections from for the hCaptcha widget
+const HCaptchaCSPSources = "https://hcaptcha.com https://*.hcaptcha.com"
+
+// HCaptcha verifies hCaptcha responses with the secret of the site.
+type HCaptcha struct {
+	Secret string
+	URL    string
+	Client *http.Client
+}
+
+// NewHCaptcha creates an HCaptcha with a client that times out after a few seconds.
+func NewHCaptcha(secret string) *HCaptcha {
+	return &HCaptcha{
+		Secret: secret,
+		URL:    HCaptchaVerifyURL,
+		Client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Verify implements Verifier.
+func (h *HCaptcha) Verify(ctx context.Context, response, remoteIP string) error {
+	form := url.Values{"secret": {h.Secret}, "response": {response}}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("hcaptcha request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("hcaptcha returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Success    bool     `json:"success"`
+		ErrorCodes []string `json:"error-codes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode hcaptcha response: %w", err)
+	}
+	if !result.Success {
+		return fmt.Errorf("%w: %s", ErrCaptcha, strings.Join(result.ErrorCodes, ", "))
+	}
+	return nil
+}
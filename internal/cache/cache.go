@@ -0,0 +1,143 @@
+// Package cache implements the shared cache-aside layer in front of product and price lookups. Values
+// are kept as JSON in a Store, usually Redis, so every instance of the application shares them.
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	// hits, misses and failures count the lookups of each kind of value, shown at /admin/metrics
+	hits     = expvar.NewMap("cache_hits")
+	misses   = expvar.NewMap("cache_misses")
+	failures = expvar.NewMap("cache_errors")
+)
+
+type (
+	// Store keeps values under keys, each until its own TTL passes
+	Store interface {
+		// Get returns the value of the key; ok is false when the key is missing or expired
+		Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+		// Set stores the value under the key for ttl
+		Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+		// Delete removes the keys, ignoring missing ones
+		Delete(ctx context.Context, keys ...string) error
+	}
+
+	// Cache keeps values of one kind, e.g. products, in a Store under "<kind>:<id>" keys for TTL. The
+	// Store is an optimization: its failures are logged and counted, and lookups fall back to the source.
+	Cache struct {
+		store Store
+		kind  string
+		ttl   time.Duration
+	}
+
+	// Memory is a Store in the memory of the process, for single instances and tests
+	Memory struct {
+		mu      sync.Mutex
+		entries map[string]memoryEntry
+		now     func() time.Time
+	}
+
+	memoryEntry struct {
+		value     []byte
+		expiresAt time.Time
+	}
+)
+
+// New creates a Cache of the kind of values keeping them in the store for ttl.
+func New(store Store, kind string, ttl time.Duration) *Cache {
+	return &Cache{store: store, kind: kind, ttl: ttl}
+}
+
+// Get decodes the cached value of the ID into dest and reports whether it was cached.
+func (c *Cache) Get(ctx context.Context, id string, dest interface{}) bool {
+	data, ok, err := c.store.Get(ctx, c.key(id))
+	if err == nil && ok {
+		err = json.Unmarshal(data, dest)
+	}
+	switch {
+	case err != nil:
+		failures.Add(c.kind, 1)
+		log.Printf("Failed to read %s %s from the cache: %v", c.kind, id, err)
+		return false
+	case !ok:
+		misses.Add(c.kind, 1)
+		return false
+	}
+	hits.Add(c.kind, 1)
+	return true
+}
+
+// Set caches the value of the ID.
+func (c *Cache) Set(ctx context.Context, id string, value interface{}) {
+	data, err := json.Marshal(value)
+	if err == nil {
+		err = c.store.Set(ctx, c.key(id), data, c.ttl)
+	}
+	if err != nil {
+		failures.Add(c.kind, 1)
+		log.Printf("Failed to cache %s %s: %v", c.kind, id, err)
+	}
+}
+
+// Invalidate removes the values of the IDs, so the next lookups read them from the source again.
+func (c *Cache) Invalidate(ctx context.Context, ids ...string) {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = c.key(id)
+	}
+	if err := c.store.Delete(ctx, keys...); err != nil {
+		failures.Add(c.kind, 1)
+		log.Printf("Failed to invalidate cached %s %v: %v", c.kind, ids, err)
+	}
+}
+
+func (c *Cache) key(id string) string {
+	return c.kind + ":" + id
+}
+
+// NewMemory creates an empty Memory store.
+func NewMemory() *Memory {
+	return &Memory{entries: map[string]memoryEntry{}, now: time.Now}
+}
+
+// SetClock replaces the clock entries expire by, for tests.
+func (m *Memory) SetClock(now func() time.Time) {
+	m.now = now
+}
+
+// Get implements Store.
+func (m *Memory) Get(_ context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.entries[key]
+	if !ok || !m.now().Before(entry.expiresAt) {
+		delete(m.entries, key)
+		return nil, false, nil
+	}
+	return entry.value, true, nil
+}
+
+// Set implements Store.
+func (m *Memory) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = memoryEntry{value: value, expiresAt: m.now().Add(ttl)}
+	return nil
+}
+
+// Delete implements Store.
+func (m *Memory) Delete(_ context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range keys {
+		delete(m.entries, key)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"interview/internal/cache"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingStore is a Store whose server is down
+type failingStore struct{}
+
+func (failingStore) Get(context.Context, string) ([]byte, bool, error) {
+	return nil, false, errors.New("connection refused")
+}
+
+func (failingStore) Set(context.Context, string, []byte, time.Duration) error {
+	return errors.New("connection refused")
+}
+
+func (failingStore) Delete(context.Context, ...string) error {
+	return errors.New("connection refused")
+}
+
+// counter returns the metric of the kind of values
+func counter(t *testing.T, metric, kind string) int64 {
+	t.Helper()
+	value, ok := expvar.Get(metric).(*expvar.Map).Get(kind).(*expvar.Int)
+	if !ok {
+		return 0
+	}
+	return value.Value()
+}
+
+type product struct {
+	Name  string
+	Price float64
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	store := cache.NewMemory()
+	store.SetClock(func() time.Time { return now })
+	products := cache.New(store, "test-product", time.Minute)
+
+	t.Run("Miss Then Hit", func(t *testing.T) {
+		var p product
+		assert.False(t, products.Get(ctx, "1", &p))
+		assert.Equal(t, int64(1), counter(t, "cache_misses", "test-product"))
+
+		products.Set(ctx, "1", product{Name: "shoe", Price: 10})
+		require.True(t, products.Get(ctx, "1", &p))
+		assert.Equal(t, product{Name: "shoe", Price: 10}, p)
+		assert.Equal(t, int64(1), counter(t, "cache_hits", "test-product"))
+
+		value, ok, err := store.Get(ctx, "test-product:1")
+		require.NoError(t, err)
+		assert.True(t, ok, "values are kept under the kind and ID")
+		assert.JSONEq(t, `{"Name": "shoe", "Price": 10}`, string(value))
+	})
+
+	t.Run("Entries Expire", func(t *testing.T) {
+		now = now.Add(time.Minute)
+		var p product
+		assert.False(t, products.Get(ctx, "1", &p))
+	})
+
+	t.Run("Invalidate", func(t *testing.T) {
+		products.Set(ctx, "2", product{Name: "bag"})
+		products.Invalidate(ctx, "2", "3")
+		var p product
+		assert.False(t, products.Get(ctx, "2", &p))
+	})
+
+	t.Run("Store Failures Are Misses", func(t *testing.T) {
+		broken := cache.New(failingStore{}, "test-broken", time.Minute)
+		var p product
+		assert.False(t, broken.Get(ctx, "1", &p))
+		broken.Set(ctx, "1", product{Name: "shoe"})
+		broken.Invalidate(ctx, "1")
+		assert.Equal(t, int64(3), counter(t, "cache_errors", "test-broken"))
+		assert.Zero(t, counter(t, "cache_misses", "test-broken"))
+	})
+}
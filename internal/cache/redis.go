@@ -0,0 +1,241 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// redisPoolSize is the most idle connections kept open
+	redisPoolSize = 8
+	// redisTimeout bounds dialing and each command without a sooner context deadline
+	redisTimeout = 2 * time.Second
+)
+
+type (
+	// Redis implements Store on a Redis server through the few commands it needs, speaking the RESP
+	// protocol over a small pool of connections.
+	Redis struct {
+		addr     string
+		username string
+		password string
+		db       int
+		tls      *tls.Config
+		pool     chan *redisConn
+	}
+
+	redisConn struct {
+		conn   net.Conn
+		reader *bufio.Reader
+	}
+
+	// redisError is an error reply of the server
+	redisError string
+)
+
+var _ Store = (*Redis)(nil)
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// NewRedis creates a client for the server at the URL, redis://[[user]:password@]host[:port][/db], or
+// rediss:// for TLS. Connections are opened when needed.
+func NewRedis(rawURL string) (*Redis, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+	r := &Redis{addr: u.Host, pool: make(chan *redisConn, redisPoolSize)}
+	switch u.Scheme {
+	case "redis":
+	case "rediss":
+		r.tls = &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}
+	default:
+		return nil, errors.New("redis URL must start with redis:// or rediss://")
+	}
+	if u.Hostname() == "" {
+		return nil, errors.New("redis URL needs a host")
+	}
+	if u.Port() == "" {
+		r.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		r.username = u.User.Username()
+		r.password, _ = u.User.Password()
+	}
+	if path := strings.Trim(u.Path, "/"); path != "" {
+		if r.db, err = strconv.Atoi(path); err != nil || r.db < 0 {
+			return nil, fmt.Errorf("invalid redis database %q", path)
+		}
+	}
+	return r, nil
+}
+
+// Get implements Store.
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := r.do(ctx, "GET", key)
+	if err != nil {
+		return nil, false, err
+	}
+	if reply == nil {
+		return nil, false, nil
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("redis: unexpected reply %v to GET", reply)
+	}
+	return value, true, nil
+}
+
+// Set implements Store.
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := r.do(ctx, "SET", key, string(value), "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	return err
+}
+
+// Delete implements Store.
+func (r *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := r.do(ctx, append([]string{"DEL"}, keys...)...)
+	return err
+}
+
+// Close closes the idle connections.
+func (r *Redis) Close() error {
+	for {
+		select {
+		case c := <-r.pool:
+			c.conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// do sends the command and returns its reply: nil, a string, an int64 or a []byte. Connections are
+// returned to the pool unless the command failed with something other than an error reply.
+func (r *Redis) do(ctx context.Context, args ...string) (interface{}, error) {
+	c, err := r.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := c.roundTrip(ctx, args)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		c.conn.Close()
+		return nil, err
+	}
+	select {
+	case r.pool <- c:
+	default:
+		c.conn.Close()
+	}
+	return reply, err
+}
+
+// conn takes an idle connection from the pool or opens one, authenticating and selecting the database.
+func (r *Redis) conn(ctx context.Context) (*redisConn, error) {
+	select {
+	case c := <-r.pool:
+		return c, nil
+	default:
+	}
+
+	dialer := &net.Dialer{Timeout: redisTimeout}
+	var conn net.Conn
+	var err error
+	if r.tls != nil {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: r.tls}).DialContext(ctx, "tcp", r.addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", r.addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redis: failed to connect: %w", err)
+	}
+	c := &redisConn{conn: conn, reader: bufio.NewReader(conn)}
+
+	var setup [][]string
+	if r.password != "" && r.username != "" {
+		setup = append(setup, []string{"AUTH", r.username, r.password})
+	} else if r.password != "" {
+		setup = append(setup, []string{"AUTH", r.password})
+	}
+	if r.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(r.db)})
+	}
+	for _, args := range setup {
+		if _, err := c.roundTrip(ctx, args); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("redis: %s failed: %w", args[0], err)
+		}
+	}
+	return c, nil
+}
+
+// roundTrip writes the command as an array of bulk strings and reads the reply.
+func (c *redisConn) roundTrip(ctx context.Context, args []string) (interface{}, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok || time.Until(deadline) > redisTimeout {
+		deadline = time.Now().Add(redisTimeout)
+	}
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	var cmd strings.Builder
+	fmt.Fprintf(&cmd, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&cmd, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.conn, cmd.String()); err != nil {
+		return nil, fmt.Errorf("redis: failed to send command: %w", err)
+	}
+	return c.readReply()
+}
+
+// readReply reads a simple string, error, integer or bulk string reply.
+func (c *redisConn) readReply() (interface{}, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("redis: failed to read reply: %w", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid bulk length %q", line[1:])
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(c.reader, data); err != nil {
+			return nil, fmt.Errorf("redis: failed to read reply: %w", err)
+		}
+		return data[:size], nil
+	}
+	return nil, fmt.Errorf("redis: unsupported reply %q", line)
+}
@@ -0,0 +1,188 @@
+package cache_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"interview/internal/cache"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis answers the commands the client sends with a map, recording them
+type fakeRedis struct {
+	listener net.Listener
+	mu       sync.Mutex
+	values   map[string]string
+	commands [][]string
+	conns    int
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	f := &fakeRedis{listener: listener, values: map[string]string{}}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			f.mu.Lock()
+			f.conns++
+			f.mu.Unlock()
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.commands = append(f.commands, args)
+		var reply string
+		switch strings.ToUpper(args[0]) {
+		case "AUTH":
+			if args[len(args)-1] == "secret" {
+				reply = "+OK\r\n"
+			} else {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case "SELECT":
+			reply = "+OK\r\n"
+		case "GET":
+			if value, ok := f.values[args[1]]; ok {
+				reply = fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+			} else {
+				reply = "$-1\r\n"
+			}
+		case "SET":
+			f.values[args[1]] = args[2]
+			reply = "+OK\r\n"
+		case "DEL":
+			deleted := 0
+			for _, key := range args[1:] {
+				if _, ok := f.values[key]; ok {
+					delete(f.values, key)
+					deleted++
+				}
+			}
+			reply = fmt.Sprintf(":%d\r\n", deleted)
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+		f.mu.Unlock()
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+func (f *fakeRedis) lastCommand() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.commands[len(f.commands)-1]
+}
+
+// readCommand reads an array of bulk strings
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		if line, err = reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		args[i] = string(data[:size])
+	}
+	return args, nil
+}
+
+func TestRedis(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeRedis(t)
+
+	t.Run("Invalid URLs", func(t *testing.T) {
+		for _, rawURL := range []string{"http://localhost:6379", "redis://", "redis://localhost/abc"} {
+			_, err := cache.NewRedis(rawURL)
+			assert.Error(t, err, rawURL)
+		}
+	})
+
+	t.Run("Set Get And Delete", func(t *testing.T) {
+		store, err := cache.NewRedis("redis://:secret@" + server.listener.Addr().String() + "/2")
+		require.NoError(t, err)
+		defer store.Close()
+
+		_, ok, err := store.Get(ctx, "product:1")
+		require.NoError(t, err)
+		assert.False(t, ok)
+
+		require.NoError(t, store.Set(ctx, "product:1", []byte(`{"name":"shoe"}`), 90*time.Second))
+		assert.Equal(t, []string{"SET", "product:1", `{"name":"shoe"}`, "PX", "90000"}, server.lastCommand())
+		value, ok, err := store.Get(ctx, "product:1")
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, `{"name":"shoe"}`, string(value))
+
+		require.NoError(t, store.Delete(ctx, "product:1", "product:2"))
+		_, ok, err = store.Get(ctx, "product:1")
+		require.NoError(t, err)
+		assert.False(t, ok)
+
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		assert.Equal(t, []string{"AUTH", "secret"}, server.commands[0])
+		assert.Equal(t, []string{"SELECT", "2"}, server.commands[1])
+		assert.Equal(t, 1, server.conns, "connections are reused")
+	})
+
+	t.Run("Wrong Password", func(t *testing.T) {
+		store, err := cache.NewRedis("redis://default:wrong@" + server.listener.Addr().String())
+		require.NoError(t, err)
+		_, _, err = store.Get(ctx, "product:1")
+		assert.ErrorContains(t, err, "WRONGPASS")
+	})
+
+	t.Run("Server Down", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		addr := listener.Addr().String()
+		listener.Close()
+
+		store, err := cache.NewRedis("redis://" + addr)
+		require.NoError(t, err)
+		_, _, err = store.Get(ctx, "product:1")
+		assert.Error(t, err)
+	})
+}
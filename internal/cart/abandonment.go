@@ -0,0 +1,76 @@
+package cart
+
+import (
+	"math"
+	"time"
+)
+
+// Segments of open carts by the risk of being abandoned, for targeted campaigns
+const (
+	// SegmentLow carts are likely to be checked out
+	SegmentLow = "low"
+	// SegmentMedium carts are worth a nudge
+	SegmentMedium = "medium"
+	// SegmentHigh carts are likely to be abandoned
+	SegmentHigh = "high"
+)
+
+// Segments lists the abandonment segments from the lowest risk to the highest
+var Segments = []string{SegmentLow, SegmentMedium, SegmentHigh}
+
+const (
+	// idleRiskAfter is how long a cart idles before its idle time adds all it can to the score
+	idleRiskAfter = 48 * time.Hour
+	// valueRiskAt is the total from which a cart's value adds all it can to the score
+	valueRiskAt = 200.0
+)
+
+// AbandonmentSignals is what the abandonment score of an open cart is computed from
+type AbandonmentSignals struct {
+	// IdleFor is how long the cart hasn't changed
+	IdleFor time.Duration
+	// Total is the grand total of the cart
+	Total float64
+	// Anonymous carts belong to no logged-in user
+	Anonymous bool
+	// PriorOrders is how many carts the customer checked out before
+	PriorOrders int
+	// Reminders is how many abandoned-cart reminders were sent for the cart
+	Reminders int
+}
+
+// AbandonmentScore returns the risk of the cart being abandoned from 0, about to be checked out, to 100.
+// Idle time weighs most, followed by the value of the cart, since big carts are more often left for
+// later. Customers who checked out before are less likely to abandon the cart, while anonymous customers
+// and those who ignored reminders are more likely to.
+func AbandonmentScore(s AbandonmentSignals) int {
+	score := 50 * math.Min(float64(s.IdleFor)/float64(idleRiskAfter), 1)
+	score += 20 * math.Min(s.Total/valueRiskAt, 1)
+	if s.Anonymous {
+		score += 10
+	}
+	score += 10 * math.Min(float64(s.Reminders), 2)
+	score -= 10 * math.Min(float64(s.PriorOrders), 3)
+	return int(math.Round(math.Max(0, math.Min(score, 100))))
+}
+
+// AbandonmentSegment returns the segment of carts with the abandonment score
+func AbandonmentSegment(score int) string {
+	switch {
+	case score >= 60:
+		return SegmentHigh
+	case score >= 30:
+		return SegmentMedium
+	}
+	return SegmentLow
+}
+
+// ValidSegment reports whether segment is one of Segments
+func ValidSegment(segment string) bool {
+	for _, s := range Segments {
+		if s == segment {
+			return true
+		}
+	}
+	return false
+}
@@ -1,26 +1,111 @@
 package cart
 
-import "gorm.io/gorm"
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
 
 const (
 	// StatusOpen represents an active shopping cart that can be modified
 	StatusOpen = "open"
 	// StatusClosed represents a completed shopping cart that can no longer be modified
 	StatusClosed = "closed"
+
+	// DefaultName is the name of the cart every session starts with
+	DefaultName = "default"
+	// maxNameLength is the longest cart name accepted, in characters
+	maxNameLength = 64
+	// MaxQuantity is the most units of a product an item can hold
+	MaxQuantity = 999
+)
+
+var (
+	// ErrCartNotFound is returned when a cart doesn't exist
+	ErrCartNotFound = errors.New("cart not found")
+	// ErrCartClosed is returned when changing a cart that is no longer open
+	ErrCartClosed = errors.New("cart is closed")
+	// ErrCartLocked is returned when changing a cart that is being checked out
+	ErrCartLocked = errors.New("cart is being checked out")
+	// ErrItemNotFound is returned when an item doesn't exist or belongs to another cart
+	ErrItemNotFound = errors.New("item not found")
+	// ErrInvalidQuantity is returned when adding less than one item
+	ErrInvalidQuantity = errors.New("quantity must be greater than 0")
+	// ErrQuantityTooLarge is returned when an item would hold more than MaxQuantity units
+	ErrQuantityTooLarge = errors.New("quantity must be at most 999")
+	// ErrInvalidPrice is returned when an item has a negative price
+	ErrInvalidPrice = errors.New("price must not be negative")
+	// ErrInvalidName is returned when a cart name is empty or too long
+	ErrInvalidName = errors.New("cart name must be between 1 and 64 characters")
+	// ErrNameTaken is returned when the session already has a cart with the name
+	ErrNameTaken = errors.New("cart name is already used")
+	// ErrCartHasCredit is returned when deleting a cart holding redeemed gift card credit
+	ErrCartHasCredit = errors.New("cart holds gift card credit")
+	// ErrInvalidItemOrder is returned when reordering the items of a cart without listing each of them once
+	ErrInvalidItemOrder = errors.New("the new order must list every item of the cart once")
 )
 
 type (
 	// Cart represents a shopping cart associated with a user session
 	Cart struct {
 		gorm.Model
-		// SessionID uniquely identifies the user's session
-		SessionID string `gorm:"size:255;uniqueIndex;not null"`
+		// TenantID is the shop the cart was filled in
+		TenantID string `gorm:"size:32;index;not null;default:default"`
+		// SessionID identifies the user's session
+		SessionID string `gorm:"size:255;uniqueIndex:idx_cart_session_open;not null"`
+		// Name tells the open carts of a session apart, e.g. "work" and "personal". Closed carts keep
+		// their name, which the next open cart of the session can use again.
+		Name string `gorm:"size:64;uniqueIndex:idx_cart_session_open;not null;default:default"`
+		// UserID links the cart to a logged-in user, nil for anonymous carts
+		UserID *uint `gorm:"index"`
 		// Status indicates whether the cart is open or closed
 		Status string `gorm:"size:64;index;not null"`
-		// Total represents the total price of all items in the cart
+		// IsOpen is true while the cart is open and NULL once it is closed. Unique with the session and
+		// the name, since NULLs never collide, it allows any number of closed carts but one open cart of
+		// each name per session.
+		IsOpen *bool `gorm:"uniqueIndex:idx_cart_session_open"`
+		// Subtotal is the price of all items in the cart before discounts
+		Subtotal float64 `gorm:"not null;default:0"`
+		// DiscountTotal is the sum of the Discounts
+		DiscountTotal float64 `gorm:"not null;default:0"`
+		// Tax is the sales tax on the discounted subtotal
+		Tax float64 `gorm:"not null;default:0"`
+		// TaxExempt carts are zero-rated, e.g. for a valid VAT ID of a business in another member state
+		TaxExempt bool `gorm:"not null;default:false"`
+		// Shipping is the shipping cost of the cart
+		Shipping float64 `gorm:"not null;default:0"`
+		// Total is the grand total to pay: the discounted subtotal plus tax and shipping, less Credit
 		Total float64
+		// Credit is the gift card balance redeemed against the cart, already deducted from Total
+		Credit float64 `gorm:"not null;default:0"`
+		// Version is incremented on every change to the cart and used for optimistic locking
+		Version int `gorm:"not null;default:0"`
+		// PricesRefreshedAt is when the item prices were last checked against current prices, nil if
+		// they never were since the cart was created
+		PricesRefreshedAt *time.Time
+		// ReservedUntil is when the hold of the cart on the stock of its limited items, those of products
+		// whose stock is tracked, runs out, nil while it holds none. Adding a limited item renews it.
+		ReservedUntil *time.Time `gorm:"index"`
+		// AbandonmentScore is the risk of the cart being abandoned from 0 to 100 and AbandonmentSegment
+		// its segment, as of ScoredAt. Open carts are scored by a scheduled job; carts never scored have
+		// no segment.
+		AbandonmentScore   int    `gorm:"not null;default:0"`
+		AbandonmentSegment string `gorm:"size:16;index;not null;default:''"`
+		ScoredAt           *time.Time
+		// ReferralID is the referral code entered for the cart, whose owner is rewarded when the cart
+		// is checked out
+		ReferralID *uint `gorm:"index"`
+		// SubscriptionID is the subscription the cart was ordered for, nil for carts filled by customers
+		SubscriptionID *uint `gorm:"index"`
+		// Metadata holds the custom attributes integrators attached to the cart
+		Metadata Metadata
 		// CartItems contains all items added to the cart
 		CartItems []CartItem
+		// Discounts contains the promotions applied to the cart, already deducted from Total
+		Discounts []CartDiscount
 	}
 
 	// CartItem represents a single item in the shopping cart
@@ -34,5 +119,102 @@ type (
 		Quantity int
 		// Price represents the unit price of the item
 		Price float64
+		// TierQuantity is the MinQuantity of the price tier of the product Price comes from, 0 when the item
+		// sells at the regular price
+		TierQuantity int `gorm:"not null;default:0"`
+		// BundleGroup links the items a bundle was expanded to when it was added: it is the ID of the first
+		// of them, 0 for items added on their own. The items of a group are removed and restored together and
+		// keep the prices they were split from the bundle price. BundleName is the bundle and BundleQuantity
+		// how many of it the group holds.
+		BundleGroup    uint   `gorm:"index;not null;default:0"`
+		BundleName     string `gorm:"size:255;not null;default:''"`
+		BundleQuantity int    `gorm:"not null;default:0"`
+		// SubscriptionDays is how often the item is reordered when it is bought with subscribe & save,
+		// 0 for a one-time purchase
+		SubscriptionDays int `gorm:"not null;default:0"`
+		// Position orders the items of the cart the way the customer arranged them, items of the same
+		// position by when they were added. New items go last, the items of a bundle together.
+		Position int `gorm:"not null;default:0"`
+		// Metadata holds the custom attributes integrators attached to the item
+		Metadata Metadata
+	}
+
+	// CartDiscount represents a promotion applied to the cart, recalculated on every cart change
+	CartDiscount struct {
+		gorm.Model
+		// CartID links the discount to its parent cart
+		CartID uint `gorm:"index;not null"`
+		// Promotion is the name of the promotion that granted the discount
+		Promotion string
+		// Amount is the value deducted from the cart total
+		Amount float64
+	}
+
+	// Reminder records an abandoned-cart email, so each idle cart is only reminded once
+	Reminder struct {
+		gorm.Model
+		// CartID and CartVersion identify the state of the cart the reminder was sent for; changing the
+		// cart makes it eligible for a new reminder once it is idle again
+		CartID      uint `gorm:"not null;uniqueIndex:idx_reminder_cart_version"`
+		CartVersion int  `gorm:"not null;uniqueIndex:idx_reminder_cart_version"`
+		// Email is the address the reminder was sent to
+		Email string `gorm:"size:255"`
 	}
 )
+
+// PricesCheckedAt returns when the item prices were last known to be current
+func (c Cart) PricesCheckedAt() time.Time {
+	if c.PricesRefreshedAt != nil {
+		return *c.PricesRefreshedAt
+	}
+	return c.CreatedAt
+}
+
+// NormalizeName trims the name typed by users and checks its length, returning ErrInvalidName
+func NormalizeName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxNameLength {
+		return "", ErrInvalidName
+	}
+	return name, nil
+}
+
+// Validate checks the item has at least one and at most MaxQuantity units, a price that isn't negative
+// and an offered subscription interval
+func (i CartItem) Validate() error {
+	if i.Quantity < 1 {
+		return ErrInvalidQuantity
+	}
+	if i.Quantity > MaxQuantity {
+		return ErrQuantityTooLarge
+	}
+	if i.Price < 0 {
+		return ErrInvalidPrice
+	}
+	if !ValidInterval(i.SubscriptionDays) {
+		return ErrInvalidInterval
+	}
+	return nil
+}
+
+// BeforeSave keeps IsOpen in step with the status on every create and save. Updates of single columns
+// set both.
+func (c *Cart) BeforeSave(*gorm.DB) error {
+	c.IsOpen = nil
+	if c.Status == StatusOpen {
+		open := true
+		c.IsOpen = &open
+	}
+	return nil
+}
+
+// BeforeSave validates items on every create and save, so no caller can store an invalid one.
+// Updates of single columns don't carry the new value in the model and are validated by the caller.
+func (i *CartItem) BeforeSave(*gorm.DB) error {
+	return i.Validate()
+}
+
+// TableName keeps the reminders table name explicit about what is reminded of.
+func (Reminder) TableName() string {
+	return "cart_reminders"
+}
@@ -0,0 +1,64 @@
+package cart
+
+import (
+	"errors"
+	"time"
+)
+
+// Actions of the changes recorded in the history of a cart
+const (
+	// ChangeAdded records units of a product added to the cart
+	ChangeAdded = "added"
+	// ChangeRemoved records an item removed from the cart
+	ChangeRemoved = "removed"
+	// ChangeRestored records a removed item put back
+	ChangeRestored = "restored"
+	// ChangeMerged records units of a product moved in from the anonymous cart of a user logging in
+	ChangeMerged = "merged"
+	// ChangeRepriced records the new price of an item whose product changed its price
+	ChangeRepriced = "repriced"
+	// ChangeGiftCard records gift card credit redeemed against the cart
+	ChangeGiftCard = "gift_card"
+	// ChangeReferral records a referral code entered for the cart
+	ChangeReferral = "referral"
+	// ChangeCheckedOut records the checkout of the cart
+	ChangeCheckedOut = "checked_out"
+	// ChangeExpired records a limited item taken out of the cart once its reservation ran out
+	ChangeExpired = "expired"
+)
+
+var (
+	// ErrNothingToUndo is returned when undoing without a recent change left to undo
+	ErrNothingToUndo = errors.New("nothing to undo")
+	// ErrCannotUndo is returned when the latest change of a cart isn't one customers can undo
+	ErrCannotUndo = errors.New("the latest change can't be undone")
+)
+
+// Change is an entry of the history of a cart, shown to its customer to explain how the cart came to
+// be what it is, e.g. "added 2x shoe". Changes are recorded in the transaction making them.
+type Change struct {
+	ID     uint `gorm:"primarykey"`
+	CartID uint `gorm:"index;not null"`
+	// Action is one of the Change constants
+	Action string `gorm:"size:32;not null"`
+	// Product and Quantity are the product changed and its units, empty for changes of the whole cart
+	Product  string `gorm:"size:255"`
+	Quantity int    `gorm:"not null;default:0"`
+	// ItemID is the item added, removed or restored, which undoing the change works on
+	ItemID uint
+	// Amount is the new price of repriced items and the credit of redeemed gift cards
+	Amount    float64 `gorm:"not null;default:0"`
+	CreatedAt time.Time
+	// UndoneAt is when the change was undone, nil while it stands
+	UndoneAt *time.Time
+}
+
+// Undoable tells whether customers can undo the change: additions, removals and restores of items
+func (c Change) Undoable() bool {
+	return c.ItemID != 0 && (c.Action == ChangeAdded || c.Action == ChangeRemoved || c.Action == ChangeRestored)
+}
+
+// TableName names the table after the carts the changes belong to.
+func (Change) TableName() string {
+	return "cart_changes"
+}
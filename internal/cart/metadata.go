@@ -0,0 +1,153 @@
+package cart
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	// maxMetadataKeys is the most keys a cart or item can hold
+	maxMetadataKeys = 50
+	// maxMetadataKeyLength is the longest key accepted, in characters
+	maxMetadataKeyLength = 40
+	// maxMetadataValueLength is the longest string value accepted, in characters
+	maxMetadataValueLength = 500
+)
+
+// ErrInvalidMetadata is returned for metadata with too many or too long keys, or values that aren't
+// short strings, numbers or booleans
+var ErrInvalidMetadata = errors.New("metadata must have at most 50 keys of up to 40 characters with string, number or boolean values")
+
+// Metadata holds custom attributes integrators attach to carts and items, e.g. campaign IDs,
+// personalization text or references into their own systems. It is stored as a JSON object; values
+// are strings, float64 numbers or booleans.
+type Metadata map[string]interface{}
+
+// String returns the string value of the key. ok is false when the key is missing or holds another type.
+func (m Metadata) String(key string) (value string, ok bool) {
+	value, ok = m[key].(string)
+	return value, ok
+}
+
+// Float returns the number value of the key. ok is false when the key is missing or holds another type.
+func (m Metadata) Float(key string) (value float64, ok bool) {
+	switch v := m[key].(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// Int returns the number value of the key if it is a whole number. ok is false otherwise.
+func (m Metadata) Int(key string) (value int, ok bool) {
+	f, ok := m.Float(key)
+	if !ok || f != math.Trunc(f) || math.Abs(f) > math.MaxInt32 {
+		return 0, false
+	}
+	return int(f), true
+}
+
+// Bool returns the boolean value of the key. ok is false when the key is missing or holds another type.
+func (m Metadata) Bool(key string) (value bool, ok bool) {
+	value, ok = m[key].(bool)
+	return value, ok
+}
+
+// Merge returns a copy of the metadata with the changes applied: keys set to nil are removed, the
+// others are set. The result is nil when no keys are left.
+func (m Metadata) Merge(changes Metadata) Metadata {
+	merged := Metadata{}
+	for key, value := range m {
+		merged[key] = value
+	}
+	for key, value := range changes {
+		if value == nil {
+			delete(merged, key)
+		} else {
+			merged[key] = value
+		}
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
+}
+
+// Validate checks the number and length of the keys and the types and length of the values, returning
+// ErrInvalidMetadata. nil values are accepted, they remove keys when merged.
+func (m Metadata) Validate() error {
+	if len(m) > maxMetadataKeys {
+		return ErrInvalidMetadata
+	}
+	for key, value := range m {
+		if key == "" || utf8.RuneCountInString(key) > maxMetadataKeyLength {
+			return ErrInvalidMetadata
+		}
+		switch v := value.(type) {
+		case nil, bool, float64, int:
+		case string:
+			if utf8.RuneCountInString(v) > maxMetadataValueLength {
+				return ErrInvalidMetadata
+			}
+		default:
+			return ErrInvalidMetadata
+		}
+	}
+	return nil
+}
+
+// Value implements driver.Valuer, storing empty metadata as NULL.
+func (m Metadata) Value() (driver.Value, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	return string(data), nil
+}
+
+// Scan implements sql.Scanner.
+func (m *Metadata) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*m = nil
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("unsupported metadata type %T", value)
+	}
+	var decoded Metadata
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return fmt.Errorf("failed to decode metadata: %w", err)
+	}
+	*m = decoded
+	return nil
+}
+
+// GormDataType tells GORM the map is a single column.
+func (Metadata) GormDataType() string {
+	return "json"
+}
+
+// GormDBDataType stores metadata in a JSON column where the database has one.
+func (Metadata) GormDBDataType(db *gorm.DB, _ *schema.Field) string {
+	if db.Dialector.Name() == "mysql" {
+		return "JSON"
+	}
+	return "TEXT"
+}
@@ -0,0 +1,65 @@
+package cart
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// SubscriptionActive subscriptions are reordered every IntervalDays
+	SubscriptionActive = "active"
+	// SubscriptionPaused subscriptions place no orders until they are resumed
+	SubscriptionPaused = "paused"
+	// SubscriptionCancelled subscriptions place no more orders
+	SubscriptionCancelled = "cancelled"
+
+	// SubscriptionPromotion names the discount of subscribed items among the discounts of a cart
+	SubscriptionPromotion = "Subscribe & save"
+)
+
+// SubscriptionIntervals are the intervals in days items can be subscribed to
+var SubscriptionIntervals = []int{7, 14, 30, 60, 90}
+
+var (
+	// ErrInvalidInterval is returned when subscribing to an item at an interval that isn't offered
+	ErrInvalidInterval = errors.New("subscription interval is not offered")
+	// ErrSubscriptionNotFound is returned when a subscription doesn't exist or belongs to someone else
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+	// ErrSubscriptionCancelled is returned when changing a cancelled subscription
+	ErrSubscriptionCancelled = errors.New("subscription is cancelled")
+)
+
+// Subscription reorders an item of a checked out cart every IntervalDays, created at checkout for the
+// items bought with subscribe & save. Every order is a new cart checked out for the session and user
+// of the original cart.
+type Subscription struct {
+	gorm.Model
+	// SessionID and UserID own the subscription and the carts of its orders
+	SessionID string `gorm:"size:255;index;not null"`
+	UserID    *uint  `gorm:"index"`
+	// CartID is the cart whose checkout created the subscription
+	CartID      uint   `gorm:"index;not null"`
+	ProductName string `gorm:"size:255;not null"`
+	Quantity    int    `gorm:"not null"`
+	// IntervalDays is the number of days between orders, one of SubscriptionIntervals
+	IntervalDays int `gorm:"not null"`
+	// Status is SubscriptionActive, SubscriptionPaused or SubscriptionCancelled
+	Status string `gorm:"size:16;index;not null"`
+	// NextOrderAt is when the next order is placed while the subscription is active
+	NextOrderAt time.Time `gorm:"index;not null"`
+	// Orders is the number of orders placed after the original checkout
+	Orders int `gorm:"not null;default:0"`
+}
+
+// ValidInterval reports whether items can be subscribed to every days, 0 being a one-time purchase
+func ValidInterval(days int) bool {
+	return days == 0 || slices.Contains(SubscriptionIntervals, days)
+}
+
+// TableName keeps the subscriptions table name apart from the subscriptions to restock emails.
+func (Subscription) TableName() string {
+	return "item_subscriptions"
+}
@@ -0,0 +1,50 @@
+package cart
+
+import "math"
+
+type (
+	// Charges are added to the discounted price of the items of every cart
+	Charges struct {
+		// TaxRate is the sales tax charged on the discounted subtotal in percent, e.g. 19
+		TaxRate float64
+		// Shipping is the shipping cost of carts with items
+		Shipping float64
+		// FreeShippingFrom is the discounted subtotal from which shipping is free, 0 to always charge it
+		FreeShippingFrom float64
+	}
+
+	// Totals breaks down what a cart costs
+	Totals struct {
+		// Subtotal is the price of all items before discounts
+		Subtotal float64
+		// Discount is the sum of the promotions applied to the cart
+		Discount float64
+		// Tax is the sales tax on the discounted subtotal
+		Tax float64
+		// Shipping is the shipping cost, 0 for empty carts and when shipping is free
+		Shipping float64
+		// Total is the grand total to pay, net of redeemed gift card credit
+		Total float64
+	}
+)
+
+// Totals computes the totals of a cart whose items cost subtotal, less discount from promotions and
+// credit from gift cards. Amounts are rounded to cents and the total never drops below 0.
+func (c Charges) Totals(subtotal, discount, credit float64) Totals {
+	t := Totals{
+		Subtotal: round(subtotal),
+		Discount: round(math.Min(discount, subtotal)),
+	}
+	net := t.Subtotal - t.Discount
+	t.Tax = round(net * c.TaxRate / 100)
+	if subtotal > 0 && (c.FreeShippingFrom == 0 || net < c.FreeShippingFrom) {
+		t.Shipping = round(c.Shipping)
+	}
+	t.Total = math.Max(round(net+t.Tax+t.Shipping-credit), 0)
+	return t
+}
+
+// round rounds amount to cents
+func round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,101 @@
+// Package checkout defines the checkout sessions customers go through to pay for their carts.
+package checkout
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// StepAddress chooses the address the order is shipped to
+	StepAddress = "address"
+	// StepShipping chooses how the order is shipped
+	StepShipping = "shipping"
+	// StepPayment has the customer approve the payment at the payment provider
+	StepPayment = "payment"
+	// StepConfirm captures the approved payment and checks the cart out
+	StepConfirm = "confirm"
+
+	// StatusActive sessions are still being checked out
+	StatusActive = "active"
+	// StatusCompleted sessions checked their cart out
+	StatusCompleted = "completed"
+	// StatusCancelled sessions were given up by the customer or rejected by staff
+	StatusCancelled = "cancelled"
+	// StatusReview sessions wait for staff to review their held payment, locking the cart meanwhile
+	StatusReview = "review"
+
+	// ShippingStandard ships the order for the shipping cost of the cart
+	ShippingStandard = "standard"
+)
+
+var (
+	// Steps are the steps of a checkout in the order customers go through them
+	Steps = []string{StepAddress, StepShipping, StepPayment, StepConfirm}
+	// ShippingMethods are the shipping methods customers can choose
+	ShippingMethods = []string{ShippingStandard}
+
+	// ErrSessionNotFound is returned when the cart isn't being checked out
+	ErrSessionNotFound = errors.New("checkout session not found")
+	// ErrSessionExpired is returned for checkouts that expired or were cancelled, which have to be
+	// started again
+	ErrSessionExpired = errors.New("checkout session expired")
+	// ErrStepNotReached is returned for steps after the one the checkout is at
+	ErrStepNotReached = errors.New("checkout step not reached yet")
+	// ErrInvalidShipping is returned for shipping methods that aren't offered
+	ErrInvalidShipping = errors.New("invalid shipping method")
+)
+
+// Session is the checkout of a cart. It remembers the step the customer is at and what they chose in the
+// steps before, so a checkout resumes after a refresh or the redirect to the payment provider. Each cart
+// has one session, which is completed once the cart is checked out. Active sessions lock their cart
+// against changes until they expire.
+type Session struct {
+	gorm.Model
+	CartID uint `gorm:"uniqueIndex;not null"`
+	// SessionID is the browser session the checkout was started in
+	SessionID string `gorm:"size:255;index;not null"`
+	// Step is the step the customer is at, one of Steps
+	Step string `gorm:"size:16;not null"`
+	// Status is StatusActive, StatusReview, StatusCompleted or StatusCancelled
+	Status string `gorm:"size:16;not null"`
+	// ExpiresAt is when an active session stops locking the cart, renewed by every step
+	ExpiresAt time.Time `gorm:"index"`
+	// AddressID is the address of the address book the order is shipped to
+	AddressID *uint
+	// BillingAddressID is the address of the address book the order is billed to, nil to bill the
+	// address it is shipped to
+	BillingAddressID *uint
+	// ShippingMethod is one of ShippingMethods
+	ShippingMethod string `gorm:"size:32"`
+	// VATID is the VAT ID of the business ordering, empty for consumers
+	VATID string `gorm:"size:16"`
+	// PaymentID is the payment.Payment started at the payment step, and ApproveURL where the customer
+	// approves it, so returning to the payment step doesn't start a second payment
+	PaymentID  *uint
+	ApproveURL string `gorm:"size:2048"`
+}
+
+// TableName keeps checkout sessions apart from the browser sessions in the "sessions" table.
+func (Session) TableName() string {
+	return "checkout_sessions"
+}
+
+// Active reports whether the session is being checked out and locks its cart at now.
+func (s Session) Active(now time.Time) bool {
+	return s.Status == StatusActive && now.Before(s.ExpiresAt)
+}
+
+// Reached reports whether the customer got to the step, which they can go back to.
+func (s Session) Reached(step string) bool {
+	i := slices.Index(Steps, step)
+	return i >= 0 && i <= slices.Index(Steps, s.Step)
+}
+
+// ValidShippingMethod reports whether the shipping method is offered.
+func ValidShippingMethod(method string) bool {
+	return slices.Contains(ShippingMethods, method)
+}
@@ -1,8 +1,22 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"interview/internal/cache"
+	"interview/internal/experiment"
+	"interview/internal/ipfilter"
+	"interview/internal/risk"
+	"interview/internal/tenant"
+	"interview/internal/user"
+	"interview/internal/vat"
+	"interview/internal/warehouse"
+	"net"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Config holds all configuration values for the application.
@@ -10,67 +24,809 @@ type Config struct {
 	// DBHost is the hostname of the MySQL database server
 	DBHost string
 	// DBPort is the port number of the MySQL database server
-	DBPort string
+	DBPort int
 	// DBUser is the username for connecting to the MySQL database
 	DBUser string
 	// DBPassword is the password for connecting to the MySQL database
 	DBPassword string
 	// DBName is the name of the MySQL database to use
 	DBName string
+	// DBMaxOpenConns is the maximum number of open connections to the database
+	DBMaxOpenConns int
+	// DBMaxIdleConns is the maximum number of idle connections kept in the pool
+	DBMaxIdleConns int
+	// DBConnMaxLifetime is the maximum time a connection may be reused, unlimited when 0
+	DBConnMaxLifetime time.Duration
+	// DBReplicaDSNs is a comma-separated list of MySQL DSNs of read replicas serving the cart page,
+	// catalog and admin listings. All reads go to the primary when empty.
+	DBReplicaDSNs string
+	// DBReplicaCheckInterval is how often the replicas are pinged to take them out of or back into rotation
+	DBReplicaCheckInterval time.Duration
+	// DBReplicaPinAfterWrite is how long the reads of a client run on the primary after it changed
+	// something, so it sees its own changes before they reach the replicas
+	DBReplicaPinAfterWrite time.Duration
+	// DBConnectAttempts is how many times connecting to the database is tried on startup
+	DBConnectAttempts int
+	// DBSlowQueryThreshold is how long a query may take before it is logged as slow, never when 0
+	DBSlowQueryThreshold time.Duration
+	// DBQueryTimeout bounds the database work of each cart operation, unbounded when 0
+	DBQueryTimeout time.Duration
+	// DBBreakerThreshold is how many queries in a row may fail because the database doesn't answer before
+	// requests are answered with 503 for DBBreakerCooldown without trying it. 0 disables the breaker.
+	DBBreakerThreshold int
+	DBBreakerCooldown  time.Duration
 	// SessionSecret is used to encrypt session data and generate CSRF tokens
 	SessionSecret string
 	// SessionName is the name of the session cookie
 	SessionName string
+	// SessionMaxAge is how long sessions last without being used
+	SessionMaxAge time.Duration
+	// SessionCleanupInterval is how often expired sessions are deleted, SessionCleanupBatch at a time
+	SessionCleanupInterval time.Duration
+	SessionCleanupBatch    int
+	// MaxSessionsPerUser is how many sessions a user may be logged in to at once, 0 for no limit. Logging in
+	// to one more logs the oldest out, whose open carts stay with its browser ("keep") or move to the
+	// session logging in ("move") as SessionLimitCarts says.
+	MaxSessionsPerUser int
+	SessionLimitCarts  string
 	// APIPort is the port number on which the HTTP server will listen
-	APIPort string
+	APIPort int
+	// APIListen is the address the HTTP server listens on instead of APIPort: "unix:///run/cart.sock"
+	// for a unix socket or "tcp://127.0.0.1:8088" for a TCP address
+	APIListen string
+	// SocketActivated is set when systemd passed the listening socket to the process (LISTEN_FDS and
+	// LISTEN_PID), which is then used instead of APIListen and APIPort
+	SocketActivated bool
+	// MaintenanceMode makes the shop read-only: pages are shown, changes are answered with 503
+	MaintenanceMode bool
+	// LogLevel selects which requests are logged: "info" logs all of them, "warn" those answered with
+	// an error and "error" those failing with a server error
+	LogLevel string
+	// TLSCertFile and TLSKeyFile make the server terminate TLS with the given certificate
+	TLSCertFile string
+	TLSKeyFile  string
+	// AutoTLSDomain is a comma-separated list of domains to obtain Let's Encrypt certificates for
+	AutoTLSDomain string
+	// AutoTLSCacheDir is where certificates obtained from Let's Encrypt are stored
+	AutoTLSCacheDir string
+	// AutoTLSHTTPPort is the port answering ACME HTTP-01 challenges and redirecting to HTTPS
+	AutoTLSHTTPPort int
+	// PriceServiceURL is the base URL of the external pricing service overriding catalog prices, which
+	// are used when empty
+	PriceServiceURL string
+	// PriceCacheTTL is how long prices fetched from the pricing service are cached
+	PriceCacheTTL time.Duration
+	// PriceRefreshAfter is how old the prices of a cart may get before they are refreshed when the
+	// cart is shown. Prices are never refreshed when 0.
+	PriceRefreshAfter time.Duration
+	// CartUndoWindow is how long after a change to a cart customers can still undo it
+	CartUndoWindow time.Duration
+	// ReservationTTL enables flash-sale reservations when positive: adding an item of a product whose
+	// stock is tracked holds its stock for the cart this long. Expired reservations are released every
+	// ReservationInterval.
+	ReservationTTL      time.Duration
+	ReservationInterval time.Duration
+	// JWTSigningKeys is a comma-separated list of "kid:secret" pairs used to sign API tokens.
+	// The first key signs new tokens, the others are accepted for verification during rotation.
+	// The JSON API is disabled when empty.
+	JWTSigningKeys string
+	// OAuthRedirectBaseURL is the public base URL of the application used to build OAuth2 callback URLs
+	OAuthRedirectBaseURL string
+	// GoogleClientID and GoogleClientSecret enable "Log in with Google" when both are set
+	GoogleClientID     string
+	GoogleClientSecret string
+	// GitHubClientID and GitHubClientSecret enable "Log in with GitHub" when both are set
+	GitHubClientID     string
+	GitHubClientSecret string
+	// CORSAllowedOrigins is a comma-separated list of origins allowed to call the application
+	// cross-origin, "*" allows any origin. CORS is disabled when empty.
+	CORSAllowedOrigins string
+	// CORSAllowedMethods is a comma-separated list of methods allowed in cross-origin requests
+	CORSAllowedMethods string
+	// CORSAllowedHeaders is a comma-separated list of request headers allowed in cross-origin requests
+	CORSAllowedHeaders string
+	// CORSAllowCredentials allows cross-origin requests to carry cookies
+	CORSAllowCredentials bool
+	// CORSMaxAge is how long browsers may cache preflight responses
+	CORSMaxAge time.Duration
+	// ContentSecurityPolicy overrides the default Content-Security-Policy of HTML responses
+	ContentSecurityPolicy string
+	// CSPReportOnly sends the policy in report-only mode
+	CSPReportOnly bool
+	// HSTSMaxAge enables Strict-Transport-Security with the given max-age when positive
+	HSTSMaxAge time.Duration
+	// AdminUser and AdminPassword protect the /admin endpoints with basic auth; admin is disabled when
+	// neither they nor OIDCIssuerURL are set
+	AdminUser     string
+	AdminPassword string
+	// AdminAllowedIPs and AdminDeniedIPs are comma-separated networks such as "10.0.0.0/8" or addresses
+	// the /admin endpoints are limited to and closed to; denied networks take precedence, and any
+	// client not denied is allowed when AdminAllowedIPs is empty
+	AdminAllowedIPs string
+	AdminDeniedIPs  string
+	// TrustedProxies are the networks of the reverse proxies whose X-Forwarded-For header tells the
+	// client address, e.g. the one checked against the admin networks and throttled on login
+	TrustedProxies string
+	// OIDCIssuerURL enables single sign-on for the /admin endpoints with the OpenID Connect identity
+	// provider at this URL, replacing basic auth. OIDCClientID and OIDCClientSecret identify the shop.
+	OIDCIssuerURL    string
+	OIDCClientID     string
+	OIDCClientSecret string
+	// OIDCRoleGroups maps groups of the identity provider to admin roles, e.g. "shop-admins=admin,shop-support=support"
+	OIDCRoleGroups string
+	// OIDCGroupsClaim is the ID token claim listing the groups of the user
+	OIDCGroupsClaim string
+	// TOTPIssuer names the shop in authenticator apps
+	TOTPIssuer string
+	// PasswordResetTTL is how long password reset links stay valid
+	PasswordResetTTL time.Duration
+	// LoginLinkTTL is how long emailed login links stay valid
+	LoginLinkTTL time.Duration
+	// EmailLimitPerIP and EmailLimitPerAddress are how many password reset, login and email change
+	// emails each IP address and email address may request per hour
+	EmailLimitPerIP      int
+	EmailLimitPerAddress int
+	// LoginFailuresPerEmail and LoginFailuresPerIP are how many logins to an email address and from an
+	// IP address may fail in a row before they are locked out for LoginLockout, which doubles with every
+	// further failure up to LoginMaxLockout
+	LoginFailuresPerEmail int
+	LoginFailuresPerIP    int
+	LoginLockout          time.Duration
+	LoginMaxLockout       time.Duration
+	// VerificationLinkTTL is how long email verification links stay valid, VerificationCooldown how long
+	// a user waits before another one is sent
+	VerificationLinkTTL  time.Duration
+	VerificationCooldown time.Duration
+	// RequireVerifiedEmail keeps the saved addresses of users out of checkout until they verified their
+	// email address
+	RequireVerifiedEmail bool
+	// RequireStaff2FA keeps users with a staff role out of the admin area until they
+	// enabled two-factor authentication
+	RequireStaff2FA bool
+	// StorageBackend selects where uploaded files are stored: "local" or "s3"
+	StorageBackend string
+	// StorageLocalDir is the directory uploads are written to with the local backend
+	StorageLocalDir string
+	// S3Endpoint overrides the AWS endpoint for S3-compatible services such as MinIO
+	S3Endpoint string
+	// S3Region and S3Bucket select the bucket uploads are written to with the s3 backend
+	S3Region string
+	S3Bucket string
+	// S3AccessKeyID and S3SecretAccessKey are the credentials used to sign S3 requests
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+	// MediaURLTTL is how long signed URLs of uploaded files stay valid
+	MediaURLTTL time.Duration
+	// SearchURL is the base URL of the Elasticsearch cluster backing product search. Searches use SQL when empty.
+	SearchURL string
+	// SearchIndex is the name of the Elasticsearch index holding the products
+	SearchIndex string
+	// CacheURL is the redis:// or rediss:// URL of the Redis server caching products and prices for
+	// every instance. Lookups go to the database and the pricing service when empty.
+	CacheURL string
+	// CacheTTL is how long products and prices stay in Redis
+	CacheTTL time.Duration
+	// PublicBaseURL is the URL the application is reachable at, used to build links in emails
+	PublicBaseURL string
+	// SMTPHost and SMTPPort locate the server emails are sent through. Emails are logged when SMTPHost is empty.
+	SMTPHost string
+	SMTPPort int
+	// SMTPUsername and SMTPPassword authenticate with the SMTP server when set
+	SMTPUsername string
+	SMTPPassword string
+	// MailFrom is the sender address of emails
+	MailFrom string
+	// ReminderAfter enables abandoned-cart reminders for carts of logged-in users idle for this long when positive
+	ReminderAfter time.Duration
+	// ReminderInterval is how often abandoned carts are looked for
+	ReminderInterval time.Duration
+	// AbandonmentInterval is how often open carts are scored by the risk of being abandoned
+	AbandonmentInterval time.Duration
+	// CrossSellInterval is how often the products frequently bought together are computed from the
+	// carts checked out
+	CrossSellInterval time.Duration
+	// ReminderLinkTTL is how long the cart links in reminder emails stay valid
+	ReminderLinkTTL time.Duration
+	// CartHandoffTTL is how long the cart links in the QR codes of the cart page stay valid
+	CartHandoffTTL time.Duration
+	// WebhookPollInterval is how often due webhook deliveries are sent
+	WebhookPollInterval time.Duration
+	// JobWorkers is how many queued background jobs, such as emails, run at a time; JobPollInterval is
+	// how often due jobs are looked for
+	JobWorkers      int
+	JobPollInterval time.Duration
+	// InventoryWebhookSecret verifies the signatures of the stock and price updates an ERP posts to
+	// /webhooks/inventory, empty to disable the endpoint
+	InventoryWebhookSecret string
+	// RestockInterval is how often subscribers of products back in stock are notified
+	RestockInterval time.Duration
+	// LowStockInterval is how often products below their low-stock threshold are looked for, and
+	// LowStockSnooze how long an alert about a product is silenced before it is repeated. LowStockEmails
+	// is a comma-separated list of the staff addresses alerts are emailed to.
+	LowStockInterval time.Duration
+	LowStockSnooze   time.Duration
+	LowStockEmails   string
+	// PriceDropRepriceCarts gives the items of open carts the new price of a product staff lowered, rather
+	// than when customers come back to their cart
+	PriceDropRepriceCarts bool
+	// DownloadLimit is how often the digital products of a checked out cart can be downloaded and
+	// DownloadTTL for how long, 0 doesn't limit them
+	DownloadLimit int
+	DownloadTTL   time.Duration
+	// DownloadEmailInterval is how often download links of checked out carts are emailed
+	DownloadEmailInterval time.Duration
+	// SubscriptionDiscount is the discount in percent on items bought with subscribe & save, and
+	// SubscriptionInterval how often the orders of due subscriptions are placed
+	SubscriptionDiscount float64
+	SubscriptionInterval time.Duration
+	// ReferralReward is the gift card credit granted to referrers for each referred cart checked out,
+	// 0 grants none
+	ReferralReward float64
+	// TaxRate is the sales tax in percent added to the discounted subtotal of carts
+	TaxRate float64
+	// VATID is the EU VAT ID of the shop. When set, businesses can enter their VAT ID at checkout, which
+	// is validated with VIES at VIESURL and zero-rates orders shipped to another member state. Results
+	// are cached for VATCacheTTL and used past it while VIES is unavailable.
+	VATID       string
+	VIESURL     string
+	VATCacheTTL time.Duration
+	// ShippingCost is charged for every cart with items, unless its discounted subtotal reaches
+	// FreeShippingFrom when that is positive
+	ShippingCost     float64
+	FreeShippingFrom float64
+	// Currency is the currency of the prices, CurrencyRates the exchange rates of the other currencies
+	// customers can choose, as "CODE=rate" entries, e.g. "USD=1.08,GBP=0.86"
+	Currency      string
+	CurrencyRates string
+	// PaymentProvider is the provider customers pay with at checkout: "paypal", "fake" to approve every
+	// payment without a real provider during development, or empty when carts are only checked out by
+	// support
+	PaymentProvider string
+	// PayPalClientID and PayPalClientSecret are the REST API credentials of the shop in the
+	// PayPalEnvironment, "sandbox" or "live"; PayPalWebhookID is the webhook notifications are verified
+	// against
+	PayPalClientID     string
+	PayPalClientSecret string
+	PayPalEnvironment  string
+	PayPalWebhookID    string
+	// CheckoutTTL is how long a checkout locks its cart against changes after the customer's last step
+	CheckoutTTL time.Duration
+	// RiskVelocityLimit is how many checkouts a session or IP address may confirm per RiskVelocityWindow
+	// before RiskVelocityAction is taken on them, 0 for no limit. Actions are "allow", "review" (hold the
+	// order for staff) or "block".
+	RiskVelocityLimit  int
+	RiskVelocityWindow time.Duration
+	RiskVelocityAction string
+	// RiskCountryHeader is the request header the proxy in front of the shop reports the customer's
+	// country code in, e.g. "CF-IPCountry"; orders shipped elsewhere get RiskCountryAction. The check is
+	// disabled when empty.
+	RiskCountryHeader string
+	RiskCountryAction string
+	// RiskServiceURL is an external fraud screening service asked about every checkout, empty for none
+	RiskServiceURL string
+	// RefundRestock puts the items staff refund back into stock
+	RefundRestock bool
+	// WarehouseStrategy decides which warehouses the items of checked out carts ship from: "nearest"
+	// prefers warehouses in the country of the order, "most-stock" those with the most units
+	WarehouseStrategy string
+	// Experiments lists the A/B experiments sessions are assigned to, as "name=variant,variant" entries
+	// separated by semicolons
+	Experiments string
+	// Tenants lists the shops served by the deployment as "id=host,host" entries separated by
+	// semicolons. Requests are assigned to the shop of their host; hosts of no shop are not found. A
+	// single shop serves every host when empty.
+	Tenants string
+	// ThemesDir is the directory of the themes restyling the pages, one directory per theme. A shop uses
+	// the theme named after its tenant ID if there is one, and Theme otherwise. Empty for no themes.
+	ThemesDir string
+	// Theme is the theme of the shops without a theme of their own, empty for the built-in look
+	Theme string
+	// BotCheck adds a hidden honeypot field and a signed render time to the forms adding items to the
+	// cart, rejecting submissions with the honeypot filled in or sent sooner than BotMinFillTime after
+	// the form was shown
+	BotCheck       bool
+	BotMinFillTime time.Duration
+	// HCaptchaSiteKey and HCaptchaSecret add an hCaptcha challenge to the bot check, empty for none
+	HCaptchaSiteKey string
+	HCaptchaSecret  string
+	// AnalyticsSinks is a comma-separated list of where analytics events are written: "db", "file"
+	// and "segment". Analytics are disabled when empty.
+	AnalyticsSinks string
+	// AnalyticsFile is the JSON lines file written by the file sink
+	AnalyticsFile string
+	// SegmentWriteKey is the write key of the Segment source receiving events from the segment sink
+	SegmentWriteKey string
+	// AnalyticsFlushInterval is how often buffered analytics events are written
+	AnalyticsFlushInterval time.Duration
 }
 
-// Load reads configuration from environment variables and validates them.
-// Returns an error if any required environment variable is missing.
+// Load reads configuration from environment variables and validates them. The returned error lists
+// every missing or invalid variable, not just the first.
 func Load() (*Config, error) {
+	return load(os.LookupEnv)
+}
+
+// load reads the configuration from the variables returned by lookup.
+func load(lookup func(string) (string, bool)) (*Config, error) {
+	env := &envReader{lookup: lookup}
 	cfg := &Config{
-		DBHost:        os.Getenv("DB_HOST"),
-		DBPort:        os.Getenv("DB_PORT"),
-		DBUser:        os.Getenv("DB_USER"),
-		DBPassword:    os.Getenv("DB_PASSWORD"),
-		DBName:        os.Getenv("DB_DATABASE"),
-		SessionSecret: os.Getenv("SESSION_SECRET"),
-		SessionName:   os.Getenv("SESSION_NAME"),
-		APIPort:       os.Getenv("API_PORT"),
+		DBHost:        env.required("DB_HOST"),
+		DBPort:        env.port("DB_PORT", ""),
+		DBUser:        env.required("DB_USER"),
+		DBPassword:    env.required("DB_PASSWORD"),
+		DBName:        env.required("DB_DATABASE"),
+		SessionSecret: env.required("SESSION_SECRET"),
+		SessionName:   env.required("SESSION_NAME"),
+		SessionMaxAge: env.interval("SESSION_MAX_AGE", "1h"),
+		APIPort:       env.optionalPort("API_PORT"),
+		APIListen:     env.get("API_LISTEN"),
+		LogLevel:      env.getDefault("LOG_LEVEL", "info"),
+
+		SocketActivated: env.get("LISTEN_FDS") != "" && env.get("LISTEN_PID") == strconv.Itoa(os.Getpid()),
+		MaintenanceMode: env.bool("MAINTENANCE_MODE", "false"),
+
+		SessionCleanupInterval: env.interval("SESSION_CLEANUP_INTERVAL", "15m"),
+		SessionCleanupBatch:    env.int("SESSION_CLEANUP_BATCH", "1000", 1),
+		MaxSessionsPerUser:     env.int("MAX_SESSIONS_PER_USER", "0", 0),
+		SessionLimitCarts:      env.getDefault("SESSION_LIMIT_CARTS", user.EvictedCartsKeep),
+		DBMaxOpenConns:         env.int("DB_MAX_OPEN_CONNS", "25", 0),
+		DBMaxIdleConns:         env.int("DB_MAX_IDLE_CONNS", "25", 0),
+		DBConnMaxLifetime:      env.duration("DB_CONN_MAX_LIFETIME", "5m"),
+		DBConnectAttempts:      env.int("DB_CONNECT_ATTEMPTS", "5", 1),
+		DBSlowQueryThreshold:   env.duration("DB_SLOW_QUERY_THRESHOLD", "200ms"),
+		DBQueryTimeout:         env.duration("DB_QUERY_TIMEOUT", "5s"),
+		DBBreakerThreshold:     env.int("DB_BREAKER_THRESHOLD", "5", 0),
+		DBBreakerCooldown:      env.interval("DB_BREAKER_COOLDOWN", "30s"),
+		DBReplicaDSNs:          env.get("DB_REPLICA_DSNS"),
+		DBReplicaCheckInterval: env.interval("DB_REPLICA_CHECK_INTERVAL", "10s"),
+		DBReplicaPinAfterWrite: env.interval("DB_REPLICA_PIN_AFTER_WRITE", "5s"),
+
+		TLSCertFile:            env.get("TLS_CERT_FILE"),
+		TLSKeyFile:             env.get("TLS_KEY_FILE"),
+		AutoTLSDomain:          env.get("AUTO_TLS_DOMAIN"),
+		AutoTLSCacheDir:        env.getDefault("AUTO_TLS_CACHE_DIR", "certs"),
+		AutoTLSHTTPPort:        env.port("AUTO_TLS_HTTP_PORT", "80"),
+		PriceServiceURL:        env.get("PRICE_SERVICE_URL"),
+		PriceCacheTTL:          env.interval("PRICE_CACHE_TTL", "5m"),
+		PriceRefreshAfter:      env.duration("PRICE_REFRESH_AFTER", "24h"),
+		CartUndoWindow:         env.interval("CART_UNDO_WINDOW", "5m"),
+		ReservationTTL:         env.duration("RESERVATION_TTL", ""),
+		ReservationInterval:    env.interval("RESERVATION_INTERVAL", "30s"),
+		JWTSigningKeys:         env.get("JWT_SIGNING_KEYS"),
+		OAuthRedirectBaseURL:   env.get("OAUTH_REDIRECT_BASE_URL"),
+		GoogleClientID:         env.get("GOOGLE_CLIENT_ID"),
+		GoogleClientSecret:     env.get("GOOGLE_CLIENT_SECRET"),
+		GitHubClientID:         env.get("GITHUB_CLIENT_ID"),
+		GitHubClientSecret:     env.get("GITHUB_CLIENT_SECRET"),
+		CORSAllowedOrigins:     env.get("CORS_ALLOWED_ORIGINS"),
+		CORSAllowedMethods:     env.getDefault("CORS_ALLOWED_METHODS", "GET,POST,DELETE,OPTIONS"),
+		CORSAllowedHeaders:     env.getDefault("CORS_ALLOWED_HEADERS", "Authorization,Content-Type,X-CSRF-Token"),
+		CORSAllowCredentials:   env.bool("CORS_ALLOW_CREDENTIALS", "false"),
+		CORSMaxAge:             env.duration("CORS_MAX_AGE", "10m"),
+		ContentSecurityPolicy:  env.get("CONTENT_SECURITY_POLICY"),
+		CSPReportOnly:          env.bool("CSP_REPORT_ONLY", "false"),
+		HSTSMaxAge:             env.duration("HSTS_MAX_AGE", "0s"),
+		AdminUser:              env.get("ADMIN_USER"),
+		AdminPassword:          env.get("ADMIN_PASSWORD"),
+		AdminAllowedIPs:        env.get("ADMIN_ALLOWED_IPS"),
+		AdminDeniedIPs:         env.get("ADMIN_DENIED_IPS"),
+		TrustedProxies:         env.getDefault("TRUSTED_PROXIES", "127.0.0.1,::1"),
+		StorageBackend:         env.getDefault("STORAGE_BACKEND", "local"),
+		StorageLocalDir:        env.getDefault("STORAGE_LOCAL_DIR", "uploads"),
+		S3Endpoint:             env.get("S3_ENDPOINT"),
+		S3Region:               env.get("S3_REGION"),
+		S3Bucket:               env.get("S3_BUCKET"),
+		S3AccessKeyID:          env.get("S3_ACCESS_KEY_ID"),
+		S3SecretAccessKey:      env.get("S3_SECRET_ACCESS_KEY"),
+		MediaURLTTL:            env.interval("MEDIA_URL_TTL", "1h"),
+		SearchURL:              env.get("SEARCH_URL"),
+		SearchIndex:            env.getDefault("SEARCH_INDEX", "products"),
+		CacheURL:               env.get("CACHE_URL"),
+		CacheTTL:               env.interval("CACHE_TTL", "1m"),
+		PublicBaseURL:          env.get("PUBLIC_BASE_URL"),
+		SMTPHost:               env.get("SMTP_HOST"),
+		SMTPPort:               env.port("SMTP_PORT", "587"),
+		SMTPUsername:           env.get("SMTP_USERNAME"),
+		SMTPPassword:           env.get("SMTP_PASSWORD"),
+		MailFrom:               env.get("MAIL_FROM"),
+		ReminderAfter:          env.duration("REMINDER_AFTER", ""),
+		ReminderInterval:       env.interval("REMINDER_INTERVAL", "15m"),
+		AbandonmentInterval:    env.interval("ABANDONMENT_INTERVAL", "1h"),
+		CrossSellInterval:      env.interval("CROSS_SELL_INTERVAL", "24h"),
+		ReminderLinkTTL:        env.interval("REMINDER_LINK_TTL", "168h"),
+		CartHandoffTTL:         env.interval("CART_HANDOFF_TTL", "15m"),
+		WebhookPollInterval:    env.interval("WEBHOOK_POLL_INTERVAL", "5s"),
+		JobWorkers:             env.int("JOB_WORKERS", "4", 1),
+		JobPollInterval:        env.interval("JOB_POLL_INTERVAL", "1s"),
+		InventoryWebhookSecret: env.get("INVENTORY_WEBHOOK_SECRET"),
+		RestockInterval:        env.interval("RESTOCK_INTERVAL", "5m"),
+		LowStockInterval:       env.interval("LOW_STOCK_INTERVAL", "15m"),
+		LowStockSnooze:         env.interval("LOW_STOCK_SNOOZE", "24h"),
+		LowStockEmails:         env.get("LOW_STOCK_EMAILS"),
+		PriceDropRepriceCarts:  env.bool("PRICE_DROP_REPRICE_CARTS", "false"),
+		DownloadLimit:          env.int("DOWNLOAD_LIMIT", "5", 0),
+		DownloadTTL:            env.duration("DOWNLOAD_TTL", "72h"),
+		DownloadEmailInterval:  env.interval("DOWNLOAD_EMAIL_INTERVAL", "1m"),
+		SubscriptionDiscount:   env.amount("SUBSCRIPTION_DISCOUNT", "0"),
+		SubscriptionInterval:   env.interval("SUBSCRIPTION_INTERVAL", "15m"),
+		ReferralReward:         env.amount("REFERRAL_REWARD", "10"),
+		TaxRate:                env.amount("TAX_RATE", "0"),
+		VATID:                  env.get("VAT_ID"),
+		VIESURL:                env.getDefault("VIES_URL", vat.VIESURL),
+		VATCacheTTL:            env.interval("VAT_CACHE_TTL", "24h"),
+		ShippingCost:           env.amount("SHIPPING_COST", "0"),
+		FreeShippingFrom:       env.amount("FREE_SHIPPING_FROM", "0"),
+		Experiments:            env.get("EXPERIMENTS"),
+		Tenants:                env.get("TENANTS"),
+		ThemesDir:              env.get("THEMES_DIR"),
+		Theme:                  env.get("THEME"),
+		BotCheck:               env.bool("BOT_CHECK", "true"),
+		BotMinFillTime:         env.duration("BOT_MIN_FILL_TIME", "1s"),
+		HCaptchaSiteKey:        env.get("HCAPTCHA_SITE_KEY"),
+		HCaptchaSecret:         env.get("HCAPTCHA_SECRET"),
+		Currency:               env.getDefault("CURRENCY", "EUR"),
+		CurrencyRates:          env.get("CURRENCY_RATES"),
+		PaymentProvider:        env.get("PAYMENT_PROVIDER"),
+		PayPalClientID:         env.get("PAYPAL_CLIENT_ID"),
+		PayPalClientSecret:     env.get("PAYPAL_CLIENT_SECRET"),
+		PayPalEnvironment:      env.getDefault("PAYPAL_ENVIRONMENT", "sandbox"),
+		PayPalWebhookID:        env.get("PAYPAL_WEBHOOK_ID"),
+		CheckoutTTL:            env.interval("CHECKOUT_TTL", "30m"),
+		RiskVelocityLimit:      env.int("RISK_VELOCITY_LIMIT", "0", 0),
+		RiskVelocityWindow:     env.interval("RISK_VELOCITY_WINDOW", "1h"),
+		RiskVelocityAction:     env.getDefault("RISK_VELOCITY_ACTION", risk.ActionReview),
+		RiskCountryHeader:      env.get("RISK_COUNTRY_HEADER"),
+		RiskCountryAction:      env.getDefault("RISK_COUNTRY_ACTION", risk.ActionReview),
+		RiskServiceURL:         env.get("RISK_SERVICE_URL"),
+		RefundRestock:          env.bool("REFUND_RESTOCK", "false"),
+		WarehouseStrategy:      env.getDefault("WAREHOUSE_STRATEGY", warehouse.StrategyNearest),
+		OIDCIssuerURL:          env.get("OIDC_ISSUER_URL"),
+		OIDCClientID:           env.get("OIDC_CLIENT_ID"),
+		OIDCClientSecret:       env.get("OIDC_CLIENT_SECRET"),
+		OIDCRoleGroups:         env.get("OIDC_ROLE_GROUPS"),
+		OIDCGroupsClaim:        env.getDefault("OIDC_GROUPS_CLAIM", "groups"),
+		TOTPIssuer:             env.getDefault("TOTP_ISSUER", "Shopping Cart"),
+		RequireStaff2FA:        env.bool("REQUIRE_STAFF_2FA", "false"),
+		PasswordResetTTL:       env.interval("PASSWORD_RESET_TTL", "1h"),
+		LoginLinkTTL:           env.interval("LOGIN_LINK_TTL", "15m"),
+		EmailLimitPerIP:        env.int("EMAIL_LIMIT_PER_IP", "10", 1),
+		EmailLimitPerAddress:   env.int("EMAIL_LIMIT_PER_ADDRESS", "3", 1),
+		LoginFailuresPerEmail:  env.int("LOGIN_FAILURES_PER_EMAIL", "5", 1),
+		LoginFailuresPerIP:     env.int("LOGIN_FAILURES_PER_IP", "20", 1),
+		LoginLockout:           env.interval("LOGIN_LOCKOUT", "1m"),
+		LoginMaxLockout:        env.interval("LOGIN_MAX_LOCKOUT", "1h"),
+		VerificationLinkTTL:    env.interval("VERIFICATION_LINK_TTL", "48h"),
+		VerificationCooldown:   env.interval("VERIFICATION_COOLDOWN", "1m"),
+		RequireVerifiedEmail:   env.bool("REQUIRE_VERIFIED_EMAIL", "false"),
+		AnalyticsSinks:         env.get("ANALYTICS_SINKS"),
+		AnalyticsFile:          env.getDefault("ANALYTICS_FILE", "analytics.jsonl"),
+		SegmentWriteKey:        env.get("SEGMENT_WRITE_KEY"),
+		AnalyticsFlushInterval: env.interval("ANALYTICS_FLUSH_INTERVAL", "5s"),
 	}
 
-	if err := cfg.validate(); err != nil {
+	if err := errors.Join(append(env.errs, cfg.validate()...)...); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
 
 	return cfg, nil
 }
 
-// validate checks if all required configuration values are present.
-func (c *Config) validate() error {
-	if c.DBHost == "" {
-		return fmt.Errorf("DB_HOST is required")
+// envReader converts environment variables to typed values, collecting the variables that are
+// missing or invalid instead of stopping at the first.
+type envReader struct {
+	lookup func(string) (string, bool)
+	errs   []error
+}
+
+// get returns the value of the variable, empty when it is unset.
+func (e *envReader) get(key string) string {
+	value, _ := e.lookup(key)
+	return value
+}
+
+// getDefault returns the value of the variable or fallback when it is unset.
+func (e *envReader) getDefault(key, fallback string) string {
+	if value, ok := e.lookup(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func (e *envReader) fail(format string, args ...any) {
+	e.errs = append(e.errs, fmt.Errorf(format, args...))
+}
+
+// required returns the value of a variable that must be set.
+func (e *envReader) required(key string) string {
+	value := e.get(key)
+	if value == "" {
+		e.fail("%s is required", key)
+	}
+	return value
+}
+
+// port returns a port number, required when fallback is empty.
+func (e *envReader) port(key, fallback string) int {
+	value := e.getDefault(key, fallback)
+	if value == "" {
+		e.fail("%s is required", key)
+		return 0
+	}
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		e.fail("%s must be a port number, got %q", key, value)
+	}
+	return port
+}
+
+// optionalPort returns a port number, 0 when the variable is empty.
+func (e *envReader) optionalPort(key string) int {
+	if e.get(key) == "" {
+		return 0
+	}
+	return e.port(key, "")
+}
+
+// int returns a whole number of at least atLeast.
+func (e *envReader) int(key, fallback string, atLeast int) int {
+	value := e.getDefault(key, fallback)
+	n, err := strconv.Atoi(value)
+	if err != nil || n < atLeast {
+		e.fail("%s must be a whole number of at least %d, got %q", key, atLeast, value)
+	}
+	return n
+}
+
+// duration returns a duration such as "1h30m" that may be 0, which an empty value stands for.
+func (e *envReader) duration(key, fallback string) time.Duration {
+	value := e.getDefault(key, fallback)
+	if value == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		e.fail("%s must be a duration such as 30s or 1h, got %q", key, value)
+	}
+	return d
+}
+
+// interval returns a positive duration.
+func (e *envReader) interval(key, fallback string) time.Duration {
+	value := e.getDefault(key, fallback)
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		e.fail("%s must be a positive duration such as 30s or 1h, got %q", key, value)
+	}
+	return d
+}
+
+// bool returns whether a variable is "true" or "false".
+func (e *envReader) bool(key, fallback string) bool {
+	value := e.getDefault(key, fallback)
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		e.fail("%s must be true or false, got %q", key, value)
+	}
+	return b
+}
+
+// amount returns a non-negative amount of money.
+func (e *envReader) amount(key, fallback string) float64 {
+	value := e.getDefault(key, fallback)
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil || amount < 0 {
+		e.fail("%s must be a non-negative amount, got %q", key, value)
+	}
+	return amount
+}
+
+// validate checks that the configuration values fit together, returning every problem found.
+func (c *Config) validate() []error {
+	var errs []error
+	fail := func(message string) {
+		errs = append(errs, errors.New(message))
+	}
+
+	switch {
+	case c.SocketActivated:
+	case strings.HasPrefix(c.APIListen, "unix://"):
+		if strings.TrimPrefix(c.APIListen, "unix://") == "" {
+			fail("API_LISTEN needs the path of the unix socket, e.g. unix:///run/cart.sock")
+		}
+	case strings.HasPrefix(c.APIListen, "tcp://"):
+		if _, _, err := net.SplitHostPort(strings.TrimPrefix(c.APIListen, "tcp://")); err != nil {
+			fail("API_LISTEN needs a host and port after tcp://, e.g. tcp://127.0.0.1:8088")
+		}
+	case c.APIListen != "":
+		fail("API_LISTEN must start with unix:// or tcp://")
+	case c.APIPort == 0:
+		fail("API_PORT is required unless API_LISTEN is set or systemd passes the socket")
+	}
+	switch c.LogLevel {
+	case "info", "warn", "error":
+	default:
+		fail("LOG_LEVEL must be info, warn or error")
+	}
+	if c.SessionLimitCarts != user.EvictedCartsKeep && c.SessionLimitCarts != user.EvictedCartsMove {
+		fail("SESSION_LIMIT_CARTS must be keep or move")
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		fail("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if c.TLSCertFile != "" && c.AutoTLSDomain != "" {
+		fail("TLS_CERT_FILE can't be combined with AUTO_TLS_DOMAIN")
+	}
+	if c.AutoTLSDomain != "" && c.AutoTLSCacheDir == "" {
+		fail("AUTO_TLS_CACHE_DIR is required with AUTO_TLS_DOMAIN")
+	}
+	if (c.GoogleClientID != "" || c.GitHubClientID != "") && c.OAuthRedirectBaseURL == "" {
+		fail("OAUTH_REDIRECT_BASE_URL is required when a login provider is configured")
+	}
+	if c.CORSAllowCredentials && strings.Contains(c.CORSAllowedOrigins, "*") {
+		fail("CORS_ALLOW_CREDENTIALS can't be combined with a wildcard in CORS_ALLOWED_ORIGINS")
+	}
+	if (c.AdminUser == "") != (c.AdminPassword == "") {
+		fail("ADMIN_USER and ADMIN_PASSWORD must be set together")
+	}
+	for _, list := range []struct{ key, value string }{
+		{"ADMIN_ALLOWED_IPS", c.AdminAllowedIPs},
+		{"ADMIN_DENIED_IPS", c.AdminDeniedIPs},
+		{"TRUSTED_PROXIES", c.TrustedProxies},
+	} {
+		if _, err := ipfilter.ParseList(list.value); err != nil {
+			fail(fmt.Sprintf("%s is invalid: %v", list.key, err))
+		}
+	}
+	if c.OIDCIssuerURL != "" {
+		if c.OIDCClientID == "" || c.OIDCClientSecret == "" || c.OIDCRoleGroups == "" {
+			fail("OIDC_CLIENT_ID, OIDC_CLIENT_SECRET and OIDC_ROLE_GROUPS are required with OIDC_ISSUER_URL")
+		}
+		if c.OAuthRedirectBaseURL == "" {
+			fail("OAUTH_REDIRECT_BASE_URL is required with OIDC_ISSUER_URL")
+		}
+	}
+	switch c.StorageBackend {
+	case "local":
+		if c.StorageLocalDir == "" {
+			fail("STORAGE_LOCAL_DIR is required with the local storage backend")
+		}
+	case "s3":
+		if c.S3Region == "" || c.S3Bucket == "" || c.S3AccessKeyID == "" || c.S3SecretAccessKey == "" {
+			fail("S3_REGION, S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY are required with the s3 storage backend")
+		}
+	default:
+		fail("STORAGE_BACKEND must be local or s3")
+	}
+	if c.SMTPHost != "" && c.MailFrom == "" {
+		fail("MAIL_FROM is required with SMTP_HOST")
 	}
-	if c.DBPort == "" {
-		return fmt.Errorf("DB_PORT is required")
+	if c.LoginMaxLockout < c.LoginLockout {
+		fail("LOGIN_MAX_LOCKOUT can't be shorter than LOGIN_LOCKOUT")
 	}
-	if c.DBUser == "" {
-		return fmt.Errorf("DB_USER is required")
+	if c.RequireVerifiedEmail && c.PublicBaseURL == "" {
+		fail("PUBLIC_BASE_URL is required with REQUIRE_VERIFIED_EMAIL")
 	}
-	if c.DBPassword == "" {
-		return fmt.Errorf("DB_PASSWORD is required")
+	if c.ReminderAfter > 0 && c.PublicBaseURL == "" {
+		fail("PUBLIC_BASE_URL is required with REMINDER_AFTER")
 	}
-	if c.DBName == "" {
-		return fmt.Errorf("DB_DATABASE is required")
+	switch c.PaymentProvider {
+	case "", "fake":
+	case "paypal":
+		if c.PayPalClientID == "" || c.PayPalClientSecret == "" || c.PayPalWebhookID == "" {
+			fail("PAYPAL_CLIENT_ID, PAYPAL_CLIENT_SECRET and PAYPAL_WEBHOOK_ID are required with the paypal payment provider")
+		}
+		if c.PayPalEnvironment != "sandbox" && c.PayPalEnvironment != "live" {
+			fail("PAYPAL_ENVIRONMENT must be sandbox or live")
+		}
+	default:
+		fail("PAYMENT_PROVIDER must be paypal, fake or empty")
 	}
-	if c.SessionSecret == "" {
-		return fmt.Errorf("SESSION_SECRET is required")
+	if c.PaymentProvider != "" && c.PublicBaseURL == "" {
+		fail("PUBLIC_BASE_URL is required with PAYMENT_PROVIDER")
 	}
-	if c.SessionName == "" {
-		return fmt.Errorf("SESSION_NAME is required")
+	if !risk.ValidAction(c.RiskVelocityAction) {
+		fail("RISK_VELOCITY_ACTION must be allow, review or block")
 	}
-	if c.APIPort == "" {
-		return fmt.Errorf("API_PORT is required")
+	if !risk.ValidAction(c.RiskCountryAction) {
+		fail("RISK_COUNTRY_ACTION must be allow, review or block")
+	}
+	if _, err := warehouse.NewStrategy(c.WarehouseStrategy); err != nil {
+		fail("WAREHOUSE_STRATEGY must be nearest or most-stock")
+	}
+	if _, err := cache.NewRedis(c.CacheURL); c.CacheURL != "" && err != nil {
+		fail(fmt.Sprintf("CACHE_URL is invalid: %v", err))
+	}
+	if c.TaxRate > 100 {
+		fail("TAX_RATE is a percentage and can't exceed 100")
+	}
+	if _, _, err := vat.Parse(c.VATID); c.VATID != "" && err != nil {
+		fail("VAT_ID must be an EU VAT ID with its country prefix, e.g. DE123456789")
+	}
+	if c.SubscriptionDiscount > 100 {
+		fail("SUBSCRIPTION_DISCOUNT is a percentage and can't exceed 100")
+	}
+	if _, err := experiment.Parse(c.Experiments); err != nil {
+		fail(fmt.Sprintf("EXPERIMENTS is invalid: %v", err))
+	}
+	if _, err := tenant.Parse(c.Tenants); err != nil {
+		fail(fmt.Sprintf("TENANTS is invalid: %v", err))
+	}
+	if c.Theme != "" && c.ThemesDir == "" {
+		fail("THEMES_DIR is required with THEME")
+	}
+	if (c.HCaptchaSiteKey == "") != (c.HCaptchaSecret == "") {
+		fail("HCAPTCHA_SITE_KEY and HCAPTCHA_SECRET must be set together")
+	}
+	if c.HCaptchaSiteKey != "" && !c.BotCheck {
+		fail("BOT_CHECK must be enabled with HCAPTCHA_SITE_KEY")
+	}
+	for _, sink := range strings.Split(c.AnalyticsSinks, ",") {
+		switch strings.TrimSpace(sink) {
+		case "", "db":
+		case "file":
+			if c.AnalyticsFile == "" {
+				fail("ANALYTICS_FILE is required with the file analytics sink")
+			}
+		case "segment":
+			if c.SegmentWriteKey == "" {
+				fail("SEGMENT_WRITE_KEY is required with the segment analytics sink")
+			}
+		default:
+			fail(fmt.Sprintf("ANALYTICS_SINKS may only list db, file and segment, got %q", strings.TrimSpace(sink)))
+		}
+	}
+	return errs
+}
+
+// Reloaded returns the configuration with the settings that may change while the server runs taken
+// from next: the log level, the email limits, maintenance mode, the REQUIRE_STAFF_2FA and EXPERIMENTS
+// feature flags and the session lifetime. Everything else needs a restart.
+func (c Config) Reloaded(next Config) Config {
+	c.LogLevel = next.LogLevel
+	c.MaintenanceMode = next.MaintenanceMode
+	c.EmailLimitPerIP = next.EmailLimitPerIP
+	c.EmailLimitPerAddress = next.EmailLimitPerAddress
+	c.RequireStaff2FA = next.RequireStaff2FA
+	c.Experiments = next.Experiments
+	c.SessionMaxAge = next.SessionMaxAge
+	return c
+}
+
+// secretFields are the settings Redacted hides. Replica DSNs and the cache URL contain passwords too.
+var secretFields = map[string]bool{
+	"DBPassword":             true,
+	"DBReplicaDSNs":          true,
+	"SessionSecret":          true,
+	"JWTSigningKeys":         true,
+	"GoogleClientSecret":     true,
+	"GitHubClientSecret":     true,
+	"AdminPassword":          true,
+	"OIDCClientSecret":       true,
+	"S3SecretAccessKey":      true,
+	"SMTPPassword":           true,
+	"SegmentWriteKey":        true,
+	"PayPalClientSecret":     true,
+	"CacheURL":               true,
+	"HCaptchaSecret":         true,
+	"InventoryWebhookSecret": true,
+}
+
+// Redacted returns the settings by field name for display, with secrets that are set replaced by
+// "REDACTED" and durations written like "15m0s".
+func (c Config) Redacted() map[string]any {
+	settings := map[string]any{}
+	v := reflect.ValueOf(c)
+	for i := 0; i < v.NumField(); i++ {
+		name, field := v.Type().Field(i).Name, v.Field(i)
+		switch value := field.Interface().(type) {
+		case time.Duration:
+			settings[name] = value.String()
+		default:
+			if secretFields[name] && !field.IsZero() {
+				value = "REDACTED"
+			}
+			settings[name] = value
+		}
 	}
-	return nil
+	return settings
 }
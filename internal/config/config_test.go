@@ -0,0 +1,390 @@
+package config_test
+
+import (
+	"interview/internal/config"
+	"interview/internal/vat"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setRequired sets the variables without defaults to valid values
+func setRequired(t *testing.T) {
+	t.Helper()
+	for key, value := range map[string]string{
+		"DB_HOST":        "localhost",
+		"DB_PORT":        "3306",
+		"DB_USER":        "cart",
+		"DB_PASSWORD":    "secret",
+		"DB_DATABASE":    "cart",
+		"SESSION_SECRET": "session-secret",
+		"SESSION_NAME":   "cart_session",
+		"API_PORT":       "8088",
+	} {
+		t.Setenv(key, value)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("typed values and defaults", func(t *testing.T) {
+		setRequired(t)
+		t.Setenv("PRICE_REFRESH_AFTER", "")
+		t.Setenv("CSP_REPORT_ONLY", "true")
+		t.Setenv("REFERRAL_REWARD", "2.5")
+
+		cfg, err := config.Load()
+		require.NoError(t, err)
+		assert.Equal(t, 3306, cfg.DBPort)
+		assert.Equal(t, 8088, cfg.APIPort)
+		assert.Equal(t, 587, cfg.SMTPPort)
+		assert.Equal(t, 25, cfg.DBMaxOpenConns)
+		assert.Equal(t, 5*time.Minute, cfg.DBConnMaxLifetime)
+		assert.Equal(t, 200*time.Millisecond, cfg.DBSlowQueryThreshold)
+		assert.Equal(t, 5*time.Second, cfg.DBQueryTimeout)
+		assert.Equal(t, 15*time.Minute, cfg.SessionCleanupInterval)
+		assert.Equal(t, 1000, cfg.SessionCleanupBatch)
+		assert.Equal(t, 5, cfg.DBBreakerThreshold)
+		assert.Equal(t, 30*time.Second, cfg.DBBreakerCooldown)
+		assert.Equal(t, 15*time.Minute, cfg.LoginLinkTTL)
+		assert.Equal(t, 4, cfg.JobWorkers)
+		assert.Equal(t, time.Second, cfg.JobPollInterval)
+		assert.Equal(t, 5*time.Minute, cfg.CartUndoWindow)
+		assert.Zero(t, cfg.ReservationTTL, "reservations are off by default")
+		assert.Equal(t, 30*time.Second, cfg.ReservationInterval)
+		assert.Equal(t, time.Hour, cfg.AbandonmentInterval)
+		assert.Equal(t, 24*time.Hour, cfg.CrossSellInterval)
+		assert.Zero(t, cfg.PriceRefreshAfter, "an empty duration disables refreshing")
+		assert.Zero(t, cfg.ReminderAfter)
+		assert.True(t, cfg.CSPReportOnly)
+		assert.False(t, cfg.CORSAllowCredentials)
+		assert.Equal(t, 2.5, cfg.ReferralReward)
+	})
+
+	t.Run("lists every problem", func(t *testing.T) {
+		setRequired(t)
+		t.Setenv("DB_HOST", "")
+		t.Setenv("API_PORT", "http")
+		t.Setenv("DB_CONNECT_ATTEMPTS", "0")
+		t.Setenv("LOGIN_LINK_TTL", "0s")
+		t.Setenv("CSP_REPORT_ONLY", "yes")
+		t.Setenv("REFERRAL_REWARD", "-1")
+		t.Setenv("ADMIN_USER", "admin")
+
+		_, err := config.Load()
+		require.Error(t, err)
+		for _, problem := range []string{
+			"DB_HOST is required",
+			`API_PORT must be a port number, got "http"`,
+			`DB_CONNECT_ATTEMPTS must be a whole number of at least 1, got "0"`,
+			`LOGIN_LINK_TTL must be a positive duration`,
+			`CSP_REPORT_ONLY must be true or false, got "yes"`,
+			`REFERRAL_REWARD must be a non-negative amount, got "-1"`,
+			"ADMIN_USER and ADMIN_PASSWORD must be set together",
+		} {
+			assert.Contains(t, err.Error(), problem)
+		}
+	})
+
+	t.Run("listen address", func(t *testing.T) {
+		setRequired(t)
+		t.Setenv("API_PORT", "")
+		t.Setenv("API_LISTEN", "unix:///run/cart.sock")
+		cfg, err := config.Load()
+		require.NoError(t, err, "API_PORT isn't needed with API_LISTEN")
+		assert.Equal(t, "unix:///run/cart.sock", cfg.APIListen)
+
+		for listen, problem := range map[string]string{
+			"":                 "API_PORT is required unless API_LISTEN is set",
+			"unix://":          "API_LISTEN needs the path of the unix socket",
+			"tcp://localhost":  "API_LISTEN needs a host and port after tcp://",
+			"http://localhost": "API_LISTEN must start with unix:// or tcp://",
+		} {
+			t.Setenv("API_LISTEN", listen)
+			_, err := config.Load()
+			assert.ErrorContains(t, err, problem, listen)
+		}
+	})
+
+	t.Run("checks combined settings", func(t *testing.T) {
+		setRequired(t)
+		t.Setenv("REMINDER_AFTER", "24h")
+		t.Setenv("PUBLIC_BASE_URL", "")
+		t.Setenv("CORS_ALLOWED_ORIGINS", "*")
+		t.Setenv("CORS_ALLOW_CREDENTIALS", "true")
+
+		_, err := config.Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "PUBLIC_BASE_URL is required with REMINDER_AFTER")
+		assert.Contains(t, err.Error(), "CORS_ALLOW_CREDENTIALS can't be combined with a wildcard in CORS_ALLOWED_ORIGINS")
+	})
+
+	t.Run("checks the settings of the payment provider", func(t *testing.T) {
+		setRequired(t)
+		t.Setenv("PAYMENT_PROVIDER", "paypal")
+		t.Setenv("PAYPAL_CLIENT_ID", "client")
+		t.Setenv("PAYPAL_ENVIRONMENT", "production")
+
+		_, err := config.Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "PAYPAL_CLIENT_ID, PAYPAL_CLIENT_SECRET and PAYPAL_WEBHOOK_ID are required with the paypal payment provider")
+		assert.Contains(t, err.Error(), "PAYPAL_ENVIRONMENT must be sandbox or live")
+		assert.Contains(t, err.Error(), "PUBLIC_BASE_URL is required with PAYMENT_PROVIDER")
+
+		t.Setenv("PAYMENT_PROVIDER", "bitcoin")
+		_, err = config.Load()
+		assert.ErrorContains(t, err, "PAYMENT_PROVIDER must be paypal, fake or empty")
+
+		t.Setenv("PAYMENT_PROVIDER", "fake")
+		_, err = config.Load()
+		require.Error(t, err)
+		assert.NotContains(t, err.Error(), "PAYPAL_CLIENT_ID")
+		assert.Contains(t, err.Error(), "PUBLIC_BASE_URL is required with PAYMENT_PROVIDER")
+
+		t.Setenv("PAYMENT_PROVIDER", "paypal")
+		t.Setenv("PAYPAL_CLIENT_SECRET", "secret")
+		t.Setenv("PAYPAL_WEBHOOK_ID", "WH-1")
+		t.Setenv("PAYPAL_ENVIRONMENT", "live")
+		t.Setenv("PUBLIC_BASE_URL", "https://shop.example.com")
+		c, err := config.Load()
+		require.NoError(t, err)
+		assert.Equal(t, "REDACTED", c.Redacted()["PayPalClientSecret"])
+	})
+
+	t.Run("checks the risk actions", func(t *testing.T) {
+		setRequired(t)
+		c, err := config.Load()
+		require.NoError(t, err)
+		assert.Equal(t, "review", c.RiskVelocityAction)
+		assert.Equal(t, time.Hour, c.RiskVelocityWindow)
+
+		t.Setenv("RISK_VELOCITY_ACTION", "deny")
+		t.Setenv("RISK_COUNTRY_ACTION", "hold")
+		t.Setenv("RISK_VELOCITY_LIMIT", "-1")
+		_, err = config.Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "RISK_VELOCITY_ACTION must be allow, review or block")
+		assert.Contains(t, err.Error(), "RISK_COUNTRY_ACTION must be allow, review or block")
+		assert.Contains(t, err.Error(), "RISK_VELOCITY_LIMIT must be a whole number of at least 0")
+	})
+
+	t.Run("checks the warehouse strategy", func(t *testing.T) {
+		setRequired(t)
+		c, err := config.Load()
+		require.NoError(t, err)
+		assert.Equal(t, "nearest", c.WarehouseStrategy)
+
+		t.Setenv("WAREHOUSE_STRATEGY", "cheapest")
+		_, err = config.Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "WAREHOUSE_STRATEGY must be nearest or most-stock")
+	})
+
+	t.Run("checks the session limit", func(t *testing.T) {
+		setRequired(t)
+		c, err := config.Load()
+		require.NoError(t, err)
+		assert.Zero(t, c.MaxSessionsPerUser, "sessions are unlimited by default")
+		assert.Equal(t, "keep", c.SessionLimitCarts)
+
+		t.Setenv("MAX_SESSIONS_PER_USER", "-1")
+		t.Setenv("SESSION_LIMIT_CARTS", "delete")
+		_, err = config.Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "MAX_SESSIONS_PER_USER must be a whole number of at least 0")
+		assert.Contains(t, err.Error(), "SESSION_LIMIT_CARTS must be keep or move")
+	})
+
+	t.Run("checks the login lockout", func(t *testing.T) {
+		setRequired(t)
+		c, err := config.Load()
+		require.NoError(t, err)
+		assert.Equal(t, 5, c.LoginFailuresPerEmail)
+		assert.Equal(t, 20, c.LoginFailuresPerIP)
+		assert.Equal(t, time.Minute, c.LoginLockout)
+		assert.Equal(t, time.Hour, c.LoginMaxLockout)
+
+		t.Setenv("LOGIN_LOCKOUT", "2h")
+		_, err = config.Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "LOGIN_MAX_LOCKOUT can't be shorter than LOGIN_LOCKOUT")
+	})
+
+	t.Run("requires the base URL for email verification", func(t *testing.T) {
+		setRequired(t)
+		c, err := config.Load()
+		require.NoError(t, err)
+		assert.False(t, c.RequireVerifiedEmail)
+		assert.Equal(t, 48*time.Hour, c.VerificationLinkTTL)
+		assert.Equal(t, time.Minute, c.VerificationCooldown)
+
+		t.Setenv("REQUIRE_VERIFIED_EMAIL", "true")
+		_, err = config.Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "PUBLIC_BASE_URL is required with REQUIRE_VERIFIED_EMAIL")
+
+		t.Setenv("PUBLIC_BASE_URL", "https://shop.example.com")
+		c, err = config.Load()
+		require.NoError(t, err)
+		assert.True(t, c.RequireVerifiedEmail)
+	})
+
+	t.Run("checks the VAT ID of the shop", func(t *testing.T) {
+		setRequired(t)
+		c, err := config.Load()
+		require.NoError(t, err)
+		assert.Empty(t, c.VATID)
+		assert.Equal(t, vat.VIESURL, c.VIESURL)
+		assert.Equal(t, 24*time.Hour, c.VATCacheTTL)
+
+		t.Setenv("VAT_ID", "DE 811 569 869")
+		_, err = config.Load()
+		require.NoError(t, err)
+
+		t.Setenv("VAT_ID", "811569869")
+		_, err = config.Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "VAT_ID must be an EU VAT ID with its country prefix")
+	})
+
+	t.Run("checks the cache URL", func(t *testing.T) {
+		setRequired(t)
+		c, err := config.Load()
+		require.NoError(t, err)
+		assert.Empty(t, c.CacheURL)
+		assert.Equal(t, time.Minute, c.CacheTTL)
+
+		t.Setenv("CACHE_URL", "redis://:secret@cache:6379/1")
+		c, err = config.Load()
+		require.NoError(t, err)
+		assert.Equal(t, "REDACTED", c.Redacted()["CacheURL"])
+
+		t.Setenv("CACHE_URL", "memcached://cache:11211")
+		t.Setenv("CACHE_TTL", "0s")
+		_, err = config.Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "CACHE_URL is invalid: redis URL must start with redis:// or rediss://")
+		assert.Contains(t, err.Error(), "CACHE_TTL must be a positive duration")
+	})
+
+	t.Run("checks the tenants", func(t *testing.T) {
+		setRequired(t)
+		t.Setenv("TENANTS", "acme=shop.acme.com;globex=globex.example")
+		c, err := config.Load()
+		require.NoError(t, err)
+		assert.Equal(t, "acme=shop.acme.com;globex=globex.example", c.Tenants)
+
+		t.Setenv("TENANTS", "acme=shop.example;globex=shop.example")
+		_, err = config.Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `TENANTS is invalid: host "shop.example" is served by both "acme" and "globex"`)
+	})
+
+	t.Run("checks the theme", func(t *testing.T) {
+		setRequired(t)
+		t.Setenv("THEME", "dark")
+		_, err := config.Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "THEMES_DIR is required with THEME")
+
+		t.Setenv("THEMES_DIR", "/etc/shop/themes")
+		c, err := config.Load()
+		require.NoError(t, err)
+		assert.Equal(t, "dark", c.Theme)
+	})
+
+	t.Run("checks the bot check", func(t *testing.T) {
+		setRequired(t)
+		c, err := config.Load()
+		require.NoError(t, err)
+		assert.True(t, c.BotCheck)
+		assert.Equal(t, time.Second, c.BotMinFillTime)
+
+		t.Setenv("HCAPTCHA_SITE_KEY", "site-key")
+		_, err = config.Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "HCAPTCHA_SITE_KEY and HCAPTCHA_SECRET must be set together")
+
+		t.Setenv("HCAPTCHA_SECRET", "captcha-secret")
+		t.Setenv("BOT_CHECK", "false")
+		_, err = config.Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "BOT_CHECK must be enabled with HCAPTCHA_SITE_KEY")
+
+		t.Setenv("BOT_CHECK", "true")
+		c, err = config.Load()
+		require.NoError(t, err)
+		assert.Equal(t, "REDACTED", c.Redacted()["HCaptchaSecret"])
+	})
+
+	t.Run("checks the admin networks", func(t *testing.T) {
+		setRequired(t)
+		c, err := config.Load()
+		require.NoError(t, err)
+		assert.Equal(t, "127.0.0.1,::1", c.TrustedProxies)
+
+		t.Setenv("ADMIN_ALLOWED_IPS", "10.0.0.0/8,office")
+		t.Setenv("TRUSTED_PROXIES", "10.0.0.1/40")
+		_, err = config.Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `ADMIN_ALLOWED_IPS is invalid: invalid address "office"`)
+		assert.Contains(t, err.Error(), `TRUSTED_PROXIES is invalid: invalid network "10.0.0.1/40"`)
+
+		t.Setenv("ADMIN_ALLOWED_IPS", "10.0.0.0/8, 192.0.2.1")
+		t.Setenv("ADMIN_DENIED_IPS", "10.6.0.0/16")
+		t.Setenv("TRUSTED_PROXIES", "10.0.0.1")
+		c, err = config.Load()
+		require.NoError(t, err)
+		assert.Equal(t, "10.0.0.0/8, 192.0.2.1", c.AdminAllowedIPs)
+		assert.Equal(t, "10.6.0.0/16", c.AdminDeniedIPs)
+	})
+}
+
+func TestReload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), ".env")
+	require.NoError(t, os.WriteFile(file, []byte(`DB_HOST=localhost
+DB_PORT=3306
+DB_USER=cart
+DB_PASSWORD=secret
+DB_DATABASE=cart
+SESSION_SECRET=session-secret
+SESSION_NAME=cart_session
+API_PORT=8088
+LOG_LEVEL=warn
+SESSION_MAX_AGE=2h
+`), 0o600))
+
+	next, err := config.Reload(file)
+	require.NoError(t, err)
+	assert.Equal(t, "warn", next.LogLevel)
+	assert.Equal(t, 2*time.Hour, next.SessionMaxAge)
+
+	current := config.Config{APIPort: 9000, LogLevel: "info", SessionMaxAge: time.Hour}
+	reloaded := current.Reloaded(*next)
+	assert.Equal(t, 9000, reloaded.APIPort, "the port only changes on restart")
+	assert.Equal(t, "warn", reloaded.LogLevel)
+	assert.Equal(t, 2*time.Hour, reloaded.SessionMaxAge)
+
+	require.NoError(t, os.WriteFile(file, []byte("LOG_LEVEL=verbose\n"), 0o600))
+	_, err = config.Reload(file)
+	assert.ErrorContains(t, err, "LOG_LEVEL must be info, warn or error")
+}
+
+func TestRedacted(t *testing.T) {
+	settings := config.Config{
+		DBHost:        "localhost",
+		DBPassword:    "secret",
+		SMTPPassword:  "",
+		LoginLinkTTL:  15 * time.Minute,
+		DBReplicaDSNs: "user:pass@tcp(replica:3306)/cart",
+	}.Redacted()
+
+	assert.Equal(t, "localhost", settings["DBHost"])
+	assert.Equal(t, "REDACTED", settings["DBPassword"])
+	assert.Equal(t, "REDACTED", settings["DBReplicaDSNs"])
+	assert.Equal(t, "", settings["SMTPPassword"], "unset secrets show that they are unset")
+	assert.Equal(t, "15m0s", settings["LoginLinkTTL"])
+}
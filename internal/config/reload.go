@@ -0,0 +1,83 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// EnvFile is the file of environment variables loaded on startup and watched for changes
+const EnvFile = ".env"
+
+// startupEnv holds the environment the process was started with, before an env file was loaded
+// into it, so it can take precedence over the file on reloads as it does on startup.
+var startupEnv = environ()
+
+func environ() map[string]string {
+	env := map[string]string{}
+	for _, entry := range os.Environ() {
+		if key, value, ok := strings.Cut(entry, "="); ok {
+			env[key] = value
+		}
+	}
+	return env
+}
+
+// Reload reads the configuration again from the environment the process was started with and the
+// current contents of the env file, the environment taking precedence. A missing file is fine.
+func Reload(file string) (*Config, error) {
+	values, err := godotenv.Read(file)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	return load(func(key string) (string, bool) {
+		if value, ok := startupEnv[key]; ok {
+			return value, true
+		}
+		value, ok := values[key]
+		return value, ok
+	})
+}
+
+// Watch calls reload whenever the process receives SIGHUP or the modification time of file changes,
+// which is checked every interval, until ctx is done.
+func Watch(ctx context.Context, file string, interval time.Duration, reload func()) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	modified := modTime(file)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			reload()
+		case <-ticker.C:
+			if t := modTime(file); !t.Equal(modified) {
+				modified = t
+				reload()
+			}
+		}
+	}
+}
+
+// modTime returns when the file was last modified, the zero time when it doesn't exist.
+func modTime(file string) time.Time {
+	info, err := os.Stat(file)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
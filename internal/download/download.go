@@ -0,0 +1,163 @@
+// Package download delivers the digital products of checked out carts: it signs the links their files
+// are downloaded from and emails them to customers.
+package download
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"interview/internal/mail"
+	productpkg "interview/internal/product"
+	"interview/internal/repo"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// batchSize is the largest number of emails sent by one run
+const batchSize = 100
+
+// PathPrefix is the path of the download endpoint, followed by the ID of the download
+const PathPrefix = "/downloads/"
+
+var emailTemplate = template.Must(template.New("download").Parse(`Hello {{ .Name }},
+
+thank you for your order ({{ .Order }}). Download your products here:
+{{ range .Downloads }}
+  {{ .ProductName }}: {{ .Link }}
+{{- with .Limits }}
+  ({{ . }})
+{{- end }}
+{{- end }}
+`))
+
+// Links builds and verifies the signed links digital products are downloaded from. How often and how
+// long a link works is tracked by its productpkg.Download.
+type Links struct {
+	baseURL string
+	secret  []byte
+}
+
+// NewLinks creates links to baseURL signed with secret
+func NewLinks(baseURL string, secret []byte) *Links {
+	return &Links{baseURL: strings.TrimSuffix(baseURL, "/"), secret: secret}
+}
+
+// URL returns the link to the download with the given ID
+func (l *Links) URL(downloadID uint) string {
+	return l.baseURL + PathPrefix + strconv.FormatUint(uint64(downloadID), 10) + "?signature=" + l.sign(downloadID)
+}
+
+// Verify reports whether the signature belongs to the download with the given ID
+func (l *Links) Verify(downloadID uint, signature string) bool {
+	return hmac.Equal([]byte(signature), []byte(l.sign(downloadID)))
+}
+
+func (l *Links) sign(downloadID uint) string {
+	mac := hmac.New(sha256.New, l.secret)
+	fmt.Fprintf(mac, "download:%d", downloadID)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sender emails the download links of checked out carts to their users
+type Sender struct {
+	repo   *repo.Repository
+	mailer mail.Mailer
+	links  *Links
+}
+
+// NewSender creates a Sender emailing links built by links
+func NewSender(r *repo.Repository, mailer mail.Mailer, links *Links) *Sender {
+	return &Sender{repo: r, mailer: mailer, links: links}
+}
+
+// Run emails the download links of every checked out cart whose links weren't sent yet and returns how
+// many emails were sent. Carts whose email fails are retried on the next run.
+func (s *Sender) Run(ctx context.Context) (int, error) {
+	purchases, err := s.repo.ListUnsentDownloads(batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var sent int
+	var errs []error
+	for _, purchased := range purchases {
+		if err := ctx.Err(); err != nil {
+			return sent, err
+		}
+
+		claimed, err := s.repo.ClaimDownloadEmail(purchased.Cart.ID)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		msg, err := s.message(purchased)
+		if err == nil {
+			err = s.mailer.Send(ctx, msg)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cart %d: %w", purchased.Cart.ID, err))
+			if err := s.repo.ReleaseDownloadEmail(purchased.Cart.ID); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		sent++
+	}
+	return sent, errors.Join(errs...)
+}
+
+func (s *Sender) message(purchased repo.PurchasedDownloads) (mail.Message, error) {
+	name := purchased.User.Name
+	if name == "" {
+		name = "there"
+	}
+
+	downloads := make([]map[string]string, len(purchased.Downloads))
+	for i, d := range purchased.Downloads {
+		downloads[i] = map[string]string{
+			"ProductName": d.ProductName,
+			"Link":        s.links.URL(d.ID),
+			"Limits":      limits(d),
+		}
+	}
+
+	var body strings.Builder
+	err := emailTemplate.Execute(&body, map[string]interface{}{
+		"Name":      name,
+		"Order":     fmt.Sprintf("%s/orders/%d", s.links.baseURL, purchased.Cart.ID),
+		"Downloads": downloads,
+	})
+	if err != nil {
+		return mail.Message{}, fmt.Errorf("failed to render download email: %w", err)
+	}
+
+	return mail.Message{
+		To:      purchased.User.Email,
+		Subject: "Your downloads",
+		Body:    body.String(),
+	}, nil
+}
+
+// limits describes how often and until when the download can be used, empty when it isn't limited
+func limits(d productpkg.Download) string {
+	var parts []string
+	switch remaining := d.Remaining(); remaining {
+	case -1:
+	case 1:
+		parts = append(parts, "1 download")
+	default:
+		parts = append(parts, strconv.Itoa(remaining)+" downloads")
+	}
+	if d.ExpiresAt != nil {
+		parts = append(parts, "until "+d.ExpiresAt.UTC().Format("2006-01-02 15:04 MST"))
+	}
+	return strings.Join(parts, ", ")
+}
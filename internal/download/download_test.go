@@ -0,0 +1,138 @@
+package download_test
+
+import (
+	"context"
+	"errors"
+	cartpkg "interview/internal/cart"
+	"interview/internal/download"
+	"interview/internal/mail"
+	productpkg "interview/internal/product"
+	"interview/internal/repo"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type fakeMailer struct {
+	sent []mail.Message
+	err  error
+}
+
+func (m *fakeMailer) Send(_ context.Context, msg mail.Message) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func TestLinks(t *testing.T) {
+	links := download.NewLinks("https://shop.example.com/", []byte("secret"))
+
+	parse := func(t *testing.T, link string) (uint, string) {
+		u, err := url.Parse(link)
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(u.Path, download.PathPrefix))
+		assert.Equal(t, "https://shop.example.com", u.Scheme+"://"+u.Host)
+		id, err := strconv.ParseUint(strings.TrimPrefix(u.Path, download.PathPrefix), 10, 64)
+		require.NoError(t, err)
+		return uint(id), u.Query().Get("signature")
+	}
+
+	t.Run("Valid Link", func(t *testing.T) {
+		id, signature := parse(t, links.URL(42))
+		assert.Equal(t, uint(42), id)
+		assert.True(t, links.Verify(id, signature))
+	})
+
+	t.Run("Tampered Download", func(t *testing.T) {
+		_, signature := parse(t, links.URL(42))
+		assert.False(t, links.Verify(43, signature))
+	})
+
+	t.Run("Other Secret", func(t *testing.T) {
+		id, signature := parse(t, links.URL(42))
+		assert.False(t, download.NewLinks("https://shop.example.com", []byte("other")).Verify(id, signature))
+	})
+}
+
+func TestSender(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, repo.Migrate(db))
+	cartRepo := repo.NewRepository(db)
+	cartRepo.SetDownloadLimits(3, 0)
+
+	ebook, err := cartRepo.UpsertProduct("ebook", 5)
+	require.NoError(t, err)
+	require.NoError(t, cartRepo.SetProductFile(ebook.ID, "products/1/file.pdf"))
+	require.NoError(t, cartRepo.SetProductType(ebook.ID, productpkg.TypeDigital))
+	user, err := cartRepo.FindOrCreateUser("github", "1", "jane@example.com", "Jane")
+	require.NoError(t, err)
+	c, err := cartRepo.GetOrCreateCart("session", cartpkg.DefaultName)
+	require.NoError(t, err)
+	require.NoError(t, cartRepo.AddCartItem(c.ID, "ebook", 1, 5))
+	require.NoError(t, cartRepo.AssignCartToUser("session", user.ID))
+	require.NoError(t, cartRepo.CloseCart(c.ID))
+	downloads, err := cartRepo.ListDownloads(c.ID)
+	require.NoError(t, err)
+	require.Len(t, downloads, 1)
+
+	links := download.NewLinks("https://shop.example.com", []byte("secret"))
+
+	t.Run("Failed Emails Are Retried", func(t *testing.T) {
+		mailer := &fakeMailer{err: errors.New("connection refused")}
+		sent, err := download.NewSender(cartRepo, mailer, links).Run(context.Background())
+		require.Error(t, err)
+		assert.Equal(t, 0, sent)
+
+		unsent, err := cartRepo.ListUnsentDownloads(10)
+		require.NoError(t, err)
+		assert.Len(t, unsent, 1)
+	})
+
+	t.Run("Sends The Links Once", func(t *testing.T) {
+		mailer := &fakeMailer{}
+		sender := download.NewSender(cartRepo, mailer, links)
+
+		sent, err := sender.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, sent)
+		require.Len(t, mailer.sent, 1)
+		msg := mailer.sent[0]
+		assert.Equal(t, "jane@example.com", msg.To)
+		assert.Contains(t, msg.Body, "Hello Jane")
+		assert.Contains(t, msg.Body, "https://shop.example.com/orders/"+strconv.FormatUint(uint64(c.ID), 10))
+		assert.Contains(t, msg.Body, "ebook: "+links.URL(downloads[0].ID))
+		assert.Contains(t, msg.Body, "(3 downloads)")
+
+		sent, err = sender.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 0, sent)
+		assert.Len(t, mailer.sent, 1)
+	})
+
+	t.Run("Mentions The Expiry", func(t *testing.T) {
+		cartRepo.SetDownloadLimits(0, time.Hour)
+		c, err := cartRepo.GetOrCreateCart("other-session", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "ebook", 1, 5))
+		require.NoError(t, cartRepo.AssignCartToUser("other-session", user.ID))
+		require.NoError(t, cartRepo.CloseCart(c.ID))
+
+		mailer := &fakeMailer{}
+		sent, err := download.NewSender(cartRepo, mailer, links).Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, sent)
+		require.Len(t, mailer.sent, 1)
+		assert.Contains(t, mailer.sent[0].Body, "(until ")
+		assert.NotContains(t, mailer.sent[0].Body, "downloads)")
+	})
+}
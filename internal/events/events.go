@@ -0,0 +1,140 @@
+// Package events is an in-process publish/subscribe bus for cart activity, used to stream live
+// updates to the admin dashboard and to queue notifications and webhooks.
+package events
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Event types published by the cart handlers
+const (
+	TypeItemAdded        = "item_added"
+	TypeItemRemoved      = "item_removed"
+	TypeGiftCardRedeemed = "gift_card_redeemed"
+	TypeReferralApplied  = "referral_applied"
+	TypeCartClosed       = "cart_closed"
+	// TypeOrderStatusChanged is published by the checkout and by staff changing the status of an order
+	TypeOrderStatusChanged = "order_status_changed"
+	// TypeLowStock is published when the stock of a product falls below its low-stock threshold
+	TypeLowStock = "low_stock"
+	// TypePriceDropped is published when an item of a cart is repriced lower, with its new Price
+	TypePriceDropped = "price_dropped"
+)
+
+// subscriberBuffer is how many events a subscriber may lag behind before events are dropped for it
+const subscriberBuffer = 64
+
+// Event describes a change to a cart. Events about the order of a checked out cart carry its OrderID
+// and new Status.
+type Event struct {
+	Type     string    `json:"type"`
+	CartID   uint      `json:"cart_id"`
+	OrderID  uint      `json:"order_id,omitempty"`
+	Status   string    `json:"status,omitempty"`
+	Product  string    `json:"product,omitempty"`
+	Quantity int       `json:"quantity,omitempty"`
+	Price    float64   `json:"price,omitempty"`
+	Total    float64   `json:"total"`
+	Time     time.Time `json:"time"`
+}
+
+// Consumer handles the events of a bus synchronously, as they are published. Unlike subscribers,
+// consumers see every event they want, so they suit anything that mustn't lose events, e.g. by storing
+// them in the database for later processing.
+type Consumer interface {
+	// Wants reports whether the consumer handles events of the type
+	Wants(eventType string) bool
+	// Handle processes the event, before Publish returns
+	Handle(e Event) error
+}
+
+// Bus fans events out to its consumers and all current subscribers. Publishing never blocks on
+// subscribers: a subscriber that doesn't keep up misses events rather than slowing down cart requests.
+type Bus struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+	consumers   []Consumer
+}
+
+// NewBus creates a Bus without subscribers.
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[chan Event]struct{})}
+}
+
+// Attach adds a consumer handling the events published from now on.
+func (b *Bus) Attach(c Consumer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.consumers = append(b.consumers, c)
+}
+
+// Subscribe returns a channel receiving all events published from now on and a function to
+// unsubscribe, which closes the channel.
+func (b *Bus) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// HasSubscribers reports whether anyone subscribed to the bus.
+func (b *Bus) HasSubscribers() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers) > 0
+}
+
+// Wants reports whether events of the type reach a subscriber or consumer, so publishers can skip
+// building costly events nobody receives.
+func (b *Bus) Wants(eventType string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.subscribers) > 0 {
+		return true
+	}
+	for _, c := range b.consumers {
+		if c.Wants(eventType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Publish sends the event to every subscriber with room in its buffer and hands it to every consumer
+// wanting it. Errors of consumers are logged. A zero Time is set to now.
+func (b *Bus) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.mu.Lock()
+	consumers := b.consumers
+	for ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+	b.mu.Unlock()
+
+	for _, c := range consumers {
+		if !c.Wants(e.Type) {
+			continue
+		}
+		if err := c.Handle(e); err != nil {
+			log.Printf("Failed to handle %s event of cart %d: %v", e.Type, e.CartID, err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package events_test
+
+import (
+	"interview/internal/events"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBus(t *testing.T) {
+	bus := events.NewBus()
+	assert.False(t, bus.HasSubscribers())
+
+	first, unsubscribeFirst := bus.Subscribe()
+	second, unsubscribeSecond := bus.Subscribe()
+	defer unsubscribeSecond()
+	assert.True(t, bus.HasSubscribers())
+
+	bus.Publish(events.Event{Type: events.TypeItemAdded, CartID: 1})
+	for _, ch := range []<-chan events.Event{first, second} {
+		e := <-ch
+		assert.Equal(t, events.TypeItemAdded, e.Type)
+		assert.False(t, e.Time.IsZero())
+	}
+
+	unsubscribeFirst()
+	unsubscribeFirst()
+	_, open := <-first
+	assert.False(t, open)
+
+	t.Run("Slow Subscribers Miss Events", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			bus.Publish(events.Event{Type: events.TypeItemRemoved, CartID: uint(i)})
+		}
+		require.Len(t, second, cap(second))
+		assert.Equal(t, uint(0), (<-second).CartID)
+	})
+	t.Run("Consumers Get Every Event They Want", func(t *testing.T) {
+		consumer := &recordingConsumer{eventType: events.TypeItemRemoved}
+		bus.Attach(consumer)
+		assert.True(t, bus.Wants(events.TypeItemAdded))
+		unsubscribeSecond()
+		assert.True(t, bus.Wants(events.TypeItemRemoved))
+		assert.False(t, bus.Wants(events.TypeItemAdded))
+
+		for i := 0; i < 100; i++ {
+			bus.Publish(events.Event{Type: events.TypeItemRemoved, CartID: uint(i)})
+			bus.Publish(events.Event{Type: events.TypeItemAdded, CartID: uint(i)})
+		}
+		require.Len(t, consumer.received, 100)
+		assert.Equal(t, uint(99), consumer.received[99].CartID)
+	})
+}
+
+// recordingConsumer records the events of one type
+type recordingConsumer struct {
+	eventType string
+	received  []events.Event
+}
+
+func (c *recordingConsumer) Wants(eventType string) bool {
+	return eventType == c.eventType
+}
+
+func (c *recordingConsumer) Handle(e events.Event) error {
+	c.received = append(c.received, e)
+	return nil
+}
@@ -0,0 +1,98 @@
+// Package experiment assigns sessions to the variants of A/B experiments and defines the conversions
+// recorded per variant.
+package experiment
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Conversion events recorded for each experiment the session takes part in
+const (
+	// EventAssigned is recorded once when a session is assigned a variant, counting its participants
+	EventAssigned = "assigned"
+	// EventAddToCart is recorded whenever the session adds a product to a cart
+	EventAddToCart = "add_to_cart"
+	// EventCheckout is recorded when a cart of the session is checked out
+	EventCheckout = "checkout"
+)
+
+type (
+	// Experiment splits sessions between variants, e.g. "checkout_button" with "control" and "green"
+	Experiment struct {
+		Name     string
+		Variants []string
+	}
+
+	// Conversion records an event of a session taking part in an experiment
+	Conversion struct {
+		gorm.Model
+		// Experiment and Variant are the experiment and the variant the session was assigned
+		Experiment string `gorm:"size:64;index:idx_conversion_variant;not null"`
+		Variant    string `gorm:"size:64;index:idx_conversion_variant;not null"`
+		// Event is what happened, one of the Event constants
+		Event string `gorm:"size:32;index:idx_conversion_variant;not null"`
+		// SessionID is the cart session the event happened in
+		SessionID string `gorm:"size:255;index;not null"`
+	}
+)
+
+// TableName keeps the conversions table name explicit about what converted.
+func (Conversion) TableName() string {
+	return "experiment_conversions"
+}
+
+// Parse reads experiments in the form "name=variant,variant;name=variant,variant". An empty spec
+// runs no experiments.
+func Parse(spec string) ([]Experiment, error) {
+	var experiments []Experiment
+	seen := map[string]bool{}
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, variants, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("experiment %q must have the form name=variant,variant", entry)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("experiment %q is defined twice", name)
+		}
+		seen[name] = true
+
+		exp := Experiment{Name: name}
+		for _, variant := range strings.Split(variants, ",") {
+			if variant = strings.TrimSpace(variant); variant != "" {
+				exp.Variants = append(exp.Variants, variant)
+			}
+		}
+		if len(exp.Variants) < 2 {
+			return nil, fmt.Errorf("experiment %q needs at least two variants", name)
+		}
+		experiments = append(experiments, exp)
+	}
+	return experiments, nil
+}
+
+// Assign returns the variant of the experiment for the session. The same session always gets the
+// same variant, and sessions are spread evenly over the variants.
+func (e Experiment) Assign(sessionID string) string {
+	h := fnv.New32a()
+	h.Write([]byte(e.Name + ":" + sessionID))
+	return e.Variants[h.Sum32()%uint32(len(e.Variants))]
+}
+
+// HasVariant reports whether variant is one of the variants of the experiment.
+func (e Experiment) HasVariant(variant string) bool {
+	for _, v := range e.Variants {
+		if v == variant {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package experiment_test
+
+import (
+	"fmt"
+	"interview/internal/experiment"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     string
+		expected []experiment.Experiment
+		wantErr  bool
+	}{
+		{name: "Empty", spec: "", expected: nil},
+		{
+			name: "Several Experiments",
+			spec: " checkout_button = control, green ;free_shipping=off,on,banner;",
+			expected: []experiment.Experiment{
+				{Name: "checkout_button", Variants: []string{"control", "green"}},
+				{Name: "free_shipping", Variants: []string{"off", "on", "banner"}},
+			},
+		},
+		{name: "Missing Variants", spec: "checkout_button", wantErr: true},
+		{name: "Single Variant", spec: "checkout_button=control", wantErr: true},
+		{name: "Missing Name", spec: "=control,green", wantErr: true},
+		{name: "Duplicate Name", spec: "a=x,y;a=x,z", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			experiments, err := experiment.Parse(tt.spec)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, experiments)
+		})
+	}
+}
+
+func TestAssign(t *testing.T) {
+	exp := experiment.Experiment{Name: "checkout_button", Variants: []string{"control", "green"}}
+
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		sessionID := fmt.Sprintf("session-%d", i)
+		variant := exp.Assign(sessionID)
+		assert.Equal(t, variant, exp.Assign(sessionID), "assignment must be deterministic")
+		assert.True(t, exp.HasVariant(variant))
+		counts[variant]++
+	}
+	assert.InDelta(t, 500, counts["control"], 75)
+	assert.InDelta(t, 500, counts["green"], 75)
+}
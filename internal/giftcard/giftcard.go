@@ -0,0 +1,56 @@
+// Package giftcard defines gift cards holding store credit and the audit trail of their redemptions.
+package giftcard
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+type (
+	// GiftCard holds store credit that can be redeemed against carts, possibly over several purchases
+	GiftCard struct {
+		gorm.Model
+		// TenantID is the shop the card is redeemed in
+		TenantID string `gorm:"size:32;index;not null;default:default"`
+		// Code is the secret printed on the card, stored in upper case
+		Code string `gorm:"size:64;uniqueIndex;not null"`
+		// Balance is the credit left on the card
+		Balance float64 `gorm:"not null;default:0"`
+		// Redemptions lists every use of the card
+		Redemptions []Redemption
+	}
+
+	// Redemption records an amount of a gift card's balance applied to a cart
+	Redemption struct {
+		gorm.Model
+		// GiftCardID links the redemption to the redeemed card
+		GiftCardID uint `gorm:"index;not null"`
+		// CartID is the cart the credit was applied to
+		CartID uint `gorm:"index;not null"`
+		// Amount is the credit taken from the card, negative for credit the cart returned
+		Amount float64
+	}
+)
+
+// TableName keeps the redemptions table name explicit about what is redeemed.
+func (Redemption) TableName() string {
+	return "gift_card_redemptions"
+}
+
+// NormalizeCode makes codes typed by users comparable to stored codes.
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// NewCode generates a random code of the form XXXX-XXXX-XXXX-XXXX.
+func NewCode() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate gift card code: %w", err)
+	}
+	hex := fmt.Sprintf("%X", b)
+	return hex[0:4] + "-" + hex[4:8] + "-" + hex[8:12] + "-" + hex[12:16], nil
+}
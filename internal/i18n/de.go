@@ -0,0 +1,306 @@
+package i18n
+
+// german holds the German translations, keyed by the English message
+var german = map[string]string{
+	// cart.html
+	"Shipping Cost Estimator":         "Versandkostenrechner",
+	"Logged in as %s":                 "Angemeldet als %s",
+	"Log out":                         "Abmelden",
+	"Log in with %s":                  "Anmelden mit %s",
+	"Product to add:":                 "Produkt hinzufügen:",
+	"Shoe":                            "Schuh",
+	"Purse":                           "Geldbörse",
+	"Bag":                             "Tasche",
+	"Watch":                           "Uhr",
+	"Quantity":                        "Menge",
+	"Add Item to Cart":                "In den Warenkorb",
+	"Product: %s":                     "Produkt: %s",
+	"Quantity: %d":                    "Menge: %d",
+	"Remove %s":                       "%s entfernen",
+	"Promotion: %s":                   "Aktion: %s",
+	"Discount: %s":                    "Rabatt: %s",
+	"Gift card":                       "Geschenkkarte",
+	"Credit: %s":                      "Guthaben: %s",
+	"Total to pay":                    "Zu zahlen",
+	"Subtotal":                        "Zwischensumme",
+	"Total discount":                  "Rabatt gesamt",
+	"Tax":                             "Steuer",
+	"Shipping":                        "Versand",
+	"Browse products":                 "Produkte ansehen",
+	"Add %s to cart":                  "%s in den Warenkorb",
+	"Frequently bought together":      "Häufig zusammen gekauft",
+	"Recently viewed":                 "Zuletzt angesehen",
+	"Continue in store":               "Im Laden fortfahren",
+	"Scan at a store terminal to pay": "Am Terminal im Laden scannen und bezahlen",
+	"QR code of your cart":            "QR-Code Ihres Warenkorbs",
+	"Gift card:":                      "Geschenkkarte:",
+	"Redeem":                          "Einlösen",
+	"Language:":                       "Sprache:",
+	"Carts:":                          "Warenkörbe:",
+	"Removed %d × %s":                 "%d × %s entfernt",
+	"Undo":                            "Rückgängig",
+	"New cart name":                   "Name des neuen Warenkorbs",
+	"New cart":                        "Neuer Warenkorb",
+	"Cart name":                       "Name des Warenkorbs",
+	"Rename":                          "Umbenennen",
+	"Delete cart":                     "Warenkorb löschen",
+	"Your referral code: %s":          "Ihr Empfehlungscode: %s",
+	"Earned gift card %s, balance %s": "Erhaltene Geschenkkarte %s, Guthaben %s",
+	"Get a referral code":             "Empfehlungscode erhalten",
+	"Referral code:":                  "Empfehlungscode:",
+	"Apply":                           "Anwenden",
+	"Email":                           "E-Mail",
+	"Password":                        "Passwort",
+	"Log in":                          "Anmelden",
+	"Forgot your password?":           "Passwort vergessen?",
+	"Email me a login link":           "Anmeldelink per E-Mail senden",
+	"Your account":                    "Ihr Konto",
+	"Your subscriptions":              "Ihre Abos",
+	"Subscribe & save":                "Abonnieren und sparen",
+	"One-time purchase":               "Einmalkauf",
+	"Check out":                       "Zur Kasse",
+	"Your cart is being checked out":  "Ihr Warenkorb wird gerade bestellt",
+	"Resume checkout":                 "Zur Kasse zurückkehren",
+	"Cancel checkout":                 "Bestellung abbrechen",
+	"Subscribe & save every %d days":  "Abo alle %d Tage",
+	"Update":                          "Ändern",
+	"Recent activity":                 "Letzte Änderungen",
+	"Added %d × %s":                   "%d × %s hinzugefügt",
+	"Took out %d × %s":                "%d × %s herausgenommen",
+	"Put back %d × %s":                "%d × %s zurückgelegt",
+	"Brought over %d × %s from before logging in": "%d × %s von vor der Anmeldung übernommen",
+	"Price of %s changed to %s":                   "Preis von %s auf %s geändert",
+	"Redeemed %s of gift card credit":             "%s Geschenkkarten-Guthaben eingelöst",
+	"Entered a referral code":                     "Empfehlungscode eingegeben",
+	"Checked out":                                 "Bestellt",
+	"(undone)":                                    "(rückgängig gemacht)",
+	"Undo last change":                            "Letzte Änderung rückgängig machen",
+	"Undone: %s":                                  "Rückgängig gemacht: %s",
+	"The reservation of %d × %s ran out":          "Die Reservierung von %d × %s ist abgelaufen",
+	"Your limited items are held for":             "Ihre limitierten Artikel sind reserviert für",
+	"You are viewing the cart of %s, read-only, every action is recorded": "Sie sehen den Warenkorb von %s, nur lesend, jede Aktion wird protokolliert",
+	"You are changing the cart of %s, every action is recorded":           "Sie ändern den Warenkorb von %s, jede Aktion wird protokolliert",
+	"Back to my session": "Zurück zu meiner Sitzung",
+
+	// product.html
+	"Quantity:":                          "Menge:",
+	"Out of stock":                       "Ausverkauft",
+	"Email me when it is back in stock:": "Benachrichtigen Sie mich per E-Mail, wenn es wieder verfügbar ist:",
+	"Notify me":                          "Benachrichtigen",
+
+	// reset_password.html
+	"Reset your password":     "Passwort zurücksetzen",
+	"New password":            "Neues Passwort",
+	"Repeat the new password": "Neues Passwort wiederholen",
+	"Back to cart":            "Zurück zum Warenkorb",
+
+	// account.html
+	"Language":                    "Sprache",
+	"Browser language":            "Sprache des Browsers",
+	"Currency":                    "Währung",
+	"Current password":            "Aktuelles Passwort",
+	"Save":                        "Speichern",
+	"Your sessions":               "Ihre Sitzungen",
+	"first seen %s, last seen %s": "zuerst gesehen %s, zuletzt gesehen %s",
+	"This session":                "Diese Sitzung",
+
+	// maintenance.html
+	"Maintenance": "Wartungsarbeiten",
+	"The shop is under maintenance, please try again in a few minutes": "Der Shop wird gerade gewartet, bitte versuchen Sie es in ein paar Minuten erneut",
+
+	// order.html
+	"Order %d":                  "Bestellung %d",
+	"Thank you for your order.": "Vielen Dank für Ihre Bestellung.",
+	"Downloads":                 "Downloads",
+	"Download %s":               "%s herunterladen",
+	"%d downloads left":         "Noch %d Downloads",
+	"Valid until %s":            "Gültig bis %s",
+
+	// subscriptions.html
+	"%d × %s every %d days": "%d × %s alle %d Tage",
+	"Paused":                "Pausiert",
+	"Next order on %s":      "Nächste Bestellung am %s",
+	"Pause":                 "Pausieren",
+	"Resume":                "Fortsetzen",
+	"Cancel subscription":   "Abo kündigen",
+	"Choose subscribe & save for items in your cart to have them delivered regularly.": "Wählen Sie „Abonnieren und sparen“ für Artikel in Ihrem Warenkorb, um sie regelmäßig geliefert zu bekommen.",
+
+	// checkout.html
+	"Checkout":                     "Kasse",
+	"Address":                      "Adresse",
+	"Payment":                      "Zahlung",
+	"Confirm":                      "Bestätigen",
+	"Continue":                     "Weiter",
+	"Ship here":                    "Hierhin liefern",
+	"Bill to":                      "Rechnungsadresse",
+	"Bill to the shipping address": "Rechnung an die Lieferadresse",
+	"Bill to %s":                   "Rechnung an %s",
+	"New address":                  "Neue Adresse",
+	"Name":                         "Name",
+	"Street":                       "Straße und Hausnummer",
+	"Address line 2":               "Adresszusatz",
+	"Postal code":                  "Postleitzahl",
+	"City":                         "Ort",
+	"Country code":                 "Ländercode",
+	"Phone":                        "Telefon",
+	"VAT ID":                       "USt-IdNr.",
+	"Reverse charge":               "Steuerschuldnerschaft des Leistungsempfängers",
+	"Standard shipping":            "Standardversand",
+	"Pay now":                      "Jetzt bezahlen",
+	"Place order":                  "Bestellung aufgeben",
+
+	// Flash messages
+	"Failed to create session":                                      "Sitzung konnte nicht erstellt werden",
+	"Failed to load cart":                                           "Warenkorb konnte nicht geladen werden",
+	"Invalid product selected":                                      "Ungültiges Produkt ausgewählt",
+	"Please enter a quantity":                                       "Bitte geben Sie eine Menge ein",
+	"Please correct the highlighted fields":                         "Bitte korrigieren Sie die markierten Felder",
+	"Quantity must be a valid number greater than 0":                "Die Menge muss eine gültige Zahl größer als 0 sein",
+	"You can add at most 999 of a product":                          "Sie können höchstens 999 Stück eines Produkts hinzufügen",
+	"Invalid session":                                               "Ungültige Sitzung",
+	"We couldn't tell you apart from a bot, please try again":       "Wir konnten Sie nicht von einem Bot unterscheiden, bitte versuchen Sie es erneut",
+	"Failed to add item to cart":                                    "Artikel konnte nicht hinzugefügt werden",
+	"Invalid item ID":                                               "Ungültige Artikel-ID",
+	"Cart not found":                                                "Warenkorb nicht gefunden",
+	"Item not found":                                                "Artikel nicht gefunden",
+	"Failed to remove item":                                         "Artikel konnte nicht entfernt werden",
+	"Failed to restore item":                                        "Artikel konnte nicht wiederhergestellt werden",
+	"Please enter a gift card code":                                 "Bitte geben Sie einen Geschenkkartencode ein",
+	"Unknown gift card code":                                        "Unbekannter Geschenkkartencode",
+	"This gift card has no balance left":                            "Diese Geschenkkarte hat kein Guthaben mehr",
+	"Your cart has nothing left to pay":                             "In Ihrem Warenkorb ist nichts mehr zu bezahlen",
+	"Failed to redeem gift card":                                    "Geschenkkarte konnte nicht eingelöst werden",
+	"This product is out of stock":                                  "Dieses Produkt ist ausverkauft",
+	"We will email you when the product is back in stock":           "Wir benachrichtigen Sie per E-Mail, sobald das Produkt wieder verfügbar ist",
+	"Failed to subscribe":                                           "Anmeldung fehlgeschlagen",
+	"Product not found":                                             "Produkt nicht gefunden",
+	"Order not found":                                               "Bestellung nicht gefunden",
+	"Failed to load downloads":                                      "Downloads konnten nicht geladen werden",
+	"Please choose an offered subscription interval":                "Bitte wählen Sie einen angebotenen Abo-Rhythmus",
+	"Failed to update item":                                         "Artikel konnte nicht geändert werden",
+	"Subscription not found":                                        "Abo nicht gefunden",
+	"This subscription was cancelled":                               "Dieses Abo wurde gekündigt",
+	"Failed to load subscriptions":                                  "Abos konnten nicht geladen werden",
+	"Failed to update subscription":                                 "Abo konnte nicht geändert werden",
+	"Subscription paused":                                           "Abo pausiert",
+	"Subscription resumed":                                          "Abo fortgesetzt",
+	"Subscription cancelled":                                        "Abo gekündigt",
+	"Your cart is empty":                                            "Ihr Warenkorb ist leer",
+	"Failed to check out":                                           "Bestellung konnte nicht abgeschlossen werden",
+	"Payments are temporarily unavailable, please try again":        "Zahlungen sind vorübergehend nicht möglich, bitte versuchen Sie es erneut",
+	"Failed to complete the payment":                                "Zahlung konnte nicht abgeschlossen werden",
+	"The payment was cancelled":                                     "Die Zahlung wurde abgebrochen",
+	"Payment not found":                                             "Zahlung nicht gefunden",
+	"The payment was not approved":                                  "Die Zahlung wurde nicht freigegeben",
+	"Your cart changed during the payment, please pay again":        "Ihr Warenkorb wurde während der Zahlung geändert, bitte bezahlen Sie erneut",
+	"Please check out from your cart":                               "Bitte gehen Sie über Ihren Warenkorb zur Kasse",
+	"Please complete the previous checkout steps first":             "Bitte schließen Sie zuerst die vorherigen Schritte ab",
+	"Please choose an offered shipping method":                      "Bitte wählen Sie eine angebotene Versandart",
+	"Address not found":                                             "Adresse nicht gefunden",
+	"Please enter a complete address":                               "Bitte geben Sie eine vollständige Adresse ein",
+	"Invalid address ID":                                            "Ungültige Adress-ID",
+	"Failed to save the address":                                    "Adresse konnte nicht gespeichert werden",
+	"Please enter a valid VAT ID":                                   "Bitte geben Sie eine gültige USt-IdNr. ein",
+	"The VAT ID must be from the country the order ships to":        "Die USt-IdNr. muss aus dem Lieferland stammen",
+	"This VAT ID isn't valid":                                       "Diese USt-IdNr. ist nicht gültig",
+	"Your VAT ID couldn't be checked right now, so tax is charged":  "Ihre USt-IdNr. konnte gerade nicht geprüft werden, daher wird Steuer berechnet",
+	"Failed to check the VAT ID":                                    "USt-IdNr. konnte nicht geprüft werden",
+	"Failed to load checkout":                                       "Kasse konnte nicht geladen werden",
+	"Failed to save the checkout":                                   "Kasse konnte nicht gespeichert werden",
+	"This cart is being checked out":                                "Dieser Warenkorb wird gerade bestellt",
+	"Your checkout expired, please check out again":                 "Ihre Bestellung ist abgelaufen, bitte gehen Sie erneut zur Kasse",
+	"The checkout was cancelled":                                    "Die Bestellung wurde abgebrochen",
+	"Failed to cancel the checkout":                                 "Bestellung konnte nicht abgebrochen werden",
+	"Your order is being reviewed before the payment is taken":      "Ihre Bestellung wird vor der Zahlung geprüft",
+	"Thank you! Your order is being reviewed":                       "Vielen Dank! Ihre Bestellung wird geprüft",
+	"We can't accept this payment, please contact us":               "Wir können diese Zahlung nicht annehmen, bitte kontaktieren Sie uns",
+	"Prices are temporarily unavailable, please try again":          "Preise sind vorübergehend nicht verfügbar, bitte versuchen Sie es erneut",
+	"Your cart changed while we were updating it, please try again": "Ihr Warenkorb wurde währenddessen geändert, bitte versuchen Sie es erneut",
+	"Invalid minimum price":                                         "Ungültiger Mindestpreis",
+	"Invalid maximum price":                                         "Ungültiger Höchstpreis",
+	"Failed to load products":                                       "Produkte konnten nicht geladen werden",
+	"Login failed, please try again":                                "Anmeldung fehlgeschlagen, bitte versuchen Sie es erneut",
+	"This link is invalid or has expired":                           "Dieser Link ist ungültig oder abgelaufen",
+	"This cart is no longer available":                              "Dieser Warenkorb ist nicht mehr verfügbar",
+	"Cart names must be between 1 and 64 characters":                "Namen von Warenkörben müssen zwischen 1 und 64 Zeichen lang sein",
+	"You already have a cart with this name":                        "Sie haben bereits einen Warenkorb mit diesem Namen",
+	"Carts holding gift card credit can't be deleted":               "Warenkörbe mit Geschenkkartenguthaben können nicht gelöscht werden",
+	"Failed to create cart":                                         "Warenkorb konnte nicht erstellt werden",
+	"Failed to switch cart":                                         "Warenkorb konnte nicht gewechselt werden",
+	"Failed to rename cart":                                         "Warenkorb konnte nicht umbenannt werden",
+	"Failed to delete cart":                                         "Warenkorb konnte nicht gelöscht werden",
+	"Please enter a referral code":                                  "Bitte geben Sie einen Empfehlungscode ein",
+	"Unknown referral code":                                         "Unbekannter Empfehlungscode",
+	"You can't use your own referral code":                          "Sie können Ihren eigenen Empfehlungscode nicht verwenden",
+	"Referral codes are only for new customers":                     "Empfehlungscodes gelten nur für Neukunden",
+	"Please log in to use a referral code":                          "Bitte melden Sie sich an, um einen Empfehlungscode zu verwenden",
+	"Failed to apply referral code":                                 "Empfehlungscode konnte nicht angewendet werden",
+	"Please log in to get a referral code":                          "Bitte melden Sie sich an, um einen Empfehlungscode zu erhalten",
+	"Failed to create referral code":                                "Empfehlungscode konnte nicht erstellt werden",
+	"Prices in your cart were updated":                              "Die Preise in Ihrem Warenkorb wurden aktualisiert",
+	"Verify":                                                        "Bestätigen",
+	"Login expired, please log in again":                            "Die Anmeldung ist abgelaufen, bitte melden Sie sich erneut an",
+	"Invalid code, please try again":                                "Ungültiger Code, bitte versuchen Sie es erneut",
+	"Too many invalid codes, please log in again":                   "Zu viele ungültige Codes, bitte melden Sie sich erneut an",
+	"Enter the code of your authenticator app or a recovery code":   "Geben Sie den Code Ihrer Authenticator-App oder einen Wiederherstellungscode ein",
+	"Invalid email or password":                                     "Ungültige E-Mail-Adresse oder ungültiges Passwort",
+	"Please enter your email address":                               "Bitte geben Sie Ihre E-Mail-Adresse ein",
+	"Too many requests, please try again later":                     "Zu viele Anfragen, bitte versuchen Sie es später erneut",
+	"Too many failed logins, please try again later":                "Zu viele fehlgeschlagene Anmeldungen, bitte versuchen Sie es später erneut",
+	"Invalid email or password, attempts left: %d":                  "Ungültige E-Mail-Adresse oder ungültiges Passwort, verbleibende Versuche: %d",
+	"Invalid code, attempts left: %d":                               "Ungültiger Code, verbleibende Versuche: %d",
+	"This password reset link is invalid or has expired":            "Dieser Link zum Zurücksetzen des Passworts ist ungültig oder abgelaufen",
+	"Passwords must have at least 8 characters":                     "Passwörter müssen mindestens 8 Zeichen lang sein",
+	"This password is too long":                                     "Dieses Passwort ist zu lang",
+	"The passwords don't match":                                     "Die Passwörter stimmen nicht überein",
+	"Failed to reset password":                                      "Passwort konnte nicht zurückgesetzt werden",
+	"Your password was changed, you can log in with it now":         "Ihr Passwort wurde geändert, Sie können sich jetzt damit anmelden",
+	"Please enter a valid email address":                            "Bitte geben Sie eine gültige E-Mail-Adresse ein",
+	"Failed to send login link":                                     "Anmeldelink konnte nicht gesendet werden",
+	"We sent you a link to log in, please check your email":         "Wir haben Ihnen einen Anmeldelink gesendet, bitte prüfen Sie Ihre E-Mails",
+	"Please log in to manage your account":                          "Bitte melden Sie sich an, um Ihr Konto zu verwalten",
+	"Unsupported language":                                          "Nicht unterstützte Sprache",
+	"Unsupported currency":                                          "Nicht unterstützte Währung",
+	"The current password is wrong":                                 "Das aktuelle Passwort ist falsch",
+	"Failed to update account":                                      "Konto konnte nicht aktualisiert werden",
+	"Failed to send the confirmation link":                          "Bestätigungslink konnte nicht gesendet werden",
+	"Failed to change email address":                                "E-Mail-Adresse konnte nicht geändert werden",
+	"Your account was updated":                                      "Ihr Konto wurde aktualisiert",
+	"Your email address was changed":                                "Ihre E-Mail-Adresse wurde geändert",
+	"This email address is already used by another account":         "Diese E-Mail-Adresse wird bereits von einem anderen Konto verwendet",
+	"If an account exists for this email address, we sent you a link to reset your password":      "Falls ein Konto mit dieser E-Mail-Adresse existiert, haben wir Ihnen einen Link zum Zurücksetzen des Passworts gesendet",
+	"The email address of this account is managed by its login provider":                          "Die E-Mail-Adresse dieses Kontos wird von seinem Anmeldedienst verwaltet",
+	"Your account was updated, please confirm your new email address with the link we sent to it": "Ihr Konto wurde aktualisiert, bitte bestätigen Sie Ihre neue E-Mail-Adresse mit dem Link, den wir an sie gesendet haben",
+	"This view of the customer's cart is read-only":                                               "Diese Ansicht des Warenkorbs des Kunden ist schreibgeschützt",
+	"Not available while viewing a customer's cart":                                               "Nicht verfügbar, während Sie den Warenkorb eines Kunden ansehen",
+	"Session not found":                          "Sitzung nicht gefunden",
+	"Failed to log out the session":              "Sitzung konnte nicht abgemeldet werden",
+	"The session was logged out":                 "Die Sitzung wurde abgemeldet",
+	"You were logged out of your oldest session": "Ihre älteste Sitzung wurde abgemeldet",
+	"Your email address was verified":            "Ihre E-Mail-Adresse wurde bestätigt",
+	"Failed to verify email address":             "E-Mail-Adresse konnte nicht bestätigt werden",
+	"This verification link has expired, please request a new one on your account page": "Dieser Bestätigungslink ist abgelaufen, bitte fordern Sie auf Ihrer Kontoseite einen neuen an",
+	"Your account has no email address":                                                 "Ihr Konto hat keine E-Mail-Adresse",
+	"Your email address is already verified":                                            "Ihre E-Mail-Adresse ist bereits bestätigt",
+	"A verification link was sent recently, please try again later":                     "Ein Bestätigungslink wurde vor Kurzem gesendet, bitte versuchen Sie es später erneut",
+	"Failed to send verification link":                                                  "Bestätigungslink konnte nicht gesendet werden",
+	"We sent you a link to verify your email address":                                   "Wir haben Ihnen einen Link zur Bestätigung Ihrer E-Mail-Adresse gesendet",
+	"Your email address is not verified yet":                                            "Ihre E-Mail-Adresse ist noch nicht bestätigt",
+	"Send a new verification link":                                                      "Neuen Bestätigungslink senden",
+	"Verify your email address to use your saved addresses":                             "Bestätigen Sie Ihre E-Mail-Adresse, um Ihre gespeicherten Adressen zu verwenden",
+	"Failed to update notifications":                                                    "Benachrichtigungen konnten nicht aktualisiert werden",
+	"The price of %s in your cart dropped to %s":                                        "Der Preis von %s in Ihrem Warenkorb ist auf %s gesunken",
+	"Your order #%d has shipped":                                                        "Ihre Bestellung #%d wurde versandt",
+	"Notifications":                                                                     "Benachrichtigungen",
+	"Mark all as read":                                                                  "Alle als gelesen markieren",
+	"Volume price from %d: %s each":                                                     "Staffelpreis ab %d: je %s",
+	"Bundle of:":                                                                        "Set aus:",
+	"Part of bundle %s":                                                                 "Teil des Sets %s",
+	"Remove bundle %s":                                                                  "Set %s entfernen",
+	"Show items as arranged":                                                            "Artikel in eigener Reihenfolge anzeigen",
+	"Group items by category":                                                           "Artikel nach Kategorie gruppieren",
+	"Other products":                                                                    "Weitere Produkte",
+	"Drag to reorder":                                                                   "Zum Umsortieren ziehen",
+	"Your cart changed, please arrange its items again":                                 "Ihr Warenkorb hat sich geändert, bitte ordnen Sie die Artikel erneut an",
+	"Failed to reorder items":                                                           "Artikel konnten nicht umsortiert werden",
+}
@@ -0,0 +1,61 @@
+// Package i18n translates user-facing messages. Messages are keyed by their English text, so
+// untranslated messages fall back to English and handlers can keep passing plain strings around,
+// e.g. as session flashes, until they are rendered.
+package i18n
+
+import (
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// Supported lists the available locales, the first one is the default.
+var Supported = []language.Tag{language.English, language.German}
+
+var matcher = language.NewMatcher(Supported)
+
+func init() {
+	for key, translation := range german {
+		if err := message.SetString(language.German, key, translation); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Match returns the supported locale closest to the given preferences. Each preference is either
+// a locale such as "de" or an Accept-Language header value; earlier preferences win. The default
+// locale is returned when nothing matches.
+func Match(preferences ...string) language.Tag {
+	var tags []language.Tag
+	for _, pref := range preferences {
+		parsed, _, err := language.ParseAcceptLanguage(pref)
+		if err != nil {
+			continue
+		}
+		tags = append(tags, parsed...)
+	}
+	_, index, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return Supported[0]
+	}
+	return Supported[index]
+}
+
+// IsSupported reports whether locale names one of the supported locales exactly.
+func IsSupported(locale string) bool {
+	for _, tag := range Supported {
+		if tag.String() == locale {
+			return true
+		}
+	}
+	return false
+}
+
+// Translate returns the message for key in the given locale, formatted with args like fmt.Sprintf.
+func Translate(locale language.Tag, key string, args ...interface{}) string {
+	return message.NewPrinter(locale).Sprintf(key, args...)
+}
+
+// T translates key into the locale with the given name. It is registered as the "t" template function.
+func T(locale string, key string, args ...interface{}) string {
+	return Translate(language.Make(locale), key, args...)
+}
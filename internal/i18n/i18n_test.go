@@ -0,0 +1,35 @@
+package i18n_test
+
+import (
+	"interview/internal/i18n"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		preferences []string
+		expected    language.Tag
+	}{
+		{name: "No Preference", preferences: nil, expected: language.English},
+		{name: "Accept-Language German", preferences: []string{"", "de-DE,de;q=0.9,en;q=0.8"}, expected: language.German},
+		{name: "Unsupported Language", preferences: []string{"fr-FR"}, expected: language.English},
+		{name: "Session Wins Over Header", preferences: []string{"en", "de"}, expected: language.English},
+		{name: "Malformed Header", preferences: []string{"!!"}, expected: language.English},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, i18n.Match(tt.preferences...))
+		})
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	assert.Equal(t, "Menge: 3", i18n.Translate(language.German, "Quantity: %d", 3))
+	assert.Equal(t, "Quantity: 3", i18n.Translate(language.English, "Quantity: %d", 3))
+	assert.Equal(t, "Not in the catalog", i18n.T("de", "Not in the catalog"))
+}
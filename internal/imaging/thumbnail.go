@@ -0,0 +1,93 @@
+// Package imaging validates uploaded images and renders thumbnails using only the standard library.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	// Register the GIF decoder for image.Decode
+	_ "image/gif"
+)
+
+// ErrUnsupportedFormat is returned for uploads that aren't JPEG, PNG or GIF images.
+var ErrUnsupportedFormat = errors.New("unsupported image format, use JPEG, PNG or GIF")
+
+// Image is a decoded upload.
+type Image struct {
+	image.Image
+	// Format is the decoder's name: "jpeg", "png" or "gif"
+	Format string
+}
+
+// Decode reads an image and rejects anything the standard library can't decode.
+func Decode(r io.Reader) (*Image, error) {
+	img, format, err := image.Decode(r)
+	if errors.Is(err, image.ErrFormat) {
+		return nil, ErrUnsupportedFormat
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	return &Image{Image: img, Format: format}, nil
+}
+
+// Thumbnail scales the image down to fit within size×size pixels, keeping its aspect ratio, and
+// encodes it as JPEG for JPEG sources and PNG otherwise to preserve transparency. It returns the
+// encoded thumbnail and its content type.
+func Thumbnail(img *Image, size int) ([]byte, string, error) {
+	scaled := fit(img.Image, size)
+
+	var buf bytes.Buffer
+	if img.Format == "jpeg" {
+		if err := jpeg.Encode(&buf, scaled, &jpeg.Options{Quality: 85}); err != nil {
+			return nil, "", fmt.Errorf("failed to encode thumbnail: %w", err)
+		}
+		return buf.Bytes(), "image/jpeg", nil
+	}
+	if err := png.Encode(&buf, scaled); err != nil {
+		return nil, "", fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+	return buf.Bytes(), "image/png", nil
+}
+
+// fit downscales src by averaging the source pixels covered by each destination pixel. Images
+// already smaller than size are returned unchanged.
+func fit(src image.Image, size int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return src
+	}
+
+	dw, dh := size, h*size/w
+	if h > w {
+		dw, dh = w*size/h, size
+	}
+	dw, dh = max(dw, 1), max(dh, 1)
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := b.Min.Y+y*h/dh, b.Min.Y+max((y+1)*h/dh, y*h/dh+1)
+		for x := 0; x < dw; x++ {
+			x0, x1 := b.Min.X+x*w/dw, b.Min.X+max((x+1)*w/dw, x*w/dw+1)
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := color.NRGBA64Model.Convert(src.At(sx, sy)).(color.NRGBA64)
+					r, g, bl, a = r+uint64(c.R), g+uint64(c.G), bl+uint64(c.B), a+uint64(c.A)
+					n++
+				}
+			}
+			dst.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(r / n >> 8), G: uint8(g / n >> 8), B: uint8(bl / n >> 8), A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,58 @@
+// Package inventory applies the stock levels and prices an ERP sends the shop in bulk.
+package inventory
+
+import "time"
+
+type (
+	// Batch is the payload of an inventory webhook. EventID identifies it so that batches sent again,
+	// whether retried by the ERP or replayed, are only applied once.
+	Batch struct {
+		EventID string   `json:"event_id"`
+		Updates []Update `json:"updates"`
+	}
+
+	// Update sets the price and stock of the product named Product, creating it when it isn't in the
+	// catalog. Fields left out are unchanged.
+	Update struct {
+		Product string   `json:"product"`
+		Price   *float64 `json:"price,omitempty"`
+		Stock   *int     `json:"stock,omitempty"`
+	}
+
+	// Report tells the ERP which updates of a batch were applied. Updates that failed are listed with
+	// why, the others were applied; Duplicate batches were applied before and are ignored.
+	Report struct {
+		EventID   string    `json:"event_id"`
+		Duplicate bool      `json:"duplicate,omitempty"`
+		Created   int       `json:"created"`
+		Updated   int       `json:"updated"`
+		Failed    []Failure `json:"failed"`
+	}
+
+	// Failure is an update of a batch that wasn't applied.
+	Failure struct {
+		// Index is the position of the update in the batch
+		Index   int    `json:"index"`
+		Product string `json:"product"`
+		Error   string `json:"error"`
+	}
+
+	// Event records a batch that was applied, to recognize it when it is sent again.
+	Event struct {
+		ID uint `gorm:"primarykey"`
+		// TenantID is the shop the batch was sent to
+		TenantID string `gorm:"size:32;uniqueIndex:idx_inventory_event;not null;default:default"`
+		// EventID is the ID the ERP gave the batch
+		EventID string `gorm:"size:255;uniqueIndex:idx_inventory_event;not null"`
+		// Created, Updated and Failed count the updates of the batch by outcome
+		Created   int `gorm:"not null"`
+		Updated   int `gorm:"not null"`
+		Failed    int `gorm:"not null"`
+		CreatedAt time.Time
+	}
+)
+
+// TableName prefixes the events table with what they are events of.
+func (Event) TableName() string {
+	return "inventory_events"
+}
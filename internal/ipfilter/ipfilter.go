@@ -0,0 +1,95 @@
+// Package ipfilter decides which client IP addresses may reach a part of the shop, by lists of
+// allowed and denied networks. The client of a request relayed by trusted proxies is taken from their
+// X-Forwarded-For header; other senders can't pass themselves off as someone else with the header.
+package ipfilter
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Filter holds the networks allowed and denied access and the proxies trusted to report the client.
+type Filter struct {
+	// Allow lists the networks clients have to be in, empty to allow any network not denied
+	Allow []netip.Prefix
+	// Deny lists the networks of clients turned away, taking precedence over Allow
+	Deny []netip.Prefix
+	// TrustedProxies lists the networks of the proxies whose X-Forwarded-For header is honored
+	TrustedProxies []netip.Prefix
+}
+
+// ParseList parses a comma-separated list of networks in CIDR notation, such as "10.0.0.0/8", or of
+// single addresses, such as "192.0.2.1". An empty list returns nil.
+func ParseList(spec string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address %q", entry)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q", entry)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// Allowed tells whether the client may be let through.
+func (f *Filter) Allowed(client netip.Addr) bool {
+	client = client.Unmap()
+	if contains(f.Deny, client) {
+		return false
+	}
+	return len(f.Allow) == 0 || contains(f.Allow, client)
+}
+
+// ClientIP returns the address of the client that sent the request. Starting from the peer, the
+// X-Forwarded-For addresses are followed from the right for as long as the address reached is a trusted
+// proxy, so only addresses added by trusted proxies are believed. It returns false when the peer or a
+// forwarded address it relies on isn't an IP address.
+func (f *Filter) ClientIP(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	client, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	client = client.Unmap()
+
+	var forwarded []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		forwarded = append(forwarded, strings.Split(header, ",")...)
+	}
+	for i := len(forwarded) - 1; i >= 0 && contains(f.TrustedProxies, client); i-- {
+		client, err = netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+		if err != nil {
+			return netip.Addr{}, false
+		}
+		client = client.Unmap()
+	}
+	return client, true
+}
+
+func contains(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
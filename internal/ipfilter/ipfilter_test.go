@@ -0,0 +1,99 @@
+package ipfilter_test
+
+import (
+	"interview/internal/ipfilter"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustParse(t *testing.T, spec string) []netip.Prefix {
+	t.Helper()
+	prefixes, err := ipfilter.ParseList(spec)
+	require.NoError(t, err)
+	return prefixes
+}
+
+func TestParseList(t *testing.T) {
+	t.Run("Networks And Addresses", func(t *testing.T) {
+		prefixes := mustParse(t, "10.1.2.3/8, 192.0.2.1,2001:db8::/32")
+		assert.Equal(t, []netip.Prefix{
+			netip.MustParsePrefix("10.0.0.0/8"),
+			netip.MustParsePrefix("192.0.2.1/32"),
+			netip.MustParsePrefix("2001:db8::/32"),
+		}, prefixes)
+	})
+
+	t.Run("Empty List", func(t *testing.T) {
+		assert.Nil(t, mustParse(t, " "))
+	})
+
+	t.Run("Invalid Entries", func(t *testing.T) {
+		for _, spec := range []string{"10.0.0.0/33", "shop.example", "192.0.2.1/"} {
+			_, err := ipfilter.ParseList(spec)
+			assert.Error(t, err, spec)
+		}
+	})
+}
+
+func TestAllowed(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter ipfilter.Filter
+		client string
+		want   bool
+	}{
+		{"No Lists", ipfilter.Filter{}, "198.51.100.7", true},
+		{"Allowed Network", ipfilter.Filter{Allow: mustParse(t, "10.0.0.0/8")}, "10.4.0.1", true},
+		{"Outside The Allowed Networks", ipfilter.Filter{Allow: mustParse(t, "10.0.0.0/8")}, "198.51.100.7", false},
+		{"Denied Network", ipfilter.Filter{Deny: mustParse(t, "198.51.100.0/24")}, "198.51.100.7", false},
+		{"Deny Takes Precedence", ipfilter.Filter{Allow: mustParse(t, "10.0.0.0/8"), Deny: mustParse(t, "10.6.0.0/16")}, "10.6.0.1", false},
+		{"IPv4-Mapped Address", ipfilter.Filter{Allow: mustParse(t, "10.0.0.0/8")}, "::ffff:10.0.0.1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.filter.Allowed(netip.MustParseAddr(tt.client)))
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	filter := ipfilter.Filter{TrustedProxies: mustParse(t, "10.0.0.0/8")}
+
+	tests := []struct {
+		name      string
+		peer      string
+		forwarded []string
+		want      string
+	}{
+		{"Direct Client", "198.51.100.7:4000", nil, "198.51.100.7"},
+		{"Untrusted Peer Forwarding", "198.51.100.7:4000", []string{"10.0.0.5"}, "198.51.100.7"},
+		{"Trusted Proxy", "10.0.0.1:4000", []string{"203.0.113.9"}, "203.0.113.9"},
+		{"Chain Of Trusted Proxies", "10.0.0.1:4000", []string{"203.0.113.9, 10.0.0.2"}, "203.0.113.9"},
+		{"Spoofed Entry Before The Client", "10.0.0.1:4000", []string{"10.0.0.9, 203.0.113.9"}, "203.0.113.9"},
+		{"Several Headers", "10.0.0.1:4000", []string{"203.0.113.9", "10.0.0.2"}, "203.0.113.9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/admin/metrics", nil)
+			req.RemoteAddr = tt.peer
+			for _, header := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", header)
+			}
+			client, ok := filter.ClientIP(req)
+			require.True(t, ok)
+			assert.Equal(t, tt.want, client.String())
+		})
+	}
+
+	t.Run("Garbage Forwarded By A Trusted Proxy", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/admin/metrics", nil)
+		req.RemoteAddr = "10.0.0.1:4000"
+		req.Header.Set("X-Forwarded-For", "unknown")
+		_, ok := filter.ClientIP(req)
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,72 @@
+// Package jobs runs background work alongside the HTTP server: tasks on a fixed interval with Scheduler,
+// and jobs queued in the database with Queue and run by the pool of a Worker, retried until they succeed.
+package jobs
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+type (
+	// Scheduler runs registered jobs periodically until its context is cancelled
+	Scheduler struct {
+		jobs   []job
+		locker Locker
+		owner  string
+		wg     sync.WaitGroup
+	}
+
+	job struct {
+		name     string
+		interval time.Duration
+		run      func(ctx context.Context) error
+	}
+)
+
+// NewScheduler creates a Scheduler without jobs
+func NewScheduler() *Scheduler {
+	return &Scheduler{}
+}
+
+// Every registers a job running every interval once the scheduler is started. Failures are logged
+// and the job runs again at its next tick.
+func (s *Scheduler) Every(name string, interval time.Duration, run func(ctx context.Context) error) {
+	s.jobs = append(s.jobs, job{name: name, interval: interval, run: run})
+}
+
+// Start runs every job in its own goroutine until ctx is cancelled. A job's runs never overlap.
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go func(j job) {
+			defer s.wg.Done()
+			ticker := time.NewTicker(j.interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					if err := s.run(ctx, j); err != nil {
+						log.Printf("Job %s failed: %v", j.name, err)
+					}
+				}
+			}
+		}(j)
+	}
+}
+
+// run runs the job, under its lock when the scheduler has a Locker
+func (s *Scheduler) run(ctx context.Context, j job) error {
+	if s.locker != nil {
+		return s.runLocked(ctx, j)
+	}
+	return j.run(ctx)
+}
+
+// Wait blocks until all jobs have returned after the context passed to Start is cancelled
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
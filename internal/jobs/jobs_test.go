@@ -0,0 +1,94 @@
+package jobs_test
+
+import (
+	"context"
+	"errors"
+	"interview/internal/jobs"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduler(t *testing.T) {
+	t.Run("runs jobs until stopped", func(t *testing.T) {
+		var runs atomic.Int32
+		s := jobs.NewScheduler()
+		s.Every("count", time.Millisecond, func(ctx context.Context) error {
+			runs.Add(1)
+			return nil
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		s.Start(ctx)
+		assert.Eventually(t, func() bool { return runs.Load() >= 3 }, time.Second, time.Millisecond)
+
+		cancel()
+		s.Wait()
+		stopped := runs.Load()
+		time.Sleep(10 * time.Millisecond)
+		assert.Equal(t, stopped, runs.Load())
+	})
+
+	t.Run("keeps running after a failure", func(t *testing.T) {
+		var runs atomic.Int32
+		s := jobs.NewScheduler()
+		s.Every("flaky", time.Millisecond, func(ctx context.Context) error {
+			runs.Add(1)
+			return errors.New("boom")
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		s.Start(ctx)
+		assert.Eventually(t, func() bool { return runs.Load() >= 2 }, time.Second, time.Millisecond)
+	})
+}
+
+func TestSchedulerLocks(t *testing.T) {
+	t.Run("runs a job on one instance at a time", func(t *testing.T) {
+		store, _ := setupRepo(t)
+		var running, overlaps, runs atomic.Int32
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		for range 2 {
+			s := jobs.NewScheduler()
+			s.SetLocker(store)
+			s.Every("sweep", time.Millisecond, func(ctx context.Context) error {
+				if running.Add(1) > 1 {
+					overlaps.Add(1)
+				}
+				time.Sleep(5 * time.Millisecond)
+				running.Add(-1)
+				runs.Add(1)
+				return nil
+			})
+			s.Start(ctx)
+		}
+
+		assert.Eventually(t, func() bool { return runs.Load() >= 5 }, time.Second, time.Millisecond)
+		assert.Zero(t, overlaps.Load())
+	})
+
+	t.Run("takes over expired locks", func(t *testing.T) {
+		store, _ := setupRepo(t)
+		now := time.Now()
+
+		acquired, err := store.AcquireLock("sweep", "a", now, now.Add(time.Minute))
+		require.NoError(t, err)
+		assert.True(t, acquired)
+		acquired, err = store.AcquireLock("sweep", "b", now.Add(30*time.Second), now.Add(2*time.Minute))
+		require.NoError(t, err)
+		assert.False(t, acquired, "the lock of a is held")
+		acquired, err = store.AcquireLock("sweep", "a", now.Add(30*time.Second), now.Add(2*time.Minute))
+		require.NoError(t, err)
+		assert.True(t, acquired, "owners renew their locks")
+
+		require.NoError(t, store.ReleaseLock("sweep", "a", now.Add(time.Minute)))
+		acquired, err = store.AcquireLock("sweep", "b", now.Add(time.Minute), now.Add(2*time.Minute))
+		require.NoError(t, err)
+		assert.True(t, acquired, "the lock of a expired")
+	})
+}
@@ -0,0 +1,72 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// maxRunLease is how long an instance keeps other instances from running a scheduled job while it runs
+// it. Runs that take longer may overlap with a run elsewhere; instances that stop while running a job
+// hand it over to another once the lease is over.
+const maxRunLease = 10 * time.Minute
+
+type (
+	// Lock is a lease on a scheduled job, held by one instance of the server until it expires
+	Lock struct {
+		// Name is the name of the job
+		Name string `gorm:"primaryKey;size:128"`
+		// Owner identifies the instance holding the lock
+		Owner string `gorm:"size:128;not null"`
+		// ExpiresAt is when other instances may take the lock over
+		ExpiresAt time.Time `gorm:"not null"`
+	}
+
+	// Locker hands out locks shared by all the instances of the server
+	Locker interface {
+		// AcquireLock takes the lock for owner until the given time, returning false when another owner
+		// holds it at now. Owners acquire locks they hold again.
+		AcquireLock(name, owner string, now, until time.Time) (bool, error)
+		// ReleaseLock keeps the lock of owner until the given time only
+		ReleaseLock(name, owner string, until time.Time) error
+	}
+)
+
+// TableName names the table after the jobs whose locks it holds.
+func (Lock) TableName() string {
+	return "job_locks"
+}
+
+// SetLocker has the jobs of the scheduler run by one instance of the server at a time. A run takes the
+// job's lock and keeps it until the job's next tick, so however many instances are deployed a job runs
+// about once per interval.
+func (s *Scheduler) SetLocker(locker Locker) {
+	s.locker = locker
+	s.owner = instanceID()
+}
+
+// runLocked runs the job if no other instance holds its lock
+func (s *Scheduler) runLocked(ctx context.Context, j job) error {
+	start := time.Now()
+	acquired, err := s.locker.AcquireLock(j.name, s.owner, start, start.Add(max(j.interval, maxRunLease)))
+	if err != nil || !acquired {
+		return err
+	}
+	err = j.run(ctx)
+	if releaseErr := s.locker.ReleaseLock(j.name, s.owner, start.Add(j.interval)); releaseErr != nil {
+		return errors.Join(err, fmt.Errorf("failed to release lock: %w", releaseErr))
+	}
+	return err
+}
+
+// instanceID identifies this process among the instances of the server sharing the locks
+func instanceID() string {
+	host, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix))
+}
@@ -0,0 +1,241 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// StatusPending jobs are waiting for their next attempt
+	StatusPending = "pending"
+	// StatusDead jobs failed as often as their type allows and are no longer retried
+	StatusDead = "dead"
+
+	// DefaultMaxAttempts is how many times jobs of types registered without a limit are tried
+	DefaultMaxAttempts = 5
+	// retryBackoff is the delay before the first retry, doubled for every following one
+	retryBackoff = 30 * time.Second
+	// maxRetryBackoff caps the delay between retries
+	maxRetryBackoff = time.Hour
+	// claimLease is how long a claimed job is hidden from other workers while it runs. Jobs of workers
+	// that stopped while running them are run again once it is over.
+	claimLease = 5 * time.Minute
+)
+
+// processed counts the jobs run by type and outcome: done, retried or dead
+var processed = expvar.NewMap("jobs_processed")
+
+type (
+	// Job is a unit of work queued in the database. Jobs are deleted once they succeed.
+	Job struct {
+		gorm.Model
+		// Type selects the Handler running the job, e.g. "mail.send"
+		Type string `gorm:"size:64;not null;index"`
+		// Payload is the JSON argument of the handler
+		Payload string `gorm:"type:text;not null"`
+		// Status is pending or dead
+		Status string `gorm:"size:32;not null;index:idx_job_due"`
+		// Attempts counts the runs so far
+		Attempts int `gorm:"not null;default:0"`
+		// RunAt is when a pending job is due
+		RunAt time.Time `gorm:"index:idx_job_due"`
+		// LastError describes why the last run failed
+		LastError string `gorm:"size:1024"`
+	}
+
+	// Store persists the queue
+	Store interface {
+		// EnqueueJob stores a new job
+		EnqueueJob(job *Job) error
+		// ClaimJobs returns up to limit due jobs of the types, hiding them from other callers for lease
+		ClaimJobs(types []string, now time.Time, lease time.Duration, limit int) ([]Job, error)
+		// SaveJob stores the outcome of a failed run
+		SaveJob(job *Job) error
+		// DeleteJob removes a job that succeeded
+		DeleteJob(id uint) error
+	}
+
+	// Handler runs a job with its payload. Errors have the job retried later.
+	Handler func(ctx context.Context, payload []byte) error
+
+	// Queue adds jobs to the queue for workers to run.
+	Queue struct {
+		store Store
+		now   func() time.Time
+	}
+
+	// Worker runs the queued jobs of the types registered with it, up to concurrency at a time.
+	Worker struct {
+		store        Store
+		concurrency  int
+		pollInterval time.Duration
+		handlers     map[string]registration
+		now          func() time.Time
+		wg           sync.WaitGroup
+	}
+
+	registration struct {
+		handle      Handler
+		maxAttempts int
+	}
+)
+
+// TableName names the table after the jobs it holds.
+func (Job) TableName() string {
+	return "jobs"
+}
+
+// NewQueue creates a Queue storing jobs in store
+func NewQueue(store Store) *Queue {
+	return &Queue{store: store, now: time.Now}
+}
+
+// Enqueue queues a job of the type to run as soon as a worker is free. The payload is encoded as JSON.
+func (q *Queue) Enqueue(jobType string, payload any) error {
+	return q.Schedule(jobType, payload, q.now())
+}
+
+// Schedule queues a job of the type to run at the given time. The payload is encoded as JSON.
+func (q *Queue) Schedule(jobType string, payload any, at time.Time) error {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s job: %w", jobType, err)
+	}
+	return q.store.EnqueueJob(&Job{Type: jobType, Payload: string(encoded), Status: StatusPending, RunAt: at})
+}
+
+// NewWorker creates a Worker running up to concurrency jobs at a time, looking for due jobs every
+// pollInterval once started.
+func NewWorker(store Store, concurrency int, pollInterval time.Duration) *Worker {
+	return &Worker{
+		store:        store,
+		concurrency:  max(concurrency, 1),
+		pollInterval: pollInterval,
+		handlers:     map[string]registration{},
+		now:          time.Now,
+	}
+}
+
+// Register runs the jobs of the type with handle, trying each up to maxAttempts times before it is
+// marked dead, DefaultMaxAttempts when 0. Jobs are registered before the worker is started.
+func (w *Worker) Register(jobType string, maxAttempts int, handle Handler) {
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	w.handlers[jobType] = registration{handle: handle, maxAttempts: maxAttempts}
+}
+
+// Start runs due jobs every poll interval until ctx is cancelled. Failures to reach the queue are
+// logged and tried again at the next tick.
+func (w *Worker) Start(ctx context.Context) {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		ticker := time.NewTicker(w.pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// Keep going while full batches are claimed, so a backlog drains without waiting
+				for {
+					ran, err := w.RunDue(ctx)
+					if err != nil {
+						log.Printf("Job worker failed: %v", err)
+					}
+					if err != nil || ran < w.concurrency || ctx.Err() != nil {
+						break
+					}
+				}
+			}
+		}
+	}()
+}
+
+// Wait blocks until the worker has returned after the context passed to Start is cancelled
+func (w *Worker) Wait() {
+	w.wg.Wait()
+}
+
+// RunDue claims up to concurrency due jobs and runs them concurrently, returning how many ran. Failed
+// jobs are rescheduled with exponential backoff until their type's maximum attempts are reached, then
+// marked dead. Only errors of the store are returned.
+func (w *Worker) RunDue(ctx context.Context) (int, error) {
+	types := make([]string, 0, len(w.handlers))
+	for jobType := range w.handlers {
+		types = append(types, jobType)
+	}
+	claimed, err := w.store.ClaimJobs(types, w.now(), claimLease, w.concurrency)
+	if err != nil || len(claimed) == 0 {
+		return 0, err
+	}
+
+	errs := make([]error, len(claimed))
+	var wg sync.WaitGroup
+	for i := range claimed {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = w.run(ctx, &claimed[i])
+		}(i)
+	}
+	wg.Wait()
+	return len(claimed), errors.Join(errs...)
+}
+
+// run makes one attempt at the job and records its outcome, returning an error only when that fails
+func (w *Worker) run(ctx context.Context, job *Job) error {
+	registered := w.handlers[job.Type]
+	job.Attempts++
+	err := runHandler(ctx, registered.handle, []byte(job.Payload))
+
+	switch {
+	case err == nil:
+		processed.Add(job.Type+" done", 1)
+		return w.store.DeleteJob(job.ID)
+	case job.Attempts >= registered.maxAttempts:
+		processed.Add(job.Type+" dead", 1)
+		log.Printf("Job %s %d failed for good after %d attempts: %v", job.Type, job.ID, job.Attempts, err)
+		job.Status = StatusDead
+	default:
+		processed.Add(job.Type+" retried", 1)
+		job.RunAt = w.now().Add(backoff(job.Attempts))
+	}
+	job.LastError = truncate(err.Error(), 1024)
+	return w.store.SaveJob(job)
+}
+
+// runHandler runs the handler, turning a panic into an error so it doesn't take the server down
+func runHandler(ctx context.Context, handle Handler, payload []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handle(ctx, payload)
+}
+
+// backoff returns the delay before the attempt following the given number of failed attempts
+func backoff(attempts int) time.Duration {
+	delay := retryBackoff
+	for i := 1; i < attempts && delay < maxRetryBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryBackoff)
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
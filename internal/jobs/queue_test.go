@@ -0,0 +1,159 @@
+package jobs_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"interview/internal/jobs"
+	"interview/internal/mail"
+	"interview/internal/repo"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupRepo(t *testing.T) (*repo.Repository, *gorm.DB) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	// Every connection to an in-memory database opens a new one; workers and schedulers share one
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	require.NoError(t, repo.Migrate(db))
+	return repo.NewRepository(db), db
+}
+
+type fakeMailer struct {
+	sent []mail.Message
+	err  error
+}
+
+func (m *fakeMailer) Send(_ context.Context, msg mail.Message) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func TestWorker(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("runs queued jobs and deletes them", func(t *testing.T) {
+		store, db := setupRepo(t)
+		queue := jobs.NewQueue(store)
+		worker := jobs.NewWorker(store, 2, time.Second)
+		var got []string
+		worker.Register("greet", 0, func(_ context.Context, payload []byte) error {
+			var name string
+			require.NoError(t, json.Unmarshal(payload, &name))
+			got = append(got, name)
+			return nil
+		})
+
+		require.NoError(t, queue.Enqueue("greet", "Ada"))
+		ran, err := worker.RunDue(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, ran)
+		assert.Equal(t, []string{"Ada"}, got)
+
+		var left int64
+		require.NoError(t, db.Unscoped().Model(&jobs.Job{}).Count(&left).Error)
+		assert.Zero(t, left)
+	})
+
+	t.Run("leaves scheduled jobs until they are due", func(t *testing.T) {
+		store, _ := setupRepo(t)
+		queue := jobs.NewQueue(store)
+		worker := jobs.NewWorker(store, 2, time.Second)
+		worker.Register("later", 0, func(context.Context, []byte) error { return nil })
+
+		require.NoError(t, queue.Schedule("later", nil, time.Now().Add(time.Hour)))
+		ran, err := worker.RunDue(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, ran)
+	})
+
+	t.Run("only claims jobs of registered types", func(t *testing.T) {
+		store, _ := setupRepo(t)
+		queue := jobs.NewQueue(store)
+		worker := jobs.NewWorker(store, 2, time.Second)
+		worker.Register("known", 0, func(context.Context, []byte) error { return nil })
+
+		require.NoError(t, queue.Enqueue("unknown", nil))
+		ran, err := worker.RunDue(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, ran)
+	})
+
+	t.Run("retries failed jobs with backoff until they are dead", func(t *testing.T) {
+		store, db := setupRepo(t)
+		queue := jobs.NewQueue(store)
+		worker := jobs.NewWorker(store, 2, time.Second)
+		var runs atomic.Int32
+		worker.Register("flaky", 2, func(context.Context, []byte) error {
+			runs.Add(1)
+			return errors.New("boom")
+		})
+		require.NoError(t, queue.Enqueue("flaky", nil))
+
+		_, err := worker.RunDue(ctx)
+		require.NoError(t, err)
+		var job jobs.Job
+		require.NoError(t, db.First(&job).Error)
+		assert.Equal(t, jobs.StatusPending, job.Status)
+		assert.Equal(t, 1, job.Attempts)
+		assert.Equal(t, "boom", job.LastError)
+		assert.WithinDuration(t, time.Now().Add(30*time.Second), job.RunAt, 5*time.Second)
+
+		ran, err := worker.RunDue(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, ran, "the job waits for its backoff")
+
+		require.NoError(t, db.Model(&job).Update("run_at", time.Now().Add(-time.Second)).Error)
+		_, err = worker.RunDue(ctx)
+		require.NoError(t, err)
+		require.NoError(t, db.First(&job).Error)
+		assert.Equal(t, jobs.StatusDead, job.Status)
+		assert.Equal(t, int32(2), runs.Load())
+	})
+
+	t.Run("recovers from panics", func(t *testing.T) {
+		store, db := setupRepo(t)
+		queue := jobs.NewQueue(store)
+		worker := jobs.NewWorker(store, 2, time.Second)
+		worker.Register("panics", 0, func(context.Context, []byte) error { panic("oops") })
+		require.NoError(t, queue.Enqueue("panics", nil))
+
+		_, err := worker.RunDue(ctx)
+		require.NoError(t, err)
+		var job jobs.Job
+		require.NoError(t, db.First(&job).Error)
+		assert.Equal(t, "panic: oops", job.LastError)
+	})
+
+	t.Run("sends queued emails", func(t *testing.T) {
+		store, db := setupRepo(t)
+		mailer := &fakeMailer{err: errors.New("mail server down")}
+		worker := jobs.NewWorker(store, 2, time.Second)
+		worker.Register(mail.SendJob, 0, mail.HandleSend(mailer))
+
+		msg := mail.Message{To: "ada@example.com", Subject: "Hi", Body: "Hello"}
+		require.NoError(t, mail.NewQueued(jobs.NewQueue(store)).Send(ctx, msg))
+		_, err := worker.RunDue(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, mailer.sent, "the email stays queued while the mail server is down")
+
+		mailer.err = nil
+		require.NoError(t, db.Model(&jobs.Job{}).Where("1 = 1").Update("run_at", time.Now().Add(-time.Second)).Error)
+		_, err = worker.RunDue(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []mail.Message{msg}, mailer.sent)
+	})
+}
@@ -0,0 +1,130 @@
+// Package lowstock alerts staff when the stock of products falls below their low-stock threshold.
+package lowstock
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"interview/internal/events"
+	"interview/internal/mail"
+	"interview/internal/product"
+	"interview/internal/repo"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var (
+	// alertsSent counts the low-stock alerts raised since the server started
+	alertsSent = expvar.NewInt("low_stock_alerts")
+	// lowProducts is the number of products below their threshold at the last check, snoozed or not
+	lowProducts = expvar.NewInt("low_stock_products")
+)
+
+var emailTemplate = template.Must(template.New("lowstock").Parse(`Hello,
+
+these products are running low:
+{{ range . }}
+- {{ .Name }}: {{ .Stock }} left, threshold {{ .Threshold }}{{ end }}
+
+Alerts about them are snoozed until they are restocked or the snooze runs out.
+`))
+
+// Alerter emails staff about products that run low and publishes an events.TypeLowStock event for
+// each, so the admin dashboard shows them live. Each alert snoozes the product for a while so the
+// check doesn't repeat it every run.
+type Alerter struct {
+	repo       *repo.Repository
+	mailer     mail.Mailer
+	recipients []string
+	bus        *events.Bus
+	snooze     time.Duration
+	now        func() time.Time
+}
+
+// NewAlerter creates an Alerter emailing the recipients, if any, and publishing to bus, if not nil.
+// Products alerted about are snoozed for snooze.
+func NewAlerter(r *repo.Repository, mailer mail.Mailer, recipients []string, bus *events.Bus, snooze time.Duration) *Alerter {
+	return &Alerter{
+		repo:       r,
+		mailer:     mailer,
+		recipients: recipients,
+		bus:        bus,
+		snooze:     snooze,
+		now:        time.Now,
+	}
+}
+
+// SetClock replaces the clock of the alerter, for tests.
+func (a *Alerter) SetClock(now func() time.Time) {
+	a.now = now
+}
+
+// Run alerts about every product below its threshold whose alert isn't snoozed and returns how many
+// were alerted about. Alerts of products restocked since are woken up first. Alerts are snoozed before
+// they are emailed, so failed emails aren't sent again until the snooze runs out.
+func (a *Alerter) Run(ctx context.Context) (int, error) {
+	if err := a.repo.WakeRestockedAlerts(); err != nil {
+		return 0, err
+	}
+	products, err := a.repo.ListLowStockProducts()
+	if err != nil {
+		return 0, err
+	}
+	lowProducts.Set(int64(len(products)))
+
+	now := a.now()
+	var alerted []product.Product
+	for _, p := range products {
+		claimed, err := a.repo.ClaimLowStockAlert(p.ID, now, now.Add(a.snooze))
+		if err != nil {
+			return 0, err
+		}
+		if !claimed {
+			continue
+		}
+		alerted = append(alerted, p)
+		alertsSent.Add(1)
+		if a.bus != nil {
+			a.bus.Publish(events.Event{Type: events.TypeLowStock, Product: p.Name, Quantity: *p.Stock})
+		}
+	}
+	if len(alerted) == 0 {
+		return 0, nil
+	}
+
+	var body strings.Builder
+	if err := emailTemplate.Execute(&body, emailLines(alerted)); err != nil {
+		return len(alerted), fmt.Errorf("failed to render low-stock alert: %w", err)
+	}
+	subject := fmt.Sprintf("%d products are running low", len(alerted))
+	if len(alerted) == 1 {
+		subject = alerted[0].Name + " is running low"
+	}
+	var errs []error
+	for _, to := range a.recipients {
+		if err := ctx.Err(); err != nil {
+			return len(alerted), err
+		}
+		if err := a.mailer.Send(ctx, mail.Message{To: to, Subject: subject, Body: body.String()}); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", to, err))
+		}
+	}
+	return len(alerted), errors.Join(errs...)
+}
+
+// lowStockLine is a product of the alert email
+type lowStockLine struct {
+	Name      string
+	Stock     int
+	Threshold int
+}
+
+func emailLines(products []product.Product) []lowStockLine {
+	lines := make([]lowStockLine, len(products))
+	for i, p := range products {
+		lines[i] = lowStockLine{Name: p.Name, Stock: *p.Stock, Threshold: *p.LowStockThreshold}
+	}
+	return lines
+}
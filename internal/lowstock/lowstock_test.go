@@ -0,0 +1,121 @@
+package lowstock_test
+
+import (
+	"context"
+	"errors"
+	"interview/internal/events"
+	"interview/internal/lowstock"
+	"interview/internal/mail"
+	"interview/internal/repo"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type fakeMailer struct {
+	sent []mail.Message
+	err  error
+}
+
+func (m *fakeMailer) Send(_ context.Context, msg mail.Message) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func TestAlerter(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, repo.Migrate(db))
+	cartRepo := repo.NewRepository(db)
+
+	shoe, err := cartRepo.UpsertProduct("shoe", 10)
+	require.NoError(t, err)
+	_, err = cartRepo.UpsertProduct("sock", 2)
+	require.NoError(t, err)
+	setStock := func(t *testing.T, stock int) {
+		t.Helper()
+		require.NoError(t, cartRepo.SetProductStock(shoe.ID, &stock))
+	}
+	setStock(t, 5)
+	threshold := 3
+	require.NoError(t, cartRepo.SetLowStockThreshold(shoe.ID, &threshold))
+
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	mailer := &fakeMailer{}
+	bus := events.NewBus()
+	received, unsubscribe := bus.Subscribe()
+	defer unsubscribe()
+	alerter := lowstock.NewAlerter(cartRepo, mailer, []string{"ops@example.com", "buyer@example.com"}, bus, 24*time.Hour)
+	alerter.SetClock(func() time.Time { return now })
+	run := func(t *testing.T) int {
+		t.Helper()
+		alerted, err := alerter.Run(context.Background())
+		require.NoError(t, err)
+		return alerted
+	}
+
+	t.Run("Waits For Stock To Run Low", func(t *testing.T) {
+		setStock(t, 3)
+		assert.Equal(t, 0, run(t))
+		assert.Empty(t, mailer.sent)
+	})
+
+	t.Run("Alerts Staff", func(t *testing.T) {
+		setStock(t, 2)
+		assert.Equal(t, 1, run(t))
+		require.Len(t, mailer.sent, 2)
+		assert.Equal(t, "ops@example.com", mailer.sent[0].To)
+		assert.Equal(t, "shoe is running low", mailer.sent[0].Subject)
+		assert.Contains(t, mailer.sent[0].Body, "- shoe: 2 left, threshold 3")
+
+		e := <-received
+		assert.Equal(t, events.TypeLowStock, e.Type)
+		assert.Equal(t, "shoe", e.Product)
+		assert.Equal(t, 2, e.Quantity)
+	})
+
+	t.Run("Snoozes Repeated Alerts", func(t *testing.T) {
+		setStock(t, 1)
+		now = now.Add(time.Hour)
+		assert.Equal(t, 0, run(t))
+		assert.Len(t, mailer.sent, 2)
+
+		now = now.Add(24 * time.Hour)
+		assert.Equal(t, 1, run(t))
+		assert.Len(t, mailer.sent, 4)
+		<-received
+	})
+
+	t.Run("Restocking Wakes Alerts Up", func(t *testing.T) {
+		setStock(t, 10)
+		assert.Equal(t, 0, run(t))
+		setStock(t, 0)
+		assert.Equal(t, 1, run(t))
+		assert.Len(t, mailer.sent, 6)
+		<-received
+	})
+
+	t.Run("Staff Can Snooze Alerts", func(t *testing.T) {
+		setStock(t, 10)
+		run(t)
+		require.NoError(t, cartRepo.SnoozeLowStock(shoe.ID, now.Add(72*time.Hour)))
+		setStock(t, 1)
+		now = now.Add(48 * time.Hour)
+		assert.Equal(t, 0, run(t))
+	})
+
+	t.Run("Failed Emails Are Reported", func(t *testing.T) {
+		now = now.Add(48 * time.Hour)
+		mailer.err = errors.New("connection refused")
+		alerted, err := alerter.Run(context.Background())
+		assert.Equal(t, 1, alerted)
+		assert.ErrorContains(t, err, "connection refused")
+	})
+}
@@ -0,0 +1,88 @@
+// Package mail sends transactional emails over SMTP.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+type (
+	// Message is a plain text email
+	Message struct {
+		To      string `json:"to"`
+		Subject string `json:"subject"`
+		Body    string `json:"body"`
+	}
+
+	// Mailer sends emails
+	Mailer interface {
+		Send(ctx context.Context, msg Message) error
+	}
+
+	// SMTP sends emails through an SMTP server, authenticating when a username is set
+	SMTP struct {
+		addr     string
+		host     string
+		from     string
+		username string
+		password string
+	}
+
+	// LogMailer writes emails to the log instead of sending them, for development
+	LogMailer struct{}
+)
+
+// NewSMTP creates a Mailer sending from the given address through the SMTP server at host:port
+func NewSMTP(host, port, username, password, from string) *SMTP {
+	return &SMTP{
+		addr:     net.JoinHostPort(host, port),
+		host:     host,
+		from:     from,
+		username: username,
+		password: password,
+	}
+}
+
+// Send implements Mailer.
+func (m *SMTP) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+	if err := smtp.SendMail(m.addr, auth, m.from, []string{msg.To}, m.format(msg)); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// format renders the message with the headers required by RFC 5322
+func (m *SMTP) format(msg Message) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", m.from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return b.Bytes()
+}
+
+// Send implements Mailer.
+func (LogMailer) Send(_ context.Context, msg Message) error {
+	log.Printf("Email to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
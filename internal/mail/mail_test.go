@@ -0,0 +1,78 @@
+package mail_test
+
+import (
+	"context"
+	"interview/internal/mail"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSMTP accepts a single message without authentication and returns what the client sent
+func fakeSMTP(t *testing.T) (host, port string, received <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	out := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tp := textproto.NewConn(conn)
+		_ = tp.PrintfLine("220 localhost ESMTP")
+
+		var transcript strings.Builder
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			transcript.WriteString(line + "\n")
+			switch {
+			case strings.HasPrefix(line, "EHLO"), strings.HasPrefix(line, "HELO"):
+				_ = tp.PrintfLine("250 localhost")
+			case line == "DATA":
+				_ = tp.PrintfLine("354 go ahead")
+				data, _ := tp.ReadDotLines()
+				transcript.WriteString(strings.Join(data, "\n"))
+				_ = tp.PrintfLine("250 queued")
+			case line == "QUIT":
+				_ = tp.PrintfLine("221 bye")
+				out <- transcript.String()
+				return
+			default:
+				_ = tp.PrintfLine("250 ok")
+			}
+		}
+	}()
+
+	host, port, err = net.SplitHostPort(ln.Addr().String())
+	require.NoError(t, err)
+	return host, port, out
+}
+
+func TestSMTP(t *testing.T) {
+	host, port, received := fakeSMTP(t)
+	mailer := mail.NewSMTP(host, port, "", "", "shop@example.com")
+
+	err := mailer.Send(context.Background(), mail.Message{
+		To:      "jane@example.com",
+		Subject: "Your cart is waiting",
+		Body:    "Hello Jane,\nyour cart is still there.",
+	})
+	require.NoError(t, err)
+
+	transcript := <-received
+	assert.Contains(t, transcript, "MAIL FROM:<shop@example.com>")
+	assert.Contains(t, transcript, "RCPT TO:<jane@example.com>")
+	assert.Contains(t, transcript, "Subject: Your cart is waiting")
+	assert.Contains(t, transcript, "Content-Type: text/plain; charset=utf-8")
+	assert.Contains(t, transcript, "Hello Jane,\nyour cart is still there.")
+}
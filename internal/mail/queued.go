@@ -0,0 +1,47 @@
+package mail
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// SendJob is the job type of the emails queued by Queued
+const SendJob = "mail.send"
+
+type (
+	// Enqueuer queues jobs for background workers, like jobs.Queue
+	Enqueuer interface {
+		Enqueue(jobType string, payload any) error
+	}
+
+	// Queued queues emails as jobs instead of sending them, so they survive restarts and are retried
+	// while the mail server is unavailable. Workers send them with the handler of HandleSend.
+	Queued struct {
+		queue Enqueuer
+	}
+)
+
+// NewQueued creates a Mailer queueing emails in queue
+func NewQueued(queue Enqueuer) *Queued {
+	return &Queued{queue: queue}
+}
+
+// Send implements Mailer.
+func (q *Queued) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return q.queue.Enqueue(SendJob, msg)
+}
+
+// HandleSend returns the job handler sending the emails queued by Queued with mailer.
+func HandleSend(mailer Mailer) func(ctx context.Context, payload []byte) error {
+	return func(ctx context.Context, payload []byte) error {
+		var msg Message
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			return fmt.Errorf("failed to decode email: %w", err)
+		}
+		return mailer.Send(ctx, msg)
+	}
+}
@@ -0,0 +1,83 @@
+// Package notification keeps the in-app notifications of customers, e.g. that the price of an item in
+// their cart dropped or that their order shipped, created from the events of the cart activity bus.
+package notification
+
+import (
+	"errors"
+	"interview/internal/cart"
+	"interview/internal/events"
+	"interview/internal/order"
+	"time"
+)
+
+// Kinds of notifications
+const (
+	// KindPriceDropped tells that the price of Product in a cart dropped to Price
+	KindPriceDropped = "price_dropped"
+	// KindOrderShipped tells that the order OrderID shipped
+	KindOrderShipped = "order_shipped"
+)
+
+// ErrNotificationNotFound is returned for notifications that don't exist or belong to someone else
+var ErrNotificationNotFound = errors.New("notification not found")
+
+type (
+	// Notification is a message to a customer, shown until they mark it read
+	Notification struct {
+		ID uint `gorm:"primarykey"`
+		// UserID is the user notified, nil for anonymous customers, who are notified in SessionID
+		UserID    *uint  `gorm:"index"`
+		SessionID string `gorm:"size:255;index"`
+		Kind      string `gorm:"size:32;not null"`
+		// Product, Price and OrderID are what the notification is about, depending on its kind
+		Product string `gorm:"size:255"`
+		Price   float64
+		OrderID uint
+		// ReadAt is when the customer marked the notification read, nil while it is unread
+		ReadAt    *time.Time
+		CreatedAt time.Time
+	}
+
+	// Store persists notifications
+	Store interface {
+		// NotifyCartOwner stores the notification for the customer the cart belongs to, failing with
+		// cart.ErrCartNotFound for missing carts
+		NotifyCartOwner(cartID uint, n *Notification) error
+	}
+
+	// Notifier turns the events of the bus customers care about into notifications
+	Notifier struct {
+		store Store
+	}
+)
+
+// NewNotifier creates a Notifier storing notifications in store.
+func NewNotifier(store Store) *Notifier {
+	return &Notifier{store: store}
+}
+
+// Wants reports whether customers are notified of events of the type. Attached to the bus as an
+// events.Consumer, the Notifier stores notifications while events are published, so none are missed.
+func (n *Notifier) Wants(eventType string) bool {
+	return eventType == events.TypePriceDropped || eventType == events.TypeOrderStatusChanged
+}
+
+// Handle notifies the owner of the cart of the event if it is one customers are notified about. Events
+// of carts deleted since are dropped.
+func (n *Notifier) Handle(e events.Event) error {
+	var notification *Notification
+	switch {
+	case e.Type == events.TypePriceDropped:
+		notification = &Notification{Kind: KindPriceDropped, Product: e.Product, Price: e.Price}
+	case e.Type == events.TypeOrderStatusChanged && e.Status == order.StatusShipped:
+		notification = &Notification{Kind: KindOrderShipped, OrderID: e.OrderID}
+	default:
+		return nil
+	}
+	notification.CreatedAt = e.Time
+	err := n.store.NotifyCartOwner(e.CartID, notification)
+	if errors.Is(err, cart.ErrCartNotFound) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,93 @@
+package notification_test
+
+import (
+	"interview/internal/cart"
+	"interview/internal/events"
+	"interview/internal/notification"
+	"interview/internal/order"
+	"interview/internal/repo"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestNotifier(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, repo.Migrate(db))
+	r := repo.NewRepository(db)
+	notifier := notification.NewNotifier(r)
+
+	anonymous, err := r.GetOrCreateCart("anonymous", cart.DefaultName)
+	require.NoError(t, err)
+	u, err := r.FindOrCreateUser("github", "1", "jane@example.com", "Jane")
+	require.NoError(t, err)
+	owned, err := r.GetOrCreateCart("jane", cart.DefaultName)
+	require.NoError(t, err)
+	require.NoError(t, r.AssignCartToUser("jane", u.ID))
+	at := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		event     events.Event
+		userID    uint
+		sessionID string
+		want      *notification.Notification
+	}{
+		{
+			name:      "Price Drop In An Anonymous Cart",
+			event:     events.Event{Type: events.TypePriceDropped, CartID: anonymous.ID, Product: "shoe", Price: 8},
+			sessionID: "anonymous",
+			want:      &notification.Notification{Kind: notification.KindPriceDropped, Product: "shoe", Price: 8},
+		},
+		{
+			name:   "Order Of A User Shipped",
+			event:  events.Event{Type: events.TypeOrderStatusChanged, CartID: owned.ID, OrderID: 7, Status: order.StatusShipped},
+			userID: u.ID,
+			want:   &notification.Notification{Kind: notification.KindOrderShipped, OrderID: 7},
+		},
+		{
+			name:   "Other Order Statuses Are Ignored",
+			event:  events.Event{Type: events.TypeOrderStatusChanged, CartID: owned.ID, OrderID: 7, Status: order.StatusPaid},
+			userID: u.ID,
+		},
+		{
+			name:      "Other Events Are Ignored",
+			event:     events.Event{Type: events.TypeItemAdded, CartID: anonymous.ID, Product: "shoe"},
+			sessionID: "anonymous",
+		},
+		{
+			name:  "Missing Carts Are Dropped",
+			event: events.Event{Type: events.TypePriceDropped, CartID: 9999, Product: "shoe", Price: 8},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, db.Exec("DELETE FROM notifications").Error)
+			tt.event.Time = at
+			require.NoError(t, notifier.Handle(tt.event))
+
+			var stored []notification.Notification
+			require.NoError(t, db.Find(&stored).Error)
+			if tt.want == nil {
+				assert.Empty(t, stored)
+				return
+			}
+			require.Len(t, stored, 1)
+			notifications, unread, err := r.ListNotifications(tt.userID, tt.sessionID, 10)
+			require.NoError(t, err)
+			require.Len(t, notifications, 1)
+			assert.EqualValues(t, 1, unread)
+			got := notifications[0]
+			assert.Equal(t, tt.want.Kind, got.Kind)
+			assert.Equal(t, tt.want.Product, got.Product)
+			assert.Equal(t, tt.want.Price, got.Price)
+			assert.Equal(t, tt.want.OrderID, got.OrderID)
+			assert.True(t, at.Equal(got.CreatedAt))
+		})
+	}
+}
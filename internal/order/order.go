@@ -0,0 +1,172 @@
+// Package order tracks checked out carts through payment, fulfilment and shipping.
+package order
+
+import (
+	"errors"
+	"interview/internal/address"
+	"interview/internal/vat"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// StatusPending orders were checked out but not paid yet
+	StatusPending = "pending"
+	// StatusPaid orders had their payment captured
+	StatusPaid = "paid"
+	// StatusFulfilled orders were packed and are ready to ship
+	StatusFulfilled = "fulfilled"
+	// StatusShipped orders were handed to the carrier
+	StatusShipped = "shipped"
+	// StatusCancelled orders were given up before they were paid
+	StatusCancelled = "cancelled"
+	// StatusRefunded orders were paid back to the customer
+	StatusRefunded = "refunded"
+
+	// RefundPending refunds were recorded but not paid back in full yet
+	RefundPending = "pending"
+	// RefundCompleted refunds were paid back to the customer
+	RefundCompleted = "completed"
+)
+
+// transitions lists the statuses each status can change to. Cancelled and refunded orders are final.
+var transitions = map[string][]string{
+	StatusPending:   {StatusPaid, StatusCancelled},
+	StatusPaid:      {StatusFulfilled, StatusRefunded},
+	StatusFulfilled: {StatusShipped, StatusRefunded},
+	StatusShipped:   {StatusRefunded},
+}
+
+var (
+	// ErrOrderNotFound is returned for orders that don't exist
+	ErrOrderNotFound = errors.New("order not found")
+	// ErrInvalidStatus is returned for statuses other than the Status constants
+	ErrInvalidStatus = errors.New("invalid order status")
+	// ErrInvalidTransition is returned when an order can't change from its status to another one
+	ErrInvalidTransition = errors.New("invalid order status transition")
+	// ErrRefundNotFound is returned for refunds that don't exist
+	ErrRefundNotFound = errors.New("refund not found")
+	// ErrNotRefundable is returned when refunding an order that isn't paid, fulfilled or shipped
+	ErrNotRefundable = errors.New("order can't be refunded")
+	// ErrInvalidRefund is returned when refunding items the order doesn't have, more units than are left
+	// to refund or more than was paid
+	ErrInvalidRefund = errors.New("invalid refund")
+	// ErrRefundPending is returned when refunding an order while another refund of it isn't paid back yet
+	ErrRefundPending = errors.New("a refund of the order is still pending")
+)
+
+type (
+	// Order is a checked out cart, created pending when the cart is closed
+	Order struct {
+		gorm.Model
+		// TenantID is the shop the order was placed in, that of its cart
+		TenantID string `gorm:"size:32;index;not null;default:default"`
+		CartID   uint   `gorm:"uniqueIndex;not null"`
+		// Status is one of the Status constants, only changed along the allowed transitions
+		Status string `gorm:"size:16;index;not null"`
+		// History lists the status changes of the order, oldest first
+		History []History
+		// VATEvidence is the validation of the VAT ID given at checkout, nil when none was given
+		VATEvidenceID *uint
+		VATEvidence   *vat.Evidence
+		// ShippingAddress and BillingAddress are copied from the address book at checkout, empty when
+		// none was chosen
+		ShippingAddress Address `gorm:"embedded;embeddedPrefix:shipping_"`
+		BillingAddress  Address `gorm:"embedded;embeddedPrefix:billing_"`
+	}
+
+	// Address is a postal address copied onto an order, so changing or deleting it in the address book
+	// doesn't change where the order went. See address.Address for the fields.
+	Address struct {
+		Name       string `gorm:"size:255"`
+		Line1      string `gorm:"size:255"`
+		Line2      string `gorm:"size:255"`
+		City       string `gorm:"size:255"`
+		PostalCode string `gorm:"size:32"`
+		Country    string `gorm:"size:2"`
+		Phone      string `gorm:"size:64"`
+	}
+
+	// History records a status change of an order
+	History struct {
+		ID      uint `gorm:"primarykey"`
+		OrderID uint `gorm:"index;not null"`
+		// FromStatus is empty for the change creating the order
+		FromStatus string `gorm:"size:16"`
+		ToStatus   string `gorm:"size:16;not null"`
+		// Actor is who changed the status, e.g. "checkout" or the role of a staff member
+		Actor     string `gorm:"size:64;not null"`
+		Note      string `gorm:"size:1024"`
+		CreatedAt time.Time
+	}
+)
+
+type (
+	// Refund pays an order back, all of it or some of its items. It stays pending until its Amount was
+	// paid back through the payment provider.
+	Refund struct {
+		gorm.Model
+		// TenantID is the shop of the refunded order
+		TenantID string `gorm:"size:32;index;not null;default:default"`
+		OrderID  uint   `gorm:"index;not null"`
+		// Amount is what the customer gets back, Paid how much of it the provider paid back so far
+		Amount float64 `gorm:"not null"`
+		Paid   float64 `gorm:"not null;default:0"`
+		// Status is RefundPending or RefundCompleted
+		Status string `gorm:"size:16;index;not null"`
+		// Actor is the staff member who issued the refund
+		Actor string `gorm:"size:64;not null"`
+		Note  string `gorm:"size:1024"`
+		// Restocked is set once the refunded items went back to the stock
+		Restocked bool `gorm:"not null;default:false"`
+		// Lines are the refunded items, none for refunds of an amount only
+		Lines []RefundLine
+	}
+
+	// RefundLine is an item of an order refunded
+	RefundLine struct {
+		ID          uint   `gorm:"primarykey"`
+		RefundID    uint   `gorm:"index;not null"`
+		CartItemID  uint   `gorm:"index;not null"`
+		ProductName string `gorm:"size:255;not null"`
+		Quantity    int    `gorm:"not null"`
+		// Amount is the price the units were bought for
+		Amount float64 `gorm:"not null"`
+	}
+)
+
+// TableName names the table after what the rows are a history of.
+func (History) TableName() string {
+	return "order_history"
+}
+
+// ValidStatus reports whether status is one of the Status constants.
+func ValidStatus(status string) bool {
+	_, ok := transitions[status]
+	return ok || status == StatusCancelled || status == StatusRefunded
+}
+
+// CanTransition reports whether an order can change from one status to the other.
+func CanTransition(from, to string) bool {
+	return slices.Contains(transitions[from], to)
+}
+
+// Refundable reports whether an order with the status can be refunded, all of it or some of its items.
+func Refundable(status string) bool {
+	return CanTransition(status, StatusRefunded)
+}
+
+// Next returns the statuses an order can change to from status, none for final statuses.
+func Next(status string) []string {
+	return slices.Clone(transitions[status])
+}
+
+// NewAddress copies an address of the address book.
+func NewAddress(a address.Address) Address {
+	return Address{
+		Name: a.Name, Line1: a.Line1, Line2: a.Line2, City: a.City, PostalCode: a.PostalCode, Country: a.Country,
+		Phone: a.Phone,
+	}
+}
@@ -0,0 +1,41 @@
+package order_test
+
+import (
+	"interview/internal/order"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		to   string
+		want bool
+	}{
+		{name: "Pending To Paid", from: order.StatusPending, to: order.StatusPaid, want: true},
+		{name: "Pending To Cancelled", from: order.StatusPending, to: order.StatusCancelled, want: true},
+		{name: "Pending To Shipped", from: order.StatusPending, to: order.StatusShipped},
+		{name: "Paid To Fulfilled", from: order.StatusPaid, to: order.StatusFulfilled, want: true},
+		{name: "Paid To Cancelled", from: order.StatusPaid, to: order.StatusCancelled},
+		{name: "Fulfilled To Shipped", from: order.StatusFulfilled, to: order.StatusShipped, want: true},
+		{name: "Shipped To Refunded", from: order.StatusShipped, to: order.StatusRefunded, want: true},
+		{name: "Shipped Back To Paid", from: order.StatusShipped, to: order.StatusPaid},
+		{name: "Refunded Is Final", from: order.StatusRefunded, to: order.StatusPaid},
+		{name: "Cancelled Is Final", from: order.StatusCancelled, to: order.StatusPending},
+		{name: "Unknown Status", from: "lost", to: order.StatusPaid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, order.CanTransition(tt.from, tt.to))
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	assert.Equal(t, []string{order.StatusFulfilled, order.StatusRefunded}, order.Next(order.StatusPaid))
+	assert.Empty(t, order.Next(order.StatusRefunded))
+	assert.True(t, order.ValidStatus(order.StatusCancelled))
+	assert.False(t, order.ValidStatus("lost"))
+}
@@ -0,0 +1,139 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// fakeStatus is the state of a payment at the fake provider
+type fakeStatus int
+
+const (
+	fakeApproved fakeStatus = iota
+	fakeDeclined
+	fakeCaptured
+	fakeRefunded
+)
+
+// Fake is a deterministic in-memory Provider for tests and local development, so checkout works without
+// the credentials of a real provider. It approves payments right away and sends customers straight back to
+// the ReturnURL; Decline withdraws the approval of a payment. Payment IDs count up from "fake_1".
+//
+// Webhook notifications are JSON objects like {"type": "captured", "payment_id": "fake_1"}, and only verify
+// when the fake agrees: approved payments for EventApproved, captured payments for EventCaptured.
+type Fake struct {
+	mu       sync.Mutex
+	payments map[string]fakeStatus
+	// refunded sums the parts of each payment paid back, refundKeys the keys of the parts paid back
+	refunded   map[string]float64
+	refundKeys map[string]bool
+}
+
+// NewFake creates a fake provider without payments.
+func NewFake() *Fake {
+	return &Fake{payments: map[string]fakeStatus{}, refunded: map[string]float64{}, refundKeys: map[string]bool{}}
+}
+
+// Name implements Provider.
+func (f *Fake) Name() string {
+	return "fake"
+}
+
+// Authorize implements Provider.
+func (f *Fake) Authorize(_ context.Context, order Order) (*Authorization, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := fmt.Sprintf("fake_%d", len(f.payments)+1)
+	f.payments[id] = fakeApproved
+	return &Authorization{PaymentID: id, ApproveURL: order.ReturnURL}, nil
+}
+
+// Decline makes the customer decline the payment, which can't be captured anymore.
+func (f *Fake) Decline(paymentID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.payments[paymentID]; ok {
+		f.payments[paymentID] = fakeDeclined
+	}
+}
+
+// Capture implements Provider.
+func (f *Fake) Capture(_ context.Context, paymentID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch status, ok := f.payments[paymentID]; {
+	case !ok:
+		return ErrPaymentNotFound
+	case status == fakeDeclined:
+		return ErrNotApproved
+	case status == fakeApproved:
+		f.payments[paymentID] = fakeCaptured
+	}
+	return nil
+}
+
+// Refund implements Provider.
+func (f *Fake) Refund(_ context.Context, paymentID, _ string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch status, ok := f.payments[paymentID]; {
+	case !ok:
+		return ErrPaymentNotFound
+	case status == fakeApproved || status == fakeDeclined:
+		return ErrNotCaptured
+	}
+	f.payments[paymentID] = fakeRefunded
+	return nil
+}
+
+// RefundPart implements Provider.
+func (f *Fake) RefundPart(_ context.Context, paymentID, key string, amount float64, _ string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch status, ok := f.payments[paymentID]; {
+	case !ok:
+		return ErrPaymentNotFound
+	case status != fakeCaptured:
+		return ErrNotCaptured
+	case f.refundKeys[key]:
+		return nil
+	}
+	f.refundKeys[key] = true
+	f.refunded[paymentID] += amount
+	return nil
+}
+
+// Refunded returns how much of a payment was paid back in parts.
+func (f *Fake) Refunded(paymentID string) float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.refunded[paymentID]
+}
+
+// VerifyWebhook implements Provider.
+func (f *Fake) VerifyWebhook(_ context.Context, _ http.Header, body []byte) (*Event, error) {
+	var notification struct {
+		Type      string `json:"type"`
+		PaymentID string `json:"payment_id"`
+	}
+	if err := json.Unmarshal(body, &notification); err != nil {
+		return nil, ErrInvalidWebhook
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	status, ok := f.payments[notification.PaymentID]
+	switch {
+	case !ok:
+		return nil, ErrInvalidWebhook
+	case notification.Type == EventApproved && status != fakeApproved,
+		notification.Type == EventCaptured && status != fakeCaptured:
+		return nil, ErrInvalidWebhook
+	case notification.Type != EventApproved && notification.Type != EventCaptured:
+		return &Event{}, nil
+	}
+	return &Event{Type: notification.Type, PaymentID: notification.PaymentID}, nil
+}
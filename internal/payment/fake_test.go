@@ -0,0 +1,88 @@
+package payment_test
+
+import (
+	"context"
+	"interview/internal/payment"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFake(t *testing.T) {
+	ctx := context.Background()
+	order := payment.Order{Reference: "1", Amount: 10, Currency: "EUR", ReturnURL: "https://shop.test/checkout/return"}
+
+	// notify verifies a webhook notification about the payment
+	notify := func(fake *payment.Fake, eventType, paymentID string) (*payment.Event, error) {
+		body := `{"type": "` + eventType + `", "payment_id": "` + paymentID + `"}`
+		return fake.VerifyWebhook(ctx, http.Header{}, []byte(body))
+	}
+
+	t.Run("Payments Are Approved Right Away", func(t *testing.T) {
+		fake := payment.NewFake()
+		auth, err := fake.Authorize(ctx, order)
+		require.NoError(t, err)
+		assert.Equal(t, "fake_1", auth.PaymentID)
+		assert.Equal(t, order.ReturnURL, auth.ApproveURL)
+		auth, err = fake.Authorize(ctx, order)
+		require.NoError(t, err)
+		assert.Equal(t, "fake_2", auth.PaymentID)
+
+		event, err := notify(fake, payment.EventApproved, "fake_1")
+		require.NoError(t, err)
+		assert.Equal(t, payment.Event{Type: payment.EventApproved, PaymentID: "fake_1"}, *event)
+		_, err = notify(fake, payment.EventCaptured, "fake_1")
+		assert.ErrorIs(t, err, payment.ErrInvalidWebhook)
+	})
+
+	t.Run("Capture And Refund", func(t *testing.T) {
+		fake := payment.NewFake()
+		auth, err := fake.Authorize(ctx, order)
+		require.NoError(t, err)
+		assert.ErrorIs(t, fake.Refund(ctx, auth.PaymentID, "refund-test"), payment.ErrNotCaptured)
+
+		require.NoError(t, fake.Capture(ctx, auth.PaymentID))
+		require.NoError(t, fake.Capture(ctx, auth.PaymentID))
+		event, err := notify(fake, payment.EventCaptured, auth.PaymentID)
+		require.NoError(t, err)
+		assert.Equal(t, payment.EventCaptured, event.Type)
+
+		require.NoError(t, fake.Refund(ctx, auth.PaymentID, "refund-test"))
+		require.NoError(t, fake.Refund(ctx, auth.PaymentID, "refund-test"))
+	})
+
+	t.Run("Refund In Parts", func(t *testing.T) {
+		fake := payment.NewFake()
+		auth, err := fake.Authorize(ctx, order)
+		require.NoError(t, err)
+		assert.ErrorIs(t, fake.RefundPart(ctx, auth.PaymentID, "refund-1", 3, "EUR"), payment.ErrNotCaptured)
+
+		require.NoError(t, fake.Capture(ctx, auth.PaymentID))
+		require.NoError(t, fake.RefundPart(ctx, auth.PaymentID, "refund-1", 3, "EUR"))
+		require.NoError(t, fake.RefundPart(ctx, auth.PaymentID, "refund-1", 3, "EUR"))
+		require.NoError(t, fake.RefundPart(ctx, auth.PaymentID, "refund-2", 2.5, "EUR"))
+		assert.Equal(t, 5.5, fake.Refunded(auth.PaymentID), "retries with the same key pay back once")
+	})
+
+	t.Run("Declined Payments Are Not Captured", func(t *testing.T) {
+		fake := payment.NewFake()
+		auth, err := fake.Authorize(ctx, order)
+		require.NoError(t, err)
+		fake.Decline(auth.PaymentID)
+		assert.ErrorIs(t, fake.Capture(ctx, auth.PaymentID), payment.ErrNotApproved)
+		_, err = notify(fake, payment.EventApproved, auth.PaymentID)
+		assert.ErrorIs(t, err, payment.ErrInvalidWebhook)
+	})
+
+	t.Run("Unknown Payments", func(t *testing.T) {
+		fake := payment.NewFake()
+		assert.ErrorIs(t, fake.Capture(ctx, "fake_1"), payment.ErrPaymentNotFound)
+		assert.ErrorIs(t, fake.Refund(ctx, "fake_1", "refund-test"), payment.ErrPaymentNotFound)
+		_, err := notify(fake, payment.EventApproved, "fake_1")
+		assert.ErrorIs(t, err, payment.ErrInvalidWebhook)
+		_, err = fake.VerifyWebhook(ctx, http.Header{}, []byte("not json"))
+		assert.ErrorIs(t, err, payment.ErrInvalidWebhook)
+	})
+}
@@ -0,0 +1,118 @@
+// Package payment takes the payments of checked out carts through external payment providers.
+package payment
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// StatusPending payments were started but not captured yet
+	StatusPending = "pending"
+	// StatusCaptured payments were collected and their cart checked out
+	StatusCaptured = "captured"
+	// StatusHeld payments were approved but are held for review before they are captured
+	StatusHeld = "held"
+	// StatusBlocked payments were declined by the risk checks and are never captured
+	StatusBlocked = "blocked"
+	// StatusRefunding payments are being paid back at their provider, which may or may not have done so yet
+	StatusRefunding = "refunding"
+	// StatusRefunded payments were captured and paid back to the customer
+	StatusRefunded = "refunded"
+
+	// EventApproved is sent when the customer approved a payment, which can be captured now
+	EventApproved = "approved"
+	// EventCaptured is sent when a payment was captured
+	EventCaptured = "captured"
+)
+
+var (
+	// ErrPaymentNotFound is returned for payments that weren't started by the shop
+	ErrPaymentNotFound = errors.New("payment not found")
+	// ErrNotApproved is returned when capturing a payment the customer didn't approve
+	ErrNotApproved = errors.New("payment was not approved")
+	// ErrNotCaptured is returned when refunding a payment that wasn't captured
+	ErrNotCaptured = errors.New("payment was not captured")
+	// ErrAlreadyCaptured is returned when declining a payment that was captured already
+	ErrAlreadyCaptured = errors.New("payment was captured already")
+	// ErrInvalidWebhook is returned for webhook notifications that weren't sent by the provider
+	ErrInvalidWebhook = errors.New("invalid webhook notification")
+	// ErrCartChanged is returned when the cart changed after its payment was started
+	ErrCartChanged = errors.New("cart changed during payment")
+)
+
+type (
+	// Provider takes payments through an external payment service. Customers approve payments on the
+	// site of the provider, which sends them back to the ReturnURL of the order.
+	Provider interface {
+		// Name identifies the provider in stored payments, e.g. "paypal"
+		Name() string
+		// Authorize starts the payment of an order and returns where the customer approves it
+		Authorize(ctx context.Context, order Order) (*Authorization, error)
+		// Capture collects an approved payment. Capturing a payment twice isn't an error.
+		Capture(ctx context.Context, paymentID string) error
+		// Refund pays a captured payment back in full. Refunding a payment twice isn't an error, and
+		// retries sending the same idempotency key pay it back only once.
+		Refund(ctx context.Context, paymentID, key string) error
+		// RefundPart pays amount of a captured payment back, e.g. for some of the items of an order.
+		// Retries sending the same idempotency key pay it back only once.
+		RefundPart(ctx context.Context, paymentID, key string, amount float64, currency string) error
+		// VerifyWebhook checks that a webhook notification was sent by the provider and returns its
+		// event, whose Type is empty for events the shop doesn't handle
+		VerifyWebhook(ctx context.Context, header http.Header, body []byte) (*Event, error)
+	}
+
+	// Order is what a payment is started for
+	Order struct {
+		// Reference identifies the cart paid for at the provider
+		Reference string
+		Amount    float64
+		Currency  string
+		// ReturnURL is where the customer is sent after approving the payment, CancelURL after
+		// cancelling it
+		ReturnURL string
+		CancelURL string
+	}
+
+	// Authorization is a payment started at the provider
+	Authorization struct {
+		// PaymentID identifies the payment at the provider
+		PaymentID string
+		// ApproveURL is the page of the provider the customer approves the payment on
+		ApproveURL string
+	}
+
+	// Event is a webhook notification about a payment
+	Event struct {
+		// Type is EventApproved or EventCaptured, empty for other events
+		Type      string
+		PaymentID string
+	}
+
+	// Payment records a payment started for a cart, which is checked out once it is captured
+	Payment struct {
+		gorm.Model
+		// TenantID is the shop the paid cart was filled in
+		TenantID string `gorm:"size:32;index;not null;default:default"`
+		CartID   uint   `gorm:"index;not null"`
+		// CartVersion is the version of the cart when the payment was started; the payment is only
+		// captured while the cart is unchanged
+		CartVersion int `gorm:"not null"`
+		// Provider and ExternalID identify the payment at its provider
+		Provider   string `gorm:"size:32;not null;uniqueIndex:idx_payment_external"`
+		ExternalID string `gorm:"size:255;not null;uniqueIndex:idx_payment_external"`
+		Amount     float64
+		Currency   string `gorm:"size:3"`
+		// Refunded is how much of Amount was paid back in parts, see Provider.RefundPart
+		Refunded float64 `gorm:"not null;default:0"`
+		// Status is StatusPending, StatusHeld, StatusBlocked, StatusCaptured, StatusRefunding or StatusRefunded
+		Status     string `gorm:"size:16;index;not null"`
+		CapturedAt *time.Time
+		// RiskReason is why the risk checks held or blocked the payment
+		RiskReason string `gorm:"size:255"`
+	}
+)
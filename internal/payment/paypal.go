@@ -0,0 +1,341 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// PayPalSandboxURL and PayPalLiveURL are the REST APIs of the PayPal environments
+	PayPalSandboxURL = "https://api-m.sandbox.paypal.com"
+	PayPalLiveURL    = "https://api-m.paypal.com"
+
+	// tokenMargin renews access tokens before they expire, so requests never carry an expired one
+	tokenMargin = time.Minute
+)
+
+// PayPal takes payments with PayPal Checkout: Authorize creates an order the customer approves on
+// PayPal, Capture captures it once they return.
+type PayPal struct {
+	baseURL      string
+	clientID     string
+	clientSecret string
+	webhookID    string
+	client       *http.Client
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+// NewPayPal creates a PayPal provider for the REST API at baseURL, verifying webhook notifications sent
+// to the webhook with webhookID.
+func NewPayPal(baseURL, clientID, clientSecret, webhookID string) *PayPal {
+	return &PayPal{
+		baseURL:      strings.TrimSuffix(baseURL, "/"),
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		webhookID:    webhookID,
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Name implements Provider.
+func (p *PayPal) Name() string {
+	return "paypal"
+}
+
+// Authorize implements Provider by creating an order to capture.
+func (p *PayPal) Authorize(ctx context.Context, order Order) (*Authorization, error) {
+	body := map[string]interface{}{
+		"intent": "CAPTURE",
+		"purchase_units": []map[string]interface{}{{
+			"reference_id": order.Reference,
+			"amount": map[string]string{
+				"currency_code": order.Currency,
+				"value":         fmt.Sprintf("%.2f", order.Amount),
+			},
+		}},
+		"application_context": map[string]string{
+			"return_url":  order.ReturnURL,
+			"cancel_url":  order.CancelURL,
+			"user_action": "PAY_NOW",
+		},
+	}
+	var created struct {
+		ID    string `json:"id"`
+		Links []struct {
+			Href string `json:"href"`
+			Rel  string `json:"rel"`
+		} `json:"links"`
+	}
+	if err := p.do(ctx, http.MethodPost, "/v2/checkout/orders", "", body, &created); err != nil {
+		return nil, fmt.Errorf("failed to create PayPal order: %w", err)
+	}
+	for _, link := range created.Links {
+		if link.Rel == "approve" || link.Rel == "payer-action" {
+			return &Authorization{PaymentID: created.ID, ApproveURL: link.Href}, nil
+		}
+	}
+	return nil, fmt.Errorf("PayPal order %s has no approve link", created.ID)
+}
+
+// Capture implements Provider.
+func (p *PayPal) Capture(ctx context.Context, paymentID string) error {
+	var captured struct {
+		Status string `json:"status"`
+	}
+	// The request ID makes PayPal answer repeated captures with the result of the first one
+	path := "/v2/checkout/orders/" + url.PathEscape(paymentID) + "/capture"
+	err := p.do(ctx, http.MethodPost, path, "capture-"+paymentID, struct{}{}, &captured)
+	var apiErr *payPalError
+	switch {
+	case errors.As(err, &apiErr) && apiErr.has("ORDER_ALREADY_CAPTURED"):
+		return nil
+	case errors.As(err, &apiErr) && apiErr.has("ORDER_NOT_APPROVED"):
+		return ErrNotApproved
+	case err != nil:
+		return fmt.Errorf("failed to capture PayPal order: %w", err)
+	case captured.Status != "COMPLETED":
+		return fmt.Errorf("PayPal order %s is %s after capture", paymentID, captured.Status)
+	}
+	return nil
+}
+
+// Refund implements Provider by refunding the capture of the order, sending the key as PayPal-Request-Id.
+func (p *PayPal) Refund(ctx context.Context, paymentID, key string) error {
+	return p.refundCapture(ctx, paymentID, key, struct{}{})
+}
+
+// RefundPart implements Provider by refunding amount of the capture of the order, sending the key as
+// PayPal-Request-Id.
+func (p *PayPal) RefundPart(ctx context.Context, paymentID, key string, amount float64, currency string) error {
+	request := map[string]interface{}{
+		"amount": map[string]string{"currency_code": currency, "value": fmt.Sprintf("%.2f", amount)},
+	}
+	return p.refundCapture(ctx, paymentID, key, request)
+}
+
+// refundCapture refunds the capture of the order with the refund request, which refunds all of it when
+// it has no amount
+func (p *PayPal) refundCapture(ctx context.Context, paymentID, key string, request interface{}) error {
+	var order struct {
+		PurchaseUnits []struct {
+			Payments struct {
+				Captures []struct {
+					ID string `json:"id"`
+				} `json:"captures"`
+			} `json:"payments"`
+		} `json:"purchase_units"`
+	}
+	if err := p.do(ctx, http.MethodGet, "/v2/checkout/orders/"+url.PathEscape(paymentID), "", nil, &order); err != nil {
+		return fmt.Errorf("failed to get PayPal order: %w", err)
+	}
+	if len(order.PurchaseUnits) == 0 || len(order.PurchaseUnits[0].Payments.Captures) == 0 {
+		return ErrNotCaptured
+	}
+
+	captureID := order.PurchaseUnits[0].Payments.Captures[0].ID
+	path := "/v2/payments/captures/" + url.PathEscape(captureID) + "/refund"
+	var refunded struct {
+		Status string `json:"status"`
+	}
+	err := p.do(ctx, http.MethodPost, path, key, request, &refunded)
+	var apiErr *payPalError
+	switch {
+	case errors.As(err, &apiErr) && apiErr.has("CAPTURE_FULLY_REFUNDED"):
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to refund PayPal capture: %w", err)
+	case refunded.Status != "COMPLETED" && refunded.Status != "PENDING":
+		return fmt.Errorf("PayPal refund of order %s is %s", paymentID, refunded.Status)
+	}
+	return nil
+}
+
+// VerifyWebhook implements Provider by asking PayPal to verify the signature of the notification.
+func (p *PayPal) VerifyWebhook(ctx context.Context, header http.Header, body []byte) (*Event, error) {
+	headers := map[string]string{
+		"auth_algo":         header.Get("PAYPAL-AUTH-ALGO"),
+		"cert_url":          header.Get("PAYPAL-CERT-URL"),
+		"transmission_id":   header.Get("PAYPAL-TRANSMISSION-ID"),
+		"transmission_sig":  header.Get("PAYPAL-TRANSMISSION-SIG"),
+		"transmission_time": header.Get("PAYPAL-TRANSMISSION-TIME"),
+	}
+	for _, value := range headers {
+		if value == "" {
+			return nil, ErrInvalidWebhook
+		}
+	}
+	if !json.Valid(body) {
+		return nil, ErrInvalidWebhook
+	}
+
+	request := map[string]interface{}{"webhook_id": p.webhookID, "webhook_event": json.RawMessage(body)}
+	for key, value := range headers {
+		request[key] = value
+	}
+	var verification struct {
+		Status string `json:"verification_status"`
+	}
+	if err := p.do(ctx, http.MethodPost, "/v1/notifications/verify-webhook-signature", "", request, &verification); err != nil {
+		return nil, fmt.Errorf("failed to verify PayPal webhook: %w", err)
+	}
+	if verification.Status != "SUCCESS" {
+		return nil, ErrInvalidWebhook
+	}
+
+	var notification struct {
+		EventType string `json:"event_type"`
+		Resource  struct {
+			ID                string `json:"id"`
+			SupplementaryData struct {
+				RelatedIDs struct {
+					OrderID string `json:"order_id"`
+				} `json:"related_ids"`
+			} `json:"supplementary_data"`
+		} `json:"resource"`
+	}
+	if err := json.Unmarshal(body, &notification); err != nil {
+		return nil, ErrInvalidWebhook
+	}
+	switch notification.EventType {
+	case "CHECKOUT.ORDER.APPROVED":
+		return &Event{Type: EventApproved, PaymentID: notification.Resource.ID}, nil
+	case "PAYMENT.CAPTURE.COMPLETED":
+		// Captures belong to the order the payment was started with
+		return &Event{Type: EventCaptured, PaymentID: notification.Resource.SupplementaryData.RelatedIDs.OrderID}, nil
+	default:
+		return &Event{}, nil
+	}
+}
+
+// payPalError is an error response of the PayPal API
+type payPalError struct {
+	status  int
+	Name    string `json:"name"`
+	Details []struct {
+		Issue string `json:"issue"`
+	} `json:"details"`
+}
+
+func (e *payPalError) Error() string {
+	return fmt.Sprintf("PayPal returned status %d: %s", e.status, e.Name)
+}
+
+// has reports whether the error lists the issue
+func (e *payPalError) has(issue string) bool {
+	for _, d := range e.Details {
+		if d.Issue == issue {
+			return true
+		}
+	}
+	return false
+}
+
+// do sends a JSON request to the API, without a body when in is nil, and decodes the JSON response into
+// out, retrying once with a new access token when the token was rejected
+func (p *PayPal) do(ctx context.Context, method, path, requestID string, in, out interface{}) error {
+	var payload []byte
+	if in != nil {
+		var err error
+		if payload, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		token, err := p.accessToken(ctx)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, method, p.baseURL+path, bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		if requestID != "" {
+			req.Header.Set("PayPal-Request-Id", requestID)
+		}
+
+		resp, err := p.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("PayPal request failed: %w", err)
+		}
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			resp.Body.Close()
+			p.expireToken(token)
+			continue
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode >= 300 {
+			apiErr := &payPalError{status: resp.StatusCode}
+			_ = json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(apiErr)
+			return apiErr
+		}
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode PayPal response: %w", err)
+		}
+		return nil
+	}
+}
+
+// accessToken returns a valid OAuth access token, requesting a new one when needed
+func (p *PayPal) accessToken(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.token != "" && time.Now().Before(p.tokenExpiry) {
+		return p.token, nil
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/v1/oauth2/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(p.clientID, p.clientSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("PayPal token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("PayPal token request returned status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode PayPal token: %w", err)
+	}
+	if body.AccessToken == "" {
+		return "", errors.New("PayPal returned no access token")
+	}
+	p.token = body.AccessToken
+	p.tokenExpiry = time.Now().Add(time.Duration(body.ExpiresIn)*time.Second - tokenMargin)
+	return p.token, nil
+}
+
+// expireToken forgets the access token if it is still the rejected one
+func (p *PayPal) expireToken(token string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.token == token {
+		p.token = ""
+	}
+}
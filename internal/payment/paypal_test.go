@@ -0,0 +1,205 @@
+package payment_test
+
+import (
+	"context"
+	"encoding/json"
+	"interview/internal/payment"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePayPal serves the parts of the PayPal REST API the provider uses
+type fakePayPal struct {
+	tokens        atomic.Int32
+	rejectToken   atomic.Bool
+	captureIssue  string
+	refundIssue   string
+	verification  string
+	lastOrder     map[string]interface{}
+	lastRefund    map[string]interface{}
+	lastRequestID string
+}
+
+func (f *fakePayPal) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/v1/oauth2/token" {
+		if id, secret, _ := r.BasicAuth(); id != "client" || secret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		f.tokens.Add(1)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "token", "expires_in": 3600})
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer token" || f.rejectToken.CompareAndSwap(true, false) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/v2/checkout/orders":
+		_ = json.NewDecoder(r.Body).Decode(&f.lastOrder)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id": "ORDER-1", "links": [
+			{"rel": "self", "href": "https://paypal.test/v2/checkout/orders/ORDER-1"},
+			{"rel": "approve", "href": "https://paypal.test/checkoutnow?token=ORDER-1"}]}`))
+	case "/v2/checkout/orders/ORDER-1/capture":
+		f.lastRequestID = r.Header.Get("PayPal-Request-Id")
+		if f.captureIssue != "" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			_, _ = w.Write([]byte(`{"name": "UNPROCESSABLE_ENTITY", "details": [{"issue": "` + f.captureIssue + `"}]}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id": "ORDER-1", "status": "COMPLETED"}`))
+	case "/v2/checkout/orders/ORDER-1":
+		_, _ = w.Write([]byte(`{"id": "ORDER-1", "purchase_units": [{"payments": {"captures": [{"id": "CAPTURE-1"}]}}]}`))
+	case "/v2/checkout/orders/ORDER-2":
+		_, _ = w.Write([]byte(`{"id": "ORDER-2", "purchase_units": [{}]}`))
+	case "/v2/payments/captures/CAPTURE-1/refund":
+		f.lastRequestID = r.Header.Get("PayPal-Request-Id")
+		f.lastRefund = nil
+		_ = json.NewDecoder(r.Body).Decode(&f.lastRefund)
+		if f.refundIssue != "" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			_, _ = w.Write([]byte(`{"name": "UNPROCESSABLE_ENTITY", "details": [{"issue": "` + f.refundIssue + `"}]}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id": "REFUND-1", "status": "COMPLETED"}`))
+	case "/v1/notifications/verify-webhook-signature":
+		_ = json.NewEncoder(w).Encode(map[string]string{"verification_status": f.verification})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestPayPal(t *testing.T) {
+	fake := &fakePayPal{verification: "SUCCESS"}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	paypal := payment.NewPayPal(server.URL+"/", "client", "secret", "WH-1")
+	ctx := context.Background()
+
+	t.Run("Authorize Creates An Order", func(t *testing.T) {
+		auth, err := paypal.Authorize(ctx, payment.Order{
+			Reference: "42", Amount: 19.5, Currency: "EUR",
+			ReturnURL: "https://shop.test/checkout/return", CancelURL: "https://shop.test/checkout/cancel",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "ORDER-1", auth.PaymentID)
+		assert.Equal(t, "https://paypal.test/checkoutnow?token=ORDER-1", auth.ApproveURL)
+
+		assert.Equal(t, "CAPTURE", fake.lastOrder["intent"])
+		unit := fake.lastOrder["purchase_units"].([]interface{})[0].(map[string]interface{})
+		assert.Equal(t, "42", unit["reference_id"])
+		assert.Equal(t, map[string]interface{}{"currency_code": "EUR", "value": "19.50"}, unit["amount"])
+	})
+
+	t.Run("Access Token Is Reused", func(t *testing.T) {
+		tokens := fake.tokens.Load()
+		require.NoError(t, paypal.Capture(ctx, "ORDER-1"))
+		assert.Equal(t, tokens, fake.tokens.Load())
+	})
+
+	t.Run("Rejected Access Token Is Renewed", func(t *testing.T) {
+		tokens := fake.tokens.Load()
+		fake.rejectToken.Store(true)
+		require.NoError(t, paypal.Capture(ctx, "ORDER-1"))
+		assert.Equal(t, tokens+1, fake.tokens.Load())
+	})
+
+	t.Run("Capture Is Idempotent", func(t *testing.T) {
+		fake.captureIssue = "ORDER_ALREADY_CAPTURED"
+		defer func() { fake.captureIssue = "" }()
+		require.NoError(t, paypal.Capture(ctx, "ORDER-1"))
+		assert.Equal(t, "capture-ORDER-1", fake.lastRequestID)
+	})
+
+	t.Run("Unapproved Orders Are Not Captured", func(t *testing.T) {
+		fake.captureIssue = "ORDER_NOT_APPROVED"
+		defer func() { fake.captureIssue = "" }()
+		assert.ErrorIs(t, paypal.Capture(ctx, "ORDER-1"), payment.ErrNotApproved)
+	})
+
+	t.Run("Unknown Orders Fail", func(t *testing.T) {
+		assert.Error(t, paypal.Capture(ctx, "ORDER-3"))
+		assert.Error(t, paypal.Refund(ctx, "ORDER-3", "refund-test"))
+	})
+
+	t.Run("Refund Refunds The Capture", func(t *testing.T) {
+		require.NoError(t, paypal.Refund(ctx, "ORDER-1", "refund-ORDER-1"))
+		assert.Equal(t, "refund-ORDER-1", fake.lastRequestID)
+		assert.Empty(t, fake.lastRefund, "refunds without an amount refund all of the capture")
+
+		fake.refundIssue = "CAPTURE_FULLY_REFUNDED"
+		defer func() { fake.refundIssue = "" }()
+		require.NoError(t, paypal.Refund(ctx, "ORDER-1", "refund-ORDER-1"))
+	})
+
+	t.Run("Refund Part Of The Capture", func(t *testing.T) {
+		require.NoError(t, paypal.RefundPart(ctx, "ORDER-1", "refund-1-1", 4.5, "EUR"))
+		assert.Equal(t, "refund-1-1", fake.lastRequestID)
+		assert.Equal(t, map[string]interface{}{"currency_code": "EUR", "value": "4.50"}, fake.lastRefund["amount"])
+	})
+
+	t.Run("Uncaptured Orders Are Not Refunded", func(t *testing.T) {
+		assert.ErrorIs(t, paypal.Refund(ctx, "ORDER-2", "refund-test"), payment.ErrNotCaptured)
+		assert.ErrorIs(t, paypal.RefundPart(ctx, "ORDER-2", "refund-test", 1, "EUR"), payment.ErrNotCaptured)
+	})
+
+	// notify verifies a webhook notification with the signature headers PayPal sends
+	notify := func(body string) (*payment.Event, error) {
+		header := http.Header{}
+		for _, name := range []string{"PAYPAL-AUTH-ALGO", "PAYPAL-CERT-URL", "PAYPAL-TRANSMISSION-ID", "PAYPAL-TRANSMISSION-SIG", "PAYPAL-TRANSMISSION-TIME"} {
+			header.Set(name, "value")
+		}
+		return paypal.VerifyWebhook(ctx, header, []byte(body))
+	}
+
+	t.Run("Webhook Events", func(t *testing.T) {
+		tests := []struct {
+			name string
+			body string
+			want payment.Event
+		}{
+			{
+				name: "Order Approved",
+				body: `{"event_type": "CHECKOUT.ORDER.APPROVED", "resource": {"id": "ORDER-1"}}`,
+				want: payment.Event{Type: payment.EventApproved, PaymentID: "ORDER-1"},
+			},
+			{
+				name: "Capture Completed",
+				body: `{"event_type": "PAYMENT.CAPTURE.COMPLETED", "resource": {"id": "CAPTURE-1",
+					"supplementary_data": {"related_ids": {"order_id": "ORDER-1"}}}}`,
+				want: payment.Event{Type: payment.EventCaptured, PaymentID: "ORDER-1"},
+			},
+			{
+				name: "Other Event",
+				body: `{"event_type": "PAYMENT.CAPTURE.REFUNDED", "resource": {"id": "REFUND-1"}}`,
+				want: payment.Event{},
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				event, err := notify(tt.body)
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, *event)
+			})
+		}
+	})
+
+	t.Run("Unverified Webhooks Are Rejected", func(t *testing.T) {
+		_, err := paypal.VerifyWebhook(ctx, http.Header{}, []byte(`{"event_type": "CHECKOUT.ORDER.APPROVED"}`))
+		assert.ErrorIs(t, err, payment.ErrInvalidWebhook)
+
+		fake.verification = "FAILURE"
+		defer func() { fake.verification = "SUCCESS" }()
+		_, err = notify(`{"event_type": "CHECKOUT.ORDER.APPROVED", "resource": {"id": "ORDER-1"}}`)
+		assert.ErrorIs(t, err, payment.ErrInvalidWebhook)
+	})
+}
@@ -0,0 +1,141 @@
+// Package pricedrop tells customers when staff lower the price of a product in their open carts.
+package pricedrop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"interview/internal/cart"
+	"interview/internal/events"
+	"interview/internal/mail"
+	"interview/internal/pricing"
+	"interview/internal/product"
+	"interview/internal/repo"
+	"interview/internal/tenant"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var emailTemplate = template.Must(template.New("pricedrop").Parse(`Hello,
+
+good news: {{ .Product }} in your cart is cheaper now, {{ printf "%.2f" .Price }} instead of {{ printf "%.2f" .Before }}.
+{{ if .Link }}
+Check out while it lasts: {{ .Link }}
+{{ end }}`))
+
+// Alerter publishes an events.TypePriceDropped event for each open cart holding a product whose price
+// was lowered, which notifies its owner in the app, and emails the owners that have an address.
+// Optionally it reprices the items of the product, which are otherwise repriced when customers come
+// back to their cart.
+type Alerter struct {
+	repo    *repo.Repository
+	mailer  mail.Mailer
+	bus     *events.Bus
+	baseURL string
+	reprice bool
+}
+
+// NewAlerter creates an Alerter publishing to bus, if not nil, and emailing links to the cart under
+// baseURL, if set. With reprice set the items of the product get its new price.
+func NewAlerter(r *repo.Repository, mailer mail.Mailer, bus *events.Bus, baseURL string, reprice bool) *Alerter {
+	return &Alerter{
+		repo:    r,
+		mailer:  mailer,
+		bus:     bus,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		reprice: reprice,
+	}
+}
+
+// Alert tells the owners of the open carts holding the product that it costs less than the price they
+// had it at, and returns how many carts were alerted about. The price they pay now is the one their cart
+// charges, see repo.Repository.CatalogPrice, so customers whose price list keeps their price up or whose
+// price comes from the price provider aren't alerted. Each address is emailed once however many carts it
+// owns.
+func (a *Alerter) Alert(ctx context.Context, p product.Product, at time.Time) (int, error) {
+	// Only the carts of the shop selling the product hold it, at the prices of that shop
+	r := a.repo.WithContext(tenant.NewContext(ctx, p.TenantID))
+	items, err := r.ListPriceDropItems(p.Name)
+	if err != nil {
+		return 0, err
+	}
+
+	var alerted int
+	var errs []error
+	emailed := map[string]bool{}
+	for _, item := range items {
+		if err := ctx.Err(); err != nil {
+			return alerted, err
+		}
+
+		price, ok, err := r.CatalogPrice(item.UserID, p.Name, at)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cart %d: %w", item.CartID, err))
+			continue
+		}
+		if !ok {
+			// The product was removed from the catalog meanwhile
+			return alerted, errors.Join(errs...)
+		}
+		if !pricing.Dropped(item.Price, price) {
+			continue
+		}
+
+		total := item.Total
+		if a.reprice {
+			_, err := r.RefreshCartPrices(item.CartID, map[string]float64{p.Name: price}, at)
+			if errors.Is(err, cart.ErrCartNotFound) || errors.Is(err, cart.ErrCartClosed) || errors.Is(err, cart.ErrCartLocked) {
+				continue
+			} else if err != nil {
+				errs = append(errs, fmt.Errorf("cart %d: %w", item.CartID, err))
+				continue
+			}
+			if repriced, err := r.GetCart(item.CartID); err == nil {
+				total = repriced.Total
+			}
+		}
+
+		alerted++
+		if a.bus != nil {
+			a.bus.Publish(events.Event{
+				Type:     events.TypePriceDropped,
+				CartID:   item.CartID,
+				Product:  p.Name,
+				Quantity: item.Quantity,
+				Price:    price,
+				Total:    total,
+			})
+		}
+		if item.Email == "" || emailed[item.Email] {
+			continue
+		}
+		emailed[item.Email] = true
+		if err := a.email(ctx, item, p.Name, price); err != nil {
+			errs = append(errs, fmt.Errorf("cart %d: %w", item.CartID, err))
+		}
+	}
+	return alerted, errors.Join(errs...)
+}
+
+func (a *Alerter) email(ctx context.Context, item repo.PriceDropItem, name string, price float64) error {
+	var body strings.Builder
+	link := ""
+	if a.baseURL != "" {
+		link = a.baseURL + "/"
+	}
+	err := emailTemplate.Execute(&body, map[string]interface{}{
+		"Product": name,
+		"Price":   price,
+		"Before":  item.Price,
+		"Link":    link,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render price drop alert: %w", err)
+	}
+	return a.mailer.Send(ctx, mail.Message{
+		To:      item.Email,
+		Subject: "The price of " + name + " in your cart dropped",
+		Body:    body.String(),
+	})
+}
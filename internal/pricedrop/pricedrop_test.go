@@ -0,0 +1,151 @@
+package pricedrop_test
+
+import (
+	"context"
+	"interview/internal/cart"
+	"interview/internal/events"
+	"interview/internal/mail"
+	"interview/internal/pricedrop"
+	"interview/internal/pricelist"
+	"interview/internal/pricing"
+	"interview/internal/product"
+	"interview/internal/repo"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type fakeMailer struct {
+	sent []mail.Message
+}
+
+func (m *fakeMailer) Send(_ context.Context, msg mail.Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func TestAlerter(t *testing.T) {
+	// setup adds the shoe at 10 to the carts of Jane, of an anonymous customer and of a wholesale
+	// customer whose price list sells it at 7, then lowers its price to 8
+	setup := func(t *testing.T) (*repo.Repository, *product.Product, map[string]*cart.Cart, uint) {
+		t.Helper()
+		db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+		require.NoError(t, err)
+		require.NoError(t, repo.Migrate(db))
+		cartRepo := repo.NewRepository(db)
+
+		shoe, err := cartRepo.UpsertProduct("shoe", 10)
+		require.NoError(t, err)
+		list := pricelist.PriceList{Name: "Wholesale", CustomerGroup: pricelist.GroupWholesale}
+		require.NoError(t, cartRepo.CreatePriceList(&list))
+		require.NoError(t, cartRepo.SetListPrice(list.ID, "shoe", 7))
+
+		carts := map[string]*cart.Cart{}
+		for _, name := range []string{"jane", "anonymous", "wholesale", "closed"} {
+			c, err := cartRepo.GetOrCreateCart(name, cart.DefaultName)
+			require.NoError(t, err)
+			require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 2, 10))
+			carts[name] = c
+		}
+		require.NoError(t, cartRepo.CloseCart(carts["closed"].ID))
+		jane, err := cartRepo.FindOrCreateUser("github", "1", "jane@example.com", "Jane")
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AssignCartToUser("jane", jane.ID))
+		wholesale, err := cartRepo.FindOrCreateUser("github", "2", "buyer@example.com", "Buyer")
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AssignCartToUser("wholesale", wholesale.ID))
+		require.NoError(t, cartRepo.SetUserGroup(wholesale.ID, pricelist.GroupWholesale))
+
+		shoe, previous, err := cartRepo.SetProductPrice(shoe.ID, 8)
+		require.NoError(t, err)
+		require.Equal(t, 10.0, previous)
+		return cartRepo, shoe, carts, list.ID
+	}
+
+	t.Run("Alerts The Owners Of Open Carts", func(t *testing.T) {
+		cartRepo, shoe, carts, _ := setup(t)
+		bus := events.NewBus()
+		received, unsubscribe := bus.Subscribe()
+		defer unsubscribe()
+		mailer := &fakeMailer{}
+
+		alerted, err := pricedrop.NewAlerter(cartRepo, mailer, bus, "https://shop.example.com/", false).Alert(context.Background(), *shoe, time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, 3, alerted)
+
+		var cartIDs []uint
+		for i := 0; i < 3; i++ {
+			e := <-received
+			assert.Equal(t, events.TypePriceDropped, e.Type)
+			assert.Equal(t, "shoe", e.Product)
+			cartIDs = append(cartIDs, e.CartID)
+			if e.CartID == carts["wholesale"].ID {
+				assert.Equal(t, 7.0, e.Price, "the price list price")
+			} else {
+				assert.Equal(t, 8.0, e.Price)
+			}
+		}
+		assert.ElementsMatch(t, []uint{carts["jane"].ID, carts["anonymous"].ID, carts["wholesale"].ID}, cartIDs)
+
+		require.Len(t, mailer.sent, 2)
+		assert.Equal(t, "jane@example.com", mailer.sent[0].To)
+		assert.Equal(t, "The price of shoe in your cart dropped", mailer.sent[0].Subject)
+		assert.Contains(t, mailer.sent[0].Body, "8.00 instead of 10.00")
+		assert.Contains(t, mailer.sent[0].Body, "https://shop.example.com/")
+
+		janeCart, err := cartRepo.GetCart(carts["jane"].ID)
+		require.NoError(t, err)
+		assert.Equal(t, 10.0, janeCart.CartItems[0].Price, "items keep their price until the cart is repriced")
+	})
+
+	t.Run("Reprices The Items", func(t *testing.T) {
+		cartRepo, shoe, carts, _ := setup(t)
+		mailer := &fakeMailer{}
+
+		alerted, err := pricedrop.NewAlerter(cartRepo, mailer, nil, "", true).Alert(context.Background(), *shoe, time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, 3, alerted)
+		assert.NotContains(t, mailer.sent[0].Body, "Check out while it lasts")
+
+		for name, price := range map[string]float64{"jane": 8, "wholesale": 7, "closed": 10} {
+			c, err := cartRepo.GetCart(carts[name].ID)
+			require.NoError(t, err)
+			assert.Equal(t, price, c.CartItems[0].Price, name)
+		}
+
+		alerted, err = pricedrop.NewAlerter(cartRepo, mailer, nil, "", true).Alert(context.Background(), *shoe, time.Now())
+		require.NoError(t, err)
+		assert.Zero(t, alerted, "repriced carts aren't alerted again")
+	})
+
+	t.Run("Price Lists Keeping The Price Up", func(t *testing.T) {
+		cartRepo, shoe, _, listID := setup(t)
+		require.NoError(t, cartRepo.SetListPrice(listID, "shoe", 10))
+		mailer := &fakeMailer{}
+
+		alerted, err := pricedrop.NewAlerter(cartRepo, mailer, nil, "", false).Alert(context.Background(), *shoe, time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, 2, alerted)
+		require.Len(t, mailer.sent, 1)
+		assert.Equal(t, "jane@example.com", mailer.sent[0].To)
+	})
+
+	t.Run("Price Provider Keeping The Price Up", func(t *testing.T) {
+		cartRepo, shoe, carts, _ := setup(t)
+		cartRepo.SetPriceProvider(pricing.StaticProvider{"shoe": 10})
+		mailer := &fakeMailer{}
+
+		alerted, err := pricedrop.NewAlerter(cartRepo, mailer, nil, "", true).Alert(context.Background(), *shoe, time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, 1, alerted, "only the price list is lower than the price of the provider")
+		require.Len(t, mailer.sent, 1)
+		assert.Equal(t, "buyer@example.com", mailer.sent[0].To)
+		janeCart, err := cartRepo.GetCart(carts["jane"].ID)
+		require.NoError(t, err)
+		assert.Equal(t, 10.0, janeCart.CartItems[0].Price)
+	})
+}
@@ -0,0 +1,92 @@
+// Package pricelist defines the price lists selling products to customer groups at their own prices.
+package pricelist
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// GroupRetail is the group of anonymous customers and, unless staff assign them another one, of
+	// every user
+	GroupRetail = "retail"
+	// GroupWholesale is the group of resellers buying in bulk
+	GroupWholesale = "wholesale"
+	// GroupVIP is the group of favoured customers
+	GroupVIP = "vip"
+)
+
+var (
+	// Groups are the customer groups price lists can be made for
+	Groups = []string{GroupRetail, GroupWholesale, GroupVIP}
+
+	// ErrPriceListNotFound is returned for unknown price lists
+	ErrPriceListNotFound = errors.New("price list not found")
+	// ErrInvalidGroup is returned for groups other than the Groups
+	ErrInvalidGroup = errors.New("invalid customer group")
+	// ErrGroupTaken is returned when creating a second price list for a customer group
+	ErrGroupTaken = errors.New("customer group already has a price list")
+	// ErrOverrideNotFound is returned for unknown price overrides
+	ErrOverrideNotFound = errors.New("price override not found")
+	// ErrInvalidPeriod is returned for price overrides that don't end after they start
+	ErrInvalidPeriod = errors.New("price override must end after it starts")
+	// ErrOverrideOverlaps is returned for price overrides overlapping another one of the product
+	ErrOverrideOverlaps = errors.New("price override overlaps another one of the product")
+)
+
+type (
+	// PriceList sells the products on it to the customers of its group at its prices. Products not on
+	// the list are sold at their base price. Each group has at most one price list in each shop.
+	PriceList struct {
+		gorm.Model
+		// TenantID is the shop whose customers buy at the list's prices
+		TenantID string `gorm:"size:32;uniqueIndex:idx_price_list_group;not null;default:default"`
+		Name     string `gorm:"size:64;not null"`
+		// CustomerGroup is one of the Groups, each has one price list per shop
+		CustomerGroup string `gorm:"size:16;uniqueIndex:idx_price_list_group;not null"`
+		// Prices are the products on the list, by product name
+		Prices []Price
+	}
+
+	// Price is the price of a product on a price list
+	Price struct {
+		ID          uint    `gorm:"primarykey"`
+		PriceListID uint    `gorm:"uniqueIndex:idx_price_list_product;not null"`
+		Product     string  `gorm:"size:255;uniqueIndex:idx_price_list_product;not null"`
+		Price       float64 `gorm:"not null"`
+		// TenantID is the shop of the price list
+		TenantID string `gorm:"size:32;index;not null;default:default"`
+	}
+
+	// PriceOverride sells a product at Price from StartsAt until EndsAt, e.g. for a sale. It replaces
+	// the base price of the product, and list prices too unless they are lower. Overrides of a product
+	// don't overlap, so at most one is active at a time; expired ones are simply no longer picked.
+	PriceOverride struct {
+		ID        uint      `gorm:"primarykey"`
+		Product   string    `gorm:"size:255;index:idx_price_override_product;not null"`
+		Price     float64   `gorm:"not null"`
+		StartsAt  time.Time `gorm:"index:idx_price_override_product;not null"`
+		EndsAt    time.Time `gorm:"not null"`
+		CreatedAt time.Time
+		// TenantID is the shop selling the product
+		TenantID string `gorm:"size:32;index:idx_price_override_product;not null;default:default"`
+	}
+)
+
+// TableName keeps the prices of price lists apart from other prices.
+func (Price) TableName() string {
+	return "price_list_prices"
+}
+
+// ValidGroup reports whether group is one of the Groups.
+func ValidGroup(group string) bool {
+	return slices.Contains(Groups, group)
+}
+
+// Active reports whether the override applies at the time.
+func (o PriceOverride) Active(at time.Time) bool {
+	return !at.Before(o.StartsAt) && at.Before(o.EndsAt)
+}
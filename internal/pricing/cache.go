@@ -0,0 +1,107 @@
+package pricing
+
+import (
+	"context"
+	"errors"
+	"interview/internal/cache"
+	"interview/internal/tenant"
+	"log"
+	"sync"
+	"time"
+)
+
+type (
+	// CachedProvider caches prices of another Provider in memory. Entries are fresh for TTL; once
+	// expired they are refetched, but if the underlying provider fails the stale price is served
+	// instead so a blip in the pricing service doesn't break adding items to the cart.
+	CachedProvider struct {
+		next Provider
+		ttl  time.Duration
+
+		mu      sync.RWMutex
+		entries map[string]cacheEntry
+	}
+
+	cacheEntry struct {
+		price     float64
+		fetchedAt time.Time
+	}
+)
+
+// NewCachedProvider wraps next with a cache whose entries are fresh for ttl.
+func NewCachedProvider(next Provider, ttl time.Duration) *CachedProvider {
+	return &CachedProvider{
+		next:    next,
+		ttl:     ttl,
+		entries: map[string]cacheEntry{},
+	}
+}
+
+// Price implements Provider.
+func (p *CachedProvider) Price(ctx context.Context, product string) (float64, error) {
+	key := cacheKey(ctx, product)
+	p.mu.RLock()
+	entry, cached := p.entries[key]
+	p.mu.RUnlock()
+
+	if cached && time.Now().Sub(entry.fetchedAt) < p.ttl {
+		return entry.price, nil
+	}
+
+	price, err := p.next.Price(ctx, product)
+	if err != nil {
+		if cached && !errors.Is(err, ErrProductNotFound) {
+			log.Printf("Serving stale price for %s: %v", product, err)
+			return entry.price, nil
+		}
+		if errors.Is(err, ErrProductNotFound) {
+			p.mu.Lock()
+			delete(p.entries, key)
+			p.mu.Unlock()
+		}
+		return 0, err
+	}
+
+	p.mu.Lock()
+	p.entries[key] = cacheEntry{price: price, fetchedAt: time.Now()}
+	p.mu.Unlock()
+
+	return price, nil
+}
+
+// SharedCachedProvider caches prices of another Provider in a cache.Store shared by every instance of
+// the application, so a price is fetched once per TTL instead of once per instance. Failures of next
+// aren't cached.
+type SharedCachedProvider struct {
+	next  Provider
+	cache *cache.Cache
+}
+
+// NewSharedCachedProvider wraps next with prices kept in the store for ttl.
+func NewSharedCachedProvider(next Provider, store cache.Store, ttl time.Duration) *SharedCachedProvider {
+	return &SharedCachedProvider{next: next, cache: cache.New(store, "price", ttl)}
+}
+
+// Price implements Provider.
+func (p *SharedCachedProvider) Price(ctx context.Context, product string) (float64, error) {
+	var price float64
+	key := cacheKey(ctx, product)
+	if p.cache.Get(ctx, key, &price) {
+		return price, nil
+	}
+	price, err := p.next.Price(ctx, product)
+	if err != nil {
+		return 0, err
+	}
+	p.cache.Set(ctx, key, price)
+	return price, nil
+}
+
+// cacheKey keys the cached price of the product by the shop it is looked up for, since shops may
+// sell the same product at different prices
+func cacheKey(ctx context.Context, product string) string {
+	if shop, ok := tenant.FromContext(ctx); ok {
+		return shop + "/" + product
+	}
+	return product
+}
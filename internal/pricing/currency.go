@@ -0,0 +1,94 @@
+package pricing
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Currencies converts prices from the currency of the price list to the other currencies customers
+// can choose to see them in.
+type Currencies struct {
+	base string
+	// rates maps currency codes to how much of the currency one unit of the base currency buys
+	rates map[string]float64
+}
+
+// NewCurrencies creates Currencies for prices in base that can be shown in the currencies of rates.
+func NewCurrencies(base string, rates map[string]float64) *Currencies {
+	return &Currencies{base: base, rates: rates}
+}
+
+// ParseRates parses a comma-separated list of exchange rates from the base currency, e.g.
+// "USD=1.08,GBP=0.86".
+func ParseRates(spec string) (map[string]float64, error) {
+	rates := map[string]float64{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		code, value, ok := strings.Cut(entry, "=")
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if !ok || !isCurrencyCode(code) {
+			return nil, fmt.Errorf("rate %q must have the form CODE=rate", entry)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || rate <= 0 || math.IsInf(rate, 0) {
+			return nil, fmt.Errorf("invalid rate for %s: %q", code, value)
+		}
+		if _, ok := rates[code]; ok {
+			return nil, fmt.Errorf("duplicate rate for %s", code)
+		}
+		rates[code] = rate
+	}
+	return rates, nil
+}
+
+// Base returns the currency of the price list.
+func (c *Currencies) Base() string {
+	return c.base
+}
+
+// Codes returns the base currency followed by the other currencies in alphabetical order.
+func (c *Currencies) Codes() []string {
+	codes := make([]string, 0, len(c.rates)+1)
+	for code := range c.rates {
+		if code != c.base {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+	return append([]string{c.base}, codes...)
+}
+
+// IsSupported reports whether prices can be shown in the currency.
+func (c *Currencies) IsSupported(code string) bool {
+	_, ok := c.rates[code]
+	return ok || code == c.base
+}
+
+// Format returns the price converted to the currency, rounded to cents. Prices in the base currency and
+// in unknown currencies are shown as they are, converted prices with the code of their currency.
+func (c *Currencies) Format(amount float64, code string) string {
+	rate, ok := c.rates[code]
+	if !ok || code == c.base {
+		return fmt.Sprintf("%.2f", amount)
+	}
+	return fmt.Sprintf("%.2f %s", math.Round(amount*rate*100)/100, code)
+}
+
+// isCurrencyCode reports whether code looks like an ISO 4217 code such as "EUR".
+func isCurrencyCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
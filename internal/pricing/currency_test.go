@@ -0,0 +1,38 @@
+package pricing_test
+
+import (
+	"interview/internal/pricing"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRates(t *testing.T) {
+	rates, err := pricing.ParseRates(" usd=1.08, GBP = 0.86 ,")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"USD": 1.08, "GBP": 0.86}, rates)
+
+	rates, err = pricing.ParseRates("")
+	require.NoError(t, err)
+	assert.Empty(t, rates)
+
+	for _, spec := range []string{"USD", "DOLLAR=1", "USD=abc", "USD=0", "USD=-1", "USD=1,USD=2"} {
+		_, err := pricing.ParseRates(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestCurrencies(t *testing.T) {
+	currencies := pricing.NewCurrencies("EUR", map[string]float64{"USD": 1.08, "GBP": 0.86})
+
+	assert.Equal(t, []string{"EUR", "GBP", "USD"}, currencies.Codes())
+	assert.True(t, currencies.IsSupported("EUR"))
+	assert.True(t, currencies.IsSupported("USD"))
+	assert.False(t, currencies.IsSupported("JPY"))
+
+	assert.Equal(t, "10.00", currencies.Format(10, "EUR"))
+	assert.Equal(t, "10.00", currencies.Format(10, ""))
+	assert.Equal(t, "10.80 USD", currencies.Format(10, "USD"))
+	assert.Equal(t, "8.60 GBP", currencies.Format(10, "GBP"))
+}
@@ -0,0 +1,114 @@
+// Package pricing looks up product prices from a static price list or an external pricing service.
+package pricing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"interview/internal/tenant"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrProductNotFound is returned when the provider doesn't know the product.
+var ErrProductNotFound = errors.New("product not found")
+
+// tolerance is the smallest difference between two prices that isn't rounding noise. Prices are stored as
+// floats, so differences below half a cent are.
+const tolerance = 0.005
+
+type (
+	// Provider returns the current unit price of a product.
+	Provider interface {
+		Price(ctx context.Context, product string) (float64, error)
+	}
+
+	// StaticProvider serves prices from a fixed map, used for development and testing.
+	StaticProvider map[string]float64
+
+	// HTTPProvider fetches prices from an external pricing service exposing
+	// GET {BaseURL}/prices/{product} which responds with {"price": 12.5}. Lookups for a shop of a
+	// multi-shop deployment ask for the prices of the shop with ?shop={id}.
+	HTTPProvider struct {
+		BaseURL string
+		Client  *http.Client
+	}
+)
+
+// DefaultPrices is the price list of the sample products created for development and testing.
+func DefaultPrices() StaticProvider {
+	return StaticProvider{
+		"shoe":  10.0,
+		"purse": 20.0,
+		"bag":   30.0,
+		"watch": 40.0,
+	}
+}
+
+// Changed reports whether two prices differ by more than rounding noise.
+func Changed(price, other float64) bool {
+	return math.Abs(price-other) >= tolerance
+}
+
+// Dropped reports whether the price went down from before to after by more than rounding noise.
+func Dropped(before, after float64) bool {
+	return before-after >= tolerance
+}
+
+// Price implements Provider.
+func (p StaticProvider) Price(_ context.Context, product string) (float64, error) {
+	price, ok := p[product]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrProductNotFound, product)
+	}
+	return price, nil
+}
+
+// NewHTTPProvider creates an HTTPProvider with a client that times out after a few seconds.
+func NewHTTPProvider(baseURL string) *HTTPProvider {
+	return &HTTPProvider{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		Client:  &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Price implements Provider.
+func (p *HTTPProvider) Price(ctx context.Context, product string) (float64, error) {
+	target := p.BaseURL + "/prices/" + url.PathEscape(product)
+	if shop, ok := tenant.FromContext(ctx); ok {
+		target += "?" + url.Values{"shop": {shop}}.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("price service request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return 0, fmt.Errorf("%w: %s", ErrProductNotFound, product)
+	case resp.StatusCode != http.StatusOK:
+		return 0, fmt.Errorf("price service returned status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Price *float64 `json:"price"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return 0, fmt.Errorf("failed to decode price: %w", err)
+	}
+	if body.Price == nil || *body.Price < 0 {
+		return 0, errors.New("price service returned an invalid price")
+	}
+	return *body.Price, nil
+}
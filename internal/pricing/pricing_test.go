@@ -0,0 +1,212 @@
+package pricing_test
+
+import (
+	"context"
+	"errors"
+	"interview/internal/cache"
+	"interview/internal/pricing"
+	"interview/internal/tenant"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticProvider(t *testing.T) {
+	p := pricing.DefaultPrices()
+
+	price, err := p.Price(context.Background(), "bag")
+	require.NoError(t, err)
+	assert.Equal(t, 30.0, price)
+
+	_, err = p.Price(context.Background(), "unknown")
+	assert.ErrorIs(t, err, pricing.ErrProductNotFound)
+}
+
+func TestPriceDifferences(t *testing.T) {
+	assert.False(t, pricing.Changed(10, 10.004), "differences below half a cent are rounding noise")
+	assert.True(t, pricing.Changed(10, 9.99))
+	assert.True(t, pricing.Dropped(10, 9.99))
+	assert.False(t, pricing.Dropped(9.99, 10))
+	assert.False(t, pricing.Dropped(10, 9.996))
+}
+
+func TestHTTPProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/prices/shoe":
+			if r.URL.Query().Get("shop") == "acme" {
+				_, _ = w.Write([]byte(`{"price": 15}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"price": 12.5}`))
+		case "/prices/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/prices/garbage":
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	p := pricing.NewHTTPProvider(srv.URL + "/")
+
+	t.Run("returns price", func(t *testing.T) {
+		price, err := p.Price(context.Background(), "shoe")
+		require.NoError(t, err)
+		assert.Equal(t, 12.5, price)
+	})
+
+	t.Run("asks for the prices of the shop", func(t *testing.T) {
+		price, err := p.Price(tenant.NewContext(context.Background(), "acme"), "shoe")
+		require.NoError(t, err)
+		assert.Equal(t, 15.0, price)
+	})
+
+	t.Run("maps 404 to not found", func(t *testing.T) {
+		_, err := p.Price(context.Background(), "unknown")
+		assert.ErrorIs(t, err, pricing.ErrProductNotFound)
+	})
+
+	t.Run("fails on server error", func(t *testing.T) {
+		_, err := p.Price(context.Background(), "broken")
+		require.Error(t, err)
+		assert.False(t, errors.Is(err, pricing.ErrProductNotFound))
+	})
+
+	t.Run("fails on missing price", func(t *testing.T) {
+		_, err := p.Price(context.Background(), "garbage")
+		assert.Error(t, err)
+	})
+}
+
+// countingProvider returns scripted results and counts calls
+type countingProvider struct {
+	calls int
+	price float64
+	err   error
+}
+
+func (p *countingProvider) Price(context.Context, string) (float64, error) {
+	p.calls++
+	return p.price, p.err
+}
+
+func TestCachedProvider(t *testing.T) {
+	t.Run("serves fresh entries from cache", func(t *testing.T) {
+		next := &countingProvider{price: 10}
+		p := pricing.NewCachedProvider(next, time.Hour)
+
+		for i := 0; i < 3; i++ {
+			price, err := p.Price(context.Background(), "shoe")
+			require.NoError(t, err)
+			assert.Equal(t, 10.0, price)
+		}
+		assert.Equal(t, 1, next.calls)
+	})
+
+	t.Run("caches prices per shop", func(t *testing.T) {
+		next := &countingProvider{price: 10}
+		p := pricing.NewCachedProvider(next, time.Hour)
+
+		_, err := p.Price(tenant.NewContext(context.Background(), "acme"), "shoe")
+		require.NoError(t, err)
+		next.price = 12
+		price, err := p.Price(tenant.NewContext(context.Background(), "globex"), "shoe")
+		require.NoError(t, err)
+		assert.Equal(t, 12.0, price)
+		assert.Equal(t, 2, next.calls)
+	})
+
+	t.Run("refetches expired entries", func(t *testing.T) {
+		next := &countingProvider{price: 10}
+		p := pricing.NewCachedProvider(next, 0)
+
+		_, err := p.Price(context.Background(), "shoe")
+		require.NoError(t, err)
+		next.price = 11
+		price, err := p.Price(context.Background(), "shoe")
+		require.NoError(t, err)
+		assert.Equal(t, 11.0, price)
+		assert.Equal(t, 2, next.calls)
+	})
+
+	t.Run("serves stale price when the provider fails", func(t *testing.T) {
+		next := &countingProvider{price: 10}
+		p := pricing.NewCachedProvider(next, 0)
+
+		_, err := p.Price(context.Background(), "shoe")
+		require.NoError(t, err)
+
+		next.err = errors.New("service down")
+		price, err := p.Price(context.Background(), "shoe")
+		require.NoError(t, err)
+		assert.Equal(t, 10.0, price)
+	})
+
+	t.Run("fails without a cached price", func(t *testing.T) {
+		p := pricing.NewCachedProvider(&countingProvider{err: errors.New("service down")}, time.Hour)
+
+		_, err := p.Price(context.Background(), "shoe")
+		assert.Error(t, err)
+	})
+
+	t.Run("does not serve stale price for removed products", func(t *testing.T) {
+		next := &countingProvider{price: 10}
+		p := pricing.NewCachedProvider(next, 0)
+
+		_, err := p.Price(context.Background(), "shoe")
+		require.NoError(t, err)
+
+		next.err = pricing.ErrProductNotFound
+		_, err = p.Price(context.Background(), "shoe")
+		assert.ErrorIs(t, err, pricing.ErrProductNotFound)
+	})
+}
+
+func TestSharedCachedProvider(t *testing.T) {
+	t.Run("instances share cached prices", func(t *testing.T) {
+		store := cache.NewMemory()
+		next := &countingProvider{price: 10}
+		first := pricing.NewSharedCachedProvider(next, store, time.Minute)
+		second := pricing.NewSharedCachedProvider(next, store, time.Minute)
+
+		for _, p := range []pricing.Provider{first, second, first} {
+			price, err := p.Price(context.Background(), "shoe")
+			require.NoError(t, err)
+			assert.Equal(t, 10.0, price)
+		}
+		assert.Equal(t, 1, next.calls)
+	})
+
+	t.Run("refetches expired prices", func(t *testing.T) {
+		now := time.Now()
+		store := cache.NewMemory()
+		store.SetClock(func() time.Time { return now })
+		next := &countingProvider{price: 10}
+		p := pricing.NewSharedCachedProvider(next, store, time.Minute)
+
+		_, err := p.Price(context.Background(), "shoe")
+		require.NoError(t, err)
+		next.price = 11
+		now = now.Add(2 * time.Minute)
+		price, err := p.Price(context.Background(), "shoe")
+		require.NoError(t, err)
+		assert.Equal(t, 11.0, price)
+	})
+
+	t.Run("does not cache failures", func(t *testing.T) {
+		next := &countingProvider{err: pricing.ErrProductNotFound}
+		p := pricing.NewSharedCachedProvider(next, cache.NewMemory(), time.Minute)
+
+		_, err := p.Price(context.Background(), "shoe")
+		assert.ErrorIs(t, err, pricing.ErrProductNotFound)
+		next.err, next.price = nil, 10
+		price, err := p.Price(context.Background(), "shoe")
+		require.NoError(t, err)
+		assert.Equal(t, 10.0, price)
+	})
+}
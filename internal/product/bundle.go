@@ -0,0 +1,70 @@
+package product
+
+import (
+	"errors"
+	"math"
+)
+
+var (
+	// ErrInvalidBundle is returned for bundle components that repeat a product, include the bundle itself
+	// or another bundle, or have a quantity below 1
+	ErrInvalidBundle = errors.New("bundle components need distinct products that aren't bundles and quantities of at least 1")
+	// ErrNotBundle is returned when adding a product without components as a bundle
+	ErrNotBundle = errors.New("product is not a bundle")
+)
+
+// BundleComponent puts Quantity units of the product ProductID into each unit of the bundle BundleID. A
+// bundle is a product with components, sold at its own price: adding it to a cart adds its components
+// instead, priced so that together they cost the price of the bundle.
+type BundleComponent struct {
+	ID        uint `gorm:"primarykey"`
+	BundleID  uint `gorm:"uniqueIndex:idx_bundle_component;not null"`
+	ProductID uint `gorm:"uniqueIndex:idx_bundle_component;index;not null"`
+	Quantity  int  `gorm:"not null"`
+}
+
+// ValidateComponents checks the components of the bundle, failing with ErrInvalidBundle. Whether the
+// products exist and aren't bundles themselves is left to the caller.
+func ValidateComponents(bundleID uint, components []BundleComponent) error {
+	seen := make(map[uint]bool, len(components))
+	for _, component := range components {
+		if component.Quantity < 1 || component.ProductID == bundleID || seen[component.ProductID] {
+			return ErrInvalidBundle
+		}
+		seen[component.ProductID] = true
+	}
+	return nil
+}
+
+// SplitBundlePrice returns the unit prices of the components of a bundle sold at price, given the regular
+// unit price and the units per bundle of each component. The price is shared out in proportion to the
+// regular prices and rounded to cents, the last component taking what rounding left over, so the units
+// of one bundle add up to its price.
+func SplitBundlePrice(price float64, regular []float64, units []int) []float64 {
+	prices := make([]float64, len(regular))
+	if len(prices) == 0 {
+		return prices
+	}
+	var total float64
+	for i := range regular {
+		total += regular[i] * float64(units[i])
+	}
+
+	left := price
+	for i := range regular[:len(regular)-1] {
+		share := price / float64(len(regular))
+		if total > 0 {
+			share = price * regular[i] * float64(units[i]) / total
+		}
+		prices[i] = roundCents(share / float64(units[i]))
+		left -= prices[i] * float64(units[i])
+	}
+	last := len(prices) - 1
+	prices[last] = max(left/float64(units[last]), 0)
+	return prices
+}
+
+// roundCents rounds the amount to whole cents
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
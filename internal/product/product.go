@@ -0,0 +1,136 @@
+package product
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// TypePhysical products are shipped to the customer
+	TypePhysical = "physical"
+	// TypeDigital products are files the customer downloads after checkout
+	TypeDigital = "digital"
+
+	// maxCategoryLength is the longest category accepted, in characters
+	maxCategoryLength = 64
+)
+
+var (
+	// ErrOutOfStock is returned when adding more units of a product to a cart than are in stock
+	ErrOutOfStock = errors.New("product is out of stock")
+	// ErrNameRequired is returned for products without a name
+	ErrNameRequired = errors.New("product is required")
+	// ErrInvalidPrice is returned for negative prices
+	ErrInvalidPrice = errors.New("price must be at least 0")
+	// ErrPriceRequired is returned when creating a product without a price
+	ErrPriceRequired = errors.New("price is required for new products")
+	// ErrInvalidStock is returned for negative stock levels
+	ErrInvalidStock = errors.New("stock must be at least 0")
+	// ErrInvalidThreshold is returned for low-stock thresholds below 1
+	ErrInvalidThreshold = errors.New("low-stock threshold must be at least 1")
+	// ErrInvalidType is returned for product types other than TypePhysical and TypeDigital
+	ErrInvalidType = errors.New("product type must be physical or digital")
+	// ErrNoFile is returned when making a product digital before its file was uploaded
+	ErrNoFile = errors.New("digital products need a file")
+	// ErrInvalidCategory is returned for category names longer than 64 characters
+	ErrInvalidCategory = errors.New("category must be at most 64 characters")
+	// ErrDownloadNotFound is returned for downloads that don't exist
+	ErrDownloadNotFound = errors.New("download not found")
+	// ErrDownloadExpired is returned for downloads past their expiry
+	ErrDownloadExpired = errors.New("download expired")
+	// ErrDownloadLimit is returned for downloads that were used as often as allowed
+	ErrDownloadLimit = errors.New("download limit reached")
+)
+
+type (
+	// Product represents an item of the catalog that can be added to a cart
+	Product struct {
+		gorm.Model
+		// TenantID is the shop selling the product
+		TenantID string `gorm:"size:32;uniqueIndex:idx_product_tenant_name;not null;default:default"`
+		// Name uniquely identifies the product within its shop and is stored on cart items
+		Name string `gorm:"size:255;uniqueIndex:idx_product_tenant_name;not null"`
+		// Price represents the current unit price of the product
+		Price float64 `gorm:"not null"`
+		// ImageKey is the storage key of the product image, empty when no image was uploaded
+		ImageKey string
+		// ThumbnailKey is the storage key of the thumbnail rendered from the image
+		ThumbnailKey string
+		// Stock is the number of units left to sell, nil when the stock of the product isn't tracked
+		Stock *int
+		// LowStockThreshold alerts staff once Stock falls below it, nil for no alerts
+		LowStockThreshold *int
+		// LowStockSnoozedUntil silences low-stock alerts of the product until then. Alerts snooze
+		// themselves and are woken up again once the product is restocked.
+		LowStockSnoozedUntil *time.Time
+		// Type is TypePhysical or TypeDigital
+		Type string `gorm:"size:16;not null;default:physical"`
+		// FileKey is the storage key of the file customers of a digital product download
+		FileKey string
+		// Category groups the product with similar ones, e.g. "shoes" on the cart page, empty when the
+		// product has none
+		Category string `gorm:"size:64;not null;default:''"`
+	}
+
+	// StockSubscription asks for an email to the address once the out-of-stock product is back in
+	// stock. Subscriptions are deleted when the email was sent.
+	StockSubscription struct {
+		gorm.Model
+		ProductID uint   `gorm:"not null;uniqueIndex:idx_stock_subscription"`
+		Email     string `gorm:"size:255;not null;uniqueIndex:idx_stock_subscription"`
+	}
+
+	// Download lets the customer who checked out a cart download the file of a digital product in it,
+	// through signed links that stop working at ExpiresAt or after MaxDownloads downloads.
+	Download struct {
+		gorm.Model
+		CartID    uint `gorm:"index;not null"`
+		ProductID uint `gorm:"not null"`
+		// ProductName is the name of the product when the cart was checked out
+		ProductName string `gorm:"size:255;not null"`
+		// Used is how often the file was downloaded
+		Used int `gorm:"not null;default:0"`
+		// MaxDownloads limits Used, 0 allows any number of downloads
+		MaxDownloads int `gorm:"not null;default:0"`
+		// ExpiresAt is when the links stop working, nil when they never do
+		ExpiresAt *time.Time
+		// EmailedAt is when the links were emailed to the customer, nil until then
+		EmailedAt *time.Time `gorm:"index"`
+	}
+)
+
+// Digital reports whether the product is a file downloaded after checkout
+func (p Product) Digital() bool {
+	return p.Type == TypeDigital
+}
+
+// OutOfStock reports whether the stock of the product is tracked and none is left
+func (p Product) OutOfStock() bool {
+	return p.Stock != nil && *p.Stock <= 0
+}
+
+// LowStock reports whether the stock of the product fell below its low-stock threshold
+func (p Product) LowStock() bool {
+	return p.Stock != nil && p.LowStockThreshold != nil && *p.Stock < *p.LowStockThreshold
+}
+
+// Remaining returns how many more times the file can be downloaded, -1 when there is no limit
+func (d Download) Remaining() int {
+	if d.MaxDownloads == 0 {
+		return -1
+	}
+	return max(d.MaxDownloads-d.Used, 0)
+}
+
+// NormalizeCategory trims the category typed by staff and checks its length, returning ErrInvalidCategory
+func NormalizeCategory(category string) (string, error) {
+	category = strings.TrimSpace(category)
+	if utf8.RuneCountInString(category) > maxCategoryLength {
+		return "", ErrInvalidCategory
+	}
+	return category, nil
+}
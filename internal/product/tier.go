@@ -0,0 +1,44 @@
+package product
+
+import (
+	"errors"
+	"sort"
+)
+
+// ErrInvalidTiers is returned for price tiers that don't start at a quantity of at least 2, repeat a
+// quantity, have a negative price or don't get cheaper the more units are bought
+var ErrInvalidTiers = errors.New("price tiers need distinct quantities of at least 2 and prices of at least 0 that drop as the quantity grows")
+
+// PriceTier sells MinQuantity or more units of a product in one cart at Price each, a volume discount on
+// the regular price. Of the tiers of a product, the one with the largest MinQuantity reached applies.
+type PriceTier struct {
+	ID          uint    `gorm:"primarykey"`
+	ProductID   uint    `gorm:"uniqueIndex:idx_price_tier_product_quantity;not null"`
+	MinQuantity int     `gorm:"uniqueIndex:idx_price_tier_product_quantity;not null"`
+	Price       float64 `gorm:"not null"`
+}
+
+// SortTiers orders the tiers by MinQuantity and checks them, failing with ErrInvalidTiers
+func SortTiers(tiers []PriceTier) error {
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].MinQuantity < tiers[j].MinQuantity })
+	for i, tier := range tiers {
+		if tier.MinQuantity < 2 || tier.Price < 0 {
+			return ErrInvalidTiers
+		}
+		if i > 0 && (tier.MinQuantity == tiers[i-1].MinQuantity || tier.Price >= tiers[i-1].Price) {
+			return ErrInvalidTiers
+		}
+	}
+	return nil
+}
+
+// TierFor returns the tier of the tiers, sorted by MinQuantity, applying to quantity units, nil when
+// the quantity reaches none
+func TierFor(tiers []PriceTier, quantity int) *PriceTier {
+	for i := len(tiers) - 1; i >= 0; i-- {
+		if quantity >= tiers[i].MinQuantity {
+			return &tiers[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+// Package promotion evaluates rules-based promotions such as "buy 3, cheapest free" or
+// "10% off orders over $100" against the items of a cart.
+package promotion
+
+import (
+	"interview/internal/cart"
+	"math"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// TypeBuyXGetY makes the cheapest FreeQuantity units of every BuyQuantity eligible units free
+	TypeBuyXGetY = "buy_x_get_y"
+	// TypeThreshold takes PercentOff off the cart once its subtotal reaches MinSubtotal
+	TypeThreshold = "threshold"
+)
+
+type (
+	// Promotion is a discount rule managed in the database
+	Promotion struct {
+		gorm.Model
+		// TenantID is the shop whose carts the promotion applies to
+		TenantID string `gorm:"size:32;index;not null;default:default"`
+		// Name is shown to the user on the discount line
+		Name string `gorm:"size:255;not null"`
+		// Type selects how the rule is evaluated, see TypeBuyXGetY and TypeThreshold
+		Type string `gorm:"size:64;not null"`
+		// Active rules are evaluated on every cart change
+		Active bool `gorm:"index"`
+		// Products restricts buy-X-get-Y rules to a comma-separated list of product names, empty for all products
+		Products string
+		// BuyQuantity is the size of a buy-X-get-Y group
+		BuyQuantity int
+		// FreeQuantity is how many of the cheapest units in each group are free
+		FreeQuantity int
+		// MinSubtotal is the subtotal from which a threshold rule applies
+		MinSubtotal float64
+		// PercentOff is the percentage taken off by a threshold rule
+		PercentOff float64
+	}
+)
+
+// Evaluate returns the discounts granted by the promotions for the given items. Item-level rules
+// (buy-X-get-Y) are applied first; threshold rules are then evaluated against the discounted subtotal.
+func Evaluate(promotions []Promotion, items []cart.CartItem) []cart.CartDiscount {
+	ordered := make([]Promotion, len(promotions))
+	copy(ordered, promotions)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Type == TypeBuyXGetY && ordered[j].Type != TypeBuyXGetY
+	})
+
+	subtotal := 0.0
+	for _, item := range items {
+		subtotal += item.Price * float64(item.Quantity)
+	}
+
+	var discounts []cart.CartDiscount
+	for _, p := range ordered {
+		if !p.Active {
+			continue
+		}
+
+		var amount float64
+		switch p.Type {
+		case TypeBuyXGetY:
+			amount = buyXGetY(p, items)
+		case TypeThreshold:
+			if subtotal >= p.MinSubtotal && p.PercentOff > 0 {
+				amount = subtotal * math.Min(p.PercentOff, 100) / 100
+			}
+		}
+
+		amount = roundCents(math.Min(amount, subtotal))
+		if amount <= 0 {
+			continue
+		}
+		subtotal -= amount
+		discounts = append(discounts, cart.CartDiscount{Promotion: p.Name, Amount: amount})
+	}
+	return discounts
+}
+
+// buyXGetY sorts eligible units from most to least expensive and, for each complete group of
+// BuyQuantity units, discounts the FreeQuantity cheapest ones. Units of the same price are counted
+// together rather than one by one, so large quantities cost no more than small ones.
+func buyXGetY(p Promotion, items []cart.CartItem) float64 {
+	if p.BuyQuantity < 1 || p.FreeQuantity < 1 || p.FreeQuantity > p.BuyQuantity {
+		return 0
+	}
+
+	eligible := map[string]bool{}
+	for _, name := range strings.Split(p.Products, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			eligible[name] = true
+		}
+	}
+
+	units := map[float64]int64{}
+	var total int64
+	for _, item := range items {
+		if item.Quantity < 1 || (len(eligible) > 0 && !eligible[item.ProductName]) {
+			continue
+		}
+		units[item.Price] += int64(item.Quantity)
+		total += int64(item.Quantity)
+	}
+	prices := make([]float64, 0, len(units))
+	for price := range units {
+		prices = append(prices, price)
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(prices)))
+
+	// free counts the free units among the first n units in that order: the last FreeQuantity of
+	// each complete group
+	buy, paid := int64(p.BuyQuantity), int64(p.BuyQuantity-p.FreeQuantity)
+	complete := total / buy * buy
+	free := func(n int64) int64 {
+		n = min(n, complete)
+		return n/buy*int64(p.FreeQuantity) + max(0, n%buy-paid)
+	}
+
+	amount := 0.0
+	var start int64
+	for _, price := range prices {
+		end := start + units[price]
+		amount += price * float64(free(end)-free(start))
+		start = end
+	}
+	return amount
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
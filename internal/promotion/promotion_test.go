@@ -0,0 +1,101 @@
+package promotion_test
+
+import (
+	"interview/internal/cart"
+	"interview/internal/promotion"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvaluate(t *testing.T) {
+	buy3 := promotion.Promotion{
+		Name: "3 shoes, cheapest free", Type: promotion.TypeBuyXGetY, Active: true,
+		Products: "shoe", BuyQuantity: 3, FreeQuantity: 1,
+	}
+	over100 := promotion.Promotion{
+		Name: "10% off over 100", Type: promotion.TypeThreshold, Active: true,
+		MinSubtotal: 100, PercentOff: 10,
+	}
+
+	tests := []struct {
+		name       string
+		promotions []promotion.Promotion
+		items      []cart.CartItem
+		expected   []cart.CartDiscount
+	}{
+		{
+			name:       "No Promotions",
+			promotions: nil,
+			items:      []cart.CartItem{{ProductName: "shoe", Quantity: 3, Price: 10}},
+			expected:   nil,
+		},
+		{
+			name:       "Buy Three Cheapest Free",
+			promotions: []promotion.Promotion{buy3},
+			items: []cart.CartItem{
+				{ProductName: "shoe", Quantity: 2, Price: 10},
+				{ProductName: "shoe", Quantity: 1, Price: 8},
+			},
+			expected: []cart.CartDiscount{{Promotion: buy3.Name, Amount: 8}},
+		},
+		{
+			name:       "Groups Spanning Prices",
+			promotions: []promotion.Promotion{buy3},
+			items: []cart.CartItem{
+				{ProductName: "shoe", Quantity: 4, Price: 10},
+				{ProductName: "shoe", Quantity: 3, Price: 8},
+				{ProductName: "bag", Quantity: 5, Price: 1},
+			},
+			expected: []cart.CartDiscount{{Promotion: buy3.Name, Amount: 18}},
+		},
+		{
+			name:       "Large Quantities",
+			promotions: []promotion.Promotion{buy3},
+			items:      []cart.CartItem{{ProductName: "shoe", Quantity: 2000000000, Price: 1}},
+			expected:   []cart.CartDiscount{{Promotion: buy3.Name, Amount: 666666666}},
+		},
+		{
+			name:       "Incomplete Group",
+			promotions: []promotion.Promotion{buy3},
+			items:      []cart.CartItem{{ProductName: "shoe", Quantity: 2, Price: 10}},
+			expected:   nil,
+		},
+		{
+			name:       "Ineligible Product",
+			promotions: []promotion.Promotion{buy3},
+			items:      []cart.CartItem{{ProductName: "bag", Quantity: 3, Price: 30}},
+			expected:   nil,
+		},
+		{
+			name:       "Threshold Below Minimum",
+			promotions: []promotion.Promotion{over100},
+			items:      []cart.CartItem{{ProductName: "bag", Quantity: 3, Price: 30}},
+			expected:   nil,
+		},
+		{
+			name:       "Threshold Applies After Item Discounts",
+			promotions: []promotion.Promotion{over100, buy3},
+			items: []cart.CartItem{
+				{ProductName: "shoe", Quantity: 3, Price: 50},
+				{ProductName: "bag", Quantity: 1, Price: 5},
+			},
+			expected: []cart.CartDiscount{
+				{Promotion: buy3.Name, Amount: 50},
+				{Promotion: over100.Name, Amount: 10.5},
+			},
+		},
+		{
+			name:       "Inactive Promotion",
+			promotions: []promotion.Promotion{{Name: "off", Type: promotion.TypeThreshold, PercentOff: 50}},
+			items:      []cart.CartItem{{ProductName: "bag", Quantity: 1, Price: 30}},
+			expected:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, promotion.Evaluate(tt.promotions, tt.items))
+		})
+	}
+}
@@ -0,0 +1,482 @@
+// Package qrcode encodes text as QR codes (ISO/IEC 18004) in byte mode with error correction level M,
+// which tolerates about 15% of the code being damaged or covered.
+package qrcode
+
+import (
+	"errors"
+	"image"
+	"image/color"
+)
+
+const (
+	minVersion = 1
+	maxVersion = 40
+	// quietZone is the light border around the code required by scanners, in modules
+	quietZone = 4
+)
+
+// ErrTooLong is returned when the text doesn't fit in the largest QR code
+var ErrTooLong = errors.New("text is too long for a QR code")
+
+var (
+	// eccPerBlock and eccBlocks are the error correction codewords of each block and the number of
+	// blocks of every version at level M, indexed by version
+	eccPerBlock = [maxVersion + 1]int{0, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26,
+		26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28}
+	eccBlocks = [maxVersion + 1]int{0, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17,
+		18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49}
+)
+
+// Code is an encoded QR code
+type Code struct {
+	// Size is the number of modules on each side
+	Size int
+	// modules are the dark modules by row and column, isFunction marks the finder, timing, alignment,
+	// format and version modules that data is placed around
+	modules    [][]bool
+	isFunction [][]bool
+}
+
+// Encode encodes text in the smallest QR code that fits it
+func Encode(text string) (*Code, error) {
+	data := []byte(text)
+	version := minVersion
+	for ; version <= maxVersion; version++ {
+		if 4+countBits(version)+len(data)*8 <= numDataCodewords(version)*8 {
+			break
+		}
+	}
+	if version > maxVersion {
+		return nil, ErrTooLong
+	}
+
+	var bits bitBuffer
+	bits.append(0b0100, 4) // byte mode
+	bits.append(len(data), countBits(version))
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+	// Terminate, fill up the last byte and pad the remaining capacity
+	capacity := numDataCodewords(version) * 8
+	bits.append(0, min(4, capacity-len(bits)))
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+
+	c := newCode(version)
+	c.drawFunctionPatterns(version)
+	c.drawCodewords(addErrorCorrection(bits.bytes(), version))
+	c.applyBestMask()
+	return c, nil
+}
+
+// Dark reports whether the module at the row and column is dark
+func (c *Code) Dark(row, col int) bool {
+	return c.modules[row][col]
+}
+
+// Image renders the code with scale pixels per module, surrounded by the quiet zone
+func (c *Code) Image(scale int) image.Image {
+	side := (c.Size + 2*quietZone) * scale
+	img := image.NewPaletted(image.Rect(0, 0, side, side), color.Palette{color.White, color.Black})
+	for row := 0; row < c.Size; row++ {
+		for col := 0; col < c.Size; col++ {
+			if !c.modules[row][col] {
+				continue
+			}
+			x, y := (col+quietZone)*scale, (row+quietZone)*scale
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetColorIndex(x+dx, y+dy, 1)
+				}
+			}
+		}
+	}
+	return img
+}
+
+func newCode(version int) *Code {
+	size := version*4 + 17
+	c := &Code{Size: size, modules: make([][]bool, size), isFunction: make([][]bool, size)}
+	for i := range c.modules {
+		c.modules[i] = make([]bool, size)
+		c.isFunction[i] = make([]bool, size)
+	}
+	return c
+}
+
+// countBits is the length of the character count of byte mode segments
+func countBits(version int) int {
+	if version < 10 {
+		return 8
+	}
+	return 16
+}
+
+// numRawDataModules is the number of modules left for data and error correction
+func numRawDataModules(version int) int {
+	result := (16*version+128)*version + 64
+	if version >= 2 {
+		numAlign := version/7 + 2
+		result -= (25*numAlign-10)*numAlign - 55
+		if version >= 7 {
+			result -= 36
+		}
+	}
+	return result
+}
+
+// numDataCodewords is the number of codewords for data, without error correction
+func numDataCodewords(version int) int {
+	return numRawDataModules(version)/8 - eccPerBlock[version]*eccBlocks[version]
+}
+
+// alignmentPositions returns the rows and columns of the centers of the alignment patterns
+func alignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	numAlign := version/7 + 2
+	step := (version*8 + numAlign*3 + 5) / (numAlign*4 - 4) * 2
+	positions := make([]int, numAlign)
+	positions[0] = 6
+	for i := numAlign - 1; i >= 1; i-- {
+		positions[i] = version*4 + 17 - 7 - (numAlign-1-i)*step
+	}
+	return positions
+}
+
+func (c *Code) setFunction(row, col int, dark bool) {
+	c.modules[row][col] = dark
+	c.isFunction[row][col] = true
+}
+
+func (c *Code) drawFunctionPatterns(version int) {
+	for i := 0; i < c.Size; i++ {
+		c.setFunction(6, i, i%2 == 0)
+		c.setFunction(i, 6, i%2 == 0)
+	}
+
+	c.drawFinder(3, 3)
+	c.drawFinder(3, c.Size-4)
+	c.drawFinder(c.Size-4, 3)
+
+	positions := alignmentPositions(version)
+	last := len(positions) - 1
+	for i, row := range positions {
+		for j, col := range positions {
+			// The corners with finder patterns have no alignment pattern
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					c.setFunction(row+dy, col+dx, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// Reserve the format modules until the mask is chosen
+	c.drawFormat(0)
+
+	if version >= 7 {
+		rem := version
+		for i := 0; i < 12; i++ {
+			rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+		}
+		bits := version<<12 | rem
+		for i := 0; i < 18; i++ {
+			dark := (bits>>i)&1 != 0
+			a, b := c.Size-11+i%3, i/3
+			c.setFunction(b, a, dark)
+			c.setFunction(a, b, dark)
+		}
+	}
+}
+
+// drawFinder draws the finder pattern centered on the row and column with its light separator
+func (c *Code) drawFinder(row, col int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			y, x := row+dy, col+dx
+			if y < 0 || y >= c.Size || x < 0 || x >= c.Size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			c.setFunction(y, x, dist != 2 && dist != 4)
+		}
+	}
+}
+
+// drawFormat draws both copies of the format information for level M and the mask
+func (c *Code) drawFormat(mask int) {
+	data := mask // level M is 0b00
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return (bits>>i)&1 != 0 }
+
+	for i := 0; i <= 5; i++ {
+		c.setFunction(i, 8, bit(i))
+	}
+	c.setFunction(7, 8, bit(6))
+	c.setFunction(8, 8, bit(7))
+	c.setFunction(8, 7, bit(8))
+	for i := 9; i < 15; i++ {
+		c.setFunction(8, 14-i, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		c.setFunction(8, c.Size-1-i, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.setFunction(c.Size-15+i, 8, bit(i))
+	}
+	c.setFunction(c.Size-8, 8, true)
+}
+
+// drawCodewords places the codewords in the zigzag order of the standard, two columns at a time
+// from the bottom right, skipping the function modules
+func (c *Code) drawCodewords(codewords []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < c.Size; vert++ {
+			row := vert
+			if upward {
+				row = c.Size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				col := right - j
+				if c.isFunction[row][col] || i >= len(codewords)*8 {
+					continue
+				}
+				c.modules[row][col] = (codewords[i>>3]>>(7-i&7))&1 != 0
+				i++
+			}
+		}
+	}
+}
+
+// masked reports whether the mask pattern inverts the module at the row and column
+func masked(mask, row, col int) bool {
+	switch mask {
+	case 0:
+		return (row+col)%2 == 0
+	case 1:
+		return row%2 == 0
+	case 2:
+		return col%3 == 0
+	case 3:
+		return (row+col)%3 == 0
+	case 4:
+		return (row/2+col/3)%2 == 0
+	case 5:
+		return row*col%2+row*col%3 == 0
+	case 6:
+		return (row*col%2+row*col%3)%2 == 0
+	default:
+		return ((row+col)%2+row*col%3)%2 == 0
+	}
+}
+
+func (c *Code) applyMask(mask int) {
+	for row := 0; row < c.Size; row++ {
+		for col := 0; col < c.Size; col++ {
+			if !c.isFunction[row][col] && masked(mask, row, col) {
+				c.modules[row][col] = !c.modules[row][col]
+			}
+		}
+	}
+}
+
+// applyBestMask applies the mask whose result is penalized least, which makes it easiest to scan
+func (c *Code) applyBestMask() {
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormat(mask)
+		if penalty := c.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		c.applyMask(mask) // masks are their own inverse
+	}
+	c.applyMask(best)
+	c.drawFormat(best)
+}
+
+// penalty scores the modules by the four rules of the standard: long runs of one color, 2×2 blocks
+// of one color, patterns resembling finders and an unbalanced share of dark modules
+func (c *Code) penalty() int {
+	penalty := 0
+	finderLike := [][]bool{
+		{true, false, true, true, true, false, true, false, false, false, false},
+		{false, false, false, false, true, false, true, true, true, false, true},
+	}
+	for _, transposed := range []bool{false, true} {
+		at := func(i, j int) bool {
+			if transposed {
+				return c.modules[j][i]
+			}
+			return c.modules[i][j]
+		}
+		for i := 0; i < c.Size; i++ {
+			run := 1
+			for j := 1; j <= c.Size; j++ {
+				if j < c.Size && at(i, j) == at(i, j-1) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					penalty += 3 + run - 5
+				}
+				run = 1
+			}
+			for j := 0; j+len(finderLike[0]) <= c.Size; j++ {
+				for _, pattern := range finderLike {
+					matches := true
+					for k, dark := range pattern {
+						if at(i, j+k) != dark {
+							matches = false
+							break
+						}
+					}
+					if matches {
+						penalty += 40
+					}
+				}
+			}
+		}
+	}
+
+	dark := 0
+	for row := 0; row < c.Size; row++ {
+		for col := 0; col < c.Size; col++ {
+			if c.modules[row][col] {
+				dark++
+			}
+			if row > 0 && col > 0 {
+				m := c.modules[row][col]
+				if m == c.modules[row-1][col] && m == c.modules[row][col-1] && m == c.modules[row-1][col-1] {
+					penalty += 3
+				}
+			}
+		}
+	}
+	total := c.Size * c.Size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	return penalty + k*10
+}
+
+// addErrorCorrection splits the data into the blocks of the version, appends the Reed-Solomon error
+// correction codewords of each block and interleaves the blocks
+func addErrorCorrection(data []byte, version int) []byte {
+	numBlocks, eccLen := eccBlocks[version], eccPerBlock[version]
+	rawCodewords := numRawDataModules(version) / 8
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortBlockLen := rawCodewords / numBlocks
+	divisor := reedSolomonDivisor(eccLen)
+
+	blocks := make([][]byte, numBlocks)
+	eccs := make([][]byte, numBlocks)
+	offset := 0
+	for i := range blocks {
+		length := shortBlockLen - eccLen
+		if i >= numShortBlocks {
+			length++
+		}
+		blocks[i] = data[offset : offset+length]
+		eccs[i] = reedSolomonRemainder(blocks[i], divisor)
+		offset += length
+	}
+
+	result := make([]byte, 0, rawCodewords)
+	for i := 0; i <= shortBlockLen-eccLen; i++ {
+		for _, block := range blocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := 0; i < eccLen; i++ {
+		for _, ecc := range eccs {
+			result = append(result, ecc[i])
+		}
+	}
+	return result
+}
+
+// reedSolomonDivisor returns the generator polynomial of the given degree, highest coefficient first
+// and without the leading 1
+func reedSolomonDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+// reedSolomonRemainder returns the error correction codewords of the data
+func reedSolomonRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, coef := range divisor {
+			result[i] ^= gfMultiply(coef, factor)
+		}
+	}
+	return result
+}
+
+// gfMultiply multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1
+func gfMultiply(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+// bitBuffer collects bits, most significant first
+type bitBuffer []bool
+
+func (b *bitBuffer) append(value, length int) {
+	for i := length - 1; i >= 0; i-- {
+		*b = append(*b, (value>>i)&1 != 0)
+	}
+}
+
+func (b bitBuffer) bytes() []byte {
+	result := make([]byte, len(b)/8)
+	for i, bit := range b {
+		if bit {
+			result[i/8] |= 1 << (7 - i%8)
+		}
+	}
+	return result
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
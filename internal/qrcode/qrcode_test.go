@@ -0,0 +1,323 @@
+package qrcode_test
+
+import (
+	"interview/internal/qrcode"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blocks are the number of blocks and error correction codewords per block at level M by version,
+// for the versions used in the tests
+var blocks = map[int][2]int{
+	1: {1, 10}, 2: {1, 16}, 3: {1, 26}, 4: {2, 18}, 5: {2, 24}, 6: {4, 16}, 7: {4, 18}, 8: {4, 22},
+	9: {5, 22}, 10: {5, 26}, 11: {5, 30},
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		version int
+	}{
+		{"Short Text", "hello", 1},
+		{"Cart Link", "https://shop.example.com/cart/resume?cart=42&expires=1791990000&signature=" +
+			strings.Repeat("ab", 32), 8},
+		{"Largest Version One", strings.Repeat("a", 14), 1},
+		{"Smallest Version Two", strings.Repeat("a", 15), 2},
+		{"Version With Version Information", strings.Repeat("x", 150), 8},
+		{"Version With Long Character Count", strings.Repeat("y", 200), 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := qrcode.Encode(tt.text)
+			require.NoError(t, err)
+			assert.Equal(t, tt.version*4+17, c.Size)
+			assert.Equal(t, tt.text, decode(t, c))
+		})
+	}
+}
+
+func TestEncodeTooLong(t *testing.T) {
+	_, err := qrcode.Encode(strings.Repeat("a", 2332))
+	assert.ErrorIs(t, err, qrcode.ErrTooLong)
+
+	c, err := qrcode.Encode(strings.Repeat("a", 2331))
+	require.NoError(t, err)
+	assert.Equal(t, 177, c.Size)
+}
+
+func TestImage(t *testing.T) {
+	c, err := qrcode.Encode("hello")
+	require.NoError(t, err)
+
+	img := c.Image(3)
+	assert.Equal(t, (21+8)*3, img.Bounds().Dx())
+	assert.Equal(t, (21+8)*3, img.Bounds().Dy())
+
+	dark := func(x, y int) bool {
+		r, _, _, _ := img.At(x, y).RGBA()
+		return r == 0
+	}
+	assert.False(t, dark(0, 0), "quiet zone")
+	assert.True(t, dark(4*3, 4*3), "finder pattern")
+	assert.True(t, dark(4*3+2, 4*3+2), "finder pattern")
+	assert.False(t, dark(5*3, 5*3), "inside of the finder pattern")
+}
+
+// decode reads the code back the way a scanner does: it checks the finder patterns and format
+// information, unmasks the data, verifies the error correction of every block and parses the byte
+// mode segment
+func decode(t *testing.T, c *qrcode.Code) string {
+	t.Helper()
+	size := c.Size
+	version := (size - 17) / 4
+
+	for _, corner := range [][2]int{{0, 0}, {0, size - 7}, {size - 7, 0}} {
+		for dy := 0; dy < 7; dy++ {
+			for dx := 0; dx < 7; dx++ {
+				dist := max(abs(dx-3), abs(dy-3))
+				require.Equal(t, dist != 2, c.Dark(corner[0]+dy, corner[1]+dx), "finder pattern")
+			}
+		}
+	}
+	for i := 8; i < size-8; i++ {
+		require.Equal(t, i%2 == 0, c.Dark(6, i), "timing pattern")
+		require.Equal(t, i%2 == 0, c.Dark(i, 6), "timing pattern")
+	}
+
+	format, second := 0, 0
+	for i := 0; i <= 5; i++ {
+		format |= bit(c.Dark(i, 8)) << i
+	}
+	format |= bit(c.Dark(7, 8))<<6 | bit(c.Dark(8, 8))<<7 | bit(c.Dark(8, 7))<<8
+	for i := 9; i < 15; i++ {
+		format |= bit(c.Dark(8, 14-i)) << i
+	}
+	for i := 0; i < 8; i++ {
+		second |= bit(c.Dark(8, size-1-i)) << i
+	}
+	for i := 8; i < 15; i++ {
+		second |= bit(c.Dark(size-15+i, 8)) << i
+	}
+	require.Equal(t, format, second, "both copies of the format information")
+	require.True(t, c.Dark(size-8, 8), "dark module")
+	format ^= 0x5412
+	require.Equal(t, 0, bch(format, 0x537, 10), "format information checksum")
+	require.Equal(t, 0, format>>13, "error correction level M")
+	mask := (format >> 10) & 7
+
+	if version >= 7 {
+		info := 0
+		for i := 0; i < 18; i++ {
+			info |= bit(c.Dark(i/3, size-11+i%3)) << i
+			require.Equal(t, c.Dark(i/3, size-11+i%3), c.Dark(size-11+i%3, i/3), "version information")
+		}
+		require.Equal(t, version, info>>12)
+		require.Equal(t, 0, bch(info, 0x1F25, 12), "version information checksum")
+	}
+
+	function := functionModules(version)
+	var codewords []byte
+	var current, count int
+	for right := size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < size; vert++ {
+			row := vert
+			if (right+1)&2 == 0 {
+				row = size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				col := right - j
+				if function[row][col] {
+					continue
+				}
+				dark := c.Dark(row, col) != masked(mask, row, col)
+				current = current<<1 | bit(dark)
+				if count++; count%8 == 0 {
+					codewords = append(codewords, byte(current))
+					current = 0
+				}
+			}
+		}
+	}
+
+	numBlocks, eccLen := blocks[version][0], blocks[version][1]
+	numShort := numBlocks - len(codewords)%numBlocks
+	shortLen := len(codewords) / numBlocks
+	split := make([][]byte, numBlocks)
+	i := 0
+	for k := 0; k <= shortLen-eccLen; k++ {
+		for b := range split {
+			if k < shortLen-eccLen || b >= numShort {
+				split[b] = append(split[b], codewords[i])
+				i++
+			}
+		}
+	}
+	for k := 0; k < eccLen; k++ {
+		for b := range split {
+			split[b] = append(split[b], codewords[i])
+			i++
+		}
+	}
+
+	var data []byte
+	for _, block := range split {
+		for root := 0; root < eccLen; root++ {
+			require.Equal(t, byte(0), syndrome(block, gfPow(root)), "error correction")
+		}
+		data = append(data, block[:len(block)-eccLen]...)
+	}
+
+	require.Equal(t, byte(0b0100), data[0]>>4, "byte mode")
+	bits := func(offset, length int) int {
+		value := 0
+		for i := offset; i < offset+length; i++ {
+			value = value<<1 | int(data[i/8]>>(7-i%8)&1)
+		}
+		return value
+	}
+	countLen := 8
+	if version >= 10 {
+		countLen = 16
+	}
+	length := bits(4, countLen)
+	text := make([]byte, length)
+	for i := range text {
+		text[i] = byte(bits(4+countLen+i*8, 8))
+	}
+	return string(text)
+}
+
+// functionModules marks the modules of the version that don't hold data
+func functionModules(version int) [][]bool {
+	size := version*4 + 17
+	function := make([][]bool, size)
+	for i := range function {
+		function[i] = make([]bool, size)
+	}
+	fill := func(row, col, height, width int) {
+		for y := row; y < row+height; y++ {
+			for x := col; x < col+width; x++ {
+				function[y][x] = true
+			}
+		}
+	}
+	fill(0, 0, 9, 9)
+	fill(0, size-8, 9, 8)
+	fill(size-8, 0, 8, 9)
+	fill(6, 0, 1, size)
+	fill(0, 6, size, 1)
+	if version >= 7 {
+		fill(0, size-11, 6, 3)
+		fill(size-11, 0, 3, 6)
+	}
+	if version >= 2 {
+		n := version/7 + 2
+		step := (version*8 + n*3 + 5) / (n*4 - 4) * 2
+		positions := []int{6}
+		for k := n - 2; k >= 0; k-- {
+			positions = append(positions, size-7-k*step)
+		}
+		for i, row := range positions {
+			for j, col := range positions {
+				if (i == 0 && j == 0) || (i == 0 && j == n-1) || (i == n-1 && j == 0) {
+					continue
+				}
+				fill(row-2, col-2, 5, 5)
+			}
+		}
+	}
+	return function
+}
+
+func masked(mask, row, col int) bool {
+	switch mask {
+	case 0:
+		return (row+col)%2 == 0
+	case 1:
+		return row%2 == 0
+	case 2:
+		return col%3 == 0
+	case 3:
+		return (row+col)%3 == 0
+	case 4:
+		return (row/2+col/3)%2 == 0
+	case 5:
+		return row*col%2+row*col%3 == 0
+	case 6:
+		return (row*col%2+row*col%3)%2 == 0
+	default:
+		return ((row+col)%2+row*col%3)%2 == 0
+	}
+}
+
+// bch returns the remainder of the BCH code word divided by the generator
+func bch(word, generator, degree int) int {
+	for i := bitLen(word) - 1; i >= degree; i-- {
+		if word>>i&1 != 0 {
+			word ^= generator << (i - degree)
+		}
+	}
+	return word
+}
+
+// syndrome evaluates the block as a polynomial, highest coefficient first, at x in GF(2^8)
+func syndrome(block []byte, x byte) byte {
+	var result byte
+	for _, b := range block {
+		result = gfMul(result, x) ^ b
+	}
+	return result
+}
+
+func gfPow(exp int) byte {
+	result := byte(1)
+	for i := 0; i < exp; i++ {
+		result = gfMul(result, 2)
+	}
+	return result
+}
+
+func gfMul(x, y byte) byte {
+	var result byte
+	for ; y != 0; y >>= 1 {
+		if y&1 != 0 {
+			result ^= x
+		}
+		carry := x&0x80 != 0
+		x <<= 1
+		if carry {
+			x ^= 0x1D
+		}
+	}
+	return result
+}
+
+func bitLen(x int) int {
+	n := 0
+	for ; x != 0; x >>= 1 {
+		n++
+	}
+	return n
+}
+
+func bit(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,107 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+type (
+	// Lockout locks keys out after repeated failures, e.g. failed logins. Once a key failed threshold
+	// times in a row, every further failure locks it out for twice as long as the one before, starting at
+	// base and capped at max. Failures are forgotten max after the last one. Counts are kept in memory,
+	// so every server instance locks out on its own.
+	Lockout struct {
+		threshold int
+		base, max time.Duration
+		now       func() time.Time
+
+		mu   sync.Mutex
+		keys map[string]*failures
+	}
+
+	// failures counts the failures of a key in a row
+	failures struct {
+		count       int
+		last        time.Time
+		lockedUntil time.Time
+	}
+)
+
+// NewLockout creates a Lockout locking keys out after threshold failures in a row.
+func NewLockout(threshold int, base, max time.Duration) *Lockout {
+	return &Lockout{
+		threshold: threshold,
+		base:      base,
+		max:       max,
+		now:       time.Now,
+		keys:      map[string]*failures{},
+	}
+}
+
+// SetClock replaces the clock of the lockout, for tests.
+func (l *Lockout) SetClock(now func() time.Time) {
+	l.now = now
+}
+
+// LockedFor returns how much longer the key is locked out, 0 if it isn't.
+func (l *Lockout) LockedFor(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, ok := l.keys[key]
+	if !ok {
+		return 0
+	}
+	if wait := f.lockedUntil.Sub(l.now()); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// Fail records a failure of key. It returns how many more failures are allowed before the key is locked
+// out, and how long it is locked out for when this failure locked it out.
+func (l *Lockout) Fail(key string) (left int, lockedFor time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	f, ok := l.keys[key]
+	if !ok || now.Sub(f.last) >= l.max {
+		if !ok && len(l.keys) >= maxKeys {
+			l.prune(now)
+		}
+		f = &failures{}
+		l.keys[key] = f
+	}
+	f.count++
+	f.last = now
+	if f.count < l.threshold {
+		return l.threshold - f.count, 0
+	}
+
+	lockedFor = l.base
+	for i := l.threshold; i < f.count && lockedFor < l.max; i++ {
+		lockedFor *= 2
+	}
+	if lockedFor > l.max {
+		lockedFor = l.max
+	}
+	f.lockedUntil = now.Add(lockedFor)
+	return 0, lockedFor
+}
+
+// Reset forgets the failures of key, e.g. once a login succeeded.
+func (l *Lockout) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.keys, key)
+}
+
+// prune drops the keys whose failures are forgotten.
+func (l *Lockout) prune(now time.Time) {
+	for key, f := range l.keys {
+		if now.Sub(f.last) >= l.max && !now.Before(f.lockedUntil) {
+			delete(l.keys, key)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package ratelimit_test
+
+import (
+	"interview/internal/ratelimit"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockout(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := ratelimit.NewLockout(3, time.Minute, 10*time.Minute)
+	l.SetClock(func() time.Time { return now })
+
+	left, lockedFor := l.Fail("a")
+	assert.Equal(t, 2, left)
+	assert.Zero(t, lockedFor)
+	l.Fail("a")
+	assert.Zero(t, l.LockedFor("a"))
+
+	left, lockedFor = l.Fail("a")
+	assert.Zero(t, left)
+	assert.Equal(t, time.Minute, lockedFor)
+	assert.Equal(t, time.Minute, l.LockedFor("a"))
+	assert.Zero(t, l.LockedFor("b"), "keys are locked out separately")
+
+	now = now.Add(time.Minute)
+	assert.Zero(t, l.LockedFor("a"))
+	for _, want := range []time.Duration{2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 10 * time.Minute, 10 * time.Minute} {
+		_, lockedFor = l.Fail("a")
+		assert.Equal(t, want, lockedFor, "each failure doubles the lockout up to the maximum")
+	}
+
+	l.Reset("a")
+	assert.Zero(t, l.LockedFor("a"))
+	left, _ = l.Fail("a")
+	assert.Equal(t, 2, left, "a reset forgets the failures")
+
+	now = now.Add(10 * time.Minute)
+	left, _ = l.Fail("a")
+	assert.Equal(t, 2, left, "failures are forgotten after the maximum lockout")
+}
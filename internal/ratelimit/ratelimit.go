@@ -0,0 +1,81 @@
+// Package ratelimit limits how often clients may do something, e.g. request password reset emails.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// maxKeys is how many keys are tracked before expired windows are dropped
+const maxKeys = 10000
+
+type (
+	// Limiter allows each key a number of events per fixed time window. Counts are kept in memory, so
+	// every server instance limits on its own.
+	Limiter struct {
+		limit  int
+		window time.Duration
+		now    func() time.Time
+
+		mu      sync.Mutex
+		windows map[string]*window
+	}
+
+	// window counts the events of a key since start
+	window struct {
+		start time.Time
+		count int
+	}
+)
+
+// NewLimiter creates a Limiter allowing limit events per key every period.
+func NewLimiter(limit int, period time.Duration) *Limiter {
+	return &Limiter{
+		limit:   limit,
+		window:  period,
+		now:     time.Now,
+		windows: map[string]*window{},
+	}
+}
+
+// SetClock replaces the clock of the limiter, for tests.
+func (l *Limiter) SetClock(now func() time.Time) {
+	l.now = now
+}
+
+// SetLimit changes how many events each key is allowed per period, applying to current windows too.
+func (l *Limiter) SetLimit(limit int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = limit
+}
+
+// Allow records an event of key and reports whether it is within the limit.
+func (l *Limiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	w, ok := l.windows[key]
+	if !ok || now.Sub(w.start) >= l.window {
+		if !ok && len(l.windows) >= maxKeys {
+			l.prune(now)
+		}
+		w = &window{start: now}
+		l.windows[key] = w
+	}
+	if w.count >= l.limit {
+		return false
+	}
+	w.count++
+	return true
+}
+
+// prune drops the windows that have ended.
+func (l *Limiter) prune(now time.Time) {
+	for key, w := range l.windows {
+		if now.Sub(w.start) >= l.window {
+			delete(l.windows, key)
+		}
+	}
+}
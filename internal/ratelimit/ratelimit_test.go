@@ -0,0 +1,36 @@
+package ratelimit_test
+
+import (
+	"interview/internal/ratelimit"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := ratelimit.NewLimiter(2, time.Hour)
+	l.SetClock(func() time.Time { return now })
+
+	assert.True(t, l.Allow("a"))
+	assert.True(t, l.Allow("a"))
+	assert.False(t, l.Allow("a"))
+	assert.True(t, l.Allow("b"), "keys are limited separately")
+
+	now = now.Add(59 * time.Minute)
+	assert.False(t, l.Allow("a"))
+
+	now = now.Add(time.Minute)
+	assert.True(t, l.Allow("a"), "a new window starts after the old one ended")
+}
+
+func TestLimiterSetLimit(t *testing.T) {
+	l := ratelimit.NewLimiter(1, time.Hour)
+	assert.True(t, l.Allow("a"))
+	assert.False(t, l.Allow("a"))
+
+	l.SetLimit(2)
+	assert.True(t, l.Allow("a"), "a raised limit applies to the current window")
+	assert.False(t, l.Allow("a"))
+}
@@ -0,0 +1,64 @@
+// Package recommend suggests products to add to a cart.
+package recommend
+
+import (
+	"context"
+	productpkg "interview/internal/product"
+	"time"
+)
+
+type (
+	// Provider recommends up to limit products to customers whose cart holds the named products. The
+	// context carries the shop the customer is in.
+	Provider interface {
+		Recommend(ctx context.Context, products []string, limit int) ([]productpkg.Product, error)
+	}
+
+	// Store lists the products checked out in the same carts as the named products, best associated
+	// first.
+	Store interface {
+		ListBoughtTogether(ctx context.Context, names []string, limit int) ([]productpkg.Product, error)
+	}
+
+	// BoughtTogether recommends the products most frequently bought together with the products of the
+	// cart, as of the last time the associations were computed.
+	BoughtTogether struct {
+		store Store
+	}
+
+	// Association records that the product RelatedID was bought in Carts of the carts checked out with
+	// the product ProductID. Score is the share of the carts with ProductID that held RelatedID, see
+	// Score. Associations are computed from scratch by a scheduled job, at ComputedAt.
+	Association struct {
+		ID         uint    `gorm:"primarykey"`
+		ProductID  uint    `gorm:"uniqueIndex:idx_product_association;not null"`
+		RelatedID  uint    `gorm:"uniqueIndex:idx_product_association;index;not null"`
+		Carts      int     `gorm:"not null"`
+		Score      float64 `gorm:"not null"`
+		ComputedAt time.Time
+	}
+)
+
+// Score returns the share, from 0 to 1, of the carts holding a product that also held the related one,
+// given how many of them held both.
+func Score(together, carts int) float64 {
+	if carts == 0 {
+		return 0
+	}
+	return float64(together) / float64(carts)
+}
+
+// TableName names the associations table after what is associated.
+func (Association) TableName() string {
+	return "product_associations"
+}
+
+// NewBoughtTogether creates a BoughtTogether provider computing recommendations from the store.
+func NewBoughtTogether(store Store) *BoughtTogether {
+	return &BoughtTogether{store: store}
+}
+
+// Recommend implements Provider.
+func (b *BoughtTogether) Recommend(ctx context.Context, products []string, limit int) ([]productpkg.Product, error) {
+	return b.store.ListBoughtTogether(ctx, products, limit)
+}
@@ -0,0 +1,57 @@
+// Package referral defines the referral codes users share with new customers and the rewards they
+// earn when a referred cart is checked out.
+package referral
+
+import (
+	"crypto/rand"
+	"fmt"
+	"interview/internal/giftcard"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+type (
+	// Referral is the referral code of a user
+	Referral struct {
+		gorm.Model
+		// UserID is the user who shares the code and is rewarded for it, each user has one code
+		UserID uint `gorm:"uniqueIndex;not null"`
+		// Code is what new customers enter, stored in upper case
+		Code string `gorm:"size:32;uniqueIndex;not null"`
+	}
+
+	// Reward records the credit granted to the referrer for a checked-out cart
+	Reward struct {
+		gorm.Model
+		// ReferralID links the reward to the referral code the cart was checked out with
+		ReferralID uint `gorm:"index;not null"`
+		// CartID is the referred cart, rewarded at most once
+		CartID uint `gorm:"uniqueIndex;not null"`
+		// ReferredUserID is the customer who checked the cart out, who earns the referrer one reward at most
+		ReferredUserID uint `gorm:"uniqueIndex;not null"`
+		// GiftCardID is the gift card holding the credit
+		GiftCardID uint `gorm:"not null"`
+		// GiftCard is the gift card holding the credit
+		GiftCard giftcard.GiftCard
+	}
+)
+
+// TableName keeps the rewards table name explicit about what is rewarded.
+func (Reward) TableName() string {
+	return "referral_rewards"
+}
+
+// NormalizeCode makes codes typed by users comparable to stored codes.
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// NewCode generates a random code of the form REF-XXXXXXXX.
+func NewCode() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate referral code: %w", err)
+	}
+	return fmt.Sprintf("REF-%X", b), nil
+}
@@ -0,0 +1,77 @@
+package reminder
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ResumePath is the path of the endpoint restoring a cart from a signed link
+const ResumePath = "/cart/resume"
+
+var (
+	// ErrInvalidLink is returned for cart links that are malformed or not signed by us
+	ErrInvalidLink = errors.New("invalid cart link")
+	// ErrLinkExpired is returned for cart links past their expiry
+	ErrLinkExpired = errors.New("cart link expired")
+)
+
+// CartLinks builds and verifies signed links that open a cart in any browser, so reminder emails
+// can take users straight back to their cart.
+type CartLinks struct {
+	baseURL string
+	secret  []byte
+	ttl     time.Duration
+	now     func() time.Time
+}
+
+// NewCartLinks creates links to baseURL signed with secret and valid for ttl
+func NewCartLinks(baseURL string, secret []byte, ttl time.Duration) *CartLinks {
+	return &CartLinks{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		secret:  secret,
+		ttl:     ttl,
+		now:     time.Now,
+	}
+}
+
+// URL returns a link to the cart with the given ID
+func (l *CartLinks) URL(cartID uint) string {
+	expires := l.now().Add(l.ttl).Unix()
+	query := url.Values{}
+	query.Set("cart", strconv.FormatUint(uint64(cartID), 10))
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", l.sign(cartID, expires))
+	return l.baseURL + ResumePath + "?" + query.Encode()
+}
+
+// Verify checks the query of a cart link and returns the ID of the linked cart
+func (l *CartLinks) Verify(query url.Values) (uint, error) {
+	cartID, err := strconv.ParseUint(query.Get("cart"), 10, 64)
+	if err != nil {
+		return 0, ErrInvalidLink
+	}
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		return 0, ErrInvalidLink
+	}
+	if !hmac.Equal([]byte(query.Get("signature")), []byte(l.sign(uint(cartID), expires))) {
+		return 0, ErrInvalidLink
+	}
+	if l.now().Unix() > expires {
+		return 0, ErrLinkExpired
+	}
+	return uint(cartID), nil
+}
+
+func (l *CartLinks) sign(cartID uint, expires int64) string {
+	mac := hmac.New(sha256.New, l.secret)
+	fmt.Fprintf(mac, "cart-link:%d:%d", cartID, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
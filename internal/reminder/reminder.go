@@ -0,0 +1,110 @@
+// Package reminder emails users who left items in their cart without checking out.
+package reminder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"interview/internal/mail"
+	"interview/internal/repo"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// batchSize is the largest number of reminders sent by one run
+const batchSize = 100
+
+var emailTemplate = template.Must(template.New("reminder").Parse(`Hello {{ .Name }},
+
+you left these items in your cart:
+{{ range .Items }}
+  {{ .Quantity }} x {{ .ProductName }}
+{{- end }}
+
+Total: {{ printf "%.2f" .Total }}
+
+Pick up where you left off: {{ .Link }}
+`))
+
+// Sender sends reminders for carts of identified users that have been idle for a while
+type Sender struct {
+	repo   *repo.Repository
+	mailer mail.Mailer
+	links  *CartLinks
+	idle   time.Duration
+}
+
+// NewSender creates a Sender reminding users of carts idle for longer than idle
+func NewSender(r *repo.Repository, mailer mail.Mailer, links *CartLinks, idle time.Duration) *Sender {
+	return &Sender{
+		repo:   r,
+		mailer: mailer,
+		links:  links,
+		idle:   idle,
+	}
+}
+
+// Run sends a reminder for every abandoned cart not reminded of yet and returns how many were sent.
+// Carts whose email fails are retried on the next run.
+func (s *Sender) Run(ctx context.Context) (int, error) {
+	carts, err := s.repo.ListAbandonedCarts(time.Now().Add(-s.idle), batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var sent int
+	var errs []error
+	for _, abandoned := range carts {
+		if err := ctx.Err(); err != nil {
+			return sent, err
+		}
+
+		reminder, claimed, err := s.repo.ClaimReminder(abandoned.Cart, abandoned.User.Email)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		msg, err := s.message(abandoned)
+		if err == nil {
+			err = s.mailer.Send(ctx, msg)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cart %d: %w", abandoned.Cart.ID, err))
+			if err := s.repo.ReleaseReminder(reminder); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		sent++
+	}
+	return sent, errors.Join(errs...)
+}
+
+func (s *Sender) message(abandoned repo.AbandonedCart) (mail.Message, error) {
+	name := abandoned.User.Name
+	if name == "" {
+		name = "there"
+	}
+
+	var body strings.Builder
+	err := emailTemplate.Execute(&body, map[string]interface{}{
+		"Name":  name,
+		"Items": abandoned.Cart.CartItems,
+		"Total": abandoned.Cart.Total,
+		"Link":  s.links.URL(abandoned.Cart.ID),
+	})
+	if err != nil {
+		return mail.Message{}, fmt.Errorf("failed to render reminder: %w", err)
+	}
+
+	return mail.Message{
+		To:      abandoned.User.Email,
+		Subject: "You left something in your cart",
+		Body:    body.String(),
+	}, nil
+}
@@ -0,0 +1,134 @@
+package reminder_test
+
+import (
+	"context"
+	"errors"
+	cartpkg "interview/internal/cart"
+	"interview/internal/mail"
+	"interview/internal/reminder"
+	"interview/internal/repo"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type fakeMailer struct {
+	sent []mail.Message
+	err  error
+}
+
+func (m *fakeMailer) Send(_ context.Context, msg mail.Message) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func TestCartLinks(t *testing.T) {
+	links := reminder.NewCartLinks("https://shop.example.com/", []byte("secret"), time.Hour)
+
+	parse := func(t *testing.T, link string) url.Values {
+		u, err := url.Parse(link)
+		require.NoError(t, err)
+		assert.Equal(t, "https://shop.example.com"+reminder.ResumePath, u.Scheme+"://"+u.Host+u.Path)
+		return u.Query()
+	}
+
+	t.Run("Valid Link", func(t *testing.T) {
+		cartID, err := links.Verify(parse(t, links.URL(42)))
+		require.NoError(t, err)
+		assert.Equal(t, uint(42), cartID)
+	})
+
+	t.Run("Tampered Cart", func(t *testing.T) {
+		query := parse(t, links.URL(42))
+		query.Set("cart", "43")
+		_, err := links.Verify(query)
+		assert.ErrorIs(t, err, reminder.ErrInvalidLink)
+	})
+
+	t.Run("Other Secret", func(t *testing.T) {
+		other := reminder.NewCartLinks("https://shop.example.com", []byte("other"), time.Hour)
+		_, err := other.Verify(parse(t, links.URL(42)))
+		assert.ErrorIs(t, err, reminder.ErrInvalidLink)
+	})
+
+	t.Run("Expired Link", func(t *testing.T) {
+		expired := reminder.NewCartLinks("https://shop.example.com", []byte("secret"), -time.Minute)
+		_, err := expired.Verify(parse(t, expired.URL(42)))
+		assert.ErrorIs(t, err, reminder.ErrLinkExpired)
+	})
+}
+
+func TestSender(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, repo.Migrate(db))
+	cartRepo := repo.NewRepository(db)
+
+	user, err := cartRepo.FindOrCreateUser("github", "1", "jane@example.com", "Jane")
+	require.NoError(t, err)
+	c, err := cartRepo.GetOrCreateCart("session", cartpkg.DefaultName)
+	require.NoError(t, err)
+	require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 2, 10.0))
+	require.NoError(t, cartRepo.AssignCartToUser("session", user.ID))
+	require.NoError(t, db.Model(&cartpkg.Cart{}).Where("id = ?", c.ID).
+		UpdateColumn("updated_at", time.Now().Add(-48*time.Hour)).Error)
+
+	links := reminder.NewCartLinks("https://shop.example.com", []byte("secret"), time.Hour)
+
+	t.Run("Failed Emails Are Retried", func(t *testing.T) {
+		mailer := &fakeMailer{err: errors.New("connection refused")}
+		sent, err := reminder.NewSender(cartRepo, mailer, links, 24*time.Hour).Run(context.Background())
+		require.Error(t, err)
+		assert.Equal(t, 0, sent)
+
+		reminders, err := cartRepo.ListReminders(c.ID)
+		require.NoError(t, err)
+		assert.Empty(t, reminders)
+	})
+
+	t.Run("Sends One Reminder Per Idle Cart", func(t *testing.T) {
+		mailer := &fakeMailer{}
+		sender := reminder.NewSender(cartRepo, mailer, links, 24*time.Hour)
+
+		sent, err := sender.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, sent)
+		require.Len(t, mailer.sent, 1)
+		msg := mailer.sent[0]
+		assert.Equal(t, "jane@example.com", msg.To)
+		assert.Contains(t, msg.Body, "Hello Jane")
+		assert.Contains(t, msg.Body, "2 x shoe")
+		assert.Contains(t, msg.Body, "Total: 20.00")
+		assert.Contains(t, msg.Body, "https://shop.example.com"+reminder.ResumePath+"?")
+
+		link := msg.Body[strings.Index(msg.Body, "https://"):]
+		u, err := url.Parse(strings.TrimSpace(link))
+		require.NoError(t, err)
+		cartID, err := links.Verify(u.Query())
+		require.NoError(t, err)
+		assert.Equal(t, c.ID, cartID)
+
+		sent, err = sender.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 0, sent)
+		assert.Len(t, mailer.sent, 1)
+	})
+
+	t.Run("Ignores Carts Not Idle Long Enough", func(t *testing.T) {
+		mailer := &fakeMailer{}
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "bag", 1, 30.0))
+
+		sent, err := reminder.NewSender(cartRepo, mailer, links, 24*time.Hour).Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 0, sent)
+	})
+}
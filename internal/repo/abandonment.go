@@ -0,0 +1,180 @@
+package repo
+
+import (
+	"fmt"
+	cartpkg "interview/internal/cart"
+	"time"
+)
+
+type (
+	// CartSignals are the abandonment signals of an open cart
+	CartSignals struct {
+		CartID  uint
+		Signals cartpkg.AbandonmentSignals
+	}
+
+	// SegmentCart is an open cart of an abandonment segment, with the email address of its user, empty
+	// for anonymous carts
+	SegmentCart struct {
+		Cart  cartpkg.Cart
+		Email string
+	}
+
+	// SegmentStats counts the open carts of an abandonment segment and sums their totals
+	SegmentStats struct {
+		Segment string
+		Carts   int64
+		Value   float64
+	}
+)
+
+// ListCartSignals returns the abandonment signals of up to limit open carts with items, ordered by ID,
+// starting after the cart with ID afterID
+func (r *Repository) ListCartSignals(afterID uint, limit int, now time.Time) ([]CartSignals, error) {
+	var carts []cartpkg.Cart
+	err := r.db.Where("status = ? AND id > ?", cartpkg.StatusOpen, afterID).
+		Where("EXISTS (SELECT 1 FROM cart_items WHERE cart_items.cart_id = carts.id AND cart_items.deleted_at IS NULL)").
+		Order("id").
+		Limit(limit).
+		Find(&carts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list open carts: %w", err)
+	}
+	if len(carts) == 0 {
+		return nil, nil
+	}
+
+	cartIDs := make([]uint, 0, len(carts))
+	var userIDs []uint
+	var sessionIDs []string
+	for _, c := range carts {
+		cartIDs = append(cartIDs, c.ID)
+		if c.UserID != nil {
+			userIDs = append(userIDs, *c.UserID)
+		} else {
+			sessionIDs = append(sessionIDs, c.SessionID)
+		}
+	}
+
+	// Orders of logged-in customers are counted across their sessions, those of anonymous ones per session
+	type count struct {
+		Owner string
+		N     int
+	}
+	var userOrders, sessionOrders, reminders []count
+	if len(userIDs) > 0 {
+		err := r.db.Model(&cartpkg.Cart{}).Select("user_id AS owner, COUNT(*) AS n").
+			Where("status = ? AND user_id IN ?", cartpkg.StatusClosed, userIDs).
+			Group("user_id").Scan(&userOrders).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to count prior orders: %w", err)
+		}
+	}
+	if len(sessionIDs) > 0 {
+		err := r.db.Model(&cartpkg.Cart{}).Select("session_id AS owner, COUNT(*) AS n").
+			Where("status = ? AND user_id IS NULL AND session_id IN ?", cartpkg.StatusClosed, sessionIDs).
+			Group("session_id").Scan(&sessionOrders).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to count prior orders: %w", err)
+		}
+	}
+	err = r.db.Model(&cartpkg.Reminder{}).Select("cart_id AS owner, COUNT(*) AS n").
+		Where("cart_id IN ?", cartIDs).
+		Group("cart_id").Scan(&reminders).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count reminders: %w", err)
+	}
+	counts := func(rows []count) map[string]int {
+		m := make(map[string]int, len(rows))
+		for _, row := range rows {
+			m[row.Owner] = row.N
+		}
+		return m
+	}
+	byUser, bySession, byCart := counts(userOrders), counts(sessionOrders), counts(reminders)
+
+	signals := make([]CartSignals, 0, len(carts))
+	for _, c := range carts {
+		s := cartpkg.AbandonmentSignals{
+			IdleFor:   now.Sub(c.UpdatedAt),
+			Total:     c.Total,
+			Anonymous: c.UserID == nil,
+			Reminders: byCart[fmt.Sprint(c.ID)],
+		}
+		if c.UserID != nil {
+			s.PriorOrders = byUser[fmt.Sprint(*c.UserID)]
+		} else {
+			s.PriorOrders = bySession[c.SessionID]
+		}
+		signals = append(signals, CartSignals{CartID: c.ID, Signals: s})
+	}
+	return signals, nil
+}
+
+// SetAbandonmentScore stores the abandonment score of an open cart and its segment. The cart's version
+// and update time are left alone: scoring isn't a change of the cart.
+func (r *Repository) SetAbandonmentScore(cartID uint, score int, at time.Time) error {
+	err := r.db.Model(&cartpkg.Cart{}).
+		Where("id = ? AND status = ?", cartID, cartpkg.StatusOpen).
+		UpdateColumns(map[string]interface{}{
+			"abandonment_score":   score,
+			"abandonment_segment": cartpkg.AbandonmentSegment(score),
+			"scored_at":           at,
+		}).Error
+	if err != nil {
+		return fmt.Errorf("failed to store abandonment score: %w", err)
+	}
+	return nil
+}
+
+// ClearAbandonmentScores takes the open carts scored before the time, e.g. those emptied since, out of
+// their segments
+func (r *Repository) ClearAbandonmentScores(scoredBefore time.Time) error {
+	err := r.db.Model(&cartpkg.Cart{}).
+		Where("status = ? AND scored_at < ?", cartpkg.StatusOpen, scoredBefore).
+		UpdateColumns(map[string]interface{}{"abandonment_score": 0, "abandonment_segment": "", "scored_at": nil}).Error
+	if err != nil {
+		return fmt.Errorf("failed to clear abandonment scores: %w", err)
+	}
+	return nil
+}
+
+// ListSegmentCarts returns up to limit open carts of the abandonment segment, ordered by ID, starting
+// after the cart with ID afterID
+func (r *Repository) ListSegmentCarts(segment string, afterID uint, limit int) ([]SegmentCart, error) {
+	var rows []struct {
+		cartpkg.Cart
+		Email string
+	}
+	err := r.reader().Table("carts").
+		Select("carts.*, COALESCE(users.email, '') AS email").
+		Joins("LEFT JOIN users ON users.id = carts.user_id AND users.deleted_at IS NULL").
+		Where("carts.deleted_at IS NULL AND carts.status = ? AND carts.abandonment_segment = ? AND carts.id > ?",
+			cartpkg.StatusOpen, segment, afterID).
+		Order("carts.id").
+		Limit(limit).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list segment carts: %w", err)
+	}
+	carts := make([]SegmentCart, len(rows))
+	for i, row := range rows {
+		carts[i] = SegmentCart{Cart: row.Cart, Email: row.Email}
+	}
+	return carts, nil
+}
+
+// ListSegmentStats returns the number and value of the open carts of each abandonment segment, leaving
+// out segments without carts and carts that weren't scored yet
+func (r *Repository) ListSegmentStats() ([]SegmentStats, error) {
+	var stats []SegmentStats
+	err := r.reader().Model(&cartpkg.Cart{}).
+		Select("abandonment_segment AS segment, COUNT(*) AS carts, COALESCE(SUM(total), 0) AS value").
+		Where("status = ? AND abandonment_segment <> ''", cartpkg.StatusOpen).
+		Group("abandonment_segment").
+		Scan(&stats).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count segment carts: %w", err)
+	}
+	return stats, nil
+}
@@ -0,0 +1,75 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"interview/internal/address"
+
+	"gorm.io/gorm"
+)
+
+// ErrAddressNotFound is returned when the address doesn't exist or belongs to someone else
+var ErrAddressNotFound = errors.New("address not found")
+
+// addressOwner scopes address queries to the address book of the user, or of the session when no
+// user is logged in
+func addressOwner(db *gorm.DB, userID uint, sessionID string) *gorm.DB {
+	if userID != 0 {
+		return db.Where("user_id = ?", userID)
+	}
+	return db.Where("session_id = ? AND user_id IS NULL", sessionID)
+}
+
+// CreateAddress normalizes, validates and saves the address, returning address.ValidationErrors
+// when it is invalid
+func (r *Repository) CreateAddress(a *address.Address) error {
+	a.Normalize()
+	if err := a.Validate(); err != nil {
+		return err
+	}
+	if err := r.db.Create(a).Error; err != nil {
+		return fmt.Errorf("failed to create address: %w", err)
+	}
+	return nil
+}
+
+// ListAddresses returns the address book of the user, or of the session for anonymous users, oldest first
+func (r *Repository) ListAddresses(userID uint, sessionID string) ([]address.Address, error) {
+	var addresses []address.Address
+	if err := addressOwner(r.db, userID, sessionID).Order("id").Find(&addresses).Error; err != nil {
+		return nil, fmt.Errorf("failed to list addresses: %w", err)
+	}
+	return addresses, nil
+}
+
+// GetAddress returns an address of the address book of the user or session
+func (r *Repository) GetAddress(userID uint, sessionID string, id uint) (*address.Address, error) {
+	var a address.Address
+	err := addressOwner(r.db, userID, sessionID).First(&a, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAddressNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get address: %w", err)
+	}
+	return &a, nil
+}
+
+// DeleteAddress removes an address from the address book of the user or session
+func (r *Repository) DeleteAddress(userID uint, sessionID string, id uint) error {
+	result := addressOwner(r.db, userID, sessionID).Delete(&address.Address{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete address: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrAddressNotFound
+	}
+	return nil
+}
+
+// AssignAddressesToUser moves the addresses saved by an anonymous session to the address book of the
+// user who logged in
+func (r *Repository) AssignAddressesToUser(sessionID string, userID uint) error {
+	return r.db.Model(&address.Address{}).
+		Where("session_id = ? AND user_id IS NULL", sessionID).
+		Update("user_id", userID).Error
+}
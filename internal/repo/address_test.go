@@ -0,0 +1,58 @@
+package repo_test
+
+import (
+	"interview/internal/address"
+	"interview/internal/repo"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddressBook(t *testing.T) {
+	db := setupTestDB(t)
+	cartRepo := repo.NewRepository(db)
+
+	newAddress := func(sessionID string) *address.Address {
+		return &address.Address{SessionID: sessionID, Name: "Jane Doe", Line1: "1 Main St", City: "Springfield",
+			PostalCode: "12345", Country: "us"}
+	}
+
+	a := newAddress("session-1")
+	require.NoError(t, cartRepo.CreateAddress(a))
+	assert.Equal(t, "US", a.Country)
+	require.NoError(t, cartRepo.CreateAddress(newAddress("session-2")))
+
+	invalid := newAddress("session-1")
+	invalid.PostalCode = "ABC"
+	var errs address.ValidationErrors
+	assert.ErrorAs(t, cartRepo.CreateAddress(invalid), &errs)
+
+	t.Run("sessions only see their addresses", func(t *testing.T) {
+		addresses, err := cartRepo.ListAddresses(0, "session-1")
+		require.NoError(t, err)
+		require.Len(t, addresses, 1)
+		assert.Equal(t, a.ID, addresses[0].ID)
+
+		_, err = cartRepo.GetAddress(0, "session-2", a.ID)
+		assert.ErrorIs(t, err, repo.ErrAddressNotFound)
+		assert.ErrorIs(t, cartRepo.DeleteAddress(0, "session-2", a.ID), repo.ErrAddressNotFound)
+	})
+
+	t.Run("logging in moves addresses to the user", func(t *testing.T) {
+		user, err := cartRepo.FindOrCreateUser("github", "1", "jane@example.com", "Jane")
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AssignAddressesToUser("session-1", user.ID))
+
+		addresses, err := cartRepo.ListAddresses(user.ID, "another-session")
+		require.NoError(t, err)
+		require.Len(t, addresses, 1)
+		addresses, err = cartRepo.ListAddresses(0, "session-1")
+		require.NoError(t, err)
+		assert.Empty(t, addresses)
+
+		require.NoError(t, cartRepo.DeleteAddress(user.ID, "", a.ID))
+		_, err = cartRepo.GetAddress(user.ID, "", a.ID)
+		assert.ErrorIs(t, err, repo.ErrAddressNotFound)
+	})
+}
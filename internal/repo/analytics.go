@@ -0,0 +1,17 @@
+package repo
+
+import (
+	"fmt"
+	"interview/internal/analytics"
+)
+
+// analyticsInsertBatch is how many events are inserted per statement
+const analyticsInsertBatch = 100
+
+// SaveAnalyticsEvents inserts the events, implementing analytics.Store
+func (r *Repository) SaveAnalyticsEvents(events []analytics.Event) error {
+	if err := r.db.CreateInBatches(events, analyticsInsertBatch).Error; err != nil {
+		return fmt.Errorf("failed to save analytics events: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"interview/internal/audit"
+	cartpkg "interview/internal/cart"
+
+	"gorm.io/gorm"
+)
+
+// RecordAudit appends the entry to the audit log
+func (r *Repository) RecordAudit(entry *audit.Entry) error {
+	if err := r.db.Create(entry).Error; err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+	return nil
+}
+
+// ListAuditLog returns up to limit entries of the audit log, newest first, only those about the session
+// if sessionID isn't empty
+func (r *Repository) ListAuditLog(sessionID string, limit int) ([]audit.Entry, error) {
+	query := r.reader().Order("id DESC").Limit(limit)
+	if sessionID != "" {
+		query = query.Where("session_id = ?", sessionID)
+	}
+	var entries []audit.Entry
+	if err := query.Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("failed to list audit log: %w", err)
+	}
+	return entries, nil
+}
+
+// FindCustomerCart returns the open cart of a customer changed last, looked up by the session when
+// sessionID isn't empty and by the user otherwise. It fails with ErrCartNotFound when the customer has
+// no open cart.
+func (r *Repository) FindCustomerCart(sessionID string, userID uint) (*cartpkg.Cart, error) {
+	query := r.db.Where("status = ?", cartpkg.StatusOpen).Order("updated_at DESC, id DESC")
+	if sessionID != "" {
+		query = query.Where("session_id = ?", sessionID)
+	} else {
+		query = query.Where("user_id = ?", userID)
+	}
+	var c cartpkg.Cart
+	err := query.First(&c).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, cartpkg.ErrCartNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to find cart: %w", err)
+	}
+	return &c, nil
+}
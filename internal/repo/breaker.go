@@ -0,0 +1,121 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"expvar"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrCircuitOpen is returned instead of running queries while the database is considered down
+var ErrCircuitOpen = errors.New("database is unavailable")
+
+// breakerTrips counts how often the circuit breaker opened, shown at /admin/metrics
+var breakerTrips = expvar.NewInt("db_breaker_trips")
+
+// Breaker is a circuit breaker around the queries of a database. After threshold queries in a row failed
+// because the database didn't answer, timing out or losing connections, it opens: queries fail right away
+// with ErrCircuitOpen for cooldown instead of tying up a goroutine and a connection each. Queries are then
+// let through again, reopening the breaker on the first failure and closing it on the first success.
+// Errors of the queries themselves, e.g. missing records or constraint violations, are successes.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+var _ gorm.Plugin = (*Breaker)(nil)
+
+// NewBreaker creates a Breaker opening for cooldown after threshold failed queries in a row, never when
+// threshold is 0.
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// SetClock replaces the clock the breaker cools down by, for tests.
+func (b *Breaker) SetClock(now func() time.Time) {
+	b.now = now
+}
+
+// Name implements gorm.Plugin.
+func (b *Breaker) Name() string {
+	return "circuit_breaker"
+}
+
+// Initialize implements gorm.Plugin, checking the breaker before and recording the outcome after the
+// queries of every kind.
+func (b *Breaker) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	// The check and record registrations of each kind of query
+	registrations := map[string][2]func(string, func(*gorm.DB)) error{
+		"create": {callbacks.Create().Before("*").Register, callbacks.Create().After("*").Register},
+		"query":  {callbacks.Query().Before("*").Register, callbacks.Query().After("*").Register},
+		"update": {callbacks.Update().Before("*").Register, callbacks.Update().After("*").Register},
+		"delete": {callbacks.Delete().Before("*").Register, callbacks.Delete().After("*").Register},
+		"row":    {callbacks.Row().Before("*").Register, callbacks.Row().After("*").Register},
+		"raw":    {callbacks.Raw().Before("*").Register, callbacks.Raw().After("*").Register},
+	}
+	for name, register := range registrations {
+		if err := register[0]("breaker:check_"+name, b.check); err != nil {
+			return err
+		}
+		if err := register[1]("breaker:record_"+name, b.record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RetryAfter returns how long the breaker stays open, 0 when it is closed.
+func (b *Breaker) RetryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return max(b.openUntil.Sub(b.now()), 0)
+}
+
+// check fails the query with ErrCircuitOpen while the breaker is open
+func (b *Breaker) check(db *gorm.DB) {
+	if b.RetryAfter() > 0 {
+		_ = db.AddError(ErrCircuitOpen)
+	}
+}
+
+// record counts the failures in a row, opening the breaker once they reach the threshold
+func (b *Breaker) record(db *gorm.DB) {
+	if b.threshold == 0 || errors.Is(db.Error, ErrCircuitOpen) {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !isOutage(db.Error) {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold && !b.now().Before(b.openUntil) {
+		b.openUntil = b.now().Add(b.cooldown)
+		breakerTrips.Add(1)
+		log.Printf("Database circuit breaker opened for %s after %d failed queries: %v", b.cooldown, b.failures, db.Error)
+	}
+}
+
+// isOutage reports whether err means the database didn't answer, rather than rejecting the query
+func isOutage(err error) bool {
+	if err == nil {
+		return false
+	}
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, sql.ErrConnDone) || errors.As(err, &netErr)
+}
@@ -0,0 +1,107 @@
+package repo_test
+
+import (
+	"context"
+	"expvar"
+	productpkg "interview/internal/product"
+	"interview/internal/repo"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestBreaker(t *testing.T) {
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	setup := func(t *testing.T, threshold int) (*gorm.DB, *repo.Breaker, *time.Time) {
+		t.Helper()
+		db := setupTestDB(t)
+		now := time.Now()
+		breaker := repo.NewBreaker(threshold, time.Minute)
+		breaker.SetClock(func() time.Time { return now })
+		require.NoError(t, db.Use(breaker))
+		return db, breaker, &now
+	}
+	// timeout runs a query the database doesn't answer in time
+	timeout := func(t *testing.T, db *gorm.DB) {
+		t.Helper()
+		var count int64
+		err := db.WithContext(expired).Model(&productpkg.Product{}).Count(&count).Error
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	}
+	query := func(db *gorm.DB) error {
+		var count int64
+		return db.Model(&productpkg.Product{}).Count(&count).Error
+	}
+
+	t.Run("opens after failures in a row", func(t *testing.T) {
+		db, breaker, _ := setup(t, 2)
+		trips := expvar.Get("db_breaker_trips").(*expvar.Int).Value()
+
+		timeout(t, db)
+		assert.Zero(t, breaker.RetryAfter())
+		timeout(t, db)
+		assert.Equal(t, time.Minute, breaker.RetryAfter())
+		assert.ErrorIs(t, query(db), repo.ErrCircuitOpen)
+		assert.Equal(t, trips+1, expvar.Get("db_breaker_trips").(*expvar.Int).Value())
+
+		_, err := repo.NewRepository(db).GetProduct(1)
+		assert.ErrorIs(t, err, repo.ErrCircuitOpen, "repository queries are stopped too")
+	})
+
+	t.Run("successes reset the failures", func(t *testing.T) {
+		db, breaker, _ := setup(t, 2)
+		timeout(t, db)
+		require.NoError(t, query(db))
+		timeout(t, db)
+		assert.Zero(t, breaker.RetryAfter())
+	})
+
+	t.Run("query errors are not failures", func(t *testing.T) {
+		db, breaker, _ := setup(t, 1)
+		_, err := repo.NewRepository(db).GetProduct(404)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		assert.Error(t, db.Exec("SELECT * FROM missing").Error)
+		assert.Zero(t, breaker.RetryAfter())
+	})
+
+	t.Run("closes on the first success after the cooldown", func(t *testing.T) {
+		db, breaker, now := setup(t, 2)
+		timeout(t, db)
+		timeout(t, db)
+		*now = now.Add(time.Minute)
+		assert.Zero(t, breaker.RetryAfter())
+		require.NoError(t, query(db))
+
+		timeout(t, db)
+		assert.Zero(t, breaker.RetryAfter(), "the failures start over")
+	})
+
+	t.Run("reopens on the first failure after the cooldown", func(t *testing.T) {
+		db, breaker, now := setup(t, 2)
+		timeout(t, db)
+		timeout(t, db)
+		*now = now.Add(time.Minute)
+		timeout(t, db)
+		assert.Equal(t, time.Minute, breaker.RetryAfter())
+	})
+
+	t.Run("never opens with a zero threshold", func(t *testing.T) {
+		db, breaker, _ := setup(t, 0)
+		for i := 0; i < 10; i++ {
+			timeout(t, db)
+		}
+		assert.Zero(t, breaker.RetryAfter())
+		assert.NoError(t, query(db))
+	})
+
+	t.Run("repository queries run with the context", func(t *testing.T) {
+		db := setupTestDB(t)
+		_, err := repo.NewRepository(db).WithContext(expired).GetProduct(1)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
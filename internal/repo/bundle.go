@@ -0,0 +1,199 @@
+package repo
+
+import (
+	"fmt"
+	cartpkg "interview/internal/cart"
+	productpkg "interview/internal/product"
+
+	"gorm.io/gorm"
+)
+
+// BundlePart is a component of a bundle: Quantity units of Product in each unit of the bundle
+type BundlePart struct {
+	Product  productpkg.Product
+	Quantity int
+}
+
+// ListBundleComponents returns the components of the bundle ordered by product name, none for products
+// that aren't bundles. It fails with gorm.ErrRecordNotFound for unknown products.
+func (r *Repository) ListBundleComponents(bundleID uint) ([]BundlePart, error) {
+	var bundle productpkg.Product
+	if err := r.db.Select("id").First(&bundle, bundleID).Error; err != nil {
+		return nil, fmt.Errorf("failed to get product: %w", err)
+	}
+	return bundleParts(r.db, bundle.ID)
+}
+
+// SetBundleComponents makes the product a bundle of the components, replacing those it had; no
+// components make it a regular product again. It fails with productpkg.ErrInvalidBundle for invalid
+// components, including products that are bundles themselves, and with gorm.ErrRecordNotFound for
+// unknown products. Bundles already in carts keep the items they were expanded to.
+func (r *Repository) SetBundleComponents(bundleID uint, components []productpkg.BundleComponent) error {
+	if err := productpkg.ValidateComponents(bundleID, components); err != nil {
+		return err
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var bundle productpkg.Product
+		if err := tx.Select("id").First(&bundle, bundleID).Error; err != nil {
+			return fmt.Errorf("failed to get product: %w", err)
+		}
+
+		if len(components) > 0 {
+			ids := make([]uint, len(components))
+			for i, component := range components {
+				ids[i] = component.ProductID
+			}
+			// Products are looked up through the tenant scope, so those of other shops aren't found
+			var found int64
+			if err := tx.Model(&productpkg.Product{}).Where("id IN ?", ids).Count(&found).Error; err != nil {
+				return fmt.Errorf("failed to get components: %w", err)
+			}
+			// Bundles don't nest: the bundle can't be a component of another one, nor its components bundles
+			var nested int64
+			err := tx.Model(&productpkg.BundleComponent{}).
+				Where("product_id = ? OR bundle_id IN ?", bundle.ID, ids).
+				Count(&nested).Error
+			if err != nil {
+				return fmt.Errorf("failed to check bundles: %w", err)
+			}
+			if found != int64(len(ids)) || nested > 0 {
+				return productpkg.ErrInvalidBundle
+			}
+		}
+
+		if err := tx.Where("bundle_id = ?", bundle.ID).Delete(&productpkg.BundleComponent{}).Error; err != nil {
+			return fmt.Errorf("failed to remove bundle components: %w", err)
+		}
+		for i := range components {
+			components[i].ID = 0
+			components[i].BundleID = bundle.ID
+		}
+		if len(components) > 0 {
+			if err := tx.Create(&components).Error; err != nil {
+				return fmt.Errorf("failed to store bundle components: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// AddBundle adds quantity of the bundle to the cart at price each, expanded to one item per component.
+// The items are linked by their BundleGroup and priced at their share of the price, see
+// productpkg.SplitBundlePrice; they are never merged with other items of the same products. It fails
+// with ErrInvalidQuantity or ErrInvalidPrice for quantities below 1 and negative prices, with
+// ErrQuantityTooLarge for items that would hold more than MaxQuantity units, with
+// gorm.ErrRecordNotFound for unknown products and with productpkg.ErrNotBundle for products that
+// aren't bundles.
+func (r *Repository) AddBundle(cartID uint, bundleID uint, quantity int, price float64) error {
+	if err := (cartpkg.CartItem{Quantity: quantity, Price: price}).Validate(); err != nil {
+		return err
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		cart, err := openCart(tx, cartID)
+		if err != nil {
+			return err
+		}
+		var bundle productpkg.Product
+		if err := tx.First(&bundle, bundleID).Error; err != nil {
+			return fmt.Errorf("failed to get product: %w", err)
+		}
+		parts, err := bundleParts(tx, bundle.ID)
+		if err != nil {
+			return err
+		}
+		if len(parts) == 0 {
+			return productpkg.ErrNotBundle
+		}
+
+		regular := make([]float64, len(parts))
+		units := make([]int, len(parts))
+		for i, part := range parts {
+			regular[i], units[i] = part.Product.Price, part.Quantity
+		}
+		prices := productpkg.SplitBundlePrice(price, regular, units)
+		position, err := nextPosition(tx, cartID)
+		if err != nil {
+			return err
+		}
+
+		var group uint
+		for i, part := range parts {
+			item := cartpkg.CartItem{
+				CartID:         cartID,
+				ProductName:    part.Product.Name,
+				Quantity:       part.Quantity * quantity,
+				Price:          prices[i],
+				BundleGroup:    group,
+				BundleName:     bundle.Name,
+				BundleQuantity: quantity,
+				Position:       position,
+			}
+			if err := tx.Create(&item).Error; err != nil {
+				return fmt.Errorf("failed to create item: %w", err)
+			}
+			if group == 0 {
+				group = item.ID
+				if err := tx.Model(&item).Update("bundle_group", group).Error; err != nil {
+					return fmt.Errorf("failed to group bundle: %w", err)
+				}
+			}
+		}
+
+		err = recordChange(tx, cartpkg.Change{CartID: cartID, Action: cartpkg.ChangeAdded, Product: bundle.Name, Quantity: quantity, ItemID: group})
+		if err != nil {
+			return err
+		}
+		return r.updateCartTotal(tx, cart)
+	})
+}
+
+// bundleParts returns the components of the bundle with the given ID ordered by product name. Components
+// whose product was deleted are left out.
+func bundleParts(db *gorm.DB, bundleID uint) ([]BundlePart, error) {
+	var components []productpkg.BundleComponent
+	if err := db.Where("bundle_id = ?", bundleID).Find(&components).Error; err != nil {
+		return nil, fmt.Errorf("failed to get bundle components: %w", err)
+	}
+	if len(components) == 0 {
+		return nil, nil
+	}
+	ids := make([]uint, len(components))
+	quantities := make(map[uint]int, len(components))
+	for i, component := range components {
+		ids[i] = component.ProductID
+		quantities[component.ProductID] = component.Quantity
+	}
+
+	var products []productpkg.Product
+	if err := db.Where("id IN ?", ids).Order("name").Find(&products).Error; err != nil {
+		return nil, fmt.Errorf("failed to get components: %w", err)
+	}
+	parts := make([]BundlePart, len(products))
+	for i, p := range products {
+		parts[i] = BundlePart{Product: p, Quantity: quantities[p.ID]}
+	}
+	return parts, nil
+}
+
+// deleteItem removes the item from its cart together with the other items of its bundle
+func deleteItem(db *gorm.DB, item cartpkg.CartItem) error {
+	if item.BundleGroup != 0 {
+		db = db.Where("cart_id = ? AND bundle_group = ?", item.CartID, item.BundleGroup)
+	} else {
+		db = db.Where("id = ?", item.ID)
+	}
+	if err := db.Delete(&cartpkg.CartItem{}).Error; err != nil {
+		return fmt.Errorf("failed to remove item: %w", err)
+	}
+	return nil
+}
+
+// changedUnits returns the product and quantity recorded in the history of the cart when the item is
+// removed or restored: those of its bundle for items of one, its own otherwise
+func changedUnits(item cartpkg.CartItem) (string, int) {
+	if item.BundleGroup != 0 {
+		return item.BundleName, item.BundleQuantity
+	}
+	return item.ProductName, item.Quantity
+}
@@ -0,0 +1,156 @@
+package repo_test
+
+import (
+	"interview/internal/cart"
+	"interview/internal/product"
+	"interview/internal/repo"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestBundles(t *testing.T) {
+	// setup sells a kit of 2 shoes at 10 and a watch at 30 for 36
+	setup := func(t *testing.T) (*repo.Repository, *product.Product, *cart.Cart) {
+		t.Helper()
+		db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+		require.NoError(t, err)
+		require.NoError(t, repo.Migrate(db))
+		cartRepo := repo.NewRepository(db)
+		shoe, err := cartRepo.UpsertProduct("shoe", 10)
+		require.NoError(t, err)
+		watch, err := cartRepo.UpsertProduct("watch", 30)
+		require.NoError(t, err)
+		kit, err := cartRepo.UpsertProduct("kit", 36)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.SetBundleComponents(kit.ID, []product.BundleComponent{
+			{ProductID: watch.ID, Quantity: 1}, {ProductID: shoe.ID, Quantity: 2},
+		}))
+		c, err := cartRepo.GetOrCreateCart("session", cart.DefaultName)
+		require.NoError(t, err)
+		return cartRepo, kit, c
+	}
+	items := func(t *testing.T, cartRepo *repo.Repository, cartID uint) []cart.CartItem {
+		t.Helper()
+		c, err := cartRepo.GetCart(cartID)
+		require.NoError(t, err)
+		return c.CartItems
+	}
+
+	t.Run("rejects invalid components", func(t *testing.T) {
+		cartRepo, kit, _ := setup(t)
+		parts, err := cartRepo.ListBundleComponents(kit.ID)
+		require.NoError(t, err)
+		require.Len(t, parts, 2)
+		shoe, watch := parts[0].Product, parts[1].Product
+		other, err := cartRepo.UpsertProduct("other kit", 20)
+		require.NoError(t, err)
+
+		for _, components := range [][]product.BundleComponent{
+			{{ProductID: shoe.ID, Quantity: 0}},
+			{{ProductID: shoe.ID, Quantity: 1}, {ProductID: shoe.ID, Quantity: 2}},
+			{{ProductID: other.ID, Quantity: 1}},
+			{{ProductID: 9999, Quantity: 1}},
+			{{ProductID: kit.ID, Quantity: 1}},
+		} {
+			assert.ErrorIs(t, cartRepo.SetBundleComponents(other.ID, components), product.ErrInvalidBundle)
+		}
+		assert.ErrorIs(t, cartRepo.SetBundleComponents(shoe.ID, []product.BundleComponent{{ProductID: watch.ID, Quantity: 1}}),
+			product.ErrInvalidBundle, "components can't be bundles")
+		assert.ErrorIs(t, cartRepo.SetBundleComponents(9999, nil), gorm.ErrRecordNotFound)
+
+		parts, err = cartRepo.ListBundleComponents(kit.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "shoe", parts[0].Product.Name)
+		assert.Equal(t, 2, parts[0].Quantity, "unchanged")
+	})
+
+	t.Run("expands bundles at the bundle price", func(t *testing.T) {
+		cartRepo, kit, c := setup(t)
+		require.NoError(t, cartRepo.AddBundle(c.ID, kit.ID, 2, 36))
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 1, 10))
+
+		got := items(t, cartRepo, c.ID)
+		require.Len(t, got, 3)
+		assert.Equal(t, "shoe", got[0].ProductName)
+		assert.Equal(t, 4, got[0].Quantity)
+		assert.Equal(t, 7.2, got[0].Price)
+		assert.Equal(t, "watch", got[1].ProductName)
+		assert.Equal(t, 2, got[1].Quantity)
+		assert.InDelta(t, 21.6, got[1].Price, 1e-9)
+		assert.Equal(t, got[0].ID, got[0].BundleGroup)
+		assert.Equal(t, got[0].ID, got[1].BundleGroup)
+		assert.Equal(t, "kit", got[1].BundleName)
+		assert.Equal(t, 2, got[1].BundleQuantity)
+		assert.Zero(t, got[2].BundleGroup, "not merged into the bundle")
+
+		updated, err := cartRepo.GetCart(c.ID)
+		require.NoError(t, err)
+		assert.InDelta(t, 82.0, updated.Subtotal, 1e-9)
+
+		_, err = cartRepo.UpsertProduct("purse", 5)
+		require.NoError(t, err)
+		assert.ErrorIs(t, cartRepo.AddBundle(c.ID, 9999, 1, 36), gorm.ErrRecordNotFound)
+		purse, err := cartRepo.GetProductsByName([]string{"purse"})
+		require.NoError(t, err)
+		assert.ErrorIs(t, cartRepo.AddBundle(c.ID, purse["purse"].ID, 1, 5), product.ErrNotBundle)
+	})
+
+	t.Run("removes and restores bundles whole", func(t *testing.T) {
+		cartRepo, kit, c := setup(t)
+		require.NoError(t, cartRepo.AddBundle(c.ID, kit.ID, 1, 36))
+		watchItem := items(t, cartRepo, c.ID)[1]
+
+		require.NoError(t, cartRepo.RemoveCartItem(c.ID, watchItem.ID))
+		assert.Empty(t, items(t, cartRepo, c.ID))
+		changes, err := cartRepo.ListCartChanges(c.ID, 0, 1)
+		require.NoError(t, err)
+		assert.Equal(t, "kit", changes[0].Product)
+		assert.Equal(t, 1, changes[0].Quantity)
+
+		deleted, err := cartRepo.ListDeletedItems(c.ID)
+		require.NoError(t, err)
+		require.Len(t, deleted, 2)
+		_, err = cartRepo.RestoreCartItem(c.ID, deleted[1].ID)
+		require.NoError(t, err)
+		assert.Len(t, items(t, cartRepo, c.ID), 2)
+
+		_, err = cartRepo.UndoCartChange(c.ID, time.Now().Add(-time.Minute), time.Now())
+		require.NoError(t, err)
+		assert.Empty(t, items(t, cartRepo, c.ID), "undoing the restore")
+		_, err = cartRepo.UndoCartChange(c.ID, time.Now().Add(-time.Minute), time.Now())
+		require.NoError(t, err)
+		assert.Len(t, items(t, cartRepo, c.ID), 2, "undoing the removal")
+		_, err = cartRepo.UndoCartChange(c.ID, time.Now().Add(-time.Minute), time.Now())
+		require.NoError(t, err)
+		assert.Empty(t, items(t, cartRepo, c.ID), "undoing the addition")
+		deleted, err = cartRepo.ListDeletedItems(c.ID)
+		require.NoError(t, err)
+		assert.Empty(t, deleted)
+	})
+
+	t.Run("keeps the bundle price", func(t *testing.T) {
+		cartRepo, kit, c := setup(t)
+		require.NoError(t, cartRepo.AddBundle(c.ID, kit.ID, 1, 36))
+		products, err := cartRepo.GetProductsByName([]string{"shoe"})
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.SetPriceTiers(products["shoe"].ID, []product.PriceTier{{MinQuantity: 2, Price: 5}}))
+
+		changed, err := cartRepo.RefreshCartPrices(c.ID, map[string]float64{"shoe": 10, "watch": 30}, time.Now())
+		require.NoError(t, err)
+		assert.False(t, changed)
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "purse", 1, 20))
+		assert.Equal(t, 7.2, items(t, cartRepo, c.ID)[0].Price)
+
+		stale, err := cartRepo.ListStalePrices(10, time.Now())
+		require.NoError(t, err)
+		assert.Empty(t, stale)
+		drops, err := cartRepo.ListPriceDropItems("watch")
+		require.NoError(t, err)
+		assert.Empty(t, drops)
+	})
+}
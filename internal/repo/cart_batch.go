@@ -0,0 +1,45 @@
+package repo
+
+import (
+	"fmt"
+	cartpkg "interview/internal/cart"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type (
+	// CartFilter selects the carts visited by EachCartBatch. Zero values don't filter.
+	CartFilter struct {
+		// Status is cart.StatusOpen or cart.StatusClosed
+		Status string
+		// From and To bound the creation time of the carts, From inclusive and To exclusive
+		From time.Time
+		To   time.Time
+	}
+)
+
+// EachCartBatch calls fn with the carts matching the filter, with their items and discounts, in
+// batches of up to size carts ordered by ID. Only one batch is held in memory at a time, so all
+// carts can be visited. It stops at the first error returned by fn.
+func (r *Repository) EachCartBatch(filter CartFilter, size int, fn func([]cartpkg.Cart) error) error {
+	query := r.reader().Preload("CartItems", orderItems).Preload("Discounts")
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+
+	var batch []cartpkg.Cart
+	err := query.FindInBatches(&batch, size, func(*gorm.DB, int) error {
+		return fn(batch)
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to iterate carts: %w", err)
+	}
+	return nil
+}
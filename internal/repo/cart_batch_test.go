@@ -0,0 +1,69 @@
+package repo_test
+
+import (
+	cartpkg "interview/internal/cart"
+	"interview/internal/repo"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEachCartBatch(t *testing.T) {
+	db := setupTestDB(t)
+	cartRepo := repo.NewRepository(db)
+
+	for _, sessionID := range []string{"batch-1", "batch-2", "batch-3"} {
+		c, err := cartRepo.GetOrCreateCart(sessionID, cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 1, 10.0))
+	}
+	closed, err := cartRepo.GetOrCreateCart("batch-closed", cartpkg.DefaultName)
+	require.NoError(t, err)
+	require.NoError(t, cartRepo.CloseCart(closed.ID))
+	require.NoError(t, db.Model(&cartpkg.Cart{}).Where("id = ?", closed.ID).
+		UpdateColumn("created_at", time.Now().Add(-48*time.Hour)).Error)
+
+	visit := func(t *testing.T, filter repo.CartFilter) ([]string, int) {
+		var sessions []string
+		batches := 0
+		err := cartRepo.EachCartBatch(filter, 2, func(carts []cartpkg.Cart) error {
+			batches++
+			for _, c := range carts {
+				sessions = append(sessions, c.SessionID)
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		return sessions, batches
+	}
+
+	t.Run("visits all carts in batches", func(t *testing.T) {
+		sessions, batches := visit(t, repo.CartFilter{})
+		assert.Equal(t, []string{"batch-1", "batch-2", "batch-3", "batch-closed"}, sessions)
+		assert.Equal(t, 2, batches)
+	})
+
+	t.Run("filters by status", func(t *testing.T) {
+		sessions, _ := visit(t, repo.CartFilter{Status: cartpkg.StatusClosed})
+		assert.Equal(t, []string{"batch-closed"}, sessions)
+	})
+
+	t.Run("filters by creation time", func(t *testing.T) {
+		sessions, _ := visit(t, repo.CartFilter{From: time.Now().Add(-time.Hour)})
+		assert.Equal(t, []string{"batch-1", "batch-2", "batch-3"}, sessions)
+		sessions, _ = visit(t, repo.CartFilter{To: time.Now().Add(-time.Hour)})
+		assert.Equal(t, []string{"batch-closed"}, sessions)
+	})
+
+	t.Run("loads items", func(t *testing.T) {
+		err := cartRepo.EachCartBatch(repo.CartFilter{Status: cartpkg.StatusOpen}, 10, func(carts []cartpkg.Cart) error {
+			for _, c := range carts {
+				assert.Len(t, c.CartItems, 1)
+			}
+			return nil
+		})
+		require.NoError(t, err)
+	})
+}
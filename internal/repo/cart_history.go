@@ -0,0 +1,131 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	cartpkg "interview/internal/cart"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ListCartChanges returns up to limit changes of the cart, newest first, starting after the change with
+// ID beforeID, or with the most recent change when beforeID is 0
+func (r *Repository) ListCartChanges(cartID uint, beforeID uint, limit int) ([]cartpkg.Change, error) {
+	query := r.db.Where("cart_id = ?", cartID)
+	if beforeID > 0 {
+		query = query.Where("id < ?", beforeID)
+	}
+	var changes []cartpkg.Change
+	err := query.Order("id DESC").Limit(limit).Find(&changes).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cart changes: %w", err)
+	}
+	return changes, nil
+}
+
+// UndoCartChange undoes the latest change of the open cart that wasn't undone yet and returns it: added
+// and restored units are taken out again and removed items put back. The next call undoes the change
+// before, so changes are undone like a stack. It fails with ErrNothingToUndo when there is no
+// change left or it was made before since, and with ErrCannotUndo when it is a change of another kind.
+func (r *Repository) UndoCartChange(cartID uint, since, now time.Time) (*cartpkg.Change, error) {
+	var change cartpkg.Change
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		cart, err := openCart(tx, cartID)
+		if err != nil {
+			return err
+		}
+
+		if change, err = latestChange(tx, cartID, since); err != nil {
+			return err
+		}
+
+		if change.Action == cartpkg.ChangeRemoved {
+			if _, _, err := restoreItem(tx, cartID, change.ItemID); err != nil {
+				return err
+			}
+		} else if err := takeOut(tx, cartID, change); err != nil {
+			return err
+		}
+
+		change.UndoneAt = &now
+		if err := tx.Model(&change).Update("undone_at", now).Error; err != nil {
+			return fmt.Errorf("failed to mark cart change undone: %w", err)
+		}
+		return r.updateCartTotal(tx, cart)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &change, nil
+}
+
+// UndoableChange returns the change UndoCartChange would undo, failing like it does
+func (r *Repository) UndoableChange(cartID uint, since time.Time) (*cartpkg.Change, error) {
+	change, err := latestChange(r.db, cartID, since)
+	if err != nil {
+		return nil, err
+	}
+	return &change, nil
+}
+
+// latestChange finds the latest change of the cart that wasn't undone yet, see UndoCartChange
+func latestChange(tx *gorm.DB, cartID uint, since time.Time) (cartpkg.Change, error) {
+	var change cartpkg.Change
+	err := tx.Where("cart_id = ? AND undone_at IS NULL", cartID).Order("id DESC").First(&change).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return change, cartpkg.ErrNothingToUndo
+	} else if err != nil {
+		return change, fmt.Errorf("failed to find cart change: %w", err)
+	}
+	if change.CreatedAt.Before(since) {
+		return change, cartpkg.ErrNothingToUndo
+	}
+	if !change.Undoable() {
+		return change, cartpkg.ErrCannotUndo
+	}
+	return change, nil
+}
+
+// takeOut takes the units added or restored by the change out of its item again. Items left without
+// units are deleted: for good when they were added, and back among the removed items when they were
+// restored. Bundles are taken out whole.
+func takeOut(tx *gorm.DB, cartID uint, change cartpkg.Change) error {
+	var item cartpkg.CartItem
+	err := tx.Where("cart_id = ? AND id = ?", cartID, change.ItemID).First(&item).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return cartpkg.ErrItemNotFound
+	} else if err != nil {
+		return fmt.Errorf("failed to find item: %w", err)
+	}
+
+	if item.BundleGroup == 0 && item.Quantity > change.Quantity {
+		item.Quantity -= change.Quantity
+		if err := tx.Save(&item).Error; err != nil {
+			return fmt.Errorf("failed to update item: %w", err)
+		}
+		return nil
+	}
+
+	del := tx
+	if change.Action == cartpkg.ChangeAdded {
+		del = tx.Unscoped()
+	}
+	return deleteItem(del, item)
+}
+
+// recordChange adds the change to the history of its cart
+func recordChange(tx *gorm.DB, change cartpkg.Change) error {
+	if err := tx.Create(&change).Error; err != nil {
+		return fmt.Errorf("failed to record cart change: %w", err)
+	}
+	return nil
+}
+
+// deleteChanges removes the history of a cart deleted for good
+func deleteChanges(tx *gorm.DB, cartID uint) error {
+	if err := tx.Where("cart_id = ?", cartID).Delete(&cartpkg.Change{}).Error; err != nil {
+		return fmt.Errorf("failed to delete cart changes: %w", err)
+	}
+	return nil
+}
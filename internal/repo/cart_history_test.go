@@ -0,0 +1,187 @@
+package repo_test
+
+import (
+	cartpkg "interview/internal/cart"
+	"interview/internal/repo"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCartChanges(t *testing.T) {
+	db := setupTestDB(t)
+	r := repo.NewRepository(db)
+
+	actions := func(t *testing.T, cartID uint) []string {
+		t.Helper()
+		changes, err := r.ListCartChanges(cartID, 0, 100)
+		require.NoError(t, err)
+		var actions []string
+		for _, change := range changes {
+			actions = append(actions, change.Action)
+		}
+		return actions
+	}
+
+	t.Run("records the changes of the cart", func(t *testing.T) {
+		cart, err := r.GetOrCreateCart("history-session", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, r.AddCartItem(cart.ID, "shoe", 2, 10))
+		require.NoError(t, r.AddCartItem(cart.ID, "watch", 1, 40))
+		items, err := r.ListCartItems(cart.ID, 0, 10)
+		require.NoError(t, err)
+		require.NoError(t, r.RemoveCartItem(cart.ID, items[1].ID))
+		_, err = r.RestoreCartItem(cart.ID, items[1].ID)
+		require.NoError(t, err)
+		_, err = r.RefreshCartPrices(cart.ID, map[string]float64{"shoe": 12, "watch": 40}, time.Now())
+		require.NoError(t, err)
+		_, err = r.CreateGiftCard("HISTORY", 5)
+		require.NoError(t, err)
+		_, err = r.RedeemGiftCard(cart.ID, "HISTORY")
+		require.NoError(t, err)
+		require.NoError(t, r.CloseCart(cart.ID))
+
+		assert.Equal(t, []string{
+			cartpkg.ChangeCheckedOut, cartpkg.ChangeGiftCard, cartpkg.ChangeRepriced, cartpkg.ChangeRestored,
+			cartpkg.ChangeRemoved, cartpkg.ChangeAdded, cartpkg.ChangeAdded,
+		}, actions(t, cart.ID))
+
+		changes, err := r.ListCartChanges(cart.ID, 0, 100)
+		require.NoError(t, err)
+		assert.Equal(t, "shoe", changes[2].Product)
+		assert.Equal(t, 12.0, changes[2].Amount)
+		assert.Equal(t, 5.0, changes[1].Amount)
+		assert.Equal(t, "watch", changes[4].Product)
+		assert.Equal(t, 1, changes[4].Quantity)
+	})
+
+	t.Run("pages through older changes", func(t *testing.T) {
+		cart, err := r.GetOrCreateCart("paging-session", cartpkg.DefaultName)
+		require.NoError(t, err)
+		for _, product := range []string{"shoe", "bag", "watch"} {
+			require.NoError(t, r.AddCartItem(cart.ID, product, 1, 10))
+		}
+
+		first, err := r.ListCartChanges(cart.ID, 0, 2)
+		require.NoError(t, err)
+		require.Len(t, first, 2)
+		assert.Equal(t, "watch", first[0].Product)
+		rest, err := r.ListCartChanges(cart.ID, first[1].ID, 2)
+		require.NoError(t, err)
+		require.Len(t, rest, 1)
+		assert.Equal(t, "shoe", rest[0].Product)
+	})
+
+	t.Run("moves merged items and forgets deleted carts", func(t *testing.T) {
+		from, err := r.GetOrCreateCart("anonymous-session", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, r.AddCartItem(from.ID, "bag", 3, 20))
+		into, err := r.GetOrCreateCart("user-session", cartpkg.DefaultName)
+		require.NoError(t, err)
+
+		require.NoError(t, r.MergeAnonymousCart(from.ID, into.ID))
+		assert.Equal(t, []string{cartpkg.ChangeMerged}, actions(t, into.ID))
+		assert.Empty(t, actions(t, from.ID))
+
+		require.NoError(t, r.DeleteCart("user-session", cartpkg.DefaultName))
+		assert.Empty(t, actions(t, into.ID))
+	})
+}
+
+func TestUndoCartChange(t *testing.T) {
+	db := setupTestDB(t)
+	r := repo.NewRepository(db)
+	now := time.Now()
+	since := now.Add(-5 * time.Minute)
+
+	quantities := func(t *testing.T, cartID uint) map[string]int {
+		t.Helper()
+		items, err := r.ListCartItems(cartID, 0, 100)
+		require.NoError(t, err)
+		quantities := map[string]int{}
+		for _, item := range items {
+			quantities[item.ProductName] = item.Quantity
+		}
+		return quantities
+	}
+
+	t.Run("undoes changes like a stack", func(t *testing.T) {
+		cart, err := r.GetOrCreateCart("undo-session", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, r.AddCartItem(cart.ID, "shoe", 2, 10))
+		require.NoError(t, r.AddCartItem(cart.ID, "shoe", 3, 10))
+		require.NoError(t, r.AddCartItem(cart.ID, "watch", 1, 40))
+		items, err := r.ListCartItems(cart.ID, 0, 10)
+		require.NoError(t, err)
+		require.NoError(t, r.RemoveCartItem(cart.ID, items[1].ID))
+		assert.Equal(t, map[string]int{"shoe": 5}, quantities(t, cart.ID))
+
+		undone, err := r.UndoCartChange(cart.ID, since, now)
+		require.NoError(t, err)
+		assert.Equal(t, cartpkg.ChangeRemoved, undone.Action)
+		assert.Equal(t, map[string]int{"shoe": 5, "watch": 1}, quantities(t, cart.ID))
+
+		_, err = r.UndoCartChange(cart.ID, since, now)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{"shoe": 5}, quantities(t, cart.ID))
+		deleted, err := r.ListDeletedItems(cart.ID)
+		require.NoError(t, err)
+		assert.Empty(t, deleted, "undone additions aren't offered to be restored")
+
+		_, err = r.UndoCartChange(cart.ID, since, now)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{"shoe": 2}, quantities(t, cart.ID))
+
+		cart, err = r.GetCart(cart.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 20.0, cart.Total)
+
+		changes, err := r.ListCartChanges(cart.ID, 0, 100)
+		require.NoError(t, err)
+		require.Len(t, changes, 4, "undoing doesn't record changes")
+		assert.Nil(t, changes[3].UndoneAt)
+		for _, change := range changes[:3] {
+			assert.NotNil(t, change.UndoneAt)
+		}
+	})
+
+	t.Run("removes restored items again", func(t *testing.T) {
+		cart, err := r.GetOrCreateCart("restore-session", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, r.AddCartItem(cart.ID, "bag", 1, 20))
+		items, err := r.ListCartItems(cart.ID, 0, 10)
+		require.NoError(t, err)
+		require.NoError(t, r.RemoveCartItem(cart.ID, items[0].ID))
+		_, err = r.RestoreCartItem(cart.ID, items[0].ID)
+		require.NoError(t, err)
+
+		_, err = r.UndoCartChange(cart.ID, since, now)
+		require.NoError(t, err)
+		assert.Empty(t, quantities(t, cart.ID))
+		deleted, err := r.ListDeletedItems(cart.ID)
+		require.NoError(t, err)
+		require.Len(t, deleted, 1)
+		assert.Equal(t, "bag", deleted[0].ProductName)
+	})
+
+	t.Run("only undoes recent changes of items", func(t *testing.T) {
+		cart, err := r.GetOrCreateCart("old-session", cartpkg.DefaultName)
+		require.NoError(t, err)
+		_, err = r.UndoCartChange(cart.ID, since, now)
+		assert.ErrorIs(t, err, cartpkg.ErrNothingToUndo)
+
+		require.NoError(t, r.AddCartItem(cart.ID, "shoe", 1, 10))
+		_, err = r.UndoCartChange(cart.ID, now.Add(time.Minute), now)
+		assert.ErrorIs(t, err, cartpkg.ErrNothingToUndo)
+
+		_, err = r.CreateGiftCard("UNDO", 5)
+		require.NoError(t, err)
+		_, err = r.RedeemGiftCard(cart.ID, "UNDO")
+		require.NoError(t, err)
+		_, err = r.UndoCartChange(cart.ID, since, now)
+		assert.ErrorIs(t, err, cartpkg.ErrCannotUndo)
+		assert.Equal(t, map[string]int{"shoe": 1}, quantities(t, cart.ID))
+	})
+}
@@ -0,0 +1,143 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	cartpkg "interview/internal/cart"
+
+	"gorm.io/gorm"
+)
+
+// ListCarts returns the open carts of the session ordered by name, with their items
+func (r *Repository) ListCarts(sessionID string) ([]cartpkg.Cart, error) {
+	var carts []cartpkg.Cart
+	err := r.db.Preload("CartItems", orderItems).Preload("Discounts").
+		Where("session_id = ? AND status = ?", sessionID, cartpkg.StatusOpen).
+		Order("name").
+		Find(&carts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list carts: %w", err)
+	}
+	return carts, nil
+}
+
+// CreateCart creates an empty cart for the session. It fails with ErrInvalidName for empty or overlong
+// names and ErrNameTaken when the session already has a cart with the name.
+func (r *Repository) CreateCart(sessionID, name string) (*cartpkg.Cart, error) {
+	name, err := cartpkg.NormalizeName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	userCart := cartpkg.Cart{SessionID: sessionID, Name: name, Status: cartpkg.StatusOpen}
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		if err := checkNameFree(tx, sessionID, name); err != nil {
+			return err
+		}
+		if err := tx.Create(&userCart).Error; err != nil {
+			return fmt.Errorf("failed to create cart: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &userCart, nil
+}
+
+// RenameCart renames the open cart of the session with the given name and returns the new name
+func (r *Repository) RenameCart(sessionID, name, newName string) (string, error) {
+	newName, err := cartpkg.NormalizeName(newName)
+	if err != nil {
+		return "", err
+	}
+
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		userCart, err := openNamedCart(tx, sessionID, name)
+		if err != nil {
+			return err
+		}
+		if newName == userCart.Name {
+			return nil
+		}
+		if err := checkNameFree(tx, sessionID, newName); err != nil {
+			return err
+		}
+
+		result := tx.Model(&cartpkg.Cart{}).
+			Where("id = ? AND version = ?", userCart.ID, userCart.Version).
+			Updates(map[string]interface{}{
+				"name":    newName,
+				"version": gorm.Expr("version + 1"),
+			})
+		if result.Error != nil {
+			return fmt.Errorf("failed to rename cart: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrConflict
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return newName, nil
+}
+
+// DeleteCart deletes the open cart of the session with the given name and its items. Carts holding
+// redeemed gift card credit are kept, since the credit would be lost, and fail with ErrCartHasCredit.
+// The cart is deleted for good so its name can be used again.
+func (r *Repository) DeleteCart(sessionID, name string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		userCart, err := openNamedCart(tx, sessionID, name)
+		if err != nil {
+			return err
+		}
+		if userCart.Credit > 0 {
+			return cartpkg.ErrCartHasCredit
+		}
+
+		if err := tx.Unscoped().Where("cart_id = ?", userCart.ID).Delete(&cartpkg.CartItem{}).Error; err != nil {
+			return fmt.Errorf("failed to delete cart items: %w", err)
+		}
+		if err := tx.Unscoped().Where("cart_id = ?", userCart.ID).Delete(&cartpkg.CartDiscount{}).Error; err != nil {
+			return fmt.Errorf("failed to delete cart discounts: %w", err)
+		}
+		if err := deleteChanges(tx, userCart.ID); err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(userCart).Error; err != nil {
+			return fmt.Errorf("failed to delete cart: %w", err)
+		}
+		return nil
+	})
+}
+
+// openNamedCart loads the open cart of the session with the given name to change it
+func openNamedCart(tx *gorm.DB, sessionID, name string) (*cartpkg.Cart, error) {
+	var userCart cartpkg.Cart
+	err := tx.Where("session_id = ? AND name = ? AND status = ?", sessionID, name, cartpkg.StatusOpen).
+		First(&userCart).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, cartpkg.ErrCartNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get cart: %w", err)
+	}
+	return &userCart, nil
+}
+
+// checkNameFree fails with ErrNameTaken when the session has an open cart with the name. Closed carts
+// keep their name without holding it.
+func checkNameFree(tx *gorm.DB, sessionID, name string) error {
+	var count int64
+	err := tx.Unscoped().Model(&cartpkg.Cart{}).
+		Where("session_id = ? AND name = ? AND is_open = ?", sessionID, name, true).
+		Count(&count).Error
+	if err != nil {
+		return fmt.Errorf("failed to check cart name: %w", err)
+	}
+	if count > 0 {
+		return cartpkg.ErrNameTaken
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package repo_test
+
+import (
+	cartpkg "interview/internal/cart"
+	"interview/internal/repo"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamedCarts(t *testing.T) {
+	db := setupTestDB(t)
+	cartRepo := repo.NewRepository(db)
+
+	_, err := cartRepo.GetOrCreateCart("session", cartpkg.DefaultName)
+	require.NoError(t, err)
+	work, err := cartRepo.CreateCart("session", " work ")
+	require.NoError(t, err)
+	assert.Equal(t, "work", work.Name)
+	require.NoError(t, cartRepo.AddCartItem(work.ID, "shoe", 1, 10))
+
+	t.Run("names are checked", func(t *testing.T) {
+		_, err := cartRepo.CreateCart("session", "")
+		assert.ErrorIs(t, err, cartpkg.ErrInvalidName)
+		_, err = cartRepo.CreateCart("session", strings.Repeat("x", 65))
+		assert.ErrorIs(t, err, cartpkg.ErrInvalidName)
+		_, err = cartRepo.CreateCart("session", "work")
+		assert.ErrorIs(t, err, cartpkg.ErrNameTaken)
+		_, err = cartRepo.RenameCart("session", cartpkg.DefaultName, "work")
+		assert.ErrorIs(t, err, cartpkg.ErrNameTaken)
+
+		// Other sessions have their own names
+		_, err = cartRepo.CreateCart("other-session", "work")
+		assert.NoError(t, err)
+	})
+
+	t.Run("carts are listed by name", func(t *testing.T) {
+		name, err := cartRepo.RenameCart("session", "work", "office")
+		require.NoError(t, err)
+		assert.Equal(t, "office", name)
+
+		carts, err := cartRepo.ListCarts("session")
+		require.NoError(t, err)
+		require.Len(t, carts, 2)
+		assert.Equal(t, cartpkg.DefaultName, carts[0].Name)
+		assert.Equal(t, "office", carts[1].Name)
+		assert.Len(t, carts[1].CartItems, 1)
+	})
+
+	t.Run("deleted names can be used again", func(t *testing.T) {
+		require.NoError(t, cartRepo.DeleteCart("session", "office"))
+		assert.ErrorIs(t, cartRepo.DeleteCart("session", "office"), cartpkg.ErrCartNotFound)
+
+		recreated, err := cartRepo.CreateCart("session", "office")
+		require.NoError(t, err)
+		assert.Empty(t, recreated.CartItems)
+	})
+
+	t.Run("carts with credit are kept", func(t *testing.T) {
+		c, err := cartRepo.GetOrCreateCart("session", "office")
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "bag", 1, 25))
+		_, err = cartRepo.CreateGiftCard("office-gift", 5)
+		require.NoError(t, err)
+		_, err = cartRepo.RedeemGiftCard(c.ID, "office-gift")
+		require.NoError(t, err)
+
+		assert.ErrorIs(t, cartRepo.DeleteCart("session", "office"), cartpkg.ErrCartHasCredit)
+	})
+}
@@ -0,0 +1,60 @@
+package repo
+
+import (
+	"fmt"
+	cartpkg "interview/internal/cart"
+
+	"gorm.io/gorm"
+)
+
+// orderItems orders the items of carts the way their customer arranged them, see cartpkg.CartItem.Position
+func orderItems(db *gorm.DB) *gorm.DB {
+	return db.Order("position, id")
+}
+
+// nextPosition returns the position of an item added last to the cart
+func nextPosition(db *gorm.DB, cartID uint) (int, error) {
+	var last int
+	err := db.Model(&cartpkg.CartItem{}).Where("cart_id = ?", cartID).
+		Select("COALESCE(MAX(position), 0)").Scan(&last).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to get item positions: %w", err)
+	}
+	return last + 1, nil
+}
+
+// ReorderCartItems arranges the items of the open cart in the order of their IDs, which must list each
+// item of the cart once, or it fails with ErrInvalidItemOrder. Reordering changes the version of the
+// cart but isn't recorded in its history.
+func (r *Repository) ReorderCartItems(cartID uint, itemIDs []uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		cart, err := openCart(tx, cartID)
+		if err != nil {
+			return err
+		}
+
+		var ids []uint
+		if err := tx.Model(&cartpkg.CartItem{}).Where("cart_id = ?", cartID).Pluck("id", &ids).Error; err != nil {
+			return fmt.Errorf("failed to get items: %w", err)
+		}
+		listed := make(map[uint]bool, len(itemIDs))
+		for _, id := range itemIDs {
+			listed[id] = true
+		}
+		if len(itemIDs) != len(ids) || len(listed) != len(ids) {
+			return cartpkg.ErrInvalidItemOrder
+		}
+		for _, id := range ids {
+			if !listed[id] {
+				return cartpkg.ErrInvalidItemOrder
+			}
+		}
+
+		for i, id := range itemIDs {
+			if err := tx.Model(&cartpkg.CartItem{}).Where("id = ?", id).UpdateColumn("position", i+1).Error; err != nil {
+				return fmt.Errorf("failed to move item: %w", err)
+			}
+		}
+		return r.updateCartTotal(tx, cart)
+	})
+}
@@ -0,0 +1,104 @@
+package repo_test
+
+import (
+	"interview/internal/cart"
+	"interview/internal/product"
+	"interview/internal/repo"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestReorderCartItems(t *testing.T) {
+	// setup fills a cart with a shoe, a bag and a watch, added in this order
+	setup := func(t *testing.T) (*repo.Repository, *cart.Cart) {
+		t.Helper()
+		cartRepo := repo.NewRepository(setupTestDB(t))
+		c, err := cartRepo.GetOrCreateCart("session", cart.DefaultName)
+		require.NoError(t, err)
+		for _, name := range []string{"shoe", "bag", "watch"} {
+			require.NoError(t, cartRepo.AddCartItem(c.ID, name, 1, 10))
+		}
+		c, err = cartRepo.GetCart(c.ID)
+		require.NoError(t, err)
+		return cartRepo, c
+	}
+	names := func(t *testing.T, cartRepo *repo.Repository, cartID uint) []string {
+		t.Helper()
+		c, err := cartRepo.GetCart(cartID)
+		require.NoError(t, err)
+		var names []string
+		for _, item := range c.CartItems {
+			names = append(names, item.ProductName)
+		}
+		return names
+	}
+
+	t.Run("reorders items", func(t *testing.T) {
+		cartRepo, c := setup(t)
+		shoe, bag, watch := c.CartItems[0].ID, c.CartItems[1].ID, c.CartItems[2].ID
+		require.NoError(t, cartRepo.ReorderCartItems(c.ID, []uint{watch, shoe, bag}))
+		assert.Equal(t, []string{"watch", "shoe", "bag"}, names(t, cartRepo, c.ID))
+
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "purse", 1, 10))
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 1, 10))
+		assert.Equal(t, []string{"watch", "shoe", "bag", "purse"}, names(t, cartRepo, c.ID), "new items go last")
+
+		updated, err := cartRepo.GetCart(c.ID)
+		require.NoError(t, err)
+		assert.Greater(t, updated.Version, c.Version)
+		changes, err := cartRepo.ListCartChanges(c.ID, 0, 10)
+		require.NoError(t, err)
+		assert.Len(t, changes, 5, "reordering isn't recorded")
+	})
+
+	t.Run("rejects incomplete orders", func(t *testing.T) {
+		cartRepo, c := setup(t)
+		shoe, bag, watch := c.CartItems[0].ID, c.CartItems[1].ID, c.CartItems[2].ID
+		for _, ids := range [][]uint{{shoe, bag}, {shoe, bag, bag}, {shoe, bag, watch, 9999}, {shoe, bag, 9999}} {
+			assert.ErrorIs(t, cartRepo.ReorderCartItems(c.ID, ids), cart.ErrInvalidItemOrder)
+		}
+		assert.Equal(t, []string{"shoe", "bag", "watch"}, names(t, cartRepo, c.ID))
+
+		require.NoError(t, cartRepo.CloseCart(c.ID))
+		assert.ErrorIs(t, cartRepo.ReorderCartItems(c.ID, []uint{watch, bag, shoe}), cart.ErrCartClosed)
+	})
+
+	t.Run("keeps bundles together", func(t *testing.T) {
+		cartRepo, c := setup(t)
+		var ids []uint
+		for _, item := range c.CartItems {
+			ids = append([]uint{item.ID}, ids...)
+		}
+		require.NoError(t, cartRepo.ReorderCartItems(c.ID, ids))
+		sock, err := cartRepo.UpsertProduct("sock", 5)
+		require.NoError(t, err)
+		lace, err := cartRepo.UpsertProduct("lace", 1)
+		require.NoError(t, err)
+		kit, err := cartRepo.UpsertProduct("kit", 5)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.SetBundleComponents(kit.ID, []product.BundleComponent{
+			{ProductID: sock.ID, Quantity: 1}, {ProductID: lace.ID, Quantity: 2},
+		}))
+
+		require.NoError(t, cartRepo.AddBundle(c.ID, kit.ID, 1, 5))
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "purse", 1, 10))
+		assert.Equal(t, []string{"watch", "bag", "shoe", "lace", "sock", "purse"}, names(t, cartRepo, c.ID))
+	})
+
+	t.Run("sets product categories", func(t *testing.T) {
+		cartRepo, _ := setup(t)
+		shoe, err := cartRepo.UpsertProduct("shoe", 10)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.SetProductCategory(shoe.ID, " footwear "))
+		p, err := cartRepo.GetProduct(shoe.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "footwear", p.Category)
+
+		assert.ErrorIs(t, cartRepo.SetProductCategory(shoe.ID, strings.Repeat("x", 65)), product.ErrInvalidCategory)
+		assert.ErrorIs(t, cartRepo.SetProductCategory(9999, "footwear"), gorm.ErrRecordNotFound)
+	})
+}
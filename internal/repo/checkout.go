@@ -0,0 +1,158 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"interview/internal/checkout"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SetCheckoutTTL sets how long checkout sessions lock their cart after the customer's last step
+func (r *Repository) SetCheckoutTTL(ttl time.Duration) {
+	r.checkoutTTL = ttl
+}
+
+// StartCheckout returns the checkout session of the cart, starting one at the address step when the cart
+// isn't being checked out yet. Starting the checkout of a cart twice returns the same session, as does
+// starting one waiting for review, and
+// starting it again after it expired or was cancelled locks the cart again, keeping the choices made
+// but not the payment.
+func (r *Repository) StartCheckout(sessionID string, cartID uint) (*checkout.Session, error) {
+	now := time.Now()
+	s, err := r.GetCheckout(cartID)
+	if err == nil {
+		if s.Status == checkout.StatusCompleted || s.Status == checkout.StatusReview || s.Active(now) {
+			return s, nil
+		}
+		return r.restartCheckout(s, now)
+	} else if !errors.Is(err, checkout.ErrSessionNotFound) {
+		return nil, err
+	}
+
+	s = &checkout.Session{CartID: cartID, SessionID: sessionID, Step: checkout.StepAddress,
+		Status: checkout.StatusActive, ExpiresAt: now.Add(r.checkoutTTL)}
+	if err := r.db.Create(s).Error; err != nil {
+		// A concurrent request may have started the checkout first
+		if existing, getErr := r.GetCheckout(cartID); getErr == nil {
+			return existing, nil
+		}
+		return nil, fmt.Errorf("failed to start checkout: %w", err)
+	}
+	return s, nil
+}
+
+// restartCheckout reactivates an expired or cancelled checkout session. The cart may have changed since,
+// so the payment is started again.
+func (r *Repository) restartCheckout(s *checkout.Session, now time.Time) (*checkout.Session, error) {
+	step := s.Step
+	if step == checkout.StepConfirm {
+		step = checkout.StepPayment
+	}
+	err := r.db.Model(s).Where("status <> ?", checkout.StatusCompleted).Updates(map[string]interface{}{
+		"step":        step,
+		"status":      checkout.StatusActive,
+		"expires_at":  now.Add(r.checkoutTTL),
+		"payment_id":  nil,
+		"approve_url": "",
+	}).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to restart checkout: %w", err)
+	}
+	return r.GetCheckout(s.CartID)
+}
+
+// GetCheckout returns the checkout session of the cart, or checkout.ErrSessionNotFound
+func (r *Repository) GetCheckout(cartID uint) (*checkout.Session, error) {
+	var s checkout.Session
+	err := r.db.Where("cart_id = ?", cartID).First(&s).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, checkout.ErrSessionNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get checkout: %w", err)
+	}
+	return &s, nil
+}
+
+// SaveCheckout stores the step and choices of an active checkout session and extends its lock on the
+// cart. Completed, cancelled and expired sessions are left alone, so a late request can't reopen a
+// checkout.
+func (r *Repository) SaveCheckout(s *checkout.Session) error {
+	now := time.Now()
+	expiresAt := now.Add(r.checkoutTTL)
+	result := r.db.Model(s).
+		Where("status = ? AND expires_at > ?", checkout.StatusActive, now).
+		Updates(map[string]interface{}{
+			"step":               s.Step,
+			"address_id":         s.AddressID,
+			"billing_address_id": s.BillingAddressID,
+			"shipping_method":    s.ShippingMethod,
+			"vat_id":             s.VATID,
+			"payment_id":         s.PaymentID,
+			"approve_url":        s.ApproveURL,
+			"expires_at":         expiresAt,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to save checkout: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		s.ExpiresAt = expiresAt
+	}
+	return nil
+}
+
+// CancelCheckout cancels the active checkout session of the cart, unlocking the cart. Cancelling a
+// session that is no longer active does nothing.
+func (r *Repository) CancelCheckout(cartID uint) error {
+	result := r.db.Model(&checkout.Session{}).
+		Where("cart_id = ? AND status = ?", cartID, checkout.StatusActive).
+		Updates(map[string]interface{}{"status": checkout.StatusCancelled, "payment_id": nil, "approve_url": ""})
+	if result.Error != nil {
+		return fmt.Errorf("failed to cancel checkout: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		_, err := r.GetCheckout(cartID)
+		return err
+	}
+	return nil
+}
+
+// CompleteCheckout checks out the cart of a session with nothing to pay. Completing a session twice only
+// checks the cart out once; it returns whether this call did.
+func (r *Repository) CompleteCheckout(id uint) (bool, error) {
+	var checkedOut bool
+	err := r.Transaction(func(tx *Repository) error {
+		var s checkout.Session
+		if err := tx.db.First(&s, id).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+			return checkout.ErrSessionNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to get checkout: %w", err)
+		}
+		if completed, err := tx.completeCheckout(s.CartID); err != nil || !completed {
+			return err
+		}
+		if err := tx.CloseCart(s.CartID); err != nil {
+			return err
+		}
+		// Nothing to pay means the order is paid
+		if err := payCartOrder(tx.db, s.CartID); err != nil {
+			return err
+		}
+		checkedOut = true
+		return nil
+	})
+	return checkedOut, err
+}
+
+// completeCheckout marks the checkout session of the cart completed, reporting whether it wasn't yet. A
+// payment captured after its checkout expired or was cancelled still completes it.
+func (r *Repository) completeCheckout(cartID uint) (bool, error) {
+	result := r.db.Model(&checkout.Session{}).
+		Where("cart_id = ? AND status <> ?", cartID, checkout.StatusCompleted).
+		Update("status", checkout.StatusCompleted)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to complete checkout: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
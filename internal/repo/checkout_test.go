@@ -0,0 +1,179 @@
+package repo_test
+
+import (
+	cartpkg "interview/internal/cart"
+	"interview/internal/checkout"
+	"interview/internal/payment"
+	"interview/internal/repo"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckoutSessions(t *testing.T) {
+	db := setupTestDB(t)
+	cartRepo := repo.NewRepository(db)
+
+	// newCart creates a cart of the session with a shoe in it
+	newCart := func(t *testing.T, sessionID string) *cartpkg.Cart {
+		t.Helper()
+		c, err := cartRepo.GetOrCreateCart(sessionID, cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 1, 10))
+		return c
+	}
+
+	t.Run("starting a checkout twice resumes it", func(t *testing.T) {
+		c := newCart(t, "resume-session")
+		_, err := cartRepo.GetCheckout(c.ID)
+		assert.ErrorIs(t, err, checkout.ErrSessionNotFound)
+
+		s, err := cartRepo.StartCheckout("resume-session", c.ID)
+		require.NoError(t, err)
+		assert.Equal(t, checkout.StepAddress, s.Step)
+		assert.Equal(t, checkout.StatusActive, s.Status)
+
+		s.Step = checkout.StepShipping
+		require.NoError(t, cartRepo.SaveCheckout(s))
+		again, err := cartRepo.StartCheckout("resume-session", c.ID)
+		require.NoError(t, err)
+		assert.Equal(t, s.ID, again.ID)
+		assert.Equal(t, checkout.StepShipping, again.Step)
+	})
+
+	t.Run("checkouts with nothing to pay are completed once", func(t *testing.T) {
+		c := newCart(t, "free-session")
+		s, err := cartRepo.StartCheckout("free-session", c.ID)
+		require.NoError(t, err)
+
+		checkedOut, err := cartRepo.CompleteCheckout(s.ID)
+		require.NoError(t, err)
+		assert.True(t, checkedOut)
+		checkedOut, err = cartRepo.CompleteCheckout(s.ID)
+		require.NoError(t, err)
+		assert.False(t, checkedOut)
+
+		closed, err := cartRepo.GetCart(c.ID)
+		require.NoError(t, err)
+		assert.Equal(t, cartpkg.StatusClosed, closed.Status)
+
+		// Completed checkouts can't be moved back
+		s.Step = checkout.StepPayment
+		require.NoError(t, cartRepo.SaveCheckout(s))
+		s, err = cartRepo.GetCheckout(c.ID)
+		require.NoError(t, err)
+		assert.Equal(t, checkout.StatusCompleted, s.Status)
+		assert.Equal(t, checkout.StepAddress, s.Step)
+	})
+
+	t.Run("completing the payment completes the checkout", func(t *testing.T) {
+		c := newCart(t, "paid-session")
+		s, err := cartRepo.StartCheckout("paid-session", c.ID)
+		require.NoError(t, err)
+		p := &payment.Payment{CartID: c.ID, Provider: "fake", ExternalID: "fake_1", Amount: 10, Status: payment.StatusPending}
+		require.NoError(t, cartRepo.CreatePayment(p))
+		s.Step, s.PaymentID = checkout.StepConfirm, &p.ID
+		require.NoError(t, cartRepo.SaveCheckout(s))
+
+		byID, err := cartRepo.GetPaymentByID(p.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "fake_1", byID.ExternalID)
+		_, err = cartRepo.GetPaymentByID(9999)
+		assert.ErrorIs(t, err, payment.ErrPaymentNotFound)
+
+		_, checkedOut, err := cartRepo.CompletePayment("fake", "fake_1")
+		require.NoError(t, err)
+		assert.True(t, checkedOut)
+		s, err = cartRepo.GetCheckout(c.ID)
+		require.NoError(t, err)
+		assert.Equal(t, checkout.StatusCompleted, s.Status)
+		assert.Equal(t, p.ID, *s.PaymentID)
+	})
+	t.Run("active checkouts lock their cart", func(t *testing.T) {
+		c := newCart(t, "locked-session")
+		_, err := cartRepo.StartCheckout("locked-session", c.ID)
+		require.NoError(t, err)
+		locked, err := cartRepo.GetCart(c.ID)
+		require.NoError(t, err)
+
+		assert.ErrorIs(t, cartRepo.AddCartItem(c.ID, "bag", 1, 10), cartpkg.ErrCartLocked)
+		assert.ErrorIs(t, cartRepo.RemoveCartItem(c.ID, locked.CartItems[0].ID), cartpkg.ErrCartLocked)
+		_, err = cartRepo.RefreshCartPrices(c.ID, map[string]float64{"shoe": 20}, time.Now())
+		assert.ErrorIs(t, err, cartpkg.ErrCartLocked)
+
+		require.NoError(t, cartRepo.CancelCheckout(c.ID))
+		require.NoError(t, cartRepo.CancelCheckout(c.ID), "cancelling twice does nothing")
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "bag", 1, 10))
+		assert.ErrorIs(t, cartRepo.CancelCheckout(9999), checkout.ErrSessionNotFound)
+	})
+
+	t.Run("expired checkouts unlock their cart and restart", func(t *testing.T) {
+		expiring := repo.NewRepository(db)
+		expiring.SetCheckoutTTL(time.Millisecond)
+		c := newCart(t, "expired-session")
+		s, err := expiring.StartCheckout("expired-session", c.ID)
+		require.NoError(t, err)
+		p := &payment.Payment{CartID: c.ID, Provider: "fake", ExternalID: "fake_2", Amount: 10, Status: payment.StatusPending}
+		require.NoError(t, cartRepo.CreatePayment(p))
+		s.Step, s.PaymentID = checkout.StepConfirm, &p.ID
+		require.NoError(t, expiring.SaveCheckout(s))
+		time.Sleep(5 * time.Millisecond)
+
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "bag", 1, 10))
+		// Expired checkouts aren't moved on anymore
+		s.Step = checkout.StepShipping
+		require.NoError(t, cartRepo.SaveCheckout(s))
+
+		s, err = cartRepo.StartCheckout("expired-session", c.ID)
+		require.NoError(t, err)
+		assert.True(t, s.Active(time.Now()))
+		assert.Equal(t, checkout.StepPayment, s.Step)
+		assert.Nil(t, s.PaymentID)
+		assert.ErrorIs(t, cartRepo.AddCartItem(c.ID, "bag", 1, 10), cartpkg.ErrCartLocked)
+	})
+
+	t.Run("held payments lock the cart until they are blocked", func(t *testing.T) {
+		c := newCart(t, "held-session")
+		s, err := cartRepo.StartCheckout("held-session", c.ID)
+		require.NoError(t, err)
+		p := &payment.Payment{CartID: c.ID, Provider: "fake", ExternalID: "fake_3", Amount: 10, Status: payment.StatusPending}
+		require.NoError(t, cartRepo.CreatePayment(p))
+		s.Step, s.PaymentID = checkout.StepConfirm, &p.ID
+		require.NoError(t, cartRepo.SaveCheckout(s))
+
+		require.NoError(t, cartRepo.HoldPayment(p.ID, "new customer"))
+		require.NoError(t, cartRepo.HoldPayment(p.ID, "new customer"), "holding twice does nothing")
+		held, err := cartRepo.ListHeldPayments()
+		require.NoError(t, err)
+		require.Len(t, held, 1)
+		assert.Equal(t, "new customer", held[0].RiskReason)
+
+		// Waiting for review survives the checkout's expiry and doesn't restart it
+		require.NoError(t, db.Model(&checkout.Session{}).Where("id = ?", s.ID).Update("expires_at", time.Now().Add(-time.Hour)).Error)
+		assert.ErrorIs(t, cartRepo.AddCartItem(c.ID, "bag", 1, 10), cartpkg.ErrCartLocked)
+		s, err = cartRepo.StartCheckout("held-session", c.ID)
+		require.NoError(t, err)
+		assert.Equal(t, checkout.StatusReview, s.Status)
+
+		require.NoError(t, cartRepo.BlockPayment(p.ID, "rejected"))
+		require.NoError(t, cartRepo.BlockPayment(p.ID, "rejected"), "blocking twice does nothing")
+		s, err = cartRepo.GetCheckout(c.ID)
+		require.NoError(t, err)
+		assert.Equal(t, checkout.StatusCancelled, s.Status)
+		assert.Nil(t, s.PaymentID)
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "bag", 1, 10))
+		held, err = cartRepo.ListHeldPayments()
+		require.NoError(t, err)
+		assert.Empty(t, held)
+	})
+
+	t.Run("captured payments can't be blocked", func(t *testing.T) {
+		c := newCart(t, "captured-session")
+		p := &payment.Payment{CartID: c.ID, Provider: "fake", ExternalID: "fake_4", Amount: 10, Status: payment.StatusCaptured}
+		require.NoError(t, cartRepo.CreatePayment(p))
+		assert.ErrorIs(t, cartRepo.BlockPayment(p.ID, "too late"), payment.ErrAlreadyCaptured)
+		assert.ErrorIs(t, cartRepo.HoldPayment(9999, "unknown"), payment.ErrPaymentNotFound)
+	})
+}
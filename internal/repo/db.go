@@ -0,0 +1,61 @@
+package repo
+
+import (
+	"fmt"
+	"interview/internal/config"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// maxConnectBackoff caps the delay between connection attempts
+const maxConnectBackoff = 30 * time.Second
+
+// PoolOptions configures the connection pool of the underlying sql.DB
+type PoolOptions struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// OpenWithRetry opens the database, retrying with exponential backoff so the application survives
+// the database becoming available slightly after it starts (e.g. in docker compose).
+func OpenWithRetry(dialector gorm.Dialector, attempts int, backoff time.Duration) (*gorm.DB, error) {
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		db, err := gorm.Open(dialector, &gorm.Config{})
+		if err == nil {
+			return db, nil
+		}
+		lastErr = err
+
+		if attempt < attempts {
+			log.Printf("Database connection attempt %d/%d failed, retrying in %s: %v", attempt, attempts, backoff, err)
+			time.Sleep(backoff)
+			backoff = min(backoff*2, maxConnectBackoff)
+		}
+	}
+	return nil, fmt.Errorf("giving up after %d attempts: %w", attempts, lastErr)
+}
+
+// ConfigurePool applies the pool options to the database connection
+func ConfigurePool(db *gorm.DB, opts PoolOptions) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get sql.DB: %w", err)
+	}
+	sqlDB.SetMaxOpenConns(opts.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(opts.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	return nil
+}
+
+// poolOptions returns the pool settings of the configuration
+func poolOptions(config config.Config) PoolOptions {
+	return PoolOptions{
+		MaxOpenConns:    config.DBMaxOpenConns,
+		MaxIdleConns:    config.DBMaxIdleConns,
+		ConnMaxLifetime: config.DBConnMaxLifetime,
+	}
+}
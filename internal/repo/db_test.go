@@ -0,0 +1,42 @@
+package repo_test
+
+import (
+	"interview/internal/repo"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+)
+
+func TestOpenWithRetry(t *testing.T) {
+	t.Run("connects on first attempt", func(t *testing.T) {
+		db, err := repo.OpenWithRetry(sqlite.Open(":memory:"), 3, time.Millisecond)
+		require.NoError(t, err)
+		require.NotNil(t, db)
+	})
+
+	t.Run("gives up after the configured attempts", func(t *testing.T) {
+		unreachable := filepath.Join(t.TempDir(), "missing", "dir", "cart.db")
+
+		start := time.Now()
+		_, err := repo.OpenWithRetry(sqlite.Open(unreachable+"?mode=ro"), 3, 5*time.Millisecond)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "3 attempts")
+		// Backoff doubles: 5ms + 10ms between the three attempts
+		assert.GreaterOrEqual(t, time.Since(start), 15*time.Millisecond)
+	})
+}
+
+func TestConfigurePool(t *testing.T) {
+	db := setupTestDB(t)
+
+	err := repo.ConfigurePool(db, repo.PoolOptions{MaxOpenConns: 7, MaxIdleConns: 3, ConnMaxLifetime: time.Minute})
+	require.NoError(t, err)
+
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	assert.Equal(t, 7, sqlDB.Stats().MaxOpenConnections)
+}
@@ -0,0 +1,168 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	cartpkg "interview/internal/cart"
+	userpkg "interview/internal/user"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrDeviceNotFound is returned for sessions without a recorded device, or devices of another user
+var ErrDeviceNotFound = errors.New("session not found")
+
+// TouchDevice records that the session of the device was used at device.LastSeenAt, by the user, user
+// agent and IP address hash of device. The first use of a session also sets FirstSeenAt.
+func (r *Repository) TouchDevice(device userpkg.Device) error {
+	device.FirstSeenAt = device.LastSeenAt
+	err := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "session_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_id", "user_agent", "ip_hash", "last_seen_at"}),
+	}).Create(&device).Error
+	if err != nil {
+		return fmt.Errorf("failed to record session device: %w", err)
+	}
+	return nil
+}
+
+// GetDevice returns the device of the session, failing with ErrDeviceNotFound when none was recorded
+func (r *Repository) GetDevice(sessionID string) (*userpkg.Device, error) {
+	var device userpkg.Device
+	err := r.db.Where("session_id = ?", sessionID).First(&device).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrDeviceNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get session device: %w", err)
+	}
+	return &device, nil
+}
+
+// ListUserDevices returns the devices of the sessions the user is logged in to that were used since the
+// time and weren't revoked, the one used last first
+func (r *Repository) ListUserDevices(userID uint, since time.Time) ([]userpkg.Device, error) {
+	var devices []userpkg.Device
+	err := r.db.Where("user_id = ? AND revoked_at IS NULL AND last_seen_at >= ?", userID, since).
+		Order("last_seen_at DESC, id DESC").
+		Find(&devices).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list session devices: %w", err)
+	}
+	return devices, nil
+}
+
+// RevokeDevice marks the session of one of the user's devices for logging out. It fails with
+// ErrDeviceNotFound when the device isn't one of the user's sessions or was revoked already.
+func (r *Repository) RevokeDevice(userID, deviceID uint, at time.Time) error {
+	result := r.db.Model(&userpkg.Device{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", deviceID, userID).
+		Update("revoked_at", at)
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke session: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrDeviceNotFound
+	}
+	return nil
+}
+
+// ForgetDeviceUser records that the revoked session of the device was logged out, so logging in to it
+// again isn't revoked too
+func (r *Repository) ForgetDeviceUser(sessionID string) error {
+	err := r.db.Model(&userpkg.Device{}).Where("session_id = ?", sessionID).
+		Updates(map[string]interface{}{"user_id": nil, "revoked_at": nil}).Error
+	if err != nil {
+		return fmt.Errorf("failed to log out session: %w", err)
+	}
+	return nil
+}
+
+// MoveDevice records the device of the session fromSessionID as that of the new session toSessionID of
+// the same browser, given when logging in
+func (r *Repository) MoveDevice(fromSessionID, toSessionID string) error {
+	err := r.db.Model(&userpkg.Device{}).Where("session_id = ?", fromSessionID).
+		Update("session_id", toSessionID).Error
+	if err != nil {
+		return fmt.Errorf("failed to move session device: %w", err)
+	}
+	return nil
+}
+
+// RevokeOldestDevices marks the sessions the user started first for logging out until at most keep of
+// their sessions used since the time remain, not counting the session sessionID logging in. It returns
+// the devices of the sessions revoked.
+func (r *Repository) RevokeOldestDevices(userID uint, sessionID string, keep int, since, at time.Time) ([]userpkg.Device, error) {
+	var revoked []userpkg.Device
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var devices []userpkg.Device
+		err := tx.Where("user_id = ? AND session_id <> ? AND revoked_at IS NULL AND last_seen_at >= ?", userID, sessionID, since).
+			Order("first_seen_at, id").
+			Find(&devices).Error
+		if err != nil {
+			return fmt.Errorf("failed to list session devices: %w", err)
+		}
+		if len(devices) <= keep {
+			return nil
+		}
+		revoked = devices[:len(devices)-keep]
+		ids := make([]uint, len(revoked))
+		for i := range revoked {
+			ids[i] = revoked[i].ID
+			revoked[i].RevokedAt = &at
+		}
+		if err := tx.Model(&userpkg.Device{}).Where("id IN ?", ids).Update("revoked_at", at).Error; err != nil {
+			return fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return revoked, nil
+}
+
+// MoveSessionCarts moves the open carts of the session fromSessionID to the session toSessionID, and
+// the items of those named like an open cart there into that cart. Carts being checked out stay.
+func (r *Repository) MoveSessionCarts(fromSessionID, toSessionID string) error {
+	return r.Transaction(func(tx *Repository) error {
+		var carts []cartpkg.Cart
+		err := tx.db.Where("session_id = ? AND status = ?", fromSessionID, cartpkg.StatusOpen).Find(&carts).Error
+		if err != nil {
+			return fmt.Errorf("failed to list carts: %w", err)
+		}
+		for _, c := range carts {
+			if err := checkCartLock(tx.db, c.ID); errors.Is(err, cartpkg.ErrCartLocked) {
+				continue
+			} else if err != nil {
+				return err
+			}
+			var into cartpkg.Cart
+			err := tx.db.Where("session_id = ? AND name = ? AND status = ?", toSessionID, c.Name, cartpkg.StatusOpen).
+				First(&into).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				if err := tx.db.Model(&c).Update("session_id", toSessionID).Error; err != nil {
+					return fmt.Errorf("failed to move cart: %w", err)
+				}
+				continue
+			} else if err != nil {
+				return fmt.Errorf("failed to get cart: %w", err)
+			}
+			if err := checkCartLock(tx.db, into.ID); errors.Is(err, cartpkg.ErrCartLocked) {
+				continue
+			} else if err != nil {
+				return err
+			}
+
+			// Only anonymous carts are merged, which the user's cart becomes for the move
+			if err := tx.db.Model(&c).Update("user_id", nil).Error; err != nil {
+				return fmt.Errorf("failed to move cart: %w", err)
+			}
+			if err := tx.MergeAnonymousCart(c.ID, into.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,134 @@
+package repo_test
+
+import (
+	cartpkg "interview/internal/cart"
+	"interview/internal/repo"
+	userpkg "interview/internal/user"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestDevices(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, repo.Migrate(db))
+	cartRepo := repo.NewRepository(db)
+	start := time.Now().Truncate(time.Second)
+	userID := uint(7)
+
+	require.NoError(t, cartRepo.TouchDevice(userpkg.Device{SessionID: "laptop", UserAgent: "Firefox", IPHash: "a", LastSeenAt: start}))
+	require.NoError(t, cartRepo.TouchDevice(userpkg.Device{SessionID: "laptop", UserID: &userID, UserAgent: "Firefox", IPHash: "b", LastSeenAt: start.Add(time.Minute)}))
+	require.NoError(t, cartRepo.TouchDevice(userpkg.Device{SessionID: "phone", UserID: &userID, UserAgent: "Safari", LastSeenAt: start.Add(-2 * time.Hour)}))
+
+	t.Run("first and last seen", func(t *testing.T) {
+		laptop, err := cartRepo.GetDevice("laptop")
+		require.NoError(t, err)
+		assert.WithinDuration(t, start, laptop.FirstSeenAt, 0)
+		assert.WithinDuration(t, start.Add(time.Minute), laptop.LastSeenAt, 0)
+		assert.Equal(t, "b", laptop.IPHash)
+		assert.Equal(t, &userID, laptop.UserID)
+
+		_, err = cartRepo.GetDevice("tablet")
+		assert.ErrorIs(t, err, repo.ErrDeviceNotFound)
+	})
+
+	t.Run("active sessions", func(t *testing.T) {
+		devices, err := cartRepo.ListUserDevices(userID, start.Add(-time.Hour))
+		require.NoError(t, err)
+		require.Len(t, devices, 1)
+		assert.Equal(t, "laptop", devices[0].SessionID)
+	})
+
+	t.Run("revoke", func(t *testing.T) {
+		laptop, err := cartRepo.GetDevice("laptop")
+		require.NoError(t, err)
+		assert.ErrorIs(t, cartRepo.RevokeDevice(userID+1, laptop.ID, start), repo.ErrDeviceNotFound)
+		require.NoError(t, cartRepo.RevokeDevice(userID, laptop.ID, start))
+		assert.ErrorIs(t, cartRepo.RevokeDevice(userID, laptop.ID, start), repo.ErrDeviceNotFound)
+
+		devices, err := cartRepo.ListUserDevices(userID, start.Add(-time.Hour))
+		require.NoError(t, err)
+		assert.Empty(t, devices)
+
+		require.NoError(t, cartRepo.ForgetDeviceUser("laptop"))
+		laptop, err = cartRepo.GetDevice("laptop")
+		require.NoError(t, err)
+		assert.Nil(t, laptop.UserID)
+		assert.Nil(t, laptop.RevokedAt)
+	})
+}
+
+func TestSessionLimit(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, repo.Migrate(db))
+	cartRepo := repo.NewRepository(db)
+	start := time.Now().Truncate(time.Second)
+	userID := uint(7)
+
+	for i, session := range []string{"desktop", "laptop", "phone"} {
+		require.NoError(t, cartRepo.TouchDevice(userpkg.Device{SessionID: session, UserID: &userID, LastSeenAt: start.Add(time.Duration(i) * time.Minute)}))
+	}
+	// The desktop was started first but is the one used last
+	require.NoError(t, cartRepo.TouchDevice(userpkg.Device{SessionID: "desktop", UserID: &userID, LastSeenAt: start.Add(time.Hour)}))
+
+	t.Run("revokes the sessions started first", func(t *testing.T) {
+		revoked, err := cartRepo.RevokeOldestDevices(userID, "tablet", 1, start.Add(-time.Hour), start)
+		require.NoError(t, err)
+		require.Len(t, revoked, 2)
+		assert.Equal(t, "desktop", revoked[0].SessionID)
+		assert.Equal(t, "laptop", revoked[1].SessionID)
+
+		devices, err := cartRepo.ListUserDevices(userID, start.Add(-time.Hour))
+		require.NoError(t, err)
+		require.Len(t, devices, 1)
+		assert.Equal(t, "phone", devices[0].SessionID)
+	})
+
+	t.Run("within the limit", func(t *testing.T) {
+		revoked, err := cartRepo.RevokeOldestDevices(userID, "phone", 0, start.Add(-time.Hour), start)
+		require.NoError(t, err)
+		assert.Empty(t, revoked, "the session logging in doesn't count")
+	})
+
+	t.Run("moves carts", func(t *testing.T) {
+		for _, session := range []string{"laptop", "phone"} {
+			c, err := cartRepo.GetOrCreateCart(session, cartpkg.DefaultName)
+			require.NoError(t, err)
+			require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 1, 10))
+			require.NoError(t, cartRepo.AssignCartToUser(session, userID))
+		}
+		work, err := cartRepo.GetOrCreateCart("laptop", "work")
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AddCartItem(work.ID, "bag", 2, 50))
+
+		require.NoError(t, cartRepo.MoveSessionCarts("laptop", "phone"))
+
+		phone, err := cartRepo.GetExistingCart("phone", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.Len(t, phone.CartItems, 1)
+		assert.Equal(t, 2, phone.CartItems[0].Quantity)
+		moved, err := cartRepo.GetExistingCart("phone", "work")
+		require.NoError(t, err)
+		assert.Equal(t, work.ID, moved.ID)
+		_, err = cartRepo.GetExistingCart("laptop", cartpkg.DefaultName)
+		assert.ErrorIs(t, err, cartpkg.ErrCartNotFound)
+	})
+
+	t.Run("moves the device to a new session", func(t *testing.T) {
+		laptop, err := cartRepo.GetDevice("laptop")
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.MoveDevice("laptop", "laptop-2"))
+		moved, err := cartRepo.GetDevice("laptop-2")
+		require.NoError(t, err)
+		assert.Equal(t, laptop.ID, moved.ID)
+		assert.WithinDuration(t, laptop.FirstSeenAt, moved.FirstSeenAt, 0)
+		_, err = cartRepo.GetDevice("laptop")
+		assert.ErrorIs(t, err, repo.ErrDeviceNotFound)
+	})
+}
@@ -0,0 +1,193 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	cartpkg "interview/internal/cart"
+	productpkg "interview/internal/product"
+	userpkg "interview/internal/user"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PurchasedDownloads are the downloads granted at the checkout of a cart whose links weren't emailed to
+// its user yet
+type PurchasedDownloads struct {
+	Cart      cartpkg.Cart
+	User      userpkg.User
+	Downloads []productpkg.Download
+}
+
+// SetDownloadLimits sets how often and for how long the digital products of carts checked out from now
+// on can be downloaded, 0 for no limit
+func (r *Repository) SetDownloadLimits(maxDownloads int, ttl time.Duration) {
+	r.downloadLimit = maxDownloads
+	r.downloadTTL = ttl
+}
+
+// SetProductType makes the product physical or digital. Products can only become digital once their
+// file was uploaded.
+func (r *Repository) SetProductType(id uint, productType string) error {
+	if productType != productpkg.TypePhysical && productType != productpkg.TypeDigital {
+		return productpkg.ErrInvalidType
+	}
+	var p productpkg.Product
+	if err := r.db.First(&p, id).Error; err != nil {
+		return fmt.Errorf("failed to get product: %w", err)
+	}
+	if productType == productpkg.TypeDigital && p.FileKey == "" {
+		return productpkg.ErrNoFile
+	}
+	if err := r.db.Model(&p).Update("type", productType).Error; err != nil {
+		return fmt.Errorf("failed to update product type: %w", err)
+	}
+	r.invalidateProduct(id)
+	return nil
+}
+
+// SetProductFile stores the storage key of the file customers of the digital product download
+func (r *Repository) SetProductFile(id uint, fileKey string) error {
+	if err := r.db.Model(&productpkg.Product{}).Where("id = ?", id).Update("file_key", fileKey).Error; err != nil {
+		return fmt.Errorf("failed to update product file: %w", err)
+	}
+	r.invalidateProduct(id)
+	return nil
+}
+
+// grantDownloads grants a download of every digital product in the cart checked out
+func (r *Repository) grantDownloads(tx *gorm.DB, cartID uint) error {
+	var products []productpkg.Product
+	err := tx.Where("type = ? AND name IN (?)", productpkg.TypeDigital,
+		tx.Model(&cartpkg.CartItem{}).Select("product_name").Where("cart_id = ?", cartID)).
+		Order("name").
+		Find(&products).Error
+	if err != nil {
+		return fmt.Errorf("failed to get digital products: %w", err)
+	}
+	if len(products) == 0 {
+		return nil
+	}
+
+	var expiresAt *time.Time
+	if r.downloadTTL > 0 {
+		expires := time.Now().Add(r.downloadTTL)
+		expiresAt = &expires
+	}
+	downloads := make([]productpkg.Download, len(products))
+	for i, p := range products {
+		downloads[i] = productpkg.Download{
+			CartID:       cartID,
+			ProductID:    p.ID,
+			ProductName:  p.Name,
+			MaxDownloads: r.downloadLimit,
+			ExpiresAt:    expiresAt,
+		}
+	}
+	if err := tx.Create(&downloads).Error; err != nil {
+		return fmt.Errorf("failed to grant downloads: %w", err)
+	}
+	return nil
+}
+
+// ListDownloads returns the downloads granted at the checkout of the cart
+func (r *Repository) ListDownloads(cartID uint) ([]productpkg.Download, error) {
+	var downloads []productpkg.Download
+	if err := r.db.Where("cart_id = ?", cartID).Order("id").Find(&downloads).Error; err != nil {
+		return nil, fmt.Errorf("failed to list downloads: %w", err)
+	}
+	return downloads, nil
+}
+
+// UseDownload counts a download of the file and returns the download. It fails with
+// productpkg.ErrDownloadExpired or productpkg.ErrDownloadLimit once the download can't be used anymore.
+func (r *Repository) UseDownload(id uint) (*productpkg.Download, error) {
+	now := time.Now()
+	result := r.db.Model(&productpkg.Download{}).
+		Where("id = ? AND (max_downloads = 0 OR used < max_downloads) AND (expires_at IS NULL OR expires_at > ?)", id, now).
+		Update("used", gorm.Expr("used + 1"))
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to use download: %w", result.Error)
+	}
+
+	var d productpkg.Download
+	if err := r.db.First(&d, id).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, productpkg.ErrDownloadNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get download: %w", err)
+	}
+	if result.RowsAffected == 0 {
+		if d.ExpiresAt != nil && !d.ExpiresAt.After(now) {
+			return nil, productpkg.ErrDownloadExpired
+		}
+		return nil, productpkg.ErrDownloadLimit
+	}
+	return &d, nil
+}
+
+// ListUnsentDownloads returns the downloads of up to limit checked out carts of users with an email
+// address whose links weren't emailed yet
+func (r *Repository) ListUnsentDownloads(limit int) ([]PurchasedDownloads, error) {
+	var carts []cartpkg.Cart
+	err := r.db.Joins("JOIN users ON users.id = carts.user_id AND users.deleted_at IS NULL").
+		Where("carts.status = ? AND users.email <> ''", cartpkg.StatusClosed).
+		Where("EXISTS (SELECT 1 FROM downloads WHERE downloads.cart_id = carts.id AND downloads.emailed_at IS NULL AND downloads.deleted_at IS NULL)").
+		Order("carts.id").
+		Limit(limit).
+		Find(&carts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list carts with unsent downloads: %w", err)
+	}
+	if len(carts) == 0 {
+		return nil, nil
+	}
+
+	cartIDs := make([]uint, len(carts))
+	userIDs := make([]uint, len(carts))
+	for i, c := range carts {
+		cartIDs[i] = c.ID
+		userIDs[i] = *c.UserID
+	}
+	var users []userpkg.User
+	if err := r.db.Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to load users: %w", err)
+	}
+	usersByID := make(map[uint]userpkg.User, len(users))
+	for _, u := range users {
+		usersByID[u.ID] = u
+	}
+	var downloads []productpkg.Download
+	if err := r.db.Where("cart_id IN ? AND emailed_at IS NULL", cartIDs).Order("id").Find(&downloads).Error; err != nil {
+		return nil, fmt.Errorf("failed to load downloads: %w", err)
+	}
+	downloadsByCart := make(map[uint][]productpkg.Download, len(carts))
+	for _, d := range downloads {
+		downloadsByCart[d.CartID] = append(downloadsByCart[d.CartID], d)
+	}
+
+	purchased := make([]PurchasedDownloads, len(carts))
+	for i, c := range carts {
+		purchased[i] = PurchasedDownloads{Cart: c, User: usersByID[*c.UserID], Downloads: downloadsByCart[c.ID]}
+	}
+	return purchased, nil
+}
+
+// ClaimDownloadEmail records that the download links of the cart are being emailed. It returns false
+// when they already were, e.g. by another instance running the same job.
+func (r *Repository) ClaimDownloadEmail(cartID uint) (bool, error) {
+	result := r.db.Model(&productpkg.Download{}).
+		Where("cart_id = ? AND emailed_at IS NULL", cartID).
+		Update("emailed_at", time.Now())
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to claim download email: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ReleaseDownloadEmail forgets a claimed download email that couldn't be sent, so the next run retries it
+func (r *Repository) ReleaseDownloadEmail(cartID uint) error {
+	if err := r.db.Model(&productpkg.Download{}).Where("cart_id = ?", cartID).Update("emailed_at", nil).Error; err != nil {
+		return fmt.Errorf("failed to release download email: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package repo_test
+
+import (
+	cartpkg "interview/internal/cart"
+	productpkg "interview/internal/product"
+	"interview/internal/repo"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestDownloads(t *testing.T) {
+	db := setupTestDB(t)
+	cartRepo := repo.NewRepository(db)
+	cartRepo.SetDownloadLimits(2, time.Hour)
+	ebook, err := cartRepo.UpsertProduct("ebook", 5)
+	require.NoError(t, err)
+	_, err = cartRepo.UpsertProduct("shoe", 10)
+	require.NoError(t, err)
+
+	// checkout closes a cart with the ebook and a shoe and returns its downloads
+	checkout := func(t *testing.T, sessionID string) (uint, []productpkg.Download) {
+		t.Helper()
+		c, err := cartRepo.GetOrCreateCart(sessionID, cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "ebook", 2, 5))
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 1, 10))
+		require.NoError(t, cartRepo.CloseCart(c.ID))
+		downloads, err := cartRepo.ListDownloads(c.ID)
+		require.NoError(t, err)
+		return c.ID, downloads
+	}
+
+	t.Run("digital products need a file", func(t *testing.T) {
+		assert.ErrorIs(t, cartRepo.SetProductType(ebook.ID, productpkg.TypeDigital), productpkg.ErrNoFile)
+		assert.ErrorIs(t, cartRepo.SetProductType(ebook.ID, "virtual"), productpkg.ErrInvalidType)
+		assert.ErrorIs(t, cartRepo.SetProductType(9999, productpkg.TypePhysical), gorm.ErrRecordNotFound)
+
+		require.NoError(t, cartRepo.SetProductFile(ebook.ID, "products/1/file.pdf"))
+		require.NoError(t, cartRepo.SetProductType(ebook.ID, productpkg.TypeDigital))
+		p, err := cartRepo.GetProduct(ebook.ID)
+		require.NoError(t, err)
+		assert.True(t, p.Digital())
+	})
+
+	t.Run("checkout grants downloads of digital products", func(t *testing.T) {
+		_, downloads := checkout(t, "download-session")
+		require.Len(t, downloads, 1)
+		d := downloads[0]
+		assert.Equal(t, ebook.ID, d.ProductID)
+		assert.Equal(t, "ebook", d.ProductName)
+		assert.Equal(t, 2, d.MaxDownloads)
+		require.NotNil(t, d.ExpiresAt)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), *d.ExpiresAt, time.Minute)
+	})
+
+	t.Run("downloads are limited", func(t *testing.T) {
+		_, downloads := checkout(t, "limited-session")
+		id := downloads[0].ID
+
+		d, err := cartRepo.UseDownload(id)
+		require.NoError(t, err)
+		assert.Equal(t, 1, d.Used)
+		assert.Equal(t, 1, d.Remaining())
+		_, err = cartRepo.UseDownload(id)
+		require.NoError(t, err)
+		_, err = cartRepo.UseDownload(id)
+		assert.ErrorIs(t, err, productpkg.ErrDownloadLimit)
+		_, err = cartRepo.UseDownload(9999)
+		assert.ErrorIs(t, err, productpkg.ErrDownloadNotFound)
+	})
+
+	t.Run("downloads expire", func(t *testing.T) {
+		_, downloads := checkout(t, "expired-session")
+		require.NoError(t, db.Model(&productpkg.Download{}).Where("id = ?", downloads[0].ID).
+			Update("expires_at", time.Now().Add(-time.Minute)).Error)
+
+		_, err := cartRepo.UseDownload(downloads[0].ID)
+		assert.ErrorIs(t, err, productpkg.ErrDownloadExpired)
+	})
+
+	t.Run("unlimited downloads", func(t *testing.T) {
+		cartRepo.SetDownloadLimits(0, 0)
+		defer cartRepo.SetDownloadLimits(2, time.Hour)
+		_, downloads := checkout(t, "unlimited-session")
+		assert.Nil(t, downloads[0].ExpiresAt)
+		for i := 0; i < 5; i++ {
+			d, err := cartRepo.UseDownload(downloads[0].ID)
+			require.NoError(t, err)
+			assert.Equal(t, -1, d.Remaining())
+		}
+	})
+
+	t.Run("download links are emailed once to users", func(t *testing.T) {
+		require.NoError(t, db.Exec("DELETE FROM downloads").Error)
+		user, err := cartRepo.FindOrCreateUser("github", "1", "jane@example.com", "Jane")
+		require.NoError(t, err)
+		c, err := cartRepo.GetOrCreateCart("user-session", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "ebook", 1, 5))
+		require.NoError(t, cartRepo.AssignCartToUser("user-session", user.ID))
+		require.NoError(t, cartRepo.CloseCart(c.ID))
+		// Downloads of anonymous carts are only shown on the order page
+		checkout(t, "anonymous-session")
+
+		unsent, err := cartRepo.ListUnsentDownloads(10)
+		require.NoError(t, err)
+		require.Len(t, unsent, 1)
+		assert.Equal(t, c.ID, unsent[0].Cart.ID)
+		assert.Equal(t, "jane@example.com", unsent[0].User.Email)
+		require.Len(t, unsent[0].Downloads, 1)
+
+		claimed, err := cartRepo.ClaimDownloadEmail(c.ID)
+		require.NoError(t, err)
+		assert.True(t, claimed)
+		claimed, err = cartRepo.ClaimDownloadEmail(c.ID)
+		require.NoError(t, err)
+		assert.False(t, claimed)
+		unsent, err = cartRepo.ListUnsentDownloads(10)
+		require.NoError(t, err)
+		assert.Empty(t, unsent)
+
+		require.NoError(t, cartRepo.ReleaseDownloadEmail(c.ID))
+		unsent, err = cartRepo.ListUnsentDownloads(10)
+		require.NoError(t, err)
+		assert.Len(t, unsent, 1)
+	})
+}
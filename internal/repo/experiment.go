@@ -0,0 +1,88 @@
+package repo
+
+import (
+	"fmt"
+	cartpkg "interview/internal/cart"
+	"interview/internal/experiment"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+// ExperimentResult sums up the conversions of a variant of an experiment
+type ExperimentResult struct {
+	Experiment string
+	Variant    string
+	// Sessions is the number of sessions assigned to the variant
+	Sessions int64
+	// AddToCartSessions is the number of those sessions that added a product to a cart
+	AddToCartSessions int64
+	// Checkouts is the number of carts of those sessions checked out
+	Checkouts int64
+}
+
+// RecordConversions records an event of the session for every experiment it takes part in, with
+// variants mapping experiment names to the variant of the session
+func (r *Repository) RecordConversions(sessionID, event string, variants map[string]string) error {
+	if len(variants) == 0 {
+		return nil
+	}
+	// Sorted so the rows are written in the same order every time
+	names := make([]string, 0, len(variants))
+	for name := range variants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	conversions := make([]experiment.Conversion, len(names))
+	for i, name := range names {
+		conversions[i] = experiment.Conversion{Experiment: name, Variant: variants[name], Event: event, SessionID: sessionID}
+	}
+	if err := r.db.Create(&conversions).Error; err != nil {
+		return fmt.Errorf("failed to record conversions: %w", err)
+	}
+	return nil
+}
+
+// ListExperimentResults returns the number of sessions, add-to-cart sessions and checkouts of every
+// variant, by experiment and variant
+func (r *Repository) ListExperimentResults() ([]ExperimentResult, error) {
+	var results []ExperimentResult
+	err := r.reader().Model(&experiment.Conversion{}).
+		Select("experiment, variant, "+
+			"COUNT(DISTINCT CASE WHEN event = ? THEN session_id END) AS sessions, "+
+			"COUNT(DISTINCT CASE WHEN event = ? THEN session_id END) AS add_to_cart_sessions, "+
+			"SUM(CASE WHEN event = ? THEN 1 ELSE 0 END) AS checkouts",
+			experiment.EventAssigned, experiment.EventAddToCart, experiment.EventCheckout).
+		Group("experiment, variant").
+		Order("experiment, variant").
+		Scan(&results).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list experiment results: %w", err)
+	}
+	return results, nil
+}
+
+// recordCheckoutConversions records the checkout of a cart for the variants its session was assigned
+func recordCheckoutConversions(tx *gorm.DB, cart *cartpkg.Cart) error {
+	var assigned []experiment.Conversion
+	err := tx.Where("session_id = ? AND event = ?", cart.SessionID, experiment.EventAssigned).
+		Find(&assigned).Error
+	if err != nil {
+		return fmt.Errorf("failed to load experiment variants: %w", err)
+	}
+	if len(assigned) == 0 {
+		return nil
+	}
+
+	checkouts := make([]experiment.Conversion, len(assigned))
+	for i, a := range assigned {
+		checkouts[i] = experiment.Conversion{
+			Experiment: a.Experiment, Variant: a.Variant, Event: experiment.EventCheckout, SessionID: cart.SessionID,
+		}
+	}
+	if err := tx.Create(&checkouts).Error; err != nil {
+		return fmt.Errorf("failed to record checkout conversions: %w", err)
+	}
+	return nil
+}
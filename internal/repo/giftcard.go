@@ -0,0 +1,137 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	cartpkg "interview/internal/cart"
+	"interview/internal/giftcard"
+	"math"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrGiftCardNotFound is returned when no gift card has the given code
+	ErrGiftCardNotFound = errors.New("gift card not found")
+	// ErrGiftCardEmpty is returned when redeeming a gift card without balance left
+	ErrGiftCardEmpty = errors.New("gift card has no balance left")
+	// ErrNothingToPay is returned when redeeming a gift card against a cart with nothing left to pay
+	ErrNothingToPay = errors.New("cart has nothing left to pay")
+)
+
+// CreateGiftCard issues a gift card with the given code and initial balance
+func (r *Repository) CreateGiftCard(code string, balance float64) (*giftcard.GiftCard, error) {
+	if balance <= 0 {
+		return nil, errors.New("gift card balance must be greater than 0")
+	}
+	card := giftcard.GiftCard{Code: giftcard.NormalizeCode(code), Balance: balance}
+	if err := r.db.Create(&card).Error; err != nil {
+		return nil, fmt.Errorf("failed to create gift card: %w", err)
+	}
+	return &card, nil
+}
+
+// GetGiftCard returns the gift card with the given code and its redemptions
+func (r *Repository) GetGiftCard(code string) (*giftcard.GiftCard, error) {
+	var card giftcard.GiftCard
+	err := r.db.Preload("Redemptions").Where("code = ?", giftcard.NormalizeCode(code)).First(&card).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrGiftCardNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get gift card: %w", err)
+	}
+	return &card, nil
+}
+
+// RedeemGiftCard applies as much of the gift card's balance as the cart total allows and returns the
+// redeemed amount. The balance deduction, the redemption record and the cart total are updated in one
+// transaction; the balance is only decremented if it still covers the amount, so concurrent
+// redemptions of the same card can't overdraw it.
+//
+// Redeemed credit stays on the cart: items added later are paid from it first, and credit exceeding
+// the total after items are removed goes back to the card (see returnCredit).
+func (r *Repository) RedeemGiftCard(cartID uint, code string) (float64, error) {
+	var amount float64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		cart, err := openCart(tx, cartID)
+		if err != nil {
+			return err
+		}
+		var card giftcard.GiftCard
+		err = tx.Where("code = ?", giftcard.NormalizeCode(code)).First(&card).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrGiftCardNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to get gift card: %w", err)
+		}
+		if card.Balance <= 0 {
+			return ErrGiftCardEmpty
+		}
+		if cart.Total <= 0 {
+			return ErrNothingToPay
+		}
+
+		amount = math.Min(card.Balance, cart.Total)
+		result := tx.Model(&giftcard.GiftCard{}).
+			Where("id = ? AND balance >= ?", card.ID, amount).
+			Update("balance", gorm.Expr("balance - ?", amount))
+		if result.Error != nil {
+			return fmt.Errorf("failed to update gift card balance: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrConflict
+		}
+
+		redemption := giftcard.Redemption{GiftCardID: card.ID, CartID: cart.ID, Amount: amount}
+		if err := tx.Create(&redemption).Error; err != nil {
+			return fmt.Errorf("failed to record redemption: %w", err)
+		}
+
+		cart.Credit += amount
+		if err := tx.Model(&cart).Update("credit", cart.Credit).Error; err != nil {
+			return fmt.Errorf("failed to update cart credit: %w", err)
+		}
+		if err := recordChange(tx, cartpkg.Change{CartID: cart.ID, Action: cartpkg.ChangeGiftCard, Amount: amount}); err != nil {
+			return err
+		}
+		return r.updateCartTotal(tx, cart)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return amount, nil
+}
+
+// returnCredit pays the part of the cart's credit exceeding what it costs back to the gift cards it was
+// redeemed from, most recently redeemed first. Each return is recorded as a redemption with a negative
+// amount, so a card's redemptions still add up to what it paid for.
+func returnCredit(db *gorm.DB, cart *cartpkg.Cart, excess float64) error {
+	var redemptions []giftcard.Redemption
+	if err := db.Where("cart_id = ?", cart.ID).Order("id DESC").Find(&redemptions).Error; err != nil {
+		return fmt.Errorf("failed to load redemptions: %w", err)
+	}
+	redeemed := make(map[uint]float64)
+	for _, redemption := range redemptions {
+		redeemed[redemption.GiftCardID] += redemption.Amount
+	}
+
+	for _, redemption := range redemptions {
+		amount := math.Round(math.Min(excess, redeemed[redemption.GiftCardID])*100) / 100
+		if amount <= 0 {
+			continue
+		}
+		err := db.Model(&giftcard.GiftCard{}).Where("id = ?", redemption.GiftCardID).
+			Update("balance", gorm.Expr("balance + ?", amount)).Error
+		if err != nil {
+			return fmt.Errorf("failed to update gift card balance: %w", err)
+		}
+		returned := giftcard.Redemption{GiftCardID: redemption.GiftCardID, CartID: cart.ID, Amount: -amount}
+		if err := db.Create(&returned).Error; err != nil {
+			return fmt.Errorf("failed to record returned credit: %w", err)
+		}
+		redeemed[redemption.GiftCardID] -= amount
+		excess -= amount
+		cart.Credit = math.Round((cart.Credit-amount)*100) / 100
+	}
+	return nil
+}
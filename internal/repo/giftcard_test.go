@@ -0,0 +1,107 @@
+package repo_test
+
+import (
+	cartpkg "interview/internal/cart"
+	"interview/internal/repo"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedeemGiftCard(t *testing.T) {
+	db := setupTestDB(t)
+	cartRepo := repo.NewRepository(db)
+
+	_, err := cartRepo.CreateGiftCard("gift-100", 100.0)
+	require.NoError(t, err)
+
+	t.Run("Partial Balance", func(t *testing.T) {
+		c, err := cartRepo.GetOrCreateCart("gift-session-1", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "bag", 2, 30.0))
+
+		amount, err := cartRepo.RedeemGiftCard(c.ID, " gift-100 ")
+		require.NoError(t, err)
+		assert.Equal(t, 60.0, amount)
+
+		c, err = cartRepo.GetOrCreateCart("gift-session-1", cartpkg.DefaultName)
+		require.NoError(t, err)
+		assert.Equal(t, 60.0, c.Credit)
+		assert.Equal(t, 0.0, c.Total)
+
+		card, err := cartRepo.GetGiftCard("GIFT-100")
+		require.NoError(t, err)
+		assert.Equal(t, 40.0, card.Balance)
+		require.Len(t, card.Redemptions, 1)
+		assert.Equal(t, c.ID, card.Redemptions[0].CartID)
+	})
+
+	t.Run("Credit Pays For Items Added Later", func(t *testing.T) {
+		c, err := cartRepo.GetOrCreateCart("gift-session-1", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 1, 10.0))
+
+		c, err = cartRepo.GetOrCreateCart("gift-session-1", cartpkg.DefaultName)
+		require.NoError(t, err)
+		assert.Equal(t, 10.0, c.Total)
+	})
+
+	t.Run("Remaining Balance Exhausted", func(t *testing.T) {
+		c, err := cartRepo.GetOrCreateCart("gift-session-2", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "watch", 1, 50.0))
+
+		amount, err := cartRepo.RedeemGiftCard(c.ID, "gift-100")
+		require.NoError(t, err)
+		assert.Equal(t, 40.0, amount)
+
+		c, err = cartRepo.GetOrCreateCart("gift-session-2", cartpkg.DefaultName)
+		require.NoError(t, err)
+		assert.Equal(t, 10.0, c.Total)
+
+		_, err = cartRepo.RedeemGiftCard(c.ID, "gift-100")
+		assert.ErrorIs(t, err, repo.ErrGiftCardEmpty)
+	})
+
+	t.Run("Unknown Code", func(t *testing.T) {
+		c, err := cartRepo.GetOrCreateCart("gift-session-2", cartpkg.DefaultName)
+		require.NoError(t, err)
+		_, err = cartRepo.RedeemGiftCard(c.ID, "nope")
+		assert.ErrorIs(t, err, repo.ErrGiftCardNotFound)
+	})
+
+	t.Run("Nothing To Pay", func(t *testing.T) {
+		_, err := cartRepo.CreateGiftCard("gift-10", 10.0)
+		require.NoError(t, err)
+		c, err := cartRepo.GetOrCreateCart("gift-session-3", cartpkg.DefaultName)
+		require.NoError(t, err)
+		_, err = cartRepo.RedeemGiftCard(c.ID, "gift-10")
+		assert.ErrorIs(t, err, repo.ErrNothingToPay)
+	})
+	t.Run("Credit Above The Total Returns To The Card", func(t *testing.T) {
+		_, err := cartRepo.CreateGiftCard("gift-50", 50.0)
+		require.NoError(t, err)
+		c, err := cartRepo.GetOrCreateCart("gift-session-4", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "bag", 1, 30.0))
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 1, 10.0))
+		_, err = cartRepo.RedeemGiftCard(c.ID, "gift-50")
+		require.NoError(t, err)
+
+		c, err = cartRepo.GetOrCreateCart("gift-session-4", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.RemoveCartItem(c.ID, c.CartItems[0].ID))
+
+		c, err = cartRepo.GetOrCreateCart("gift-session-4", cartpkg.DefaultName)
+		require.NoError(t, err)
+		assert.Equal(t, 10.0, c.Credit)
+		assert.Equal(t, 0.0, c.Total)
+
+		card, err := cartRepo.GetGiftCard("gift-50")
+		require.NoError(t, err)
+		assert.Equal(t, 40.0, card.Balance)
+		require.Len(t, card.Redemptions, 2)
+		assert.Equal(t, -30.0, card.Redemptions[1].Amount)
+	})
+}
@@ -0,0 +1,62 @@
+package repo
+
+import (
+	"fmt"
+	"interview/internal/inventory"
+	productpkg "interview/internal/product"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ApplyInventory upserts the products of the batch, unless a batch with its event ID was applied
+// before. Updates that can't be applied, see IsProductImportFailure, are reported as failed without
+// failing the others; database errors fail the whole batch, so the ERP can send it again.
+func (r *Repository) ApplyInventory(batch inventory.Batch) (*inventory.Report, error) {
+	report := &inventory.Report{EventID: batch.EventID, Failed: []inventory.Failure{}}
+	var changed []productpkg.Product
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		event := inventory.Event{EventID: batch.EventID}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&event)
+		if result.Error != nil {
+			return fmt.Errorf("failed to record inventory event: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			report.Duplicate = true
+			return nil
+		}
+
+		for i, update := range batch.Updates {
+			p, created, err := upsertProduct(tx, ProductImport{Name: update.Product, Price: update.Price, Stock: update.Stock})
+			if IsProductImportFailure(err) {
+				report.Failed = append(report.Failed, inventory.Failure{Index: i, Product: update.Product, Error: err.Error()})
+				continue
+			} else if err != nil {
+				return err
+			}
+			if created {
+				report.Created++
+			} else {
+				report.Updated++
+			}
+			changed = append(changed, *p)
+		}
+
+		err := tx.Model(&event).Updates(map[string]interface{}{
+			"created": report.Created, "updated": report.Updated, "failed": len(report.Failed),
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to record inventory event: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range changed {
+		r.invalidateProduct(p.ID)
+		r.indexProduct(p)
+	}
+	return report, nil
+}
@@ -0,0 +1,79 @@
+package repo_test
+
+import (
+	"interview/internal/inventory"
+	"interview/internal/repo"
+	"interview/internal/warehouse"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyInventory(t *testing.T) {
+	db := setupTestDB(t)
+	cartRepo := repo.NewRepository(db)
+	shoe, err := cartRepo.UpsertProduct("shoe", 10)
+	require.NoError(t, err)
+	hat, err := cartRepo.UpsertProduct("hat", 5)
+	require.NoError(t, err)
+	berlin := warehouse.Warehouse{Name: "Berlin", Country: "DE"}
+	require.NoError(t, cartRepo.CreateWarehouse(&berlin))
+	_, err = cartRepo.SetWarehouseStock(berlin.ID, hat.ID, 3)
+	require.NoError(t, err)
+
+	price := func(v float64) *float64 { return &v }
+	stock := func(v int) *int { return &v }
+
+	t.Run("upserts products and reports failed updates", func(t *testing.T) {
+		report, err := cartRepo.ApplyInventory(inventory.Batch{EventID: "evt_1", Updates: []inventory.Update{
+			{Product: "shoe", Price: price(12), Stock: stock(7)},
+			{Product: "sock", Price: price(2), Stock: stock(50)},
+			{Product: "scarf", Stock: stock(1)},
+			{Product: "hat", Stock: stock(9)},
+			{Product: "shoe", Price: price(-1)},
+		}})
+		require.NoError(t, err)
+		assert.Equal(t, &inventory.Report{EventID: "evt_1", Created: 1, Updated: 1, Failed: []inventory.Failure{
+			{Index: 2, Product: "scarf", Error: "price is required for new products"},
+			{Index: 3, Product: "hat", Error: "stock of the product is kept per warehouse"},
+			{Index: 4, Product: "shoe", Error: "price must be at least 0"},
+		}}, report)
+
+		updated, err := cartRepo.GetProduct(shoe.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 12.0, updated.Price)
+		require.NotNil(t, updated.Stock)
+		assert.Equal(t, 7, *updated.Stock)
+
+		created, err := cartRepo.GetProductsByName([]string{"sock", "scarf"})
+		require.NoError(t, err)
+		require.Contains(t, created, "sock")
+		assert.NotContains(t, created, "scarf")
+		assert.Equal(t, 2.0, created["sock"].Price)
+	})
+
+	t.Run("leaves out fields that aren't sent", func(t *testing.T) {
+		_, err := cartRepo.ApplyInventory(inventory.Batch{EventID: "evt_2", Updates: []inventory.Update{
+			{Product: "shoe", Stock: stock(3)},
+		}})
+		require.NoError(t, err)
+		updated, err := cartRepo.GetProduct(shoe.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 12.0, updated.Price)
+		assert.Equal(t, 3, *updated.Stock)
+	})
+
+	t.Run("ignores batches applied before", func(t *testing.T) {
+		report, err := cartRepo.ApplyInventory(inventory.Batch{EventID: "evt_1", Updates: []inventory.Update{
+			{Product: "shoe", Price: price(99)},
+		}})
+		require.NoError(t, err)
+		assert.True(t, report.Duplicate)
+		assert.Empty(t, report.Failed)
+
+		updated, err := cartRepo.GetProduct(shoe.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 12.0, updated.Price)
+	})
+}
@@ -0,0 +1,95 @@
+package repo
+
+import (
+	"fmt"
+	"interview/internal/jobs"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// EnqueueJob stores a new job of the queue
+func (r *Repository) EnqueueJob(job *jobs.Job) error {
+	if err := r.db.Create(job).Error; err != nil {
+		return fmt.Errorf("failed to enqueue job: %w", err)
+	}
+	return nil
+}
+
+// ClaimJobs returns up to limit pending jobs of the types that are due, oldest first, and pushes their
+// run time back by lease so other workers skip them while they run
+func (r *Repository) ClaimJobs(types []string, now time.Time, lease time.Duration, limit int) ([]jobs.Job, error) {
+	if len(types) == 0 {
+		return nil, nil
+	}
+	var due []jobs.Job
+	err := r.db.Where("status = ? AND run_at <= ? AND type IN ?", jobs.StatusPending, now, types).
+		Order("run_at").
+		Limit(limit).
+		Find(&due).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load due jobs: %w", err)
+	}
+
+	claimed := due[:0]
+	for _, job := range due {
+		result := r.db.Model(&jobs.Job{}).
+			Where("id = ? AND status = ? AND run_at = ?", job.ID, jobs.StatusPending, job.RunAt).
+			Update("run_at", now.Add(lease))
+		if result.Error != nil {
+			return nil, fmt.Errorf("failed to claim job: %w", result.Error)
+		}
+		if result.RowsAffected == 1 {
+			claimed = append(claimed, job)
+		}
+	}
+	return claimed, nil
+}
+
+// SaveJob stores the outcome of a failed run of a job
+func (r *Repository) SaveJob(job *jobs.Job) error {
+	if err := r.db.Save(job).Error; err != nil {
+		return fmt.Errorf("failed to save job: %w", err)
+	}
+	return nil
+}
+
+// DeleteJob removes a job that succeeded
+func (r *Repository) DeleteJob(id uint) error {
+	if err := r.db.Unscoped().Delete(&jobs.Job{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete job: %w", err)
+	}
+	return nil
+}
+
+// AcquireLock takes the lock of a scheduled job for owner until the given time, if it is free, expired at
+// now or already held by owner
+func (r *Repository) AcquireLock(name, owner string, now, until time.Time) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&jobs.Lock{Name: name, Owner: owner, ExpiresAt: until})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to acquire lock %s: %w", name, result.Error)
+	}
+	if result.RowsAffected == 1 {
+		return true, nil
+	}
+
+	result = r.db.Model(&jobs.Lock{}).
+		Where("name = ? AND (owner = ? OR expires_at <= ?)", name, owner, now).
+		Updates(map[string]any{"owner": owner, "expires_at": until})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to acquire lock %s: %w", name, result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// ReleaseLock shortens the lock of a scheduled job held by owner to the given time
+func (r *Repository) ReleaseLock(name, owner string, until time.Time) error {
+	err := r.db.Model(&jobs.Lock{}).
+		Where("name = ? AND owner = ?", name, owner).
+		Update("expires_at", until).Error
+	if err != nil {
+		return fmt.Errorf("failed to release lock %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/utils"
+)
+
+// slowQueries counts the queries that took longer than the slow query threshold, shown at /admin/metrics
+var slowQueries = expvar.NewInt("slow_queries")
+
+// QueryLogger is the GORM logger of the application. It logs failed queries and, when a threshold is set,
+// queries taking at least that long through slog, counting the latter in the slow_queries metric. Queries
+// are logged with placeholders instead of their bound parameters, which hold emails, addresses and tokens.
+type QueryLogger struct {
+	logger        *slog.Logger
+	slowThreshold time.Duration
+	level         logger.LogLevel
+}
+
+var (
+	_ logger.Interface  = (*QueryLogger)(nil)
+	_ gorm.ParamsFilter = (*QueryLogger)(nil)
+)
+
+// NewQueryLogger creates a QueryLogger writing to l that logs queries taking slowThreshold or longer, none
+// when it is 0.
+func NewQueryLogger(l *slog.Logger, slowThreshold time.Duration) *QueryLogger {
+	return &QueryLogger{logger: l, slowThreshold: slowThreshold, level: logger.Warn}
+}
+
+// LogMode implements logger.Interface. At logger.Info, e.g. with db.Debug(), every query is logged.
+func (l *QueryLogger) LogMode(level logger.LogLevel) logger.Interface {
+	copied := *l
+	copied.level = level
+	return &copied
+}
+
+// Info implements logger.Interface.
+func (l *QueryLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= logger.Info {
+		l.logger.InfoContext(ctx, fmt.Sprintf(msg, data...), "source", utils.FileWithLineNum())
+	}
+}
+
+// Warn implements logger.Interface.
+func (l *QueryLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= logger.Warn {
+		l.logger.WarnContext(ctx, fmt.Sprintf(msg, data...), "source", utils.FileWithLineNum())
+	}
+}
+
+// Error implements logger.Interface.
+func (l *QueryLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= logger.Error {
+		l.logger.ErrorContext(ctx, fmt.Sprintf(msg, data...), "source", utils.FileWithLineNum())
+	}
+}
+
+// Trace implements logger.Interface. Missing records are expected by most lookups and aren't logged as
+// failures.
+func (l *QueryLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= logger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+	slow := l.slowThreshold > 0 && elapsed >= l.slowThreshold
+	if slow {
+		slowQueries.Add(1)
+	}
+
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && l.level >= logger.Error:
+		sql, rows := fc()
+		l.logger.ErrorContext(ctx, "Query failed", "sql", sql, "rows", rows, "duration", elapsed,
+			"source", utils.FileWithLineNum(), "error", err)
+	case slow && l.level >= logger.Warn:
+		sql, rows := fc()
+		l.logger.WarnContext(ctx, "Slow query", "sql", sql, "rows", rows, "duration", elapsed,
+			"threshold", l.slowThreshold, "source", utils.FileWithLineNum())
+	case l.level >= logger.Info:
+		sql, rows := fc()
+		l.logger.InfoContext(ctx, "Query", "sql", sql, "rows", rows, "duration", elapsed,
+			"source", utils.FileWithLineNum())
+	}
+}
+
+// ParamsFilter implements gorm.ParamsFilter, dropping the bound parameters from logged queries.
+func (l *QueryLogger) ParamsFilter(_ context.Context, sql string, _ ...interface{}) (string, []interface{}) {
+	return sql, nil
+}
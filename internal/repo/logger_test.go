@@ -0,0 +1,79 @@
+package repo_test
+
+import (
+	"bytes"
+	"expvar"
+	"interview/internal/repo"
+	userpkg "interview/internal/user"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestQueryLogger(t *testing.T) {
+	open := func(t *testing.T, slowThreshold time.Duration) (*gorm.DB, *bytes.Buffer) {
+		t.Helper()
+		var logs bytes.Buffer
+		queryLogger := repo.NewQueryLogger(slog.New(slog.NewJSONHandler(&logs, nil)), slowThreshold)
+		db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: queryLogger})
+		require.NoError(t, err)
+		require.NoError(t, db.AutoMigrate(&userpkg.User{}))
+		logs.Reset()
+		return db, &logs
+	}
+	slowQueries := func() int64 {
+		return expvar.Get("slow_queries").(*expvar.Int).Value()
+	}
+
+	t.Run("logs slow queries without their parameters", func(t *testing.T) {
+		db, logs := open(t, time.Nanosecond)
+		before := slowQueries()
+
+		var u userpkg.User
+		err := db.Where("email = ?", "jane@example.com").First(&u).Error
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+		assert.Contains(t, logs.String(), `"level":"WARN","msg":"Slow query"`)
+		assert.Contains(t, logs.String(), "email = ?")
+		assert.NotContains(t, logs.String(), "jane@example.com")
+		assert.NotContains(t, logs.String(), "Query failed", "missing records aren't failures")
+		assert.Equal(t, before+1, slowQueries())
+	})
+
+	t.Run("logs nothing for fast queries", func(t *testing.T) {
+		db, logs := open(t, time.Hour)
+		before := slowQueries()
+
+		require.NoError(t, db.Create(&userpkg.User{Email: "jane@example.com"}).Error)
+		assert.Empty(t, logs.String())
+		assert.Equal(t, before, slowQueries())
+	})
+
+	t.Run("a zero threshold disables slow query logging", func(t *testing.T) {
+		db, logs := open(t, 0)
+		var count int64
+		require.NoError(t, db.Model(&userpkg.User{}).Count(&count).Error)
+		assert.Empty(t, logs.String())
+	})
+
+	t.Run("logs failed queries", func(t *testing.T) {
+		db, logs := open(t, 0)
+		err := db.Exec("UPDATE missing SET email = ?", "jane@example.com").Error
+		require.Error(t, err)
+		assert.Contains(t, logs.String(), `"level":"ERROR","msg":"Query failed"`)
+		assert.Contains(t, logs.String(), "no such table")
+		assert.NotContains(t, logs.String(), "jane@example.com")
+	})
+
+	t.Run("logs every query in debug mode", func(t *testing.T) {
+		db, logs := open(t, 0)
+		var count int64
+		require.NoError(t, db.Debug().Model(&userpkg.User{}).Count(&count).Error)
+		assert.Contains(t, logs.String(), `"msg":"Query"`)
+	})
+}
@@ -0,0 +1,69 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	cartpkg "interview/internal/cart"
+
+	"gorm.io/gorm"
+)
+
+// SetCartMetadata merges the changes into the metadata of an open cart, see cart.Metadata.Merge, and
+// returns the resulting metadata
+func (r *Repository) SetCartMetadata(cartID uint, changes cartpkg.Metadata) (cartpkg.Metadata, error) {
+	if err := changes.Validate(); err != nil {
+		return nil, err
+	}
+
+	var merged cartpkg.Metadata
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		cart, err := openCart(tx, cartID)
+		if err != nil {
+			return err
+		}
+		merged = cart.Metadata.Merge(changes)
+		if err := merged.Validate(); err != nil {
+			return err
+		}
+		if err := tx.Model(&cartpkg.Cart{}).Where("id = ?", cartID).Update("metadata", merged).Error; err != nil {
+			return fmt.Errorf("failed to update metadata: %w", err)
+		}
+		return r.updateCartTotal(tx, cart)
+	})
+	return merged, err
+}
+
+// SetItemMetadata merges the changes into the metadata of an item of an open cart, see
+// cart.Metadata.Merge, and returns the resulting metadata
+func (r *Repository) SetItemMetadata(cartID uint, itemID uint, changes cartpkg.Metadata) (cartpkg.Metadata, error) {
+	if err := changes.Validate(); err != nil {
+		return nil, err
+	}
+
+	var merged cartpkg.Metadata
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		cart, err := openCart(tx, cartID)
+		if err != nil {
+			return err
+		}
+
+		var item cartpkg.CartItem
+		err = tx.Where("cart_id = ? AND id = ?", cartID, itemID).First(&item).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return cartpkg.ErrItemNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to get item: %w", err)
+		}
+		merged = item.Metadata.Merge(changes)
+		if err := merged.Validate(); err != nil {
+			return err
+		}
+		item.Metadata = merged
+		if err := tx.Save(&item).Error; err != nil {
+			return fmt.Errorf("failed to update item: %w", err)
+		}
+
+		return r.updateCartTotal(tx, cart)
+	})
+	return merged, err
+}
@@ -0,0 +1,103 @@
+package repo_test
+
+import (
+	cartpkg "interview/internal/cart"
+	"interview/internal/repo"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadata(t *testing.T) {
+	db := setupTestDB(t)
+	cartRepo := repo.NewRepository(db)
+
+	c, err := cartRepo.GetOrCreateCart("metadata-session", cartpkg.DefaultName)
+	require.NoError(t, err)
+	require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 1, 10))
+	c, err = cartRepo.GetCart(c.ID)
+	require.NoError(t, err)
+	itemID := c.CartItems[0].ID
+
+	t.Run("cart metadata is merged", func(t *testing.T) {
+		merged, err := cartRepo.SetCartMetadata(c.ID, cartpkg.Metadata{"campaign": "spring", "priority": 2.0, "gift": true})
+		require.NoError(t, err)
+		assert.Len(t, merged, 3)
+		_, err = cartRepo.SetCartMetadata(c.ID, cartpkg.Metadata{"gift": nil, "priority": 3.0})
+		require.NoError(t, err)
+
+		stored, err := cartRepo.GetCart(c.ID)
+		require.NoError(t, err)
+		campaign, ok := stored.Metadata.String("campaign")
+		assert.True(t, ok)
+		assert.Equal(t, "spring", campaign)
+		priority, ok := stored.Metadata.Int("priority")
+		assert.True(t, ok)
+		assert.Equal(t, 3, priority)
+		_, ok = stored.Metadata.Bool("gift")
+		assert.False(t, ok, "keys set to null are removed")
+		_, ok = stored.Metadata.Float("campaign")
+		assert.False(t, ok, "accessors check the type")
+		assert.Greater(t, stored.Version, c.Version, "changing metadata is a change of the cart")
+	})
+
+	t.Run("item metadata is merged", func(t *testing.T) {
+		_, err := cartRepo.SetItemMetadata(c.ID, itemID, cartpkg.Metadata{"engraving": "For Sam", "ref": "ext-1"})
+		require.NoError(t, err)
+		merged, err := cartRepo.SetItemMetadata(c.ID, itemID, cartpkg.Metadata{"ref": nil})
+		require.NoError(t, err)
+		assert.Equal(t, cartpkg.Metadata{"engraving": "For Sam"}, merged)
+
+		item, err := cartRepo.GetCartItem(c.ID, itemID)
+		require.NoError(t, err)
+		engraving, _ := item.Metadata.String("engraving")
+		assert.Equal(t, "For Sam", engraving)
+
+		_, err = cartRepo.SetItemMetadata(c.ID, itemID+100, cartpkg.Metadata{"ref": "x"})
+		assert.ErrorIs(t, err, cartpkg.ErrItemNotFound)
+
+		// Removing every key leaves no metadata
+		merged, err = cartRepo.SetItemMetadata(c.ID, itemID, cartpkg.Metadata{"engraving": nil})
+		require.NoError(t, err)
+		assert.Nil(t, merged)
+		item, err = cartRepo.GetCartItem(c.ID, itemID)
+		require.NoError(t, err)
+		assert.Nil(t, item.Metadata)
+	})
+
+	t.Run("invalid metadata is rejected", func(t *testing.T) {
+		tooMany := cartpkg.Metadata{}
+		for i := 0; i < 51; i++ {
+			tooMany[strings.Repeat("k", i+1)] = "v"
+		}
+		tests := []struct {
+			name     string
+			metadata cartpkg.Metadata
+		}{
+			{"too many keys", tooMany},
+			{"long key", cartpkg.Metadata{strings.Repeat("k", 41): "v"}},
+			{"empty key", cartpkg.Metadata{"": "v"}},
+			{"long value", cartpkg.Metadata{"note": strings.Repeat("v", 501)}},
+			{"nested value", cartpkg.Metadata{"nested": map[string]interface{}{"a": "b"}}},
+			{"list value", cartpkg.Metadata{"list": []interface{}{"a"}}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := cartRepo.SetCartMetadata(c.ID, tt.metadata)
+				assert.ErrorIs(t, err, cartpkg.ErrInvalidMetadata)
+			})
+		}
+	})
+
+	t.Run("closed carts keep their metadata", func(t *testing.T) {
+		require.NoError(t, cartRepo.CloseCart(c.ID))
+		_, err := cartRepo.SetCartMetadata(c.ID, cartpkg.Metadata{"campaign": "summer"})
+		assert.ErrorIs(t, err, cartpkg.ErrCartClosed)
+		closed, err := cartRepo.GetCart(c.ID)
+		require.NoError(t, err)
+		campaign, _ := closed.Metadata.String("campaign")
+		assert.Equal(t, "spring", campaign)
+	})
+}
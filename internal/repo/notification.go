@@ -0,0 +1,89 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	cartpkg "interview/internal/cart"
+	"interview/internal/notification"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// notificationOwner scopes a query to the notifications of the user, or of the anonymous session when
+// userID is 0
+func notificationOwner(db *gorm.DB, userID uint, sessionID string) *gorm.DB {
+	if userID != 0 {
+		return db.Where("user_id = ?", userID)
+	}
+	return db.Where("session_id = ? AND user_id IS NULL", sessionID)
+}
+
+// NotifyCartOwner stores the notification for the user the cart belongs to, or its session when the
+// cart is anonymous. It fails with ErrCartNotFound for missing carts.
+func (r *Repository) NotifyCartOwner(cartID uint, n *notification.Notification) error {
+	var owner cartpkg.Cart
+	err := r.db.Select("id", "session_id", "user_id").First(&owner, cartID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return cartpkg.ErrCartNotFound
+	} else if err != nil {
+		return fmt.Errorf("failed to load cart: %w", err)
+	}
+	n.UserID, n.SessionID = owner.UserID, owner.SessionID
+	if err := r.db.Create(n).Error; err != nil {
+		return fmt.Errorf("failed to create notification: %w", err)
+	}
+	return nil
+}
+
+// ListNotifications returns up to limit notifications of the user or anonymous session, newest first,
+// and how many of all their notifications are unread
+func (r *Repository) ListNotifications(userID uint, sessionID string, limit int) ([]notification.Notification, int64, error) {
+	var notifications []notification.Notification
+	err := notificationOwner(r.reader(), userID, sessionID).Order("id DESC").Limit(limit).Find(&notifications).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list notifications: %w", err)
+	}
+	var unread int64
+	err = notificationOwner(r.reader().Model(&notification.Notification{}), userID, sessionID).
+		Where("read_at IS NULL").Count(&unread).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count unread notifications: %w", err)
+	}
+	return notifications, unread, nil
+}
+
+// MarkNotificationRead marks a notification of the user or anonymous session read at the time, keeping
+// the time it was first read. It fails with ErrNotificationNotFound for notifications of others.
+func (r *Repository) MarkNotificationRead(userID uint, sessionID string, id uint, at time.Time) error {
+	owned := notificationOwner(r.db.Model(&notification.Notification{}), userID, sessionID).
+		Where("id = ?", id).
+		Session(&gorm.Session{})
+	result := owned.Where("read_at IS NULL").Update("read_at", at)
+	if result.Error != nil {
+		return fmt.Errorf("failed to mark notification read: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+	// Read before, unless it isn't theirs
+	var found int64
+	if err := owned.Count(&found).Error; err != nil {
+		return fmt.Errorf("failed to mark notification read: %w", err)
+	}
+	if found == 0 {
+		return notification.ErrNotificationNotFound
+	}
+	return nil
+}
+
+// MarkAllNotificationsRead marks the unread notifications of the user or anonymous session read at the time
+func (r *Repository) MarkAllNotificationsRead(userID uint, sessionID string, at time.Time) error {
+	err := notificationOwner(r.db.Model(&notification.Notification{}), userID, sessionID).
+		Where("read_at IS NULL").
+		Update("read_at", at).Error
+	if err != nil {
+		return fmt.Errorf("failed to mark notifications read: %w", err)
+	}
+	return nil
+}
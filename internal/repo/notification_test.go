@@ -0,0 +1,73 @@
+package repo_test
+
+import (
+	"interview/internal/cart"
+	"interview/internal/notification"
+	"interview/internal/repo"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestNotifications(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, repo.Migrate(db))
+	cartRepo := repo.NewRepository(db)
+	at := time.Now().Truncate(time.Second)
+
+	anonymous, err := cartRepo.GetOrCreateCart("anonymous", cart.DefaultName)
+	require.NoError(t, err)
+	u, err := cartRepo.FindOrCreateUser("github", "1", "jane@example.com", "Jane")
+	require.NoError(t, err)
+	owned, err := cartRepo.GetOrCreateCart("jane", cart.DefaultName)
+	require.NoError(t, err)
+	require.NoError(t, cartRepo.AssignCartToUser("jane", u.ID))
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, cartRepo.NotifyCartOwner(owned.ID, &notification.Notification{Kind: notification.KindOrderShipped, OrderID: uint(i + 1)}))
+	}
+	require.NoError(t, cartRepo.NotifyCartOwner(anonymous.ID, &notification.Notification{Kind: notification.KindPriceDropped, Product: "shoe"}))
+	assert.ErrorIs(t, cartRepo.NotifyCartOwner(9999, &notification.Notification{Kind: notification.KindPriceDropped}), cart.ErrCartNotFound)
+
+	t.Run("lists the notifications of the owner", func(t *testing.T) {
+		notifications, unread, err := cartRepo.ListNotifications(u.ID, "other-session", 2)
+		require.NoError(t, err)
+		require.Len(t, notifications, 2)
+		assert.Equal(t, uint(3), notifications[0].OrderID, "newest first")
+		assert.EqualValues(t, 3, unread)
+
+		notifications, unread, err = cartRepo.ListNotifications(0, "jane", 10)
+		require.NoError(t, err)
+		assert.Empty(t, notifications, "notifications of users don't show in their sessions once logged out")
+		assert.Zero(t, unread)
+	})
+
+	t.Run("marks a notification read", func(t *testing.T) {
+		notifications, _, err := cartRepo.ListNotifications(0, "anonymous", 10)
+		require.NoError(t, err)
+		require.Len(t, notifications, 1)
+		id := notifications[0].ID
+
+		assert.ErrorIs(t, cartRepo.MarkNotificationRead(u.ID, "", id, at), notification.ErrNotificationNotFound)
+		require.NoError(t, cartRepo.MarkNotificationRead(0, "anonymous", id, at))
+		require.NoError(t, cartRepo.MarkNotificationRead(0, "anonymous", id, at.Add(time.Hour)), "marking again succeeds")
+
+		notifications, unread, err := cartRepo.ListNotifications(0, "anonymous", 10)
+		require.NoError(t, err)
+		assert.Zero(t, unread)
+		require.NotNil(t, notifications[0].ReadAt)
+		assert.WithinDuration(t, at, *notifications[0].ReadAt, 0, "the first read is kept")
+	})
+
+	t.Run("marks every notification read", func(t *testing.T) {
+		require.NoError(t, cartRepo.MarkAllNotificationsRead(u.ID, "", at))
+		_, unread, err := cartRepo.ListNotifications(u.ID, "", 10)
+		require.NoError(t, err)
+		assert.Zero(t, unread)
+	})
+}
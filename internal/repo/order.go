@@ -0,0 +1,162 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"interview/internal/address"
+	cartpkg "interview/internal/cart"
+	"interview/internal/checkout"
+	"interview/internal/order"
+	"interview/internal/payment"
+	"interview/internal/vat"
+
+	"gorm.io/gorm"
+)
+
+// createOrder creates the pending order of a cart being closed in the shop of the cart, keeping the VAT
+// evidence and a copy of the addresses of its checkout
+func createOrder(tx *gorm.DB, cart *cartpkg.Cart) error {
+	o := order.Order{TenantID: cart.TenantID, CartID: cart.ID, Status: order.StatusPending}
+	var evidence vat.Evidence
+	if err := tx.Where("cart_id = ?", cart.ID).Limit(1).Find(&evidence).Error; err != nil {
+		return fmt.Errorf("failed to get VAT evidence: %w", err)
+	} else if evidence.ID != 0 {
+		o.VATEvidenceID = &evidence.ID
+	}
+	var s checkout.Session
+	if err := tx.Where("cart_id = ?", cart.ID).Limit(1).Find(&s).Error; err != nil {
+		return fmt.Errorf("failed to get checkout: %w", err)
+	}
+	if s.AddressID != nil {
+		shipping, err := orderAddress(tx, *s.AddressID)
+		if err != nil {
+			return err
+		}
+		o.ShippingAddress, o.BillingAddress = shipping, shipping
+	}
+	if s.BillingAddressID != nil {
+		billing, err := orderAddress(tx, *s.BillingAddressID)
+		if err != nil {
+			return err
+		}
+		o.BillingAddress = billing
+	}
+	if err := tx.Create(&o).Error; err != nil {
+		return fmt.Errorf("failed to create order: %w", err)
+	}
+	h := order.History{OrderID: o.ID, ToStatus: order.StatusPending, Actor: "checkout"}
+	if err := tx.Create(&h).Error; err != nil {
+		return fmt.Errorf("failed to record order history: %w", err)
+	}
+	return nil
+}
+
+// orderAddress copies an address of the address book onto an order, also when it was deleted since it
+// was chosen
+func orderAddress(tx *gorm.DB, id uint) (order.Address, error) {
+	var a address.Address
+	if err := tx.Unscoped().First(&a, id).Error; err != nil {
+		return order.Address{}, fmt.Errorf("failed to get address: %w", err)
+	}
+	return order.NewAddress(a), nil
+}
+
+// GetOrder returns an order with its history, or order.ErrOrderNotFound
+func (r *Repository) GetOrder(id uint) (*order.Order, error) {
+	return r.findOrder(r.db.Where("id = ?", id))
+}
+
+// GetOrderByCart returns the order of a checked out cart with its history, or order.ErrOrderNotFound
+func (r *Repository) GetOrderByCart(cartID uint) (*order.Order, error) {
+	return r.findOrder(r.db.Where("cart_id = ?", cartID))
+}
+
+func (r *Repository) findOrder(query *gorm.DB) (*order.Order, error) {
+	var o order.Order
+	err := query.Preload("History", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("VATEvidence").
+		First(&o).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, order.ErrOrderNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+	return &o, nil
+}
+
+// ListOrders returns up to limit orders with the status, all orders for an empty status, newest first,
+// starting after the order with ID beforeID, or with the most recent order when beforeID is 0
+func (r *Repository) ListOrders(status string, beforeID uint, limit int) ([]order.Order, error) {
+	query := r.db.Order("id DESC").Limit(limit)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if beforeID > 0 {
+		query = query.Where("id < ?", beforeID)
+	}
+	var orders []order.Order
+	if err := query.Find(&orders).Error; err != nil {
+		return nil, fmt.Errorf("failed to list orders: %w", err)
+	}
+	return orders, nil
+}
+
+// TransitionOrder changes the status of an order, recording who changed it and why in its history.
+// Refunding an order marks its captured and refunding payments refunded, which have to be paid back at their provider
+// first. It fails with order.ErrInvalidTransition for changes the status doesn't allow and with
+// ErrConflict when the order changed meanwhile.
+func (r *Repository) TransitionOrder(id uint, to, actor, note string) (*order.Order, error) {
+	var o order.Order
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&o, id).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+			return order.ErrOrderNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to get order: %w", err)
+		}
+		return transitionOrder(tx, &o, to, actor, note)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.GetOrder(o.ID)
+}
+
+// payCartOrder marks the order of a cart checked out by the customer paid
+func payCartOrder(tx *gorm.DB, cartID uint) error {
+	var o order.Order
+	if err := tx.Where("cart_id = ?", cartID).First(&o).Error; err != nil {
+		return fmt.Errorf("failed to get order: %w", err)
+	}
+	return transitionOrder(tx, &o, order.StatusPaid, "checkout", "")
+}
+
+func transitionOrder(tx *gorm.DB, o *order.Order, to, actor, note string) error {
+	if !order.ValidStatus(to) {
+		return order.ErrInvalidStatus
+	}
+	if !order.CanTransition(o.Status, to) {
+		return fmt.Errorf("%w from %s to %s", order.ErrInvalidTransition, o.Status, to)
+	}
+
+	result := tx.Model(&order.Order{}).Where("id = ? AND status = ?", o.ID, o.Status).Update("status", to)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update order: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrConflict
+	}
+	h := order.History{OrderID: o.ID, FromStatus: o.Status, ToStatus: to, Actor: actor, Note: note}
+	if err := tx.Create(&h).Error; err != nil {
+		return fmt.Errorf("failed to record order history: %w", err)
+	}
+	if to == order.StatusRefunded {
+		err := tx.Model(&payment.Payment{}).
+			Where("cart_id = ? AND status IN ?", o.CartID, []string{payment.StatusCaptured, payment.StatusRefunding}).
+			Update("status", payment.StatusRefunded).Error
+		if err != nil {
+			return fmt.Errorf("failed to refund payments: %w", err)
+		}
+	}
+	o.Status = to
+	return nil
+}
@@ -0,0 +1,89 @@
+package repo_test
+
+import (
+	cartpkg "interview/internal/cart"
+	"interview/internal/order"
+	"interview/internal/payment"
+	"interview/internal/repo"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrders(t *testing.T) {
+	db := setupTestDB(t)
+	cartRepo := repo.NewRepository(db)
+
+	closeCart := func(t *testing.T, sessionID string) *order.Order {
+		t.Helper()
+		c, err := cartRepo.GetOrCreateCart(sessionID, cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 1, 10))
+		require.NoError(t, cartRepo.CloseCart(c.ID))
+		o, err := cartRepo.GetOrderByCart(c.ID)
+		require.NoError(t, err)
+		return o
+	}
+
+	t.Run("closing a cart creates a pending order", func(t *testing.T) {
+		o := closeCart(t, "order-session-1")
+		assert.Equal(t, order.StatusPending, o.Status)
+		require.Len(t, o.History, 1)
+		assert.Equal(t, "", o.History[0].FromStatus)
+		assert.Equal(t, order.StatusPending, o.History[0].ToStatus)
+	})
+
+	t.Run("captured payments pay the order", func(t *testing.T) {
+		c, err := cartRepo.GetOrCreateCart("order-session-2", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 1, 10))
+		require.NoError(t, cartRepo.CreatePayment(&payment.Payment{
+			CartID: c.ID, Provider: "paypal", ExternalID: "ORDER-PAID", Amount: 10, Status: payment.StatusPending,
+		}))
+		_, _, err = cartRepo.CompletePayment("paypal", "ORDER-PAID")
+		require.NoError(t, err)
+
+		o, err := cartRepo.GetOrderByCart(c.ID)
+		require.NoError(t, err)
+		assert.Equal(t, order.StatusPaid, o.Status)
+		require.Len(t, o.History, 2)
+		assert.Equal(t, "checkout", o.History[1].Actor)
+	})
+
+	t.Run("transitions follow the allowed statuses", func(t *testing.T) {
+		o := closeCart(t, "order-session-3")
+		_, err := cartRepo.TransitionOrder(o.ID, order.StatusShipped, "admin", "")
+		assert.ErrorIs(t, err, order.ErrInvalidTransition)
+		_, err = cartRepo.TransitionOrder(o.ID, "lost", "admin", "")
+		assert.ErrorIs(t, err, order.ErrInvalidStatus)
+		_, err = cartRepo.TransitionOrder(o.ID+100, order.StatusPaid, "admin", "")
+		assert.ErrorIs(t, err, order.ErrOrderNotFound)
+
+		cancelled, err := cartRepo.TransitionOrder(o.ID, order.StatusCancelled, "support", "customer asked")
+		require.NoError(t, err)
+		assert.Equal(t, order.StatusCancelled, cancelled.Status)
+		require.Len(t, cancelled.History, 2)
+		assert.Equal(t, order.StatusPending, cancelled.History[1].FromStatus)
+		assert.Equal(t, "support", cancelled.History[1].Actor)
+		assert.Equal(t, "customer asked", cancelled.History[1].Note)
+
+		_, err = cartRepo.TransitionOrder(o.ID, order.StatusPaid, "admin", "")
+		assert.ErrorIs(t, err, order.ErrInvalidTransition)
+	})
+
+	t.Run("orders are listed by status", func(t *testing.T) {
+		pending, err := cartRepo.ListOrders(order.StatusPending, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, pending, 1)
+		assert.Equal(t, order.StatusPending, pending[0].Status)
+
+		all, err := cartRepo.ListOrders("", 0, 10)
+		require.NoError(t, err)
+		assert.Len(t, all, 3)
+
+		older, err := cartRepo.ListOrders("", all[0].ID, 10)
+		require.NoError(t, err)
+		assert.Equal(t, all[1:], older)
+	})
+}
@@ -0,0 +1,83 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	userpkg "interview/internal/user"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrResetTokenInvalid is returned for unknown, used or expired password reset tokens
+var ErrResetTokenInvalid = errors.New("password reset link is invalid or has expired")
+
+// FindUserByEmail returns the user with the given email address, ignoring case. When accounts of
+// several login providers share the address, the oldest one is returned.
+func (r *Repository) FindUserByEmail(email string) (*userpkg.User, error) {
+	var u userpkg.User
+	err := r.db.Where("LOWER(email) = ?", strings.ToLower(strings.TrimSpace(email))).Order("id").First(&u).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUserNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+	return &u, nil
+}
+
+// CreatePasswordResetToken stores the hash of a token allowing the user to reset their password until
+// it expires
+func (r *Repository) CreatePasswordResetToken(userID uint, tokenHash string, expiresAt time.Time) error {
+	token := userpkg.PasswordResetToken{UserID: userID, TokenHash: tokenHash, ExpiresAt: expiresAt}
+	if err := r.db.Create(&token).Error; err != nil {
+		return fmt.Errorf("failed to create password reset token: %w", err)
+	}
+	return nil
+}
+
+// GetPasswordResetToken returns the unused, unexpired token with the given hash
+func (r *Repository) GetPasswordResetToken(tokenHash string) (*userpkg.PasswordResetToken, error) {
+	var token userpkg.PasswordResetToken
+	err := r.db.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", tokenHash, time.Now()).First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrResetTokenInvalid
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get password reset token: %w", err)
+	}
+	return &token, nil
+}
+
+// ResetPassword uses the token with the given hash to set the password hash of its user. The other
+// tokens of the user stop working too, so a leaked older email can't be used afterwards.
+func (r *Repository) ResetPassword(tokenHash, passwordHash string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var token userpkg.PasswordResetToken
+		err := tx.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", tokenHash, time.Now()).First(&token).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrResetTokenInvalid
+		} else if err != nil {
+			return fmt.Errorf("failed to get password reset token: %w", err)
+		}
+
+		// Only one of two requests racing with the same token may use it
+		now := time.Now()
+		result := tx.Model(&token).Where("used_at IS NULL").Update("used_at", now)
+		if result.Error != nil {
+			return fmt.Errorf("failed to use password reset token: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrResetTokenInvalid
+		}
+		if err := tx.Model(&userpkg.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", token.UserID).
+			Update("used_at", now).Error; err != nil {
+			return fmt.Errorf("failed to invalidate password reset tokens: %w", err)
+		}
+
+		if err := tx.Model(&userpkg.User{}).Where("id = ?", token.UserID).Update("password_hash", passwordHash).Error; err != nil {
+			return fmt.Errorf("failed to update password: %w", err)
+		}
+		return nil
+	})
+}
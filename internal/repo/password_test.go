@@ -0,0 +1,62 @@
+package repo_test
+
+import (
+	"interview/internal/repo"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindUserByEmail(t *testing.T) {
+	db := setupTestDB(t)
+	cartRepo := repo.NewRepository(db)
+
+	first, err := cartRepo.FindOrCreateUser("google", "1", "Jane@Example.com", "Jane")
+	require.NoError(t, err)
+	_, err = cartRepo.FindOrCreateUser("github", "1", "jane@example.com", "Jane")
+	require.NoError(t, err)
+
+	u, err := cartRepo.FindUserByEmail(" jane@EXAMPLE.com")
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, u.ID, "the oldest account with the address is returned")
+
+	_, err = cartRepo.FindUserByEmail("nobody@example.com")
+	assert.ErrorIs(t, err, repo.ErrUserNotFound)
+}
+
+func TestResetPassword(t *testing.T) {
+	db := setupTestDB(t)
+	cartRepo := repo.NewRepository(db)
+
+	u, err := cartRepo.FindOrCreateUser("google", "1", "jane@example.com", "Jane")
+	require.NoError(t, err)
+	require.NoError(t, cartRepo.CreatePasswordResetToken(u.ID, "older", time.Now().Add(time.Hour)))
+	require.NoError(t, cartRepo.CreatePasswordResetToken(u.ID, "current", time.Now().Add(time.Hour)))
+	require.NoError(t, cartRepo.CreatePasswordResetToken(u.ID, "expired", time.Now().Add(-time.Minute)))
+
+	t.Run("expired tokens are invalid", func(t *testing.T) {
+		_, err := cartRepo.GetPasswordResetToken("expired")
+		assert.ErrorIs(t, err, repo.ErrResetTokenInvalid)
+		assert.ErrorIs(t, cartRepo.ResetPassword("expired", "hash"), repo.ErrResetTokenInvalid)
+	})
+
+	t.Run("sets the password once", func(t *testing.T) {
+		token, err := cartRepo.GetPasswordResetToken("current")
+		require.NoError(t, err)
+		assert.Equal(t, u.ID, token.UserID)
+
+		require.NoError(t, cartRepo.ResetPassword("current", "new-hash"))
+		stored, err := cartRepo.GetUser(u.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "new-hash", stored.PasswordHash)
+
+		assert.ErrorIs(t, cartRepo.ResetPassword("current", "other-hash"), repo.ErrResetTokenInvalid)
+	})
+
+	t.Run("other tokens of the user stop working", func(t *testing.T) {
+		_, err := cartRepo.GetPasswordResetToken("older")
+		assert.ErrorIs(t, err, repo.ErrResetTokenInvalid)
+	})
+}
@@ -0,0 +1,177 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"interview/internal/checkout"
+	"interview/internal/payment"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CreatePayment records a payment started for a cart
+func (r *Repository) CreatePayment(p *payment.Payment) error {
+	if err := r.db.Create(p).Error; err != nil {
+		return fmt.Errorf("failed to create payment: %w", err)
+	}
+	return nil
+}
+
+// GetPaymentByID returns a payment by its ID, or ErrPaymentNotFound
+func (r *Repository) GetPaymentByID(id uint) (*payment.Payment, error) {
+	var p payment.Payment
+	err := r.db.First(&p, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, payment.ErrPaymentNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get payment: %w", err)
+	}
+	return &p, nil
+}
+
+// GetPayment returns the payment of the provider with the external ID, or ErrPaymentNotFound
+func (r *Repository) GetPayment(provider, externalID string) (*payment.Payment, error) {
+	var p payment.Payment
+	err := r.db.Where("provider = ? AND external_id = ?", provider, externalID).First(&p).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, payment.ErrPaymentNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get payment: %w", err)
+	}
+	return &p, nil
+}
+
+// CompletePayment marks a captured payment and checks out its cart, completing its checkout session. Held
+// payments are completed once staff approved them.
+// Completing a payment twice only checks the cart out once; it returns whether this call did.
+func (r *Repository) CompletePayment(provider, externalID string) (*payment.Payment, bool, error) {
+	var completed *payment.Payment
+	var checkedOut bool
+	err := r.Transaction(func(tx *Repository) error {
+		p, err := tx.GetPayment(provider, externalID)
+		if err != nil {
+			return err
+		}
+		completed = p
+
+		now := time.Now()
+		result := tx.db.Model(&payment.Payment{}).
+			Where("id = ? AND status IN ?", p.ID, []string{payment.StatusPending, payment.StatusHeld}).
+			Updates(map[string]interface{}{"status": payment.StatusCaptured, "captured_at": now})
+		if result.Error != nil {
+			return fmt.Errorf("failed to update payment: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		p.Status, p.CapturedAt = payment.StatusCaptured, &now
+
+		if _, err := tx.completeCheckout(p.CartID); err != nil {
+			return err
+		}
+		if err := tx.CloseCart(p.CartID); err != nil {
+			return err
+		}
+		if err := payCartOrder(tx.db, p.CartID); err != nil {
+			return err
+		}
+		checkedOut = true
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return completed, checkedOut, nil
+}
+
+// HoldPayment holds an approved payment for staff to review before it is captured. Its checkout session
+// waits for the review, which keeps the cart locked. Holding a payment twice does nothing.
+func (r *Repository) HoldPayment(id uint, reason string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var p payment.Payment
+		if err := tx.First(&p, id).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+			return payment.ErrPaymentNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to get payment: %w", err)
+		}
+		result := tx.Model(&p).Where("status = ?", payment.StatusPending).
+			Updates(map[string]interface{}{"status": payment.StatusHeld, "risk_reason": reason})
+		if result.Error != nil {
+			return fmt.Errorf("failed to hold payment: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		err := tx.Model(&checkout.Session{}).
+			Where("cart_id = ? AND payment_id = ? AND status = ?", p.CartID, p.ID, checkout.StatusActive).
+			Update("status", checkout.StatusReview).Error
+		if err != nil {
+			return fmt.Errorf("failed to hold checkout: %w", err)
+		}
+		return nil
+	})
+}
+
+// BlockPayment declines a payment blocked by the risk checks or rejected by staff, so it is never
+// captured. A checkout being confirmed goes back to the payment step, and a checkout waiting for review
+// is cancelled, unlocking the cart. Blocking a payment twice does nothing, blocking a captured one fails
+// with ErrAlreadyCaptured.
+func (r *Repository) BlockPayment(id uint, reason string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var p payment.Payment
+		if err := tx.First(&p, id).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+			return payment.ErrPaymentNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to get payment: %w", err)
+		}
+		switch p.Status {
+		case payment.StatusBlocked:
+			return nil
+		case payment.StatusCaptured:
+			return payment.ErrAlreadyCaptured
+		}
+		result := tx.Model(&p).Where("status IN ?", []string{payment.StatusPending, payment.StatusHeld}).
+			Updates(map[string]interface{}{"status": payment.StatusBlocked, "risk_reason": reason})
+		if result.Error != nil {
+			return fmt.Errorf("failed to block payment: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("payment %d changed while blocking it", id)
+		}
+
+		err := tx.Model(&checkout.Session{}).
+			Where("cart_id = ? AND payment_id = ? AND status = ?", p.CartID, p.ID, checkout.StatusActive).
+			Updates(map[string]interface{}{"step": checkout.StepPayment, "payment_id": nil, "approve_url": ""}).Error
+		if err != nil {
+			return fmt.Errorf("failed to update checkout: %w", err)
+		}
+		err = tx.Model(&checkout.Session{}).
+			Where("cart_id = ? AND payment_id = ? AND status = ?", p.CartID, p.ID, checkout.StatusReview).
+			Updates(map[string]interface{}{"status": checkout.StatusCancelled, "payment_id": nil, "approve_url": ""}).Error
+		if err != nil {
+			return fmt.Errorf("failed to cancel checkout: %w", err)
+		}
+		return nil
+	})
+}
+
+// ListHeldPayments returns the payments held for review, oldest first
+func (r *Repository) ListHeldPayments() ([]payment.Payment, error) {
+	var payments []payment.Payment
+	if err := r.db.Where("status = ?", payment.StatusHeld).Order("id").Find(&payments).Error; err != nil {
+		return nil, fmt.Errorf("failed to list held payments: %w", err)
+	}
+	return payments, nil
+}
+
+// ListRefundablePayments returns the payments of a cart that are captured or still being refunded
+func (r *Repository) ListRefundablePayments(cartID uint) ([]payment.Payment, error) {
+	var payments []payment.Payment
+	err := r.db.Where("cart_id = ? AND status IN ?", cartID, []string{payment.StatusCaptured, payment.StatusRefunding}).
+		Order("id").Find(&payments).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list payments: %w", err)
+	}
+	return payments, nil
+}
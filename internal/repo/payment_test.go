@@ -0,0 +1,56 @@
+package repo_test
+
+import (
+	cartpkg "interview/internal/cart"
+	"interview/internal/payment"
+	"interview/internal/repo"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPayments(t *testing.T) {
+	db := setupTestDB(t)
+	cartRepo := repo.NewRepository(db)
+
+	c, err := cartRepo.GetOrCreateCart("payment-session", cartpkg.DefaultName)
+	require.NoError(t, err)
+	require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 1, 10))
+	require.NoError(t, cartRepo.CreatePayment(&payment.Payment{
+		CartID: c.ID, Provider: "paypal", ExternalID: "ORDER-1", Amount: 10, Currency: "EUR", Status: payment.StatusPending,
+	}))
+
+	t.Run("unknown payments are not found", func(t *testing.T) {
+		_, err := cartRepo.GetPayment("paypal", "ORDER-2")
+		assert.ErrorIs(t, err, payment.ErrPaymentNotFound)
+		_, err = cartRepo.GetPayment("stripe", "ORDER-1")
+		assert.ErrorIs(t, err, payment.ErrPaymentNotFound)
+		_, _, err = cartRepo.CompletePayment("paypal", "ORDER-2")
+		assert.ErrorIs(t, err, payment.ErrPaymentNotFound)
+	})
+
+	t.Run("external IDs are unique per provider", func(t *testing.T) {
+		err := cartRepo.CreatePayment(&payment.Payment{
+			CartID: c.ID, Provider: "paypal", ExternalID: "ORDER-1", Status: payment.StatusPending,
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("completing a payment checks out its cart once", func(t *testing.T) {
+		p, checkedOut, err := cartRepo.CompletePayment("paypal", "ORDER-1")
+		require.NoError(t, err)
+		assert.True(t, checkedOut)
+		assert.Equal(t, payment.StatusCaptured, p.Status)
+		assert.NotNil(t, p.CapturedAt)
+
+		closed, err := cartRepo.GetCart(c.ID)
+		require.NoError(t, err)
+		assert.Equal(t, cartpkg.StatusClosed, closed.Status)
+
+		p, checkedOut, err = cartRepo.CompletePayment("paypal", "ORDER-1")
+		require.NoError(t, err)
+		assert.False(t, checkedOut)
+		assert.Equal(t, payment.StatusCaptured, p.Status)
+	})
+}
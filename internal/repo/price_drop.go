@@ -0,0 +1,57 @@
+package repo
+
+import (
+	"fmt"
+	cartpkg "interview/internal/cart"
+	productpkg "interview/internal/product"
+)
+
+// PriceDropItem is an item of a product in an open cart, with the user and email address of the cart's
+// owner, if any
+type PriceDropItem struct {
+	CartID   uint
+	UserID   *uint
+	Email    string
+	ItemID   uint
+	Quantity int
+	Price    float64
+	Total    float64
+}
+
+// SetProductPrice sets the base price of a product and returns the updated product with the price it
+// had before. It fails with gorm.ErrRecordNotFound for unknown products.
+func (r *Repository) SetProductPrice(id uint, price float64) (*productpkg.Product, float64, error) {
+	if price < 0 {
+		return nil, 0, productpkg.ErrInvalidPrice
+	}
+	var p productpkg.Product
+	if err := r.db.First(&p, id).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to get product: %w", err)
+	}
+	previous := p.Price
+	if err := r.db.Model(&p).Update("price", price).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to update price: %w", err)
+	}
+	r.invalidateProduct(p.ID)
+	r.indexProduct(p)
+	return &p, previous, nil
+}
+
+// ListPriceDropItems returns the items of the product in open carts, by cart, whose price is compared
+// with the price their customer pays now, see CatalogPrice. Items of bundles are left out, they sell at
+// their share of the bundle price.
+func (r *Repository) ListPriceDropItems(product string) ([]PriceDropItem, error) {
+	var items []PriceDropItem
+	query := r.reader().Table("cart_items").
+		Select("carts.id AS cart_id, carts.user_id, COALESCE(users.email, '') AS email, cart_items.id AS item_id, "+
+			"cart_items.quantity, cart_items.price, carts.total").
+		Joins("JOIN carts ON carts.id = cart_items.cart_id AND carts.deleted_at IS NULL").
+		Joins("LEFT JOIN users ON users.id = carts.user_id").
+		Where("cart_items.deleted_at IS NULL AND cart_items.bundle_group = 0 AND cart_items.product_name = ? AND carts.status = ?",
+			product, cartpkg.StatusOpen)
+	err := scopeJoined(query, "carts").Order("carts.id, cart_items.id").Scan(&items).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list price drops: %w", err)
+	}
+	return items, nil
+}
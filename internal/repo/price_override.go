@@ -0,0 +1,74 @@
+package repo
+
+import (
+	"fmt"
+	"interview/internal/pricelist"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CreatePriceOverride schedules a price override, failing with pricelist.ErrOverrideOverlaps when the
+// product has another override during its period
+func (r *Repository) CreatePriceOverride(override *pricelist.PriceOverride) error {
+	if !override.EndsAt.After(override.StartsAt) {
+		return pricelist.ErrInvalidPeriod
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		err := tx.Model(&pricelist.PriceOverride{}).
+			Where("product = ? AND starts_at < ? AND ends_at > ?", override.Product, override.EndsAt, override.StartsAt).
+			Count(&count).Error
+		if err != nil {
+			return fmt.Errorf("failed to check price overrides: %w", err)
+		}
+		if count > 0 {
+			return pricelist.ErrOverrideOverlaps
+		}
+		if err := tx.Create(override).Error; err != nil {
+			return fmt.Errorf("failed to create price override: %w", err)
+		}
+		return nil
+	})
+}
+
+// ListPriceOverrides returns the price overrides of the product, or of all products when product is
+// empty, by start. Overrides that ended before since are left out.
+func (r *Repository) ListPriceOverrides(product string, since time.Time) ([]pricelist.PriceOverride, error) {
+	db := r.db.Where("ends_at > ?", since)
+	if product != "" {
+		db = db.Where("product = ?", product)
+	}
+	var overrides []pricelist.PriceOverride
+	if err := db.Order("starts_at, product").Find(&overrides).Error; err != nil {
+		return nil, fmt.Errorf("failed to list price overrides: %w", err)
+	}
+	return overrides, nil
+}
+
+// DeletePriceOverride deletes a price override, ending it right away when it is active
+func (r *Repository) DeletePriceOverride(id uint) error {
+	result := r.db.Delete(&pricelist.PriceOverride{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete price override: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return pricelist.ErrOverrideNotFound
+	}
+	return nil
+}
+
+// ActivePriceOverrides returns the overrides of the named products active at the time, keyed by product
+// name. Products without one are left out.
+func (r *Repository) ActivePriceOverrides(names []string, at time.Time) (map[string]pricelist.PriceOverride, error) {
+	var overrides []pricelist.PriceOverride
+	err := r.reader().Where("product IN ? AND starts_at <= ? AND ends_at > ?", names, at, at).Find(&overrides).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get price overrides: %w", err)
+	}
+	byName := make(map[string]pricelist.PriceOverride, len(overrides))
+	for _, o := range overrides {
+		byName[o.Product] = o
+	}
+	return byName, nil
+}
@@ -0,0 +1,113 @@
+package repo_test
+
+import (
+	cartpkg "interview/internal/cart"
+	"interview/internal/pricelist"
+	"interview/internal/repo"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriceOverrides(t *testing.T) {
+	db := setupTestDB(t)
+	cartRepo := repo.NewRepository(db)
+	now := time.Now()
+	sale := pricelist.PriceOverride{Product: "shoe", Price: 7, StartsAt: now.Add(-time.Hour), EndsAt: now.Add(time.Hour)}
+
+	t.Run("overrides of a product don't overlap", func(t *testing.T) {
+		require.NoError(t, cartRepo.CreatePriceOverride(&sale))
+		tests := []struct {
+			name     string
+			override pricelist.PriceOverride
+			err      error
+		}{
+			{"overlapping start", pricelist.PriceOverride{Product: "shoe", Price: 5, StartsAt: now, EndsAt: now.Add(2 * time.Hour)}, pricelist.ErrOverrideOverlaps},
+			{"enclosing", pricelist.PriceOverride{Product: "shoe", Price: 5, StartsAt: now.Add(-2 * time.Hour), EndsAt: now.Add(2 * time.Hour)}, pricelist.ErrOverrideOverlaps},
+			{"end before start", pricelist.PriceOverride{Product: "shoe", Price: 5, StartsAt: now.Add(3 * time.Hour), EndsAt: now.Add(2 * time.Hour)}, pricelist.ErrInvalidPeriod},
+			{"following", pricelist.PriceOverride{Product: "shoe", Price: 5, StartsAt: now.Add(time.Hour), EndsAt: now.Add(2 * time.Hour)}, nil},
+			{"other product", pricelist.PriceOverride{Product: "bag", Price: 5, StartsAt: now, EndsAt: now.Add(time.Hour)}, nil},
+			{"expired", pricelist.PriceOverride{Product: "watch", Price: 5, StartsAt: now.Add(-2 * time.Hour), EndsAt: now.Add(-time.Hour)}, nil},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert.ErrorIs(t, cartRepo.CreatePriceOverride(&tt.override), tt.err)
+			})
+		}
+
+		overrides, err := cartRepo.ListPriceOverrides("shoe", now)
+		require.NoError(t, err)
+		require.Len(t, overrides, 2)
+		assert.Equal(t, sale.ID, overrides[0].ID)
+		overrides, err = cartRepo.ListPriceOverrides("", now)
+		require.NoError(t, err)
+		assert.Len(t, overrides, 3, "expired overrides aren't listed")
+	})
+
+	t.Run("the active override sets the price", func(t *testing.T) {
+		buyer, err := cartRepo.FindOrCreateUser("github", "1", "buyer@example.com", "Buyer")
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.SetUserGroup(buyer.ID, pricelist.GroupWholesale))
+		wholesale := pricelist.PriceList{Name: "Wholesale", CustomerGroup: pricelist.GroupWholesale}
+		require.NoError(t, cartRepo.CreatePriceList(&wholesale))
+		require.NoError(t, cartRepo.SetListPrice(wholesale.ID, "shoe", 6))
+		require.NoError(t, cartRepo.SetListPrice(wholesale.ID, "bag", 8))
+
+		tests := []struct {
+			name    string
+			userID  *uint
+			product string
+			at      time.Time
+			price   float64
+			ok      bool
+		}{
+			{name: "active override", product: "shoe", at: now, price: 7, ok: true},
+			{name: "next override", product: "shoe", at: now.Add(90 * time.Minute), price: 5, ok: true},
+			{name: "after the overrides", product: "shoe", at: now.Add(3 * time.Hour)},
+			{name: "expired override", product: "watch", at: now},
+			{name: "lower list price", userID: &buyer.ID, product: "shoe", at: now, price: 6, ok: true},
+			{name: "higher list price", userID: &buyer.ID, product: "bag", at: now, price: 5, ok: true},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				price, ok, err := cartRepo.ResolvePrice(tt.userID, tt.product, tt.at)
+				require.NoError(t, err)
+				assert.Equal(t, tt.ok, ok)
+				assert.Equal(t, tt.price, price)
+			})
+		}
+	})
+
+	t.Run("carts are repriced to the override", func(t *testing.T) {
+		_, err := cartRepo.UpsertProduct("shoe", 10)
+		require.NoError(t, err)
+		c, err := cartRepo.GetOrCreateCart("sale-session", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 1, 10))
+
+		stale, err := cartRepo.ListStalePrices(10, now)
+		require.NoError(t, err)
+		require.Len(t, stale, 1)
+		assert.Equal(t, 7.0, stale[0].CatalogPrice)
+		stale, err = cartRepo.ListStalePrices(10, now.Add(3*time.Hour))
+		require.NoError(t, err)
+		assert.Empty(t, stale, "the base price is current again once the overrides ended")
+
+		changed, err := cartRepo.RepriceCart(c.ID, now)
+		require.NoError(t, err)
+		assert.True(t, changed)
+		repriced, err := cartRepo.GetCart(c.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 7.0, repriced.CartItems[0].Price)
+	})
+
+	t.Run("deleted overrides end", func(t *testing.T) {
+		require.NoError(t, cartRepo.DeletePriceOverride(sale.ID))
+		_, ok, err := cartRepo.ResolvePrice(nil, "shoe", now)
+		require.NoError(t, err)
+		assert.False(t, ok)
+		assert.ErrorIs(t, cartRepo.DeletePriceOverride(sale.ID), pricelist.ErrOverrideNotFound)
+	})
+}
@@ -0,0 +1,142 @@
+package repo
+
+import (
+	"fmt"
+	cartpkg "interview/internal/cart"
+	"interview/internal/pricing"
+	productpkg "interview/internal/product"
+	"time"
+)
+
+// stalePriceBatch is how many items ListStalePrices compares with the catalog at a time
+const stalePriceBatch = 500
+
+// StalePrice is an item of an open cart whose price differs from the current catalog price, which is
+// the price its customer would pay for it now, see CatalogPrice, or that of the price tier its quantity
+// reaches where lower: the price RepriceCart gives it
+type StalePrice struct {
+	CartID       uint
+	SessionID    string
+	CartName     string
+	ItemID       uint
+	ProductName  string
+	Quantity     int
+	Price        float64
+	CatalogPrice float64
+}
+
+// ListStalePrices returns up to limit items of open carts priced differently than in the catalog at the
+// time, grouped by cart. Items of products no longer in the catalog are left out, they can't be repriced,
+// and so are items of bundles, which are priced at their share of the bundle price.
+func (r *Repository) ListStalePrices(limit int, at time.Time) ([]StalePrice, error) {
+	type pricedItem struct {
+		StalePrice
+		UserID *uint
+	}
+	// Customers often have the same products in their carts, so each price is resolved once
+	type priceKey struct {
+		userID  uint
+		product string
+	}
+	prices := map[priceKey]*float64{}
+	tiers := map[string][]productpkg.PriceTier{}
+
+	var stale []StalePrice
+	var lastCartID, lastItemID uint
+	for len(stale) < limit {
+		var items []pricedItem
+		err := r.reader().Table("cart_items").
+			Select("carts.id AS cart_id, carts.session_id, carts.name AS cart_name, carts.user_id, cart_items.id AS item_id, "+
+				"cart_items.product_name, cart_items.quantity, cart_items.price").
+			Joins("JOIN carts ON carts.id = cart_items.cart_id AND carts.deleted_at IS NULL").
+			Where("cart_items.deleted_at IS NULL AND cart_items.bundle_group = 0 AND carts.status = ?", cartpkg.StatusOpen).
+			Where("cart_items.cart_id > ? OR (cart_items.cart_id = ? AND cart_items.id > ?)", lastCartID, lastCartID, lastItemID).
+			Order("cart_items.cart_id, cart_items.id").
+			Limit(stalePriceBatch).
+			Scan(&items).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to list stale prices: %w", err)
+		}
+		if len(items) == 0 {
+			break
+		}
+		lastCartID, lastItemID = items[len(items)-1].CartID, items[len(items)-1].ItemID
+
+		var names []string
+		for _, item := range items {
+			if _, ok := tiers[item.ProductName]; !ok {
+				tiers[item.ProductName] = nil
+				names = append(names, item.ProductName)
+			}
+		}
+		if len(names) > 0 {
+			found, err := priceTiers(r.db, names)
+			if err != nil {
+				return nil, err
+			}
+			for name, productTiers := range found {
+				tiers[name] = productTiers
+			}
+		}
+
+		for _, item := range items {
+			key := priceKey{product: item.ProductName}
+			if item.UserID != nil {
+				key.userID = *item.UserID
+			}
+			regular, resolved := prices[key]
+			if !resolved {
+				price, ok, err := r.CatalogPrice(item.UserID, item.ProductName, at)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					regular = &price
+				}
+				prices[key] = regular
+			}
+			if regular == nil {
+				continue
+			}
+			item.CatalogPrice, _ = tieredPrice(tiers[item.ProductName], item.Quantity, *regular)
+			if !pricing.Changed(item.Price, item.CatalogPrice) {
+				continue
+			}
+			stale = append(stale, item.StalePrice)
+			if len(stale) == limit {
+				break
+			}
+		}
+	}
+	return stale, nil
+}
+
+// RepriceCart updates the prices of the items of an open cart to the catalog prices at the time, see
+// CatalogPrice and RefreshCartPrices. It fails with ErrCartNotFound or ErrCartClosed for
+// carts that can't be changed.
+func (r *Repository) RepriceCart(cartID uint, at time.Time) (bool, error) {
+	var names []string
+	err := r.db.Model(&cartpkg.CartItem{}).Where("cart_id = ?", cartID).Distinct().Pluck("product_name", &names).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to load items: %w", err)
+	}
+	// Missing carts are reported by RefreshCartPrices
+	var owner cartpkg.Cart
+	if err := r.db.Select("user_id", "tenant_id").Where("id = ?", cartID).Limit(1).Find(&owner).Error; err != nil {
+		return false, fmt.Errorf("failed to load cart: %w", err)
+	}
+
+	shop := *r
+	shop.db = inShop(r.db, owner.TenantID)
+	prices := make(map[string]float64, len(names))
+	for _, name := range names {
+		price, ok, err := shop.CatalogPrice(owner.UserID, name, at)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			prices[name] = price
+		}
+	}
+	return r.RefreshCartPrices(cartID, prices, at)
+}
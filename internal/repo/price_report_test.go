@@ -0,0 +1,54 @@
+package repo_test
+
+import (
+	"interview/internal/cart"
+	"interview/internal/pricing"
+	"interview/internal/repo"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListStalePrices(t *testing.T) {
+	cartRepo := repo.NewRepository(setupTestDB(t))
+	_, err := cartRepo.UpsertProduct("shoe", 10)
+	require.NoError(t, err)
+	_, err = cartRepo.UpsertProduct("bag", 30)
+	require.NoError(t, err)
+	// The cart adds items at the price of the price provider, which overrides the catalog
+	cartRepo.SetPriceProvider(pricing.StaticProvider{"shoe": 9.5})
+	c, err := cartRepo.GetOrCreateCart("session", cart.DefaultName)
+	require.NoError(t, err)
+	require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 1, 9.5))
+	require.NoError(t, cartRepo.AddCartItem(c.ID, "bag", 1, 30))
+
+	t.Run("agrees with the prices of the cart", func(t *testing.T) {
+		stale, err := cartRepo.ListStalePrices(10, time.Now())
+		require.NoError(t, err)
+		assert.Empty(t, stale)
+		changed, err := cartRepo.RepriceCart(c.ID, time.Now())
+		require.NoError(t, err)
+		assert.False(t, changed)
+	})
+
+	t.Run("lists prices that changed", func(t *testing.T) {
+		cartRepo.SetPriceProvider(pricing.StaticProvider{"shoe": 9, "bag": 28})
+		stale, err := cartRepo.ListStalePrices(1, time.Now())
+		require.NoError(t, err)
+		require.Len(t, stale, 1)
+		assert.Equal(t, "shoe", stale[0].ProductName)
+		assert.Equal(t, 9.0, stale[0].CatalogPrice)
+
+		changed, err := cartRepo.RepriceCart(c.ID, time.Now())
+		require.NoError(t, err)
+		assert.True(t, changed)
+		stale, err = cartRepo.ListStalePrices(10, time.Now())
+		require.NoError(t, err)
+		assert.Empty(t, stale)
+		repriced, err := cartRepo.GetCart(c.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 37.0, repriced.Subtotal)
+	})
+}
@@ -0,0 +1,152 @@
+package repo
+
+import (
+	"fmt"
+	cartpkg "interview/internal/cart"
+	productpkg "interview/internal/product"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ListPriceTiers returns the price tiers of a product ordered by MinQuantity. It fails with
+// gorm.ErrRecordNotFound for unknown products.
+func (r *Repository) ListPriceTiers(productID uint) ([]productpkg.PriceTier, error) {
+	var p productpkg.Product
+	if err := r.db.Select("id").First(&p, productID).Error; err != nil {
+		return nil, fmt.Errorf("failed to get product: %w", err)
+	}
+	var tiers []productpkg.PriceTier
+	if err := r.db.Where("product_id = ?", p.ID).Order("min_quantity").Find(&tiers).Error; err != nil {
+		return nil, fmt.Errorf("failed to list price tiers: %w", err)
+	}
+	return tiers, nil
+}
+
+// SetPriceTiers replaces the price tiers of a product, removing them all for no tiers. It fails with
+// productpkg.ErrInvalidTiers for invalid tiers and gorm.ErrRecordNotFound for unknown products. Items
+// in carts are priced at the new tiers on the next change to their cart or refresh of its prices.
+func (r *Repository) SetPriceTiers(productID uint, tiers []productpkg.PriceTier) error {
+	if err := productpkg.SortTiers(tiers); err != nil {
+		return err
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var p productpkg.Product
+		if err := tx.Select("id").First(&p, productID).Error; err != nil {
+			return fmt.Errorf("failed to get product: %w", err)
+		}
+		if err := tx.Where("product_id = ?", p.ID).Delete(&productpkg.PriceTier{}).Error; err != nil {
+			return fmt.Errorf("failed to remove price tiers: %w", err)
+		}
+		for i := range tiers {
+			tiers[i].ID = 0
+			tiers[i].ProductID = p.ID
+		}
+		if len(tiers) > 0 {
+			if err := tx.Create(&tiers).Error; err != nil {
+				return fmt.Errorf("failed to store price tiers: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// priceTiers returns the price tiers of the named products ordered by MinQuantity, by product name.
+// Products without tiers are left out.
+func priceTiers(db *gorm.DB, names []string) (map[string][]productpkg.PriceTier, error) {
+	// Products are looked up first, so the lookup is limited to those of the tenant
+	var products []productpkg.Product
+	if err := db.Select("id", "name").Where("name IN ?", names).Find(&products).Error; err != nil {
+		return nil, fmt.Errorf("failed to get products: %w", err)
+	}
+	if len(products) == 0 {
+		return nil, nil
+	}
+	ids := make([]uint, len(products))
+	byID := make(map[uint]string, len(products))
+	for i, p := range products {
+		ids[i] = p.ID
+		byID[p.ID] = p.Name
+	}
+
+	var tiers []productpkg.PriceTier
+	if err := db.Where("product_id IN ?", ids).Order("min_quantity").Find(&tiers).Error; err != nil {
+		return nil, fmt.Errorf("failed to get price tiers: %w", err)
+	}
+	byName := make(map[string][]productpkg.PriceTier)
+	for _, tier := range tiers {
+		byName[byID[tier.ProductID]] = append(byName[byID[tier.ProductID]], tier)
+	}
+	return byName, nil
+}
+
+// tieredPrice returns the unit price of quantity units of an item whose regular price is price, and the
+// MinQuantity of the tier it comes from: the price of the tier the quantity reaches where it is lower,
+// the regular price with no tier otherwise
+func tieredPrice(tiers []productpkg.PriceTier, quantity int, price float64) (float64, int) {
+	if tier := productpkg.TierFor(tiers, quantity); tier != nil && tier.Price < price {
+		return tier.Price, tier.MinQuantity
+	}
+	return price, 0
+}
+
+// applyPriceTiers prices the items of the cart at the tiers their quantity reaches, and those no longer
+// reaching the tier they were priced at at the regular price again, the price the cart's customer pays
+// for the product when added, see CatalogPrice. Items whose price doesn't come from a tier keep their regular price, and
+// items of bundles their share of the bundle price.
+func (r *Repository) applyPriceTiers(db *gorm.DB, cart *cartpkg.Cart, items []cartpkg.CartItem, at time.Time) error {
+	names := make([]string, 0, len(items))
+	for _, item := range items {
+		if item.BundleGroup == 0 {
+			names = append(names, item.ProductName)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	tiers, err := priceTiers(db, names)
+	if err != nil {
+		return err
+	}
+
+	// The prices are those of the cart's shop, also for carts changed by background jobs
+	inTx := *r
+	inTx.db = inShop(db, cart.TenantID)
+	for i := range items {
+		item := &items[i]
+		if item.BundleGroup != 0 {
+			continue
+		}
+		productTiers := tiers[item.ProductName]
+		tier := productpkg.TierFor(productTiers, item.Quantity)
+		if tier == nil && item.TierQuantity == 0 {
+			continue
+		}
+		if tier != nil && tier.MinQuantity == item.TierQuantity && tier.Price == item.Price {
+			continue
+		}
+
+		regular := item.Price
+		if item.TierQuantity > 0 {
+			price, ok, err := inTx.CatalogPrice(cart.UserID, item.ProductName, at)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				// Items of products no longer in the catalog keep their price
+				continue
+			}
+			regular = price
+		}
+		price, tierQuantity := tieredPrice(productTiers, item.Quantity, regular)
+		if price == item.Price && tierQuantity == item.TierQuantity {
+			continue
+		}
+		err := db.Model(item).Updates(map[string]interface{}{"price": price, "tier_quantity": tierQuantity}).Error
+		if err != nil {
+			return fmt.Errorf("failed to update item price: %w", err)
+		}
+		item.Price, item.TierQuantity = price, tierQuantity
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package repo_test
+
+import (
+	"interview/internal/cart"
+	"interview/internal/pricelist"
+	"interview/internal/pricing"
+	"interview/internal/product"
+	"interview/internal/repo"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestPriceTiers(t *testing.T) {
+	// setup sells the shoe at 10, 9 from 10 units and 8 from 50 units
+	setup := func(t *testing.T) (*repo.Repository, *product.Product) {
+		t.Helper()
+		db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+		require.NoError(t, err)
+		require.NoError(t, repo.Migrate(db))
+		cartRepo := repo.NewRepository(db)
+		shoe, err := cartRepo.UpsertProduct("shoe", 10)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.SetPriceTiers(shoe.ID, []product.PriceTier{{MinQuantity: 50, Price: 8}, {MinQuantity: 10, Price: 9}}))
+		return cartRepo, shoe
+	}
+	// item returns the only item of the cart
+	item := func(t *testing.T, cartRepo *repo.Repository, cartID uint) cart.CartItem {
+		t.Helper()
+		c, err := cartRepo.GetCart(cartID)
+		require.NoError(t, err)
+		require.Len(t, c.CartItems, 1)
+		return c.CartItems[0]
+	}
+
+	t.Run("rejects invalid tiers", func(t *testing.T) {
+		cartRepo, shoe := setup(t)
+		for _, tiers := range [][]product.PriceTier{
+			{{MinQuantity: 1, Price: 9}},
+			{{MinQuantity: 10, Price: -1}},
+			{{MinQuantity: 10, Price: 9}, {MinQuantity: 10, Price: 8}},
+			{{MinQuantity: 10, Price: 8}, {MinQuantity: 50, Price: 9}},
+		} {
+			assert.ErrorIs(t, cartRepo.SetPriceTiers(shoe.ID, tiers), product.ErrInvalidTiers)
+		}
+		assert.ErrorIs(t, cartRepo.SetPriceTiers(9999, nil), gorm.ErrRecordNotFound)
+
+		tiers, err := cartRepo.ListPriceTiers(shoe.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []int{10, 50}, []int{tiers[0].MinQuantity, tiers[1].MinQuantity}, "ordered by quantity and unchanged")
+	})
+
+	t.Run("picks the tier of the quantity", func(t *testing.T) {
+		cartRepo, _ := setup(t)
+		c, err := cartRepo.GetOrCreateCart("session", cart.DefaultName)
+		require.NoError(t, err)
+
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 9, 10))
+		assert.Equal(t, 10.0, item(t, cartRepo, c.ID).Price)
+		assert.Zero(t, item(t, cartRepo, c.ID).TierQuantity)
+
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 1, 10))
+		assert.Equal(t, 9.0, item(t, cartRepo, c.ID).Price)
+		assert.Equal(t, 10, item(t, cartRepo, c.ID).TierQuantity)
+
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 40, 10))
+		assert.Equal(t, 8.0, item(t, cartRepo, c.ID).Price)
+		updated, err := cartRepo.GetCart(c.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 400.0, updated.Subtotal)
+	})
+
+	t.Run("falls back to the regular price", func(t *testing.T) {
+		cartRepo, _ := setup(t)
+		c, err := cartRepo.GetOrCreateCart("session", cart.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 5, 10))
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 5, 10))
+		require.Equal(t, 9.0, item(t, cartRepo, c.ID).Price)
+
+		_, err = cartRepo.UndoCartChange(c.ID, time.Now().Add(-time.Minute), time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, 10.0, item(t, cartRepo, c.ID).Price)
+		assert.Zero(t, item(t, cartRepo, c.ID).TierQuantity)
+	})
+
+	t.Run("keeps lower list prices", func(t *testing.T) {
+		cartRepo, _ := setup(t)
+		list := pricelist.PriceList{Name: "Retail", CustomerGroup: pricelist.GroupRetail}
+		require.NoError(t, cartRepo.CreatePriceList(&list))
+		require.NoError(t, cartRepo.SetListPrice(list.ID, "shoe", 8.5))
+		c, err := cartRepo.GetOrCreateCart("session", cart.DefaultName)
+		require.NoError(t, err)
+
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 10, 8.5))
+		assert.Equal(t, 8.5, item(t, cartRepo, c.ID).Price)
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 40, 8.5))
+		assert.Equal(t, 8.0, item(t, cartRepo, c.ID).Price)
+		_, err = cartRepo.UndoCartChange(c.ID, time.Now().Add(-time.Minute), time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, 8.5, item(t, cartRepo, c.ID).Price, "the list price once the tier no longer applies")
+	})
+
+	t.Run("falls back to the price of the price provider", func(t *testing.T) {
+		cartRepo, _ := setup(t)
+		cartRepo.SetPriceProvider(pricing.StaticProvider{"shoe": 9.5})
+		c, err := cartRepo.GetOrCreateCart("session", cart.DefaultName)
+		require.NoError(t, err)
+
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 5, 9.5))
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 5, 9.5))
+		require.Equal(t, 9.0, item(t, cartRepo, c.ID).Price)
+		_, err = cartRepo.UndoCartChange(c.ID, time.Now().Add(-time.Minute), time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, 9.5, item(t, cartRepo, c.ID).Price, "the price items are added at, not the catalog price")
+	})
+
+	t.Run("refreshes prices within the tier", func(t *testing.T) {
+		cartRepo, shoe := setup(t)
+		c, err := cartRepo.GetOrCreateCart("session", cart.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 10, 10))
+
+		changed, err := cartRepo.RefreshCartPrices(c.ID, map[string]float64{"shoe": 10}, time.Now())
+		require.NoError(t, err)
+		assert.False(t, changed, "the tier price is current")
+		stale, err := cartRepo.ListStalePrices(10, time.Now())
+		require.NoError(t, err)
+		assert.Empty(t, stale)
+
+		changed, err = cartRepo.RefreshCartPrices(c.ID, map[string]float64{"shoe": 7}, time.Now())
+		require.NoError(t, err)
+		assert.True(t, changed)
+		assert.Equal(t, 7.0, item(t, cartRepo, c.ID).Price, "regular prices below the tier")
+		assert.Zero(t, item(t, cartRepo, c.ID).TierQuantity)
+
+		require.NoError(t, cartRepo.SetPriceTiers(shoe.ID, nil))
+		changed, err = cartRepo.RefreshCartPrices(c.ID, map[string]float64{"shoe": 10}, time.Now())
+		require.NoError(t, err)
+		assert.True(t, changed)
+		assert.Equal(t, 10.0, item(t, cartRepo, c.ID).Price)
+	})
+}
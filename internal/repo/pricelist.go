@@ -0,0 +1,202 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"interview/internal/pricelist"
+	"interview/internal/pricing"
+	productpkg "interview/internal/product"
+	userpkg "interview/internal/user"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CreatePriceList adds a price list for a customer group, failing with pricelist.ErrGroupTaken when the
+// group has one already
+func (r *Repository) CreatePriceList(list *pricelist.PriceList) error {
+	if !pricelist.ValidGroup(list.CustomerGroup) {
+		return pricelist.ErrInvalidGroup
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := groupAvailable(tx, list.CustomerGroup, 0); err != nil {
+			return err
+		}
+		if err := tx.Create(list).Error; err != nil {
+			return fmt.Errorf("failed to create price list: %w", err)
+		}
+		return nil
+	})
+}
+
+// ListPriceLists returns all price lists in the order they were added, without their prices
+func (r *Repository) ListPriceLists() ([]pricelist.PriceList, error) {
+	var lists []pricelist.PriceList
+	if err := r.db.Order("id").Find(&lists).Error; err != nil {
+		return nil, fmt.Errorf("failed to list price lists: %w", err)
+	}
+	return lists, nil
+}
+
+// GetPriceList returns a price list with its prices by product name, or pricelist.ErrPriceListNotFound
+func (r *Repository) GetPriceList(id uint) (*pricelist.PriceList, error) {
+	var list pricelist.PriceList
+	err := r.db.Preload("Prices", func(db *gorm.DB) *gorm.DB { return db.Order("product") }).First(&list, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, pricelist.ErrPriceListNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get price list: %w", err)
+	}
+	return &list, nil
+}
+
+// UpdatePriceList renames a price list and moves it to another customer group, which mustn't have a
+// price list yet
+func (r *Repository) UpdatePriceList(id uint, name, group string) error {
+	if !pricelist.ValidGroup(group) {
+		return pricelist.ErrInvalidGroup
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := groupAvailable(tx, group, id); err != nil {
+			return err
+		}
+		result := tx.Model(&pricelist.PriceList{}).Where("id = ?", id).
+			Updates(map[string]interface{}{"name": name, "customer_group": group})
+		if result.Error != nil {
+			return fmt.Errorf("failed to update price list: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return pricelist.ErrPriceListNotFound
+		}
+		return nil
+	})
+}
+
+// DeletePriceList deletes a price list and its prices, so its customer group buys at base prices again
+func (r *Repository) DeletePriceList(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Delete(&pricelist.PriceList{}, id)
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete price list: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return pricelist.ErrPriceListNotFound
+		}
+		if err := tx.Where("price_list_id = ?", id).Delete(&pricelist.Price{}).Error; err != nil {
+			return fmt.Errorf("failed to delete prices: %w", err)
+		}
+		return nil
+	})
+}
+
+// SetListPrice puts a product on a price list at the price, or changes its price there
+func (r *Repository) SetListPrice(listID uint, product string, price float64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var list pricelist.PriceList
+		if err := tx.First(&list, listID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+			return pricelist.ErrPriceListNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to get price list: %w", err)
+		}
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "price_list_id"}, {Name: "product"}},
+			DoUpdates: clause.AssignmentColumns([]string{"price"}),
+		}).Create(&pricelist.Price{PriceListID: listID, Product: product, Price: price, TenantID: list.TenantID}).Error
+		if err != nil {
+			return fmt.Errorf("failed to set price: %w", err)
+		}
+		return nil
+	})
+}
+
+// RemoveListPrice takes a product off a price list, selling it at its base price again. Removing a
+// product that isn't on the list does nothing.
+func (r *Repository) RemoveListPrice(listID uint, product string) error {
+	err := r.db.Where("price_list_id = ? AND product = ?", listID, product).Delete(&pricelist.Price{}).Error
+	if err != nil {
+		return fmt.Errorf("failed to remove price: %w", err)
+	}
+	return nil
+}
+
+// ResolvePrice returns the price of the product at the time for the user, nil for anonymous customers:
+// the price of its active price override or, where lower, its price on the price list of the user's
+// customer group (the retail group for anonymous customers), both of the shop of the context. ok is false when neither applies, in which
+// case the product sells at its base price.
+func (r *Repository) ResolvePrice(userID *uint, product string, at time.Time) (price float64, ok bool, err error) {
+	group := pricelist.GroupRetail
+	if userID != nil {
+		var u userpkg.User
+		if err := r.db.Select("customer_group").First(&u, *userID).Error; err == nil {
+			group = u.CustomerGroup
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, false, fmt.Errorf("failed to get customer group: %w", err)
+		}
+	}
+
+	var prices []pricelist.Price
+	err = r.db.Model(&pricelist.Price{}).
+		Joins("JOIN price_lists ON price_lists.id = price_list_prices.price_list_id AND price_lists.deleted_at IS NULL").
+		Where("price_lists.customer_group = ? AND price_list_prices.product = ?", group, product).
+		Limit(1).
+		Find(&prices).Error
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to resolve price: %w", err)
+	}
+	overrides, err := r.ActivePriceOverrides([]string{product}, at)
+	if err != nil {
+		return 0, false, err
+	}
+
+	override, onSale := overrides[product]
+	switch {
+	case len(prices) > 0 && (!onSale || prices[0].Price < override.Price):
+		return prices[0].Price, true, nil
+	case onSale:
+		return override.Price, true, nil
+	}
+	return 0, false, nil
+}
+
+// CatalogPrice returns the price the user, nil for anonymous customers, pays for the product at the
+// time: their price, see ResolvePrice, or else the base price of the product, that of the price provider
+// where one is set and knows the product and its price in the catalog otherwise. ok is false for
+// products not in the catalog, which aren't sold.
+func (r *Repository) CatalogPrice(userID *uint, product string, at time.Time) (price float64, ok bool, err error) {
+	var products []productpkg.Product
+	if err := r.db.Select("price").Where("name = ?", product).Limit(1).Find(&products).Error; err != nil {
+		return 0, false, fmt.Errorf("failed to get product: %w", err)
+	}
+	if len(products) == 0 {
+		return 0, false, nil
+	}
+	price, listed, err := r.ResolvePrice(userID, product, at)
+	if err != nil || listed {
+		return price, listed, err
+	}
+	if r.prices != nil {
+		price, err := r.prices.Price(r.db.Statement.Context, product)
+		if err == nil {
+			return price, true, nil
+		}
+		if !errors.Is(err, pricing.ErrProductNotFound) {
+			return 0, false, fmt.Errorf("failed to look up price of %s: %w", product, err)
+		}
+	}
+	return products[0].Price, true, nil
+}
+
+// groupAvailable fails with pricelist.ErrGroupTaken when another price list than exceptID is made for
+// the group
+func groupAvailable(db *gorm.DB, group string, exceptID uint) error {
+	var count int64
+	err := db.Model(&pricelist.PriceList{}).Where("customer_group = ? AND id <> ?", group, exceptID).Count(&count).Error
+	if err != nil {
+		return fmt.Errorf("failed to check customer group: %w", err)
+	}
+	if count > 0 {
+		return pricelist.ErrGroupTaken
+	}
+	return nil
+}
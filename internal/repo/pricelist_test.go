@@ -0,0 +1,126 @@
+package repo_test
+
+import (
+	cartpkg "interview/internal/cart"
+	"interview/internal/pricelist"
+	"interview/internal/repo"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriceLists(t *testing.T) {
+	db := setupTestDB(t)
+	cartRepo := repo.NewRepository(db)
+
+	buyer, err := cartRepo.FindOrCreateUser("github", "1", "buyer@example.com", "Buyer")
+	require.NoError(t, err)
+	assert.Equal(t, pricelist.GroupRetail, buyer.CustomerGroup, "users buy at retail prices by default")
+	require.NoError(t, cartRepo.SetUserGroup(buyer.ID, pricelist.GroupWholesale))
+	wholesale := pricelist.PriceList{Name: "Wholesale", CustomerGroup: pricelist.GroupWholesale}
+
+	t.Run("one price list per customer group", func(t *testing.T) {
+		require.NoError(t, cartRepo.CreatePriceList(&wholesale))
+		assert.ErrorIs(t, cartRepo.CreatePriceList(&pricelist.PriceList{Name: "Resellers", CustomerGroup: pricelist.GroupWholesale}),
+			pricelist.ErrGroupTaken)
+		assert.ErrorIs(t, cartRepo.CreatePriceList(&pricelist.PriceList{Name: "Staff", CustomerGroup: "staff"}),
+			pricelist.ErrInvalidGroup)
+
+		vip := pricelist.PriceList{Name: "VIP", CustomerGroup: pricelist.GroupVIP}
+		require.NoError(t, cartRepo.CreatePriceList(&vip))
+		assert.ErrorIs(t, cartRepo.UpdatePriceList(vip.ID, "VIP", pricelist.GroupWholesale), pricelist.ErrGroupTaken)
+		require.NoError(t, cartRepo.UpdatePriceList(vip.ID, "Friends", pricelist.GroupVIP))
+		assert.ErrorIs(t, cartRepo.UpdatePriceList(999, "Gone", pricelist.GroupRetail), pricelist.ErrPriceListNotFound)
+
+		lists, err := cartRepo.ListPriceLists()
+		require.NoError(t, err)
+		require.Len(t, lists, 2)
+		assert.Equal(t, "Friends", lists[1].Name)
+	})
+
+	t.Run("prices resolve by customer group", func(t *testing.T) {
+		require.NoError(t, cartRepo.SetListPrice(wholesale.ID, "shoe", 8))
+		require.NoError(t, cartRepo.SetListPrice(wholesale.ID, "shoe", 7.5))
+		require.NoError(t, cartRepo.SetListPrice(wholesale.ID, "bag", 20))
+		assert.ErrorIs(t, cartRepo.SetListPrice(999, "shoe", 1), pricelist.ErrPriceListNotFound)
+
+		list, err := cartRepo.GetPriceList(wholesale.ID)
+		require.NoError(t, err)
+		require.Len(t, list.Prices, 2)
+		assert.Equal(t, "bag", list.Prices[0].Product)
+		assert.Equal(t, 7.5, list.Prices[1].Price)
+
+		tests := []struct {
+			name    string
+			userID  *uint
+			product string
+			price   float64
+			listed  bool
+		}{
+			{name: "listed product", userID: &buyer.ID, product: "shoe", price: 7.5, listed: true},
+			{name: "product not on the list", userID: &buyer.ID, product: "watch"},
+			{name: "anonymous customer without retail list", product: "shoe"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				price, listed, err := cartRepo.ResolvePrice(tt.userID, tt.product, time.Now())
+				require.NoError(t, err)
+				assert.Equal(t, tt.listed, listed)
+				assert.Equal(t, tt.price, price)
+			})
+		}
+	})
+
+	t.Run("removed prices and lists sell at base prices", func(t *testing.T) {
+		require.NoError(t, cartRepo.RemoveListPrice(wholesale.ID, "bag"))
+		_, listed, err := cartRepo.ResolvePrice(&buyer.ID, "bag", time.Now())
+		require.NoError(t, err)
+		assert.False(t, listed)
+
+		require.NoError(t, cartRepo.DeletePriceList(wholesale.ID))
+		_, listed, err = cartRepo.ResolvePrice(&buyer.ID, "shoe", time.Now())
+		require.NoError(t, err)
+		assert.False(t, listed)
+		_, err = cartRepo.GetPriceList(wholesale.ID)
+		assert.ErrorIs(t, err, pricelist.ErrPriceListNotFound)
+		assert.ErrorIs(t, cartRepo.DeletePriceList(wholesale.ID), pricelist.ErrPriceListNotFound)
+
+		// The group can get a new price list
+		wholesale = pricelist.PriceList{Name: "Wholesale", CustomerGroup: pricelist.GroupWholesale}
+		require.NoError(t, cartRepo.CreatePriceList(&wholesale))
+	})
+
+	t.Run("carts of users are repriced to their price list", func(t *testing.T) {
+		_, err := cartRepo.UpsertProduct("shoe", 10)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.SetListPrice(wholesale.ID, "shoe", 6))
+
+		c, err := cartRepo.GetOrCreateCart("buyer-session", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AssignCartToUser("buyer-session", buyer.ID))
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 1, 7.5))
+
+		stale, err := cartRepo.ListStalePrices(10, time.Now())
+		require.NoError(t, err)
+		require.Len(t, stale, 1)
+		assert.Equal(t, 6.0, stale[0].CatalogPrice)
+
+		changed, err := cartRepo.RepriceCart(c.ID, time.Now())
+		require.NoError(t, err)
+		assert.True(t, changed)
+		repriced, err := cartRepo.GetCart(c.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 6.0, repriced.CartItems[0].Price)
+
+		stale, err = cartRepo.ListStalePrices(10, time.Now())
+		require.NoError(t, err)
+		assert.Empty(t, stale)
+	})
+
+	t.Run("customer groups are checked", func(t *testing.T) {
+		assert.ErrorIs(t, cartRepo.SetUserGroup(buyer.ID, "staff"), pricelist.ErrInvalidGroup)
+		assert.ErrorIs(t, cartRepo.SetUserGroup(999, pricelist.GroupVIP), repo.ErrUserNotFound)
+	})
+}
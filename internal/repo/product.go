@@ -0,0 +1,277 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	productpkg "interview/internal/product"
+	"log"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// UpsertProduct creates the product or updates its price when a product with the same name exists
+func (r *Repository) UpsertProduct(name string, price float64) (*productpkg.Product, error) {
+	var p productpkg.Product
+	err := r.db.Where("name = ?", name).First(&p).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		p = productpkg.Product{Name: name, Price: price}
+		if err := r.db.Create(&p).Error; err != nil {
+			return nil, fmt.Errorf("failed to create product: %w", err)
+		}
+		r.indexProduct(p)
+		return &p, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get product: %w", err)
+	}
+
+	if err := r.db.Model(&p).Update("price", price).Error; err != nil {
+		return nil, fmt.Errorf("failed to update product: %w", err)
+	}
+	r.invalidateProduct(p.ID)
+	r.indexProduct(p)
+	return &p, nil
+}
+
+// HasProduct reports whether the catalog has a product with the name
+func (r *Repository) HasProduct(name string) (bool, error) {
+	var count int64
+	if err := r.db.Model(&productpkg.Product{}).Where("name = ?", name).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to get product: %w", err)
+	}
+	return count > 0, nil
+}
+
+// ListProducts returns all products ordered by name
+func (r *Repository) ListProducts() ([]productpkg.Product, error) {
+	var products []productpkg.Product
+	if err := r.db.Order("name").Find(&products).Error; err != nil {
+		return nil, fmt.Errorf("failed to list products: %w", err)
+	}
+	return products, nil
+}
+
+// GetProduct returns the product with the given ID
+func (r *Repository) GetProduct(id uint) (*productpkg.Product, error) {
+	var p productpkg.Product
+	if err := r.db.First(&p, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to get product: %w", err)
+	}
+	return &p, nil
+}
+
+// GetProductsByName returns the products with the given names keyed by name; unknown names are skipped
+func (r *Repository) GetProductsByName(names []string) (map[string]productpkg.Product, error) {
+	var products []productpkg.Product
+	if err := r.db.Where("name IN ?", names).Find(&products).Error; err != nil {
+		return nil, fmt.Errorf("failed to get products: %w", err)
+	}
+	byName := make(map[string]productpkg.Product, len(products))
+	for _, p := range products {
+		byName[p.Name] = p
+	}
+	return byName, nil
+}
+
+// GetProductsByID returns the products with the given IDs in the same order; unknown IDs are skipped
+func (r *Repository) GetProductsByID(ids []uint) ([]productpkg.Product, error) {
+	var found []productpkg.Product
+	if err := r.reader().Where("id IN ?", ids).Find(&found).Error; err != nil {
+		return nil, fmt.Errorf("failed to get products: %w", err)
+	}
+	byID := make(map[uint]productpkg.Product, len(found))
+	for _, p := range found {
+		byID[p.ID] = p
+	}
+	products := make([]productpkg.Product, 0, len(found))
+	for _, id := range ids {
+		if p, ok := byID[id]; ok {
+			products = append(products, p)
+		}
+	}
+	return products, nil
+}
+
+// SetProductImage stores the storage keys of the product's image and thumbnail
+func (r *Repository) SetProductImage(id uint, imageKey, thumbnailKey string) error {
+	err := r.db.Model(&productpkg.Product{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"image_key":     imageKey,
+		"thumbnail_key": thumbnailKey,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to update product image: %w", err)
+	}
+	r.invalidateProduct(id)
+	return nil
+}
+
+// SetProductCategory puts the product in the category, trimmed, or in none for an empty one. It fails
+// with productpkg.ErrInvalidCategory for categories longer than 64 characters and with
+// gorm.ErrRecordNotFound for unknown products.
+func (r *Repository) SetProductCategory(id uint, category string) error {
+	category, err := productpkg.NormalizeCategory(category)
+	if err != nil {
+		return err
+	}
+	var p productpkg.Product
+	if err := r.db.Select("id").First(&p, id).Error; err != nil {
+		return fmt.Errorf("failed to get product: %w", err)
+	}
+	if err := r.db.Model(&p).Update("category", category).Error; err != nil {
+		return fmt.Errorf("failed to update product category: %w", err)
+	}
+	r.invalidateProduct(id)
+	return nil
+}
+
+// Product sort orders accepted by SearchProducts
+const (
+	SortByName      = "name"
+	SortByPriceAsc  = "price_asc"
+	SortByPriceDesc = "price_desc"
+	SortByNewest    = "newest"
+	// SortByRelevance ranks the best matches of a full-text search first; it falls back to
+	// SortByName without a search index
+	SortByRelevance = "relevance"
+)
+
+// ProductQuery filters and paginates the product catalog
+type ProductQuery struct {
+	// Search matches products whose name contains it, ignoring case
+	Search string
+	// MinPrice and MaxPrice bound the price when non-nil
+	MinPrice *float64
+	MaxPrice *float64
+	// Sort is one of the SortBy constants, SortByName when empty
+	Sort string
+	// Page is 1-based
+	Page    int
+	PerPage int
+}
+
+// SearchProducts returns one page of the products matching the query and the total number of matches.
+// Name searches go to the product index when one is set, which tolerates typos; otherwise they are
+// substring matches in SQL.
+func (r *Repository) SearchProducts(q ProductQuery) ([]productpkg.Product, int64, error) {
+	if r.productIndex != nil && q.Search != "" {
+		return r.searchProductIndex(q)
+	}
+
+	db := r.reader().Model(&productpkg.Product{})
+	if q.Search != "" {
+		db = db.Where("LOWER(name) LIKE ? ESCAPE '!'", "%"+escapeLike(strings.ToLower(q.Search))+"%")
+	}
+	if q.MinPrice != nil {
+		db = db.Where("price >= ?", *q.MinPrice)
+	}
+	if q.MaxPrice != nil {
+		db = db.Where("price <= ?", *q.MaxPrice)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count products: %w", err)
+	}
+
+	switch q.Sort {
+	case SortByPriceAsc:
+		db = db.Order("price").Order("name")
+	case SortByPriceDesc:
+		db = db.Order("price DESC").Order("name")
+	case SortByNewest:
+		db = db.Order("created_at DESC").Order("id DESC")
+	default:
+		db = db.Order("name")
+	}
+
+	var products []productpkg.Product
+	err := db.Offset((q.Page - 1) * q.PerPage).Limit(q.PerPage).Find(&products).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search products: %w", err)
+	}
+	return products, total, nil
+}
+
+// escapeLike escapes the LIKE wildcards in a user-provided search term. "!" is used as the escape
+// character because backslashes are treated differently by MySQL and SQLite.
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+// ProductIndex is a full-text search index of the products. The repository keeps it in sync with
+// products it writes.
+type ProductIndex interface {
+	// IndexProduct adds or replaces the product in the index
+	IndexProduct(ctx context.Context, p productpkg.Product) error
+	// SearchProducts returns the IDs of one page of matching products, in order, and the total number of matches
+	SearchProducts(ctx context.Context, q ProductQuery) ([]uint, int64, error)
+}
+
+// SetProductIndex makes the repository index the products it writes and use the index for name searches.
+func (r *Repository) SetProductIndex(index ProductIndex) {
+	r.productIndex = index
+}
+
+// ReindexProducts adds all products to the index, e.g. after it was created or fell out of sync
+func (r *Repository) ReindexProducts(ctx context.Context) (int, error) {
+	if r.productIndex == nil {
+		return 0, errors.New("no product index configured")
+	}
+
+	var products []productpkg.Product
+	count := 0
+	err := r.db.FindInBatches(&products, 500, func(tx *gorm.DB, batch int) error {
+		for _, p := range products {
+			if err := r.productIndex.IndexProduct(ctx, p); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return count, fmt.Errorf("failed to reindex products: %w", err)
+	}
+	return count, nil
+}
+
+// indexProduct updates the product in the index. Failures are logged rather than returned as the
+// database is the source of truth; ReindexProducts repairs the index.
+func (r *Repository) indexProduct(p productpkg.Product) {
+	if r.productIndex == nil {
+		return
+	}
+	if err := r.productIndex.IndexProduct(context.Background(), p); err != nil {
+		log.Printf("Failed to index product %d: %v", p.ID, err)
+	}
+}
+
+func (r *Repository) searchProductIndex(q ProductQuery) ([]productpkg.Product, int64, error) {
+	ids, total, err := r.productIndex.SearchProducts(context.Background(), q)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search products: %w", err)
+	}
+	if len(ids) == 0 {
+		return nil, total, nil
+	}
+
+	var found []productpkg.Product
+	if err := r.reader().Where("id IN ?", ids).Find(&found).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to load products: %w", err)
+	}
+	byID := make(map[uint]productpkg.Product, len(found))
+	for _, p := range found {
+		byID[p.ID] = p
+	}
+
+	// Keep the index order and skip products deleted since they were indexed
+	products := make([]productpkg.Product, 0, len(ids))
+	for _, id := range ids {
+		if p, ok := byID[id]; ok {
+			products = append(products, p)
+		}
+	}
+	return products, total, nil
+}
@@ -0,0 +1,43 @@
+package repo
+
+import (
+	"context"
+	"interview/internal/cache"
+	productpkg "interview/internal/product"
+	"strconv"
+	"time"
+)
+
+// SetProductCache makes storefront product lookups go through the cache, keeping products in the store
+// for ttl. The product updates of the repository invalidate them.
+func (r *Repository) SetProductCache(store cache.Store, ttl time.Duration) {
+	r.products = cache.New(store, "product", ttl)
+}
+
+// GetCachedProduct returns the product with the given ID like GetProduct, but from the product cache
+// when one is set. Stock sold at checkout may take until the cached product expires to show; CheckStock
+// always reads the database. Products are cached by ID for every shop; the cached product of another
+// shop than the one of the repository's context is looked up in the database like a missing one.
+func (r *Repository) GetCachedProduct(id uint) (*productpkg.Product, error) {
+	if r.products == nil {
+		return r.GetProduct(id)
+	}
+	ctx, key := context.Background(), strconv.FormatUint(uint64(id), 10)
+	var p productpkg.Product
+	if r.products.Get(ctx, key, &p) && inTenant(r.db.Statement.Context, p.TenantID) {
+		return &p, nil
+	}
+	found, err := r.GetProduct(id)
+	if err != nil {
+		return nil, err
+	}
+	r.products.Set(ctx, key, found)
+	return found, nil
+}
+
+// invalidateProduct removes the product from the product cache after it changed
+func (r *Repository) invalidateProduct(id uint) {
+	if r.products != nil {
+		r.products.Invalidate(context.Background(), strconv.FormatUint(uint64(id), 10))
+	}
+}
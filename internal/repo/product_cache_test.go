@@ -0,0 +1,73 @@
+package repo_test
+
+import (
+	"interview/internal/cache"
+	"interview/internal/repo"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestProductCache(t *testing.T) {
+	db := setupTestDB(t)
+	store := cache.NewMemory()
+	storefront := repo.NewRepository(db)
+	storefront.SetProductCache(store, time.Minute)
+	// The admin endpoints have a repository of their own sharing the store
+	admin := repo.NewRepository(db)
+	admin.SetProductCache(store, time.Minute)
+
+	shoe, err := admin.UpsertProduct("shoe", 10)
+	require.NoError(t, err)
+
+	t.Run("serves cached products", func(t *testing.T) {
+		p, err := storefront.GetCachedProduct(shoe.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 10.0, p.Price)
+
+		// Written behind the back of the repository, so the cached product is served
+		require.NoError(t, db.Exec("UPDATE products SET price = 99 WHERE id = ?", shoe.ID).Error)
+		p, err = storefront.GetCachedProduct(shoe.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 10.0, p.Price)
+		assert.Equal(t, "shoe", p.Name)
+
+		p, err = storefront.GetProduct(shoe.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 99.0, p.Price, "uncached lookups read the database")
+	})
+
+	t.Run("product updates invalidate the cache", func(t *testing.T) {
+		_, err := admin.UpsertProduct("shoe", 12)
+		require.NoError(t, err)
+		p, err := storefront.GetCachedProduct(shoe.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 12.0, p.Price)
+
+		stock := 3
+		require.NoError(t, admin.SetProductStock(shoe.ID, &stock))
+		p, err = storefront.GetCachedProduct(shoe.ID)
+		require.NoError(t, err)
+		require.NotNil(t, p.Stock)
+		assert.Equal(t, 3, *p.Stock)
+
+		require.NoError(t, admin.SetProductImage(shoe.ID, "products/shoe.png", "products/shoe-thumb.png"))
+		p, err = storefront.GetCachedProduct(shoe.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "products/shoe.png", p.ImageKey)
+	})
+
+	t.Run("unknown products are not cached", func(t *testing.T) {
+		_, err := storefront.GetCachedProduct(shoe.ID + 100)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	})
+
+	t.Run("works without a cache", func(t *testing.T) {
+		p, err := repo.NewRepository(db).GetCachedProduct(shoe.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 12.0, p.Price)
+	})
+}
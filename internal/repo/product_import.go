@@ -0,0 +1,182 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	productpkg "interview/internal/product"
+	"interview/internal/warehouse"
+
+	"gorm.io/gorm"
+)
+
+// errDryRun rolls back the transaction of a dry-run import
+var errDryRun = errors.New("dry run")
+
+type (
+	// ProductImport sets the fields of the product named Name, creating the product when it isn't in
+	// the catalog. Nil fields and an empty Type leave the product unchanged.
+	ProductImport struct {
+		Name              string
+		Price             *float64
+		Stock             *int
+		LowStockThreshold *int
+		Type              string
+	}
+
+	// ProductImporter applies the rows of an import in its transaction, see ImportProducts.
+	ProductImporter struct {
+		tx *gorm.DB
+		// Created and Updated count the rows applied so far
+		Created int
+		Updated int
+		changed []productpkg.Product
+	}
+)
+
+// ImportProducts calls fn with an importer applying rows in one transaction. The transaction is
+// committed when fn returns nil, and rolled back when it returns an error or dryRun is set.
+func (r *Repository) ImportProducts(dryRun bool, fn func(*ProductImporter) error) error {
+	importer := &ProductImporter{}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		importer.tx = tx
+		if err := fn(importer); err != nil {
+			return err
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if errors.Is(err, errDryRun) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for _, p := range importer.changed {
+		r.invalidateProduct(p.ID)
+		r.indexProduct(p)
+	}
+	return nil
+}
+
+// EachProductBatch calls fn with all products in batches of up to size products ordered by ID, holding
+// one batch in memory at a time. It stops at the first error returned by fn.
+func (r *Repository) EachProductBatch(size int, fn func([]productpkg.Product) error) error {
+	var batch []productpkg.Product
+	err := r.reader().FindInBatches(&batch, size, func(*gorm.DB, int) error {
+		return fn(batch)
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to iterate products: %w", err)
+	}
+	return nil
+}
+
+// Apply creates or updates the product of the row. Rows that can't be applied, see
+// IsProductImportFailure, fail without changing anything, leaving it to the caller whether to go on
+// with the import.
+func (i *ProductImporter) Apply(row ProductImport) error {
+	p, created, err := upsertProduct(i.tx, row)
+	if err != nil {
+		return err
+	}
+	if created {
+		i.Created++
+	} else {
+		i.Updated++
+	}
+	i.changed = append(i.changed, *p)
+	return nil
+}
+
+// IsProductImportFailure reports whether a ProductImport failed because of its own fields rather than
+// the database.
+func IsProductImportFailure(err error) bool {
+	for _, failure := range []error{
+		productpkg.ErrNameRequired, productpkg.ErrInvalidPrice, productpkg.ErrPriceRequired,
+		productpkg.ErrInvalidStock, productpkg.ErrInvalidThreshold, productpkg.ErrInvalidType,
+		productpkg.ErrNoFile, warehouse.ErrStockInWarehouses,
+	} {
+		if errors.Is(err, failure) {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks the fields of the row that don't depend on the catalog
+func (row ProductImport) validate() error {
+	switch {
+	case row.Name == "":
+		return productpkg.ErrNameRequired
+	case row.Price != nil && *row.Price < 0:
+		return productpkg.ErrInvalidPrice
+	case row.Stock != nil && *row.Stock < 0:
+		return productpkg.ErrInvalidStock
+	case row.LowStockThreshold != nil && *row.LowStockThreshold < 1:
+		return productpkg.ErrInvalidThreshold
+	case row.Type != "" && row.Type != productpkg.TypePhysical && row.Type != productpkg.TypeDigital:
+		return productpkg.ErrInvalidType
+	}
+	return nil
+}
+
+// upsertProduct creates or updates the product of the row and returns it, and whether it was created
+func upsertProduct(tx *gorm.DB, row ProductImport) (*productpkg.Product, bool, error) {
+	if err := row.validate(); err != nil {
+		return nil, false, err
+	}
+
+	var p productpkg.Product
+	err := tx.Where("name = ?", row.Name).First(&p).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if row.Price == nil {
+			return nil, false, productpkg.ErrPriceRequired
+		}
+		if row.Type == productpkg.TypeDigital {
+			return nil, false, productpkg.ErrNoFile
+		}
+		p = productpkg.Product{
+			Name: row.Name, Price: *row.Price, Stock: row.Stock, LowStockThreshold: row.LowStockThreshold,
+			Type: productpkg.TypePhysical,
+		}
+		if err := tx.Create(&p).Error; err != nil {
+			return nil, false, fmt.Errorf("failed to create product: %w", err)
+		}
+		return &p, true, nil
+	} else if err != nil {
+		return nil, false, fmt.Errorf("failed to get product: %w", err)
+	}
+
+	changes := map[string]interface{}{}
+	if row.Price != nil && *row.Price != p.Price {
+		changes["price"] = *row.Price
+	}
+	if row.Stock != nil && (p.Stock == nil || *row.Stock != *p.Stock) {
+		var kept int64
+		if err := tx.Model(&warehouse.Stock{}).Where("product_id = ?", p.ID).Count(&kept).Error; err != nil {
+			return nil, false, fmt.Errorf("failed to check warehouse stock: %w", err)
+		}
+		if kept > 0 {
+			return nil, false, warehouse.ErrStockInWarehouses
+		}
+		changes["stock"] = *row.Stock
+	}
+	if row.LowStockThreshold != nil && (p.LowStockThreshold == nil || *row.LowStockThreshold != *p.LowStockThreshold) {
+		changes["low_stock_threshold"] = *row.LowStockThreshold
+		changes["low_stock_snoozed_until"] = nil
+	}
+	if row.Type != "" && row.Type != p.Type {
+		if row.Type == productpkg.TypeDigital && p.FileKey == "" {
+			return nil, false, productpkg.ErrNoFile
+		}
+		changes["type"] = row.Type
+	}
+	if len(changes) > 0 {
+		if err := tx.Model(&p).Updates(changes).Error; err != nil {
+			return nil, false, fmt.Errorf("failed to update product: %w", err)
+		}
+	}
+	return &p, false, nil
+}
@@ -0,0 +1,144 @@
+package repo_test
+
+import (
+	"context"
+	"interview/internal/product"
+	"interview/internal/repo"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpsertProduct(t *testing.T) {
+	db := setupTestDB(t)
+	repo := repo.NewRepository(db)
+
+	created, err := repo.UpsertProduct("shoe", 10.0)
+	require.NoError(t, err)
+
+	updated, err := repo.UpsertProduct("shoe", 12.0)
+	require.NoError(t, err)
+	assert.Equal(t, created.ID, updated.ID)
+
+	products, err := repo.ListProducts()
+	require.NoError(t, err)
+	require.Len(t, products, 1)
+	assert.Equal(t, 12.0, products[0].Price)
+}
+
+func TestMigrateDown(t *testing.T) {
+	db := setupTestDB(t)
+
+	require.NoError(t, repo.MigrateDown(db))
+	assert.False(t, db.Migrator().HasTable("carts"))
+	assert.False(t, db.Migrator().HasTable("products"))
+
+	require.NoError(t, repo.Migrate(db))
+	assert.True(t, db.Migrator().HasTable("carts"))
+}
+
+func TestSearchProducts(t *testing.T) {
+	db := setupTestDB(t)
+	cartRepo := repo.NewRepository(db)
+
+	for name, price := range map[string]float64{
+		"shoe": 10, "snow_shoe": 45, "purse": 20, "bag": 30, "watch": 300, "100%_cotton_bag": 15,
+	} {
+		_, err := cartRepo.UpsertProduct(name, price)
+		require.NoError(t, err)
+	}
+
+	price := func(p float64) *float64 { return &p }
+	names := func(products []product.Product) []string {
+		result := make([]string, len(products))
+		for i, p := range products {
+			result[i] = p.Name
+		}
+		return result
+	}
+
+	tests := []struct {
+		name          string
+		query         repo.ProductQuery
+		expected      []string
+		expectedTotal int64
+	}{
+		{
+			name:          "Search By Name",
+			query:         repo.ProductQuery{Search: "SHOE", Page: 1, PerPage: 10},
+			expected:      []string{"shoe", "snow_shoe"},
+			expectedTotal: 2,
+		},
+		{
+			name:          "Wildcards Are Literal",
+			query:         repo.ProductQuery{Search: "100%_", Page: 1, PerPage: 10},
+			expected:      []string{"100%_cotton_bag"},
+			expectedTotal: 1,
+		},
+		{
+			name:          "Price Range Sorted By Price",
+			query:         repo.ProductQuery{MinPrice: price(15), MaxPrice: price(45), Sort: repo.SortByPriceDesc, Page: 1, PerPage: 10},
+			expected:      []string{"snow_shoe", "bag", "purse", "100%_cotton_bag"},
+			expectedTotal: 4,
+		},
+		{
+			name:          "Second Page",
+			query:         repo.ProductQuery{Sort: repo.SortByPriceAsc, Page: 2, PerPage: 4},
+			expected:      []string{"snow_shoe", "watch"},
+			expectedTotal: 6,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			products, total, err := cartRepo.SearchProducts(tt.query)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, names(products))
+			assert.Equal(t, tt.expectedTotal, total)
+		})
+	}
+}
+
+// fakeIndex is an in-memory ProductIndex returning a fixed search result
+type fakeIndex struct {
+	indexed map[uint]string
+	results []uint
+}
+
+func (f *fakeIndex) IndexProduct(_ context.Context, p product.Product) error {
+	f.indexed[p.ID] = p.Name
+	return nil
+}
+
+func (f *fakeIndex) SearchProducts(_ context.Context, _ repo.ProductQuery) ([]uint, int64, error) {
+	return f.results, int64(len(f.results)), nil
+}
+
+func TestProductIndex(t *testing.T) {
+	db := setupTestDB(t)
+	cartRepo := repo.NewRepository(db)
+	index := &fakeIndex{indexed: map[uint]string{}}
+
+	unindexed, err := cartRepo.UpsertProduct("bag", 30.0)
+	require.NoError(t, err)
+
+	cartRepo.SetProductIndex(index)
+	shoe, err := cartRepo.UpsertProduct("shoe", 10.0)
+	require.NoError(t, err)
+	assert.Equal(t, map[uint]string{shoe.ID: "shoe"}, index.indexed)
+
+	count, err := cartRepo.ReindexProducts(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, "bag", index.indexed[unindexed.ID])
+
+	// Results keep the index order and skip products missing from the database
+	index.results = []uint{shoe.ID, 999, unindexed.ID}
+	products, total, err := cartRepo.SearchProducts(repo.ProductQuery{Search: "sheo", Page: 1, PerPage: 10})
+	require.NoError(t, err)
+	require.Len(t, products, 2)
+	assert.Equal(t, "shoe", products[0].Name)
+	assert.Equal(t, "bag", products[1].Name)
+	assert.Equal(t, int64(3), total)
+}
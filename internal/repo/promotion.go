@@ -0,0 +1,23 @@
+package repo
+
+import (
+	"fmt"
+	"interview/internal/promotion"
+)
+
+// CreatePromotion stores a new promotion. Active promotions apply from the next change of each cart.
+func (r *Repository) CreatePromotion(p *promotion.Promotion) error {
+	if err := r.db.Create(p).Error; err != nil {
+		return fmt.Errorf("failed to create promotion: %w", err)
+	}
+	return nil
+}
+
+// ListPromotions returns all promotions ordered by ID
+func (r *Repository) ListPromotions() ([]promotion.Promotion, error) {
+	var promotions []promotion.Promotion
+	if err := r.db.Order("id").Find(&promotions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list promotions: %w", err)
+	}
+	return promotions, nil
+}
@@ -0,0 +1,51 @@
+package repo_test
+
+import (
+	cartpkg "interview/internal/cart"
+	"interview/internal/promotion"
+	"interview/internal/repo"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromotionsAppliedOnCartChange(t *testing.T) {
+	db := setupTestDB(t)
+	cartRepo := repo.NewRepository(db)
+
+	require.NoError(t, cartRepo.CreatePromotion(&promotion.Promotion{
+		Name: "3 shoes, cheapest free", Type: promotion.TypeBuyXGetY, Active: true,
+		Products: "shoe", BuyQuantity: 3, FreeQuantity: 1,
+	}))
+	require.NoError(t, cartRepo.CreatePromotion(&promotion.Promotion{
+		Name: "10% off over 100", Type: promotion.TypeThreshold, Active: true,
+		MinSubtotal: 100, PercentOff: 10,
+	}))
+
+	c, err := cartRepo.GetOrCreateCart("promo-session", cartpkg.DefaultName)
+	require.NoError(t, err)
+
+	require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 3, 40.0))
+	c, err = cartRepo.GetOrCreateCart("promo-session", cartpkg.DefaultName)
+	require.NoError(t, err)
+	require.Len(t, c.Discounts, 1)
+	assert.Equal(t, "3 shoes, cheapest free", c.Discounts[0].Promotion)
+	assert.Equal(t, 40.0, c.Discounts[0].Amount)
+	assert.Equal(t, 80.0, c.Total)
+
+	require.NoError(t, cartRepo.AddCartItem(c.ID, "watch", 1, 30.0))
+	c, err = cartRepo.GetOrCreateCart("promo-session", cartpkg.DefaultName)
+	require.NoError(t, err)
+	require.Len(t, c.Discounts, 2)
+	assert.Equal(t, 11.0, c.Discounts[1].Amount)
+	assert.Equal(t, 99.0, c.Total)
+
+	for _, item := range c.CartItems {
+		require.NoError(t, cartRepo.RemoveCartItem(c.ID, item.ID))
+	}
+	c, err = cartRepo.GetOrCreateCart("promo-session", cartpkg.DefaultName)
+	require.NoError(t, err)
+	assert.Empty(t, c.Discounts)
+	assert.Equal(t, 0.0, c.Total)
+}
@@ -0,0 +1,135 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	cartpkg "interview/internal/cart"
+	"interview/internal/order"
+	productpkg "interview/internal/product"
+	"interview/internal/recommend"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// maxAssociations is how many associated products are stored per product, the best scored
+	maxAssociations = 20
+	// associationInsertBatch is how many associations are stored per insert
+	associationInsertBatch = 500
+)
+
+// ListBoughtTogether returns up to limit products associated with any of the named products, those
+// with the highest scores summed over the named products first, see ComputeAssociations. The named
+// products themselves are left out, and so are the products of other shops.
+func (r *Repository) ListBoughtTogether(ctx context.Context, names []string, limit int) ([]productpkg.Product, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	var products []productpkg.Product
+	err := r.WithContext(ctx).reader().
+		Select("products.*").
+		Joins("JOIN product_associations ON product_associations.related_id = products.id").
+		Joins("JOIN products bought ON bought.id = product_associations.product_id AND bought.name IN ? "+
+			"AND bought.deleted_at IS NULL", names).
+		Where("products.name NOT IN ?", names).
+		Group("products.id").
+		Order("SUM(product_associations.score) DESC, SUM(product_associations.carts) DESC, products.name").
+		Limit(limit).
+		Find(&products).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list products bought together: %w", err)
+	}
+	return products, nil
+}
+
+// ComputeAssociations replaces the stored product associations with those of the carts checked out so
+// far and returns how many it stored. Each product keeps the maxAssociations products bought with it
+// in the most carts, scored with recommend.Score. Carts whose order was cancelled or refunded don't
+// count, and neither do the other items of a bundle, which are always bought together.
+func (r *Repository) ComputeAssociations(ctx context.Context, now time.Time) (int, error) {
+	db := r.WithContext(ctx).reader()
+	// Carts are matched with the products of their own shop
+	bought := func(query *gorm.DB) *gorm.DB {
+		return query.
+			Joins("JOIN carts ON carts.id = a.cart_id AND carts.status = ? AND carts.deleted_at IS NULL", cartpkg.StatusClosed).
+			Joins("JOIN products pa ON pa.name = a.product_name AND pa.tenant_id = carts.tenant_id AND pa.deleted_at IS NULL").
+			Where("a.deleted_at IS NULL").
+			Where("NOT EXISTS (SELECT 1 FROM orders WHERE orders.cart_id = carts.id AND orders.status IN ? "+
+				"AND orders.deleted_at IS NULL)", []string{order.StatusCancelled, order.StatusRefunded})
+	}
+
+	var carts []struct {
+		ProductID uint
+		Carts     int
+	}
+	err := bought(db.Table("cart_items a")).
+		Select("pa.id AS product_id, COUNT(DISTINCT carts.id) AS carts").
+		Group("pa.id").
+		Scan(&carts).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to count carts per product: %w", err)
+	}
+	var pairs []struct {
+		ProductID uint
+		RelatedID uint
+		Carts     int
+	}
+	err = bought(db.Table("cart_items a")).
+		Joins("JOIN cart_items b ON b.cart_id = a.cart_id AND b.product_name <> a.product_name AND b.deleted_at IS NULL " +
+			"AND (a.bundle_group = 0 OR b.bundle_group <> a.bundle_group)").
+		Joins("JOIN products pb ON pb.name = b.product_name AND pb.tenant_id = carts.tenant_id AND pb.deleted_at IS NULL").
+		Select("pa.id AS product_id, pb.id AS related_id, COUNT(DISTINCT carts.id) AS carts").
+		Group("pa.id, pb.id").
+		Scan(&pairs).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to count products bought together: %w", err)
+	}
+
+	perProduct := make(map[uint]int, len(carts))
+	for _, c := range carts {
+		perProduct[c.ProductID] = c.Carts
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].ProductID != pairs[j].ProductID {
+			return pairs[i].ProductID < pairs[j].ProductID
+		}
+		if pairs[i].Carts != pairs[j].Carts {
+			return pairs[i].Carts > pairs[j].Carts
+		}
+		return pairs[i].RelatedID < pairs[j].RelatedID
+	})
+	associations := make([]recommend.Association, 0, len(pairs))
+	kept := make(map[uint]int)
+	for _, pair := range pairs {
+		if kept[pair.ProductID] == maxAssociations {
+			continue
+		}
+		kept[pair.ProductID]++
+		associations = append(associations, recommend.Association{
+			ProductID:  pair.ProductID,
+			RelatedID:  pair.RelatedID,
+			Carts:      pair.Carts,
+			Score:      recommend.Score(pair.Carts, perProduct[pair.ProductID]),
+			ComputedAt: now,
+		})
+	}
+
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&recommend.Association{}).Error; err != nil {
+			return fmt.Errorf("failed to remove product associations: %w", err)
+		}
+		if len(associations) == 0 {
+			return nil
+		}
+		if err := tx.CreateInBatches(associations, associationInsertBatch).Error; err != nil {
+			return fmt.Errorf("failed to store product associations: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(associations), nil
+}
@@ -0,0 +1,119 @@
+package repo_test
+
+import (
+	"context"
+	cartpkg "interview/internal/cart"
+	"interview/internal/order"
+	productpkg "interview/internal/product"
+	"interview/internal/recommend"
+	"interview/internal/repo"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListBoughtTogether(t *testing.T) {
+	db := setupTestDB(t)
+	cartRepo := repo.NewRepository(db)
+	ids := map[string]uint{}
+	for _, name := range []string{"shoe", "sock", "lace", "bag", "hat", "pen", "ink", "writing set"} {
+		p, err := cartRepo.UpsertProduct(name, 10)
+		require.NoError(t, err)
+		ids[name] = p.ID
+	}
+	require.NoError(t, cartRepo.SetBundleComponents(ids["writing set"], []productpkg.BundleComponent{
+		{ProductID: ids["pen"], Quantity: 1}, {ProductID: ids["ink"], Quantity: 1},
+	}))
+
+	// checkout fills a cart of the session with the products and closes it unless open is set
+	checkout := func(t *testing.T, session string, open bool, products ...string) *cartpkg.Cart {
+		t.Helper()
+		c, err := cartRepo.GetOrCreateCart(session, cartpkg.DefaultName)
+		require.NoError(t, err)
+		for _, p := range products {
+			require.NoError(t, cartRepo.AddCartItem(c.ID, p, 1, 10))
+		}
+		if !open {
+			require.NoError(t, cartRepo.CloseCart(c.ID))
+		}
+		return c
+	}
+	checkout(t, "bought-1", false, "shoe", "sock", "lace")
+	checkout(t, "bought-2", false, "shoe", "sock")
+	checkout(t, "bought-3", false, "bag", "hat")
+	checkout(t, "bought-4", true, "shoe", "hat")
+	cancelled := checkout(t, "bought-5", false, "shoe", "hat")
+	o, err := cartRepo.GetOrderByCart(cancelled.ID)
+	require.NoError(t, err)
+	_, err = cartRepo.TransitionOrder(o.ID, order.StatusCancelled, "admin", "")
+	require.NoError(t, err)
+	set := checkout(t, "bought-6", true)
+	require.NoError(t, cartRepo.AddBundle(set.ID, ids["writing set"], 1, 15))
+	require.NoError(t, cartRepo.CloseCart(set.ID))
+
+	products, err := cartRepo.ListBoughtTogether(context.Background(), []string{"shoe"}, 4)
+	require.NoError(t, err)
+	assert.Empty(t, products, "before associations are computed")
+	stored, err := cartRepo.ComputeAssociations(context.Background(), time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 8, stored, "shoe, sock and lace with each other, bag and hat with each other")
+
+	var associations []recommend.Association
+	require.NoError(t, db.Where("product_id = ?", ids["shoe"]).Order("score DESC").Find(&associations).Error)
+	require.Len(t, associations, 2)
+	assert.Equal(t, ids["sock"], associations[0].RelatedID)
+	assert.Equal(t, 2, associations[0].Carts)
+	assert.Equal(t, 1.0, associations[0].Score)
+	assert.Equal(t, 0.5, associations[1].Score, "lace is in one of the two carts with a shoe")
+	stored, err = cartRepo.ComputeAssociations(context.Background(), time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 8, stored, "replacing the stored associations")
+
+	names := func(products []productpkg.Product) []string {
+		var names []string
+		for _, p := range products {
+			names = append(names, p.Name)
+		}
+		return names
+	}
+
+	tests := []struct {
+		name     string
+		products []string
+		limit    int
+		want     []string
+	}{
+		{name: "most frequent first", products: []string{"shoe"}, limit: 4, want: []string{"sock", "lace"}},
+		{name: "limit", products: []string{"shoe"}, limit: 1, want: []string{"sock"}},
+		{name: "leaves out cart products", products: []string{"shoe", "sock"}, limit: 4, want: []string{"lace"}},
+		{name: "open and cancelled carts don't count", products: []string{"hat"}, limit: 4, want: []string{"bag"}},
+		{name: "bundle parts aren't bought together", products: []string{"pen"}, limit: 4, want: nil},
+		{name: "never bought", products: []string{"umbrella"}, limit: 4, want: nil},
+		{name: "empty cart", products: nil, limit: 4, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			products, err := cartRepo.ListBoughtTogether(context.Background(), tt.products, tt.limit)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, names(products))
+		})
+	}
+}
+
+func TestGetProductsByID(t *testing.T) {
+	db := setupTestDB(t)
+	cartRepo := repo.NewRepository(db)
+	shoe, err := cartRepo.UpsertProduct("shoe", 10)
+	require.NoError(t, err)
+	bag, err := cartRepo.UpsertProduct("bag", 30)
+	require.NoError(t, err)
+
+	products, err := cartRepo.GetProductsByID([]uint{bag.ID, 9999, shoe.ID})
+	require.NoError(t, err)
+	require.Len(t, products, 2)
+	assert.Equal(t, "bag", products[0].Name)
+	assert.Equal(t, "shoe", products[1].Name)
+}
@@ -0,0 +1,167 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	cartpkg "interview/internal/cart"
+	"interview/internal/giftcard"
+	"interview/internal/referral"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrReferralNotFound is returned when no referral has the given code
+	ErrReferralNotFound = errors.New("referral code not found")
+	// ErrSelfReferral is returned when users enter their own referral code
+	ErrSelfReferral = errors.New("referral codes can't be used by their owner")
+	// ErrNotNewCustomer is returned when a customer who already checked out enters a referral code
+	ErrNotNewCustomer = errors.New("referral codes are only for new customers")
+	// ErrReferralLoginRequired is returned when a customer who isn't logged in enters a referral code
+	ErrReferralLoginRequired = errors.New("referral codes require a logged-in customer")
+)
+
+// SetReferralReward sets the credit granted to referrers for each referred cart checked out, 0 grants none
+func (r *Repository) SetReferralReward(amount float64) {
+	r.referralReward = amount
+}
+
+// GetReferral returns the referral code of the user
+func (r *Repository) GetReferral(userID uint) (*referral.Referral, error) {
+	var ref referral.Referral
+	err := r.db.Where("user_id = ?", userID).First(&ref).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrReferralNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get referral: %w", err)
+	}
+	return &ref, nil
+}
+
+// CreateReferral returns the referral code of the user, generating it on first use
+func (r *Repository) CreateReferral(userID uint) (*referral.Referral, error) {
+	ref, err := r.GetReferral(userID)
+	if !errors.Is(err, ErrReferralNotFound) {
+		return ref, err
+	}
+
+	code, err := referral.NewCode()
+	if err != nil {
+		return nil, err
+	}
+	ref = &referral.Referral{UserID: userID, Code: code}
+	if err := r.db.Create(ref).Error; err != nil {
+		// Another request may have generated the code in the meantime
+		if existing, getErr := r.GetReferral(userID); getErr == nil {
+			return existing, nil
+		}
+		return nil, fmt.Errorf("failed to create referral: %w", err)
+	}
+	return ref, nil
+}
+
+// ApplyReferralCode records the referral code on an open cart, replacing any code entered before.
+// Only logged-in customers can enter codes, as anonymous ones could be the referrer or have checked out
+// before. Users can't refer themselves, and customers who already checked out a cart aren't new anymore.
+func (r *Repository) ApplyReferralCode(cartID uint, code string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		cart, err := openCart(tx, cartID)
+		if err != nil {
+			return err
+		}
+
+		var ref referral.Referral
+		err = tx.Where("code = ?", referral.NormalizeCode(code)).First(&ref).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrReferralNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to get referral: %w", err)
+		}
+
+		if cart.UserID == nil {
+			return ErrReferralLoginRequired
+		}
+		if *cart.UserID == ref.UserID {
+			return ErrSelfReferral
+		}
+		var checkedOut int64
+		err = tx.Model(&cartpkg.Cart{}).
+			Where("user_id = ? AND status = ?", *cart.UserID, cartpkg.StatusClosed).
+			Count(&checkedOut).Error
+		if err != nil {
+			return fmt.Errorf("failed to count carts: %w", err)
+		}
+		if checkedOut > 0 {
+			return ErrNotNewCustomer
+		}
+
+		if err := tx.Model(cart).Update("referral_id", ref.ID).Error; err != nil {
+			return fmt.Errorf("failed to apply referral code: %w", err)
+		}
+		return recordChange(tx, cartpkg.Change{CartID: cart.ID, Action: cartpkg.ChangeReferral})
+	})
+}
+
+// ListReferralRewards returns the rewards earned by the user with their gift cards, newest first
+func (r *Repository) ListReferralRewards(userID uint) ([]referral.Reward, error) {
+	var rewards []referral.Reward
+	err := r.reader().Preload("GiftCard").
+		Joins("JOIN referrals ON referrals.id = referral_rewards.referral_id").
+		Where("referrals.user_id = ?", userID).
+		Order("referral_rewards.id DESC").
+		Find(&rewards).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list referral rewards: %w", err)
+	}
+	return rewards, nil
+}
+
+// rewardReferral issues a gift card to the referrer of a cart being checked out. Carts without a
+// referral code, checked out without a customer or that belong to the referrer earn nothing, and so do
+// carts of customers who checked out before, as ApplyReferralCode only checked that when the code was
+// entered. Each referred customer earns the referrer one reward at most.
+func (r *Repository) rewardReferral(tx *gorm.DB, cart *cartpkg.Cart) error {
+	if cart.ReferralID == nil || r.referralReward <= 0 {
+		return nil
+	}
+
+	var ref referral.Referral
+	if err := tx.First(&ref, *cart.ReferralID).Error; err != nil {
+		return fmt.Errorf("failed to get referral: %w", err)
+	}
+	if cart.UserID == nil || *cart.UserID == ref.UserID {
+		return nil
+	}
+	var checkedOut int64
+	err := tx.Model(&cartpkg.Cart{}).
+		Where("user_id = ? AND status = ? AND id <> ?", *cart.UserID, cartpkg.StatusClosed, cart.ID).
+		Count(&checkedOut).Error
+	if err != nil {
+		return fmt.Errorf("failed to count carts: %w", err)
+	}
+	if checkedOut > 0 {
+		return nil
+	}
+
+	code, err := giftcard.NewCode()
+	if err != nil {
+		return err
+	}
+	card := giftcard.GiftCard{TenantID: cart.TenantID, Code: code, Balance: r.referralReward}
+	if err := tx.Create(&card).Error; err != nil {
+		return fmt.Errorf("failed to create gift card: %w", err)
+	}
+	reward := referral.Reward{ReferralID: ref.ID, CartID: cart.ID, ReferredUserID: *cart.UserID, GiftCardID: card.ID}
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&reward)
+	if result.Error != nil {
+		return fmt.Errorf("failed to record referral reward: %w", result.Error)
+	}
+	// A concurrent checkout of the customer rewarded the referrer already
+	if result.RowsAffected == 0 {
+		if err := tx.Unscoped().Delete(&card).Error; err != nil {
+			return fmt.Errorf("failed to delete gift card: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package repo_test
+
+import (
+	cartpkg "interview/internal/cart"
+	"interview/internal/repo"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReferrals(t *testing.T) {
+	db := setupTestDB(t)
+	cartRepo := repo.NewRepository(db)
+	cartRepo.SetReferralReward(10)
+
+	referrer, err := cartRepo.FindOrCreateUser("github", "1", "jane@example.com", "Jane")
+	require.NoError(t, err)
+	ref, err := cartRepo.CreateReferral(referrer.ID)
+	require.NoError(t, err)
+	assert.Regexp(t, `^REF-[0-9A-F]{8}$`, ref.Code)
+
+	again, err := cartRepo.CreateReferral(referrer.ID)
+	require.NoError(t, err)
+	assert.Equal(t, ref.Code, again.Code, "users keep their code")
+
+	t.Run("unknown code", func(t *testing.T) {
+		c, err := cartRepo.GetOrCreateCart("session-unknown", cartpkg.DefaultName)
+		require.NoError(t, err)
+		assert.ErrorIs(t, cartRepo.ApplyReferralCode(c.ID, "REF-00000000"), repo.ErrReferralNotFound)
+	})
+
+	t.Run("own code is rejected", func(t *testing.T) {
+		_, err := cartRepo.GetOrCreateCart("session-referrer", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AssignCartToUser("session-referrer", referrer.ID))
+		c, err := cartRepo.GetExistingCart("session-referrer", cartpkg.DefaultName)
+		require.NoError(t, err)
+		assert.ErrorIs(t, cartRepo.ApplyReferralCode(c.ID, ref.Code), repo.ErrSelfReferral)
+	})
+
+	t.Run("anonymous customers can't enter codes", func(t *testing.T) {
+		c, err := cartRepo.GetOrCreateCart("session-anonymous", cartpkg.DefaultName)
+		require.NoError(t, err)
+		assert.ErrorIs(t, cartRepo.ApplyReferralCode(c.ID, ref.Code), repo.ErrReferralLoginRequired)
+	})
+
+	t.Run("carts checked out anonymously earn nothing", func(t *testing.T) {
+		c, err := cartRepo.GetOrCreateCart("session-sneaky", cartpkg.DefaultName)
+		require.NoError(t, err)
+		// Codes entered before they required logging in stayed on anonymous carts
+		require.NoError(t, db.Model(&cartpkg.Cart{}).Where("id = ?", c.ID).Update("referral_id", ref.ID).Error)
+		require.NoError(t, cartRepo.CloseCart(c.ID))
+
+		rewards, err := cartRepo.ListReferralRewards(referrer.ID)
+		require.NoError(t, err)
+		assert.Empty(t, rewards)
+	})
+
+	t.Run("checking out a referred cart rewards the referrer", func(t *testing.T) {
+		customer, err := cartRepo.FindOrCreateUser("github", "3", "ann@example.com", "Ann")
+		require.NoError(t, err)
+		_, err = cartRepo.GetOrCreateCart("session-new", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AssignCartToUser("session-new", customer.ID))
+		c, err := cartRepo.GetExistingCart("session-new", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.ApplyReferralCode(c.ID, " "+ref.Code+" "))
+		require.NoError(t, cartRepo.CloseCart(c.ID))
+
+		rewards, err := cartRepo.ListReferralRewards(referrer.ID)
+		require.NoError(t, err)
+		require.Len(t, rewards, 1)
+		assert.Equal(t, c.ID, rewards[0].CartID)
+		assert.Equal(t, 10.0, rewards[0].GiftCard.Balance)
+
+		card, err := cartRepo.GetGiftCard(rewards[0].GiftCard.Code)
+		require.NoError(t, err)
+		assert.Equal(t, 10.0, card.Balance)
+	})
+
+	t.Run("customers earn the referrer one reward", func(t *testing.T) {
+		customer, err := cartRepo.FindOrCreateUser("github", "4", "max@example.com", "Max")
+		require.NoError(t, err)
+		// Both carts get the code while the customer hasn't checked out yet
+		var carts []*cartpkg.Cart
+		for _, name := range []string{cartpkg.DefaultName, "gifts"} {
+			_, err := cartRepo.GetOrCreateCart("session-max", name)
+			require.NoError(t, err)
+			require.NoError(t, cartRepo.AssignCartToUser("session-max", customer.ID))
+			c, err := cartRepo.GetExistingCart("session-max", name)
+			require.NoError(t, err)
+			require.NoError(t, cartRepo.ApplyReferralCode(c.ID, ref.Code))
+			carts = append(carts, c)
+		}
+		before, err := cartRepo.ListReferralRewards(referrer.ID)
+		require.NoError(t, err)
+		for _, c := range carts {
+			require.NoError(t, cartRepo.CloseCart(c.ID))
+		}
+
+		rewards, err := cartRepo.ListReferralRewards(referrer.ID)
+		require.NoError(t, err)
+		require.Len(t, rewards, len(before)+1)
+		assert.Equal(t, carts[0].ID, rewards[0].CartID)
+	})
+
+	t.Run("returning customers are rejected", func(t *testing.T) {
+		customer, err := cartRepo.FindOrCreateUser("github", "2", "joe@example.com", "Joe")
+		require.NoError(t, err)
+		first, err := cartRepo.GetOrCreateCart("session-joe-1", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AssignCartToUser("session-joe-1", customer.ID))
+		require.NoError(t, cartRepo.CloseCart(first.ID))
+
+		second, err := cartRepo.GetOrCreateCart("session-joe-2", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AssignCartToUser("session-joe-2", customer.ID))
+		assert.ErrorIs(t, cartRepo.ApplyReferralCode(second.ID, ref.Code), repo.ErrNotNewCustomer)
+	})
+}
@@ -0,0 +1,319 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	cartpkg "interview/internal/cart"
+	"interview/internal/order"
+	"interview/internal/payment"
+	"interview/internal/pricing"
+	productpkg "interview/internal/product"
+	"interview/internal/warehouse"
+	"math"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// StartRefund records a pending refund of an order, to be paid back through the payment provider and
+// finished with FinishRefund. Items maps the IDs of the cart items refunded to how many units of them;
+// amount is what is paid back, by default the price of the items or, refunding no items, all that is left
+// to pay back along with all items left. It fails with order.ErrNotRefundable for orders that aren't
+// paid, with order.ErrRefundPending while another refund of the order is pending and with
+// order.ErrInvalidRefund for items and amounts the order doesn't have left to refund.
+func (r *Repository) StartRefund(orderID uint, items map[uint]int, amount float64, actor, note string) (*order.Refund, error) {
+	if amount < 0 {
+		return nil, order.ErrInvalidRefund
+	}
+	refund := order.Refund{OrderID: orderID, Status: order.RefundPending, Actor: actor, Note: note}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// Locking the order keeps two refunds of it from being started at once
+		var o order.Order
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&o, orderID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return order.ErrOrderNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to get order: %w", err)
+		}
+		if !order.Refundable(o.Status) {
+			return order.ErrNotRefundable
+		}
+		refund.TenantID = o.TenantID
+		var pending int64
+		err = tx.Model(&order.Refund{}).Where("order_id = ? AND status = ?", o.ID, order.RefundPending).Count(&pending).Error
+		if err != nil {
+			return fmt.Errorf("failed to check refunds: %w", err)
+		} else if pending > 0 {
+			return order.ErrRefundPending
+		}
+
+		left, err := refundableItems(tx, o)
+		if err != nil {
+			return err
+		}
+		var price float64
+		for _, item := range left {
+			quantity, ok := items[item.ID]
+			if len(items) == 0 && amount == 0 {
+				quantity, ok = item.Quantity, item.Quantity > 0
+			}
+			if !ok {
+				continue
+			}
+			if quantity < 1 || quantity > item.Quantity {
+				return order.ErrInvalidRefund
+			}
+			line := order.RefundLine{
+				CartItemID: item.ID, ProductName: item.ProductName, Quantity: quantity,
+				Amount: math.Round(item.Price*float64(quantity)*100) / 100,
+			}
+			refund.Lines = append(refund.Lines, line)
+			price += line.Amount
+		}
+		if len(refund.Lines) < len(items) {
+			return order.ErrInvalidRefund // items of other orders
+		}
+
+		paid, err := refundablePayments(tx, o.CartID)
+		if err != nil {
+			return err
+		}
+		switch {
+		case amount > 0:
+			refund.Amount = math.Round(amount*100) / 100
+		case len(items) > 0:
+			refund.Amount = math.Round(math.Min(price, paid)*100) / 100
+		default:
+			refund.Amount = paid
+		}
+		if refund.Amount > paid && pricing.Changed(refund.Amount, paid) {
+			return order.ErrInvalidRefund
+		}
+		if refund.Amount == 0 && len(refund.Lines) == 0 {
+			return order.ErrInvalidRefund // nothing left to refund
+		}
+		if err := tx.Create(&refund).Error; err != nil {
+			return fmt.Errorf("failed to create refund: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+// refundableItems returns the items of the order with the units not refunded yet as their quantity
+func refundableItems(tx *gorm.DB, o order.Order) ([]cartpkg.CartItem, error) {
+	var items []cartpkg.CartItem
+	if err := tx.Where("cart_id = ?", o.CartID).Order("id").Find(&items).Error; err != nil {
+		return nil, fmt.Errorf("failed to get items: %w", err)
+	}
+	var refunded []struct {
+		CartItemID uint
+		Quantity   int
+	}
+	err := tx.Model(&order.RefundLine{}).
+		Select("refund_lines.cart_item_id, SUM(refund_lines.quantity) AS quantity").
+		Joins("JOIN refunds ON refunds.id = refund_lines.refund_id AND refunds.deleted_at IS NULL").
+		Where("refunds.order_id = ?", o.ID).
+		Group("refund_lines.cart_item_id").
+		Scan(&refunded).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get refunded items: %w", err)
+	}
+	units := make(map[uint]int, len(refunded))
+	for _, line := range refunded {
+		units[line.CartItemID] = line.Quantity
+	}
+	for i := range items {
+		items[i].Quantity -= units[items[i].ID]
+	}
+	return items, nil
+}
+
+// refundablePayments returns how much of the captured payments of a cart wasn't paid back yet
+func refundablePayments(tx *gorm.DB, cartID uint) (float64, error) {
+	var left float64
+	err := tx.Model(&payment.Payment{}).
+		Where("cart_id = ? AND status = ?", cartID, payment.StatusCaptured).
+		Select("COALESCE(SUM(amount - refunded), 0)").
+		Scan(&left).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to sum payments: %w", err)
+	}
+	return math.Round(left*100) / 100, nil
+}
+
+// GetRefund returns a refund with its lines, or order.ErrRefundNotFound
+func (r *Repository) GetRefund(id uint) (*order.Refund, error) {
+	var refund order.Refund
+	err := r.db.Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).First(&refund, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, order.ErrRefundNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get refund: %w", err)
+	}
+	return &refund, nil
+}
+
+// ListRefunds returns the refunds of an order with their lines, oldest first
+func (r *Repository) ListRefunds(orderID uint) ([]order.Refund, error) {
+	var refunds []order.Refund
+	err := r.db.Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Where("order_id = ?", orderID).
+		Order("id").
+		Find(&refunds).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list refunds: %w", err)
+	}
+	return refunds, nil
+}
+
+// PayRefund records that the provider paid amount of a pending refund back from a payment, which is
+// refunded once all of it was paid back
+func (r *Repository) PayRefund(id, paymentID uint, amount float64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&order.Refund{}).Where("id = ? AND status = ?", id, order.RefundPending).
+			Update("paid", gorm.Expr("paid + ?", amount))
+		if result.Error != nil {
+			return fmt.Errorf("failed to update refund: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return order.ErrRefundNotFound
+		}
+		err := tx.Model(&payment.Payment{}).Where("id = ?", paymentID).
+			Update("refunded", gorm.Expr("refunded + ?", amount)).Error
+		if err != nil {
+			return fmt.Errorf("failed to update payment: %w", err)
+		}
+		var p payment.Payment
+		if err := tx.First(&p, paymentID).Error; err != nil {
+			return fmt.Errorf("failed to get payment: %w", err)
+		}
+		if pricing.Changed(p.Amount, p.Refunded) {
+			return nil
+		}
+		if err := tx.Model(&p).Update("status", payment.StatusRefunded).Error; err != nil {
+			return fmt.Errorf("failed to update payment: %w", err)
+		}
+		return nil
+	})
+}
+
+// FinishRefund completes a refund paid back in full, putting its items back into stock when restock is
+// set. Refunding all that is left of an order refunds the order, recording actor in its history. Refunds
+// finished before are returned as they are.
+func (r *Repository) FinishRefund(id uint, restock bool, actor string) (*order.Refund, error) {
+	var restocked []uint
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var refund order.Refund
+		err := tx.Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).First(&refund, id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return order.ErrRefundNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to get refund: %w", err)
+		}
+		if refund.Status != order.RefundPending {
+			return nil
+		}
+		if refund.Paid < refund.Amount && pricing.Changed(refund.Paid, refund.Amount) {
+			return fmt.Errorf("refund %d was paid back %.2f of %.2f", refund.ID, refund.Paid, refund.Amount)
+		}
+
+		result := tx.Model(&order.Refund{}).Where("id = ? AND status = ?", refund.ID, order.RefundPending).
+			Updates(map[string]interface{}{"status": order.RefundCompleted, "restocked": restock && len(refund.Lines) > 0})
+		if result.Error != nil {
+			return fmt.Errorf("failed to finish refund: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil // finished by another request meanwhile
+		}
+		if restock {
+			if restocked, err = restockRefund(tx, refund); err != nil {
+				return err
+			}
+		}
+		return refundOrderWhenDone(tx, refund, actor)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, productID := range restocked {
+		r.invalidateProduct(productID)
+	}
+	return r.GetRefund(id)
+}
+
+// restockRefund puts the items of a refund back into stock. The refund records that they went back when
+// it is completed, so FinishRefund restocks them once. Items allocated to warehouses go back to the
+// warehouses they shipped from. It returns the IDs of the products restocked.
+func restockRefund(tx *gorm.DB, refund order.Refund) ([]uint, error) {
+	var restocked []uint
+	for _, line := range refund.Lines {
+		var p productpkg.Product
+		if err := tx.Where("name = ?", line.ProductName).First(&p).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to get product: %w", err)
+		}
+		var allocations []warehouse.Allocation
+		if err := tx.Where("cart_item_id = ?", line.CartItemID).Order("id").Find(&allocations).Error; err != nil {
+			return nil, fmt.Errorf("failed to get allocations: %w", err)
+		}
+		if len(allocations) == 0 {
+			err := tx.Model(&productpkg.Product{}).Where("id = ? AND stock IS NOT NULL", p.ID).
+				Update("stock", gorm.Expr("stock + ?", line.Quantity)).Error
+			if err != nil {
+				return nil, fmt.Errorf("failed to update stock: %w", err)
+			}
+			restocked = append(restocked, p.ID)
+			continue
+		}
+		left := line.Quantity
+		for _, a := range allocations {
+			units := min(left, a.Quantity)
+			if units == 0 {
+				break
+			}
+			err := tx.Model(&warehouse.Stock{}).Where("warehouse_id = ? AND product_id = ?", a.WarehouseID, p.ID).
+				Update("quantity", gorm.Expr("quantity + ?", units)).Error
+			if err != nil {
+				return nil, fmt.Errorf("failed to update warehouse stock: %w", err)
+			}
+			left -= units
+		}
+		if _, err := syncProductStock(tx, p.ID); err != nil {
+			return nil, err
+		}
+		restocked = append(restocked, p.ID)
+	}
+	return restocked, nil
+}
+
+// refundOrderWhenDone refunds the order of a refund once none of its items and payments are left to
+// refund
+func refundOrderWhenDone(tx *gorm.DB, refund order.Refund, actor string) error {
+	var o order.Order
+	if err := tx.First(&o, refund.OrderID).Error; err != nil {
+		return fmt.Errorf("failed to get order: %w", err)
+	}
+	if !order.Refundable(o.Status) {
+		return nil
+	}
+	items, err := refundableItems(tx, o)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if item.Quantity > 0 {
+			return nil
+		}
+	}
+	if left, err := refundablePayments(tx, o.CartID); err != nil {
+		return err
+	} else if left > 0 {
+		return nil
+	}
+	return transitionOrder(tx, &o, order.StatusRefunded, actor, refund.Note)
+}
@@ -0,0 +1,180 @@
+package repo_test
+
+import (
+	cartpkg "interview/internal/cart"
+	"interview/internal/inventory"
+	"interview/internal/order"
+	"interview/internal/payment"
+	"interview/internal/repo"
+	"interview/internal/warehouse"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefunds(t *testing.T) {
+	db := setupTestDB(t)
+	cartRepo := repo.NewRepository(db)
+	shoe, err := cartRepo.UpsertProduct("shoe", 10)
+	require.NoError(t, err)
+	bag, err := cartRepo.UpsertProduct("bag", 30)
+	require.NoError(t, err)
+	berlin := warehouse.Warehouse{Name: "Berlin", Country: "DE"}
+	require.NoError(t, cartRepo.CreateWarehouse(&berlin))
+
+	stock := func(t *testing.T, id uint) int {
+		t.Helper()
+		p, err := cartRepo.GetProduct(id)
+		require.NoError(t, err)
+		require.NotNil(t, p.Stock)
+		return *p.Stock
+	}
+	// paidOrder checks out two shoes from the stock and a bag from the warehouse, paid with a payment of 50
+	paidOrder := func(t *testing.T, sessionID string) (*order.Order, []cartpkg.CartItem, payment.Payment) {
+		t.Helper()
+		five := 5
+		require.NoError(t, cartRepo.SetProductStock(shoe.ID, &five))
+		_, err := cartRepo.SetWarehouseStock(berlin.ID, bag.ID, 3)
+		require.NoError(t, err)
+		c, err := cartRepo.GetOrCreateCart(sessionID, cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 2, 10))
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "bag", 1, 30))
+		p := payment.Payment{
+			CartID: c.ID, Provider: "paypal", ExternalID: "ORDER-" + sessionID, Amount: 50, Status: payment.StatusPending,
+		}
+		require.NoError(t, cartRepo.CreatePayment(&p))
+		_, _, err = cartRepo.CompletePayment("paypal", p.ExternalID)
+		require.NoError(t, err)
+
+		o, err := cartRepo.GetOrderByCart(c.ID)
+		require.NoError(t, err)
+		var items []cartpkg.CartItem
+		require.NoError(t, db.Where("cart_id = ?", c.ID).Order("id").Find(&items).Error)
+		return o, items, p
+	}
+	// pay pays a refund back in full from the payment and finishes it
+	pay := func(t *testing.T, refund *order.Refund, p payment.Payment, restock bool) *order.Refund {
+		t.Helper()
+		require.NoError(t, cartRepo.PayRefund(refund.ID, p.ID, refund.Amount))
+		finished, err := cartRepo.FinishRefund(refund.ID, restock, "admin")
+		require.NoError(t, err)
+		return finished
+	}
+
+	t.Run("items are refunded at their price and restocked once", func(t *testing.T) {
+		o, items, p := paidOrder(t, "refund-session-1")
+		require.Equal(t, 3, stock(t, shoe.ID))
+
+		refund, err := cartRepo.StartRefund(o.ID, map[uint]int{items[0].ID: 1}, 0, "admin", "damaged")
+		require.NoError(t, err)
+		assert.Equal(t, order.RefundPending, refund.Status)
+		assert.Equal(t, 10.0, refund.Amount)
+		require.Len(t, refund.Lines, 1)
+		assert.Equal(t, order.RefundLine{ID: refund.Lines[0].ID, RefundID: refund.ID, CartItemID: items[0].ID, ProductName: "shoe", Quantity: 1, Amount: 10}, refund.Lines[0])
+		_, err = cartRepo.StartRefund(o.ID, map[uint]int{items[1].ID: 1}, 0, "admin", "")
+		assert.ErrorIs(t, err, order.ErrRefundPending)
+
+		finished := pay(t, refund, p, true)
+		assert.Equal(t, order.RefundCompleted, finished.Status)
+		assert.Equal(t, 10.0, finished.Paid)
+		assert.True(t, finished.Restocked)
+		assert.Equal(t, 4, stock(t, shoe.ID))
+		_, err = cartRepo.FinishRefund(refund.ID, true, "admin")
+		require.NoError(t, err)
+		assert.Equal(t, 4, stock(t, shoe.ID), "finishing a refund twice restocks once")
+		var events int64
+		require.NoError(t, db.Model(&inventory.Event{}).Count(&events).Error)
+		assert.Zero(t, events, "restocks leave the batches of the ERP alone")
+
+		require.NoError(t, db.First(&p, p.ID).Error)
+		assert.Equal(t, 10.0, p.Refunded)
+		assert.Equal(t, payment.StatusCaptured, p.Status)
+		unchanged, err := cartRepo.GetOrder(o.ID)
+		require.NoError(t, err)
+		assert.Equal(t, order.StatusPaid, unchanged.Status)
+	})
+
+	t.Run("refunds are limited to what is left", func(t *testing.T) {
+		o, items, p := paidOrder(t, "refund-session-2")
+		refund, err := cartRepo.StartRefund(o.ID, map[uint]int{items[0].ID: 1}, 0, "admin", "")
+		require.NoError(t, err)
+		pay(t, refund, p, false)
+
+		tests := []struct {
+			name   string
+			items  map[uint]int
+			amount float64
+		}{
+			{"more units than left", map[uint]int{items[0].ID: 2}, 0},
+			{"no units", map[uint]int{items[0].ID: 0}, 0},
+			{"items of other orders", map[uint]int{items[1].ID + 100: 1}, 0},
+			{"more than was paid", nil, 40.01},
+			{"negative amount", nil, -1},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := cartRepo.StartRefund(o.ID, tt.items, tt.amount, "admin", "")
+				assert.ErrorIs(t, err, order.ErrInvalidRefund)
+			})
+		}
+
+		partial, err := cartRepo.StartRefund(o.ID, nil, 5, "admin", "late delivery")
+		require.NoError(t, err)
+		assert.Empty(t, partial.Lines)
+		assert.Equal(t, 5.0, partial.Amount)
+	})
+
+	t.Run("refunding all that is left refunds the order", func(t *testing.T) {
+		o, items, p := paidOrder(t, "refund-session-3")
+		refund, err := cartRepo.StartRefund(o.ID, map[uint]int{items[0].ID: 1}, 0, "admin", "")
+		require.NoError(t, err)
+		pay(t, refund, p, true)
+		require.Equal(t, 2, stock(t, bag.ID))
+
+		rest, err := cartRepo.StartRefund(o.ID, nil, 0, "admin", "returned")
+		require.NoError(t, err)
+		assert.Equal(t, 40.0, rest.Amount)
+		require.Len(t, rest.Lines, 2)
+		assert.Equal(t, 1, rest.Lines[0].Quantity)
+		assert.Equal(t, "bag", rest.Lines[1].ProductName)
+		pay(t, rest, p, true)
+
+		assert.Equal(t, 3, stock(t, bag.ID), "the bag goes back to the warehouse it shipped from")
+		refunded, err := cartRepo.GetOrder(o.ID)
+		require.NoError(t, err)
+		assert.Equal(t, order.StatusRefunded, refunded.Status)
+		assert.Equal(t, "returned", refunded.History[len(refunded.History)-1].Note)
+		require.NoError(t, db.First(&p, p.ID).Error)
+		assert.Equal(t, payment.StatusRefunded, p.Status)
+
+		_, err = cartRepo.StartRefund(o.ID, nil, 0, "admin", "")
+		assert.ErrorIs(t, err, order.ErrNotRefundable)
+		refunds, err := cartRepo.ListRefunds(o.ID)
+		require.NoError(t, err)
+		assert.Len(t, refunds, 2)
+	})
+
+	t.Run("items are only restocked when asked to", func(t *testing.T) {
+		o, items, p := paidOrder(t, "refund-session-4")
+		refund, err := cartRepo.StartRefund(o.ID, map[uint]int{items[0].ID: 2}, 0, "admin", "")
+		require.NoError(t, err)
+		finished := pay(t, refund, p, false)
+		assert.False(t, finished.Restocked)
+		assert.Equal(t, 3, stock(t, shoe.ID))
+	})
+
+	t.Run("unpaid orders are not refunded", func(t *testing.T) {
+		c, err := cartRepo.GetOrCreateCart("refund-session-5", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 1, 10))
+		require.NoError(t, cartRepo.CloseCart(c.ID))
+		o, err := cartRepo.GetOrderByCart(c.ID)
+		require.NoError(t, err)
+		_, err = cartRepo.StartRefund(o.ID, nil, 0, "admin", "")
+		assert.ErrorIs(t, err, order.ErrNotRefundable)
+		_, err = cartRepo.StartRefund(o.ID+100, nil, 0, "admin", "")
+		assert.ErrorIs(t, err, order.ErrOrderNotFound)
+	})
+}
@@ -0,0 +1,83 @@
+package repo
+
+import (
+	"fmt"
+	cartpkg "interview/internal/cart"
+	userpkg "interview/internal/user"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// AbandonedCart is an open cart of an identified user that hasn't changed for a while
+type AbandonedCart struct {
+	Cart cartpkg.Cart
+	User userpkg.User
+}
+
+// ListAbandonedCarts returns up to limit open carts with items that haven't changed since idleSince,
+// belong to users with an email address and haven't been reminded of in their current state.
+func (r *Repository) ListAbandonedCarts(idleSince time.Time, limit int) ([]AbandonedCart, error) {
+	var carts []cartpkg.Cart
+	err := r.db.Preload("CartItems", orderItems).
+		Joins("JOIN users ON users.id = carts.user_id AND users.deleted_at IS NULL").
+		Where("carts.status = ? AND carts.updated_at < ? AND users.email <> ''", cartpkg.StatusOpen, idleSince).
+		Where("EXISTS (SELECT 1 FROM cart_items WHERE cart_items.cart_id = carts.id AND cart_items.deleted_at IS NULL)").
+		Where("NOT EXISTS (SELECT 1 FROM cart_reminders WHERE cart_reminders.cart_id = carts.id AND cart_reminders.cart_version = carts.version)").
+		Order("carts.id").
+		Limit(limit).
+		Find(&carts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list abandoned carts: %w", err)
+	}
+	if len(carts) == 0 {
+		return nil, nil
+	}
+
+	userIDs := make([]uint, 0, len(carts))
+	for _, c := range carts {
+		userIDs = append(userIDs, *c.UserID)
+	}
+	var users []userpkg.User
+	if err := r.db.Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to load users: %w", err)
+	}
+	byID := make(map[uint]userpkg.User, len(users))
+	for _, u := range users {
+		byID[u.ID] = u
+	}
+
+	abandoned := make([]AbandonedCart, 0, len(carts))
+	for _, c := range carts {
+		abandoned = append(abandoned, AbandonedCart{Cart: c, User: byID[*c.UserID]})
+	}
+	return abandoned, nil
+}
+
+// ClaimReminder records that a reminder is being sent for the cart in its current version. It returns
+// false when a reminder was already recorded, e.g. by another instance running the same job.
+func (r *Repository) ClaimReminder(c cartpkg.Cart, email string) (*cartpkg.Reminder, bool, error) {
+	reminder := cartpkg.Reminder{CartID: c.ID, CartVersion: c.Version, Email: email}
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&reminder)
+	if result.Error != nil {
+		return nil, false, fmt.Errorf("failed to record reminder: %w", result.Error)
+	}
+	return &reminder, result.RowsAffected > 0, nil
+}
+
+// ReleaseReminder forgets a claimed reminder that couldn't be sent, so the next run retries it
+func (r *Repository) ReleaseReminder(reminder *cartpkg.Reminder) error {
+	if err := r.db.Unscoped().Delete(reminder).Error; err != nil {
+		return fmt.Errorf("failed to release reminder: %w", err)
+	}
+	return nil
+}
+
+// ListReminders returns the reminders sent for a cart, oldest first
+func (r *Repository) ListReminders(cartID uint) ([]cartpkg.Reminder, error) {
+	var reminders []cartpkg.Reminder
+	if err := r.db.Where("cart_id = ?", cartID).Order("id").Find(&reminders).Error; err != nil {
+		return nil, fmt.Errorf("failed to list reminders: %w", err)
+	}
+	return reminders, nil
+}
@@ -0,0 +1,87 @@
+package repo_test
+
+import (
+	cartpkg "interview/internal/cart"
+	"interview/internal/repo"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListAbandonedCarts(t *testing.T) {
+	db := setupTestDB(t)
+	cartRepo := repo.NewRepository(db)
+	idleSince := time.Now().Add(-time.Hour)
+
+	user, err := cartRepo.FindOrCreateUser("github", "1", "jane@example.com", "Jane")
+	require.NoError(t, err)
+	anonymous, err := cartRepo.FindOrCreateUser("github", "2", "", "No Email")
+	require.NoError(t, err)
+
+	newCart := func(sessionID string, userID uint, withItems bool, idle bool) *cartpkg.Cart {
+		c, err := cartRepo.GetOrCreateCart(sessionID, cartpkg.DefaultName)
+		require.NoError(t, err)
+		if withItems {
+			require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 1, 10.0))
+		}
+		if userID != 0 {
+			require.NoError(t, cartRepo.AssignCartToUser(sessionID, userID))
+		}
+		if idle {
+			require.NoError(t, db.Model(&cartpkg.Cart{}).Where("id = ?", c.ID).
+				UpdateColumn("updated_at", time.Now().Add(-2*time.Hour)).Error)
+		}
+		c, err = cartRepo.GetCart(c.ID)
+		require.NoError(t, err)
+		return c
+	}
+
+	abandoned := newCart("abandoned", user.ID, true, true)
+	newCart("recent", user.ID, true, false)
+	newCart("empty", user.ID, false, true)
+	newCart("no-user", 0, true, true)
+	newCart("no-email", anonymous.ID, true, true)
+
+	t.Run("Finds Idle Carts Of Users With Email", func(t *testing.T) {
+		carts, err := cartRepo.ListAbandonedCarts(idleSince, 10)
+		require.NoError(t, err)
+		require.Len(t, carts, 1)
+		assert.Equal(t, abandoned.ID, carts[0].Cart.ID)
+		assert.Equal(t, "jane@example.com", carts[0].User.Email)
+		assert.Len(t, carts[0].Cart.CartItems, 1)
+	})
+
+	t.Run("Claims Each Cart Version Once", func(t *testing.T) {
+		reminder, claimed, err := cartRepo.ClaimReminder(*abandoned, "jane@example.com")
+		require.NoError(t, err)
+		assert.True(t, claimed)
+
+		_, claimed, err = cartRepo.ClaimReminder(*abandoned, "jane@example.com")
+		require.NoError(t, err)
+		assert.False(t, claimed)
+
+		carts, err := cartRepo.ListAbandonedCarts(idleSince, 10)
+		require.NoError(t, err)
+		assert.Empty(t, carts)
+
+		reminders, err := cartRepo.ListReminders(abandoned.ID)
+		require.NoError(t, err)
+		require.Len(t, reminders, 1)
+		assert.Equal(t, reminder.ID, reminders[0].ID)
+	})
+
+	t.Run("Released Reminders Are Retried", func(t *testing.T) {
+		other := newCart("abandoned-2", user.ID, true, true)
+		reminder, claimed, err := cartRepo.ClaimReminder(*other, "jane@example.com")
+		require.NoError(t, err)
+		require.True(t, claimed)
+		require.NoError(t, cartRepo.ReleaseReminder(reminder))
+
+		carts, err := cartRepo.ListAbandonedCarts(idleSince, 10)
+		require.NoError(t, err)
+		require.Len(t, carts, 1)
+		assert.Equal(t, other.ID, carts[0].Cart.ID)
+	})
+}
@@ -0,0 +1,141 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// replicaPingTimeout bounds a single health check of a replica
+const replicaPingTimeout = 2 * time.Second
+
+type (
+	// ReplicaSet routes read queries to read replicas that passed their last health check, falling
+	// back to the primary when none did.
+	ReplicaSet struct {
+		primary  *gorm.DB
+		replicas []*replica
+		next     atomic.Uint32
+	}
+
+	replica struct {
+		db    *gorm.DB
+		ready atomic.Bool
+	}
+
+	readPrimaryKey struct{}
+)
+
+// ReadPrimary returns a context whose queries skip the read replicas, for requests that must see
+// changes made just before them that may not have reached the replicas yet
+func ReadPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readPrimaryKey{}, true)
+}
+
+// readsPrimary reports whether the queries of the context must run on the primary
+func readsPrimary(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	pinned, _ := ctx.Value(readPrimaryKey{}).(bool)
+	return pinned
+}
+
+// NewReplicaSet creates a ReplicaSet over the given replicas. Replicas are considered ready until
+// the first health check says otherwise.
+func NewReplicaSet(primary *gorm.DB, replicas ...*gorm.DB) *ReplicaSet {
+	s := &ReplicaSet{primary: primary}
+	for _, db := range replicas {
+		r := &replica{db: db}
+		r.ready.Store(true)
+		s.replicas = append(s.replicas, r)
+	}
+	return s
+}
+
+// ConnectReplicas opens the read replicas listed in DB_REPLICA_DSNS with the pool settings of the
+// primary. Replicas are only tried once: one that is down at startup is marked as not ready and picked
+// up by Monitor when it comes back.
+func ConnectReplicas(primary *gorm.DB, dsns string, pool PoolOptions) (*ReplicaSet, error) {
+	var replicas []*gorm.DB
+	for _, dsn := range strings.Split(dsns, ",") {
+		dsn = strings.TrimSpace(dsn)
+		if dsn == "" {
+			continue
+		}
+		// Skip the initial ping so an unreachable replica doesn't prevent startup
+		db, err := gorm.Open(mysql.New(mysql.Config{DSN: dsn}), &gorm.Config{DisableAutomaticPing: true, Logger: primary.Logger})
+		if err != nil {
+			return nil, fmt.Errorf("failed to open replica: %w", err)
+		}
+		if err := ConfigurePool(db, pool); err != nil {
+			return nil, err
+		}
+		if err := db.Use(TenantScope{}); err != nil {
+			return nil, fmt.Errorf("failed to set up tenant scoping of replica: %w", err)
+		}
+		replicas = append(replicas, db)
+	}
+
+	s := NewReplicaSet(primary, replicas...)
+	s.CheckHealth(context.Background())
+	return s, nil
+}
+
+// Reader returns the database read queries should run on: the next ready replica in round-robin
+// order, or the primary when no replica is ready.
+func (s *ReplicaSet) Reader() *gorm.DB {
+	n := len(s.replicas)
+	start := int(s.next.Add(1))
+	for i := 0; i < n; i++ {
+		r := s.replicas[(start+i)%n]
+		if r.ready.Load() {
+			return r.db
+		}
+	}
+	return s.primary
+}
+
+// CheckHealth pings every replica and records whether it is ready to serve reads
+func (s *ReplicaSet) CheckHealth(ctx context.Context) {
+	for i, r := range s.replicas {
+		err := ping(ctx, r.db)
+		wasReady := r.ready.Swap(err == nil)
+		switch {
+		case err != nil && wasReady:
+			log.Printf("Read replica %d is down, reading from the primary: %v", i, err)
+		case err == nil && !wasReady:
+			log.Printf("Read replica %d is back", i)
+		}
+	}
+}
+
+// Monitor checks the health of the replicas every interval until ctx is done
+func (s *ReplicaSet) Monitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.CheckHealth(ctx)
+		}
+	}
+}
+
+func ping(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, replicaPingTimeout)
+	defer cancel()
+	return sqlDB.PingContext(ctx)
+}
@@ -0,0 +1,94 @@
+package repo_test
+
+import (
+	"context"
+	cartpkg "interview/internal/cart"
+	"interview/internal/repo"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestReplicaSet(t *testing.T) {
+	t.Run("reads from ready replicas in turn", func(t *testing.T) {
+		primary, first, second := setupTestDB(t), setupTestDB(t), setupTestDB(t)
+		set := repo.NewReplicaSet(primary, first, second)
+
+		reads := map[*gorm.DB]int{}
+		for i := 0; i < 4; i++ {
+			reads[set.Reader()]++
+		}
+		assert.Equal(t, map[*gorm.DB]int{first: 2, second: 2}, reads)
+	})
+
+	t.Run("falls back to the primary when replicas are down", func(t *testing.T) {
+		primary, replica := setupTestDB(t), setupTestDB(t)
+		set := repo.NewReplicaSet(primary, replica)
+
+		sqlDB, err := replica.DB()
+		require.NoError(t, err)
+		require.NoError(t, sqlDB.Close())
+		set.CheckHealth(context.Background())
+
+		assert.Same(t, primary, set.Reader())
+	})
+
+	t.Run("works without replicas", func(t *testing.T) {
+		primary := setupTestDB(t)
+		assert.Same(t, primary, repo.NewReplicaSet(primary).Reader())
+	})
+}
+
+func TestRepositoryReplicas(t *testing.T) {
+	primary, replica := setupTestDB(t), setupTestDB(t)
+	r := repo.NewRepository(primary)
+	r.SetReplicas(repo.NewReplicaSet(primary, replica))
+
+	t.Run("serves listings from the replica", func(t *testing.T) {
+		require.NoError(t, replica.Create(&cartpkg.Cart{SessionID: "replicated", Status: cartpkg.StatusOpen, Total: 30}).Error)
+
+		carts, err := r.GetAllCarts()
+		require.NoError(t, err)
+		require.Len(t, carts, 1)
+		assert.Equal(t, "replicated", carts[0].SessionID)
+
+		count, value, err := r.OpenCartStats()
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+		assert.Equal(t, 30.0, value)
+	})
+
+	t.Run("finds carts that haven't reached the replica yet", func(t *testing.T) {
+		created, err := r.GetOrCreateCart("lagging", cartpkg.DefaultName)
+		require.NoError(t, err)
+
+		found, err := r.GetOrCreateCart("lagging", cartpkg.DefaultName)
+		require.NoError(t, err)
+		assert.Equal(t, created.ID, found.ID)
+
+		var count int64
+		require.NoError(t, primary.Model(&cartpkg.Cart{}).Where("session_id = ?", "lagging").Count(&count).Error)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("pinned reads go to the primary", func(t *testing.T) {
+		carts, err := r.WithContext(repo.ReadPrimary(context.Background())).GetAllCarts()
+		require.NoError(t, err)
+		require.Len(t, carts, 1)
+		assert.Equal(t, "lagging", carts[0].SessionID)
+	})
+
+	t.Run("writes go to the primary", func(t *testing.T) {
+		_, err := r.UpsertProduct("shoe", 10)
+		require.NoError(t, err)
+
+		products, err := repo.NewRepository(primary).ListProducts()
+		require.NoError(t, err)
+		assert.Len(t, products, 1)
+		products, err = repo.NewRepository(replica).ListProducts()
+		require.NoError(t, err)
+		assert.Empty(t, products)
+	})
+}
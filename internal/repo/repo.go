@@ -1,58 +1,348 @@
 package repo
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"interview/internal/address"
+	"interview/internal/analytics"
+	"interview/internal/audit"
+	"interview/internal/cache"
 	cartpkg "interview/internal/cart"
+	"interview/internal/checkout"
 	"interview/internal/config"
+	"interview/internal/experiment"
+	"interview/internal/giftcard"
+	"interview/internal/inventory"
+	"interview/internal/jobs"
+	"interview/internal/notification"
+	"interview/internal/order"
+	"interview/internal/payment"
+	"interview/internal/pricelist"
+	"interview/internal/pricing"
+	productpkg "interview/internal/product"
+	"interview/internal/promotion"
+	"interview/internal/recommend"
+	"interview/internal/referral"
+	userpkg "interview/internal/user"
+	"interview/internal/vat"
+	"interview/internal/warehouse"
+	"interview/internal/webhook"
+	"log"
+	"log/slog"
+	"time"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 )
 
+// ErrConflict is returned when a cart was modified by another request while being updated
+var ErrConflict = errors.New("cart was modified concurrently")
+
 type Repository struct {
-	db *gorm.DB
+	db           *gorm.DB
+	productIndex ProductIndex
+	replicas     *ReplicaSet
+	// referralReward is the credit granted to referrers for each referred cart, 0 grants none
+	referralReward float64
+	// charges are the tax and shipping added to cart totals
+	charges cartpkg.Charges
+	// downloadLimit and downloadTTL limit the downloads granted for digital products at checkout,
+	// 0 doesn't limit them
+	downloadLimit int
+	downloadTTL   time.Duration
+	// subscriptionDiscount is the discount in percent on items bought with subscribe & save
+	subscriptionDiscount float64
+	// checkoutTTL is how long checkout sessions lock their cart after the last step
+	checkoutTTL time.Duration
+	// allocation picks the warehouses the items of checked out carts ship from
+	allocation warehouse.Strategy
+	// products caches storefront product lookups, nil when no cache is set
+	products *cache.Cache
+	// prices overrides the base prices of the products it knows, nil when they come from the catalog
+	prices pricing.Provider
 }
 
+// defaultCheckoutTTL is how long checkout sessions lock their cart unless SetCheckoutTTL changes it
+const defaultCheckoutTTL = 30 * time.Minute
+
 func NewRepository(db *gorm.DB) *Repository {
-	return &Repository{db: db}
+	return &Repository{db: db, checkoutTTL: defaultCheckoutTTL, allocation: warehouse.Nearest{}}
+}
+
+// SetReplicas makes read-heavy queries that tolerate replication lag run on the read replicas
+func (r *Repository) SetReplicas(replicas *ReplicaSet) {
+	r.replicas = replicas
+}
+
+// SetPriceProvider makes products sell at the base price of the provider where it knows them instead of
+// their price in the catalog, see CatalogPrice
+func (r *Repository) SetPriceProvider(provider pricing.Provider) {
+	r.prices = provider
+}
+
+// SetCharges sets the tax and shipping added to cart totals when carts change
+func (r *Repository) SetCharges(charges cartpkg.Charges) {
+	r.charges = charges
+}
+
+// Transaction runs fn with a Repository whose queries all belong to one database transaction, which
+// is committed when fn returns nil and rolled back otherwise. Transactions started by the methods of
+// the transactional Repository become savepoints.
+func (r *Repository) Transaction(fn func(tx *Repository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// Queries of the transaction all run on the primary
+		copied := *r
+		copied.db = tx
+		copied.replicas = nil
+		return fn(&copied)
+	})
+}
+
+// WithContext returns a Repository running its queries with ctx, so they are cancelled when ctx is done
+func (r *Repository) WithContext(ctx context.Context) *Repository {
+	copied := *r
+	copied.db = r.db.WithContext(ctx)
+	return &copied
+}
+
+// reader returns the database for queries that may be served by a read replica, the primary for
+// contexts made by ReadPrimary
+func (r *Repository) reader() *gorm.DB {
+	if r.replicas == nil || readsPrimary(r.db.Statement.Context) {
+		return r.db
+	}
+	return r.replicas.Reader().WithContext(r.db.Statement.Context)
 }
 
 // InitDatabase initializes the MySQL database connection and performs auto-migration
 func InitDatabase(config config.Config) (*gorm.DB, error) {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+	db, err := Connect(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := Migrate(db); err != nil {
+		return nil, fmt.Errorf("database migration failed: %w", err)
+	}
+
+	return db, nil
+}
+
+// Connect opens the MySQL database connection without migrating the schema
+func Connect(config config.Config) (*gorm.DB, error) {
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
 		config.DBUser,
 		config.DBPassword,
 		config.DBHost,
 		config.DBPort,
 		config.DBName)
 
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	db, err := OpenWithRetry(mysql.Open(dsn), config.DBConnectAttempts, time.Second)
 	if err != nil {
 		return nil, fmt.Errorf("database connection failed: %w", err)
 	}
 
-	if err := db.AutoMigrate(&cartpkg.Cart{}, &cartpkg.CartItem{}); err != nil {
-		return nil, fmt.Errorf("database migration failed: %w", err)
+	if err := ConfigurePool(db, poolOptions(config)); err != nil {
+		return nil, err
+	}
+	// Replaces the default logger printing queries with their parameters to stdout
+	db.Logger = NewQueryLogger(slog.Default(), config.DBSlowQueryThreshold)
+	if err := db.Use(TenantScope{}); err != nil {
+		return nil, fmt.Errorf("failed to set up tenant scoping: %w", err)
 	}
 
 	return db, nil
 }
 
-func (r *Repository) GetOrCreateCart(sessionID string) (*cartpkg.Cart, error) {
+// ConnectReplicaSet opens the read replicas configured in DB_REPLICA_DSNS alongside the primary
+func ConnectReplicaSet(config config.Config, primary *gorm.DB) (*ReplicaSet, error) {
+	return ConnectReplicas(primary, config.DBReplicaDSNs, poolOptions(config))
+}
+
+// models lists all models managed by the repository, parents before the tables referencing them
+func models() []interface{} {
+	return []interface{}{
+		&userpkg.User{},
+		&userpkg.RecoveryCode{},
+		&userpkg.PasswordResetToken{},
+		&userpkg.Device{},
+		&address.Address{},
+		&referral.Referral{},
+		&productpkg.Product{},
+		&productpkg.StockSubscription{},
+		&productpkg.PriceTier{},
+		&productpkg.BundleComponent{},
+		&recommend.Association{},
+		&promotion.Promotion{},
+		&pricelist.PriceList{},
+		&pricelist.Price{},
+		&pricelist.PriceOverride{},
+		&cartpkg.Cart{},
+		&cartpkg.CartItem{},
+		&cartpkg.CartDiscount{},
+		&cartpkg.Change{},
+		&cartpkg.Reminder{},
+		&cartpkg.Subscription{},
+		&productpkg.Download{},
+		&payment.Payment{},
+		&checkout.Session{},
+		&vat.Evidence{},
+		&order.Order{},
+		&order.History{},
+		&order.Refund{},
+		&order.RefundLine{},
+		&warehouse.Warehouse{},
+		&warehouse.Stock{},
+		&warehouse.Allocation{},
+		&giftcard.GiftCard{},
+		&giftcard.Redemption{},
+		&referral.Reward{},
+		&webhook.Endpoint{},
+		&webhook.Delivery{},
+		&inventory.Event{},
+		&jobs.Job{},
+		&jobs.Lock{},
+		&experiment.Conversion{},
+		&analytics.Event{},
+		&audit.Entry{},
+		&notification.Notification{},
+	}
+}
+
+// Migrate creates or updates the tables of all models managed by the repository
+func Migrate(db *gorm.DB) error {
+	hadSubtotal := db.Migrator().HasColumn(&cartpkg.Cart{}, "Subtotal")
+	hadIsOpen := db.Migrator().HasColumn(&cartpkg.Cart{}, "IsOpen")
+	hadOrderTenants := db.Migrator().HasColumn(&order.Order{}, "TenantID")
+	if err := db.AutoMigrate(models()...); err != nil {
+		return err
+	}
+	// Carts only stored their total before it was broken down; there was no tax or shipping yet
+	if !hadSubtotal {
+		err := db.Exec(`UPDATE carts SET
+			subtotal = (SELECT COALESCE(SUM(price * quantity), 0) FROM cart_items WHERE cart_items.cart_id = carts.id AND cart_items.deleted_at IS NULL),
+			discount_total = (SELECT COALESCE(SUM(amount), 0) FROM cart_discounts WHERE cart_discounts.cart_id = carts.id AND cart_discounts.deleted_at IS NULL)`).Error
+		if err != nil {
+			return fmt.Errorf("failed to fill in the subtotals of carts: %w", err)
+		}
+	}
+	// Carts were unique per session before sessions could have several named carts
+	if db.Migrator().HasIndex(&cartpkg.Cart{}, "idx_carts_session_id") {
+		if err := db.Migrator().DropIndex(&cartpkg.Cart{}, "idx_carts_session_id"); err != nil {
+			return fmt.Errorf("failed to drop the session index of carts: %w", err)
+		}
+	}
+	// Carts were unique per session and name, open or closed, before sessions could reopen a cart of
+	// the name of a closed one
+	if db.Migrator().HasIndex(&cartpkg.Cart{}, "idx_cart_session_name") {
+		if err := db.Migrator().DropIndex(&cartpkg.Cart{}, "idx_cart_session_name"); err != nil {
+			return fmt.Errorf("failed to drop the session and name index of carts: %w", err)
+		}
+	}
+	if !hadIsOpen {
+		if err := markOpenCarts(db); err != nil {
+			return err
+		}
+	}
+	// Product names were unique across the deployment before it could serve several shops
+	if db.Migrator().HasIndex(&productpkg.Product{}, "idx_products_name") {
+		if err := db.Migrator().DropIndex(&productpkg.Product{}, "idx_products_name"); err != nil {
+			return fmt.Errorf("failed to drop the name index of products: %w", err)
+		}
+	}
+	// Price lists were unique per customer group across the deployment before they belonged to a shop
+	if db.Migrator().HasIndex(&pricelist.PriceList{}, "idx_price_lists_customer_group") {
+		if err := db.Migrator().DropIndex(&pricelist.PriceList{}, "idx_price_lists_customer_group"); err != nil {
+			return fmt.Errorf("failed to drop the customer group index of price lists: %w", err)
+		}
+	}
+	// The order list pages through the orders of a status by ID, newest first
+	if !db.Migrator().HasIndex(&order.Order{}, "idx_order_status_id") {
+		if err := db.Exec("CREATE INDEX idx_order_status_id ON orders (status, id)").Error; err != nil {
+			return fmt.Errorf("failed to index orders by status: %w", err)
+		}
+	}
+	// Orders, their refunds and payments belong to the shop of their cart
+	if !hadOrderTenants {
+		for _, stmt := range []string{
+			"UPDATE orders SET tenant_id = (SELECT tenant_id FROM carts WHERE carts.id = orders.cart_id) WHERE EXISTS (SELECT 1 FROM carts WHERE carts.id = orders.cart_id)",
+			"UPDATE payments SET tenant_id = (SELECT tenant_id FROM carts WHERE carts.id = payments.cart_id) WHERE EXISTS (SELECT 1 FROM carts WHERE carts.id = payments.cart_id)",
+			"UPDATE refunds SET tenant_id = (SELECT tenant_id FROM orders WHERE orders.id = refunds.order_id) WHERE EXISTS (SELECT 1 FROM orders WHERE orders.id = refunds.order_id)",
+		} {
+			if err := db.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("failed to assign orders to shops: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// markOpenCarts sets IsOpen on the open carts of existing databases. Sessions with several open carts
+// of the same name keep the most recent one; the others are soft-deleted with their items, so nothing
+// is lost but the session sees a single cart.
+func markOpenCarts(db *gorm.DB) error {
+	var duplicates []uint
+	err := db.Raw(`SELECT DISTINCT older.id FROM carts older
+		JOIN carts newer ON newer.session_id = older.session_id AND newer.name = older.name AND newer.id > older.id
+			AND newer.status = ? AND newer.deleted_at IS NULL
+		WHERE older.status = ? AND older.deleted_at IS NULL`, cartpkg.StatusOpen, cartpkg.StatusOpen).
+		Scan(&duplicates).Error
+	if err != nil {
+		return fmt.Errorf("failed to find duplicate open carts: %w", err)
+	}
+	if len(duplicates) > 0 {
+		if err := db.Exec("UPDATE carts SET deleted_at = ? WHERE id IN ?", time.Now(), duplicates).Error; err != nil {
+			return fmt.Errorf("failed to delete duplicate open carts: %w", err)
+		}
+		log.Printf("Deleted %d duplicate open carts, keeping the most recent of each session and name", len(duplicates))
+	}
+
+	err = db.Exec("UPDATE carts SET is_open = ? WHERE status = ? AND deleted_at IS NULL", true, cartpkg.StatusOpen).Error
+	if err != nil {
+		return fmt.Errorf("failed to mark open carts: %w", err)
+	}
+	return nil
+}
+
+// MigrateDown drops the tables of all models managed by the repository
+func MigrateDown(db *gorm.DB) error {
+	all := models()
+	for i := len(all) - 1; i >= 0; i-- {
+		if err := db.Migrator().DropTable(all[i]); err != nil {
+			return fmt.Errorf("failed to drop table: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetOrCreateCart returns the open cart of the session with the given name, creating it if needed
+func (r *Repository) GetOrCreateCart(sessionID, name string) (*cartpkg.Cart, error) {
 	var userCart cartpkg.Cart
 
 	// Only consider open carts
-	err := r.db.Preload("CartItems").
-		Where("session_id = ? AND status = ?", sessionID, cartpkg.StatusOpen).
-		First(&userCart).Error
+	findOpenCart := func(db *gorm.DB) error {
+		return db.Preload("CartItems", orderItems).Preload("Discounts").
+			Where("session_id = ? AND name = ? AND status = ?", sessionID, name, cartpkg.StatusOpen).
+			First(&userCart).Error
+	}
+	err := findOpenCart(r.reader())
+	if errors.Is(err, gorm.ErrRecordNotFound) && r.replicas != nil {
+		// The cart may have been created too recently to have reached the replica
+		err = findOpenCart(r.db)
+	}
 
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		userCart = cartpkg.Cart{
 			SessionID: sessionID,
+			Name:      name,
 			Status:    cartpkg.StatusOpen,
 		}
 		if err := r.db.Create(&userCart).Error; err != nil {
+			// A concurrent request of the session may have created the cart first
+			if findOpenCart(r.db) == nil {
+				return &userCart, nil
+			}
 			return nil, fmt.Errorf("failed to create new cart: %w", err)
 		}
 	} else if err != nil {
@@ -62,32 +352,41 @@ func (r *Repository) GetOrCreateCart(sessionID string) (*cartpkg.Cart, error) {
 	return &userCart, nil
 }
 
+// AddCartItem adds quantity units of the product to the cart, increasing the quantity of an existing
+// item of the product added on its own, not with a bundle. It fails with ErrInvalidQuantity or ErrInvalidPrice for quantities below 1 and
+// negative prices, and with ErrQuantityTooLarge when the item would hold more than MaxQuantity units.
 func (r *Repository) AddCartItem(cartID uint, productName string, quantity int, price float64) error {
-	return r.db.Transaction(func(tx *gorm.DB) error {
-		var cart cartpkg.Cart
-		if err := tx.First(&cart, cartID).Error; err != nil {
-			return fmt.Errorf("cart not found: %w", err)
-		}
+	// The hooks of CartItem only see the resulting quantity, so the added one is checked up front
+	if err := (cartpkg.CartItem{Quantity: quantity, Price: price}).Validate(); err != nil {
+		return err
+	}
 
-		if cart.Status != cartpkg.StatusOpen {
-			return errors.New("cannot add items to a closed cart")
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		cart, err := openCart(tx, cartID)
+		if err != nil {
+			return err
 		}
 
-		var existingItem cartpkg.CartItem
-		err := tx.Where("cart_id = ? AND product_name = ?", cartID, productName).
-			First(&existingItem).Error
+		var item cartpkg.CartItem
+		err = tx.Where("cart_id = ? AND product_name = ? AND bundle_group = 0", cartID, productName).
+			First(&item).Error
 
 		if err == nil {
-			existingItem.Quantity += quantity
-			if err := tx.Save(&existingItem).Error; err != nil {
+			item.Quantity += quantity
+			if err := tx.Save(&item).Error; err != nil {
 				return fmt.Errorf("failed to update item: %w", err)
 			}
 		} else if errors.Is(err, gorm.ErrRecordNotFound) {
-			item := cartpkg.CartItem{
+			position, err := nextPosition(tx, cartID)
+			if err != nil {
+				return err
+			}
+			item = cartpkg.CartItem{
 				CartID:      cartID,
 				ProductName: productName,
 				Quantity:    quantity,
 				Price:       price,
+				Position:    position,
 			}
 			if err := tx.Create(&item).Error; err != nil {
 				return fmt.Errorf("failed to create item: %w", err)
@@ -96,77 +395,449 @@ func (r *Repository) AddCartItem(cartID uint, productName string, quantity int,
 			return fmt.Errorf("failed to check items: %w", err)
 		}
 
-		return r.updateCartTotal(tx, cartID)
+		err = recordChange(tx, cartpkg.Change{CartID: cartID, Action: cartpkg.ChangeAdded, Product: productName, Quantity: quantity, ItemID: item.ID})
+		if err != nil {
+			return err
+		}
+		return r.updateCartTotal(tx, cart)
 	})
 }
 
+// RemoveCartItem removes the item from the open cart, together with the other items of its bundle
 func (r *Repository) RemoveCartItem(cartID uint, itemID uint) error {
 	return r.db.Transaction(func(tx *gorm.DB) error {
-		var cart cartpkg.Cart
-		if err := tx.First(&cart, cartID).Error; err != nil {
-			return fmt.Errorf("cart not found: %w", err)
-		}
-
-		if cart.Status != cartpkg.StatusOpen {
-			return errors.New("cannot remove items from a closed cart")
+		cart, err := openCart(tx, cartID)
+		if err != nil {
+			return err
 		}
 
 		var item cartpkg.CartItem
 		if err := tx.Where("cart_id = ? AND id = ?", cartID, itemID).
 			First(&item).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return errors.New("item not found")
+				return cartpkg.ErrItemNotFound
 			}
 			return fmt.Errorf("failed to find item: %w", err)
 		}
 
-		if err := tx.Delete(&item).Error; err != nil {
-			return fmt.Errorf("failed to remove item: %w", err)
+		if err := deleteItem(tx, item); err != nil {
+			return err
+		}
+
+		product, quantity := changedUnits(item)
+		err = recordChange(tx, cartpkg.Change{CartID: cartID, Action: cartpkg.ChangeRemoved, Product: product, Quantity: quantity, ItemID: item.ID})
+		if err != nil {
+			return err
+		}
+		return r.updateCartTotal(tx, cart)
+	})
+}
+
+// ListDeletedItems returns the items removed from the cart, most recently removed first
+func (r *Repository) ListDeletedItems(cartID uint) ([]cartpkg.CartItem, error) {
+	var items []cartpkg.CartItem
+	err := r.db.Unscoped().
+		Where("cart_id = ? AND deleted_at IS NOT NULL", cartID).
+		Order("deleted_at DESC, id DESC").
+		Find(&items).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deleted items: %w", err)
+	}
+	return items, nil
+}
+
+// ListRemovedItems returns the removed items of the cart that restoring the item puts back: the item, or
+// all items of its bundle. It fails with ErrItemNotFound when the item wasn't removed from the cart.
+func (r *Repository) ListRemovedItems(cartID uint, itemID uint) ([]cartpkg.CartItem, error) {
+	var item cartpkg.CartItem
+	err := r.db.Unscoped().Where("cart_id = ? AND id = ? AND deleted_at IS NOT NULL", cartID, itemID).First(&item).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, cartpkg.ErrItemNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to find deleted item: %w", err)
+	}
+	if item.BundleGroup == 0 {
+		return []cartpkg.CartItem{item}, nil
+	}
+
+	var items []cartpkg.CartItem
+	err = r.db.Unscoped().Where("cart_id = ? AND bundle_group = ? AND deleted_at IS NOT NULL", cartID, item.BundleGroup).
+		Order("id").Find(&items).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deleted bundle items: %w", err)
+	}
+	return items, nil
+}
+
+// RestoreCartItem puts an item removed from the open cart back and returns it. When the product was
+// added to the cart again in the meantime, the restored quantity is added to that item instead. Items of
+// a bundle are put back with the other items of the bundle.
+func (r *Repository) RestoreCartItem(cartID uint, itemID uint) (*cartpkg.CartItem, error) {
+	var restored *cartpkg.CartItem
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		cart, err := openCart(tx, cartID)
+		if err != nil {
+			return err
+		}
+
+		var changedID uint
+		restored, changedID, err = restoreItem(tx, cartID, itemID)
+		if err != nil {
+			return err
 		}
 
-		return r.updateCartTotal(tx, cartID)
+		product, quantity := changedUnits(*restored)
+		err = recordChange(tx, cartpkg.Change{CartID: cartID, Action: cartpkg.ChangeRestored, Product: product, Quantity: quantity, ItemID: changedID})
+		if err != nil {
+			return err
+		}
+		return r.updateCartTotal(tx, cart)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return restored, nil
+}
+
+// restoreItem puts a removed item of the cart back, see RestoreCartItem. It also returns the ID of the
+// item now holding the restored units.
+func restoreItem(tx *gorm.DB, cartID uint, itemID uint) (*cartpkg.CartItem, uint, error) {
+	var restored cartpkg.CartItem
+	err := tx.Unscoped().Where("cart_id = ? AND id = ? AND deleted_at IS NOT NULL", cartID, itemID).
+		First(&restored).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, 0, cartpkg.ErrItemNotFound
+	} else if err != nil {
+		return nil, 0, fmt.Errorf("failed to find deleted item: %w", err)
+	}
+
+	if restored.BundleGroup != 0 {
+		// Bundles are only ever removed whole, so their items all come back
+		err := tx.Unscoped().Model(&cartpkg.CartItem{}).
+			Where("cart_id = ? AND bundle_group = ? AND deleted_at IS NOT NULL", cartID, restored.BundleGroup).
+			UpdateColumn("deleted_at", nil).Error
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to restore bundle: %w", err)
+		}
+		restored.DeletedAt = gorm.DeletedAt{}
+		return &restored, restored.ID, nil
+	}
+
+	changedID := restored.ID
+	var existing cartpkg.CartItem
+	err = tx.Where("cart_id = ? AND product_name = ? AND bundle_group = 0", cartID, restored.ProductName).First(&existing).Error
+	if err == nil {
+		existing.Quantity += restored.Quantity
+		if err := tx.Save(&existing).Error; err != nil {
+			return nil, 0, fmt.Errorf("failed to update item: %w", err)
+		}
+		if err := tx.Unscoped().Delete(&restored).Error; err != nil {
+			return nil, 0, fmt.Errorf("failed to purge restored item: %w", err)
+		}
+		changedID = existing.ID
+	} else if errors.Is(err, gorm.ErrRecordNotFound) {
+		if err := tx.Unscoped().Model(&restored).Update("deleted_at", nil).Error; err != nil {
+			return nil, 0, fmt.Errorf("failed to restore item: %w", err)
+		}
+		restored.DeletedAt = gorm.DeletedAt{}
+	} else {
+		return nil, 0, fmt.Errorf("failed to check items: %w", err)
+	}
+	return &restored, changedID, nil
+}
+
+// openCart loads a cart to change it, failing with ErrCartNotFound, ErrCartClosed or ErrCartLocked when it
+// can't be
+func openCart(tx *gorm.DB, cartID uint) (*cartpkg.Cart, error) {
+	var cart cartpkg.Cart
+	if err := tx.First(&cart, cartID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, cartpkg.ErrCartNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get cart: %w", err)
+	}
+	if cart.Status != cartpkg.StatusOpen {
+		return nil, cartpkg.ErrCartClosed
+	}
+	if err := checkCartLock(tx, cartID); err != nil {
+		return nil, err
+	}
+	return &cart, nil
+}
+
+// checkCartLock fails with ErrCartLocked while the cart has an active checkout session that hasn't
+// expired yet, or one waiting for its payment to be reviewed
+func checkCartLock(tx *gorm.DB, cartID uint) error {
+	var locks int64
+	err := tx.Model(&checkout.Session{}).
+		Where("cart_id = ? AND ((status = ? AND expires_at > ?) OR status = ?)",
+			cartID, checkout.StatusActive, time.Now(), checkout.StatusReview).
+		Count(&locks).Error
+	if err != nil {
+		return fmt.Errorf("failed to check checkout: %w", err)
+	}
+	if locks > 0 {
+		return cartpkg.ErrCartLocked
+	}
+	return nil
+}
+
+// RefreshCartPrices updates the prices of the cart items to the given current prices, or the lower price
+// of the tier their quantity reaches, and records when it happened. Items of products missing from
+// prices keep their price, and so do items of bundles, which sell at their share of the bundle price.
+// It reports whether any price changed; the total is only recalculated when one did.
+func (r *Repository) RefreshCartPrices(cartID uint, prices map[string]float64, at time.Time) (bool, error) {
+	changed := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		cart, err := openCart(tx, cartID)
+		if err != nil {
+			return err
+		}
+
+		var items []cartpkg.CartItem
+		if err := tx.Where("cart_id = ?", cartID).Find(&items).Error; err != nil {
+			return fmt.Errorf("failed to load items: %w", err)
+		}
+		names := make([]string, 0, len(prices))
+		for name := range prices {
+			names = append(names, name)
+		}
+		tiers, err := priceTiers(tx, names)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			price, ok := prices[item.ProductName]
+			if !ok || item.BundleGroup != 0 {
+				continue
+			}
+			if price < 0 {
+				return fmt.Errorf("price of %s: %w", item.ProductName, cartpkg.ErrInvalidPrice)
+			}
+			price, tierQuantity := tieredPrice(tiers[item.ProductName], item.Quantity, price)
+			if price == item.Price && tierQuantity == item.TierQuantity {
+				continue
+			}
+			if err := tx.Model(&item).Updates(map[string]interface{}{"price": price, "tier_quantity": tierQuantity}).Error; err != nil {
+				return fmt.Errorf("failed to update item price: %w", err)
+			}
+			err := recordChange(tx, cartpkg.Change{CartID: cartID, Action: cartpkg.ChangeRepriced, Product: item.ProductName, Amount: price})
+			if err != nil {
+				return err
+			}
+			changed = true
+		}
+
+		// Checking prices isn't a change to the cart, so it doesn't touch updated_at
+		if err := tx.Model(&cart).UpdateColumn("prices_refreshed_at", at).Error; err != nil {
+			return fmt.Errorf("failed to record price refresh: %w", err)
+		}
+		if !changed {
+			return nil
+		}
+		return r.updateCartTotal(tx, cart)
 	})
+	return changed, err
 }
 
-func (r *Repository) updateCartTotal(db *gorm.DB, cartID uint) error {
-	var total float64
-	if err := db.Model(&cartpkg.CartItem{}).
-		Where("cart_id = ?", cartID).
-		Select("COALESCE(SUM(price * quantity), 0)").
-		Scan(&total).Error; err != nil {
+// updateCartTotal re-applies the price tiers and active promotions of the cart's shop, recalculates the totals of the cart
+// with the discounts, tax, shipping and redeemed gift card credit, and bumps its version. Credit the cart
+// no longer needs is returned to its gift cards.
+// The update only applies if the version is still the one read at the start of the transaction,
+// otherwise ErrConflict is returned.
+func (r *Repository) updateCartTotal(db *gorm.DB, cart *cartpkg.Cart) error {
+	var items []cartpkg.CartItem
+	if err := db.Where("cart_id = ?", cart.ID).Find(&items).Error; err != nil {
 		return fmt.Errorf("failed to calculate total: %w", err)
 	}
+	// Quantities may have changed, so items may reach another price tier
+	if err := r.applyPriceTiers(db, cart, items, time.Now()); err != nil {
+		return err
+	}
+
+	var promotions []promotion.Promotion
+	if err := db.Where("active = ? AND tenant_id = ?", true, cart.TenantID).Find(&promotions).Error; err != nil {
+		return fmt.Errorf("failed to load promotions: %w", err)
+	}
+
+	var subtotal, discount float64
+	for _, item := range items {
+		subtotal += item.Price * float64(item.Quantity)
+	}
 
-	return db.Model(&cartpkg.Cart{}).
-		Where("id = ?", cartID).
-		Update("total", total).Error
+	discounts := promotion.Evaluate(promotions, items)
+	if saved := r.subscriptionSavings(items); saved > 0 {
+		discounts = append(discounts, cartpkg.CartDiscount{Promotion: cartpkg.SubscriptionPromotion, Amount: saved})
+	}
+	if err := db.Unscoped().Where("cart_id = ?", cart.ID).Delete(&cartpkg.CartDiscount{}).Error; err != nil {
+		return fmt.Errorf("failed to clear discounts: %w", err)
+	}
+	for i := range discounts {
+		discounts[i].CartID = cart.ID
+		discount += discounts[i].Amount
+	}
+	if len(discounts) > 0 {
+		if err := db.Create(&discounts).Error; err != nil {
+			return fmt.Errorf("failed to store discounts: %w", err)
+		}
+	}
+	charges := r.charges
+	if cart.TaxExempt {
+		charges.TaxRate = 0
+	}
+	if due := charges.Totals(subtotal, discount, 0).Total; cart.Credit > due {
+		if err := returnCredit(db, cart, cart.Credit-due); err != nil {
+			return err
+		}
+	}
+	totals := charges.Totals(subtotal, discount, cart.Credit)
+
+	result := db.Model(&cartpkg.Cart{}).
+		Where("id = ? AND version = ?", cart.ID, cart.Version).
+		Updates(map[string]interface{}{
+			"subtotal":       totals.Subtotal,
+			"discount_total": totals.Discount,
+			"tax":            totals.Tax,
+			"shipping":       totals.Shipping,
+			"total":          totals.Total,
+			"credit":         cart.Credit,
+			"version":        gorm.Expr("version + 1"),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to update cart: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrConflict
+	}
+	return nil
 }
 
 func (r *Repository) GetCartItem(cartID uint, itemID uint) (*cartpkg.CartItem, error) {
 	var item cartpkg.CartItem
 	err := r.db.Where("cart_id = ? AND id = ?", cartID, itemID).First(&item).Error
-	if err != nil {
-		return nil, err
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, cartpkg.ErrItemNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get item: %w", err)
 	}
 	return &item, nil
 }
 
-func (r *Repository) GetExistingCart(sessionID string) (*cartpkg.Cart, error) {
+// ListCartItems returns up to limit items of the cart with an ID greater than afterID, ordered by ID.
+// Keyset pagination keeps deep pages as cheap as the first one: the cart_id index also holds the
+// primary key, so the query never scans skipped rows the way OFFSET does.
+func (r *Repository) ListCartItems(cartID uint, afterID uint, limit int) ([]cartpkg.CartItem, error) {
+	var items []cartpkg.CartItem
+	err := r.db.Where("cart_id = ? AND id > ?", cartID, afterID).
+		Order("id").
+		Limit(limit).
+		Find(&items).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cart items: %w", err)
+	}
+	return items, nil
+}
+
+// GetExistingCart returns the cart of the session with the given name without creating it: the open
+// one, or the most recently closed one when there is none
+func (r *Repository) GetExistingCart(sessionID, name string) (*cartpkg.Cart, error) {
 	var c cartpkg.Cart
-	result := r.db.Preload("CartItems").
-		Where("session_id = ?", sessionID).
+	result := r.db.Preload("CartItems", orderItems).Preload("Discounts").
+		Where("session_id = ? AND name = ?", sessionID, name).
+		Order("id DESC").
 		First(&c)
-	if result.Error != nil {
-		return nil, result.Error
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, cartpkg.ErrCartNotFound
+	} else if result.Error != nil {
+		return nil, fmt.Errorf("failed to get cart: %w", result.Error)
+	}
+	return &c, nil
+}
+
+// GetCart returns the cart with the given ID and its items
+func (r *Repository) GetCart(cartID uint) (*cartpkg.Cart, error) {
+	var c cartpkg.Cart
+	err := r.db.Preload("CartItems", orderItems).Preload("Discounts").First(&c, cartID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, cartpkg.ErrCartNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get cart: %w", err)
 	}
 	return &c, nil
 }
 
 func (r *Repository) GetAllCarts() ([]*cartpkg.Cart, error) {
 	var carts []*cartpkg.Cart
-	result := r.db.Preload("CartItems").Find(&carts)
+	result := r.reader().Preload("CartItems", orderItems).Preload("Discounts").Find(&carts)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return carts, nil
 }
+
+// CloseCart marks an open cart as closed so it can no longer be modified, creating its pending order,
+// granting the downloads of its digital products, subscribing to the items bought with subscribe & save
+// and rewarding the referrer of the cart if any
+func (r *Repository) CloseCart(cartID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var cart cartpkg.Cart
+		if err := tx.First(&cart, cartID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+			return cartpkg.ErrCartNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to get cart: %w", err)
+		}
+
+		if cart.Status == cartpkg.StatusClosed {
+			return fmt.Errorf("cart is already closed: %w", cartpkg.ErrCartClosed)
+		}
+
+		result := tx.Model(&cartpkg.Cart{}).
+			Where("id = ? AND version = ?", cart.ID, cart.Version).
+			Updates(map[string]interface{}{
+				"status":         cartpkg.StatusClosed,
+				"is_open":        nil,
+				"reserved_until": nil,
+				"version":        gorm.Expr("version + 1"),
+			})
+		if result.Error != nil {
+			return fmt.Errorf("failed to close cart: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrConflict
+		}
+		if err := recordChange(tx, cartpkg.Change{CartID: cart.ID, Action: cartpkg.ChangeCheckedOut}); err != nil {
+			return err
+		}
+		if err := createOrder(tx, &cart); err != nil {
+			return err
+		}
+		if err := r.consumeStock(tx, cart.ID); err != nil {
+			return err
+		}
+		if err := r.grantDownloads(tx, cart.ID); err != nil {
+			return err
+		}
+		if err := createSubscriptions(tx, &cart); err != nil {
+			return err
+		}
+		if err := r.rewardReferral(tx, &cart); err != nil {
+			return err
+		}
+		return recordCheckoutConversions(tx, &cart)
+	})
+}
+
+// OpenCartStats returns the number of open carts and the sum of their totals
+func (r *Repository) OpenCartStats() (int64, float64, error) {
+	var stats struct {
+		Count int64
+		Value float64
+	}
+	err := r.reader().Model(&cartpkg.Cart{}).
+		Select("COUNT(*) AS count, COALESCE(SUM(total), 0) AS value").
+		Where("status = ?", cartpkg.StatusOpen).
+		Scan(&stats).Error
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get cart stats: %w", err)
+	}
+	return stats.Count, stats.Value, nil
+}
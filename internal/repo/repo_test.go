@@ -1,10 +1,10 @@
 package repo_test
 
 import (
-	"errors"
 	cartpkg "interview/internal/cart"
 	"interview/internal/repo"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -16,7 +16,7 @@ func setupTestDB(t *testing.T) *gorm.DB {
 	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	require.NoError(t, err)
 
-	err = db.AutoMigrate(&cartpkg.Cart{}, &cartpkg.CartItem{})
+	err = repo.Migrate(db)
 	require.NoError(t, err)
 
 	return db
@@ -28,7 +28,7 @@ func TestGetOrCreateCart(t *testing.T) {
 
 	t.Run("creates new cart when none exists", func(t *testing.T) {
 		sessionID := "test-session-1"
-		cart, err := repo.GetOrCreateCart(sessionID)
+		cart, err := repo.GetOrCreateCart(sessionID, cartpkg.DefaultName)
 
 		require.NoError(t, err)
 		assert.Equal(t, sessionID, cart.SessionID)
@@ -38,15 +38,68 @@ func TestGetOrCreateCart(t *testing.T) {
 
 	t.Run("returns existing cart", func(t *testing.T) {
 		sessionID := "test-session-2"
-		cart1, err := repo.GetOrCreateCart(sessionID)
+		cart1, err := repo.GetOrCreateCart(sessionID, cartpkg.DefaultName)
 		require.NoError(t, err)
 
-		cart2, err := repo.GetOrCreateCart(sessionID)
+		cart2, err := repo.GetOrCreateCart(sessionID, cartpkg.DefaultName)
 		require.NoError(t, err)
 
 		assert.Equal(t, cart1.ID, cart2.ID)
 		assert.Equal(t, sessionID, cart2.SessionID)
 	})
+
+	t.Run("opens a new cart once the cart is closed", func(t *testing.T) {
+		sessionID := "test-session-3"
+		closed, err := repo.GetOrCreateCart(sessionID, cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, repo.CloseCart(closed.ID))
+
+		reopened, err := repo.GetOrCreateCart(sessionID, cartpkg.DefaultName)
+		require.NoError(t, err)
+		assert.NotEqual(t, closed.ID, reopened.ID)
+		assert.Equal(t, cartpkg.StatusOpen, reopened.Status)
+
+		existing, err := repo.GetExistingCart(sessionID, cartpkg.DefaultName)
+		require.NoError(t, err)
+		assert.Equal(t, reopened.ID, existing.ID, "the open cart wins over closed ones")
+	})
+
+	t.Run("allows one open cart per name", func(t *testing.T) {
+		sessionID := "test-session-4"
+		_, err := repo.GetOrCreateCart(sessionID, cartpkg.DefaultName)
+		require.NoError(t, err)
+
+		duplicate := cartpkg.Cart{SessionID: sessionID, Name: cartpkg.DefaultName, Status: cartpkg.StatusOpen}
+		assert.Error(t, db.Create(&duplicate).Error)
+	})
+}
+
+func TestMigrateOpenCarts(t *testing.T) {
+	db := setupTestDB(t)
+	// Databases of earlier versions have no is_open column and may hold several open carts per name
+	require.NoError(t, db.Migrator().DropIndex(&cartpkg.Cart{}, "idx_cart_session_open"))
+	require.NoError(t, db.Migrator().DropColumn(&cartpkg.Cart{}, "is_open"))
+	for _, status := range []string{cartpkg.StatusClosed, cartpkg.StatusOpen, cartpkg.StatusOpen} {
+		err := db.Exec("INSERT INTO carts (session_id, name, status, created_at, updated_at) VALUES (?, ?, ?, ?, ?)",
+			"legacy", cartpkg.DefaultName, status, time.Now(), time.Now()).Error
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, repo.Migrate(db))
+
+	var carts []cartpkg.Cart
+	require.NoError(t, db.Unscoped().Order("id").Find(&carts).Error)
+	require.Len(t, carts, 3)
+	assert.Nil(t, carts[0].IsOpen, "closed carts aren't open")
+	assert.True(t, carts[1].DeletedAt.Valid, "older duplicates are deleted")
+	assert.Nil(t, carts[1].IsOpen)
+	assert.False(t, carts[2].DeletedAt.Valid)
+	require.NotNil(t, carts[2].IsOpen)
+	assert.True(t, *carts[2].IsOpen)
+
+	open, err := repo.NewRepository(db).GetOrCreateCart("legacy", cartpkg.DefaultName)
+	require.NoError(t, err)
+	assert.Equal(t, carts[2].ID, open.ID)
 }
 
 func TestAddCartItem(t *testing.T) {
@@ -54,13 +107,13 @@ func TestAddCartItem(t *testing.T) {
 	repo := repo.NewRepository(db)
 
 	t.Run("adds new item to cart", func(t *testing.T) {
-		cart, err := repo.GetOrCreateCart("test-session")
+		cart, err := repo.GetOrCreateCart("test-session", cartpkg.DefaultName)
 		require.NoError(t, err)
 
 		err = repo.AddCartItem(cart.ID, "test-product", 1, 10.0)
 		require.NoError(t, err)
 
-		updatedCart, err := repo.GetExistingCart("test-session")
+		updatedCart, err := repo.GetExistingCart("test-session", cartpkg.DefaultName)
 		require.NoError(t, err)
 		require.Len(t, updatedCart.CartItems, 1)
 		assert.Equal(t, "test-product", updatedCart.CartItems[0].ProductName)
@@ -70,7 +123,7 @@ func TestAddCartItem(t *testing.T) {
 	})
 
 	t.Run("updates quantity for existing item", func(t *testing.T) {
-		cart, err := repo.GetOrCreateCart("test-session-2")
+		cart, err := repo.GetOrCreateCart("test-session-2", cartpkg.DefaultName)
 		require.NoError(t, err)
 
 		err = repo.AddCartItem(cart.ID, "test-product", 1, 10.0)
@@ -79,7 +132,7 @@ func TestAddCartItem(t *testing.T) {
 		err = repo.AddCartItem(cart.ID, "test-product", 2, 10.0)
 		require.NoError(t, err)
 
-		updatedCart, err := repo.GetExistingCart("test-session-2")
+		updatedCart, err := repo.GetExistingCart("test-session-2", cartpkg.DefaultName)
 		require.NoError(t, err)
 		require.Len(t, updatedCart.CartItems, 1)
 		assert.Equal(t, 3, updatedCart.CartItems[0].Quantity)
@@ -97,27 +150,27 @@ func TestRemoveCartItem(t *testing.T) {
 	repo := repo.NewRepository(db)
 
 	t.Run("removes item from cart", func(t *testing.T) {
-		cart, err := repo.GetOrCreateCart("test-session")
+		cart, err := repo.GetOrCreateCart("test-session", cartpkg.DefaultName)
 		require.NoError(t, err)
 
 		err = repo.AddCartItem(cart.ID, "test-product", 1, 10.0)
 		require.NoError(t, err)
 
-		updatedCart, err := repo.GetExistingCart("test-session")
+		updatedCart, err := repo.GetExistingCart("test-session", cartpkg.DefaultName)
 		require.NoError(t, err)
 		require.Len(t, updatedCart.CartItems, 1)
 
 		err = repo.RemoveCartItem(cart.ID, updatedCart.CartItems[0].ID)
 		require.NoError(t, err)
 
-		finalCart, err := repo.GetExistingCart("test-session")
+		finalCart, err := repo.GetExistingCart("test-session", cartpkg.DefaultName)
 		require.NoError(t, err)
 		assert.Empty(t, finalCart.CartItems)
 		assert.Equal(t, 0.0, finalCart.Total)
 	})
 
 	t.Run("fails for non-existent item", func(t *testing.T) {
-		cart, err := repo.GetOrCreateCart("test-session-2")
+		cart, err := repo.GetOrCreateCart("test-session-2", cartpkg.DefaultName)
 		require.NoError(t, err)
 
 		err = repo.RemoveCartItem(cart.ID, 9999)
@@ -125,18 +178,157 @@ func TestRemoveCartItem(t *testing.T) {
 	})
 }
 
+func TestRestoreCartItem(t *testing.T) {
+	db := setupTestDB(t)
+	repo := repo.NewRepository(db)
+
+	removeAll := func(t *testing.T, sessionID string) *cartpkg.Cart {
+		cart, err := repo.GetOrCreateCart(sessionID, cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, repo.AddCartItem(cart.ID, "shoe", 2, 10.0))
+		require.NoError(t, repo.AddCartItem(cart.ID, "bag", 1, 30.0))
+
+		cart, err = repo.GetExistingCart(sessionID, cartpkg.DefaultName)
+		require.NoError(t, err)
+		for _, item := range cart.CartItems {
+			require.NoError(t, repo.RemoveCartItem(cart.ID, item.ID))
+		}
+		return cart
+	}
+
+	t.Run("lists deleted items", func(t *testing.T) {
+		cart := removeAll(t, "restore-list")
+
+		deleted, err := repo.ListDeletedItems(cart.ID)
+		require.NoError(t, err)
+		require.Len(t, deleted, 2)
+		assert.ElementsMatch(t, []string{"shoe", "bag"}, []string{deleted[0].ProductName, deleted[1].ProductName})
+	})
+
+	t.Run("restores item", func(t *testing.T) {
+		cart := removeAll(t, "restore-item")
+		deleted, err := repo.ListDeletedItems(cart.ID)
+		require.NoError(t, err)
+
+		item, err := repo.RestoreCartItem(cart.ID, deleted[0].ID)
+		require.NoError(t, err)
+		assert.Equal(t, deleted[0].ID, item.ID)
+
+		restored, err := repo.GetExistingCart("restore-item", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.Len(t, restored.CartItems, 1)
+		assert.Equal(t, deleted[0].ProductName, restored.CartItems[0].ProductName)
+		assert.Equal(t, float64(deleted[0].Quantity)*deleted[0].Price, restored.Total)
+
+		remaining, err := repo.ListDeletedItems(cart.ID)
+		require.NoError(t, err)
+		assert.Len(t, remaining, 1)
+	})
+
+	t.Run("merges with product added again", func(t *testing.T) {
+		cart := removeAll(t, "restore-merge")
+		require.NoError(t, repo.AddCartItem(cart.ID, "shoe", 1, 10.0))
+		deleted, err := repo.ListDeletedItems(cart.ID)
+		require.NoError(t, err)
+		var shoe cartpkg.CartItem
+		for _, item := range deleted {
+			if item.ProductName == "shoe" {
+				shoe = item
+			}
+		}
+
+		_, err = repo.RestoreCartItem(cart.ID, shoe.ID)
+		require.NoError(t, err)
+
+		restored, err := repo.GetExistingCart("restore-merge", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.Len(t, restored.CartItems, 1)
+		assert.Equal(t, 3, restored.CartItems[0].Quantity)
+		assert.Equal(t, 30.0, restored.Total)
+
+		remaining, err := repo.ListDeletedItems(cart.ID)
+		require.NoError(t, err)
+		require.Len(t, remaining, 1)
+		assert.Equal(t, "bag", remaining[0].ProductName)
+	})
+
+	t.Run("fails for active item", func(t *testing.T) {
+		cart, err := repo.GetOrCreateCart("restore-active", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, repo.AddCartItem(cart.ID, "shoe", 1, 10.0))
+		cart, err = repo.GetExistingCart("restore-active", cartpkg.DefaultName)
+		require.NoError(t, err)
+
+		_, err = repo.RestoreCartItem(cart.ID, cart.CartItems[0].ID)
+		assert.ErrorIs(t, err, cartpkg.ErrItemNotFound)
+	})
+}
+
+func TestCartItemValidation(t *testing.T) {
+	db := setupTestDB(t)
+	repo := repo.NewRepository(db)
+
+	cart, err := repo.GetOrCreateCart("validation-session", cartpkg.DefaultName)
+	require.NoError(t, err)
+	require.NoError(t, repo.AddCartItem(cart.ID, "shoe", 2, 10.0))
+
+	t.Run("rejects invalid items", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			product  string
+			quantity int
+			price    float64
+			err      error
+		}{
+			{"zero quantity", "bag", 0, 10.0, cartpkg.ErrInvalidQuantity},
+			{"negative quantity", "bag", -1, 10.0, cartpkg.ErrInvalidQuantity},
+			{"negative quantity of existing item", "shoe", -1, 10.0, cartpkg.ErrInvalidQuantity},
+			{"quantity above the maximum", "bag", 2000000000, 10.0, cartpkg.ErrQuantityTooLarge},
+			{"existing item above the maximum", "shoe", cartpkg.MaxQuantity - 1, 10.0, cartpkg.ErrQuantityTooLarge},
+			{"negative price", "bag", 1, -5.0, cartpkg.ErrInvalidPrice},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				err := repo.AddCartItem(cart.ID, tt.product, tt.quantity, tt.price)
+				assert.ErrorIs(t, err, tt.err)
+			})
+		}
+
+		stored, err := repo.GetExistingCart("validation-session", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.Len(t, stored.CartItems, 1)
+		assert.Equal(t, 2, stored.CartItems[0].Quantity)
+		assert.Equal(t, 20.0, stored.Total)
+	})
+
+	t.Run("rejects invalid items written directly", func(t *testing.T) {
+		err := db.Create(&cartpkg.CartItem{CartID: cart.ID, ProductName: "bag", Quantity: 0, Price: 10.0}).Error
+		assert.ErrorIs(t, err, cartpkg.ErrInvalidQuantity)
+
+		var item cartpkg.CartItem
+		require.NoError(t, db.Where("cart_id = ?", cart.ID).First(&item).Error)
+		item.Price = -1
+		assert.ErrorIs(t, db.Save(&item).Error, cartpkg.ErrInvalidPrice)
+	})
+
+	t.Run("rejects negative refreshed prices", func(t *testing.T) {
+		_, err := repo.RefreshCartPrices(cart.ID, map[string]float64{"shoe": -1}, time.Now())
+		assert.ErrorIs(t, err, cartpkg.ErrInvalidPrice)
+	})
+}
+
 func TestGetCartItem(t *testing.T) {
 	db := setupTestDB(t)
 	repo := repo.NewRepository(db)
 
 	t.Run("gets existing item", func(t *testing.T) {
-		cart, err := repo.GetOrCreateCart("test-session")
+		cart, err := repo.GetOrCreateCart("test-session", cartpkg.DefaultName)
 		require.NoError(t, err)
 
 		err = repo.AddCartItem(cart.ID, "test-product", 1, 10.0)
 		require.NoError(t, err)
 
-		updatedCart, err := repo.GetExistingCart("test-session")
+		updatedCart, err := repo.GetExistingCart("test-session", cartpkg.DefaultName)
 		require.NoError(t, err)
 
 		item, err := repo.GetCartItem(cart.ID, updatedCart.CartItems[0].ID)
@@ -147,11 +339,11 @@ func TestGetCartItem(t *testing.T) {
 	})
 
 	t.Run("returns error for non-existent item", func(t *testing.T) {
-		cart, err := repo.GetOrCreateCart("test-session-2")
+		cart, err := repo.GetOrCreateCart("test-session-2", cartpkg.DefaultName)
 		require.NoError(t, err)
 
 		_, err = repo.GetCartItem(cart.ID, 9999)
-		assert.Error(t, err)
+		assert.ErrorIs(t, err, cartpkg.ErrItemNotFound)
 	})
 }
 
@@ -166,12 +358,12 @@ func TestGetAllCarts(t *testing.T) {
 	})
 
 	t.Run("returns all existing carts", func(t *testing.T) {
-		cart1, err := repo.GetOrCreateCart("test-session-1")
+		cart1, err := repo.GetOrCreateCart("test-session-1", cartpkg.DefaultName)
 		require.NoError(t, err)
 		err = repo.AddCartItem(cart1.ID, "product-1", 1, 10.0)
 		require.NoError(t, err)
 
-		cart2, err := repo.GetOrCreateCart("test-session-2")
+		cart2, err := repo.GetOrCreateCart("test-session-2", cartpkg.DefaultName)
 		require.NoError(t, err)
 		err = repo.AddCartItem(cart2.ID, "product-2", 2, 20.0)
 		require.NoError(t, err)
@@ -187,20 +379,20 @@ func TestGetExistingCart(t *testing.T) {
 	repo := repo.NewRepository(db)
 
 	t.Run("returns error for non-existent cart", func(t *testing.T) {
-		_, err := repo.GetExistingCart("non-existent-session")
+		_, err := repo.GetExistingCart("non-existent-session", cartpkg.DefaultName)
 		assert.Error(t, err)
-		assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+		assert.ErrorIs(t, err, cartpkg.ErrCartNotFound)
 	})
 
 	t.Run("returns existing cart", func(t *testing.T) {
 		sessionID := "test-session"
-		cart, err := repo.GetOrCreateCart(sessionID)
+		cart, err := repo.GetOrCreateCart(sessionID, cartpkg.DefaultName)
 		require.NoError(t, err)
 
 		err = repo.AddCartItem(cart.ID, "test-product", 1, 10.0)
 		require.NoError(t, err)
 
-		existingCart, err := repo.GetExistingCart(sessionID)
+		existingCart, err := repo.GetExistingCart(sessionID, cartpkg.DefaultName)
 		require.NoError(t, err)
 		assert.Equal(t, cart.ID, existingCart.ID)
 		assert.Equal(t, sessionID, existingCart.SessionID)
@@ -208,3 +400,157 @@ func TestGetExistingCart(t *testing.T) {
 		assert.Equal(t, "test-product", existingCart.CartItems[0].ProductName)
 	})
 }
+
+func TestOptimisticLocking(t *testing.T) {
+	db := setupTestDB(t)
+	cartRepo := repo.NewRepository(db)
+
+	t.Run("every mutation bumps the version", func(t *testing.T) {
+		cart, err := cartRepo.GetOrCreateCart("versioned-session", cartpkg.DefaultName)
+		require.NoError(t, err)
+		initial := cart.Version
+
+		require.NoError(t, cartRepo.AddCartItem(cart.ID, "test-product", 1, 10.0))
+		cart, err = cartRepo.GetExistingCart("versioned-session", cartpkg.DefaultName)
+		require.NoError(t, err)
+		assert.Equal(t, initial+1, cart.Version)
+
+		require.NoError(t, cartRepo.RemoveCartItem(cart.ID, cart.CartItems[0].ID))
+		cart, err = cartRepo.GetExistingCart("versioned-session", cartpkg.DefaultName)
+		require.NoError(t, err)
+		assert.Equal(t, initial+2, cart.Version)
+	})
+
+	t.Run("concurrent modification returns ErrConflict", func(t *testing.T) {
+		cart, err := cartRepo.GetOrCreateCart("conflict-session", cartpkg.DefaultName)
+		require.NoError(t, err)
+
+		// Simulate another request committing a change between reading and updating the cart
+		interfere := true
+		require.NoError(t, db.Callback().Update().Before("gorm:update").Register("test:interfere", func(tx *gorm.DB) {
+			if interfere && tx.Statement.Table == "carts" {
+				interfere = false
+				tx.Session(&gorm.Session{NewDB: true}).Exec("UPDATE carts SET version = version + 1 WHERE id = ?", cart.ID)
+			}
+		}))
+		t.Cleanup(func() { _ = db.Callback().Update().Remove("test:interfere") })
+
+		err = cartRepo.AddCartItem(cart.ID, "test-product", 1, 10.0)
+		assert.ErrorIs(t, err, repo.ErrConflict)
+
+		// The transaction was rolled back, so the item was not added
+		cart, err = cartRepo.GetExistingCart("conflict-session", cartpkg.DefaultName)
+		require.NoError(t, err)
+		assert.Empty(t, cart.CartItems)
+	})
+}
+
+func TestListCartItems(t *testing.T) {
+	db := setupTestDB(t)
+	repo := repo.NewRepository(db)
+
+	cart, err := repo.GetOrCreateCart("paged-session", cartpkg.DefaultName)
+	require.NoError(t, err)
+	for _, product := range []string{"a", "b", "c", "d", "e"} {
+		require.NoError(t, repo.AddCartItem(cart.ID, product, 1, 1.0))
+	}
+
+	t.Run("returns first page in ID order", func(t *testing.T) {
+		items, err := repo.ListCartItems(cart.ID, 0, 2)
+		require.NoError(t, err)
+		require.Len(t, items, 2)
+		assert.Equal(t, "a", items[0].ProductName)
+		assert.Equal(t, "b", items[1].ProductName)
+	})
+
+	t.Run("continues after the cursor", func(t *testing.T) {
+		first, err := repo.ListCartItems(cart.ID, 0, 2)
+		require.NoError(t, err)
+
+		items, err := repo.ListCartItems(cart.ID, first[1].ID, 10)
+		require.NoError(t, err)
+		require.Len(t, items, 3)
+		assert.Equal(t, "c", items[0].ProductName)
+	})
+
+	t.Run("only lists items of the given cart", func(t *testing.T) {
+		other, err := repo.GetOrCreateCart("other-paged-session", cartpkg.DefaultName)
+		require.NoError(t, err)
+
+		items, err := repo.ListCartItems(other.ID, 0, 10)
+		require.NoError(t, err)
+		assert.Empty(t, items)
+	})
+}
+
+func TestCloseCart(t *testing.T) {
+	db := setupTestDB(t)
+	repo := repo.NewRepository(db)
+
+	cart, err := repo.GetOrCreateCart("closing-session", cartpkg.DefaultName)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.CloseCart(cart.ID))
+
+	closed, err := repo.GetExistingCart("closing-session", cartpkg.DefaultName)
+	require.NoError(t, err)
+	assert.Equal(t, cartpkg.StatusClosed, closed.Status)
+
+	t.Run("closed cart can't be modified", func(t *testing.T) {
+		err := repo.AddCartItem(cart.ID, "test-product", 1, 10.0)
+		assert.ErrorIs(t, err, cartpkg.ErrCartClosed)
+	})
+
+	t.Run("closing twice fails", func(t *testing.T) {
+		assert.ErrorIs(t, repo.CloseCart(cart.ID), cartpkg.ErrCartClosed)
+	})
+
+	t.Run("unknown cart fails", func(t *testing.T) {
+		assert.ErrorIs(t, repo.CloseCart(9999), cartpkg.ErrCartNotFound)
+	})
+}
+
+func TestRefreshCartPrices(t *testing.T) {
+	db := setupTestDB(t)
+	repo := repo.NewRepository(db)
+
+	cart, err := repo.GetOrCreateCart("refresh-session", cartpkg.DefaultName)
+	require.NoError(t, err)
+	require.NoError(t, repo.AddCartItem(cart.ID, "shoe", 2, 10.0))
+	require.NoError(t, repo.AddCartItem(cart.ID, "bag", 1, 30.0))
+	cart, err = repo.GetExistingCart("refresh-session", cartpkg.DefaultName)
+	require.NoError(t, err)
+	assert.Nil(t, cart.PricesRefreshedAt)
+
+	t.Run("unchanged prices only record the check", func(t *testing.T) {
+		at := time.Now()
+		changed, err := repo.RefreshCartPrices(cart.ID, map[string]float64{"shoe": 10.0, "bag": 30.0}, at)
+		require.NoError(t, err)
+		assert.False(t, changed)
+
+		refreshed, err := repo.GetExistingCart("refresh-session", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NotNil(t, refreshed.PricesRefreshedAt)
+		assert.WithinDuration(t, at, refreshed.PricesCheckedAt(), time.Second)
+		assert.Equal(t, cart.Version, refreshed.Version)
+		assert.Equal(t, cart.UpdatedAt.Unix(), refreshed.UpdatedAt.Unix())
+	})
+
+	t.Run("changed prices update items and total", func(t *testing.T) {
+		// The bag is no longer sold, so it keeps its price
+		changed, err := repo.RefreshCartPrices(cart.ID, map[string]float64{"shoe": 12.5}, time.Now())
+		require.NoError(t, err)
+		assert.True(t, changed)
+
+		refreshed, err := repo.GetExistingCart("refresh-session", cartpkg.DefaultName)
+		require.NoError(t, err)
+		assert.Equal(t, 55.0, refreshed.Total)
+		assert.Equal(t, cart.Version+1, refreshed.Version)
+	})
+
+	t.Run("closed cart can't be refreshed", func(t *testing.T) {
+		require.NoError(t, repo.CloseCart(cart.ID))
+		_, err := repo.RefreshCartPrices(cart.ID, map[string]float64{"shoe": 15.0}, time.Now())
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,127 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	cartpkg "interview/internal/cart"
+	productpkg "interview/internal/product"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReserveStock holds quantity more units of the named product for the cart until then, on top of those
+// already in it, failing with productpkg.ErrOutOfStock when fewer are left. Units held by other carts
+// aren't left: carts hold the stock of their limited items until ReleaseExpiredReservations releases it.
+// Products that aren't in the catalog or whose stock isn't tracked aren't limited and aren't held.
+func (r *Repository) ReserveStock(cartID uint, name string, quantity int, until time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// Locking the product makes concurrent reservations of it wait for each other
+		var p productpkg.Product
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("name = ?", name).First(&p).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to check stock: %w", err)
+		}
+		if p.Stock == nil {
+			return nil
+		}
+
+		var held, own int
+		err = tx.Model(&cartpkg.CartItem{}).
+			Joins("JOIN carts ON carts.id = cart_items.cart_id AND carts.deleted_at IS NULL").
+			Where("cart_items.product_name = ? AND carts.reserved_until IS NOT NULL AND carts.id <> ?", name, cartID).
+			Select("COALESCE(SUM(cart_items.quantity), 0)").
+			Scan(&held).Error
+		if err != nil {
+			return fmt.Errorf("failed to sum reserved stock: %w", err)
+		}
+		err = tx.Model(&cartpkg.CartItem{}).
+			Where("cart_id = ? AND product_name = ?", cartID, name).
+			Select("COALESCE(SUM(quantity), 0)").
+			Scan(&own).Error
+		if err != nil {
+			return fmt.Errorf("failed to sum cart items: %w", err)
+		}
+		if *p.Stock-held < own+quantity {
+			return productpkg.ErrOutOfStock
+		}
+
+		if err := tx.Model(&cartpkg.Cart{}).Where("id = ?", cartID).Update("reserved_until", until).Error; err != nil {
+			return fmt.Errorf("failed to reserve stock: %w", err)
+		}
+		return nil
+	})
+}
+
+// ReleaseExpiredReservations releases the stock held by open carts whose reservation ran out before now,
+// taking their limited items out, and returns how many carts it released. Carts being checked out keep
+// their reservation until the checkout ends.
+func (r *Repository) ReleaseExpiredReservations(ctx context.Context, now time.Time) (int, error) {
+	var carts []cartpkg.Cart
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND reserved_until <= ?", cartpkg.StatusOpen, now).
+		Order("reserved_until").
+		Find(&carts).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to find expired reservations: %w", err)
+	}
+
+	released := 0
+	for _, cart := range carts {
+		if err := ctx.Err(); err != nil {
+			return released, err
+		}
+		err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			cart, err := openCart(tx, cart.ID)
+			if err != nil {
+				return err
+			}
+			return r.releaseReservation(tx, cart)
+		})
+		if errors.Is(err, cartpkg.ErrCartLocked) || errors.Is(err, cartpkg.ErrCartClosed) {
+			continue
+		} else if err != nil {
+			return released, err
+		}
+		released++
+	}
+	return released, nil
+}
+
+// releaseReservation takes the limited items out of the cart, with the bundles they are part of, and
+// clears its reservation
+func (r *Repository) releaseReservation(tx *gorm.DB, cart *cartpkg.Cart) error {
+	var items []cartpkg.CartItem
+	err := tx.Joins("JOIN products ON products.name = cart_items.product_name AND products.deleted_at IS NULL").
+		Where("cart_items.cart_id = ? AND products.stock IS NOT NULL", cart.ID).
+		Find(&items).Error
+	if err != nil {
+		return fmt.Errorf("failed to find limited items: %w", err)
+	}
+	bundles := make(map[uint]bool)
+	for _, item := range items {
+		if item.BundleGroup != 0 {
+			if bundles[item.BundleGroup] {
+				continue
+			}
+			bundles[item.BundleGroup] = true
+		}
+		if err := deleteItem(tx, item); err != nil {
+			return err
+		}
+		product, quantity := changedUnits(item)
+		err := recordChange(tx, cartpkg.Change{CartID: cart.ID, Action: cartpkg.ChangeExpired, Product: product, Quantity: quantity})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Model(&cartpkg.Cart{}).Where("id = ?", cart.ID).Update("reserved_until", nil).Error; err != nil {
+		return fmt.Errorf("failed to release reservation: %w", err)
+	}
+	return r.updateCartTotal(tx, cart)
+}
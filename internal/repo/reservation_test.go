@@ -0,0 +1,102 @@
+package repo_test
+
+import (
+	"context"
+	cartpkg "interview/internal/cart"
+	productpkg "interview/internal/product"
+	"interview/internal/repo"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReservations(t *testing.T) {
+	db := setupTestDB(t)
+	r := repo.NewRepository(db)
+	watch, err := r.UpsertProduct("watch", 40)
+	require.NoError(t, err)
+	three := 3
+	require.NoError(t, r.SetProductStock(watch.ID, &three))
+	_, err = r.UpsertProduct("shoe", 10)
+	require.NoError(t, err)
+	now := time.Now()
+
+	reserve := func(t *testing.T, cart *cartpkg.Cart, product string, quantity int, until time.Time) error {
+		t.Helper()
+		if err := r.ReserveStock(cart.ID, product, quantity, until); err != nil {
+			return err
+		}
+		return r.AddCartItem(cart.ID, product, quantity, 10)
+	}
+
+	t.Run("holds stock for other carts", func(t *testing.T) {
+		first, err := r.GetOrCreateCart("first-session", cartpkg.DefaultName)
+		require.NoError(t, err)
+		second, err := r.GetOrCreateCart("second-session", cartpkg.DefaultName)
+		require.NoError(t, err)
+
+		require.NoError(t, reserve(t, first, "watch", 2, now.Add(10*time.Minute)))
+		assert.ErrorIs(t, reserve(t, second, "watch", 2, now.Add(10*time.Minute)), productpkg.ErrOutOfStock)
+		require.NoError(t, reserve(t, second, "watch", 1, now.Add(10*time.Minute)))
+		assert.ErrorIs(t, reserve(t, first, "watch", 1, now.Add(10*time.Minute)), productpkg.ErrOutOfStock)
+
+		// Products whose stock isn't tracked aren't held
+		third, err := r.GetOrCreateCart("third-session", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, reserve(t, third, "shoe", 100, now.Add(10*time.Minute)))
+		third, err = r.GetCart(third.ID)
+		require.NoError(t, err)
+		assert.Nil(t, third.ReservedUntil)
+
+		first, err = r.GetCart(first.ID)
+		require.NoError(t, err)
+		require.NotNil(t, first.ReservedUntil)
+		assert.WithinDuration(t, now.Add(10*time.Minute), *first.ReservedUntil, time.Second)
+	})
+
+	t.Run("releases expired reservations", func(t *testing.T) {
+		cart, err := r.GetOrCreateCart("expired-session", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, r.AddCartItem(cart.ID, "shoe", 1, 10))
+		// The stock held by the first two carts is released once they expire
+		assert.ErrorIs(t, reserve(t, cart, "watch", 1, now.Add(-time.Minute)), productpkg.ErrOutOfStock)
+
+		released, err := r.ReleaseExpiredReservations(context.Background(), now.Add(11*time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, 2, released)
+		require.NoError(t, reserve(t, cart, "watch", 3, now.Add(-time.Minute)))
+
+		released, err = r.ReleaseExpiredReservations(context.Background(), now)
+		require.NoError(t, err)
+		assert.Equal(t, 1, released)
+		cart, err = r.GetCart(cart.ID)
+		require.NoError(t, err)
+		assert.Nil(t, cart.ReservedUntil)
+		require.Len(t, cart.CartItems, 1, "only limited items are taken out")
+		assert.Equal(t, "shoe", cart.CartItems[0].ProductName)
+		assert.Equal(t, 10.0, cart.Subtotal)
+
+		changes, err := r.ListCartChanges(cart.ID, 0, 1)
+		require.NoError(t, err)
+		require.Len(t, changes, 1)
+		assert.Equal(t, cartpkg.ChangeExpired, changes[0].Action)
+		assert.Equal(t, "watch", changes[0].Product)
+		assert.Equal(t, 3, changes[0].Quantity)
+	})
+
+	t.Run("checkout ends the reservation", func(t *testing.T) {
+		cart, err := r.GetOrCreateCart("checkout-session", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, reserve(t, cart, "watch", 3, now.Add(time.Minute)))
+		require.NoError(t, r.CloseCart(cart.ID))
+
+		cart, err = r.GetCart(cart.ID)
+		require.NoError(t, err)
+		assert.Nil(t, cart.ReservedUntil)
+		p, err := r.GetProduct(watch.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 0, *p.Stock)
+	})
+}
@@ -0,0 +1,128 @@
+package repo
+
+import (
+	"fmt"
+	cartpkg "interview/internal/cart"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type (
+	// ReportPeriod bounds the carts a report covers, From inclusive and To exclusive. Zero values don't
+	// bound it.
+	ReportPeriod struct {
+		From time.Time
+		To   time.Time
+	}
+
+	// DailyRevenue is the number and value of the carts checked out on a day
+	DailyRevenue struct {
+		// Day is the date in the form 2006-01-02
+		Day     string
+		Carts   int64
+		Revenue float64
+	}
+
+	// ProductSales is how much of a product was checked out
+	ProductSales struct {
+		ProductName string
+		Quantity    int64
+		Revenue     float64
+	}
+
+	// CheckoutStats sums up how carts ended
+	CheckoutStats struct {
+		// CheckedOut and AverageValue are the number and average total of the carts checked out
+		CheckedOut   int64
+		AverageValue float64
+		// Started is the number of carts with items created in the period, of which Converted were
+		// checked out and Abandoned are open but idle
+		Started   int64
+		Converted int64
+		Abandoned int64
+	}
+)
+
+// closedIn selects the carts checked out in the period. Closed carts can't change anymore, so their
+// update time is when they were checked out.
+func closedIn(query *gorm.DB, period ReportPeriod) *gorm.DB {
+	query = query.Where("carts.status = ? AND carts.deleted_at IS NULL", cartpkg.StatusClosed)
+	if !period.From.IsZero() {
+		query = query.Where("carts.updated_at >= ?", period.From)
+	}
+	if !period.To.IsZero() {
+		query = query.Where("carts.updated_at < ?", period.To)
+	}
+	return query
+}
+
+// ListDailyRevenue returns the revenue of the carts checked out in the period by day, oldest first
+func (r *Repository) ListDailyRevenue(period ReportPeriod) ([]DailyRevenue, error) {
+	var days []DailyRevenue
+	err := closedIn(r.reader().Table("carts"), period).
+		Select("DATE(carts.updated_at) AS day, COUNT(*) AS carts, SUM(carts.total) AS revenue").
+		Group("DATE(carts.updated_at)").
+		Order("day").
+		Scan(&days).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list daily revenue: %w", err)
+	}
+	// MySQL returns dates as times, which are scanned in RFC 3339
+	for i := range days {
+		if len(days[i].Day) > len(time.DateOnly) {
+			days[i].Day = days[i].Day[:len(time.DateOnly)]
+		}
+	}
+	return days, nil
+}
+
+// ListTopProducts returns the limit products checked out in the largest quantities in the period
+func (r *Repository) ListTopProducts(period ReportPeriod, limit int) ([]ProductSales, error) {
+	var products []ProductSales
+	err := closedIn(r.reader().Table("cart_items"), period).
+		Select("cart_items.product_name, SUM(cart_items.quantity) AS quantity, " +
+			"SUM(cart_items.quantity * cart_items.price) AS revenue").
+		Joins("JOIN carts ON carts.id = cart_items.cart_id").
+		Where("cart_items.deleted_at IS NULL").
+		Group("cart_items.product_name").
+		Order("quantity DESC, cart_items.product_name").
+		Limit(limit).
+		Scan(&products).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list top products: %w", err)
+	}
+	return products, nil
+}
+
+// GetCheckoutStats returns the checkouts of the period and how the carts created in it ended. Open
+// carts count as abandoned once they haven't changed since idleSince.
+func (r *Repository) GetCheckoutStats(period ReportPeriod, idleSince time.Time) (*CheckoutStats, error) {
+	var stats CheckoutStats
+	err := closedIn(r.reader().Table("carts"), period).
+		Select("COUNT(*) AS checked_out, COALESCE(AVG(carts.total), 0) AS average_value").
+		Scan(&stats).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get checkout stats: %w", err)
+	}
+
+	query := r.reader().Table("carts").
+		Select("COUNT(*) AS started, "+
+			"COALESCE(SUM(CASE WHEN carts.status = ? THEN 1 ELSE 0 END), 0) AS converted, "+
+			"COALESCE(SUM(CASE WHEN carts.status = ? AND carts.updated_at < ? THEN 1 ELSE 0 END), 0) AS abandoned",
+			cartpkg.StatusClosed, cartpkg.StatusOpen, idleSince).
+		Where("carts.deleted_at IS NULL").
+		Where("EXISTS (SELECT 1 FROM cart_items WHERE cart_items.cart_id = carts.id AND cart_items.deleted_at IS NULL)")
+	if !period.From.IsZero() {
+		query = query.Where("carts.created_at >= ?", period.From)
+	}
+	if !period.To.IsZero() {
+		query = query.Where("carts.created_at < ?", period.To)
+	}
+	var ended struct{ Started, Converted, Abandoned int64 }
+	if err := query.Scan(&ended).Error; err != nil {
+		return nil, fmt.Errorf("failed to get abandonment stats: %w", err)
+	}
+	stats.Started, stats.Converted, stats.Abandoned = ended.Started, ended.Converted, ended.Abandoned
+	return &stats, nil
+}
@@ -0,0 +1,68 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// sessionTable is the table the session store keeps sessions in
+	sessionTable = "sessions"
+	// sessionCleanupLock is the advisory lock held by the instance sweeping expired sessions
+	sessionCleanupLock = "session_cleanup"
+)
+
+// SweepExpiredSessions deletes the sessions that expired at now, batchSize at a time so the table isn't
+// locked for long, and returns how many were deleted. On MySQL only one instance sweeps at a time: the
+// others skip their turn while the advisory lock is held.
+func (r *Repository) SweepExpiredSessions(ctx context.Context, now time.Time, batchSize int) (int64, error) {
+	var deleted int64
+	err := r.withAdvisoryLock(ctx, sessionCleanupLock, func(db *gorm.DB) error {
+		for ctx.Err() == nil {
+			var ids []string
+			err := db.Table(sessionTable).Where("expires_at <= ?", now).Order("expires_at").Limit(batchSize).Pluck("id", &ids).Error
+			if err != nil {
+				return fmt.Errorf("failed to find expired sessions: %w", err)
+			}
+			if len(ids) == 0 {
+				return nil
+			}
+			result := db.Table(sessionTable).Where("id IN ?", ids).Delete(nil)
+			if result.Error != nil {
+				return fmt.Errorf("failed to delete expired sessions: %w", result.Error)
+			}
+			deleted += result.RowsAffected
+			if len(ids) < batchSize {
+				return nil
+			}
+		}
+		return ctx.Err()
+	})
+	return deleted, err
+}
+
+// withAdvisoryLock runs fn on a connection holding the named MySQL advisory lock, skipping it when another
+// connection holds the lock. Other databases serve a single instance and run fn right away.
+func (r *Repository) withAdvisoryLock(ctx context.Context, name string, fn func(db *gorm.DB) error) error {
+	db := r.db.WithContext(ctx)
+	if db.Dialector.Name() != "mysql" {
+		return fn(db)
+	}
+	// Advisory locks belong to a connection, so the queries run on the one taking the lock
+	return db.Connection(func(conn *gorm.DB) error {
+		var acquired sql.NullInt64
+		if err := conn.Raw("SELECT GET_LOCK(?, 0)", name).Scan(&acquired).Error; err != nil {
+			return fmt.Errorf("failed to take lock %s: %w", name, err)
+		}
+		if acquired.Int64 != 1 {
+			return nil
+		}
+		// Released even when ctx is done, or the pooled connection would keep holding the lock
+		defer conn.WithContext(context.WithoutCancel(ctx)).Exec("SELECT RELEASE_LOCK(?)", name)
+		return fn(conn)
+	})
+}
@@ -0,0 +1,62 @@
+package repo_test
+
+import (
+	"context"
+	"fmt"
+	"interview/internal/repo"
+	"testing"
+	"time"
+
+	gormSessions "github.com/gin-contrib/sessions/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestSweepExpiredSessions(t *testing.T) {
+	setup := func(t *testing.T, expired, live int) (*gorm.DB, *repo.Repository, time.Time) {
+		t.Helper()
+		db := setupTestDB(t)
+		// The session store creates its table
+		gormSessions.NewStore(db, false, []byte("secret"))
+		now := time.Now()
+		for i := 0; i < expired+live; i++ {
+			expiresAt := now.Add(-time.Duration(i+1) * time.Minute)
+			if i >= expired {
+				expiresAt = now.Add(time.Hour)
+			}
+			err := db.Table("sessions").Create(map[string]interface{}{
+				"id": fmt.Sprintf("session-%d", i), "data": "", "created_at": now, "updated_at": now, "expires_at": expiresAt,
+			}).Error
+			require.NoError(t, err)
+		}
+		return db, repo.NewRepository(db), now
+	}
+	remaining := func(t *testing.T, db *gorm.DB) int64 {
+		t.Helper()
+		var count int64
+		require.NoError(t, db.Table("sessions").Count(&count).Error)
+		return count
+	}
+
+	t.Run("deletes expired sessions in batches", func(t *testing.T) {
+		db, cartRepo, now := setup(t, 5, 2)
+		deleted, err := cartRepo.SweepExpiredSessions(context.Background(), now, 2)
+		require.NoError(t, err)
+		assert.Equal(t, int64(5), deleted)
+		assert.Equal(t, int64(2), remaining(t, db), "live sessions are kept")
+
+		deleted, err = cartRepo.SweepExpiredSessions(context.Background(), now, 2)
+		require.NoError(t, err)
+		assert.Zero(t, deleted)
+	})
+
+	t.Run("stops between batches when cancelled", func(t *testing.T) {
+		db, cartRepo, now := setup(t, 3, 0)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := cartRepo.SweepExpiredSessions(ctx, now, 1)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, int64(3), remaining(t, db))
+	})
+}
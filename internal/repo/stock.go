@@ -0,0 +1,207 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	cartpkg "interview/internal/cart"
+	productpkg "interview/internal/product"
+	"interview/internal/warehouse"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RestockedSubscription is a subscription whose product is back in stock
+type RestockedSubscription struct {
+	Subscription productpkg.StockSubscription
+	Product      productpkg.Product
+}
+
+// SetProductStock sets the units left of the product, nil to stop tracking its stock. Products kept in
+// warehouses fail with warehouse.ErrStockInWarehouses, their stock is set per warehouse.
+func (r *Repository) SetProductStock(id uint, stock *int) error {
+	var kept int64
+	if err := r.db.Model(&warehouse.Stock{}).Where("product_id = ?", id).Count(&kept).Error; err != nil {
+		return fmt.Errorf("failed to check warehouse stock: %w", err)
+	}
+	if kept > 0 {
+		return warehouse.ErrStockInWarehouses
+	}
+	result := r.db.Model(&productpkg.Product{}).Where("id = ?", id).Update("stock", stock)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update stock: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("failed to update stock: %w", gorm.ErrRecordNotFound)
+	}
+	r.invalidateProduct(id)
+	return nil
+}
+
+// CheckStock returns productpkg.ErrOutOfStock when fewer than quantity units of the named product are
+// left. Products that aren't in the catalog or whose stock isn't tracked are always available.
+func (r *Repository) CheckStock(name string, quantity int) error {
+	var p productpkg.Product
+	err := r.db.Where("name = ?", name).First(&p).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to check stock: %w", err)
+	}
+	if p.Stock != nil && *p.Stock < quantity {
+		return productpkg.ErrOutOfStock
+	}
+	return nil
+}
+
+// consumeStock takes the items of the cart checked out from the stock of their products, which never
+// drops below 0. Items of products kept in warehouses are allocated to the warehouses they ship from.
+func (r *Repository) consumeStock(db *gorm.DB, cartID uint) error {
+	var items []cartpkg.CartItem
+	if err := db.Where("cart_id = ?", cartID).Find(&items).Error; err != nil {
+		return fmt.Errorf("failed to get items: %w", err)
+	}
+	country, err := shippingCountry(db, cartID)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		allocated, err := r.allocateItem(db, item, country)
+		if err != nil {
+			return err
+		}
+		if allocated {
+			continue
+		}
+		err = db.Model(&productpkg.Product{}).
+			Where("name = ? AND stock IS NOT NULL", item.ProductName).
+			Update("stock", gorm.Expr("CASE WHEN stock > ? THEN stock - ? ELSE 0 END", item.Quantity, item.Quantity)).Error
+		if err != nil {
+			return fmt.Errorf("failed to update stock: %w", err)
+		}
+	}
+	return nil
+}
+
+// SubscribeToStock asks for an email to the address once the product is back in stock. Subscribing
+// twice keeps the first subscription.
+func (r *Repository) SubscribeToStock(productID uint, email string) error {
+	sub := productpkg.StockSubscription{ProductID: productID, Email: email}
+	if err := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&sub).Error; err != nil {
+		return fmt.Errorf("failed to subscribe: %w", err)
+	}
+	return nil
+}
+
+// ListRestockedSubscriptions returns up to limit subscriptions whose product is back in stock, oldest
+// first
+func (r *Repository) ListRestockedSubscriptions(limit int) ([]RestockedSubscription, error) {
+	var subs []productpkg.StockSubscription
+	err := r.db.Joins("JOIN products ON products.id = stock_subscriptions.product_id AND products.deleted_at IS NULL").
+		Where("products.stock IS NULL OR products.stock > 0").
+		Order("stock_subscriptions.id").
+		Limit(limit).
+		Find(&subs).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list restocked subscriptions: %w", err)
+	}
+	if len(subs) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]uint, len(subs))
+	for i, sub := range subs {
+		ids[i] = sub.ProductID
+	}
+	var products []productpkg.Product
+	if err := r.db.Where("id IN ?", ids).Find(&products).Error; err != nil {
+		return nil, fmt.Errorf("failed to get products: %w", err)
+	}
+	byID := make(map[uint]productpkg.Product, len(products))
+	for _, p := range products {
+		byID[p.ID] = p
+	}
+
+	restocked := make([]RestockedSubscription, len(subs))
+	for i, sub := range subs {
+		restocked[i] = RestockedSubscription{Subscription: sub, Product: byID[sub.ProductID]}
+	}
+	return restocked, nil
+}
+
+// ClaimStockSubscription deletes the subscription before its email is sent. It returns false when
+// another instance claimed it first.
+func (r *Repository) ClaimStockSubscription(id uint) (bool, error) {
+	result := r.db.Unscoped().Delete(&productpkg.StockSubscription{}, id)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to claim subscription: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// SetLowStockThreshold sets the stock below which staff are alerted about the product, nil to stop
+// alerting. Snoozed alerts are woken up.
+func (r *Repository) SetLowStockThreshold(id uint, threshold *int) error {
+	result := r.db.Model(&productpkg.Product{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"low_stock_threshold": threshold, "low_stock_snoozed_until": nil})
+	if result.Error != nil {
+		return fmt.Errorf("failed to update low-stock threshold: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("failed to update low-stock threshold: %w", gorm.ErrRecordNotFound)
+	}
+	r.invalidateProduct(id)
+	return nil
+}
+
+// SnoozeLowStock silences the low-stock alerts of the product until the time
+func (r *Repository) SnoozeLowStock(id uint, until time.Time) error {
+	result := r.db.Model(&productpkg.Product{}).Where("id = ?", id).Update("low_stock_snoozed_until", until)
+	if result.Error != nil {
+		return fmt.Errorf("failed to snooze low-stock alerts: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("failed to snooze low-stock alerts: %w", gorm.ErrRecordNotFound)
+	}
+	r.invalidateProduct(id)
+	return nil
+}
+
+// ListLowStockProducts returns the products whose stock fell below their low-stock threshold, including
+// snoozed ones
+func (r *Repository) ListLowStockProducts() ([]productpkg.Product, error) {
+	var products []productpkg.Product
+	err := r.db.Where("stock IS NOT NULL AND low_stock_threshold IS NOT NULL AND stock < low_stock_threshold").
+		Order("id").
+		Find(&products).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list low-stock products: %w", err)
+	}
+	return products, nil
+}
+
+// ClaimLowStockAlert snoozes the low-stock alert of the product until the time before it is sent. It
+// returns false when the alert is snoozed at now, e.g. because another instance claimed it first.
+func (r *Repository) ClaimLowStockAlert(id uint, now, until time.Time) (bool, error) {
+	result := r.db.Model(&productpkg.Product{}).
+		Where("id = ? AND (low_stock_snoozed_until IS NULL OR low_stock_snoozed_until <= ?)", id, now).
+		Update("low_stock_snoozed_until", until)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to claim low-stock alert: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// WakeRestockedAlerts wakes up the snoozed low-stock alerts of products back at or above their
+// threshold, so they alert as soon as they run low again
+func (r *Repository) WakeRestockedAlerts() error {
+	err := r.db.Model(&productpkg.Product{}).
+		Where("low_stock_snoozed_until IS NOT NULL").
+		Where("stock IS NULL OR low_stock_threshold IS NULL OR stock >= low_stock_threshold").
+		Update("low_stock_snoozed_until", nil).Error
+	if err != nil {
+		return fmt.Errorf("failed to wake low-stock alerts: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package repo_test
+
+import (
+	cartpkg "interview/internal/cart"
+	productpkg "interview/internal/product"
+	"interview/internal/repo"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductStock(t *testing.T) {
+	db := setupTestDB(t)
+	cartRepo := repo.NewRepository(db)
+	shoe, err := cartRepo.UpsertProduct("shoe", 10)
+	require.NoError(t, err)
+
+	stock := func(t *testing.T) *int {
+		t.Helper()
+		p, err := cartRepo.GetProduct(shoe.ID)
+		require.NoError(t, err)
+		return p.Stock
+	}
+
+	t.Run("untracked stock is always available", func(t *testing.T) {
+		assert.NoError(t, cartRepo.CheckStock("shoe", 1000))
+		assert.NoError(t, cartRepo.CheckStock("unknown", 1))
+	})
+
+	t.Run("checkout consumes stock", func(t *testing.T) {
+		three := 3
+		require.NoError(t, cartRepo.SetProductStock(shoe.ID, &three))
+		assert.NoError(t, cartRepo.CheckStock("shoe", 3))
+		assert.ErrorIs(t, cartRepo.CheckStock("shoe", 4), productpkg.ErrOutOfStock)
+
+		c, err := cartRepo.GetOrCreateCart("stock-session", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 2, 10))
+		require.NoError(t, cartRepo.CloseCart(c.ID))
+		require.NotNil(t, stock(t))
+		assert.Equal(t, 1, *stock(t))
+	})
+
+	t.Run("stock never drops below zero", func(t *testing.T) {
+		c, err := cartRepo.GetOrCreateCart("stock-session-2", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 5, 10))
+		require.NoError(t, cartRepo.CloseCart(c.ID))
+		assert.Equal(t, 0, *stock(t))
+		assert.ErrorIs(t, cartRepo.CheckStock("shoe", 1), productpkg.ErrOutOfStock)
+	})
+
+	t.Run("stops tracking stock", func(t *testing.T) {
+		require.NoError(t, cartRepo.SetProductStock(shoe.ID, nil))
+		assert.Nil(t, stock(t))
+	})
+
+	t.Run("unknown product", func(t *testing.T) {
+		assert.Error(t, cartRepo.SetProductStock(9999, nil))
+	})
+}
+
+func TestStockSubscriptions(t *testing.T) {
+	db := setupTestDB(t)
+	cartRepo := repo.NewRepository(db)
+	shoe, err := cartRepo.UpsertProduct("shoe", 10)
+	require.NoError(t, err)
+	none := 0
+	require.NoError(t, cartRepo.SetProductStock(shoe.ID, &none))
+
+	require.NoError(t, cartRepo.SubscribeToStock(shoe.ID, "jane@example.com"))
+	require.NoError(t, cartRepo.SubscribeToStock(shoe.ID, "jane@example.com"), "subscribing twice is fine")
+
+	restocked, err := cartRepo.ListRestockedSubscriptions(10)
+	require.NoError(t, err)
+	assert.Empty(t, restocked)
+
+	two := 2
+	require.NoError(t, cartRepo.SetProductStock(shoe.ID, &two))
+	restocked, err = cartRepo.ListRestockedSubscriptions(10)
+	require.NoError(t, err)
+	require.Len(t, restocked, 1)
+	assert.Equal(t, "jane@example.com", restocked[0].Subscription.Email)
+	assert.Equal(t, "shoe", restocked[0].Product.Name)
+
+	claimed, err := cartRepo.ClaimStockSubscription(restocked[0].Subscription.ID)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+	claimed, err = cartRepo.ClaimStockSubscription(restocked[0].Subscription.ID)
+	require.NoError(t, err)
+	assert.False(t, claimed)
+}
@@ -0,0 +1,219 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	cartpkg "interview/internal/cart"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SetSubscriptionDiscount sets the discount in percent on the items carts buy with subscribe & save,
+// 0 for none
+func (r *Repository) SetSubscriptionDiscount(percent float64) {
+	r.subscriptionDiscount = percent
+}
+
+// subscriptionSavings returns the subscription discount on the subscribed items, rounded to cents
+func (r *Repository) subscriptionSavings(items []cartpkg.CartItem) float64 {
+	if r.subscriptionDiscount <= 0 {
+		return 0
+	}
+	var subscribed float64
+	for _, item := range items {
+		if item.SubscriptionDays > 0 {
+			subscribed += item.Price * float64(item.Quantity)
+		}
+	}
+	return math.Round(subscribed*r.subscriptionDiscount) / 100
+}
+
+// SetItemSubscription subscribes to the item of the open cart every days once the cart is checked out,
+// or makes it a one-time purchase again for 0 days
+func (r *Repository) SetItemSubscription(cartID uint, itemID uint, days int) error {
+	if !cartpkg.ValidInterval(days) {
+		return cartpkg.ErrInvalidInterval
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		cart, err := openCart(tx, cartID)
+		if err != nil {
+			return err
+		}
+
+		var item cartpkg.CartItem
+		err = tx.Where("cart_id = ? AND id = ?", cartID, itemID).First(&item).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return cartpkg.ErrItemNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to get item: %w", err)
+		}
+		item.SubscriptionDays = days
+		if err := tx.Save(&item).Error; err != nil {
+			return fmt.Errorf("failed to update item: %w", err)
+		}
+
+		return r.updateCartTotal(tx, cart)
+	})
+}
+
+// createSubscriptions subscribes the owner of the cart checked out to its items bought with
+// subscribe & save. The orders placed for subscriptions don't subscribe again.
+func createSubscriptions(tx *gorm.DB, cart *cartpkg.Cart) error {
+	if cart.SubscriptionID != nil {
+		return nil
+	}
+	var items []cartpkg.CartItem
+	if err := tx.Where("cart_id = ? AND subscription_days > 0", cart.ID).Find(&items).Error; err != nil {
+		return fmt.Errorf("failed to get subscribed items: %w", err)
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	subs := make([]cartpkg.Subscription, len(items))
+	for i, item := range items {
+		subs[i] = cartpkg.Subscription{
+			SessionID:    cart.SessionID,
+			UserID:       cart.UserID,
+			CartID:       cart.ID,
+			ProductName:  item.ProductName,
+			Quantity:     item.Quantity,
+			IntervalDays: item.SubscriptionDays,
+			Status:       cartpkg.SubscriptionActive,
+			NextOrderAt:  now.AddDate(0, 0, item.SubscriptionDays),
+		}
+	}
+	if err := tx.Create(&subs).Error; err != nil {
+		return fmt.Errorf("failed to create subscriptions: %w", err)
+	}
+	return nil
+}
+
+// ownedSubscriptions limits a query to the subscriptions of the session or the user, if any
+func ownedSubscriptions(db *gorm.DB, sessionID string, userID *uint) *gorm.DB {
+	if userID != nil {
+		return db.Where("(session_id = ? OR user_id = ?)", sessionID, *userID)
+	}
+	return db.Where("session_id = ?", sessionID)
+}
+
+// ListSubscriptions returns the subscriptions of the session or the user that weren't cancelled, the
+// next order first
+func (r *Repository) ListSubscriptions(sessionID string, userID *uint) ([]cartpkg.Subscription, error) {
+	var subs []cartpkg.Subscription
+	err := ownedSubscriptions(r.db, sessionID, userID).
+		Where("status <> ?", cartpkg.SubscriptionCancelled).
+		Order("next_order_at, id").
+		Find(&subs).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+	return subs, nil
+}
+
+// SetSubscriptionStatus pauses, resumes or cancels a subscription of the session or the user. It fails
+// with ErrSubscriptionNotFound for subscriptions of others and ErrSubscriptionCancelled once cancelled.
+func (r *Repository) SetSubscriptionStatus(id uint, sessionID string, userID *uint, status string) error {
+	if status != cartpkg.SubscriptionActive && status != cartpkg.SubscriptionPaused && status != cartpkg.SubscriptionCancelled {
+		return fmt.Errorf("invalid subscription status %q", status)
+	}
+
+	var sub cartpkg.Subscription
+	err := ownedSubscriptions(r.db, sessionID, userID).Where("id = ?", id).First(&sub).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return cartpkg.ErrSubscriptionNotFound
+	} else if err != nil {
+		return fmt.Errorf("failed to get subscription: %w", err)
+	}
+
+	// The status is only changed while the subscription isn't cancelled, also by a concurrent request
+	result := r.db.Model(&cartpkg.Subscription{}).
+		Where("id = ? AND status <> ?", sub.ID, cartpkg.SubscriptionCancelled).
+		Update("status", status)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update subscription: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return cartpkg.ErrSubscriptionCancelled
+	}
+	return nil
+}
+
+// ListDueSubscriptions returns up to limit active subscriptions whose next order is due at now, the
+// longest due first
+func (r *Repository) ListDueSubscriptions(now time.Time, limit int) ([]cartpkg.Subscription, error) {
+	var subs []cartpkg.Subscription
+	err := r.db.Where("status = ? AND next_order_at <= ?", cartpkg.SubscriptionActive, now).
+		Order("next_order_at, id").
+		Limit(limit).
+		Find(&subs).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due subscriptions: %w", err)
+	}
+	return subs, nil
+}
+
+// PlaceSubscriptionOrder orders the item of a due subscription at price: it checks out a new cart for
+// the owner of the subscription and schedules the next order. It returns nil when the order was placed
+// concurrently or the subscription changed since it was listed.
+func (r *Repository) PlaceSubscriptionOrder(sub cartpkg.Subscription, price float64, now time.Time) (*cartpkg.Cart, error) {
+	next := sub.NextOrderAt.AddDate(0, 0, sub.IntervalDays)
+	if !next.After(now) {
+		// Orders missed while the subscription was paused or the job didn't run aren't made up for
+		next = now.AddDate(0, 0, sub.IntervalDays)
+	}
+
+	var order *cartpkg.Cart
+	err := r.Transaction(func(tx *Repository) error {
+		// Orders is only incremented here, so it tells whether the order was already placed
+		result := tx.db.Model(&cartpkg.Subscription{}).
+			Where("id = ? AND status = ? AND orders = ?", sub.ID, cartpkg.SubscriptionActive, sub.Orders).
+			Updates(map[string]interface{}{
+				"next_order_at": next,
+				"orders":        gorm.Expr("orders + 1"),
+			})
+		if result.Error != nil {
+			return fmt.Errorf("failed to update subscription: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		cart := cartpkg.Cart{
+			SessionID:      sub.SessionID,
+			Name:           fmt.Sprintf("subscription %d order %d", sub.ID, sub.Orders+1),
+			UserID:         sub.UserID,
+			Status:         cartpkg.StatusOpen,
+			SubscriptionID: &sub.ID,
+		}
+		if err := tx.db.Create(&cart).Error; err != nil {
+			return fmt.Errorf("failed to create order: %w", err)
+		}
+		item := cartpkg.CartItem{
+			CartID:           cart.ID,
+			ProductName:      sub.ProductName,
+			Quantity:         sub.Quantity,
+			Price:            price,
+			SubscriptionDays: sub.IntervalDays,
+		}
+		if err := tx.db.Create(&item).Error; err != nil {
+			return fmt.Errorf("failed to create item: %w", err)
+		}
+		if err := tx.updateCartTotal(tx.db, &cart); err != nil {
+			return err
+		}
+		if err := tx.CloseCart(cart.ID); err != nil {
+			return err
+		}
+		order = &cart
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
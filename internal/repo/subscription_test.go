@@ -0,0 +1,162 @@
+package repo_test
+
+import (
+	cartpkg "interview/internal/cart"
+	"interview/internal/repo"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscriptions(t *testing.T) {
+	db := setupTestDB(t)
+	cartRepo := repo.NewRepository(db)
+	cartRepo.SetSubscriptionDiscount(10)
+
+	// checkout closes a cart of the session with a shoe subscribed to every week and a one-time bag
+	checkout := func(t *testing.T, sessionID string) *cartpkg.Cart {
+		t.Helper()
+		c, err := cartRepo.GetOrCreateCart(sessionID, cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 2, 10))
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "bag", 1, 30))
+		c, err = cartRepo.GetOrCreateCart(sessionID, cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.SetItemSubscription(c.ID, c.CartItems[0].ID, 7))
+		require.NoError(t, cartRepo.CloseCart(c.ID))
+		return c
+	}
+
+	t.Run("subscribed items are discounted", func(t *testing.T) {
+		c, err := cartRepo.GetOrCreateCart("discount-session", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 2, 10))
+		c, err = cartRepo.GetOrCreateCart("discount-session", cartpkg.DefaultName)
+		require.NoError(t, err)
+		itemID := c.CartItems[0].ID
+
+		assert.ErrorIs(t, cartRepo.SetItemSubscription(c.ID, itemID, 3), cartpkg.ErrInvalidInterval)
+		assert.ErrorIs(t, cartRepo.SetItemSubscription(c.ID, 9999, 7), cartpkg.ErrItemNotFound)
+		require.NoError(t, cartRepo.SetItemSubscription(c.ID, itemID, 7))
+
+		c, err = cartRepo.GetCart(c.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 7, c.CartItems[0].SubscriptionDays)
+		require.Len(t, c.Discounts, 1)
+		assert.Equal(t, cartpkg.SubscriptionPromotion, c.Discounts[0].Promotion)
+		assert.Equal(t, 2.0, c.Discounts[0].Amount)
+		assert.Equal(t, 18.0, c.Total)
+
+		require.NoError(t, cartRepo.SetItemSubscription(c.ID, itemID, 0))
+		c, err = cartRepo.GetCart(c.ID)
+		require.NoError(t, err)
+		assert.Empty(t, c.Discounts)
+		assert.Equal(t, 20.0, c.Total)
+	})
+
+	t.Run("checkout subscribes to subscribed items", func(t *testing.T) {
+		c := checkout(t, "checkout-session")
+
+		subs, err := cartRepo.ListSubscriptions("checkout-session", nil)
+		require.NoError(t, err)
+		require.Len(t, subs, 1)
+		sub := subs[0]
+		assert.Equal(t, c.ID, sub.CartID)
+		assert.Equal(t, "shoe", sub.ProductName)
+		assert.Equal(t, 2, sub.Quantity)
+		assert.Equal(t, 7, sub.IntervalDays)
+		assert.Equal(t, cartpkg.SubscriptionActive, sub.Status)
+		assert.WithinDuration(t, time.Now().AddDate(0, 0, 7), sub.NextOrderAt, time.Minute)
+
+		subs, err = cartRepo.ListSubscriptions("other-session", nil)
+		require.NoError(t, err)
+		assert.Empty(t, subs)
+	})
+
+	t.Run("due subscriptions are ordered once", func(t *testing.T) {
+		checkout(t, "order-session")
+		subs, err := cartRepo.ListSubscriptions("order-session", nil)
+		require.NoError(t, err)
+		require.Len(t, subs, 1)
+
+		due, err := cartRepo.ListDueSubscriptions(time.Now(), 10)
+		require.NoError(t, err)
+		assert.Empty(t, due)
+
+		now := time.Now().AddDate(0, 0, 8)
+		due, err = cartRepo.ListDueSubscriptions(now, 10)
+		require.NoError(t, err)
+		require.Len(t, due, 2)
+		var sub cartpkg.Subscription
+		for _, d := range due {
+			if d.ID == subs[0].ID {
+				sub = d
+			}
+		}
+
+		order, err := cartRepo.PlaceSubscriptionOrder(sub, 12, now)
+		require.NoError(t, err)
+		require.NotNil(t, order)
+		order, err = cartRepo.GetCart(order.ID)
+		require.NoError(t, err)
+		assert.Equal(t, cartpkg.StatusClosed, order.Status)
+		assert.Equal(t, "order-session", order.SessionID)
+		require.NotNil(t, order.SubscriptionID)
+		assert.Equal(t, sub.ID, *order.SubscriptionID)
+		require.Len(t, order.CartItems, 1)
+		assert.Equal(t, "shoe", order.CartItems[0].ProductName)
+		assert.Equal(t, 2, order.CartItems[0].Quantity)
+		assert.Equal(t, 12.0, order.CartItems[0].Price)
+		assert.Equal(t, 21.6, order.Total)
+
+		// The subscription listed before the order is outdated
+		order, err = cartRepo.PlaceSubscriptionOrder(sub, 12, now)
+		require.NoError(t, err)
+		assert.Nil(t, order)
+
+		subs, err = cartRepo.ListSubscriptions("order-session", nil)
+		require.NoError(t, err)
+		require.Len(t, subs, 1, "orders don't subscribe again")
+		assert.Equal(t, 1, subs[0].Orders)
+		assert.WithinDuration(t, sub.NextOrderAt.AddDate(0, 0, 7), subs[0].NextOrderAt, time.Second)
+	})
+
+	t.Run("subscriptions can be paused, resumed and cancelled", func(t *testing.T) {
+		user, err := cartRepo.FindOrCreateUser("github", "1", "jane@example.com", "Jane")
+		require.NoError(t, err)
+		c, err := cartRepo.GetOrCreateCart("user-session", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 1, 10))
+		c, err = cartRepo.GetOrCreateCart("user-session", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.SetItemSubscription(c.ID, c.CartItems[0].ID, 30))
+		require.NoError(t, cartRepo.AssignCartToUser("user-session", user.ID))
+		require.NoError(t, cartRepo.CloseCart(c.ID))
+
+		// The user sees the subscription from any session
+		subs, err := cartRepo.ListSubscriptions("new-session", &user.ID)
+		require.NoError(t, err)
+		require.Len(t, subs, 1)
+		id := subs[0].ID
+
+		assert.ErrorIs(t, cartRepo.SetSubscriptionStatus(id, "new-session", nil, cartpkg.SubscriptionPaused),
+			cartpkg.ErrSubscriptionNotFound)
+		require.NoError(t, cartRepo.SetSubscriptionStatus(id, "new-session", &user.ID, cartpkg.SubscriptionPaused))
+		due, err := cartRepo.ListDueSubscriptions(time.Now().AddDate(1, 0, 0), 10)
+		require.NoError(t, err)
+		for _, d := range due {
+			assert.NotEqual(t, id, d.ID, "paused subscriptions aren't due")
+		}
+
+		require.NoError(t, cartRepo.SetSubscriptionStatus(id, "user-session", nil, cartpkg.SubscriptionActive))
+		require.NoError(t, cartRepo.SetSubscriptionStatus(id, "user-session", nil, cartpkg.SubscriptionCancelled))
+		assert.ErrorIs(t, cartRepo.SetSubscriptionStatus(id, "user-session", nil, cartpkg.SubscriptionActive),
+			cartpkg.ErrSubscriptionCancelled)
+
+		subs, err = cartRepo.ListSubscriptions("user-session", &user.ID)
+		require.NoError(t, err)
+		assert.Empty(t, subs)
+	})
+}
@@ -0,0 +1,108 @@
+package repo
+
+import (
+	"context"
+	"interview/internal/tenant"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// TenantScope is a gorm.Plugin keeping the shops of a deployment apart. Statements on models with a
+// TenantID field, e.g. products, carts, orders and price lists, are limited to the tenant of their
+// context: queries, updates and deletes only see its rows and created rows are assigned to it.
+// Statements whose context has no tenant, e.g. those of background jobs and single-shop deployments,
+// see the rows of every shop.
+type TenantScope struct{}
+
+var _ gorm.Plugin = TenantScope{}
+
+// Name implements gorm.Plugin.
+func (TenantScope) Name() string {
+	return "tenant_scope"
+}
+
+// Initialize implements gorm.Plugin, registering the callbacks scoping the statements of every kind.
+func (TenantScope) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	if err := callbacks.Create().Before("gorm:create").Register("tenant:assign", assignTenant); err != nil {
+		return err
+	}
+	if err := callbacks.Query().Before("gorm:query").Register("tenant:scope_query", scopeTenant); err != nil {
+		return err
+	}
+	if err := callbacks.Update().Before("gorm:update").Register("tenant:scope_update", scopeTenant); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().Before("gorm:delete").Register("tenant:scope_delete", scopeTenant); err != nil {
+		return err
+	}
+	return callbacks.Row().Before("gorm:row").Register("tenant:scope_row", scopeTenant)
+}
+
+// tenantField returns the tenant of the statement and the TenantID field of its model, nil when the
+// statement isn't scoped
+func tenantField(db *gorm.DB) (string, *schema.Field) {
+	id, ok := tenant.FromContext(db.Statement.Context)
+	if !ok || db.Statement.Schema == nil {
+		return "", nil
+	}
+	return id, db.Statement.Schema.LookUpField("TenantID")
+}
+
+// scopeTenant limits the statement to the rows of its tenant
+func scopeTenant(db *gorm.DB) {
+	id, field := tenantField(db)
+	if field == nil {
+		return
+	}
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: id},
+	}})
+}
+
+// assignTenant assigns the created rows without a tenant to the tenant of the statement
+func assignTenant(db *gorm.DB) {
+	id, field := tenantField(db)
+	if field == nil {
+		return
+	}
+	assign := func(row reflect.Value) {
+		if _, zero := field.ValueOf(db.Statement.Context, row); zero {
+			if err := field.Set(db.Statement.Context, row, id); err != nil {
+				_ = db.AddError(err)
+			}
+		}
+	}
+	switch rows := db.Statement.ReflectValue; rows.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rows.Len(); i++ {
+			assign(reflect.Indirect(rows.Index(i)))
+		}
+	case reflect.Struct:
+		assign(rows)
+	}
+}
+
+// scopeJoined limits a query on tables without a TenantID, which TenantScope can't scope, to the rows
+// joined with those of the tenant of its context in table
+func scopeJoined(db *gorm.DB, table string) *gorm.DB {
+	if id, ok := tenant.FromContext(db.Statement.Context); ok {
+		return db.Where(table+".tenant_id = ?", id)
+	}
+	return db
+}
+
+// inShop returns db limited to the tenant, for statements about the rows of a known shop whose context
+// may have none, e.g. those of background jobs and payment webhooks
+func inShop(db *gorm.DB, id string) *gorm.DB {
+	return db.WithContext(tenant.NewContext(db.Statement.Context, id))
+}
+
+// inTenant reports whether a row of the tenant is visible to statements with the context
+func inTenant(ctx context.Context, id string) bool {
+	current, ok := tenant.FromContext(ctx)
+	return !ok || current == id
+}
@@ -0,0 +1,165 @@
+package repo_test
+
+import (
+	"context"
+	"interview/internal/cache"
+	cartpkg "interview/internal/cart"
+	"interview/internal/order"
+	"interview/internal/payment"
+	"interview/internal/pricelist"
+	"interview/internal/promotion"
+	"interview/internal/repo"
+	"interview/internal/tenant"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenantScope(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, db.Use(repo.TenantScope{}))
+	shared := repo.NewRepository(db)
+	shared.SetProductCache(cache.NewMemory(), time.Minute)
+	acme := shared.WithContext(tenant.NewContext(context.Background(), "acme"))
+	globex := shared.WithContext(tenant.NewContext(context.Background(), "globex"))
+
+	acmeShoe, err := acme.UpsertProduct("shoe", 10)
+	require.NoError(t, err)
+	globexShoe, err := globex.UpsertProduct("shoe", 20)
+	require.NoError(t, err)
+
+	t.Run("products are created in the shop of the context", func(t *testing.T) {
+		assert.NotEqual(t, acmeShoe.ID, globexShoe.ID, "product names are only unique within a shop")
+		assert.Equal(t, "acme", acmeShoe.TenantID)
+		assert.Equal(t, "globex", globexShoe.TenantID)
+
+		_, err := acme.UpsertProduct("shoe", 11)
+		require.NoError(t, err)
+		p, err := globex.GetProduct(globexShoe.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 20.0, p.Price, "updates are limited to the shop")
+	})
+
+	t.Run("lookups only see the products of the shop", func(t *testing.T) {
+		products, err := acme.ListProducts()
+		require.NoError(t, err)
+		require.Len(t, products, 1)
+		assert.Equal(t, acmeShoe.ID, products[0].ID)
+
+		_, err = acme.GetProduct(globexShoe.ID)
+		assert.Error(t, err)
+		_, err = acme.GetCachedProduct(globexShoe.ID)
+		assert.Error(t, err)
+
+		// Cached by the shop selling it, the product still isn't served to the other
+		_, err = globex.GetCachedProduct(globexShoe.ID)
+		require.NoError(t, err)
+		_, err = acme.GetCachedProduct(globexShoe.ID)
+		assert.Error(t, err)
+	})
+
+	t.Run("carts are kept apart", func(t *testing.T) {
+		acmeCart, err := acme.GetOrCreateCart("session", cartpkg.DefaultName)
+		require.NoError(t, err)
+		assert.Equal(t, "acme", acmeCart.TenantID)
+
+		globexCart, err := globex.GetOrCreateCart("session", "work")
+		require.NoError(t, err)
+		carts, err := globex.ListCarts("session")
+		require.NoError(t, err)
+		require.Len(t, carts, 1)
+		assert.Equal(t, globexCart.ID, carts[0].ID)
+	})
+
+	t.Run("promotions apply to the carts of their shop", func(t *testing.T) {
+		require.NoError(t, acme.CreatePromotion(&promotion.Promotion{
+			Name: "half off", Type: promotion.TypeThreshold, Active: true, PercentOff: 50,
+		}))
+		acmeCart, err := acme.GetOrCreateCart("promotion-session", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, acme.AddCartItem(acmeCart.ID, "shoe", 1, 10))
+		globexCart, err := globex.GetOrCreateCart("promotion-session", "work")
+		require.NoError(t, err)
+		require.NoError(t, globex.AddCartItem(globexCart.ID, "shoe", 1, 20))
+
+		// Carts changed without a shop, e.g. by background jobs, get the promotions of theirs too
+		require.NoError(t, shared.AddCartItem(globexCart.ID, "shoe", 1, 20))
+		discounted, err := shared.GetCart(acmeCart.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 5.0, discounted.DiscountTotal)
+		full, err := shared.GetCart(globexCart.ID)
+		require.NoError(t, err)
+		assert.Zero(t, full.DiscountTotal)
+
+		promotions, err := globex.ListPromotions()
+		require.NoError(t, err)
+		assert.Empty(t, promotions)
+	})
+
+	t.Run("prices are those of the shop", func(t *testing.T) {
+		acmeList := pricelist.PriceList{Name: "Retail", CustomerGroup: pricelist.GroupRetail}
+		require.NoError(t, acme.CreatePriceList(&acmeList))
+		globexList := pricelist.PriceList{Name: "Retail", CustomerGroup: pricelist.GroupRetail}
+		require.NoError(t, globex.CreatePriceList(&globexList), "each shop has a list for each group")
+		assert.ErrorIs(t, globex.CreatePriceList(&pricelist.PriceList{Name: "Again", CustomerGroup: pricelist.GroupRetail}),
+			pricelist.ErrGroupTaken)
+		require.NoError(t, acme.SetListPrice(acmeList.ID, "shoe", 8))
+
+		now := time.Now()
+		require.NoError(t, globex.CreatePriceOverride(&pricelist.PriceOverride{
+			Product: "shoe", Price: 15, StartsAt: now.Add(-time.Hour), EndsAt: now.Add(time.Hour),
+		}))
+		price, _, err := acme.CatalogPrice(nil, "shoe", now)
+		require.NoError(t, err)
+		assert.Equal(t, 8.0, price)
+		price, _, err = globex.CatalogPrice(nil, "shoe", now)
+		require.NoError(t, err)
+		assert.Equal(t, 15.0, price)
+
+		overrides, err := acme.ActivePriceOverrides([]string{"shoe"}, now)
+		require.NoError(t, err)
+		assert.Empty(t, overrides)
+	})
+
+	t.Run("orders, payments and gift cards are kept apart", func(t *testing.T) {
+		acmeCart, err := acme.GetOrCreateCart("order-session", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, acme.AddCartItem(acmeCart.ID, "shoe", 1, 10))
+		p := payment.Payment{TenantID: acmeCart.TenantID, CartID: acmeCart.ID, Provider: "paypal", ExternalID: "ACME-1", Status: payment.StatusPending}
+		require.NoError(t, acme.CreatePayment(&p))
+		// Payment webhooks close carts without a shop
+		require.NoError(t, shared.CloseCart(acmeCart.ID))
+
+		o, err := acme.GetOrderByCart(acmeCart.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "acme", o.TenantID)
+		_, err = globex.GetOrder(o.ID)
+		assert.ErrorIs(t, err, order.ErrOrderNotFound)
+		orders, err := globex.ListOrders("", 0, 100)
+		require.NoError(t, err)
+		assert.Empty(t, orders)
+		_, err = globex.GetPaymentByID(p.ID)
+		assert.ErrorIs(t, err, payment.ErrPaymentNotFound)
+
+		_, err = acme.CreateGiftCard("ACME-GIFT", 10)
+		require.NoError(t, err)
+		_, err = globex.GetGiftCard("ACME-GIFT")
+		assert.Error(t, err)
+	})
+
+	t.Run("statements without a shop see every shop", func(t *testing.T) {
+		products, err := shared.ListProducts()
+		require.NoError(t, err)
+		assert.Len(t, products, 2)
+
+		carts, err := shared.ListCarts("session")
+		require.NoError(t, err)
+		assert.Len(t, carts, 2)
+
+		p, err := shared.GetProduct(acmeShoe.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "acme", p.TenantID)
+	})
+}
@@ -0,0 +1,94 @@
+package repo_test
+
+import (
+	cartpkg "interview/internal/cart"
+	"interview/internal/promotion"
+	"interview/internal/repo"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCartTotals(t *testing.T) {
+	db := setupTestDB(t)
+	cartRepo := repo.NewRepository(db)
+	require.NoError(t, cartRepo.CreatePromotion(&promotion.Promotion{
+		Name: "10% off over 100", Type: promotion.TypeThreshold, Active: true,
+		MinSubtotal: 100, PercentOff: 10,
+	}))
+
+	tests := []struct {
+		name     string
+		charges  cartpkg.Charges
+		price    float64
+		quantity int
+		want     cartpkg.Totals
+	}{
+		{
+			name: "no charges", price: 40, quantity: 1,
+			want: cartpkg.Totals{Subtotal: 40, Total: 40},
+		},
+		{
+			name: "tax on discounted subtotal", charges: cartpkg.Charges{TaxRate: 19}, price: 50, quantity: 3,
+			want: cartpkg.Totals{Subtotal: 150, Discount: 15, Tax: 25.65, Total: 160.65},
+		},
+		{
+			name: "shipping below free shipping", charges: cartpkg.Charges{Shipping: 4.95, FreeShippingFrom: 50}, price: 20, quantity: 2,
+			want: cartpkg.Totals{Subtotal: 40, Shipping: 4.95, Total: 44.95},
+		},
+		{
+			name: "free shipping", charges: cartpkg.Charges{Shipping: 4.95, FreeShippingFrom: 50}, price: 25, quantity: 2,
+			want: cartpkg.Totals{Subtotal: 50, Total: 50},
+		},
+		{
+			name: "tax and shipping", charges: cartpkg.Charges{TaxRate: 7, Shipping: 5}, price: 9.99, quantity: 1,
+			want: cartpkg.Totals{Subtotal: 9.99, Tax: 0.7, Shipping: 5, Total: 15.69},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cartRepo.SetCharges(tt.charges)
+			c, err := cartRepo.GetOrCreateCart("totals-"+tt.name, cartpkg.DefaultName)
+			require.NoError(t, err)
+			require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", tt.quantity, tt.price))
+
+			c, err = cartRepo.GetOrCreateCart("totals-"+tt.name, cartpkg.DefaultName)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, cartpkg.Totals{
+				Subtotal: c.Subtotal, Discount: c.DiscountTotal, Tax: c.Tax, Shipping: c.Shipping, Total: c.Total,
+			})
+		})
+	}
+
+	t.Run("empty cart has no shipping", func(t *testing.T) {
+		cartRepo.SetCharges(cartpkg.Charges{TaxRate: 19, Shipping: 4.95})
+		c, err := cartRepo.GetOrCreateCart("totals-empty", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 1, 10))
+		c, err = cartRepo.GetOrCreateCart("totals-empty", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.RemoveCartItem(c.ID, c.CartItems[0].ID))
+
+		c, err = cartRepo.GetOrCreateCart("totals-empty", cartpkg.DefaultName)
+		require.NoError(t, err)
+		assert.Zero(t, c.Subtotal)
+		assert.Zero(t, c.Tax)
+		assert.Zero(t, c.Shipping)
+		assert.Zero(t, c.Total)
+	})
+
+	t.Run("charges apply in transactions", func(t *testing.T) {
+		cartRepo.SetCharges(cartpkg.Charges{TaxRate: 10, Shipping: 5})
+		c, err := cartRepo.GetOrCreateCart("totals-transaction", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.Transaction(func(tx *repo.Repository) error {
+			return tx.AddCartItem(c.ID, "shoe", 1, 10)
+		}))
+
+		c, err = cartRepo.GetOrCreateCart("totals-transaction", cartpkg.DefaultName)
+		require.NoError(t, err)
+		assert.Equal(t, 16.0, c.Total)
+	})
+}
@@ -0,0 +1,341 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	cartpkg "interview/internal/cart"
+	"interview/internal/giftcard"
+	"interview/internal/pricelist"
+	userpkg "interview/internal/user"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrUserNotFound is returned when no user has the given ID
+	ErrUserNotFound = errors.New("user not found")
+	// ErrTOTPEnabled is returned when enrolling a user who already has two-factor authentication
+	ErrTOTPEnabled = errors.New("two-factor authentication is already enabled")
+	// ErrTOTPNotEnrolled is returned when confirming two-factor authentication before enrolling
+	ErrTOTPNotEnrolled = errors.New("two-factor authentication enrollment was not started")
+	// ErrRecoveryCodeInvalid is returned for unknown or already used recovery codes
+	ErrRecoveryCodeInvalid = errors.New("invalid recovery code")
+	// ErrEmailTaken is returned when changing the email address to the address of another user
+	ErrEmailTaken = errors.New("email address is already used by another account")
+)
+
+// FindOrCreateUser returns the user linked to the given provider identity, creating it on first login.
+// Email and name are refreshed from the provider on every login.
+func (r *Repository) FindOrCreateUser(provider, providerUserID, email, name string) (*userpkg.User, error) {
+	var u userpkg.User
+	err := r.db.Where("provider = ? AND provider_user_id = ?", provider, providerUserID).First(&u).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		u = userpkg.User{
+			Provider:       provider,
+			ProviderUserID: providerUserID,
+			Email:          email,
+			Name:           name,
+		}
+		if err := r.db.Create(&u).Error; err != nil {
+			return nil, fmt.Errorf("failed to create user: %w", err)
+		}
+		return &u, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if u.Email != email || u.Name != name {
+		if !strings.EqualFold(u.Email, email) {
+			// The new address of the provider isn't verified yet
+			u.EmailVerifiedAt, u.VerificationSentAt = nil, nil
+		}
+		u.Email = email
+		u.Name = name
+		if err := r.db.Save(&u).Error; err != nil {
+			return nil, fmt.Errorf("failed to update user: %w", err)
+		}
+	}
+
+	return &u, nil
+}
+
+// FindOrCreateUserByEmail returns the user with the given email address, creating an account of the
+// provider identified by the address when there is none
+func (r *Repository) FindOrCreateUserByEmail(provider, email string) (*userpkg.User, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	u, err := r.FindUserByEmail(email)
+	if !errors.Is(err, ErrUserNotFound) {
+		return u, err
+	}
+	return r.FindOrCreateUser(provider, email, email, "")
+}
+
+// GetUser returns the user with the given ID
+func (r *Repository) GetUser(userID uint) (*userpkg.User, error) {
+	var u userpkg.User
+	if err := r.db.First(&u, userID).Error; err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// AssignCartToUser links the open cart of the given session to a user
+func (r *Repository) AssignCartToUser(sessionID string, userID uint) error {
+	return r.db.Model(&cartpkg.Cart{}).
+		Where("session_id = ? AND status = ?", sessionID, cartpkg.StatusOpen).
+		Update("user_id", userID).Error
+}
+
+// MergeAnonymousCart moves the items, redeemed gift card credit, referral code and metadata of the open
+// anonymous cart fromCartID into the open cart intoCartID and deletes it. Items of products already in the
+// cart add to their quantity, bundles move as they are. It fails with ErrCartNotFound when fromCartID isn't an open anonymous cart and with
+// ErrCartLocked while either cart is being checked out.
+func (r *Repository) MergeAnonymousCart(fromCartID, intoCartID uint) error {
+	if fromCartID == intoCartID {
+		return nil
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var from cartpkg.Cart
+		err := tx.Preload("CartItems", orderItems).
+			Where("id = ? AND user_id IS NULL AND status = ?", fromCartID, cartpkg.StatusOpen).
+			First(&from).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return cartpkg.ErrCartNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to get cart: %w", err)
+		}
+		if err := checkCartLock(tx, from.ID); err != nil {
+			return err
+		}
+		into, err := openCart(tx, intoCartID)
+		if err != nil {
+			return err
+		}
+
+		// Moved items go last, in the order they had
+		position, err := nextPosition(tx, into.ID)
+		if err != nil {
+			return err
+		}
+		for _, item := range from.CartItems {
+			var existing cartpkg.CartItem
+			err := gorm.ErrRecordNotFound
+			if item.BundleGroup == 0 {
+				err = tx.Where("cart_id = ? AND product_name = ? AND bundle_group = 0", into.ID, item.ProductName).First(&existing).Error
+			}
+			if err == nil {
+				existing.Quantity += item.Quantity
+				if err := tx.Save(&existing).Error; err != nil {
+					return fmt.Errorf("failed to update item: %w", err)
+				}
+			} else if errors.Is(err, gorm.ErrRecordNotFound) {
+				moved := cartpkg.CartItem{
+					CartID: into.ID, ProductName: item.ProductName, Quantity: item.Quantity, Price: item.Price, Metadata: item.Metadata,
+					BundleGroup: item.BundleGroup, BundleName: item.BundleName, BundleQuantity: item.BundleQuantity,
+					Position: position + item.Position,
+				}
+				if err := tx.Create(&moved).Error; err != nil {
+					return fmt.Errorf("failed to move item: %w", err)
+				}
+			} else {
+				return fmt.Errorf("failed to check items: %w", err)
+			}
+			err = recordChange(tx, cartpkg.Change{CartID: into.ID, Action: cartpkg.ChangeMerged, Product: item.ProductName, Quantity: item.Quantity})
+			if err != nil {
+				return err
+			}
+		}
+
+		updates := map[string]interface{}{"credit": gorm.Expr("credit + ?", from.Credit)}
+		if into.ReferralID == nil && from.ReferralID != nil {
+			updates["referral_id"] = *from.ReferralID
+		}
+		if from.ReservedUntil != nil && (into.ReservedUntil == nil || from.ReservedUntil.After(*into.ReservedUntil)) {
+			// The stock held for the anonymous cart stays held for its items
+			updates["reserved_until"] = *from.ReservedUntil
+		}
+		if len(from.Metadata) > 0 {
+			// Attributes of the cart merged into keep their values
+			updates["metadata"] = from.Metadata.Merge(into.Metadata)
+		}
+		if err := tx.Model(&cartpkg.Cart{}).Where("id = ?", into.ID).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to update cart: %w", err)
+		}
+		into.Credit += from.Credit
+		err = tx.Model(&giftcard.Redemption{}).Where("cart_id = ?", from.ID).Update("cart_id", into.ID).Error
+		if err != nil {
+			return fmt.Errorf("failed to move gift card redemptions: %w", err)
+		}
+
+		if err := tx.Unscoped().Where("cart_id = ?", from.ID).Delete(&cartpkg.CartItem{}).Error; err != nil {
+			return fmt.Errorf("failed to delete cart items: %w", err)
+		}
+		if err := tx.Unscoped().Where("cart_id = ?", from.ID).Delete(&cartpkg.CartDiscount{}).Error; err != nil {
+			return fmt.Errorf("failed to delete cart discounts: %w", err)
+		}
+		if err := deleteChanges(tx, from.ID); err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(&from).Error; err != nil {
+			return fmt.Errorf("failed to delete cart: %w", err)
+		}
+
+		return r.updateCartTotal(tx, into)
+	})
+}
+
+// ListUsers returns all users, oldest first
+func (r *Repository) ListUsers() ([]userpkg.User, error) {
+	var users []userpkg.User
+	if err := r.reader().Order("id").Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	return users, nil
+}
+
+// SetUserRole changes the role of the user with the given ID
+func (r *Repository) SetUserRole(userID uint, role string) error {
+	result := r.db.Model(&userpkg.User{}).Where("id = ?", userID).Update("role", role)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update user role: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// SetUserGroup changes the customer group of a user, which selects the price list they buy from
+func (r *Repository) SetUserGroup(userID uint, group string) error {
+	if !pricelist.ValidGroup(group) {
+		return pricelist.ErrInvalidGroup
+	}
+	result := r.db.Model(&userpkg.User{}).Where("id = ?", userID).Update("customer_group", group)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update customer group: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// UpdatePreferences stores the locale and currency the user prefers, empty for the defaults
+func (r *Repository) UpdatePreferences(userID uint, locale, currency string) error {
+	return r.updateUser(userID, map[string]interface{}{"locale": locale, "currency": currency})
+}
+
+// SetPassword replaces the password of the user with the given bcrypt hash
+func (r *Repository) SetPassword(userID uint, passwordHash string) error {
+	return r.updateUser(userID, map[string]interface{}{"password_hash": passwordHash})
+}
+
+// ChangeEmail changes the email address of the user, failing with ErrEmailTaken when another user has
+// the address. Accounts of the given email provider are identified by their address, which changes too.
+func (r *Repository) ChangeEmail(userID uint, emailProvider, email string) error {
+	email = strings.ToLower(strings.TrimSpace(email))
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		err := tx.Model(&userpkg.User{}).Where("LOWER(email) = ? AND id <> ?", email, userID).Count(&count).Error
+		if err != nil {
+			return fmt.Errorf("failed to check email address: %w", err)
+		}
+		if count > 0 {
+			return ErrEmailTaken
+		}
+
+		var u userpkg.User
+		if err := tx.First(&u, userID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to get user: %w", err)
+		}
+		// The link confirming the change was sent to the new address, which verifies it
+		updates := map[string]interface{}{"email": email, "email_verified_at": time.Now()}
+		if u.Provider == emailProvider {
+			updates["provider_user_id"] = email
+		}
+		if err := tx.Model(&u).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to change email address: %w", err)
+		}
+		return nil
+	})
+}
+
+// updateUser applies the updates to the user with the given ID
+func (r *Repository) updateUser(userID uint, updates map[string]interface{}) error {
+	result := r.db.Model(&userpkg.User{}).Where("id = ?", userID).Updates(updates)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update user: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// StartTOTPEnrollment stores a new authenticator app secret for the user, replacing the secret of an
+// enrollment that wasn't confirmed
+func (r *Repository) StartTOTPEnrollment(userID uint, secret string) error {
+	result := r.db.Model(&userpkg.User{}).
+		Where("id = ? AND totp_enabled = ?", userID, false).
+		Update("totp_secret", secret)
+	if result.Error != nil {
+		return fmt.Errorf("failed to store TOTP secret: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		if _, err := r.GetUser(userID); err != nil {
+			return ErrUserNotFound
+		}
+		return ErrTOTPEnabled
+	}
+	return nil
+}
+
+// EnableTOTP turns two-factor authentication on for the user once they confirmed a code, replacing
+// their recovery codes with the given hashes
+func (r *Repository) EnableTOTP(userID uint, recoveryCodeHashes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&userpkg.User{}).
+			Where("id = ? AND totp_enabled = ? AND totp_secret <> ''", userID, false).
+			Update("totp_enabled", true)
+		if result.Error != nil {
+			return fmt.Errorf("failed to enable two-factor authentication: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrTOTPNotEnrolled
+		}
+
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&userpkg.RecoveryCode{}).Error; err != nil {
+			return fmt.Errorf("failed to delete recovery codes: %w", err)
+		}
+		codes := make([]userpkg.RecoveryCode, len(recoveryCodeHashes))
+		for i, hash := range recoveryCodeHashes {
+			codes[i] = userpkg.RecoveryCode{UserID: userID, CodeHash: hash}
+		}
+		if len(codes) > 0 {
+			if err := tx.Create(&codes).Error; err != nil {
+				return fmt.Errorf("failed to store recovery codes: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// UseRecoveryCode marks the recovery code of the user with the given hash as used. Codes can only be
+// used once, so two logins racing with the same code can't both succeed.
+func (r *Repository) UseRecoveryCode(userID uint, codeHash string) error {
+	result := r.db.Model(&userpkg.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("failed to use recovery code: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrRecoveryCodeInvalid
+	}
+	return nil
+}
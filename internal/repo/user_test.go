@@ -0,0 +1,229 @@
+package repo_test
+
+import (
+	cartpkg "interview/internal/cart"
+	"interview/internal/repo"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindOrCreateUser(t *testing.T) {
+	db := setupTestDB(t)
+	repo := repo.NewRepository(db)
+
+	t.Run("creates user on first login", func(t *testing.T) {
+		u, err := repo.FindOrCreateUser("github", "42", "octo@example.com", "Octo")
+		require.NoError(t, err)
+		assert.NotZero(t, u.ID)
+		assert.Equal(t, "octo@example.com", u.Email)
+	})
+
+	t.Run("returns the same user and refreshes the profile", func(t *testing.T) {
+		first, err := repo.FindOrCreateUser("github", "43", "old@example.com", "Old")
+		require.NoError(t, err)
+
+		second, err := repo.FindOrCreateUser("github", "43", "new@example.com", "New")
+		require.NoError(t, err)
+		assert.Equal(t, first.ID, second.ID)
+
+		stored, err := repo.GetUser(first.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "new@example.com", stored.Email)
+		assert.Equal(t, "New", stored.Name)
+	})
+
+	t.Run("same provider ID on another provider is a different user", func(t *testing.T) {
+		gh, err := repo.FindOrCreateUser("github", "44", "", "")
+		require.NoError(t, err)
+		google, err := repo.FindOrCreateUser("google", "44", "", "")
+		require.NoError(t, err)
+		assert.NotEqual(t, gh.ID, google.ID)
+	})
+}
+
+func TestAssignCartToUser(t *testing.T) {
+	db := setupTestDB(t)
+	repo := repo.NewRepository(db)
+
+	cart, err := repo.GetOrCreateCart("session-with-user", cartpkg.DefaultName)
+	require.NoError(t, err)
+	assert.Nil(t, cart.UserID)
+
+	u, err := repo.FindOrCreateUser("google", "1", "user@example.com", "User")
+	require.NoError(t, err)
+
+	require.NoError(t, repo.AssignCartToUser("session-with-user", u.ID))
+
+	cart, err = repo.GetExistingCart("session-with-user", cartpkg.DefaultName)
+	require.NoError(t, err)
+	require.NotNil(t, cart.UserID)
+	assert.Equal(t, u.ID, *cart.UserID)
+}
+
+func TestSetUserRole(t *testing.T) {
+	db := setupTestDB(t)
+	cartRepo := repo.NewRepository(db)
+
+	u, err := cartRepo.FindOrCreateUser("google", "2", "staff@example.com", "Staff")
+	require.NoError(t, err)
+	assert.Equal(t, "customer", u.Role)
+
+	require.NoError(t, cartRepo.SetUserRole(u.ID, "support"))
+	u, err = cartRepo.GetUser(u.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "support", u.Role)
+
+	assert.ErrorIs(t, cartRepo.SetUserRole(u.ID+100, "admin"), repo.ErrUserNotFound)
+}
+
+func TestTwoFactorAuthentication(t *testing.T) {
+	db := setupTestDB(t)
+	cartRepo := repo.NewRepository(db)
+
+	u, err := cartRepo.FindOrCreateUser("google", "3", "totp@example.com", "TOTP")
+	require.NoError(t, err)
+
+	t.Run("enabling requires enrollment", func(t *testing.T) {
+		assert.ErrorIs(t, cartRepo.EnableTOTP(u.ID, nil), repo.ErrTOTPNotEnrolled)
+	})
+
+	t.Run("enables two-factor authentication with recovery codes", func(t *testing.T) {
+		require.NoError(t, cartRepo.StartTOTPEnrollment(u.ID, "first"))
+		require.NoError(t, cartRepo.StartTOTPEnrollment(u.ID, "second"))
+		require.NoError(t, cartRepo.EnableTOTP(u.ID, []string{"hash-1", "hash-2"}))
+
+		stored, err := cartRepo.GetUser(u.ID)
+		require.NoError(t, err)
+		assert.True(t, stored.TOTPEnabled)
+		assert.Equal(t, "second", stored.TOTPSecret)
+
+		assert.ErrorIs(t, cartRepo.StartTOTPEnrollment(u.ID, "third"), repo.ErrTOTPEnabled)
+		assert.ErrorIs(t, cartRepo.EnableTOTP(u.ID, nil), repo.ErrTOTPNotEnrolled)
+		assert.ErrorIs(t, cartRepo.StartTOTPEnrollment(u.ID+100, "x"), repo.ErrUserNotFound)
+	})
+
+	t.Run("recovery codes can be used once", func(t *testing.T) {
+		require.NoError(t, cartRepo.UseRecoveryCode(u.ID, "hash-1"))
+		assert.ErrorIs(t, cartRepo.UseRecoveryCode(u.ID, "hash-1"), repo.ErrRecoveryCodeInvalid)
+		assert.ErrorIs(t, cartRepo.UseRecoveryCode(u.ID+100, "hash-2"), repo.ErrRecoveryCodeInvalid)
+		assert.ErrorIs(t, cartRepo.UseRecoveryCode(u.ID, "unknown"), repo.ErrRecoveryCodeInvalid)
+		require.NoError(t, cartRepo.UseRecoveryCode(u.ID, "hash-2"))
+	})
+}
+
+func TestFindOrCreateUserByEmail(t *testing.T) {
+	db := setupTestDB(t)
+	cartRepo := repo.NewRepository(db)
+
+	google, err := cartRepo.FindOrCreateUser("google", "4", "Jane@example.com", "Jane")
+	require.NoError(t, err)
+
+	t.Run("finds the account with the address", func(t *testing.T) {
+		u, err := cartRepo.FindOrCreateUserByEmail("email", " jane@EXAMPLE.com")
+		require.NoError(t, err)
+		assert.Equal(t, google.ID, u.ID)
+	})
+
+	t.Run("creates an account for new addresses", func(t *testing.T) {
+		u, err := cartRepo.FindOrCreateUserByEmail("email", "John@example.com")
+		require.NoError(t, err)
+		assert.Equal(t, "email", u.Provider)
+		assert.Equal(t, "john@example.com", u.Email)
+
+		again, err := cartRepo.FindOrCreateUserByEmail("email", "john@example.com")
+		require.NoError(t, err)
+		assert.Equal(t, u.ID, again.ID)
+	})
+}
+
+func TestMergeAnonymousCart(t *testing.T) {
+	db := setupTestDB(t)
+	cartRepo := repo.NewRepository(db)
+
+	_, err := cartRepo.CreateGiftCard("merge-gift", 15.0)
+	require.NoError(t, err)
+	from, err := cartRepo.GetOrCreateCart("merge-phone", cartpkg.DefaultName)
+	require.NoError(t, err)
+	require.NoError(t, cartRepo.AddCartItem(from.ID, "shoe", 1, 10.0))
+	require.NoError(t, cartRepo.AddCartItem(from.ID, "bag", 2, 30.0))
+	_, err = cartRepo.RedeemGiftCard(from.ID, "merge-gift")
+	require.NoError(t, err)
+	into, err := cartRepo.GetOrCreateCart("merge-laptop", cartpkg.DefaultName)
+	require.NoError(t, err)
+	require.NoError(t, cartRepo.AddCartItem(into.ID, "shoe", 2, 10.0))
+
+	t.Run("moves items and credit", func(t *testing.T) {
+		require.NoError(t, cartRepo.MergeAnonymousCart(from.ID, into.ID))
+
+		merged, err := cartRepo.GetOrCreateCart("merge-laptop", cartpkg.DefaultName)
+		require.NoError(t, err)
+		quantities := map[string]int{}
+		for _, item := range merged.CartItems {
+			quantities[item.ProductName] = item.Quantity
+		}
+		assert.Equal(t, map[string]int{"shoe": 3, "bag": 2}, quantities)
+		assert.Equal(t, 15.0, merged.Credit)
+		assert.Equal(t, 75.0, merged.Total)
+
+		_, err = cartRepo.GetExistingCart("merge-phone", cartpkg.DefaultName)
+		assert.Error(t, err)
+	})
+
+	t.Run("merged carts are gone", func(t *testing.T) {
+		assert.ErrorIs(t, cartRepo.MergeAnonymousCart(from.ID, into.ID), cartpkg.ErrCartNotFound)
+	})
+
+	t.Run("carts of users are not merged", func(t *testing.T) {
+		other, err := cartRepo.GetOrCreateCart("merge-other", cartpkg.DefaultName)
+		require.NoError(t, err)
+		u, err := cartRepo.FindOrCreateUser("google", "5", "other@example.com", "Other")
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AssignCartToUser("merge-other", u.ID))
+
+		assert.ErrorIs(t, cartRepo.MergeAnonymousCart(other.ID, into.ID), cartpkg.ErrCartNotFound)
+	})
+}
+
+func TestUpdateAccount(t *testing.T) {
+	db := setupTestDB(t)
+	cartRepo := repo.NewRepository(db)
+
+	u, err := cartRepo.FindOrCreateUserByEmail("email", "jane@example.com")
+	require.NoError(t, err)
+	_, err = cartRepo.FindOrCreateUser("google", "6", "john@example.com", "John")
+	require.NoError(t, err)
+
+	t.Run("stores preferences and password", func(t *testing.T) {
+		require.NoError(t, cartRepo.UpdatePreferences(u.ID, "de", "USD"))
+		require.NoError(t, cartRepo.SetPassword(u.ID, "hash"))
+
+		stored, err := cartRepo.GetUser(u.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "de", stored.Locale)
+		assert.Equal(t, "USD", stored.Currency)
+		assert.Equal(t, "hash", stored.PasswordHash)
+
+		assert.ErrorIs(t, cartRepo.UpdatePreferences(u.ID+100, "", ""), repo.ErrUserNotFound)
+	})
+
+	t.Run("changes the email address and identity", func(t *testing.T) {
+		require.NoError(t, cartRepo.ChangeEmail(u.ID, "email", "Jane.Doe@example.com"))
+
+		stored, err := cartRepo.GetUser(u.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "jane.doe@example.com", stored.Email)
+		assert.Equal(t, "jane.doe@example.com", stored.ProviderUserID)
+
+		// The old address is free for a new account
+		other, err := cartRepo.FindOrCreateUserByEmail("email", "jane@example.com")
+		require.NoError(t, err)
+		assert.NotEqual(t, u.ID, other.ID)
+	})
+
+	t.Run("addresses of other users are taken", func(t *testing.T) {
+		assert.ErrorIs(t, cartRepo.ChangeEmail(u.ID, "email", "JOHN@example.com"), repo.ErrEmailTaken)
+		assert.ErrorIs(t, cartRepo.ChangeEmail(u.ID+100, "email", "new@example.com"), repo.ErrUserNotFound)
+	})
+}
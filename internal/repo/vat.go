@@ -0,0 +1,54 @@
+package repo
+
+import (
+	"fmt"
+	cartpkg "interview/internal/cart"
+	"interview/internal/vat"
+)
+
+// SetVATEvidence records the validation of the VAT ID given at the checkout of the cart, replacing an
+// earlier one, and zero-rates the cart if the evidence exempts it. A nil evidence forgets the VAT ID
+// and charges tax again. Unlike other changes to the cart, this is allowed while it is checked out.
+func (r *Repository) SetVATEvidence(cartID uint, evidence *vat.Evidence) error {
+	return r.Transaction(func(tx *Repository) error {
+		var cart cartpkg.Cart
+		if err := tx.db.First(&cart, cartID).Error; err != nil {
+			return fmt.Errorf("failed to get cart: %w", err)
+		}
+		if cart.Status != cartpkg.StatusOpen {
+			return cartpkg.ErrCartClosed
+		}
+		if err := tx.db.Where("cart_id = ?", cartID).Delete(&vat.Evidence{}).Error; err != nil {
+			return fmt.Errorf("failed to clear VAT evidence: %w", err)
+		}
+		if evidence != nil {
+			evidence.ID, evidence.CartID = 0, cartID
+			if err := tx.db.Create(evidence).Error; err != nil {
+				return fmt.Errorf("failed to store VAT evidence: %w", err)
+			}
+		}
+
+		exempt := evidence != nil && evidence.Exempt
+		if exempt == cart.TaxExempt {
+			return nil
+		}
+		if err := tx.db.Model(&cart).Update("tax_exempt", exempt).Error; err != nil {
+			return fmt.Errorf("failed to update tax exemption: %w", err)
+		}
+		return tx.updateCartTotal(tx.db, &cart)
+	})
+}
+
+// GetVATEvidence returns the validation of the VAT ID given at the checkout of the cart, nil when none
+// was given
+func (r *Repository) GetVATEvidence(cartID uint) (*vat.Evidence, error) {
+	var evidence vat.Evidence
+	err := r.db.Where("cart_id = ?", cartID).Limit(1).Find(&evidence).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get VAT evidence: %w", err)
+	}
+	if evidence.ID == 0 {
+		return nil, nil
+	}
+	return &evidence, nil
+}
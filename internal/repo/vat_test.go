@@ -0,0 +1,85 @@
+package repo_test
+
+import (
+	cartpkg "interview/internal/cart"
+	"interview/internal/repo"
+	"interview/internal/vat"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVATEvidence(t *testing.T) {
+	db := setupTestDB(t)
+	cartRepo := repo.NewRepository(db)
+	cartRepo.SetCharges(cartpkg.Charges{TaxRate: 19, Shipping: 5})
+
+	c, err := cartRepo.GetOrCreateCart("vat-session", cartpkg.DefaultName)
+	require.NoError(t, err)
+	require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 1, 100))
+	valid := vat.Result{Valid: true, ConsultationNumber: "WAPIAAAAZ1", CheckedAt: time.Now()}
+
+	t.Run("exempt evidence zero-rates the cart", func(t *testing.T) {
+		require.NoError(t, cartRepo.SetVATEvidence(c.ID, vat.NewEvidence(c.ID, "AT", "U12345678", valid, "DE")))
+		exempt, err := cartRepo.GetCart(c.ID)
+		require.NoError(t, err)
+		assert.True(t, exempt.TaxExempt)
+		assert.Zero(t, exempt.Tax)
+		assert.Equal(t, 105.0, exempt.Total)
+
+		evidence, err := cartRepo.GetVATEvidence(c.ID)
+		require.NoError(t, err)
+		require.NotNil(t, evidence)
+		assert.Equal(t, "ATU12345678", evidence.VATID)
+	})
+
+	t.Run("zero-rated carts stay zero-rated when changed", func(t *testing.T) {
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "watch", 1, 100))
+		changed, err := cartRepo.GetCart(c.ID)
+		require.NoError(t, err)
+		assert.Zero(t, changed.Tax)
+		assert.Equal(t, 205.0, changed.Total)
+	})
+
+	t.Run("domestic evidence replaces it and charges tax", func(t *testing.T) {
+		require.NoError(t, cartRepo.SetVATEvidence(c.ID, vat.NewEvidence(c.ID, "DE", "123456789", valid, "DE")))
+		taxed, err := cartRepo.GetCart(c.ID)
+		require.NoError(t, err)
+		assert.False(t, taxed.TaxExempt)
+		assert.Equal(t, 38.0, taxed.Tax)
+
+		evidence, err := cartRepo.GetVATEvidence(c.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "DE123456789", evidence.VATID)
+		var count int64
+		require.NoError(t, db.Model(&vat.Evidence{}).Where("cart_id = ?", c.ID).Count(&count).Error)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("no evidence forgets the VAT ID", func(t *testing.T) {
+		require.NoError(t, cartRepo.SetVATEvidence(c.ID, vat.NewEvidence(c.ID, "AT", "U12345678", valid, "DE")))
+		require.NoError(t, cartRepo.SetVATEvidence(c.ID, nil))
+		taxed, err := cartRepo.GetCart(c.ID)
+		require.NoError(t, err)
+		assert.False(t, taxed.TaxExempt)
+		assert.Equal(t, 38.0, taxed.Tax)
+
+		evidence, err := cartRepo.GetVATEvidence(c.ID)
+		require.NoError(t, err)
+		assert.Nil(t, evidence)
+	})
+
+	t.Run("evidence is kept on the order", func(t *testing.T) {
+		require.NoError(t, cartRepo.SetVATEvidence(c.ID, vat.NewEvidence(c.ID, "AT", "U12345678", valid, "DE")))
+		require.NoError(t, cartRepo.CloseCart(c.ID))
+		o, err := cartRepo.GetOrderByCart(c.ID)
+		require.NoError(t, err)
+		require.NotNil(t, o.VATEvidence)
+		assert.Equal(t, "WAPIAAAAZ1", o.VATEvidence.ConsultationNumber)
+		assert.True(t, o.VATEvidence.Exempt)
+
+		assert.ErrorIs(t, cartRepo.SetVATEvidence(c.ID, nil), cartpkg.ErrCartClosed)
+	})
+}
@@ -0,0 +1,54 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	userpkg "interview/internal/user"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrVerificationCooldown is returned when a verification link was sent to the user too recently to
+// send another
+var ErrVerificationCooldown = errors.New("a verification link was sent recently")
+
+// StartEmailVerification records that a link verifying the email address of the user is sent at the
+// time. It fails with ErrVerificationCooldown when the last one was sent less than cooldown before.
+func (r *Repository) StartEmailVerification(userID uint, at time.Time, cooldown time.Duration) error {
+	result := r.db.Model(&userpkg.User{}).
+		Where("id = ? AND (verification_sent_at IS NULL OR verification_sent_at <= ?)", userID, at.Add(-cooldown)).
+		Update("verification_sent_at", at)
+	if result.Error != nil {
+		return fmt.Errorf("failed to record verification link: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrVerificationCooldown
+	}
+	return nil
+}
+
+// VerifyEmail records that the user proved to receive email at email at the time, keeping the time of an
+// earlier verification. It fails with ErrUserNotFound when email isn't the address of the user (anymore).
+func (r *Repository) VerifyEmail(userID uint, email string, at time.Time) error {
+	owner := r.db.Model(&userpkg.User{}).
+		Where("id = ? AND LOWER(email) = ?", userID, strings.ToLower(strings.TrimSpace(email))).
+		Session(&gorm.Session{})
+	result := owner.Where("email_verified_at IS NULL").Update("email_verified_at", at)
+	if result.Error != nil {
+		return fmt.Errorf("failed to verify email address: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+	// Verified before, unless the address isn't the user's
+	var verified int64
+	if err := owner.Count(&verified).Error; err != nil {
+		return fmt.Errorf("failed to verify email address: %w", err)
+	}
+	if verified == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
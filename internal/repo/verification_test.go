@@ -0,0 +1,51 @@
+package repo_test
+
+import (
+	"interview/internal/repo"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestEmailVerification(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, repo.Migrate(db))
+	cartRepo := repo.NewRepository(db)
+	start := time.Now().Truncate(time.Second)
+
+	u, err := cartRepo.FindOrCreateUser("github", "1", "jane@example.com", "Jane")
+	require.NoError(t, err)
+	assert.Nil(t, u.EmailVerifiedAt)
+
+	t.Run("cooldown", func(t *testing.T) {
+		require.NoError(t, cartRepo.StartEmailVerification(u.ID, start, time.Minute))
+		assert.ErrorIs(t, cartRepo.StartEmailVerification(u.ID, start.Add(30*time.Second), time.Minute), repo.ErrVerificationCooldown)
+		require.NoError(t, cartRepo.StartEmailVerification(u.ID, start.Add(time.Minute), time.Minute))
+	})
+
+	t.Run("verify", func(t *testing.T) {
+		assert.ErrorIs(t, cartRepo.VerifyEmail(u.ID, "old@example.com", start), repo.ErrUserNotFound)
+		require.NoError(t, cartRepo.VerifyEmail(u.ID, " Jane@Example.com", start))
+		require.NoError(t, cartRepo.VerifyEmail(u.ID, "jane@example.com", start.Add(time.Hour)))
+		verified, err := cartRepo.GetUser(u.ID)
+		require.NoError(t, err)
+		require.NotNil(t, verified.EmailVerifiedAt)
+		assert.WithinDuration(t, start, *verified.EmailVerifiedAt, 0, "verifying again keeps the first time")
+	})
+
+	t.Run("new address of the provider", func(t *testing.T) {
+		changed, err := cartRepo.FindOrCreateUser("github", "1", "jane@example.org", "Jane")
+		require.NoError(t, err)
+		assert.Nil(t, changed.EmailVerifiedAt)
+		assert.Nil(t, changed.VerificationSentAt)
+
+		renamed, err := cartRepo.FindOrCreateUser("github", "1", "jane@example.org", "Jane Doe")
+		require.NoError(t, err)
+		assert.Nil(t, renamed.EmailVerifiedAt)
+	})
+}
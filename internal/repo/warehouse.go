@@ -0,0 +1,166 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	cartpkg "interview/internal/cart"
+	"interview/internal/checkout"
+	productpkg "interview/internal/product"
+	"interview/internal/warehouse"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ItemAllocation is an allocation of a checked out cart item with the names of its product and warehouse
+type ItemAllocation struct {
+	CartItemID    uint
+	ProductName   string
+	WarehouseID   uint
+	WarehouseName string
+	Quantity      int
+}
+
+// SetAllocationStrategy sets how the items of checked out carts are allocated to warehouses
+func (r *Repository) SetAllocationStrategy(strategy warehouse.Strategy) {
+	r.allocation = strategy
+}
+
+// CreateWarehouse adds a warehouse products can be kept in
+func (r *Repository) CreateWarehouse(w *warehouse.Warehouse) error {
+	if err := r.db.Create(w).Error; err != nil {
+		return fmt.Errorf("failed to create warehouse: %w", err)
+	}
+	return nil
+}
+
+// ListWarehouses returns all warehouses in the order they were added
+func (r *Repository) ListWarehouses() ([]warehouse.Warehouse, error) {
+	var warehouses []warehouse.Warehouse
+	if err := r.db.Order("id").Find(&warehouses).Error; err != nil {
+		return nil, fmt.Errorf("failed to list warehouses: %w", err)
+	}
+	return warehouses, nil
+}
+
+// SetWarehouseStock sets the units of the product in the warehouse and returns the stock of the product
+// in all warehouses, which becomes the stock of the product. It fails with warehouse.ErrWarehouseNotFound
+// and gorm.ErrRecordNotFound for unknown warehouses and products.
+func (r *Repository) SetWarehouseStock(warehouseID, productID uint, quantity int) (int, error) {
+	var total int
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&warehouse.Warehouse{}, warehouseID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+			return warehouse.ErrWarehouseNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to get warehouse: %w", err)
+		}
+		if err := tx.First(&productpkg.Product{}, productID).Error; err != nil {
+			return fmt.Errorf("failed to get product: %w", err)
+		}
+
+		stock := warehouse.Stock{WarehouseID: warehouseID, ProductID: productID, Quantity: quantity}
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "warehouse_id"}, {Name: "product_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"quantity"}),
+		}).Create(&stock).Error
+		if err != nil {
+			return fmt.Errorf("failed to update warehouse stock: %w", err)
+		}
+		total, err = syncProductStock(tx, productID)
+		return err
+	})
+	if err == nil {
+		r.invalidateProduct(productID)
+	}
+	return total, err
+}
+
+// ListAllocations returns the allocations of the items of a checked out cart, by item
+func (r *Repository) ListAllocations(cartID uint) ([]ItemAllocation, error) {
+	var allocations []ItemAllocation
+	err := r.db.Table("item_allocations").
+		Select("item_allocations.cart_item_id, cart_items.product_name, item_allocations.warehouse_id, "+
+			"warehouses.name AS warehouse_name, item_allocations.quantity").
+		Joins("JOIN cart_items ON cart_items.id = item_allocations.cart_item_id").
+		Joins("JOIN warehouses ON warehouses.id = item_allocations.warehouse_id").
+		Where("cart_items.cart_id = ?", cartID).
+		Order("item_allocations.cart_item_id, item_allocations.id").
+		Scan(&allocations).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list allocations: %w", err)
+	}
+	return allocations, nil
+}
+
+// allocateItem takes the units of a checked out item from the warehouses chosen by the allocation
+// strategy and records where they ship from. It reports false for products not kept in warehouses.
+func (r *Repository) allocateItem(db *gorm.DB, item cartpkg.CartItem, country string) (bool, error) {
+	var p productpkg.Product
+	if err := db.Where("name = ?", item.ProductName).First(&p).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to get product: %w", err)
+	}
+	var levels []warehouse.Level
+	err := db.Model(&warehouse.Stock{}).
+		Select("warehouse_stock.warehouse_id, warehouses.country, warehouse_stock.quantity").
+		Joins("JOIN warehouses ON warehouses.id = warehouse_stock.warehouse_id AND warehouses.deleted_at IS NULL").
+		Where("warehouse_stock.product_id = ?", p.ID).
+		Scan(&levels).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to get warehouse stock: %w", err)
+	}
+	if len(levels) == 0 {
+		return false, nil
+	}
+
+	for _, pick := range r.allocation.Allocate(country, item.Quantity, levels) {
+		err := db.Model(&warehouse.Stock{}).
+			Where("warehouse_id = ? AND product_id = ?", pick.WarehouseID, p.ID).
+			Update("quantity", gorm.Expr("CASE WHEN quantity > ? THEN quantity - ? ELSE 0 END", pick.Quantity, pick.Quantity)).Error
+		if err != nil {
+			return false, fmt.Errorf("failed to update warehouse stock: %w", err)
+		}
+		allocation := warehouse.Allocation{CartItemID: item.ID, WarehouseID: pick.WarehouseID, Quantity: pick.Quantity}
+		if err := db.Create(&allocation).Error; err != nil {
+			return false, fmt.Errorf("failed to allocate item: %w", err)
+		}
+	}
+	if _, err := syncProductStock(db, p.ID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// syncProductStock sets the stock of a product kept in warehouses to its units in all of them
+func syncProductStock(db *gorm.DB, productID uint) (int, error) {
+	var total int
+	err := db.Model(&warehouse.Stock{}).
+		Joins("JOIN warehouses ON warehouses.id = warehouse_stock.warehouse_id AND warehouses.deleted_at IS NULL").
+		Where("warehouse_stock.product_id = ?", productID).
+		Select("COALESCE(SUM(warehouse_stock.quantity), 0)").
+		Scan(&total).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to sum warehouse stock: %w", err)
+	}
+	if err := db.Model(&productpkg.Product{}).Where("id = ?", productID).Update("stock", total).Error; err != nil {
+		return 0, fmt.Errorf("failed to update stock: %w", err)
+	}
+	return total, nil
+}
+
+// shippingCountry returns the country the cart is shipped to, empty when its checkout didn't choose an
+// address, e.g. for subscription orders
+func shippingCountry(db *gorm.DB, cartID uint) (string, error) {
+	var country string
+	err := db.Model(&checkout.Session{}).
+		Select("addresses.country").
+		Joins("JOIN addresses ON addresses.id = checkout_sessions.address_id").
+		Where("checkout_sessions.cart_id = ?", cartID).
+		Limit(1).
+		Scan(&country).Error
+	if err != nil {
+		return "", fmt.Errorf("failed to get shipping country: %w", err)
+	}
+	return country, nil
+}
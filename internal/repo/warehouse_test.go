@@ -0,0 +1,102 @@
+package repo_test
+
+import (
+	"interview/internal/address"
+	cartpkg "interview/internal/cart"
+	"interview/internal/repo"
+	"interview/internal/warehouse"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestWarehouses(t *testing.T) {
+	db := setupTestDB(t)
+	cartRepo := repo.NewRepository(db)
+	shoe, err := cartRepo.UpsertProduct("shoe", 10)
+	require.NoError(t, err)
+
+	berlin := warehouse.Warehouse{Name: "Berlin", Country: "DE"}
+	amsterdam := warehouse.Warehouse{Name: "Amsterdam", Country: "NL"}
+	require.NoError(t, cartRepo.CreateWarehouse(&amsterdam))
+	require.NoError(t, cartRepo.CreateWarehouse(&berlin))
+
+	stock := func(t *testing.T) int {
+		t.Helper()
+		p, err := cartRepo.GetProduct(shoe.ID)
+		require.NoError(t, err)
+		require.NotNil(t, p.Stock)
+		return *p.Stock
+	}
+	// checkOut closes a cart with quantity shoes shipped to the country
+	checkOut := func(t *testing.T, sessionID, country string, quantity int) uint {
+		t.Helper()
+		c, err := cartRepo.GetOrCreateCart(sessionID, cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", quantity, 10))
+		a := address.Address{SessionID: sessionID, Name: "Jane Doe", Line1: "Main St 1", City: "Berlin", PostalCode: "10115", Country: country}
+		require.NoError(t, cartRepo.CreateAddress(&a))
+		s, err := cartRepo.StartCheckout(sessionID, c.ID)
+		require.NoError(t, err)
+		s.AddressID = &a.ID
+		require.NoError(t, cartRepo.SaveCheckout(s))
+		require.NoError(t, cartRepo.CloseCart(c.ID))
+		return c.ID
+	}
+
+	t.Run("warehouse stock adds up to the product stock", func(t *testing.T) {
+		total, err := cartRepo.SetWarehouseStock(amsterdam.ID, shoe.ID, 4)
+		require.NoError(t, err)
+		assert.Equal(t, 4, total)
+		total, err = cartRepo.SetWarehouseStock(berlin.ID, shoe.ID, 2)
+		require.NoError(t, err)
+		assert.Equal(t, 6, total)
+		assert.Equal(t, 6, stock(t))
+
+		five := 5
+		assert.ErrorIs(t, cartRepo.SetProductStock(shoe.ID, &five), warehouse.ErrStockInWarehouses)
+		_, err = cartRepo.SetWarehouseStock(berlin.ID+100, shoe.ID, 1)
+		assert.ErrorIs(t, err, warehouse.ErrWarehouseNotFound)
+		_, err = cartRepo.SetWarehouseStock(berlin.ID, shoe.ID+100, 1)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	})
+
+	t.Run("checkout allocates items to the nearest warehouses", func(t *testing.T) {
+		cartID := checkOut(t, "warehouse-session-1", "DE", 3)
+		allocations, err := cartRepo.ListAllocations(cartID)
+		require.NoError(t, err)
+		require.Len(t, allocations, 2)
+		assert.Equal(t, "Berlin", allocations[0].WarehouseName)
+		assert.Equal(t, 2, allocations[0].Quantity)
+		assert.Equal(t, "Amsterdam", allocations[1].WarehouseName)
+		assert.Equal(t, 1, allocations[1].Quantity)
+		assert.Equal(t, "shoe", allocations[1].ProductName)
+		assert.Equal(t, 3, stock(t))
+	})
+
+	t.Run("the strategy is pluggable", func(t *testing.T) {
+		_, err := cartRepo.SetWarehouseStock(berlin.ID, shoe.ID, 1)
+		require.NoError(t, err)
+		cartRepo.SetAllocationStrategy(warehouse.MostStock{})
+		defer cartRepo.SetAllocationStrategy(warehouse.Nearest{})
+
+		cartID := checkOut(t, "warehouse-session-2", "DE", 1)
+		allocations, err := cartRepo.ListAllocations(cartID)
+		require.NoError(t, err)
+		require.Len(t, allocations, 1)
+		assert.Equal(t, "Amsterdam", allocations[0].WarehouseName)
+		assert.Equal(t, 3, stock(t))
+	})
+
+	t.Run("products outside warehouses are not allocated", func(t *testing.T) {
+		c, err := cartRepo.GetOrCreateCart("warehouse-session-3", cartpkg.DefaultName)
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.AddCartItem(c.ID, "sock", 1, 2))
+		require.NoError(t, cartRepo.CloseCart(c.ID))
+		allocations, err := cartRepo.ListAllocations(c.ID)
+		require.NoError(t, err)
+		assert.Empty(t, allocations)
+	})
+}
@@ -0,0 +1,166 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"interview/internal/webhook"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrWebhookNotFound is returned when no webhook endpoint has the given ID
+	ErrWebhookNotFound = errors.New("webhook not found")
+	// ErrDeliveryNotRetryable is returned when retrying a delivery that doesn't exist or isn't dead
+	ErrDeliveryNotRetryable = errors.New("only dead deliveries can be retried")
+)
+
+// CreateWebhook registers an endpoint receiving the given events
+func (r *Repository) CreateWebhook(url, secret string, events []string) (*webhook.Endpoint, error) {
+	endpoint := webhook.Endpoint{URL: url, Secret: secret, Events: strings.Join(events, ",")}
+	if err := r.db.Create(&endpoint).Error; err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+	return &endpoint, nil
+}
+
+// ListWebhooks returns all registered endpoints
+func (r *Repository) ListWebhooks() ([]webhook.Endpoint, error) {
+	var endpoints []webhook.Endpoint
+	if err := r.db.Order("id").Find(&endpoints).Error; err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	return endpoints, nil
+}
+
+// GetWebhook returns the endpoint with the given ID
+func (r *Repository) GetWebhook(id uint) (*webhook.Endpoint, error) {
+	var endpoint webhook.Endpoint
+	err := r.db.First(&endpoint, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrWebhookNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+	return &endpoint, nil
+}
+
+// DeleteWebhook unregisters an endpoint. Its pending deliveries are dropped, the log is kept.
+func (r *Repository) DeleteWebhook(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&webhook.Endpoint{}, id)
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete webhook: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrWebhookNotFound
+		}
+		err := tx.Model(&webhook.Delivery{}).
+			Where("endpoint_id = ? AND status = ?", id, webhook.StatusPending).
+			Updates(map[string]interface{}{"status": webhook.StatusDead, "last_error": "webhook deleted"}).Error
+		if err != nil {
+			return fmt.Errorf("failed to cancel deliveries: %w", err)
+		}
+		return nil
+	})
+}
+
+// EnqueueWebhookDeliveries creates a pending delivery of the payload for every endpoint subscribed to the event
+func (r *Repository) EnqueueWebhookDeliveries(event string, payload []byte, at time.Time) (int, error) {
+	endpoints, err := r.ListWebhooks()
+	if err != nil {
+		return 0, err
+	}
+
+	var deliveries []webhook.Delivery
+	for _, endpoint := range endpoints {
+		if endpoint.Subscribes(event) {
+			deliveries = append(deliveries, webhook.Delivery{
+				EndpointID:    endpoint.ID,
+				Event:         event,
+				Payload:       string(payload),
+				Status:        webhook.StatusPending,
+				NextAttemptAt: at,
+			})
+		}
+	}
+	if len(deliveries) == 0 {
+		return 0, nil
+	}
+	if err := r.db.Omit(clause.Associations).Create(&deliveries).Error; err != nil {
+		return 0, fmt.Errorf("failed to queue webhook deliveries: %w", err)
+	}
+	return len(deliveries), nil
+}
+
+// ClaimWebhookDeliveries returns up to limit pending deliveries due at now, with their endpoint.
+// Claimed deliveries are pushed back by lease so concurrent dispatchers don't send them twice.
+func (r *Repository) ClaimWebhookDeliveries(now time.Time, lease time.Duration, limit int) ([]webhook.Delivery, error) {
+	var due []webhook.Delivery
+	err := r.db.Preload("Endpoint").
+		Where("status = ? AND next_attempt_at <= ?", webhook.StatusPending, now).
+		Order("next_attempt_at").
+		Limit(limit).
+		Find(&due).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load due deliveries: %w", err)
+	}
+
+	claimed := due[:0]
+	for _, d := range due {
+		result := r.db.Model(&webhook.Delivery{}).
+			Where("id = ? AND status = ? AND next_attempt_at = ?", d.ID, webhook.StatusPending, d.NextAttemptAt).
+			Update("next_attempt_at", now.Add(lease))
+		if result.Error != nil {
+			return nil, fmt.Errorf("failed to claim delivery: %w", result.Error)
+		}
+		if result.RowsAffected == 1 {
+			claimed = append(claimed, d)
+		}
+	}
+	return claimed, nil
+}
+
+// SaveWebhookDelivery stores the outcome of a delivery attempt
+func (r *Repository) SaveWebhookDelivery(d *webhook.Delivery) error {
+	if err := r.db.Omit(clause.Associations).Save(d).Error; err != nil {
+		return fmt.Errorf("failed to save delivery: %w", err)
+	}
+	return nil
+}
+
+// ListWebhookDeliveries returns the latest deliveries to an endpoint, newest first, optionally
+// restricted to one status
+func (r *Repository) ListWebhookDeliveries(endpointID uint, status string, limit int) ([]webhook.Delivery, error) {
+	db := r.db.Where("endpoint_id = ?", endpointID)
+	if status != "" {
+		db = db.Where("status = ?", status)
+	}
+	var deliveries []webhook.Delivery
+	if err := db.Order("id DESC").Limit(limit).Find(&deliveries).Error; err != nil {
+		return nil, fmt.Errorf("failed to list deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// RetryWebhookDelivery gives a dead delivery a fresh set of attempts, starting now
+func (r *Repository) RetryWebhookDelivery(id uint, now time.Time) error {
+	result := r.db.Model(&webhook.Delivery{}).
+		Where("id = ? AND status = ?", id, webhook.StatusDead).
+		Where("endpoint_id IN (?)", r.db.Model(&webhook.Endpoint{}).Select("id")).
+		Updates(map[string]interface{}{
+			"status":          webhook.StatusPending,
+			"attempts":        0,
+			"next_attempt_at": now,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to retry delivery: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrDeliveryNotRetryable
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+// Package restock emails customers who asked to be told when an out-of-stock product is back.
+package restock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"interview/internal/mail"
+	"interview/internal/repo"
+	"strings"
+	"text/template"
+)
+
+// batchSize is the largest number of notifications sent by one run
+const batchSize = 100
+
+var emailTemplate = template.Must(template.New("restock").Parse(`Hello,
+
+{{ .Product }} is back in stock: {{ .Link }}
+
+Stock is limited, so don't wait too long.
+`))
+
+// Notifier emails the subscribers of products that are back in stock
+type Notifier struct {
+	repo    *repo.Repository
+	mailer  mail.Mailer
+	baseURL string
+}
+
+// NewNotifier creates a Notifier whose emails link to the product pages under baseURL
+func NewNotifier(r *repo.Repository, mailer mail.Mailer, baseURL string) *Notifier {
+	return &Notifier{
+		repo:    r,
+		mailer:  mailer,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+// Run notifies every subscriber of a product back in stock and returns how many were notified.
+// Subscriptions are deleted once notified; those whose email fails are kept for the next run.
+func (n *Notifier) Run(ctx context.Context) (int, error) {
+	subs, err := n.repo.ListRestockedSubscriptions(batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var sent int
+	var errs []error
+	for _, restocked := range subs {
+		if err := ctx.Err(); err != nil {
+			return sent, err
+		}
+
+		claimed, err := n.repo.ClaimStockSubscription(restocked.Subscription.ID)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		msg, err := n.message(restocked)
+		if err == nil {
+			err = n.mailer.Send(ctx, msg)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("subscription %d: %w", restocked.Subscription.ID, err))
+			if err := n.repo.SubscribeToStock(restocked.Subscription.ProductID, restocked.Subscription.Email); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		sent++
+	}
+	return sent, errors.Join(errs...)
+}
+
+func (n *Notifier) message(restocked repo.RestockedSubscription) (mail.Message, error) {
+	var body strings.Builder
+	err := emailTemplate.Execute(&body, map[string]interface{}{
+		"Product": restocked.Product.Name,
+		"Link":    fmt.Sprintf("%s/products/%d", n.baseURL, restocked.Product.ID),
+	})
+	if err != nil {
+		return mail.Message{}, fmt.Errorf("failed to render notification: %w", err)
+	}
+
+	return mail.Message{
+		To:      restocked.Subscription.Email,
+		Subject: restocked.Product.Name + " is back in stock",
+		Body:    body.String(),
+	}, nil
+}
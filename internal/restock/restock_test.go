@@ -0,0 +1,81 @@
+package restock_test
+
+import (
+	"context"
+	"errors"
+	"interview/internal/mail"
+	"interview/internal/repo"
+	"interview/internal/restock"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type fakeMailer struct {
+	sent []mail.Message
+	err  error
+}
+
+func (m *fakeMailer) Send(_ context.Context, msg mail.Message) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func TestNotifier(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, repo.Migrate(db))
+	cartRepo := repo.NewRepository(db)
+
+	shoe, err := cartRepo.UpsertProduct("shoe", 10)
+	require.NoError(t, err)
+	none := 0
+	require.NoError(t, cartRepo.SetProductStock(shoe.ID, &none))
+	require.NoError(t, cartRepo.SubscribeToStock(shoe.ID, "jane@example.com"))
+
+	t.Run("Waits For Stock", func(t *testing.T) {
+		mailer := &fakeMailer{}
+		sent, err := restock.NewNotifier(cartRepo, mailer, "https://shop.example.com/").Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 0, sent)
+		assert.Empty(t, mailer.sent)
+	})
+
+	five := 5
+	require.NoError(t, cartRepo.SetProductStock(shoe.ID, &five))
+
+	t.Run("Failed Emails Are Retried", func(t *testing.T) {
+		mailer := &fakeMailer{err: errors.New("connection refused")}
+		sent, err := restock.NewNotifier(cartRepo, mailer, "https://shop.example.com/").Run(context.Background())
+		require.Error(t, err)
+		assert.Equal(t, 0, sent)
+
+		restocked, err := cartRepo.ListRestockedSubscriptions(10)
+		require.NoError(t, err)
+		assert.Len(t, restocked, 1)
+	})
+
+	t.Run("Notifies Once And Deletes Subscription", func(t *testing.T) {
+		mailer := &fakeMailer{}
+		notifier := restock.NewNotifier(cartRepo, mailer, "https://shop.example.com/")
+
+		sent, err := notifier.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, sent)
+		require.Len(t, mailer.sent, 1)
+		assert.Equal(t, "jane@example.com", mailer.sent[0].To)
+		assert.Equal(t, "shoe is back in stock", mailer.sent[0].Subject)
+		assert.Contains(t, mailer.sent[0].Body, "https://shop.example.com/products/1")
+
+		sent, err = notifier.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 0, sent)
+		assert.Len(t, mailer.sent, 1)
+	})
+}
@@ -0,0 +1,57 @@
+package risk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// HTTPChecker asks an external fraud screening service, which receives the Checkout as JSON at URL and
+// responds with a Decision like {"action": "review", "reason": "card testing"}.
+type HTTPChecker struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewHTTPChecker creates an HTTPChecker with a client that times out after a few seconds.
+func NewHTTPChecker(url string) *HTTPChecker {
+	return &HTTPChecker{
+		URL:    url,
+		Client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Check implements Checker.
+func (h *HTTPChecker) Check(ctx context.Context, checkout Checkout) (Decision, error) {
+	body, err := json.Marshal(checkout)
+	if err != nil {
+		return Decision{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return Decision{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return Decision{}, fmt.Errorf("risk service request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Decision{}, fmt.Errorf("risk service returned status %d", resp.StatusCode)
+	}
+
+	var decision Decision
+	if err := json.NewDecoder(resp.Body).Decode(&decision); err != nil {
+		return Decision{}, fmt.Errorf("failed to decode risk decision: %w", err)
+	}
+	if !ValidAction(decision.Action) {
+		return Decision{}, fmt.Errorf("%w: %q", ErrInvalidAction, decision.Action)
+	}
+	return decision, nil
+}
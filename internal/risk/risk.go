@@ -0,0 +1,144 @@
+// Package risk screens checkouts for fraud before their payment is captured.
+package risk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"interview/internal/ratelimit"
+	"strings"
+	"time"
+)
+
+const (
+	// ActionAllow captures the payment and checks the cart out
+	ActionAllow = "allow"
+	// ActionReview holds the order until staff approved or rejected it
+	ActionReview = "review"
+	// ActionBlock declines the payment
+	ActionBlock = "block"
+)
+
+// ErrInvalidAction is returned for actions other than ActionAllow, ActionReview and ActionBlock
+var ErrInvalidAction = errors.New("invalid risk action")
+
+type (
+	// Checker decides what happens to a checkout before its payment is captured
+	Checker interface {
+		Check(ctx context.Context, checkout Checkout) (Decision, error)
+	}
+
+	// Checkout is what a Checker judges
+	Checkout struct {
+		CartID    uint   `json:"cart_id"`
+		SessionID string `json:"session_id"`
+		UserID    *uint  `json:"user_id,omitempty"`
+		// IP is the address the customer confirmed the checkout from and IPCountry its country code as
+		// reported by the proxy in front of the shop, empty when unknown
+		IP        string `json:"ip"`
+		IPCountry string `json:"ip_country,omitempty"`
+		// ShippingCountry is the country code of the address the order is shipped to
+		ShippingCountry string  `json:"shipping_country"`
+		Amount          float64 `json:"amount"`
+		Currency        string  `json:"currency"`
+	}
+
+	// Decision is the Action taken on a checkout and the Reason for it, which staff see when reviewing
+	// the order
+	Decision struct {
+		Action string `json:"action"`
+		Reason string `json:"reason,omitempty"`
+	}
+
+	// Checkers runs every checker and takes the strictest of their decisions. Checks stop at the first
+	// checker that blocks.
+	Checkers []Checker
+
+	// Velocity takes Action on sessions and IP addresses confirming more than a number of checkouts per
+	// time window. Counts are kept in memory, so every server instance counts on its own.
+	Velocity struct {
+		Action   string
+		sessions *ratelimit.Limiter
+		ips      *ratelimit.Limiter
+	}
+
+	// CountryMismatch takes Action on checkouts shipped to another country than the customer's IP
+	// address is in. Checkouts of unknown IP countries are allowed.
+	CountryMismatch struct {
+		Action string
+	}
+)
+
+// ValidAction reports whether the action is ActionAllow, ActionReview or ActionBlock.
+func ValidAction(action string) bool {
+	return action == ActionAllow || action == ActionReview || action == ActionBlock
+}
+
+// severity orders the actions from the most lenient to the strictest
+func severity(action string) int {
+	switch action {
+	case ActionReview:
+		return 1
+	case ActionBlock:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// Check implements Checker.
+func (c Checkers) Check(ctx context.Context, checkout Checkout) (Decision, error) {
+	decision := Decision{Action: ActionAllow}
+	for _, checker := range c {
+		d, err := checker.Check(ctx, checkout)
+		if err != nil {
+			return Decision{}, err
+		}
+		if severity(d.Action) > severity(decision.Action) {
+			decision = d
+		}
+		if decision.Action == ActionBlock {
+			break
+		}
+	}
+	return decision, nil
+}
+
+// NewVelocity creates a Velocity check taking action on more than limit checkouts per session or IP
+// address every window.
+func NewVelocity(limit int, window time.Duration, action string) *Velocity {
+	return &Velocity{
+		Action:   action,
+		sessions: ratelimit.NewLimiter(limit, window),
+		ips:      ratelimit.NewLimiter(limit, window),
+	}
+}
+
+// SetClock replaces the clock of the check, for tests.
+func (v *Velocity) SetClock(now func() time.Time) {
+	v.sessions.SetClock(now)
+	v.ips.SetClock(now)
+}
+
+// Check implements Checker. Every check counts as a checkout of the session and IP address.
+func (v *Velocity) Check(_ context.Context, checkout Checkout) (Decision, error) {
+	// Both are counted, so a session switching addresses doesn't reset its count
+	sessionOK := v.sessions.Allow(checkout.SessionID)
+	ipOK := checkout.IP == "" || v.ips.Allow(checkout.IP)
+	switch {
+	case !sessionOK:
+		return Decision{Action: v.Action, Reason: "too many checkouts from this session"}, nil
+	case !ipOK:
+		return Decision{Action: v.Action, Reason: fmt.Sprintf("too many checkouts from %s", checkout.IP)}, nil
+	}
+	return Decision{Action: ActionAllow}, nil
+}
+
+// Check implements Checker.
+func (m CountryMismatch) Check(_ context.Context, checkout Checkout) (Decision, error) {
+	ip, shipping := strings.ToUpper(checkout.IPCountry), strings.ToUpper(checkout.ShippingCountry)
+	if ip == "" || shipping == "" || ip == shipping {
+		return Decision{Action: ActionAllow}, nil
+	}
+	return Decision{Action: m.Action, Reason: fmt.Sprintf("shipped to %s from an IP address in %s", shipping, ip)}, nil
+}
@@ -0,0 +1,138 @@
+package risk_test
+
+import (
+	"context"
+	"encoding/json"
+	"interview/internal/risk"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixed always takes the same decision
+type fixed risk.Decision
+
+func (f fixed) Check(context.Context, risk.Checkout) (risk.Decision, error) {
+	return risk.Decision(f), nil
+}
+
+func TestCheckers(t *testing.T) {
+	ctx := context.Background()
+	allow := fixed{Action: risk.ActionAllow}
+	review := fixed{Action: risk.ActionReview, Reason: "new customer"}
+	block := fixed{Action: risk.ActionBlock, Reason: "stolen card"}
+
+	tests := []struct {
+		name     string
+		checkers risk.Checkers
+		want     risk.Decision
+	}{
+		{name: "No Checkers Allow", want: risk.Decision{Action: risk.ActionAllow}},
+		{name: "All Allow", checkers: risk.Checkers{allow, allow}, want: risk.Decision{Action: risk.ActionAllow}},
+		{name: "Review Beats Allow", checkers: risk.Checkers{allow, review, allow}, want: risk.Decision(review)},
+		{name: "Block Beats Review", checkers: risk.Checkers{review, block}, want: risk.Decision(block)},
+		{name: "First Review Wins", checkers: risk.Checkers{review, fixed{Action: risk.ActionReview, Reason: "other"}}, want: risk.Decision(review)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := tt.checkers.Check(ctx, risk.Checkout{})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, decision)
+		})
+	}
+}
+
+func TestVelocity(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	velocity := risk.NewVelocity(2, time.Hour, risk.ActionBlock)
+	velocity.SetClock(func() time.Time { return now })
+
+	check := func(sessionID, ip string) risk.Decision {
+		decision, err := velocity.Check(ctx, risk.Checkout{SessionID: sessionID, IP: ip})
+		require.NoError(t, err)
+		return decision
+	}
+
+	t.Run("Limits Sessions", func(t *testing.T) {
+		assert.Equal(t, risk.ActionAllow, check("session-1", "192.0.2.1").Action)
+		assert.Equal(t, risk.ActionAllow, check("session-1", "192.0.2.2").Action)
+		decision := check("session-1", "192.0.2.3")
+		assert.Equal(t, risk.ActionBlock, decision.Action)
+		assert.Equal(t, "too many checkouts from this session", decision.Reason)
+	})
+
+	t.Run("Limits IP Addresses", func(t *testing.T) {
+		assert.Equal(t, risk.ActionAllow, check("session-2", "192.0.2.9").Action)
+		assert.Equal(t, risk.ActionAllow, check("session-3", "192.0.2.9").Action)
+		decision := check("session-4", "192.0.2.9")
+		assert.Equal(t, risk.ActionBlock, decision.Action)
+		assert.Equal(t, "too many checkouts from 192.0.2.9", decision.Reason)
+	})
+
+	t.Run("Window Resets", func(t *testing.T) {
+		now = now.Add(time.Hour)
+		assert.Equal(t, risk.ActionAllow, check("session-1", "192.0.2.9").Action)
+	})
+}
+
+func TestCountryMismatch(t *testing.T) {
+	mismatch := risk.CountryMismatch{Action: risk.ActionReview}
+	tests := []struct {
+		name      string
+		ipCountry string
+		shipping  string
+		want      risk.Decision
+	}{
+		{name: "Same Country", ipCountry: "de", shipping: "DE", want: risk.Decision{Action: risk.ActionAllow}},
+		{name: "Unknown IP Country", shipping: "DE", want: risk.Decision{Action: risk.ActionAllow}},
+		{
+			name: "Other Country", ipCountry: "nl", shipping: "DE",
+			want: risk.Decision{Action: risk.ActionReview, Reason: "shipped to DE from an IP address in NL"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := mismatch.Check(context.Background(), risk.Checkout{IPCountry: tt.ipCountry, ShippingCountry: tt.shipping})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, decision)
+		})
+	}
+}
+
+func TestHTTPChecker(t *testing.T) {
+	var received risk.Checkout
+	response := `{"action": "review", "reason": "card testing"}`
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(response))
+	}))
+	defer server.Close()
+	checker := risk.NewHTTPChecker(server.URL)
+	ctx := context.Background()
+
+	t.Run("Returns The Decision Of The Service", func(t *testing.T) {
+		decision, err := checker.Check(ctx, risk.Checkout{CartID: 7, IP: "192.0.2.1", Amount: 40, Currency: "EUR"})
+		require.NoError(t, err)
+		assert.Equal(t, risk.Decision{Action: risk.ActionReview, Reason: "card testing"}, decision)
+		assert.Equal(t, risk.Checkout{CartID: 7, IP: "192.0.2.1", Amount: 40, Currency: "EUR"}, received)
+	})
+
+	t.Run("Rejects Unknown Actions", func(t *testing.T) {
+		response = `{"action": "maybe"}`
+		_, err := checker.Check(ctx, risk.Checkout{})
+		assert.ErrorIs(t, err, risk.ErrInvalidAction)
+	})
+
+	t.Run("Fails On Errors", func(t *testing.T) {
+		status = http.StatusServiceUnavailable
+		_, err := checker.Check(ctx, risk.Checkout{})
+		assert.Error(t, err)
+	})
+}
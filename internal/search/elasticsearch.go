@@ -0,0 +1,191 @@
+// Package search implements the product search index on Elasticsearch (or OpenSearch) through its
+// REST API, giving the catalog fuzzy, typo-tolerant name search.
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"interview/internal/product"
+	"interview/internal/repo"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// indexMapping indexes the name both as analyzed text for fuzzy matching and as a keyword for sorting.
+const indexMapping = `{
+  "mappings": {
+    "properties": {
+      "name": {"type": "text", "fields": {"keyword": {"type": "keyword"}}},
+      "price": {"type": "double"},
+      "created_at": {"type": "date"}
+    }
+  }
+}`
+
+// Elasticsearch implements repo.ProductIndex.
+type Elasticsearch struct {
+	BaseURL string
+	Index   string
+	Client  *http.Client
+}
+
+var _ repo.ProductIndex = (*Elasticsearch)(nil)
+
+type document struct {
+	Name      string    `json:"name"`
+	Price     float64   `json:"price"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewElasticsearch creates a client for the given index with a client that times out after a few seconds.
+func NewElasticsearch(baseURL, index string) *Elasticsearch {
+	return &Elasticsearch{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		Index:   index,
+		Client:  &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// EnsureIndex creates the index with its mapping unless it already exists.
+func (e *Elasticsearch) EnsureIndex(ctx context.Context) error {
+	resp, err := e.do(ctx, http.MethodPut, "/"+e.Index, []byte(indexMapping))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode == http.StatusBadRequest && bytes.Contains(body, []byte("resource_already_exists_exception")) {
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to create search index: status %d: %s", resp.StatusCode, body)
+	}
+	return nil
+}
+
+// IndexProduct implements repo.ProductIndex.
+func (e *Elasticsearch) IndexProduct(ctx context.Context, p product.Product) error {
+	doc, err := json.Marshal(document{Name: p.Name, Price: p.Price, CreatedAt: p.CreatedAt})
+	if err != nil {
+		return err
+	}
+	resp, err := e.do(ctx, http.MethodPut, "/"+e.Index+"/_doc/"+strconv.FormatUint(uint64(p.ID), 10), doc)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("failed to index product: status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SearchProducts implements repo.ProductIndex. The name is matched with fuzziness AUTO, which
+// allows one typo in words of 3 to 5 characters and two in longer words.
+func (e *Elasticsearch) SearchProducts(ctx context.Context, q repo.ProductQuery) ([]uint, int64, error) {
+	body, err := json.Marshal(searchRequest(q))
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := e.do(ctx, http.MethodPost, "/"+e.Index+"/_search", body)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("search failed: status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Hits struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				ID string `json:"_id"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode search response: %w", err)
+	}
+
+	ids := make([]uint, 0, len(result.Hits.Hits))
+	for _, hit := range result.Hits.Hits {
+		id, err := strconv.ParseUint(hit.ID, 10, 32)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid document ID %q", hit.ID)
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, result.Hits.Total.Value, nil
+}
+
+// searchRequest builds the query DSL for the catalog query.
+func searchRequest(q repo.ProductQuery) map[string]interface{} {
+	must := []interface{}{map[string]interface{}{"match_all": map[string]interface{}{}}}
+	if q.Search != "" {
+		must = []interface{}{map[string]interface{}{
+			"match": map[string]interface{}{
+				"name": map[string]interface{}{"query": q.Search, "fuzziness": "AUTO", "operator": "and"},
+			},
+		}}
+	}
+
+	priceRange := map[string]interface{}{}
+	if q.MinPrice != nil {
+		priceRange["gte"] = *q.MinPrice
+	}
+	if q.MaxPrice != nil {
+		priceRange["lte"] = *q.MaxPrice
+	}
+	filter := []interface{}{}
+	if len(priceRange) > 0 {
+		filter = append(filter, map[string]interface{}{"range": map[string]interface{}{"price": priceRange}})
+	}
+
+	var sort []interface{}
+	switch q.Sort {
+	case repo.SortByPriceAsc:
+		sort = []interface{}{map[string]string{"price": "asc"}, map[string]string{"name.keyword": "asc"}}
+	case repo.SortByPriceDesc:
+		sort = []interface{}{map[string]string{"price": "desc"}, map[string]string{"name.keyword": "asc"}}
+	case repo.SortByNewest:
+		sort = []interface{}{map[string]string{"created_at": "desc"}}
+	case repo.SortByName:
+		sort = []interface{}{map[string]string{"name.keyword": "asc"}}
+	default:
+		sort = []interface{}{"_score", map[string]string{"name.keyword": "asc"}}
+	}
+
+	return map[string]interface{}{
+		"from":             (q.Page - 1) * q.PerPage,
+		"size":             q.PerPage,
+		"track_total_hits": true,
+		"_source":          false,
+		"query":            map[string]interface{}{"bool": map[string]interface{}{"must": must, "filter": filter}},
+		"sort":             sort,
+	}
+}
+
+func (e *Elasticsearch) do(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, e.BaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("search service request failed: %w", err)
+	}
+	return resp, nil
+}
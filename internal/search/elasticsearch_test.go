@@ -0,0 +1,73 @@
+package search_test
+
+import (
+	"context"
+	"encoding/json"
+	"interview/internal/product"
+	"interview/internal/repo"
+	"interview/internal/search"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestElasticsearch(t *testing.T) {
+	var requests []*http.Request
+	var bodies []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		bodies = append(bodies, body)
+
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/products":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"type":"resource_already_exists_exception"}}`))
+		case r.URL.Path == "/products/_search":
+			_, _ = w.Write([]byte(`{"hits":{"total":{"value":7},"hits":[{"_id":"3"},{"_id":"1"}]}}`))
+		default:
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer server.Close()
+
+	index := search.NewElasticsearch(server.URL+"/", "products")
+
+	t.Run("Existing Index Is Kept", func(t *testing.T) {
+		require.NoError(t, index.EnsureIndex(context.Background()))
+	})
+
+	t.Run("Index Product", func(t *testing.T) {
+		err := index.IndexProduct(context.Background(), product.Product{Model: gorm.Model{ID: 5}, Name: "shoe", Price: 10})
+		require.NoError(t, err)
+
+		last := len(requests) - 1
+		assert.Equal(t, http.MethodPut, requests[last].Method)
+		assert.Equal(t, "/products/_doc/5", requests[last].URL.Path)
+		assert.Equal(t, "shoe", bodies[last]["name"])
+	})
+
+	t.Run("Fuzzy Search", func(t *testing.T) {
+		min := 5.0
+		ids, total, err := index.SearchProducts(context.Background(), repo.ProductQuery{
+			Search: "sheo", MinPrice: &min, Sort: repo.SortByRelevance, Page: 2, PerPage: 10,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []uint{3, 1}, ids)
+		assert.Equal(t, int64(7), total)
+
+		body := bodies[len(bodies)-1]
+		assert.Equal(t, 10.0, body["from"])
+		query := body["query"].(map[string]interface{})["bool"].(map[string]interface{})
+		match := query["must"].([]interface{})[0].(map[string]interface{})["match"].(map[string]interface{})["name"].(map[string]interface{})
+		assert.Equal(t, "sheo", match["query"])
+		assert.Equal(t, "AUTO", match["fuzziness"])
+		assert.Len(t, query["filter"], 1)
+		assert.Equal(t, "_score", body["sort"].([]interface{})[0])
+	})
+}
@@ -0,0 +1,60 @@
+// Package seed populates the database with sample data for local development and integration tests.
+package seed
+
+import (
+	"fmt"
+	"interview/internal/cart"
+	"interview/internal/pricing"
+	"interview/internal/repo"
+	"sort"
+)
+
+// demoCarts maps the session IDs of the demo carts to their items and quantities
+var demoCarts = map[string]map[string]int{
+	"demo-session-1": {"shoe": 2, "bag": 1},
+	"demo-session-2": {"watch": 1},
+	"demo-session-3": {},
+}
+
+// Run creates the sample products and demo carts. It is idempotent: products are upserted and demo
+// carts that already contain items are left untouched.
+func Run(r *repo.Repository) error {
+	if err := Products(r); err != nil {
+		return err
+	}
+	prices := pricing.DefaultPrices()
+
+	for sessionID, items := range demoCarts {
+		c, err := r.GetOrCreateCart(sessionID, cart.DefaultName)
+		if err != nil {
+			return fmt.Errorf("failed to seed cart %s: %w", sessionID, err)
+		}
+		if len(c.CartItems) > 0 {
+			continue
+		}
+		for product, quantity := range items {
+			if err := r.AddCartItem(c.ID, product, quantity, prices[product]); err != nil {
+				return fmt.Errorf("failed to seed cart %s: %w", sessionID, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Products creates the sample products at the prices of pricing.DefaultPrices, or resets their prices
+func Products(r *repo.Repository) error {
+	prices := pricing.DefaultPrices()
+	names := make([]string, 0, len(prices))
+	for name := range prices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, err := r.UpsertProduct(name, prices[name]); err != nil {
+			return fmt.Errorf("failed to seed product %s: %w", name, err)
+		}
+	}
+	return nil
+}
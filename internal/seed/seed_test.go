@@ -0,0 +1,33 @@
+package seed_test
+
+import (
+	cartpkg "interview/internal/cart"
+	"interview/internal/repo"
+	"interview/internal/seed"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestRun(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, repo.Migrate(db))
+	r := repo.NewRepository(db)
+
+	// Running twice must not duplicate data
+	require.NoError(t, seed.Run(r))
+	require.NoError(t, seed.Run(r))
+
+	products, err := r.ListProducts()
+	require.NoError(t, err)
+	assert.Len(t, products, 4)
+
+	c, err := r.GetExistingCart("demo-session-1", cartpkg.DefaultName)
+	require.NoError(t, err)
+	assert.Len(t, c.CartItems, 2)
+	assert.Equal(t, 50.0, c.Total)
+}
@@ -0,0 +1,501 @@
+// Package service holds the cart use cases shared by the HTML and JSON handlers. Each operation
+// validates its input, looks up what it needs and runs its repository calls in a single database
+// transaction, so handlers only deal with HTTP.
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	cartpkg "interview/internal/cart"
+	"interview/internal/pricing"
+	productpkg "interview/internal/product"
+	"interview/internal/repo"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidProduct is returned when adding a product that isn't sold
+	ErrInvalidProduct = errors.New("invalid product selected")
+	// ErrMissingCode is returned when redeeming an empty gift card code
+	ErrMissingCode = errors.New("gift card code is required")
+	// ErrMissingReferralCode is returned when applying an empty referral code
+	ErrMissingReferralCode = errors.New("referral code is required")
+	// ErrPricesUnavailable is returned when the price of a product can't be looked up
+	ErrPricesUnavailable = errors.New("prices are unavailable")
+)
+
+// CartService changes the cart of a session
+type CartService struct {
+	repo *repo.Repository
+	// locks serializes changes to the same cart within this process
+	locks *keyedMutex
+	// reads collapses concurrent reads of the same cart or product into one query
+	reads *flightGroup
+	// queryTimeout bounds the database work of each operation, unbounded when 0
+	queryTimeout time.Duration
+	// undoWindow is how long changes can be undone
+	undoWindow time.Duration
+	// reservationTTL is how long adding limited items holds their stock, not at all when 0
+	reservationTTL time.Duration
+}
+
+// defaultUndoWindow is how long changes can be undone unless SetUndoWindow says otherwise
+const defaultUndoWindow = 5 * time.Minute
+
+// NewCartService creates a CartService pricing items at their catalog price, see
+// repo.Repository.CatalogPrice
+func NewCartService(r *repo.Repository) *CartService {
+	return &CartService{
+		repo:       r,
+		locks:      newKeyedMutex(),
+		reads:      newFlightGroup(),
+		undoWindow: defaultUndoWindow,
+	}
+}
+
+// SetQueryTimeout bounds the database work of each operation, so requests give up on a database that
+// stopped answering instead of waiting for it. 0 doesn't bound it.
+func (s *CartService) SetQueryTimeout(timeout time.Duration) {
+	s.queryTimeout = timeout
+}
+
+// SetUndoWindow sets how long after a change to a cart Undo can still undo it
+func (s *CartService) SetUndoWindow(window time.Duration) {
+	s.undoWindow = window
+}
+
+// SetReservationTTL makes AddItem hold the stock of limited items, those of products whose stock is
+// tracked, for the cart for ttl, renewed by every item added. 0 doesn't hold stock.
+func (s *CartService) SetReservationTTL(ttl time.Duration) {
+	s.reservationTTL = ttl
+}
+
+// queries returns the repository running the queries of an operation with ctx, with the query timeout
+// as its deadline. The returned function releases the deadline.
+func (s *CartService) queries(ctx context.Context) (*repo.Repository, context.CancelFunc) {
+	if s.queryTimeout <= 0 {
+		return s.repo.WithContext(ctx), func() {}
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	return s.repo.WithContext(ctx), cancel
+}
+
+// Price returns the current price of a product for anonymous customers, so the service can serve as a
+// pricing.Provider
+func (s *CartService) Price(ctx context.Context, product string) (float64, error) {
+	return s.CustomerPrice(ctx, nil, product)
+}
+
+// GetCart returns the named cart of the session, creating it if needed. Concurrent calls for the same
+// cart, e.g. during a flash sale, share one lookup and the returned cart, which callers must not change.
+func (s *CartService) GetCart(ctx context.Context, sessionID, cartName string) (*cartpkg.Cart, error) {
+	c, err := s.reads.Do(readKey(ctx, "cart", sessionID, cartName), func() (interface{}, error) {
+		// The lookup is shared, so it isn't cancelled with the request that happened to start it
+		r, cancel := s.queries(context.WithoutCancel(ctx))
+		defer cancel()
+		return r.GetOrCreateCart(sessionID, cartName)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.(*cartpkg.Cart), nil
+}
+
+// GetProduct returns the product with the given ID through the product cache. Concurrent calls for the
+// same product share one lookup and the returned product, which callers must not change.
+func (s *CartService) GetProduct(ctx context.Context, id uint) (*productpkg.Product, error) {
+	p, err := s.reads.Do(readKey(ctx, "product", strconv.FormatUint(uint64(id), 10)), func() (interface{}, error) {
+		r, cancel := s.queries(context.WithoutCancel(ctx))
+		defer cancel()
+		return r.GetCachedProduct(id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p.(*productpkg.Product), nil
+}
+
+// CustomerPrice returns the current price of a product for the logged-in user, nil for anonymous
+// customers: the price of its active price override or on the price list of their customer group, or
+// the base price of the product, see repo.Repository.CatalogPrice. It fails with
+// pricing.ErrProductNotFound for products not in the catalog.
+func (s *CartService) CustomerPrice(ctx context.Context, userID *uint, product string) (float64, error) {
+	r, cancel := s.queries(ctx)
+	defer cancel()
+	price, ok, err := r.CatalogPrice(userID, product, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", pricing.ErrProductNotFound, product)
+	}
+	return price, nil
+}
+
+// AddItem adds quantity items of the product at its current price for the user, nil for anonymous
+// customers, to the named open cart of the session, creating the cart if needed
+func (s *CartService) AddItem(ctx context.Context, sessionID string, userID *uint, cartName, product string, quantity int) error {
+	if quantity < 1 {
+		return cartpkg.ErrInvalidQuantity
+	}
+	if quantity > cartpkg.MaxQuantity {
+		return cartpkg.ErrQuantityTooLarge
+	}
+
+	// The price is looked up before the transaction so it isn't held open during the request
+	price, err := s.CustomerPrice(ctx, userID, product)
+	if errors.Is(err, pricing.ErrProductNotFound) {
+		return ErrInvalidProduct
+	} else if err != nil {
+		return fmt.Errorf("%w: %v", ErrPricesUnavailable, err)
+	}
+
+	defer s.locks.Lock(sessionID)()
+	r, cancel := s.queries(ctx)
+	defer cancel()
+	return r.Transaction(func(tx *repo.Repository) error {
+		if s.reservationTTL <= 0 {
+			if err := tx.CheckStock(product, quantity); err != nil {
+				return err
+			}
+		}
+		userCart, err := tx.GetOrCreateCart(sessionID, cartName)
+		if err != nil {
+			return err
+		}
+		if s.reservationTTL > 0 {
+			if err := tx.ReserveStock(userCart.ID, product, quantity, time.Now().Add(s.reservationTTL)); err != nil {
+				return err
+			}
+		}
+		return tx.AddCartItem(userCart.ID, product, quantity, price)
+	})
+}
+
+// AddBundle adds quantity of the bundle with the given ID at its current price for the user, nil for
+// anonymous customers, to the named open cart of the session, creating the cart if needed, and returns
+// the bundle. The bundle is expanded to one item per component, see repo.AddBundle. It fails with
+// ErrInvalidProduct for products that aren't bundles.
+func (s *CartService) AddBundle(ctx context.Context, sessionID string, userID *uint, cartName string, bundleID uint, quantity int) (*productpkg.Product, error) {
+	if quantity < 1 {
+		return nil, cartpkg.ErrInvalidQuantity
+	}
+	if quantity > cartpkg.MaxQuantity {
+		return nil, cartpkg.ErrQuantityTooLarge
+	}
+
+	r, cancel := s.queries(ctx)
+	defer cancel()
+	parts, err := r.ListBundleComponents(bundleID)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && len(parts) == 0) {
+		return nil, ErrInvalidProduct
+	} else if err != nil {
+		return nil, err
+	}
+	bundle, err := r.GetProduct(bundleID)
+	if err != nil {
+		return nil, err
+	}
+	price, err := s.CustomerPrice(ctx, userID, bundle.Name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPricesUnavailable, err)
+	}
+
+	defer s.locks.Lock(sessionID)()
+	err = r.Transaction(func(tx *repo.Repository) error {
+		if s.reservationTTL <= 0 {
+			for _, part := range parts {
+				if err := tx.CheckStock(part.Product.Name, part.Quantity*quantity); err != nil {
+					return err
+				}
+			}
+		}
+		userCart, err := tx.GetOrCreateCart(sessionID, cartName)
+		if err != nil {
+			return err
+		}
+		if s.reservationTTL > 0 {
+			until := time.Now().Add(s.reservationTTL)
+			for _, part := range parts {
+				if err := tx.ReserveStock(userCart.ID, part.Product.Name, part.Quantity*quantity, until); err != nil {
+					return err
+				}
+			}
+		}
+		return tx.AddBundle(userCart.ID, bundle.ID, quantity, price)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+// RemoveItem removes an item from the named cart of the session, with the other items of its bundle, and
+// returns the removed item
+func (s *CartService) RemoveItem(ctx context.Context, sessionID, cartName string, itemID uint) (*cartpkg.CartItem, error) {
+	defer s.locks.Lock(sessionID)()
+	r, cancel := s.queries(ctx)
+	defer cancel()
+
+	var removed *cartpkg.CartItem
+	err := r.Transaction(func(tx *repo.Repository) error {
+		userCart, err := tx.GetExistingCart(sessionID, cartName)
+		if err != nil {
+			return err
+		}
+
+		// The item must belong to the session's cart
+		item, err := tx.GetCartItem(userCart.ID, itemID)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.RemoveCartItem(userCart.ID, itemID); err != nil {
+			return err
+		}
+		removed = item
+		return nil
+	})
+	return removed, err
+}
+
+// RestoreItem puts an item removed from the named cart of the session back and returns it. It fails with
+// productpkg.ErrOutOfStock when fewer units of it are left than it holds.
+func (s *CartService) RestoreItem(ctx context.Context, sessionID, cartName string, itemID uint) (*cartpkg.CartItem, error) {
+	defer s.locks.Lock(sessionID)()
+	r, cancel := s.queries(ctx)
+	defer cancel()
+
+	var restored *cartpkg.CartItem
+	err := r.Transaction(func(tx *repo.Repository) error {
+		userCart, err := tx.GetExistingCart(sessionID, cartName)
+		if err != nil {
+			return err
+		}
+		items, err := tx.ListRemovedItems(userCart.ID, itemID)
+		if err != nil {
+			return err
+		}
+		if err := s.restock(tx, userCart.ID, items); err != nil {
+			return err
+		}
+		restored, err = tx.RestoreCartItem(userCart.ID, itemID)
+		return err
+	})
+	return restored, err
+}
+
+// Undo undoes the latest change to the named cart of the session made within the undo window, putting a
+// removed item back or taking added units out again, and returns the change undone. Putting an item back
+// fails with productpkg.ErrOutOfStock like RestoreItem.
+func (s *CartService) Undo(ctx context.Context, sessionID, cartName string) (*cartpkg.Change, error) {
+	defer s.locks.Lock(sessionID)()
+	r, cancel := s.queries(ctx)
+	defer cancel()
+
+	var undone *cartpkg.Change
+	err := r.Transaction(func(tx *repo.Repository) error {
+		userCart, err := tx.GetExistingCart(sessionID, cartName)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		change, err := tx.UndoableChange(userCart.ID, now.Add(-s.undoWindow))
+		if err != nil {
+			return err
+		}
+		if change.Action == cartpkg.ChangeRemoved {
+			items, err := tx.ListRemovedItems(userCart.ID, change.ItemID)
+			if err != nil {
+				return err
+			}
+			if err := s.restock(tx, userCart.ID, items); err != nil {
+				return err
+			}
+		}
+		undone, err = tx.UndoCartChange(userCart.ID, now.Add(-s.undoWindow), now)
+		return err
+	})
+	return undone, err
+}
+
+// restock checks the stock of removed items put back into the cart, or reserves it when carts hold their
+// stock, like AddItem does for the units it adds
+func (s *CartService) restock(tx *repo.Repository, cartID uint, items []cartpkg.CartItem) error {
+	until := time.Now().Add(s.reservationTTL)
+	for _, item := range items {
+		if s.reservationTTL <= 0 {
+			if err := tx.CheckStock(item.ProductName, item.Quantity); err != nil {
+				return err
+			}
+		} else if err := tx.ReserveStock(cartID, item.ProductName, item.Quantity, until); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReorderItems arranges the items of the named cart of the session in the order of their IDs, see
+// repo.Repository.ReorderCartItems
+func (s *CartService) ReorderItems(ctx context.Context, sessionID, cartName string, itemIDs []uint) error {
+	defer s.locks.Lock(sessionID)()
+	r, cancel := s.queries(ctx)
+	defer cancel()
+
+	return r.Transaction(func(tx *repo.Repository) error {
+		userCart, err := tx.GetExistingCart(sessionID, cartName)
+		if err != nil {
+			return err
+		}
+		return tx.ReorderCartItems(userCart.ID, itemIDs)
+	})
+}
+
+// SetItemSubscription subscribes to an item of the named cart of the session every days once the cart
+// is checked out, 0 days making it a one-time purchase again
+func (s *CartService) SetItemSubscription(ctx context.Context, sessionID, cartName string, itemID uint, days int) error {
+	defer s.locks.Lock(sessionID)()
+	r, cancel := s.queries(ctx)
+	defer cancel()
+
+	return r.Transaction(func(tx *repo.Repository) error {
+		userCart, err := tx.GetExistingCart(sessionID, cartName)
+		if err != nil {
+			return err
+		}
+		return tx.SetItemSubscription(userCart.ID, itemID, days)
+	})
+}
+
+// SetCartMetadata merges the changes into the metadata of the named cart of the session, see
+// cart.Metadata.Merge
+func (s *CartService) SetCartMetadata(ctx context.Context, sessionID, cartName string, changes cartpkg.Metadata) error {
+	defer s.locks.Lock(sessionID)()
+	r, cancel := s.queries(ctx)
+	defer cancel()
+
+	return r.Transaction(func(tx *repo.Repository) error {
+		userCart, err := tx.GetExistingCart(sessionID, cartName)
+		if err != nil {
+			return err
+		}
+		_, err = tx.SetCartMetadata(userCart.ID, changes)
+		return err
+	})
+}
+
+// SetItemMetadata merges the changes into the metadata of an item of the named cart of the session, see
+// cart.Metadata.Merge
+func (s *CartService) SetItemMetadata(ctx context.Context, sessionID, cartName string, itemID uint, changes cartpkg.Metadata) error {
+	defer s.locks.Lock(sessionID)()
+	r, cancel := s.queries(ctx)
+	defer cancel()
+
+	return r.Transaction(func(tx *repo.Repository) error {
+		userCart, err := tx.GetExistingCart(sessionID, cartName)
+		if err != nil {
+			return err
+		}
+		_, err = tx.SetItemMetadata(userCart.ID, itemID, changes)
+		return err
+	})
+}
+
+// RedeemGiftCard applies the balance of a gift card to the named cart of the session and returns the
+// amount applied
+func (s *CartService) RedeemGiftCard(ctx context.Context, sessionID, cartName, code string) (float64, error) {
+	if strings.TrimSpace(code) == "" {
+		return 0, ErrMissingCode
+	}
+
+	defer s.locks.Lock(sessionID)()
+	r, cancel := s.queries(ctx)
+	defer cancel()
+
+	var amount float64
+	err := r.Transaction(func(tx *repo.Repository) error {
+		userCart, err := tx.GetExistingCart(sessionID, cartName)
+		if err != nil {
+			return err
+		}
+
+		amount, err = tx.RedeemGiftCard(userCart.ID, code)
+		return err
+	})
+	return amount, err
+}
+
+// ApplyReferralCode records the referral code on the named cart of the session, so its owner is
+// rewarded when the cart is checked out
+func (s *CartService) ApplyReferralCode(ctx context.Context, sessionID, cartName, code string) error {
+	if strings.TrimSpace(code) == "" {
+		return ErrMissingReferralCode
+	}
+
+	defer s.locks.Lock(sessionID)()
+	r, cancel := s.queries(ctx)
+	defer cancel()
+	return r.Transaction(func(tx *repo.Repository) error {
+		userCart, err := tx.GetExistingCart(sessionID, cartName)
+		if err != nil {
+			return err
+		}
+		return tx.ApplyReferralCode(userCart.ID, code)
+	})
+}
+
+// CreateCart creates an empty cart with the given name for the session
+func (s *CartService) CreateCart(ctx context.Context, sessionID, name string) (*cartpkg.Cart, error) {
+	defer s.locks.Lock(sessionID)()
+	r, cancel := s.queries(ctx)
+	defer cancel()
+	return r.CreateCart(sessionID, name)
+}
+
+// RenameCart renames the named open cart of the session and returns the new name
+func (s *CartService) RenameCart(ctx context.Context, sessionID, name, newName string) (string, error) {
+	defer s.locks.Lock(sessionID)()
+	r, cancel := s.queries(ctx)
+	defer cancel()
+	return r.RenameCart(sessionID, name, newName)
+}
+
+// DeleteCart deletes the named open cart of the session
+func (s *CartService) DeleteCart(ctx context.Context, sessionID, name string) error {
+	defer s.locks.Lock(sessionID)()
+	r, cancel := s.queries(ctx)
+	defer cancel()
+	return r.DeleteCart(sessionID, name)
+}
+
+// RefreshPrices re-fetches the prices of the cart items for the user, nil for anonymous customers, when
+// they were last checked more than maxAge ago and reports whether any of them changed. Items of products
+// that no longer exist keep their price.
+func (s *CartService) RefreshPrices(ctx context.Context, userCart *cartpkg.Cart, userID *uint, maxAge time.Duration) (bool, error) {
+	if maxAge <= 0 || len(userCart.CartItems) == 0 || time.Since(userCart.PricesCheckedAt()) < maxAge {
+		return false, nil
+	}
+
+	prices := make(map[string]float64, len(userCart.CartItems))
+	for _, item := range userCart.CartItems {
+		price, err := s.CustomerPrice(ctx, userID, item.ProductName)
+		if errors.Is(err, pricing.ErrProductNotFound) {
+			log.Printf("Keeping the price of %s, which is no longer sold", item.ProductName)
+			continue
+		} else if err != nil {
+			return false, fmt.Errorf("failed to look up price of %s: %w", item.ProductName, err)
+		}
+		prices[item.ProductName] = price
+	}
+
+	defer s.locks.Lock(userCart.SessionID)()
+	r, cancel := s.queries(ctx)
+	defer cancel()
+	return r.RefreshCartPrices(userCart.ID, prices, time.Now())
+}
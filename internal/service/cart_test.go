@@ -0,0 +1,278 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"interview/internal/cart"
+	"interview/internal/pricelist"
+	"interview/internal/pricing"
+	productpkg "interview/internal/product"
+	"interview/internal/repo"
+	"interview/internal/service"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type failingProvider struct{}
+
+func (failingProvider) Price(context.Context, string) (float64, error) {
+	return 0, errors.New("price service is down")
+}
+
+func setupService(t *testing.T) (*service.CartService, *repo.Repository) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, repo.Migrate(db))
+	cartRepo := repo.NewRepository(db)
+	for name, price := range map[string]float64{"shoe": 10, "bag": 25} {
+		_, err := cartRepo.UpsertProduct(name, price)
+		require.NoError(t, err)
+	}
+	return service.NewCartService(cartRepo), cartRepo
+}
+
+func TestCartService(t *testing.T) {
+	ctx := context.Background()
+	carts, cartRepo := setupService(t)
+
+	t.Run("Add Item", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			product  string
+			quantity int
+			err      error
+		}{
+			{"Valid Item", "shoe", 2, nil},
+			{"Unknown Product", "hat", 1, service.ErrInvalidProduct},
+			{"Zero Quantity", "shoe", 0, cart.ErrInvalidQuantity},
+			{"Quantity Above The Maximum", "shoe", 2000000000, cart.ErrQuantityTooLarge},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				err := carts.AddItem(ctx, "session-1", nil, cart.DefaultName, tt.product, tt.quantity)
+				if tt.err != nil {
+					assert.ErrorIs(t, err, tt.err)
+				} else {
+					assert.NoError(t, err)
+				}
+			})
+		}
+
+		c, err := cartRepo.GetExistingCart("session-1", cart.DefaultName)
+		require.NoError(t, err)
+		require.Len(t, c.CartItems, 1)
+		assert.Equal(t, 20.0, c.Total)
+	})
+
+	t.Run("Provider Overrides Catalog Prices", func(t *testing.T) {
+		cartRepo.SetPriceProvider(pricing.StaticProvider{"bag": 22, "hat": 5})
+		defer cartRepo.SetPriceProvider(nil)
+
+		require.NoError(t, carts.AddItem(ctx, "session-provider", nil, cart.DefaultName, "bag", 1))
+		require.NoError(t, carts.AddItem(ctx, "session-provider", nil, cart.DefaultName, "shoe", 1))
+		assert.ErrorIs(t, carts.AddItem(ctx, "session-provider", nil, cart.DefaultName, "hat", 1), service.ErrInvalidProduct,
+			"products not in the catalog aren't sold")
+		c, err := cartRepo.GetExistingCart("session-provider", cart.DefaultName)
+		require.NoError(t, err)
+		require.Len(t, c.CartItems, 2)
+		assert.Equal(t, 22.0, c.CartItems[0].Price)
+		assert.Equal(t, 10.0, c.CartItems[1].Price, "products the provider doesn't know sell at their catalog price")
+	})
+
+	t.Run("Prices Unavailable", func(t *testing.T) {
+		cartRepo.SetPriceProvider(failingProvider{})
+		defer cartRepo.SetPriceProvider(nil)
+
+		err := carts.AddItem(ctx, "session-2", nil, cart.DefaultName, "shoe", 1)
+		assert.ErrorIs(t, err, service.ErrPricesUnavailable)
+		// The cart isn't created when the item can't be added
+		_, err = cartRepo.GetExistingCart("session-2", cart.DefaultName)
+		assert.ErrorIs(t, err, cart.ErrCartNotFound)
+	})
+
+	t.Run("Add Item At Price List Price", func(t *testing.T) {
+		user, err := cartRepo.FindOrCreateUser("github", "1", "buyer@example.com", "Buyer")
+		require.NoError(t, err)
+		require.NoError(t, cartRepo.SetUserGroup(user.ID, pricelist.GroupWholesale))
+		wholesale := pricelist.PriceList{Name: "Wholesale", CustomerGroup: pricelist.GroupWholesale}
+		require.NoError(t, cartRepo.CreatePriceList(&wholesale))
+		require.NoError(t, cartRepo.SetListPrice(wholesale.ID, "shoe", 7.5))
+
+		require.NoError(t, carts.AddItem(ctx, "session-wholesale", &user.ID, cart.DefaultName, "shoe", 2))
+		require.NoError(t, carts.AddItem(ctx, "session-wholesale", &user.ID, cart.DefaultName, "bag", 1))
+		c, err := cartRepo.GetExistingCart("session-wholesale", cart.DefaultName)
+		require.NoError(t, err)
+		require.Len(t, c.CartItems, 2)
+		assert.Equal(t, 7.5, c.CartItems[0].Price)
+		assert.Equal(t, 25.0, c.CartItems[1].Price, "products not on the list sell at their base price")
+
+		// Anonymous customers buy at retail prices
+		require.NoError(t, carts.AddItem(ctx, "session-retail", nil, cart.DefaultName, "shoe", 1))
+		c, err = cartRepo.GetExistingCart("session-retail", cart.DefaultName)
+		require.NoError(t, err)
+		assert.Equal(t, 10.0, c.CartItems[0].Price)
+	})
+
+	t.Run("Remove Item", func(t *testing.T) {
+		_, err := carts.RemoveItem(ctx, "session-unknown", cart.DefaultName, 1)
+		assert.ErrorIs(t, err, cart.ErrCartNotFound)
+
+		require.NoError(t, carts.AddItem(ctx, "session-3", nil, cart.DefaultName, "bag", 1))
+		other, err := cartRepo.GetExistingCart("session-3", cart.DefaultName)
+		require.NoError(t, err)
+		// Items of other carts can't be removed
+		_, err = carts.RemoveItem(ctx, "session-1", cart.DefaultName, other.CartItems[0].ID)
+		assert.ErrorIs(t, err, cart.ErrItemNotFound)
+
+		item, err := carts.RemoveItem(ctx, "session-3", cart.DefaultName, other.CartItems[0].ID)
+		require.NoError(t, err)
+		assert.Equal(t, "bag", item.ProductName)
+		other, err = cartRepo.GetExistingCart("session-3", cart.DefaultName)
+		require.NoError(t, err)
+		assert.Empty(t, other.CartItems)
+	})
+
+	t.Run("Redeem Gift Card", func(t *testing.T) {
+		_, err := carts.RedeemGiftCard(ctx, "session-1", cart.DefaultName, " ")
+		assert.ErrorIs(t, err, service.ErrMissingCode)
+		_, err = carts.RedeemGiftCard(ctx, "session-unknown", cart.DefaultName, "gift-5")
+		assert.ErrorIs(t, err, cart.ErrCartNotFound)
+
+		_, err = cartRepo.CreateGiftCard("gift-5", 5)
+		require.NoError(t, err)
+		amount, err := carts.RedeemGiftCard(ctx, "session-1", cart.DefaultName, "gift-5")
+		require.NoError(t, err)
+		assert.Equal(t, 5.0, amount)
+	})
+}
+
+func TestRepositoryTransaction(t *testing.T) {
+	_, cartRepo := setupService(t)
+
+	err := cartRepo.Transaction(func(tx *repo.Repository) error {
+		if _, err := tx.GetOrCreateCart("rolled-back", cart.DefaultName); err != nil {
+			return err
+		}
+		return errors.New("abort")
+	})
+	require.EqualError(t, err, "abort")
+	_, err = cartRepo.GetExistingCart("rolled-back", cart.DefaultName)
+	assert.ErrorIs(t, err, cart.ErrCartNotFound)
+}
+
+func TestQueryTimeout(t *testing.T) {
+	ctx := context.Background()
+	carts, _ := setupService(t)
+
+	t.Run("Operations Give Up After The Timeout", func(t *testing.T) {
+		carts.SetQueryTimeout(time.Nanosecond)
+		t.Cleanup(func() { carts.SetQueryTimeout(0) })
+		_, err := carts.CreateCart(ctx, "session-timeout", "Gifts")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("Operations Use The Request Context", func(t *testing.T) {
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err := carts.CreateCart(cancelled, "session-timeout", "Gifts")
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("Operations Within The Timeout Succeed", func(t *testing.T) {
+		carts.SetQueryTimeout(time.Minute)
+		t.Cleanup(func() { carts.SetQueryTimeout(0) })
+		c, err := carts.CreateCart(ctx, "session-timeout", "Gifts")
+		require.NoError(t, err)
+		assert.Equal(t, "Gifts", c.Name)
+	})
+}
+
+func TestReservations(t *testing.T) {
+	ctx := context.Background()
+	carts, cartRepo := setupService(t)
+	bag, err := cartRepo.UpsertProduct("bag", 25)
+	require.NoError(t, err)
+	two := 2
+	require.NoError(t, cartRepo.SetProductStock(bag.ID, &two))
+
+	t.Run("Without Reservations Stock Is Checked Per Cart", func(t *testing.T) {
+		require.NoError(t, carts.AddItem(ctx, "session-1", nil, cart.DefaultName, "bag", 2))
+		require.NoError(t, carts.AddItem(ctx, "session-2", nil, cart.DefaultName, "bag", 2))
+		c, err := cartRepo.GetExistingCart("session-1", cart.DefaultName)
+		require.NoError(t, err)
+		assert.Nil(t, c.ReservedUntil)
+	})
+
+	t.Run("Adding Limited Items Holds Their Stock", func(t *testing.T) {
+		carts.SetReservationTTL(10 * time.Minute)
+		t.Cleanup(func() { carts.SetReservationTTL(0) })
+
+		require.NoError(t, carts.AddItem(ctx, "session-3", nil, cart.DefaultName, "bag", 2))
+		c, err := cartRepo.GetExistingCart("session-3", cart.DefaultName)
+		require.NoError(t, err)
+		require.NotNil(t, c.ReservedUntil)
+		assert.WithinDuration(t, time.Now().Add(10*time.Minute), *c.ReservedUntil, time.Minute)
+
+		err = carts.AddItem(ctx, "session-4", nil, cart.DefaultName, "bag", 1)
+		assert.ErrorIs(t, err, productpkg.ErrOutOfStock)
+		// Products whose stock isn't tracked can still be added
+		require.NoError(t, carts.AddItem(ctx, "session-4", nil, cart.DefaultName, "shoe", 1))
+	})
+
+	// removeBag adds a bag to the cart of the session without holding its stock and removes it again
+	removeBag := func(t *testing.T, sessionID string) uint {
+		t.Helper()
+		require.NoError(t, carts.AddItem(ctx, sessionID, nil, cart.DefaultName, "bag", 1))
+		c, err := cartRepo.GetExistingCart(sessionID, cart.DefaultName)
+		require.NoError(t, err)
+		_, err = carts.RemoveItem(ctx, sessionID, cart.DefaultName, c.CartItems[0].ID)
+		require.NoError(t, err)
+		return c.CartItems[0].ID
+	}
+
+	t.Run("Restoring Items Checks Their Stock", func(t *testing.T) {
+		itemID := removeBag(t, "session-5")
+		none := 0
+		require.NoError(t, cartRepo.SetProductStock(bag.ID, &none))
+		t.Cleanup(func() { require.NoError(t, cartRepo.SetProductStock(bag.ID, &two)) })
+
+		_, err := carts.RestoreItem(ctx, "session-5", cart.DefaultName, itemID)
+		assert.ErrorIs(t, err, productpkg.ErrOutOfStock)
+		_, err = carts.Undo(ctx, "session-5", cart.DefaultName)
+		assert.ErrorIs(t, err, productpkg.ErrOutOfStock)
+
+		require.NoError(t, cartRepo.SetProductStock(bag.ID, &two))
+		_, err = carts.Undo(ctx, "session-5", cart.DefaultName)
+		require.NoError(t, err)
+		c, err := cartRepo.GetExistingCart("session-5", cart.DefaultName)
+		require.NoError(t, err)
+		assert.Len(t, c.CartItems, 1)
+	})
+
+	t.Run("Restoring Limited Items Holds Their Stock", func(t *testing.T) {
+		itemID := removeBag(t, "session-6")
+		carts.SetReservationTTL(10 * time.Minute)
+		t.Cleanup(func() { carts.SetReservationTTL(0) })
+
+		// The bags held by session-3 leave none to put back
+		_, err := carts.RestoreItem(ctx, "session-6", cart.DefaultName, itemID)
+		assert.ErrorIs(t, err, productpkg.ErrOutOfStock)
+		_, err = carts.Undo(ctx, "session-6", cart.DefaultName)
+		assert.ErrorIs(t, err, productpkg.ErrOutOfStock)
+
+		holding, err := cartRepo.GetExistingCart("session-3", cart.DefaultName)
+		require.NoError(t, err)
+		_, err = carts.RemoveItem(ctx, "session-3", cart.DefaultName, holding.CartItems[0].ID)
+		require.NoError(t, err)
+		_, err = carts.RestoreItem(ctx, "session-6", cart.DefaultName, itemID)
+		require.NoError(t, err)
+		c, err := cartRepo.GetExistingCart("session-6", cart.DefaultName)
+		require.NoError(t, err)
+		assert.NotNil(t, c.ReservedUntil)
+	})
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"interview/internal/tenant"
+	"strings"
+	"sync"
+)
+
+// errFlightPanicked is returned to the callers waiting for a call that panicked
+var errFlightPanicked = errors.New("shared call panicked")
+
+// flightGroup collapses concurrent calls for the same key into one, like golang.org/x/sync/singleflight:
+// while a call for a key runs, callers asking for the same key wait for it and share its result. Calls
+// made after it returned run again, so nothing is cached.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// readKey returns the flight key of a read, which is only shared with reads of the same tenant
+func readKey(ctx context.Context, parts ...string) string {
+	shop, _ := tenant.FromContext(ctx)
+	return shop + "\x00" + strings.Join(parts, "\x00")
+}
+
+func newFlightGroup() *flightGroup {
+	return &flightGroup{calls: make(map[string]*flightCall)}
+}
+
+// Do runs fn for key unless a call for key is already running, in which case it waits for that call and
+// returns its result.
+func (g *flightGroup) Do(key string, fn func() (interface{}, error)) (interface{}, error) {
+	g.mu.Lock()
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-call.done
+		return call.value, call.err
+	}
+	call := &flightCall{done: make(chan struct{}), err: errFlightPanicked}
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(call.done)
+	}()
+	call.value, call.err = fn()
+	return call.value, call.err
+}
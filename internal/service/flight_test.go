@@ -0,0 +1,117 @@
+package service_test
+
+import (
+	"context"
+	"interview/internal/cart"
+	"interview/internal/repo"
+	"interview/internal/service"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// queryGate counts the queries of a database and, while closed, holds them until it is opened
+type queryGate struct {
+	queries atomic.Int32
+	mu      sync.Mutex
+	open    chan struct{}
+}
+
+func newQueryGate(db *gorm.DB) *queryGate {
+	g := &queryGate{open: make(chan struct{})}
+	close(g.open)
+	err := db.Callback().Query().Before("gorm:query").Register("test:gate", func(*gorm.DB) {
+		g.queries.Add(1)
+		g.mu.Lock()
+		open := g.open
+		g.mu.Unlock()
+		<-open
+	})
+	if err != nil {
+		panic(err)
+	}
+	return g
+}
+
+// concurrently calls read from several goroutines at once, holding the first query until the other
+// calls had time to start, and returns the number of queries run
+func (g *queryGate) concurrently(t *testing.T, read func()) int32 {
+	t.Helper()
+	g.queries.Store(0)
+	g.mu.Lock()
+	g.open = make(chan struct{})
+	open := g.open
+	g.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			read()
+		}()
+	}
+	require.Eventually(t, func() bool { return g.queries.Load() > 0 }, time.Second, time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	close(open)
+	wg.Wait()
+	return g.queries.Load()
+}
+
+// sequentially returns the number of queries a single call of read runs
+func (g *queryGate) sequentially(read func()) int32 {
+	g.queries.Store(0)
+	read()
+	return g.queries.Load()
+}
+
+func TestConcurrentReads(t *testing.T) {
+	ctx := context.Background()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, repo.Migrate(db))
+	cartRepo := repo.NewRepository(db)
+	carts := service.NewCartService(cartRepo)
+	shoe, err := cartRepo.UpsertProduct("shoe", 10)
+	require.NoError(t, err)
+	require.NoError(t, carts.AddItem(ctx, "flash-sale", nil, cart.DefaultName, "shoe", 1))
+	gate := newQueryGate(db)
+
+	t.Run("Same Cart", func(t *testing.T) {
+		read := func() {
+			c, err := carts.GetCart(ctx, "flash-sale", cart.DefaultName)
+			if assert.NoError(t, err) {
+				assert.Len(t, c.CartItems, 1)
+			}
+		}
+		once := gate.sequentially(read)
+		require.Positive(t, once)
+		assert.Equal(t, once, gate.concurrently(t, read), "concurrent reads share one lookup")
+		assert.Equal(t, once, gate.sequentially(read), "finished reads aren't cached")
+	})
+
+	t.Run("Same Product", func(t *testing.T) {
+		read := func() {
+			p, err := carts.GetProduct(ctx, shoe.ID)
+			if assert.NoError(t, err) {
+				assert.Equal(t, "shoe", p.Name)
+			}
+		}
+		assert.Equal(t, gate.sequentially(read), gate.concurrently(t, read))
+	})
+
+	t.Run("Errors Are Shared", func(t *testing.T) {
+		read := func() {
+			_, err := carts.GetProduct(ctx, shoe.ID+100)
+			assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		}
+		assert.Equal(t, gate.sequentially(read), gate.concurrently(t, read))
+	})
+
+}
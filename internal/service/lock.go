@@ -0,0 +1,43 @@
+package service
+
+import "sync"
+
+// keyedMutex serializes work per key, e.g. per cart session, without a global lock. Entries are
+// reference counted and removed once nobody holds or waits for them, so the map doesn't grow with
+// every session ever seen.
+type keyedMutex struct {
+	mu      sync.Mutex
+	entries map[string]*keyedMutexEntry
+}
+
+type keyedMutexEntry struct {
+	mu   sync.Mutex
+	refs int
+}
+
+func newKeyedMutex() *keyedMutex {
+	return &keyedMutex{entries: make(map[string]*keyedMutexEntry)}
+}
+
+// Lock blocks until the lock for key is acquired and returns the function releasing it.
+func (k *keyedMutex) Lock(key string) func() {
+	k.mu.Lock()
+	entry, ok := k.entries[key]
+	if !ok {
+		entry = &keyedMutexEntry{}
+		k.entries[key] = entry
+	}
+	entry.refs++
+	k.mu.Unlock()
+
+	entry.mu.Lock()
+	return func() {
+		entry.mu.Unlock()
+		k.mu.Lock()
+		entry.refs--
+		if entry.refs == 0 {
+			delete(k.entries, key)
+		}
+		k.mu.Unlock()
+	}
+}
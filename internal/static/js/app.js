@@ -0,0 +1,48 @@
+// Select the whole quantity when it is clicked, so typing replaces it
+document.addEventListener('click', function (event) {
+    if (event.target.matches('[data-select-on-click]')) {
+        event.target.select();
+    }
+});
+
+// Count down the time left of reservations, e.g. <time data-countdown datetime="...">9:41</time>
+setInterval(function () {
+    document.querySelectorAll('time[data-countdown]').forEach(function (el) {
+        var left = Math.max(0, Math.floor((Date.parse(el.getAttribute('datetime')) - Date.now()) / 1000));
+        el.textContent = Math.floor(left / 60) + ':' + String(left % 60).padStart(2, '0');
+    });
+}, 1000);
+
+// Reorder cart items by dragging one onto another, e.g. <div draggable="true" data-item-id="3">, posting
+// the IDs of all items in their new order with the form #reorder-form
+var draggedItem = null;
+document.addEventListener('dragstart', function (event) {
+    draggedItem = event.target.closest ? event.target.closest('[data-item-id]') : null;
+});
+document.addEventListener('dragover', function (event) {
+    if (draggedItem && event.target.closest && event.target.closest('[data-item-id]')) {
+        event.preventDefault();
+    }
+});
+document.addEventListener('drop', function (event) {
+    var target = event.target.closest ? event.target.closest('[data-item-id]') : null;
+    var form = document.getElementById('reorder-form');
+    if (!draggedItem || !target || target === draggedItem || !form) {
+        return;
+    }
+    event.preventDefault();
+    var ids = Array.prototype.map.call(document.querySelectorAll('[data-item-id]'), function (el) {
+        return el.getAttribute('data-item-id');
+    });
+    var from = ids.indexOf(draggedItem.getAttribute('data-item-id'));
+    var to = ids.indexOf(target.getAttribute('data-item-id'));
+    ids.splice(to, 0, ids.splice(from, 1)[0]);
+    ids.forEach(function (id) {
+        var input = document.createElement('input');
+        input.type = 'hidden';
+        input.name = 'items';
+        input.value = id;
+        form.appendChild(input);
+    });
+    form.submit();
+});
@@ -0,0 +1,204 @@
+// Package static serves the CSS and JavaScript of the HTML pages from files embedded in the binary.
+// Asset URLs carry a hash of the content, so browsers can cache them forever and still fetch a new
+// version as soon as it changes.
+package static
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"text/template/parse"
+	"time"
+)
+
+//go:embed css js
+var embedded embed.FS
+
+// hashLength is the number of hex digits of the content hash put in asset names
+const hashLength = 12
+
+type (
+	// Assets serves a set of files under their hashed names
+	Assets struct {
+		// baseURL is the path where ServeHTTP is mounted
+		baseURL string
+		// hashed maps file names to their hashed names
+		hashed map[string]string
+		// files maps hashed names to their content
+		files map[string]asset
+	}
+
+	asset struct {
+		content     []byte
+		contentType string
+		etag        string
+	}
+)
+
+// New creates Assets serving all files of fsys under baseURL, e.g. "/static".
+func New(fsys fs.FS, baseURL string) (*Assets, error) {
+	a := &Assets{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		hashed:  make(map[string]string),
+		files:   make(map[string]asset),
+	}
+	if err := a.Add(fsys, ""); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Add serves the files of fsys too, named after their path in dir, e.g. "themes/dark/css/app.css" for
+// "css/app.css" in dir "themes/dark". Files are added before the assets are served, which isn't safe
+// concurrently.
+func (a *Assets) Add(fsys fs.FS, dir string) error {
+	return fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		name = path.Join(dir, name)
+		sum := sha256.Sum256(content)
+		hash := hex.EncodeToString(sum[:])[:hashLength]
+		ext := path.Ext(name)
+		hashedName := strings.TrimSuffix(name, ext) + "." + hash + ext
+
+		a.hashed[name] = hashedName
+		a.files[hashedName] = asset{
+			content:     content,
+			contentType: mime.TypeByExtension(ext),
+			etag:        `"` + hash + `"`,
+		}
+		return nil
+	})
+}
+
+// Default returns the Assets embedded in the binary, served under "/static".
+func Default() *Assets {
+	a, err := New(embedded, "/static")
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+// URL returns the URL of the current version of the named file, e.g. "/static/css/app.3f2a9c1b0d4e.css"
+// for "css/app.css". It panics for unknown files; CheckTemplates finds the files templates refer to
+// that don't exist when they are loaded, before they are rendered.
+func (a *Assets) URL(name string) string {
+	hashedName, ok := a.hashed[name]
+	if !ok {
+		panic(fmt.Sprintf("unknown static asset %q", name))
+	}
+	return a.baseURL + "/" + hashedName
+}
+
+// Has reports whether the named file is served.
+func (a *Assets) Has(name string) bool {
+	_, ok := a.hashed[name]
+	return ok
+}
+
+// ServeHTTP serves a file by its hashed name, relative to the mount point. The hash changes with the
+// content, so responses may be cached for a year without revalidation.
+func (a *Assets) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	file, ok := a.files[strings.TrimPrefix(r.URL.Path, "/")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("ETag", file.etag)
+	if file.contentType != "" {
+		w.Header().Set("Content-Type", file.contentType)
+	}
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(file.content))
+}
+
+// CheckTemplates returns an error for the first file passed to the asset function of the templates,
+// e.g. {{ asset "css/app.css" }}, that exists reports as missing. Only file names written out in the
+// templates are checked.
+func CheckTemplates(tpl *template.Template, exists func(name string) bool) error {
+	for _, t := range tpl.Templates() {
+		if t.Tree == nil {
+			continue
+		}
+		var missing string
+		walkAssets(t.Tree.Root, func(name string) {
+			if missing == "" && !exists(name) {
+				missing = name
+			}
+		})
+		if missing != "" {
+			return fmt.Errorf("template %q refers to unknown static asset %q", t.Name(), missing)
+		}
+	}
+	return nil
+}
+
+// walkAssets calls found with the file names passed to the asset function in the node and its children
+func walkAssets(node parse.Node, found func(name string)) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			walkAssets(child, found)
+		}
+	case *parse.ActionNode:
+		walkAssets(n.Pipe, found)
+	case *parse.IfNode:
+		walkAssets(&n.BranchNode, found)
+	case *parse.RangeNode:
+		walkAssets(&n.BranchNode, found)
+	case *parse.WithNode:
+		walkAssets(&n.BranchNode, found)
+	case *parse.BranchNode:
+		walkAssets(n.Pipe, found)
+		walkAssets(n.List, found)
+		walkAssets(n.ElseList, found)
+	case *parse.TemplateNode:
+		walkAssets(n.Pipe, found)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for i, cmd := range n.Cmds {
+			// {{ "css/app.css" | asset }}
+			if i > 0 && len(cmd.Args) == 1 && isAsset(cmd.Args[0]) && len(n.Cmds[i-1].Args) == 1 {
+				if name, ok := n.Cmds[i-1].Args[0].(*parse.StringNode); ok {
+					found(name.Text)
+				}
+			}
+			walkAssets(cmd, found)
+		}
+	case *parse.CommandNode:
+		// {{ asset "css/app.css" }}
+		if len(n.Args) == 2 && isAsset(n.Args[0]) {
+			if name, ok := n.Args[1].(*parse.StringNode); ok {
+				found(name.Text)
+			}
+		}
+		for _, arg := range n.Args {
+			walkAssets(arg, found)
+		}
+	}
+}
+
+// isAsset reports whether the node names the asset function
+func isAsset(node parse.Node) bool {
+	fn, ok := node.(*parse.IdentifierNode)
+	return ok && fn.Ident == "asset"
+}
@@ -0,0 +1,102 @@
+package static_test
+
+import (
+	"html/template"
+	"interview/internal/static"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssets(t *testing.T) {
+	assets, err := static.New(fstest.MapFS{
+		"css/app.css": {Data: []byte("body { color: red; }")},
+	}, "/static/")
+	require.NoError(t, err)
+
+	url := assets.URL("css/app.css")
+	// echo -n 'body { color: red; }' | sha256sum
+	assert.Equal(t, "/static/css/app.5de625c36355.css", url)
+	assert.Panics(t, func() { assets.URL("css/missing.css") })
+
+	serve := func(path, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		http.StripPrefix("/static", assets).ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Hashed Name", func(t *testing.T) {
+		w := serve(url, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "body { color: red; }", w.Body.String())
+		assert.Equal(t, "text/css; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Equal(t, "public, max-age=31536000, immutable", w.Header().Get("Cache-Control"))
+	})
+
+	t.Run("Not Modified", func(t *testing.T) {
+		w := serve(url, serve(url, "").Header().Get("ETag"))
+		assert.Equal(t, http.StatusNotModified, w.Code)
+	})
+
+	t.Run("Unhashed Name", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, serve("/static/css/app.css", "").Code)
+	})
+
+	t.Run("Added Files", func(t *testing.T) {
+		require.NoError(t, assets.Add(fstest.MapFS{
+			"css/app.css": {Data: []byte("body { color: blue; }")},
+		}, "themes/blue"))
+		assert.True(t, assets.Has("themes/blue/css/app.css"))
+		assert.False(t, assets.Has("themes/blue/js/app.js"))
+		assert.Equal(t, url, assets.URL("css/app.css"), "files added under a directory don't replace others")
+
+		w := serve(assets.URL("themes/blue/css/app.css"), "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "body { color: blue; }", w.Body.String())
+	})
+}
+
+func TestCheckTemplates(t *testing.T) {
+	assets, err := static.New(fstest.MapFS{"css/app.css": {Data: []byte("body {}")}}, "/static")
+	require.NoError(t, err)
+	parse := func(text string) *template.Template {
+		return template.Must(template.New("").Funcs(template.FuncMap{"asset": assets.URL}).Parse(text))
+	}
+
+	tests := []struct {
+		name    string
+		text    string
+		wantErr bool
+	}{
+		{"Known Asset", `{{ define "cart.html" }}{{ asset "css/app.css" }}{{ end }}`, false},
+		{"Unknown Asset", `{{ define "cart.html" }}{{ asset "css/missing.css" }}{{ end }}`, true},
+		{"Unknown Asset In A Branch", `{{ define "cart.html" }}{{ if . }}{{ else }}{{ "js/app.js" | asset }}{{ end }}{{ end }}`, true},
+		{"Computed Name", `{{ define "cart.html" }}{{ asset . }}{{ end }}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := static.CheckTemplates(parse(tt.text), assets.Has)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDefault(t *testing.T) {
+	assets := static.Default()
+	assert.NotPanics(t, func() {
+		assets.URL("css/app.css")
+		assets.URL("js/app.js")
+	})
+}
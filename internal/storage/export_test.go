@@ -0,0 +1,9 @@
+package storage
+
+import "time"
+
+// SetNow overrides the clock used to sign and verify URLs.
+func (l *Local) SetNow(now func() time.Time) { l.now = now }
+
+// SetNow overrides the clock used to sign requests.
+func (s *S3) SetNow(now func() time.Time) { s.now = now }
@@ -0,0 +1,210 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// unsignedPayload tells S3 not to verify a hash of the body, so uploads can be streamed.
+// The body is still protected by TLS.
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// S3 stores objects in an S3 bucket, or any S3-compatible service such as MinIO. Requests and
+// presigned URLs are signed with AWS Signature Version 4.
+type S3 struct {
+	bucket          string
+	region          string
+	endpoint        string
+	accessKeyID     string
+	secretAccessKey string
+	client          *http.Client
+	now             func() time.Time
+}
+
+// NewS3 creates an S3 storage. With an empty endpoint, AWS virtual-hosted-style URLs such as
+// https://bucket.s3.eu-west-1.amazonaws.com/key are used; otherwise path-style URLs like
+// endpoint/bucket/key, which S3-compatible services expect.
+func NewS3(endpoint, region, bucket, accessKeyID, secretAccessKey string) *S3 {
+	return &S3{
+		bucket:          bucket,
+		region:          region,
+		endpoint:        strings.TrimSuffix(endpoint, "/"),
+		accessKeyID:     accessKeyID,
+		secretAccessKey: secretAccessKey,
+		client:          &http.Client{Timeout: 30 * time.Second},
+		now:             time.Now,
+	}
+}
+
+// Origin returns the scheme and host objects are served from, e.g. for a Content-Security-Policy.
+func (s *S3) Origin() string {
+	if s.endpoint != "" {
+		return s.endpoint
+	}
+	if s.region == "us-east-1" {
+		return "https://" + s.bucket + ".s3.amazonaws.com"
+	}
+	return "https://" + s.bucket + ".s3." + s.region + ".amazonaws.com"
+}
+
+// objectURL returns the origin and the canonical path of the object.
+func (s *S3) objectURL(key string) (string, string) {
+	if s.endpoint != "" {
+		return s.endpoint, "/" + awsEscape(s.bucket, false) + "/" + awsEscape(key, false)
+	}
+	return s.Origin(), "/" + awsEscape(key, false)
+}
+
+// Put uploads the object with a single PUT request.
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	req, err := s.newSignedRequest(ctx, http.MethodPut, key, r)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+	return s.do(req)
+}
+
+// Delete removes the object. S3 answers 204 for missing objects too.
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	req, err := s.newSignedRequest(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	return s.do(req)
+}
+
+// SignedURL returns a presigned GET URL, valid for at most seven days as S3 doesn't accept more.
+func (s *S3) SignedURL(key string, ttl time.Duration) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+	if ttl > 7*24*time.Hour {
+		ttl = 7 * 24 * time.Hour
+	}
+
+	now := s.now().UTC()
+	origin, path := s.objectURL(key)
+	host := strings.TrimPrefix(strings.TrimPrefix(origin, "https://"), "http://")
+
+	query := map[string]string{
+		"X-Amz-Algorithm":     "AWS4-HMAC-SHA256",
+		"X-Amz-Credential":    s.accessKeyID + "/" + s.scope(now),
+		"X-Amz-Date":          now.Format("20060102T150405Z"),
+		"X-Amz-Expires":       strconv.Itoa(int(ttl.Seconds())),
+		"X-Amz-SignedHeaders": "host",
+	}
+	canonicalQuery := canonicalQueryString(query)
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet, path, canonicalQuery, "host:" + host + "\n", "host", unsignedPayload,
+	}, "\n")
+
+	signature := s.signature(now, canonicalRequest)
+	return origin + path + "?" + canonicalQuery + "&X-Amz-Signature=" + signature, nil
+}
+
+func (s *S3) newSignedRequest(ctx context.Context, method, key string, body io.Reader) (*http.Request, error) {
+	origin, path := s.objectURL(key)
+	req, err := http.NewRequestWithContext(ctx, method, origin+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 request: %w", err)
+	}
+
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		method, path, "",
+		"host:" + req.URL.Host + "\n" +
+			"x-amz-content-sha256:" + unsignedPayload + "\n" +
+			"x-amz-date:" + amzDate + "\n",
+		signedHeaders, unsignedPayload,
+	}, "\n")
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKeyID, s.scope(now), signedHeaders, s.signature(now, canonicalRequest)))
+	return req, nil
+}
+
+func (s *S3) do(req *http.Request) error {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("S3 request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("S3 %s %s returned %d: %s", req.Method, req.URL.Path, resp.StatusCode, body)
+	}
+	return nil
+}
+
+func (s *S3) scope(t time.Time) string {
+	return t.Format("20060102") + "/" + s.region + "/s3/aws4_request"
+}
+
+// signature derives the signing key for the day and signs the canonical request.
+func (s *S3) signature(t time.Time, canonicalRequest string) string {
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256", t.Format("20060102T150405Z"), s.scope(t), hex.EncodeToString(hash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretAccessKey), t.Format("20060102"))
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func canonicalQueryString(params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = awsEscape(k, true) + "=" + awsEscape(params[k], true)
+	}
+	return strings.Join(pairs, "&")
+}
+
+// awsEscape percent-encodes everything but unreserved characters, as SigV4 requires. Slashes are
+// kept in paths and encoded in query values.
+func awsEscape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
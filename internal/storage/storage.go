@@ -0,0 +1,40 @@
+// Package storage keeps uploaded files such as product images on the local disk or in S3 and hands
+// out time-limited signed URLs to serve them.
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrInvalidKey is returned for keys that are empty, absolute or escape the storage root.
+var ErrInvalidKey = errors.New("invalid storage key")
+
+// Storage stores objects under slash-separated keys such as "products/1/image.png".
+type Storage interface {
+	// Put stores size bytes read from r under key, replacing any existing object
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Delete removes the object; deleting a missing object is not an error
+	Delete(ctx context.Context, key string) error
+	// SignedURL returns a URL serving the object until ttl has passed
+	SignedURL(key string, ttl time.Duration) (string, error)
+}
+
+func validateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key || strings.HasPrefix(key, "..") {
+		return ErrInvalidKey
+	}
+	return nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,6 @@
+package subscription
+
+import "time"
+
+// SetNow overrides the clock deciding which subscriptions are due.
+func (o *Orderer) SetNow(now func() time.Time) { o.now = now }
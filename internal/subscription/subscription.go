@@ -0,0 +1,63 @@
+// Package subscription places the recurring orders of the items customers bought with subscribe & save.
+package subscription
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"interview/internal/pricing"
+	"interview/internal/repo"
+	"time"
+)
+
+// batchSize is the largest number of orders placed by one run
+const batchSize = 100
+
+// Orderer places the orders of subscriptions that are due
+type Orderer struct {
+	repo   *repo.Repository
+	prices pricing.Provider
+	now    func() time.Time
+}
+
+// NewOrderer creates an Orderer ordering items at the current prices of prices
+func NewOrderer(r *repo.Repository, prices pricing.Provider) *Orderer {
+	return &Orderer{
+		repo:   r,
+		prices: prices,
+		now:    time.Now,
+	}
+}
+
+// Run places an order for every active subscription that is due and returns how many were placed.
+// Subscriptions whose price can't be looked up or whose order fails stay due for the next run.
+func (o *Orderer) Run(ctx context.Context) (int, error) {
+	now := o.now()
+	subs, err := o.repo.ListDueSubscriptions(now, batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var placed int
+	var errs []error
+	for _, sub := range subs {
+		if err := ctx.Err(); err != nil {
+			return placed, err
+		}
+
+		price, err := o.prices.Price(ctx, sub.ProductName)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("subscription %d: failed to get price: %w", sub.ID, err))
+			continue
+		}
+		order, err := o.repo.PlaceSubscriptionOrder(sub, price, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("subscription %d: %w", sub.ID, err))
+			continue
+		}
+		if order != nil {
+			placed++
+		}
+	}
+	return placed, errors.Join(errs...)
+}
@@ -0,0 +1,85 @@
+package subscription_test
+
+import (
+	"context"
+	cartpkg "interview/internal/cart"
+	"interview/internal/pricing"
+	"interview/internal/repo"
+	"interview/internal/subscription"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestOrderer(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, repo.Migrate(db))
+	cartRepo := repo.NewRepository(db)
+
+	c, err := cartRepo.GetOrCreateCart("session", cartpkg.DefaultName)
+	require.NoError(t, err)
+	require.NoError(t, cartRepo.AddCartItem(c.ID, "shoe", 2, 10))
+	require.NoError(t, cartRepo.AddCartItem(c.ID, "bag", 1, 30))
+	c, err = cartRepo.GetOrCreateCart("session", cartpkg.DefaultName)
+	require.NoError(t, err)
+	for _, item := range c.CartItems {
+		require.NoError(t, cartRepo.SetItemSubscription(c.ID, item.ID, 14))
+	}
+	require.NoError(t, cartRepo.CloseCart(c.ID))
+
+	// orders returns the carts checked out for subscriptions
+	orders := func(t *testing.T) []cartpkg.Cart {
+		t.Helper()
+		var carts []cartpkg.Cart
+		require.NoError(t, db.Preload("CartItems").Where("subscription_id IS NOT NULL").Order("id").Find(&carts).Error)
+		return carts
+	}
+	// orderer returns an Orderer running the given number of days from now
+	orderer := func(prices pricing.Provider, days int) *subscription.Orderer {
+		o := subscription.NewOrderer(cartRepo, prices)
+		o.SetNow(func() time.Time { return time.Now().AddDate(0, 0, days) })
+		return o
+	}
+
+	t.Run("Waits Until Due", func(t *testing.T) {
+		placed, err := orderer(pricing.DefaultPrices(), 13).Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 0, placed)
+		assert.Empty(t, orders(t))
+	})
+
+	t.Run("Failed Prices Are Retried", func(t *testing.T) {
+		placed, err := orderer(pricing.StaticProvider{"shoe": 12}, 15).Run(context.Background())
+		require.ErrorIs(t, err, pricing.ErrProductNotFound)
+		assert.Equal(t, 1, placed)
+		require.Len(t, orders(t), 1)
+		assert.Equal(t, "shoe", orders(t)[0].CartItems[0].ProductName)
+	})
+
+	t.Run("Orders Due Subscriptions Once", func(t *testing.T) {
+		placed, err := orderer(pricing.DefaultPrices(), 15).Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, placed)
+		all := orders(t)
+		require.Len(t, all, 2)
+		assert.Equal(t, "bag", all[1].CartItems[0].ProductName)
+		assert.Equal(t, 30.0, all[1].CartItems[0].Price)
+		assert.Equal(t, cartpkg.StatusClosed, all[1].Status)
+
+		placed, err = orderer(pricing.DefaultPrices(), 15).Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 0, placed)
+	})
+
+	t.Run("Orders Again After The Interval", func(t *testing.T) {
+		placed, err := orderer(pricing.DefaultPrices(), 29).Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 2, placed)
+		assert.Len(t, orders(t), 4)
+	})
+}
@@ -0,0 +1,86 @@
+// Package tenant tells apart the shops served by one deployment. Each tenant is a storefront with its
+// own host names, products and carts; requests are assigned to a tenant by the host they were sent to.
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Default is the tenant owning the products and carts of single-shop deployments, and those created
+// before the deployment served several shops
+const Default = "default"
+
+// Hosts maps the host names of the deployment to the tenants they serve
+type Hosts map[string]string
+
+type contextKey struct{}
+
+// Parse reads tenants in the form "id=host,host;id=host". An empty spec serves a single shop and
+// returns no hosts.
+func Parse(spec string) (Hosts, error) {
+	hosts := Hosts{}
+	seen := map[string]bool{}
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, names, ok := strings.Cut(entry, "=")
+		id = strings.TrimSpace(id)
+		if !ok || id == "" {
+			return nil, fmt.Errorf("tenant %q must have the form id=host,host", entry)
+		}
+		if len(id) > 32 {
+			return nil, fmt.Errorf("tenant %q has an ID longer than 32 characters", id)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("tenant %q is defined twice", id)
+		}
+		seen[id] = true
+
+		count := 0
+		for _, host := range strings.Split(names, ",") {
+			if host = strings.ToLower(strings.TrimSpace(host)); host == "" {
+				continue
+			}
+			if other, taken := hosts[host]; taken {
+				return nil, fmt.Errorf("host %q is served by both %q and %q", host, other, id)
+			}
+			hosts[host] = id
+			count++
+		}
+		if count == 0 {
+			return nil, fmt.Errorf("tenant %q needs at least one host", id)
+		}
+	}
+	if len(hosts) == 0 {
+		return nil, nil
+	}
+	return hosts, nil
+}
+
+// Resolve returns the tenant serving the host of a request, which may include a port
+func (h Hosts) Resolve(host string) (string, bool) {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	id, ok := h[strings.ToLower(strings.TrimSuffix(host, "."))]
+	return id, ok
+}
+
+// NewContext returns a context whose database queries are limited to the tenant
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the tenant of the context, if it was assigned one
+func FromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	id, ok := ctx.Value(contextKey{}).(string)
+	return id, ok
+}
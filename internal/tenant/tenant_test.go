@@ -0,0 +1,80 @@
+package tenant_test
+
+import (
+	"context"
+	"interview/internal/tenant"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     string
+		expected tenant.Hosts
+		wantErr  bool
+	}{
+		{name: "Empty", spec: " ; ", expected: nil},
+		{
+			name: "Several Tenants",
+			spec: " acme = shop.acme.com, ACME.example ;globex=globex.example;",
+			expected: tenant.Hosts{
+				"shop.acme.com":  "acme",
+				"acme.example":   "acme",
+				"globex.example": "globex",
+			},
+		},
+		{name: "Missing Hosts", spec: "acme=", wantErr: true},
+		{name: "Missing ID", spec: "=shop.acme.com", wantErr: true},
+		{name: "Long ID", spec: "a-tenant-id-that-is-far-too-long-to-store=shop.acme.com", wantErr: true},
+		{name: "Duplicate ID", spec: "acme=a.example;acme=b.example", wantErr: true},
+		{name: "Shared Host", spec: "acme=shop.example;globex=shop.example", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hosts, err := tenant.Parse(tt.spec)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, hosts)
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	hosts := tenant.Hosts{"shop.acme.com": "acme"}
+
+	tests := []struct {
+		name     string
+		host     string
+		expected string
+		found    bool
+	}{
+		{name: "Host", host: "shop.acme.com", expected: "acme", found: true},
+		{name: "Host With Port", host: "shop.acme.com:8080", expected: "acme", found: true},
+		{name: "Upper Case And Trailing Dot", host: "Shop.Acme.com.", expected: "acme", found: true},
+		{name: "Unknown Host", host: "globex.example", found: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, found := hosts.Resolve(tt.host)
+			assert.Equal(t, tt.found, found)
+			assert.Equal(t, tt.expected, id)
+		})
+	}
+}
+
+func TestContext(t *testing.T) {
+	_, ok := tenant.FromContext(context.Background())
+	assert.False(t, ok)
+
+	id, ok := tenant.FromContext(tenant.NewContext(context.Background(), "acme"))
+	assert.True(t, ok)
+	assert.Equal(t, "acme", id)
+}
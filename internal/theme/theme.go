@@ -0,0 +1,109 @@
+// Package theme lets brands restyle the pages of the shop without changing the code. A theme is a
+// directory of the themes directory, named after the theme, holding any of
+//
+//	templates/*.html  pages replacing the built-in page of the same name, e.g. templates/cart.html
+//	static/           CSS, JavaScript and images replacing or adding to the built-in static assets
+//
+// Pages and assets a theme leaves out are taken from the built-in look.
+package theme
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"interview/internal/static"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// Themes are the page templates of the built-in look and of the themes of a themes directory
+type Themes struct {
+	base      *template.Template
+	templates map[string]*template.Template
+	// selected is the theme of the shops without a theme of their own, empty for the built-in look
+	selected string
+}
+
+// Load reads the themes of dir. Their templates are parsed on top of clones of base, the built-in pages,
+// and their static files are added to assets under "themes/<name>/". The asset function of a theme's
+// templates returns the URL of the theme's version of a file when it has one; themes referring to files
+// that neither they nor the built-in look have fail to load.
+func Load(dir string, base *template.Template, assets *static.Assets) (*Themes, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read themes: %w", err)
+	}
+	t := &Themes{base: base, templates: make(map[string]*template.Template)}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		fsys := os.DirFS(filepath.Join(dir, name))
+
+		prefix := path.Join("themes", name)
+		if err := addAssets(assets, fsys, prefix); err != nil {
+			return nil, fmt.Errorf("failed to load the static files of theme %q: %w", name, err)
+		}
+
+		tpl, err := base.Clone()
+		if err != nil {
+			return nil, err
+		}
+		tpl.Funcs(template.FuncMap{"asset": func(file string) string {
+			if themed := path.Join(prefix, file); assets.Has(themed) {
+				return assets.URL(themed)
+			}
+			return assets.URL(file)
+		}})
+		if pages, _ := fs.Glob(fsys, "templates/*.html"); len(pages) > 0 {
+			if _, err := tpl.ParseFS(fsys, pages...); err != nil {
+				return nil, fmt.Errorf("failed to parse the templates of theme %q: %w", name, err)
+			}
+			err := static.CheckTemplates(tpl, func(file string) bool {
+				return assets.Has(path.Join(prefix, file)) || assets.Has(file)
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to load the templates of theme %q: %w", name, err)
+			}
+		}
+		t.templates[name] = tpl
+	}
+	return t, nil
+}
+
+// addAssets adds the static files of a theme, if it has any
+func addAssets(assets *static.Assets, theme fs.FS, prefix string) error {
+	files, err := fs.Sub(theme, "static")
+	if err != nil {
+		return err
+	}
+	if _, err := fs.Stat(files, "."); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return assets.Add(files, prefix)
+}
+
+// Select makes the named theme the one of shops without a theme of their own. The empty name selects
+// the built-in look.
+func (t *Themes) Select(name string) error {
+	if _, ok := t.templates[name]; name != "" && !ok {
+		return fmt.Errorf("theme %q not found", name)
+	}
+	t.selected = name
+	return nil
+}
+
+// For returns the page templates of the shop: those of the theme named after the shop when there is
+// one, otherwise those of the selected theme.
+func (t *Themes) For(shop string) *template.Template {
+	if tpl, ok := t.templates[shop]; ok && shop != "" {
+		return tpl
+	}
+	if tpl, ok := t.templates[t.selected]; ok {
+		return tpl
+	}
+	return t.base
+}
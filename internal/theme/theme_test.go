@@ -0,0 +1,91 @@
+package theme_test
+
+import (
+	"bytes"
+	"html/template"
+	"interview/internal/static"
+	"interview/internal/theme"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFiles creates the files under dir, keyed by their slash-separated path
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(file), 0o755))
+		require.NoError(t, os.WriteFile(file, []byte(content), 0o644))
+	}
+}
+
+func render(t *testing.T, tpl *template.Template, page string) string {
+	t.Helper()
+	var out bytes.Buffer
+	require.NoError(t, tpl.ExecuteTemplate(&out, page, nil))
+	return out.String()
+}
+
+func TestThemes(t *testing.T) {
+	assets, err := static.New(fstest.MapFS{"css/app.css": {Data: []byte("body {}")}}, "/static")
+	require.NoError(t, err)
+	base := template.Must(template.New("").Funcs(template.FuncMap{"asset": assets.URL}).Parse(
+		`{{ define "cart.html" }}built-in {{ asset "css/app.css" }}{{ end }}` +
+			`{{ define "products.html" }}catalog {{ asset "css/app.css" }}{{ end }}`))
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"acme/templates/cart.html": `acme {{ asset "css/app.css" }}`,
+		"acme/static/css/app.css":  "body { color: red; }",
+		"dark/static/css/app.css":  "body { background: black; }",
+		"README.md":                "Files next to the themes are ignored",
+	})
+	themes, err := theme.Load(dir, base, assets)
+	require.NoError(t, err)
+
+	builtIn := assets.URL("css/app.css")
+	acmeCSS := assets.URL("themes/acme/css/app.css")
+	darkCSS := assets.URL("themes/dark/css/app.css")
+	require.True(t, strings.HasPrefix(acmeCSS, "/static/themes/acme/css/app."))
+
+	t.Run("Theme Of The Shop", func(t *testing.T) {
+		tpl := themes.For("acme")
+		assert.Equal(t, "acme "+acmeCSS, render(t, tpl, "cart.html"))
+		assert.Equal(t, "catalog "+acmeCSS, render(t, tpl, "products.html"), "built-in pages get the assets of the theme")
+	})
+
+	t.Run("Built-In Look", func(t *testing.T) {
+		assert.Equal(t, "built-in "+builtIn, render(t, themes.For("globex"), "cart.html"))
+		assert.Equal(t, "built-in "+builtIn, render(t, themes.For(""), "cart.html"))
+	})
+
+	t.Run("Selected Theme", func(t *testing.T) {
+		require.NoError(t, themes.Select("dark"))
+		assert.Equal(t, "built-in "+darkCSS, render(t, themes.For("globex"), "cart.html"))
+		assert.Equal(t, "acme "+acmeCSS, render(t, themes.For("acme"), "cart.html"), "shops keep a theme of their own")
+
+		assert.Error(t, themes.Select("missing"))
+		require.NoError(t, themes.Select(""))
+		assert.Equal(t, "built-in "+builtIn, render(t, themes.For("globex"), "cart.html"))
+	})
+
+	t.Run("Unknown Asset", func(t *testing.T) {
+		broken := t.TempDir()
+		writeFiles(t, broken, map[string]string{"acme/templates/cart.html": `acme {{ asset "css/acme.css" }}`})
+		base := template.Must(template.New("").Funcs(template.FuncMap{"asset": assets.URL}).Parse(
+			`{{ define "cart.html" }}built-in {{ asset "css/app.css" }}{{ end }}`))
+		_, err := theme.Load(broken, base, assets)
+		assert.ErrorContains(t, err, "css/acme.css")
+	})
+
+	t.Run("Missing Directory", func(t *testing.T) {
+		_, err := theme.Load(filepath.Join(dir, "missing"), base, assets)
+		assert.Error(t, err)
+	})
+}
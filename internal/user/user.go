@@ -0,0 +1,98 @@
+package user
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// What happens to the open carts of a session logged out for exceeding the limit of sessions per user
+const (
+	// EvictedCartsKeep leaves the carts with the browser of the session, as logging out does
+	EvictedCartsKeep = "keep"
+	// EvictedCartsMove moves the carts to the session logging in, merging those of the same name
+	EvictedCartsMove = "move"
+)
+
+type (
+	// User represents a customer account created through a social login provider
+	User struct {
+		gorm.Model
+		// Email is the address reported by the login provider
+		Email string `gorm:"size:255;index"`
+		// Name is the display name reported by the login provider
+		Name string `gorm:"size:255"`
+		// Provider is the name of the login provider the account was created with (e.g. "google")
+		Provider string `gorm:"size:64;not null;uniqueIndex:idx_user_identity"`
+		// ProviderUserID is the stable user identifier issued by the login provider
+		ProviderUserID string `gorm:"size:255;not null;uniqueIndex:idx_user_identity"`
+		// Role is customer, or support or admin for staff members working in the admin area
+		Role string `gorm:"size:16;not null;default:customer"`
+		// CustomerGroup selects the price list the user buys from, see pricelist.Groups
+		CustomerGroup string `gorm:"size:16;not null;default:retail"`
+		// TOTPSecret is the secret of the authenticator app, set when enrollment starts
+		TOTPSecret string `gorm:"size:64"`
+		// TOTPEnabled is set once the user confirmed a code, from then on logins ask for one
+		TOTPEnabled bool `gorm:"not null;default:false"`
+		// PasswordHash is the bcrypt hash of the password chosen with a password reset or on the account
+		// page, empty when the user only logs in with the provider
+		PasswordHash string `gorm:"size:255"`
+		// Locale is the language the user prefers, e.g. "de", empty to detect it from the browser
+		Locale string `gorm:"size:16"`
+		// Currency is the currency the user prefers to see prices in, e.g. "USD", empty for the currency
+		// of the shop
+		Currency string `gorm:"size:3"`
+		// EmailVerifiedAt is when the user proved to receive email at Email, nil until they did
+		EmailVerifiedAt *time.Time
+		// VerificationSentAt is when the last link verifying Email was sent, nil if none was
+		VerificationSentAt *time.Time
+	}
+
+	// RecoveryCode is a single-use code logging a user with two-factor authentication in without
+	// their authenticator app
+	RecoveryCode struct {
+		gorm.Model
+		// UserID is the user the code belongs to
+		UserID uint `gorm:"not null;index"`
+		// CodeHash is the SHA-256 hash of the code, which is only shown to the user once
+		CodeHash string `gorm:"size:64;not null"`
+		// UsedAt is set when the code has been used
+		UsedAt *time.Time
+	}
+
+	// PasswordResetToken lets the user it was emailed to choose a new password once before it expires
+	PasswordResetToken struct {
+		gorm.Model
+		// UserID is the user whose password is reset
+		UserID uint `gorm:"not null;index"`
+		// TokenHash is the SHA-256 hash of the token, which is only sent in the email
+		TokenHash string `gorm:"size:64;not null;uniqueIndex"`
+		// ExpiresAt is when the token stops working
+		ExpiresAt time.Time `gorm:"not null"`
+		// UsedAt is set when the token has been used
+		UsedAt *time.Time
+	}
+
+	// Device records the browser of a session, which carts are associated with through the session ID
+	Device struct {
+		ID uint `gorm:"primarykey"`
+		// SessionID is the ID of the session, see cart.Cart.SessionID
+		SessionID string `gorm:"size:255;not null;uniqueIndex"`
+		// UserID is the user logged in to the session, nil for anonymous sessions
+		UserID    *uint  `gorm:"index"`
+		UserAgent string `gorm:"size:512"`
+		// IPHash is the keyed SHA-256 hash of the IP address the session was last used from, which tells
+		// sessions from different places apart without storing the address
+		IPHash      string    `gorm:"size:64"`
+		FirstSeenAt time.Time `gorm:"not null"`
+		LastSeenAt  time.Time `gorm:"not null;index"`
+		// RevokedAt is set when the user logged the session out from another one; the session is logged
+		// out with its next request
+		RevokedAt *time.Time
+	}
+)
+
+// TableName names the table after the sessions the devices are recorded for.
+func (Device) TableName() string {
+	return "session_devices"
+}
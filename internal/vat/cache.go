@@ -0,0 +1,60 @@
+package vat
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Cached remembers the results of a Validator for a while, so customers going through the checkout
+// again don't ask the service every time. When the service is unavailable, the last result for a VAT
+// ID is used however old it is, marked Offline. Results are kept in memory, so every server instance
+// caches on its own.
+type Cached struct {
+	validator Validator
+	ttl       time.Duration
+	now       func() time.Time
+
+	mu      sync.Mutex
+	results map[string]cachedResult
+}
+
+// cachedResult is a result and when it was fetched, as the service may date its checks differently
+type cachedResult struct {
+	Result
+	fetchedAt time.Time
+}
+
+// NewCached caches the results of the validator for ttl.
+func NewCached(validator Validator, ttl time.Duration) *Cached {
+	return &Cached{validator: validator, ttl: ttl, now: time.Now, results: map[string]cachedResult{}}
+}
+
+// SetClock replaces the clock of the cache, for tests.
+func (c *Cached) SetClock(now func() time.Time) {
+	c.now = now
+}
+
+// Validate implements Validator. Errors other than ErrUnavailable aren't cached or replaced.
+func (c *Cached) Validate(ctx context.Context, country, number string) (Result, error) {
+	key := country + number
+	c.mu.Lock()
+	cached, ok := c.results[key]
+	c.mu.Unlock()
+	if ok && c.now().Sub(cached.fetchedAt) < c.ttl {
+		return cached.Result, nil
+	}
+
+	result, err := c.validator.Validate(ctx, country, number)
+	if errors.Is(err, ErrUnavailable) && ok {
+		cached.Offline = true
+		return cached.Result, nil
+	} else if err != nil {
+		return Result{}, err
+	}
+	c.mu.Lock()
+	c.results[key] = cachedResult{Result: result, fetchedAt: c.now()}
+	c.mu.Unlock()
+	return result, nil
+}
@@ -0,0 +1,118 @@
+// Package vat validates the VAT IDs of business customers, whose cross-border orders within the EU are
+// zero-rated under the reverse charge.
+package vat
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidID is returned for VAT IDs that aren't formatted like an EU VAT ID
+	ErrInvalidID = errors.New("invalid VAT ID")
+	// ErrUnavailable is returned when the validation service can't be asked, e.g. because the tax
+	// authority of the member state is down
+	ErrUnavailable = errors.New("VAT validation service unavailable")
+)
+
+var (
+	// prefixes are the country prefixes of EU VAT IDs, XI being Northern Ireland
+	prefixes = map[string]bool{
+		"AT": true, "BE": true, "BG": true, "CY": true, "CZ": true, "DE": true, "DK": true, "EE": true,
+		"EL": true, "ES": true, "FI": true, "FR": true, "HR": true, "HU": true, "IE": true, "IT": true,
+		"LT": true, "LU": true, "LV": true, "MT": true, "NL": true, "PL": true, "PT": true, "RO": true,
+		"SE": true, "SI": true, "SK": true, "XI": true,
+	}
+	// numberPattern is the part of a VAT ID after its country prefix
+	numberPattern = regexp.MustCompile(`^[0-9A-Z+*]{2,12}$`)
+	// separators are left out of VAT IDs, which are often written grouped, e.g. "DE 123.456.789"
+	separators = strings.NewReplacer(" ", "", ".", "", "-", "")
+)
+
+type (
+	// Validator checks VAT IDs with the tax authorities
+	Validator interface {
+		Validate(ctx context.Context, country, number string) (Result, error)
+	}
+
+	// Result is what a Validator found out about a VAT ID
+	Result struct {
+		Valid bool
+		// Name and Address are those of the registered business, when the member state shares them
+		Name    string
+		Address string
+		// ConsultationNumber identifies the request at the service, which proves the check was made
+		ConsultationNumber string
+		CheckedAt          time.Time
+		// Offline is set for results remembered from an earlier check because the service was unavailable
+		Offline bool
+	}
+
+	// Evidence records the validation of the VAT ID given at the checkout of a cart, kept for the tax
+	// authorities with the order
+	Evidence struct {
+		ID     uint `gorm:"primarykey"`
+		CartID uint `gorm:"uniqueIndex;not null"`
+		// VATID is the ID with its country prefix, e.g. "DE123456789"
+		VATID   string `gorm:"size:16;not null"`
+		Valid   bool   `gorm:"not null"`
+		Name    string `gorm:"size:255"`
+		Address string `gorm:"size:1024"`
+		// ConsultationNumber is the identifier of the check at the service
+		ConsultationNumber string `gorm:"size:64"`
+		CheckedAt          time.Time
+		// Offline is whether the service was unavailable and an earlier check was relied on
+		Offline bool `gorm:"not null"`
+		// Exempt is whether the order is zero-rated: the ID is valid and of another member state than the
+		// shop's
+		Exempt    bool `gorm:"not null"`
+		CreatedAt time.Time
+	}
+)
+
+// TableName keeps the table name singular, as evidence isn't counted.
+func (Evidence) TableName() string {
+	return "vat_evidence"
+}
+
+// Parse splits a VAT ID into its country prefix and number, ignoring case, spaces, dots and dashes.
+func Parse(id string) (country, number string, err error) {
+	id = separators.Replace(strings.ToUpper(strings.TrimSpace(id)))
+	if len(id) < 4 || !prefixes[id[:2]] || !numberPattern.MatchString(id[2:]) {
+		return "", "", ErrInvalidID
+	}
+	return id[:2], id[2:], nil
+}
+
+// Prefix returns the VAT ID prefix of the ISO country code, which is EL rather than GR for Greece.
+func Prefix(country string) string {
+	if country == "GR" {
+		return "EL"
+	}
+	return country
+}
+
+// Matches reports whether VAT IDs with the prefix are issued in the ISO country, so an order shipped
+// there can be zero-rated for them.
+func Matches(prefix, country string) bool {
+	return prefix == Prefix(country) || prefix == "XI" && country == "GB"
+}
+
+// NewEvidence records the result of validating the VAT ID for the checkout of a cart. The order is
+// exempt from tax if the ID is valid and not issued in shopPrefix, the member state of the shop.
+func NewEvidence(cartID uint, country, number string, result Result, shopPrefix string) *Evidence {
+	return &Evidence{
+		CartID:             cartID,
+		VATID:              country + number,
+		Valid:              result.Valid,
+		Name:               result.Name,
+		Address:            result.Address,
+		ConsultationNumber: result.ConsultationNumber,
+		CheckedAt:          result.CheckedAt,
+		Offline:            result.Offline,
+		Exempt:             result.Valid && country != shopPrefix,
+	}
+}
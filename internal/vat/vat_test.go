@@ -0,0 +1,202 @@
+package vat_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"interview/internal/vat"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      string
+		country string
+		number  string
+		wantErr bool
+	}{
+		{name: "Plain", id: "DE123456789", country: "DE", number: "123456789"},
+		{name: "Grouped And Lower Case", id: " de 123.456-789 ", country: "DE", number: "123456789"},
+		{name: "Letters In Number", id: "NL123456789B01", country: "NL", number: "123456789B01"},
+		{name: "Greece", id: "EL094259216", country: "EL", number: "094259216"},
+		{name: "Not EU", id: "US123456789", wantErr: true},
+		{name: "Greek ISO Code", id: "GR094259216", wantErr: true},
+		{name: "Too Short", id: "DE1", wantErr: true},
+		{name: "Too Long", id: "DE1234567890123", wantErr: true},
+		{name: "Invalid Characters", id: "DE12345678?", wantErr: true},
+		{name: "Empty", id: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			country, number, err := vat.Parse(tt.id)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, vat.ErrInvalidID)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.country, country)
+			assert.Equal(t, tt.number, number)
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	assert.True(t, vat.Matches("DE", "DE"))
+	assert.True(t, vat.Matches("EL", "GR"))
+	assert.True(t, vat.Matches("XI", "GB"))
+	assert.False(t, vat.Matches("DE", "AT"))
+	assert.False(t, vat.Matches("GR", "GR"))
+}
+
+func TestNewEvidence(t *testing.T) {
+	checked := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	valid := vat.Result{Valid: true, Name: "Doe GmbH", ConsultationNumber: "WAPIAAAAZ1", CheckedAt: checked}
+
+	evidence := vat.NewEvidence(7, "DE", "123456789", valid, "FR")
+	assert.Equal(t, uint(7), evidence.CartID)
+	assert.Equal(t, "DE123456789", evidence.VATID)
+	assert.Equal(t, "WAPIAAAAZ1", evidence.ConsultationNumber)
+	assert.Equal(t, checked, evidence.CheckedAt)
+	assert.True(t, evidence.Exempt)
+
+	assert.False(t, vat.NewEvidence(7, "DE", "123456789", valid, "DE").Exempt, "domestic orders are taxed")
+	assert.False(t, vat.NewEvidence(7, "DE", "123456789", vat.Result{}, "FR").Exempt, "invalid IDs are taxed")
+}
+
+func TestVIES(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Valid Number", func(t *testing.T) {
+		var got map[string]string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+			w.Write([]byte(`{"countryCode": "DE", "vatNumber": "123456789", "requestDate": "2026-01-01T12:00:00.000Z",
+				"valid": true, "requestIdentifier": "WAPIAAAAZ1", "name": "---", "address": "---"}`))
+		}))
+		defer server.Close()
+
+		result, err := vat.NewVIES(server.URL, "FR40303265045").Validate(ctx, "DE", "123456789")
+		require.NoError(t, err)
+		assert.True(t, result.Valid)
+		assert.Equal(t, "WAPIAAAAZ1", result.ConsultationNumber)
+		assert.Empty(t, result.Name, "details the member state doesn't share are left out")
+		assert.Equal(t, time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC), result.CheckedAt)
+		assert.Equal(t, map[string]string{"countryCode": "DE", "vatNumber": "123456789",
+			"requesterMemberStateCode": "FR", "requesterNumber": "40303265045"}, got)
+	})
+
+	t.Run("Invalid Number", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"countryCode": "DE", "vatNumber": "999999999", "valid": false}`))
+		}))
+		defer server.Close()
+
+		result, err := vat.NewVIES(server.URL, "").Validate(ctx, "DE", "999999999")
+		require.NoError(t, err)
+		assert.False(t, result.Valid)
+	})
+
+	t.Run("Malformed Number", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"actionSucceed": false, "errorWrappers": [{"error": "INVALID_INPUT"}]}`))
+		}))
+		defer server.Close()
+
+		result, err := vat.NewVIES(server.URL, "").Validate(ctx, "DE", "1")
+		require.NoError(t, err)
+		assert.False(t, result.Valid)
+	})
+
+	t.Run("Member State Unavailable", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"actionSucceed": false, "errorWrappers": [{"error": "MS_UNAVAILABLE"}]}`))
+		}))
+		defer server.Close()
+
+		_, err := vat.NewVIES(server.URL, "").Validate(ctx, "DE", "123456789")
+		assert.ErrorIs(t, err, vat.ErrUnavailable)
+		assert.ErrorContains(t, err, "MS_UNAVAILABLE")
+	})
+
+	t.Run("Service Down", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+
+		_, err := vat.NewVIES(server.URL, "").Validate(ctx, "DE", "123456789")
+		assert.ErrorIs(t, err, vat.ErrUnavailable)
+	})
+}
+
+// flaky validates every VAT ID as valid, or fails with err, and counts its calls
+type flaky struct {
+	err   error
+	calls int
+}
+
+func (f *flaky) Validate(context.Context, string, string) (vat.Result, error) {
+	f.calls++
+	if f.err != nil {
+		return vat.Result{}, f.err
+	}
+	return vat.Result{Valid: true, ConsultationNumber: "WAPIAAAAZ1"}, nil
+}
+
+func TestCached(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	validator := &flaky{}
+	cached := vat.NewCached(validator, time.Hour)
+	cached.SetClock(func() time.Time { return now })
+
+	t.Run("Caches Results", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			result, err := cached.Validate(ctx, "DE", "123456789")
+			require.NoError(t, err)
+			assert.True(t, result.Valid)
+			assert.False(t, result.Offline)
+		}
+		assert.Equal(t, 1, validator.calls)
+	})
+
+	t.Run("Falls Back To Expired Results While Unavailable", func(t *testing.T) {
+		now = now.Add(2 * time.Hour)
+		validator.err = vat.ErrUnavailable
+		result, err := cached.Validate(ctx, "DE", "123456789")
+		require.NoError(t, err)
+		assert.True(t, result.Valid)
+		assert.True(t, result.Offline)
+		assert.Equal(t, "WAPIAAAAZ1", result.ConsultationNumber)
+		assert.Equal(t, 2, validator.calls)
+	})
+
+	t.Run("Unknown IDs Fail While Unavailable", func(t *testing.T) {
+		_, err := cached.Validate(ctx, "DE", "987654321")
+		assert.ErrorIs(t, err, vat.ErrUnavailable)
+	})
+
+	t.Run("Other Errors Are Not Replaced", func(t *testing.T) {
+		validator.err = errors.New("bad request")
+		_, err := cached.Validate(ctx, "DE", "123456789")
+		assert.EqualError(t, err, "bad request")
+	})
+
+	t.Run("Refreshes Expired Results", func(t *testing.T) {
+		validator.err = nil
+		result, err := cached.Validate(ctx, "DE", "123456789")
+		require.NoError(t, err)
+		assert.False(t, result.Offline)
+		calls := validator.calls
+		_, err = cached.Validate(ctx, "DE", "123456789")
+		require.NoError(t, err)
+		assert.Equal(t, calls, validator.calls)
+	})
+}
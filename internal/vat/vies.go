@@ -0,0 +1,116 @@
+package vat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// VIESURL is the REST endpoint of VIES, the VAT Information Exchange System of the European Commission
+const VIESURL = "https://ec.europa.eu/taxation_customs/vies/rest-api/check-vat-number"
+
+type (
+	// VIES validates VAT IDs with the VIES service at URL. Checks made with the VAT ID of the shop as
+	// requester get a consultation number proving them.
+	VIES struct {
+		URL    string
+		Client *http.Client
+		// RequesterCountry and RequesterNumber are the VAT ID of the shop, empty for anonymous checks
+		RequesterCountry string
+		RequesterNumber  string
+	}
+
+	viesRequest struct {
+		CountryCode              string `json:"countryCode"`
+		VATNumber                string `json:"vatNumber"`
+		RequesterMemberStateCode string `json:"requesterMemberStateCode,omitempty"`
+		RequesterNumber          string `json:"requesterNumber,omitempty"`
+	}
+
+	viesResponse struct {
+		Valid             bool      `json:"valid"`
+		Name              string    `json:"name"`
+		Address           string    `json:"address"`
+		RequestIdentifier string    `json:"requestIdentifier"`
+		RequestDate       time.Time `json:"requestDate"`
+	}
+
+	viesError struct {
+		ErrorWrappers []struct {
+			Error string `json:"error"`
+		} `json:"errorWrappers"`
+	}
+)
+
+// NewVIES creates a VIES client with a client that times out after a few seconds. requesterID is the
+// VAT ID of the shop, empty for anonymous checks.
+func NewVIES(url, requesterID string) *VIES {
+	v := &VIES{URL: url, Client: &http.Client{Timeout: 10 * time.Second}}
+	if country, number, err := Parse(requesterID); err == nil {
+		v.RequesterCountry, v.RequesterNumber = country, number
+	}
+	return v
+}
+
+// Validate implements Validator. Numbers VIES rejects as malformed are invalid; every other error of the
+// service is ErrUnavailable.
+func (v *VIES) Validate(ctx context.Context, country, number string) (Result, error) {
+	body, err := json.Marshal(viesRequest{
+		CountryCode:              country,
+		VATNumber:                number,
+		RequesterMemberStateCode: v.RequesterCountry,
+		RequesterNumber:          v.RequesterNumber,
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.URL, bytes.NewReader(body))
+	if err != nil {
+		return Result{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := v.Client.Do(req)
+	if err != nil {
+		return Result{}, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var e viesError
+		if json.NewDecoder(resp.Body).Decode(&e) == nil && len(e.ErrorWrappers) > 0 {
+			code := e.ErrorWrappers[0].Error
+			if code == "INVALID_INPUT" {
+				return Result{CheckedAt: time.Now()}, nil
+			}
+			return Result{}, fmt.Errorf("%w: %s", ErrUnavailable, code)
+		}
+		return Result{}, fmt.Errorf("%w: status %d", ErrUnavailable, resp.StatusCode)
+	}
+
+	var r viesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return Result{}, fmt.Errorf("%w: failed to decode response: %v", ErrUnavailable, err)
+	}
+	if r.RequestDate.IsZero() {
+		r.RequestDate = time.Now()
+	}
+	return Result{
+		Valid:              r.Valid,
+		Name:               hidden(r.Name),
+		Address:            hidden(r.Address),
+		ConsultationNumber: r.RequestIdentifier,
+		CheckedAt:          r.RequestDate,
+	}, nil
+}
+
+// hidden returns empty for the "---" VIES answers with when a member state doesn't share the detail
+func hidden(detail string) string {
+	if detail == "---" {
+		return ""
+	}
+	return detail
+}
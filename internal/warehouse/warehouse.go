@@ -0,0 +1,151 @@
+// Package warehouse keeps the stock of products per warehouse and decides which warehouses the items of
+// checked out carts are shipped from.
+package warehouse
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// StrategyNearest ships from the warehouses in the country of the order first
+	StrategyNearest = "nearest"
+	// StrategyMostStock ships from the warehouses with the most units of the product first
+	StrategyMostStock = "most-stock"
+)
+
+var (
+	// ErrWarehouseNotFound is returned for warehouses that don't exist
+	ErrWarehouseNotFound = errors.New("warehouse not found")
+	// ErrInvalidStrategy is returned for strategies other than StrategyNearest and StrategyMostStock
+	ErrInvalidStrategy = errors.New("allocation strategy must be nearest or most-stock")
+	// ErrStockInWarehouses is returned when setting the stock of a product kept in warehouses directly
+	ErrStockInWarehouses = errors.New("stock of the product is kept per warehouse")
+)
+
+type (
+	// Warehouse is a location products are shipped from
+	Warehouse struct {
+		gorm.Model
+		Name string `gorm:"size:255;uniqueIndex;not null"`
+		// Country is the ISO 3166-1 alpha-2 code of the country the warehouse is in
+		Country string `gorm:"size:2;not null"`
+	}
+
+	// Stock is the number of units of a product in a warehouse. The stock of a product kept in
+	// warehouses is the sum of its stock in all of them.
+	Stock struct {
+		ID          uint `gorm:"primarykey"`
+		WarehouseID uint `gorm:"not null;uniqueIndex:idx_warehouse_stock"`
+		ProductID   uint `gorm:"not null;uniqueIndex:idx_warehouse_stock;index"`
+		Quantity    int  `gorm:"not null"`
+	}
+
+	// Allocation records that Quantity units of a checked out cart item ship from a warehouse
+	Allocation struct {
+		ID          uint `gorm:"primarykey"`
+		CartItemID  uint `gorm:"index;not null"`
+		WarehouseID uint `gorm:"index;not null"`
+		Quantity    int  `gorm:"not null"`
+	}
+
+	// Level is the stock of a product in a warehouse, as seen by a Strategy
+	Level struct {
+		WarehouseID uint
+		Country     string
+		Quantity    int
+	}
+
+	// Pick takes Quantity units from a warehouse
+	Pick struct {
+		WarehouseID uint
+		Quantity    int
+	}
+
+	// Strategy decides which warehouses the units of an item shipped to a country are taken from
+	Strategy interface {
+		// Allocate picks up to quantity units from the levels, never more than a warehouse has. Units
+		// left over are backordered.
+		Allocate(country string, quantity int, levels []Level) []Pick
+	}
+
+	// Nearest takes units from the warehouses in the country the item is shipped to first, then from
+	// the others in the order they were added
+	Nearest struct{}
+
+	// MostStock takes units from the warehouses with the most units first, so items split over as few
+	// shipments as possible
+	MostStock struct{}
+)
+
+// TableName names the table after what it keeps the stock of.
+func (Stock) TableName() string {
+	return "warehouse_stock"
+}
+
+// TableName prefixes the table with what is allocated.
+func (Allocation) TableName() string {
+	return "item_allocations"
+}
+
+// NewStrategy returns the strategy of the name, StrategyNearest or StrategyMostStock.
+func NewStrategy(name string) (Strategy, error) {
+	switch name {
+	case StrategyNearest:
+		return Nearest{}, nil
+	case StrategyMostStock:
+		return MostStock{}, nil
+	}
+	return nil, ErrInvalidStrategy
+}
+
+// Allocate implements Strategy.
+func (Nearest) Allocate(country string, quantity int, levels []Level) []Pick {
+	ranked := sortedLevels(levels, func(a, b Level) bool {
+		return strings.EqualFold(a.Country, country) && !strings.EqualFold(b.Country, country)
+	})
+	return pick(quantity, ranked)
+}
+
+// Allocate implements Strategy.
+func (MostStock) Allocate(_ string, quantity int, levels []Level) []Pick {
+	ranked := sortedLevels(levels, func(a, b Level) bool {
+		return a.Quantity > b.Quantity
+	})
+	return pick(quantity, ranked)
+}
+
+// sortedLevels copies the levels ordered by before, then by warehouse
+func sortedLevels(levels []Level, before func(a, b Level) bool) []Level {
+	sorted := append([]Level(nil), levels...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if before(sorted[i], sorted[j]) {
+			return true
+		}
+		if before(sorted[j], sorted[i]) {
+			return false
+		}
+		return sorted[i].WarehouseID < sorted[j].WarehouseID
+	})
+	return sorted
+}
+
+// pick takes up to quantity units from the levels in order
+func pick(quantity int, levels []Level) []Pick {
+	var picks []Pick
+	for _, level := range levels {
+		if quantity == 0 {
+			break
+		}
+		if level.Quantity <= 0 {
+			continue
+		}
+		n := min(quantity, level.Quantity)
+		picks = append(picks, Pick{WarehouseID: level.WarehouseID, Quantity: n})
+		quantity -= n
+	}
+	return picks
+}
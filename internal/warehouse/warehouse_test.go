@@ -0,0 +1,59 @@
+package warehouse_test
+
+import (
+	"interview/internal/warehouse"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrategies(t *testing.T) {
+	levels := []warehouse.Level{
+		{WarehouseID: 1, Country: "NL", Quantity: 2},
+		{WarehouseID: 2, Country: "DE", Quantity: 1},
+		{WarehouseID: 3, Country: "DE", Quantity: 5},
+		{WarehouseID: 4, Country: "FR", Quantity: 0},
+	}
+	tests := []struct {
+		name     string
+		strategy warehouse.Strategy
+		country  string
+		quantity int
+		want     []warehouse.Pick
+	}{
+		{
+			name: "Nearest Prefers The Country", strategy: warehouse.Nearest{}, country: "de", quantity: 3,
+			want: []warehouse.Pick{{WarehouseID: 2, Quantity: 1}, {WarehouseID: 3, Quantity: 2}},
+		},
+		{
+			name: "Nearest Falls Back To Other Countries", strategy: warehouse.Nearest{}, country: "DE", quantity: 7,
+			want: []warehouse.Pick{{WarehouseID: 2, Quantity: 1}, {WarehouseID: 3, Quantity: 5}, {WarehouseID: 1, Quantity: 1}},
+		},
+		{
+			name: "Nearest Without Country", strategy: warehouse.Nearest{}, quantity: 3,
+			want: []warehouse.Pick{{WarehouseID: 1, Quantity: 2}, {WarehouseID: 2, Quantity: 1}},
+		},
+		{
+			name: "Most Stock Prefers Full Warehouses", strategy: warehouse.MostStock{}, country: "NL", quantity: 3,
+			want: []warehouse.Pick{{WarehouseID: 3, Quantity: 3}},
+		},
+		{
+			name: "Shortage Is Backordered", strategy: warehouse.MostStock{}, quantity: 10,
+			want: []warehouse.Pick{{WarehouseID: 3, Quantity: 5}, {WarehouseID: 1, Quantity: 2}, {WarehouseID: 2, Quantity: 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.strategy.Allocate(tt.country, tt.quantity, levels))
+		})
+	}
+}
+
+func TestNewStrategy(t *testing.T) {
+	strategy, err := warehouse.NewStrategy(warehouse.StrategyMostStock)
+	require.NoError(t, err)
+	assert.Equal(t, warehouse.MostStock{}, strategy)
+	_, err = warehouse.NewStrategy("cheapest")
+	assert.ErrorIs(t, err, warehouse.ErrInvalidStrategy)
+}
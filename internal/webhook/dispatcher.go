@@ -0,0 +1,200 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"interview/internal/events"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// MaxAttempts is how many times a delivery is tried before it is marked dead
+	MaxAttempts = 8
+	// retryBackoff is the delay before the first retry, doubled for every following one
+	retryBackoff = 30 * time.Second
+	// maxRetryBackoff caps the delay between retries
+	maxRetryBackoff = 2 * time.Hour
+	// claimLease is how long a claimed delivery is hidden from other dispatchers while being sent
+	claimLease = time.Minute
+	// batchSize is the largest number of deliveries sent by one run
+	batchSize = 50
+	// requestTimeout bounds a single delivery request
+	requestTimeout = 10 * time.Second
+)
+
+type (
+	// Store persists endpoints and deliveries
+	Store interface {
+		// ListWebhooks returns all registered endpoints
+		ListWebhooks() ([]Endpoint, error)
+		// EnqueueWebhookDeliveries creates a pending delivery of the payload for every endpoint subscribed to the event
+		EnqueueWebhookDeliveries(event string, payload []byte, at time.Time) (int, error)
+		// ClaimWebhookDeliveries returns up to limit due deliveries with their endpoint, hiding them from
+		// other callers for lease
+		ClaimWebhookDeliveries(now time.Time, lease time.Duration, limit int) ([]Delivery, error)
+		// SaveWebhookDelivery stores the outcome of a delivery attempt
+		SaveWebhookDelivery(d *Delivery) error
+	}
+
+	// Dispatcher turns events into deliveries and sends the deliveries that are due
+	Dispatcher struct {
+		store  Store
+		client *http.Client
+		now    func() time.Time
+	}
+
+	// Envelope is the JSON body of every delivery
+	Envelope struct {
+		ID        string      `json:"id"`
+		Event     string      `json:"event"`
+		CreatedAt time.Time   `json:"created_at"`
+		Data      interface{} `json:"data"`
+	}
+)
+
+// NewDispatcher creates a Dispatcher storing deliveries in store
+func NewDispatcher(store Store) *Dispatcher {
+	return &Dispatcher{
+		store:  store,
+		client: &http.Client{Timeout: requestTimeout},
+		now:    time.Now,
+	}
+}
+
+// Publish queues the event for every subscribed endpoint
+func (d *Dispatcher) Publish(event string, data interface{}) error {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Errorf("failed to generate event ID: %w", err)
+	}
+	now := d.now()
+	payload, err := json.Marshal(Envelope{
+		ID:        "evt_" + hex.EncodeToString(id),
+		Event:     event,
+		CreatedAt: now.UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	_, err = d.store.EnqueueWebhookDeliveries(event, payload, now)
+	return err
+}
+
+// Wants reports whether an endpoint subscribes to the cart events of the type. It makes the Dispatcher
+// an events.Consumer: attached to the bus, cart events are queued as "cart.<type>" webhook events while
+// they are published, so none are lost when deliveries can't keep up.
+func (d *Dispatcher) Wants(eventType string) bool {
+	endpoints, err := d.store.ListWebhooks()
+	if err != nil {
+		log.Printf("Failed to list webhooks: %v", err)
+		// Queueing the event finds out for sure
+		return true
+	}
+	for _, endpoint := range endpoints {
+		if endpoint.Subscribes("cart." + eventType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handle queues the cart event as a "cart.<type>" webhook event
+func (d *Dispatcher) Handle(e events.Event) error {
+	return d.Publish("cart."+e.Type, e)
+}
+
+// DeliverDue sends the deliveries that are due. Failed deliveries are rescheduled with exponential
+// backoff until MaxAttempts is reached, then marked dead. Failures of endpoints are recorded in the
+// delivery log; only errors storing the outcome are returned.
+func (d *Dispatcher) DeliverDue(ctx context.Context) error {
+	deliveries, err := d.store.ClaimWebhookDeliveries(d.now(), claimLease, batchSize)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i := range deliveries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := d.deliver(ctx, &deliveries[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deliver makes one attempt and records its outcome, returning an error only when that fails
+func (d *Dispatcher) deliver(ctx context.Context, delivery *Delivery) error {
+	delivery.Attempts++
+	status, err := d.send(ctx, delivery)
+	delivery.ResponseStatus = status
+
+	now := d.now()
+	switch {
+	case err == nil:
+		delivery.Status = StatusDelivered
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+	case delivery.Attempts >= MaxAttempts:
+		delivery.Status = StatusDead
+		delivery.LastError = truncate(err.Error(), 1024)
+	default:
+		delivery.Status = StatusPending
+		delivery.LastError = truncate(err.Error(), 1024)
+		delivery.NextAttemptAt = now.Add(backoff(delivery.Attempts))
+	}
+	return d.store.SaveWebhookDelivery(delivery)
+}
+
+// send POSTs the payload and returns the response status
+func (d *Dispatcher) send(ctx context.Context, delivery *Delivery) (int, error) {
+	payload := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Endpoint.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "interview-webhooks/1.0")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(SignatureHeader, Sign(delivery.Endpoint.Secret, d.now(), payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns the delay before the attempt following the given number of failed attempts
+func backoff(attempts int) time.Duration {
+	delay := retryBackoff
+	for i := 1; i < attempts && delay < maxRetryBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryBackoff)
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,195 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"interview/internal/events"
+	"interview/internal/repo"
+	"interview/internal/webhook"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupRepo(t *testing.T) *repo.Repository {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, repo.Migrate(db))
+	return repo.NewRepository(db)
+}
+
+func TestSign(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	signature := webhook.Sign("secret", at, []byte(`{"event":"cart.item_added"}`))
+	// echo -n '1700000000.{"event":"cart.item_added"}' | openssl dgst -sha256 -hmac secret
+	assert.Equal(t, "t=1700000000,v1=f5ca8dfc4a55d3a680a2d586dd548229898b7d975e24d15cc181606ff021f17a", signature)
+}
+
+func TestVerify(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	payload := []byte(`{"event":"cart.item_added"}`)
+	signature := webhook.Sign("secret", at, payload)
+
+	tests := []struct {
+		name      string
+		secret    string
+		signature string
+		payload   []byte
+		now       time.Time
+		wantErr   bool
+	}{
+		{"Valid", "secret", signature, payload, at.Add(time.Minute), false},
+		{"Other Secret", "other", signature, payload, at, true},
+		{"Changed Payload", "secret", signature, []byte(`{"event":"order.created"}`), at, true},
+		{"Too Old", "secret", signature, payload, at.Add(10 * time.Minute), true},
+		{"From The Future", "secret", signature, payload, at.Add(-10 * time.Minute), true},
+		{"Malformed", "secret", "v1=abc", payload, at, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := webhook.Verify(tt.secret, tt.signature, tt.payload, tt.now, 5*time.Minute)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, webhook.ErrInvalidSignature)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestEndpointSubscribes(t *testing.T) {
+	tests := []struct {
+		name   string
+		events string
+		event  string
+		want   bool
+	}{
+		{"Listed Event", "cart.item_added,cart.item_removed", "cart.item_removed", true},
+		{"Unlisted Event", "cart.item_added", "cart.item_removed", false},
+		{"Wildcard", "*", "order.created", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, webhook.Endpoint{Events: tt.events}.Subscribes(tt.event))
+		})
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	var status atomic.Int32
+	var received atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received.Store(r.Header.Get(webhook.SignatureHeader) + "|" + r.Header.Get(webhook.EventHeader) + "|" + string(body))
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer server.Close()
+
+	cartRepo := setupRepo(t)
+	endpoint, err := cartRepo.CreateWebhook(server.URL, "secret", []string{"cart.item_added"})
+	require.NoError(t, err)
+	_, err = cartRepo.CreateWebhook(server.URL+"/other", "secret", []string{"order.created"})
+	require.NoError(t, err)
+
+	now := time.Now()
+	dispatcher := webhook.NewDispatcher(cartRepo)
+	dispatcher.SetNow(func() time.Time { return now })
+
+	deliveries := func(t *testing.T) []webhook.Delivery {
+		list, err := cartRepo.ListWebhookDeliveries(endpoint.ID, "", 10)
+		require.NoError(t, err)
+		return list
+	}
+
+	t.Run("Delivers Signed Payloads To Subscribers", func(t *testing.T) {
+		status.Store(http.StatusOK)
+		require.NoError(t, dispatcher.Publish("cart.item_added", events.Event{Type: events.TypeItemAdded, CartID: 7}))
+		require.NoError(t, dispatcher.DeliverDue(context.Background()))
+
+		list := deliveries(t)
+		require.Len(t, list, 1)
+		assert.Equal(t, webhook.StatusDelivered, list[0].Status)
+		assert.Equal(t, 1, list[0].Attempts)
+		assert.Equal(t, http.StatusOK, list[0].ResponseStatus)
+
+		var envelope struct {
+			Event string       `json:"event"`
+			Data  events.Event `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(list[0].Payload), &envelope))
+		assert.Equal(t, "cart.item_added", envelope.Event)
+		assert.Equal(t, uint(7), envelope.Data.CartID)
+
+		expected := webhook.Sign("secret", now, []byte(list[0].Payload)) + "|cart.item_added|" + list[0].Payload
+		assert.Equal(t, expected, received.Load())
+	})
+
+	t.Run("Retries With Backoff Then Gives Up", func(t *testing.T) {
+		status.Store(http.StatusInternalServerError)
+		require.NoError(t, dispatcher.Publish("cart.item_added", events.Event{Type: events.TypeItemAdded, CartID: 8}))
+
+		var previousDelay time.Duration
+		for attempt := 1; attempt <= webhook.MaxAttempts; attempt++ {
+			// Failures of the endpoint are recorded in the delivery log, not returned
+			require.NoError(t, dispatcher.DeliverDue(context.Background()))
+
+			d := deliveries(t)[0]
+			assert.Equal(t, attempt, d.Attempts)
+			assert.Equal(t, http.StatusInternalServerError, d.ResponseStatus)
+			if attempt < webhook.MaxAttempts {
+				require.Equal(t, webhook.StatusPending, d.Status)
+				delay := d.NextAttemptAt.Sub(now)
+				assert.Greater(t, delay, previousDelay, "attempt "+strconv.Itoa(attempt))
+				previousDelay = delay
+
+				// Nothing is sent before the delivery is due
+				require.NoError(t, dispatcher.DeliverDue(context.Background()))
+				now = d.NextAttemptAt
+			} else {
+				assert.Equal(t, webhook.StatusDead, d.Status)
+			}
+		}
+
+		now = now.Add(24 * time.Hour)
+		require.NoError(t, dispatcher.DeliverDue(context.Background()))
+		assert.Equal(t, webhook.MaxAttempts, deliveries(t)[0].Attempts)
+	})
+
+	t.Run("Dead Deliveries Can Be Retried", func(t *testing.T) {
+		status.Store(http.StatusNoContent)
+		dead := deliveries(t)[0]
+		require.NoError(t, cartRepo.RetryWebhookDelivery(dead.ID, now))
+		assert.ErrorIs(t, cartRepo.RetryWebhookDelivery(dead.ID, now), repo.ErrDeliveryNotRetryable)
+
+		require.NoError(t, dispatcher.DeliverDue(context.Background()))
+		d := deliveries(t)[0]
+		assert.Equal(t, webhook.StatusDelivered, d.Status)
+		assert.Equal(t, 1, d.Attempts)
+	})
+
+	t.Run("Queues Bus Events", func(t *testing.T) {
+		status.Store(http.StatusOK)
+		bus := events.NewBus()
+		bus.Attach(dispatcher)
+		assert.True(t, bus.Wants(events.TypeItemAdded))
+		assert.False(t, bus.Wants(events.TypeItemRemoved))
+
+		// Unlike subscribers, the dispatcher doesn't miss events however many are published at once
+		for i := 0; i < 100; i++ {
+			bus.Publish(events.Event{Type: events.TypeItemAdded, CartID: uint(i)})
+		}
+		pending, err := cartRepo.ListWebhookDeliveries(endpoint.ID, webhook.StatusPending, 200)
+		require.NoError(t, err)
+		require.Len(t, pending, 100)
+		assert.Equal(t, "cart.item_added", pending[0].Event)
+	})
+}
@@ -0,0 +1,6 @@
+package webhook
+
+import "time"
+
+// SetNow overrides the clock used to schedule and sign deliveries.
+func (d *Dispatcher) SetNow(now func() time.Time) { d.now = now }